@@ -3,14 +3,20 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
+	"github.com/xataio/pgstream/pkg/debug"
+	"github.com/xataio/pgstream/pkg/health"
 	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/stream"
+	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/processor/batch"
 	"github.com/xataio/pgstream/pkg/wal/processor/transformer"
 	"gopkg.in/yaml.v3"
@@ -22,6 +28,8 @@ const (
 	defaultPostgresBulkBatchBytes   = int64(80 * 1024 * 1024) // 80MiB
 )
 
+var errDebugAddressInUse = errors.New("debug listen address must be different from the health and metrics addresses")
+
 func Load() error {
 	return LoadFile(viper.GetString("config"))
 }
@@ -69,6 +77,94 @@ func ParseInstrumentationConfig() (*otel.Config, error) {
 	}
 }
 
+func ParseShutdownConfig() (*wal.ShutdownConfig, error) {
+	cfgFile := viper.GetViper().ConfigFileUsed()
+	switch ext := filepath.Ext(cfgFile); ext {
+	case ".yml", ".yaml":
+		yamlCfg := struct {
+			Shutdown ShutdownConfig `mapstructure:"shutdown" yaml:"shutdown"`
+		}{}
+		if err := viper.Unmarshal(&yamlCfg); err != nil {
+			return nil, fmt.Errorf("invalid format for shutdown in config file %q: %w", cfgFile, err)
+		}
+
+		return yamlCfg.Shutdown.toWALShutdownConfig(), nil
+	default:
+		return envToShutdownConfig(), nil
+	}
+}
+
+// ParseHealthConfig returns the configuration of the health endpoints, or nil
+// if they're not enabled.
+func ParseHealthConfig() (*health.Config, error) {
+	cfgFile := viper.GetViper().ConfigFileUsed()
+	switch ext := filepath.Ext(cfgFile); ext {
+	case ".yml", ".yaml":
+		yamlCfg := struct {
+			Health *HealthConfig `mapstructure:"health" yaml:"health"`
+		}{}
+		if err := viper.Unmarshal(&yamlCfg); err != nil {
+			return nil, fmt.Errorf("invalid format for health in config file %q: %w", cfgFile, err)
+		}
+
+		return yamlCfg.Health.toHealthConfig(), nil
+	default:
+		return envToHealthConfig(), nil
+	}
+}
+
+// ParseDebugConfig returns the configuration of the debug endpoints, or nil if
+// they're not enabled. The debug server can't share its address with the
+// health or metrics servers, since it's not meant to be publicly reachable.
+func ParseDebugConfig() (*debug.Config, error) {
+	var debugConfig *debug.Config
+	cfgFile := viper.GetViper().ConfigFileUsed()
+	switch ext := filepath.Ext(cfgFile); ext {
+	case ".yml", ".yaml":
+		yamlCfg := struct {
+			Debug *DebugConfig `mapstructure:"debug" yaml:"debug"`
+		}{}
+		if err := viper.Unmarshal(&yamlCfg); err != nil {
+			return nil, fmt.Errorf("invalid format for debug in config file %q: %w", cfgFile, err)
+		}
+		debugConfig = yamlCfg.Debug.toDebugConfig()
+	default:
+		debugConfig = envToDebugConfig()
+	}
+	if debugConfig == nil || debugConfig.ListenAddress == "" {
+		return debugConfig, nil
+	}
+
+	healthConfig, err := ParseHealthConfig()
+	if err != nil {
+		return nil, err
+	}
+	instrumentationConfig, err := ParseInstrumentationConfig()
+	if err != nil {
+		return nil, err
+	}
+	addresses := []string{}
+	if healthConfig != nil {
+		addresses = append(addresses, healthConfig.ListenAddress)
+	}
+	if instrumentationConfig.Metrics != nil && instrumentationConfig.Metrics.Prometheus != nil {
+		addresses = append(addresses, instrumentationConfig.Metrics.Prometheus.ListenAddress)
+	}
+	if slices.Contains(addresses, debugConfig.ListenAddress) {
+		return nil, fmt.Errorf("%w: %s", errDebugAddressInUse, debugConfig.ListenAddress)
+	}
+	return debugConfig, nil
+}
+
+// ReloadStreamConfig reads the config file again and parses the stream
+// config from it.
+func ReloadStreamConfig() (*stream.Config, error) {
+	if err := Load(); err != nil {
+		return nil, err
+	}
+	return ParseStreamConfig()
+}
+
 func ParseStreamConfig() (*stream.Config, error) {
 	cfgFile := viper.GetViper().ConfigFileUsed()
 	switch ext := filepath.Ext(cfgFile); ext {
@@ -99,6 +195,43 @@ func ParseStreamConfig() (*stream.Config, error) {
 	}
 }
 
+// ParsePipelinesConfig returns the configuration of the pipelines defined in
+// the config file. Multiple pipelines are only supported with yaml
+// configuration files, if none are defined an empty list is returned.
+func ParsePipelinesConfig() ([]stream.PipelineConfig, error) {
+	cfgFile := viper.GetViper().ConfigFileUsed()
+	switch ext := filepath.Ext(cfgFile); ext {
+	case ".yml", ".yaml":
+		pipelinesCfg := PipelinesConfig{}
+		if err := viper.Unmarshal(&pipelinesCfg); err != nil {
+			return nil, fmt.Errorf("invalid format for pipelines in config file %q: %w", cfgFile, err)
+		}
+		if len(pipelinesCfg.Pipelines) == 0 {
+			return nil, nil
+		}
+
+		buf, err := os.ReadFile(cfgFile)
+		if err != nil {
+			return nil, err
+		}
+		// yaml.Unmarshal is used for the transformers configuration to support
+		// case sensitive keys, same as with the single stream configuration.
+		yamlLibCfg := PipelinesConfig{}
+		if err := yaml.Unmarshal(buf, &yamlLibCfg); err != nil {
+			return nil, fmt.Errorf("invalid format for pipelines in file %q: %w", cfgFile, err)
+		}
+		for i := range pipelinesCfg.Pipelines {
+			if i < len(yamlLibCfg.Pipelines) {
+				pipelinesCfg.Pipelines[i].Modifiers.Transformations = yamlLibCfg.Pipelines[i].Modifiers.Transformations
+			}
+		}
+
+		return pipelinesCfg.toPipelinesConfig()
+	default:
+		return nil, nil
+	}
+}
+
 func ParseTransformerConfig(filename string) (*transformer.Config, error) {
 	if filename == "" {
 		return nil, nil
@@ -142,3 +275,14 @@ func getRolesSnapshotMode(mode string) (string, error) {
 		return "", errUnsupportedRolesSnapshotMode
 	}
 }
+
+func toWALActions(actions []string) []wal.Action {
+	if len(actions) == 0 {
+		return nil
+	}
+	walActions := make([]wal.Action, 0, len(actions))
+	for _, action := range actions {
+		walActions = append(walActions, wal.Action(strings.ToUpper(action)))
+	}
+	return walActions
+}
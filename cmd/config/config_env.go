@@ -4,27 +4,60 @@ package config
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/spf13/viper"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/audit/pgaudit"
 	"github.com/xataio/pgstream/pkg/backoff"
+	"github.com/xataio/pgstream/pkg/debug"
+	"github.com/xataio/pgstream/pkg/health"
 	"github.com/xataio/pgstream/pkg/kafka"
+	"github.com/xataio/pgstream/pkg/metrics/cloudwatch"
+	"github.com/xataio/pgstream/pkg/metrics/prometheus"
 	"github.com/xataio/pgstream/pkg/otel"
 	pgschemalog "github.com/xataio/pgstream/pkg/schemalog/postgres"
 	pgsnapshotgenerator "github.com/xataio/pgstream/pkg/snapshot/generator/postgres/data"
 	"github.com/xataio/pgstream/pkg/snapshot/generator/postgres/schema/pgdumprestore"
 	"github.com/xataio/pgstream/pkg/stream"
 	"github.com/xataio/pgstream/pkg/tls"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer/committer"
+	filecheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/file"
 	kafkacheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/kafka"
+	pgcheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/postgres"
+	redischeckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/redis"
+	pglistener "github.com/xataio/pgstream/pkg/wal/listener/postgres"
 	"github.com/xataio/pgstream/pkg/wal/listener/snapshot/adapter"
 	snapshotbuilder "github.com/xataio/pgstream/pkg/wal/listener/snapshot/builder"
+	"github.com/xataio/pgstream/pkg/wal/processor/amqp"
 	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	"github.com/xataio/pgstream/pkg/wal/processor/coalesce"
+	"github.com/xataio/pgstream/pkg/wal/processor/converter"
+	"github.com/xataio/pgstream/pkg/wal/processor/dynamodb"
+	"github.com/xataio/pgstream/pkg/wal/processor/filelog"
 	"github.com/xataio/pgstream/pkg/wal/processor/filter"
+	"github.com/xataio/pgstream/pkg/wal/processor/healthcheck"
 	"github.com/xataio/pgstream/pkg/wal/processor/injector"
 	kafkaprocessor "github.com/xataio/pgstream/pkg/wal/processor/kafka"
+	"github.com/xataio/pgstream/pkg/wal/processor/kafka/partition"
+	"github.com/xataio/pgstream/pkg/wal/processor/migration"
 	"github.com/xataio/pgstream/pkg/wal/processor/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/postgres/fkorder"
+	"github.com/xataio/pgstream/pkg/wal/processor/pubsub"
+	"github.com/xataio/pgstream/pkg/wal/processor/redshift"
+	"github.com/xataio/pgstream/pkg/wal/processor/scd"
 	"github.com/xataio/pgstream/pkg/wal/processor/search"
 	"github.com/xataio/pgstream/pkg/wal/processor/search/store"
+	"github.com/xataio/pgstream/pkg/wal/processor/sequencer"
+	"github.com/xataio/pgstream/pkg/wal/processor/snowflake"
+	"github.com/xataio/pgstream/pkg/wal/processor/throttle"
+	"github.com/xataio/pgstream/pkg/wal/processor/toast"
+	"github.com/xataio/pgstream/pkg/wal/processor/transform"
 	"github.com/xataio/pgstream/pkg/wal/processor/transformer"
+	"github.com/xataio/pgstream/pkg/wal/processor/typeevolution"
+	"github.com/xataio/pgstream/pkg/wal/processor/validate"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/notifier"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/server"
 	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
@@ -33,17 +66,86 @@ import (
 func init() {
 	viper.BindEnv("PGSTREAM_METRICS_ENDPOINT")
 	viper.BindEnv("PGSTREAM_METRICS_COLLECTION_INTERVAL")
+	viper.BindEnv("PGSTREAM_METRICS_MAX_TABLE_CARDINALITY")
+	viper.BindEnv("PGSTREAM_METRICS_CLOUDWATCH_NAMESPACE")
+	viper.BindEnv("PGSTREAM_METRICS_CLOUDWATCH_REGION")
+	viper.BindEnv("PGSTREAM_METRICS_CLOUDWATCH_HIGH_RESOLUTION")
+	viper.BindEnv("PGSTREAM_METRICS_CLOUDWATCH_DIMENSIONS")
+	viper.BindEnv("PGSTREAM_METRICS_PROMETHEUS_LISTEN_ADDRESS")
 	viper.BindEnv("PGSTREAM_TRACES_ENDPOINT")
 	viper.BindEnv("PGSTREAM_TRACES_SAMPLE_RATIO")
 
+	viper.BindEnv("PGSTREAM_SHUTDOWN_TIMEOUT")
+
 	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_URL")
+	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_AUTH_MODE")
+	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_AWS_REGION")
 	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_EXP_BACKOFF_INITIAL_INTERVAL")
 	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_EXP_BACKOFF_MAX_INTERVAL")
 	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_EXP_BACKOFF_MAX_RETRIES")
 	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_BACKOFF_INTERVAL")
 	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_BACKOFF_MAX_RETRIES")
 	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_DISABLE_RETRIES")
+	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_RECONNECT_EXP_BACKOFF_INITIAL_INTERVAL")
+	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_RECONNECT_EXP_BACKOFF_MAX_INTERVAL")
+	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_RECONNECT_EXP_BACKOFF_MAX_RETRIES")
+	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_RECONNECT_BACKOFF_INTERVAL")
+	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_RECONNECT_BACKOFF_MAX_RETRIES")
+	viper.BindEnv("PGSTREAM_POSTGRES_LISTENER_RECONNECT_DISABLE_RETRIES")
 	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_SLOT_NAME")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_SLOT_TEMPORARY")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_SLOT_TWO_PHASE")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_SLOT_FAILOVER")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_SLOT_CREATE_IF_MISSING")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_PLUGIN_NAME")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_PLUGIN_FORMAT_VERSION")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_PLUGIN_INCLUDE_TRANSACTION")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_SLOT_MONITOR_ENABLED")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_SLOT_MONITOR_CHECK_INTERVAL")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_SLOT_MONITOR_RETAINED_WAL_LIMIT_BYTES")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_SLOT_MONITOR_PAUSE_SNAPSHOTS_ON_LIMIT")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_HEARTBEAT_ENABLED")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_HEARTBEAT_INTERVAL")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_HEARTBEAT_TABLE_NAME")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_HEARTBEAT_FORWARD_EVENTS")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_PUBLICATION_NAME")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_STANDBY_STATUS_INTERVAL")
+	viper.BindEnv("PGSTREAM_CHECKPOINT_COMMIT_INTERVAL")
+	viper.BindEnv("PGSTREAM_CHECKPOINT_MAX_UNCOMMITTED_EVENTS")
+	viper.BindEnv("PGSTREAM_CHECKPOINT_MAX_UNCOMMITTED_BYTES")
+	viper.BindEnv("PGSTREAM_CHECKPOINT_DISABLE_SHUTDOWN_COMMIT")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_TABLE_ENABLED")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_TABLE_URL")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_TABLE_PIPELINE_ID")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_TABLE_NAME")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_FILE_PATH")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_FILE_FLUSH_INTERVAL")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_FILE_RESET")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_ADDRESSES")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_MODE")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_MASTER_NAME")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_USERNAME")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_PASSWORD")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_SENTINEL_USERNAME")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_SENTINEL_PASSWORD")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_DB")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_TLS_ENABLED")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_TLS_CA_CERT_FILE")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_TLS_CLIENT_CERT_FILE")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_TLS_CLIENT_KEY_FILE")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_STREAM_ID")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_KEY_PREFIX")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_KEY_TTL")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_COMMIT_INTERVAL")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_MAX_UNCOMMITTED_WINDOW")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_EXP_BACKOFF_INITIAL_INTERVAL")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_EXP_BACKOFF_MAX_INTERVAL")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_EXP_BACKOFF_MAX_RETRIES")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_BACKOFF_INTERVAL")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_BACKOFF_MAX_RETRIES")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_DISABLE_RETRIES")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_PUBLICATION_PUBLISH_VIA_PARTITION_ROOT")
+	viper.BindEnv("PGSTREAM_POSTGRES_REPLICATION_PUBLICATION_SKIP_UNLOGGED")
 
 	viper.BindEnv("PGSTREAM_POSTGRES_SNAPSHOT_BATCH_BYTES")
 	viper.BindEnv("PGSTREAM_POSTGRES_SNAPSHOT_SCHEMA_WORKERS")
@@ -52,6 +154,7 @@ func init() {
 	viper.BindEnv("PGSTREAM_POSTGRES_SNAPSHOT_EXCLUDED_TABLES")
 	viper.BindEnv("PGSTREAM_POSTGRES_SNAPSHOT_WORKERS")
 	viper.BindEnv("PGSTREAM_POSTGRES_SNAPSHOT_MAX_CONNECTIONS")
+	viper.BindEnv("PGSTREAM_POSTGRES_SNAPSHOT_RLS_ROLE")
 	viper.BindEnv("PGSTREAM_POSTGRES_SNAPSHOT_STORE_URL")
 	viper.BindEnv("PGSTREAM_POSTGRES_SNAPSHOT_STORE_REPEATABLE")
 	viper.BindEnv("PGSTREAM_POSTGRES_SNAPSHOT_MODE")
@@ -68,6 +171,8 @@ func init() {
 	viper.BindEnv("PGSTREAM_POSTGRES_SNAPSHOT_DISABLE_PROGRESS_TRACKING")
 
 	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_TARGET_URL")
+	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_TARGET_AUTH_MODE")
+	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_TARGET_AWS_REGION")
 	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_BATCH_TIMEOUT")
 	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_BATCH_BYTES")
 	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_BATCH_SIZE")
@@ -88,6 +193,15 @@ func init() {
 	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_BACKOFF_MAX_RETRIES")
 	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_DISABLE_RETRIES")
 	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_IGNORE_DDL")
+	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_CONFLICT_RESOLUTION_STRATEGY")
+	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_CONFLICT_RESOLUTION_LSN_COLUMN")
+	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_SCD_TYPE2_ENABLED")
+	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_SCD_TYPE2_VALID_FROM_COLUMN")
+	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_SCD_TYPE2_VALID_TO_COLUMN")
+	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_SCD_TYPE2_MAX_CONFLICT_RETRIES")
+	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_FK_ORDERING_ENABLED")
+	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_FK_ORDERING_URL")
+	viper.BindEnv("PGSTREAM_POSTGRES_WRITER_FK_ORDERING_MAX_BUFFERED_EVENTS")
 
 	viper.BindEnv("PGSTREAM_KAFKA_READER_SERVERS")
 	viper.BindEnv("PGSTREAM_KAFKA_WRITER_SERVERS")
@@ -108,6 +222,10 @@ func init() {
 	viper.BindEnv("PGSTREAM_KAFKA_WRITER_BATCH_SIZE")
 	viper.BindEnv("PGSTREAM_KAFKA_WRITER_BATCH_IGNORE_SEND_ERRORS")
 	viper.BindEnv("PGSTREAM_KAFKA_WRITER_MAX_QUEUE_BYTES")
+	viper.BindEnv("PGSTREAM_KAFKA_WRITER_PARTITIONING_STRATEGY")
+	viper.BindEnv("PGSTREAM_KAFKA_WRITER_PARTITIONING_TENANT_COLUMN")
+	viper.BindEnv("PGSTREAM_KAFKA_AZURE_EVENT_HUBS_CONNECTION_STRING")
+	viper.BindEnv("PGSTREAM_KAFKA_AZURE_EVENT_HUBS_EVENT_HUB")
 
 	viper.BindEnv("PGSTREAM_OPENSEARCH_STORE_URL")
 	viper.BindEnv("PGSTREAM_ELASTICSEARCH_STORE_URL")
@@ -133,10 +251,159 @@ func init() {
 	viper.BindEnv("PGSTREAM_WEBHOOK_SUBSCRIPTION_SERVER_READ_TIMEOUT")
 	viper.BindEnv("PGSTREAM_WEBHOOK_SUBSCRIPTION_SERVER_WRITE_TIMEOUT")
 
+	viper.BindEnv("PGSTREAM_FILELOG_PATH")
+	viper.BindEnv("PGSTREAM_FILELOG_ROTATION_INTERVAL")
+	viper.BindEnv("PGSTREAM_FILELOG_MAX_FILE_BYTES")
+	viper.BindEnv("PGSTREAM_FILELOG_MAX_ROTATIONS")
+	viper.BindEnv("PGSTREAM_FILELOG_TAIL_BUFFER_SIZE")
+	viper.BindEnv("PGSTREAM_FILELOG_BATCH_SIZE")
+	viper.BindEnv("PGSTREAM_FILELOG_BATCH_TIMEOUT")
+	viper.BindEnv("PGSTREAM_FILELOG_BATCH_BYTES")
+	viper.BindEnv("PGSTREAM_FILELOG_MAX_QUEUE_BYTES")
+	viper.BindEnv("PGSTREAM_FILELOG_BATCH_IGNORE_SEND_ERRORS")
+
+	viper.BindEnv("PGSTREAM_PUBSUB_PROJECT_ID")
+	viper.BindEnv("PGSTREAM_PUBSUB_DEFAULT_TOPIC")
+	viper.BindEnv("PGSTREAM_PUBSUB_TABLE_TOPICS")
+	viper.BindEnv("PGSTREAM_PUBSUB_ENDPOINT")
+	viper.BindEnv("PGSTREAM_PUBSUB_EMULATOR_HOST")
+	viper.BindEnv("PGSTREAM_PUBSUB_CREDENTIALS_FILE")
+	viper.BindEnv("PGSTREAM_PUBSUB_MAX_OUTSTANDING_MESSAGES")
+	viper.BindEnv("PGSTREAM_PUBSUB_BATCH_SIZE")
+	viper.BindEnv("PGSTREAM_PUBSUB_BATCH_TIMEOUT")
+	viper.BindEnv("PGSTREAM_PUBSUB_BATCH_BYTES")
+	viper.BindEnv("PGSTREAM_PUBSUB_MAX_QUEUE_BYTES")
+	viper.BindEnv("PGSTREAM_PUBSUB_BATCH_IGNORE_SEND_ERRORS")
+	viper.BindEnv("PGSTREAM_PUBSUB_EXP_BACKOFF_INITIAL_INTERVAL")
+	viper.BindEnv("PGSTREAM_PUBSUB_EXP_BACKOFF_MAX_INTERVAL")
+	viper.BindEnv("PGSTREAM_PUBSUB_EXP_BACKOFF_MAX_RETRIES")
+	viper.BindEnv("PGSTREAM_PUBSUB_BACKOFF_INTERVAL")
+	viper.BindEnv("PGSTREAM_PUBSUB_BACKOFF_MAX_RETRIES")
+	viper.BindEnv("PGSTREAM_PUBSUB_DISABLE_RETRIES")
+
+	viper.BindEnv("PGSTREAM_AMQP_URL")
+	viper.BindEnv("PGSTREAM_AMQP_EXCHANGE")
+	viper.BindEnv("PGSTREAM_AMQP_MESSAGE_TTL")
+	viper.BindEnv("PGSTREAM_AMQP_PRIORITY")
+	viper.BindEnv("PGSTREAM_AMQP_TABLE_PRIORITIES")
+	viper.BindEnv("PGSTREAM_AMQP_CONFIRM_TIMEOUT")
+	viper.BindEnv("PGSTREAM_AMQP_TLS_ENABLED")
+	viper.BindEnv("PGSTREAM_AMQP_TLS_CA_CERT_FILE")
+	viper.BindEnv("PGSTREAM_AMQP_TLS_CLIENT_CERT_FILE")
+	viper.BindEnv("PGSTREAM_AMQP_TLS_CLIENT_KEY_FILE")
+	viper.BindEnv("PGSTREAM_AMQP_BATCH_SIZE")
+	viper.BindEnv("PGSTREAM_AMQP_BATCH_TIMEOUT")
+	viper.BindEnv("PGSTREAM_AMQP_BATCH_BYTES")
+	viper.BindEnv("PGSTREAM_AMQP_MAX_QUEUE_BYTES")
+	viper.BindEnv("PGSTREAM_AMQP_BATCH_IGNORE_SEND_ERRORS")
+	viper.BindEnv("PGSTREAM_AMQP_EXP_BACKOFF_INITIAL_INTERVAL")
+	viper.BindEnv("PGSTREAM_AMQP_EXP_BACKOFF_MAX_INTERVAL")
+	viper.BindEnv("PGSTREAM_AMQP_EXP_BACKOFF_MAX_RETRIES")
+	viper.BindEnv("PGSTREAM_AMQP_BACKOFF_INTERVAL")
+	viper.BindEnv("PGSTREAM_AMQP_BACKOFF_MAX_RETRIES")
+	viper.BindEnv("PGSTREAM_AMQP_DISABLE_RETRIES")
+
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_URL")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_WAREHOUSE")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_WAREHOUSE_AUTO_RESUME")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_ROLE")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_SESSION_PARAMETERS")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_STAGE")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_SCHEMA")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_TABLE")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_MODE")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_PRIVATE_KEY_FILE")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_STREAMING_ACCOUNT")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_STREAMING_USER")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_STREAMING_DATABASE")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_STREAMING_PIPE")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_STREAMING_CHANNEL")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_STREAMING_COMMIT_TIMEOUT")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_STREAMING_ENDPOINT")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_BATCH_SIZE")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_BATCH_TIMEOUT")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_BATCH_BYTES")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_MAX_QUEUE_BYTES")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_BATCH_IGNORE_SEND_ERRORS")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_EXP_BACKOFF_INITIAL_INTERVAL")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_EXP_BACKOFF_MAX_INTERVAL")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_EXP_BACKOFF_MAX_RETRIES")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_BACKOFF_INTERVAL")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_BACKOFF_MAX_RETRIES")
+	viper.BindEnv("PGSTREAM_SNOWFLAKE_DISABLE_RETRIES")
+
+	viper.BindEnv("PGSTREAM_DYNAMODB_REGION")
+	viper.BindEnv("PGSTREAM_DYNAMODB_ENDPOINT")
+	viper.BindEnv("PGSTREAM_DYNAMODB_TABLES")
+	viper.BindEnv("PGSTREAM_DYNAMODB_TABLE")
+	viper.BindEnv("PGSTREAM_DYNAMODB_VERSION_ATTRIBUTE")
+	viper.BindEnv("PGSTREAM_DYNAMODB_BATCH_SIZE")
+	viper.BindEnv("PGSTREAM_DYNAMODB_BATCH_TIMEOUT")
+	viper.BindEnv("PGSTREAM_DYNAMODB_BATCH_BYTES")
+	viper.BindEnv("PGSTREAM_DYNAMODB_MAX_QUEUE_BYTES")
+	viper.BindEnv("PGSTREAM_DYNAMODB_BATCH_IGNORE_SEND_ERRORS")
+
 	viper.BindEnv("PGSTREAM_INJECTOR_STORE_POSTGRES_URL")
 	viper.BindEnv("PGSTREAM_TRANSFORMER_RULES_FILE")
 	viper.BindEnv("PGSTREAM_FILTER_INCLUDE_TABLES")
 	viper.BindEnv("PGSTREAM_FILTER_EXCLUDE_TABLES")
+	viper.BindEnv("PGSTREAM_FILTER_ALLOWED_ACTIONS")
+	viper.BindEnv("PGSTREAM_FILTER_DENIED_ACTIONS")
+
+	viper.BindEnv("PGSTREAM_CONVERTER_BYTEA_ENABLED")
+	viper.BindEnv("PGSTREAM_CONVERTER_BYTEA_OUTPUT_FORMAT")
+	viper.BindEnv("PGSTREAM_CONVERTER_NORMALIZE_TIMESTAMPS")
+	viper.BindEnv("PGSTREAM_CONVERTER_GEOMETRIC_TYPES")
+	viper.BindEnv("PGSTREAM_CONVERTER_NETWORK_ADDRESSES")
+	viper.BindEnv("PGSTREAM_CONVERTER_FULL_TEXT_SEARCH_TYPES")
+
+	viper.BindEnv("PGSTREAM_TOAST_CACHE_ENABLED")
+	viper.BindEnv("PGSTREAM_TOAST_CACHE_MAX_ENTRIES")
+	viper.BindEnv("PGSTREAM_TOAST_CACHE_MAX_BYTES")
+
+	viper.BindEnv("PGSTREAM_BEFORE_AFTER_NORMALIZER_ENABLED")
+	viper.BindEnv("PGSTREAM_BEFORE_AFTER_NORMALIZER_MAX_ENTRIES")
+	viper.BindEnv("PGSTREAM_BEFORE_AFTER_NORMALIZER_MAX_BYTES")
+
+	viper.BindEnv("PGSTREAM_MIGRATION_SAFE_MODE_ENABLED")
+	viper.BindEnv("PGSTREAM_MIGRATION_SAFE_MODE_LOCK_NAME")
+	viper.BindEnv("PGSTREAM_MIGRATION_SAFE_MODE_CHECK_INTERVAL")
+	viper.BindEnv("PGSTREAM_MIGRATION_SAFE_MODE_MAX_BUFFERED_EVENTS")
+
+	viper.BindEnv("PGSTREAM_PG_AUDIT_ENABLED")
+	viper.BindEnv("PGSTREAM_PG_AUDIT_LOG_FILE")
+	viper.BindEnv("PGSTREAM_PG_AUDIT_TABLE")
+	viper.BindEnv("PGSTREAM_PG_AUDIT_URL")
+	viper.BindEnv("PGSTREAM_PG_AUDIT_POLL_INTERVAL")
+	viper.BindEnv("PGSTREAM_PG_AUDIT_MATCH_TIMEOUT")
+	viper.BindEnv("PGSTREAM_PG_AUDIT_MAX_ENTRIES")
+	viper.BindEnv("PGSTREAM_PG_AUDIT_FROM_START")
+
+	viper.BindEnv("PGSTREAM_SORT_KEY_TABLES")
+	viper.BindEnv("PGSTREAM_SORT_KEY_WINDOW")
+	viper.BindEnv("PGSTREAM_SORT_KEY_MAX_BUFFERED_EVENTS")
+	viper.BindEnv("PGSTREAM_SORT_KEY_WARN_OUT_OF_ORDER")
+
+	viper.BindEnv("PGSTREAM_BULK_UPDATE_COALESCER_ENABLED")
+	viper.BindEnv("PGSTREAM_BULK_UPDATE_COALESCER_TABLES")
+	viper.BindEnv("PGSTREAM_BULK_UPDATE_COALESCER_WINDOW")
+	viper.BindEnv("PGSTREAM_BULK_UPDATE_COALESCER_MAX_BUFFERED_ROWS")
+
+	viper.BindEnv("PGSTREAM_XID_SEQUENCER_ENABLED")
+	viper.BindEnv("PGSTREAM_XID_SEQUENCER_MAX_BUFFERED_EVENTS")
+
+	viper.BindEnv("PGSTREAM_SINK_HEALTH_CHECK_ENABLED")
+	viper.BindEnv("PGSTREAM_SINK_HEALTH_CHECK_INTERVAL")
+	viper.BindEnv("PGSTREAM_SINK_HEALTH_CHECK_MAX_STARTUP_WAIT")
+
+	viper.BindEnv("PGSTREAM_TABLE_SIZE_THROTTLE_ENABLED")
+	viper.BindEnv("PGSTREAM_TABLE_SIZE_THROTTLE_MIN_TABLE_SIZE")
+	viper.BindEnv("PGSTREAM_TABLE_SIZE_THROTTLE_EVENTS_PER_SECOND_PER_GB")
+	viper.BindEnv("PGSTREAM_TABLE_SIZE_THROTTLE_REFRESH_INTERVAL")
+
+	viper.BindEnv("PGSTREAM_SCHEMA_VALIDATION_TABLE_SCHEMAS")
+	viper.BindEnv("PGSTREAM_SCHEMA_VALIDATION_DEAD_LETTER_FILE")
+	viper.BindEnv("PGSTREAM_SCHEMA_VALIDATION_CHECK_CONSTRAINTS")
 
 	viper.BindEnv("PGSTREAM_KAFKA_TLS_ENABLED")
 	viper.BindEnv("PGSTREAM_KAFKA_TLS_CA_CERT_FILE")
@@ -144,14 +411,85 @@ func init() {
 	viper.BindEnv("PGSTREAM_KAFKA_TLS_CLIENT_KEY_FILE")
 }
 
+// parseCloudWatchMetricsConfig parses the cloudwatch metrics configuration,
+// which is enabled when either the namespace or the region are provided. The
+// dimensions are provided as a list of name=value pairs.
+func parseCloudWatchMetricsConfig() (*cloudwatch.Config, error) {
+	namespace := viper.GetString("PGSTREAM_METRICS_CLOUDWATCH_NAMESPACE")
+	region := viper.GetString("PGSTREAM_METRICS_CLOUDWATCH_REGION")
+	if namespace == "" && region == "" {
+		return nil, nil
+	}
+
+	dimensionPairs := viper.GetStringSlice("PGSTREAM_METRICS_CLOUDWATCH_DIMENSIONS")
+	var dimensions map[string]string
+	if len(dimensionPairs) > 0 {
+		dimensions = make(map[string]string, len(dimensionPairs))
+		for _, pair := range dimensionPairs {
+			name, value, found := strings.Cut(pair, "=")
+			if !found || name == "" || value == "" {
+				return nil, fmt.Errorf("%w: %q", errInvalidMetricsDimensionPair, pair)
+			}
+			dimensions[name] = value
+		}
+	}
+
+	return &cloudwatch.Config{
+		Namespace:      namespace,
+		Region:         region,
+		HighResolution: viper.GetBool("PGSTREAM_METRICS_CLOUDWATCH_HIGH_RESOLUTION"),
+		Dimensions:     dimensions,
+	}, nil
+}
+
+func envToShutdownConfig() *wal.ShutdownConfig {
+	return &wal.ShutdownConfig{
+		ShutdownTimeout: viper.GetDuration("PGSTREAM_SHUTDOWN_TIMEOUT"),
+	}
+}
+
+func envToHealthConfig() *health.Config {
+	listenAddress := viper.GetString("PGSTREAM_HEALTH_LISTEN_ADDRESS")
+	if listenAddress == "" {
+		return nil
+	}
+	return &health.Config{
+		ListenAddress:          listenAddress,
+		MaxReplicationLagBytes: viper.GetInt64("PGSTREAM_HEALTH_MAX_REPLICATION_LAG_BYTES"),
+	}
+}
+
+func envToDebugConfig() *debug.Config {
+	listenAddress := viper.GetString("PGSTREAM_DEBUG_LISTEN_ADDRESS")
+	if listenAddress == "" {
+		return nil
+	}
+	return &debug.Config{
+		ListenAddress: listenAddress,
+	}
+}
+
 func envToOtelConfig() (*otel.Config, error) {
 	cfg := &otel.Config{}
 
+	cloudwatchCfg, err := parseCloudWatchMetricsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var prometheusCfg *prometheus.Config
+	if listenAddress := viper.GetString("PGSTREAM_METRICS_PROMETHEUS_LISTEN_ADDRESS"); listenAddress != "" {
+		prometheusCfg = &prometheus.Config{ListenAddress: listenAddress}
+	}
+
 	metricsEndpoint := viper.GetString("PGSTREAM_METRICS_ENDPOINT")
-	if metricsEndpoint != "" {
+	if metricsEndpoint != "" || cloudwatchCfg != nil || prometheusCfg != nil {
 		cfg.Metrics = &otel.MetricsConfig{
-			Endpoint:           metricsEndpoint,
-			CollectionInterval: viper.GetDuration("PGSTREAM_METRICS_COLLECTION_INTERVAL"),
+			Endpoint:            metricsEndpoint,
+			CollectionInterval:  viper.GetDuration("PGSTREAM_METRICS_COLLECTION_INTERVAL"),
+			CloudWatch:          cloudwatchCfg,
+			Prometheus:          prometheusCfg,
+			MaxTableCardinality: viper.GetInt("PGSTREAM_METRICS_MAX_TABLE_CARDINALITY"),
 		}
 	}
 
@@ -185,9 +523,28 @@ func envConfigToStreamConfig() (*stream.Config, error) {
 	return &stream.Config{
 		Listener:  listenerCfg,
 		Processor: processorCfg,
+		Replay:    parseReplayConfig(),
 	}, nil
 }
 
+// parseReplayConfig returns the configuration of the replay command, or nil
+// if no replay is configured. Only the file log archive can be replayed with
+// the environment configuration.
+func parseReplayConfig() *stream.ReplayConfig {
+	startLSN := viper.GetString("PGSTREAM_REPLAY_START_LSN")
+	endLSN := viper.GetString("PGSTREAM_REPLAY_END_LSN")
+	fileLogPath := viper.GetString("PGSTREAM_REPLAY_FILELOG_PATH")
+	if startLSN == "" && endLSN == "" && fileLogPath == "" {
+		return nil
+	}
+
+	return &stream.ReplayConfig{
+		StartLSN:    startLSN,
+		EndLSN:      endLSN,
+		FileLogPath: fileLogPath,
+	}
+}
+
 // listener parsing
 
 func parseListenerConfig() (stream.ListenerConfig, error) {
@@ -197,13 +554,30 @@ func parseListenerConfig() (stream.ListenerConfig, error) {
 	}
 
 	return stream.ListenerConfig{
-		Postgres: postgresConfig,
-		Kafka:    parseKafkaListenerConfig(),
+		Postgres:   postgresConfig,
+		Kafka:      parseKafkaListenerConfig(),
+		Checkpoint: parseCheckpointConfig(),
 	}, nil
 }
 
+func parseCheckpointConfig() *committer.Config {
+	cfg := &committer.Config{
+		CommitInterval:        viper.GetDuration("PGSTREAM_CHECKPOINT_COMMIT_INTERVAL"),
+		MaxUncommittedEvents:  viper.GetUint64("PGSTREAM_CHECKPOINT_MAX_UNCOMMITTED_EVENTS"),
+		MaxUncommittedBytes:   viper.GetUint64("PGSTREAM_CHECKPOINT_MAX_UNCOMMITTED_BYTES"),
+		DisableShutdownCommit: viper.GetBool("PGSTREAM_CHECKPOINT_DISABLE_SHUTDOWN_COMMIT"),
+	}
+	if *cfg == (committer.Config{}) {
+		return nil
+	}
+	return cfg
+}
+
 func parsePostgresListenerConfig() (*stream.PostgresListenerConfig, error) {
-	pgURL := viper.GetString("PGSTREAM_POSTGRES_LISTENER_URL")
+	pgURL, err := parsePostgresURL("PGSTREAM_POSTGRES_LISTENER")
+	if err != nil {
+		return nil, err
+	}
 	if pgURL == "" {
 		return nil, nil
 	}
@@ -213,8 +587,81 @@ func parsePostgresListenerConfig() (*stream.PostgresListenerConfig, error) {
 		Replication: pgreplication.Config{
 			PostgresURL:         pgURL,
 			ReplicationSlotName: viper.GetString("PGSTREAM_POSTGRES_REPLICATION_SLOT_NAME"),
+			Slot: pgreplication.SlotConfig{
+				Temporary:       viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_SLOT_TEMPORARY"),
+				TwoPhase:        viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_SLOT_TWO_PHASE"),
+				Failover:        viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_SLOT_FAILOVER"),
+				CreateIfMissing: viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_SLOT_CREATE_IF_MISSING"),
+			},
+			Plugin: pgreplication.PluginConfig{
+				Name:               viper.GetString("PGSTREAM_POSTGRES_REPLICATION_PLUGIN_NAME"),
+				FormatVersion:      viper.GetInt("PGSTREAM_POSTGRES_REPLICATION_PLUGIN_FORMAT_VERSION"),
+				IncludeTransaction: viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_PLUGIN_INCLUDE_TRANSACTION"),
+			},
 		},
-		RetryPolicy: parseBackoffConfig("PGSTREAM_POSTGRES_LISTENER"),
+		RetryPolicy:           parseBackoffConfig("PGSTREAM_POSTGRES_LISTENER"),
+		ReconnectPolicy:       parseBackoffConfig("PGSTREAM_POSTGRES_LISTENER_RECONNECT"),
+		StandbyStatusInterval: viper.GetDuration("PGSTREAM_POSTGRES_REPLICATION_STANDBY_STATUS_INTERVAL"),
+	}
+
+	if publicationName := viper.GetString("PGSTREAM_POSTGRES_REPLICATION_PUBLICATION_NAME"); publicationName != "" {
+		cfg.Replication.Publication = &pgreplication.PublicationConfig{
+			Name:                    publicationName,
+			PublishViaPartitionRoot: viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_PUBLICATION_PUBLISH_VIA_PARTITION_ROOT"),
+			SkipUnlogged:            viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_PUBLICATION_SKIP_UNLOGGED"),
+		}
+	}
+
+	if viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_SLOT_MONITOR_ENABLED") {
+		cfg.SlotMonitor = &pglistener.SlotMonitorConfig{
+			CheckInterval:         viper.GetDuration("PGSTREAM_POSTGRES_REPLICATION_SLOT_MONITOR_CHECK_INTERVAL"),
+			RetainedWALLimitBytes: viper.GetInt64("PGSTREAM_POSTGRES_REPLICATION_SLOT_MONITOR_RETAINED_WAL_LIMIT_BYTES"),
+			PauseSnapshotsOnLimit: viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_SLOT_MONITOR_PAUSE_SNAPSHOTS_ON_LIMIT"),
+		}
+	}
+
+	if viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_HEARTBEAT_ENABLED") {
+		cfg.Heartbeat = &pglistener.HeartbeatConfig{
+			Interval:      viper.GetDuration("PGSTREAM_POSTGRES_REPLICATION_HEARTBEAT_INTERVAL"),
+			TableName:     viper.GetString("PGSTREAM_POSTGRES_REPLICATION_HEARTBEAT_TABLE_NAME"),
+			ForwardEvents: viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_HEARTBEAT_FORWARD_EVENTS"),
+		}
+	}
+
+	if viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_TABLE_ENABLED") {
+		cfg.CheckpointTable = &pgcheckpoint.TableConfig{
+			URL:        viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_TABLE_URL"),
+			PipelineID: viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_TABLE_PIPELINE_ID"),
+			Table:      viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_TABLE_NAME"),
+		}
+	}
+
+	if checkpointFilePath := viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_FILE_PATH"); checkpointFilePath != "" {
+		cfg.CheckpointFile = &filecheckpoint.Config{
+			Path:          checkpointFilePath,
+			FlushInterval: viper.GetDuration("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_FILE_FLUSH_INTERVAL"),
+			Reset:         viper.GetBool("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_FILE_RESET"),
+		}
+	}
+
+	if redisAddresses := viper.GetStringSlice("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_ADDRESSES"); len(redisAddresses) > 0 {
+		cfg.CheckpointRedis = &redischeckpoint.Config{
+			Mode:                 redischeckpoint.Mode(viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_MODE")),
+			Addresses:            redisAddresses,
+			MasterName:           viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_MASTER_NAME"),
+			Username:             viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_USERNAME"),
+			Password:             viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_PASSWORD"),
+			SentinelUsername:     viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_SENTINEL_USERNAME"),
+			SentinelPassword:     viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_SENTINEL_PASSWORD"),
+			DB:                   viper.GetInt("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_DB"),
+			TLS:                  parseTLSConfig("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS"),
+			StreamID:             viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_STREAM_ID"),
+			KeyPrefix:            viper.GetString("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_KEY_PREFIX"),
+			KeyTTL:               viper.GetDuration("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_KEY_TTL"),
+			CommitInterval:       viper.GetDuration("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_COMMIT_INTERVAL"),
+			MaxUncommittedWindow: viper.GetDuration("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS_MAX_UNCOMMITTED_WINDOW"),
+			RetryPolicy:          parseBackoffConfig("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_REDIS"),
+		}
 	}
 
 	if filterConfig := parseFilterConfig(); filterConfig != nil {
@@ -225,7 +672,6 @@ func parsePostgresListenerConfig() (*stream.PostgresListenerConfig, error) {
 	snapshotTables := viper.GetStringSlice("PGSTREAM_POSTGRES_SNAPSHOT_TABLES")
 	excludedTables := viper.GetStringSlice("PGSTREAM_POSTGRES_SNAPSHOT_EXCLUDED_TABLES")
 	if len(snapshotTables) > 0 || len(excludedTables) > 0 {
-		var err error
 		cfg.Snapshot, err = parseSnapshotConfig(pgURL)
 		if err != nil {
 			return nil, err
@@ -267,6 +713,10 @@ func parseSnapshotConfig(pgURL string) (*snapshotbuilder.SnapshotListenerConfig,
 			TableWorkers:    viper.GetUint("PGSTREAM_POSTGRES_SNAPSHOT_TABLE_WORKERS"),
 			SnapshotWorkers: viper.GetUint("PGSTREAM_POSTGRES_SNAPSHOT_WORKERS"),
 			MaxConnections:  viper.GetUint("PGSTREAM_POSTGRES_SNAPSHOT_MAX_CONNECTIONS"),
+			RLSRole:         viper.GetString("PGSTREAM_POSTGRES_SNAPSHOT_RLS_ROLE"),
+
+			SerializableSnapshot:    viper.GetBool("PGSTREAM_POSTGRES_SNAPSHOT_SERIALIZABLE"),
+			MaxSerializationRetries: viper.GetUint("PGSTREAM_POSTGRES_SNAPSHOT_MAX_SERIALIZATION_RETRIES"),
 		}
 	}
 
@@ -292,7 +742,10 @@ func parseSnapshotConfig(pgURL string) (*snapshotbuilder.SnapshotListenerConfig,
 
 func parseSchemaSnapshotConfig(pgurl string) (*snapshotbuilder.SchemaSnapshotConfig, error) {
 	useSchemaLog := viper.GetBool("PGSTREAM_POSTGRES_SNAPSHOT_USE_SCHEMALOG")
-	pgTargetURL := viper.GetString("PGSTREAM_POSTGRES_WRITER_TARGET_URL")
+	pgTargetURL, err := parsePostgresURL("PGSTREAM_POSTGRES_WRITER_TARGET")
+	if err != nil {
+		return nil, err
+	}
 	if pgTargetURL != "" && !useSchemaLog {
 		rolesSnapshotConfig, err := getRolesSnapshotMode(viper.GetString("PGSTREAM_POSTGRES_SNAPSHOT_ROLES_SNAPSHOT_MODE"))
 		if err != nil {
@@ -362,27 +815,103 @@ func parseProcessorConfig() (stream.ProcessorConfig, error) {
 	if err != nil {
 		return stream.ProcessorConfig{}, err
 	}
+	validatorCfg, err := parseValidatorConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
+	sortKeyCfg, err := parseSortKeyConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
+	postgresCfg, err := parsePostgresProcessorConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
+	pubsubCfg, err := parsePubSubProcessorConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
+	amqpCfg, err := parseAMQPProcessorConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
+	snowflakeCfg, err := parseSnowflakeProcessorConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
+	dynamoDBCfg, err := parseDynamoDBProcessorConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
+	kafkaCfg, err := parseKafkaProcessorConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
 	return stream.ProcessorConfig{
-		Kafka:       parseKafkaProcessorConfig(),
-		Search:      parseSearchProcessorConfig(),
-		Webhook:     parseWebhookProcessorConfig(),
-		Postgres:    parsePostgresProcessorConfig(),
-		Injector:    parseInjectorConfig(),
-		Transformer: transformerCfg,
-		Filter:      parseFilterConfig(),
+		Kafka:               kafkaCfg,
+		Search:              parseSearchProcessorConfig(),
+		Webhook:             parseWebhookProcessorConfig(),
+		FileLog:             parseFileLogProcessorConfig(),
+		PubSub:              pubsubCfg,
+		AMQP:                amqpCfg,
+		Snowflake:           snowflakeCfg,
+		DynamoDB:            dynamoDBCfg,
+		Postgres:            postgresCfg,
+		Injector:            parseInjectorConfig(),
+		Transformer:         transformerCfg,
+		Filter:              parseFilterConfig(),
+		Converter:           parseConverterConfig(),
+		TOASTCache:          parseTOASTCacheConfig(),
+		BeforeAfter:         parseBeforeAfterConfig(),
+		Validator:           validatorCfg,
+		MigrationSafeMode:   parseMigrationSafeModeConfig(),
+		PGAudit:             parsePGAuditConfig(),
+		SortKey:             sortKeyCfg,
+		BulkUpdateCoalescer: parseBulkUpdateCoalescerConfig(),
+		XIDSequencer:        parseXIDSequencerConfig(),
+		SinkHealthCheck:     parseSinkHealthCheckConfig(),
+		TableSizeThrottle:   parseTableSizeThrottleConfig(),
+		TypeEvolution:       parseTypeEvolutionConfig(),
 	}, nil
 }
 
-func parseKafkaProcessorConfig() *stream.KafkaProcessorConfig {
+func parseKafkaProcessorConfig() (*stream.KafkaProcessorConfig, error) {
 	kafkaTopic := viper.GetString("PGSTREAM_KAFKA_TOPIC_NAME")
 	kafkaServers := viper.GetStringSlice("PGSTREAM_KAFKA_WRITER_SERVERS")
-	if len(kafkaServers) == 0 || kafkaTopic == "" {
-		return nil
+	azureConnString := viper.GetString("PGSTREAM_KAFKA_AZURE_EVENT_HUBS_CONNECTION_STRING")
+	if azureConnString == "" && (len(kafkaServers) == 0 || kafkaTopic == "") {
+		return nil, nil
 	}
 
-	return &stream.KafkaProcessorConfig{
+	cfg := &stream.KafkaProcessorConfig{
 		Writer: parseKafkaWriterConfig(kafkaServers, kafkaTopic),
 	}
+	if azureConnString != "" {
+		// the topic name is used as event hub when not explicitly set
+		eventHub := viper.GetString("PGSTREAM_KAFKA_AZURE_EVENT_HUBS_EVENT_HUB")
+		if eventHub == "" {
+			eventHub = kafkaTopic
+		}
+		azureCfg := &kafka.AzureEventHubsConfig{
+			ConnectionString: azureConnString,
+			EventHub:         eventHub,
+		}
+		azureConnCfg, err := azureCfg.ConnConfig()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Writer.Kafka.Servers = azureConnCfg.Servers
+		cfg.Writer.Kafka.Topic.Name = azureConnCfg.Topic.Name
+		cfg.Writer.Kafka.TLS = azureConnCfg.TLS
+		cfg.Writer.Kafka.SASL = azureConnCfg.SASL
+	}
+	if strategy := viper.GetString("PGSTREAM_KAFKA_WRITER_PARTITIONING_STRATEGY"); strategy != "" {
+		cfg.Partitioning = &partition.Config{
+			Strategy:     partition.Strategy(strategy),
+			TenantColumn: viper.GetString("PGSTREAM_KAFKA_WRITER_PARTITIONING_TENANT_COLUMN"),
+		}
+	}
+	return cfg, nil
 }
 
 func parseKafkaWriterConfig(kafkaServers []string, kafkaTopic string) *kafkaprocessor.Config {
@@ -459,12 +988,211 @@ func parseWebhookProcessorConfig() *stream.WebhookProcessorConfig {
 	}
 }
 
-func parsePostgresProcessorConfig() *stream.PostgresProcessorConfig {
-	targetPostgresURL := viper.GetString("PGSTREAM_POSTGRES_WRITER_TARGET_URL")
-	if targetPostgresURL == "" {
+func parseFileLogProcessorConfig() *filelog.Config {
+	path := viper.GetString("PGSTREAM_FILELOG_PATH")
+	if path == "" {
 		return nil
 	}
 
+	return &filelog.Config{
+		Path:             path,
+		RotationInterval: viper.GetDuration("PGSTREAM_FILELOG_ROTATION_INTERVAL"),
+		MaxFileBytes:     viper.GetInt64("PGSTREAM_FILELOG_MAX_FILE_BYTES"),
+		MaxRotations:     viper.GetInt("PGSTREAM_FILELOG_MAX_ROTATIONS"),
+		TailBufferSize:   viper.GetInt("PGSTREAM_FILELOG_TAIL_BUFFER_SIZE"),
+		Batch: batch.Config{
+			MaxBatchSize:     viper.GetInt64("PGSTREAM_FILELOG_BATCH_SIZE"),
+			BatchTimeout:     viper.GetDuration("PGSTREAM_FILELOG_BATCH_TIMEOUT"),
+			MaxBatchBytes:    viper.GetInt64("PGSTREAM_FILELOG_BATCH_BYTES"),
+			MaxQueueBytes:    viper.GetInt64("PGSTREAM_FILELOG_MAX_QUEUE_BYTES"),
+			IgnoreSendErrors: viper.GetBool("PGSTREAM_FILELOG_BATCH_IGNORE_SEND_ERRORS"),
+		},
+	}
+}
+
+// parsePubSubProcessorConfig parses the pubsub sink configuration. The table
+// topics are provided as a list of schema.table=topic pairs.
+func parsePubSubProcessorConfig() (*pubsub.Config, error) {
+	projectID := viper.GetString("PGSTREAM_PUBSUB_PROJECT_ID")
+	if projectID == "" {
+		return nil, nil
+	}
+
+	tableTopicPairs := viper.GetStringSlice("PGSTREAM_PUBSUB_TABLE_TOPICS")
+	var tableTopics map[string]string
+	if len(tableTopicPairs) > 0 {
+		tableTopics = make(map[string]string, len(tableTopicPairs))
+		for _, pair := range tableTopicPairs {
+			table, topic, found := strings.Cut(pair, "=")
+			if !found || table == "" || topic == "" {
+				return nil, fmt.Errorf("%w: %q", errInvalidTableTopicPair, pair)
+			}
+			tableTopics[table] = topic
+		}
+	}
+
+	return &pubsub.Config{
+		ProjectID:              projectID,
+		DefaultTopic:           viper.GetString("PGSTREAM_PUBSUB_DEFAULT_TOPIC"),
+		TableTopics:            tableTopics,
+		Endpoint:               viper.GetString("PGSTREAM_PUBSUB_ENDPOINT"),
+		EmulatorHost:           viper.GetString("PGSTREAM_PUBSUB_EMULATOR_HOST"),
+		CredentialsFile:        viper.GetString("PGSTREAM_PUBSUB_CREDENTIALS_FILE"),
+		MaxOutstandingMessages: viper.GetInt64("PGSTREAM_PUBSUB_MAX_OUTSTANDING_MESSAGES"),
+		RetryPolicy:            parseBackoffConfig("PGSTREAM_PUBSUB"),
+		Batch: batch.Config{
+			MaxBatchSize:     viper.GetInt64("PGSTREAM_PUBSUB_BATCH_SIZE"),
+			BatchTimeout:     viper.GetDuration("PGSTREAM_PUBSUB_BATCH_TIMEOUT"),
+			MaxBatchBytes:    viper.GetInt64("PGSTREAM_PUBSUB_BATCH_BYTES"),
+			MaxQueueBytes:    viper.GetInt64("PGSTREAM_PUBSUB_MAX_QUEUE_BYTES"),
+			IgnoreSendErrors: viper.GetBool("PGSTREAM_PUBSUB_BATCH_IGNORE_SEND_ERRORS"),
+		},
+	}, nil
+}
+
+// parseAMQPProcessorConfig parses the amqp sink configuration. The table
+// priorities are provided as a list of schema.table=priority pairs.
+func parseAMQPProcessorConfig() (*amqp.Config, error) {
+	url := viper.GetString("PGSTREAM_AMQP_URL")
+	if url == "" {
+		return nil, nil
+	}
+
+	tablePriorityPairs := viper.GetStringSlice("PGSTREAM_AMQP_TABLE_PRIORITIES")
+	var tablePriorities map[string]int
+	if len(tablePriorityPairs) > 0 {
+		tablePriorities = make(map[string]int, len(tablePriorityPairs))
+		for _, pair := range tablePriorityPairs {
+			table, priorityStr, found := strings.Cut(pair, "=")
+			if !found || table == "" {
+				return nil, fmt.Errorf("%w: %q", errInvalidTablePriorityPair, pair)
+			}
+			priority, err := strconv.Atoi(priorityStr)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q", errInvalidTablePriorityPair, pair)
+			}
+			tablePriorities[table] = priority
+		}
+	}
+
+	return &amqp.Config{
+		URL:             url,
+		Exchange:        viper.GetString("PGSTREAM_AMQP_EXCHANGE"),
+		MessageTTL:      viper.GetDuration("PGSTREAM_AMQP_MESSAGE_TTL"),
+		Priority:        viper.GetInt("PGSTREAM_AMQP_PRIORITY"),
+		TablePriorities: tablePriorities,
+		ConfirmTimeout:  viper.GetDuration("PGSTREAM_AMQP_CONFIRM_TIMEOUT"),
+		TLS:             parseTLSConfig("PGSTREAM_AMQP"),
+		RetryPolicy:     parseBackoffConfig("PGSTREAM_AMQP"),
+		Batch: batch.Config{
+			MaxBatchSize:     viper.GetInt64("PGSTREAM_AMQP_BATCH_SIZE"),
+			BatchTimeout:     viper.GetDuration("PGSTREAM_AMQP_BATCH_TIMEOUT"),
+			MaxBatchBytes:    viper.GetInt64("PGSTREAM_AMQP_BATCH_BYTES"),
+			MaxQueueBytes:    viper.GetInt64("PGSTREAM_AMQP_MAX_QUEUE_BYTES"),
+			IgnoreSendErrors: viper.GetBool("PGSTREAM_AMQP_BATCH_IGNORE_SEND_ERRORS"),
+		},
+	}, nil
+}
+
+// parseSnowflakeProcessorConfig parses the snowflake sink configuration. The
+// session parameters are provided as a list of name=value pairs, with the
+// names upper cased like in the yaml configuration.
+func parseSnowflakeProcessorConfig() (*snowflake.Config, error) {
+	dsn := viper.GetString("PGSTREAM_SNOWFLAKE_URL")
+	if dsn == "" {
+		return nil, nil
+	}
+
+	parameterPairs := viper.GetStringSlice("PGSTREAM_SNOWFLAKE_SESSION_PARAMETERS")
+	var sessionParameters map[string]string
+	if len(parameterPairs) > 0 {
+		sessionParameters = make(map[string]string, len(parameterPairs))
+		for _, pair := range parameterPairs {
+			name, value, found := strings.Cut(pair, "=")
+			if !found || name == "" {
+				return nil, fmt.Errorf("%w: %q", errInvalidSessionParameterPair, pair)
+			}
+			sessionParameters[strings.ToUpper(name)] = value
+		}
+	}
+
+	return &snowflake.Config{
+		DSN:                 dsn,
+		Warehouse:           viper.GetString("PGSTREAM_SNOWFLAKE_WAREHOUSE"),
+		WarehouseAutoResume: viper.GetBool("PGSTREAM_SNOWFLAKE_WAREHOUSE_AUTO_RESUME"),
+		Role:                viper.GetString("PGSTREAM_SNOWFLAKE_ROLE"),
+		SessionParameters:   sessionParameters,
+		Stage:               viper.GetString("PGSTREAM_SNOWFLAKE_STAGE"),
+		Schema:              viper.GetString("PGSTREAM_SNOWFLAKE_SCHEMA"),
+		Table:               viper.GetString("PGSTREAM_SNOWFLAKE_TABLE"),
+		Mode:                snowflake.Mode(viper.GetString("PGSTREAM_SNOWFLAKE_MODE")),
+		PrivateKeyFile:      viper.GetString("PGSTREAM_SNOWFLAKE_PRIVATE_KEY_FILE"),
+		Streaming: snowflake.StreamingConfig{
+			Account:       viper.GetString("PGSTREAM_SNOWFLAKE_STREAMING_ACCOUNT"),
+			User:          viper.GetString("PGSTREAM_SNOWFLAKE_STREAMING_USER"),
+			Database:      viper.GetString("PGSTREAM_SNOWFLAKE_STREAMING_DATABASE"),
+			Pipe:          viper.GetString("PGSTREAM_SNOWFLAKE_STREAMING_PIPE"),
+			Channel:       viper.GetString("PGSTREAM_SNOWFLAKE_STREAMING_CHANNEL"),
+			CommitTimeout: viper.GetDuration("PGSTREAM_SNOWFLAKE_STREAMING_COMMIT_TIMEOUT"),
+			Endpoint:      viper.GetString("PGSTREAM_SNOWFLAKE_STREAMING_ENDPOINT"),
+		},
+		RetryPolicy: parseBackoffConfig("PGSTREAM_SNOWFLAKE"),
+		Batch: batch.Config{
+			MaxBatchSize:     viper.GetInt64("PGSTREAM_SNOWFLAKE_BATCH_SIZE"),
+			BatchTimeout:     viper.GetDuration("PGSTREAM_SNOWFLAKE_BATCH_TIMEOUT"),
+			MaxBatchBytes:    viper.GetInt64("PGSTREAM_SNOWFLAKE_BATCH_BYTES"),
+			MaxQueueBytes:    viper.GetInt64("PGSTREAM_SNOWFLAKE_MAX_QUEUE_BYTES"),
+			IgnoreSendErrors: viper.GetBool("PGSTREAM_SNOWFLAKE_BATCH_IGNORE_SEND_ERRORS"),
+		},
+	}, nil
+}
+
+// parseDynamoDBProcessorConfig parses the dynamodb sink configuration. The
+// target tables are provided as a list of schema.table=target_table pairs.
+func parseDynamoDBProcessorConfig() (*dynamodb.Config, error) {
+	tablePairs := viper.GetStringSlice("PGSTREAM_DYNAMODB_TABLES")
+	catchAllTable := viper.GetString("PGSTREAM_DYNAMODB_TABLE")
+	if len(tablePairs) == 0 && catchAllTable == "" {
+		return nil, nil
+	}
+
+	var tables map[string]string
+	if len(tablePairs) > 0 {
+		tables = make(map[string]string, len(tablePairs))
+		for _, pair := range tablePairs {
+			table, targetTable, found := strings.Cut(pair, "=")
+			if !found || table == "" || targetTable == "" {
+				return nil, fmt.Errorf("%w: %q", errInvalidTableTargetPair, pair)
+			}
+			tables[table] = targetTable
+		}
+	}
+
+	return &dynamodb.Config{
+		Region:           viper.GetString("PGSTREAM_DYNAMODB_REGION"),
+		Endpoint:         viper.GetString("PGSTREAM_DYNAMODB_ENDPOINT"),
+		Tables:           tables,
+		Table:            catchAllTable,
+		VersionAttribute: viper.GetString("PGSTREAM_DYNAMODB_VERSION_ATTRIBUTE"),
+		Batch: batch.Config{
+			MaxBatchSize:     viper.GetInt64("PGSTREAM_DYNAMODB_BATCH_SIZE"),
+			BatchTimeout:     viper.GetDuration("PGSTREAM_DYNAMODB_BATCH_TIMEOUT"),
+			MaxBatchBytes:    viper.GetInt64("PGSTREAM_DYNAMODB_BATCH_BYTES"),
+			MaxQueueBytes:    viper.GetInt64("PGSTREAM_DYNAMODB_MAX_QUEUE_BYTES"),
+			IgnoreSendErrors: viper.GetBool("PGSTREAM_DYNAMODB_BATCH_IGNORE_SEND_ERRORS"),
+		},
+	}, nil
+}
+
+func parsePostgresProcessorConfig() (*stream.PostgresProcessorConfig, error) {
+	targetPostgresURL, err := parsePostgresURL("PGSTREAM_POSTGRES_WRITER_TARGET")
+	if err != nil {
+		return nil, err
+	}
+	if targetPostgresURL == "" {
+		return nil, nil
+	}
+
 	bulkIngestEnabled := viper.GetBool("PGSTREAM_POSTGRES_WRITER_BULK_INGEST_ENABLED")
 	cfg := &stream.PostgresProcessorConfig{
 		BatchWriter: postgres.Config{
@@ -490,6 +1218,10 @@ func parsePostgresProcessorConfig() *stream.PostgresProcessorConfig {
 			BulkIngestEnabled: bulkIngestEnabled,
 			RetryPolicy:       parseBackoffConfig("PGSTREAM_POSTGRES_WRITER"),
 			IgnoreDDL:         viper.GetBool("PGSTREAM_POSTGRES_WRITER_IGNORE_DDL"),
+			ConflictResolution: postgres.ConflictResolutionConfig{
+				Strategy:  viper.GetString("PGSTREAM_POSTGRES_WRITER_CONFLICT_RESOLUTION_STRATEGY"),
+				LSNColumn: viper.GetString("PGSTREAM_POSTGRES_WRITER_CONFLICT_RESOLUTION_LSN_COLUMN"),
+			},
 		},
 	}
 
@@ -497,7 +1229,36 @@ func parsePostgresProcessorConfig() *stream.PostgresProcessorConfig {
 		applyPostgresBulkBatchDefaults(&cfg.BatchWriter.BatchConfig)
 	}
 
-	return cfg
+	if viper.GetBool("PGSTREAM_POSTGRES_WRITER_SCD_TYPE2_ENABLED") {
+		cfg.SCDType2 = &scd.Config{
+			URL:                targetPostgresURL,
+			ValidFromColumn:    viper.GetString("PGSTREAM_POSTGRES_WRITER_SCD_TYPE2_VALID_FROM_COLUMN"),
+			ValidToColumn:      viper.GetString("PGSTREAM_POSTGRES_WRITER_SCD_TYPE2_VALID_TO_COLUMN"),
+			MaxConflictRetries: viper.GetInt("PGSTREAM_POSTGRES_WRITER_SCD_TYPE2_MAX_CONFLICT_RETRIES"),
+			Batch:              cfg.BatchWriter.BatchConfig,
+		}
+	}
+
+	if viper.GetBool("PGSTREAM_POSTGRES_WRITER_FK_ORDERING_ENABLED") {
+		cfg.FKOrdering = &fkorder.Config{
+			URL:               viper.GetString("PGSTREAM_POSTGRES_WRITER_FK_ORDERING_URL"),
+			MaxBufferedEvents: viper.GetInt("PGSTREAM_POSTGRES_WRITER_FK_ORDERING_MAX_BUFFERED_EVENTS"),
+		}
+	}
+
+	return cfg, nil
+}
+
+// parsePostgresURL returns the postgres URL for the environment prefix on
+// input, with the configured auth mode applied.
+func parsePostgresURL(prefix string) (string, error) {
+	pgURL := viper.GetString(fmt.Sprintf("%s_URL", prefix))
+	if pgURL == "" {
+		return "", nil
+	}
+	authMode := viper.GetString(fmt.Sprintf("%s_AUTH_MODE", prefix))
+	awsRegion := viper.GetString(fmt.Sprintf("%s_AWS_REGION", prefix))
+	return pglib.WithAuthMode(pgURL, pglib.AuthMode(authMode), awsRegion)
 }
 
 func parseBackoffConfig(prefix string) backoff.Config {
@@ -546,6 +1307,18 @@ func parseInjectorConfig() *injector.Config {
 	}
 }
 
+func parseTypeEvolutionConfig() *typeevolution.Config {
+	pgURL := viper.GetString("PGSTREAM_TYPE_EVOLUTION_STORE_POSTGRES_URL")
+	if pgURL == "" {
+		return nil
+	}
+	return &typeevolution.Config{
+		Store: pgschemalog.Config{
+			URL: pgURL,
+		},
+	}
+}
+
 func parseTransformerConfig() (*transformer.Config, error) {
 	filename := viper.GetString("PGSTREAM_TRANSFORMER_RULES_FILE")
 	return ParseTransformerConfig(filename)
@@ -554,14 +1327,194 @@ func parseTransformerConfig() (*transformer.Config, error) {
 func parseFilterConfig() *filter.Config {
 	includeTables := viper.GetStringSlice("PGSTREAM_FILTER_INCLUDE_TABLES")
 	excludeTables := viper.GetStringSlice("PGSTREAM_FILTER_EXCLUDE_TABLES")
-	if len(includeTables) == 0 && len(excludeTables) == 0 {
+	allowedActions := viper.GetStringSlice("PGSTREAM_FILTER_ALLOWED_ACTIONS")
+	deniedActions := viper.GetStringSlice("PGSTREAM_FILTER_DENIED_ACTIONS")
+	if len(includeTables) == 0 && len(excludeTables) == 0 && len(allowedActions) == 0 && len(deniedActions) == 0 {
 		return nil
 	}
 
-	return &filter.Config{
+	cfg := &filter.Config{
 		IncludeTables: includeTables,
 		ExcludeTables: excludeTables,
 	}
+	if len(allowedActions) > 0 || len(deniedActions) > 0 {
+		cfg.Actions = &filter.ActionFilter{
+			Allow: toWALActions(allowedActions),
+			Deny:  toWALActions(deniedActions),
+		}
+	}
+	return cfg
+}
+
+func parseTOASTCacheConfig() *toast.Config {
+	if !viper.GetBool("PGSTREAM_TOAST_CACHE_ENABLED") {
+		return nil
+	}
+	return &toast.Config{
+		MaxEntries: viper.GetInt("PGSTREAM_TOAST_CACHE_MAX_ENTRIES"),
+		MaxBytes:   viper.GetInt64("PGSTREAM_TOAST_CACHE_MAX_BYTES"),
+	}
+}
+
+func parseBeforeAfterConfig() *transform.Config {
+	if !viper.GetBool("PGSTREAM_BEFORE_AFTER_NORMALIZER_ENABLED") {
+		return nil
+	}
+	return &transform.Config{
+		MaxEntries: viper.GetInt("PGSTREAM_BEFORE_AFTER_NORMALIZER_MAX_ENTRIES"),
+		MaxBytes:   viper.GetInt64("PGSTREAM_BEFORE_AFTER_NORMALIZER_MAX_BYTES"),
+	}
+}
+
+func parsePGAuditConfig() *pgaudit.Config {
+	if !viper.GetBool("PGSTREAM_PG_AUDIT_ENABLED") {
+		return nil
+	}
+	return &pgaudit.Config{
+		LogFile:      viper.GetString("PGSTREAM_PG_AUDIT_LOG_FILE"),
+		Table:        viper.GetString("PGSTREAM_PG_AUDIT_TABLE"),
+		URL:          viper.GetString("PGSTREAM_PG_AUDIT_URL"),
+		PollInterval: viper.GetDuration("PGSTREAM_PG_AUDIT_POLL_INTERVAL"),
+		MatchTimeout: viper.GetDuration("PGSTREAM_PG_AUDIT_MATCH_TIMEOUT"),
+		MaxEntries:   viper.GetInt("PGSTREAM_PG_AUDIT_MAX_ENTRIES"),
+		FromStart:    viper.GetBool("PGSTREAM_PG_AUDIT_FROM_START"),
+	}
+}
+
+func parseMigrationSafeModeConfig() *migration.Config {
+	if !viper.GetBool("PGSTREAM_MIGRATION_SAFE_MODE_ENABLED") {
+		return nil
+	}
+	return &migration.Config{
+		LockName:          viper.GetString("PGSTREAM_MIGRATION_SAFE_MODE_LOCK_NAME"),
+		CheckInterval:     viper.GetDuration("PGSTREAM_MIGRATION_SAFE_MODE_CHECK_INTERVAL"),
+		MaxBufferedEvents: viper.GetInt("PGSTREAM_MIGRATION_SAFE_MODE_MAX_BUFFERED_EVENTS"),
+	}
+}
+
+func parseBulkUpdateCoalescerConfig() *coalesce.Config {
+	if !viper.GetBool("PGSTREAM_BULK_UPDATE_COALESCER_ENABLED") {
+		return nil
+	}
+	return &coalesce.Config{
+		Tables:          viper.GetStringSlice("PGSTREAM_BULK_UPDATE_COALESCER_TABLES"),
+		Window:          viper.GetDuration("PGSTREAM_BULK_UPDATE_COALESCER_WINDOW"),
+		MaxBufferedRows: viper.GetInt("PGSTREAM_BULK_UPDATE_COALESCER_MAX_BUFFERED_ROWS"),
+	}
+}
+
+func parseXIDSequencerConfig() *sequencer.Config {
+	if !viper.GetBool("PGSTREAM_XID_SEQUENCER_ENABLED") {
+		return nil
+	}
+	return &sequencer.Config{
+		MaxBufferedEvents: viper.GetInt("PGSTREAM_XID_SEQUENCER_MAX_BUFFERED_EVENTS"),
+	}
+}
+
+func parseSinkHealthCheckConfig() *healthcheck.Config {
+	if !viper.GetBool("PGSTREAM_SINK_HEALTH_CHECK_ENABLED") {
+		return nil
+	}
+	return &healthcheck.Config{
+		Interval:       viper.GetDuration("PGSTREAM_SINK_HEALTH_CHECK_INTERVAL"),
+		MaxStartupWait: viper.GetDuration("PGSTREAM_SINK_HEALTH_CHECK_MAX_STARTUP_WAIT"),
+	}
+}
+
+func parseTableSizeThrottleConfig() *throttle.Config {
+	if !viper.GetBool("PGSTREAM_TABLE_SIZE_THROTTLE_ENABLED") {
+		return nil
+	}
+	return &throttle.Config{
+		MinTableSize:         viper.GetInt64("PGSTREAM_TABLE_SIZE_THROTTLE_MIN_TABLE_SIZE"),
+		EventsPerSecondPerGB: viper.GetFloat64("PGSTREAM_TABLE_SIZE_THROTTLE_EVENTS_PER_SECOND_PER_GB"),
+		RefreshInterval:      viper.GetDuration("PGSTREAM_TABLE_SIZE_THROTTLE_REFRESH_INTERVAL"),
+	}
+}
+
+// parseSortKeyConfig parses the table sort keys, provided as a list of
+// table=column pairs.
+func parseSortKeyConfig() (*redshift.Config, error) {
+	tableSortKeyPairs := viper.GetStringSlice("PGSTREAM_SORT_KEY_TABLES")
+	if len(tableSortKeyPairs) == 0 {
+		return nil, nil
+	}
+
+	tables := make(map[string]string, len(tableSortKeyPairs))
+	for _, pair := range tableSortKeyPairs {
+		table, column, found := strings.Cut(pair, "=")
+		if !found || table == "" || column == "" {
+			return nil, fmt.Errorf("%w: %q", errInvalidTableSortKeyPair, pair)
+		}
+		tables[table] = column
+	}
+
+	return &redshift.Config{
+		Tables:            tables,
+		Window:            viper.GetDuration("PGSTREAM_SORT_KEY_WINDOW"),
+		MaxBufferedEvents: viper.GetInt("PGSTREAM_SORT_KEY_MAX_BUFFERED_EVENTS"),
+		WarnOutOfOrder:    viper.GetBool("PGSTREAM_SORT_KEY_WARN_OUT_OF_ORDER"),
+	}, nil
+}
+
+// parseValidatorConfig parses the table JSON schemas, provided as a list of
+// table=location pairs. The check constraints use the source postgres url to
+// retrieve the schema log.
+func parseValidatorConfig() (*validate.Config, error) {
+	tableSchemaPairs := viper.GetStringSlice("PGSTREAM_SCHEMA_VALIDATION_TABLE_SCHEMAS")
+	checkConstraints := viper.GetBool("PGSTREAM_SCHEMA_VALIDATION_CHECK_CONSTRAINTS")
+	if len(tableSchemaPairs) == 0 && !checkConstraints {
+		return nil, nil
+	}
+
+	tableSchemas := make(map[string]string, len(tableSchemaPairs))
+	for _, pair := range tableSchemaPairs {
+		table, location, found := strings.Cut(pair, "=")
+		if !found || table == "" || location == "" {
+			return nil, fmt.Errorf("%w: %q", errInvalidTableSchemaPair, pair)
+		}
+		tableSchemas[table] = location
+	}
+
+	cfg := &validate.Config{
+		TableSchemas:   tableSchemas,
+		DeadLetterFile: viper.GetString("PGSTREAM_SCHEMA_VALIDATION_DEAD_LETTER_FILE"),
+	}
+	if checkConstraints {
+		pgURL := viper.GetString("PGSTREAM_POSTGRES_LISTENER_URL")
+		if pgURL == "" {
+			return nil, errInvalidCheckConstraintsConfig
+		}
+		cfg.CheckConstraintsStore = &pgschemalog.Config{
+			URL: pgURL,
+		}
+	}
+	return cfg, nil
+}
+
+func parseConverterConfig() *converter.Config {
+	byteaEnabled := viper.GetBool("PGSTREAM_CONVERTER_BYTEA_ENABLED")
+	normalizeTimestamps := viper.GetBool("PGSTREAM_CONVERTER_NORMALIZE_TIMESTAMPS")
+	geometricTypes := viper.GetBool("PGSTREAM_CONVERTER_GEOMETRIC_TYPES")
+	networkAddresses := viper.GetBool("PGSTREAM_CONVERTER_NETWORK_ADDRESSES")
+	fullTextSearchTypes := viper.GetBool("PGSTREAM_CONVERTER_FULL_TEXT_SEARCH_TYPES")
+	if !byteaEnabled && !normalizeTimestamps && !geometricTypes && !networkAddresses && !fullTextSearchTypes {
+		return nil
+	}
+
+	cfg := &converter.Config{
+		NormalizeTimestamps: normalizeTimestamps,
+		GeometricTypes:      geometricTypes,
+		NetworkAddresses:    networkAddresses,
+		FullTextSearchTypes: fullTextSearchTypes,
+	}
+	if byteaEnabled {
+		cfg.Bytea = &converter.ByteaConfig{
+			OutputFormat: viper.GetString("PGSTREAM_CONVERTER_BYTEA_OUTPUT_FORMAT"),
+		}
+	}
+	return cfg
 }
 
 func parseTLSConfig(prefix string) tls.Config {
@@ -5,27 +5,64 @@ package config
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/audit/pgaudit"
 	"github.com/xataio/pgstream/pkg/backoff"
+	"github.com/xataio/pgstream/pkg/debug"
+	"github.com/xataio/pgstream/pkg/health"
 	"github.com/xataio/pgstream/pkg/kafka"
+	"github.com/xataio/pgstream/pkg/metrics/cloudwatch"
+	"github.com/xataio/pgstream/pkg/metrics/prometheus"
 	"github.com/xataio/pgstream/pkg/otel"
 	pgschemalog "github.com/xataio/pgstream/pkg/schemalog/postgres"
 	pgsnapshotgenerator "github.com/xataio/pgstream/pkg/snapshot/generator/postgres/data"
 	"github.com/xataio/pgstream/pkg/snapshot/generator/postgres/schema/pgdumprestore"
 	"github.com/xataio/pgstream/pkg/stream"
 	"github.com/xataio/pgstream/pkg/tls"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer/committer"
+	filecheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/file"
 	kafkacheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/kafka"
+	pgcheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/postgres"
+	redischeckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/redis"
+	pglistener "github.com/xataio/pgstream/pkg/wal/listener/postgres"
 	"github.com/xataio/pgstream/pkg/wal/listener/snapshot/adapter"
 	snapshotbuilder "github.com/xataio/pgstream/pkg/wal/listener/snapshot/builder"
+	"github.com/xataio/pgstream/pkg/wal/processor/amqp"
+	"github.com/xataio/pgstream/pkg/wal/processor/auditlog"
 	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	"github.com/xataio/pgstream/pkg/wal/processor/bigquery"
+	"github.com/xataio/pgstream/pkg/wal/processor/clickhouse"
+	"github.com/xataio/pgstream/pkg/wal/processor/coalesce"
+	"github.com/xataio/pgstream/pkg/wal/processor/converter"
+	"github.com/xataio/pgstream/pkg/wal/processor/dynamodb"
+	"github.com/xataio/pgstream/pkg/wal/processor/filelog"
 	"github.com/xataio/pgstream/pkg/wal/processor/filter"
+	"github.com/xataio/pgstream/pkg/wal/processor/healthcheck"
 	"github.com/xataio/pgstream/pkg/wal/processor/injector"
 	kafkaprocessor "github.com/xataio/pgstream/pkg/wal/processor/kafka"
+	"github.com/xataio/pgstream/pkg/wal/processor/kafka/partition"
+	"github.com/xataio/pgstream/pkg/wal/processor/migration"
+	"github.com/xataio/pgstream/pkg/wal/processor/objectstore"
 	"github.com/xataio/pgstream/pkg/wal/processor/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/postgres/fkorder"
+	"github.com/xataio/pgstream/pkg/wal/processor/pubsub"
+	"github.com/xataio/pgstream/pkg/wal/processor/redshift"
+	"github.com/xataio/pgstream/pkg/wal/processor/router"
+	"github.com/xataio/pgstream/pkg/wal/processor/scd"
 	"github.com/xataio/pgstream/pkg/wal/processor/search"
 	"github.com/xataio/pgstream/pkg/wal/processor/search/store"
+	"github.com/xataio/pgstream/pkg/wal/processor/sequencer"
+	"github.com/xataio/pgstream/pkg/wal/processor/snowflake"
+	"github.com/xataio/pgstream/pkg/wal/processor/throttle"
+	"github.com/xataio/pgstream/pkg/wal/processor/toast"
+	"github.com/xataio/pgstream/pkg/wal/processor/transform"
 	"github.com/xataio/pgstream/pkg/wal/processor/transformer"
+	"github.com/xataio/pgstream/pkg/wal/processor/typeevolution"
+	"github.com/xataio/pgstream/pkg/wal/processor/validate"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/notifier"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/server"
 	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
@@ -36,9 +73,36 @@ type InstrumentationConfig struct {
 	Traces  *TracesConfig  `mapstructure:"traces" yaml:"traces"`
 }
 
+type ShutdownConfig struct {
+	Timeout int `mapstructure:"timeout" yaml:"timeout"`
+}
+
+type HealthConfig struct {
+	ListenAddress          string `mapstructure:"listen_address" yaml:"listen_address"`
+	MaxReplicationLagBytes int64  `mapstructure:"max_replication_lag_bytes" yaml:"max_replication_lag_bytes"`
+}
+
+type DebugConfig struct {
+	ListenAddress string `mapstructure:"listen_address" yaml:"listen_address"`
+}
+
 type MetricsConfig struct {
-	Endpoint           string `mapstructure:"endpoint" yaml:"endpoint"`
-	CollectionInterval int    `mapstructure:"collection_interval" yaml:"collection_interval"`
+	Endpoint            string                   `mapstructure:"endpoint" yaml:"endpoint"`
+	CollectionInterval  int                      `mapstructure:"collection_interval" yaml:"collection_interval"`
+	CloudWatch          *CloudWatchMetricsConfig `mapstructure:"cloudwatch" yaml:"cloudwatch"`
+	Prometheus          *PrometheusMetricsConfig `mapstructure:"prometheus" yaml:"prometheus"`
+	MaxTableCardinality int                      `mapstructure:"max_table_cardinality" yaml:"max_table_cardinality"`
+}
+
+type PrometheusMetricsConfig struct {
+	ListenAddress string `mapstructure:"listen_address" yaml:"listen_address"`
+}
+
+type CloudWatchMetricsConfig struct {
+	Namespace      string            `mapstructure:"namespace" yaml:"namespace"`
+	Region         string            `mapstructure:"region" yaml:"region"`
+	HighResolution bool              `mapstructure:"high_resolution" yaml:"high_resolution"`
+	Dimensions     map[string]string `mapstructure:"dimensions" yaml:"dimensions"`
 }
 
 type TracesConfig struct {
@@ -50,26 +114,96 @@ type YAMLConfig struct {
 	Source    SourceConfig    `mapstructure:"source" yaml:"source"`
 	Target    TargetConfig    `mapstructure:"target" yaml:"target"`
 	Modifiers ModifiersConfig `mapstructure:"modifiers" yaml:"modifiers"`
+	// Replay is only used by the replay command
+	Replay *ReplayConfig `mapstructure:"replay" yaml:"replay"`
+}
+
+type ReplayConfig struct {
+	StartLSN string              `mapstructure:"start_lsn" yaml:"start_lsn"`
+	EndLSN   string              `mapstructure:"end_lsn" yaml:"end_lsn"`
+	Archive  ReplayArchiveConfig `mapstructure:"archive" yaml:"archive"`
+}
+
+// ReplayArchiveConfig is the archive the events are replayed from, as written
+// by a file log or object store target.
+type ReplayArchiveConfig struct {
+	FileLog     *ReplayFileLogConfig     `mapstructure:"file_log" yaml:"file_log"`
+	ObjectStore *ObjectStoreTargetConfig `mapstructure:"object_store" yaml:"object_store"`
+}
+
+type ReplayFileLogConfig struct {
+	Path string `mapstructure:"path" yaml:"path"`
+}
+
+// PipelinesConfig is the configuration of the independent pipelines that can be
+// run within the same pgstream process.
+type PipelinesConfig struct {
+	Pipelines []PipelineConfig `mapstructure:"pipelines" yaml:"pipelines"`
+}
+
+type PipelineConfig struct {
+	Name                 string        `mapstructure:"name" yaml:"name"`
+	RestartPolicy        BackoffConfig `mapstructure:"restart_policy" yaml:"restart_policy"`
+	RestartResetInterval int           `mapstructure:"restart_reset_interval" yaml:"restart_reset_interval"`
+	YAMLConfig           `mapstructure:",squash" yaml:",inline"`
 }
 
 type SourceConfig struct {
-	Postgres *PostgresConfig `mapstructure:"postgres" yaml:"postgres"`
-	Kafka    *KafkaConfig    `mapstructure:"kafka" yaml:"kafka"`
+	Postgres   *PostgresConfig         `mapstructure:"postgres" yaml:"postgres"`
+	Kafka      *KafkaConfig            `mapstructure:"kafka" yaml:"kafka"`
+	Checkpoint *SourceCheckpointConfig `mapstructure:"checkpoint" yaml:"checkpoint"`
+}
+
+type SourceCheckpointConfig struct {
+	CommitInterval        int    `mapstructure:"commit_interval" yaml:"commit_interval"`
+	MaxUncommittedEvents  uint64 `mapstructure:"max_uncommitted_events" yaml:"max_uncommitted_events"`
+	MaxUncommittedBytes   uint64 `mapstructure:"max_uncommitted_bytes" yaml:"max_uncommitted_bytes"`
+	DisableShutdownCommit bool   `mapstructure:"disable_shutdown_commit" yaml:"disable_shutdown_commit"`
 }
 
 type TargetConfig struct {
-	Postgres *PostgresTargetConfig `mapstructure:"postgres" yaml:"postgres"`
-	Kafka    *KafkaTargetConfig    `mapstructure:"kafka" yaml:"kafka"`
-	Search   *SearchConfig         `mapstructure:"search" yaml:"search"`
-	Webhooks *WebhooksConfig       `mapstructure:"webhooks" yaml:"webhooks"`
+	Postgres    *PostgresTargetConfig    `mapstructure:"postgres" yaml:"postgres"`
+	Kafka       *KafkaTargetConfig       `mapstructure:"kafka" yaml:"kafka"`
+	Search      *SearchConfig            `mapstructure:"search" yaml:"search"`
+	Webhooks    *WebhooksConfig          `mapstructure:"webhooks" yaml:"webhooks"`
+	FileLog     *FileLogTargetConfig     `mapstructure:"file_log" yaml:"file_log"`
+	PubSub      *PubSubTargetConfig      `mapstructure:"pubsub" yaml:"pubsub"`
+	AMQP        *AMQPTargetConfig        `mapstructure:"amqp" yaml:"amqp"`
+	AuditLog    *AuditLogTargetConfig    `mapstructure:"audit_log" yaml:"audit_log"`
+	ObjectStore *ObjectStoreTargetConfig `mapstructure:"object_store" yaml:"object_store"`
+	ClickHouse  *ClickHouseTargetConfig  `mapstructure:"clickhouse" yaml:"clickhouse"`
+	BigQuery    *BigQueryTargetConfig    `mapstructure:"bigquery" yaml:"bigquery"`
+	Snowflake   *SnowflakeTargetConfig   `mapstructure:"snowflake" yaml:"snowflake"`
+	DynamoDB    *DynamoDBTargetConfig    `mapstructure:"dynamodb" yaml:"dynamodb"`
+	// Router sends the events to the targets of the routes they match
+	Router *RouterTargetConfig `mapstructure:"router" yaml:"router"`
+}
+
+type RouterTargetConfig struct {
+	Routes []RouteTargetConfig `mapstructure:"routes" yaml:"routes"`
+}
+
+type RouteTargetConfig struct {
+	Name          string       `mapstructure:"name" yaml:"name"`
+	Tables        []string     `mapstructure:"tables" yaml:"tables"`
+	Actions       []string     `mapstructure:"actions" yaml:"actions"`
+	FailurePolicy string       `mapstructure:"failure_policy" yaml:"failure_policy"`
+	Target        TargetConfig `mapstructure:"target" yaml:"target"`
 }
 
 type PostgresConfig struct {
-	URL         string             `mapstructure:"url" yaml:"url"`
-	Mode        string             `mapstructure:"mode" yaml:"mode"`
-	Snapshot    *SnapshotConfig    `mapstructure:"snapshot" yaml:"snapshot"`
-	Replication *ReplicationConfig `mapstructure:"replication" yaml:"replication"`
-	RetryPolicy BackoffConfig      `mapstructure:"retry_policy" yaml:"retry_policy"`
+	URL             string              `mapstructure:"url" yaml:"url"`
+	Auth            *PostgresAuthConfig `mapstructure:"auth" yaml:"auth"`
+	Mode            string              `mapstructure:"mode" yaml:"mode"`
+	Snapshot        *SnapshotConfig     `mapstructure:"snapshot" yaml:"snapshot"`
+	Replication     *ReplicationConfig  `mapstructure:"replication" yaml:"replication"`
+	RetryPolicy     BackoffConfig       `mapstructure:"retry_policy" yaml:"retry_policy"`
+	ReconnectPolicy BackoffConfig       `mapstructure:"reconnect_policy" yaml:"reconnect_policy"`
+}
+
+type PostgresAuthConfig struct {
+	Mode      string `mapstructure:"mode" yaml:"mode"`
+	AWSRegion string `mapstructure:"aws_region" yaml:"aws_region"`
 }
 
 type SnapshotConfig struct {
@@ -89,10 +223,13 @@ type SnapshotRecorderConfig struct {
 }
 
 type SnapshotDataConfig struct {
-	SchemaWorkers  int    `mapstructure:"schema_workers" yaml:"schema_workers"`
-	TableWorkers   int    `mapstructure:"table_workers" yaml:"table_workers"`
-	BatchBytes     uint64 `mapstructure:"batch_bytes" yaml:"batch_bytes"`
-	MaxConnections uint   `mapstructure:"max_connections" yaml:"max_connections"`
+	SchemaWorkers           int    `mapstructure:"schema_workers" yaml:"schema_workers"`
+	TableWorkers            int    `mapstructure:"table_workers" yaml:"table_workers"`
+	BatchBytes              uint64 `mapstructure:"batch_bytes" yaml:"batch_bytes"`
+	MaxConnections          uint   `mapstructure:"max_connections" yaml:"max_connections"`
+	RLSRole                 string `mapstructure:"rls_role" yaml:"rls_role"`
+	SerializableSnapshot    bool   `mapstructure:"serializable_snapshot" yaml:"serializable_snapshot"`
+	MaxSerializationRetries uint   `mapstructure:"max_serialization_retries" yaml:"max_serialization_retries"`
 }
 
 type SnapshotSchemaConfig struct {
@@ -113,7 +250,77 @@ type PgDumpPgRestoreConfig struct {
 }
 
 type ReplicationConfig struct {
-	ReplicationSlot string `mapstructure:"replication_slot" yaml:"replication_slot"`
+	ReplicationSlot       string                 `mapstructure:"replication_slot" yaml:"replication_slot"`
+	SlotOptions           *SlotOptionsConfig     `mapstructure:"slot_options" yaml:"slot_options"`
+	Plugin                *PluginConfig          `mapstructure:"plugin" yaml:"plugin"`
+	SlotMonitor           *SlotMonitorConfig     `mapstructure:"slot_monitor" yaml:"slot_monitor"`
+	Heartbeat             *HeartbeatConfig       `mapstructure:"heartbeat" yaml:"heartbeat"`
+	Publication           *PublicationConfig     `mapstructure:"publication" yaml:"publication"`
+	StandbyStatusInterval int                    `mapstructure:"standby_status_interval" yaml:"standby_status_interval"`
+	CheckpointTable       *CheckpointTableConfig `mapstructure:"checkpoint_table" yaml:"checkpoint_table"`
+	CheckpointFile        *CheckpointFileConfig  `mapstructure:"checkpoint_file" yaml:"checkpoint_file"`
+	CheckpointRedis       *CheckpointRedisConfig `mapstructure:"checkpoint_redis" yaml:"checkpoint_redis"`
+}
+
+type CheckpointRedisConfig struct {
+	Mode                 string        `mapstructure:"mode" yaml:"mode"`
+	Addresses            []string      `mapstructure:"addresses" yaml:"addresses"`
+	MasterName           string        `mapstructure:"master_name" yaml:"master_name"`
+	Username             string        `mapstructure:"username" yaml:"username"`
+	Password             string        `mapstructure:"password" yaml:"password"`
+	SentinelUsername     string        `mapstructure:"sentinel_username" yaml:"sentinel_username"`
+	SentinelPassword     string        `mapstructure:"sentinel_password" yaml:"sentinel_password"`
+	DB                   int           `mapstructure:"db" yaml:"db"`
+	TLS                  *TLSConfig    `mapstructure:"tls" yaml:"tls"`
+	StreamID             string        `mapstructure:"stream_id" yaml:"stream_id"`
+	KeyPrefix            string        `mapstructure:"key_prefix" yaml:"key_prefix"`
+	KeyTTL               int           `mapstructure:"key_ttl" yaml:"key_ttl"`
+	CommitInterval       int           `mapstructure:"commit_interval" yaml:"commit_interval"`
+	MaxUncommittedWindow int           `mapstructure:"max_uncommitted_window" yaml:"max_uncommitted_window"`
+	RetryPolicy          BackoffConfig `mapstructure:"retry_policy" yaml:"retry_policy"`
+}
+
+type CheckpointFileConfig struct {
+	Path          string `mapstructure:"path" yaml:"path"`
+	FlushInterval int    `mapstructure:"flush_interval" yaml:"flush_interval"`
+	Reset         bool   `mapstructure:"reset" yaml:"reset"`
+}
+
+type CheckpointTableConfig struct {
+	URL        string `mapstructure:"url" yaml:"url"`
+	PipelineID string `mapstructure:"pipeline_id" yaml:"pipeline_id"`
+	Table      string `mapstructure:"table" yaml:"table"`
+}
+
+type PluginConfig struct {
+	Name               string `mapstructure:"name" yaml:"name"`
+	FormatVersion      int    `mapstructure:"format_version" yaml:"format_version"`
+	IncludeTransaction bool   `mapstructure:"include_transaction" yaml:"include_transaction"`
+}
+
+type SlotOptionsConfig struct {
+	Temporary       bool `mapstructure:"temporary" yaml:"temporary"`
+	TwoPhase        bool `mapstructure:"two_phase" yaml:"two_phase"`
+	Failover        bool `mapstructure:"failover" yaml:"failover"`
+	CreateIfMissing bool `mapstructure:"create_if_missing" yaml:"create_if_missing"`
+}
+
+type PublicationConfig struct {
+	Name                    string `mapstructure:"name" yaml:"name"`
+	PublishViaPartitionRoot bool   `mapstructure:"publish_via_partition_root" yaml:"publish_via_partition_root"`
+	SkipUnlogged            bool   `mapstructure:"skip_unlogged" yaml:"skip_unlogged"`
+}
+
+type HeartbeatConfig struct {
+	Interval      int    `mapstructure:"interval" yaml:"interval"`
+	TableName     string `mapstructure:"table_name" yaml:"table_name"`
+	ForwardEvents bool   `mapstructure:"forward_events" yaml:"forward_events"`
+}
+
+type SlotMonitorConfig struct {
+	CheckInterval         int   `mapstructure:"check_interval" yaml:"check_interval"`
+	RetainedWALLimitBytes int64 `mapstructure:"retained_wal_limit_bytes" yaml:"retained_wal_limit_bytes"`
+	PauseSnapshotsOnLimit bool  `mapstructure:"pause_snapshots_on_limit" yaml:"pause_snapshots_on_limit"`
 }
 
 type KafkaConfig struct {
@@ -160,21 +367,63 @@ type ConstantBackoffConfig struct {
 }
 
 type PostgresTargetConfig struct {
-	URL               string            `mapstructure:"url" yaml:"url"`
-	Batch             *BatchConfig      `mapstructure:"batch" yaml:"batch"`
-	BulkIngest        *BulkIngestConfig `mapstructure:"bulk_ingest" yaml:"bulk_ingest"`
-	SchemaLogStoreURL string            `mapstructure:"schema_log_store_url" yaml:"schema_log_store_url"`
-	DisableTriggers   bool              `mapstructure:"disable_triggers" yaml:"disable_triggers"`
-	OnConflictAction  string            `mapstructure:"on_conflict_action" yaml:"on_conflict_action"`
-	RetryPolicy       BackoffConfig     `mapstructure:"retry_policy" yaml:"retry_policy"`
-	IgnoreDDL         bool              `mapstructure:"ignore_ddl" yaml:"ignore_ddl"`
+	URL               string              `mapstructure:"url" yaml:"url"`
+	Auth              *PostgresAuthConfig `mapstructure:"auth" yaml:"auth"`
+	Batch             *BatchConfig        `mapstructure:"batch" yaml:"batch"`
+	BulkIngest        *BulkIngestConfig   `mapstructure:"bulk_ingest" yaml:"bulk_ingest"`
+	SchemaLogStoreURL string              `mapstructure:"schema_log_store_url" yaml:"schema_log_store_url"`
+	DisableTriggers   bool                `mapstructure:"disable_triggers" yaml:"disable_triggers"`
+	OnConflictAction  string              `mapstructure:"on_conflict_action" yaml:"on_conflict_action"`
+	RetryPolicy       BackoffConfig       `mapstructure:"retry_policy" yaml:"retry_policy"`
+	IgnoreDDL         bool                `mapstructure:"ignore_ddl" yaml:"ignore_ddl"`
+	// ConflictResolution replaces the on conflict action with a conflict
+	// resolution strategy
+	ConflictResolution *ConflictResolutionConfig `mapstructure:"conflict_resolution" yaml:"conflict_resolution"`
+	// SCDType2 writes the changes as row versions instead of in place
+	SCDType2 *SCDType2Config `mapstructure:"scd_type2" yaml:"scd_type2"`
+	// FKOrdering writes the inserts of each transaction in foreign key
+	// dependency order
+	FKOrdering *FKOrderingConfig `mapstructure:"fk_ordering" yaml:"fk_ordering"`
+}
+
+type FKOrderingConfig struct {
+	Enabled           bool   `mapstructure:"enabled" yaml:"enabled"`
+	URL               string `mapstructure:"url" yaml:"url"`
+	MaxBufferedEvents int    `mapstructure:"max_buffered_events" yaml:"max_buffered_events"`
+}
+
+type SCDType2Config struct {
+	Enabled            bool   `mapstructure:"enabled" yaml:"enabled"`
+	ValidFromColumn    string `mapstructure:"valid_from_column" yaml:"valid_from_column"`
+	ValidToColumn      string `mapstructure:"valid_to_column" yaml:"valid_to_column"`
+	MaxConflictRetries int    `mapstructure:"max_conflict_retries" yaml:"max_conflict_retries"`
+}
+
+type ConflictResolutionConfig struct {
+	Strategy  string `mapstructure:"strategy" yaml:"strategy"`
+	LSNColumn string `mapstructure:"lsn_column" yaml:"lsn_column"`
 }
 
 type KafkaTargetConfig struct {
-	Servers []string         `mapstructure:"servers" yaml:"servers"`
-	Topic   KafkaTopicConfig `mapstructure:"topic" yaml:"topic"`
-	TLS     *TLSConfig       `mapstructure:"tls" yaml:"tls"`
-	Batch   *BatchConfig     `mapstructure:"batch" yaml:"batch"`
+	Servers      []string                 `mapstructure:"servers" yaml:"servers"`
+	Topic        KafkaTopicConfig         `mapstructure:"topic" yaml:"topic"`
+	TLS          *TLSConfig               `mapstructure:"tls" yaml:"tls"`
+	Batch        *BatchConfig             `mapstructure:"batch" yaml:"batch"`
+	Partitioning *KafkaPartitioningConfig `mapstructure:"partitioning" yaml:"partitioning"`
+	// AzureEventHubs configures the connection to an Azure Event Hubs
+	// namespace Kafka endpoint. The servers, TLS and authentication settings
+	// are derived from the connection string.
+	AzureEventHubs *AzureEventHubsConfig `mapstructure:"azure_event_hubs" yaml:"azure_event_hubs"`
+}
+
+type AzureEventHubsConfig struct {
+	ConnectionString string `mapstructure:"connection_string" yaml:"connection_string"`
+	EventHub         string `mapstructure:"event_hub" yaml:"event_hub"`
+}
+
+type KafkaPartitioningConfig struct {
+	Strategy     string `mapstructure:"strategy" yaml:"strategy"`
+	TenantColumn string `mapstructure:"tenant_column" yaml:"tenant_column"`
 }
 
 type KafkaTopicConfig struct {
@@ -191,6 +440,150 @@ type SearchConfig struct {
 	Backoff *BackoffConfig `mapstructure:"backoff" yaml:"backoff"`
 }
 
+type FileLogTargetConfig struct {
+	Path             string       `mapstructure:"path" yaml:"path"`
+	RotationInterval int          `mapstructure:"rotation_interval" yaml:"rotation_interval"`
+	MaxFileBytes     int64        `mapstructure:"max_file_bytes" yaml:"max_file_bytes"`
+	MaxRotations     int          `mapstructure:"max_rotations" yaml:"max_rotations"`
+	TailBufferSize   int          `mapstructure:"tail_buffer_size" yaml:"tail_buffer_size"`
+	Batch            *BatchConfig `mapstructure:"batch" yaml:"batch"`
+}
+
+type PubSubTargetConfig struct {
+	ProjectID              string             `mapstructure:"project_id" yaml:"project_id"`
+	DefaultTopic           string             `mapstructure:"default_topic" yaml:"default_topic"`
+	TableTopics            []TableTopicConfig `mapstructure:"table_topics" yaml:"table_topics"`
+	Endpoint               string             `mapstructure:"endpoint" yaml:"endpoint"`
+	EmulatorHost           string             `mapstructure:"emulator_host" yaml:"emulator_host"`
+	CredentialsFile        string             `mapstructure:"credentials_file" yaml:"credentials_file"`
+	MaxOutstandingMessages int64              `mapstructure:"max_outstanding_messages" yaml:"max_outstanding_messages"`
+	RetryPolicy            BackoffConfig      `mapstructure:"retry_policy" yaml:"retry_policy"`
+	Batch                  *BatchConfig       `mapstructure:"batch" yaml:"batch"`
+}
+
+type TableTopicConfig struct {
+	Table string `mapstructure:"table" yaml:"table"`
+	Topic string `mapstructure:"topic" yaml:"topic"`
+}
+
+type AMQPTargetConfig struct {
+	URL             string                `mapstructure:"url" yaml:"url"`
+	Exchange        string                `mapstructure:"exchange" yaml:"exchange"`
+	MessageTTL      int                   `mapstructure:"message_ttl" yaml:"message_ttl"`
+	Priority        int                   `mapstructure:"priority" yaml:"priority"`
+	TablePriorities []TablePriorityConfig `mapstructure:"table_priorities" yaml:"table_priorities"`
+	ConfirmTimeout  int                   `mapstructure:"confirm_timeout" yaml:"confirm_timeout"`
+	TLS             *TLSConfig            `mapstructure:"tls" yaml:"tls"`
+	RetryPolicy     BackoffConfig         `mapstructure:"retry_policy" yaml:"retry_policy"`
+	Batch           *BatchConfig          `mapstructure:"batch" yaml:"batch"`
+}
+
+type AuditLogTargetConfig struct {
+	IncludeValues   bool                    `mapstructure:"include_values" yaml:"include_values"`
+	RedactedColumns []string                `mapstructure:"redacted_columns" yaml:"redacted_columns"`
+	File            *AuditLogFileConfig     `mapstructure:"file" yaml:"file"`
+	Postgres        *AuditLogPostgresConfig `mapstructure:"postgres" yaml:"postgres"`
+	Batch           *BatchConfig            `mapstructure:"batch" yaml:"batch"`
+}
+
+type AuditLogFileConfig struct {
+	Path             string `mapstructure:"path" yaml:"path"`
+	RotationInterval int    `mapstructure:"rotation_interval" yaml:"rotation_interval"`
+	MaxFileBytes     int64  `mapstructure:"max_file_bytes" yaml:"max_file_bytes"`
+	MaxRotations     int    `mapstructure:"max_rotations" yaml:"max_rotations"`
+}
+
+type AuditLogPostgresConfig struct {
+	URL       string `mapstructure:"url" yaml:"url"`
+	Table     string `mapstructure:"table" yaml:"table"`
+	Retention int    `mapstructure:"retention" yaml:"retention"`
+}
+
+type ObjectStoreTargetConfig struct {
+	Provider        string       `mapstructure:"provider" yaml:"provider"`
+	Bucket          string       `mapstructure:"bucket" yaml:"bucket"`
+	Prefix          string       `mapstructure:"prefix" yaml:"prefix"`
+	Format          string       `mapstructure:"format" yaml:"format"`
+	Endpoint        string       `mapstructure:"endpoint" yaml:"endpoint"`
+	Region          string       `mapstructure:"region" yaml:"region"`
+	AccessKeyID     string       `mapstructure:"access_key_id" yaml:"access_key_id"`
+	SecretAccessKey string       `mapstructure:"secret_access_key" yaml:"secret_access_key"`
+	CredentialsFile string       `mapstructure:"credentials_file" yaml:"credentials_file"`
+	AccountName     string       `mapstructure:"account_name" yaml:"account_name"`
+	AccountKey      string       `mapstructure:"account_key" yaml:"account_key"`
+	PartSize        int64        `mapstructure:"part_size" yaml:"part_size"`
+	Batch           *BatchConfig `mapstructure:"batch" yaml:"batch"`
+}
+
+type ClickHouseTargetConfig struct {
+	URL            string            `mapstructure:"url" yaml:"url"`
+	User           string            `mapstructure:"user" yaml:"user"`
+	Password       string            `mapstructure:"password" yaml:"password"`
+	Database       string            `mapstructure:"database" yaml:"database"`
+	Mode           string            `mapstructure:"mode" yaml:"mode"`
+	TableModes     map[string]string `mapstructure:"table_modes" yaml:"table_modes"`
+	DeadLetterFile string            `mapstructure:"dead_letter_file" yaml:"dead_letter_file"`
+	RetryPolicy    BackoffConfig     `mapstructure:"retry_policy" yaml:"retry_policy"`
+	Batch          *BatchConfig      `mapstructure:"batch" yaml:"batch"`
+}
+
+type BigQueryTargetConfig struct {
+	ProjectID       string        `mapstructure:"project_id" yaml:"project_id"`
+	CredentialsFile string        `mapstructure:"credentials_file" yaml:"credentials_file"`
+	Dataset         string        `mapstructure:"dataset" yaml:"dataset"`
+	Table           string        `mapstructure:"table" yaml:"table"`
+	Location        string        `mapstructure:"location" yaml:"location"`
+	Mode            string        `mapstructure:"mode" yaml:"mode"`
+	MaxStaleness    int           `mapstructure:"max_staleness" yaml:"max_staleness"`
+	RetryPolicy     BackoffConfig `mapstructure:"retry_policy" yaml:"retry_policy"`
+	Batch           *BatchConfig  `mapstructure:"batch" yaml:"batch"`
+}
+
+type SnowflakeTargetConfig struct {
+	URL                 string                    `mapstructure:"url" yaml:"url"`
+	Warehouse           string                    `mapstructure:"warehouse" yaml:"warehouse"`
+	WarehouseAutoResume bool                      `mapstructure:"warehouse_auto_resume" yaml:"warehouse_auto_resume"`
+	Role                string                    `mapstructure:"role" yaml:"role"`
+	SessionParameters   map[string]string         `mapstructure:"session_parameters" yaml:"session_parameters"`
+	Stage               string                    `mapstructure:"stage" yaml:"stage"`
+	Schema              string                    `mapstructure:"schema" yaml:"schema"`
+	Table               string                    `mapstructure:"table" yaml:"table"`
+	Mode                string                    `mapstructure:"mode" yaml:"mode"`
+	PrivateKeyFile      string                    `mapstructure:"private_key_file" yaml:"private_key_file"`
+	Streaming           *SnowflakeStreamingConfig `mapstructure:"streaming" yaml:"streaming"`
+	RetryPolicy         BackoffConfig             `mapstructure:"retry_policy" yaml:"retry_policy"`
+	Batch               *BatchConfig              `mapstructure:"batch" yaml:"batch"`
+}
+
+type SnowflakeStreamingConfig struct {
+	Account       string `mapstructure:"account" yaml:"account"`
+	User          string `mapstructure:"user" yaml:"user"`
+	Database      string `mapstructure:"database" yaml:"database"`
+	Pipe          string `mapstructure:"pipe" yaml:"pipe"`
+	Channel       string `mapstructure:"channel" yaml:"channel"`
+	CommitTimeout int    `mapstructure:"commit_timeout" yaml:"commit_timeout"`
+	Endpoint      string `mapstructure:"endpoint" yaml:"endpoint"`
+}
+
+type DynamoDBTargetConfig struct {
+	Region           string                `mapstructure:"region" yaml:"region"`
+	Endpoint         string                `mapstructure:"endpoint" yaml:"endpoint"`
+	Tables           []DynamoDBTableConfig `mapstructure:"tables" yaml:"tables"`
+	Table            string                `mapstructure:"table" yaml:"table"`
+	VersionAttribute string                `mapstructure:"version_attribute" yaml:"version_attribute"`
+	Batch            *BatchConfig          `mapstructure:"batch" yaml:"batch"`
+}
+
+type DynamoDBTableConfig struct {
+	Table       string `mapstructure:"table" yaml:"table"`
+	TargetTable string `mapstructure:"target_table" yaml:"target_table"`
+}
+
+type TablePriorityConfig struct {
+	Table    string `mapstructure:"table" yaml:"table"`
+	Priority int    `mapstructure:"priority" yaml:"priority"`
+}
+
 type BatchConfig struct {
 	Timeout          int                  `mapstructure:"timeout" yaml:"timeout"`
 	Size             int                  `mapstructure:"size" yaml:"size"`
@@ -243,9 +636,116 @@ type WebhookNotifierConfig struct {
 }
 
 type ModifiersConfig struct {
-	Injector        *InjectorConfig        `mapstructure:"injector" yaml:"injector"`
-	Transformations *TransformationsConfig `mapstructure:"transformations" yaml:"transformations"`
-	Filter          *FilterConfig          `mapstructure:"filter" yaml:"filter"`
+	Injector            *InjectorConfig            `mapstructure:"injector" yaml:"injector"`
+	Transformations     *TransformationsConfig     `mapstructure:"transformations" yaml:"transformations"`
+	Filter              *FilterConfig              `mapstructure:"filter" yaml:"filter"`
+	Converter           *ConverterConfig           `mapstructure:"converter" yaml:"converter"`
+	TOASTCache          *TOASTCacheConfig          `mapstructure:"toast_cache" yaml:"toast_cache"`
+	BeforeAfter         *BeforeAfterConfig         `mapstructure:"before_after_normalizer" yaml:"before_after_normalizer"`
+	Validation          *ValidationConfig          `mapstructure:"schema_validation" yaml:"schema_validation"`
+	MigrationSafeMode   *MigrationSafeModeConfig   `mapstructure:"migration_safe_mode" yaml:"migration_safe_mode"`
+	PGAudit             *PGAuditConfig             `mapstructure:"pg_audit" yaml:"pg_audit"`
+	SortKey             *SortKeyConfig             `mapstructure:"sort_key" yaml:"sort_key"`
+	BulkUpdateCoalescer *BulkUpdateCoalescerConfig `mapstructure:"bulk_update_coalescer" yaml:"bulk_update_coalescer"`
+	XIDSequencer        *XIDSequencerConfig        `mapstructure:"xid_sequencer" yaml:"xid_sequencer"`
+	SinkHealthCheck     *SinkHealthCheckConfig     `mapstructure:"sink_health_check" yaml:"sink_health_check"`
+	TableSizeThrottle   *TableSizeThrottleConfig   `mapstructure:"table_size_throttle" yaml:"table_size_throttle"`
+	TypeEvolution       *TypeEvolutionConfig       `mapstructure:"type_evolution" yaml:"type_evolution"`
+}
+
+type ValidationConfig struct {
+	Tables           []TableSchemaConfig `mapstructure:"tables" yaml:"tables"`
+	DeadLetterFile   string              `mapstructure:"dead_letter_file" yaml:"dead_letter_file"`
+	CheckConstraints bool                `mapstructure:"check_constraints" yaml:"check_constraints"`
+}
+
+type TableSchemaConfig struct {
+	Table      string `mapstructure:"table" yaml:"table"`
+	JSONSchema string `mapstructure:"json_schema" yaml:"json_schema"`
+}
+
+type TOASTCacheConfig struct {
+	Enabled    bool  `mapstructure:"enabled" yaml:"enabled"`
+	MaxEntries int   `mapstructure:"max_entries" yaml:"max_entries"`
+	MaxBytes   int64 `mapstructure:"max_bytes" yaml:"max_bytes"`
+}
+
+type BeforeAfterConfig struct {
+	Enabled    bool  `mapstructure:"enabled" yaml:"enabled"`
+	MaxEntries int   `mapstructure:"max_entries" yaml:"max_entries"`
+	MaxBytes   int64 `mapstructure:"max_bytes" yaml:"max_bytes"`
+}
+
+type XIDSequencerConfig struct {
+	Enabled           bool `mapstructure:"enabled" yaml:"enabled"`
+	MaxBufferedEvents int  `mapstructure:"max_buffered_events" yaml:"max_buffered_events"`
+}
+
+type SinkHealthCheckConfig struct {
+	Enabled        bool `mapstructure:"enabled" yaml:"enabled"`
+	Interval       int  `mapstructure:"interval" yaml:"interval"`
+	MaxStartupWait int  `mapstructure:"max_startup_wait" yaml:"max_startup_wait"`
+}
+
+type TableSizeThrottleConfig struct {
+	Enabled              bool    `mapstructure:"enabled" yaml:"enabled"`
+	MinTableSize         int64   `mapstructure:"min_table_size" yaml:"min_table_size"`
+	EventsPerSecondPerGB float64 `mapstructure:"events_per_second_per_gb" yaml:"events_per_second_per_gb"`
+	RefreshInterval      int     `mapstructure:"refresh_interval" yaml:"refresh_interval"`
+}
+
+type TypeEvolutionConfig struct {
+	Enabled      bool   `mapstructure:"enabled" yaml:"enabled"`
+	SchemalogURL string `mapstructure:"schemalog_url" yaml:"schemalog_url"`
+}
+
+type MigrationSafeModeConfig struct {
+	Enabled           bool   `mapstructure:"enabled" yaml:"enabled"`
+	LockName          string `mapstructure:"lock_name" yaml:"lock_name"`
+	CheckInterval     int    `mapstructure:"check_interval" yaml:"check_interval"`
+	MaxBufferedEvents int    `mapstructure:"max_buffered_events" yaml:"max_buffered_events"`
+}
+
+type PGAuditConfig struct {
+	Enabled      bool   `mapstructure:"enabled" yaml:"enabled"`
+	LogFile      string `mapstructure:"log_file" yaml:"log_file"`
+	Table        string `mapstructure:"table" yaml:"table"`
+	URL          string `mapstructure:"url" yaml:"url"`
+	PollInterval int    `mapstructure:"poll_interval" yaml:"poll_interval"`
+	MatchTimeout int    `mapstructure:"match_timeout" yaml:"match_timeout"`
+	MaxEntries   int    `mapstructure:"max_entries" yaml:"max_entries"`
+	FromStart    bool   `mapstructure:"from_start" yaml:"from_start"`
+}
+
+type SortKeyConfig struct {
+	Tables            []TableSortKeyConfig `mapstructure:"tables" yaml:"tables"`
+	Window            int                  `mapstructure:"window" yaml:"window"`
+	MaxBufferedEvents int                  `mapstructure:"max_buffered_events" yaml:"max_buffered_events"`
+	WarnOutOfOrder    bool                 `mapstructure:"warn_out_of_order" yaml:"warn_out_of_order"`
+}
+
+type TableSortKeyConfig struct {
+	Table  string `mapstructure:"table" yaml:"table"`
+	Column string `mapstructure:"column" yaml:"column"`
+}
+
+type BulkUpdateCoalescerConfig struct {
+	Enabled         bool     `mapstructure:"enabled" yaml:"enabled"`
+	Tables          []string `mapstructure:"tables" yaml:"tables"`
+	Window          int      `mapstructure:"window" yaml:"window"`
+	MaxBufferedRows int      `mapstructure:"max_buffered_rows" yaml:"max_buffered_rows"`
+}
+
+type ConverterConfig struct {
+	Bytea               *ByteaConverterConfig `mapstructure:"bytea" yaml:"bytea"`
+	NormalizeTimestamps bool                  `mapstructure:"normalize_timestamps" yaml:"normalize_timestamps"`
+	GeometricTypes      bool                  `mapstructure:"geometric_types" yaml:"geometric_types"`
+	NetworkAddresses    bool                  `mapstructure:"network_addresses" yaml:"network_addresses"`
+	FullTextSearchTypes bool                  `mapstructure:"full_text_search_types" yaml:"full_text_search_types"`
+}
+
+type ByteaConverterConfig struct {
+	OutputFormat string `mapstructure:"output_format" yaml:"output_format"`
 }
 
 type InjectorConfig struct {
@@ -254,15 +754,52 @@ type InjectorConfig struct {
 }
 
 type FilterConfig struct {
-	IncludeTables []string `mapstructure:"include_tables" yaml:"include_tables"`
-	ExcludeTables []string `mapstructure:"exclude_tables" yaml:"exclude_tables"`
+	IncludeTables        []string                  `mapstructure:"include_tables" yaml:"include_tables"`
+	ExcludeTables        []string                  `mapstructure:"exclude_tables" yaml:"exclude_tables"`
+	Actions              *ActionFilterConfig       `mapstructure:"actions" yaml:"actions"`
+	TableActions         []TableActionFilterConfig `mapstructure:"table_actions" yaml:"table_actions"`
+	ChangedColumns       []ChangedColumnsConfig    `mapstructure:"changed_columns" yaml:"changed_columns"`
+	Sampling             []SamplingConfig          `mapstructure:"sampling" yaml:"sampling"`
+	RowFilters           []RowFilterConfig         `mapstructure:"row_filters" yaml:"row_filters"`
+	RowFilterErrorPolicy string                    `mapstructure:"row_filter_error_policy" yaml:"row_filter_error_policy"`
+}
+
+type ActionFilterConfig struct {
+	Allow []string `mapstructure:"allow" yaml:"allow"`
+	Deny  []string `mapstructure:"deny" yaml:"deny"`
+}
+
+type TableActionFilterConfig struct {
+	Tables []string `mapstructure:"tables" yaml:"tables"`
+	Allow  []string `mapstructure:"allow" yaml:"allow"`
+	Deny   []string `mapstructure:"deny" yaml:"deny"`
+}
+
+type ChangedColumnsConfig struct {
+	Tables  []string `mapstructure:"tables" yaml:"tables"`
+	Columns []string `mapstructure:"columns" yaml:"columns"`
+}
+
+type SamplingConfig struct {
+	Tables     []string `mapstructure:"tables" yaml:"tables"`
+	Percentage float64  `mapstructure:"percentage" yaml:"percentage"`
+	Mode       string   `mapstructure:"mode" yaml:"mode"`
+	KeyColumns []string `mapstructure:"key_columns" yaml:"key_columns"`
+}
+
+type RowFilterConfig struct {
+	Name       string   `mapstructure:"name" yaml:"name"`
+	Tables     []string `mapstructure:"tables" yaml:"tables"`
+	Expression string   `mapstructure:"expression" yaml:"expression"`
+	Exclude    bool     `mapstructure:"exclude" yaml:"exclude"`
 }
 
 type TransformationsConfig struct {
-	InferFromSecurityLabels bool                      `mapstructure:"infer_from_security_labels" yaml:"infer_from_security_labels"`
-	DumpInferredRules       bool                      `mapstructure:"dump_inferred_rules" yaml:"dump_inferred_rules"`
-	TransformerRules        []TableTransformersConfig `mapstructure:"table_transformers" yaml:"table_transformers"`
-	ValidationMode          string                    `mapstructure:"validation_mode" yaml:"validation_mode"`
+	InferFromSecurityLabels bool                              `mapstructure:"infer_from_security_labels" yaml:"infer_from_security_labels"`
+	DumpInferredRules       bool                              `mapstructure:"dump_inferred_rules" yaml:"dump_inferred_rules"`
+	TransformerRules        []TableTransformersConfig         `mapstructure:"table_transformers" yaml:"table_transformers"`
+	NamedTransformers       map[string]NamedTransformerConfig `mapstructure:"named_transformers" yaml:"named_transformers"`
+	ValidationMode          string                            `mapstructure:"validation_mode" yaml:"validation_mode"`
 }
 type TableTransformersConfig struct {
 	Schema         string                              `mapstructure:"schema" yaml:"schema"`
@@ -272,11 +809,33 @@ type TableTransformersConfig struct {
 }
 
 type ColumnTransformersConfig struct {
+	Name              string             `mapstructure:"name" yaml:"name"`
+	Parameters        map[string]any     `mapstructure:"parameters" yaml:"parameters"`
+	DynamicParameters map[string]any     `mapstructure:"dynamic_parameters" yaml:"dynamic_parameters"`
+	Condition         *ConditionConfig   `mapstructure:"condition" yaml:"condition"`
+	NamedTransformer  string             `mapstructure:"named_transformer" yaml:"named_transformer"`
+	Array             bool               `mapstructure:"array" yaml:"array"`
+	Chain             []ChainStageConfig `mapstructure:"chain" yaml:"chain"`
+}
+
+type ChainStageConfig struct {
+	Name              string         `mapstructure:"name" yaml:"name"`
+	Parameters        map[string]any `mapstructure:"parameters" yaml:"parameters"`
+	DynamicParameters map[string]any `mapstructure:"dynamic_parameters" yaml:"dynamic_parameters"`
+	NamedTransformer  string         `mapstructure:"named_transformer" yaml:"named_transformer"`
+}
+
+type NamedTransformerConfig struct {
 	Name              string         `mapstructure:"name" yaml:"name"`
 	Parameters        map[string]any `mapstructure:"parameters" yaml:"parameters"`
 	DynamicParameters map[string]any `mapstructure:"dynamic_parameters" yaml:"dynamic_parameters"`
 }
 
+type ConditionConfig struct {
+	Expression      string `mapstructure:"expression" yaml:"expression"`
+	OnMissingColumn string `mapstructure:"on_missing_column" yaml:"on_missing_column"`
+}
+
 // postgres source modes
 const (
 	replicationMode            = "replication"
@@ -329,17 +888,66 @@ var (
 	errUnsupportedRolesSnapshotMode            = errors.New("unsupported roles snapshot mode, must be one of 'enabled', 'disabled', or 'no_passwords'")
 	errInvalidPgdumpPgrestoreConfig            = errors.New("pgdump_pgrestore snapshot mode requires target postgres config")
 	errInvalidInjectorConfig                   = errors.New("injector config can't infer schemalog url from source postgres url, schemalog_url must be provided")
+	errInvalidTypeEvolutionConfig              = errors.New("type evolution config can't infer schemalog url from source postgres url, schemalog_url must be provided")
+	errInvalidCheckConstraintsConfig           = errors.New("schema validation check constraints require a source postgres url")
 	errInvalidSnapshotRecorderConfig           = errors.New("snapshot recorder config requires a postgres url")
 	errInvalidSampleRatio                      = errors.New("trace sample ratio must be a value between 0.0 and 1.0")
 	errSchemaSnapshotNotConfigured             = errors.New("schema snapshot config must be provided when snapshot mode is 'full' or 'schema'")
+	errInvalidTableSchemaPair                  = errors.New("invalid table JSON schema, must be in the format table=location")
+	errInvalidTableSortKeyPair                 = errors.New("invalid table sort key, must be in the format table=column")
+	errInvalidTableTopicPair                   = errors.New("invalid table topic, must be in the format schema.table=topic")
+	errInvalidTablePriorityPair                = errors.New("invalid table priority, must be in the format schema.table=priority")
+	errInvalidMetricsDimensionPair             = errors.New("invalid metrics dimension, must be in the format name=value")
+	errInvalidSessionParameterPair             = errors.New("invalid session parameter, must be in the format name=value")
+	errInvalidTableTargetPair                  = errors.New("invalid target table, must be in the format schema.table=target_table")
+	errInvalidAzureEventHubsConfig             = errors.New("kafka servers and tls cannot be set when azure event hubs is configured, they are derived from the connection string")
 )
 
+func (c *ShutdownConfig) toWALShutdownConfig() *wal.ShutdownConfig {
+	return &wal.ShutdownConfig{
+		ShutdownTimeout: time.Duration(c.Timeout) * time.Second,
+	}
+}
+
+func (c *HealthConfig) toHealthConfig() *health.Config {
+	if c == nil {
+		return nil
+	}
+	return &health.Config{
+		ListenAddress:          c.ListenAddress,
+		MaxReplicationLagBytes: c.MaxReplicationLagBytes,
+	}
+}
+
+func (c *DebugConfig) toDebugConfig() *debug.Config {
+	if c == nil {
+		return nil
+	}
+	return &debug.Config{
+		ListenAddress: c.ListenAddress,
+	}
+}
+
 func (c *InstrumentationConfig) toOtelConfig() (*otel.Config, error) {
 	cfg := &otel.Config{}
 	if c.Metrics != nil {
 		cfg.Metrics = &otel.MetricsConfig{
-			Endpoint:           c.Metrics.Endpoint,
-			CollectionInterval: time.Duration(c.Metrics.CollectionInterval) * time.Second,
+			Endpoint:            c.Metrics.Endpoint,
+			CollectionInterval:  time.Duration(c.Metrics.CollectionInterval) * time.Second,
+			MaxTableCardinality: c.Metrics.MaxTableCardinality,
+		}
+		if c.Metrics.CloudWatch != nil {
+			cfg.Metrics.CloudWatch = &cloudwatch.Config{
+				Namespace:      c.Metrics.CloudWatch.Namespace,
+				Region:         c.Metrics.CloudWatch.Region,
+				HighResolution: c.Metrics.CloudWatch.HighResolution,
+				Dimensions:     c.Metrics.CloudWatch.Dimensions,
+			}
+		}
+		if c.Metrics.Prometheus != nil {
+			cfg.Metrics.Prometheus = &prometheus.Config{
+				ListenAddress: c.Metrics.Prometheus.ListenAddress,
+			}
 		}
 	}
 
@@ -357,6 +965,10 @@ func (c *InstrumentationConfig) toOtelConfig() (*otel.Config, error) {
 }
 
 func (c *YAMLConfig) toStreamConfig() (*stream.Config, error) {
+	if err := c.applyPostgresAuthConfig(); err != nil {
+		return nil, fmt.Errorf("parsing postgres auth config: %w", err)
+	}
+
 	listener, err := c.parseListenerConfig()
 	if err != nil {
 		return nil, fmt.Errorf("parsing source config: %w", err)
@@ -369,9 +981,54 @@ func (c *YAMLConfig) toStreamConfig() (*stream.Config, error) {
 	return &stream.Config{
 		Listener:  listener,
 		Processor: processor,
+		Replay:    c.parseReplayConfig(),
 	}, nil
 }
 
+func (c *PipelinesConfig) toPipelinesConfig() ([]stream.PipelineConfig, error) {
+	pipelines := make([]stream.PipelineConfig, 0, len(c.Pipelines))
+	for _, p := range c.Pipelines {
+		streamConfig, err := p.toStreamConfig()
+		if err != nil {
+			return nil, fmt.Errorf("parsing pipeline %s config: %w", p.Name, err)
+		}
+		pipelines = append(pipelines, stream.PipelineConfig{
+			Name:                 p.Name,
+			Stream:               *streamConfig,
+			RestartPolicy:        p.RestartPolicy.parseBackoffConfig(),
+			RestartResetInterval: time.Duration(p.RestartResetInterval) * time.Millisecond,
+		})
+	}
+	return pipelines, nil
+}
+
+// applyPostgresAuthConfig sets the configured auth mode on the source and
+// target postgres URLs, so that it's used by all the connections built from
+// them.
+func (c *YAMLConfig) applyPostgresAuthConfig() error {
+	var err error
+	if c.Source.Postgres != nil && c.Source.Postgres.URL != "" {
+		c.Source.Postgres.URL, err = c.Source.Postgres.Auth.applyTo(c.Source.Postgres.URL)
+		if err != nil {
+			return fmt.Errorf("source: %w", err)
+		}
+	}
+	if c.Target.Postgres != nil && c.Target.Postgres.URL != "" {
+		c.Target.Postgres.URL, err = c.Target.Postgres.Auth.applyTo(c.Target.Postgres.URL)
+		if err != nil {
+			return fmt.Errorf("target: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *PostgresAuthConfig) applyTo(url string) (string, error) {
+	if c == nil {
+		return url, nil
+	}
+	return pglib.WithAuthMode(url, pglib.AuthMode(c.Mode), c.AWSRegion)
+}
+
 func (c *YAMLConfig) parseListenerConfig() (stream.ListenerConfig, error) {
 	pgCfg, err := c.parsePostgresListenerConfig()
 	if err != nil {
@@ -379,31 +1036,81 @@ func (c *YAMLConfig) parseListenerConfig() (stream.ListenerConfig, error) {
 	}
 
 	return stream.ListenerConfig{
-		Kafka:    c.Source.Kafka.parseKafkaListenerConfig(),
-		Postgres: pgCfg,
+		Kafka:      c.Source.Kafka.parseKafkaListenerConfig(),
+		Postgres:   pgCfg,
+		Checkpoint: c.Source.Checkpoint.parseCheckpointConfig(),
 	}, nil
 }
 
 func (c *YAMLConfig) parseProcessorConfig() (stream.ProcessorConfig, error) {
-	streamCfg := stream.ProcessorConfig{
-		Kafka:    c.parseKafkaProcessorConfig(),
-		Postgres: c.parsePostgresProcessorConfig(),
-		Webhook:  c.parseWebhookProcessorConfig(),
-		Filter:   c.parseFilterConfig(),
+	streamCfg, err := c.parseTargetProcessorConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
+	streamCfg.Filter = c.parseFilterConfig()
+	streamCfg.Converter = c.parseConverterConfig()
+	streamCfg.TOASTCache = c.parseTOASTCacheConfig()
+	streamCfg.BeforeAfter = c.parseBeforeAfterConfig()
+	streamCfg.MigrationSafeMode = c.parseMigrationSafeModeConfig()
+	streamCfg.PGAudit = c.parsePGAuditConfig()
+	streamCfg.SortKey = c.parseSortKeyConfig()
+	streamCfg.BulkUpdateCoalescer = c.parseBulkUpdateCoalescerConfig()
+	streamCfg.XIDSequencer = c.parseXIDSequencerConfig()
+	streamCfg.SinkHealthCheck = c.parseSinkHealthCheckConfig()
+	streamCfg.TableSizeThrottle = c.parseTableSizeThrottleConfig()
+
+	streamCfg.Validator, err = c.parseValidatorConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
 	}
 
-	var err error
 	streamCfg.Injector, err = c.parseInjectorConfig()
 	if err != nil {
 		return stream.ProcessorConfig{}, err
 	}
 
+	streamCfg.Transformer, err = c.parseTransformationConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
+
+	streamCfg.TypeEvolution, err = c.parseTypeEvolutionConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
+
+	return streamCfg, nil
+}
+
+// parseTargetProcessorConfig returns the processor configuration with only the
+// targets set.
+func (c *YAMLConfig) parseTargetProcessorConfig() (stream.ProcessorConfig, error) {
+	streamCfg := stream.ProcessorConfig{
+		Postgres:    c.parsePostgresProcessorConfig(),
+		Webhook:     c.parseWebhookProcessorConfig(),
+		FileLog:     c.parseFileLogProcessorConfig(),
+		PubSub:      c.parsePubSubProcessorConfig(),
+		AMQP:        c.parseAMQPProcessorConfig(),
+		AuditLog:    c.parseAuditLogProcessorConfig(),
+		ObjectStore: c.parseObjectStoreProcessorConfig(),
+		ClickHouse:  c.parseClickHouseProcessorConfig(),
+		BigQuery:    c.parseBigQueryProcessorConfig(),
+		Snowflake:   c.parseSnowflakeProcessorConfig(),
+		DynamoDB:    c.parseDynamoDBProcessorConfig(),
+	}
+
+	var err error
 	streamCfg.Search, err = c.parseSearchProcessorConfig()
 	if err != nil {
 		return stream.ProcessorConfig{}, err
 	}
 
-	streamCfg.Transformer, err = c.parseTransformationConfig()
+	streamCfg.Kafka, err = c.parseKafkaProcessorConfig()
+	if err != nil {
+		return stream.ProcessorConfig{}, err
+	}
+
+	streamCfg.Router, err = c.parseRouterProcessorConfig()
 	if err != nil {
 		return stream.ProcessorConfig{}, err
 	}
@@ -411,6 +1118,36 @@ func (c *YAMLConfig) parseProcessorConfig() (stream.ProcessorConfig, error) {
 	return streamCfg, nil
 }
 
+func (c *YAMLConfig) parseRouterProcessorConfig() (*stream.RouterProcessorConfig, error) {
+	if c.Target.Router == nil {
+		return nil, nil
+	}
+
+	cfg := &stream.RouterProcessorConfig{
+		Routes: make([]stream.RouteConfig, 0, len(c.Target.Router.Routes)),
+	}
+	for i, route := range c.Target.Router.Routes {
+		// the route targets are parsed with the rest of the configuration,
+		// since some targets rely on the source settings
+		routeYAMLConfig := *c
+		routeYAMLConfig.Target = route.Target
+		target, err := routeYAMLConfig.parseTargetProcessorConfig()
+		if err != nil {
+			return nil, fmt.Errorf("route %d: %w", i, err)
+		}
+		cfg.Routes = append(cfg.Routes, stream.RouteConfig{
+			Route: router.Route{
+				Name:          route.Name,
+				Tables:        route.Tables,
+				Actions:       toWALActions(route.Actions),
+				FailurePolicy: router.FailurePolicy(route.FailurePolicy),
+			},
+			Target: target,
+		})
+	}
+	return cfg, nil
+}
+
 func (c *YAMLConfig) parsePostgresListenerConfig() (*stream.PostgresListenerConfig, error) {
 	if c.Source.Postgres == nil {
 		return nil, nil
@@ -423,18 +1160,34 @@ func (c *YAMLConfig) parsePostgresListenerConfig() (*stream.PostgresListenerConf
 	}
 
 	streamCfg := &stream.PostgresListenerConfig{
-		URL:         c.Source.Postgres.URL,
-		RetryPolicy: c.Source.Postgres.RetryPolicy.parseBackoffConfig(),
+		URL:             c.Source.Postgres.URL,
+		RetryPolicy:     c.Source.Postgres.RetryPolicy.parseBackoffConfig(),
+		ReconnectPolicy: c.Source.Postgres.ReconnectPolicy.parseBackoffConfig(),
 	}
 
 	if c.Source.Postgres.Mode == replicationMode || c.Source.Postgres.Mode == snapshotAndReplicationMode {
 		replicationSlotName := ""
+		var publication *pgreplication.PublicationConfig
+		var slotConfig pgreplication.SlotConfig
+		var pluginConfig pgreplication.PluginConfig
 		if c.Source.Postgres.Replication != nil {
 			replicationSlotName = c.Source.Postgres.Replication.ReplicationSlot
+			slotConfig = c.Source.Postgres.Replication.SlotOptions.parseSlotConfig()
+			pluginConfig = c.Source.Postgres.Replication.Plugin.parsePluginConfig()
+			streamCfg.SlotMonitor = c.Source.Postgres.Replication.SlotMonitor.parseSlotMonitorConfig()
+			streamCfg.Heartbeat = c.Source.Postgres.Replication.Heartbeat.parseHeartbeatConfig()
+			publication = c.Source.Postgres.Replication.Publication.parsePublicationConfig()
+			streamCfg.StandbyStatusInterval = time.Duration(c.Source.Postgres.Replication.StandbyStatusInterval) * time.Second
+			streamCfg.CheckpointTable = c.Source.Postgres.Replication.CheckpointTable.parseCheckpointTableConfig()
+			streamCfg.CheckpointFile = c.Source.Postgres.Replication.CheckpointFile.parseCheckpointFileConfig()
+			streamCfg.CheckpointRedis = c.Source.Postgres.Replication.CheckpointRedis.parseCheckpointRedisConfig()
 		}
 		streamCfg.Replication = pgreplication.Config{
 			PostgresURL:         c.Source.Postgres.URL,
 			ReplicationSlotName: replicationSlotName,
+			Slot:                slotConfig,
+			Plugin:              pluginConfig,
+			Publication:         publication,
 		}
 	}
 
@@ -517,6 +1270,9 @@ func (c *YAMLConfig) parseDataSnapshotConfig() *pgsnapshotgenerator.Config {
 		streamCfg.SchemaWorkers = uint(snapshotCfg.Data.SchemaWorkers)
 		streamCfg.TableWorkers = uint(snapshotCfg.Data.TableWorkers)
 		streamCfg.MaxConnections = snapshotCfg.Data.MaxConnections
+		streamCfg.RLSRole = snapshotCfg.Data.RLSRole
+		streamCfg.SerializableSnapshot = snapshotCfg.Data.SerializableSnapshot
+		streamCfg.MaxSerializationRetries = snapshotCfg.Data.MaxSerializationRetries
 	}
 
 	return streamCfg
@@ -589,25 +1345,82 @@ func (c *YAMLConfig) parseInjectorConfig() (*injector.Config, error) {
 	}, nil
 }
 
-func (c *YAMLConfig) parseKafkaProcessorConfig() *stream.KafkaProcessorConfig {
+func (c *YAMLConfig) parseTypeEvolutionConfig() (*typeevolution.Config, error) {
+	if c.Modifiers.TypeEvolution == nil || !c.Modifiers.TypeEvolution.Enabled {
+		return nil, nil
+	}
+
+	url := c.Modifiers.TypeEvolution.SchemalogURL
+	if url == "" {
+		if c.Source.Postgres == nil || c.Source.Postgres.URL == "" {
+			return nil, errInvalidTypeEvolutionConfig
+		}
+		url = c.Source.Postgres.URL
+	}
+
+	return &typeevolution.Config{
+		Store: pgschemalog.Config{
+			URL: url,
+		},
+	}, nil
+}
+
+func (c *YAMLConfig) parseKafkaProcessorConfig() (*stream.KafkaProcessorConfig, error) {
 	if c.Target.Kafka == nil {
-		return nil
+		return nil, nil
+	}
+
+	connCfg := kafka.ConnConfig{
+		Servers: c.Target.Kafka.Servers,
+		Topic: kafka.TopicConfig{
+			Name:              c.Target.Kafka.Topic.Name,
+			NumPartitions:     c.Target.Kafka.Topic.Partitions,
+			ReplicationFactor: c.Target.Kafka.Topic.ReplicationFactor,
+			AutoCreate:        c.Target.Kafka.Topic.AutoCreate,
+		},
+		TLS: c.Target.Kafka.TLS.parseTLSConfig(),
+	}
+
+	if c.Target.Kafka.AzureEventHubs != nil {
+		if len(c.Target.Kafka.Servers) > 0 || c.Target.Kafka.TLS != nil {
+			return nil, errInvalidAzureEventHubsConfig
+		}
+		// the topic name is used as event hub when not explicitly set, and
+		// the topic settings are kept
+		eventHub := c.Target.Kafka.AzureEventHubs.EventHub
+		if eventHub == "" {
+			eventHub = c.Target.Kafka.Topic.Name
+		}
+		azureCfg := &kafka.AzureEventHubsConfig{
+			ConnectionString: c.Target.Kafka.AzureEventHubs.ConnectionString,
+			EventHub:         eventHub,
+		}
+		azureConnCfg, err := azureCfg.ConnConfig()
+		if err != nil {
+			return nil, err
+		}
+		connCfg.Servers = azureConnCfg.Servers
+		connCfg.Topic.Name = azureConnCfg.Topic.Name
+		connCfg.TLS = azureConnCfg.TLS
+		connCfg.SASL = azureConnCfg.SASL
 	}
 
 	return &stream.KafkaProcessorConfig{
 		Writer: &kafkaprocessor.Config{
-			Kafka: kafka.ConnConfig{
-				Servers: c.Target.Kafka.Servers,
-				Topic: kafka.TopicConfig{
-					Name:              c.Target.Kafka.Topic.Name,
-					NumPartitions:     c.Target.Kafka.Topic.Partitions,
-					ReplicationFactor: c.Target.Kafka.Topic.ReplicationFactor,
-					AutoCreate:        c.Target.Kafka.Topic.AutoCreate,
-				},
-				TLS: c.Target.Kafka.TLS.parseTLSConfig(),
-			},
+			Kafka: connCfg,
 			Batch: c.Target.Kafka.Batch.parseBatchConfig(),
 		},
+		Partitioning: c.Target.Kafka.Partitioning.parsePartitioningConfig(),
+	}, nil
+}
+
+func (c *KafkaPartitioningConfig) parsePartitioningConfig() *partition.Config {
+	if c == nil {
+		return nil
+	}
+	return &partition.Config{
+		Strategy:     partition.Strategy(c.Strategy),
+		TenantColumn: c.TenantColumn,
 	}
 }
 
@@ -630,6 +1443,13 @@ func (c *YAMLConfig) parsePostgresProcessorConfig() *stream.PostgresProcessorCon
 		},
 	}
 
+	if c.Target.Postgres.ConflictResolution != nil {
+		cfg.BatchWriter.ConflictResolution = postgres.ConflictResolutionConfig{
+			Strategy:  c.Target.Postgres.ConflictResolution.Strategy,
+			LSNColumn: c.Target.Postgres.ConflictResolution.LSNColumn,
+		}
+	}
+
 	if c.Target.Postgres.BulkIngest != nil {
 		cfg.BatchWriter.BulkIngestEnabled = c.Target.Postgres.BulkIngest.Enabled
 		if cfg.BatchWriter.BulkIngestEnabled {
@@ -637,6 +1457,23 @@ func (c *YAMLConfig) parsePostgresProcessorConfig() *stream.PostgresProcessorCon
 		}
 	}
 
+	if c.Target.Postgres.SCDType2 != nil && c.Target.Postgres.SCDType2.Enabled {
+		cfg.SCDType2 = &scd.Config{
+			URL:                cfg.BatchWriter.URL,
+			ValidFromColumn:    c.Target.Postgres.SCDType2.ValidFromColumn,
+			ValidToColumn:      c.Target.Postgres.SCDType2.ValidToColumn,
+			MaxConflictRetries: c.Target.Postgres.SCDType2.MaxConflictRetries,
+			Batch:              cfg.BatchWriter.BatchConfig,
+		}
+	}
+
+	if c.Target.Postgres.FKOrdering != nil && c.Target.Postgres.FKOrdering.Enabled {
+		cfg.FKOrdering = &fkorder.Config{
+			URL:               c.Target.Postgres.FKOrdering.URL,
+			MaxBufferedEvents: c.Target.Postgres.FKOrdering.MaxBufferedEvents,
+		}
+	}
+
 	return cfg
 }
 
@@ -667,6 +1504,263 @@ func (c *YAMLConfig) parseSearchProcessorConfig() (*stream.SearchProcessorConfig
 	}, nil
 }
 
+func (c *YAMLConfig) parseFileLogProcessorConfig() *filelog.Config {
+	if c.Target.FileLog == nil {
+		return nil
+	}
+
+	return &filelog.Config{
+		Path:             c.Target.FileLog.Path,
+		RotationInterval: time.Duration(c.Target.FileLog.RotationInterval) * time.Second,
+		MaxFileBytes:     c.Target.FileLog.MaxFileBytes,
+		MaxRotations:     c.Target.FileLog.MaxRotations,
+		TailBufferSize:   c.Target.FileLog.TailBufferSize,
+		Batch:            c.Target.FileLog.Batch.parseBatchConfig(),
+	}
+}
+
+func (c *YAMLConfig) parsePubSubProcessorConfig() *pubsub.Config {
+	if c.Target.PubSub == nil {
+		return nil
+	}
+
+	var tableTopics map[string]string
+	if len(c.Target.PubSub.TableTopics) > 0 {
+		tableTopics = make(map[string]string, len(c.Target.PubSub.TableTopics))
+		for _, t := range c.Target.PubSub.TableTopics {
+			tableTopics[t.Table] = t.Topic
+		}
+	}
+
+	return &pubsub.Config{
+		ProjectID:              c.Target.PubSub.ProjectID,
+		DefaultTopic:           c.Target.PubSub.DefaultTopic,
+		TableTopics:            tableTopics,
+		Endpoint:               c.Target.PubSub.Endpoint,
+		EmulatorHost:           c.Target.PubSub.EmulatorHost,
+		CredentialsFile:        c.Target.PubSub.CredentialsFile,
+		MaxOutstandingMessages: c.Target.PubSub.MaxOutstandingMessages,
+		RetryPolicy:            c.Target.PubSub.RetryPolicy.parseBackoffConfig(),
+		Batch:                  c.Target.PubSub.Batch.parseBatchConfig(),
+	}
+}
+
+func (c *YAMLConfig) parseAuditLogProcessorConfig() *auditlog.Config {
+	if c.Target.AuditLog == nil {
+		return nil
+	}
+
+	cfg := &auditlog.Config{
+		IncludeValues:   c.Target.AuditLog.IncludeValues,
+		RedactedColumns: c.Target.AuditLog.RedactedColumns,
+		Batch:           c.Target.AuditLog.Batch.parseBatchConfig(),
+	}
+	if c.Target.AuditLog.File != nil {
+		cfg.File = &auditlog.FileConfig{
+			Path:             c.Target.AuditLog.File.Path,
+			RotationInterval: time.Duration(c.Target.AuditLog.File.RotationInterval) * time.Second,
+			MaxFileBytes:     c.Target.AuditLog.File.MaxFileBytes,
+			MaxRotations:     c.Target.AuditLog.File.MaxRotations,
+		}
+	}
+	if c.Target.AuditLog.Postgres != nil {
+		cfg.Postgres = &auditlog.PostgresConfig{
+			URL:       c.Target.AuditLog.Postgres.URL,
+			Table:     c.Target.AuditLog.Postgres.Table,
+			Retention: time.Duration(c.Target.AuditLog.Postgres.Retention) * time.Second,
+		}
+	}
+	return cfg
+}
+
+func (c *YAMLConfig) parseObjectStoreProcessorConfig() *objectstore.Config {
+	if c.Target.ObjectStore == nil {
+		return nil
+	}
+
+	return c.Target.ObjectStore.parseObjectStoreConfig()
+}
+
+func (c *ObjectStoreTargetConfig) parseObjectStoreConfig() *objectstore.Config {
+	return &objectstore.Config{
+		Provider:        objectstore.Provider(c.Provider),
+		Bucket:          c.Bucket,
+		Prefix:          c.Prefix,
+		Format:          objectstore.Format(c.Format),
+		Endpoint:        c.Endpoint,
+		Region:          c.Region,
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		CredentialsFile: c.CredentialsFile,
+		AccountName:     c.AccountName,
+		AccountKey:      c.AccountKey,
+		PartSize:        c.PartSize,
+		Batch:           c.Batch.parseBatchConfig(),
+	}
+}
+
+// parseReplayConfig returns the configuration of the replay command, or nil
+// if no replay is configured.
+func (c *YAMLConfig) parseReplayConfig() *stream.ReplayConfig {
+	if c.Replay == nil {
+		return nil
+	}
+
+	replayConfig := &stream.ReplayConfig{
+		StartLSN: c.Replay.StartLSN,
+		EndLSN:   c.Replay.EndLSN,
+	}
+	if c.Replay.Archive.FileLog != nil {
+		replayConfig.FileLogPath = c.Replay.Archive.FileLog.Path
+	}
+	if c.Replay.Archive.ObjectStore != nil {
+		replayConfig.ObjectStore = c.Replay.Archive.ObjectStore.parseObjectStoreConfig()
+	}
+	return replayConfig
+}
+
+func (c *YAMLConfig) parseClickHouseProcessorConfig() *clickhouse.Config {
+	if c.Target.ClickHouse == nil {
+		return nil
+	}
+
+	var tableModes map[string]clickhouse.Mode
+	if len(c.Target.ClickHouse.TableModes) > 0 {
+		tableModes = make(map[string]clickhouse.Mode, len(c.Target.ClickHouse.TableModes))
+		for table, mode := range c.Target.ClickHouse.TableModes {
+			tableModes[table] = clickhouse.Mode(mode)
+		}
+	}
+
+	return &clickhouse.Config{
+		URL:            c.Target.ClickHouse.URL,
+		User:           c.Target.ClickHouse.User,
+		Password:       c.Target.ClickHouse.Password,
+		Database:       c.Target.ClickHouse.Database,
+		Mode:           clickhouse.Mode(c.Target.ClickHouse.Mode),
+		TableModes:     tableModes,
+		DeadLetterFile: c.Target.ClickHouse.DeadLetterFile,
+		RetryPolicy:    c.Target.ClickHouse.RetryPolicy.parseBackoffConfig(),
+		Batch:          c.Target.ClickHouse.Batch.parseBatchConfig(),
+	}
+}
+
+func (c *YAMLConfig) parseBigQueryProcessorConfig() *bigquery.Config {
+	if c.Target.BigQuery == nil {
+		return nil
+	}
+
+	return &bigquery.Config{
+		ProjectID:       c.Target.BigQuery.ProjectID,
+		CredentialsFile: c.Target.BigQuery.CredentialsFile,
+		Dataset:         c.Target.BigQuery.Dataset,
+		Table:           c.Target.BigQuery.Table,
+		Location:        c.Target.BigQuery.Location,
+		Mode:            bigquery.Mode(c.Target.BigQuery.Mode),
+		MaxStaleness:    time.Duration(c.Target.BigQuery.MaxStaleness) * time.Second,
+		RetryPolicy:     c.Target.BigQuery.RetryPolicy.parseBackoffConfig(),
+		Batch:           c.Target.BigQuery.Batch.parseBatchConfig(),
+	}
+}
+
+func (c *YAMLConfig) parseSnowflakeProcessorConfig() *snowflake.Config {
+	if c.Target.Snowflake == nil {
+		return nil
+	}
+
+	// the keys of the yaml maps are lower cased, and the session parameters
+	// are quoted by the sink, so they're restored to the upper case names
+	// used by Snowflake.
+	var sessionParameters map[string]string
+	if len(c.Target.Snowflake.SessionParameters) > 0 {
+		sessionParameters = make(map[string]string, len(c.Target.Snowflake.SessionParameters))
+		for name, value := range c.Target.Snowflake.SessionParameters {
+			sessionParameters[strings.ToUpper(name)] = value
+		}
+	}
+
+	return &snowflake.Config{
+		DSN:                 c.Target.Snowflake.URL,
+		Warehouse:           c.Target.Snowflake.Warehouse,
+		WarehouseAutoResume: c.Target.Snowflake.WarehouseAutoResume,
+		Role:                c.Target.Snowflake.Role,
+		SessionParameters:   sessionParameters,
+		Stage:               c.Target.Snowflake.Stage,
+		Schema:              c.Target.Snowflake.Schema,
+		Table:               c.Target.Snowflake.Table,
+		Mode:                snowflake.Mode(c.Target.Snowflake.Mode),
+		PrivateKeyFile:      c.Target.Snowflake.PrivateKeyFile,
+		Streaming:           c.Target.Snowflake.Streaming.parseStreamingConfig(),
+		RetryPolicy:         c.Target.Snowflake.RetryPolicy.parseBackoffConfig(),
+		Batch:               c.Target.Snowflake.Batch.parseBatchConfig(),
+	}
+}
+
+func (c *SnowflakeStreamingConfig) parseStreamingConfig() snowflake.StreamingConfig {
+	if c == nil {
+		return snowflake.StreamingConfig{}
+	}
+
+	return snowflake.StreamingConfig{
+		Account:       c.Account,
+		User:          c.User,
+		Database:      c.Database,
+		Pipe:          c.Pipe,
+		Channel:       c.Channel,
+		CommitTimeout: time.Duration(c.CommitTimeout) * time.Millisecond,
+		Endpoint:      c.Endpoint,
+	}
+}
+
+func (c *YAMLConfig) parseDynamoDBProcessorConfig() *dynamodb.Config {
+	if c.Target.DynamoDB == nil {
+		return nil
+	}
+
+	var tables map[string]string
+	if len(c.Target.DynamoDB.Tables) > 0 {
+		tables = make(map[string]string, len(c.Target.DynamoDB.Tables))
+		for _, t := range c.Target.DynamoDB.Tables {
+			tables[t.Table] = t.TargetTable
+		}
+	}
+
+	return &dynamodb.Config{
+		Region:           c.Target.DynamoDB.Region,
+		Endpoint:         c.Target.DynamoDB.Endpoint,
+		Tables:           tables,
+		Table:            c.Target.DynamoDB.Table,
+		VersionAttribute: c.Target.DynamoDB.VersionAttribute,
+		Batch:            c.Target.DynamoDB.Batch.parseBatchConfig(),
+	}
+}
+
+func (c *YAMLConfig) parseAMQPProcessorConfig() *amqp.Config {
+	if c.Target.AMQP == nil {
+		return nil
+	}
+
+	var tablePriorities map[string]int
+	if len(c.Target.AMQP.TablePriorities) > 0 {
+		tablePriorities = make(map[string]int, len(c.Target.AMQP.TablePriorities))
+		for _, t := range c.Target.AMQP.TablePriorities {
+			tablePriorities[t.Table] = t.Priority
+		}
+	}
+
+	return &amqp.Config{
+		URL:             c.Target.AMQP.URL,
+		Exchange:        c.Target.AMQP.Exchange,
+		MessageTTL:      time.Duration(c.Target.AMQP.MessageTTL) * time.Millisecond,
+		Priority:        c.Target.AMQP.Priority,
+		TablePriorities: tablePriorities,
+		ConfirmTimeout:  time.Duration(c.Target.AMQP.ConfirmTimeout) * time.Millisecond,
+		TLS:             c.Target.AMQP.TLS.parseTLSConfig(),
+		RetryPolicy:     c.Target.AMQP.RetryPolicy.parseBackoffConfig(),
+		Batch:           c.Target.AMQP.Batch.parseBatchConfig(),
+	}
+}
+
 func (c *YAMLConfig) parseWebhookProcessorConfig() *stream.WebhookProcessorConfig {
 	if c.Target.Webhooks == nil {
 		return nil
@@ -707,12 +1801,207 @@ func (c YAMLConfig) parseFilterConfig() *filter.Config {
 	if c.Modifiers.Filter == nil {
 		return nil
 	}
+	var rowFilters []filter.RowFilter
+	for _, rf := range c.Modifiers.Filter.RowFilters {
+		rowFilters = append(rowFilters, filter.RowFilter{
+			Name:       rf.Name,
+			Tables:     rf.Tables,
+			Expression: rf.Expression,
+			Exclude:    rf.Exclude,
+		})
+	}
+	var tableActions []filter.TableActionFilter
+	for _, ta := range c.Modifiers.Filter.TableActions {
+		tableActions = append(tableActions, filter.TableActionFilter{
+			Tables: ta.Tables,
+			Actions: filter.ActionFilter{
+				Allow: toWALActions(ta.Allow),
+				Deny:  toWALActions(ta.Deny),
+			},
+		})
+	}
+	var changedColumns []filter.ChangedColumnsFilter
+	for _, cc := range c.Modifiers.Filter.ChangedColumns {
+		changedColumns = append(changedColumns, filter.ChangedColumnsFilter{
+			Tables:  cc.Tables,
+			Columns: cc.Columns,
+		})
+	}
+	var sampling []filter.SamplingFilter
+	for _, sc := range c.Modifiers.Filter.Sampling {
+		sampling = append(sampling, filter.SamplingFilter{
+			Tables:     sc.Tables,
+			Percentage: sc.Percentage,
+			Mode:       filter.SamplingMode(sc.Mode),
+			KeyColumns: sc.KeyColumns,
+		})
+	}
 	return &filter.Config{
-		ExcludeTables: c.Modifiers.Filter.ExcludeTables,
-		IncludeTables: c.Modifiers.Filter.IncludeTables,
+		ExcludeTables:        c.Modifiers.Filter.ExcludeTables,
+		IncludeTables:        c.Modifiers.Filter.IncludeTables,
+		Actions:              c.Modifiers.Filter.Actions.parseActionFilterConfig(),
+		TableActions:         tableActions,
+		ChangedColumns:       changedColumns,
+		Sampling:             sampling,
+		RowFilters:           rowFilters,
+		RowFilterErrorPolicy: filter.RowFilterErrorPolicy(c.Modifiers.Filter.RowFilterErrorPolicy),
+	}
+}
+
+func (c *ActionFilterConfig) parseActionFilterConfig() *filter.ActionFilter {
+	if c == nil {
+		return nil
+	}
+	return &filter.ActionFilter{
+		Allow: toWALActions(c.Allow),
+		Deny:  toWALActions(c.Deny),
+	}
+}
+
+func (c YAMLConfig) parseTOASTCacheConfig() *toast.Config {
+	if c.Modifiers.TOASTCache == nil || !c.Modifiers.TOASTCache.Enabled {
+		return nil
+	}
+	return &toast.Config{
+		MaxEntries: c.Modifiers.TOASTCache.MaxEntries,
+		MaxBytes:   c.Modifiers.TOASTCache.MaxBytes,
+	}
+}
+
+func (c YAMLConfig) parseBeforeAfterConfig() *transform.Config {
+	if c.Modifiers.BeforeAfter == nil || !c.Modifiers.BeforeAfter.Enabled {
+		return nil
+	}
+	return &transform.Config{
+		MaxEntries: c.Modifiers.BeforeAfter.MaxEntries,
+		MaxBytes:   c.Modifiers.BeforeAfter.MaxBytes,
 	}
 }
 
+func (c YAMLConfig) parsePGAuditConfig() *pgaudit.Config {
+	if c.Modifiers.PGAudit == nil || !c.Modifiers.PGAudit.Enabled {
+		return nil
+	}
+	return &pgaudit.Config{
+		LogFile:      c.Modifiers.PGAudit.LogFile,
+		Table:        c.Modifiers.PGAudit.Table,
+		URL:          c.Modifiers.PGAudit.URL,
+		PollInterval: time.Duration(c.Modifiers.PGAudit.PollInterval) * time.Millisecond,
+		MatchTimeout: time.Duration(c.Modifiers.PGAudit.MatchTimeout) * time.Millisecond,
+		MaxEntries:   c.Modifiers.PGAudit.MaxEntries,
+		FromStart:    c.Modifiers.PGAudit.FromStart,
+	}
+}
+
+func (c YAMLConfig) parseMigrationSafeModeConfig() *migration.Config {
+	if c.Modifiers.MigrationSafeMode == nil || !c.Modifiers.MigrationSafeMode.Enabled {
+		return nil
+	}
+	return &migration.Config{
+		LockName:          c.Modifiers.MigrationSafeMode.LockName,
+		CheckInterval:     time.Duration(c.Modifiers.MigrationSafeMode.CheckInterval) * time.Second,
+		MaxBufferedEvents: c.Modifiers.MigrationSafeMode.MaxBufferedEvents,
+	}
+}
+
+func (c YAMLConfig) parseXIDSequencerConfig() *sequencer.Config {
+	if c.Modifiers.XIDSequencer == nil || !c.Modifiers.XIDSequencer.Enabled {
+		return nil
+	}
+	return &sequencer.Config{
+		MaxBufferedEvents: c.Modifiers.XIDSequencer.MaxBufferedEvents,
+	}
+}
+
+func (c YAMLConfig) parseSinkHealthCheckConfig() *healthcheck.Config {
+	if c.Modifiers.SinkHealthCheck == nil || !c.Modifiers.SinkHealthCheck.Enabled {
+		return nil
+	}
+	return &healthcheck.Config{
+		Interval:       time.Duration(c.Modifiers.SinkHealthCheck.Interval) * time.Second,
+		MaxStartupWait: time.Duration(c.Modifiers.SinkHealthCheck.MaxStartupWait) * time.Second,
+	}
+}
+
+func (c YAMLConfig) parseTableSizeThrottleConfig() *throttle.Config {
+	if c.Modifiers.TableSizeThrottle == nil || !c.Modifiers.TableSizeThrottle.Enabled {
+		return nil
+	}
+	return &throttle.Config{
+		MinTableSize:         c.Modifiers.TableSizeThrottle.MinTableSize,
+		EventsPerSecondPerGB: c.Modifiers.TableSizeThrottle.EventsPerSecondPerGB,
+		RefreshInterval:      time.Duration(c.Modifiers.TableSizeThrottle.RefreshInterval) * time.Second,
+	}
+}
+
+func (c YAMLConfig) parseSortKeyConfig() *redshift.Config {
+	if c.Modifiers.SortKey == nil || len(c.Modifiers.SortKey.Tables) == 0 {
+		return nil
+	}
+	tables := make(map[string]string, len(c.Modifiers.SortKey.Tables))
+	for _, t := range c.Modifiers.SortKey.Tables {
+		tables[t.Table] = t.Column
+	}
+	return &redshift.Config{
+		Tables:            tables,
+		Window:            time.Duration(c.Modifiers.SortKey.Window) * time.Millisecond,
+		MaxBufferedEvents: c.Modifiers.SortKey.MaxBufferedEvents,
+		WarnOutOfOrder:    c.Modifiers.SortKey.WarnOutOfOrder,
+	}
+}
+
+func (c YAMLConfig) parseBulkUpdateCoalescerConfig() *coalesce.Config {
+	if c.Modifiers.BulkUpdateCoalescer == nil || !c.Modifiers.BulkUpdateCoalescer.Enabled {
+		return nil
+	}
+	return &coalesce.Config{
+		Tables:          c.Modifiers.BulkUpdateCoalescer.Tables,
+		Window:          time.Duration(c.Modifiers.BulkUpdateCoalescer.Window) * time.Millisecond,
+		MaxBufferedRows: c.Modifiers.BulkUpdateCoalescer.MaxBufferedRows,
+	}
+}
+
+func (c YAMLConfig) parseValidatorConfig() (*validate.Config, error) {
+	if c.Modifiers.Validation == nil || (len(c.Modifiers.Validation.Tables) == 0 && !c.Modifiers.Validation.CheckConstraints) {
+		return nil, nil
+	}
+	tableSchemas := make(map[string]string, len(c.Modifiers.Validation.Tables))
+	for _, t := range c.Modifiers.Validation.Tables {
+		tableSchemas[t.Table] = t.JSONSchema
+	}
+	cfg := &validate.Config{
+		TableSchemas:   tableSchemas,
+		DeadLetterFile: c.Modifiers.Validation.DeadLetterFile,
+	}
+	if c.Modifiers.Validation.CheckConstraints {
+		if c.Source.Postgres == nil || c.Source.Postgres.URL == "" {
+			return nil, errInvalidCheckConstraintsConfig
+		}
+		cfg.CheckConstraintsStore = &pgschemalog.Config{
+			URL: c.Source.Postgres.URL,
+		}
+	}
+	return cfg, nil
+}
+
+func (c YAMLConfig) parseConverterConfig() *converter.Config {
+	if c.Modifiers.Converter == nil {
+		return nil
+	}
+	cfg := &converter.Config{
+		NormalizeTimestamps: c.Modifiers.Converter.NormalizeTimestamps,
+		GeometricTypes:      c.Modifiers.Converter.GeometricTypes,
+		NetworkAddresses:    c.Modifiers.Converter.NetworkAddresses,
+		FullTextSearchTypes: c.Modifiers.Converter.FullTextSearchTypes,
+	}
+	if c.Modifiers.Converter.Bytea != nil {
+		cfg.Bytea = &converter.ByteaConfig{
+			OutputFormat: c.Modifiers.Converter.Bytea.OutputFormat,
+		}
+	}
+	return cfg
+}
+
 func (c TransformationsConfig) parseTransformationConfig() (*transformer.Config, error) {
 	if c.TransformerRules == nil && !c.InferFromSecurityLabels {
 		// transformation configuration provided, but no rules defined
@@ -734,6 +2023,7 @@ func (c TransformationsConfig) parseTransformationConfig() (*transformer.Config,
 			InferFromSecurityLabels: true,
 			DumpInferredRules:       c.DumpInferredRules,
 			TransformerRules:        nil,
+			NamedTransformers:       c.parseNamedTransformers(),
 			ValidationMode:          globalValidationMode,
 		}, nil
 	}
@@ -767,6 +2057,10 @@ func (c TransformationsConfig) parseTransformationConfig() (*transformer.Config,
 				Name:              cr.Name,
 				Parameters:        cr.Parameters,
 				DynamicParameters: cr.DynamicParameters,
+				Condition:         cr.Condition.toTransformerConditionRules(),
+				NamedTransformer:  cr.NamedTransformer,
+				Array:             cr.Array,
+				Chain:             parseChainStages(cr.Chain),
 			}
 		}
 		rules = append(rules, transformer.TableRules{
@@ -778,11 +2072,53 @@ func (c TransformationsConfig) parseTransformationConfig() (*transformer.Config,
 	}
 
 	return &transformer.Config{
-		TransformerRules: rules,
-		ValidationMode:   globalValidationMode,
+		TransformerRules:  rules,
+		NamedTransformers: c.parseNamedTransformers(),
+		ValidationMode:    globalValidationMode,
 	}, nil
 }
 
+func (c TransformationsConfig) parseNamedTransformers() map[string]transformer.NamedTransformerRules {
+	if len(c.NamedTransformers) == 0 {
+		return nil
+	}
+	namedTransformers := make(map[string]transformer.NamedTransformerRules, len(c.NamedTransformers))
+	for name, nt := range c.NamedTransformers {
+		namedTransformers[name] = transformer.NamedTransformerRules{
+			Name:              nt.Name,
+			Parameters:        nt.Parameters,
+			DynamicParameters: nt.DynamicParameters,
+		}
+	}
+	return namedTransformers
+}
+
+func parseChainStages(stages []ChainStageConfig) []transformer.ChainStageRules {
+	if len(stages) == 0 {
+		return nil
+	}
+	chain := make([]transformer.ChainStageRules, 0, len(stages))
+	for _, stage := range stages {
+		chain = append(chain, transformer.ChainStageRules{
+			Name:              stage.Name,
+			Parameters:        stage.Parameters,
+			DynamicParameters: stage.DynamicParameters,
+			NamedTransformer:  stage.NamedTransformer,
+		})
+	}
+	return chain
+}
+
+func (c *ConditionConfig) toTransformerConditionRules() *transformer.ConditionRules {
+	if c == nil {
+		return nil
+	}
+	return &transformer.ConditionRules{
+		Expression:      c.Expression,
+		OnMissingColumn: c.OnMissingColumn,
+	}
+}
+
 func (c *KafkaConfig) parseKafkaListenerConfig() *stream.KafkaListenerConfig {
 	if c == nil {
 		return nil
@@ -816,6 +2152,119 @@ func (c *KafkaConfig) parseKafkaReaderConfig() kafka.ReaderConfig {
 	}
 }
 
+func (c *SlotMonitorConfig) parseSlotMonitorConfig() *pglistener.SlotMonitorConfig {
+	if c == nil {
+		return nil
+	}
+	return &pglistener.SlotMonitorConfig{
+		CheckInterval:         time.Duration(c.CheckInterval) * time.Second,
+		RetainedWALLimitBytes: c.RetainedWALLimitBytes,
+		PauseSnapshotsOnLimit: c.PauseSnapshotsOnLimit,
+	}
+}
+
+func (c *SlotOptionsConfig) parseSlotConfig() pgreplication.SlotConfig {
+	if c == nil {
+		return pgreplication.SlotConfig{}
+	}
+	return pgreplication.SlotConfig{
+		Temporary:       c.Temporary,
+		TwoPhase:        c.TwoPhase,
+		Failover:        c.Failover,
+		CreateIfMissing: c.CreateIfMissing,
+	}
+}
+
+func (c *PluginConfig) parsePluginConfig() pgreplication.PluginConfig {
+	if c == nil {
+		return pgreplication.PluginConfig{}
+	}
+	return pgreplication.PluginConfig{
+		Name:               c.Name,
+		FormatVersion:      c.FormatVersion,
+		IncludeTransaction: c.IncludeTransaction,
+	}
+}
+
+func (c *PublicationConfig) parsePublicationConfig() *pgreplication.PublicationConfig {
+	if c == nil {
+		return nil
+	}
+	return &pgreplication.PublicationConfig{
+		Name:                    c.Name,
+		PublishViaPartitionRoot: c.PublishViaPartitionRoot,
+		SkipUnlogged:            c.SkipUnlogged,
+	}
+}
+
+func (c *HeartbeatConfig) parseHeartbeatConfig() *pglistener.HeartbeatConfig {
+	if c == nil {
+		return nil
+	}
+	return &pglistener.HeartbeatConfig{
+		Interval:      time.Duration(c.Interval) * time.Second,
+		TableName:     c.TableName,
+		ForwardEvents: c.ForwardEvents,
+	}
+}
+
+func (c *CheckpointTableConfig) parseCheckpointTableConfig() *pgcheckpoint.TableConfig {
+	if c == nil {
+		return nil
+	}
+	return &pgcheckpoint.TableConfig{
+		URL:        c.URL,
+		PipelineID: c.PipelineID,
+		Table:      c.Table,
+	}
+}
+
+func (c *CheckpointFileConfig) parseCheckpointFileConfig() *filecheckpoint.Config {
+	if c == nil {
+		return nil
+	}
+	return &filecheckpoint.Config{
+		Path:          c.Path,
+		FlushInterval: time.Duration(c.FlushInterval) * time.Millisecond,
+		Reset:         c.Reset,
+	}
+}
+
+func (c *SourceCheckpointConfig) parseCheckpointConfig() *committer.Config {
+	if c == nil {
+		return nil
+	}
+	return &committer.Config{
+		CommitInterval:        time.Duration(c.CommitInterval) * time.Millisecond,
+		MaxUncommittedEvents:  c.MaxUncommittedEvents,
+		MaxUncommittedBytes:   c.MaxUncommittedBytes,
+		DisableShutdownCommit: c.DisableShutdownCommit,
+	}
+}
+
+func (c *CheckpointRedisConfig) parseCheckpointRedisConfig() *redischeckpoint.Config {
+	if c == nil {
+		return nil
+	}
+	return &redischeckpoint.Config{
+		Mode:                 redischeckpoint.Mode(c.Mode),
+		Addresses:            c.Addresses,
+		MasterName:           c.MasterName,
+		Username:             c.Username,
+		Password:             c.Password,
+		SentinelUsername:     c.SentinelUsername,
+		SentinelPassword:     c.SentinelPassword,
+		DB:                   c.DB,
+		TLS:                  c.TLS.parseTLSConfig(),
+		StreamID:             c.StreamID,
+		KeyPrefix:            c.KeyPrefix,
+		KeyTTL:               time.Duration(c.KeyTTL) * time.Second,
+		CommitInterval:       time.Duration(c.CommitInterval) * time.Millisecond,
+		MaxUncommittedWindow: time.Duration(c.MaxUncommittedWindow) * time.Second,
+		RetryPolicy:          c.RetryPolicy.parseBackoffConfig(),
+	}
+}
+
 func (t *TLSConfig) parseTLSConfig() tls.Config {
 	if t == nil {
 		return tls.Config{Enabled: false}
@@ -27,7 +27,7 @@ var initCmd = &cobra.Command{
 			return fmt.Errorf("parsing stream config: %w", err)
 		}
 
-		if err := stream.Init(context.Background(), streamConfig.SourcePostgresURL(), streamConfig.PostgresReplicationSlot()); err != nil {
+		if err := stream.Init(context.Background(), streamConfig.SourcePostgresURL(), streamConfig.PostgresReplicationSlot(), stream.WithSlotConfig(streamConfig.PostgresReplicationSlotConfig()), stream.WithOutputPlugin(streamConfig.PostgresReplicationPlugin())); err != nil {
 			sp.Fail(err.Error())
 			return err
 		}
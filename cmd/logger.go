@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	zerologinternal "github.com/xataio/pgstream/internal/log/zerolog"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	zerologlib "github.com/xataio/pgstream/pkg/log/zerolog"
+)
+
+var errInvalidModuleLogLevel = errors.New("invalid module log level, must be in the format module=level")
+
+// newLogger sets up the process global logger, and returns the logger for the
+// pgstream modules, which applies the per module log levels. The levels on
+// output can be used to update them at runtime.
+func newLogger() (loglib.Logger, *zerologlib.Levels, error) {
+	levels, err := newLogLevels()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger := zerologinternal.NewLogger(&zerologinternal.Config{
+		LogLevel: viper.GetString("PGSTREAM_LOG_LEVEL"),
+	})
+	zerologinternal.SetGlobalLogger(logger)
+
+	return zerologinternal.NewStdLogger(logger, zerologlib.WithLevels(levels)), levels, nil
+}
+
+// newLogLevels parses the log levels configuration. The module levels are
+// provided as a list of module=level pairs.
+func newLogLevels() (*zerologlib.Levels, error) {
+	cfg := &zerologlib.LevelsConfig{
+		Level:      viper.GetString("PGSTREAM_LOG_LEVEL"),
+		SampleRate: viper.GetUint64("PGSTREAM_LOG_SAMPLE_RATE"),
+	}
+
+	moduleLevelPairs := viper.GetStringSlice("PGSTREAM_LOG_MODULE_LEVELS")
+	if len(moduleLevelPairs) > 0 {
+		cfg.ModuleLevels = make(map[string]string, len(moduleLevelPairs))
+	}
+	for _, pair := range moduleLevelPairs {
+		module, level, found := strings.Cut(pair, "=")
+		if !found || module == "" || level == "" {
+			return nil, fmt.Errorf("%w: %q", errInvalidModuleLogLevel, pair)
+		}
+		cfg.ModuleLevels[module] = level
+	}
+
+	levels, err := zerologlib.NewLevels(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing log levels: %w", err)
+	}
+	return levels, nil
+}
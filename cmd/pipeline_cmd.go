@@ -0,0 +1,75 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/xataio/pgstream/cmd/config"
+	pgcheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/postgres"
+)
+
+// parent command for pipeline inspection subcommands
+var pipelineCmd = &cobra.Command{
+	Use:   "pipeline",
+	Short: "Inspect the pipelines checkpointed into the checkpoints table",
+}
+
+var pipelineVersionsCmd = &cobra.Command{
+	Use:   "versions",
+	Short: "Lists the pipelines in the checkpoints table and the hash of the configuration they last checkpointed with",
+	RunE:  pipelineVersions,
+	Example: `
+	pgstream pipeline versions --checkpoint-url <postgres-url>
+	pgstream pipeline versions -c pg2pg.yaml --json
+	`,
+}
+
+var errPipelineNoCheckpointTable = errors.New("checkpoint table URL is required to list the pipeline versions")
+
+func pipelineVersions(cmd *cobra.Command, _ []string) error {
+	sp, _ := pterm.DefaultSpinner.WithText("listing pipeline versions...").Start()
+
+	checkpoints, err := func() (pgcheckpoint.PipelineCheckpoints, error) {
+		tableCfg, err := pipelineCheckpointTableConfig(cmd)
+		if err != nil {
+			return nil, err
+		}
+		return pgcheckpoint.ListPipelineCheckpoints(context.Background(), tableCfg)
+	}()
+	if err != nil {
+		sp.Fail(err.Error())
+		return err
+	}
+
+	sp.Success(fmt.Sprintf("found %d pipelines", len(checkpoints)))
+	return print(cmd, checkpoints)
+}
+
+// pipelineCheckpointTableConfig returns the checkpoint table provided with
+// the flags, or the one of the stream configuration if no URL is provided.
+func pipelineCheckpointTableConfig(cmd *cobra.Command) (*pgcheckpoint.TableConfig, error) {
+	url, _ := cmd.Flags().GetString("checkpoint-url")
+	table, _ := cmd.Flags().GetString("checkpoint-table")
+	if url != "" {
+		return &pgcheckpoint.TableConfig{URL: url, Table: table}, nil
+	}
+
+	streamConfig, err := config.ParseStreamConfig()
+	if err != nil {
+		return nil, fmt.Errorf("parsing stream config: %w", err)
+	}
+	tableCfg := streamConfig.CheckpointTableConfig()
+	if tableCfg == nil || tableCfg.URL == "" {
+		return nil, errPipelineNoCheckpointTable
+	}
+	if table != "" {
+		tableCfg.Table = table
+	}
+	return tableCfg, nil
+}
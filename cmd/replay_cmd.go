@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/xataio/pgstream/cmd/config"
+	"github.com/xataio/pgstream/pkg/stream"
+)
+
+var replayCmd = &cobra.Command{
+	Use:     "replay",
+	Short:   "Replay re-processes the events archived by a file log or object store target within an LSN range into the configured target",
+	PreRunE: replayFlagBinding,
+	RunE:    withSignalWatcher(replay),
+	Example: `
+	pgstream replay --config config.yaml --start-lsn 0/15D68C8 --end-lsn 0/15D6A10
+	pgstream replay --config config.env --start-lsn 0/15D68C8 --end-lsn 0/15D6A10 --log-level info`,
+}
+
+func replay(ctx context.Context) error {
+	logger, _, err := newLogger()
+	if err != nil {
+		return err
+	}
+
+	streamConfig, err := config.ParseStreamConfig()
+	if err != nil {
+		return fmt.Errorf("parsing stream config: %w", err)
+	}
+
+	provider, err := newInstrumentationProvider()
+	if err != nil {
+		return err
+	}
+	defer provider.Close()
+
+	return stream.Replay(ctx, logger, streamConfig, provider.NewInstrumentation("replay"))
+}
+
+func replayFlagBinding(cmd *cobra.Command, args []string) error {
+	// to be able to overwrite configuration with flags when yaml config file is
+	// provided
+	viper.BindPFlag("replay.start_lsn", cmd.Flags().Lookup("start-lsn"))
+	viper.BindPFlag("replay.end_lsn", cmd.Flags().Lookup("end-lsn"))
+	viper.BindPFlag("replay.archive.file_log.path", cmd.Flags().Lookup("file-log-path"))
+
+	// to be able to overwrite configuration with flags when env config file is
+	// provided or when no configuration is provided
+	viper.BindPFlag("PGSTREAM_REPLAY_START_LSN", cmd.Flags().Lookup("start-lsn"))
+	viper.BindPFlag("PGSTREAM_REPLAY_END_LSN", cmd.Flags().Lookup("end-lsn"))
+	viper.BindPFlag("PGSTREAM_REPLAY_FILELOG_PATH", cmd.Flags().Lookup("file-log-path"))
+	return nil
+}
@@ -8,12 +8,14 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/xataio/pgstream/cmd/config"
 	"github.com/xataio/pgstream/internal/profiling"
+	"github.com/xataio/pgstream/pkg/debug"
 	"github.com/xataio/pgstream/pkg/otel"
 )
 
@@ -45,6 +47,8 @@ func Prepare() *cobra.Command {
 	// root cmd
 	rootCmd.PersistentFlags().StringP("config", "c", "", ".env or .yaml config file to use with pgstream if any")
 	rootCmd.PersistentFlags().String("log-level", "debug", "log level for the application. One of trace, debug, info, warn, error, fatal, panic")
+	rootCmd.PersistentFlags().StringSlice("log-module-levels", nil, "log level of specific modules, in the format <module>=<level>. Wildcards are supported in the module name")
+	rootCmd.PersistentFlags().Uint64("log-sample-rate", 0, "log 1 in every N trace and debug lines of each module. Info, warning and error lines are always logged")
 
 	// init cmd
 	initCmd.Flags().String("postgres-url", "", "Source postgres URL where pgstream setup will be run")
@@ -78,11 +82,18 @@ func Prepare() *cobra.Command {
 	runCmd.Flags().Bool("profile", false, "Whether to expose a /debug/pprof endpoint on localhost:6060")
 	runCmd.Flags().BoolVar(&initFlag, "init", false, "Whether to initialize pgstream before starting replication")
 	runCmd.Flags().String("dump-file", "", "File where the pg_dump output will be written if initial snapshot is enabled")
+	runCmd.Flags().Bool("reset-checkpoint", false, "Whether to ignore a corrupted checkpoint file and resume from the replication slot position")
+
+	// replay cmd
+	replayCmd.Flags().String("start-lsn", "", "LSN of the first archived event to replay, in the format <upper>/<lower>")
+	replayCmd.Flags().String("end-lsn", "", "LSN of the last archived event to replay, in the format <upper>/<lower>")
+	replayCmd.Flags().String("file-log-path", "", "Path of the file log target archive to replay the events from")
 
 	// status cmd
 	statusCmd.Flags().String("postgres-url", "", "Source postgres URL where pgstream has been initialised")
 	statusCmd.Flags().String("replication-slot", "", "Name of the postgres replication slot created by pgstream on the source url")
 	statusCmd.Flags().Bool("json", false, "Output the status in JSON format")
+	statusCmd.Flags().String("health-url", "", "URL of the health server of a running pgstream process, to retrieve the state of its pipelines")
 
 	// validate cmd
 	// validate rules cmd
@@ -90,6 +101,44 @@ func Prepare() *cobra.Command {
 	validateRulesCmd.Flags().StringP("rules-file", "f", "", "Path to a YAML file containing the transformation rules to validate")
 	validateRulesCmd.Flags().Bool("json", false, "Output the validation status in JSON format")
 	validateCmd.AddCommand(validateRulesCmd)
+	// validate dry-run cmd
+	validateDryRunCmd.Flags().String("postgres-url", "", "Source postgres URL to validate the rules against and read the sample rows from")
+	validateDryRunCmd.Flags().StringP("rules-file", "f", "", "Path to a YAML file containing the transformation rules to validate")
+	validateDryRunCmd.Flags().Int("sample-rows", 0, "Number of rows per table to transform and display, without writing them anywhere")
+	validateDryRunCmd.Flags().Bool("json", false, "Output the dry run report in JSON format")
+	validateCmd.AddCommand(validateDryRunCmd)
+
+	// slot cmd
+	slotCreateCmd.Flags().String("postgres-url", "", "Source postgres URL where the replication slot will be created")
+	slotCreateCmd.Flags().String("replication-slot", "", "Name of the postgres replication slot to be created. Defaults to the pgstream replication slot name for the database")
+	slotCreateCmd.Flags().Bool("json", false, "Output the replication slot details in JSON format")
+	slotDropCmd.Flags().String("postgres-url", "", "Source postgres URL where the replication slot will be dropped")
+	slotDropCmd.Flags().String("replication-slot", "", "Name of the postgres replication slot to be dropped. Defaults to the pgstream replication slot name for the database")
+	slotListCmd.Flags().String("postgres-url", "", "Source postgres URL to list the replication slots from")
+	slotListCmd.Flags().Bool("json", false, "Output the replication slots in JSON format")
+	slotInfoCmd.Flags().String("postgres-url", "", "Source postgres URL where the replication slot exists")
+	slotInfoCmd.Flags().String("replication-slot", "", "Name of the postgres replication slot. Defaults to the pgstream replication slot name for the database")
+	slotInfoCmd.Flags().Bool("json", false, "Output the replication slot details in JSON format")
+	slotCmd.AddCommand(slotCreateCmd)
+	slotCmd.AddCommand(slotDropCmd)
+	slotCmd.AddCommand(slotListCmd)
+	slotCmd.AddCommand(slotInfoCmd)
+
+	// pipeline cmd
+	pipelineVersionsCmd.Flags().String("checkpoint-url", "", "Postgres URL where the checkpoints table is stored. Defaults to the checkpoint table of the configuration")
+	pipelineVersionsCmd.Flags().String("checkpoint-table", "", "Schema qualified name of the checkpoints table. Defaults to pgstream.checkpoints")
+	pipelineVersionsCmd.Flags().Bool("json", false, "Output the pipeline versions in JSON format")
+	pipelineCmd.AddCommand(pipelineVersionsCmd)
+
+	// test cmd
+	disconnectRecoveryCmd.Flags().String("postgres-url", "", "Source postgres URL where the test table and replication slot will be created")
+	disconnectRecoveryCmd.Flags().Int("rows", 1000, "Number of rows inserted, one per transaction")
+	disconnectRecoveryCmd.Flags().Int("disconnect-after", 0, "Number of events delivered before the replication connection is killed. Defaults to half of the rows")
+	disconnectRecoveryCmd.Flags().String("table", "public.pgstream_disconnect_recovery_test", "Schema qualified name of the test table, which is dropped once the test is done")
+	disconnectRecoveryCmd.Flags().String("replication-slot", "pgstream_disconnect_recovery_test_slot", "Name of the test replication slot, which is dropped once the test is done")
+	disconnectRecoveryCmd.Flags().Duration("timeout", 5*time.Minute, "Max duration of the test")
+	disconnectRecoveryCmd.Flags().Bool("json", false, "Output the test report in JSON format")
+	testCmd.AddCommand(disconnectRecoveryCmd)
 
 	// Flag binding for root cmd
 	rootFlagBinding(rootCmd)
@@ -100,8 +149,12 @@ func Prepare() *cobra.Command {
 	rootCmd.AddCommand(tearDownCmd)
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(replayCmd)
 	rootCmd.AddCommand(statusCmd)
 	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(slotCmd)
+	rootCmd.AddCommand(pipelineCmd)
+	rootCmd.AddCommand(testCmd)
 	return rootCmd
 }
 
@@ -160,6 +213,8 @@ func withProfiling(fn func(cmd *cobra.Command, args []string) error) func(cmd *c
 func rootFlagBinding(cmd *cobra.Command) {
 	viper.BindPFlag("config", cmd.PersistentFlags().Lookup("config"))
 	viper.BindPFlag("PGSTREAM_LOG_LEVEL", cmd.PersistentFlags().Lookup("log-level"))
+	viper.BindPFlag("PGSTREAM_LOG_MODULE_LEVELS", cmd.PersistentFlags().Lookup("log-module-levels"))
+	viper.BindPFlag("PGSTREAM_LOG_SAMPLE_RATE", cmd.PersistentFlags().Lookup("log-sample-rate"))
 }
 
 func version() string {
@@ -169,6 +224,28 @@ func version() string {
 	return Version
 }
 
+// newDebugServer starts the debug endpoints server if enabled, or returns nil
+// otherwise.
+func newDebugServer() (*debug.Server, error) {
+	debugConfig, err := config.ParseDebugConfig()
+	if err != nil {
+		return nil, fmt.Errorf("parsing debug config: %w", err)
+	}
+	if debugConfig == nil {
+		return nil, nil
+	}
+	return debug.NewServer(debugConfig)
+}
+
+func closeDebugServer(server *debug.Server) error {
+	if server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(ctx)
+}
+
 func newInstrumentationProvider() (otel.InstrumentationProvider, error) {
 	cfg, err := config.ParseInstrumentationConfig()
 	if err != nil {
@@ -6,26 +6,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/xataio/pgstream/cmd/config"
-	"github.com/xataio/pgstream/internal/log/zerolog"
+	"github.com/xataio/pgstream/pkg/health"
+	zerologlib "github.com/xataio/pgstream/pkg/log/zerolog"
 	"github.com/xataio/pgstream/pkg/stream"
+	"github.com/xataio/pgstream/pkg/wal"
 )
 
 var runCmd = &cobra.Command{
 	Use:     "run",
 	Short:   "Run starts a continuous data stream from the configured source to the configured target",
 	PreRunE: runFlagBinding,
-	RunE:    withProfiling(withSignalWatcher(run)),
+	RunE:    withProfiling(run),
 	Example: `
 	pgstream run --source postgres --source-url <source-postgres-url> --target postgres --target-url <target-postgres-url> --init
 	pgstream run --source postgres --source-url <source-postgres-url> --target postgres --target-url <target-postgres-url> --snapshot-tables <schema.table> --reset
 	pgstream run --source kafka --source-url <kafka-url> --target elasticsearch --target-url <elasticsearch-url>
 	pgstream run --source postgres --source-url <postgres-url> --target kafka --target-url <kafka-url>
 	pgstream run --config config.yaml --log-level info
-	pgstream run --config config.env`,
+	pgstream run --config config.env
+	pgstream run --config config.yaml --reset-checkpoint`,
 }
 
 var (
@@ -37,26 +42,130 @@ var (
 const (
 	defaultKafkaTopicName = "pgstream"
 	postgres              = "postgres"
+
+	logLevelsPath = "/loglevels"
 )
 
-func run(ctx context.Context) error {
-	logger := zerolog.NewLogger(&zerolog.Config{
-		LogLevel: viper.GetString("PGSTREAM_LOG_LEVEL"),
-	})
-	zerolog.SetGlobalLogger(logger)
+func run(cmd *cobra.Command, args []string) error {
+	logger, logLevels, err := newLogger()
+	if err != nil {
+		return err
+	}
 
-	streamConfig, err := config.ParseStreamConfig()
+	pipelinesConfig, err := config.ParsePipelinesConfig()
 	if err != nil {
-		return fmt.Errorf("parsing stream config: %w", err)
+		return fmt.Errorf("parsing pipelines config: %w", err)
 	}
 
 	provider, err := newInstrumentationProvider()
 	if err != nil {
 		return err
 	}
-	defer provider.Close()
 
-	return stream.Run(ctx, zerolog.NewStdLogger(logger), streamConfig, initFlag, provider.NewInstrumentation("run"))
+	healthServer, healthRegistry, err := newHealthServer(logLevels)
+	if err != nil {
+		provider.Close()
+		return err
+	}
+	debugServer, err := newDebugServer()
+	if err != nil {
+		provider.Close()
+		closeHealthServer(healthServer)
+		return err
+	}
+	runOpts := []stream.RunOption{}
+	if healthRegistry != nil {
+		runOpts = append(runOpts, stream.WithHealthRegistry(healthRegistry))
+	}
+
+	// when multiple pipelines are configured, they are all run within this
+	// process, each one with its own listener, processors and checkpointer
+	if len(pipelinesConfig) > 0 {
+		defer provider.Close()
+		defer closeHealthServer(healthServer)
+		defer closeDebugServer(debugServer)
+		return withSignalWatcher(func(ctx context.Context) error {
+			return stream.RunPipelines(ctx, logger, pipelinesConfig, initFlag, provider, runOpts...)
+		})(cmd, args)
+	}
+
+	streamConfig, err := config.ParseStreamConfig()
+	if err != nil {
+		provider.Close()
+		closeHealthServer(healthServer)
+		closeDebugServer(debugServer)
+		return fmt.Errorf("parsing stream config: %w", err)
+	}
+
+	shutdownConfig, err := config.ParseShutdownConfig()
+	if err != nil {
+		provider.Close()
+		closeHealthServer(healthServer)
+		closeDebugServer(debugServer)
+		return fmt.Errorf("parsing shutdown config: %w", err)
+	}
+
+	// when the stream replicates from postgres with a config file, a SIGHUP
+	// reloads the file and restarts the stream with it without a gap in the
+	// replication, instead of shutting it down
+	shutdownOpts := []wal.ShutdownOption{wal.WithShutdownLogger(logger)}
+	var restart *stream.GracefulRestart
+	if viper.GetString("config") != "" && streamConfig.Listener.Postgres != nil {
+		restart = stream.NewGracefulRestart(config.ReloadStreamConfig, stream.WithRestartLogger(logger))
+		shutdownOpts = append(shutdownOpts, wal.WithShutdownSignals(syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT))
+	}
+
+	// the graceful shutdown intercepts the signals and stops the stream
+	// components in order, exiting the process once done. The instrumentation
+	// provider is registered first so that it's the last one to be closed.
+	shutdown := wal.NewGracefulShutdown(shutdownConfig, shutdownOpts...)
+	shutdown.OnClose(provider.Close)
+	shutdown.OnClose(func() error {
+		return closeHealthServer(healthServer)
+	})
+	shutdown.OnClose(func() error {
+		return closeDebugServer(debugServer)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go shutdown.Watch(ctx)
+
+	runOpts = append(runOpts, stream.WithGracefulShutdown(shutdown))
+	if restart != nil {
+		go restart.Watch(ctx)
+		return restart.Run(ctx, logger, streamConfig, initFlag, provider.NewInstrumentation("run"), runOpts...)
+	}
+	return stream.Run(ctx, logger, streamConfig, initFlag, provider.NewInstrumentation("run"), runOpts...)
+}
+
+// newHealthServer starts the health endpoints server if enabled, returning the
+// registry the pipelines report their state to. Both are nil otherwise. The
+// server also exposes the log levels, so that they can be updated at runtime.
+func newHealthServer(logLevels *zerologlib.Levels) (*health.Server, *health.Registry, error) {
+	healthConfig, err := config.ParseHealthConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing health config: %w", err)
+	}
+	if healthConfig == nil {
+		return nil, nil, nil
+	}
+
+	registry := health.NewRegistry(healthConfig)
+	server, err := health.NewServer(healthConfig, registry, health.WithHandler(logLevelsPath, logLevels))
+	if err != nil {
+		return nil, nil, err
+	}
+	return server, registry, nil
+}
+
+func closeHealthServer(server *health.Server) error {
+	if server == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(ctx)
 }
 
 func runFlagBinding(cmd *cobra.Command, args []string) error {
@@ -73,6 +182,11 @@ func runFlagBinding(cmd *cobra.Command, args []string) error {
 
 	initialSnapshotFlagBinding(cmd)
 
+	if cmd.Flags().Lookup("reset-checkpoint").Changed {
+		viper.BindPFlag("source.postgres.replication.checkpoint_file.reset", cmd.Flags().Lookup("reset-checkpoint"))
+		viper.BindPFlag("PGSTREAM_POSTGRES_REPLICATION_CHECKPOINT_FILE_RESET", cmd.Flags().Lookup("reset-checkpoint"))
+	}
+
 	return nil
 }
 
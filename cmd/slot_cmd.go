@@ -0,0 +1,146 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/xataio/pgstream/cmd/config"
+	"github.com/xataio/pgstream/pkg/wal/replication/slot"
+)
+
+// parent command for replication slot management subcommands
+var slotCmd = &cobra.Command{
+	Use:   "slot",
+	Short: "Manage the lifecycle of the postgres replication slots",
+}
+
+var slotCreateCmd = &cobra.Command{
+	Use:    "create",
+	Short:  "Creates a logical replication slot using the configured output plugin, wal2json by default",
+	PreRun: slotFlagBinding,
+	RunE: withSlotManager("creating replication slot...", func(ctx context.Context, cmd *cobra.Command, m *slot.Manager, slotName string, sp *pterm.SpinnerPrinter) error {
+		s, err := m.Create(ctx, slotName)
+		if err != nil {
+			return err
+		}
+
+		sp.Success("replication slot ", s.Name, " created")
+		return print(cmd, s)
+	}),
+	Example: `
+	pgstream slot create --postgres-url <postgres-url> --replication-slot <replication-slot-name>
+	pgstream slot create -c pg2pg.yaml
+	`,
+}
+
+var slotDropCmd = &cobra.Command{
+	Use:    "drop",
+	Short:  "Drops a replication slot",
+	PreRun: slotFlagBinding,
+	RunE: withSlotManager("dropping replication slot...", func(ctx context.Context, cmd *cobra.Command, m *slot.Manager, slotName string, sp *pterm.SpinnerPrinter) error {
+		if err := m.Drop(ctx, slotName); err != nil {
+			return err
+		}
+
+		sp.Success("replication slot dropped")
+		return nil
+	}),
+	Example: `
+	pgstream slot drop --postgres-url <postgres-url> --replication-slot <replication-slot-name>
+	pgstream slot drop -c pg2pg.env
+	`,
+}
+
+var slotListCmd = &cobra.Command{
+	Use:    "list",
+	Short:  "Lists the replication slots",
+	PreRun: slotFlagBinding,
+	RunE: withSlotManager("listing replication slots...", func(ctx context.Context, cmd *cobra.Command, m *slot.Manager, _ string, sp *pterm.SpinnerPrinter) error {
+		slots, err := m.List(ctx)
+		if err != nil {
+			return err
+		}
+
+		sp.Success(fmt.Sprintf("found %d replication slots", len(slots)))
+		return print(cmd, slots)
+	}),
+	Example: `
+	pgstream slot list --postgres-url <postgres-url>
+	pgstream slot list -c pg2pg.yaml --json
+	`,
+}
+
+var slotInfoCmd = &cobra.Command{
+	Use:    "info",
+	Short:  "Displays the details of a replication slot, including the confirmed LSN, active PID and lag",
+	PreRun: slotFlagBinding,
+	RunE: withSlotManager("retrieving replication slot info...", func(ctx context.Context, cmd *cobra.Command, m *slot.Manager, slotName string, sp *pterm.SpinnerPrinter) error {
+		s, err := m.Info(ctx, slotName)
+		if err != nil {
+			return err
+		}
+
+		sp.Success("replication slot ", s.Name, " info retrieved")
+		return print(cmd, s)
+	}),
+	Example: `
+	pgstream slot info --postgres-url <postgres-url> --replication-slot <replication-slot-name>
+	pgstream slot info -c pg2pg.yaml --json
+	`,
+}
+
+var errSlotNoPostgresURL = errors.New("postgres URL is required for replication slot management")
+
+// withSlotManager sets up the replication slot manager for the configured
+// source postgres URL before running the slot subcommand function provided.
+func withSlotManager(text string, fn func(ctx context.Context, cmd *cobra.Command, m *slot.Manager, slotName string, sp *pterm.SpinnerPrinter) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		sp, _ := pterm.DefaultSpinner.WithText(text).Start()
+
+		err := func() error {
+			streamConfig, err := config.ParseStreamConfig()
+			if err != nil {
+				return fmt.Errorf("parsing stream config: %w", err)
+			}
+
+			pgURL := streamConfig.SourcePostgresURL()
+			if pgURL == "" {
+				return errSlotNoPostgresURL
+			}
+
+			ctx := context.Background()
+			m, err := slot.NewManager(ctx, pgURL, slot.WithPlugin(streamConfig.PostgresReplicationPlugin()))
+			if err != nil {
+				return err
+			}
+			defer m.Close(ctx)
+
+			return fn(ctx, cmd, m, streamConfig.PostgresReplicationSlot(), sp)
+		}()
+		if err != nil {
+			sp.Fail(err.Error())
+		}
+
+		return err
+	}
+}
+
+func slotFlagBinding(cmd *cobra.Command, _ []string) {
+	// to be able to overwrite configuration with flags when yaml config file is
+	// provided
+	viper.BindPFlag("source.postgres.url", cmd.Flags().Lookup("postgres-url"))
+	viper.BindPFlag("source.postgres.replication.replication_slot", cmd.Flags().Lookup("replication-slot"))
+	viper.Set("source.postgres.mode", "replication")
+
+	// to be able to overwrite configuration with flags when env config file is
+	// provided or when no configuration is provided
+	viper.BindPFlag("PGSTREAM_POSTGRES_LISTENER_URL", cmd.Flags().Lookup("postgres-url"))
+	viper.BindPFlag("PGSTREAM_POSTGRES_REPLICATION_SLOT_NAME", cmd.Flags().Lookup("replication-slot"))
+}
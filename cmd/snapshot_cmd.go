@@ -9,7 +9,6 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/xataio/pgstream/cmd/config"
-	"github.com/xataio/pgstream/internal/log/zerolog"
 	"github.com/xataio/pgstream/pkg/stream"
 )
 
@@ -25,10 +24,10 @@ var snapshotCmd = &cobra.Command{
 }
 
 func snapshot(ctx context.Context) error {
-	logger := zerolog.NewLogger(&zerolog.Config{
-		LogLevel: viper.GetString("PGSTREAM_LOG_LEVEL"),
-	})
-	zerolog.SetGlobalLogger(logger)
+	logger, _, err := newLogger()
+	if err != nil {
+		return err
+	}
 
 	streamConfig, err := config.ParseStreamConfig()
 	if err != nil {
@@ -41,7 +40,13 @@ func snapshot(ctx context.Context) error {
 	}
 	defer provider.Close()
 
-	return stream.Snapshot(ctx, zerolog.NewStdLogger(logger), streamConfig, provider.NewInstrumentation("snapshot"))
+	debugServer, err := newDebugServer()
+	if err != nil {
+		return err
+	}
+	defer closeDebugServer(debugServer)
+
+	return stream.Snapshot(ctx, logger, streamConfig, provider.NewInstrumentation("snapshot"))
 }
 
 func snapshotFlagBinding(cmd *cobra.Command, args []string) error {
@@ -7,13 +7,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/xataio/pgstream/cmd/config"
+	"github.com/xataio/pgstream/pkg/health"
 	"github.com/xataio/pgstream/pkg/stream"
 )
 
@@ -24,6 +27,12 @@ var statusCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		sp, _ := pterm.DefaultSpinner.WithText("checking pgstream status...").Start()
 
+		// the state of a running pgstream process is retrieved from its
+		// health server instead of checking the configuration
+		if healthURL := cmd.Flags().Lookup("health-url").Value.String(); healthURL != "" {
+			return runningStatus(cmd, sp, healthURL)
+		}
+
 		streamConfig, err := config.ParseStreamConfig()
 		if err != nil {
 			return fmt.Errorf("parsing stream config: %w", err)
@@ -55,9 +64,33 @@ var statusCmd = &cobra.Command{
 	pgstream status -c pg2pg.env
 	pgstream status --postgres-url <postgres-url> --replication-slot <replication-slot-name>
 	pgstream status -c pg2pg.yaml --json
+	pgstream status --health-url http://localhost:8080
 	`,
 }
 
+func runningStatus(cmd *cobra.Command, sp *pterm.SpinnerPrinter, healthURL string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	status, err := health.FetchStatus(ctx, http.DefaultClient, healthURL)
+	if err != nil {
+		sp.Fail(err.Error())
+		return err
+	}
+
+	if status.Ready {
+		sp.Success("pgstream pipelines are ready")
+	} else {
+		sp.Warning("pgstream pipelines are not ready")
+	}
+
+	if err := print(cmd, status); err != nil {
+		sp.Fail("failed to format pgstream status")
+		return err
+	}
+	return nil
+}
+
 type printer interface {
 	PrettyPrint() string
 }
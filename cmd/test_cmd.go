@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/xataio/pgstream/cmd/config"
+	"github.com/xataio/pgstream/pkg/testing/harness"
+)
+
+// parent command for the end to end validation tests
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Run end to end tests validating the pgstream behaviour against a postgres database",
+}
+
+var errTestNoPostgresURL = errors.New("postgres URL is required for the disconnect recovery test")
+
+var errDisconnectRecoveryFailed = errors.New("disconnect recovery test failed")
+
+var disconnectRecoveryCmd = &cobra.Command{
+	Use:    "disconnect-recovery",
+	Short:  "Validates that the replication resumes from the last checkpoint after a disconnect, without event gaps or duplicates",
+	PreRun: testFlagBinding,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sp, _ := pterm.DefaultSpinner.WithText("running disconnect recovery test...").Start()
+
+		err := func() error {
+			streamConfig, err := config.ParseStreamConfig()
+			if err != nil {
+				return fmt.Errorf("parsing stream config: %w", err)
+			}
+
+			pgURL := streamConfig.SourcePostgresURL()
+			if pgURL == "" {
+				return errTestNoPostgresURL
+			}
+
+			cfg := harness.DisconnectRecoveryConfig{
+				PostgresURL:         pgURL,
+				Rows:                viper.GetInt("test.rows"),
+				DisconnectAfter:     viper.GetInt("test.disconnect_after"),
+				Table:               viper.GetString("test.table"),
+				ReplicationSlotName: viper.GetString("test.replication_slot"),
+				Timeout:             viper.GetDuration("test.timeout"),
+			}
+
+			ctx := context.Background()
+			test, err := harness.NewDisconnectRecoveryTest(ctx, cfg)
+			if err != nil {
+				return err
+			}
+			defer test.Close()
+
+			result, err := test.Run(ctx)
+			if err != nil {
+				return err
+			}
+
+			if !result.Passed() {
+				sp.Fail(fmt.Sprintf("disconnect recovery test failed: %d missing and %d duplicate events", result.Missing, result.Duplicates))
+				if err := print(cmd, result); err != nil {
+					return err
+				}
+				return errDisconnectRecoveryFailed
+			}
+
+			sp.Success(fmt.Sprintf("disconnect recovery test passed: %d events delivered exactly once", result.Delivered))
+			return print(cmd, result)
+		}()
+		if err != nil && !errors.Is(err, errDisconnectRecoveryFailed) {
+			sp.Fail(err.Error())
+		}
+
+		return err
+	},
+	Example: `
+	pgstream test disconnect-recovery --postgres-url <postgres-url>
+	pgstream test disconnect-recovery -c pg2pg.yaml --rows 10000 --disconnect-after 2500
+	pgstream test disconnect-recovery --postgres-url <postgres-url> --json
+	`,
+}
+
+func testFlagBinding(cmd *cobra.Command, _ []string) {
+	// to be able to overwrite configuration with flags when yaml config file is
+	// provided
+	viper.BindPFlag("source.postgres.url", cmd.Flags().Lookup("postgres-url"))
+	viper.Set("source.postgres.mode", "replication")
+
+	// to be able to overwrite configuration with flags when env config file is
+	// provided or when no configuration is provided
+	viper.BindPFlag("PGSTREAM_POSTGRES_LISTENER_URL", cmd.Flags().Lookup("postgres-url"))
+
+	viper.BindPFlag("test.rows", cmd.Flags().Lookup("rows"))
+	viper.BindPFlag("test.disconnect_after", cmd.Flags().Lookup("disconnect-after"))
+	viper.BindPFlag("test.table", cmd.Flags().Lookup("table"))
+	viper.BindPFlag("test.replication_slot", cmd.Flags().Lookup("replication-slot"))
+	viper.BindPFlag("test.timeout", cmd.Flags().Lookup("timeout"))
+}
@@ -41,22 +41,12 @@ var validateRulesCmd = &cobra.Command{
 				return errNoPostgresURL
 			}
 
+			if err := overwriteRulesFromFlag(cmd, streamConfig); err != nil {
+				return err
+			}
 			if streamConfig.Processor.Transformer.HasNoRules() {
-				// If yaml config file is provided without rules, make sure to
-				// overwrite with rules file from flag if available.
-				if cmd.Flags().Lookup("rules-file").Changed {
-					rulesConfig, err := config.ParseTransformerConfig(cmd.Flags().Lookup("rules-file").Value.String())
-					if err != nil {
-						return fmt.Errorf("parsing transformer rules config: %w", err)
-					}
-					streamConfig.Processor.Transformer = rulesConfig
-				}
-
-				// check again after overwriting rules
-				if streamConfig.Processor.Transformer.HasNoRules() {
-					sp.Success("no transformation rules to validate")
-					return nil
-				}
+				sp.Success("no transformation rules to validate")
+				return nil
 			}
 
 			statusChecker := stream.NewStatusChecker()
@@ -91,6 +81,86 @@ var validateRulesCmd = &cobra.Command{
 	`,
 }
 
+var errInvalidRules = errors.New("transformation rules dry run identified invalid rules")
+
+var validateDryRunCmd = &cobra.Command{
+	Use:     "dry-run",
+	Short:   "Validates each transformation rule against the provided Postgres database and previews the transformations on sample rows",
+	PreRunE: validateRulesFlagBinding,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sp, _ := pterm.DefaultSpinner.WithText("running pgstream transformation rules dry run...").Start()
+
+		err := func() error {
+			streamConfig, err := config.ParseStreamConfig()
+			if err != nil {
+				return fmt.Errorf("parsing stream config: %w", err)
+			}
+
+			if streamConfig.Listener.Postgres == nil || streamConfig.Listener.Postgres.URL == "" {
+				return errNoPostgresURL
+			}
+
+			if err := overwriteRulesFromFlag(cmd, streamConfig); err != nil {
+				return err
+			}
+			if streamConfig.Processor.Transformer.HasNoRules() {
+				sp.Success("no transformation rules to validate")
+				return nil
+			}
+
+			sampleRows, err := cmd.Flags().GetInt("sample-rows")
+			if err != nil {
+				return err
+			}
+
+			report, err := stream.DryRunTransformationRules(context.Background(), streamConfig, sampleRows)
+			if err != nil {
+				return err
+			}
+
+			if report.Valid() {
+				sp.Success("transformation rules are valid")
+			} else {
+				sp.Warning("pgstream dry run identified invalid transformation rules")
+			}
+
+			if err := print(cmd, report); err != nil {
+				return fmt.Errorf("failed to format pgstream dry run report: %w", err)
+			}
+
+			if !report.Valid() {
+				return errInvalidRules
+			}
+			return nil
+		}()
+		if err != nil && !errors.Is(err, errInvalidRules) {
+			sp.Fail(err.Error())
+		}
+
+		return err
+	},
+	Example: `
+	pgstream validate dry-run -c pg2pg.env
+	pgstream validate dry-run --postgres-url <postgres-url> --rules-file rules.yaml --sample-rows 5
+	pgstream validate dry-run -c pg2pg.yaml --sample-rows 5 --json
+	`,
+}
+
+// overwriteRulesFromFlag makes sure the transformation rules are taken from
+// the rules file flag when the configuration provided has no rules.
+func overwriteRulesFromFlag(cmd *cobra.Command, streamConfig *stream.Config) error {
+	if !streamConfig.Processor.Transformer.HasNoRules() || !cmd.Flags().Lookup("rules-file").Changed {
+		return nil
+	}
+
+	rulesConfig, err := config.ParseTransformerConfig(cmd.Flags().Lookup("rules-file").Value.String())
+	if err != nil {
+		return fmt.Errorf("parsing transformer rules config: %w", err)
+	}
+	streamConfig.Processor.Transformer = rulesConfig
+	return nil
+}
+
 func validateRulesFlagBinding(cmd *cobra.Command, _ []string) error {
 	// to be able to overwrite configuration with flags when yaml config file is
 	// provided
@@ -52,8 +52,8 @@ func SetGlobalLogger(logger *zerolog.Logger) {
 	zerolog.DefaultContextLogger = logger
 }
 
-func NewStdLogger(l *zerolog.Logger) loglib.Logger {
-	return zerologlib.NewLogger(l)
+func NewStdLogger(l *zerolog.Logger, opts ...zerologlib.Option) loglib.Logger {
+	return zerologlib.NewLogger(l, opts...)
 }
 
 // NewLogger creates a new logger writing to out.
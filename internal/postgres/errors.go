@@ -72,6 +72,14 @@ func (e *ErrRuleViolation) Error() string {
 	return fmt.Sprintf("rule violation: %s", e.Details)
 }
 
+type ErrObjectInUse struct {
+	Details string
+}
+
+func (e *ErrObjectInUse) Error() string {
+	return fmt.Sprintf("object in use: %s", e.Details)
+}
+
 type ErrPreconditionFailed struct {
 	Details string
 }
@@ -80,6 +88,14 @@ func (e *ErrPreconditionFailed) Error() string {
 	return fmt.Sprintf("precondition failed: %s", e.Details)
 }
 
+type ErrSerializationFailure struct {
+	Details string
+}
+
+func (e *ErrSerializationFailure) Error() string {
+	return fmt.Sprintf("serialization failure: %s", e.Details)
+}
+
 func MapError(err error) error {
 	if pgconn.Timeout(err) {
 		return fmt.Errorf("%w: %w", ErrConnTimeout, err)
@@ -124,6 +140,16 @@ func MapError(err error) error {
 			return &ErrPreconditionFailed{
 				Details: pgErr.Message,
 			}
+		case "55006":
+			// 55006 	object_in_use
+			return &ErrObjectInUse{
+				Details: pgErr.Message,
+			}
+		case "40001":
+			// 40001 	serialization_failure
+			return &ErrSerializationFailure{
+				Details: pgErr.Message,
+			}
 		case "42701", "42P03", "42P04", "42723", "42P05", "42P06", "42P07", "42712", "42710":
 			// 42701 	duplicate_column
 			// 42P03 	duplicate_cursor
@@ -10,8 +10,9 @@ import (
 
 type ReplicationConn struct {
 	IdentifySystemFn          func(ctx context.Context) (postgres.IdentifySystemResult, error)
+	CreateReplicationSlotFn   func(ctx context.Context, cfg postgres.CreateReplicationSlotConfig) error
 	StartReplicationFn        func(ctx context.Context, cfg postgres.ReplicationConfig) error
-	SendStandbyStatusUpdateFn func(ctx context.Context, lsn uint64) error
+	SendStandbyStatusUpdateFn func(ctx context.Context, status postgres.StandbyStatus) error
 	ReceiveMessageFn          func(ctx context.Context) (*postgres.ReplicationMessage, error)
 	CloseFn                   func(ctx context.Context) error
 }
@@ -20,12 +21,16 @@ func (m *ReplicationConn) IdentifySystem(ctx context.Context) (postgres.Identify
 	return m.IdentifySystemFn(ctx)
 }
 
+func (m *ReplicationConn) CreateReplicationSlot(ctx context.Context, cfg postgres.CreateReplicationSlotConfig) error {
+	return m.CreateReplicationSlotFn(ctx, cfg)
+}
+
 func (m *ReplicationConn) StartReplication(ctx context.Context, cfg postgres.ReplicationConfig) error {
 	return m.StartReplicationFn(ctx, cfg)
 }
 
-func (m *ReplicationConn) SendStandbyStatusUpdate(ctx context.Context, lsn uint64) error {
-	return m.SendStandbyStatusUpdateFn(ctx, lsn)
+func (m *ReplicationConn) SendStandbyStatusUpdate(ctx context.Context, status postgres.StandbyStatus) error {
+	return m.SendStandbyStatusUpdateFn(ctx, status)
 }
 
 func (m *ReplicationConn) ReceiveMessage(ctx context.Context) (*postgres.ReplicationMessage, error) {
@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// AuthMode defines how the postgres connections are authenticated.
+type AuthMode string
+
+const (
+	// AuthModePassword uses the password provided in the connection string,
+	// if any.
+	AuthModePassword AuthMode = "password"
+	// AuthModeRDSIAM uses AWS RDS IAM authentication tokens, generated with
+	// the AWS SDK default credential chain before each connection is
+	// established.
+	AuthModeRDSIAM AuthMode = "rds_iam"
+)
+
+// Connection string parameters used to configure the authentication. They're
+// removed from the connection string before connecting, since they're not
+// postgres parameters.
+const (
+	authModeParam  = "pgstream_auth_mode"
+	awsRegionParam = "pgstream_aws_region"
+)
+
+const (
+	rdsIAMService = "rds-db"
+	// rdsIAMTokenExpiry is the validity of the RDS IAM tokens. Tokens are only
+	// used to establish the connection, so they're regenerated for every new
+	// connection instead of being cached.
+	rdsIAMTokenExpiry = 15 * time.Minute
+	// emptyPayloadHash is the sha256 hash of an empty payload, used to sign
+	// the RDS IAM token request.
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+var (
+	errUnsupportedAuthMode = errors.New("unsupported postgres auth mode")
+	errMissingAWSRegion    = errors.New("aws region is required for rds iam authentication")
+	errAuthRequiresURL     = errors.New("postgres auth mode requires a URL connection string")
+)
+
+// passwordProvider sets the password of the connection config on input
+// before connecting.
+type passwordProvider func(ctx context.Context, cfg *pgconn.Config) error
+
+// awsCredentialsLoader returns the AWS credentials provider and the default
+// region resolved by the AWS SDK credential chain.
+var awsCredentialsLoader = func(ctx context.Context) (aws.CredentialsProvider, string, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("loading aws config: %w", err)
+	}
+	return cfg.Credentials, cfg.Region, nil
+}
+
+// WithAuthMode returns the URL connection string on input with the auth mode
+// parameters set. The AWS region is only used by the RDS IAM auth mode, and
+// defaults to the AWS SDK configured region when empty.
+func WithAuthMode(connString string, mode AuthMode, awsRegion string) (string, error) {
+	if mode == "" || mode == AuthModePassword {
+		return connString, nil
+	}
+	if mode != AuthModeRDSIAM {
+		return "", fmt.Errorf("%w: %q", errUnsupportedAuthMode, mode)
+	}
+
+	u, err := parseConnectionURL(connString)
+	if err != nil {
+		return "", err
+	}
+	params := u.Query()
+	params.Set(authModeParam, string(mode))
+	if awsRegion != "" {
+		params.Set(awsRegionParam, awsRegion)
+	}
+	u.RawQuery = params.Encode()
+	return u.String(), nil
+}
+
+// newPasswordProvider removes the auth mode parameters from the runtime
+// parameters of the connection config on input, and returns the password
+// provider for the configured auth mode. It returns nil if the password from
+// the connection string is to be used.
+func newPasswordProvider(ctx context.Context, cfg *pgconn.Config) (passwordProvider, error) {
+	mode := AuthMode(cfg.RuntimeParams[authModeParam])
+	region := cfg.RuntimeParams[awsRegionParam]
+	delete(cfg.RuntimeParams, authModeParam)
+	delete(cfg.RuntimeParams, awsRegionParam)
+
+	switch mode {
+	case "", AuthModePassword:
+		return nil, nil
+	case AuthModeRDSIAM:
+		return newRDSIAMPasswordProvider(ctx, region)
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnsupportedAuthMode, mode)
+	}
+}
+
+// ConfigureAuth sets the password of the connection config on input according
+// to its auth mode.
+func ConfigureAuth(ctx context.Context, cfg *pgconn.Config) error {
+	provider, err := newPasswordProvider(ctx, cfg)
+	if err != nil || provider == nil {
+		return err
+	}
+	return provider(ctx, cfg)
+}
+
+func newRDSIAMPasswordProvider(ctx context.Context, region string) (passwordProvider, error) {
+	credentials, defaultRegion, err := awsCredentialsLoader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if region == "" {
+		region = defaultRegion
+	}
+	if region == "" {
+		return nil, errMissingAWSRegion
+	}
+	return rdsIAMPasswordProvider(credentials, region), nil
+}
+
+// rdsIAMPasswordProvider returns a password provider that generates a new RDS
+// IAM token every time it's called.
+func rdsIAMPasswordProvider(credentials aws.CredentialsProvider, region string) passwordProvider {
+	return func(ctx context.Context, cfg *pgconn.Config) error {
+		endpoint := net.JoinHostPort(cfg.Host, strconv.Itoa(int(cfg.Port)))
+		token, err := buildRDSIAMAuthToken(ctx, endpoint, region, cfg.User, credentials)
+		if err != nil {
+			return fmt.Errorf("generating rds iam auth token: %w", err)
+		}
+		cfg.Password = token
+		return nil
+	}
+}
+
+// buildRDSIAMAuthToken returns an RDS IAM authentication token for the
+// database user and endpoint on input. The token is a presigned connect
+// request URL, without the scheme.
+func buildRDSIAMAuthToken(ctx context.Context, endpoint, region, user string, credentials aws.CredentialsProvider) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.URL.Path = "/"
+	values := req.URL.Query()
+	values.Set("Action", "connect")
+	values.Set("DBUser", user)
+	values.Set("X-Amz-Expires", strconv.Itoa(int(rdsIAMTokenExpiry.Seconds())))
+	req.URL.RawQuery = values.Encode()
+
+	creds, err := credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("retrieving aws credentials: %w", err)
+	}
+
+	signedURL, _, err := v4.NewSigner().PresignHTTP(ctx, creds, req, emptyPayloadHash, rdsIAMService, region, time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(signedURL, "https://"), nil
+}
+
+// ResolveAuth returns the connection string on input with the auth mode
+// parameters removed and the password resolved for the configured auth mode.
+// It's meant to be used by the clients that don't go through the pgx
+// connection configuration, such as the pg_dump and pg_restore commands.
+func ResolveAuth(ctx context.Context, connString string) (string, error) {
+	if !strings.Contains(connString, authModeParam) {
+		return connString, nil
+	}
+
+	u, err := parseConnectionURL(connString)
+	if err != nil {
+		return "", err
+	}
+	params := u.Query()
+	mode := AuthMode(params.Get(authModeParam))
+	region := params.Get(awsRegionParam)
+	params.Del(authModeParam)
+	params.Del(awsRegionParam)
+	u.RawQuery = params.Encode()
+
+	switch mode {
+	case "", AuthModePassword:
+		return u.String(), nil
+	case AuthModeRDSIAM:
+	default:
+		return "", fmt.Errorf("%w: %q", errUnsupportedAuthMode, mode)
+	}
+
+	cfg, err := pgconn.ParseConfig(u.String())
+	if err != nil {
+		return "", fmt.Errorf("failed parsing postgres connection string: %w", MapError(err))
+	}
+	provider, err := newRDSIAMPasswordProvider(ctx, region)
+	if err != nil {
+		return "", err
+	}
+	if err := provider(ctx, cfg); err != nil {
+		return "", err
+	}
+
+	user := u.User.Username()
+	if user == "" {
+		user = cfg.User
+	}
+	u.User = url.UserPassword(user, cfg.Password)
+	return u.String(), nil
+}
+
+func parseConnectionURL(connString string) (*url.URL, error) {
+	if !strings.HasPrefix(connString, "postgresql://") && !strings.HasPrefix(connString, "postgres://") {
+		return nil, errAuthRequiresURL
+	}
+	// connection strings without user info don't need escaping
+	if escapedURL, err := escapeConnectionURL(connString); err == nil {
+		connString = escapedURL
+	}
+	return url.Parse(connString)
+}
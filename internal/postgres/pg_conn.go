@@ -21,6 +21,10 @@ func NewConn(ctx context.Context, url string) (*Conn, error) {
 
 	configureTCPKeepalive(pgCfg)
 
+	if err := ConfigureAuth(ctx, &pgCfg.Config); err != nil {
+		return nil, err
+	}
+
 	conn, err := pgx.ConnectConfig(ctx, pgCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to postgres: %w", MapError(err))
@@ -61,7 +65,7 @@ func (c *Conn) ExecInTxWithOptions(ctx context.Context, fn func(Tx) error, opts
 		return MapError(err)
 	}
 
-	return tx.Commit(ctx)
+	return MapError(tx.Commit(ctx))
 }
 
 func (c *Conn) CopyFrom(ctx context.Context, tableName string, columnNames []string, srcRows [][]any) (int64, error) {
@@ -32,6 +32,18 @@ func NewConnPool(ctx context.Context, url string, opts ...PoolOption) (*Pool, er
 
 	configureTCPKeepalive(pgCfg.ConnConfig)
 
+	// the password is resolved for every new connection of the pool, so
+	// that short lived credentials are not used past their expiry
+	passwordProvider, err := newPasswordProvider(ctx, &pgCfg.ConnConfig.Config)
+	if err != nil {
+		return nil, err
+	}
+	if passwordProvider != nil {
+		pgCfg.BeforeConnect = func(ctx context.Context, cfg *pgx.ConnConfig) error {
+			return passwordProvider(ctx, &cfg.Config)
+		}
+	}
+
 	for _, opt := range opts {
 		opt(pgCfg)
 	}
@@ -80,7 +92,7 @@ func (c *Pool) ExecInTxWithOptions(ctx context.Context, fn func(Tx) error, opts
 		return MapError(err)
 	}
 
-	return tx.Commit(ctx)
+	return MapError(tx.Commit(ctx))
 }
 
 func (c *Pool) CopyFrom(ctx context.Context, tableName string, columnNames []string, srcRows [][]any) (int64, error) {
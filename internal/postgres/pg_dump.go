@@ -101,7 +101,13 @@ func (opts *PGDumpOptions) ToArgs() []string {
 }
 
 // Func RunPGDump runs pg_dump command with the given options and returns the result.
-func RunPGDump(_ context.Context, opts PGDumpOptions) ([]byte, error) {
+func RunPGDump(ctx context.Context, opts PGDumpOptions) ([]byte, error) {
+	var err error
+	opts.ConnectionString, err = ResolveAuth(ctx, opts.ConnectionString)
+	if err != nil {
+		return nil, err
+	}
+
 	cmd := exec.Command(pgDumpCmd, opts.ToArgs()...) //nolint:gosec
 
 	// TODO: add streaming support when it needs to be used for large data dumps
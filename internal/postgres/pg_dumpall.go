@@ -61,7 +61,13 @@ func (opts *PGDumpAllOptions) ToArgs() []string {
 }
 
 // Func RunPGDumpAll runs pg_dumpall command with the given options and returns the result.
-func RunPGDumpAll(_ context.Context, opts PGDumpAllOptions) ([]byte, error) {
+func RunPGDumpAll(ctx context.Context, opts PGDumpAllOptions) ([]byte, error) {
+	var err error
+	opts.ConnectionString, err = ResolveAuth(ctx, opts.ConnectionString)
+	if err != nil {
+		return nil, err
+	}
+
 	cmd := exec.Command(pgDumpAllCmd, opts.ToArgs()...) //nolint:gosec
 
 	var stderr bytes.Buffer
@@ -49,6 +49,21 @@ func (m *Mapper) TypeForOID(ctx context.Context, oid uint32) (string, error) {
 	return dataType.Name, nil
 }
 
+// ArrayElementOID returns the OID of the element type for the given array
+// type OID. It returns false if the OID is not a standard PostgreSQL array
+// type.
+func (m *Mapper) ArrayElementOID(oid uint32) (uint32, bool) {
+	dataType, found := m.pgMap.TypeForOID(oid)
+	if !found {
+		return 0, false
+	}
+	arrayCodec, ok := dataType.Codec.(*pgtype.ArrayCodec)
+	if !ok {
+		return 0, false
+	}
+	return arrayCodec.ElementType.OID, true
+}
+
 func (m *Mapper) queryType(ctx context.Context, oid uint32) (string, error) {
 	if customType, found := m.customOIDMap.Get(oid); found {
 		return customType, nil
@@ -92,3 +92,43 @@ func TestMapper_TypeForOID(t *testing.T) {
 		})
 	}
 }
+
+func TestMapper_ArrayElementOID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		oid  uint32
+
+		wantOID   uint32
+		wantFound bool
+	}{
+		{
+			name:      "text array",
+			oid:       1009, // OID for _text
+			wantOID:   25,
+			wantFound: true,
+		},
+		{
+			name:      "not an array",
+			oid:       25, // OID for text
+			wantFound: false,
+		},
+		{
+			name:      "unknown type",
+			oid:       1234,
+			wantFound: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := NewMapper(&mockQuerier{})
+			oid, found := m.ArrayElementOID(tc.oid)
+			require.Equal(t, tc.wantFound, found)
+			require.Equal(t, tc.wantOID, oid)
+		})
+	}
+}
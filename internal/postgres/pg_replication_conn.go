@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jackc/pglogrepl"
@@ -16,14 +17,18 @@ import (
 
 type ReplicationQuerier interface {
 	IdentifySystem(ctx context.Context) (IdentifySystemResult, error)
+	CreateReplicationSlot(ctx context.Context, cfg CreateReplicationSlotConfig) error
 	StartReplication(ctx context.Context, cfg ReplicationConfig) error
-	SendStandbyStatusUpdate(ctx context.Context, lsn uint64) error
+	SendStandbyStatusUpdate(ctx context.Context, status StandbyStatus) error
 	ReceiveMessage(ctx context.Context) (*ReplicationMessage, error)
 	Close(ctx context.Context) error
 }
 
 type ReplicationConn struct {
 	conn *pgconn.PgConn
+	// sendMutex serialises the standby status updates, which can be sent
+	// concurrently with the messages being received.
+	sendMutex sync.Mutex
 }
 
 type ReplicationConfig struct {
@@ -32,6 +37,30 @@ type ReplicationConfig struct {
 	PluginArguments []string
 }
 
+type CreateReplicationSlotConfig struct {
+	SlotName string
+	Plugin   string
+	// Temporary slots are dropped when the replication connection that
+	// created them is closed.
+	Temporary bool
+	// TwoPhase enables the decoding of prepared transactions. Requires
+	// postgres 15+.
+	TwoPhase bool
+	// Failover enables the synchronisation of the slot to the standbys.
+	// Requires postgres 17+.
+	Failover bool
+}
+
+// StandbyStatus is the replication progress reported to postgres.
+type StandbyStatus struct {
+	// WritePosition is the last WAL position received.
+	WritePosition uint64
+	// FlushPosition is the last WAL position durably processed. Postgres will
+	// not retain WAL older than this position for the replication slot. It's
+	// also used as the applied position.
+	FlushPosition uint64
+}
+
 type ReplicationMessage struct {
 	LSN            uint64
 	ServerTime     time.Time
@@ -53,6 +82,10 @@ func NewReplicationConn(ctx context.Context, url string) (*ReplicationConn, erro
 
 	configureTCPKeepalive(pgCfg)
 
+	if err := ConfigureAuth(ctx, &pgCfg.Config); err != nil {
+		return nil, err
+	}
+
 	conn, err := pgconn.ConnectConfig(context.Background(), &pgCfg.Config)
 	if err != nil {
 		return nil, fmt.Errorf("create postgres replication client: %w", MapError(err))
@@ -68,6 +101,14 @@ func (c *ReplicationConn) IdentifySystem(ctx context.Context) (IdentifySystemRes
 	return IdentifySystemResult(res), MapError(err)
 }
 
+// CreateReplicationSlot creates a logical replication slot using the
+// replication protocol, which is required for temporary slots to be bound to
+// this connection.
+func (c *ReplicationConn) CreateReplicationSlot(ctx context.Context, cfg CreateReplicationSlotConfig) error {
+	_, err := pglogrepl.ParseCreateReplicationSlot(c.conn.Exec(ctx, createReplicationSlotCommand(cfg)))
+	return MapError(err)
+}
+
 func (c *ReplicationConn) StartReplication(ctx context.Context, cfg ReplicationConfig) error {
 	return MapError(pglogrepl.StartReplication(
 		ctx,
@@ -77,11 +118,17 @@ func (c *ReplicationConn) StartReplication(ctx context.Context, cfg ReplicationC
 		pglogrepl.StartReplicationOptions{PluginArgs: cfg.PluginArguments}))
 }
 
-func (c *ReplicationConn) SendStandbyStatusUpdate(ctx context.Context, lsn uint64) error {
+func (c *ReplicationConn) SendStandbyStatusUpdate(ctx context.Context, status StandbyStatus) error {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
 	return MapError(pglogrepl.SendStandbyStatusUpdate(
 		ctx,
 		c.conn,
-		pglogrepl.StandbyStatusUpdate{WALWritePosition: pglogrepl.LSN(lsn)},
+		pglogrepl.StandbyStatusUpdate{
+			WALWritePosition: pglogrepl.LSN(status.WritePosition),
+			WALFlushPosition: pglogrepl.LSN(status.FlushPosition),
+			WALApplyPosition: pglogrepl.LSN(status.FlushPosition),
+		},
 	))
 }
 
@@ -130,6 +177,31 @@ func (c *ReplicationConn) Close(ctx context.Context) error {
 	return MapError(c.conn.Close(ctx))
 }
 
+// createReplicationSlotCommand builds the CREATE_REPLICATION_SLOT replication
+// command for the configuration on input. The legacy syntax is used unless
+// options only supported by the new syntax (postgres 15+) are enabled, to keep
+// compatibility with older postgres versions.
+func createReplicationSlotCommand(cfg CreateReplicationSlotConfig) string {
+	cmd := "CREATE_REPLICATION_SLOT " + cfg.SlotName
+	if cfg.Temporary {
+		cmd += " TEMPORARY"
+	}
+	cmd += " LOGICAL " + cfg.Plugin
+
+	if !cfg.TwoPhase && !cfg.Failover {
+		return cmd + " NOEXPORT_SNAPSHOT"
+	}
+
+	options := []string{"SNAPSHOT 'nothing'"}
+	if cfg.TwoPhase {
+		options = append(options, "TWO_PHASE true")
+	}
+	if cfg.Failover {
+		options = append(options, "FAILOVER true")
+	}
+	return cmd + " (" + strings.Join(options, ", ") + ")"
+}
+
 func DefaultReplicationSlotName(dbName string) string {
 	// sanitise the dbName before creating the replication slot name to ensure
 	// the name does not contain invalid characters.
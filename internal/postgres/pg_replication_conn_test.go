@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateReplicationSlotCommand(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  CreateReplicationSlotConfig
+
+		wantCmd string
+	}{
+		{
+			name: "default",
+			cfg:  CreateReplicationSlotConfig{SlotName: "test_slot", Plugin: "wal2json"},
+
+			wantCmd: "CREATE_REPLICATION_SLOT test_slot LOGICAL wal2json NOEXPORT_SNAPSHOT",
+		},
+		{
+			name: "temporary",
+			cfg:  CreateReplicationSlotConfig{SlotName: "test_slot", Plugin: "wal2json", Temporary: true},
+
+			wantCmd: "CREATE_REPLICATION_SLOT test_slot TEMPORARY LOGICAL wal2json NOEXPORT_SNAPSHOT",
+		},
+		{
+			name: "two phase",
+			cfg:  CreateReplicationSlotConfig{SlotName: "test_slot", Plugin: "wal2json", TwoPhase: true},
+
+			wantCmd: "CREATE_REPLICATION_SLOT test_slot LOGICAL wal2json (SNAPSHOT 'nothing', TWO_PHASE true)",
+		},
+		{
+			name: "temporary with two phase and failover",
+			cfg:  CreateReplicationSlotConfig{SlotName: "test_slot", Plugin: "wal2json", Temporary: true, TwoPhase: true, Failover: true},
+
+			wantCmd: "CREATE_REPLICATION_SLOT test_slot TEMPORARY LOGICAL wal2json (SNAPSHOT 'nothing', TWO_PHASE true, FAILOVER true)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.wantCmd, createReplicationSlotCommand(tc.cfg))
+		})
+	}
+}
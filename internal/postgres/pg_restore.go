@@ -63,11 +63,16 @@ func (opts PGRestoreOptions) toPSQLArgs() []string {
 // Func RunPGRestore runs pg_restore command with the given options and returns
 // the result.
 func RunPGRestore(ctx context.Context, opts PGRestoreOptions, dump []byte) (string, error) {
+	var err error
+	opts.ConnectionString, err = ResolveAuth(ctx, opts.ConnectionString)
+	if err != nil {
+		return "", err
+	}
+
 	var cmd *exec.Cmd
 	// if the database is being created, make sure the connection string
 	// does not include it so that pg_restore can create it.
 	if opts.Create {
-		var err error
 		opts.ConnectionString, err = removeDatabaseFromConnectionString(opts.ConnectionString)
 		if err != nil {
 			return "", err
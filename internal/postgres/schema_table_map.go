@@ -4,47 +4,71 @@ package postgres
 
 import (
 	"errors"
+	"fmt"
+	"path"
+	"regexp"
 	"strings"
+	"sync"
 )
 
+// SchemaTableMap indexes the tables by schema. Besides literal names, schema
+// and table names can be glob patterns (public.data_tenant_*), and entries
+// starting with "^" are regular expressions matched against the schema
+// qualified table name (^public\.data_tenant_\d+$). Regular expressions are
+// kept under the wildcard schema.
 type SchemaTableMap map[string]map[string]struct{}
 
 const (
 	PublicSchema = "public"
 
 	wildcard = "*"
+	// regexPrefix identifies the table entries that are regular expressions
+	regexPrefix = "^"
+	globChars   = "*?["
 )
 
-var ErrInvalidTableName = errors.New("invalid table name format")
+var (
+	ErrInvalidTableName    = errors.New("invalid table name format")
+	ErrInvalidTablePattern = errors.New("invalid table pattern")
+)
+
+// tableRegexps caches the compiled table regular expressions, since they're
+// evaluated for every event.
+var tableRegexps sync.Map
 
 func NewSchemaTableMap(tables []string) (SchemaTableMap, error) {
 	schemaTablesMap := make(SchemaTableMap, len(tables))
 	for _, table := range tables {
-		schemaName, tableName, err := parseTableName(table)
-		if err != nil {
+		if err := schemaTablesMap.Add(table); err != nil {
 			return nil, err
 		}
-		if _, found := schemaTablesMap[schemaName]; !found {
-			schemaTablesMap[schemaName] = make(map[string]struct{})
-		}
-		schemaTablesMap[schemaName][tableName] = struct{}{}
 	}
 	return schemaTablesMap, nil
 }
 
+// ContainsSchemaTable returns true if the schema table on input matches any of
+// the map entries, be it by name, wildcard or pattern.
 func (t SchemaTableMap) ContainsSchemaTable(schema, table string) bool {
 	if len(t) == 0 {
 		return false
 	}
 
-	tables := t.GetSchemaTables(schema)
-	if len(tables) == 0 {
-		return false
+	// literal names don't require evaluating the patterns
+	if _, found := t[schema][table]; found {
+		return true
 	}
 
-	_, found := tables[table]
-	_, wildcardFound := tables[wildcard]
-	return found || wildcardFound
+	for schemaPattern, tables := range t {
+		if !matchName(schemaPattern, schema) {
+			continue
+		}
+		for tablePattern := range tables {
+			if MatchTablePattern(tablePattern, schema, table) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (t SchemaTableMap) GetSchemaTables(schema string) map[string]struct{} {
@@ -61,11 +85,19 @@ func (t SchemaTableMap) GetSchemaTables(schema string) map[string]struct{} {
 	return tables
 }
 
+// Add adds the table on input to the map. An error is returned if the table
+// name is not valid, or if it's a pattern that doesn't compile.
 func (t SchemaTableMap) Add(table string) error {
 	schema, table, err := parseTableName(table)
 	if err != nil {
 		return err
 	}
+	if err := validateTablePattern(schema); err != nil {
+		return err
+	}
+	if err := validateTablePattern(table); err != nil {
+		return err
+	}
 	_, found := t[schema]
 	if !found {
 		t[schema] = map[string]struct{}{}
@@ -74,7 +106,92 @@ func (t SchemaTableMap) Add(table string) error {
 	return nil
 }
 
+// ValidateTablePatterns returns an error if any of the table patterns on input
+// is not valid. Literal table names are not validated.
+func ValidateTablePatterns(tables []string) error {
+	for _, table := range tables {
+		if !IsTablePattern(table) {
+			continue
+		}
+		if _, err := NewSchemaTableMap([]string{table}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsTableRegexp returns true if the table entry on input is a regular
+// expression matched against the schema qualified table name.
+func IsTableRegexp(name string) bool {
+	return strings.HasPrefix(name, regexPrefix)
+}
+
+// IsTablePattern returns true if the schema or table name on input is a glob
+// pattern or a regular expression, rather than a literal name.
+func IsTablePattern(name string) bool {
+	return IsTableRegexp(name) || strings.ContainsAny(name, globChars)
+}
+
+// MatchTablePattern returns true if the schema table on input matches the
+// table pattern. Regular expressions are matched against the schema qualified
+// table name, while names and globs are matched against the table name.
+// Invalid patterns never match.
+func MatchTablePattern(pattern, schema, table string) bool {
+	if IsTableRegexp(pattern) {
+		re, err := compileTableRegexp(pattern)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(schema + "." + table)
+	}
+	return matchName(pattern, table)
+}
+
+func matchName(pattern, name string) bool {
+	switch {
+	case pattern == wildcard, pattern == name:
+		return true
+	case strings.ContainsAny(pattern, globChars):
+		matched, err := path.Match(pattern, name)
+		return err == nil && matched
+	default:
+		return false
+	}
+}
+
+func validateTablePattern(pattern string) error {
+	switch {
+	case IsTableRegexp(pattern):
+		if _, err := compileTableRegexp(pattern); err != nil {
+			return fmt.Errorf("%w %q: %w", ErrInvalidTablePattern, pattern, err)
+		}
+	case strings.ContainsAny(pattern, globChars):
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w %q: %w", ErrInvalidTablePattern, pattern, err)
+		}
+	}
+	return nil
+}
+
+func compileTableRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, found := tableRegexps.Load(pattern); found {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	tableRegexps.Store(pattern, re)
+	return re, nil
+}
+
+// parseTableName returns the schema and table names of the table on input.
+// Regular expressions are returned with the wildcard schema, since they apply
+// to the schema qualified name.
 func parseTableName(qualifiedTableName string) (string, string, error) {
+	if IsTableRegexp(qualifiedTableName) {
+		return wildcard, qualifiedTableName, nil
+	}
 	parts := strings.Split(qualifiedTableName, ".")
 	switch len(parts) {
 	case 1:
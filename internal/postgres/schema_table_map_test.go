@@ -84,6 +84,61 @@ func TestSchemaTableMap_containsSchemaTable(t *testing.T) {
 			},
 			wantContains: true,
 		},
+		{
+			name:   "glob table pattern matches",
+			schema: "public",
+			table:  "data_tenant_12",
+			schemaMap: SchemaTableMap{
+				"public": {
+					"data_tenant_*": struct{}{},
+				},
+			},
+			wantContains: true,
+		},
+		{
+			name:   "glob table pattern does not match",
+			schema: "public",
+			table:  "tenants",
+			schemaMap: SchemaTableMap{
+				"public": {
+					"data_tenant_*": struct{}{},
+				},
+			},
+			wantContains: false,
+		},
+		{
+			name:   "glob schema pattern matches",
+			schema: "tenant_a",
+			table:  "users",
+			schemaMap: SchemaTableMap{
+				"tenant_?": {
+					"users": struct{}{},
+				},
+			},
+			wantContains: true,
+		},
+		{
+			name:   "regex matches qualified table name",
+			schema: "public",
+			table:  "data_tenant_12",
+			schemaMap: SchemaTableMap{
+				"*": {
+					`^public\.data_tenant_\d+$`: struct{}{},
+				},
+			},
+			wantContains: true,
+		},
+		{
+			name:   "regex does not match qualified table name",
+			schema: "private",
+			table:  "data_tenant_12",
+			schemaMap: SchemaTableMap{
+				"*": {
+					`^public\.data_tenant_\d+$`: struct{}{},
+				},
+			},
+			wantContains: false,
+		},
 		{
 			name:         "empty SchemaTableMap",
 			schema:       "public",
@@ -102,3 +157,57 @@ func TestSchemaTableMap_containsSchemaTable(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSchemaTableMap(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		tables []string
+
+		wantMap SchemaTableMap
+		wantErr error
+	}{
+		{
+			name:   "ok - names, globs and regular expressions",
+			tables: []string{"users", "private.orders", "public.data_tenant_*", `^tenant_\d+\.events$`},
+			wantMap: SchemaTableMap{
+				"public": {
+					"users":         struct{}{},
+					"data_tenant_*": struct{}{},
+				},
+				"private": {
+					"orders": struct{}{},
+				},
+				"*": {
+					`^tenant_\d+\.events$`: struct{}{},
+				},
+			},
+		},
+		{
+			name:    "error - invalid table name",
+			tables:  []string{"a.b.c"},
+			wantErr: ErrInvalidTableName,
+		},
+		{
+			name:    "error - invalid glob",
+			tables:  []string{"public.data_[tenant"},
+			wantErr: ErrInvalidTablePattern,
+		},
+		{
+			name:    "error - invalid regular expression",
+			tables:  []string{`^public\.data_(tenant$`},
+			wantErr: ErrInvalidTablePattern,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			schemaTableMap, err := NewSchemaTableMap(tc.tables)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantMap, schemaTableMap)
+		})
+	}
+}
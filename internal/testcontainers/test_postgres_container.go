@@ -18,6 +18,8 @@ type PostgresImage string
 
 const (
 	Postgres14 PostgresImage = "debezium/postgres:14-alpine"
+	Postgres15 PostgresImage = "debezium/postgres:15-alpine"
+	Postgres16 PostgresImage = "debezium/postgres:16-alpine"
 	Postgres17 PostgresImage = "debezium/postgres:17-alpine"
 )
 
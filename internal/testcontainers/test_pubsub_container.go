@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package testcontainers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	pubsubEmulatorImage = "gcr.io/google.com/cloudsdktool/google-cloud-cli:emulators"
+	pubsubEmulatorPort  = "8085/tcp"
+)
+
+// SetupPubSubEmulatorContainer starts a Pub/Sub emulator for the project on
+// input, and sets the host on input to the emulator host:port.
+func SetupPubSubEmulatorContainer(ctx context.Context, host *string, projectID string) (cleanup, error) {
+	ctr, err := testcontainers.Run(ctx, pubsubEmulatorImage,
+		testcontainers.WithExposedPorts(pubsubEmulatorPort),
+		testcontainers.WithCmd("gcloud", "beta", "emulators", "pubsub", "start",
+			"--host-port=0.0.0.0:8085", "--project="+projectID),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("Server started").
+				WithOccurrence(1).
+				WithStartupTimeout(60*time.Second),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start pubsub emulator container: %w", err)
+	}
+
+	*host, err = ctr.PortEndpoint(ctx, pubsubEmulatorPort, "")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving host for pubsub emulator container: %w", err)
+	}
+
+	return func() error {
+		return ctr.Terminate(ctx)
+	}, nil
+}
@@ -6,6 +6,9 @@ import (
 	"os"
 
 	"github.com/xataio/pgstream/cmd"
+
+	// registers the snowflake database/sql driver used by the snowflake sink
+	_ "github.com/snowflakedb/gosnowflake"
 )
 
 func main() {
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pgaudit
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+)
+
+type Config struct {
+	// LogFile is the path of the postgres csvlog file the pg_audit entries
+	// are tailed from. Requires log_destination to include csvlog.
+	LogFile string
+	// Table is the schema qualified table the pg_audit entries are polled
+	// from, with the postgres csvlog columns (i.e. loaded with file_fdw).
+	// Only one of the log file or the table can be configured.
+	Table string
+	// URL of the postgres database containing the log table. Defaults to the
+	// source postgres URL.
+	URL string
+	// PollInterval is the interval at which new log entries are read.
+	// Defaults to 1s.
+	PollInterval time.Duration
+	// MatchTimeout is the max time an event waits for the audit entries of
+	// its transaction to be read, when they're not available yet. The wait
+	// only happens once per transaction. Defaults to 0, in which case the
+	// events are not enriched if their audit entries haven't been read by the
+	// time they're processed.
+	MatchTimeout time.Duration
+	// MaxEntries is the max number of transactions kept in memory to be
+	// matched with the wal events. The oldest ones are evicted first.
+	// Defaults to 100000.
+	MaxEntries int
+	// FromStart reads the log file from the beginning instead of tailing
+	// only the new entries. It doesn't apply to the log table, which is
+	// always read from the beginning.
+	FromStart bool
+}
+
+const (
+	defaultPollInterval = time.Second
+	defaultMaxEntries   = 100000
+)
+
+var (
+	errMissingSource   = errors.New("pg_audit correlator requires a log file or a log table")
+	errMultipleSources = errors.New("pg_audit correlator supports only one of log file or log table")
+	errMissingURL      = errors.New("pg_audit correlator log table requires a postgres URL")
+)
+
+func (c *Config) validate() error {
+	switch {
+	case c.LogFile == "" && c.Table == "":
+		return errMissingSource
+	case c.LogFile != "" && c.Table != "":
+		return errMultipleSources
+	case c.Table != "" && c.URL == "":
+		return errMissingURL
+	}
+	return nil
+}
+
+func (c *Config) pollInterval() time.Duration {
+	if c.PollInterval > 0 {
+		return c.PollInterval
+	}
+	return defaultPollInterval
+}
+
+func (c *Config) maxEntries() int {
+	if c.MaxEntries > 0 {
+		return c.MaxEntries
+	}
+	return defaultMaxEntries
+}
+
+// quotedTable returns the quoted schema qualified log table.
+func (c *Config) quotedTable() (string, error) {
+	qn, err := pglib.NewQualifiedName(c.Table)
+	if err != nil {
+		return "", fmt.Errorf("pg_audit log table %q: %w", c.Table, err)
+	}
+	schema := qn.Schema()
+	if schema == "" {
+		schema = "public"
+	}
+	return pglib.QuoteQualifiedIdentifier(schema, qn.Name()), nil
+}
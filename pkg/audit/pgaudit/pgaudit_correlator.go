@@ -0,0 +1,298 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pgaudit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// PGAuditCorrelator is a decorator around a wal processor that enriches the
+// wal events with the audit context of their transaction, as logged by the
+// pg_audit extension. The pg_audit entries are read from the postgres csvlog
+// file or a table on the configured interval, and matched to the events by
+// transaction id, which requires the transaction records to be included in
+// the replication. Entries logged before the transaction id was assigned are
+// matched through the later entries of the same virtual transaction, so the
+// single statement transactions might not be matched if pg_audit logs them
+// before the first write.
+type PGAuditCorrelator struct {
+	logger       loglib.Logger
+	processor    processor.Processor
+	source       logSource
+	pollInterval time.Duration
+	matchTimeout time.Duration
+
+	// mutex protects the audit cache and the read channel, which are updated
+	// on every read
+	mutex sync.Mutex
+	cache *auditCache
+	// readChan is closed and replaced on every read, to wake up the events
+	// waiting for their audit entries
+	readChan chan struct{}
+	// lastWaitedXID is the last transaction the events waited for, to only
+	// wait once per transaction
+	lastWaitedXID uint32
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type Option func(c *PGAuditCorrelator)
+
+// New will return a pg_audit correlator wrapper around the processor on
+// input. It performs an initial read of the configured log source, and keeps
+// reading it on the configured interval until closed.
+func New(ctx context.Context, cfg *Config, p processor.Processor, opts ...Option) (*PGAuditCorrelator, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	source, err := newLogSource(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := newCorrelator(cfg, p, source)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if err := c.read(ctx); err != nil {
+		source.close()
+		return nil, err
+	}
+
+	readCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.readLoop(readCtx)
+	}()
+
+	return c, nil
+}
+
+func newCorrelator(cfg *Config, p processor.Processor, source logSource) *PGAuditCorrelator {
+	return &PGAuditCorrelator{
+		logger:       loglib.NewNoopLogger(),
+		processor:    p,
+		source:       source,
+		pollInterval: cfg.pollInterval(),
+		matchTimeout: cfg.MatchTimeout,
+		cache:        newAuditCache(cfg.maxEntries()),
+		readChan:     make(chan struct{}),
+	}
+}
+
+func newLogSource(ctx context.Context, cfg *Config) (logSource, error) {
+	if cfg.LogFile != "" {
+		return newFileSource(cfg.LogFile, cfg.FromStart)
+	}
+
+	table, err := cfg.quotedTable()
+	if err != nil {
+		return nil, err
+	}
+	querier, err := pglib.NewConnPool(ctx, cfg.URL, pglib.WithMaxConnections(1))
+	if err != nil {
+		return nil, fmt.Errorf("creating pg_audit log table connection: %w", err)
+	}
+	return &tableSource{querier: querier, table: table}, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(c *PGAuditCorrelator) {
+		c.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "pgaudit_correlator",
+		})
+	}
+}
+
+// ProcessWALEvent adds the audit context of the event transaction to its
+// metadata, if found, and sends it to the wrapped processor.
+func (c *PGAuditCorrelator) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	if event.Data != nil && event.Data.XID != 0 {
+		if info := c.match(ctx, event.Data); info != nil {
+			event.Data.Metadata.Audit = info
+		}
+	}
+	return c.processor.ProcessWALEvent(ctx, event)
+}
+
+func (c *PGAuditCorrelator) Name() string {
+	return c.processor.Name()
+}
+
+// Close stops reading the log source and closes the wrapped processor.
+func (c *PGAuditCorrelator) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+	return errors.Join(c.source.close(), c.processor.Close())
+}
+
+func (c *PGAuditCorrelator) Ping(ctx context.Context) error {
+	return c.processor.Ping(ctx)
+}
+
+// match returns the audit context of the data on input. If it's not found,
+// it waits up to the match timeout for the following reads, unless it already
+// waited for the data transaction.
+func (c *PGAuditCorrelator) match(ctx context.Context, data *wal.Data) *wal.AuditInfo {
+	object := data.Schema + "." + data.Table
+
+	c.mutex.Lock()
+	info := c.cache.get(data.XID, object)
+	readChan := c.readChan
+	wait := info == nil && c.matchTimeout > 0 && data.XID != c.lastWaitedXID
+	if wait {
+		c.lastWaitedXID = data.XID
+	}
+	c.mutex.Unlock()
+
+	if !wait {
+		return info
+	}
+
+	timer := time.NewTimer(c.matchTimeout)
+	defer timer.Stop()
+	for info == nil {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+			return nil
+		case <-readChan:
+		}
+		c.mutex.Lock()
+		info = c.cache.get(data.XID, object)
+		readChan = c.readChan
+		c.mutex.Unlock()
+	}
+	return info
+}
+
+func (c *PGAuditCorrelator) readLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.read(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				c.logger.Error(err, "reading pg_audit log entries")
+			}
+		}
+	}
+}
+
+// read adds the new entries of the log source to the audit cache, and wakes
+// up the events waiting for them.
+func (c *PGAuditCorrelator) read(ctx context.Context) error {
+	entries, err := c.source.readEntries(ctx)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, entry := range entries {
+		c.cache.add(entry)
+	}
+	close(c.readChan)
+	c.readChan = make(chan struct{})
+	return err
+}
+
+// auditCache keeps the audit context of the most recent transactions, by
+// virtual transaction id.
+type auditCache struct {
+	maxEntries int
+	txs        map[string]*txAudit
+	xids       map[uint32]string
+	// order is the virtual transaction ids in the order they were added, to
+	// evict the oldest ones first
+	order []string
+}
+
+type txAudit struct {
+	xid uint32
+	// info is the audit context of the first entry of the transaction
+	info *wal.AuditInfo
+	// objects is the audit context of the first entry of each object of the
+	// transaction
+	objects map[string]*wal.AuditInfo
+}
+
+func newAuditCache(maxEntries int) *auditCache {
+	return &auditCache{
+		maxEntries: maxEntries,
+		txs:        map[string]*txAudit{},
+		xids:       map[uint32]string{},
+	}
+}
+
+func (c *auditCache) add(entry *logEntry) {
+	tx, found := c.txs[entry.vxid]
+	if !found {
+		tx = &txAudit{objects: map[string]*wal.AuditInfo{}}
+		c.txs[entry.vxid] = tx
+		c.order = append(c.order, entry.vxid)
+		c.evict()
+	}
+
+	info := &wal.AuditInfo{
+		User:        entry.user,
+		Application: entry.application,
+		Command:     entry.command,
+	}
+	if tx.info == nil {
+		tx.info = info
+	}
+	if _, found := tx.objects[entry.object]; entry.object != "" && !found {
+		tx.objects[entry.object] = info
+	}
+	if entry.xid != 0 && tx.xid == 0 {
+		tx.xid = entry.xid
+		c.xids[entry.xid] = entry.vxid
+	}
+}
+
+// get returns a copy of the audit context of the transaction object on
+// input, or of the transaction if there's none for the object.
+func (c *auditCache) get(xid uint32, object string) *wal.AuditInfo {
+	vxid, found := c.xids[xid]
+	if !found {
+		return nil
+	}
+	tx := c.txs[vxid]
+	info, found := tx.objects[object]
+	if !found {
+		info = tx.info
+	}
+	infoCopy := *info
+	return &infoCopy
+}
+
+func (c *auditCache) evict() {
+	for len(c.order) > c.maxEntries {
+		vxid := c.order[0]
+		c.order = c.order[1:]
+		// the transaction ids wrap around, so the mapping might belong to a
+		// newer transaction
+		if tx, found := c.txs[vxid]; found && c.xids[tx.xid] == vxid {
+			delete(c.xids, tx.xid)
+		}
+		delete(c.txs, vxid)
+	}
+}
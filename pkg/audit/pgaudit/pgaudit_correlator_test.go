@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pgaudit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+type mockSource struct {
+	mutex   sync.Mutex
+	entries []*logEntry
+	err     error
+	closed  bool
+}
+
+func (m *mockSource) readEntries(ctx context.Context) ([]*logEntry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entries := m.entries
+	m.entries = nil
+	return entries, m.err
+}
+
+func (m *mockSource) close() error {
+	m.closed = true
+	return nil
+}
+
+func (m *mockSource) addEntries(entries ...*logEntry) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.entries = append(m.entries, entries...)
+}
+
+func TestPGAuditCorrelator_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	entries := []*logEntry{
+		// logged before the transaction id was assigned
+		{user: "alice", application: "psql", vxid: "3/12", command: "INSERT", object: "public.users"},
+		{user: "alice", application: "psql", vxid: "3/12", xid: 742, command: "UPDATE", object: "public.orders"},
+		{user: "bob", application: "app", vxid: "4/1", xid: 743, command: "DELETE"},
+	}
+
+	tests := []struct {
+		name  string
+		event *wal.Event
+
+		wantAudit *wal.AuditInfo
+	}{
+		{
+			name:  "ok - matched by object",
+			event: &wal.Event{Data: &wal.Data{XID: 742, Schema: "public", Table: "users"}},
+
+			wantAudit: &wal.AuditInfo{User: "alice", Application: "psql", Command: "INSERT"},
+		},
+		{
+			name:  "ok - matched by object logged with the transaction id",
+			event: &wal.Event{Data: &wal.Data{XID: 742, Schema: "public", Table: "orders"}},
+
+			wantAudit: &wal.AuditInfo{User: "alice", Application: "psql", Command: "UPDATE"},
+		},
+		{
+			name:  "ok - matched by transaction",
+			event: &wal.Event{Data: &wal.Data{XID: 743, Schema: "public", Table: "users"}},
+
+			wantAudit: &wal.AuditInfo{User: "bob", Application: "app", Command: "DELETE"},
+		},
+		{
+			name:  "ok - unknown transaction",
+			event: &wal.Event{Data: &wal.Data{XID: 1, Schema: "public", Table: "users"}},
+
+			wantAudit: nil,
+		},
+		{
+			name:  "ok - no transaction id",
+			event: &wal.Event{Data: &wal.Data{Schema: "public", Table: "users"}},
+
+			wantAudit: nil,
+		},
+		{
+			name:  "ok - keep alive event",
+			event: &wal.Event{CommitPosition: "1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var processed *wal.Event
+			c := newCorrelator(&Config{}, &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					processed = walEvent
+					return nil
+				},
+			}, &mockSource{entries: entries})
+			require.NoError(t, c.read(context.Background()))
+
+			require.NoError(t, c.ProcessWALEvent(context.Background(), tc.event))
+			require.Equal(t, tc.event, processed)
+			if tc.event.Data != nil {
+				require.Equal(t, tc.wantAudit, tc.event.Data.Metadata.Audit)
+			}
+		})
+	}
+}
+
+func TestPGAuditCorrelator_match(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok - waits for the following reads", func(t *testing.T) {
+		t.Parallel()
+
+		source := &mockSource{}
+		c := newCorrelator(&Config{MatchTimeout: time.Minute}, &mocks.Processor{}, source)
+
+		done := make(chan *wal.AuditInfo, 1)
+		go func() {
+			done <- c.match(context.Background(), &wal.Data{XID: 742, Schema: "public", Table: "users"})
+		}()
+
+		// reads without the transaction entries keep it waiting
+		require.NoError(t, c.read(context.Background()))
+		source.addEntries(&logEntry{user: "alice", vxid: "3/12", xid: 742, command: "INSERT"})
+		require.Eventually(t, func() bool {
+			require.NoError(t, c.read(context.Background()))
+			return len(done) == 1
+		}, time.Second, time.Millisecond)
+		require.Equal(t, &wal.AuditInfo{User: "alice", Command: "INSERT"}, <-done)
+	})
+
+	t.Run("ok - only waits once per transaction", func(t *testing.T) {
+		t.Parallel()
+
+		c := newCorrelator(&Config{MatchTimeout: 10 * time.Millisecond}, &mocks.Processor{}, &mockSource{})
+
+		data := &wal.Data{XID: 742, Schema: "public", Table: "users"}
+		start := time.Now()
+		require.Nil(t, c.match(context.Background(), data))
+		require.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+
+		start = time.Now()
+		require.Nil(t, c.match(context.Background(), data))
+		require.Less(t, time.Since(start), 10*time.Millisecond)
+	})
+}
+
+func TestPGAuditCorrelator_Close(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	source := &mockSource{}
+	c := newCorrelator(&Config{}, &mocks.Processor{
+		CloseFn: func() error { return errTest },
+	}, source)
+
+	require.ErrorIs(t, c.Close(), errTest)
+	require.True(t, source.closed)
+}
+
+func TestAuditCache(t *testing.T) {
+	t.Parallel()
+
+	cache := newAuditCache(2)
+	cache.add(&logEntry{user: "alice", vxid: "3/1", xid: 1})
+	cache.add(&logEntry{user: "bob", vxid: "3/2", xid: 2})
+	cache.add(&logEntry{user: "carol", vxid: "3/3", xid: 3})
+
+	// the oldest transaction is evicted first
+	require.Nil(t, cache.get(1, "public.users"))
+	require.Equal(t, &wal.AuditInfo{User: "bob"}, cache.get(2, "public.users"))
+	require.Equal(t, &wal.AuditInfo{User: "carol"}, cache.get(3, "public.users"))
+
+	// the transaction id mapping of a newer transaction is kept when the
+	// wrapped around transaction id is evicted
+	cache.add(&logEntry{user: "dave", vxid: "3/4", xid: 2})
+	cache.add(&logEntry{user: "erin", vxid: "3/5", xid: 5})
+	require.Equal(t, &wal.AuditInfo{User: "dave"}, cache.get(2, "public.users"))
+	require.Len(t, cache.txs, 2)
+}
@@ -0,0 +1,80 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pgaudit
+
+import (
+	"encoding/csv"
+	"strconv"
+	"strings"
+)
+
+// logEntry is a pg_audit entry of the postgres logs.
+type logEntry struct {
+	user        string
+	application string
+	// vxid is the virtual transaction id, always set. It's used to match the
+	// entries logged before the transaction id was assigned.
+	vxid string
+	// xid is the transaction id, 0 when it had not been assigned yet
+	xid     uint32
+	command string
+	// object is the schema qualified name of the object the statement
+	// applied to, if any
+	object string
+}
+
+// postgres csvlog column positions
+const (
+	csvLogUserName             = 1
+	csvLogVirtualTransactionID = 9
+	csvLogTransactionID        = 10
+	csvLogMessage              = 13
+	csvLogApplicationName      = 22
+)
+
+const auditMessagePrefix = "AUDIT: "
+
+// pg_audit message field positions
+const (
+	auditCommand    = 4
+	auditObjectName = 6
+)
+
+// parseCSVLogRecord returns the pg_audit entry of the postgres csvlog record
+// on input, or false if the record is not a pg_audit entry.
+func parseCSVLogRecord(record []string) (*logEntry, bool) {
+	if len(record) <= csvLogApplicationName {
+		return nil, false
+	}
+	xid, err := strconv.ParseUint(record[csvLogTransactionID], 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	return parseAuditMessage(record[csvLogMessage], record[csvLogUserName], record[csvLogApplicationName], record[csvLogVirtualTransactionID], uint32(xid))
+}
+
+// parseAuditMessage parses the pg_audit log message on input, with the format
+// AUDIT: AUDIT_TYPE,STATEMENT_ID,SUBSTATEMENT_ID,CLASS,COMMAND,OBJECT_TYPE,OBJECT_NAME,STATEMENT,PARAMETER
+func parseAuditMessage(message, user, application, vxid string, xid uint32) (*logEntry, bool) {
+	fields, found := strings.CutPrefix(message, auditMessagePrefix)
+	if !found {
+		return nil, false
+	}
+
+	r := csv.NewReader(strings.NewReader(fields))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	auditFields, err := r.Read()
+	if err != nil || len(auditFields) <= auditObjectName {
+		return nil, false
+	}
+
+	return &logEntry{
+		user:        user,
+		application: application,
+		vxid:        vxid,
+		xid:         xid,
+		command:     auditFields[auditCommand],
+		object:      strings.ReplaceAll(auditFields[auditObjectName], `"`, ""),
+	}, true
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pgaudit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCSVLogRecord(t *testing.T) {
+	t.Parallel()
+
+	newRecord := func(xid, message string) []string {
+		record := make([]string, 26)
+		record[csvLogUserName] = "alice"
+		record[csvLogVirtualTransactionID] = "3/12"
+		record[csvLogTransactionID] = xid
+		record[csvLogMessage] = message
+		record[csvLogApplicationName] = "psql"
+		return record
+	}
+
+	tests := []struct {
+		name   string
+		record []string
+
+		wantEntry *logEntry
+		wantOK    bool
+	}{
+		{
+			name:   "ok - session audit entry",
+			record: newRecord("742", `AUDIT: SESSION,1,1,WRITE,INSERT,TABLE,public.users,"insert into users values (1, 'a,b')",<not logged>`),
+
+			wantEntry: &logEntry{
+				user:        "alice",
+				application: "psql",
+				vxid:        "3/12",
+				xid:         742,
+				command:     "INSERT",
+				object:      "public.users",
+			},
+			wantOK: true,
+		},
+		{
+			name:   "ok - entry without transaction id or object",
+			record: newRecord("0", `AUDIT: SESSION,2,1,DDL,CREATE TABLE,,,create table t(id int),<not logged>`),
+
+			wantEntry: &logEntry{
+				user:        "alice",
+				application: "psql",
+				vxid:        "3/12",
+				command:     "CREATE TABLE",
+			},
+			wantOK: true,
+		},
+		{
+			name:   "ok - quoted object name",
+			record: newRecord("742", `AUDIT: OBJECT,1,1,WRITE,UPDATE,TABLE,"public"."Users",update "Users" set a = 1,<not logged>`),
+
+			wantEntry: &logEntry{
+				user:        "alice",
+				application: "psql",
+				vxid:        "3/12",
+				xid:         742,
+				command:     "UPDATE",
+				object:      "public.Users",
+			},
+			wantOK: true,
+		},
+		{
+			name:   "not an audit entry",
+			record: newRecord("742", "checkpoint starting: time"),
+
+			wantOK: false,
+		},
+		{
+			name:   "invalid transaction id",
+			record: newRecord("invalid", `AUDIT: SESSION,1,1,WRITE,INSERT,TABLE,public.users,insert,<not logged>`),
+
+			wantOK: false,
+		},
+		{
+			name:   "missing audit fields",
+			record: newRecord("742", `AUDIT: SESSION,1,1`),
+
+			wantOK: false,
+		},
+		{
+			name:   "missing csvlog columns",
+			record: []string{"2024-01-01", "alice"},
+
+			wantOK: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			entry, ok := parseCSVLogRecord(tc.record)
+			require.Equal(t, tc.wantOK, ok)
+			require.Equal(t, tc.wantEntry, entry)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pgaudit
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+)
+
+// logSource returns the pg_audit entries logged since the previous read.
+type logSource interface {
+	readEntries(ctx context.Context) ([]*logEntry, error)
+	close() error
+}
+
+// fileSource tails a postgres csvlog file. The incomplete records at the end
+// of the file are kept until the rest of the record is written.
+type fileSource struct {
+	path    string
+	offset  int64
+	pending []byte
+}
+
+// maxPendingBytes is the max size of the log data that couldn't be parsed
+// into complete records, past which it's discarded as malformed.
+const maxPendingBytes = 1 << 20
+
+var errMalformedLogData = errors.New("discarding malformed pg_audit log data")
+
+func newFileSource(path string, fromStart bool) (*fileSource, error) {
+	s := &fileSource{path: path}
+	if fromStart {
+		return s, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("reading pg_audit log file: %w", err)
+	}
+	s.offset = info.Size()
+	return s, nil
+}
+
+func (s *fileSource) readEntries(ctx context.Context) ([]*logEntry, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("opening pg_audit log file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("reading pg_audit log file: %w", err)
+	}
+	// the log file has been truncated or rotated in place
+	if info.Size() < s.offset {
+		s.offset = 0
+		s.pending = nil
+	}
+
+	if _, err := f.Seek(s.offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking pg_audit log file: %w", err)
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading pg_audit log file: %w", err)
+	}
+	s.offset += int64(len(data))
+	s.pending = append(s.pending, data...)
+
+	entries, consumed := parseCSVLog(s.pending)
+	s.pending = s.pending[consumed:]
+	if len(s.pending) > maxPendingBytes {
+		s.pending = nil
+		return entries, errMalformedLogData
+	}
+	return entries, nil
+}
+
+func (s *fileSource) close() error {
+	return nil
+}
+
+// parseCSVLog returns the pg_audit entries of the complete csvlog records of
+// the data on input, and the number of bytes consumed.
+func parseCSVLog(data []byte) ([]*logEntry, int) {
+	// the last line might not have been completely written yet
+	end := bytes.LastIndexByte(data, '\n') + 1
+	r := csv.NewReader(bytes.NewReader(data[:end]))
+	r.FieldsPerRecord = -1
+
+	entries := []*logEntry{}
+	consumed := 0
+	for {
+		record, err := r.Read()
+		if err != nil {
+			// records with multiline fields might not be complete until
+			// the following lines are written
+			if errors.Is(err, io.EOF) {
+				consumed = end
+			}
+			return entries, consumed
+		}
+		consumed = int(r.InputOffset())
+		if entry, ok := parseCSVLogRecord(record); ok {
+			entries = append(entries, entry)
+		}
+	}
+}
+
+// tableSource polls a table with the postgres csvlog columns.
+type tableSource struct {
+	querier     pglib.Querier
+	table       string
+	lastLogTime time.Time
+}
+
+const auditEntriesQuery = `SELECT log_time, COALESCE(user_name, ''), COALESCE(application_name, ''), COALESCE(virtual_transaction_id, ''), COALESCE(transaction_id, 0), message
+FROM %s
+WHERE log_time > $1 AND message LIKE 'AUDIT: %%'
+ORDER BY log_time`
+
+func (s *tableSource) readEntries(ctx context.Context) ([]*logEntry, error) {
+	rows, err := s.querier.Query(ctx, fmt.Sprintf(auditEntriesQuery, s.table), s.lastLogTime)
+	if err != nil {
+		return nil, fmt.Errorf("querying pg_audit log table: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*logEntry{}
+	lastLogTime := s.lastLogTime
+	for rows.Next() {
+		var logTime time.Time
+		var user, application, vxid, message string
+		var xid int64
+		if err := rows.Scan(&logTime, &user, &application, &vxid, &xid, &message); err != nil {
+			return nil, fmt.Errorf("scanning pg_audit log table row: %w", err)
+		}
+		lastLogTime = logTime
+		if entry, ok := parseAuditMessage(message, user, application, vxid, uint32(xid)); ok {
+			entries = append(entries, entry)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading pg_audit log table rows: %w", err)
+	}
+	s.lastLogTime = lastLogTime
+	return entries, nil
+}
+
+func (s *tableSource) close() error {
+	return s.querier.Close(context.Background())
+}
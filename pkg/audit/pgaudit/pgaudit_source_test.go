@@ -0,0 +1,181 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pgaudit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pglibmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+)
+
+func csvLogLine(xid, message string) string {
+	return `2024-01-01 00:00:00.000 UTC,"alice","db",123,"[local]",65a1.7b,1,"INSERT",2024-01-01 00:00:00 UTC,3/12,` + xid +
+		`,LOG,00000,"` + strings.ReplaceAll(message, `"`, `""`) + `",,,,,,,,,"psql","client backend",,0` + "\n"
+}
+
+func TestFileSource_readEntries(t *testing.T) {
+	t.Parallel()
+
+	auditMsg := `AUDIT: SESSION,1,1,WRITE,INSERT,TABLE,public.users,"insert into users
+values (1)",<not logged>`
+	wantEntry := &logEntry{
+		user:        "alice",
+		application: "psql",
+		vxid:        "3/12",
+		xid:         742,
+		command:     "INSERT",
+		object:      "public.users",
+	}
+
+	t.Run("ok - tails the log file", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "postgresql.csv")
+		require.NoError(t, os.WriteFile(path, []byte(csvLogLine("741", auditMsg)), 0o644))
+
+		s, err := newFileSource(path, false)
+		require.NoError(t, err)
+
+		// existing entries are skipped
+		entries, err := s.readEntries(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, entries)
+
+		line := csvLogLine("742", auditMsg)
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+		defer f.Close()
+
+		// the incomplete record is kept until it's fully written
+		_, err = f.WriteString(line[:len(line)/2])
+		require.NoError(t, err)
+		entries, err = s.readEntries(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, entries)
+
+		_, err = f.WriteString(line[len(line)/2:] + csvLogLine("742", "checkpoint starting: time"))
+		require.NoError(t, err)
+		entries, err = s.readEntries(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []*logEntry{wantEntry}, entries)
+	})
+
+	t.Run("ok - from start and truncated file", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "postgresql.csv")
+		require.NoError(t, os.WriteFile(path, []byte(csvLogLine("742", auditMsg)+csvLogLine("742", auditMsg)), 0o644))
+
+		s, err := newFileSource(path, true)
+		require.NoError(t, err)
+		entries, err := s.readEntries(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []*logEntry{wantEntry, wantEntry}, entries)
+
+		require.NoError(t, os.WriteFile(path, []byte(csvLogLine("742", auditMsg)), 0o644))
+		entries, err = s.readEntries(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []*logEntry{wantEntry}, entries)
+	})
+
+	t.Run("ok - missing log file", func(t *testing.T) {
+		t.Parallel()
+
+		s, err := newFileSource(filepath.Join(t.TempDir(), "postgresql.csv"), false)
+		require.NoError(t, err)
+		entries, err := s.readEntries(context.Background())
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+}
+
+func TestTableSource_readEntries(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	logTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		querier *pglibmocks.Querier
+
+		wantEntries     []*logEntry
+		wantLastLogTime time.Time
+		wantErr         error
+	}{
+		{
+			name: "ok",
+			querier: &pglibmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					require.Contains(t, query, `FROM "audit"."postgres_log"`)
+					require.Equal(t, []any{time.Time{}}, args)
+					return &pglibmocks.Rows{
+						NextFn: func(i uint) bool { return i <= 2 },
+						ScanFn: func(i uint, dest ...any) error {
+							require.Len(t, dest, 6)
+							*dest[0].(*time.Time) = logTime.Add(time.Duration(i) * time.Second)
+							*dest[1].(*string) = "alice"
+							*dest[2].(*string) = "psql"
+							*dest[3].(*string) = "3/12"
+							*dest[4].(*int64) = 742
+							*dest[5].(*string) = "AUDIT: SESSION,1,1,WRITE,DELETE,TABLE,public.users,delete from users,<not logged>"
+							if i == 2 {
+								*dest[5].(*string) = "AUDIT: invalid"
+							}
+							return nil
+						},
+						ErrFn: func() error { return nil },
+					}, nil
+				},
+			},
+
+			wantEntries: []*logEntry{
+				{user: "alice", application: "psql", vxid: "3/12", xid: 742, command: "DELETE", object: "public.users"},
+			},
+			wantLastLogTime: logTime.Add(2 * time.Second),
+		},
+		{
+			name: "error - querying",
+			querier: &pglibmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					return nil, errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+		{
+			name: "error - scanning",
+			querier: &pglibmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					return &pglibmocks.Rows{
+						NextFn: func(i uint) bool { return i == 1 },
+						ScanFn: func(i uint, dest ...any) error { return errTest },
+					}, nil
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &tableSource{querier: tc.querier, table: `"audit"."postgres_log"`}
+			entries, err := s.readEntries(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantEntries, entries)
+			require.Equal(t, tc.wantLastLogTime, s.lastLogTime)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package debug
+
+type Config struct {
+	// ListenAddress is the host:port address the debug HTTP server listens
+	// on. It needs to be different from the health and metrics addresses, and
+	// should not be publicly reachable. Defaults to localhost:6060.
+	ListenAddress string
+}
+
+const (
+	defaultListenAddress = "localhost:6060"
+
+	pprofPath   = "/debug/pprof/"
+	varsPath    = "/debug/vars"
+	runtimePath = "/debug/runtime"
+	queuesPath  = "/debug/queues"
+)
+
+func (c *Config) listenAddress() string {
+	if c.ListenAddress != "" {
+		return c.ListenAddress
+	}
+	return defaultListenAddress
+}
@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package debug
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+
+	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+// Server exposes runtime diagnostics of the process over HTTP, to help
+// troubleshoot its memory and CPU usage without rebuilding it:
+//   - /debug/pprof/ serves the net/http/pprof profiles.
+//   - /debug/vars serves the expvar variables, including the memory stats.
+//   - /debug/runtime returns the goroutine, heap and GC stats in JSON format.
+//   - /debug/queues returns the queue depth and the size of the batches being
+//     built and sent by the batch sender of every processor in JSON format.
+type Server struct {
+	server   *http.Server
+	listener net.Listener
+	serveErr chan error
+}
+
+// RuntimeStats is a snapshot of the Go runtime stats of the process.
+type RuntimeStats struct {
+	Goroutines int       `json:"goroutines"`
+	Heap       HeapStats `json:"heap"`
+	GC         GCStats   `json:"gc"`
+}
+
+type HeapStats struct {
+	AllocBytes    uint64 `json:"alloc_bytes"`
+	InUseBytes    uint64 `json:"in_use_bytes"`
+	IdleBytes     uint64 `json:"idle_bytes"`
+	ReleasedBytes uint64 `json:"released_bytes"`
+	SysBytes      uint64 `json:"sys_bytes"`
+	Objects       uint64 `json:"objects"`
+}
+
+type GCStats struct {
+	Cycles          uint32    `json:"cycles"`
+	NextTargetBytes uint64    `json:"next_target_bytes"`
+	LastRun         time.Time `json:"last_run"`
+	PauseTotalNs    uint64    `json:"pause_total_ns"`
+	CPUFraction     float64   `json:"cpu_fraction"`
+}
+
+// QueueStats is a snapshot of the internal queues of the process.
+type QueueStats struct {
+	BatchSenders []batch.SenderStats `json:"batch_senders"`
+}
+
+const readHeaderTimeout = 10 * time.Second
+
+// NewServer returns a debug server listening on the configured address.
+func NewServer(cfg *Config) (*Server, error) {
+	listener, err := net.Listen("tcp", cfg.listenAddress())
+	if err != nil {
+		return nil, fmt.Errorf("listening on debug address %s: %w", cfg.listenAddress(), err)
+	}
+
+	s := &Server{
+		listener: listener,
+		serveErr: make(chan error, 1),
+		server: &http.Server{
+			Handler:           handler(),
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+	}
+
+	go func() {
+		defer close(s.serveErr)
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.serveErr <- err
+		}
+	}()
+
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Shutdown stops accepting new requests, and waits for the ongoing ones to
+// complete until the context is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shutting down debug server: %w", err)
+	}
+	if err := <-s.serveErr; err != nil {
+		return fmt.Errorf("serving debug endpoints: %w", err)
+	}
+	return nil
+}
+
+func handler() http.Handler {
+	mux := http.NewServeMux()
+	// the pprof handlers are registered explicitly, since importing the
+	// package registers them on the default mux
+	mux.HandleFunc(pprofPath, pprof.Index)
+	mux.HandleFunc(pprofPath+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(pprofPath+"profile", pprof.Profile)
+	mux.HandleFunc(pprofPath+"symbol", pprof.Symbol)
+	mux.HandleFunc(pprofPath+"trace", pprof.Trace)
+	mux.Handle(varsPath, expvar.Handler())
+	mux.HandleFunc(runtimePath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, readRuntimeStats())
+	})
+	mux.HandleFunc(queuesPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, &QueueStats{BatchSenders: batch.Stats()})
+	})
+	return mux
+}
+
+func readRuntimeStats() *RuntimeStats {
+	memStats := &runtime.MemStats{}
+	runtime.ReadMemStats(memStats)
+
+	stats := &RuntimeStats{
+		Goroutines: runtime.NumGoroutine(),
+		Heap: HeapStats{
+			AllocBytes:    memStats.HeapAlloc,
+			InUseBytes:    memStats.HeapInuse,
+			IdleBytes:     memStats.HeapIdle,
+			ReleasedBytes: memStats.HeapReleased,
+			SysBytes:      memStats.HeapSys,
+			Objects:       memStats.HeapObjects,
+		},
+		GC: GCStats{
+			Cycles:          memStats.NumGC,
+			NextTargetBytes: memStats.NextGC,
+			PauseTotalNs:    memStats.PauseTotalNs,
+			CPUFraction:     memStats.GCCPUFraction,
+		},
+	}
+	if memStats.LastGC > 0 {
+		stats.GC.LastRun = time.Unix(0, int64(memStats.LastGC)).UTC()
+	}
+	return stats
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body) //nolint:errcheck
+}
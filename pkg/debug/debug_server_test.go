@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package debug
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/json"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+type mockMessage struct{}
+
+func (m mockMessage) Size() int     { return 1 }
+func (m mockMessage) IsEmpty() bool { return false }
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer(&Config{ListenAddress: "127.0.0.1:0"})
+	require.NoError(t, err)
+
+	get := func(path string) (int, []byte) {
+		resp, err := http.Get("http://" + s.Addr() + path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		return resp.StatusCode, body
+	}
+
+	statusCode, body := get(pprofPath)
+	require.Equal(t, http.StatusOK, statusCode)
+	require.Contains(t, string(body), "goroutine")
+
+	statusCode, _ = get(pprofPath + "heap?debug=1")
+	require.Equal(t, http.StatusOK, statusCode)
+
+	statusCode, body = get(varsPath)
+	require.Equal(t, http.StatusOK, statusCode)
+	require.Contains(t, string(body), `"memstats"`)
+
+	statusCode, body = get(runtimePath)
+	require.Equal(t, http.StatusOK, statusCode)
+	runtimeStats := &RuntimeStats{}
+	require.NoError(t, json.Unmarshal(body, runtimeStats))
+	require.Positive(t, runtimeStats.Goroutines)
+	require.Positive(t, runtimeStats.Heap.AllocBytes)
+
+	// the queues of the open batch senders are reported
+	sender, err := batch.NewSender(context.Background(), &batch.Config{},
+		func(context.Context, *batch.Batch[mockMessage]) error { return nil },
+		loglib.NewNoopLogger(), batch.WithInstrumentation(nil, "debug-test-processor"))
+	require.NoError(t, err)
+	defer sender.Close()
+	require.NoError(t, sender.SendMessage(context.Background(), batch.NewWALMessage(mockMessage{}, "")))
+
+	statusCode, body = get(queuesPath)
+	require.Equal(t, http.StatusOK, statusCode)
+	queueStats := &QueueStats{}
+	require.NoError(t, json.Unmarshal(body, queueStats))
+	require.True(t, slices.ContainsFunc(queueStats.BatchSenders, func(s batch.SenderStats) bool {
+		return s.Processor == "debug-test-processor"
+	}))
+
+	require.NoError(t, s.Shutdown(context.Background()))
+}
@@ -0,0 +1,28 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+type Config struct {
+	// ListenAddress is the host:port address the health HTTP server listens
+	// on. Defaults to :8080.
+	ListenAddress string
+	// MaxReplicationLagBytes is the replication lag above which the pipelines
+	// are reported as not ready. Zero disables the lag readiness check.
+	MaxReplicationLagBytes int64
+}
+
+const (
+	defaultListenAddress = ":8080"
+
+	healthzPath = "/healthz"
+	healthPath  = "/health"
+	readyzPath  = "/readyz"
+	statusPath  = "/status"
+)
+
+func (c *Config) listenAddress() string {
+	if c.ListenAddress != "" {
+		return c.ListenAddress
+	}
+	return defaultListenAddress
+}
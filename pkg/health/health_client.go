@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/xataio/pgstream/internal/json"
+)
+
+var errFetchingStatus = errors.New("fetching pgstream status")
+
+// FetchStatus returns the status of the pipelines run by the pgstream process
+// with the health server at the URL on input (e.g. http://localhost:8080).
+func FetchStatus(ctx context.Context, client *http.Client, serverURL string) (*Status, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(serverURL, "/")+statusPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errFetchingStatus, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errFetchingStatus, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errFetchingStatus, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status code %d: %s", errFetchingStatus, resp.StatusCode, body)
+	}
+
+	status := &Status{}
+	if err := json.Unmarshal(body, status); err != nil {
+		return nil, fmt.Errorf("%w: %w", errFetchingStatus, err)
+	}
+	return status, nil
+}
@@ -0,0 +1,155 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/xataio/pgstream/internal/json"
+)
+
+// Server exposes the state of the pipelines registered with the registry on
+// input over HTTP:
+//   - /healthz reports the process is up, to be used as liveness probe.
+//   - /readyz reports whether all the pipelines are ready, to be used as
+//     readiness probe. It returns a 503 otherwise.
+//   - /health returns the detailed state of every pipeline in JSON format,
+//     with a 503 if any of them is degraded.
+//   - /status returns the detailed state of every pipeline in JSON format.
+//
+// Additional admin endpoints can be served with the WithHandler option.
+type Server struct {
+	registry *Registry
+	handlers map[string]http.Handler
+	server   *http.Server
+	listener net.Listener
+	serveErr chan error
+}
+
+type ServerOption func(s *Server)
+
+const readHeaderTimeout = 10 * time.Second
+
+// NewServer returns a health server listening on the configured address.
+func NewServer(cfg *Config, registry *Registry, opts ...ServerOption) (*Server, error) {
+	s := &Server{
+		registry: registry,
+		handlers: map[string]http.Handler{},
+		serveErr: make(chan error, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	listener, err := net.Listen("tcp", cfg.listenAddress())
+	if err != nil {
+		return nil, fmt.Errorf("listening on health address %s: %w", cfg.listenAddress(), err)
+	}
+	s.listener = listener
+	s.server = &http.Server{
+		Handler:           s.handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+
+	go func() {
+		defer close(s.serveErr)
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.serveErr <- err
+		}
+	}()
+
+	return s, nil
+}
+
+// WithHandler serves the handler on input on the path on input, alongside the
+// health endpoints.
+func WithHandler(path string, handler http.Handler) ServerOption {
+	return func(s *Server) {
+		s.handlers[path] = handler
+	}
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Shutdown stops accepting new requests, and waits for the ongoing ones to
+// complete until the context is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shutting down health server: %w", err)
+	}
+	if err := <-s.serveErr; err != nil {
+		return fmt.Errorf("serving health endpoints: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(healthzPath, LivenessProbe())
+	mux.Handle(readyzPath, ReadinessProbe(s.registry))
+	mux.Handle(healthPath, HealthHandler(s.registry))
+	mux.HandleFunc(statusPath, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.registry.Status(r.Context()))
+	})
+	for path, handler := range s.handlers {
+		mux.Handle(path, handler)
+	}
+	return mux
+}
+
+// LivenessProbe returns a handler that reports the process is up, regardless
+// of the state of its pipelines, since restarting the process doesn't fix a
+// sink or replication slot that's not available.
+func LivenessProbe() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok")) //nolint:errcheck
+	})
+}
+
+// ReadinessProbe returns a handler that reports whether all the pipelines of
+// the registry on input are healthy and have processed their first event,
+// returning a 503 otherwise.
+func ReadinessProbe(registry *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := registry.Status(r.Context())
+		writeJSON(w, statusCode(status.Ready), status)
+	})
+}
+
+// HealthHandler returns a handler that reports the health of the sinks,
+// replication slot, replication lag and last processed position of all the
+// pipelines of the registry on input, returning a 503 if any of them is
+// degraded.
+func HealthHandler(registry *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := registry.Status(r.Context())
+		writeJSON(w, statusCode(status.Healthy), status)
+	})
+}
+
+func statusCode(ok bool) int {
+	if ok {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(body) //nolint:errcheck
+}
@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry(&Config{})
+	s, err := NewServer(&Config{ListenAddress: "127.0.0.1:0"}, registry,
+		WithHandler("/admin", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("admin")) //nolint:errcheck
+		})))
+	require.NoError(t, err)
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get("http://" + s.Addr() + path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	statusCode, body := get(healthzPath)
+	require.Equal(t, http.StatusOK, statusCode)
+	require.Equal(t, "ok", body)
+
+	statusCode, body = get("/admin")
+	require.Equal(t, http.StatusOK, statusCode)
+	require.Equal(t, "admin", body)
+
+	// no pipelines running yet
+	statusCode, _ = get(readyzPath)
+	require.Equal(t, http.StatusServiceUnavailable, statusCode)
+	statusCode, _ = get(healthPath)
+	require.Equal(t, http.StatusServiceUnavailable, statusCode)
+
+	// the pipeline is healthy once running, but not ready until it processes
+	// its first event
+	pipeline := registry.Pipeline("default")
+	pipeline.Start("replication")
+	statusCode, body = get(healthPath)
+	require.Equal(t, http.StatusOK, statusCode)
+	require.JSONEq(t, `{"healthy":true,"ready":false,"pipelines":[{"name":"default","mode":"replication","running":true,"paused":false,"healthy":true,"ready":false,"error":"pipeline hasn't processed any events yet"}]}`, body)
+	statusCode, _ = get(readyzPath)
+	require.Equal(t, http.StatusServiceUnavailable, statusCode)
+
+	process := pipeline.TrackEvents(func(context.Context, *wal.Event) error { return nil })
+	require.NoError(t, process(context.Background(), &wal.Event{CommitPosition: "0/1"}))
+	statusCode, _ = get(readyzPath)
+	require.Equal(t, http.StatusOK, statusCode)
+
+	pipeline.AddSink("postgres", &mockPinger{pingFn: func(context.Context) error { return errors.New("oh noes") }})
+	pipeline.SetPaused(true)
+	statusCode, _ = get(readyzPath)
+	require.Equal(t, http.StatusServiceUnavailable, statusCode)
+	statusCode, body = get(healthPath)
+	require.Equal(t, http.StatusServiceUnavailable, statusCode)
+	require.Contains(t, body, `"sinks":[{"name":"postgres","healthy":false,"error":"oh noes"}]`)
+
+	// the liveness probe doesn't depend on the pipelines
+	statusCode, _ = get(healthzPath)
+	require.Equal(t, http.StatusOK, statusCode)
+
+	// the status is returned regardless of the readiness
+	status, err := FetchStatus(context.Background(), http.DefaultClient, "http://"+s.Addr()+"/")
+	require.NoError(t, err)
+	require.Len(t, status.Pipelines, 1)
+	require.NotNil(t, status.Pipelines[0].LastEventTime)
+	status.Pipelines[0].LastEventTime = nil
+	require.Equal(t, &Status{
+		Healthy: false,
+		Ready:   false,
+		Pipelines: []PipelineStatus{
+			{
+				Name:         "default",
+				Mode:         "replication",
+				Running:      true,
+				Paused:       true,
+				Healthy:      false,
+				Ready:        false,
+				Error:        errPaused.Error(),
+				Sinks:        []CheckStatus{{Name: "postgres", Healthy: false, Error: "oh noes"}},
+				LastPosition: "0/1",
+			},
+		},
+	}, status)
+
+	require.NoError(t, s.Shutdown(context.Background()))
+
+	_, err = FetchStatus(context.Background(), http.DefaultClient, "http://"+s.Addr())
+	require.ErrorIs(t, err, errFetchingStatus)
+}
@@ -0,0 +1,286 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/snapshot"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// PipelineState keeps track of the state of a running pipeline, and of the
+// checks that determine whether it's ready. All its methods are safe to call
+// on a nil state, in which case they're a noop, so that the pipelines can be
+// run without health reporting.
+type PipelineState struct {
+	name                   string
+	maxReplicationLagBytes int64
+	clock                  func() time.Time
+
+	mutex          sync.Mutex
+	mode           string
+	running        bool
+	paused         bool
+	checks         []namedCheck
+	sinks          []namedCheck
+	replicationLag LagFn
+	// processedEvents is reset on every run, unlike the last position,
+	// since a restarted pipeline is not ready until it processes events again
+	processedEvents bool
+	lastPosition    wal.CommitPosition
+	lastEventTime   time.Time
+	snapshot        *SnapshotProgress
+}
+
+// Check returns an error if the pipeline component it checks is not healthy.
+type Check func(ctx context.Context) error
+
+// Pinger is a pipeline sink that can be pinged to check it's reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// LagFn returns the replication lag of the pipeline in bytes.
+type LagFn func(ctx context.Context) (int64, error)
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+const (
+	checkTimeout = 5 * time.Second
+
+	replicationLagCheck = "replication_lag"
+)
+
+var (
+	errNotRunning            = errors.New("pipeline is not running")
+	errPaused                = errors.New("pipeline is paused")
+	errNoEventsProcessed     = errors.New("pipeline hasn't processed any events yet")
+	errReplicationLagReached = errors.New("replication lag over the configured threshold")
+)
+
+func newPipelineState(name string, maxReplicationLagBytes int64) *PipelineState {
+	return &PipelineState{
+		name:                   name,
+		maxReplicationLagBytes: maxReplicationLagBytes,
+		clock:                  time.Now,
+	}
+}
+
+// Start resets the state of the pipeline for a new run in the mode on input.
+// The checks of any previous run are removed, since they reference the
+// components of a run that's no longer active.
+func (p *PipelineState) Start(mode string) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.mode = mode
+	p.running = true
+	p.paused = false
+	p.checks = nil
+	p.sinks = nil
+	p.replicationLag = nil
+	p.processedEvents = false
+	p.snapshot = nil
+}
+
+// Stop marks the pipeline as no longer running, which makes it not ready.
+func (p *PipelineState) Stop() {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.running = false
+}
+
+// AddCheck registers a readiness check for the current run of the pipeline.
+func (p *PipelineState) AddCheck(name string, check Check) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.checks = append(p.checks, namedCheck{name: name, check: check})
+}
+
+// AddSink registers a sink of the current run of the pipeline, which is
+// pinged to report its health.
+func (p *PipelineState) AddSink(name string, sink Pinger) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.sinks = append(p.sinks, namedCheck{name: name, check: sink.Ping})
+}
+
+// SetPaused reports whether the processing of the pipeline events is paused,
+// which makes it degraded.
+func (p *PipelineState) SetPaused(paused bool) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.paused = paused
+}
+
+// SetReplicationLag registers the function used to report the replication lag
+// of the current run of the pipeline.
+func (p *PipelineState) SetReplicationLag(lagFn LagFn) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.replicationLag = lagFn
+}
+
+// TrackEvents returns a wrapper around the event processing function on input
+// that keeps track of the position of the last event processed.
+func (p *PipelineState) TrackEvents(process func(context.Context, *wal.Event) error) func(context.Context, *wal.Event) error {
+	if p == nil {
+		return process
+	}
+	return func(ctx context.Context, event *wal.Event) error {
+		if err := process(ctx, event); err != nil {
+			return err
+		}
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		p.processedEvents = true
+		if event.CommitPosition != "" {
+			p.lastPosition = event.CommitPosition
+			p.lastEventTime = p.clock()
+		}
+		return nil
+	}
+}
+
+// SnapshotTableEvent keeps track of the snapshot progress of the current run
+// of the pipeline. It's meant to be used as the table event handler of the
+// data snapshot generator.
+func (p *PipelineState) SnapshotTableEvent(_ context.Context, event *snapshot.TableEvent) {
+	if p == nil || event == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.snapshot == nil {
+		p.snapshot = &SnapshotProgress{}
+	}
+	switch event.Type {
+	case snapshot.TableSnapshotStarted:
+		p.snapshot.TablesStarted++
+	case snapshot.TableSnapshotCompleted:
+		if event.Err != nil {
+			p.snapshot.TablesFailed++
+			return
+		}
+		p.snapshot.TablesCompleted++
+		p.snapshot.Rows += event.Rows
+	}
+}
+
+// status runs the health checks of the pipeline and returns its status. The
+// pipeline is healthy when it's running, not paused and all its checks pass,
+// and it's ready once it's healthy and it has processed its first event.
+func (p *PipelineState) status(ctx context.Context) PipelineStatus {
+	p.mutex.Lock()
+	status := PipelineStatus{
+		Name:         p.name,
+		Mode:         p.mode,
+		Running:      p.running,
+		Paused:       p.paused,
+		LastPosition: string(p.lastPosition),
+	}
+	if !p.lastEventTime.IsZero() {
+		lastEventTime := p.lastEventTime
+		status.LastEventTime = &lastEventTime
+	}
+	if p.snapshot != nil {
+		snapshotProgress := *p.snapshot
+		status.Snapshot = &snapshotProgress
+	}
+	checks := p.checks
+	sinks := p.sinks
+	replicationLag := p.replicationLag
+	processedEvents := p.processedEvents
+	p.mutex.Unlock()
+
+	status.Healthy = status.Running && !status.Paused
+	switch {
+	case !status.Running:
+		status.Error = errNotRunning.Error()
+	case status.Paused:
+		status.Error = errPaused.Error()
+	case !processedEvents:
+		status.Error = errNoEventsProcessed.Error()
+	}
+
+	// the checks are run without holding the lock, since they query the
+	// pipeline components and can take a while
+	status.Checks = runChecks(ctx, checks, &status)
+	status.Sinks = runChecks(ctx, sinks, &status)
+
+	if replicationLag != nil {
+		checkStatus := p.replicationLagStatus(ctx, replicationLag, &status)
+		if !checkStatus.Healthy {
+			status.Healthy = false
+		}
+		status.Checks = append(status.Checks, checkStatus)
+	}
+
+	status.Ready = status.Healthy && processedEvents
+	return status
+}
+
+// runChecks runs the checks on input, marking the pipeline status as not
+// healthy if any of them fails.
+func runChecks(ctx context.Context, checks []namedCheck, status *PipelineStatus) []CheckStatus {
+	var checkStatuses []CheckStatus
+	for _, c := range checks {
+		checkStatus := CheckStatus{Name: c.name, Healthy: true}
+		if err := runCheck(ctx, c.check); err != nil {
+			checkStatus.Healthy = false
+			checkStatus.Error = err.Error()
+			status.Healthy = false
+		}
+		checkStatuses = append(checkStatuses, checkStatus)
+	}
+	return checkStatuses
+}
+
+func (p *PipelineState) replicationLagStatus(ctx context.Context, replicationLag LagFn, status *PipelineStatus) CheckStatus {
+	checkStatus := CheckStatus{Name: replicationLagCheck, Healthy: true}
+	checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	lag, err := replicationLag(checkCtx)
+	if err != nil {
+		checkStatus.Healthy = false
+		checkStatus.Error = err.Error()
+		return checkStatus
+	}
+	status.ReplicationLagBytes = &lag
+	if p.maxReplicationLagBytes > 0 && lag > p.maxReplicationLagBytes {
+		checkStatus.Healthy = false
+		checkStatus.Error = fmt.Sprintf("%s: %d bytes (max %d bytes)", errReplicationLagReached, lag, p.maxReplicationLagBytes)
+	}
+	return checkStatus
+}
+
+func runCheck(ctx context.Context, check Check) error {
+	checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	return check(checkCtx)
+}
@@ -0,0 +1,292 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/snapshot"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestPipelineState_status(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	lag := int64(100)
+	healthy := func(context.Context) error { return nil }
+	unhealthy := func(context.Context) error { return errTest }
+	lagFn := func(l int64, err error) LagFn {
+		return func(context.Context) (int64, error) { return l, err }
+	}
+	processEvent := func(p *PipelineState) {
+		process := p.TrackEvents(func(context.Context, *wal.Event) error { return nil })
+		require.NoError(t, process(context.Background(), &wal.Event{}))
+	}
+
+	tests := []struct {
+		name   string
+		maxLag int64
+		setup  func(p *PipelineState)
+
+		wantStatus PipelineStatus
+	}{
+		{
+			name: "ok - ready",
+			setup: func(p *PipelineState) {
+				p.Start("replication")
+				p.AddCheck("replication", healthy)
+				p.AddSink("postgres", &mockPinger{pingFn: healthy})
+				p.SetReplicationLag(lagFn(lag, nil))
+				processEvent(p)
+			},
+
+			wantStatus: PipelineStatus{
+				Name:    "test",
+				Mode:    "replication",
+				Running: true,
+				Healthy: true,
+				Ready:   true,
+				Checks: []CheckStatus{
+					{Name: "replication", Healthy: true},
+					{Name: replicationLagCheck, Healthy: true},
+				},
+				Sinks: []CheckStatus{
+					{Name: "postgres", Healthy: true},
+				},
+				ReplicationLagBytes: &lag,
+			},
+		},
+		{
+			name: "ok - healthy before the first event",
+			setup: func(p *PipelineState) {
+				p.Start("replication")
+				p.AddSink("postgres", &mockPinger{pingFn: healthy})
+			},
+
+			wantStatus: PipelineStatus{
+				Name:    "test",
+				Mode:    "replication",
+				Running: true,
+				Healthy: true,
+				Ready:   false,
+				Error:   errNoEventsProcessed.Error(),
+				Sinks: []CheckStatus{
+					{Name: "postgres", Healthy: true},
+				},
+			},
+		},
+		{
+			name: "ok - paused",
+			setup: func(p *PipelineState) {
+				p.Start("replication")
+				p.AddSink("postgres", &mockPinger{pingFn: unhealthy})
+				processEvent(p)
+				p.SetPaused(true)
+			},
+
+			wantStatus: PipelineStatus{
+				Name:    "test",
+				Mode:    "replication",
+				Running: true,
+				Paused:  true,
+				Healthy: false,
+				Ready:   false,
+				Error:   errPaused.Error(),
+				Sinks: []CheckStatus{
+					{Name: "postgres", Healthy: false, Error: errTest.Error()},
+				},
+			},
+		},
+		{
+			name: "ok - not running",
+			setup: func(p *PipelineState) {
+				p.Start("replication")
+				p.AddCheck("target", healthy)
+				p.Stop()
+			},
+
+			wantStatus: PipelineStatus{
+				Name:    "test",
+				Mode:    "replication",
+				Running: false,
+				Healthy: false,
+				Ready:   false,
+				Error:   errNotRunning.Error(),
+				Checks: []CheckStatus{
+					{Name: "target", Healthy: true},
+				},
+			},
+		},
+		{
+			name: "ok - check failing",
+			setup: func(p *PipelineState) {
+				p.Start("kafka")
+				p.AddCheck("target", unhealthy)
+				processEvent(p)
+			},
+
+			wantStatus: PipelineStatus{
+				Name:    "test",
+				Mode:    "kafka",
+				Running: true,
+				Healthy: false,
+				Ready:   false,
+				Checks: []CheckStatus{
+					{Name: "target", Healthy: false, Error: errTest.Error()},
+				},
+			},
+		},
+		{
+			name:   "ok - replication lag over threshold",
+			maxLag: 50,
+			setup: func(p *PipelineState) {
+				p.Start("replication")
+				p.SetReplicationLag(lagFn(lag, nil))
+				processEvent(p)
+			},
+
+			wantStatus: PipelineStatus{
+				Name:    "test",
+				Mode:    "replication",
+				Running: true,
+				Healthy: false,
+				Ready:   false,
+				Checks: []CheckStatus{
+					{Name: replicationLagCheck, Healthy: false, Error: "replication lag over the configured threshold: 100 bytes (max 50 bytes)"},
+				},
+				ReplicationLagBytes: &lag,
+			},
+		},
+		{
+			name: "ok - replication lag error",
+			setup: func(p *PipelineState) {
+				p.Start("replication")
+				p.SetReplicationLag(lagFn(0, errTest))
+				processEvent(p)
+			},
+
+			wantStatus: PipelineStatus{
+				Name:    "test",
+				Mode:    "replication",
+				Running: true,
+				Healthy: false,
+				Ready:   false,
+				Checks: []CheckStatus{
+					{Name: replicationLagCheck, Healthy: false, Error: errTest.Error()},
+				},
+			},
+		},
+		{
+			name: "ok - state reset on restart",
+			setup: func(p *PipelineState) {
+				p.Start("replication")
+				p.AddCheck("target", unhealthy)
+				p.AddSink("postgres", &mockPinger{pingFn: unhealthy})
+				processEvent(p)
+				p.SetPaused(true)
+				p.Stop()
+				p.Start("replication")
+			},
+
+			// the pipeline is not ready until it processes an event in the
+			// new run
+			wantStatus: PipelineStatus{
+				Name:    "test",
+				Mode:    "replication",
+				Running: true,
+				Healthy: true,
+				Ready:   false,
+				Error:   errNoEventsProcessed.Error(),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			p := newPipelineState("test", tc.maxLag)
+			tc.setup(p)
+			require.Equal(t, tc.wantStatus, p.status(context.Background()))
+		})
+	}
+}
+
+func TestPipelineState_TrackEvents(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	p := newPipelineState("test", 0)
+	p.clock = func() time.Time { return now }
+	p.Start("replication")
+
+	var processErr error
+	process := p.TrackEvents(func(context.Context, *wal.Event) error { return processErr })
+
+	require.NoError(t, process(context.Background(), &wal.Event{CommitPosition: "0/1"}))
+	processErr = errTest
+	require.ErrorIs(t, process(context.Background(), &wal.Event{CommitPosition: "0/2"}), errTest)
+
+	status := p.status(context.Background())
+	require.Equal(t, "0/1", status.LastPosition)
+	require.Equal(t, &now, status.LastEventTime)
+}
+
+func TestPipelineState_SnapshotTableEvent(t *testing.T) {
+	t.Parallel()
+
+	p := newPipelineState("test", 0)
+	p.Start("snapshot_and_replication")
+
+	for _, event := range []*snapshot.TableEvent{
+		{Type: snapshot.TableSnapshotStarted, Schema: "public", Table: "a"},
+		{Type: snapshot.TableSnapshotStarted, Schema: "public", Table: "b"},
+		{Type: snapshot.TableSnapshotStarted, Schema: "public", Table: "c"},
+		{Type: snapshot.TableSnapshotCompleted, Schema: "public", Table: "a", Rows: 10},
+		{Type: snapshot.TableSnapshotCompleted, Schema: "public", Table: "b", Err: errors.New("oh noes")},
+	} {
+		p.SnapshotTableEvent(context.Background(), event)
+	}
+
+	require.Equal(t, &SnapshotProgress{
+		TablesStarted:   3,
+		TablesCompleted: 1,
+		TablesFailed:    1,
+		Rows:            10,
+	}, p.status(context.Background()).Snapshot)
+}
+
+func TestPipelineState_nil(t *testing.T) {
+	t.Parallel()
+
+	var p *PipelineState
+	p.Start("replication")
+	p.AddCheck("target", func(context.Context) error { return nil })
+	p.AddSink("postgres", &mockPinger{})
+	p.SetPaused(true)
+	p.SetReplicationLag(func(context.Context) (int64, error) { return 0, nil })
+	p.SnapshotTableEvent(context.Background(), &snapshot.TableEvent{})
+	p.Stop()
+
+	called := false
+	process := p.TrackEvents(func(context.Context, *wal.Event) error {
+		called = true
+		return nil
+	})
+	require.NoError(t, process(context.Background(), &wal.Event{}))
+	require.True(t, called)
+}
+
+type mockPinger struct {
+	pingFn func(ctx context.Context) error
+}
+
+func (m *mockPinger) Ping(ctx context.Context) error {
+	return m.pingFn(ctx)
+}
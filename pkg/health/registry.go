@@ -0,0 +1,74 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"sync"
+)
+
+// Registry keeps track of the state of all the pipelines run by the pgstream
+// process, which determines the process readiness.
+type Registry struct {
+	maxReplicationLagBytes int64
+
+	mutex     sync.Mutex
+	pipelines []*PipelineState
+}
+
+func NewRegistry(cfg *Config) *Registry {
+	return &Registry{
+		maxReplicationLagBytes: cfg.MaxReplicationLagBytes,
+	}
+}
+
+// Pipeline returns the state of the pipeline on input, registering it if it
+// doesn't exist yet, so that it's kept across restarts of the pipeline.
+func (r *Registry) Pipeline(name string) *PipelineState {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, p := range r.pipelines {
+		if p.name == name {
+			return p
+		}
+	}
+	p := newPipelineState(name, r.maxReplicationLagBytes)
+	r.pipelines = append(r.pipelines, p)
+	return p
+}
+
+// Status runs the health checks of all the registered pipelines and returns
+// their status. The process is healthy (or ready) when there's at least one
+// pipeline, and all of them are healthy (or ready).
+func (r *Registry) Status(ctx context.Context) *Status {
+	r.mutex.Lock()
+	pipelines := make([]*PipelineState, len(r.pipelines))
+	copy(pipelines, r.pipelines)
+	r.mutex.Unlock()
+
+	status := &Status{
+		Healthy:   len(pipelines) > 0,
+		Ready:     len(pipelines) > 0,
+		Pipelines: make([]PipelineStatus, len(pipelines)),
+	}
+
+	// the pipelines are independent, so their checks are run concurrently
+	wg := &sync.WaitGroup{}
+	for i, p := range pipelines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status.Pipelines[i] = p.status(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for _, p := range status.Pipelines {
+		status.Healthy = status.Healthy && p.Healthy
+		status.Ready = status.Ready && p.Ready
+	}
+	return status
+}
@@ -0,0 +1,53 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestRegistry_Status(t *testing.T) {
+	t.Parallel()
+
+	r := NewRegistry(&Config{})
+	status := r.Status(context.Background())
+	require.False(t, status.Healthy)
+	require.False(t, status.Ready)
+
+	orders := r.Pipeline("orders")
+	orders.Start("replication")
+	users := r.Pipeline("users")
+	users.Start("kafka")
+	require.Same(t, orders, r.Pipeline("orders"))
+
+	status = r.Status(context.Background())
+	require.True(t, status.Healthy)
+	require.False(t, status.Ready)
+	require.Len(t, status.Pipelines, 2)
+	require.Equal(t, "orders", status.Pipelines[0].Name)
+	require.Equal(t, "users", status.Pipelines[1].Name)
+
+	// the process is ready once all the pipelines have processed events
+	noop := func(context.Context, *wal.Event) error { return nil }
+	require.NoError(t, orders.TrackEvents(noop)(context.Background(), &wal.Event{}))
+	require.False(t, r.Status(context.Background()).Ready)
+	require.NoError(t, users.TrackEvents(noop)(context.Background(), &wal.Event{}))
+	status = r.Status(context.Background())
+	require.True(t, status.Healthy)
+	require.True(t, status.Ready)
+
+	// one pipeline degraded makes the process not healthy nor ready
+	users.AddCheck("target", func(context.Context) error { return errors.New("oh noes") })
+	status = r.Status(context.Background())
+	require.False(t, status.Healthy)
+	require.False(t, status.Ready)
+	require.True(t, status.Pipelines[0].Healthy)
+	require.True(t, status.Pipelines[0].Ready)
+	require.False(t, status.Pipelines[1].Healthy)
+	require.False(t, status.Pipelines[1].Ready)
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package health
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Status is the state of all the pipelines run by the pgstream process.
+type Status struct {
+	Healthy   bool             `json:"healthy"`
+	Ready     bool             `json:"ready"`
+	Pipelines []PipelineStatus `json:"pipelines"`
+}
+
+type PipelineStatus struct {
+	Name    string        `json:"name"`
+	Mode    string        `json:"mode,omitempty"`
+	Running bool          `json:"running"`
+	Paused  bool          `json:"paused"`
+	Healthy bool          `json:"healthy"`
+	Ready   bool          `json:"ready"`
+	Error   string        `json:"error,omitempty"`
+	Checks  []CheckStatus `json:"checks,omitempty"`
+	Sinks   []CheckStatus `json:"sinks,omitempty"`
+	// LastPosition is the commit position of the last event processed by the
+	// pipeline (LSN for postgres sources, offset for kafka sources).
+	LastPosition        string            `json:"last_position,omitempty"`
+	LastEventTime       *time.Time        `json:"last_event_time,omitempty"`
+	ReplicationLagBytes *int64            `json:"replication_lag_bytes,omitempty"`
+	Snapshot            *SnapshotProgress `json:"snapshot,omitempty"`
+}
+
+type CheckStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+}
+
+type SnapshotProgress struct {
+	TablesStarted   uint64 `json:"tables_started"`
+	TablesCompleted uint64 `json:"tables_completed"`
+	TablesFailed    uint64 `json:"tables_failed"`
+	Rows            uint64 `json:"rows"`
+}
+
+func (s *Status) PrettyPrint() string {
+	if s == nil {
+		return ""
+	}
+
+	var prettyPrint strings.Builder
+	prettyPrint.WriteString(fmt.Sprintf("Healthy: %t\n", s.Healthy))
+	prettyPrint.WriteString(fmt.Sprintf("Ready: %t\n", s.Ready))
+	for _, p := range s.Pipelines {
+		prettyPrint.WriteString(p.PrettyPrint())
+		prettyPrint.WriteByte('\n')
+	}
+
+	// trim the last newline character
+	return strings.TrimSuffix(prettyPrint.String(), "\n")
+}
+
+func (ps *PipelineStatus) PrettyPrint() string {
+	var prettyPrint strings.Builder
+	prettyPrint.WriteString(fmt.Sprintf("Pipeline %s status:\n", ps.Name))
+	if ps.Mode != "" {
+		prettyPrint.WriteString(fmt.Sprintf(" - Mode: %s\n", ps.Mode))
+	}
+	prettyPrint.WriteString(fmt.Sprintf(" - Running: %t\n", ps.Running))
+	prettyPrint.WriteString(fmt.Sprintf(" - Paused: %t\n", ps.Paused))
+	prettyPrint.WriteString(fmt.Sprintf(" - Healthy: %t\n", ps.Healthy))
+	prettyPrint.WriteString(fmt.Sprintf(" - Ready: %t\n", ps.Ready))
+	if ps.Error != "" {
+		prettyPrint.WriteString(fmt.Sprintf(" - Error: %s\n", ps.Error))
+	}
+	for _, c := range ps.Checks {
+		prettyPrint.WriteString(c.prettyPrint("Check"))
+	}
+	for _, c := range ps.Sinks {
+		prettyPrint.WriteString(c.prettyPrint("Sink"))
+	}
+	if ps.LastPosition != "" {
+		prettyPrint.WriteString(fmt.Sprintf(" - Last position: %s\n", ps.LastPosition))
+	}
+	if ps.LastEventTime != nil {
+		prettyPrint.WriteString(fmt.Sprintf(" - Last event time: %s\n", ps.LastEventTime.Format(time.RFC3339)))
+	}
+	if ps.ReplicationLagBytes != nil {
+		prettyPrint.WriteString(fmt.Sprintf(" - Replication lag: %d bytes\n", *ps.ReplicationLagBytes))
+	}
+	if ps.Snapshot != nil {
+		prettyPrint.WriteString(fmt.Sprintf(" - Snapshot tables: %d started, %d completed, %d failed (%d rows)\n",
+			ps.Snapshot.TablesStarted, ps.Snapshot.TablesCompleted, ps.Snapshot.TablesFailed, ps.Snapshot.Rows))
+	}
+
+	// trim the last newline character
+	return strings.TrimSuffix(prettyPrint.String(), "\n")
+}
+
+func (cs *CheckStatus) prettyPrint(kind string) string {
+	if cs.Healthy {
+		return fmt.Sprintf(" - %s %s: healthy\n", kind, cs.Name)
+	}
+	return fmt.Sprintf(" - %s %s: unhealthy (%s)\n", kind, cs.Name, cs.Error)
+}
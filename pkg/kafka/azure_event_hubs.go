@@ -0,0 +1,105 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	tlslib "github.com/xataio/pgstream/pkg/tls"
+)
+
+// AzureEventHubsConfig configures the connection to an Azure Event Hubs
+// namespace through its Kafka endpoint. The Kafka endpoint is only available
+// in the Standard, Premium and Dedicated tiers, not in the Basic tier.
+type AzureEventHubsConfig struct {
+	// ConnectionString is the shared access signature connection string of
+	// the namespace or the event hub, in the format
+	// Endpoint=sb://<namespace>.servicebus.windows.net/;SharedAccessKeyName=<key name>;SharedAccessKey=<key>[;EntityPath=<event hub>]
+	ConnectionString string
+	// EventHub is the name of the event hub the messages are produced to (the
+	// equivalent of a Kafka topic). Defaults to the EntityPath of the
+	// connection string.
+	EventHub string
+}
+
+const (
+	azureEventHubsKafkaPort     = "9093"
+	azureEventHubsSASLUsername  = "$ConnectionString"
+	azureEventHubsEndpointKey   = "Endpoint"
+	azureEventHubsKeyNameKey    = "SharedAccessKeyName"
+	azureEventHubsKeyKey        = "SharedAccessKey"
+	azureEventHubsEntityPathKey = "EntityPath"
+)
+
+var (
+	errInvalidAzureConnectionString = errors.New("invalid azure event hubs connection string")
+	errMissingAzureEventHub         = errors.New("azure event hubs: event hub must be provided when the connection string has no EntityPath")
+)
+
+// ConnConfig returns the kafka connection configuration for the event hubs
+// namespace of the connection string. The bootstrap server is the namespace
+// Kafka endpoint on port 9093, and the authentication is SASL/PLAIN over TLS,
+// using the connection string as password.
+func (c *AzureEventHubsConfig) ConnConfig() (*ConnConfig, error) {
+	properties, err := parseAzureConnectionString(c.ConnectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := url.Parse(properties[azureEventHubsEndpointKey])
+	if err != nil || endpoint.Hostname() == "" {
+		return nil, fmt.Errorf("%w: invalid endpoint %q", errInvalidAzureConnectionString, properties[azureEventHubsEndpointKey])
+	}
+
+	eventHub := c.EventHub
+	if eventHub == "" {
+		eventHub = properties[azureEventHubsEntityPathKey]
+	}
+	if eventHub == "" {
+		return nil, errMissingAzureEventHub
+	}
+
+	return &ConnConfig{
+		Servers: []string{endpoint.Hostname() + ":" + azureEventHubsKafkaPort},
+		Topic: TopicConfig{
+			Name: eventHub,
+		},
+		TLS: tlslib.Config{
+			Enabled: true,
+		},
+		SASL: &SASLConfig{
+			Username: azureEventHubsSASLUsername,
+			Password: c.ConnectionString,
+		},
+	}, nil
+}
+
+// parseAzureConnectionString returns the key value properties of the
+// connection string, validating the required ones are present.
+func parseAzureConnectionString(connectionString string) (map[string]string, error) {
+	properties := map[string]string{}
+	for _, property := range strings.Split(connectionString, ";") {
+		property = strings.TrimSpace(property)
+		if property == "" {
+			continue
+		}
+		// the shared access key is base64 encoded and can contain '=', so only
+		// split on the first one
+		key, value, found := strings.Cut(property, "=")
+		if !found {
+			// don't include the property, since it could contain the key
+			return nil, fmt.Errorf("%w: malformed property", errInvalidAzureConnectionString)
+		}
+		properties[key] = value
+	}
+
+	for _, key := range []string{azureEventHubsEndpointKey, azureEventHubsKeyNameKey, azureEventHubsKeyKey} {
+		if properties[key] == "" {
+			return nil, fmt.Errorf("%w: missing %s", errInvalidAzureConnectionString, key)
+		}
+	}
+	return properties, nil
+}
@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	tlslib "github.com/xataio/pgstream/pkg/tls"
+)
+
+func TestAzureEventHubsConfig_ConnConfig(t *testing.T) {
+	t.Parallel()
+
+	const (
+		namespaceConnString = "Endpoint=sb://pgstream.servicebus.windows.net/;SharedAccessKeyName=RootManageSharedAccessKey;SharedAccessKey=c2VjcmV0a2V5=="
+		eventHubConnString  = namespaceConnString + ";EntityPath=wal-events"
+	)
+
+	tests := []struct {
+		name   string
+		config AzureEventHubsConfig
+
+		wantConfig *ConnConfig
+		wantErr    error
+	}{
+		{
+			name:   "ok - event hub from entity path",
+			config: AzureEventHubsConfig{ConnectionString: eventHubConnString},
+			wantConfig: &ConnConfig{
+				Servers: []string{"pgstream.servicebus.windows.net:9093"},
+				Topic:   TopicConfig{Name: "wal-events"},
+				TLS:     tlslib.Config{Enabled: true},
+				SASL:    &SASLConfig{Username: "$ConnectionString", Password: eventHubConnString},
+			},
+		},
+		{
+			name:   "ok - explicit event hub",
+			config: AzureEventHubsConfig{ConnectionString: namespaceConnString + ";", EventHub: "other"},
+			wantConfig: &ConnConfig{
+				Servers: []string{"pgstream.servicebus.windows.net:9093"},
+				Topic:   TopicConfig{Name: "other"},
+				TLS:     tlslib.Config{Enabled: true},
+				SASL:    &SASLConfig{Username: "$ConnectionString", Password: namespaceConnString + ";"},
+			},
+		},
+		{
+			name:    "error - missing event hub",
+			config:  AzureEventHubsConfig{ConnectionString: namespaceConnString},
+			wantErr: errMissingAzureEventHub,
+		},
+		{
+			name:    "error - empty connection string",
+			config:  AzureEventHubsConfig{},
+			wantErr: errInvalidAzureConnectionString,
+		},
+		{
+			name:    "error - missing shared access key",
+			config:  AzureEventHubsConfig{ConnectionString: "Endpoint=sb://pgstream.servicebus.windows.net/;SharedAccessKeyName=key;EntityPath=hub"},
+			wantErr: errInvalidAzureConnectionString,
+		},
+		{
+			name:    "error - malformed property",
+			config:  AzureEventHubsConfig{ConnectionString: namespaceConnString + ";invalid"},
+			wantErr: errInvalidAzureConnectionString,
+		},
+		{
+			name:    "error - invalid endpoint",
+			config:  AzureEventHubsConfig{ConnectionString: "Endpoint=pgstream;SharedAccessKeyName=key;SharedAccessKey=secret;EntityPath=hub"},
+			wantErr: errInvalidAzureConnectionString,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.config.ConnConfig()
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantConfig, got)
+		})
+	}
+}
@@ -2,12 +2,26 @@
 
 package kafka
 
-import tlslib "github.com/xataio/pgstream/pkg/tls"
+import (
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	tlslib "github.com/xataio/pgstream/pkg/tls"
+)
 
 type ConnConfig struct {
 	Servers []string
 	Topic   TopicConfig
 	TLS     tlslib.Config
+	// SASL enables the SASL/PLAIN authentication when set.
+	SASL *SASLConfig
+}
+
+// SASLConfig holds the credentials for the SASL/PLAIN authentication. It
+// should be used with TLS enabled, since the credentials are sent in plain
+// text.
+type SASLConfig struct {
+	Username string
+	Password string
 }
 
 type TopicConfig struct {
@@ -65,3 +79,10 @@ func (c *ReaderConfig) consumerGroupStartOffset() string {
 	}
 	return defaultConsumerGroupOffset
 }
+
+func (c *SASLConfig) mechanism() sasl.Mechanism {
+	return plain.Mechanism{
+		Username: c.Username,
+		Password: c.Password,
+	}
+}
@@ -3,6 +3,7 @@
 package kafka
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
@@ -17,7 +18,7 @@ import (
 // withConnection creates a connection that can be used by the kafka operation
 // passed in the parameters. This ensures the cleanup of all connection resources.
 func withConnection(config *ConnConfig, kafkaOperation func(conn *kafka.Conn) error) error {
-	dialer, err := buildDialer(&config.TLS)
+	dialer, err := buildDialer(config)
 	if err != nil {
 		return err
 	}
@@ -54,17 +55,47 @@ func withConnection(config *ConnConfig, kafkaOperation func(conn *kafka.Conn) er
 	return kafkaOperation(controllerConn)
 }
 
-func buildDialer(cfg *tlslib.Config) (*kafka.Dialer, error) {
+// pingTopic connects to the first reachable server and reads the partitions of
+// the configured topic.
+func pingTopic(ctx context.Context, config *ConnConfig) error {
+	dialer, err := buildDialer(config)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, server := range config.Servers {
+		conn, err := dialer.DialContext(ctx, "tcp", server)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		defer conn.Close()
+
+		if _, err := conn.ReadPartitions(config.Topic.Name); err != nil {
+			return fmt.Errorf("reading partitions for topic %s: %w", config.Topic.Name, err)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("error connecting to kafka, all servers failed: %w", errs)
+}
+
+func buildDialer(cfg *ConnConfig) (*kafka.Dialer, error) {
 	timeout := 10 * time.Second
 
-	tlsConfig, err := tlslib.NewConfig(cfg)
+	tlsConfig, err := tlslib.NewConfig(&cfg.TLS)
 	if err != nil {
 		return nil, fmt.Errorf("loading TLS configuration: %w", err)
 	}
 
-	return &kafka.Dialer{
+	dialer := &kafka.Dialer{
 		Timeout:   timeout,
 		DualStack: true,
 		TLS:       tlsConfig,
-	}, nil
+	}
+	if cfg.SASL != nil {
+		dialer.SASLMechanism = cfg.SASL.mechanism()
+	}
+	return dialer, nil
 }
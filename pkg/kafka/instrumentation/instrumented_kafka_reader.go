@@ -100,6 +100,13 @@ func (i *Reader) FetchMessage(ctx context.Context) (msg *kafka.Message, err erro
 	if msg != nil && i.meter != nil {
 		i.metrics.msgBytes.Record(ctx, int64(len(msg.Value)))
 	}
+	// the message is only known once fetched, so the producer span is linked
+	// rather than used as parent
+	if msg != nil && span != nil {
+		if producerSpan := trace.SpanContextFromContext(kafka.ExtractTraceContext(ctx, msg)); producerSpan.IsValid() {
+			span.AddLink(trace.Link{SpanContext: producerSpan})
+		}
+	}
 
 	return msg, err
 }
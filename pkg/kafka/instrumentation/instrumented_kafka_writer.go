@@ -102,3 +102,7 @@ func (i *Writer) WriteMessages(ctx context.Context, msgs ...kafka.Message) (err
 func (i *Writer) Close() error {
 	return i.inner.Close()
 }
+
+func (i *Writer) Ping(ctx context.Context) error {
+	return i.inner.Ping(ctx)
+}
@@ -15,16 +15,21 @@ import (
 type MessageWriter interface {
 	WriteMessages(context.Context, ...Message) error
 	Close() error
+	Ping(context.Context) error
 }
 
 // Writer is a wrapper around the kafkago library writer
 type Writer struct {
 	kafkaWriter *kafka.Writer
+	conn        ConnConfig
 }
 
 // Message is a wrapper around the kafkago library message
 type Message kafka.Message
 
+// Header is a kafka message header
+type Header = kafka.Header
+
 // Size returns the size of the kafka message value (does not include headers or
 // other fields)
 func (m Message) Size() int {
@@ -58,6 +63,7 @@ func NewWriter(config WriterConfig, logger loglib.Logger) (*Writer, error) {
 	logger.Info("creating kafka writer", loglib.Fields{
 		"kafka_servers": config.Conn.Servers,
 		"tls_enabled":   config.Conn.TLS.Enabled,
+		"sasl_enabled":  config.Conn.SASL != nil,
 	})
 
 	if config.Conn.Topic.AutoCreate {
@@ -66,7 +72,7 @@ func NewWriter(config WriterConfig, logger loglib.Logger) (*Writer, error) {
 		}
 	}
 
-	transport, err := buildTransport(&config.Conn.TLS)
+	transport, err := buildTransport(&config.Conn)
 	if err != nil {
 		return nil, err
 	}
@@ -84,6 +90,7 @@ func NewWriter(config WriterConfig, logger loglib.Logger) (*Writer, error) {
 			BatchBytes:   config.BatchBytes,
 			BatchSize:    config.BatchSize,
 		},
+		conn: config.Conn,
 	}, nil
 }
 
@@ -99,6 +106,12 @@ func (w *Writer) Close() error {
 	return w.kafkaWriter.Close()
 }
 
+// Ping checks that one of the kafka servers is reachable and that it has the
+// metadata for the writer topic.
+func (w *Writer) Ping(ctx context.Context) error {
+	return pingTopic(ctx, &w.conn)
+}
+
 func createTopic(cfg *ConnConfig) error {
 	return withConnection(cfg, func(conn *kafka.Conn) error {
 		topicConfigs := []kafka.TopicConfig{
@@ -118,14 +131,21 @@ func createTopic(cfg *ConnConfig) error {
 	})
 }
 
-func buildTransport(cfg *tlslib.Config) (kafka.RoundTripper, error) {
-	if cfg.Enabled {
-		tlsConfig, err := tlslib.NewConfig(cfg)
+func buildTransport(cfg *ConnConfig) (kafka.RoundTripper, error) {
+	if !cfg.TLS.Enabled && cfg.SASL == nil {
+		return kafka.DefaultTransport, nil
+	}
+
+	transport := &kafka.Transport{}
+	if cfg.TLS.Enabled {
+		tlsConfig, err := tlslib.NewConfig(&cfg.TLS)
 		if err != nil {
 			return nil, fmt.Errorf("building TLS config: %w", err)
 		}
-		return &kafka.Transport{TLS: tlsConfig}, nil
+		transport.TLS = tlsConfig
 	}
-
-	return kafka.DefaultTransport, nil
+	if cfg.SASL != nil {
+		transport.SASL = cfg.SASL.mechanism()
+	}
+	return transport, nil
 }
@@ -12,6 +12,7 @@ import (
 type Writer struct {
 	WriteMessagesFn func(context.Context, uint64, ...kafka.Message) error
 	CloseFn         func() error
+	PingFn          func(context.Context) error
 	WriteCalls      uint64
 }
 
@@ -27,6 +28,13 @@ func (m *Writer) Close() error {
 	return nil
 }
 
+func (m *Writer) Ping(ctx context.Context) error {
+	if m.PingFn != nil {
+		return m.PingFn(ctx)
+	}
+	return nil
+}
+
 func (m *Writer) GetWriteCalls() uint64 {
 	return atomic.LoadUint64(&m.WriteCalls)
 }
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// traceContextPropagator propagates the W3C trace context (traceparent and
+// tracestate headers) across kafka, regardless of the global propagator
+// configured in the process.
+var traceContextPropagator = propagation.TraceContext{}
+
+// headerCarrier adapts the kafka message headers to the opentelemetry text map
+// carrier interface.
+type headerCarrier struct {
+	headers *[]Header
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
+
+// InjectTraceContext adds the trace context of the span in the context on
+// input to the message headers. It's a noop if there's no valid span in the
+// context.
+func InjectTraceContext(ctx context.Context, msg *Message) {
+	traceContextPropagator.Inject(ctx, headerCarrier{headers: &msg.Headers})
+}
+
+// ExtractTraceContext returns a copy of the context on input containing the
+// remote span context from the message headers, if any, so that the spans
+// started from it continue the trace of the producer.
+func ExtractTraceContext(ctx context.Context, msg *Message) context.Context {
+	return traceContextPropagator.Extract(ctx, headerCarrier{headers: &msg.Headers})
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceContext_InjectExtract(t *testing.T) {
+	t.Parallel()
+
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		msg  *Message
+
+		wantHeaders     []string
+		wantSpanContext trace.SpanContext
+	}{
+		{
+			name: "ok - span context propagated",
+			ctx:  trace.ContextWithSpanContext(context.Background(), spanContext),
+			msg: &Message{
+				Headers: []Header{{Key: "content-type", Value: []byte("application/json")}},
+			},
+
+			wantHeaders:     []string{"content-type", "traceparent"},
+			wantSpanContext: spanContext.WithRemote(true),
+		},
+		{
+			name: "ok - existing trace context overwritten",
+			ctx:  trace.ContextWithSpanContext(context.Background(), spanContext),
+			msg: &Message{
+				Headers: []Header{{Key: "traceparent", Value: []byte("00-00000000000000000000000000000001-0000000000000001-01")}},
+			},
+
+			wantHeaders:     []string{"traceparent"},
+			wantSpanContext: spanContext.WithRemote(true),
+		},
+		{
+			name: "ok - no span context",
+			ctx:  context.Background(),
+			msg:  &Message{},
+
+			wantHeaders:     []string{},
+			wantSpanContext: trace.SpanContext{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			InjectTraceContext(tc.ctx, tc.msg)
+			require.Equal(t, tc.wantHeaders, headerCarrier{headers: &tc.msg.Headers}.Keys())
+
+			ctx := ExtractTraceContext(context.Background(), tc.msg)
+			require.Equal(t, tc.wantSpanContext, trace.SpanContextFromContext(ctx))
+		})
+	}
+}
@@ -33,6 +33,18 @@ func (l *NoopLogger) IsTraceEnabled() bool {
 
 const ModuleField = "module"
 
+// Fields identifying the pipeline and the event a log line refers to. They're
+// used by all the modules, so that the log lines of an event can be filtered
+// across the pipeline.
+const (
+	StreamIDField       = "stream_id"
+	SchemaField         = "schema"
+	TableField          = "table"
+	ActionField         = "action"
+	LSNField            = "lsn"
+	CommitPositionField = "commit_position"
+)
+
 func NewNoopLogger() *NoopLogger {
 	return &NoopLogger{}
 }
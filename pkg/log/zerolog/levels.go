@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package zerolog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog"
+	"github.com/xataio/pgstream/internal/json"
+)
+
+// Levels keeps the log level of each module, identified by the module field of
+// the loggers, and the sampling of the trace and debug lines. It can be shared
+// by all the loggers of the process, and updated at runtime.
+type Levels struct {
+	mutex        sync.RWMutex
+	defaultLevel zerolog.Level
+	// modules has the configured level of each module pattern
+	modules    map[string]zerolog.Level
+	sampleRate uint64
+	// resolved caches the level of the modules that have already logged, so
+	// that the patterns are only matched once per module
+	resolved map[string]zerolog.Level
+	counters map[string]*atomic.Uint64
+}
+
+type LevelsConfig struct {
+	// Level is the log level of the modules without a configured level.
+	Level string `json:"level"`
+	// ModuleLevels is the log level of each module. The modules can be
+	// patterns, as supported by path.Match (e.g. wal_*_listener).
+	ModuleLevels map[string]string `json:"module_levels,omitempty"`
+	// SampleRate logs 1 in every N trace and debug lines of each module.
+	// Info, warning and error lines are always logged. When unset (or 1), the
+	// trace and debug lines are not sampled.
+	SampleRate uint64 `json:"sample_rate,omitempty"`
+}
+
+var errInvalidLogLevel = errors.New("invalid log level")
+
+// NewLevels returns the log levels for the configuration on input.
+func NewLevels(cfg *LevelsConfig) (*Levels, error) {
+	l := &Levels{}
+	if err := l.Update(cfg); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Update replaces the log levels with the configuration on input. It can be
+// called while the loggers are in use.
+func (l *Levels) Update(cfg *LevelsConfig) error {
+	defaultLevel, err := parseLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	modules := make(map[string]zerolog.Level, len(cfg.ModuleLevels))
+	for module, levelStr := range cfg.ModuleLevels {
+		if _, err := path.Match(module, ""); err != nil {
+			return fmt.Errorf("invalid module pattern %q: %w", module, err)
+		}
+		level, err := parseLevel(levelStr)
+		if err != nil {
+			return fmt.Errorf("module %s: %w", module, err)
+		}
+		modules[module] = level
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.defaultLevel = defaultLevel
+	l.modules = modules
+	l.sampleRate = cfg.SampleRate
+	l.resolved = map[string]zerolog.Level{}
+	if l.counters == nil {
+		l.counters = map[string]*atomic.Uint64{}
+	}
+	return nil
+}
+
+// Config returns the current configuration of the log levels.
+func (l *Levels) Config() *LevelsConfig {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	cfg := &LevelsConfig{
+		Level:      l.defaultLevel.String(),
+		SampleRate: l.sampleRate,
+	}
+	if len(l.modules) > 0 {
+		cfg.ModuleLevels = make(map[string]string, len(l.modules))
+		for module, level := range l.modules {
+			cfg.ModuleLevels[module] = level.String()
+		}
+	}
+	return cfg
+}
+
+// ServeHTTP returns the current log levels configuration on GET requests, and
+// replaces it with the one in the request body on PUT requests.
+func (l *Levels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("reading request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		cfg := &LevelsConfig{}
+		if err := json.Unmarshal(body, cfg); err != nil {
+			http.Error(w, fmt.Sprintf("decoding log levels: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := l.Update(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := json.Marshal(l.Config())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("encoding log levels: %v", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body) //nolint:errcheck
+}
+
+// level returns the log level of the module on input. Exact module matches
+// take precedence over patterns, and longer patterns over shorter ones.
+func (l *Levels) level(module string) zerolog.Level {
+	l.mutex.RLock()
+	level, found := l.resolved[module]
+	l.mutex.RUnlock()
+	if found {
+		return level
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	level = l.defaultLevel
+	if moduleLevel, found := l.modules[module]; found {
+		level = moduleLevel
+	} else {
+		patterns := slices.SortedFunc(maps.Keys(l.modules), func(a, b string) int {
+			if len(a) != len(b) {
+				return len(b) - len(a)
+			}
+			return strings.Compare(a, b)
+		})
+		for _, pattern := range patterns {
+			if matched, _ := path.Match(pattern, module); matched {
+				level = l.modules[pattern]
+				break
+			}
+		}
+	}
+	l.resolved[module] = level
+	return level
+}
+
+// sampled returns whether the next trace or debug line of the module on input
+// should be logged.
+func (l *Levels) sampled(module string) bool {
+	l.mutex.RLock()
+	sampleRate := l.sampleRate
+	counter, found := l.counters[module]
+	l.mutex.RUnlock()
+	if sampleRate <= 1 {
+		return true
+	}
+
+	if !found {
+		l.mutex.Lock()
+		if counter, found = l.counters[module]; !found {
+			counter = &atomic.Uint64{}
+			l.counters[module] = counter
+		}
+		l.mutex.Unlock()
+	}
+	// the first line is always logged
+	return (counter.Add(1)-1)%sampleRate == 0
+}
+
+func (l *Levels) isSampling() bool {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.sampleRate > 1
+}
+
+func parseLevel(levelStr string) (zerolog.Level, error) {
+	// an empty level defaults to no level, in line with the process log level
+	level, err := zerolog.ParseLevel(strings.ToLower(levelStr))
+	if err != nil {
+		return zerolog.NoLevel, fmt.Errorf("%w: %q", errInvalidLogLevel, levelStr)
+	}
+	return level, nil
+}
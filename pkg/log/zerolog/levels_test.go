@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package zerolog
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+func TestNewLevels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  *LevelsConfig
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			cfg: &LevelsConfig{
+				Level:        "info",
+				ModuleLevels: map[string]string{"wal_*_listener": "TRACE"},
+			},
+		},
+		{
+			name:    "error - invalid level",
+			cfg:     &LevelsConfig{Level: "loud"},
+			wantErr: errInvalidLogLevel,
+		},
+		{
+			name: "error - invalid module level",
+			cfg: &LevelsConfig{
+				Level:        "info",
+				ModuleLevels: map[string]string{"wal_postgres_listener": "loud"},
+			},
+			wantErr: errInvalidLogLevel,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewLevels(tc.cfg)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestLevels_level(t *testing.T) {
+	t.Parallel()
+
+	levels, err := NewLevels(&LevelsConfig{
+		Level: "info",
+		ModuleLevels: map[string]string{
+			"wal_postgres_listener": "trace",
+			"wal_*":                 "warn",
+			"wal_*_batch_writer":    "debug",
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, zerolog.TraceLevel, levels.level("wal_postgres_listener"))
+	require.Equal(t, zerolog.DebugLevel, levels.level("wal_kafka_batch_writer"))
+	require.Equal(t, zerolog.WarnLevel, levels.level("wal_filter"))
+	require.Equal(t, zerolog.InfoLevel, levels.level("postgres_checkpointer"))
+	require.Equal(t, zerolog.InfoLevel, levels.level(""))
+
+	// the resolved levels are reset on update
+	require.NoError(t, levels.Update(&LevelsConfig{Level: "error"}))
+	require.Equal(t, zerolog.ErrorLevel, levels.level("wal_postgres_listener"))
+}
+
+func TestLogger_withLevels(t *testing.T) {
+	t.Parallel()
+
+	newTestLogger := func(cfg *LevelsConfig) (loglib.Logger, *bytes.Buffer) {
+		buf := &bytes.Buffer{}
+		zl := zerolog.New(buf).Level(zerolog.InfoLevel)
+		levels, err := NewLevels(cfg)
+		require.NoError(t, err)
+		return NewLogger(&zl, WithLevels(levels)), buf
+	}
+	requireLines := func(t *testing.T, want []string, buf *bytes.Buffer) {
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, len(want))
+		for i := range want {
+			require.JSONEq(t, want[i], lines[i])
+		}
+	}
+
+	t.Run("module levels", func(t *testing.T) {
+		t.Parallel()
+
+		logger, buf := newTestLogger(&LevelsConfig{
+			Level:        "info",
+			ModuleLevels: map[string]string{"noisy": "error", "debugged": "trace"},
+		})
+
+		noisy := logger.WithFields(loglib.Fields{loglib.ModuleField: "noisy"})
+		noisy.Info("noisy info")
+		noisy.Error(nil, "noisy error")
+		// the module is kept when deriving the logger
+		debugged := logger.WithFields(loglib.Fields{loglib.ModuleField: "debugged"}).WithFields(loglib.Fields{"key": "value"})
+		require.True(t, debugged.IsTraceEnabled())
+		debugged.Trace("debugged trace")
+		logger.Debug("default debug")
+		logger.Info("default info")
+
+		requireLines(t, []string{
+			`{"level":"error","module":"noisy","message":"noisy error"}`,
+			`{"level":"trace","key":"value","module":"debugged","message":"debugged trace"}`,
+			`{"level":"info","message":"default info"}`,
+		}, buf)
+	})
+
+	t.Run("sampled trace and debug lines", func(t *testing.T) {
+		t.Parallel()
+
+		logger, buf := newTestLogger(&LevelsConfig{
+			Level:      "debug",
+			SampleRate: 3,
+		})
+
+		module := logger.WithFields(loglib.Fields{loglib.ModuleField: "module"})
+		for range 5 {
+			module.Debug("debug")
+			module.Error(nil, "error")
+		}
+
+		requireLines(t, []string{
+			`{"level":"debug","module":"module","message":"debug"}`,
+			`{"level":"error","module":"module","message":"error"}`,
+			`{"level":"error","module":"module","message":"error"}`,
+			`{"level":"error","module":"module","message":"error"}`,
+			`{"level":"debug","module":"module","message":"debug"}`,
+			`{"level":"error","module":"module","message":"error"}`,
+			`{"level":"error","module":"module","message":"error"}`,
+		}, buf)
+	})
+}
+
+func TestLevels_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	levels, err := NewLevels(&LevelsConfig{Level: "info"})
+	require.NoError(t, err)
+
+	serve := func(method, body string) (int, string) {
+		rec := httptest.NewRecorder()
+		levels.ServeHTTP(rec, httptest.NewRequest(method, "/loglevels", strings.NewReader(body)))
+		respBody, err := io.ReadAll(rec.Result().Body)
+		require.NoError(t, err)
+		return rec.Code, string(respBody)
+	}
+
+	statusCode, body := serve(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, statusCode)
+	require.JSONEq(t, `{"level":"info"}`, body)
+
+	statusCode, body = serve(http.MethodPut, `{"level":"warn","module_levels":{"wal_postgres_listener":"debug"},"sample_rate":10}`)
+	require.Equal(t, http.StatusOK, statusCode)
+	require.JSONEq(t, `{"level":"warn","module_levels":{"wal_postgres_listener":"debug"},"sample_rate":10}`, body)
+	require.Equal(t, zerolog.DebugLevel, levels.level("wal_postgres_listener"))
+
+	statusCode, _ = serve(http.MethodPut, `{"level":"loud"}`)
+	require.Equal(t, http.StatusBadRequest, statusCode)
+	// the levels are unchanged on error
+	require.Equal(t, zerolog.WarnLevel, levels.level("other"))
+
+	statusCode, _ = serve(http.MethodPost, "")
+	require.Equal(t, http.StatusMethodNotAllowed, statusCode)
+}
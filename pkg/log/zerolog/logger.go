@@ -13,61 +13,110 @@ import (
 type Logger struct {
 	zerologger *zerolog.Logger
 	fields     loglib.Fields
+	// levels overrides the zerolog logger level with the level of the logger
+	// module, if set
+	levels *Levels
+	module string
 }
 
+type Option func(l *Logger)
+
 // if we go over this limit the log will likely be truncated and it will not
 // be very readable
 const logMaxBytes = 10000
 
-func NewLogger(zl *zerolog.Logger) *Logger {
-	return &Logger{
+func NewLogger(zl *zerolog.Logger, opts ...Option) *Logger {
+	l := &Logger{
 		zerologger: zl,
 	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// WithLevels sets the per module log levels of the logger and all the loggers
+// derived from it.
+func WithLevels(levels *Levels) Option {
+	return func(l *Logger) {
+		l.levels = levels
+	}
 }
 
 func (l *Logger) Trace(msg string, fields ...loglib.Fields) {
-	if !l.IsTraceEnabled() {
+	if !l.IsTraceEnabled() || !l.sampled() {
 		return
 	}
-	withFields(l.zerologger.Trace(), append(fields, l.fields)...).Msg(msg)
+	withFields(l.logger().Trace(), append(fields, l.fields)...).Msg(msg)
 }
 
 func (l *Logger) Debug(msg string, fields ...loglib.Fields) {
-	if !l.IsDebugEnabled() {
+	if !l.IsDebugEnabled() || !l.sampled() {
 		return
 	}
-	withFields(l.zerologger.Debug(), append(fields, l.fields)...).Msg(msg)
+	withFields(l.logger().Debug(), append(fields, l.fields)...).Msg(msg)
 }
 
 func (l *Logger) Info(msg string, fields ...loglib.Fields) {
-	withFields(l.zerologger.Info(), append(fields, l.fields)...).Msg(msg)
+	withFields(l.logger().Info(), append(fields, l.fields)...).Msg(msg)
 }
 
 func (l *Logger) Warn(err error, msg string, fields ...loglib.Fields) {
-	withFields(l.zerologger.Warn().Err(err), append(fields, l.fields)...).Msg(msg)
+	withFields(l.logger().Warn().Err(err), append(fields, l.fields)...).Msg(msg)
 }
 
 func (l *Logger) Error(err error, msg string, fields ...loglib.Fields) {
-	withFields(l.zerologger.Error().Err(err), append(fields, l.fields)...).Msg(msg)
+	withFields(l.logger().Error().Err(err), append(fields, l.fields)...).Msg(msg)
 }
 
 func (l *Logger) Panic(msg string, fields ...loglib.Fields) {
-	withFields(l.zerologger.Panic(), append(fields, l.fields)...).Msg(msg)
+	withFields(l.logger().Panic(), append(fields, l.fields)...).Msg(msg)
 }
 
 func (l *Logger) WithFields(fields loglib.Fields) loglib.Logger {
-	return &Logger{
+	derived := &Logger{
 		zerologger: l.zerologger,
 		fields:     loglib.MergeFields(l.fields, fields),
+		levels:     l.levels,
+		module:     l.module,
+	}
+	if module, ok := fields[loglib.ModuleField].(string); ok {
+		derived.module = module
 	}
+	return derived
 }
 
 func (l *Logger) IsDebugEnabled() bool {
-	return l.zerologger.GetLevel() <= zerolog.DebugLevel
+	return l.level() <= zerolog.DebugLevel
 }
 
 func (l *Logger) IsTraceEnabled() bool {
-	return l.zerologger.GetLevel() <= zerolog.TraceLevel
+	return l.level() <= zerolog.TraceLevel
+}
+
+func (l *Logger) level() zerolog.Level {
+	if l.levels == nil {
+		return l.zerologger.GetLevel()
+	}
+	return l.levels.level(l.module)
+}
+
+// logger returns the zerolog logger with the level of the logger module. When
+// the levels sample the trace and debug lines, the zerolog sampling is
+// disabled, since they're already sampled.
+func (l *Logger) logger() *zerolog.Logger {
+	if l.levels == nil {
+		return l.zerologger
+	}
+	zl := l.zerologger.Level(l.levels.level(l.module))
+	if l.levels.isSampling() {
+		zl = zl.Sample(nil)
+	}
+	return &zl
+}
+
+func (l *Logger) sampled() bool {
+	return l.levels == nil || l.levels.sampled(l.module)
 }
 
 func withFields(event *zerolog.Event, fieldMaps ...loglib.Fields) *zerolog.Event {
@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudwatch
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// CloudWatchReporter is an OpenTelemetry metrics exporter that publishes the
+// replication health metrics to CloudWatch as custom metrics, for AWS native
+// monitoring and alarms. Rather than exporting all the pgstream metrics, it
+// derives the following metrics from the processor instrumentation:
+//
+//   - ReplicationLagSeconds: time since the wal events were produced until
+//     they're processed, published as a statistic set per collection interval.
+//   - EventsPerSecond: rate of wal events processed during the collection
+//     interval.
+//   - ErrorsTotal: number of wal events that failed to be processed during
+//     the collection interval.
+//
+// All metrics have a dimension per processor attribute (i.e. target), as well
+// as the configured dimensions.
+type CloudWatchReporter struct {
+	client            putMetricDataAPI
+	namespace         string
+	storageResolution int32
+	dimensions        []types.Dimension
+}
+
+type putMetricDataAPI interface {
+	PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+const (
+	ReplicationLagSecondsMetric = "ReplicationLagSeconds"
+	EventsPerSecondMetric       = "EventsPerSecond"
+	ErrorsTotalMetric           = "ErrorsTotal"
+
+	// names of the processor instruments the metrics are derived from
+	processingLagInstrument     = "pgstream.target.processing.lag"
+	processingLatencyInstrument = "pgstream.target.processing.latency"
+	processingErrorsInstrument  = "pgstream.target.processing.errors"
+
+	// maxMetricDataPerRequest is the max number of metrics CloudWatch accepts
+	// per PutMetricData request
+	maxMetricDataPerRequest = 1000
+)
+
+var _ sdkmetric.Exporter = (*CloudWatchReporter)(nil)
+
+func NewCloudWatchReporter(ctx context.Context, cfg *Config) (*CloudWatchReporter, error) {
+	opts := []func(*awsconfig.LoadOptions) error{}
+	if cfg.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(cfg.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading aws config: %w", err)
+	}
+
+	return newCloudWatchReporter(cloudwatch.NewFromConfig(awsCfg), cfg), nil
+}
+
+func newCloudWatchReporter(client putMetricDataAPI, cfg *Config) *CloudWatchReporter {
+	dimensions := make([]types.Dimension, 0, len(cfg.Dimensions))
+	for name, value := range cfg.Dimensions {
+		dimensions = append(dimensions, types.Dimension{Name: aws.String(name), Value: aws.String(value)})
+	}
+	slices.SortFunc(dimensions, func(a, b types.Dimension) int {
+		return strings.Compare(*a.Name, *b.Name)
+	})
+
+	return &CloudWatchReporter{
+		client:            client,
+		namespace:         cfg.namespace(),
+		storageResolution: cfg.storageResolution(),
+		dimensions:        dimensions,
+	}
+}
+
+// Temporality uses delta temporality for the counters and histograms, since
+// the metrics are published per collection interval.
+func (r *CloudWatchReporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindUpDownCounter,
+		sdkmetric.InstrumentKindObservableUpDownCounter:
+		return metricdata.CumulativeTemporality
+	default:
+		return metricdata.DeltaTemporality
+	}
+}
+
+func (r *CloudWatchReporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+// Export publishes the metrics derived from the collected data to CloudWatch,
+// in batches of up to 1000 metrics.
+func (r *CloudWatchReporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	metricData := r.metricData(rm)
+	for start := 0; start < len(metricData); start += maxMetricDataPerRequest {
+		end := min(start+maxMetricDataPerRequest, len(metricData))
+		if _, err := r.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(r.namespace),
+			MetricData: metricData[start:end],
+		}); err != nil {
+			return fmt.Errorf("putting cloudwatch metric data: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *CloudWatchReporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (r *CloudWatchReporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// metricData returns the CloudWatch metrics derived from the collected data.
+// The errors are published for all the processors with processed events, so
+// that the metric is reported as zero rather than missing when there are no
+// errors.
+func (r *CloudWatchReporter) metricData(rm *metricdata.ResourceMetrics) []types.MetricDatum {
+	metricData := []types.MetricDatum{}
+	errorCounts := map[attribute.Distinct]int64{}
+	processors := map[attribute.Distinct]processorDataPoint{}
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch m.Name {
+			case processingLagInstrument:
+				for _, dp := range histogramDataPoints(m.Data) {
+					if datum, ok := r.lagDatum(dp); ok {
+						metricData = append(metricData, datum)
+					}
+				}
+			case processingLatencyInstrument:
+				for _, dp := range histogramDataPoints(m.Data) {
+					metricData = append(metricData, r.eventsPerSecondDatum(dp))
+					processors[dp.Attributes.Equivalent()] = processorDataPoint{attributes: dp.Attributes, time: dp.Time}
+				}
+			case processingErrorsInstrument:
+				sum, ok := m.Data.(metricdata.Sum[int64])
+				if !ok {
+					continue
+				}
+				for _, dp := range sum.DataPoints {
+					errorCounts[dp.Attributes.Equivalent()] += dp.Value
+					if _, found := processors[dp.Attributes.Equivalent()]; !found {
+						processors[dp.Attributes.Equivalent()] = processorDataPoint{attributes: dp.Attributes, time: dp.Time}
+					}
+				}
+			}
+		}
+	}
+
+	for key, p := range processors {
+		metricData = append(metricData, r.newDatum(ErrorsTotalMetric, types.StandardUnitCount, p.attributes, p.time, func(d *types.MetricDatum) {
+			d.Value = aws.Float64(float64(errorCounts[key]))
+		}))
+	}
+
+	return metricData
+}
+
+type processorDataPoint struct {
+	attributes attribute.Set
+	time       time.Time
+}
+
+// lagDatum returns the statistic set of the processing lag on input, in
+// seconds. Data points without samples are skipped, since CloudWatch doesn't
+// accept empty statistic sets.
+func (r *CloudWatchReporter) lagDatum(dp metricdata.HistogramDataPoint[int64]) (types.MetricDatum, bool) {
+	if dp.Count == 0 {
+		return types.MetricDatum{}, false
+	}
+	minValue, minDefined := dp.Min.Value()
+	maxValue, maxDefined := dp.Max.Value()
+	if !minDefined || !maxDefined {
+		return types.MetricDatum{}, false
+	}
+
+	return r.newDatum(ReplicationLagSecondsMetric, types.StandardUnitSeconds, dp.Attributes, dp.Time, func(d *types.MetricDatum) {
+		d.StatisticValues = &types.StatisticSet{
+			SampleCount: aws.Float64(float64(dp.Count)),
+			Sum:         aws.Float64(nanosToSeconds(dp.Sum)),
+			Minimum:     aws.Float64(nanosToSeconds(minValue)),
+			Maximum:     aws.Float64(nanosToSeconds(maxValue)),
+		}
+	}), true
+}
+
+// eventsPerSecondDatum returns the rate of events processed during the
+// collection interval of the data point on input.
+func (r *CloudWatchReporter) eventsPerSecondDatum(dp metricdata.HistogramDataPoint[int64]) types.MetricDatum {
+	rate := 0.0
+	if interval := dp.Time.Sub(dp.StartTime).Seconds(); interval > 0 {
+		rate = float64(dp.Count) / interval
+	}
+	return r.newDatum(EventsPerSecondMetric, types.StandardUnitCountSecond, dp.Attributes, dp.Time, func(d *types.MetricDatum) {
+		d.Value = aws.Float64(rate)
+	})
+}
+
+func (r *CloudWatchReporter) newDatum(name string, unit types.StandardUnit, attrs attribute.Set, timestamp time.Time, setValue func(*types.MetricDatum)) types.MetricDatum {
+	datum := types.MetricDatum{
+		MetricName:        aws.String(name),
+		Dimensions:        r.datumDimensions(attrs),
+		StorageResolution: aws.Int32(r.storageResolution),
+		Timestamp:         aws.Time(timestamp),
+		Unit:              unit,
+	}
+	setValue(&datum)
+	return datum
+}
+
+// datumDimensions returns the configured dimensions followed by the data
+// point attributes, which are sorted by key.
+func (r *CloudWatchReporter) datumDimensions(attrs attribute.Set) []types.Dimension {
+	dimensions := make([]types.Dimension, 0, len(r.dimensions)+attrs.Len())
+	dimensions = append(dimensions, r.dimensions...)
+	iter := attrs.Iter()
+	for iter.Next() {
+		kv := iter.Attribute()
+		dimensions = append(dimensions, types.Dimension{
+			Name:  aws.String(string(kv.Key)),
+			Value: aws.String(kv.Value.Emit()),
+		})
+	}
+	return dimensions
+}
+
+func histogramDataPoints(data metricdata.Aggregation) []metricdata.HistogramDataPoint[int64] {
+	histogram, ok := data.(metricdata.Histogram[int64])
+	if !ok {
+		return nil
+	}
+	return histogram.DataPoints
+}
+
+func nanosToSeconds(nanos int64) float64 {
+	return time.Duration(nanos).Seconds()
+}
@@ -0,0 +1,247 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+type mockCloudWatchClient struct {
+	putMetricDataFn func(input *cloudwatch.PutMetricDataInput) error
+	inputs          []*cloudwatch.PutMetricDataInput
+}
+
+func (m *mockCloudWatchClient) PutMetricData(_ context.Context, input *cloudwatch.PutMetricDataInput, _ ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	m.inputs = append(m.inputs, input)
+	if m.putMetricDataFn != nil {
+		if err := m.putMetricDataFn(input); err != nil {
+			return nil, err
+		}
+	}
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func TestCloudWatchReporter_Export(t *testing.T) {
+	t.Parallel()
+
+	startTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	endTime := startTime.Add(10 * time.Second)
+	targetAttrs := attribute.NewSet(attribute.String("target", "postgres"))
+
+	lagMetric := metricdata.Metrics{
+		Name: processingLagInstrument,
+		Data: metricdata.Histogram[int64]{
+			Temporality: metricdata.DeltaTemporality,
+			DataPoints: []metricdata.HistogramDataPoint[int64]{
+				{
+					Attributes: targetAttrs,
+					StartTime:  startTime,
+					Time:       endTime,
+					Count:      4,
+					Sum:        int64(6 * time.Second),
+					Min:        metricdata.NewExtrema(int64(500 * time.Millisecond)),
+					Max:        metricdata.NewExtrema(int64(3 * time.Second)),
+				},
+			},
+		},
+	}
+	latencyMetric := metricdata.Metrics{
+		Name: processingLatencyInstrument,
+		Data: metricdata.Histogram[int64]{
+			Temporality: metricdata.DeltaTemporality,
+			DataPoints: []metricdata.HistogramDataPoint[int64]{
+				{Attributes: targetAttrs, StartTime: startTime, Time: endTime, Count: 50},
+			},
+		},
+	}
+	errorsMetric := metricdata.Metrics{
+		Name: processingErrorsInstrument,
+		Data: metricdata.Sum[int64]{
+			Temporality: metricdata.DeltaTemporality,
+			IsMonotonic: true,
+			DataPoints: []metricdata.DataPoint[int64]{
+				{Attributes: targetAttrs, StartTime: startTime, Time: endTime, Value: 3},
+			},
+		},
+	}
+	unrelatedMetric := metricdata.Metrics{
+		Name: "pgstream.target.processing.message.size",
+		Data: metricdata.Histogram[int64]{
+			DataPoints: []metricdata.HistogramDataPoint[int64]{
+				{Attributes: targetAttrs, StartTime: startTime, Time: endTime, Count: 50},
+			},
+		},
+	}
+
+	newResourceMetrics := func(metrics ...metricdata.Metrics) *metricdata.ResourceMetrics {
+		return &metricdata.ResourceMetrics{
+			ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: metrics}},
+		}
+	}
+
+	targetDimensions := []types.Dimension{
+		{Name: aws.String("cluster"), Value: aws.String("eu-1")},
+		{Name: aws.String("env"), Value: aws.String("prod")},
+		{Name: aws.String("target"), Value: aws.String("postgres")},
+	}
+	newDatum := func(name string, unit types.StandardUnit, resolution int32) types.MetricDatum {
+		return types.MetricDatum{
+			MetricName:        aws.String(name),
+			Dimensions:        targetDimensions,
+			StorageResolution: aws.Int32(resolution),
+			Timestamp:         aws.Time(endTime),
+			Unit:              unit,
+		}
+	}
+	lagDatum := func(resolution int32) types.MetricDatum {
+		d := newDatum(ReplicationLagSecondsMetric, types.StandardUnitSeconds, resolution)
+		d.StatisticValues = &types.StatisticSet{
+			SampleCount: aws.Float64(4),
+			Sum:         aws.Float64(6),
+			Minimum:     aws.Float64(0.5),
+			Maximum:     aws.Float64(3),
+		}
+		return d
+	}
+	eventsPerSecondDatum := func(resolution int32) types.MetricDatum {
+		d := newDatum(EventsPerSecondMetric, types.StandardUnitCountSecond, resolution)
+		d.Value = aws.Float64(5)
+		return d
+	}
+	errorsDatum := func(resolution int32, value float64) types.MetricDatum {
+		d := newDatum(ErrorsTotalMetric, types.StandardUnitCount, resolution)
+		d.Value = aws.Float64(value)
+		return d
+	}
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name            string
+		cfg             *Config
+		resourceMetrics *metricdata.ResourceMetrics
+		putMetricDataFn func(input *cloudwatch.PutMetricDataInput) error
+
+		wantNamespace  string
+		wantMetricData []types.MetricDatum
+		wantErr        error
+	}{
+		{
+			name:            "ok",
+			cfg:             &Config{Namespace: "replication"},
+			resourceMetrics: newResourceMetrics(lagMetric, latencyMetric, errorsMetric, unrelatedMetric),
+
+			wantNamespace: "replication",
+			wantMetricData: []types.MetricDatum{
+				lagDatum(standardStorageResolution),
+				eventsPerSecondDatum(standardStorageResolution),
+				errorsDatum(standardStorageResolution, 3),
+			},
+		},
+		{
+			name:            "ok - high resolution with no errors",
+			cfg:             &Config{HighResolution: true},
+			resourceMetrics: newResourceMetrics(lagMetric, latencyMetric),
+
+			wantNamespace: defaultNamespace,
+			wantMetricData: []types.MetricDatum{
+				lagDatum(highStorageResolution),
+				eventsPerSecondDatum(highStorageResolution),
+				errorsDatum(highStorageResolution, 0),
+			},
+		},
+		{
+			name: "ok - lag without samples",
+			cfg:  &Config{},
+			resourceMetrics: newResourceMetrics(metricdata.Metrics{
+				Name: processingLagInstrument,
+				Data: metricdata.Histogram[int64]{
+					DataPoints: []metricdata.HistogramDataPoint[int64]{
+						{Attributes: targetAttrs, StartTime: startTime, Time: endTime},
+					},
+				},
+			}),
+
+			wantNamespace:  defaultNamespace,
+			wantMetricData: []types.MetricDatum{},
+		},
+		{
+			name:            "error - putting metric data",
+			cfg:             &Config{},
+			resourceMetrics: newResourceMetrics(latencyMetric),
+			putMetricDataFn: func(*cloudwatch.PutMetricDataInput) error { return errTest },
+
+			wantNamespace: defaultNamespace,
+			wantMetricData: []types.MetricDatum{
+				eventsPerSecondDatum(standardStorageResolution),
+				errorsDatum(standardStorageResolution, 0),
+			},
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &mockCloudWatchClient{putMetricDataFn: tc.putMetricDataFn}
+			tc.cfg.Dimensions = map[string]string{"env": "prod", "cluster": "eu-1"}
+			reporter := newCloudWatchReporter(client, tc.cfg)
+
+			err := reporter.Export(context.Background(), tc.resourceMetrics)
+			require.ErrorIs(t, err, tc.wantErr)
+
+			gotMetricData := []types.MetricDatum{}
+			for _, input := range client.inputs {
+				require.Equal(t, tc.wantNamespace, aws.ToString(input.Namespace))
+				gotMetricData = append(gotMetricData, input.MetricData...)
+			}
+			require.Equal(t, tc.wantMetricData, gotMetricData)
+		})
+	}
+}
+
+func TestCloudWatchReporter_Export_batching(t *testing.T) {
+	t.Parallel()
+
+	dataPoints := make([]metricdata.HistogramDataPoint[int64], 0, 1200)
+	for i := range 1200 {
+		dataPoints = append(dataPoints, metricdata.HistogramDataPoint[int64]{
+			Attributes: attribute.NewSet(attribute.String("target", fmt.Sprintf("target-%d", i))),
+			Count:      1,
+		})
+	}
+
+	client := &mockCloudWatchClient{}
+	reporter := newCloudWatchReporter(client, &Config{})
+	err := reporter.Export(context.Background(), &metricdata.ResourceMetrics{
+		ScopeMetrics: []metricdata.ScopeMetrics{
+			{
+				Metrics: []metricdata.Metrics{
+					{
+						Name: processingLatencyInstrument,
+						Data: metricdata.Histogram[int64]{DataPoints: dataPoints},
+					},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// events per second and errors for each of the targets
+	require.Len(t, client.inputs, 3)
+	require.Len(t, client.inputs[0].MetricData, maxMetricDataPerRequest)
+	require.Len(t, client.inputs[1].MetricData, maxMetricDataPerRequest)
+	require.Len(t, client.inputs[2].MetricData, 400)
+}
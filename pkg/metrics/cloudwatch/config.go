@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cloudwatch
+
+type Config struct {
+	// Namespace is the CloudWatch namespace the custom metrics are published
+	// under. Defaults to pgstream.
+	Namespace string
+	// Region is the AWS region of the CloudWatch API. Defaults to the region
+	// of the AWS default configuration (i.e. AWS_REGION).
+	Region string
+	// HighResolution publishes the metrics with a 1 second storage resolution
+	// instead of the standard 1 minute resolution. The metrics collection
+	// interval should be lowered accordingly.
+	HighResolution bool
+	// Dimensions are added to all the published metrics, i.e. to tell apart
+	// the metrics of multiple pgstream deployments.
+	Dimensions map[string]string
+}
+
+const (
+	defaultNamespace = "pgstream"
+
+	highStorageResolution     int32 = 1
+	standardStorageResolution int32 = 60
+)
+
+func (c *Config) namespace() string {
+	if c.Namespace != "" {
+		return c.Namespace
+	}
+	return defaultNamespace
+}
+
+func (c *Config) storageResolution() int32 {
+	if c.HighResolution {
+		return highStorageResolution
+	}
+	return standardStorageResolution
+}
@@ -0,0 +1,22 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheus
+
+type Config struct {
+	// ListenAddress is the host:port address the metrics HTTP server listens
+	// on. Defaults to :9464.
+	ListenAddress string
+}
+
+const (
+	defaultListenAddress = ":9464"
+
+	metricsPath = "/metrics"
+)
+
+func (c *Config) listenAddress() string {
+	if c.ListenAddress != "" {
+		return c.ListenAddress
+	}
+	return defaultListenAddress
+}
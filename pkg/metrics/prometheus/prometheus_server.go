@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	promclient "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprometheus "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// Server exposes the metrics of the meter provider it's registered with on
+// the /metrics HTTP endpoint, in the Prometheus text format. The metrics are
+// collected on every scrape, so the definitions are shared with any other
+// configured exporter.
+type Server struct {
+	reader   sdkmetric.Reader
+	server   *http.Server
+	listener net.Listener
+	serveErr chan error
+}
+
+const readHeaderTimeout = 10 * time.Second
+
+// NewServer returns a metrics server listening on the configured address. The
+// reader must be registered with the meter provider for the metrics to be
+// exposed, and the producers on input are collected along with them.
+func NewServer(cfg *Config, producers ...sdkmetric.Producer) (*Server, error) {
+	registry := promclient.NewRegistry()
+	opts := []otelprometheus.Option{otelprometheus.WithRegisterer(registry)}
+	for _, producer := range producers {
+		opts = append(opts, otelprometheus.WithProducer(producer))
+	}
+	reader, err := otelprometheus.New(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating prometheus exporter: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", cfg.listenAddress())
+	if err != nil {
+		return nil, fmt.Errorf("listening on prometheus metrics address %s: %w", cfg.listenAddress(), err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	s := &Server{
+		reader: reader,
+		server: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: readHeaderTimeout,
+		},
+		listener: listener,
+		serveErr: make(chan error, 1),
+	}
+
+	go func() {
+		defer close(s.serveErr)
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.serveErr <- err
+		}
+	}()
+
+	return s, nil
+}
+
+// Reader returns the metrics reader to register with the meter provider.
+func (s *Server) Reader() sdkmetric.Reader {
+	return s.reader
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Shutdown stops accepting new scrapes, and waits for the ongoing ones to
+// complete until the context is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shutting down prometheus metrics server: %w", err)
+	}
+	if err := <-s.serveErr; err != nil {
+		return fmt.Errorf("serving prometheus metrics: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package prometheus
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer(&Config{ListenAddress: "127.0.0.1:0"})
+	require.NoError(t, err)
+
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(s.Reader()))
+	counter, err := mp.Meter("test").Int64Counter("pgstream.test.events")
+	require.NoError(t, err)
+	counter.Add(context.Background(), 3)
+
+	resp, err := http.Get("http://" + s.Addr() + metricsPath)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Contains(t, string(body), "pgstream_test_events_total")
+
+	require.NoError(t, s.Shutdown(context.Background()))
+	require.NoError(t, mp.Shutdown(context.Background()))
+
+	_, err = http.Get("http://" + s.Addr() + metricsPath)
+	require.Error(t, err)
+}
+
+func TestNewServer_addressInUse(t *testing.T) {
+	t.Parallel()
+
+	s, err := NewServer(&Config{ListenAddress: "127.0.0.1:0"})
+	require.NoError(t, err)
+	defer s.Shutdown(context.Background())
+
+	_, err = NewServer(&Config{ListenAddress: s.Addr()})
+	require.Error(t, err)
+}
@@ -2,7 +2,12 @@
 
 package otel
 
-import "time"
+import (
+	"time"
+
+	"github.com/xataio/pgstream/pkg/metrics/cloudwatch"
+	"github.com/xataio/pgstream/pkg/metrics/prometheus"
+)
 
 type Config struct {
 	Metrics *MetricsConfig
@@ -12,6 +17,17 @@ type Config struct {
 type MetricsConfig struct {
 	Endpoint           string
 	CollectionInterval time.Duration
+	// CloudWatch publishes the replication health metrics to AWS CloudWatch
+	// when set. It can be used alongside the OTLP endpoint.
+	CloudWatch *cloudwatch.Config
+	// Prometheus exposes the metrics on a /metrics HTTP endpoint to be scraped
+	// by Prometheus when set. It can be used alongside the other exporters.
+	Prometheus *prometheus.Config
+	// MaxTableCardinality is the max number of distinct tables labelled in the
+	// per table metrics. Any other tables are reported under the "other"
+	// bucket. Defaults to 1000, and a negative value disables the per table
+	// labels.
+	MaxTableCardinality int
 }
 
 type TracesConfig struct {
@@ -21,6 +37,13 @@ type TracesConfig struct {
 
 const defaultCollectionInterval = 60 * time.Second
 
+func (c *MetricsConfig) maxTableCardinality() int {
+	if c.MaxTableCardinality != 0 {
+		return c.MaxTableCardinality
+	}
+	return defaultMaxTableCardinality
+}
+
 func (c *MetricsConfig) collectionInterval() time.Duration {
 	if c.CollectionInterval != 0 {
 		return c.CollectionInterval
@@ -3,18 +3,26 @@
 package otel
 
 import (
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type InstrumentationProvider interface {
-	NewInstrumentation(name string) *Instrumentation
+	// NewInstrumentation returns the instrumentation for the name on input.
+	// The attributes provided will be added to all the metrics and traces
+	// produced by it.
+	NewInstrumentation(name string, attrs ...attribute.KeyValue) *Instrumentation
 	Close() error
 }
 
 type Instrumentation struct {
 	Meter  metric.Meter
 	Tracer trace.Tracer
+	// Tables provides the attributes of the per table metrics, shared by all
+	// the instrumentations of the provider so that the cardinality is bounded
+	// globally.
+	Tables *TableAttributes
 }
 
 func (i *Instrumentation) IsEnabled() bool {
@@ -23,7 +31,7 @@ func (i *Instrumentation) IsEnabled() bool {
 
 type noopProvider struct{}
 
-func (p *noopProvider) NewInstrumentation(name string) *Instrumentation {
+func (p *noopProvider) NewInstrumentation(name string, attrs ...attribute.KeyValue) *Instrumentation {
 	return nil
 }
 
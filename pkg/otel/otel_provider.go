@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/xataio/pgstream/pkg/metrics/cloudwatch"
+	"github.com/xataio/pgstream/pkg/metrics/prometheus"
 	"go.opentelemetry.io/contrib/instrumentation/runtime"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/metric"
@@ -25,6 +28,7 @@ import (
 type Provider struct {
 	meterProvider  metric.MeterProvider
 	tracerProvider trace.TracerProvider
+	tableAttrs     *TableAttributes
 	shutdownFns    []func(context.Context) error
 }
 
@@ -52,10 +56,11 @@ func (o *Provider) Tracer(name string) trace.Tracer {
 	return o.tracerProvider.Tracer(name)
 }
 
-func (o *Provider) NewInstrumentation(name string) *Instrumentation {
+func (o *Provider) NewInstrumentation(name string, attrs ...attribute.KeyValue) *Instrumentation {
 	return &Instrumentation{
-		Meter:  o.Meter(name),
-		Tracer: o.Tracer(name),
+		Meter:  o.meterProvider.Meter(name, metric.WithInstrumentationAttributes(attrs...)),
+		Tracer: o.tracerProvider.Tracer(name, trace.WithInstrumentationAttributes(attrs...)),
+		Tables: o.tableAttrs,
 	}
 }
 
@@ -79,23 +84,46 @@ func (o *Provider) initMeterProvider(ctx context.Context, metricsConfig *Metrics
 		return nil
 	}
 
-	metricsExporter, err := otlpmetricgrpc.New(ctx,
-		otlpmetricgrpc.WithTemporalitySelector(deltaSelector),
-		otlpmetricgrpc.WithInsecure(),
-		otlpmetricgrpc.WithEndpoint(metricsConfig.Endpoint))
-	if err != nil {
-		return err
+	o.tableAttrs = NewTableAttributes(metricsConfig.maxTableCardinality())
+
+	opts := []sdkmetric.Option{sdkmetric.WithResource(newResource())}
+	if metricsConfig.Endpoint != "" {
+		metricsExporter, err := otlpmetricgrpc.New(ctx,
+			otlpmetricgrpc.WithTemporalitySelector(deltaSelector),
+			otlpmetricgrpc.WithInsecure(),
+			otlpmetricgrpc.WithEndpoint(metricsConfig.Endpoint))
+		if err != nil {
+			return err
+		}
+
+		// periodic reader collects and exports metrics to the exporter at the
+		// defined interval (defaults to 60s)
+		opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricsExporter,
+			sdkmetric.WithInterval(metricsConfig.collectionInterval()),
+			sdkmetric.WithProducer(runtime.NewProducer()))))
 	}
 
-	// periodic reader collects and exports metrics to the exporter at the
-	// defined interval (defaults to 60s)
-	reader := sdkmetric.NewPeriodicReader(metricsExporter,
-		sdkmetric.WithInterval(metricsConfig.collectionInterval()),
-		sdkmetric.WithProducer(runtime.NewProducer()))
+	if metricsConfig.CloudWatch != nil {
+		cloudwatchReporter, err := cloudwatch.NewCloudWatchReporter(ctx, metricsConfig.CloudWatch)
+		if err != nil {
+			return fmt.Errorf("initialising cloudwatch metrics reporter: %w", err)
+		}
+		opts = append(opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(cloudwatchReporter,
+			sdkmetric.WithInterval(metricsConfig.collectionInterval()))))
+	}
+
+	if metricsConfig.Prometheus != nil {
+		prometheusServer, err := prometheus.NewServer(metricsConfig.Prometheus, runtime.NewProducer())
+		if err != nil {
+			return fmt.Errorf("initialising prometheus metrics server: %w", err)
+		}
+		opts = append(opts, sdkmetric.WithReader(prometheusServer.Reader()))
+		// the server stops serving scrapes before the meter provider is shut
+		// down
+		o.shutdownFns = append(o.shutdownFns, prometheusServer.Shutdown)
+	}
 
-	mp := sdkmetric.NewMeterProvider(
-		sdkmetric.WithResource(newResource()),
-		sdkmetric.WithReader(reader))
+	mp := sdkmetric.NewMeterProvider(opts...)
 	o.shutdownFns = append(o.shutdownFns, mp.Shutdown)
 
 	o.meterProvider = mp
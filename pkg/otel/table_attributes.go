@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package otel
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TableAttributes provides the schema and table attributes of the per table
+// metrics, bounding their cardinality. Once the max number of tables has been
+// reached, any new table is reported under the "other" bucket.
+type TableAttributes struct {
+	maxTables int
+
+	mutex  sync.RWMutex
+	tables map[tableKey][]attribute.KeyValue
+}
+
+type tableKey struct {
+	schema string
+	table  string
+}
+
+const (
+	SchemaAttributeKey = "schema"
+	TableAttributeKey  = "table"
+
+	// OtherTablesAttributeValue is the schema and table attribute value of the
+	// tables exceeding the cardinality cap.
+	OtherTablesAttributeValue = "other"
+
+	defaultMaxTableCardinality = 1000
+)
+
+var otherTableAttributes = []attribute.KeyValue{
+	attribute.String(SchemaAttributeKey, OtherTablesAttributeValue),
+	attribute.String(TableAttributeKey, OtherTablesAttributeValue),
+}
+
+// NewTableAttributes returns a table attributes provider that labels up to
+// maxTables distinct tables. A negative value reports all tables under the
+// "other" bucket.
+func NewTableAttributes(maxTables int) *TableAttributes {
+	return &TableAttributes{
+		maxTables: maxTables,
+		tables:    map[tableKey][]attribute.KeyValue{},
+	}
+}
+
+// Get returns the schema and table attributes for the table on input, or the
+// "other" bucket ones if the cardinality cap has been reached. A nil provider
+// doesn't bound the cardinality.
+func (t *TableAttributes) Get(schema, table string) []attribute.KeyValue {
+	if t == nil {
+		return tableAttributes(schema, table)
+	}
+
+	key := tableKey{schema: schema, table: table}
+	t.mutex.RLock()
+	attrs, found := t.tables[key]
+	t.mutex.RUnlock()
+	if found {
+		return attrs
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if attrs, found := t.tables[key]; found {
+		return attrs
+	}
+	if len(t.tables) >= t.maxTables {
+		return otherTableAttributes
+	}
+	attrs = tableAttributes(schema, table)
+	t.tables[key] = attrs
+	return attrs
+}
+
+func tableAttributes(schema, table string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String(SchemaAttributeKey, schema),
+		attribute.String(TableAttributeKey, table),
+	}
+}
@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package otel
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestTableAttributes_Get(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		attributes *TableAttributes
+		tables     []tableKey
+
+		wantAttributes [][]attribute.KeyValue
+	}{
+		{
+			name:       "ok - within the cardinality cap",
+			attributes: NewTableAttributes(2),
+			tables:     []tableKey{{"public", "a"}, {"public", "b"}, {"public", "a"}},
+
+			wantAttributes: [][]attribute.KeyValue{
+				tableAttributes("public", "a"),
+				tableAttributes("public", "b"),
+				tableAttributes("public", "a"),
+			},
+		},
+		{
+			name:       "ok - cardinality cap reached",
+			attributes: NewTableAttributes(1),
+			tables:     []tableKey{{"public", "a"}, {"public", "b"}, {"public", "a"}},
+
+			wantAttributes: [][]attribute.KeyValue{
+				tableAttributes("public", "a"),
+				otherTableAttributes,
+				tableAttributes("public", "a"),
+			},
+		},
+		{
+			name:       "ok - negative cardinality cap",
+			attributes: NewTableAttributes(-1),
+			tables:     []tableKey{{"public", "a"}},
+
+			wantAttributes: [][]attribute.KeyValue{otherTableAttributes},
+		},
+		{
+			name:       "ok - nil attributes are not bounded",
+			attributes: nil,
+			tables:     []tableKey{{"public", "a"}},
+
+			wantAttributes: [][]attribute.KeyValue{tableAttributes("public", "a")},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			attrs := make([][]attribute.KeyValue, 0, len(tc.tables))
+			for _, table := range tc.tables {
+				attrs = append(attrs, tc.attributes.Get(table.schema, table.table))
+			}
+			require.Equal(t, tc.wantAttributes, attrs)
+		})
+	}
+}
+
+func TestTableAttributes_Get_concurrent(t *testing.T) {
+	t.Parallel()
+
+	attributes := NewTableAttributes(10)
+	wg := sync.WaitGroup{}
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attributes.Get("public", fmt.Sprintf("table_%d", i))
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, attributes.tables, 10)
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package schemalog
+
+import (
+	"slices"
+	"sync"
+)
+
+// IndexCache keeps the primary key, unique and foreign key lookups of the
+// tables of the schema log entries it's updated with, so that they can be
+// answered by schema, table and column without going through the table
+// definitions. It is concurrency safe.
+type IndexCache struct {
+	mutex   *sync.RWMutex
+	schemas map[string]*schemaIndex
+}
+
+type schemaIndex struct {
+	version int64
+	tables  map[string]*tableIndex
+}
+
+type tableIndex struct {
+	primaryKey  []string
+	unique      map[string]struct{}
+	foreignKeys map[string][]ForeignKey
+}
+
+func NewIndexCache() *IndexCache {
+	return &IndexCache{
+		mutex:   &sync.RWMutex{},
+		schemas: make(map[string]*schemaIndex),
+	}
+}
+
+// Update replaces the indexes of the schema of the log entry on input, unless
+// a more recent version of the schema has already been cached. The indexes of
+// dropped schemas are removed.
+func (c *IndexCache) Update(entry *LogEntry) {
+	if entry == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if cached, found := c.schemas[entry.SchemaName]; found && cached.version > entry.Version {
+		return
+	}
+	if entry.Schema.Dropped {
+		delete(c.schemas, entry.SchemaName)
+		return
+	}
+
+	index := &schemaIndex{
+		version: entry.Version,
+		tables:  make(map[string]*tableIndex, len(entry.Schema.Tables)),
+	}
+	for i := range entry.Schema.Tables {
+		table := &entry.Schema.Tables[i]
+		index.tables[table.Name] = newTableIndex(table)
+	}
+	c.schemas[entry.SchemaName] = index
+}
+
+// IsPrimaryKey returns true if the column is part of the primary key of the
+// table, whether it's a single or composite key.
+func (c *IndexCache) IsPrimaryKey(schema, table, column string) bool {
+	index := c.getTableIndex(schema, table)
+	return index != nil && slices.Contains(index.primaryKey, column)
+}
+
+// IsUnique returns true if the column values are unique on their own, as
+// defined by Table.IsUnique.
+func (c *IndexCache) IsUnique(schema, table, column string) bool {
+	index := c.getTableIndex(schema, table)
+	if index == nil {
+		return false
+	}
+	_, found := index.unique[column]
+	return found
+}
+
+// ForeignKeys returns the foreign keys of the table that include the column on
+// input, whether they're single or composite foreign keys.
+func (c *IndexCache) ForeignKeys(schema, table, column string) []ForeignKey {
+	index := c.getTableIndex(schema, table)
+	if index == nil {
+		return nil
+	}
+	return index.foreignKeys[column]
+}
+
+func (c *IndexCache) getTableIndex(schema, table string) *tableIndex {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	index, found := c.schemas[schema]
+	if !found {
+		return nil
+	}
+	return index.tables[table]
+}
+
+func newTableIndex(table *Table) *tableIndex {
+	index := &tableIndex{
+		primaryKey:  slices.Clone(table.PrimaryKeyColumns),
+		unique:      make(map[string]struct{}),
+		foreignKeys: make(map[string][]ForeignKey),
+	}
+	for _, col := range table.Columns {
+		if table.IsUnique(col.Name) {
+			index.unique[col.Name] = struct{}{}
+		}
+	}
+	for _, fk := range table.ForeignKeys {
+		for _, col := range fk.Columns() {
+			index.foreignKeys[col] = append(index.foreignKeys[col], fk)
+		}
+	}
+	return index
+}
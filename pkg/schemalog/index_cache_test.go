@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package schemalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexCache(t *testing.T) {
+	t.Parallel()
+
+	const testSchema = "public"
+	userFK := ForeignKey{Name: "orders_user_fk", Definition: "FOREIGN KEY (user_id) REFERENCES users(id)"}
+	testLogEntry := func(version int64, tables ...Table) *LogEntry {
+		return &LogEntry{
+			SchemaName: testSchema,
+			Version:    version,
+			Schema:     Schema{Tables: tables},
+		}
+	}
+	ordersTable := Table{
+		Name: "orders",
+		Columns: []Column{
+			{Name: "id"},
+			{Name: "user_id"},
+			{Name: "reference", Unique: true},
+		},
+		PrimaryKeyColumns: []string{"id"},
+		ForeignKeys:       []ForeignKey{userFK},
+	}
+
+	c := NewIndexCache()
+	c.Update(testLogEntry(1, ordersTable))
+
+	require.True(t, c.IsPrimaryKey(testSchema, "orders", "id"))
+	require.False(t, c.IsPrimaryKey(testSchema, "orders", "user_id"))
+	require.True(t, c.IsUnique(testSchema, "orders", "id"))
+	require.True(t, c.IsUnique(testSchema, "orders", "reference"))
+	require.False(t, c.IsUnique(testSchema, "orders", "user_id"))
+	require.Equal(t, []ForeignKey{userFK}, c.ForeignKeys(testSchema, "orders", "user_id"))
+	require.Empty(t, c.ForeignKeys(testSchema, "orders", "id"))
+
+	// unknown schemas and tables
+	require.False(t, c.IsPrimaryKey("other", "orders", "id"))
+	require.False(t, c.IsUnique(testSchema, "users", "id"))
+	require.Nil(t, c.ForeignKeys(testSchema, "users", "id"))
+
+	// schema change moving the primary key and dropping the foreign key
+	updatedOrdersTable := ordersTable
+	updatedOrdersTable.PrimaryKeyColumns = []string{"reference"}
+	updatedOrdersTable.ForeignKeys = nil
+	c.Update(testLogEntry(2, updatedOrdersTable))
+
+	require.False(t, c.IsPrimaryKey(testSchema, "orders", "id"))
+	require.True(t, c.IsPrimaryKey(testSchema, "orders", "reference"))
+	require.Empty(t, c.ForeignKeys(testSchema, "orders", "user_id"))
+
+	// older versions are ignored
+	c.Update(testLogEntry(1, ordersTable))
+	require.True(t, c.IsPrimaryKey(testSchema, "orders", "reference"))
+
+	// dropped schemas are removed
+	dropped := testLogEntry(3)
+	dropped.Schema.Dropped = true
+	c.Update(dropped)
+	require.False(t, c.IsPrimaryKey(testSchema, "orders", "reference"))
+}
@@ -4,27 +4,53 @@ package instrumentation
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
+	"github.com/rs/xid"
 	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/schemalog"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type Store struct {
-	inner  schemalog.Store
-	tracer trace.Tracer
+	inner   schemalog.Store
+	tracer  trace.Tracer
+	meter   metric.Meter
+	metrics *metrics
+
+	// column counts per schema and table, refreshed whenever a new schema log
+	// entry is fetched or acked for the schema
+	mutex        sync.RWMutex
+	logEntryIDs  map[string]xid.ID
+	columnCounts map[string]map[string]int64
+}
+
+type metrics struct {
+	tableColumnCount metric.Int64ObservableGauge
 }
 
-func NewStore(inner schemalog.Store, instrumentation *otel.Instrumentation) schemalog.Store {
+func NewStore(inner schemalog.Store, instrumentation *otel.Instrumentation) (schemalog.Store, error) {
 	if instrumentation == nil {
-		return inner
+		return inner, nil
 	}
 
-	return &Store{
-		inner:  inner,
-		tracer: instrumentation.Tracer,
+	s := &Store{
+		inner:        inner,
+		tracer:       instrumentation.Tracer,
+		meter:        instrumentation.Meter,
+		metrics:      &metrics{},
+		logEntryIDs:  map[string]xid.ID{},
+		columnCounts: map[string]map[string]int64{},
 	}
+
+	if err := s.initMetrics(); err != nil {
+		return nil, fmt.Errorf("error initialising schema log store metrics: %w", err)
+	}
+
+	return s, nil
 }
 
 func (s *Store) Insert(ctx context.Context, schemaName string) (le *schemalog.LogEntry, err error) {
@@ -36,7 +62,12 @@ func (s *Store) Insert(ctx context.Context, schemaName string) (le *schemalog.Lo
 func (s *Store) FetchLast(ctx context.Context, schemaName string, acked bool) (le *schemalog.LogEntry, err error) {
 	ctx, span := otel.StartSpan(ctx, s.tracer, "schemalogstore.FetchLast", trace.WithAttributes(attribute.String("schema", schemaName)))
 	defer otel.CloseSpan(span, err)
-	return s.inner.FetchLast(ctx, schemaName, acked)
+	le, err = s.inner.FetchLast(ctx, schemaName, acked)
+	if err != nil {
+		return nil, err
+	}
+	s.refreshColumnCounts(le)
+	return le, nil
 }
 
 func (s *Store) Fetch(ctx context.Context, schemaName string, version int) (le *schemalog.LogEntry, err error) {
@@ -45,12 +76,74 @@ func (s *Store) Fetch(ctx context.Context, schemaName string, version int) (le *
 	return s.inner.Fetch(ctx, schemaName, version)
 }
 
+func (s *Store) FetchSince(ctx context.Context, schemaName string, version int) (les []*schemalog.LogEntry, err error) {
+	ctx, span := otel.StartSpan(ctx, s.tracer, "schemalogstore.FetchSince", trace.WithAttributes(attribute.String("schema", schemaName)))
+	defer otel.CloseSpan(span, err)
+	return s.inner.FetchSince(ctx, schemaName, version)
+}
+
 func (s *Store) Ack(ctx context.Context, le *schemalog.LogEntry) (err error) {
 	ctx, span := otel.StartSpan(ctx, s.tracer, "schemalogstore.Ack", trace.WithAttributes(attribute.String("schema", le.SchemaName)))
 	defer otel.CloseSpan(span, err)
-	return s.inner.Ack(ctx, le)
+	if err := s.inner.Ack(ctx, le); err != nil {
+		return err
+	}
+	s.refreshColumnCounts(le)
+	return nil
 }
 
 func (s *Store) Close() error {
 	return s.inner.Close()
 }
+
+func (s *Store) initMetrics() error {
+	if s.meter == nil {
+		return nil
+	}
+
+	var err error
+	s.metrics.tableColumnCount, err = s.meter.Int64ObservableGauge("pgstream.table.column_count",
+		metric.WithUnit("columns"),
+		metric.WithDescription("Number of columns of the table in the latest schema log entry"))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		s.mutex.RLock()
+		defer s.mutex.RUnlock()
+		for schemaName, tables := range s.columnCounts {
+			for tableName, count := range tables {
+				o.ObserveInt64(s.metrics.tableColumnCount, count, metric.WithAttributes(
+					attribute.String("schema", schemaName),
+					attribute.String("table", tableName),
+				))
+			}
+		}
+		return nil
+	}, s.metrics.tableColumnCount)
+	return err
+}
+
+// refreshColumnCounts updates the table column counts for the schema of the
+// log entry on input. The counts are only recomputed when the log entry is
+// different from the last one seen for the schema, since the cached entries
+// are returned on most calls.
+func (s *Store) refreshColumnCounts(le *schemalog.LogEntry) {
+	if le == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if id, found := s.logEntryIDs[le.SchemaName]; found && id == le.ID {
+		return
+	}
+
+	tables := make(map[string]int64, len(le.Schema.Tables))
+	for _, table := range le.Schema.Tables {
+		tables[table.Name] = int64(len(table.Columns))
+	}
+	s.logEntryIDs[le.SchemaName] = le.ID
+	s.columnCounts[le.SchemaName] = tables
+}
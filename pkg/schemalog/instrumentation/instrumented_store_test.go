@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package instrumentation
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	schemalogmocks "github.com/xataio/pgstream/pkg/schemalog/mocks"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestStore_tableColumnCount(t *testing.T) {
+	t.Parallel()
+
+	newLogEntry := func(tables ...schemalog.Table) *schemalog.LogEntry {
+		return &schemalog.LogEntry{
+			ID:         xid.New(),
+			SchemaName: "public",
+			Schema:     schemalog.Schema{Tables: tables},
+		}
+	}
+	usersTable := schemalog.Table{Name: "users", Columns: []schemalog.Column{{Name: "id"}, {Name: "name"}}}
+	ordersTable := schemalog.Table{Name: "orders", Columns: []schemalog.Column{{Name: "id"}}}
+
+	fetchedEntry := newLogEntry(usersTable, ordersTable)
+	usersTable.Columns = append(usersTable.Columns, schemalog.Column{Name: "email"})
+	ackedEntry := newLogEntry(usersTable)
+
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	store, err := NewStore(&schemalogmocks.Store{
+		FetchLastFn: func(ctx context.Context, schemaName string, ackedOnly bool) (*schemalog.LogEntry, error) {
+			return fetchedEntry, nil
+		},
+		AckFn: func(ctx context.Context, le *schemalog.LogEntry) error {
+			return nil
+		},
+	}, &otel.Instrumentation{Meter: meterProvider.Meter("test")})
+	require.NoError(t, err)
+
+	collect := func() map[string]int64 {
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		counts := map[string]int64{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name != "pgstream.table.column_count" {
+					continue
+				}
+				for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+					schemaName, _ := dp.Attributes.Value(attribute.Key("schema"))
+					tableName, _ := dp.Attributes.Value(attribute.Key("table"))
+					counts[schemaName.AsString()+"."+tableName.AsString()] = dp.Value
+				}
+			}
+		}
+		return counts
+	}
+
+	require.Empty(t, collect())
+
+	_, err = store.FetchLast(context.Background(), "public", true)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"public.users": 2, "public.orders": 1}, collect())
+
+	// the acked entry replaces the previous one for the schema
+	require.NoError(t, store.Ack(context.Background(), ackedEntry))
+	require.Equal(t, map[string]int64{"public.users": 3}, collect())
+
+	// the same entry doesn't trigger a refresh
+	ackedEntry.Schema.Tables = nil
+	require.NoError(t, store.Ack(context.Background(), ackedEntry))
+	require.Equal(t, map[string]int64{"public.users": 3}, collect())
+}
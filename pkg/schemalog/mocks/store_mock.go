@@ -10,14 +10,15 @@ import (
 )
 
 type Store struct {
-	InsertFn    func(ctx context.Context, schemaName string) (*schemalog.LogEntry, error)
-	FetchLastFn func(ctx context.Context, schemaName string, ackedOnly bool) (*schemalog.LogEntry, error)
-	FetchFn     func(ctx context.Context, schemaName string, version int) (*schemalog.LogEntry, error)
-	AckFn       func(ctx context.Context, le *schemalog.LogEntry) error
-	CloseFn     func() error
-	insertCalls uint64
-	fetchCalls  uint64
-	ackCalls    uint64
+	InsertFn     func(ctx context.Context, schemaName string) (*schemalog.LogEntry, error)
+	FetchLastFn  func(ctx context.Context, schemaName string, ackedOnly bool) (*schemalog.LogEntry, error)
+	FetchFn      func(ctx context.Context, schemaName string, version int) (*schemalog.LogEntry, error)
+	FetchSinceFn func(ctx context.Context, schemaName string, version int) ([]*schemalog.LogEntry, error)
+	AckFn        func(ctx context.Context, le *schemalog.LogEntry) error
+	CloseFn      func() error
+	insertCalls  uint64
+	fetchCalls   uint64
+	ackCalls     uint64
 }
 
 var _ schemalog.Store = (*Store)(nil)
@@ -37,6 +38,11 @@ func (m *Store) Fetch(ctx context.Context, schemaName string, version int) (*sch
 	return m.FetchFn(ctx, schemaName, version)
 }
 
+func (m *Store) FetchSince(ctx context.Context, schemaName string, version int) ([]*schemalog.LogEntry, error) {
+	atomic.AddUint64(&m.fetchCalls, 1)
+	return m.FetchSinceFn(ctx, schemaName, version)
+}
+
 func (m *Store) Ack(ctx context.Context, le *schemalog.LogEntry) error {
 	atomic.AddUint64(&m.ackCalls, 1)
 	return m.AckFn(ctx, le)
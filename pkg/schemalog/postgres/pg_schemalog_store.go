@@ -95,6 +95,33 @@ func (s *Store) Fetch(ctx context.Context, schemaName string, version int) (*sch
 	return l, nil
 }
 
+// FetchSince retrieves the log entries for the schema provided with a version
+// greater than the one on input, ordered by version.
+func (s *Store) FetchSince(ctx context.Context, schemaName string, version int) ([]*schemalog.LogEntry, error) {
+	sql := fmt.Sprintf(`select id, version, schema_name, schema, created_at, acked from %s where schema_name = $1 and version > $2 order by version`, s.table())
+
+	rows, err := s.querier.Query(ctx, sql, schemaName, version)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching schema logs for schema %s since version %d: %w", schemaName, version, err)
+	}
+	defer rows.Close()
+
+	entries := []*schemalog.LogEntry{}
+	for rows.Next() {
+		l := &schemalog.LogEntry{}
+		if err := rows.Scan(&l.ID, &l.Version, &l.SchemaName, &l.Schema, &l.CreatedAt, &l.Acked); err != nil {
+			return nil, fmt.Errorf("error scanning schema log for schema %s: %w", schemaName, err)
+		}
+		entries = append(entries, l)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching schema logs for schema %s since version %d: %w", schemaName, version, err)
+	}
+
+	return entries, nil
+}
+
 func (s *Store) Ack(ctx context.Context, logEntry *schemalog.LogEntry) error {
 	sql := fmt.Sprintf(`update %s set acked = true where id = $1 and schema_name = $2`, s.table())
 	_, err := s.querier.Exec(ctx, sql, logEntry.ID.String(), logEntry.SchemaName)
@@ -168,6 +168,107 @@ func TestStore_Fetch(t *testing.T) {
 	}
 }
 
+func TestStore_FetchSince(t *testing.T) {
+	t.Parallel()
+
+	testSchema := "test_schema"
+	testIDs := []xid.ID{xid.New(), xid.New()}
+	testVersion := 1
+	errTest := errors.New("oh noes")
+
+	testRows := func(scanErr, rowsErr error) *pgmocks.Rows {
+		return &pgmocks.Rows{
+			NextFn: func(i uint) bool { return i <= uint(len(testIDs)) },
+			ScanFn: func(i uint, dest ...any) error {
+				if scanErr != nil {
+					return scanErr
+				}
+				require.Len(t, dest, 6)
+				id, ok := dest[0].(*xid.ID)
+				require.True(t, ok, "expected first dest arg to be of type *xid.ID, got %T", dest[0])
+				*id = testIDs[i-1]
+				version, ok := dest[1].(*int64)
+				require.True(t, ok, "expected second dest arg to be of type *int64, got %T", dest[1])
+				*version = int64(testVersion) + int64(i)
+				return nil
+			},
+			ErrFn: func() error { return rowsErr },
+		}
+	}
+
+	tests := []struct {
+		name    string
+		querier pglib.Querier
+
+		wantLogEntries []*schemalog.LogEntry
+		wantErr        error
+	}{
+		{
+			name: "ok",
+			querier: &pgmocks.Querier{
+				QueryFn: func(_ context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					require.Equal(t, []any{testSchema, testVersion}, args)
+					require.Equal(t,
+						fmt.Sprintf("select id, version, schema_name, schema, created_at, acked from %q.%q where schema_name = $1 and version > $2 order by version", schemalog.SchemaName, schemalog.TableName),
+						query)
+					return testRows(nil, nil), nil
+				},
+			},
+
+			wantLogEntries: []*schemalog.LogEntry{
+				{ID: testIDs[0], Version: 2},
+				{ID: testIDs[1], Version: 3},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "error - querying rows",
+			querier: &pgmocks.Querier{
+				QueryFn: func(_ context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					return nil, errTest
+				},
+			},
+
+			wantLogEntries: nil,
+			wantErr:        errTest,
+		},
+		{
+			name: "error - scanning row",
+			querier: &pgmocks.Querier{
+				QueryFn: func(_ context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					return testRows(errTest, nil), nil
+				},
+			},
+
+			wantLogEntries: nil,
+			wantErr:        errTest,
+		},
+		{
+			name: "error - rows error",
+			querier: &pgmocks.Querier{
+				QueryFn: func(_ context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					return testRows(nil, errTest), nil
+				},
+			},
+
+			wantLogEntries: nil,
+			wantErr:        errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := NewStoreWithQuerier(tc.querier)
+
+			logEntries, err := s.FetchSince(context.Background(), testSchema, testVersion)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantLogEntries, logEntries)
+		})
+	}
+}
+
 func TestStore_Ack(t *testing.T) {
 	t.Parallel()
 
@@ -77,11 +77,25 @@ type Constraint struct {
 	Definition string `json:"definition"`
 }
 
+// CheckConstraint is a table CHECK constraint, with the boolean expression
+// every row of the table must satisfy.
+type CheckConstraint struct {
+	Name       string
+	Expression string
+}
+
+const CheckConstraintType = "CHECK"
+
 type ForeignKey struct {
 	Name       string `json:"name"`
 	Definition string `json:"definition"`
 }
 
+const (
+	foreignKeyPrefix  = "FOREIGN KEY ("
+	referencesKeyword = " REFERENCES "
+)
+
 func (s *Schema) MarshalJSON() ([]byte, error) {
 	if s == nil {
 		return nil, nil
@@ -230,6 +244,114 @@ func (t *Table) GetColumnByName(name string) (Column, bool) {
 	return Column{}, false
 }
 
+// IsPrimaryKey returns true if the column is part of the table primary key,
+// whether it's a single or composite key.
+func (t *Table) IsPrimaryKey(column string) bool {
+	return slices.Contains(t.PrimaryKeyColumns, column)
+}
+
+// IsUnique returns true if the column values are unique on their own, either
+// because the column is flagged as unique, it's the single column primary key
+// or there's a single column unique index on it. Columns that are only part of
+// a composite unique index or primary key are not unique on their own.
+func (t *Table) IsUnique(column string) bool {
+	if c, found := t.GetColumnByName(column); found && c.Unique {
+		return true
+	}
+	if len(t.PrimaryKeyColumns) == 1 && t.PrimaryKeyColumns[0] == column {
+		return true
+	}
+	for _, idx := range t.Indexes {
+		if idx.Unique && len(idx.Columns) == 1 && idx.Columns[0] == column {
+			return true
+		}
+	}
+	return false
+}
+
+// ColumnForeignKeys returns the foreign keys of the table that include the
+// column on input, whether it's a single or composite foreign key.
+func (t *Table) ColumnForeignKeys(column string) []ForeignKey {
+	fks := []ForeignKey{}
+	for _, fk := range t.ForeignKeys {
+		if slices.Contains(fk.Columns(), column) {
+			fks = append(fks, fk)
+		}
+	}
+	return fks
+}
+
+// Columns returns the columns of the foreign key, extracted from its
+// definition (i.e. `FOREIGN KEY (user_id) REFERENCES users(id)`). It returns
+// nil if the definition can't be parsed.
+func (fk ForeignKey) Columns() []string {
+	rest, found := strings.CutPrefix(fk.Definition, foreignKeyPrefix)
+	if !found {
+		return nil
+	}
+	columns, _, found := strings.Cut(rest, ")")
+	if !found {
+		return nil
+	}
+	return parseIdentifierList(columns)
+}
+
+// References returns the table, qualified with its schema unless it's in the
+// search path, and the columns referenced by the foreign key, extracted from
+// its definition. It returns an empty table if the definition can't be
+// parsed.
+func (fk ForeignKey) References() (string, []string) {
+	_, rest, found := strings.Cut(fk.Definition, referencesKeyword)
+	if !found {
+		return "", nil
+	}
+	table, rest, found := strings.Cut(rest, "(")
+	if !found {
+		return "", nil
+	}
+	columns, _, found := strings.Cut(rest, ")")
+	if !found {
+		return "", nil
+	}
+	return table, parseIdentifierList(columns)
+}
+
+// parseIdentifierList returns the unquoted identifiers of the comma separated
+// list on input. The identifiers are quoted by postgres when needed.
+func parseIdentifierList(list string) []string {
+	identifiers := []string{}
+	for _, identifier := range strings.Split(list, ",") {
+		identifier = strings.TrimSpace(identifier)
+		if unquoted, found := strings.CutPrefix(identifier, `"`); found {
+			identifier = strings.ReplaceAll(strings.TrimSuffix(unquoted, `"`), `""`, `"`)
+		}
+		identifiers = append(identifiers, identifier)
+	}
+	return identifiers
+}
+
+// CheckConstraints returns the CHECK constraints of the table, with their
+// expression extracted from the constraint definition (i.e. `(price > 0)` for
+// `CHECK ((price > 0)) NOT VALID`).
+func (t *Table) CheckConstraints() []CheckConstraint {
+	checks := []CheckConstraint{}
+	for _, c := range t.Constraints {
+		if c.Type != CheckConstraintType {
+			continue
+		}
+		expression := strings.TrimSpace(c.Definition)
+		expression = strings.TrimSuffix(expression, " NOT VALID")
+		expression = strings.TrimSuffix(expression, " NO INHERIT")
+		expression = strings.TrimPrefix(expression, "CHECK (")
+		expression = strings.TrimSuffix(expression, ")")
+		checks = append(checks, CheckConstraint{
+			Name:       c.Name,
+			Expression: expression,
+		})
+	}
+	return checks
+}
+
 // GetFirstUniqueNotNullColumn will return the first unique not null column in
 // the table. It will sort the columns by pgstream ID, and return the first one
 // matching the not null/unique constraints. It uses the pgstream id instead of
@@ -65,6 +65,143 @@ func TestTable_GetFirstUniqueNotNullColumn(t *testing.T) {
 	}
 }
 
+func TestTable_IsPrimaryKeyIsUnique(t *testing.T) {
+	t.Parallel()
+
+	table := &Table{
+		Columns: []Column{
+			{Name: "id"},
+			{Name: "email", Unique: true},
+			{Name: "username"},
+			{Name: "tenant_id"},
+			{Name: "slug"},
+			{Name: "name"},
+		},
+		PrimaryKeyColumns: []string{"id"},
+		Indexes: []Index{
+			{Name: "username_idx", Columns: []string{"username"}, Unique: true},
+			{Name: "tenant_slug_idx", Columns: []string{"tenant_id", "slug"}, Unique: true},
+			{Name: "name_idx", Columns: []string{"name"}},
+		},
+	}
+
+	tests := []struct {
+		column         string
+		wantPrimaryKey bool
+		wantUnique     bool
+	}{
+		{column: "id", wantPrimaryKey: true, wantUnique: true},
+		{column: "email", wantUnique: true},
+		{column: "username", wantUnique: true},
+		{column: "tenant_id"},
+		{column: "slug"},
+		{column: "name"},
+		{column: "unknown"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.column, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.wantPrimaryKey, table.IsPrimaryKey(tc.column))
+			require.Equal(t, tc.wantUnique, table.IsUnique(tc.column))
+		})
+	}
+
+	composite := &Table{PrimaryKeyColumns: []string{"tenant_id", "id"}}
+	require.True(t, composite.IsPrimaryKey("id"))
+	require.False(t, composite.IsUnique("id"))
+}
+
+func TestTable_ColumnForeignKeys(t *testing.T) {
+	t.Parallel()
+
+	userFK := ForeignKey{Name: "orders_user_fk", Definition: "FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE"}
+	productFK := ForeignKey{Name: "orders_product_fk", Definition: `FOREIGN KEY (tenant_id, "Product ID") REFERENCES catalog.products(tenant_id, id)`}
+	table := &Table{
+		ForeignKeys: []ForeignKey{userFK, productFK, {Name: "invalid", Definition: "invalid"}},
+	}
+
+	tests := []struct {
+		column string
+
+		wantForeignKeys []ForeignKey
+	}{
+		{column: "user_id", wantForeignKeys: []ForeignKey{userFK}},
+		{column: "tenant_id", wantForeignKeys: []ForeignKey{productFK}},
+		{column: "Product ID", wantForeignKeys: []ForeignKey{productFK}},
+		{column: "id", wantForeignKeys: []ForeignKey{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.column, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.wantForeignKeys, table.ColumnForeignKeys(tc.column))
+		})
+	}
+}
+
+func TestForeignKey_References(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		fk   ForeignKey
+
+		wantColumns           []string
+		wantReferencedTable   string
+		wantReferencedColumns []string
+	}{
+		{
+			name:                  "single column",
+			fk:                    ForeignKey{Definition: "FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE"},
+			wantColumns:           []string{"user_id"},
+			wantReferencedTable:   "users",
+			wantReferencedColumns: []string{"id"},
+		},
+		{
+			name:                  "composite with quoted identifiers",
+			fk:                    ForeignKey{Definition: `FOREIGN KEY (tenant_id, "Product ""ID""") REFERENCES catalog.products(tenant_id, id) NOT VALID`},
+			wantColumns:           []string{"tenant_id", `Product "ID"`},
+			wantReferencedTable:   "catalog.products",
+			wantReferencedColumns: []string{"tenant_id", "id"},
+		},
+		{
+			name: "invalid definition",
+			fk:   ForeignKey{Definition: "CHECK ((price > 0))"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantColumns, tc.fk.Columns())
+			table, columns := tc.fk.References()
+			require.Equal(t, tc.wantReferencedTable, table)
+			require.Equal(t, tc.wantReferencedColumns, columns)
+		})
+	}
+}
+
+func TestTable_CheckConstraints(t *testing.T) {
+	t.Parallel()
+
+	table := &Table{
+		Constraints: []Constraint{
+			{Name: "price_positive", Type: "CHECK", Definition: "CHECK ((price > (0)::numeric))"},
+			{Name: "name_unique", Type: "UNIQUE", Definition: "UNIQUE (name)"},
+			{Name: "status_valid", Type: "CHECK", Definition: "CHECK ((status = ANY (ARRAY['active'::text, 'inactive'::text]))) NOT VALID"},
+		},
+	}
+
+	require.Equal(t, []CheckConstraint{
+		{Name: "price_positive", Expression: "(price > (0)::numeric)"},
+		{Name: "status_valid", Expression: "(status = ANY (ARRAY['active'::text, 'inactive'::text]))"},
+	}, table.CheckConstraints())
+
+	require.Empty(t, (&Table{}).CheckConstraints())
+}
+
 func TestColumn_GetSequenceName(t *testing.T) {
 	t.Parallel()
 
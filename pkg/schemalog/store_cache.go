@@ -10,21 +10,30 @@ import (
 
 // StoreCache is a wrapper around a schemalog Store that provides an in memory
 // caching mechanism to reduce the amount of calls to the database. It is not
-// concurrency safe.
+// concurrency safe. The index cache is kept up to date with the cached log
+// entries, so it's refreshed with every acked schema change.
 type StoreCache struct {
-	store Store
-	mutex *sync.RWMutex
-	cache map[string]*LogEntry
+	store   Store
+	mutex   *sync.RWMutex
+	cache   map[string]*LogEntry
+	indexes *IndexCache
 }
 
 func NewStoreCache(store Store) *StoreCache {
 	return &StoreCache{
-		store: store,
-		cache: make(map[string]*LogEntry),
-		mutex: &sync.RWMutex{},
+		store:   store,
+		cache:   make(map[string]*LogEntry),
+		mutex:   &sync.RWMutex{},
+		indexes: NewIndexCache(),
 	}
 }
 
+// Indexes returns the index cache of the schemas fetched or acked through the
+// store cache.
+func (s *StoreCache) Indexes() *IndexCache {
+	return s.indexes
+}
+
 func (s *StoreCache) Insert(ctx context.Context, schemaName string) (*LogEntry, error) {
 	return s.store.Insert(ctx, schemaName)
 }
@@ -47,6 +56,10 @@ func (s *StoreCache) Fetch(ctx context.Context, schemaName string, version int)
 	return s.store.Fetch(ctx, schemaName, version)
 }
 
+func (s *StoreCache) FetchSince(ctx context.Context, schemaName string, version int) ([]*LogEntry, error) {
+	return s.store.FetchSince(ctx, schemaName, version)
+}
+
 func (s *StoreCache) Ack(ctx context.Context, entry *LogEntry) error {
 	s.updateCachedLogEntry(entry.SchemaName, entry)
 	if err := s.store.Ack(ctx, entry); err != nil {
@@ -69,4 +82,5 @@ func (s *StoreCache) updateCachedLogEntry(schema string, logEntry *LogEntry) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 	s.cache[schema] = logEntry
+	s.indexes.Update(logEntry)
 }
@@ -103,6 +103,9 @@ func TestStoreCache_Ack(t *testing.T) {
 		ID:         xid.New(),
 		SchemaName: testSchema,
 		Version:    2,
+		Schema: Schema{
+			Tables: []Table{{Name: "test-table", PrimaryKeyColumns: []string{"id"}}},
+		},
 	}
 
 	errTest := errors.New("oh noes")
@@ -163,6 +166,7 @@ func TestStoreCache_Ack(t *testing.T) {
 			err := s.Ack(context.Background(), updatedTestLogEntry)
 			require.ErrorIs(t, err, tc.wantErr)
 			require.Equal(t, s.cache, tc.wantCache)
+			require.True(t, s.Indexes().IsPrimaryKey(testSchema, "test-table", "id"))
 		})
 	}
 }
@@ -227,10 +231,11 @@ func TestStoreCache_Insert(t *testing.T) {
 }
 
 type mockStore struct {
-	insertFn    func(schemaName string) (*LogEntry, error)
-	fetchLastFn func(schemaName string, ackedOnly bool) (*LogEntry, error)
-	fetchFn     func(schemaName string, version int) (*LogEntry, error)
-	ackFn       func(le *LogEntry) error
+	insertFn     func(schemaName string) (*LogEntry, error)
+	fetchLastFn  func(schemaName string, ackedOnly bool) (*LogEntry, error)
+	fetchFn      func(schemaName string, version int) (*LogEntry, error)
+	fetchSinceFn func(schemaName string, version int) ([]*LogEntry, error)
+	ackFn        func(le *LogEntry) error
 }
 
 func (m *mockStore) Insert(_ context.Context, schemaName string) (*LogEntry, error) {
@@ -245,6 +250,10 @@ func (m *mockStore) Fetch(_ context.Context, schemaName string, version int) (*L
 	return m.fetchFn(schemaName, version)
 }
 
+func (m *mockStore) FetchSince(_ context.Context, schemaName string, version int) ([]*LogEntry, error) {
+	return m.fetchSinceFn(schemaName, version)
+}
+
 func (m *mockStore) Ack(_ context.Context, le *LogEntry) error {
 	return m.ackFn(le)
 }
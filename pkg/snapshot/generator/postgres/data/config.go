@@ -22,6 +22,22 @@ type Config struct {
 	// snapshot generator can open to Postgres. This setting is optional.
 	// Defaults to 50
 	MaxConnections uint
+	// RLSRole is the role used to query the table rows, so that the snapshot
+	// only includes the rows allowed by the row level security policies that
+	// apply to it. The role can't be a superuser or have the BYPASSRLS
+	// attribute, and the connection user must be a member of it. This
+	// setting is optional. By default the rows are queried with the
+	// connection user.
+	RLSRole string
+	// SerializableSnapshot makes the snapshot transactions use the
+	// serializable isolation level instead of repeatable read. Schema
+	// snapshots that fail with a serialization failure are retried with a new
+	// transaction snapshot. Defaults to false.
+	SerializableSnapshot bool
+	// MaxSerializationRetries is the maximum number of times a schema snapshot
+	// is retried after a serialization failure, when the serializable snapshot
+	// is enabled. Defaults to 3.
+	MaxSerializationRetries uint
 }
 
 const (
@@ -30,6 +46,8 @@ const (
 	defaultSnapshotWorkers = 1
 	defaultBatchBytes      = 80 * 1024 * 1024 // 80 MiB
 	defaultMaxConnections  = 50
+
+	defaultMaxSerializationRetries = 3
 )
 
 func (c *Config) batchBytes() uint64 {
@@ -66,3 +84,10 @@ func (c *Config) maxConnections() uint {
 	}
 	return defaultMaxConnections
 }
+
+func (c *Config) maxSerializationRetries() uint {
+	if c.MaxSerializationRetries > 0 {
+		return c.MaxSerializationRetries
+	}
+	return defaultMaxSerializationRetries
+}
@@ -32,6 +32,10 @@ func (mp *mockProcessor) Name() string {
 	return "mockProcessor"
 }
 
+func (mp *mockProcessor) Ping(context.Context) error {
+	return nil
+}
+
 func execQuery(t *testing.T, ctx context.Context, pgurl, query string) {
 	conn, err := pglib.NewConn(ctx, pgurl)
 	require.NoError(t, err)
@@ -4,29 +4,97 @@ package postgres
 
 import (
 	"context"
+	"errors"
 
+	pglib "github.com/xataio/pgstream/internal/postgres"
 	"github.com/xataio/pgstream/pkg/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type instrumentedTableSnapshotGenerator struct {
 	tracer          trace.Tracer
+	metrics         *tableSnapshotMetrics
 	snapshotTableFn snapshotTableFn
 }
 
-func newInstrumentedTableSnapshotGenerator(fn snapshotTableFn, i *otel.Instrumentation) *instrumentedTableSnapshotGenerator {
-	return &instrumentedTableSnapshotGenerator{
+type tableSnapshotMetrics struct {
+	tablesInProgress metric.Int64UpDownCounter
+	tables           metric.Int64Counter
+	rows             metric.Int64Counter
+	tableAttributes  *otel.TableAttributes
+}
+
+const (
+	tableSnapshotStatusCompleted = "completed"
+	tableSnapshotStatusFailed    = "failed"
+)
+
+func newInstrumentedTableSnapshotGenerator(fn snapshotTableFn, i *otel.Instrumentation) (*instrumentedTableSnapshotGenerator, error) {
+	ig := &instrumentedTableSnapshotGenerator{
 		tracer:          i.Tracer,
 		snapshotTableFn: fn,
 	}
+	if i.Meter != nil {
+		var err error
+		if ig.metrics, err = newTableSnapshotMetrics(i.Meter); err != nil {
+			return nil, err
+		}
+		ig.metrics.tableAttributes = i.Tables
+	}
+	return ig, nil
 }
 
 func (i *instrumentedTableSnapshotGenerator) snapshotTable(ctx context.Context, snapshotID string, table *table) (err error) {
-	ctx, span := otel.StartSpan(ctx, i.tracer, "tableSnapshotGenerator.SnapshotTable", trace.WithAttributes([]attribute.KeyValue{
+	tableAttributes := []attribute.KeyValue{
 		{Key: "schema", Value: attribute.StringValue(table.schema)},
 		{Key: "table", Value: attribute.StringValue(table.name)},
-	}...))
+	}
+	ctx, span := otel.StartSpan(ctx, i.tracer, "tableSnapshotGenerator.SnapshotTable", trace.WithAttributes(tableAttributes...))
 	defer otel.CloseSpan(span, err)
+
+	if i.metrics != nil {
+		i.metrics.tablesInProgress.Add(ctx, 1)
+		defer func() {
+			i.metrics.tablesInProgress.Add(ctx, -1)
+			status := tableSnapshotStatusCompleted
+			// tables that don't exist are not considered a failure
+			if err != nil && !errors.Is(err, pglib.ErrNoRows) {
+				status = tableSnapshotStatusFailed
+			}
+			i.metrics.tables.Add(ctx, 1, metric.WithAttributes(attribute.String("status", status)))
+			i.metrics.rows.Add(ctx, int64(table.rows.Load()), metric.WithAttributes(i.metrics.tableAttributes.Get(table.schema, table.name)...))
+		}()
+	}
+
 	return i.snapshotTableFn(ctx, snapshotID, table)
 }
+
+func newTableSnapshotMetrics(meter metric.Meter) (*tableSnapshotMetrics, error) {
+	m := &tableSnapshotMetrics{}
+
+	var err error
+	m.tablesInProgress, err = meter.Int64UpDownCounter("pgstream.snapshot.tables.in_progress",
+		metric.WithUnit("tables"),
+		metric.WithDescription("Number of tables being snapshotted"))
+	if err != nil {
+		return nil, err
+	}
+
+	m.tables, err = meter.Int64Counter("pgstream.snapshot.tables",
+		metric.WithUnit("tables"),
+		metric.WithDescription("Number of tables snapshotted, by completion status"))
+	if err != nil {
+		return nil, err
+	}
+
+	m.rows, err = meter.Int64Counter("pgstream.snapshot.rows",
+		metric.WithUnit("rows"),
+		metric.WithDescription("Number of rows snapshotted per table, reported once the table snapshot completes"))
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
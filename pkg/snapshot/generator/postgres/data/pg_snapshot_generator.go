@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	pglib "github.com/xataio/pgstream/internal/postgres"
 	pglibinstrumentation "github.com/xataio/pgstream/internal/postgres/instrumentation"
@@ -17,9 +18,19 @@ import (
 	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/snapshot"
 	"github.com/xataio/pgstream/pkg/wal/processor"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 	"golang.org/x/sync/errgroup"
 )
 
+var (
+	errRLSRoleNotFound    = errors.New("row level security role not found")
+	errRLSRoleBypassesRLS = errors.New("row level security role is a superuser or has the BYPASSRLS attribute")
+	// errSerializationFailure is returned by a schema snapshot when at least
+	// one of its tables failed with a serialization failure
+	errSerializationFailure = errors.New("table snapshot serialization failure")
+)
+
 type SnapshotGenerator struct {
 	logger  loglib.Logger
 	conn    pglib.Querier
@@ -32,10 +43,25 @@ type SnapshotGenerator struct {
 	// workers per table, parallelise the snapshot creation for each page range
 	tableWorkers uint
 	batchBytes   uint64
+	// role used to query the table rows, to apply its row level security
+	// policies
+	rlsRole string
+	// use the serializable isolation level for the snapshot transactions,
+	// retrying the schema snapshots that fail with a serialization failure up
+	// to the max serialization retries
+	serializableSnapshot    bool
+	maxSerializationRetries uint
+	serializationRetries    metric.Int64Counter
 
 	// Function called for processing produced rows.
 	processor              processor.Processor
 	tableSnapshotGenerator snapshotTableFn
+	// tableSnapshotGate is called before starting the snapshot of each table,
+	// and can be used to delay new table snapshots.
+	tableSnapshotGate func(context.Context) error
+	// tableEventHandler is notified when each table snapshot starts and
+	// completes.
+	tableEventHandler func(context.Context, *snapshot.TableEvent)
 
 	progressTracking   bool
 	progressBars       *synclib.Map[string, progress.Bar]
@@ -67,6 +93,9 @@ type table struct {
 	schema  string
 	name    string
 	rowSize int64
+	// rows is the number of rows snapshotted, updated concurrently by the
+	// page range workers
+	rows atomic.Uint64
 }
 
 type snapshotTableFn func(ctx context.Context, snapshotID string, table *table) error
@@ -79,6 +108,13 @@ func NewSnapshotGenerator(ctx context.Context, cfg *Config, processor processor.
 		return nil, err
 	}
 
+	if cfg.RLSRole != "" {
+		if err := validateRLSRole(ctx, conn, cfg.RLSRole); err != nil {
+			conn.Close(ctx)
+			return nil, err
+		}
+	}
+
 	sg := &SnapshotGenerator{
 		logger:          loglib.NewNoopLogger(),
 		conn:            conn,
@@ -87,6 +123,10 @@ func NewSnapshotGenerator(ctx context.Context, cfg *Config, processor processor.
 		tableWorkers:    cfg.tableWorkers(),
 		schemaWorkers:   cfg.schemaWorkers(),
 		snapshotWorkers: cfg.snapshotWorkers(),
+		rlsRole:         cfg.RLSRole,
+
+		serializableSnapshot:    cfg.SerializableSnapshot,
+		maxSerializationRetries: cfg.maxSerializationRetries(),
 	}
 
 	sg.tableSnapshotGenerator = sg.snapshotTable
@@ -117,8 +157,47 @@ func WithInstrumentation(i *otel.Instrumentation) Option {
 			panic(err)
 		}
 
-		ig := newInstrumentedTableSnapshotGenerator(sg.tableSnapshotGenerator, i)
+		ig, err := newInstrumentedTableSnapshotGenerator(sg.tableSnapshotGenerator, i)
+		if err != nil {
+			// this should never happen
+			panic(err)
+		}
 		sg.tableSnapshotGenerator = ig.snapshotTable
+
+		if i.Meter != nil {
+			sg.serializationRetries, err = newSerializationRetriesCounter(i.Meter)
+			if err != nil {
+				// this should never happen
+				panic(err)
+			}
+		}
+	}
+}
+
+// WithTableSnapshotGate sets a function that will be called before starting
+// each table snapshot. It can block to delay the table snapshot, or return an
+// error to skip it.
+func WithTableSnapshotGate(gate func(context.Context) error) Option {
+	return func(sg *SnapshotGenerator) {
+		sg.tableSnapshotGate = gate
+	}
+}
+
+// WithTableEventHandler sets a function that will be notified when each table
+// snapshot starts and completes, to track the snapshot progress per table. It's
+// called concurrently by the schema workers. When set multiple times, all the
+// handlers are notified in the order they were set.
+func WithTableEventHandler(handler func(context.Context, *snapshot.TableEvent)) Option {
+	return func(sg *SnapshotGenerator) {
+		previous := sg.tableEventHandler
+		if previous == nil {
+			sg.tableEventHandler = handler
+			return
+		}
+		sg.tableEventHandler = func(ctx context.Context, event *snapshot.TableEvent) {
+			previous(ctx, event)
+			handler(ctx, event)
+		}
 	}
 }
 
@@ -179,7 +258,33 @@ func (sg *SnapshotGenerator) Close() error {
 	return sg.conn.Close(context.Background())
 }
 
+// createSchemaSnapshot snapshots the schema tables on input. When the
+// serializable snapshot is enabled, the schema snapshot is retried with a new
+// transaction snapshot if it fails with a serialization failure. The rows of
+// the failed attempt that were already processed will be processed again.
 func (sg *SnapshotGenerator) createSchemaSnapshot(ctx context.Context, schemaTables *schemaTables) error {
+	for retries := uint(0); ; retries++ {
+		err := sg.createSchemaSnapshotTx(ctx, schemaTables)
+		if !sg.serializableSnapshot || !isSerializationFailure(err) {
+			return err
+		}
+		if retries >= sg.maxSerializationRetries {
+			return fmt.Errorf("schema snapshot failed after %d serialization retries: %w", retries, err)
+		}
+
+		sg.logger.Warn(err, "serialization failure creating data snapshot, retrying", loglib.Fields{
+			"schema": schemaTables.schema, "retry": retries + 1,
+		})
+		if sg.progressTracking {
+			sg.markProgressBarCompleted(schemaTables.schema)
+		}
+		if sg.serializationRetries != nil {
+			sg.serializationRetries.Add(ctx, 1, metric.WithAttributes(attribute.String("schema", schemaTables.schema)))
+		}
+	}
+}
+
+func (sg *SnapshotGenerator) createSchemaSnapshotTx(ctx context.Context, schemaTables *schemaTables) error {
 	// use a transaction snapshot to ensure the table rows can be parallelised.
 	// The transaction snapshot is available for use only until the end of the
 	// transaction that exported it.
@@ -222,8 +327,12 @@ func (sg *SnapshotGenerator) createSchemaSnapshot(ctx context.Context, schemaTab
 		close(tableChan)
 		wg.Wait()
 
-		return sg.collectTableErrors(schemaTables.schema, workerTableErrs)
-	}, snapshotTxOptions())
+		tableErrs := sg.collectTableErrors(schemaTables.schema, workerTableErrs)
+		if hasSerializationFailure(workerTableErrs) {
+			return fmt.Errorf("%w: %w", errSerializationFailure, tableErrs)
+		}
+		return tableErrs
+	}, sg.snapshotTxOptions())
 }
 
 func (sg *SnapshotGenerator) createSnapshotWorker(ctx context.Context, wg *sync.WaitGroup, snapshotID string, tableChan <-chan *table, tableErrMap map[string]error) {
@@ -232,17 +341,47 @@ func (sg *SnapshotGenerator) createSnapshotWorker(ctx context.Context, wg *sync.
 		logFields := loglib.Fields{"schema": t.schema, "table": t.name, "snapshotID": snapshotID}
 		sg.logger.Debug("snapshotting table", logFields)
 
-		if err := sg.tableSnapshotGenerator(ctx, snapshotID, t); err != nil {
+		if sg.tableSnapshotGate != nil {
+			if err := sg.tableSnapshotGate(ctx); err != nil {
+				sg.logger.Error(err, "waiting to snapshot table", logFields)
+				tableErrMap[t.name] = err
+				sg.notifyTableEvent(ctx, t, snapshot.TableSnapshotCompleted, err)
+				continue
+			}
+		}
+
+		sg.notifyTableEvent(ctx, t, snapshot.TableSnapshotStarted, nil)
+		err := sg.tableSnapshotGenerator(ctx, snapshotID, t)
+		if err != nil {
 			sg.logger.Error(err, "snapshotting table", logFields)
 			// errors will get notified unless the table doesn't exist
-			if !errors.Is(err, pglib.ErrNoRows) {
+			if errors.Is(err, pglib.ErrNoRows) {
+				err = nil
+			} else {
 				tableErrMap[t.name] = err
 			}
 		}
+		sg.notifyTableEvent(ctx, t, snapshot.TableSnapshotCompleted, err)
 		sg.logger.Debug("table snapshot completed", logFields)
 	}
 }
 
+func (sg *SnapshotGenerator) notifyTableEvent(ctx context.Context, t *table, eventType snapshot.TableEventType, err error) {
+	if sg.tableEventHandler == nil {
+		return
+	}
+	event := &snapshot.TableEvent{
+		Type:   eventType,
+		Schema: t.schema,
+		Table:  t.name,
+	}
+	if eventType == snapshot.TableSnapshotCompleted {
+		event.Rows = t.rows.Load()
+		event.Err = err
+	}
+	sg.tableEventHandler(ctx, event)
+}
+
 func (sg *SnapshotGenerator) collectTableErrors(schema string, workerTableErrs []map[string]error) error {
 	var schemaErrs *snapshot.SchemaErrors
 	for _, worker := range workerTableErrs {
@@ -329,6 +468,12 @@ var pageRangeQuery = "SELECT * FROM %s WHERE ctid BETWEEN '(%d,0)' AND '(%d,0)'"
 
 func (sg *SnapshotGenerator) snapshotTableRange(ctx context.Context, snapshotID string, table *table, pageRange pageRange) error {
 	return sg.execInSnapshotTx(ctx, snapshotID, func(tx pglib.Tx) error {
+		// the role is only set for the rows query, since the table page
+		// information needs to be computed with all the table rows
+		if err := sg.setRLSRole(ctx, tx); err != nil {
+			return err
+		}
+
 		sg.logger.Debug(fmt.Sprintf("querying table page range %d-%d", pageRange.start, pageRange.end), loglib.Fields{
 			"schema": table.schema, "table": table.name, "snapshotID": snapshotID,
 		})
@@ -364,6 +509,7 @@ func (sg *SnapshotGenerator) snapshotTableRange(ctx context.Context, snapshotID
 			}
 		}
 
+		table.rows.Add(uint64(rowCount))
 		if sg.progressTracking {
 			bar, found := sg.progressBars.Get(table.schema)
 			if found {
@@ -517,6 +663,38 @@ func (sg *SnapshotGenerator) setTransactionSnapshot(ctx context.Context, tx pgli
 	return nil
 }
 
+// setRLSRole sets the configured row level security role for the remainder of
+// the transaction on input, if any.
+func (sg *SnapshotGenerator) setRLSRole(ctx context.Context, tx pglib.Tx) error {
+	if sg.rlsRole == "" {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL ROLE %s", pglib.QuoteIdentifier(sg.rlsRole))); err != nil {
+		return fmt.Errorf("setting row level security role: %w", err)
+	}
+	return nil
+}
+
+const rlsRoleQuery = `SELECT rolsuper, rolbypassrls FROM pg_roles WHERE rolname = $1`
+
+// validateRLSRole makes sure the row level security policies will be applied
+// to the role on input, since superusers and roles with the BYPASSRLS
+// attribute skip them, which would silently include all the rows in the
+// snapshot.
+func validateRLSRole(ctx context.Context, conn pglib.Querier, role string) error {
+	var isSuperuser, bypassRLS bool
+	if err := conn.QueryRow(ctx, []any{&isSuperuser, &bypassRLS}, rlsRoleQuery, role); err != nil {
+		if errors.Is(err, pglib.ErrNoRows) {
+			return fmt.Errorf("%w: %q", errRLSRoleNotFound, role)
+		}
+		return fmt.Errorf("retrieving row level security role: %w", err)
+	}
+	if isSuperuser || bypassRLS {
+		return fmt.Errorf("%w: %q", errRLSRoleBypassesRLS, role)
+	}
+	return nil
+}
+
 func (sg *SnapshotGenerator) execInSnapshotTx(ctx context.Context, snapshotID string, fn func(tx pglib.Tx) error) error {
 	return sg.conn.ExecInTxWithOptions(ctx, func(tx pglib.Tx) error {
 		if err := sg.setTransactionSnapshot(ctx, tx, snapshotID); err != nil {
@@ -524,16 +702,49 @@ func (sg *SnapshotGenerator) execInSnapshotTx(ctx context.Context, snapshotID st
 		}
 
 		return fn(tx)
-	}, snapshotTxOptions())
+	}, sg.snapshotTxOptions())
 }
 
-func snapshotTxOptions() pglib.TxOptions {
+// snapshotTxOptions returns the options for both the transaction that exports
+// the snapshot and the ones that import it, since postgres requires the
+// exporting transaction to be serializable for a serializable transaction to
+// import its snapshot.
+func (sg *SnapshotGenerator) snapshotTxOptions() pglib.TxOptions {
+	isolationLevel := pglib.RepeatableRead
+	if sg.serializableSnapshot {
+		isolationLevel = pglib.Serializable
+	}
 	return pglib.TxOptions{
-		IsolationLevel: pglib.RepeatableRead,
+		IsolationLevel: isolationLevel,
 		AccessMode:     pglib.ReadOnly,
 	}
 }
 
+func isSerializationFailure(err error) bool {
+	if errors.Is(err, errSerializationFailure) {
+		return true
+	}
+	var serializationErr *pglib.ErrSerializationFailure
+	return errors.As(err, &serializationErr)
+}
+
+func hasSerializationFailure(workerTableErrs []map[string]error) bool {
+	for _, worker := range workerTableErrs {
+		for _, err := range worker {
+			if isSerializationFailure(err) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newSerializationRetriesCounter(meter metric.Meter) (metric.Int64Counter, error) {
+	return meter.Int64Counter("pgstream.snapshot.serialization_retries",
+		metric.WithUnit("retries"),
+		metric.WithDescription("Number of schema snapshot retries caused by serialization failures"))
+}
+
 // calculateBatchPageSize will automatically determine the batch page size based
 // on the average page size and the configured batch bytes limit.
 func (t *tableInfo) calculateBatchPageSize(bytes uint64) {
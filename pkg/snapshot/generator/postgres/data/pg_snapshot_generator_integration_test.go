@@ -67,6 +67,63 @@ func Test_PostgresSnapshotGenerator(t *testing.T) {
 	require.Equal(t, wantEvents, rows)
 }
 
+func Test_PostgresSnapshotGenerator_RLSRole(t *testing.T) {
+	if os.Getenv("PGSTREAM_INTEGRATION_TESTS") == "" {
+		t.Skip("skipping integration test...")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var pgurl string
+	pgCleanup, err := testcontainers.SetupPostgresContainer(ctx, &pgurl, testcontainers.Postgres14)
+	require.NoError(t, err)
+	defer pgCleanup()
+
+	// create a table with a row level security policy that only allows the
+	// tenant role to see its own rows
+	testTable := "snapshot_generator_rls_integration_test"
+	testRole := "tenant_acme"
+	execQuery(t, ctx, pgurl, fmt.Sprintf("CREATE TABLE %s(id SERIAL PRIMARY KEY, name TEXT, tenant TEXT)", testTable))
+	execQuery(t, ctx, pgurl, fmt.Sprintf("INSERT INTO %s(name, tenant) VALUES('alice', 'acme'), ('bob', 'globex'), ('charlie', 'acme')", testTable))
+	execQuery(t, ctx, pgurl, fmt.Sprintf("CREATE ROLE %s", testRole))
+	execQuery(t, ctx, pgurl, fmt.Sprintf("GRANT SELECT ON %s TO %s", testTable, testRole))
+	execQuery(t, ctx, pgurl, fmt.Sprintf("ALTER TABLE %s ENABLE ROW LEVEL SECURITY", testTable))
+	execQuery(t, ctx, pgurl, fmt.Sprintf("CREATE POLICY tenant_isolation ON %s FOR SELECT TO %s USING (tenant = 'acme')", testTable, testRole))
+
+	mockProcessor := &mockProcessor{
+		eventChan: make(chan *wal.Event),
+	}
+
+	generator, err := NewSnapshotGenerator(ctx, &Config{URL: pgurl, RLSRole: testRole}, mockProcessor)
+	require.NoError(t, err)
+	defer generator.Close()
+
+	go func() {
+		err = generator.CreateSnapshot(ctx, &snapshot.Snapshot{
+			SchemaTables: map[string][]string{
+				"public": {testTable},
+			},
+		})
+		require.NoError(t, err)
+		mockProcessor.Close()
+	}()
+
+	names := []string{}
+	for event := range mockProcessor.eventChan {
+		for _, col := range event.Data.Columns {
+			if col.Name == "name" {
+				names = append(names, col.Value.(string))
+			}
+		}
+	}
+	require.Equal(t, []string{"alice", "charlie"}, names)
+
+	// superuser roles bypass the row level security policies
+	_, err = NewSnapshotGenerator(ctx, &Config{URL: pgurl, RLSRole: "postgres"}, mockProcessor)
+	require.ErrorIs(t, err, errRLSRoleBypassesRLS)
+}
+
 func newTestEvent(tableName string, id int32, name string) *wal.Event {
 	return &wal.Event{
 		Data: &wal.Data{
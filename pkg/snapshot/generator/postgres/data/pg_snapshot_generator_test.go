@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -25,6 +26,9 @@ import (
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/processor"
 	processormocks "github.com/xataio/pgstream/pkg/wal/processor/mocks"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 func TestSnapshotGenerator_CreateSnapshot(t *testing.T) {
@@ -1179,6 +1183,7 @@ func TestSnapshotGenerator_snapshotTableRange(t *testing.T) {
 		table     *table
 		pageRange pageRange
 		processor processor.Processor
+		rlsRole   string
 
 		wantEvents []*wal.Event
 		wantErr    error
@@ -1227,6 +1232,82 @@ func TestSnapshotGenerator_snapshotTableRange(t *testing.T) {
 			wantEvents: []*wal.Event{testEvent},
 			wantErr:    nil,
 		},
+		{
+			name: "ok - with rls role",
+			querier: &pgmocks.Querier{
+				ExecInTxWithOptionsFn: func(_ context.Context, i uint, f func(tx pglib.Tx) error, to pglib.TxOptions) error {
+					mockTx := pgmocks.Tx{
+						ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+							switch i {
+							case 1:
+								require.Equal(t, fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", testSnapshotID), query)
+							case 2:
+								require.Equal(t, `SET LOCAL ROLE "tenant_reader"`, query)
+							default:
+								return pglib.CommandTag{}, fmt.Errorf("unexpected call to ExecFn: %d", i)
+							}
+							return pglib.CommandTag{}, nil
+						},
+						QueryFn: func(ctx context.Context, query string, args ...any) (pglib.Rows, error) {
+							require.Equal(t, fmt.Sprintf(pageRangeQuery, quotedSchemaTable, 0, 5), query)
+							return &pgmocks.Rows{
+								CloseFn: func() {},
+								NextFn:  func(i uint) bool { return i == 1 },
+								FieldDescriptionsFn: func() []pgconn.FieldDescription {
+									return []pgconn.FieldDescription{
+										{Name: "id", DataTypeOID: pgtype.UUIDOID},
+										{Name: "name", DataTypeOID: pgtype.TextOID},
+									}
+								},
+								ValuesFn: func() ([]any, error) {
+									return []any{testUUID, "alice"}, nil
+								},
+								ErrFn: func() error { return nil },
+							}, nil
+						},
+					}
+					return f(&mockTx)
+				},
+			},
+			table: &table{
+				schema:  testSchema,
+				name:    testTable,
+				rowSize: 512,
+			},
+			pageRange: testPageRange,
+			rlsRole:   "tenant_reader",
+
+			wantEvents: []*wal.Event{testEvent},
+			wantErr:    nil,
+		},
+		{
+			name: "error - setting rls role",
+			querier: &pgmocks.Querier{
+				ExecInTxWithOptionsFn: func(_ context.Context, i uint, f func(tx pglib.Tx) error, to pglib.TxOptions) error {
+					mockTx := pgmocks.Tx{
+						ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+							if i == 2 {
+								return pglib.CommandTag{}, errTest
+							}
+							return pglib.CommandTag{}, nil
+						},
+						QueryFn: func(ctx context.Context, query string, args ...any) (pglib.Rows, error) {
+							return nil, fmt.Errorf("unexpected call to QueryFn")
+						},
+					}
+					return f(&mockTx)
+				},
+			},
+			table: &table{
+				schema: testSchema,
+				name:   testTable,
+			},
+			pageRange: testPageRange,
+			rlsRole:   "tenant_reader",
+
+			wantEvents: []*wal.Event{},
+			wantErr:    fmt.Errorf("setting row level security role: %w", errTest),
+		},
 		{
 			name: "ok - multiple rows",
 			querier: &pgmocks.Querier{
@@ -1565,6 +1646,7 @@ func TestSnapshotGenerator_snapshotTableRange(t *testing.T) {
 				},
 				progressTracking: tc.name == "ok - with progress tracking",
 				progressBars:     synclib.NewMap[string, progress.Bar](),
+				rlsRole:          tc.rlsRole,
 			}
 
 			if sg.progressTracking {
@@ -1586,3 +1668,305 @@ func TestSnapshotGenerator_snapshotTableRange(t *testing.T) {
 		})
 	}
 }
+
+func TestSnapshotGenerator_createSnapshotWorker(t *testing.T) {
+	t.Parallel()
+
+	const testSchema = "test-schema"
+	const testTable = "test-table"
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name            string
+		gate            func(context.Context) error
+		snapshotTableFn snapshotTableFn
+		noEventHandler  bool
+		wantTableEvents []*snapshot.TableEvent
+		wantTableErrs   map[string]error
+	}{
+		{
+			name: "ok",
+			snapshotTableFn: func(ctx context.Context, snapshotID string, table *table) error {
+				table.rows.Add(3)
+				return nil
+			},
+
+			wantTableEvents: []*snapshot.TableEvent{
+				{Type: snapshot.TableSnapshotStarted, Schema: testSchema, Table: testTable},
+				{Type: snapshot.TableSnapshotCompleted, Schema: testSchema, Table: testTable, Rows: 3},
+			},
+			wantTableErrs: map[string]error{},
+		},
+		{
+			name: "ok - table not found",
+			snapshotTableFn: func(ctx context.Context, snapshotID string, table *table) error {
+				return pglib.ErrNoRows
+			},
+
+			wantTableEvents: []*snapshot.TableEvent{
+				{Type: snapshot.TableSnapshotStarted, Schema: testSchema, Table: testTable},
+				{Type: snapshot.TableSnapshotCompleted, Schema: testSchema, Table: testTable},
+			},
+			wantTableErrs: map[string]error{},
+		},
+		{
+			name: "ok - no table event handler",
+			snapshotTableFn: func(ctx context.Context, snapshotID string, table *table) error {
+				return nil
+			},
+			noEventHandler: true,
+
+			wantTableEvents: []*snapshot.TableEvent{},
+			wantTableErrs:   map[string]error{},
+		},
+		{
+			name: "error - snapshotting table",
+			snapshotTableFn: func(ctx context.Context, snapshotID string, table *table) error {
+				table.rows.Add(1)
+				return errTest
+			},
+
+			wantTableEvents: []*snapshot.TableEvent{
+				{Type: snapshot.TableSnapshotStarted, Schema: testSchema, Table: testTable},
+				{Type: snapshot.TableSnapshotCompleted, Schema: testSchema, Table: testTable, Rows: 1, Err: errTest},
+			},
+			wantTableErrs: map[string]error{testTable: errTest},
+		},
+		{
+			name: "error - table snapshot gate",
+			gate: func(ctx context.Context) error { return errTest },
+			snapshotTableFn: func(ctx context.Context, snapshotID string, table *table) error {
+				return errors.New("snapshotTableFn should not be called")
+			},
+
+			wantTableEvents: []*snapshot.TableEvent{
+				{Type: snapshot.TableSnapshotCompleted, Schema: testSchema, Table: testTable, Err: errTest},
+			},
+			wantTableErrs: map[string]error{testTable: errTest},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tableEvents := []*snapshot.TableEvent{}
+			sg := SnapshotGenerator{
+				logger:                 loglib.NewNoopLogger(),
+				tableSnapshotGenerator: tc.snapshotTableFn,
+				tableSnapshotGate:      tc.gate,
+			}
+			if !tc.noEventHandler {
+				sg.tableEventHandler = func(_ context.Context, event *snapshot.TableEvent) {
+					tableEvents = append(tableEvents, event)
+				}
+			}
+
+			tableChan := make(chan *table, 1)
+			tableChan <- &table{schema: testSchema, name: testTable}
+			close(tableChan)
+
+			tableErrs := map[string]error{}
+			wg := &sync.WaitGroup{}
+			wg.Add(1)
+			sg.createSnapshotWorker(context.Background(), wg, "test-snapshot-id", tableChan, tableErrs)
+
+			require.Equal(t, tc.wantTableEvents, tableEvents)
+			require.Equal(t, tc.wantTableErrs, tableErrs)
+		})
+	}
+}
+
+func TestValidateRLSRole(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	newQuerier := func(isSuperuser, bypassRLS bool, err error) *pgmocks.Querier {
+		return &pgmocks.Querier{
+			QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+				require.Equal(t, rlsRoleQuery, query)
+				require.Equal(t, []any{"tenant_reader"}, args)
+				if err != nil {
+					return err
+				}
+				*dest[0].(*bool) = isSuperuser
+				*dest[1].(*bool) = bypassRLS
+				return nil
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		querier pglib.Querier
+
+		wantErr error
+	}{
+		{
+			name:    "ok",
+			querier: newQuerier(false, false, nil),
+			wantErr: nil,
+		},
+		{
+			name:    "error - superuser",
+			querier: newQuerier(true, false, nil),
+			wantErr: errRLSRoleBypassesRLS,
+		},
+		{
+			name:    "error - bypassrls",
+			querier: newQuerier(false, true, nil),
+			wantErr: errRLSRoleBypassesRLS,
+		},
+		{
+			name:    "error - role not found",
+			querier: newQuerier(false, false, pglib.ErrNoRows),
+			wantErr: errRLSRoleNotFound,
+		},
+		{
+			name:    "error - querying role",
+			querier: newQuerier(false, false, errTest),
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateRLSRole(context.Background(), tc.querier, "tenant_reader")
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestSnapshotGenerator_createSchemaSnapshot_serializationRetries(t *testing.T) {
+	t.Parallel()
+
+	const testSchema = "test-schema"
+	const testTable = "test-table"
+	errSerialization := &pglib.ErrSerializationFailure{Details: "could not serialize access due to read/write dependencies among transactions"}
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name                 string
+		serializableSnapshot bool
+		tableErrs            []error
+
+		wantExports int
+		wantRetries int64
+		wantErr     error
+	}{
+		{
+			name:                 "ok - retried after serialization failure",
+			serializableSnapshot: true,
+			tableErrs:            []error{errSerialization, nil},
+
+			wantExports: 2,
+			wantRetries: 1,
+		},
+		{
+			name:                 "error - serialization retries exhausted",
+			serializableSnapshot: true,
+			tableErrs:            []error{errSerialization, errSerialization, errSerialization},
+
+			wantExports: 3,
+			wantRetries: 2,
+			wantErr: fmt.Errorf("schema snapshot failed after 2 serialization retries: %w", fmt.Errorf("%w: %w", errSerializationFailure, &snapshot.SchemaErrors{
+				Schema:      testSchema,
+				TableErrors: map[string]string{testTable: errSerialization.Error()},
+			})),
+		},
+		{
+			name:                 "error - not a serialization failure",
+			serializableSnapshot: true,
+			tableErrs:            []error{errTest},
+
+			wantExports: 1,
+			wantErr: &snapshot.SchemaErrors{
+				Schema:      testSchema,
+				TableErrors: map[string]string{testTable: errTest.Error()},
+			},
+		},
+		{
+			name:                 "error - serialization failure without serializable snapshot",
+			serializableSnapshot: false,
+			tableErrs:            []error{errSerialization},
+
+			wantExports: 1,
+			wantErr: fmt.Errorf("%w: %w", errSerializationFailure, &snapshot.SchemaErrors{
+				Schema:      testSchema,
+				TableErrors: map[string]string{testTable: errSerialization.Error()},
+			}),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			wantTxOptions := pglib.TxOptions{
+				IsolationLevel: pglib.RepeatableRead,
+				AccessMode:     pglib.ReadOnly,
+			}
+			if tc.serializableSnapshot {
+				wantTxOptions.IsolationLevel = pglib.Serializable
+			}
+
+			exports := 0
+			reader := sdkmetric.NewManualReader()
+			meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+			serializationRetries, err := newSerializationRetriesCounter(meterProvider.Meter("test"))
+			require.NoError(t, err)
+
+			sg := SnapshotGenerator{
+				logger: loglib.NewNoopLogger(),
+				conn: &pgmocks.Querier{
+					ExecInTxWithOptionsFn: func(_ context.Context, i uint, f func(tx pglib.Tx) error, to pglib.TxOptions) error {
+						require.Equal(t, wantTxOptions, to)
+						return f(&pgmocks.Tx{
+							QueryRowFn: func(_ context.Context, dest []any, query string, args ...any) error {
+								require.Equal(t, exportSnapshotQuery, query)
+								exports++
+								*dest[0].(*string) = fmt.Sprintf("snapshot-%d", exports)
+								return nil
+							},
+						})
+					},
+				},
+				schemaWorkers:           1,
+				serializableSnapshot:    tc.serializableSnapshot,
+				maxSerializationRetries: 2,
+				serializationRetries:    serializationRetries,
+			}
+			sg.tableSnapshotGenerator = func(_ context.Context, snapshotID string, table *table) error {
+				require.Equal(t, fmt.Sprintf("snapshot-%d", exports), snapshotID)
+				return tc.tableErrs[exports-1]
+			}
+
+			err = sg.createSchemaSnapshot(context.Background(), &schemaTables{
+				schema: testSchema,
+				tables: []string{testTable},
+			})
+			require.Equal(t, tc.wantErr, err)
+			require.Equal(t, tc.wantExports, exports)
+
+			rm := metricdata.ResourceMetrics{}
+			require.NoError(t, reader.Collect(context.Background(), &rm))
+			if tc.wantRetries == 0 {
+				require.Empty(t, rm.ScopeMetrics)
+				return
+			}
+			require.Len(t, rm.ScopeMetrics, 1)
+			require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+			m := rm.ScopeMetrics[0].Metrics[0]
+			require.Equal(t, "pgstream.snapshot.serialization_retries", m.Name)
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			require.True(t, ok)
+			require.Len(t, sum.DataPoints, 1)
+			schema, _ := sum.DataPoints[0].Attributes.Value(attribute.Key("schema"))
+			require.Equal(t, testSchema, schema.AsString())
+			require.Equal(t, tc.wantRetries, sum.DataPoints[0].Value)
+		})
+	}
+}
@@ -125,11 +125,11 @@ func (o *optionGenerator) pgdumpOptions(ctx context.Context, schemaTables map[st
 			// tables, since they are all included.
 			continue
 		}
-		var err error
-		opts.ExcludeTables, err = o.pgdumpExcludedTables(ctx, schema, tables)
+		excludeTables, err := o.pgdumpExcludedTables(ctx, schema, tables)
 		if err != nil {
 			return nil, err
 		}
+		opts.ExcludeTables = append(opts.ExcludeTables, excludeTables...)
 	}
 
 	for schema, tables := range excludedTables {
@@ -137,9 +137,21 @@ func (o *optionGenerator) pgdumpOptions(ctx context.Context, schemaTables map[st
 			opts.ExcludeSchemas = append(opts.ExcludeSchemas, pglib.QuoteIdentifier(schema))
 			continue
 		}
-		for _, table := range tables {
-			if !slices.Contains(opts.ExcludeTables, pglib.QuoteQualifiedIdentifier(schema, table)) {
-				opts.ExcludeTables = append(opts.ExcludeTables, pglib.QuoteQualifiedIdentifier(schema, table))
+		tableNames, tablePatterns := splitTablePatterns(tables)
+		excludeTables := make([]string, 0, len(tableNames))
+		for _, table := range tableNames {
+			excludeTables = append(excludeTables, pglib.QuoteQualifiedIdentifier(schema, table))
+		}
+		if len(tablePatterns) > 0 {
+			matchingTables, err := o.pgdumpMatchingTables(ctx, schema, tablePatterns)
+			if err != nil {
+				return nil, err
+			}
+			excludeTables = append(excludeTables, matchingTables...)
+		}
+		for _, table := range excludeTables {
+			if !slices.Contains(opts.ExcludeTables, table) {
+				opts.ExcludeTables = append(opts.ExcludeTables, table)
 			}
 		}
 	}
@@ -148,54 +160,104 @@ func (o *optionGenerator) pgdumpOptions(ctx context.Context, schemaTables map[st
 }
 
 const (
-	selectTablesQuery       = "SELECT schemaname,tablename FROM pg_tables WHERE tablename NOT IN (%s)"
-	selectSchemaTablesQuery = "SELECT schemaname,tablename FROM pg_tables WHERE schemaname = '%s' AND tablename NOT IN (%s)"
+	selectTablesQuery          = "SELECT schemaname,tablename FROM pg_tables WHERE tablename NOT IN (%s)"
+	selectSchemaTablesQuery    = "SELECT schemaname,tablename FROM pg_tables WHERE schemaname = '%s' AND tablename NOT IN (%s)"
+	selectAllTablesQuery       = "SELECT schemaname,tablename FROM pg_tables"
+	selectAllSchemaTablesQuery = "SELECT schemaname,tablename FROM pg_tables WHERE schemaname = '%s'"
 )
 
+// pgdumpExcludedTables returns the tables of the schema that are not part of
+// the include list. Since table patterns can't be evaluated by postgres, they
+// are matched against the query results.
 func (o *optionGenerator) pgdumpExcludedTables(ctx context.Context, schemaName string, includeTables []string) ([]string, error) {
-	conn, err := o.connBuilder(ctx, o.sourceURL)
-	if err != nil {
-		return nil, err
-	}
-	defer conn.Close(ctx)
+	tableNames, tablePatterns := splitTablePatterns(includeTables)
 
-	paramRefs := make([]string, 0, len(includeTables))
-	tableParams := make([]any, 0, len(includeTables))
-	for i, table := range includeTables {
+	paramRefs := make([]string, 0, len(tableNames))
+	tableParams := make([]any, 0, len(tableNames))
+	for i, table := range tableNames {
 		tableParams = append(tableParams, table)
 		paramRefs = append(paramRefs, fmt.Sprintf("$%d", i+1))
 	}
 
 	var query string
-	switch schemaName {
-	case wildcard:
+	switch {
+	case schemaName == wildcard && len(tableNames) == 0:
+		query = selectAllTablesQuery
+	case schemaName == wildcard:
 		query = fmt.Sprintf(selectTablesQuery, strings.Join(paramRefs, ","))
+	case len(tableNames) == 0:
+		query = fmt.Sprintf(selectAllSchemaTablesQuery, schemaName)
 	default:
 		// if the schema is not wildcard, we need to filter by schema name
 		query = fmt.Sprintf(selectSchemaTablesQuery, schemaName, strings.Join(paramRefs, ","))
 	}
 
 	// get all tables in the schema that are not in the include list
-	rows, err := conn.Query(ctx, query, tableParams...)
+	return o.querySchemaTables(ctx, query, tableParams, func(schema, table string) bool {
+		return !matchesAnyPattern(tablePatterns, schema, table)
+	})
+}
+
+// pgdumpMatchingTables returns the tables of the schema that match any of the
+// table patterns on input.
+func (o *optionGenerator) pgdumpMatchingTables(ctx context.Context, schemaName string, tablePatterns []string) ([]string, error) {
+	query := fmt.Sprintf(selectAllSchemaTablesQuery, schemaName)
+	if schemaName == wildcard {
+		query = selectAllTablesQuery
+	}
+	return o.querySchemaTables(ctx, query, nil, func(schema, table string) bool {
+		return matchesAnyPattern(tablePatterns, schema, table)
+	})
+}
+
+// querySchemaTables returns the quoted qualified names of the tables returned
+// by the query for which the keep function returns true.
+func (o *optionGenerator) querySchemaTables(ctx context.Context, query string, args []any, keep func(schema, table string) bool) ([]string, error) {
+	conn, err := o.connBuilder(ctx, o.sourceURL)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close(ctx)
+
+	rows, err := conn.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("retrieving tables from schema: %w", err)
 	}
 	defer rows.Close()
 
-	excludeTables := []string{}
+	tables := []string{}
 	for rows.Next() {
 		schemaName, tableName := "", ""
 		if err := rows.Scan(&schemaName, &tableName); err != nil {
 			return nil, fmt.Errorf("scanning table name: %w", err)
 		}
-		excludeTables = append(excludeTables, pglib.QuoteQualifiedIdentifier(schemaName, tableName))
+		if keep(schemaName, tableName) {
+			tables = append(tables, pglib.QuoteQualifiedIdentifier(schemaName, tableName))
+		}
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return excludeTables, nil
+	return tables, nil
+}
+
+func splitTablePatterns(tables []string) (tableNames, tablePatterns []string) {
+	for _, table := range tables {
+		if pglib.IsTablePattern(table) {
+			tablePatterns = append(tablePatterns, table)
+			continue
+		}
+		tableNames = append(tableNames, table)
+	}
+	return tableNames, tablePatterns
+}
+
+func matchesAnyPattern(tablePatterns []string, schema, table string) bool {
+	return slices.ContainsFunc(tablePatterns, func(pattern string) bool {
+		return pglib.MatchTablePattern(pattern, schema, table)
+	})
 }
 
 const selectSchemasQuery = "SELECT schema_name FROM information_schema.schemata WHERE schema_name NOT IN (%s)"
@@ -268,6 +268,40 @@ func TestOptionsGenerator_pgdumpOptions(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "schema table patterns and excluded table patterns",
+			schemaTables: map[string][]string{
+				"public": {"table1", "data_tenant_*"},
+			},
+			excludedTables: map[string][]string{
+				"*": {`^public\.data_tenant_archive$`},
+			},
+			includeGlobal: false,
+			conn: &pglibmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					switch query {
+					case fmt.Sprintf(selectSchemaTablesQuery, "public", "$1"):
+						require.Equal(t, []any{"table1"}, args)
+						return newMockSchemaTableRows([][2]string{{"public", "data_tenant_1"}, {"public", "table3"}}), nil
+					case selectAllTablesQuery:
+						require.Empty(t, args)
+						return newMockSchemaTableRows([][2]string{{"public", "data_tenant_archive"}, {"public", "table3"}}), nil
+					default:
+						return nil, fmt.Errorf("unexpected query: %s", query)
+					}
+				},
+			},
+
+			wantOpts: &pglib.PGDumpOptions{
+				ConnectionString: "source-url",
+				Format:           "p",
+				Schemas:          []string{"public"},
+				ExcludeSchemas:   nil,
+				SchemaOnly:       true,
+				ExcludeTables:    []string{`"public"."table3"`, `"public"."data_tenant_archive"`},
+			},
+			wantErr: nil,
+		},
 		{
 			name: "wildcard schema tables and excluded wildcard tables",
 			schemaTables: map[string][]string{
@@ -768,3 +802,23 @@ func TestOptionsGenerator_pgdumpExcludedSchemas(t *testing.T) {
 		})
 	}
 }
+
+func newMockSchemaTableRows(tables [][2]string) *pglibmocks.Rows {
+	return &pglibmocks.Rows{
+		NextFn: func(i uint) bool { return int(i) <= len(tables) },
+		ScanFn: func(i uint, dest ...any) error {
+			schema, ok := dest[0].(*string)
+			if !ok {
+				return errors.New("unexpected schema scan destination")
+			}
+			table, ok := dest[1].(*string)
+			if !ok {
+				return errors.New("unexpected table scan destination")
+			}
+			*schema, *table = tables[i-1][0], tables[i-1][1]
+			return nil
+		},
+		ErrFn:   func() error { return nil },
+		CloseFn: func() {},
+	}
+}
@@ -144,7 +144,11 @@ func WithInstrumentation(i *otel.Instrumentation) Option {
 		sg.pgDumpAllFn = pglibinstrumentation.NewPGDumpAllFn(sg.pgDumpAllFn, i)
 		sg.pgRestoreFn = pglibinstrumentation.NewPGRestoreFn(sg.pgRestoreFn, i)
 		if sg.schemalogStore != nil {
-			sg.schemalogStore = schemaloginstrumentation.NewStore(sg.schemalogStore, i)
+			sg.schemalogStore, err = schemaloginstrumentation.NewStore(sg.schemalogStore, i)
+			if err != nil {
+				// this should never happen
+				panic(err)
+			}
 		}
 	}
 }
@@ -15,8 +15,8 @@ import (
 )
 
 // SnapshotSchemaTableFinder is a decorator around a snapshot generator that will
-// explode the wildcard references in the snapshot requests and replace them by
-// all the schemas & schema tables in postgres.
+// explode the wildcard references and table patterns in the snapshot requests
+// and replace them by the matching schemas & schema tables in postgres.
 type SnapshotSchemaTableFinder struct {
 	wrapped           generator.SnapshotGenerator
 	conn              pglib.Querier
@@ -71,8 +71,10 @@ func WithInstrumentation(i *otel.Instrumentation) Option {
 func (s *SnapshotSchemaTableFinder) CreateSnapshot(ctx context.Context, ss *snapshot.Snapshot) error {
 	tablenames, wildcardSchemaFound := ss.SchemaTables[wildcard]
 	if wildcardSchemaFound {
-		if len(tablenames) != 1 || tablenames[0] != wildcard {
-			return fmt.Errorf("wildcard schema must be used with wildcard table, got %q", tablenames)
+		for _, table := range tablenames {
+			if !pglib.IsTablePattern(table) {
+				return fmt.Errorf("wildcard schema must be used with wildcard table or table patterns, got %q", tablenames)
+			}
 		}
 
 		schemas, err := s.schemaDiscoveryFn(ctx, s.conn)
@@ -80,19 +82,35 @@ func (s *SnapshotSchemaTableFinder) CreateSnapshot(ctx context.Context, ss *snap
 			return err
 		}
 		for _, schema := range schemas {
-			ss.SchemaTables[schema] = []string{wildcard}
+			if slices.Contains(tablenames, wildcard) {
+				ss.SchemaTables[schema] = []string{wildcard}
+				continue
+			}
+			// the patterns apply to all schemas, alongside the tables
+			// configured for the schema explicitly
+			ss.SchemaTables[schema] = append(ss.SchemaTables[schema], tablenames...)
 		}
 		delete(ss.SchemaTables, wildcard)
 	}
 
 	for schema, tables := range ss.SchemaTables {
+		if !slices.ContainsFunc(tables, pglib.IsTablePattern) {
+			continue
+		}
+		schemaTables, err := s.tableDiscoveryFn(ctx, s.conn, schema)
+		if err != nil {
+			return err
+		}
 		if slices.Contains(tables, wildcard) {
-			var err error
-			ss.SchemaTables[schema], err = s.tableDiscoveryFn(ctx, s.conn, schema)
-			if err != nil {
-				return err
-			}
+			ss.SchemaTables[schema] = schemaTables
+			continue
+		}
+		matchedTables := matchingTables(schema, tables, schemaTables)
+		if len(matchedTables) == 0 {
+			delete(ss.SchemaTables, schema)
+			continue
 		}
+		ss.SchemaTables[schema] = matchedTables
 	}
 
 	if len(ss.SchemaExcludedTables) == 0 {
@@ -102,21 +120,23 @@ func (s *SnapshotSchemaTableFinder) CreateSnapshot(ctx context.Context, ss *snap
 
 	// Remove excluded tables from the snapshot request
 	for schema, tables := range ss.SchemaTables {
-		if excludedTables, found := ss.SchemaExcludedTables[schema]; found {
-			// Filter out the excluded tables
-			filteredTables := []string{}
-			for _, table := range tables {
-				if !slices.Contains(excludedTables, table) {
-					filteredTables = append(filteredTables, table)
-				}
-			}
-			if len(filteredTables) == 0 {
-				// If no tables left after filtering, remove the schema from the snapshot
-				delete(ss.SchemaTables, schema)
-			} else {
-				ss.SchemaTables[schema] = filteredTables
+		excludedTables := append(slices.Clone(ss.SchemaExcludedTables[schema]), ss.SchemaExcludedTables[wildcard]...)
+		if len(excludedTables) == 0 {
+			continue
+		}
+		// Filter out the excluded tables
+		filteredTables := []string{}
+		for _, table := range tables {
+			if !matchesAny(excludedTables, schema, table) {
+				filteredTables = append(filteredTables, table)
 			}
 		}
+		if len(filteredTables) == 0 {
+			// If no tables left after filtering, remove the schema from the snapshot
+			delete(ss.SchemaTables, schema)
+		} else {
+			ss.SchemaTables[schema] = filteredTables
+		}
 	}
 
 	return s.wrapped.CreateSnapshot(ctx, ss)
@@ -125,3 +145,30 @@ func (s *SnapshotSchemaTableFinder) CreateSnapshot(ctx context.Context, ss *snap
 func (s *SnapshotSchemaTableFinder) Close() error {
 	return s.conn.Close(context.Background())
 }
+
+// matchingTables returns the configured table names, followed by the
+// discovered schema tables that match any of the configured patterns.
+func matchingTables(schema string, configuredTables, schemaTables []string) []string {
+	tables := []string{}
+	patterns := []string{}
+	for _, table := range configuredTables {
+		if pglib.IsTablePattern(table) {
+			patterns = append(patterns, table)
+			continue
+		}
+		tables = append(tables, table)
+	}
+
+	for _, table := range schemaTables {
+		if !slices.Contains(tables, table) && matchesAny(patterns, schema, table) {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+func matchesAny(patterns []string, schema, table string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		return pglib.MatchTablePattern(pattern, schema, table)
+	})
+}
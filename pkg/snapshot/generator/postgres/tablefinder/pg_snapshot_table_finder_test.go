@@ -230,6 +230,66 @@ func TestSnapshotTableFinder_CreateSnapshot(t *testing.T) {
 
 			wantErr: nil,
 		},
+		{
+			name: "ok - table patterns",
+			conn: &pgmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					require.Equal(t, "SELECT tablename FROM pg_tables WHERE schemaname=$1", query)
+					require.Equal(t, []any{testSchema}, args)
+					return newTestRows([]string{"data_tenant_1", "data_tenant_2", "data_tenant_archive", "tenants"}), nil
+				},
+			},
+			snapshot: &snapshot.Snapshot{
+				SchemaTables: map[string][]string{
+					testSchema: {"tenants", "data_tenant_*"},
+				},
+				SchemaExcludedTables: map[string][]string{
+					wildcard: {`^test-schema\.data_tenant_[^\d]+$`},
+				},
+			},
+			generator: &mocks.Generator{
+				CreateSnapshotFn: func(ctx context.Context, snapshot *snapshot.Snapshot) error {
+					require.Equal(t, map[string][]string{
+						testSchema: {"tenants", "data_tenant_1", "data_tenant_2"},
+					}, snapshot.SchemaTables)
+					return nil
+				},
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - schema wildcard with regular expression",
+			conn: &pgmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					switch query {
+					case "SELECT nspname FROM pg_catalog.pg_namespace WHERE nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast', 'pgstream')":
+						return newTestRows([]string{testSchema, "other-schema"}), nil
+					case "SELECT tablename FROM pg_tables WHERE schemaname=$1":
+						require.Len(t, args, 1)
+						return newTestRows([]string{"data_tenant_1", "tenants"}), nil
+					default:
+						t.Fatalf("unexpected query: %s", query)
+					}
+					return nil, nil // unreachable
+				},
+			},
+			snapshot: &snapshot.Snapshot{
+				SchemaTables: map[string][]string{
+					wildcard: {`^test-schema\.data_tenant_\d+$`},
+				},
+			},
+			generator: &mocks.Generator{
+				CreateSnapshotFn: func(ctx context.Context, snapshot *snapshot.Snapshot) error {
+					require.Equal(t, map[string][]string{
+						testSchema: {"data_tenant_1"},
+					}, snapshot.SchemaTables)
+					return nil
+				},
+			},
+
+			wantErr: nil,
+		},
 		{
 			name: "error - schema wildcard with table name",
 			conn: &pgmocks.Querier{
@@ -244,7 +304,7 @@ func TestSnapshotTableFinder_CreateSnapshot(t *testing.T) {
 				},
 			},
 
-			wantErr: errors.New("wildcard schema must be used with wildcard table, got [\"table-1\"]"),
+			wantErr: errors.New("wildcard schema must be used with wildcard table or table patterns, got [\"table-1\"]"),
 		},
 		{
 			name: "error - querying schema tables",
@@ -347,3 +407,19 @@ func TestSnapshotTableFinder_CreateSnapshot(t *testing.T) {
 		})
 	}
 }
+
+func newTestRows(values []string) *pgmocks.Rows {
+	return &pgmocks.Rows{
+		ScanFn: func(i uint, dest ...any) error {
+			value, ok := dest[0].(*string)
+			if !ok {
+				return errors.New("unexpected scan destination")
+			}
+			*value = values[i-1]
+			return nil
+		},
+		NextFn:  func(i uint) bool { return int(i) <= len(values) },
+		CloseFn: func() {},
+		ErrFn:   func() error { return nil },
+	}
+}
@@ -14,6 +14,24 @@ type Request struct {
 	Errors *SchemaErrors
 }
 
+// TableEvent reports the progress of the snapshot of a table.
+type TableEvent struct {
+	Type   TableEventType
+	Schema string
+	Table  string
+	// Rows is the number of rows snapshotted. Only set on completion.
+	Rows uint64
+	// Err is the reason the table snapshot failed. Only set on completion.
+	Err error
+}
+
+type TableEventType string
+
+const (
+	TableSnapshotStarted   = TableEventType("started")
+	TableSnapshotCompleted = TableEventType("completed")
+)
+
 type Status string
 
 const (
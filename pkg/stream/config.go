@@ -3,21 +3,57 @@
 package stream
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/audit/pgaudit"
 	"github.com/xataio/pgstream/pkg/backoff"
 	"github.com/xataio/pgstream/pkg/kafka"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer/committer"
+	filecheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/file"
 	kafkacheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/kafka"
+	pgcheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/postgres"
+	redischeckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/redis"
+	pglistener "github.com/xataio/pgstream/pkg/wal/listener/postgres"
 	snapshotbuilder "github.com/xataio/pgstream/pkg/wal/listener/snapshot/builder"
+	"github.com/xataio/pgstream/pkg/wal/processor/amqp"
+	"github.com/xataio/pgstream/pkg/wal/processor/auditlog"
+	"github.com/xataio/pgstream/pkg/wal/processor/bigquery"
+	"github.com/xataio/pgstream/pkg/wal/processor/clickhouse"
+	"github.com/xataio/pgstream/pkg/wal/processor/coalesce"
+	"github.com/xataio/pgstream/pkg/wal/processor/converter"
+	"github.com/xataio/pgstream/pkg/wal/processor/dynamodb"
+	"github.com/xataio/pgstream/pkg/wal/processor/filelog"
 	"github.com/xataio/pgstream/pkg/wal/processor/filter"
+	"github.com/xataio/pgstream/pkg/wal/processor/healthcheck"
 	"github.com/xataio/pgstream/pkg/wal/processor/injector"
 	kafkaprocessor "github.com/xataio/pgstream/pkg/wal/processor/kafka"
+	"github.com/xataio/pgstream/pkg/wal/processor/kafka/partition"
+	"github.com/xataio/pgstream/pkg/wal/processor/migration"
+	"github.com/xataio/pgstream/pkg/wal/processor/objectstore"
 	"github.com/xataio/pgstream/pkg/wal/processor/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/postgres/fkorder"
+	"github.com/xataio/pgstream/pkg/wal/processor/pubsub"
+	"github.com/xataio/pgstream/pkg/wal/processor/redshift"
+	"github.com/xataio/pgstream/pkg/wal/processor/router"
+	"github.com/xataio/pgstream/pkg/wal/processor/scd"
 	"github.com/xataio/pgstream/pkg/wal/processor/search"
 	"github.com/xataio/pgstream/pkg/wal/processor/search/store"
+	"github.com/xataio/pgstream/pkg/wal/processor/sequencer"
+	"github.com/xataio/pgstream/pkg/wal/processor/snowflake"
+	"github.com/xataio/pgstream/pkg/wal/processor/throttle"
+	"github.com/xataio/pgstream/pkg/wal/processor/toast"
+	"github.com/xataio/pgstream/pkg/wal/processor/transaction"
+	"github.com/xataio/pgstream/pkg/wal/processor/transform"
 	"github.com/xataio/pgstream/pkg/wal/processor/transformer"
+	"github.com/xataio/pgstream/pkg/wal/processor/typeevolution"
+	"github.com/xataio/pgstream/pkg/wal/processor/types"
+	"github.com/xataio/pgstream/pkg/wal/processor/validate"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/notifier"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/server"
 	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
@@ -26,18 +62,64 @@ import (
 type Config struct {
 	Listener  ListenerConfig
 	Processor ProcessorConfig
+	// Replay configures the archive and the LSN range of the events replayed
+	// by Replay. It's not used by the stream.
+	Replay *ReplayConfig
+}
+
+// ReplayConfig is the configuration of the replay of the events archived by
+// a file log or object store sink, through the configured processor.
+type ReplayConfig struct {
+	// StartLSN and EndLSN are the inclusive LSN range of the replayed events.
+	StartLSN string
+	EndLSN   string
+	// FileLogPath is the path the file log sink archived the events to.
+	FileLogPath string
+	// ObjectStore is the configuration of the object store sink that
+	// archived the events. Only one of the file log and object store
+	// archives can be configured.
+	ObjectStore *objectstore.Config
 }
 
 type ListenerConfig struct {
 	Postgres *PostgresListenerConfig
 	Kafka    *KafkaListenerConfig
+	// Checkpoint configures the cadence at which the processed positions are
+	// committed to the checkpointer backend. If not set, they're committed as
+	// soon as they're processed.
+	Checkpoint *committer.Config
 }
 
 type PostgresListenerConfig struct {
 	URL         string
 	Replication pgreplication.Config
 	RetryPolicy backoff.Config
-	Snapshot    *snapshotbuilder.SnapshotListenerConfig
+	// ReconnectPolicy is applied by the listener to re-establish a lost
+	// replication connection. It's only used when the RetryPolicy retries are
+	// disabled, since the retrier already resets the connection.
+	ReconnectPolicy backoff.Config
+	Snapshot        *snapshotbuilder.SnapshotListenerConfig
+	SlotMonitor     *pglistener.SlotMonitorConfig
+	Heartbeat       *pglistener.HeartbeatConfig
+	// StandbyStatusInterval is the interval at which the replication progress
+	// is reported to postgres. If not set, the listener default is used.
+	StandbyStatusInterval time.Duration
+	// CheckpointTable persists the checkpointed LSN into a postgres table, on
+	// top of the replication slot, and resumes the replication from it. The
+	// URL defaults to the postgres target, in which case the checkpoint is
+	// written in the same transaction as the batch it belongs to. The pipeline
+	// id defaults to the replication slot name.
+	CheckpointTable *pgcheckpoint.TableConfig
+	// CheckpointFile persists the checkpointed LSN into a local file, and
+	// resumes the replication from it. The replication slot is only synced
+	// once the LSN is durable in the file. It can't be combined with the
+	// checkpoint table.
+	CheckpointFile *filecheckpoint.Config
+	// CheckpointRedis commits the checkpointed LSN into redis, and resumes the
+	// replication from it. The replication slot is only synced once the LSN
+	// is committed. The stream id defaults to the replication slot name. It
+	// can't be combined with the checkpoint table nor file.
+	CheckpointRedis *redischeckpoint.Config
 }
 
 type KafkaListenerConfig struct {
@@ -46,17 +128,89 @@ type KafkaListenerConfig struct {
 }
 
 type ProcessorConfig struct {
-	Kafka       *KafkaProcessorConfig
-	Search      *SearchProcessorConfig
-	Webhook     *WebhookProcessorConfig
-	Postgres    *PostgresProcessorConfig
+	Kafka    *KafkaProcessorConfig
+	Search   *SearchProcessorConfig
+	Webhook  *WebhookProcessorConfig
+	Postgres *PostgresProcessorConfig
+	FileLog  *filelog.Config
+	PubSub   *pubsub.Config
+	AMQP     *amqp.Config
+	// Snowflake requires the Snowflake database/sql driver to be registered
+	// by importing github.com/snowflakedb/gosnowflake.
+	Snowflake *snowflake.Config
+	DynamoDB  *dynamodb.Config
+	// AuditLog keeps a compact journal of the changes, usually as one of the
+	// router targets, alongside the main target.
+	AuditLog *auditlog.Config
+	// ObjectStore archives the changes into JSONL or parquet objects in S3,
+	// GCS or Azure Blob Storage.
+	ObjectStore *objectstore.Config
+	ClickHouse  *clickhouse.Config
+	BigQuery    *bigquery.Config
+	// Router fans out the events to several target processors, as per the
+	// tables and actions of their routes.
+	Router      *RouterProcessorConfig
 	Injector    *injector.Config
 	Transformer *transformer.Config
 	Filter      *filter.Config
+	Converter   *converter.Config
+	// TypeRegistry has the custom converters registered for the postgres
+	// data types the built in conversions don't handle. It's only available
+	// programmatically, and can be updated while the pipeline is running.
+	TypeRegistry *types.Registry
+	TOASTCache   *toast.Config
+	// BeforeAfter sets the before image of the update and delete events to
+	// the latest known state of the rows, leaving their identity unchanged.
+	BeforeAfter *transform.Config
+	Validator   *validate.Config
+	// MigrationSafeMode pauses the processing of the replication events while
+	// the migration advisory lock is held.
+	MigrationSafeMode *migration.Config
+	// PGAudit enriches the replication events with the audit context of
+	// their transaction, as logged by the pg_audit extension.
+	PGAudit *pgaudit.Config
+	// SortKey buffers the insert events of the configured tables and sends
+	// them sorted by their sort key column.
+	SortKey *redshift.Config
+	// BulkUpdateCoalescer merges the updates of the same row received within
+	// a time window into a single update.
+	BulkUpdateCoalescer *coalesce.Config
+	// XIDSequencer buffers the events of each transaction until it's
+	// committed, and sends them in LSN order with their sequence number
+	// within the transaction.
+	XIDSequencer *sequencer.Config
+	// TransactionHooks are called on the transaction boundaries. The commit
+	// hook is called once the transaction has been checkpointed by the
+	// target, and the rollback hook for the transactions whose commit event
+	// never reaches the processor. They're only available programmatically.
+	TransactionHooks *transaction.Config
+	// SinkHealthCheck pings the target before processing begins and on an
+	// interval, blocking the processing while it's unreachable.
+	SinkHealthCheck *healthcheck.Config
+	// TableSizeThrottle slows down the snapshot events of the tables larger
+	// than the configured size, at a rate inversely proportional to it.
+	TableSizeThrottle *throttle.Config
+	// TypeEvolution coerces the column values of the replayed events to the
+	// types recorded in the schema log when they were produced.
+	TypeEvolution *typeevolution.Config
+}
+
+// RouterProcessorConfig sends the events to the targets of all the routes
+// they match, from a single listener.
+type RouterProcessorConfig struct {
+	Routes []RouteConfig
+}
+
+type RouteConfig struct {
+	Route router.Route
+	// Target is the processor the route events are sent to. Only the target
+	// processors are used, the modifiers apply to the whole pipeline.
+	Target ProcessorConfig
 }
 
 type KafkaProcessorConfig struct {
-	Writer *kafkaprocessor.Config
+	Writer       *kafkaprocessor.Config
+	Partitioning *partition.Config
 }
 
 type SearchProcessorConfig struct {
@@ -73,6 +227,13 @@ type WebhookProcessorConfig struct {
 
 type PostgresProcessorConfig struct {
 	BatchWriter postgres.Config
+	// SCDType2 replaces the batch writer with a sink that keeps the history of
+	// the rows in the target as slowly changing dimensions of type 2.
+	SCDType2 *scd.Config
+	// FKOrdering sorts the inserts of each replicated transaction by the
+	// foreign key dependencies of their tables, and defers the checks of the
+	// circular foreign keys in the target transactions.
+	FKOrdering *fkorder.Config
 }
 
 type WebhookSubscriptionStoreConfig struct {
@@ -81,14 +242,109 @@ type WebhookSubscriptionStoreConfig struct {
 	CacheRefreshInterval time.Duration
 }
 
+var (
+	errXIDSequencerRequiresTransaction    = errors.New("xid sequencer requires a postgres listener with the transaction records included")
+	errFKOrderingRequiresTransaction      = errors.New("foreign key ordering requires a postgres listener with the transaction records included")
+	errTransactionHooksRequireTransaction = errors.New("transaction hooks require a postgres listener with the transaction records included")
+	errPGAuditRequiresTransaction         = errors.New("pg_audit correlation requires a postgres listener with the transaction records included")
+	errCheckpointTableRequiresURL         = errors.New("checkpoint table requires a URL when the target is not postgres")
+	errMultipleCheckpointBackends         = errors.New("only one of checkpoint table, checkpoint file or checkpoint redis can be configured")
+	errCheckpointBytesRequirePostgres     = errors.New("checkpoint max uncommitted bytes requires a postgres listener")
+	errMissingReplayArchive               = errors.New("replay requires a file log or object store archive")
+	errMultipleReplayArchives             = errors.New("only one of file log or object store archive can be replayed")
+	errMissingReplayLSNRange              = errors.New("replay requires a start and end LSN")
+)
+
 func (c *Config) IsValid() error {
 	if err := c.Listener.IsValid(); err != nil {
 		return err
 	}
 
+	if err := c.validateTablePatterns(); err != nil {
+		return err
+	}
+
+	// the sequencer relies on the transaction id of the events and the commit
+	// records, which are only available with the wal2json transaction records
+	if c.Processor.XIDSequencer != nil && !c.includesTransaction() {
+		return errXIDSequencerRequiresTransaction
+	}
+
+	// the events are grouped by transaction id to be sorted, which is only
+	// available with the wal2json transaction records
+	if c.Processor.Postgres != nil && c.Processor.Postgres.FKOrdering != nil && !c.includesTransaction() {
+		return errFKOrderingRequiresTransaction
+	}
+
+	if c.Processor.TransactionHooks != nil && !c.includesTransaction() {
+		return errTransactionHooksRequireTransaction
+	}
+
+	// the audit entries are matched to the events by transaction id
+	if c.Processor.PGAudit != nil && !c.includesTransaction() {
+		return errPGAuditRequiresTransaction
+	}
+
+	if c.Listener.Postgres != nil {
+		if c.Listener.Postgres.CheckpointTable != nil && c.Listener.Postgres.CheckpointTable.URL == "" && c.Processor.Postgres == nil {
+			return errCheckpointTableRequiresURL
+		}
+		if c.Listener.Postgres.checkpointBackends() > 1 {
+			return errMultipleCheckpointBackends
+		}
+	}
+
+	// the uncommitted bytes are measured as the WAL distance between the
+	// processed and committed LSNs
+	if c.Listener.Checkpoint != nil && c.Listener.Checkpoint.MaxUncommittedBytes > 0 && c.Listener.Postgres == nil {
+		return errCheckpointBytesRequirePostgres
+	}
+
 	return c.Processor.IsValid()
 }
 
+func (c *ReplayConfig) IsValid() error {
+	if c == nil || (c.FileLogPath == "" && c.ObjectStore == nil) {
+		return errMissingReplayArchive
+	}
+	if c.FileLogPath != "" && c.ObjectStore != nil {
+		return errMultipleReplayArchives
+	}
+	if c.StartLSN == "" || c.EndLSN == "" {
+		return errMissingReplayLSNRange
+	}
+	return nil
+}
+
+// includesTransaction returns true if the postgres listener emits the
+// transaction records.
+func (c *Config) includesTransaction() bool {
+	return c.Listener.Postgres != nil && c.Listener.Postgres.Replication.Plugin.IncludeTransaction
+}
+
+// validateTablePatterns makes sure the table patterns of the replication,
+// snapshot and filter configurations compile, so that invalid patterns fail
+// before the stream starts.
+func (c *Config) validateTablePatterns() error {
+	tableLists := [][]string{}
+	if c.Listener.Postgres != nil {
+		tableLists = append(tableLists, c.Listener.Postgres.Replication.IncludeTables, c.Listener.Postgres.Replication.ExcludeTables)
+		if c.Listener.Postgres.Snapshot != nil {
+			tableLists = append(tableLists, c.Listener.Postgres.Snapshot.Adapter.Tables, c.Listener.Postgres.Snapshot.Adapter.ExcludedTables)
+		}
+	}
+	if c.Processor.Filter != nil {
+		tableLists = append(tableLists, c.Processor.Filter.IncludeTables, c.Processor.Filter.ExcludeTables)
+	}
+
+	for _, tables := range tableLists {
+		if err := pglib.ValidateTablePatterns(tables); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *ListenerConfig) IsValid() error {
 	listenerCount := 0
 	if c.Kafka != nil {
@@ -124,6 +380,39 @@ func (c *ProcessorConfig) IsValid() error {
 	if c.Webhook != nil {
 		processorCount++
 	}
+	if c.FileLog != nil {
+		processorCount++
+	}
+	if c.PubSub != nil {
+		processorCount++
+	}
+	if c.AMQP != nil {
+		processorCount++
+	}
+	if c.Snowflake != nil {
+		processorCount++
+	}
+	if c.DynamoDB != nil {
+		processorCount++
+	}
+	if c.AuditLog != nil {
+		processorCount++
+	}
+	if c.ObjectStore != nil {
+		processorCount++
+	}
+	if c.ClickHouse != nil {
+		processorCount++
+	}
+	if c.BigQuery != nil {
+		processorCount++
+	}
+	if c.Router != nil {
+		processorCount++
+		if err := c.Router.IsValid(); err != nil {
+			return err
+		}
+	}
 
 	switch processorCount {
 	case 0:
@@ -137,6 +426,102 @@ func (c *ProcessorConfig) IsValid() error {
 	}
 }
 
+var errNestedRouter = errors.New("router routes cannot target another router")
+
+func (c *RouterProcessorConfig) IsValid() error {
+	if len(c.Routes) == 0 {
+		return errors.New("router needs at least one route configured")
+	}
+	for i, route := range c.Routes {
+		if route.Target.Router != nil {
+			return errNestedRouter
+		}
+		if err := route.Target.IsValid(); err != nil {
+			return fmt.Errorf("router route %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// checkpointTableConfig returns the checkpoint table configuration with the
+// defaults applied, using the replication slot name on input as the default
+// pipeline id.
+func (c *Config) checkpointTableConfig(slotName string) *pgcheckpoint.TableConfig {
+	cfg := *c.Listener.Postgres.CheckpointTable
+	if cfg.URL == "" && c.Processor.Postgres != nil {
+		cfg.URL = c.Processor.Postgres.BatchWriter.URL
+	}
+	if cfg.PipelineID == "" {
+		cfg.PipelineID = slotName
+	}
+	return &cfg
+}
+
+// CheckpointTableConfig returns the checkpoint table configuration with the
+// URL default applied, or nil if the checkpoint table is not configured.
+func (c *Config) CheckpointTableConfig() *pgcheckpoint.TableConfig {
+	if c.Listener.Postgres == nil || c.Listener.Postgres.CheckpointTable == nil {
+		return nil
+	}
+	return c.checkpointTableConfig("")
+}
+
+// checkpointRedisConfig returns the redis checkpointer configuration, using
+// the replication slot name on input as the default stream id.
+func (c *Config) checkpointRedisConfig(slotName string) *redischeckpoint.Config {
+	cfg := *c.Listener.Postgres.CheckpointRedis
+	if cfg.StreamID == "" {
+		cfg.StreamID = slotName
+	}
+	return &cfg
+}
+
+// checkpointBackends returns the number of checkpoint backends configured on
+// top of the replication slot.
+func (c *PostgresListenerConfig) checkpointBackends() int {
+	backends := 0
+	if c.CheckpointTable != nil {
+		backends++
+	}
+	if c.CheckpointFile != nil {
+		backends++
+	}
+	if c.CheckpointRedis != nil {
+		backends++
+	}
+	return backends
+}
+
+// checkpointsInTarget returns true if the checkpoint table is stored in the
+// database the postgres batch writer writes to, which allows the checkpoints
+// to be written in the batch transactions.
+func (c *Config) checkpointsInTarget() bool {
+	if c.Listener.Postgres == nil || c.Listener.Postgres.CheckpointTable == nil ||
+		c.Processor.Postgres == nil || c.Processor.Postgres.SCDType2 != nil {
+		return false
+	}
+	url := c.Listener.Postgres.CheckpointTable.URL
+	return url == "" || url == c.Processor.Postgres.BatchWriter.URL
+}
+
+// PipelineVersion returns the hex encoded SHA256 hash of the configuration,
+// which identifies the version of the pipeline. The programmatic only
+// settings are not part of the version, since they can't be serialised.
+func (c *Config) PipelineVersion() (string, error) {
+	cfg := *c
+	cfg.Processor.TypeRegistry = nil
+	cfg.Processor.TransactionHooks = nil
+	// replayed events are stamped with the version of the live pipeline
+	cfg.Replay = nil
+	// encoding/json sorts the map keys, which keeps the hash deterministic
+	cfgBytes, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("serialising pipeline config: %w", err)
+	}
+	hash := sha256.Sum256(cfgBytes)
+	return hex.EncodeToString(hash[:]), nil
+}
+
 func (c *Config) SourcePostgresURL() string {
 	if c.Listener.Postgres != nil {
 		return c.Listener.Postgres.URL
@@ -144,6 +529,21 @@ func (c *Config) SourcePostgresURL() string {
 	return ""
 }
 
+// healthMode returns the mode the stream state is reported with by the health
+// endpoints.
+func (c *Config) healthMode() string {
+	switch {
+	case c.Listener.Postgres != nil && c.Listener.Postgres.Snapshot != nil:
+		return "snapshot_and_replication"
+	case c.Listener.Postgres != nil:
+		return "replication"
+	case c.Listener.Kafka != nil:
+		return "kafka"
+	default:
+		return ""
+	}
+}
+
 func (c *Config) PostgresReplicationSlot() string {
 	if c.Listener.Postgres != nil {
 		return c.Listener.Postgres.Replication.ReplicationSlotName
@@ -151,6 +551,20 @@ func (c *Config) PostgresReplicationSlot() string {
 	return ""
 }
 
+func (c *Config) PostgresReplicationSlotConfig() pgreplication.SlotConfig {
+	if c.Listener.Postgres != nil {
+		return c.Listener.Postgres.Replication.Slot
+	}
+	return pgreplication.SlotConfig{}
+}
+
+func (c *Config) PostgresReplicationPlugin() string {
+	if c.Listener.Postgres != nil {
+		return c.Listener.Postgres.Replication.Plugin.OutputPlugin()
+	}
+	return pgreplication.PluginWal2JSON
+}
+
 func (c *Config) RequiredTables() []string {
 	requiredTables := []string{}
 	if c.Listener.Postgres != nil {
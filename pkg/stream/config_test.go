@@ -0,0 +1,533 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer/committer"
+	filecheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/file"
+	pgcheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/postgres"
+	redischeckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/redis"
+	snapshotbuilder "github.com/xataio/pgstream/pkg/wal/listener/snapshot/builder"
+	"github.com/xataio/pgstream/pkg/wal/processor/filelog"
+	"github.com/xataio/pgstream/pkg/wal/processor/filter"
+	"github.com/xataio/pgstream/pkg/wal/processor/objectstore"
+	"github.com/xataio/pgstream/pkg/wal/processor/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/postgres/fkorder"
+	"github.com/xataio/pgstream/pkg/wal/processor/scd"
+	"github.com/xataio/pgstream/pkg/wal/processor/transaction"
+	"github.com/xataio/pgstream/pkg/wal/processor/types"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+func TestConfig_IsValid_tablePatterns(t *testing.T) {
+	t.Parallel()
+
+	newConfig := func(replicationTables, snapshotTables, filterTables []string) *Config {
+		cfg := &Config{
+			Listener: ListenerConfig{
+				Postgres: &PostgresListenerConfig{
+					Replication: pgreplication.Config{IncludeTables: replicationTables},
+					Snapshot:    &snapshotbuilder.SnapshotListenerConfig{},
+				},
+			},
+			Processor: ProcessorConfig{
+				FileLog: &filelog.Config{},
+				Filter:  &filter.Config{ExcludeTables: filterTables},
+			},
+		}
+		cfg.Listener.Postgres.Snapshot.Adapter.Tables = snapshotTables
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		config *Config
+
+		wantErr error
+	}{
+		{
+			name:    "ok - valid patterns",
+			config:  newConfig([]string{"public.data_tenant_*"}, []string{`^public\.data_tenant_\d+$`}, []string{"public.data_tenant_[ab]"}),
+			wantErr: nil,
+		},
+		{
+			name:    "error - invalid replication pattern",
+			config:  newConfig([]string{"public.data_tenant_["}, nil, nil),
+			wantErr: pglib.ErrInvalidTablePattern,
+		},
+		{
+			name:    "error - invalid snapshot pattern",
+			config:  newConfig(nil, []string{`^public\.(data`}, nil),
+			wantErr: pglib.ErrInvalidTablePattern,
+		},
+		{
+			name:    "error - invalid filter pattern",
+			config:  newConfig(nil, nil, []string{`^public\.data_tenant_\d+[`}),
+			wantErr: pglib.ErrInvalidTablePattern,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.config.IsValid()
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestConfig_IsValid_fkOrdering(t *testing.T) {
+	t.Parallel()
+
+	newConfig := func(includeTransaction bool, fkOrdering *fkorder.Config) *Config {
+		cfg := &Config{
+			Listener: ListenerConfig{
+				Postgres: &PostgresListenerConfig{},
+			},
+			Processor: ProcessorConfig{
+				Postgres: &PostgresProcessorConfig{FKOrdering: fkOrdering},
+			},
+		}
+		cfg.Listener.Postgres.Replication.Plugin.IncludeTransaction = includeTransaction
+		return cfg
+	}
+
+	tests := []struct {
+		name   string
+		config *Config
+
+		wantErr error
+	}{
+		{
+			name:    "ok - fk ordering with transaction records",
+			config:  newConfig(true, &fkorder.Config{}),
+			wantErr: nil,
+		},
+		{
+			name:    "ok - fk ordering disabled",
+			config:  newConfig(false, nil),
+			wantErr: nil,
+		},
+		{
+			name:    "error - fk ordering without transaction records",
+			config:  newConfig(false, &fkorder.Config{}),
+			wantErr: errFKOrderingRequiresTransaction,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.config.IsValid()
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestConfig_IsValid_checkpoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		listener ListenerConfig
+
+		wantErr error
+	}{
+		{
+			name: "ok - max uncommitted bytes with postgres listener",
+			listener: ListenerConfig{
+				Postgres:   &PostgresListenerConfig{},
+				Checkpoint: &committer.Config{MaxUncommittedBytes: 1024},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "ok - max uncommitted events with kafka listener",
+			listener: ListenerConfig{
+				Kafka:      &KafkaListenerConfig{},
+				Checkpoint: &committer.Config{MaxUncommittedEvents: 100},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "error - max uncommitted bytes with kafka listener",
+			listener: ListenerConfig{
+				Kafka:      &KafkaListenerConfig{},
+				Checkpoint: &committer.Config{MaxUncommittedBytes: 1024},
+			},
+			wantErr: errCheckpointBytesRequirePostgres,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			config := &Config{
+				Listener: tc.listener,
+				Processor: ProcessorConfig{
+					Postgres: &PostgresProcessorConfig{},
+				},
+			}
+			err := config.IsValid()
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestConfig_checkpointTable(t *testing.T) {
+	t.Parallel()
+
+	const targetURL = "postgres://target"
+
+	newConfig := func(checkpointURL string, target *PostgresProcessorConfig) *Config {
+		cfg := &Config{
+			Listener: ListenerConfig{
+				Postgres: &PostgresListenerConfig{
+					CheckpointTable: &pgcheckpoint.TableConfig{URL: checkpointURL},
+				},
+			},
+			Processor: ProcessorConfig{
+				Postgres: target,
+			},
+		}
+		if target == nil {
+			cfg.Processor.FileLog = &filelog.Config{}
+		}
+		return cfg
+	}
+	postgresTarget := func() *PostgresProcessorConfig {
+		return &PostgresProcessorConfig{BatchWriter: postgres.Config{URL: targetURL}}
+	}
+
+	tests := []struct {
+		name   string
+		config *Config
+
+		wantErr      error
+		wantURL      string
+		wantInTarget bool
+	}{
+		{
+			name:   "ok - defaults to the postgres target",
+			config: newConfig("", postgresTarget()),
+
+			wantURL:      targetURL,
+			wantInTarget: true,
+		},
+		{
+			name:   "ok - postgres target url",
+			config: newConfig(targetURL, postgresTarget()),
+
+			wantURL:      targetURL,
+			wantInTarget: true,
+		},
+		{
+			name:   "ok - different database",
+			config: newConfig("postgres://checkpoints", postgresTarget()),
+
+			wantURL:      "postgres://checkpoints",
+			wantInTarget: false,
+		},
+		{
+			name: "ok - scd type 2 target",
+			config: newConfig("", &PostgresProcessorConfig{
+				BatchWriter: postgres.Config{URL: targetURL},
+				SCDType2:    &scd.Config{},
+			}),
+
+			wantURL:      targetURL,
+			wantInTarget: false,
+		},
+		{
+			name:   "ok - non postgres target",
+			config: newConfig("postgres://checkpoints", nil),
+
+			wantURL:      "postgres://checkpoints",
+			wantInTarget: false,
+		},
+		{
+			name:   "error - non postgres target without url",
+			config: newConfig("", nil),
+
+			wantErr: errCheckpointTableRequiresURL,
+		},
+		{
+			name: "error - checkpoint table and file",
+			config: func() *Config {
+				cfg := newConfig("", postgresTarget())
+				cfg.Listener.Postgres.CheckpointFile = &filecheckpoint.Config{Path: "checkpoint.json"}
+				return cfg
+			}(),
+
+			wantErr: errMultipleCheckpointBackends,
+		},
+		{
+			name: "error - checkpoint table and redis",
+			config: func() *Config {
+				cfg := newConfig("", postgresTarget())
+				cfg.Listener.Postgres.CheckpointRedis = &redischeckpoint.Config{Addresses: []string{"localhost:6379"}}
+				return cfg
+			}(),
+
+			wantErr: errMultipleCheckpointBackends,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.config.IsValid()
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+
+			cfg := tc.config.checkpointTableConfig("test_slot")
+			require.Equal(t, &pgcheckpoint.TableConfig{URL: tc.wantURL, PipelineID: "test_slot"}, cfg)
+			require.Equal(t, tc.wantInTarget, tc.config.checkpointsInTarget())
+		})
+	}
+}
+
+func TestConfig_checkpointRedis(t *testing.T) {
+	t.Parallel()
+
+	newConfig := func(streamID string) *Config {
+		return &Config{
+			Listener: ListenerConfig{
+				Postgres: &PostgresListenerConfig{
+					CheckpointRedis: &redischeckpoint.Config{
+						Addresses: []string{"localhost:6379"},
+						StreamID:  streamID,
+					},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		config *Config
+
+		wantStreamID string
+	}{
+		{
+			name:   "ok - defaults to the replication slot name",
+			config: newConfig(""),
+
+			wantStreamID: "test_slot",
+		},
+		{
+			name:   "ok - stream id",
+			config: newConfig("test_stream"),
+
+			wantStreamID: "test_stream",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := tc.config.checkpointRedisConfig("test_slot")
+			require.Equal(t, tc.wantStreamID, cfg.StreamID)
+			// the listener configuration is not modified
+			require.NotSame(t, tc.config.Listener.Postgres.CheckpointRedis, cfg)
+		})
+	}
+}
+
+func TestConfig_PipelineVersion(t *testing.T) {
+	t.Parallel()
+
+	newConfig := func(slotName string) *Config {
+		return &Config{
+			Listener: ListenerConfig{
+				Postgres: &PostgresListenerConfig{
+					URL:         "postgres://localhost:5432",
+					Replication: pgreplication.Config{ReplicationSlotName: slotName},
+				},
+			},
+			Processor: ProcessorConfig{
+				FileLog: &filelog.Config{Path: "events.log"},
+			},
+		}
+	}
+
+	version, err := newConfig("test_slot").PipelineVersion()
+	require.NoError(t, err)
+	require.Len(t, version, 64)
+
+	tests := []struct {
+		name   string
+		config *Config
+
+		wantSameVersion bool
+	}{
+		{
+			name:   "ok - same config",
+			config: newConfig("test_slot"),
+
+			wantSameVersion: true,
+		},
+		{
+			name: "ok - programmatic settings are ignored",
+			config: func() *Config {
+				cfg := newConfig("test_slot")
+				cfg.Processor.TypeRegistry = types.NewRegistry()
+				cfg.Processor.TransactionHooks = &transaction.Config{
+					OnCommit: func(xid uint32, position wal.CommitPosition) {},
+				}
+				return cfg
+			}(),
+
+			wantSameVersion: true,
+		},
+		{
+			name: "ok - replay settings are ignored",
+			config: func() *Config {
+				cfg := newConfig("test_slot")
+				cfg.Replay = &ReplayConfig{FileLogPath: "events.log", StartLSN: "0/10", EndLSN: "0/20"}
+				return cfg
+			}(),
+
+			wantSameVersion: true,
+		},
+		{
+			name:   "ok - different config",
+			config: newConfig("other_slot"),
+
+			wantSameVersion: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotVersion, err := tc.config.PipelineVersion()
+			require.NoError(t, err)
+			require.Equal(t, tc.wantSameVersion, gotVersion == version)
+		})
+	}
+}
+
+func TestReplayConfig_IsValid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config *ReplayConfig
+
+		wantErr error
+	}{
+		{
+			name:    "ok - file log",
+			config:  &ReplayConfig{FileLogPath: "events.log", StartLSN: "0/10", EndLSN: "0/20"},
+			wantErr: nil,
+		},
+		{
+			name:    "ok - object store",
+			config:  &ReplayConfig{ObjectStore: &objectstore.Config{Bucket: "bucket"}, StartLSN: "0/10", EndLSN: "0/20"},
+			wantErr: nil,
+		},
+		{
+			name:    "error - not configured",
+			config:  nil,
+			wantErr: errMissingReplayArchive,
+		},
+		{
+			name:    "error - missing archive",
+			config:  &ReplayConfig{StartLSN: "0/10", EndLSN: "0/20"},
+			wantErr: errMissingReplayArchive,
+		},
+		{
+			name:    "error - multiple archives",
+			config:  &ReplayConfig{FileLogPath: "events.log", ObjectStore: &objectstore.Config{Bucket: "bucket"}, StartLSN: "0/10", EndLSN: "0/20"},
+			wantErr: errMultipleReplayArchives,
+		},
+		{
+			name:    "error - missing end lsn",
+			config:  &ReplayConfig{FileLogPath: "events.log", StartLSN: "0/10"},
+			wantErr: errMissingReplayLSNRange,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.config.IsValid()
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestProcessorConfig_IsValid_router(t *testing.T) {
+	t.Parallel()
+
+	fileLogRoute := RouteConfig{Target: ProcessorConfig{FileLog: &filelog.Config{}}}
+
+	tests := []struct {
+		name   string
+		config *ProcessorConfig
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			config: &ProcessorConfig{
+				Router: &RouterProcessorConfig{Routes: []RouteConfig{fileLogRoute, fileLogRoute}},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "error - nested router",
+			config: &ProcessorConfig{
+				Router: &RouterProcessorConfig{Routes: []RouteConfig{
+					{Target: ProcessorConfig{Router: &RouterProcessorConfig{Routes: []RouteConfig{fileLogRoute}}}},
+				}},
+			},
+			wantErr: errNestedRouter,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.config.IsValid()
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+
+	t.Run("error - no routes", func(t *testing.T) {
+		t.Parallel()
+
+		require.Error(t, (&ProcessorConfig{Router: &RouterProcessorConfig{}}).IsValid())
+	})
+
+	t.Run("error - route without target", func(t *testing.T) {
+		t.Parallel()
+
+		require.Error(t, (&ProcessorConfig{Router: &RouterProcessorConfig{Routes: []RouteConfig{{}}}}).IsValid())
+	})
+
+	t.Run("error - router and another processor", func(t *testing.T) {
+		t.Parallel()
+
+		require.Error(t, (&ProcessorConfig{
+			FileLog: &filelog.Config{},
+			Router:  &RouterProcessorConfig{Routes: []RouteConfig{fileLogRoute}},
+		}).IsValid())
+	})
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/xataio/pgstream/pkg/wal/processor/injector"
 	kafkaprocessor "github.com/xataio/pgstream/pkg/wal/processor/kafka"
 	"github.com/xataio/pgstream/pkg/wal/processor/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/pubsub"
 	"github.com/xataio/pgstream/pkg/wal/processor/search/store"
 	"github.com/xataio/pgstream/pkg/wal/processor/transformer"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook"
@@ -43,6 +44,7 @@ var (
 	kafkaBrokers     []string
 	opensearchURL    string
 	elasticsearchURL string
+	pubsubHost       string
 )
 
 const (
@@ -51,6 +53,8 @@ const (
 
 	withGeneratedColumn    = true
 	withoutGeneratedColumn = false
+
+	pubsubProjectID = "pgstream-test"
 )
 
 type mockProcessor struct {
@@ -132,11 +136,15 @@ func testLogger() loglib.Logger {
 }
 
 func testPostgresListenerCfg() stream.ListenerConfig {
+	return testPostgresListenerCfgWithURL(pgurl)
+}
+
+func testPostgresListenerCfgWithURL(sourceURL string) stream.ListenerConfig {
 	return stream.ListenerConfig{
 		Postgres: &stream.PostgresListenerConfig{
-			URL: pgurl,
+			URL: sourceURL,
 			Replication: pgreplication.Config{
-				PostgresURL: pgurl,
+				PostgresURL: sourceURL,
 			},
 		},
 	}
@@ -194,6 +202,21 @@ func testKafkaProcessorCfg() stream.ProcessorConfig {
 	}
 }
 
+func testPubSubProcessorCfg(tableTopics map[string]string) stream.ProcessorConfig {
+	return stream.ProcessorConfig{
+		PubSub: &pubsub.Config{
+			ProjectID:    pubsubProjectID,
+			TableTopics:  tableTopics,
+			EmulatorHost: pubsubHost,
+		},
+		Injector: &injector.Config{
+			Store: schemalogpg.Config{
+				URL: pgurl,
+			},
+		},
+	}
+}
+
 func testSearchProcessorCfg(storeCfg store.Config) stream.ProcessorConfig {
 	return stream.ProcessorConfig{
 		Search: &stream.SearchProcessorConfig{
@@ -224,10 +247,14 @@ func testWebhookProcessorCfg() stream.ProcessorConfig {
 }
 
 func testPostgresProcessorCfg(sourcePGURL string, bulkIngestion bool) stream.ProcessorConfig {
+	return testPostgresProcessorCfgWithTarget(sourcePGURL, targetPGURL, bulkIngestion)
+}
+
+func testPostgresProcessorCfgWithTarget(sourcePGURL, targetURL string, bulkIngestion bool) stream.ProcessorConfig {
 	return stream.ProcessorConfig{
 		Postgres: &stream.PostgresProcessorConfig{
 			BatchWriter: postgres.Config{
-				URL: targetPGURL,
+				URL: targetURL,
 				BatchConfig: batch.Config{
 					MaxBatchSize: 1,
 					// BatchTimeout: 50 * time.Millisecond,
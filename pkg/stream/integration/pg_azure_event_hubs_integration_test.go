@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/kafka"
+	"github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/stream"
+	"github.com/xataio/pgstream/pkg/wal"
+	kafkalistener "github.com/xataio/pgstream/pkg/wal/listener/kafka"
+	kafkaprocessor "github.com/xataio/pgstream/pkg/wal/processor/kafka"
+)
+
+// Test_PostgresToAzureEventHubs requires an existing Azure Event Hubs
+// namespace in the Standard tier or higher, since there is no emulator for the
+// Kafka endpoint. The event hub is read from the EntityPath of the connection
+// string, or from PGSTREAM_AZURE_EVENT_HUBS_EVENT_HUB.
+func Test_PostgresToAzureEventHubs(t *testing.T) {
+	if os.Getenv("PGSTREAM_INTEGRATION_TESTS") == "" {
+		t.Skip("skipping integration test...")
+	}
+	connectionString := os.Getenv("PGSTREAM_AZURE_EVENT_HUBS_CONNECTION_STRING")
+	if connectionString == "" {
+		t.Skip("skipping azure event hubs integration test, no connection string provided...")
+	}
+
+	azureCfg := &kafka.AzureEventHubsConfig{
+		ConnectionString: connectionString,
+		EventHub:         os.Getenv("PGSTREAM_AZURE_EVENT_HUBS_EVENT_HUB"),
+	}
+	connCfg, err := azureCfg.ConnConfig()
+	require.NoError(t, err)
+
+	processorCfg := testKafkaProcessorCfg()
+	processorCfg.Kafka = &stream.KafkaProcessorConfig{
+		Writer: &kafkaprocessor.Config{
+			Kafka: *connCfg,
+		},
+	}
+	cfg := &stream.Config{
+		Listener:  testPostgresListenerCfg(),
+		Processor: processorCfg,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runStream(t, ctx, cfg)
+
+	// use a mock processor and a kafka reader on the same event hub to
+	// validate the messages are properly sent
+	mockProcessor := &mockProcessor{
+		eventChan: make(chan *wal.Event),
+	}
+	defer mockProcessor.close()
+
+	kafkaReader, err := kafka.NewReader(kafka.ReaderConfig{
+		Conn:                     *connCfg,
+		ConsumerGroupID:          "pgstream-integration-test-group",
+		ConsumerGroupStartOffset: "latest",
+	}, log.NewNoopLogger())
+	require.NoError(t, err)
+	reader, err := kafkalistener.NewWALReader(kafkaReader, mockProcessor.process)
+	require.NoError(t, err)
+	go func() {
+		defer func() {
+			reader.Close()
+			kafkaReader.Close()
+		}()
+		reader.Listen(ctx)
+	}()
+
+	testTable := fmt.Sprintf("pg2eventhubs_integration_test_%d", time.Now().Unix())
+	execQuery(t, ctx, fmt.Sprintf("create table %s(id serial primary key, name text)", testTable))
+	execQuery(t, ctx, fmt.Sprintf("insert into %s(name) values('a')", testTable))
+
+	timer := time.NewTimer(60 * time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			cancel()
+			t.Error("timeout waiting for wal event")
+			return
+		case event := <-mockProcessor.eventChan:
+			require.NotNil(t, event.Data)
+			if event.Data.Table != testTable {
+				continue
+			}
+			require.Equal(t, "I", event.Data.Action)
+			require.Equal(t, "public", event.Data.Schema)
+			return
+		}
+	}
+}
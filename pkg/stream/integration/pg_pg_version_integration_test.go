@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/internal/testcontainers"
+	"github.com/xataio/pgstream/pkg/stream"
+)
+
+func Test_PostgresToPostgres_CrossVersion(t *testing.T) {
+	if os.Getenv("PGSTREAM_INTEGRATION_TESTS") == "" {
+		t.Skip("skipping integration test...")
+	}
+
+	tests := []struct {
+		name        string
+		sourceImage testcontainers.PostgresImage
+		targetImage testcontainers.PostgresImage
+	}{
+		{
+			name:        "postgres 15 to postgres 16",
+			sourceImage: testcontainers.Postgres15,
+			targetImage: testcontainers.Postgres16,
+		},
+		{
+			name:        "postgres 16 to postgres 15",
+			sourceImage: testcontainers.Postgres16,
+			targetImage: testcontainers.Postgres15,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var sourceURL, targetURL string
+			sourceCleanup, err := testcontainers.SetupPostgresContainer(ctx, &sourceURL, tc.sourceImage, "config/postgresql.conf")
+			require.NoError(t, err)
+			defer sourceCleanup()
+
+			targetCleanup, err := testcontainers.SetupPostgresContainer(ctx, &targetURL, tc.targetImage)
+			require.NoError(t, err)
+			defer targetCleanup()
+
+			initStream(t, ctx, sourceURL)
+
+			cfg := &stream.Config{
+				Listener:  testPostgresListenerCfgWithURL(sourceURL),
+				Processor: testPostgresProcessorCfgWithTarget(sourceURL, targetURL, withoutBulkIngestion),
+			}
+			runStream(t, ctx, cfg)
+
+			targetConn, err := pglib.NewConn(ctx, targetURL)
+			require.NoError(t, err)
+			defer targetConn.Close(ctx)
+
+			testTable := "pg2pg_version_integration_test"
+			queries := []string{
+				fmt.Sprintf("create table %s(id int generated always as identity primary key, name text, username text generated always as (lower(name)) stored)", testTable),
+				fmt.Sprintf("insert into %s(name) values('Alice')", testTable),
+				fmt.Sprintf("update %s set name='Bob' where name='Alice'", testTable),
+			}
+			for _, query := range queries {
+				execQueryWithURL(t, ctx, sourceURL, query)
+			}
+
+			timer := time.NewTimer(20 * time.Second)
+			defer timer.Stop()
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-timer.C:
+					t.Error("timeout waiting for postgres sync")
+					return
+				case <-ticker.C:
+					columns := getTestTableColumns(t, ctx, targetConn, fmt.Sprintf("select id,name,username from %s", testTable), true)
+					if len(columns) == 0 || columns[0].name != "Bob" {
+						continue
+					}
+					require.ElementsMatch(t, []*testTableColumn{{id: 1, name: "Bob", username: "bob"}}, columns)
+					return
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/stream"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+type pubsubReceivedMessage struct {
+	AckID   string `json:"ackId"`
+	Message struct {
+		Data        []byte            `json:"data"`
+		Attributes  map[string]string `json:"attributes"`
+		OrderingKey string            `json:"orderingKey"`
+	} `json:"message"`
+}
+
+func Test_PostgresToPubSub(t *testing.T) {
+	if os.Getenv("PGSTREAM_INTEGRATION_TESTS") == "" {
+		t.Skip("skipping integration test...")
+	}
+
+	testTable := "pg2pubsub_integration_test"
+	testTopic := "pg2pubsub-topic"
+	testSubscription := "pg2pubsub-subscription"
+	setupPubSubSubscription(t, testTopic, testSubscription)
+
+	cfg := &stream.Config{
+		Listener:  testPostgresListenerCfg(),
+		Processor: testPubSubProcessorCfg(map[string]string{testTable: testTopic}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runStream(t, ctx, cfg)
+
+	execQuery(t, ctx, fmt.Sprintf("create table %s(id serial primary key, name text)", testTable))
+
+	tests := []struct {
+		name  string
+		query string
+
+		wantAction      string
+		wantOrderingKey string
+	}{
+		{
+			name:            "insert event",
+			query:           fmt.Sprintf("insert into %s(name) values('a')", testTable),
+			wantAction:      "I",
+			wantOrderingKey: "1",
+		},
+		{
+			name:            "update event",
+			query:           fmt.Sprintf("update %s set name = 'b' where id = 1", testTable),
+			wantAction:      "U",
+			wantOrderingKey: "1",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			execQuery(t, ctx, tc.query)
+
+			timer := time.NewTimer(20 * time.Second)
+			defer timer.Stop()
+			for {
+				select {
+				case <-timer.C:
+					cancel()
+					t.Error("timeout waiting for pubsub message")
+					return
+				default:
+					msgs := pullPubSubMessages(t, testSubscription)
+					if len(msgs) == 0 {
+						time.Sleep(100 * time.Millisecond)
+						continue
+					}
+					require.Len(t, msgs, 1)
+					msg := msgs[0].Message
+					require.Equal(t, tc.wantAction, msg.Attributes["action"])
+					require.Equal(t, "public", msg.Attributes["schema"])
+					require.Equal(t, testTable, msg.Attributes["table"])
+					require.NotEmpty(t, msg.Attributes["lsn"])
+					require.Equal(t, tc.wantOrderingKey, msg.OrderingKey)
+
+					data := &wal.Data{}
+					require.NoError(t, json.Unmarshal(msg.Data, data))
+					require.Equal(t, tc.wantAction, data.Action)
+					require.Equal(t, testTable, data.Table)
+					return
+				}
+			}
+		})
+	}
+}
+
+func setupPubSubSubscription(t *testing.T, topic, subscription string) {
+	topicPath := fmt.Sprintf("projects/%s/topics/%s", pubsubProjectID, topic)
+	pubsubRequest(t, http.MethodPut, topicPath, map[string]any{}, nil)
+	pubsubRequest(t, http.MethodPut, fmt.Sprintf("projects/%s/subscriptions/%s", pubsubProjectID, subscription), map[string]any{
+		"topic":                 topicPath,
+		"enableMessageOrdering": true,
+	}, nil)
+}
+
+func pullPubSubMessages(t *testing.T, subscription string) []pubsubReceivedMessage {
+	subscriptionPath := fmt.Sprintf("projects/%s/subscriptions/%s", pubsubProjectID, subscription)
+	resp := struct {
+		ReceivedMessages []pubsubReceivedMessage `json:"receivedMessages"`
+	}{}
+	pubsubRequest(t, http.MethodPost, subscriptionPath+":pull", map[string]any{
+		"maxMessages":       10,
+		"returnImmediately": true,
+	}, &resp)
+
+	if len(resp.ReceivedMessages) > 0 {
+		ackIDs := make([]string, 0, len(resp.ReceivedMessages))
+		for _, msg := range resp.ReceivedMessages {
+			ackIDs = append(ackIDs, msg.AckID)
+		}
+		pubsubRequest(t, http.MethodPost, subscriptionPath+":acknowledge", map[string]any{"ackIds": ackIDs}, nil)
+	}
+	return resp.ReceivedMessages
+}
+
+func pubsubRequest(t *testing.T, method, path string, body, resp any) {
+	bodyBytes, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s/v1/%s", pubsubHost, path), bytes.NewReader(bodyBytes))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer httpResp.Body.Close()
+	require.Equal(t, http.StatusOK, httpResp.StatusCode)
+
+	if resp != nil {
+		require.NoError(t, json.NewDecoder(httpResp.Body).Decode(resp))
+	}
+}
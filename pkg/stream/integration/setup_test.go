@@ -44,6 +44,12 @@ func TestMain(m *testing.M) {
 		}
 		defer escleanup()
 
+		pubsubcleanup, err := testcontainers.SetupPubSubEmulatorContainer(ctx, &pubsubHost, pubsubProjectID)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer pubsubcleanup()
+
 		targetPGCleanup, err := testcontainers.SetupPostgresContainer(ctx, &targetPGURL, testcontainers.Postgres17)
 		if err != nil {
 			log.Fatal(err)
@@ -10,18 +10,42 @@ import (
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/schemalog"
+	schemalogpg "github.com/xataio/pgstream/pkg/schemalog/postgres"
 	"github.com/xataio/pgstream/pkg/transformers/builder"
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
 	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/amqp"
+	"github.com/xataio/pgstream/pkg/wal/processor/auditlog"
+	"github.com/xataio/pgstream/pkg/wal/processor/bigquery"
+	"github.com/xataio/pgstream/pkg/wal/processor/clickhouse"
+	"github.com/xataio/pgstream/pkg/wal/processor/coalesce"
+	"github.com/xataio/pgstream/pkg/wal/processor/converter"
+	"github.com/xataio/pgstream/pkg/wal/processor/dynamodb"
+	"github.com/xataio/pgstream/pkg/wal/processor/filelog"
 	"github.com/xataio/pgstream/pkg/wal/processor/filter"
 	"github.com/xataio/pgstream/pkg/wal/processor/injector"
 	processinstrumentation "github.com/xataio/pgstream/pkg/wal/processor/instrumentation"
 	kafkaprocessor "github.com/xataio/pgstream/pkg/wal/processor/kafka"
+	"github.com/xataio/pgstream/pkg/wal/processor/kafka/partition"
+	"github.com/xataio/pgstream/pkg/wal/processor/objectstore"
 	pgwriter "github.com/xataio/pgstream/pkg/wal/processor/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/postgres/fkorder"
+	"github.com/xataio/pgstream/pkg/wal/processor/pubsub"
+	"github.com/xataio/pgstream/pkg/wal/processor/redshift"
+	"github.com/xataio/pgstream/pkg/wal/processor/router"
+	"github.com/xataio/pgstream/pkg/wal/processor/scd"
 	"github.com/xataio/pgstream/pkg/wal/processor/search"
 	searchinstrumentation "github.com/xataio/pgstream/pkg/wal/processor/search/instrumentation"
 	"github.com/xataio/pgstream/pkg/wal/processor/search/store"
+	"github.com/xataio/pgstream/pkg/wal/processor/sequencer"
+	"github.com/xataio/pgstream/pkg/wal/processor/snowflake"
+	"github.com/xataio/pgstream/pkg/wal/processor/toast"
+	"github.com/xataio/pgstream/pkg/wal/processor/transaction"
+	"github.com/xataio/pgstream/pkg/wal/processor/transform"
 	"github.com/xataio/pgstream/pkg/wal/processor/transformer"
+	"github.com/xataio/pgstream/pkg/wal/processor/typeevolution"
+	"github.com/xataio/pgstream/pkg/wal/processor/validate"
+	"github.com/xataio/pgstream/pkg/wal/processor/version"
 	webhooknotifier "github.com/xataio/pgstream/pkg/wal/processor/webhook/notifier"
 	subscriptionserver "github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/server"
 	webhookstore "github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/store"
@@ -39,7 +63,7 @@ const (
 	processorTypeSnapshot
 )
 
-func buildProcessor(ctx context.Context, logger loglib.Logger, config *ProcessorConfig, checkpoint checkpointer.Checkpoint, processorType processorType, instrumentation *otel.Instrumentation) (processor.Processor, error) {
+func buildProcessor(ctx context.Context, logger loglib.Logger, config *ProcessorConfig, checkpoint checkpointer.Checkpoint, txCheckpoint pgwriter.TxCheckpoint, processorType processorType, instrumentation *otel.Instrumentation) (processor.Processor, error) {
 	var processor processor.Processor
 	switch {
 	case config.Kafka != nil:
@@ -56,6 +80,13 @@ func buildProcessor(ctx context.Context, logger loglib.Logger, config *Processor
 			return nil, err
 		}
 		processor = kafkaWriter
+		if config.Kafka.Partitioning != nil {
+			logger.Info("adding partition key assignment layer to kafka processor...")
+			processor, err = partition.New(config.Kafka.Partitioning, processor, partition.WithLogger(logger))
+			if err != nil {
+				return nil, fmt.Errorf("error creating kafka partition key assignment layer: %w", err)
+			}
+		}
 	case config.Search != nil:
 		logger.Info("search processor configured")
 		var searchStore search.Store
@@ -78,6 +109,7 @@ func buildProcessor(ctx context.Context, logger loglib.Logger, config *Processor
 			pgreplication.NewLSNParser(),
 			search.WithCheckpoint(checkpoint),
 			search.WithLogger(logger),
+			search.WithInstrumentation(instrumentation),
 		)
 		if err != nil {
 			return nil, err
@@ -149,6 +181,19 @@ func buildProcessor(ctx context.Context, logger loglib.Logger, config *Processor
 			opts = append(opts, pgwriter.WithInstrumentation(instrumentation))
 		}
 
+		if config.Postgres.SCDType2 != nil {
+			logger.Info("postgres scd type 2 sink enabled")
+			scdSink, err := scd.NewSCDType2Sink(ctx, config.Postgres.SCDType2,
+				scd.WithCheckpoint(checkpoint),
+				scd.WithLogger(logger),
+				scd.WithInstrumentation(instrumentation),
+			)
+			if err != nil {
+				return nil, fmt.Errorf("target postgres scd type 2: %w", err)
+			}
+			return scdSink, nil
+		}
+
 		if processorType == processorTypeSnapshot && config.Postgres.BatchWriter.BulkIngestEnabled {
 			logger.Info("postgres bulk ingest writer enabled")
 			bulkIngestWriter, err := pgwriter.NewBulkIngestWriter(ctx, &config.Postgres.BatchWriter, opts...)
@@ -158,13 +203,166 @@ func buildProcessor(ctx context.Context, logger loglib.Logger, config *Processor
 			processor = bulkIngestWriter
 		} else {
 			opts := append(opts, pgwriter.WithCheckpoint(checkpoint))
+			if txCheckpoint != nil {
+				logger.Info("postgres transactional checkpoints enabled")
+				opts = append(opts, pgwriter.WithTxCheckpoint(txCheckpoint))
+			}
+
+			// the snapshot events don't belong to a transaction, so there's
+			// nothing to sort
+			var fkGraph *fkorder.ForeignKeyGraph
+			if processorType == processorTypeReplication && config.Postgres.FKOrdering != nil {
+				logger.Info("postgres foreign key ordering enabled")
+				fkURL := config.Postgres.FKOrdering.URL
+				if fkURL == "" {
+					fkURL = config.Postgres.BatchWriter.URL
+				}
+				var err error
+				fkGraph, err = fkorder.NewForeignKeyGraph(ctx, fkURL)
+				if err != nil {
+					return nil, fmt.Errorf("target postgres foreign key graph: %w", err)
+				}
+				opts = append(opts, pgwriter.WithConstraintDeferrer(fkGraph))
+			}
+
 			pgBatchWriter, err := pgwriter.NewBatchWriter(ctx, &config.Postgres.BatchWriter, opts...)
 			if err != nil {
+				if fkGraph != nil {
+					fkGraph.Close()
+				}
 				return nil, fmt.Errorf("target postgres: %w", err)
 			}
 
 			processor = pgBatchWriter
+			if fkGraph != nil {
+				processor = fkorder.New(config.Postgres.FKOrdering, fkGraph, pgBatchWriter, fkorder.WithLogger(logger))
+			}
+		}
+
+	case config.FileLog != nil:
+		logger.Info("file log processor configured")
+		fileLogSink, err := filelog.NewFileLogSink(ctx, config.FileLog,
+			filelog.WithCheckpoint(checkpoint),
+			filelog.WithLogger(logger),
+			filelog.WithInstrumentation(instrumentation),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("target file log: %w", err)
+		}
+		processor = fileLogSink
+
+	case config.PubSub != nil:
+		logger.Info("pubsub processor configured")
+		pubsubSink, err := pubsub.NewPubSubSink(ctx, config.PubSub,
+			pubsub.WithCheckpoint(checkpoint),
+			pubsub.WithLogger(logger),
+			pubsub.WithInstrumentation(instrumentation),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("target pubsub: %w", err)
+		}
+		processor = pubsubSink
+
+	case config.AMQP != nil:
+		logger.Info("amqp processor configured")
+		amqpSink, err := amqp.NewAMQPSink(ctx, config.AMQP,
+			amqp.WithCheckpoint(checkpoint),
+			amqp.WithLogger(logger),
+			amqp.WithInstrumentation(instrumentation),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("target amqp: %w", err)
+		}
+		processor = amqpSink
+
+	case config.Snowflake != nil:
+		logger.Info("snowflake processor configured")
+		snowflakeSink, err := snowflake.NewSnowflakeSink(ctx, config.Snowflake,
+			snowflake.WithCheckpoint(checkpoint),
+			snowflake.WithLogger(logger),
+			snowflake.WithInstrumentation(instrumentation),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("target snowflake: %w", err)
+		}
+		processor = snowflakeSink
+
+	case config.DynamoDB != nil:
+		logger.Info("dynamodb processor configured")
+		dynamoDBSink, err := dynamodb.NewDynamoDBSink(ctx, config.DynamoDB,
+			dynamodb.WithCheckpoint(checkpoint),
+			dynamodb.WithLogger(logger),
+			dynamodb.WithInstrumentation(instrumentation),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("target dynamodb: %w", err)
+		}
+		processor = dynamoDBSink
+
+	case config.AuditLog != nil:
+		logger.Info("audit log processor configured")
+		auditLogSink, err := auditlog.NewAuditLogSink(ctx, config.AuditLog,
+			auditlog.WithCheckpoint(checkpoint),
+			auditlog.WithLogger(logger),
+			auditlog.WithInstrumentation(instrumentation),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("target audit log: %w", err)
+		}
+		processor = auditLogSink
+
+	case config.ObjectStore != nil:
+		logger.Info("object store processor configured")
+		objectStoreSink, err := objectstore.NewObjectStoreSink(ctx, config.ObjectStore,
+			objectstore.WithCheckpoint(checkpoint),
+			objectstore.WithLogger(logger),
+			objectstore.WithInstrumentation(instrumentation),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("target object store: %w", err)
+		}
+		processor = objectStoreSink
+
+	case config.ClickHouse != nil:
+		logger.Info("clickhouse processor configured")
+		clickHouseSink, err := clickhouse.NewClickHouseSink(ctx, config.ClickHouse,
+			clickhouse.WithCheckpoint(checkpoint),
+			clickhouse.WithLogger(logger),
+			clickhouse.WithInstrumentation(instrumentation),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("target clickhouse: %w", err)
+		}
+		processor = clickHouseSink
+
+	case config.BigQuery != nil:
+		logger.Info("bigquery processor configured")
+		bigQuerySink, err := bigquery.NewBigQuerySink(ctx, config.BigQuery,
+			bigquery.WithCheckpoint(checkpoint),
+			bigquery.WithLogger(logger),
+			bigquery.WithInstrumentation(instrumentation),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("target bigquery: %w", err)
+		}
+		processor = bigQuerySink
+
+	case config.Router != nil:
+		logger.Info("router processor configured")
+		opts := []router.FanOutOption{router.WithFanOutLogger(logger)}
+		if instrumentation.IsEnabled() {
+			opts = append(opts, router.WithFanOutInstrumentation(instrumentation))
+		}
+		routes := make([]router.Route, 0, len(config.Router.Routes))
+		for _, route := range config.Router.Routes {
+			routes = append(routes, route.Route)
+		}
+		fanOutRouter, err := router.NewFanOutRouter(&router.FanOutConfig{Routes: routes}, checkpoint,
+			newRouteProcessorFn(ctx, logger, config.Router, processorType, instrumentation), opts...)
+		if err != nil {
+			return nil, fmt.Errorf("target router: %w", err)
 		}
+		processor = fanOutRouter
 
 	default:
 		return nil, errors.New("no supported processor found")
@@ -173,12 +371,82 @@ func buildProcessor(ctx context.Context, logger loglib.Logger, config *Processor
 	return processor, nil
 }
 
-func addProcessorModifiers(ctx context.Context, config *Config, logger loglib.Logger, processor processor.Processor, instrumentation *otel.Instrumentation) (processor.Processor, closerFn, error) {
+// newRouteProcessorFn returns the constructor of the router route target
+// processors, which are given the route checkpoint.
+func newRouteProcessorFn(ctx context.Context, logger loglib.Logger, config *RouterProcessorConfig, processorType processorType, instrumentation *otel.Instrumentation) router.NewRouteProcessorFn {
+	return func(i int, checkpoint checkpointer.Checkpoint) (processor.Processor, error) {
+		return buildProcessor(ctx, logger, &config.Routes[i].Target, checkpoint, nil, processorType, instrumentation)
+	}
+}
+
+func addProcessorModifiers(ctx context.Context, config *Config, logger loglib.Logger, processor processor.Processor, instrumentation *otel.Instrumentation, commitTracker *transaction.CommitTracker) (processor.Processor, closerFn, error) {
 	closerAgg := &closerAggregator{}
+	// the pipeline version is stamped by the innermost layer, so that it's set
+	// on every event sent to the target
+	if processor != nil {
+		pipelineVersion, err := config.PipelineVersion()
+		if err != nil {
+			return nil, nil, err
+		}
+		processor = version.New(pipelineVersion, processor)
+	}
+
 	var err error
+	// the sort key ordering is the innermost layer, so that the events are
+	// sorted by the values that will be sent to the target
+	if config.Processor.SortKey != nil {
+		logger.Info("adding sort key layer to processor...")
+		processor, err = redshift.New(config.Processor.SortKey, processor, redshift.WithLogger(logger))
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating processor sort key layer: %w", err)
+		}
+	}
+
+	// the update coalescing wraps the sort key layer, so that the merged
+	// updates are the only ones sent to the target
+	if config.Processor.BulkUpdateCoalescer != nil {
+		logger.Info("adding bulk update coalescer layer to processor...")
+		processor = coalesce.New(config.Processor.BulkUpdateCoalescer, processor, coalesce.WithLogger(logger))
+	}
+
+	// the schema validation wraps the sort key and update coalescing layers,
+	// so that the events are validated in the same format they will be sent
+	// to the target
+	if config.Processor.Validator != nil {
+		logger.Info("adding schema validation layer to processor...")
+		processor, err = validate.New(config.Processor.Validator, processor, validate.WithLogger(logger))
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating processor schema validation layer: %w", err)
+		}
+	}
+
+	if config.Processor.Converter != nil || config.Processor.TypeRegistry != nil {
+		logger.Info("adding type conversion layer to processor...")
+		converterCfg := config.Processor.Converter
+		if converterCfg == nil {
+			converterCfg = &converter.Config{}
+		}
+		opts := []converter.Option{converter.WithLogger(logger)}
+		if config.Processor.TypeRegistry != nil {
+			opts = append(opts, converter.WithTypeRegistry(config.Processor.TypeRegistry))
+		}
+		processor, err = converter.New(converterCfg, processor, opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating processor type conversion layer: %w", err)
+		}
+	}
+
+	// the before/after normalizer runs after the transformers, so that the
+	// cached rows, and therefore the before images, have the same values that
+	// were sent to the target
+	if config.Processor.BeforeAfter != nil {
+		logger.Info("adding before/after normalizer to processor...")
+		processor = transform.New(config.Processor.BeforeAfter, processor, transform.WithLogger(logger))
+	}
+
 	if config.Processor.Transformer != nil {
 		logger.Info("adding transformation layer to processor...")
-		builderOpts := []builder.Option{}
+		builderOpts := []builder.Option{builder.WithLogger(logger)}
 		if instrumentation.IsEnabled() {
 			builderOpts = append(builderOpts, builder.WithInstrumentation(instrumentation))
 		}
@@ -219,6 +487,27 @@ func addProcessorModifiers(ctx context.Context, config *Config, logger loglib.Lo
 		}
 	}
 
+	// the TOAST cache needs to run before the transformers, so that they
+	// receive the complete row values, and after the injector, to be able to
+	// use the pgstream identity columns
+	if config.Processor.TOASTCache != nil {
+		logger.Info("adding TOAST cache to processor...")
+		processor = toast.New(config.Processor.TOASTCache, processor, toast.WithLogger(logger))
+	}
+
+	// the column type evolution runs before the TOAST cache and the
+	// transformers, so that they receive the values with their historical
+	// types, and after the injector, to use the schema version it stamps
+	if config.Processor.TypeEvolution != nil {
+		logger.Info("adding column type evolution layer to processor...")
+		store, err := schemalogpg.NewStore(ctx, config.Processor.TypeEvolution.Store)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating processor column type evolution layer: %w", err)
+		}
+		closerAgg.addCloserFn(store.Close)
+		processor = typeevolution.New(store, processor, typeevolution.WithLogger(logger))
+	}
+
 	if config.Processor.Injector != nil {
 		logger.Info("adding injection to processor...")
 		opts := []injector.Option{
@@ -235,18 +524,41 @@ func addProcessorModifiers(ctx context.Context, config *Config, logger loglib.Lo
 
 	if config.Processor.Filter != nil {
 		logger.Info("adding filtering to processor...")
-		var err error
-		processor, err = filter.New(processor, config.Processor.Filter,
+		opts := []filter.Option{
 			filter.WithLogger(logger),
 			// by default we include the pgstream schema log table, since we won't
 			// be able to replicate DDL changes otherwise. This behaviour can be
 			// disabled by adding it to the exclude tables.
-			filter.WithDefaultIncludeTables([]string{schemalog.SchemaName + "." + schemalog.TableName}))
+			filter.WithDefaultIncludeTables([]string{schemalog.SchemaName + "." + schemalog.TableName}),
+		}
+		if instrumentation.IsEnabled() {
+			opts = append(opts, filter.WithInstrumentation(instrumentation))
+		}
+		var err error
+		processor, err = filter.New(processor, config.Processor.Filter, opts...)
 		if err != nil {
 			return nil, nil, err
 		}
 	}
 
+	// the sequencer is the outermost layer, since it needs to receive the
+	// commit events, which are not table operations
+	if config.Processor.XIDSequencer != nil {
+		logger.Info("adding transaction sequencer to processor...")
+		processor = sequencer.New(config.Processor.XIDSequencer, processor, sequencer.WithLogger(logger))
+	}
+
+	// the transaction hooks wrap the sequencer, so that they're called once
+	// the buffered events of the transaction have been sent
+	if config.Processor.TransactionHooks != nil {
+		logger.Info("adding transaction hooks to processor...")
+		opts := []transaction.Option{transaction.WithLogger(logger)}
+		if commitTracker != nil {
+			opts = append(opts, transaction.WithCommitTracker(commitTracker))
+		}
+		processor = transaction.New(config.Processor.TransactionHooks, processor, opts...)
+	}
+
 	if processor != nil && instrumentation.IsEnabled() {
 		var err error
 		processor, err = processinstrumentation.NewProcessor(processor, instrumentation)
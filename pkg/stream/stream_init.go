@@ -10,6 +10,7 @@ import (
 
 	pglib "github.com/xataio/pgstream/internal/postgres"
 	pgmigrations "github.com/xataio/pgstream/migrations/postgres"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
@@ -25,13 +26,46 @@ const (
 
 var errMissingPostgresURL = errors.New("postgres URL is required")
 
+type initConfig struct {
+	slot         pgreplication.SlotConfig
+	outputPlugin string
+}
+
+type InitOption func(*initConfig)
+
+// WithSlotConfig sets the options used to create the replication slot.
+// Temporary slots are not created on init, since they're bound to the
+// replication connection, they will be created when the replication starts.
+func WithSlotConfig(cfg pgreplication.SlotConfig) InitOption {
+	return func(c *initConfig) {
+		c.slot = cfg
+	}
+}
+
+// WithOutputPlugin sets the output plugin the replication slot is created
+// with. Defaults to wal2json.
+func WithOutputPlugin(name string) InitOption {
+	return func(c *initConfig) {
+		if name != "" {
+			c.outputPlugin = name
+		}
+	}
+}
+
 // Init initialises the pgstream state in the postgres database provided, along
 // with creating the relevant replication slot if it doesn't already exist.
-func Init(ctx context.Context, pgURL, replicationSlotName string) error {
+func Init(ctx context.Context, pgURL, replicationSlotName string, opts ...InitOption) error {
 	if pgURL == "" {
 		return errMissingPostgresURL
 	}
 
+	cfg := &initConfig{
+		outputPlugin: pgreplication.PluginWal2JSON,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	conn, err := newPGConn(ctx, pgURL)
 	if err != nil {
 		return err
@@ -64,6 +98,10 @@ func Init(ctx context.Context, pgURL, replicationSlotName string) error {
 		return err
 	}
 
+	if cfg.slot.Temporary {
+		return nil
+	}
+
 	// check if the replication slot already exists
 	exists, err := replicationSlotExists(ctx, conn, replicationSlotName)
 	if err != nil {
@@ -74,7 +112,7 @@ func Init(ctx context.Context, pgURL, replicationSlotName string) error {
 		return nil
 	}
 
-	if err := createReplicationSlot(ctx, conn, replicationSlotName); err != nil {
+	if err := createReplicationSlot(ctx, conn, replicationSlotName, cfg.outputPlugin, cfg.slot); err != nil {
 		return fmt.Errorf("failed to create replication slot: %w", err)
 	}
 
@@ -142,8 +180,18 @@ func dropPGStreamSchema(ctx context.Context, conn *pgx.Conn) error {
 	return nil
 }
 
-func createReplicationSlot(ctx context.Context, conn *pgx.Conn, slotName string) error {
-	_, err := conn.Exec(ctx, fmt.Sprintf(`SELECT 'init' FROM pg_create_logical_replication_slot ('%s', 'wal2json')`, slotName))
+func createReplicationSlot(ctx context.Context, conn *pgx.Conn, slotName, plugin string, cfg pgreplication.SlotConfig) error {
+	// the two phase (postgres 14+) and failover (postgres 17+) arguments are
+	// only provided when enabled, to keep compatibility with older versions
+	var err error
+	switch {
+	case cfg.Failover:
+		_, err = conn.Exec(ctx, `SELECT 'init' FROM pg_create_logical_replication_slot ($1, $2, false, $3, true)`, slotName, plugin, cfg.TwoPhase)
+	case cfg.TwoPhase:
+		_, err = conn.Exec(ctx, `SELECT 'init' FROM pg_create_logical_replication_slot ($1, $2, false, true)`, slotName, plugin)
+	default:
+		_, err = conn.Exec(ctx, `SELECT 'init' FROM pg_create_logical_replication_slot ($1, $2)`, slotName, plugin)
+	}
 	if err != nil && !isDuplicateObject(err) {
 		return err
 	}
@@ -169,6 +217,9 @@ func newPGConn(ctx context.Context, pgURL string) (*pgx.Conn, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed parsing postgres connection string: %w", err)
 	}
+	if err := pglib.ConfigureAuth(ctx, &pgCfg.Config); err != nil {
+		return nil, err
+	}
 	pgConn, err := pgx.ConnectConfig(ctx, pgCfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create postgres connection: %w", err)
@@ -177,6 +228,13 @@ func newPGConn(ctx context.Context, pgURL string) (*pgx.Conn, error) {
 }
 
 func newPGMigrator(pgURL string) (*migrate.Migrate, error) {
+	// the migrations use their own postgres driver, so the credentials need
+	// to be resolved in the connection string
+	pgURL, err := pglib.ResolveAuth(context.Background(), pgURL)
+	if err != nil {
+		return nil, err
+	}
+
 	src := bindata.Resource(pgmigrations.AssetNames(), pgmigrations.Asset)
 	d, err := bindata.WithInstance(src)
 	if err != nil {
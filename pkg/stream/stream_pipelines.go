@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/backoff"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// PipelineConfig is the configuration of one of the independent streams that
+// can be run within the same pgstream process.
+type PipelineConfig struct {
+	// Name identifies the pipeline. It's used to tag the logs and metrics
+	// produced by the pipeline.
+	Name   string
+	Stream Config
+	// RestartPolicy is the backoff policy applied to restart the pipeline when
+	// it fails. Defaults to an exponential backoff with no time limit.
+	RestartPolicy backoff.Config
+	// RestartResetInterval is how long the pipeline needs to be running before
+	// a failure is no longer counted against the restart policy of previous
+	// failures. The next failure starts a new restart policy from scratch.
+	// Defaults to 1 minute.
+	RestartResetInterval time.Duration
+}
+
+type runFn func(ctx context.Context, logger loglib.Logger, config *Config, init bool, instrumentation *otel.Instrumentation, opts ...RunOption) error
+
+const (
+	pipelineField                       = "pipeline"
+	defaultPipelineRestartResetInterval = time.Minute
+)
+
+var (
+	errNoPipelines          = errors.New("need at least one pipeline configured")
+	errPipelineNameRequired = errors.New("pipeline name is required")
+)
+
+// RunPipelines will run the configured pipelines concurrently. Each pipeline
+// has its own listener, processors and checkpointer. A pipeline that returns
+// an error, or panics in the goroutine running it, is restarted according to
+// its restart policy without affecting the others. Panics in the goroutines
+// started by the pipeline can't be recovered and will still stop the process,
+// taking down all the pipelines. This call is blocking until all the pipelines
+// have stopped. When the context is cancelled, all the pipelines are drained before
+// returning. The run options on input are applied to all the pipelines.
+func RunPipelines(ctx context.Context, logger loglib.Logger, pipelines []PipelineConfig, init bool, provider otel.InstrumentationProvider, opts ...RunOption) error {
+	return runPipelines(ctx, logger, pipelines, init, provider, Run, opts...)
+}
+
+func runPipelines(ctx context.Context, logger loglib.Logger, pipelines []PipelineConfig, init bool, provider otel.InstrumentationProvider, run runFn, opts ...RunOption) error {
+	if err := validatePipelines(pipelines); err != nil {
+		return fmt.Errorf("incompatible pipelines configuration: %w", err)
+	}
+
+	errs := make([]error, len(pipelines))
+	wg := &sync.WaitGroup{}
+	for i := range pipelines {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = runPipeline(ctx, logger, &pipelines[i], init, provider, run, opts...)
+		}(i)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// runPipeline runs the pipeline on input until the context is cancelled,
+// restarting it when it fails until the restart policy is exhausted.
+func runPipeline(ctx context.Context, logger loglib.Logger, pipeline *PipelineConfig, init bool, provider otel.InstrumentationProvider, run runFn, opts ...RunOption) error {
+	logger = loglib.NewLogger(logger).WithFields(loglib.Fields{
+		loglib.StreamIDField: pipeline.Name,
+	})
+	instrumentation := provider.NewInstrumentation("run", attribute.String(pipelineField, pipeline.Name))
+	opts = append(slices.Clone(opts), withPipelineName(pipeline.Name))
+
+	restartPolicy := pipeline.RestartPolicy
+	switch {
+	case restartPolicy.DisableRetries:
+		restartPolicy = backoff.Config{}
+	case !restartPolicy.IsSet():
+		restartPolicy = defaultPipelineRestartPolicy()
+	}
+	resetInterval := pipeline.RestartResetInterval
+	if resetInterval == 0 {
+		resetInterval = defaultPipelineRestartResetInterval
+	}
+	backoffProvider := backoff.NewProvider(&restartPolicy)
+
+	logger.Info("starting pipeline...")
+	var err error
+	for {
+		// each failure streak gets a new backoff, so that the restart policy
+		// is only exhausted by consecutive failures
+		var stableRunErr error
+		err = backoffProvider(ctx).RetryNotify(
+			func() error {
+				start := time.Now()
+				err := safeRun(ctx, logger, pipeline, init, instrumentation, run, opts...)
+				if err == nil || ctx.Err() != nil {
+					return nil
+				}
+				if time.Since(start) >= resetInterval {
+					stableRunErr = err
+					return fmt.Errorf("%w: %w", err, backoff.ErrPermanent)
+				}
+				return err
+			},
+			func(err error, d time.Duration) {
+				logger.Error(err, "pipeline failed, restarting", loglib.Fields{
+					"backoff": d,
+				})
+			})
+		if stableRunErr == nil {
+			break
+		}
+		if !restartPolicy.IsSet() {
+			err = stableRunErr
+			break
+		}
+		logger.Error(stableRunErr, "pipeline failed after running for longer than the restart reset interval, restarting", loglib.Fields{
+			"reset_interval": resetInterval,
+		})
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Error(err, "pipeline stopped")
+		return fmt.Errorf("pipeline %s: %w", pipeline.Name, err)
+	}
+
+	logger.Info("pipeline stopped")
+	return nil
+}
+
+// safeRun runs the pipeline, recovering from the panics in the calling
+// goroutine so that they don't take down the other pipelines running in the
+// process. Panics in the goroutines started by the pipeline are not recovered.
+func safeRun(ctx context.Context, logger loglib.Logger, pipeline *PipelineConfig, init bool, instrumentation *otel.Instrumentation, run runFn, opts ...RunOption) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("pipeline panicked: %v", r)
+		}
+	}()
+	return run(ctx, logger, &pipeline.Stream, init, instrumentation, opts...)
+}
+
+// validatePipelines checks the pipelines are valid and don't compete for the
+// same replication slot.
+func validatePipelines(pipelines []PipelineConfig) error {
+	if len(pipelines) == 0 {
+		return errNoPipelines
+	}
+
+	names := make(map[string]struct{}, len(pipelines))
+	slots := make(map[string]string, len(pipelines))
+	for i := range pipelines {
+		p := &pipelines[i]
+		if p.Name == "" {
+			return errPipelineNameRequired
+		}
+		if _, found := names[p.Name]; found {
+			return fmt.Errorf("duplicate pipeline name %s", p.Name)
+		}
+		names[p.Name] = struct{}{}
+
+		if err := p.Stream.IsValid(); err != nil {
+			return fmt.Errorf("pipeline %s: %w", p.Name, err)
+		}
+
+		pgURL := p.Stream.SourcePostgresURL()
+		if pgURL == "" {
+			continue
+		}
+		slotName := p.Stream.PostgresReplicationSlot()
+		if slotName == "" {
+			var err error
+			slotName, err = getReplicationSlotName(pgURL)
+			if err != nil {
+				return fmt.Errorf("pipeline %s: %w", p.Name, err)
+			}
+		}
+		slotKey, err := replicationSlotKey(pgURL, slotName)
+		if err != nil {
+			return fmt.Errorf("pipeline %s: %w", p.Name, err)
+		}
+		if other, found := slots[slotKey]; found {
+			return fmt.Errorf("pipelines %s and %s use the same replication slot %s", other, p.Name, slotName)
+		}
+		slots[slotKey] = p.Name
+	}
+
+	return nil
+}
+
+// replicationSlotKey returns a key identifying the replication slot on input
+// within the postgres cluster of the URL. Replication slots are shared by all
+// the databases in the cluster, so only the host and port of the URL are
+// relevant, regardless of how the URL is written.
+func replicationSlotKey(pgURL, slotName string) (string, error) {
+	cfg, err := pglib.ParseConfig(pgURL)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d/%s", strings.ToLower(cfg.Host), cfg.Port, slotName), nil
+}
+
+func defaultPipelineRestartPolicy() backoff.Config {
+	return backoff.Config{
+		Exponential: &backoff.ExponentialConfig{
+			InitialInterval: time.Second,
+			// no max elapsed time, the pipeline will be restarted until the
+			// context is cancelled
+			MaxInterval: 0,
+		},
+	}
+}
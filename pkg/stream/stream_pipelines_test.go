@@ -0,0 +1,338 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/backoff"
+	"github.com/xataio/pgstream/pkg/health"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+)
+
+func TestRunPipelines(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	testPipeline := func(name, pgURL string) PipelineConfig {
+		return PipelineConfig{
+			Name: name,
+			Stream: Config{
+				Listener: ListenerConfig{
+					Postgres: &PostgresListenerConfig{URL: pgURL},
+				},
+				Processor: ProcessorConfig{
+					Kafka: &KafkaProcessorConfig{},
+				},
+			},
+			RestartPolicy: backoff.Config{
+				Constant: &backoff.ConstantConfig{
+					Interval:   time.Millisecond,
+					MaxRetries: 2,
+				},
+			},
+		}
+	}
+
+	provider, err := otel.NewInstrumentationProvider(&otel.Config{})
+	require.NoError(t, err)
+
+	t.Run("ok - failing pipeline restarted without affecting the others", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		mutex := &sync.Mutex{}
+		calls := map[string]int{}
+		started := make(chan struct{})
+		run := func(ctx context.Context, logger loglib.Logger, config *Config, init bool, instrumentation *otel.Instrumentation, opts ...RunOption) error {
+			mutex.Lock()
+			calls[config.SourcePostgresURL()]++
+			attempt := calls[config.SourcePostgresURL()]
+			mutex.Unlock()
+
+			// the first pipeline fails on the first attempt
+			if config.SourcePostgresURL() == "postgres://localhost:5432/db1" && attempt == 1 {
+				return errTest
+			}
+			if config.SourcePostgresURL() == "postgres://localhost:5432/db1" {
+				close(started)
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		doneChan := make(chan error)
+		go func() {
+			doneChan <- runPipelines(ctx, loglib.NewNoopLogger(), []PipelineConfig{
+				testPipeline("db1", "postgres://localhost:5432/db1"),
+				testPipeline("db2", "postgres://localhost:5432/db2"),
+			}, false, provider, run)
+		}()
+
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for pipeline restart")
+		}
+		cancel()
+
+		select {
+		case err := <-doneChan:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for pipelines to stop")
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		require.Equal(t, map[string]int{
+			"postgres://localhost:5432/db1": 2,
+			"postgres://localhost:5432/db2": 1,
+		}, calls)
+	})
+
+	t.Run("ok - run options applied with the pipeline name", func(t *testing.T) {
+		t.Parallel()
+
+		registry := health.NewRegistry(&health.Config{})
+		mutex := &sync.Mutex{}
+		runConfigs := map[string]*runConfig{}
+		run := func(ctx context.Context, logger loglib.Logger, config *Config, init bool, instrumentation *otel.Instrumentation, opts ...RunOption) error {
+			runCfg := &runConfig{}
+			for _, opt := range opts {
+				opt(runCfg)
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			runConfigs[config.SourcePostgresURL()] = runCfg
+			return nil
+		}
+
+		err := runPipelines(context.Background(), loglib.NewNoopLogger(), []PipelineConfig{
+			testPipeline("db1", "postgres://localhost:5432/db1"),
+			testPipeline("db2", "postgres://localhost:5432/db2"),
+		}, false, provider, run, WithHealthRegistry(registry))
+		require.NoError(t, err)
+		require.Equal(t, map[string]*runConfig{
+			"postgres://localhost:5432/db1": {healthRegistry: registry, pipelineName: "db1"},
+			"postgres://localhost:5432/db2": {healthRegistry: registry, pipelineName: "db2"},
+		}, runConfigs)
+	})
+
+	t.Run("error - restart policy exhausted", func(t *testing.T) {
+		t.Parallel()
+
+		mutex := &sync.Mutex{}
+		calls := 0
+		run := func(ctx context.Context, logger loglib.Logger, config *Config, init bool, instrumentation *otel.Instrumentation, opts ...RunOption) error {
+			if config.SourcePostgresURL() == "postgres://localhost:5432/db2" {
+				return nil
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			calls++
+			return errTest
+		}
+
+		err := runPipelines(context.Background(), loglib.NewNoopLogger(), []PipelineConfig{
+			testPipeline("db1", "postgres://localhost:5432/db1"),
+			testPipeline("db2", "postgres://localhost:5432/db2"),
+		}, false, provider, run)
+		require.ErrorIs(t, err, errTest)
+		require.ErrorContains(t, err, "pipeline db1")
+		require.Equal(t, 3, calls)
+	})
+
+	t.Run("ok - restart policy reset after running for longer than the reset interval", func(t *testing.T) {
+		t.Parallel()
+
+		pipeline := testPipeline("db1", "postgres://localhost:5432/db1")
+		pipeline.RestartResetInterval = time.Millisecond
+
+		calls := 0
+		run := func(ctx context.Context, logger loglib.Logger, config *Config, init bool, instrumentation *otel.Instrumentation, opts ...RunOption) error {
+			calls++
+			if calls == 5 {
+				return nil
+			}
+			time.Sleep(5 * time.Millisecond)
+			return errTest
+		}
+
+		// the restart policy allows 2 restarts, but each failure happens after
+		// the reset interval, so it's never exhausted
+		err := runPipelines(context.Background(), loglib.NewNoopLogger(), []PipelineConfig{pipeline}, false, provider, run)
+		require.NoError(t, err)
+		require.Equal(t, 5, calls)
+	})
+
+	t.Run("error - restarts disabled after running for longer than the reset interval", func(t *testing.T) {
+		t.Parallel()
+
+		pipeline := testPipeline("db1", "postgres://localhost:5432/db1")
+		pipeline.RestartPolicy = backoff.Config{DisableRetries: true}
+		pipeline.RestartResetInterval = time.Millisecond
+
+		calls := 0
+		run := func(ctx context.Context, logger loglib.Logger, config *Config, init bool, instrumentation *otel.Instrumentation, opts ...RunOption) error {
+			calls++
+			time.Sleep(5 * time.Millisecond)
+			return errTest
+		}
+
+		err := runPipelines(context.Background(), loglib.NewNoopLogger(), []PipelineConfig{pipeline}, false, provider, run)
+		require.ErrorIs(t, err, errTest)
+		require.NotErrorIs(t, err, backoff.ErrPermanent)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("error - panic recovered", func(t *testing.T) {
+		t.Parallel()
+
+		pipeline := testPipeline("db1", "postgres://localhost:5432/db1")
+		pipeline.RestartPolicy = backoff.Config{DisableRetries: true}
+
+		err := runPipelines(context.Background(), loglib.NewNoopLogger(), []PipelineConfig{pipeline}, false, provider,
+			func(ctx context.Context, logger loglib.Logger, config *Config, init bool, instrumentation *otel.Instrumentation, opts ...RunOption) error {
+				panic("oh noes")
+			})
+		require.ErrorContains(t, err, "pipeline panicked: oh noes")
+	})
+
+	t.Run("error - invalid pipelines", func(t *testing.T) {
+		t.Parallel()
+
+		err := runPipelines(context.Background(), loglib.NewNoopLogger(), nil, false, provider,
+			func(ctx context.Context, logger loglib.Logger, config *Config, init bool, instrumentation *otel.Instrumentation, opts ...RunOption) error {
+				return nil
+			})
+		require.ErrorIs(t, err, errNoPipelines)
+	})
+}
+
+func TestValidatePipelines(t *testing.T) {
+	t.Parallel()
+
+	testPipeline := func(name, pgURL, slotName string) PipelineConfig {
+		p := PipelineConfig{
+			Name: name,
+			Stream: Config{
+				Listener: ListenerConfig{
+					Postgres: &PostgresListenerConfig{URL: pgURL},
+				},
+				Processor: ProcessorConfig{
+					Kafka: &KafkaProcessorConfig{},
+				},
+			},
+		}
+		p.Stream.Listener.Postgres.Replication.ReplicationSlotName = slotName
+		return p
+	}
+
+	tests := []struct {
+		name      string
+		pipelines []PipelineConfig
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			pipelines: []PipelineConfig{
+				testPipeline("a", "postgres://localhost:5432/db1", ""),
+				testPipeline("b", "postgres://localhost:5432/db2", ""),
+				testPipeline("c", "postgres://localhost:5432/db1", "other_slot"),
+			},
+
+			wantErr: nil,
+		},
+		{
+			name:      "error - no pipelines",
+			pipelines: []PipelineConfig{},
+
+			wantErr: errNoPipelines,
+		},
+		{
+			name: "error - missing name",
+			pipelines: []PipelineConfig{
+				testPipeline("", "postgres://localhost:5432/db1", ""),
+			},
+
+			wantErr: errPipelineNameRequired,
+		},
+		{
+			name: "error - duplicate name",
+			pipelines: []PipelineConfig{
+				testPipeline("a", "postgres://localhost:5432/db1", ""),
+				testPipeline("a", "postgres://localhost:5432/db2", ""),
+			},
+
+			wantErr: errors.New("duplicate pipeline name a"),
+		},
+		{
+			name: "error - same default replication slot",
+			pipelines: []PipelineConfig{
+				testPipeline("a", "postgres://localhost:5432/db1", ""),
+				testPipeline("b", "postgres://localhost:5432/db1", ""),
+			},
+
+			wantErr: errors.New("pipelines a and b use the same replication slot pgstream_db1_slot"),
+		},
+		{
+			name: "error - same default replication slot with different urls",
+			pipelines: []PipelineConfig{
+				testPipeline("a", "postgres://localhost/db1", ""),
+				testPipeline("b", "postgres://LOCALHOST:5432/db1?sslmode=disable", ""),
+			},
+
+			wantErr: errors.New("pipelines a and b use the same replication slot pgstream_db1_slot"),
+		},
+		{
+			name: "error - same replication slot on different databases",
+			pipelines: []PipelineConfig{
+				testPipeline("a", "postgres://localhost:5432/db1", "shared_slot"),
+				testPipeline("b", "postgres://localhost:5432/db2", "shared_slot"),
+			},
+
+			wantErr: errors.New("pipelines a and b use the same replication slot shared_slot"),
+		},
+		{
+			name: "ok - same replication slot on different hosts",
+			pipelines: []PipelineConfig{
+				testPipeline("a", "postgres://host1:5432/db1", "shared_slot"),
+				testPipeline("b", "postgres://host2:5432/db1", "shared_slot"),
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "error - invalid stream config",
+			pipelines: []PipelineConfig{
+				{Name: "a"},
+			},
+
+			wantErr: errors.New("pipeline a: need at least one listener configured"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validatePipelines(tc.pipelines)
+			if tc.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, tc.wantErr.Error())
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"context"
+	"fmt"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal/processor/filelog"
+	"github.com/xataio/pgstream/pkg/wal/processor/objectstore"
+	"github.com/xataio/pgstream/pkg/wal/processor/rewind"
+)
+
+// Replay re-processes the events archived by a file log or object store sink
+// within the configured LSN range through the configured processor, without
+// a listener nor a replication slot. The replayed events are never
+// checkpointed. This call is blocking, and returns once all the events in the
+// range have been sent to the processor and flushed.
+func Replay(ctx context.Context, logger loglib.Logger, config *Config, instrumentation *otel.Instrumentation) error {
+	if err := config.Replay.IsValid(); err != nil {
+		return fmt.Errorf("incompatible configuration: %w", err)
+	}
+	if err := config.Processor.IsValid(); err != nil {
+		return fmt.Errorf("incompatible configuration: %w", err)
+	}
+
+	archive, closeArchive, err := newReplayArchive(ctx, config.Replay)
+	if err != nil {
+		return err
+	}
+	defer closeArchive()
+
+	// Processor
+
+	processor, err := buildProcessor(ctx, logger, &config.Processor, nil, nil, processorTypeReplication, instrumentation)
+	if err != nil {
+		return err
+	}
+	defer processor.Close()
+
+	var closer closerFn
+	processor, closer, err = addProcessorModifiers(ctx, config, logger, processor, instrumentation, nil)
+	if err != nil {
+		return err
+	}
+	defer closer()
+
+	rewindProcessor := rewind.New(processor, archive, rewind.WithLogger(logger))
+	return rewindProcessor.Rewind(ctx, config.Replay.StartLSN, config.Replay.EndLSN)
+}
+
+func newReplayArchive(ctx context.Context, config *ReplayConfig) (rewind.Archive, closerFn, error) {
+	if config.ObjectStore != nil {
+		reader, err := objectstore.NewArchiveReader(ctx, config.ObjectStore)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error setting up object store archive reader: %w", err)
+		}
+		return reader, reader.Close, nil
+	}
+
+	reader, err := filelog.NewArchiveReader(config.FileLogPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error setting up file log archive reader: %w", err)
+	}
+	return reader, func() error { return nil }, nil
+}
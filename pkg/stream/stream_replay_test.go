@@ -0,0 +1,63 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/json"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/filelog"
+)
+
+func TestReplay(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "archive.log")
+	archived := []string{}
+	for _, lsn := range []string{"0/10", "0/20", "0/30"} {
+		line, err := json.Marshal(&wal.Data{Action: "I", LSN: lsn, Schema: "public", Table: "test"})
+		require.NoError(t, err)
+		archived = append(archived, string(line))
+	}
+	require.NoError(t, os.WriteFile(archivePath, []byte(strings.Join(archived, "\n")+"\n"), 0o600))
+
+	targetPath := filepath.Join(dir, "target.log")
+	config := &Config{
+		Processor: ProcessorConfig{
+			FileLog: &filelog.Config{Path: targetPath},
+		},
+		Replay: &ReplayConfig{
+			FileLogPath: archivePath,
+			StartLSN:    "0/20",
+			EndLSN:      "0/30",
+		},
+	}
+
+	err := Replay(context.Background(), loglib.NewNoopLogger(), config, nil)
+	require.NoError(t, err)
+
+	replayed, err := os.ReadFile(targetPath)
+	require.NoError(t, err)
+	lsns := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(replayed)), "\n") {
+		data := &wal.Data{}
+		require.NoError(t, json.Unmarshal([]byte(line), data))
+		lsns = append(lsns, data.LSN)
+	}
+	require.Equal(t, []string{"0/20", "0/30"}, lsns)
+
+	t.Run("error - invalid config", func(t *testing.T) {
+		t.Parallel()
+
+		err := Replay(context.Background(), loglib.NewNoopLogger(), &Config{Processor: config.Processor}, nil)
+		require.ErrorIs(t, err, errMissingReplayArchive)
+	})
+}
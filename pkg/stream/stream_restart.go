@@ -0,0 +1,426 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// GracefulRestart runs the stream and replaces it with one running the
+// reloaded configuration when a restart is requested, without leaving a gap in
+// the replication. The new pipeline is set up while the current one keeps
+// running. Once it's ready, the current pipeline is drained and releases the
+// replication slot, and the new pipeline resumes the replication from the
+// position the current one acknowledged.
+type GracefulRestart struct {
+	logger   loglib.Logger
+	load     func() (*Config, error)
+	run      runFn
+	restarts chan struct{}
+	notify   func(chan<- os.Signal, ...os.Signal)
+	stop     func(chan<- os.Signal)
+}
+
+type RestartOption func(*GracefulRestart)
+
+// generation is one of the pipelines run by the graceful restart, each with
+// its own components and shutdown sequence.
+type generation struct {
+	config   *Config
+	shutdown *wal.GracefulShutdown
+	handoff  *handoff
+	done     chan struct{}
+	err      error
+}
+
+// slotReleaseInterval is how often the new pipeline retries to start the
+// replication while the slot is still held by the backend of the replaced one.
+const slotReleaseInterval = 100 * time.Millisecond
+
+var (
+	errRestartListener      = errors.New("graceful restart requires a postgres listener")
+	errRestartTemporarySlot = errors.New("graceful restart is not supported with temporary replication slots")
+	errRestartSourceChanged = errors.New("the source database and the replication slot can't be changed on restart")
+	errRestartAborted       = errors.New("pipeline with the new config stopped before taking over")
+	errRestartStopping      = errors.New("stream is stopping")
+)
+
+// NewGracefulRestart returns a graceful restart that reloads the configuration
+// with the function on input on every restart.
+func NewGracefulRestart(load func() (*Config, error), opts ...RestartOption) *GracefulRestart {
+	r := &GracefulRestart{
+		logger:   loglib.NewNoopLogger(),
+		load:     load,
+		run:      Run,
+		restarts: make(chan struct{}, 1),
+		notify:   signal.Notify,
+		stop:     signal.Stop,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func WithRestartLogger(l loglib.Logger) RestartOption {
+	return func(r *GracefulRestart) {
+		r.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "graceful_restart",
+		})
+	}
+}
+
+// Restart requests the stream to be restarted with the reloaded
+// configuration. It doesn't block, and requests received while a restart is
+// pending are merged into it.
+func (r *GracefulRestart) Restart() {
+	select {
+	case r.restarts <- struct{}{}:
+	default:
+	}
+}
+
+// Watch requests a restart every time a SIGHUP is received, until the context
+// is cancelled.
+func (r *GracefulRestart) Watch(ctx context.Context) {
+	sigc := make(chan os.Signal, 1)
+	r.notify(sigc, syscall.SIGHUP)
+	defer r.stop(sigc)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-sigc:
+			r.logger.Info("signal received, reloading configuration", loglib.Fields{
+				"signal": sig.String(),
+			})
+			r.Restart()
+		}
+	}
+}
+
+// Run runs the stream with the configuration on input, and restarts it with
+// the reloaded configuration when requested. This call is blocking until the
+// running pipeline stops. The run options are applied to every pipeline, and
+// the graceful shutdown on input, if any, stops the running pipeline.
+func (r *GracefulRestart) Run(ctx context.Context, logger loglib.Logger, config *Config, init bool, instrumentation *otel.Instrumentation, opts ...RunOption) error {
+	runCfg := &runConfig{}
+	for _, opt := range opts {
+		opt(runCfg)
+	}
+	shutdown := runCfg.shutdown
+	if shutdown == nil {
+		shutdown = wal.NewGracefulShutdown(&wal.ShutdownConfig{}, wal.WithShutdownLogger(logger))
+	}
+	defer shutdown.Shutdown()
+
+	rr := &restartRun{
+		GracefulRestart: r,
+		ctx:             ctx,
+		logger:          logger,
+		instrumentation: instrumentation,
+		opts:            opts,
+	}
+	shutdown.OnReaderStop(rr.stopAll)
+
+	current, err := rr.start(config, init, newHandoff(false))
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-current.done:
+			return current.err
+		case <-r.restarts:
+			next, err := rr.restart(current)
+			if err != nil {
+				r.logger.Error(err, "graceful restart failed, the pipeline keeps running with the previous config")
+				continue
+			}
+			current = next
+		}
+	}
+}
+
+// restartRun keeps track of the pipelines run by the graceful restart.
+type restartRun struct {
+	*GracefulRestart
+	ctx             context.Context
+	logger          loglib.Logger
+	instrumentation *otel.Instrumentation
+	opts            []RunOption
+
+	mutex    sync.Mutex
+	stopping bool
+	current  *generation
+	pending  *generation
+}
+
+func (rr *restartRun) start(config *Config, init bool, h *handoff) (*generation, error) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	if rr.stopping {
+		return nil, errRestartStopping
+	}
+
+	g := &generation{
+		config:   config,
+		shutdown: wal.NewGracefulShutdown(&wal.ShutdownConfig{}, wal.WithShutdownLogger(rr.logger)),
+		handoff:  h,
+		done:     make(chan struct{}),
+	}
+	opts := append(slices.Clone(rr.opts), WithGracefulShutdown(g.shutdown), withHandoff(h))
+	go func() {
+		defer close(g.done)
+		g.err = rr.run(rr.ctx, rr.logger, config, init, rr.instrumentation, opts...)
+	}()
+
+	if h.restart {
+		rr.pending = g
+	} else {
+		rr.current = g
+	}
+	return g, nil
+}
+
+// restart starts a pipeline with the reloaded configuration and hands the
+// replication over to it once it's ready. The current pipeline is kept if the
+// new one can't be started.
+func (rr *restartRun) restart(current *generation) (*generation, error) {
+	config, err := rr.load()
+	if err != nil {
+		return nil, fmt.Errorf("loading config: %w", err)
+	}
+	if err := config.IsValid(); err != nil {
+		return nil, fmt.Errorf("incompatible configuration: %w", err)
+	}
+	if err := validateRestart(current.config, config); err != nil {
+		return nil, err
+	}
+
+	rr.logger.Info("starting pipeline with the new config")
+	next, err := rr.start(config, false, newHandoff(true))
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-next.handoff.ready:
+	case <-next.done:
+		rr.setPending(nil)
+		if next.err != nil {
+			return nil, fmt.Errorf("starting pipeline with the new config: %w", next.err)
+		}
+		return nil, errRestartAborted
+	}
+
+	rr.logger.Info("draining the pipeline running with the previous config")
+	current.handoff.handedOff.Store(true)
+	if err := current.stop(); err != nil {
+		rr.logger.Warn(err, "stopping the pipeline running with the previous config")
+	}
+
+	var handoffLSN replication.LSN
+	if current.handoff.syncedLSN != nil {
+		handoffLSN = current.handoff.syncedLSN()
+	}
+	rr.logger.Info("replication handed off to the pipeline running with the new config", loglib.Fields{
+		"handoff_lsn": pgreplication.NewLSNParser().ToString(handoffLSN),
+	})
+	next.handoff.takeOver()
+
+	rr.mutex.Lock()
+	rr.current = next
+	rr.pending = nil
+	rr.mutex.Unlock()
+	return next, nil
+}
+
+func (rr *restartRun) setPending(g *generation) {
+	rr.mutex.Lock()
+	defer rr.mutex.Unlock()
+	rr.pending = g
+}
+
+// stopAll stops the running pipelines, and prevents any new ones from being
+// started.
+func (rr *restartRun) stopAll() error {
+	rr.mutex.Lock()
+	rr.stopping = true
+	generations := []*generation{rr.current, rr.pending}
+	rr.mutex.Unlock()
+
+	var errs error
+	for _, g := range generations {
+		if g != nil {
+			errs = errors.Join(errs, g.stop())
+		}
+	}
+	return errs
+}
+
+// stop runs the shutdown sequence of the pipeline and waits for it to return.
+func (g *generation) stop() error {
+	err := g.shutdown.Shutdown()
+	<-g.done
+	return err
+}
+
+// validateRestart makes sure the pipeline with the new config can pick up the
+// replication where the current one leaves it.
+func validateRestart(current, next *Config) error {
+	if current.Listener.Postgres == nil || next.Listener.Postgres == nil {
+		return errRestartListener
+	}
+	if current.PostgresReplicationSlotConfig().Temporary || next.PostgresReplicationSlotConfig().Temporary {
+		return errRestartTemporarySlot
+	}
+	if current.SourcePostgresURL() != next.SourcePostgresURL() || current.PostgresReplicationSlot() != next.PostgresReplicationSlot() {
+		return errRestartSourceChanged
+	}
+	return nil
+}
+
+// handoff coordinates a pipeline run by the graceful restart with the one it
+// replaces, so that only one of them holds the replication slot at a time.
+type handoff struct {
+	// restart is set when the pipeline replaces a running one
+	restart bool
+	// ready is closed once the pipeline is set up and about to start the
+	// replication
+	ready     chan struct{}
+	readyOnce sync.Once
+	// takeover is closed once the replaced pipeline has released the slot
+	takeover chan struct{}
+	// onTakeover reports the state of the pipeline once it takes over, since
+	// until then it's reported by the replaced one
+	onTakeover func()
+	// handedOff is set once the pipeline is being replaced
+	handedOff atomic.Bool
+	// syncedLSN returns the last position acknowledged to the slot
+	syncedLSN func() replication.LSN
+}
+
+func withHandoff(h *handoff) RunOption {
+	return func(c *runConfig) {
+		c.handoff = h
+	}
+}
+
+func newHandoff(restart bool) *handoff {
+	h := &handoff{
+		restart:  restart,
+		ready:    make(chan struct{}),
+		takeover: make(chan struct{}),
+	}
+	if !restart {
+		close(h.takeover)
+	}
+	return h
+}
+
+func (h *handoff) markReady() {
+	h.readyOnce.Do(func() { close(h.ready) })
+}
+
+func (h *handoff) takeOver() {
+	if h.onTakeover != nil {
+		h.onTakeover()
+	}
+	close(h.takeover)
+}
+
+func (h *handoff) isRestart() bool {
+	return h != nil && h.restart
+}
+
+func (h *handoff) isHandedOff() bool {
+	return h != nil && h.handedOff.Load()
+}
+
+// reportsHealth returns true if the pipeline is the one reporting its state to
+// the health registry.
+func (h *handoff) reportsHealth() bool {
+	if h == nil {
+		return true
+	}
+	select {
+	case <-h.takeover:
+		return !h.handedOff.Load()
+	default:
+		return false
+	}
+}
+
+// handoffHandler holds the start of the replication until the pipeline takes
+// over the replication slot.
+type handoffHandler struct {
+	replication.Handler
+	handoff *handoff
+}
+
+func newHandoffHandler(handler replication.Handler, h *handoff) *handoffHandler {
+	return &handoffHandler{
+		Handler: handler,
+		handoff: h,
+	}
+}
+
+func (h *handoffHandler) StartReplication(ctx context.Context) error {
+	return h.startAfterTakeover(ctx, func() error {
+		return h.Handler.StartReplication(ctx)
+	})
+}
+
+func (h *handoffHandler) StartReplicationFromLSN(ctx context.Context, lsn replication.LSN) error {
+	return h.startAfterTakeover(ctx, func() error {
+		return h.Handler.StartReplicationFromLSN(ctx, lsn)
+	})
+}
+
+func (h *handoffHandler) startAfterTakeover(ctx context.Context, start func() error) error {
+	h.handoff.markReady()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-h.handoff.takeover:
+	}
+
+	if !h.handoff.restart {
+		return start()
+	}
+
+	// the backend of the replaced pipeline can hold on to the slot for a
+	// moment after its connection is closed
+	ticker := time.NewTicker(slotReleaseInterval)
+	defer ticker.Stop()
+	for {
+		err := start()
+		if err == nil || !errors.Is(err, replication.ErrSlotInUse) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,313 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	replicationmocks "github.com/xataio/pgstream/pkg/wal/replication/mocks"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+func testRestartConfig(pgURL string) *Config {
+	return &Config{
+		Listener: ListenerConfig{
+			Postgres: &PostgresListenerConfig{URL: pgURL},
+		},
+		Processor: ProcessorConfig{
+			Kafka: &KafkaProcessorConfig{},
+		},
+	}
+}
+
+// restartRecorder is a fake stream run that records the lifecycle of the
+// pipelines run by the graceful restart.
+type restartRecorder struct {
+	mutex   sync.Mutex
+	calls   []string
+	runs    int
+	started chan int
+	// failRun makes the run on input fail before starting the replication
+	failRun int
+}
+
+func newRestartRecorder() *restartRecorder {
+	return &restartRecorder{started: make(chan int, 10)}
+}
+
+func (r *restartRecorder) record(call string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *restartRecorder) getCalls() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string{}, r.calls...)
+}
+
+func (r *restartRecorder) run(ctx context.Context, _ loglib.Logger, config *Config, init bool, _ *otel.Instrumentation, opts ...RunOption) error {
+	runCfg := &runConfig{}
+	for _, opt := range opts {
+		opt(runCfg)
+	}
+
+	r.mutex.Lock()
+	r.runs++
+	id := r.runs
+	r.mutex.Unlock()
+	r.record(fmt.Sprintf("run %d: init=%v", id, init))
+
+	if id == r.failRun {
+		return errTest
+	}
+
+	runCfg.handoff.syncedLSN = func() replication.LSN { return replication.LSN(id) }
+	handler := newHandoffHandler(&replicationmocks.Handler{
+		StartReplicationFn: func(context.Context) error {
+			r.record(fmt.Sprintf("run %d: start replication", id))
+			return nil
+		},
+	}, runCfg.handoff)
+	runCfg.shutdown.OnClose(func() error {
+		r.record(fmt.Sprintf("run %d: close", id))
+		return nil
+	})
+
+	readerCtx := runCfg.shutdown.ReaderContext(ctx)
+	if err := handler.StartReplication(readerCtx); err != nil {
+		return nil
+	}
+	r.started <- id
+	<-readerCtx.Done()
+	return nil
+}
+
+func (r *restartRecorder) waitForStart(t *testing.T, wantID int) {
+	select {
+	case id := <-r.started:
+		require.Equal(t, wantID, id)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timeout waiting for run %d to start", wantID)
+	}
+}
+
+func TestGracefulRestart_Run(t *testing.T) {
+	t.Parallel()
+
+	testURL := "postgres://localhost:5432/db"
+
+	runRestart := func(r *GracefulRestart, shutdown *wal.GracefulShutdown) chan error {
+		doneChan := make(chan error, 1)
+		go func() {
+			doneChan <- r.Run(context.Background(), loglib.NewNoopLogger(), testRestartConfig(testURL), true, nil, WithGracefulShutdown(shutdown))
+		}()
+		return doneChan
+	}
+
+	waitForRun := func(t *testing.T, doneChan chan error) {
+		select {
+		case err := <-doneChan:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for the run to return")
+		}
+	}
+
+	t.Run("ok - replication handed off to the new pipeline", func(t *testing.T) {
+		t.Parallel()
+
+		recorder := newRestartRecorder()
+		r := NewGracefulRestart(func() (*Config, error) { return testRestartConfig(testURL), nil })
+		r.run = recorder.run
+		shutdown := wal.NewGracefulShutdown(&wal.ShutdownConfig{})
+
+		doneChan := runRestart(r, shutdown)
+		recorder.waitForStart(t, 1)
+		r.Restart()
+		recorder.waitForStart(t, 2)
+		shutdown.Shutdown()
+		waitForRun(t, doneChan)
+
+		require.Equal(t, []string{
+			"run 1: init=true",
+			"run 1: start replication",
+			"run 2: init=false",
+			"run 1: close",
+			"run 2: start replication",
+			"run 2: close",
+		}, recorder.getCalls())
+	})
+
+	t.Run("ok - failed restarts keep the running pipeline", func(t *testing.T) {
+		t.Parallel()
+
+		recorder := newRestartRecorder()
+		recorder.failRun = 2
+		loads := 0
+		r := NewGracefulRestart(func() (*Config, error) {
+			loads++
+			switch loads {
+			case 1:
+				return nil, errTest
+			case 2:
+				return testRestartConfig("postgres://localhost:5432/other"), nil
+			default:
+				return testRestartConfig(testURL), nil
+			}
+		})
+		r.run = recorder.run
+		shutdown := wal.NewGracefulShutdown(&wal.ShutdownConfig{})
+
+		doneChan := runRestart(r, shutdown)
+		recorder.waitForStart(t, 1)
+		// loading error, source changed and the new pipeline failing
+		for i := 0; i < 3; i++ {
+			r.Restart()
+			require.Eventually(t, func() bool { return len(r.restarts) == 0 }, 5*time.Second, time.Millisecond)
+		}
+		require.Eventually(t, func() bool { return len(recorder.getCalls()) == 3 }, 5*time.Second, time.Millisecond)
+		shutdown.Shutdown()
+		waitForRun(t, doneChan)
+
+		require.Equal(t, []string{
+			"run 1: init=true",
+			"run 1: start replication",
+			"run 2: init=false",
+			"run 1: close",
+		}, recorder.getCalls())
+	})
+}
+
+func TestGracefulRestart_Restart(t *testing.T) {
+	t.Parallel()
+
+	r := NewGracefulRestart(func() (*Config, error) { return nil, errTest })
+	r.Restart()
+	r.Restart()
+	require.Len(t, r.restarts, 1)
+}
+
+func TestValidateRestart(t *testing.T) {
+	t.Parallel()
+
+	testURL := "postgres://localhost:5432/db"
+	withSlot := func(cfg *Config, slotName string, temporary bool) *Config {
+		cfg.Listener.Postgres.Replication.ReplicationSlotName = slotName
+		cfg.Listener.Postgres.Replication.Slot = pgreplication.SlotConfig{Temporary: temporary}
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		current *Config
+		next    *Config
+
+		wantErr error
+	}{
+		{
+			name:    "ok",
+			current: withSlot(testRestartConfig(testURL), "slot", false),
+			next:    withSlot(testRestartConfig(testURL), "slot", false),
+
+			wantErr: nil,
+		},
+		{
+			name:    "error - kafka listener",
+			current: testRestartConfig(testURL),
+			next:    &Config{Listener: ListenerConfig{Kafka: &KafkaListenerConfig{}}},
+
+			wantErr: errRestartListener,
+		},
+		{
+			name:    "error - temporary slot",
+			current: withSlot(testRestartConfig(testURL), "slot", true),
+			next:    withSlot(testRestartConfig(testURL), "slot", true),
+
+			wantErr: errRestartTemporarySlot,
+		},
+		{
+			name:    "error - source changed",
+			current: testRestartConfig(testURL),
+			next:    testRestartConfig("postgres://localhost:5432/other"),
+
+			wantErr: errRestartSourceChanged,
+		},
+		{
+			name:    "error - slot changed",
+			current: withSlot(testRestartConfig(testURL), "slot", false),
+			next:    withSlot(testRestartConfig(testURL), "other_slot", false),
+
+			wantErr: errRestartSourceChanged,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateRestart(tc.current, tc.next)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestHandoffHandler_StartReplication(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok - retried while the slot is in use", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHandoff(true)
+		attempts := 0
+		handler := newHandoffHandler(&replicationmocks.Handler{
+			StartReplicationFn: func(context.Context) error {
+				attempts++
+				if attempts < 3 {
+					return replication.ErrSlotInUse
+				}
+				return nil
+			},
+		}, h)
+
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- handler.StartReplication(context.Background())
+		}()
+
+		<-h.ready
+		require.Zero(t, attempts)
+		h.takeOver()
+		require.NoError(t, <-errChan)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("error - context cancelled before the takeover", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHandoff(true)
+		handler := newHandoffHandler(&replicationmocks.Handler{
+			StartReplicationFromLSNFn: func(context.Context, replication.LSN) error {
+				return errors.New("unexpected call to StartReplicationFromLSN")
+			},
+		}, h)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := handler.StartReplicationFromLSN(ctx, 1)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+var errTest = errors.New("oh noes")
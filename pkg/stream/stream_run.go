@@ -6,19 +6,37 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/audit/pgaudit"
+	"github.com/xataio/pgstream/pkg/health"
 	"github.com/xataio/pgstream/pkg/kafka"
 	kafkainstrumentation "github.com/xataio/pgstream/pkg/kafka/instrumentation"
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/otel"
+	pgsnapshotgenerator "github.com/xataio/pgstream/pkg/snapshot/generator/postgres/data"
+	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer/committer"
+	filecheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/file"
 	kafkacheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/kafka"
 	pgcheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/postgres"
+	redischeckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/redis"
 	"github.com/xataio/pgstream/pkg/wal/listener"
 	kafkalistener "github.com/xataio/pgstream/pkg/wal/listener/kafka"
 	pglistener "github.com/xataio/pgstream/pkg/wal/listener/postgres"
+	listenersnapshot "github.com/xataio/pgstream/pkg/wal/listener/snapshot"
+	"github.com/xataio/pgstream/pkg/wal/listener/snapshot/adapter"
 	snapshotbuilder "github.com/xataio/pgstream/pkg/wal/listener/snapshot/builder"
 	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/healthcheck"
+	processinstrumentation "github.com/xataio/pgstream/pkg/wal/processor/instrumentation"
+	"github.com/xataio/pgstream/pkg/wal/processor/migration"
+	pgwriter "github.com/xataio/pgstream/pkg/wal/processor/postgres"
+	"github.com/xataio/pgstream/pkg/wal/processor/throttle"
+	"github.com/xataio/pgstream/pkg/wal/processor/transaction"
 	"github.com/xataio/pgstream/pkg/wal/replication"
 	replicationinstrumentation "github.com/xataio/pgstream/pkg/wal/replication/instrumentation"
 	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
@@ -27,14 +45,81 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
+type RunOption func(*runConfig)
+
+type runConfig struct {
+	shutdown       *wal.GracefulShutdown
+	healthRegistry *health.Registry
+	pipelineName   string
+	// handoff is set when the stream is run by the graceful restart, to
+	// coordinate the replication slot with the pipeline it replaces
+	handoff *handoff
+}
+
+// defaultPipelineName is the name the stream state is reported with when it's
+// not run as one of multiple pipelines.
+const defaultPipelineName = "default"
+
+// WithGracefulShutdown registers the stream components with the shutdown
+// coordinator on input, which will be responsible for stopping them in order
+// when a shutdown signal is received. By default, the components are stopped
+// when the context is cancelled or the listener fails.
+func WithGracefulShutdown(shutdown *wal.GracefulShutdown) RunOption {
+	return func(c *runConfig) {
+		c.shutdown = shutdown
+	}
+}
+
+// WithHealthRegistry reports the state of the stream components to the health
+// registry on input, which determines the readiness of the process.
+func WithHealthRegistry(registry *health.Registry) RunOption {
+	return func(c *runConfig) {
+		c.healthRegistry = registry
+	}
+}
+
+func withPipelineName(name string) RunOption {
+	return func(c *runConfig) {
+		c.pipelineName = name
+	}
+}
+
 // Run will run the configured pgstream processes. This call is blocking.
-func Run(ctx context.Context, logger loglib.Logger, config *Config, init bool, instrumentation *otel.Instrumentation) error {
+func Run(ctx context.Context, logger loglib.Logger, config *Config, init bool, instrumentation *otel.Instrumentation, opts ...RunOption) error {
+	runCfg := &runConfig{pipelineName: defaultPipelineName}
+	for _, opt := range opts {
+		opt(runCfg)
+	}
+	logger = loglib.NewLogger(logger).WithFields(loglib.Fields{
+		loglib.StreamIDField: runCfg.pipelineName,
+	})
+	// the pipeline state is nil when no health registry is configured, in
+	// which case the health reporting is a noop
+	pipelineHealth := runCfg.healthRegistry.Pipeline(runCfg.pipelineName)
+	// when replacing a running pipeline, the state is reported once the
+	// replication slot is taken over
+	if !runCfg.handoff.isRestart() {
+		pipelineHealth.Start(config.healthMode())
+	}
+	defer func() {
+		if runCfg.handoff.reportsHealth() {
+			pipelineHealth.Stop()
+		}
+	}()
+	shutdown := runCfg.shutdown
+	if shutdown == nil {
+		shutdown = wal.NewGracefulShutdown(&wal.ShutdownConfig{}, wal.WithShutdownLogger(logger))
+	}
+	// the shutdown sequence only runs once, so this waits for it to complete
+	// if it has already been triggered by a signal
+	defer shutdown.Shutdown()
+
 	if err := config.IsValid(); err != nil {
 		return fmt.Errorf("incompatible configuration: %w", err)
 	}
 
 	if init {
-		if err := Init(ctx, config.SourcePostgresURL(), config.PostgresReplicationSlot()); err != nil {
+		if err := Init(ctx, config.SourcePostgresURL(), config.PostgresReplicationSlot(), WithSlotConfig(config.PostgresReplicationSlotConfig()), WithOutputPlugin(config.PostgresReplicationPlugin())); err != nil {
 			return err
 		}
 	}
@@ -42,15 +127,80 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, init bool, i
 	eg, ctx := errgroup.WithContext(ctx)
 
 	var replicationHandler replication.Handler
+	var heartbeat *pglistener.Heartbeat
+	var newlyPublishedTables []string
+	var completeNewlyPublishedTables func(context.Context) error
+	var publicationRefresher *pgreplication.Handler
+	var tableCheckpointer *pgcheckpoint.TableCheckpointer
+	var fileCheckpointer *filecheckpoint.Checkpointer
+	var redisCheckpointer *redischeckpoint.Checkpointer
 	if config.Listener.Postgres != nil {
-		var err error
-		replicationHandler, err = pgreplication.NewHandler(ctx,
-			config.Listener.Postgres.Replication,
-			pgreplication.WithLogger(logger))
+		replicationConfig := config.Listener.Postgres.Replication
+		if config.Listener.Postgres.Heartbeat != nil {
+			logger.Info("replication heartbeat enabled")
+			var err error
+			heartbeat, err = pglistener.NewHeartbeat(ctx, config.Listener.Postgres.URL, *config.Listener.Postgres.Heartbeat, pglistener.WithHeartbeatLogger(logger))
+			if err != nil {
+				return fmt.Errorf("error setting up replication heartbeat: %w", err)
+			}
+			shutdown.OnClose(heartbeat.Close)
+			// the heartbeat table needs to exist before the replication handler
+			// is created, so that it's part of the publication tables
+			if err := heartbeat.Init(ctx); err != nil {
+				return fmt.Errorf("error setting up replication heartbeat: %w", err)
+			}
+			// make sure the heartbeat events are not filtered out when the
+			// decoded tables are restricted to the publication ones
+			if replicationConfig.Publication != nil {
+				replicationConfig.IncludeTables = append(slices.Clone(replicationConfig.IncludeTables), config.Listener.Postgres.Heartbeat.QualifiedTableName())
+			}
+		}
+		handlerOpts := []pgreplication.Option{
+			pgreplication.WithLogger(logger),
+			pgreplication.WithInstrumentation(instrumentation),
+		}
+		if config.Listener.Postgres.CheckpointTable != nil {
+			// the checkpointer is set up once the handler has resolved the
+			// replication slot name, before the replication is started
+			handlerOpts = append(handlerOpts, pgreplication.WithCheckpointedLSN(func(ctx context.Context) (replication.LSN, error) {
+				return tableCheckpointer.LastSyncedLSN(ctx)
+			}))
+		}
+		if config.Listener.Postgres.CheckpointFile != nil {
+			handlerOpts = append(handlerOpts, pgreplication.WithCheckpointedLSN(func(ctx context.Context) (replication.LSN, error) {
+				return fileCheckpointer.LastSyncedLSN(ctx)
+			}))
+		}
+		if config.Listener.Postgres.CheckpointRedis != nil {
+			handlerOpts = append(handlerOpts, pgreplication.WithCheckpointedLSN(func(ctx context.Context) (replication.LSN, error) {
+				return redisCheckpointer.LastSyncedLSN(ctx)
+			}))
+		}
+		pgReplicationHandler, err := pgreplication.NewHandler(ctx, replicationConfig, handlerOpts...)
 		if err != nil {
 			return fmt.Errorf("error setting up postgres replication handler: %w", err)
 		}
-		defer replicationHandler.Close()
+		shutdown.OnClose(pgReplicationHandler.Close)
+		if runCfg.handoff != nil {
+			runCfg.handoff.syncedLSN = pgReplicationHandler.SyncedLSN
+		}
+		if config.Listener.Postgres.CheckpointTable != nil {
+			tableCfg := config.checkpointTableConfig(pgReplicationHandler.GetReplicationSlotName())
+			if tableCfg.PipelineVersion, err = config.PipelineVersion(); err != nil {
+				return err
+			}
+			tableCheckpointer, err = pgcheckpoint.NewTableCheckpointer(ctx, tableCfg)
+			if err != nil {
+				return fmt.Errorf("error setting up postgres table checkpointer: %w", err)
+			}
+			shutdown.OnCheckpointFlush(tableCheckpointer.Close)
+		}
+		replicationHandler = pgReplicationHandler
+		newlyPublishedTables = pgReplicationHandler.NewlyPublishedTables()
+		completeNewlyPublishedTables = pgReplicationHandler.CompleteNewlyPublishedTables
+		if replicationConfig.Publication != nil {
+			publicationRefresher = pgReplicationHandler
+		}
 		// if retries are not explicitly disabled, wrap the replication handler
 		// with a retrier, which will apply default retry policy if none is set
 		if !config.Listener.Postgres.RetryPolicy.DisableRetries {
@@ -70,6 +220,10 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, init bool, i
 		}
 	}
 
+	if replicationHandler != nil && runCfg.handoff != nil {
+		replicationHandler = newHandoffHandler(replicationHandler, runCfg.handoff)
+	}
+
 	var kafkaReader kafka.MessageReader
 	if config.Listener.Kafka != nil {
 		var err error
@@ -77,7 +231,7 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, init bool, i
 		if err != nil {
 			return fmt.Errorf("error setting up kafka reader: %w", err)
 		}
-		defer kafkaReader.Close()
+		shutdown.OnClose(kafkaReader.Close)
 	}
 
 	if kafkaReader != nil && instrumentation.IsEnabled() {
@@ -91,6 +245,7 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, init bool, i
 	// Checkpointer
 
 	var checkpoint checkpointer.Checkpoint
+	var txCheckpoint pgwriter.TxCheckpoint
 	switch {
 	case config.Listener.Kafka != nil:
 		kafkaCheckpointer, err := kafkacheckpoint.New(ctx,
@@ -100,23 +255,110 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, init bool, i
 		if err != nil {
 			return fmt.Errorf("error setting up kafka checkpointer:%w", err)
 		}
-		defer kafkaCheckpointer.Close()
+		shutdown.OnCheckpointFlush(kafkaCheckpointer.Close)
 		checkpoint = kafkaCheckpointer.CommitOffsets
 
 	case config.Listener.Postgres != nil:
 		pgCheckpointer := pgcheckpoint.New(replicationHandler)
-		defer pgCheckpointer.Close()
+		shutdown.OnCheckpointFlush(pgCheckpointer.Close)
 		checkpoint = pgCheckpointer.SyncLSN
+
+		switch {
+		case config.Listener.Postgres.CheckpointFile != nil:
+			// the slot is only synced once the positions are durable in the
+			// file, so that the replication never resumes ahead of it
+			var err error
+			fileCheckpointer, err = filecheckpoint.New(config.Listener.Postgres.CheckpointFile, pgCheckpointer.SyncLSN, filecheckpoint.WithLogger(logger))
+			if err != nil {
+				return fmt.Errorf("error setting up file checkpointer: %w", err)
+			}
+			shutdown.OnCheckpointFlush(fileCheckpointer.Close)
+			checkpoint = fileCheckpointer.SyncLSN
+		case config.Listener.Postgres.CheckpointRedis != nil:
+			// the slot is only synced once the positions are committed to
+			// redis, so that the replication never resumes ahead of it
+			var err error
+			redisCfg := config.checkpointRedisConfig(replicationHandler.GetReplicationSlotName())
+			redisCheckpointer, err = redischeckpoint.New(ctx, redisCfg, pgCheckpointer.SyncLSN, redischeckpoint.WithLogger(logger))
+			if err != nil {
+				return fmt.Errorf("error setting up redis checkpointer: %w", err)
+			}
+			shutdown.OnCheckpointFlush(redisCheckpointer.Close)
+			checkpoint = redisCheckpointer.SyncLSN
+		case tableCheckpointer == nil:
+		case config.checkpointsInTarget():
+			// the checkpoint row is written by the postgres writer in the same
+			// transaction as the batch it belongs to, and the slot is synced
+			// once it's committed
+			txCheckpoint = tableCheckpointer.SyncLSNInTx
+		default:
+			checkpoint = func(ctx context.Context, positions []wal.CommitPosition) error {
+				if err := tableCheckpointer.SyncLSN(ctx, positions); err != nil {
+					return err
+				}
+				return pgCheckpointer.SyncLSN(ctx, positions)
+			}
+		}
+	}
+
+	if config.Listener.Checkpoint != nil && checkpoint != nil {
+		// the commits to the checkpointer backend are deferred following the
+		// configured semantics
+		committerOpts := []committer.Option{
+			committer.WithLogger(logger),
+			committer.WithInstrumentation(instrumentation),
+		}
+		if config.Listener.Postgres != nil {
+			committerOpts = append(committerOpts, committer.WithLSNParser(pgreplication.NewLSNParser()))
+		}
+		checkpointCommitter := committer.New(config.Listener.Checkpoint, checkpoint, committerOpts...)
+		shutdown.OnCheckpointFlush(checkpointCommitter.Close)
+		checkpoint = checkpointCommitter.Checkpoint
 	}
 
 	// Processor
 
-	processor, closer, err := newProcessor(ctx, logger, config, checkpoint, processorTypeReplication, instrumentation)
-	defer closer()
+	processor, closer, err := newProcessor(ctx, logger, config, checkpoint, txCheckpoint, processorTypeReplication, instrumentation, pipelineHealth)
+	shutdown.OnProcessorFlush(closer)
 	if err != nil {
 		return err
 	}
 
+	// Health
+
+	addHealthChecks := func() {
+		if replicationHandler != nil {
+			pipelineHealth.AddCheck("replication", func(ctx context.Context) error {
+				slotStatus, err := replicationHandler.GetReplicationSlotStatus(ctx)
+				if err != nil {
+					return err
+				}
+				if !slotStatus.Active {
+					return errReplicationInactive
+				}
+				return nil
+			})
+			pipelineHealth.SetReplicationLag(replicationHandler.GetReplicationLag)
+		}
+		switch {
+		case tableCheckpointer != nil:
+			pipelineHealth.AddCheck("checkpointer", lastSyncedLSNCheck(tableCheckpointer.LastSyncedLSN))
+		case fileCheckpointer != nil:
+			pipelineHealth.AddCheck("checkpointer", lastSyncedLSNCheck(fileCheckpointer.LastSyncedLSN))
+		case redisCheckpointer != nil:
+			pipelineHealth.AddCheck("checkpointer", lastSyncedLSNCheck(redisCheckpointer.LastSyncedLSN))
+		}
+		pipelineHealth.AddSink(processor.Name(), processor)
+	}
+	if runCfg.handoff.isRestart() {
+		runCfg.handoff.onTakeover = func() {
+			pipelineHealth.Start(config.healthMode())
+			addHealthChecks()
+		}
+	} else {
+		addHealthChecks()
+	}
+
 	// Listener
 
 	var listener listener.Listener
@@ -125,40 +367,89 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, init bool, i
 		logger.Info("postgres listener configured")
 		opts := []pglistener.Option{
 			pglistener.WithLogger(logger),
+			pglistener.WithStandbyStatusInterval(config.Listener.Postgres.StandbyStatusInterval),
+			pglistener.WithFormatVersion(config.Listener.Postgres.Replication.Plugin.FormatVersion),
+			pglistener.WithOutputPlugin(config.PostgresReplicationPlugin()),
+		}
+		if config.Processor.XIDSequencer != nil {
+			opts = append(opts, pglistener.WithCommitEvents())
+		}
+		if instrumentation.IsEnabled() {
+			opts = append(opts, pglistener.WithInstrumentation(instrumentation))
+		}
+		// the retrier already resets the replication connection when receiving
+		// a message fails, so the listener only re-establishes it when the
+		// retries are disabled, unless reconnects are explicitly disabled too.
+		// The default reconnect policy applies if none is set.
+		if config.Listener.Postgres.RetryPolicy.DisableRetries && !config.Listener.Postgres.ReconnectPolicy.DisableRetries {
+			opts = append(opts, pglistener.WithReconnect(config.Listener.Postgres.ReconnectPolicy))
+		}
+		var snapshotDataOpts []pgsnapshotgenerator.Option
+		if config.Listener.Postgres.SlotMonitor != nil {
+			logger.Info("replication slot monitor enabled")
+			slotMonitor := pglistener.NewSlotMonitor(*config.Listener.Postgres.SlotMonitor, replicationHandler, pglistener.WithSlotMonitorLogger(logger))
+			opts = append(opts, pglistener.WithSlotMonitor(slotMonitor))
+			snapshotDataOpts = append(snapshotDataOpts, pgsnapshotgenerator.WithTableSnapshotGate(slotMonitor.WaitForCapacity))
+		}
+		if heartbeat != nil {
+			opts = append(opts, pglistener.WithHeartbeat(heartbeat))
+		}
+		if publicationRefresher != nil {
+			opts = append(opts, pglistener.WithPublicationRefresh(publicationRefresher))
 		}
-		if config.Listener.Postgres.Snapshot != nil {
+		listenerConfig := config.Listener.Postgres
+		if runCfg.handoff.isRestart() {
+			// the initial snapshot was completed by the pipeline being replaced
+			withoutSnapshot := *listenerConfig
+			withoutSnapshot.Snapshot = nil
+			listenerConfig = &withoutSnapshot
+		}
+		snapshotConfig := listenerConfig.Snapshot
+		if len(newlyPublishedTables) > 0 {
+			logger.Info("snapshot enabled for tables added to the publication", loglib.Fields{"tables": newlyPublishedTables})
+			snapshotConfig = newlyPublishedTablesSnapshotConfig(listenerConfig, newlyPublishedTables)
+		}
+		if snapshotConfig != nil {
 			logger.Info("initial snapshot enabled")
 			// use a dedicated processor for the snapshot phase, to be able to
 			// close it and make sure the snapshot is complete before starting
 			// to process the WAL replication events.
-			snapshotProcessor, snapshotCloser, err := newProcessor(ctx, logger, config, checkpoint, processorTypeSnapshot, instrumentation)
-			defer snapshotCloser()
+			snapshotProcessor, snapshotCloser, err := newProcessor(ctx, logger, config, checkpoint, nil, processorTypeSnapshot, instrumentation, pipelineHealth)
+			shutdown.OnProcessorFlush(snapshotCloser)
 			if err != nil {
 				return fmt.Errorf("error creating snapshot processor: %w", err)
 			}
 
+			snapshotDataOpts = append(snapshotDataOpts, pgsnapshotgenerator.WithTableEventHandler(pipelineHealth.SnapshotTableEvent))
 			snapshotGenerator, err := snapshotbuilder.NewSnapshotGenerator(
 				ctx,
-				config.Listener.Postgres.Snapshot,
+				snapshotConfig,
 				snapshotProcessor,
 				logger,
-				instrumentation)
+				instrumentation,
+				snapshotDataOpts...)
 			if err != nil {
 				return err
 			}
-			defer snapshotGenerator.Close()
+			shutdown.OnReaderStop(snapshotGenerator.Close)
+			if len(newlyPublishedTables) > 0 {
+				snapshotGenerator = &newlyPublishedTablesGenerator{
+					Generator: snapshotGenerator,
+					complete:  completeNewlyPublishedTables,
+				}
+			}
 			opts = append(opts, pglistener.WithInitialSnapshot(snapshotGenerator))
 		}
 
 		listener = pglistener.New(
 			replicationHandler,
-			processor.ProcessWALEvent,
+			shutdown.TrackEvents(ctx, pipelineHealth.TrackEvents(processor.ProcessWALEvent)),
 			opts...)
 	case config.Listener.Kafka != nil:
 		logger.Info("kafka listener configured")
 		listener, err = kafkalistener.NewWALReader(
 			kafkaReader,
-			processor.ProcessWALEvent,
+			shutdown.TrackEvents(ctx, pipelineHealth.TrackEvents(processor.ProcessWALEvent)),
 			kafkalistener.WithLogger(logger))
 		if err != nil {
 			return err
@@ -166,16 +457,21 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, init bool, i
 	default:
 		return errors.New("no supported listener found")
 	}
-	defer listener.Close()
 
+	listenerDone := make(chan struct{})
 	eg.Go(func() error {
+		defer close(listenerDone)
 		defer logger.Info("stopping listener...")
 		logger.Info("starting listener...")
-		return listener.Listen(ctx)
+		return listener.Listen(shutdown.ReaderContext(ctx))
+	})
+	shutdown.OnReaderStop(func() error {
+		<-listenerDone
+		return listener.Close()
 	})
 
 	if err := eg.Wait(); err != nil {
-		if !errors.Is(err, context.Canceled) {
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, wal.ErrShuttingDown) {
 			return err
 		}
 	}
@@ -183,24 +479,235 @@ func Run(ctx context.Context, logger loglib.Logger, config *Config, init bool, i
 	return nil
 }
 
+var errReplicationInactive = errors.New("replication slot is not active")
+
+// lastSyncedLSNCheck returns a health check that makes sure the checkpointer
+// backend is reachable by reading the last synced LSN from it.
+func lastSyncedLSNCheck(lastSyncedLSN func(context.Context) (replication.LSN, error)) health.Check {
+	return func(ctx context.Context) error {
+		_, err := lastSyncedLSN(ctx)
+		return err
+	}
+}
+
+// newlyPublishedTablesSnapshotConfig returns the snapshot configuration
+// required to snapshot the tables that have been added to the publication. If
+// an initial snapshot is configured, the tables are added to it. Otherwise, a
+// data snapshot is configured for them.
+func newlyPublishedTablesSnapshotConfig(cfg *PostgresListenerConfig, tables []string) *snapshotbuilder.SnapshotListenerConfig {
+	if cfg.Snapshot == nil {
+		return &snapshotbuilder.SnapshotListenerConfig{
+			Data: &pgsnapshotgenerator.Config{
+				URL: cfg.URL,
+			},
+			Adapter: adapter.SnapshotConfig{
+				Tables: tables,
+			},
+		}
+	}
+
+	snapshotConfig := *cfg.Snapshot
+	snapshotConfig.Adapter.Tables = slices.Clone(cfg.Snapshot.Adapter.Tables)
+	for _, table := range tables {
+		if !slices.Contains(snapshotConfig.Adapter.Tables, table) {
+			snapshotConfig.Adapter.Tables = append(snapshotConfig.Adapter.Tables, table)
+		}
+	}
+	return &snapshotConfig
+}
+
+// newlyPublishedTablesGenerator wraps the snapshot generator so that the
+// tables added to the publication are no longer pending once the snapshot has
+// completed.
+type newlyPublishedTablesGenerator struct {
+	listenersnapshot.Generator
+	complete func(context.Context) error
+}
+
+func (g *newlyPublishedTablesGenerator) CreateSnapshot(ctx context.Context) error {
+	if err := g.Generator.CreateSnapshot(ctx); err != nil {
+		return err
+	}
+	if err := g.complete(ctx); err != nil {
+		return fmt.Errorf("completing snapshot of tables added to the publication: %w", err)
+	}
+	return nil
+}
+
 var noopCloser func() error = func() error {
 	return nil
 }
 
-func newProcessor(ctx context.Context, logger loglib.Logger, config *Config, checkpoint checkpointer.Checkpoint, processorType processorType, instrumentation *otel.Instrumentation) (processor.Processor, closerFn, error) {
-	processor, err := buildProcessor(ctx, logger, &config.Processor, checkpoint, processorType, instrumentation)
+func newProcessor(ctx context.Context, logger loglib.Logger, config *Config, checkpoint checkpointer.Checkpoint, txCheckpoint pgwriter.TxCheckpoint, processorType processorType, instrumentation *otel.Instrumentation, pipelineHealth *health.PipelineState) (processor.Processor, closerFn, error) {
+	// the apply latency is measured from the source commit, so it's only
+	// tracked for the replication events
+	var latencyTracker *processinstrumentation.ApplyLatencyTracker
+	if processorType == processorTypeReplication && config.Listener.Postgres != nil && instrumentation.IsEnabled() && instrumentation.Meter != nil {
+		var err error
+		latencyTracker, err = newApplyLatencyTracker(ctx, logger, config, instrumentation)
+		if err != nil {
+			return nil, noopCloser, err
+		}
+		if checkpoint != nil {
+			checkpoint = latencyTracker.Checkpoint(checkpoint)
+		}
+		if txCheckpoint != nil {
+			next := txCheckpoint
+			txCheckpoint = func(ctx context.Context, tx pglib.Tx, positions []wal.CommitPosition) error {
+				if err := next(ctx, tx, positions); err != nil {
+					return err
+				}
+				latencyTracker.Acknowledge(ctx, positions)
+				return nil
+			}
+		}
+	}
+
+	// the commit hook is called from the checkpoints, so that it's only called
+	// once the transaction has been written to the target
+	var commitTracker *transaction.CommitTracker
+	if processorType == processorTypeReplication && config.Processor.TransactionHooks != nil && config.Listener.Postgres != nil {
+		commitTracker = transaction.NewCommitTracker(config.Processor.TransactionHooks.OnCommit, pgreplication.NewLSNParser())
+		if checkpoint != nil {
+			checkpoint = commitTracker.Checkpoint(checkpoint)
+		}
+		if txCheckpoint != nil {
+			next := txCheckpoint
+			txCheckpoint = func(ctx context.Context, tx pglib.Tx, positions []wal.CommitPosition) error {
+				if err := next(ctx, tx, positions); err != nil {
+					return err
+				}
+				commitTracker.Acknowledge(positions)
+				return nil
+			}
+		}
+	}
+
+	processor, err := buildProcessor(ctx, logger, &config.Processor, checkpoint, txCheckpoint, processorType, instrumentation)
 	if err != nil {
 		return nil, noopCloser, err
 	}
 	var closerAgg closerAggregator
 	var closer closerFn
-	processor, closer, err = addProcessorModifiers(ctx, config, logger, processor, instrumentation)
+	processor, closer, err = addProcessorModifiers(ctx, config, logger, processor, instrumentation, commitTracker)
 	if err != nil {
 		return nil, noopCloser, err
 	}
 
+	// the snapshot events are not part of a replicated transaction, so they
+	// can't be correlated with the pg_audit entries
+	if processorType == processorTypeReplication && config.Processor.PGAudit != nil {
+		logger.Info("adding pg_audit correlation to processor...")
+		processor, err = newPGAuditCorrelator(ctx, logger, config, processor)
+		if err != nil {
+			closer()
+			return nil, noopCloser, err
+		}
+	}
+
+	// the migration safe mode is only applied to the replication events, since
+	// the snapshot events are not acknowledged through the replication slot
+	if processorType == processorTypeReplication && config.Processor.MigrationSafeMode != nil {
+		logger.Info("adding migration safe mode to processor...")
+		processor, err = newMigrationSafeMode(ctx, logger, config, processor)
+		if err != nil {
+			closer()
+			return nil, noopCloser, err
+		}
+	}
+
+	// the live replication events are not throttled, so that they're not
+	// starved by the snapshot of very large tables
+	if processorType == processorTypeSnapshot && config.Processor.TableSizeThrottle != nil {
+		logger.Info("adding table size throttle to snapshot processor...")
+		cfg := *config.Processor.TableSizeThrottle
+		if cfg.URL == "" {
+			cfg.URL = config.SourcePostgresURL()
+		}
+		tableSizeThrottle, err := throttle.New(ctx, &cfg, processor, throttle.WithLogger(logger), throttle.WithInstrumentation(instrumentation))
+		if err != nil {
+			processor.Close()
+			closer()
+			return nil, noopCloser, fmt.Errorf("error creating processor table size throttle layer: %w", err)
+		}
+		processor = tableSizeThrottle
+	}
+
+	// the sink health check is the outermost layer, so that the processing
+	// blocks before any of the modifiers buffer the events while the sink is
+	// unreachable
+	if config.Processor.SinkHealthCheck != nil {
+		logger.Info("adding sink health check to processor...")
+		healthChecker, err := healthcheck.New(ctx, config.Processor.SinkHealthCheck, processor,
+			healthcheck.WithLogger(logger),
+			healthcheck.WithPauseHandler(pipelineHealth.SetPaused))
+		if err != nil {
+			processor.Close()
+			closer()
+			return nil, noopCloser, fmt.Errorf("error creating processor sink health check layer: %w", err)
+		}
+		processor = healthChecker
+	}
+
+	if latencyTracker != nil {
+		processor = latencyTracker.Wrap(processor)
+	}
+
 	closerAgg.addCloserFn(closer)
 	closerAgg.addCloserFn(processor.Close)
 
 	return processor, closerAgg.close, nil
 }
+
+// newApplyLatencyTracker returns an apply latency tracker corrected by the
+// clock skew with the source database. The latency is still tracked without
+// the correction if the skew can't be estimated.
+func newApplyLatencyTracker(ctx context.Context, logger loglib.Logger, config *Config, instrumentation *otel.Instrumentation) (*processinstrumentation.ApplyLatencyTracker, error) {
+	opts := []processinstrumentation.ApplyLatencyOption{}
+	skew, err := estimateSourceClockSkew(ctx, config.SourcePostgresURL())
+	if err != nil {
+		logger.Warn(err, "apply latency won't be corrected by the source clock skew")
+	} else {
+		logger.Info("estimated source clock skew", loglib.Fields{"clock_skew": skew.String()})
+		opts = append(opts, processinstrumentation.WithClockSkew(skew))
+	}
+
+	tracker, err := processinstrumentation.NewApplyLatencyTracker(instrumentation, pgreplication.NewLSNParser(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating apply latency tracker: %w", err)
+	}
+	return tracker, nil
+}
+
+func estimateSourceClockSkew(ctx context.Context, url string) (time.Duration, error) {
+	conn, err := pglib.NewConn(ctx, url)
+	if err != nil {
+		return 0, fmt.Errorf("connecting to source postgres: %w", err)
+	}
+	defer conn.Close(context.Background())
+	return processinstrumentation.EstimateClockSkew(ctx, conn)
+}
+
+func newPGAuditCorrelator(ctx context.Context, logger loglib.Logger, config *Config, processor processor.Processor) (processor.Processor, error) {
+	cfg := *config.Processor.PGAudit
+	if cfg.Table != "" && cfg.URL == "" {
+		cfg.URL = config.SourcePostgresURL()
+	}
+	correlator, err := pgaudit.New(ctx, &cfg, processor, pgaudit.WithLogger(logger))
+	if err != nil {
+		return nil, fmt.Errorf("error creating processor pg_audit correlation layer: %w", err)
+	}
+	return correlator, nil
+}
+
+func newMigrationSafeMode(ctx context.Context, logger loglib.Logger, config *Config, processor processor.Processor) (processor.Processor, error) {
+	cfg := *config.Processor.MigrationSafeMode
+	if cfg.URL == "" {
+		cfg.URL = config.SourcePostgresURL()
+	}
+	safeMode, err := migration.New(ctx, &cfg, processor, migration.WithLogger(logger))
+	if err != nil {
+		return nil, fmt.Errorf("error creating processor migration safe mode layer: %w", err)
+	}
+	return safeMode, nil
+}
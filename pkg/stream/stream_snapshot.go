@@ -27,14 +27,14 @@ func Snapshot(ctx context.Context, logger loglib.Logger, config *Config, instrum
 
 	// Processor
 
-	processor, err := buildProcessor(ctx, logger, &config.Processor, nil, processorTypeSnapshot, instrumentation)
+	processor, err := buildProcessor(ctx, logger, &config.Processor, nil, nil, processorTypeSnapshot, instrumentation)
 	if err != nil {
 		return err
 	}
 	defer processor.Close()
 
 	var closer closerFn
-	processor, closer, err = addProcessorModifiers(ctx, config, logger, processor, instrumentation)
+	processor, closer, err = addProcessorModifiers(ctx, config, logger, processor, instrumentation, nil)
 	if err != nil {
 		return err
 	}
@@ -13,6 +13,7 @@ import (
 	pgmigrations "github.com/xataio/pgstream/migrations/postgres"
 	"github.com/xataio/pgstream/pkg/transformers/builder"
 	"github.com/xataio/pgstream/pkg/wal/processor/transformer"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
 
 	"github.com/jackc/pgx/v5"
 )
@@ -36,8 +37,6 @@ type migrator interface {
 	Close() (error, error)
 }
 
-const wal2jsonPlugin = "wal2json"
-
 const (
 	noPgstreamSchemaErrMsg         = "pgstream schema does not exist in the configured postgres database"
 	noPgstreamSchemaLogTableErrMsg = "pgstream schema_log table does not exist in the configured postgres database"
@@ -72,7 +71,7 @@ func (s *StatusChecker) Status(ctx context.Context, config *Config) (*Status, er
 		return nil, fmt.Errorf("checking source status: %w", err)
 	}
 
-	initStatus, err := s.initStatus(ctx, config.SourcePostgresURL(), config.PostgresReplicationSlot())
+	initStatus, err := s.initStatus(ctx, config.SourcePostgresURL(), config.PostgresReplicationSlot(), config.PostgresReplicationPlugin())
 	if err != nil {
 		return nil, fmt.Errorf("checking init status: %w", err)
 	}
@@ -139,8 +138,9 @@ func (s *StatusChecker) sourceStatus(ctx context.Context, config *Config) (*Sour
 
 // initStatus checks the initialisation status of pgstream in the provided
 // postgres database. If the replicationSlotName is empty, it will check against
-// the default (pgstream_<database>_slot).
-func (s *StatusChecker) initStatus(ctx context.Context, pgURL, replicationSlotName string) (*InitStatus, error) {
+// the default (pgstream_<database>_slot). The replication slot is expected to
+// use the output plugin on input, wal2json if empty.
+func (s *StatusChecker) initStatus(ctx context.Context, pgURL, replicationSlotName, outputPlugin string) (*InitStatus, error) {
 	if pgURL == "" {
 		return nil, errMissingPostgresURL
 	}
@@ -158,7 +158,7 @@ func (s *StatusChecker) initStatus(ctx context.Context, pgURL, replicationSlotNa
 		return nil, fmt.Errorf("validating pgstream migrations: %w", err)
 	}
 
-	initStatus.ReplicationSlot, err = s.validateReplicationSlotStatus(ctx, pgURL, replicationSlotName)
+	initStatus.ReplicationSlot, err = s.validateReplicationSlotStatus(ctx, pgURL, replicationSlotName, outputPlugin)
 	if err != nil {
 		return nil, fmt.Errorf("validating replication slot: %w", err)
 	}
@@ -190,8 +190,9 @@ func (s *StatusChecker) TransformationRulesStatus(ctx context.Context, config *C
 		return nil, err
 	}
 	rules := transformer.Rules{
-		Transformers:   config.Processor.Transformer.TransformerRules,
-		ValidationMode: config.Processor.Transformer.ValidationMode,
+		Transformers:      config.Processor.Transformer.TransformerRules,
+		NamedTransformers: config.Processor.Transformer.NamedTransformers,
+		ValidationMode:    config.Processor.Transformer.ValidationMode,
 	}
 	if _, err := validator(ctx, rules); err != nil {
 		status.Valid = false
@@ -302,8 +303,13 @@ func (s *StatusChecker) validateSchemaStatus(ctx context.Context, pgURL string)
 }
 
 // validateReplicationSlotStatus checks if the replication slot exists in the
-// configured postgres database, and it's correctly configured.
-func (s *StatusChecker) validateReplicationSlotStatus(ctx context.Context, pgURL, replicationSlotName string) (*ReplicationSlotStatus, error) {
+// configured postgres database, and it's correctly configured with the output
+// plugin on input, wal2json if empty.
+func (s *StatusChecker) validateReplicationSlotStatus(ctx context.Context, pgURL, replicationSlotName, outputPlugin string) (*ReplicationSlotStatus, error) {
+	if outputPlugin == "" {
+		outputPlugin = pgreplication.PluginWal2JSON
+	}
+
 	if replicationSlotName == "" {
 		var err error
 		replicationSlotName, err = getReplicationSlotName(pgURL)
@@ -344,8 +350,8 @@ func (s *StatusChecker) validateReplicationSlotStatus(ctx context.Context, pgURL
 			return []string{fmt.Sprintf("replication slot %s does not exist in the configured database", replicationSlotName)}
 		}
 
-		if plugin != wal2jsonPlugin {
-			return []string{fmt.Sprintf("replication slot %s is not using the wal2json plugin", replicationSlotName)}
+		if plugin != outputPlugin {
+			return []string{fmt.Sprintf("replication slot %s is not using the %s plugin", replicationSlotName, outputPlugin)}
 		}
 
 		return nil
@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/transformers/builder"
+	"github.com/xataio/pgstream/pkg/wal/processor/transformer"
+)
+
+// TransformationRulesDryRun is the per rule validation report of the
+// transformation rules, along with the sample rows of each table before and
+// after the transformations.
+type TransformationRulesDryRun struct {
+	*transformer.DryRunReport
+}
+
+var errMissingTransformerRules = errors.New("transformation rules are required for the dry run")
+
+// DryRunTransformationRules validates each of the configured transformation
+// rules against the source postgres database schema, using the same parser as
+// the stream, and applies them to the number of sample rows on input from each
+// table. Nothing is written to the target.
+func DryRunTransformationRules(ctx context.Context, config *Config, sampleRows int) (*TransformationRulesDryRun, error) {
+	if config.Processor.Transformer == nil {
+		return nil, errMissingTransformerRules
+	}
+
+	pgURL := config.SourcePostgresURL()
+	if pgURL == "" {
+		return nil, errMissingPostgresURL
+	}
+
+	parser, err := transformer.NewPostgresTransformerParser(ctx, pgURL, builder.NewTransformerBuilder(), config.RequiredTables())
+	if err != nil {
+		return nil, fmt.Errorf("creating transformer validator: %w", err)
+	}
+	defer parser.Close()
+
+	rules := transformer.Rules{
+		Transformers:      config.Processor.Transformer.TransformerRules,
+		NamedTransformers: config.Processor.Transformer.NamedTransformers,
+		ValidationMode:    config.Processor.Transformer.ValidationMode,
+	}
+	if config.Processor.Transformer.InferFromSecurityLabels {
+		anonRuleParser, err := transformer.NewAnonRuleParser(ctx, pgURL, false, loglib.NewNoopLogger(), parser.ParseAndValidate)
+		if err != nil {
+			return nil, fmt.Errorf("creating anon rule parser: %w", err)
+		}
+		defer anonRuleParser.Close()
+		if rules, err = anonRuleParser.InferRules(ctx, rules); err != nil {
+			return nil, fmt.Errorf("inferring transformation rules from security labels: %w", err)
+		}
+	}
+
+	report, err := parser.DryRun(ctx, rules, sampleRows)
+	if err != nil {
+		return nil, err
+	}
+	return &TransformationRulesDryRun{DryRunReport: report}, nil
+}
+
+func (d *TransformationRulesDryRun) PrettyPrint() string {
+	if d == nil || d.DryRunReport == nil {
+		return ""
+	}
+
+	var prettyPrint strings.Builder
+	prettyPrint.WriteString("Transformation rules dry run:\n")
+	prettyPrint.WriteString(fmt.Sprintf(" - Valid: %t\n", d.Valid()))
+	if len(d.Errors) > 0 {
+		prettyPrint.WriteString(fmt.Sprintf(" - Errors: %s\n", d.Errors))
+	}
+
+	// the sample rows only show the columns with a valid rule
+	validColumns := map[string][]string{}
+	if len(d.Rules) > 0 {
+		prettyPrint.WriteString(" - Rules:\n")
+	}
+	for _, rule := range d.Rules {
+		table := rule.Schema + "." + rule.Table
+		result := "valid"
+		if rule.Error != "" {
+			result = rule.Error
+		} else {
+			validColumns[table] = append(validColumns[table], rule.Column)
+		}
+		prettyPrint.WriteString(fmt.Sprintf("   - %s.%s (%s): %s\n", table, rule.Column, ruleTransformerName(rule), result))
+	}
+
+	if len(d.Samples) > 0 {
+		prettyPrint.WriteString(" - Samples:\n")
+	}
+	for _, sample := range d.Samples {
+		table := sample.Schema + "." + sample.Table
+		columns := validColumns[table]
+		slices.Sort(columns)
+		if len(sample.Rows) == 0 {
+			prettyPrint.WriteString(fmt.Sprintf("   - %s: no rows\n", table))
+		}
+		for i, row := range sample.Rows {
+			prettyPrint.WriteString(fmt.Sprintf("   - %s row %d:\n", table, i+1))
+			for _, column := range columns {
+				if err, found := row.Errors[column]; found {
+					prettyPrint.WriteString(fmt.Sprintf("     - %s: %v -> error: %s\n", column, row.Before[column], err))
+					continue
+				}
+				prettyPrint.WriteString(fmt.Sprintf("     - %s: %v -> %v\n", column, row.Before[column], row.After[column]))
+			}
+		}
+	}
+
+	// trim the last newline character
+	return strings.TrimSuffix(prettyPrint.String(), "\n")
+}
+
+func ruleTransformerName(rule transformer.RuleReport) string {
+	switch {
+	case rule.NamedTransformer != "":
+		return "named_transformer: " + rule.NamedTransformer
+	case rule.Transformer != "":
+		return rule.Transformer
+	default:
+		return "no transformer"
+	}
+}
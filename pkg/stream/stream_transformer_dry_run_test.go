@@ -0,0 +1,103 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal/processor/transformer"
+)
+
+func TestTransformationRulesDryRun_PrettyPrint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		dryRun     *TransformationRulesDryRun
+		wantOutput string
+	}{
+		{
+			name:       "nil report",
+			dryRun:     &TransformationRulesDryRun{},
+			wantOutput: "",
+		},
+		{
+			name: "valid rules without samples",
+			dryRun: &TransformationRulesDryRun{
+				DryRunReport: &transformer.DryRunReport{
+					Rules: []transformer.RuleReport{
+						{Schema: "public", Table: "users", Column: "email", NamedTransformer: "email_mask"},
+						{Schema: "public", Table: "users", Column: "name", Transformer: "neosync_firstname"},
+					},
+				},
+			},
+			wantOutput: `Transformation rules dry run:
+ - Valid: true
+ - Rules:
+   - public.users.email (named_transformer: email_mask): valid
+   - public.users.name (neosync_firstname): valid`,
+		},
+		{
+			name: "invalid rules with samples",
+			dryRun: &TransformationRulesDryRun{
+				DryRunReport: &transformer.DryRunReport{
+					Errors: []string{`table "public"."missing": querying table rows: oh noes`},
+					Rules: []transformer.RuleReport{
+						{Schema: "public", Table: "users", Column: "name", Transformer: "literal_string"},
+						{Schema: "public", Table: "users", Column: "id", Transformer: "literal_string"},
+						{Schema: "public", Table: "users", Column: "phone", Transformer: "literal_string", Error: `column phone not found in table "public"."users"`},
+						{Schema: "public", Table: "users", Column: "age", Error: "column age has no transformer configured in strict mode"},
+					},
+					Samples: []transformer.TableSample{
+						{
+							Schema: "public",
+							Table:  "users",
+							Rows: []transformer.SampleRow{
+								{
+									Before: map[string]any{"id": 1, "name": "alice", "age": 30},
+									After:  map[string]any{"id": "x", "name": "anonymous", "age": 30},
+								},
+								{
+									Before: map[string]any{"id": 2, "name": "bob", "age": 40},
+									After:  map[string]any{"id": nil, "name": "anonymous", "age": 40},
+									Errors: map[string]string{"id": "oh noes"},
+								},
+							},
+						},
+						{
+							Schema: "public",
+							Table:  "empty",
+							Rows:   []transformer.SampleRow{},
+						},
+					},
+				},
+			},
+			wantOutput: `Transformation rules dry run:
+ - Valid: false
+ - Errors: [table "public"."missing": querying table rows: oh noes]
+ - Rules:
+   - public.users.name (literal_string): valid
+   - public.users.id (literal_string): valid
+   - public.users.phone (literal_string): column phone not found in table "public"."users"
+   - public.users.age (no transformer): column age has no transformer configured in strict mode
+ - Samples:
+   - public.users row 1:
+     - id: 1 -> x
+     - name: alice -> anonymous
+   - public.users row 2:
+     - id: 2 -> error: oh noes
+     - name: bob -> anonymous
+   - public.empty: no rows`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			output := tc.dryRun.PrettyPrint()
+			require.Equal(t, tc.wantOutput, output)
+		})
+	}
+}
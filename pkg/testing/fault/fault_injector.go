@@ -0,0 +1,354 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fault provides a processor decorator that injects failures in the
+// processing pipeline, to chaos test the behaviour of pgstream under sink
+// errors, slow consumers, corrupted events and panics.
+package fault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// FaultInjector is a decorator around a wal processor that injects the faults
+// described by its FaultSpec before sending the events to the wrapped
+// processor. It's meant to be used in tests only.
+type FaultInjector struct {
+	logger    loglib.Logger
+	processor processor.Processor
+	spec      FaultSpec
+
+	// rand is not concurrency safe, so its use is guarded by the mutex
+	randMutex sync.Mutex
+	rand      *rand.Rand
+
+	sleep func(ctx context.Context, d time.Duration) error
+
+	errors      atomic.Uint64
+	panics      atomic.Uint64
+	corruptions atomic.Uint64
+	delays      atomic.Uint64
+}
+
+// FaultSpec describes the faults injected for every event processed. The
+// rates are probabilities between 0 and 1, where 0 disables the fault.
+type FaultSpec struct {
+	// ErrorRate is the probability of returning ErrInjectedFault instead of
+	// sending the event to the wrapped processor.
+	ErrorRate float64
+	// PanicRate is the probability of panicking while processing the event.
+	PanicRate float64
+	// CorruptionRate is the probability of flipping the value of a random
+	// column of the event before sending it to the wrapped processor.
+	CorruptionRate float64
+	// Latency adds an artificial delay before processing every event. No
+	// latency is added if not set.
+	Latency *LatencySpec
+	// Seed of the random source, to make the injected faults reproducible.
+	// Defaults to a random seed.
+	Seed uint64
+}
+
+// LatencyDistribution is the probability distribution the artificial latency
+// is drawn from.
+type LatencyDistribution string
+
+const (
+	// FixedLatency always delays the events by the mean latency.
+	FixedLatency LatencyDistribution = "fixed"
+	// UniformLatency draws the latency uniformly between min and max.
+	UniformLatency LatencyDistribution = "uniform"
+	// NormalLatency draws the latency from a normal distribution with the
+	// mean and standard deviation configured. Negative draws are clamped to 0.
+	NormalLatency LatencyDistribution = "normal"
+	// ExponentialLatency draws the latency from an exponential distribution
+	// with the mean configured, to simulate occasional long tail delays.
+	ExponentialLatency LatencyDistribution = "exponential"
+)
+
+type LatencySpec struct {
+	// Distribution defaults to fixed.
+	Distribution LatencyDistribution
+	// Mean is used by the fixed, normal and exponential distributions.
+	Mean time.Duration
+	// StdDev is used by the normal distribution.
+	StdDev time.Duration
+	// Min and Max are used by the uniform distribution.
+	Min time.Duration
+	Max time.Duration
+}
+
+// Stats counts the faults injected so far.
+type Stats struct {
+	Errors      uint64
+	Panics      uint64
+	Corruptions uint64
+	Delays      uint64
+}
+
+type Option func(f *FaultInjector)
+
+var (
+	// ErrInjectedFault is returned for the events that are selected for error
+	// injection.
+	ErrInjectedFault = errors.New("injected fault")
+	// ErrInjectedPanic is the value the injected panics are raised with.
+	ErrInjectedPanic = errors.New("injected panic")
+
+	errInvalidRate                    = errors.New("fault rate must be between 0 and 1")
+	errUnsupportedLatencyDistribution = errors.New("unsupported latency distribution, must be one of 'fixed', 'uniform', 'normal' or 'exponential'")
+	errInvalidLatencyDistribution     = errors.New("invalid latency distribution parameters")
+)
+
+// New will return a fault injector wrapper around the processor on input,
+// injecting the faults described by the spec.
+func New(p processor.Processor, spec FaultSpec, opts ...Option) (*FaultInjector, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+
+	seed := spec.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+
+	f := &FaultInjector{
+		logger:    loglib.NewNoopLogger(),
+		processor: p,
+		spec:      spec,
+		rand:      rand.New(rand.NewPCG(seed, seed)),
+		sleep:     sleepContext,
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(f *FaultInjector) {
+		f.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "fault_injector",
+		})
+	}
+}
+
+// ProcessWALEvent injects the configured faults, and sends the event to the
+// wrapped processor unless an error or a panic was injected. Events with
+// corrupted values are copied, so that the event on input is not modified.
+func (f *FaultInjector) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	if f.roll(f.spec.PanicRate) {
+		f.panics.Add(1)
+		f.logger.Debug("injecting panic", f.logFields(event))
+		panic(ErrInjectedPanic)
+	}
+
+	if f.spec.Latency != nil {
+		delay := f.latency()
+		f.delays.Add(1)
+		f.logger.Debug("injecting latency", f.logFields(event, loglib.Fields{"latency": delay.String()}))
+		if err := f.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+
+	if f.roll(f.spec.ErrorRate) {
+		f.errors.Add(1)
+		f.logger.Debug("injecting error", f.logFields(event))
+		return ErrInjectedFault
+	}
+
+	if event.Data != nil && len(event.Data.Columns) > 0 && f.roll(f.spec.CorruptionRate) {
+		f.corruptions.Add(1)
+		event = f.corrupt(event)
+		f.logger.Debug("injecting corrupted event", f.logFields(event))
+	}
+
+	return f.processor.ProcessWALEvent(ctx, event)
+}
+
+// Stats returns the number of faults injected so far.
+func (f *FaultInjector) Stats() Stats {
+	return Stats{
+		Errors:      f.errors.Load(),
+		Panics:      f.panics.Load(),
+		Corruptions: f.corruptions.Load(),
+		Delays:      f.delays.Load(),
+	}
+}
+
+func (f *FaultInjector) Name() string {
+	return f.processor.Name()
+}
+
+func (f *FaultInjector) Close() error {
+	return f.processor.Close()
+}
+
+func (f *FaultInjector) Ping(ctx context.Context) error {
+	return f.processor.Ping(ctx)
+}
+
+// roll returns true with the probability on input.
+func (f *FaultInjector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.randMutex.Lock()
+	defer f.randMutex.Unlock()
+	return f.rand.Float64() < rate
+}
+
+func (f *FaultInjector) latency() time.Duration {
+	f.randMutex.Lock()
+	defer f.randMutex.Unlock()
+
+	spec := f.spec.Latency
+	var delay float64
+	switch spec.Distribution {
+	case UniformLatency:
+		delay = float64(spec.Min) + f.rand.Float64()*float64(spec.Max-spec.Min)
+	case NormalLatency:
+		delay = float64(spec.Mean) + f.rand.NormFloat64()*float64(spec.StdDev)
+	case ExponentialLatency:
+		delay = f.rand.ExpFloat64() * float64(spec.Mean)
+	default:
+		delay = float64(spec.Mean)
+	}
+	return time.Duration(max(delay, 0))
+}
+
+// corrupt returns a copy of the event with the value of one of its columns
+// flipped.
+func (f *FaultInjector) corrupt(event *wal.Event) *wal.Event {
+	f.randMutex.Lock()
+	defer f.randMutex.Unlock()
+
+	data := *event.Data
+	data.Columns = make([]wal.Column, len(event.Data.Columns))
+	copy(data.Columns, event.Data.Columns)
+
+	i := f.rand.IntN(len(data.Columns))
+	data.Columns[i].Value = f.flipValue(data.Columns[i].Value)
+
+	corrupted := *event
+	corrupted.Data = &data
+	return &corrupted
+}
+
+// flipValue flips a random bit of the value on input. Values of unsupported
+// types are replaced by nil.
+func (f *FaultInjector) flipValue(value any) any {
+	switch v := value.(type) {
+	case bool:
+		return !v
+	case int:
+		return v ^ (1 << f.rand.IntN(63))
+	case int32:
+		return v ^ (1 << f.rand.IntN(31))
+	case int64:
+		return v ^ (1 << f.rand.IntN(63))
+	case float64:
+		// only flip mantissa bits, to avoid generating NaN or infinite values
+		return math.Float64frombits(math.Float64bits(v) ^ (1 << f.rand.IntN(52)))
+	case string:
+		if v == "" {
+			return "\x00"
+		}
+		return string(f.flipByte([]byte(v)))
+	case []byte:
+		if len(v) == 0 {
+			return []byte{0}
+		}
+		return f.flipByte(append([]byte(nil), v...))
+	case nil:
+		return "\x00"
+	default:
+		return nil
+	}
+}
+
+func (f *FaultInjector) flipByte(b []byte) []byte {
+	b[f.rand.IntN(len(b))] ^= 1 << f.rand.IntN(8)
+	return b
+}
+
+func (f *FaultInjector) logFields(event *wal.Event, extra ...loglib.Fields) loglib.Fields {
+	fields := loglib.Fields{
+		"commit_position": event.CommitPosition,
+	}
+	if event.Data != nil {
+		fields["schema"] = event.Data.Schema
+		fields["table"] = event.Data.Table
+		fields["action"] = event.Data.Action
+	}
+	for _, e := range extra {
+		for k, v := range e {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+func (s *FaultSpec) validate() error {
+	for name, rate := range map[string]float64{
+		"error":      s.ErrorRate,
+		"panic":      s.PanicRate,
+		"corruption": s.CorruptionRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%s rate %v: %w", name, rate, errInvalidRate)
+		}
+	}
+
+	if s.Latency == nil {
+		return nil
+	}
+	return s.Latency.validate()
+}
+
+func (s *LatencySpec) validate() error {
+	switch s.Distribution {
+	case "", FixedLatency, ExponentialLatency:
+		if s.Mean < 0 {
+			return fmt.Errorf("%w: mean latency cannot be negative", errInvalidLatencyDistribution)
+		}
+	case NormalLatency:
+		if s.Mean < 0 || s.StdDev < 0 {
+			return fmt.Errorf("%w: mean and standard deviation cannot be negative", errInvalidLatencyDistribution)
+		}
+	case UniformLatency:
+		if s.Min < 0 || s.Max < s.Min {
+			return fmt.Errorf("%w: min latency must be positive and lower than max latency", errInvalidLatencyDistribution)
+		}
+	default:
+		return errUnsupportedLatencyDistribution
+	}
+	return nil
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,312 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package fault
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+const testSeed = 42
+
+func testEvent() *wal.Event {
+	return &wal.Event{
+		Data: &wal.Data{
+			Action: "I",
+			Schema: "public",
+			Table:  "test",
+			Columns: []wal.Column{
+				{Name: "id", Value: int64(1)},
+				{Name: "name", Value: "alice"},
+			},
+		},
+		CommitPosition: "0/10",
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		spec FaultSpec
+
+		wantErr error
+	}{
+		{
+			name: "ok - all faults",
+			spec: FaultSpec{
+				ErrorRate:      0.1,
+				PanicRate:      0.01,
+				CorruptionRate: 1,
+				Latency:        &LatencySpec{Distribution: UniformLatency, Min: time.Millisecond, Max: time.Second},
+			},
+		},
+		{
+			name: "ok - no faults",
+			spec: FaultSpec{},
+		},
+		{
+			name:    "error - invalid error rate",
+			spec:    FaultSpec{ErrorRate: 1.5},
+			wantErr: errInvalidRate,
+		},
+		{
+			name:    "error - invalid corruption rate",
+			spec:    FaultSpec{CorruptionRate: -0.1},
+			wantErr: errInvalidRate,
+		},
+		{
+			name:    "error - unsupported latency distribution",
+			spec:    FaultSpec{Latency: &LatencySpec{Distribution: "pareto"}},
+			wantErr: errUnsupportedLatencyDistribution,
+		},
+		{
+			name:    "error - invalid uniform latency",
+			spec:    FaultSpec{Latency: &LatencySpec{Distribution: UniformLatency, Min: time.Second, Max: time.Millisecond}},
+			wantErr: errInvalidLatencyDistribution,
+		},
+		{
+			name:    "error - invalid normal latency",
+			spec:    FaultSpec{Latency: &LatencySpec{Distribution: NormalLatency, Mean: time.Second, StdDev: -time.Second}},
+			wantErr: errInvalidLatencyDistribution,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := New(&mocks.Processor{}, tc.spec)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestFaultInjector_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name      string
+		spec      FaultSpec
+		processor *mocks.Processor
+
+		wantErr       error
+		wantProcessed bool
+		wantStats     Stats
+	}{
+		{
+			name: "ok - no faults",
+			spec: FaultSpec{},
+			processor: &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					require.Equal(t, testEvent(), walEvent)
+					return nil
+				},
+			},
+			wantProcessed: true,
+		},
+		{
+			name: "ok - injected corruption",
+			spec: FaultSpec{CorruptionRate: 1},
+			processor: &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					require.NotEqual(t, testEvent().Data.Columns, walEvent.Data.Columns)
+					require.Equal(t, testEvent().CommitPosition, walEvent.CommitPosition)
+					return nil
+				},
+			},
+			wantProcessed: true,
+			wantStats:     Stats{Corruptions: 1},
+		},
+		{
+			name: "error - injected fault",
+			spec: FaultSpec{ErrorRate: 1},
+			processor: &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					return errors.New("unexpected call to ProcessWALEventFn")
+				},
+			},
+			wantErr:   ErrInjectedFault,
+			wantStats: Stats{Errors: 1},
+		},
+		{
+			name: "error - wrapped processor error",
+			spec: FaultSpec{},
+			processor: &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					return errTest
+				},
+			},
+			wantErr:       errTest,
+			wantProcessed: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := New(tc.processor, tc.spec)
+			require.NoError(t, err)
+
+			event := testEvent()
+			err = f.ProcessWALEvent(context.Background(), event)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantProcessed, tc.processor.GetProcessCalls() == 1)
+			require.Equal(t, tc.wantStats, f.Stats())
+			// the event on input is never modified
+			require.Equal(t, testEvent(), event)
+		})
+	}
+}
+
+func TestFaultInjector_ProcessWALEvent_panic(t *testing.T) {
+	t.Parallel()
+
+	processor := &mocks.Processor{}
+	f, err := New(processor, FaultSpec{PanicRate: 1})
+	require.NoError(t, err)
+
+	require.PanicsWithValue(t, ErrInjectedPanic, func() {
+		_ = f.ProcessWALEvent(context.Background(), testEvent())
+	})
+	require.Equal(t, Stats{Panics: 1}, f.Stats())
+	require.Zero(t, processor.GetProcessCalls())
+}
+
+func TestFaultInjector_ProcessWALEvent_errorRate(t *testing.T) {
+	t.Parallel()
+
+	f, err := New(&mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error { return nil },
+	}, FaultSpec{ErrorRate: 0.3, Seed: testSeed})
+	require.NoError(t, err)
+
+	const events = 1000
+	failed := 0
+	for range events {
+		if err := f.ProcessWALEvent(context.Background(), testEvent()); err != nil {
+			require.ErrorIs(t, err, ErrInjectedFault)
+			failed++
+		}
+	}
+	require.InDelta(t, 0.3*events, failed, 0.05*events)
+	require.Equal(t, uint64(failed), f.Stats().Errors)
+}
+
+func TestFaultInjector_ProcessWALEvent_latency(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		latency LatencySpec
+
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{
+			name:    "fixed",
+			latency: LatencySpec{Mean: 10 * time.Millisecond},
+			wantMin: 10 * time.Millisecond,
+			wantMax: 10 * time.Millisecond,
+		},
+		{
+			name:    "uniform",
+			latency: LatencySpec{Distribution: UniformLatency, Min: 5 * time.Millisecond, Max: 20 * time.Millisecond},
+			wantMin: 5 * time.Millisecond,
+			wantMax: 20 * time.Millisecond,
+		},
+		{
+			name:    "normal",
+			latency: LatencySpec{Distribution: NormalLatency, Mean: 10 * time.Millisecond, StdDev: 100 * time.Millisecond},
+			wantMin: 0,
+			wantMax: time.Second,
+		},
+		{
+			name:    "exponential",
+			latency: LatencySpec{Distribution: ExponentialLatency, Mean: 10 * time.Millisecond},
+			wantMin: 0,
+			wantMax: time.Second,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f, err := New(&mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error { return nil },
+			}, FaultSpec{Latency: &tc.latency, Seed: testSeed})
+			require.NoError(t, err)
+
+			delays := []time.Duration{}
+			f.sleep = func(_ context.Context, d time.Duration) error {
+				delays = append(delays, d)
+				return nil
+			}
+
+			for range 100 {
+				require.NoError(t, f.ProcessWALEvent(context.Background(), testEvent()))
+			}
+			require.Len(t, delays, 100)
+			for _, d := range delays {
+				require.GreaterOrEqual(t, d, tc.wantMin)
+				require.LessOrEqual(t, d, tc.wantMax)
+			}
+			require.Equal(t, uint64(100), f.Stats().Delays)
+		})
+	}
+}
+
+func TestFaultInjector_ProcessWALEvent_latencyCancelled(t *testing.T) {
+	t.Parallel()
+
+	processor := &mocks.Processor{}
+	f, err := New(processor, FaultSpec{Latency: &LatencySpec{Mean: time.Minute}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = f.ProcessWALEvent(ctx, testEvent())
+	require.ErrorIs(t, err, context.Canceled)
+	require.Zero(t, processor.GetProcessCalls())
+}
+
+func TestFaultInjector_flipValue(t *testing.T) {
+	t.Parallel()
+
+	f, err := New(&mocks.Processor{}, FaultSpec{Seed: testSeed})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{name: "bool", value: true},
+		{name: "int", value: 10},
+		{name: "int32", value: int32(10)},
+		{name: "int64", value: int64(10)},
+		{name: "float64", value: 10.5},
+		{name: "string", value: "alice"},
+		{name: "empty string", value: ""},
+		{name: "bytes", value: []byte("alice")},
+		{name: "nil", value: nil},
+		{name: "unsupported type", value: time.Now()},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NotEqual(t, tc.value, f.flipValue(tc.value))
+		})
+	}
+}
@@ -0,0 +1,429 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package harness provides end to end tests that validate the behaviour of
+// pgstream against a live postgres database.
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	pgcheckpoint "github.com/xataio/pgstream/pkg/wal/checkpointer/postgres"
+	pglistener "github.com/xataio/pgstream/pkg/wal/listener/postgres"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// DisconnectRecoveryConfig describes the disconnect recovery test. The test
+// table and replication slot are created by the test and dropped once it's
+// done, so they must not be used by anything else.
+type DisconnectRecoveryConfig struct {
+	// PostgresURL of the source database. The user needs the replication
+	// role, and permissions to create tables and terminate backends.
+	PostgresURL string
+	// Rows is the number of rows inserted, one per transaction. Defaults to
+	// 1000.
+	Rows int
+	// DisconnectAfter is the number of events delivered before the
+	// replication connection is killed. Defaults to half of the rows.
+	DisconnectAfter int
+	// Table is the schema qualified test table. Defaults to
+	// public.pgstream_disconnect_recovery_test.
+	Table string
+	// ReplicationSlotName is the name of the test replication slot. Defaults
+	// to pgstream_disconnect_recovery_test_slot.
+	ReplicationSlotName string
+	// Timeout is the max duration of the test. Defaults to 5m.
+	Timeout time.Duration
+}
+
+// DisconnectRecoveryResult contains the event counts of the disconnect
+// recovery test.
+type DisconnectRecoveryResult struct {
+	// Inserted is the number of rows inserted in the test table.
+	Inserted int `json:"inserted"`
+	// Delivered is the number of insert events delivered to the sink,
+	// including the duplicates.
+	Delivered int `json:"delivered"`
+	// Missing is the number of inserted rows never delivered to the sink.
+	Missing int `json:"missing"`
+	// Duplicates is the number of insert events delivered more than once.
+	Duplicates int `json:"duplicates"`
+	// DeliveredBeforeDisconnect is the number of insert events delivered
+	// when the replication connection was killed.
+	DeliveredBeforeDisconnect int `json:"delivered_before_disconnect"`
+}
+
+const (
+	defaultDisconnectRecoveryRows    = 1000
+	defaultDisconnectRecoveryTable   = "public.pgstream_disconnect_recovery_test"
+	defaultDisconnectRecoverySlot    = "pgstream_disconnect_recovery_test_slot"
+	defaultDisconnectRecoveryTimeout = 5 * time.Minute
+
+	// settleInterval is the time the sink keeps receiving events after all
+	// the rows have been delivered, to detect late duplicates
+	settleInterval = 2 * time.Second
+	pollInterval   = 100 * time.Millisecond
+)
+
+var (
+	errInvalidRows            = errors.New("disconnect recovery test requires at least 2 rows")
+	errInvalidDisconnectAfter = errors.New("disconnect recovery test requires disconnecting after at least 1 and less than the total rows")
+	errMissingPostgresURL     = errors.New("disconnect recovery test requires a postgres URL")
+	errDisconnectTimeout      = errors.New("timed out waiting for the events before the disconnect")
+	errNoActiveConnection     = errors.New("no active replication connection to kill")
+)
+
+// Passed returns true if all the inserted rows were delivered exactly once.
+func (r *DisconnectRecoveryResult) Passed() bool {
+	return r.Inserted > 0 && r.Missing == 0 && r.Duplicates == 0
+}
+
+func (r *DisconnectRecoveryResult) PrettyPrint() string {
+	if r == nil {
+		return ""
+	}
+
+	var prettyPrint strings.Builder
+	prettyPrint.WriteString("Disconnect recovery test:\n")
+	prettyPrint.WriteString(fmt.Sprintf(" - Inserted rows: %d\n", r.Inserted))
+	prettyPrint.WriteString(fmt.Sprintf(" - Delivered events: %d\n", r.Delivered))
+	prettyPrint.WriteString(fmt.Sprintf(" - Delivered before disconnect: %d\n", r.DeliveredBeforeDisconnect))
+	prettyPrint.WriteString(fmt.Sprintf(" - Missing events: %d\n", r.Missing))
+	prettyPrint.WriteString(fmt.Sprintf(" - Duplicate events: %d", r.Duplicates))
+	return prettyPrint.String()
+}
+
+func (c *DisconnectRecoveryConfig) rows() int {
+	if c.Rows > 0 {
+		return c.Rows
+	}
+	return defaultDisconnectRecoveryRows
+}
+
+func (c *DisconnectRecoveryConfig) disconnectAfter() int {
+	if c.DisconnectAfter > 0 {
+		return c.DisconnectAfter
+	}
+	return c.rows() / 2
+}
+
+func (c *DisconnectRecoveryConfig) table() string {
+	if c.Table != "" {
+		return c.Table
+	}
+	return defaultDisconnectRecoveryTable
+}
+
+func (c *DisconnectRecoveryConfig) replicationSlotName() string {
+	if c.ReplicationSlotName != "" {
+		return c.ReplicationSlotName
+	}
+	return defaultDisconnectRecoverySlot
+}
+
+func (c *DisconnectRecoveryConfig) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return defaultDisconnectRecoveryTimeout
+}
+
+func (c *DisconnectRecoveryConfig) validate() error {
+	switch {
+	case c.PostgresURL == "":
+		return errMissingPostgresURL
+	case c.rows() < 2:
+		return errInvalidRows
+	case c.disconnectAfter() < 1 || c.disconnectAfter() >= c.rows():
+		return errInvalidDisconnectAfter
+	}
+	return nil
+}
+
+// DisconnectRecoveryTest validates that the replication resumes from the last
+// checkpoint after a network disconnect, without event gaps or duplicates. It
+// inserts the configured rows while replicating them to an in memory sink,
+// kills the replication connection midway, and restarts the replication from
+// the slot to deliver the rest.
+type DisconnectRecoveryTest struct {
+	logger  loglib.Logger
+	config  DisconnectRecoveryConfig
+	querier pglib.Querier
+	// table is the quoted test table, with its schema and name
+	table     string
+	schema    string
+	tableName string
+}
+
+type Option func(t *DisconnectRecoveryTest)
+
+// NewDisconnectRecoveryTest returns a disconnect recovery test for the
+// configuration on input.
+func NewDisconnectRecoveryTest(ctx context.Context, cfg DisconnectRecoveryConfig, opts ...Option) (*DisconnectRecoveryTest, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	qn, err := pglib.NewQualifiedName(cfg.table())
+	if err != nil {
+		return nil, fmt.Errorf("test table %q: %w", cfg.table(), err)
+	}
+	schema := qn.Schema()
+	if schema == "" {
+		schema = "public"
+	}
+
+	querier, err := pglib.NewConnPool(ctx, cfg.PostgresURL)
+	if err != nil {
+		return nil, fmt.Errorf("creating postgres connection: %w", err)
+	}
+
+	t := &DisconnectRecoveryTest{
+		logger:    loglib.NewNoopLogger(),
+		config:    cfg,
+		querier:   querier,
+		table:     pglib.QuoteQualifiedIdentifier(schema, qn.Name()),
+		schema:    schema,
+		tableName: qn.Name(),
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(t *DisconnectRecoveryTest) {
+		t.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "disconnect_recovery_test",
+		})
+	}
+}
+
+// Run runs the test and returns the event counts. An error is only returned
+// if the test could not be completed, the result must be checked to know if
+// it passed.
+func (t *DisconnectRecoveryTest) Run(ctx context.Context) (*DisconnectRecoveryResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.config.timeout())
+	defer cancel()
+
+	if err := t.setup(ctx); err != nil {
+		return nil, err
+	}
+	defer t.teardown()
+
+	sink := newRecordingSink(t.schema, t.tableName)
+
+	first, err := t.startReplication(ctx, sink)
+	if err != nil {
+		return nil, err
+	}
+
+	// the inserts run concurrently with the replication, so that the
+	// connection is killed mid-stream
+	insertCtx, cancelInserts := context.WithCancel(ctx)
+	insertDone := make(chan struct{})
+	var insertErr error
+	go func() {
+		defer close(insertDone)
+		insertErr = t.insertRows(insertCtx)
+	}()
+	defer func() {
+		cancelInserts()
+		<-insertDone
+	}()
+
+	disconnectAfter := t.config.disconnectAfter()
+	if err := waitFor(ctx, func() bool { return sink.delivered() >= disconnectAfter }); err != nil {
+		first.stop()
+		select {
+		case <-insertDone:
+			if insertErr != nil {
+				return nil, insertErr
+			}
+		default:
+		}
+		return nil, errDisconnectTimeout
+	}
+	result := &DisconnectRecoveryResult{DeliveredBeforeDisconnect: sink.delivered()}
+	t.logger.Info("killing replication connection", loglib.Fields{"delivered_events": result.DeliveredBeforeDisconnect})
+	if err := t.killReplicationConnection(ctx); err != nil {
+		first.stop()
+		return nil, err
+	}
+	if err := first.wait(); err != nil {
+		t.logger.Info("replication stopped after killing its connection", loglib.Fields{"error": err.Error()})
+	}
+	first.stop()
+
+	t.logger.Info("restarting replication")
+	second, err := t.startReplication(ctx, sink)
+	if err != nil {
+		return nil, err
+	}
+	defer second.stop()
+
+	<-insertDone
+	if insertErr != nil {
+		return nil, insertErr
+	}
+	rows := t.config.rows()
+	// the missing rows are reported on timeout, so the wait error is ignored
+	_ = waitFor(ctx, func() bool { return sink.unique() >= rows })
+	select {
+	case <-ctx.Done():
+	case <-time.After(settleInterval):
+	}
+
+	result.Inserted = rows
+	result.Delivered = sink.delivered()
+	result.Duplicates = sink.delivered() - sink.unique()
+	result.Missing = rows - sink.unique()
+	return result, nil
+}
+
+// Close releases the postgres connection of the test.
+func (t *DisconnectRecoveryTest) Close() error {
+	return t.querier.Close(context.Background())
+}
+
+func (t *DisconnectRecoveryTest) setup(ctx context.Context) error {
+	if _, err := t.querier.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", t.table)); err != nil {
+		return fmt.Errorf("dropping previous test table: %w", err)
+	}
+	if _, err := t.querier.Exec(ctx, fmt.Sprintf("CREATE TABLE %s (id bigint PRIMARY KEY)", t.table)); err != nil {
+		return fmt.Errorf("creating test table: %w", err)
+	}
+	if err := t.dropReplicationSlot(ctx); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *DisconnectRecoveryTest) teardown() {
+	ctx := context.Background()
+	if err := t.dropReplicationSlot(ctx); err != nil {
+		t.logger.Error(err, "dropping test replication slot")
+	}
+	if _, err := t.querier.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", t.table)); err != nil {
+		t.logger.Error(err, "dropping test table")
+	}
+}
+
+func (t *DisconnectRecoveryTest) dropReplicationSlot(ctx context.Context) error {
+	_, err := t.querier.Exec(ctx, "SELECT pg_drop_replication_slot(slot_name) FROM pg_replication_slots WHERE slot_name = $1", t.config.replicationSlotName())
+	if err != nil {
+		return fmt.Errorf("dropping test replication slot: %w", err)
+	}
+	return nil
+}
+
+func (t *DisconnectRecoveryTest) insertRows(ctx context.Context) error {
+	for i := 1; i <= t.config.rows(); i++ {
+		if _, err := t.querier.Exec(ctx, fmt.Sprintf("INSERT INTO %s (id) VALUES ($1)", t.table), i); err != nil {
+			return fmt.Errorf("inserting test row %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// killReplicationConnection terminates the backend serving the replication
+// connection of the test slot, as a network disconnect would.
+func (t *DisconnectRecoveryTest) killReplicationConnection(ctx context.Context) error {
+	tag, err := t.querier.Exec(ctx, "SELECT pg_terminate_backend(active_pid) FROM pg_replication_slots WHERE slot_name = $1 AND active_pid IS NOT NULL", t.config.replicationSlotName())
+	if err != nil {
+		return fmt.Errorf("killing replication connection: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return errNoActiveConnection
+	}
+	return nil
+}
+
+// replicationRun is a running replication to the recording sink.
+type replicationRun struct {
+	handler *pgreplication.Handler
+	cancel  context.CancelFunc
+	// err is the error the replication stopped with, set before done is
+	// closed
+	err  error
+	done chan struct{}
+}
+
+// startReplication starts replicating the test table to the sink on input,
+// resuming from the test slot position. The sink checkpoints every event to
+// the slot, as the pgstream processors do once the events are delivered.
+func (t *DisconnectRecoveryTest) startReplication(ctx context.Context, sink *recordingSink) (*replicationRun, error) {
+	handler, err := pgreplication.NewHandler(ctx, pgreplication.Config{
+		PostgresURL:         t.config.PostgresURL,
+		ReplicationSlotName: t.config.replicationSlotName(),
+		IncludeTables:       []string{t.schema + "." + t.tableName},
+	}, pgreplication.WithLogger(t.logger))
+	if err != nil {
+		return nil, fmt.Errorf("creating replication handler: %w", err)
+	}
+	sink.setCheckpoint(pgcheckpoint.New(handler).SyncLSN)
+
+	listener := pglistener.New(handler, sink.ProcessWALEvent, pglistener.WithLogger(t.logger))
+	runCtx, cancel := context.WithCancel(ctx)
+	run := &replicationRun{
+		handler: handler,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(run.done)
+		run.err = t.listen(runCtx, listener)
+	}()
+	return run, nil
+}
+
+// listen starts the listener, retrying while the slot is still in use by the
+// backend of a killed connection.
+func (t *DisconnectRecoveryTest) listen(ctx context.Context, listener *pglistener.Listener) error {
+	for {
+		err := listener.Listen(ctx)
+		if !errors.Is(err, replication.ErrSlotInUse) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// wait returns the error the replication stopped with, once it stops.
+func (r *replicationRun) wait() error {
+	<-r.done
+	return r.err
+}
+
+// stop stops the replication and closes its connection.
+func (r *replicationRun) stop() {
+	r.cancel()
+	<-r.done
+	r.handler.Close()
+}
+
+func waitFor(ctx context.Context, condition func() bool) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for !condition() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package harness
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisconnectRecoveryConfig_validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config DisconnectRecoveryConfig
+
+		wantErr error
+	}{
+		{
+			name:    "ok - defaults",
+			config:  DisconnectRecoveryConfig{PostgresURL: "postgres://"},
+			wantErr: nil,
+		},
+		{
+			name:    "ok - disconnect after",
+			config:  DisconnectRecoveryConfig{PostgresURL: "postgres://", Rows: 10, DisconnectAfter: 9},
+			wantErr: nil,
+		},
+		{
+			name:    "error - missing postgres url",
+			config:  DisconnectRecoveryConfig{},
+			wantErr: errMissingPostgresURL,
+		},
+		{
+			name:    "error - invalid rows",
+			config:  DisconnectRecoveryConfig{PostgresURL: "postgres://", Rows: 1},
+			wantErr: errInvalidRows,
+		},
+		{
+			name:    "error - disconnect after all rows",
+			config:  DisconnectRecoveryConfig{PostgresURL: "postgres://", Rows: 10, DisconnectAfter: 10},
+			wantErr: errInvalidDisconnectAfter,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.ErrorIs(t, tc.config.validate(), tc.wantErr)
+		})
+	}
+}
+
+func TestDisconnectRecoveryResult_Passed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		result DisconnectRecoveryResult
+
+		wantPassed bool
+	}{
+		{
+			name:       "passed",
+			result:     DisconnectRecoveryResult{Inserted: 10, Delivered: 10},
+			wantPassed: true,
+		},
+		{
+			name:       "failed - missing events",
+			result:     DisconnectRecoveryResult{Inserted: 10, Delivered: 9, Missing: 1},
+			wantPassed: false,
+		},
+		{
+			name:       "failed - duplicate events",
+			result:     DisconnectRecoveryResult{Inserted: 10, Delivered: 11, Duplicates: 1},
+			wantPassed: false,
+		},
+		{
+			name:       "failed - no events",
+			result:     DisconnectRecoveryResult{},
+			wantPassed: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantPassed, tc.result.Passed())
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package harness
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+)
+
+// recordingSink keeps count of the insert events delivered for each id of the
+// test table, and checkpoints every event once recorded.
+type recordingSink struct {
+	schema string
+	table  string
+
+	mutex      sync.Mutex
+	checkpoint checkpointer.Checkpoint
+	counts     map[string]int
+	total      int
+}
+
+const idColumn = "id"
+
+func newRecordingSink(schema, table string) *recordingSink {
+	return &recordingSink{
+		schema: schema,
+		table:  table,
+		counts: map[string]int{},
+	}
+}
+
+func (s *recordingSink) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if id, ok := s.insertedID(event.Data); ok {
+		s.counts[id]++
+		s.total++
+	}
+
+	if s.checkpoint == nil || event.CommitPosition == "" {
+		return nil
+	}
+	return s.checkpoint(ctx, []wal.CommitPosition{event.CommitPosition})
+}
+
+// setCheckpoint configures the checkpoint of the replication the events are
+// delivered from.
+func (s *recordingSink) setCheckpoint(checkpoint checkpointer.Checkpoint) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.checkpoint = checkpoint
+}
+
+// insertedID returns the id of the test table row inserted by the event on
+// input, and false if it's not an insert of the test table.
+func (s *recordingSink) insertedID(data *wal.Data) (string, bool) {
+	if data == nil || wal.Action(data.Action) != wal.ActionInsert || data.Schema != s.schema || data.Table != s.table {
+		return "", false
+	}
+	for _, column := range data.Columns {
+		if column.Name == idColumn {
+			return fmt.Sprint(column.Value), true
+		}
+	}
+	return "", false
+}
+
+// delivered returns the number of insert events delivered, including the
+// duplicates.
+func (s *recordingSink) delivered() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.total
+}
+
+// unique returns the number of distinct rows delivered.
+func (s *recordingSink) unique() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.counts)
+}
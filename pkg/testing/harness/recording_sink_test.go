@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package harness
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestRecordingSink_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	newEvent := func(action, table string, id any, position wal.CommitPosition) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action:  action,
+				Schema:  "public",
+				Table:   table,
+				Columns: []wal.Column{{Name: "id", Value: id}},
+			},
+			CommitPosition: position,
+		}
+	}
+
+	sink := newRecordingSink("public", "test")
+	checkpointed := []wal.CommitPosition{}
+	sink.setCheckpoint(func(ctx context.Context, positions []wal.CommitPosition) error {
+		checkpointed = append(checkpointed, positions...)
+		return nil
+	})
+
+	ctx := context.Background()
+	require.NoError(t, sink.ProcessWALEvent(ctx, newEvent("I", "test", float64(1), "0/10")))
+	require.NoError(t, sink.ProcessWALEvent(ctx, newEvent("I", "test", float64(2), "0/20")))
+	// duplicate
+	require.NoError(t, sink.ProcessWALEvent(ctx, newEvent("I", "test", float64(2), "0/20")))
+	// not inserts of the test table
+	require.NoError(t, sink.ProcessWALEvent(ctx, newEvent("U", "test", float64(3), "0/30")))
+	require.NoError(t, sink.ProcessWALEvent(ctx, newEvent("I", "other", float64(4), "0/40")))
+	// keep alive
+	require.NoError(t, sink.ProcessWALEvent(ctx, &wal.Event{CommitPosition: "0/50"}))
+
+	require.Equal(t, 3, sink.delivered())
+	require.Equal(t, 2, sink.unique())
+	require.Equal(t, []wal.CommitPosition{"0/10", "0/20", "0/20", "0/30", "0/40", "0/50"}, checkpointed)
+
+	sink.setCheckpoint(func(ctx context.Context, positions []wal.CommitPosition) error {
+		return errTest
+	})
+	require.ErrorIs(t, sink.ProcessWALEvent(ctx, newEvent("I", "test", float64(5), "0/60")), errTest)
+}
@@ -0,0 +1,164 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/xataio/pgstream/internal/json"
+)
+
+// ArrayTransformer applies a scalar transformer to each of the elements of an
+// array value, such as the ones of a `text[]` column. The array can be provided
+// as a postgres array literal (`{a,b,NULL}`), as a json array (`["a","b"]`), or
+// as a slice of values, and the transformed array is returned in the same
+// representation. NULL elements are left untouched. Multidimensional arrays
+// are not supported.
+type ArrayTransformer struct {
+	Transformer
+	pgMap *pgtype.Map
+}
+
+var ErrNestedArray = errors.New("array transformer: nested arrays are not supported")
+
+// NewArrayTransformer returns an array transformer that applies the
+// transformer on input to each of the array elements.
+func NewArrayTransformer(t Transformer) *ArrayTransformer {
+	return &ArrayTransformer{
+		Transformer: t,
+		pgMap:       pgtype.NewMap(),
+	}
+}
+
+func (t *ArrayTransformer) Transform(ctx context.Context, value Value) (any, error) {
+	elementType := ArrayElementType(value.TransformType)
+	switch val := value.TransformValue.(type) {
+	case string:
+		transformed, err := t.transformText(ctx, []byte(val), elementType, value.DynamicValues)
+		if err != nil {
+			return nil, err
+		}
+		return string(transformed), nil
+	case []byte:
+		return t.transformText(ctx, val, elementType, value.DynamicValues)
+	case []any:
+		return t.transformElements(ctx, val, elementType, value.DynamicValues)
+	case []string:
+		elements := make([]any, 0, len(val))
+		for _, v := range val {
+			elements = append(elements, v)
+		}
+		return t.transformElements(ctx, elements, elementType, value.DynamicValues)
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+}
+
+// ReferencedColumns returns the columns referenced by the wrapped transformer.
+func (t *ArrayTransformer) ReferencedColumns() []string {
+	if referencer, ok := t.Transformer.(ColumnReferencer); ok {
+		return referencer.ReferencedColumns()
+	}
+	return nil
+}
+
+// IsArrayType returns true if the postgres type name on input is an array
+// type, either in its formatted (`text[]`) or internal (`_text`) form.
+func IsArrayType(typeName string) bool {
+	return strings.HasSuffix(typeName, "[]") || strings.HasPrefix(typeName, "_")
+}
+
+// ArrayElementType returns the type name of the elements of the postgres array
+// type name on input.
+func ArrayElementType(typeName string) string {
+	if strings.HasSuffix(typeName, "[]") {
+		return strings.TrimSuffix(typeName, "[]")
+	}
+	return strings.TrimPrefix(typeName, "_")
+}
+
+// transformText transforms the array text representation on input, which can
+// be either a json array or a postgres array literal.
+func (t *ArrayTransformer) transformText(ctx context.Context, text []byte, elementType string, dynamicValues map[string]any) ([]byte, error) {
+	if trimmed := bytes.TrimSpace(text); len(trimmed) > 0 && trimmed[0] == '[' {
+		var elements []any
+		if err := json.Unmarshal(trimmed, &elements); err != nil {
+			return nil, fmt.Errorf("array transformer: parsing json array: %w", err)
+		}
+		transformed, err := t.transformElements(ctx, elements, elementType, dynamicValues)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(transformed)
+	}
+
+	var array pgtype.Array[*string]
+	if err := t.pgMap.Scan(pgtype.TextArrayOID, pgtype.TextFormatCode, text, &array); err != nil {
+		return nil, fmt.Errorf("array transformer: parsing array literal: %w", err)
+	}
+	if len(array.Dims) > 1 {
+		return nil, ErrNestedArray
+	}
+
+	for i, element := range array.Elements {
+		if element == nil {
+			continue
+		}
+		transformed, err := t.transformElement(ctx, *element, elementType, dynamicValues)
+		if err != nil {
+			return nil, fmt.Errorf("array transformer: element[%d]: %w", i, err)
+		}
+		array.Elements[i] = elementToText(transformed)
+	}
+
+	encoded, err := t.pgMap.Encode(pgtype.TextArrayOID, pgtype.TextFormatCode, array, nil)
+	if err != nil {
+		return nil, fmt.Errorf("array transformer: encoding array literal: %w", err)
+	}
+	return encoded, nil
+}
+
+func (t *ArrayTransformer) transformElements(ctx context.Context, elements []any, elementType string, dynamicValues map[string]any) ([]any, error) {
+	transformed := make([]any, len(elements))
+	for i, element := range elements {
+		if element == nil {
+			continue
+		}
+		v, err := t.transformElement(ctx, element, elementType, dynamicValues)
+		if err != nil {
+			return nil, fmt.Errorf("array transformer: element[%d]: %w", i, err)
+		}
+		transformed[i] = v
+	}
+	return transformed, nil
+}
+
+func (t *ArrayTransformer) transformElement(ctx context.Context, element any, elementType string, dynamicValues map[string]any) (any, error) {
+	switch element.(type) {
+	case []any, []string:
+		return nil, ErrNestedArray
+	}
+	return t.Transformer.Transform(ctx, NewValue(element, elementType, dynamicValues))
+}
+
+// elementToText returns the text representation of the transformed element,
+// to be encoded in a postgres array literal.
+func elementToText(element any) *string {
+	var text string
+	switch v := element.(type) {
+	case nil:
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		text = fmt.Sprint(v)
+	}
+	return &text
+}
@@ -0,0 +1,169 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// upperTransformer is a minimal scalar transformer used to test the array
+// wrapper without depending on the generated values of other transformers.
+type upperTransformer struct {
+	errOn string
+}
+
+func (t *upperTransformer) Transform(_ context.Context, v Value) (any, error) {
+	s, ok := v.TransformValue.(string)
+	if !ok {
+		return nil, ErrUnsupportedValueType
+	}
+	if t.errOn != "" && s == t.errOn {
+		return nil, errors.New("oh noes")
+	}
+	return strings.ToUpper(s), nil
+}
+
+func (t *upperTransformer) IsDynamic() bool { return false }
+func (t *upperTransformer) CompatibleTypes() []SupportedDataType {
+	return []SupportedDataType{StringDataType}
+}
+func (t *upperTransformer) Type() TransformerType { return String }
+func (t *upperTransformer) Close() error          { return nil }
+
+func TestArrayTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value any
+		inner Transformer
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "ok - array literal",
+			value:     `{alice@example.com,bob@example.com}`,
+			wantValue: `{ALICE@EXAMPLE.COM,BOB@EXAMPLE.COM}`,
+		},
+		{
+			name:      "ok - array literal with quoted and null elements",
+			value:     `{"a b",NULL,"c,d","e\"f"}`,
+			wantValue: `{A B,NULL,"C,D","E\"F"}`,
+		},
+		{
+			name:      "ok - empty array literal",
+			value:     `{}`,
+			wantValue: `{}`,
+		},
+		{
+			name:      "ok - array literal bytes",
+			value:     []byte(`{a,b}`),
+			wantValue: []byte(`{A,B}`),
+		},
+		{
+			name:      "ok - json array",
+			value:     `["a",null,"b"]`,
+			wantValue: `["A",null,"B"]`,
+		},
+		{
+			name:      "ok - empty json array",
+			value:     `[]`,
+			wantValue: `[]`,
+		},
+		{
+			name:      "ok - slice",
+			value:     []any{"a", nil, "b"},
+			wantValue: []any{"A", nil, "B"},
+		},
+		{
+			name:      "ok - string slice",
+			value:     []string{"a", "b"},
+			wantValue: []any{"A", "B"},
+		},
+		{
+			name:    "error - nested array literal",
+			value:   `{{a,b},{c,d}}`,
+			wantErr: ErrNestedArray,
+		},
+		{
+			name:    "error - nested json array",
+			value:   `[["a"],["b"]]`,
+			wantErr: ErrNestedArray,
+		},
+		{
+			name:    "error - nested slice",
+			value:   []any{[]any{"a"}},
+			wantErr: ErrNestedArray,
+		},
+		{
+			name:    "error - invalid array literal",
+			value:   `{a,b`,
+			wantErr: errors.New("array transformer: parsing array literal"),
+		},
+		{
+			name:    "error - transforming element",
+			value:   `{a,b}`,
+			inner:   &upperTransformer{errOn: "b"},
+			wantErr: errors.New("array transformer: element[1]: oh noes"),
+		},
+		{
+			name:    "error - unsupported value type",
+			value:   1,
+			wantErr: ErrUnsupportedValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			inner := tc.inner
+			if inner == nil {
+				inner = &upperTransformer{}
+			}
+			transformer := NewArrayTransformer(inner)
+
+			got, err := transformer.Transform(context.Background(), NewValue(tc.value, "text[]", nil))
+			if tc.wantErr != nil {
+				require.Error(t, err)
+				if !errors.Is(err, tc.wantErr) {
+					require.Contains(t, err.Error(), tc.wantErr.Error())
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantValue, got)
+		})
+	}
+}
+
+func TestArrayElementType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		typeName string
+
+		wantArray       bool
+		wantElementType string
+	}{
+		{typeName: "text[]", wantArray: true, wantElementType: "text"},
+		{typeName: "character varying[]", wantArray: true, wantElementType: "character varying"},
+		{typeName: "_text", wantArray: true, wantElementType: "text"},
+		{typeName: "text", wantArray: false, wantElementType: "text"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.typeName, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantArray, IsArrayType(tc.typeName))
+			require.Equal(t, tc.wantElementType, ArrayElementType(tc.typeName))
+		})
+	}
+}
@@ -5,6 +5,7 @@ package builder
 import (
 	"fmt"
 
+	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/transformers"
 	"github.com/xataio/pgstream/pkg/transformers/greenmask"
@@ -14,12 +15,15 @@ import (
 
 type TransformerBuilder struct {
 	instrumentation *otel.Instrumentation
+	logger          loglib.Logger
 }
 
 type Option func(b *TransformerBuilder)
 
 func NewTransformerBuilder(opts ...Option) *TransformerBuilder {
-	b := &TransformerBuilder{}
+	b := &TransformerBuilder{
+		logger: loglib.NewNoopLogger(),
+	}
 	for _, opt := range opts {
 		opt(b)
 	}
@@ -32,6 +36,14 @@ func WithInstrumentation(i *otel.Instrumentation) Option {
 	}
 }
 
+// WithLogger sets the logger of the transformers that report background
+// errors, such as the lookup transformer reloads.
+func WithLogger(l loglib.Logger) Option {
+	return func(b *TransformerBuilder) {
+		b.logger = l
+	}
+}
+
 var TransformersMap = map[transformers.TransformerType]struct {
 	Definition *transformers.Definition
 	BuildFn    func(cfg *transformers.Config) (transformers.Transformer, error)
@@ -48,6 +60,24 @@ var TransformersMap = map[transformers.TransformerType]struct {
 			return transformers.NewEmailTransformer(cfg.Parameters)
 		},
 	},
+	transformers.MaskedEmail: {
+		Definition: transformers.MaskedEmailTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewMaskedEmailTransformer(cfg.Parameters)
+		},
+	},
+	transformers.MaskedPhone: {
+		Definition: transformers.MaskedPhoneTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewMaskedPhoneTransformer(cfg.Parameters, cfg.DynamicParameters)
+		},
+	},
+	transformers.DeterministicUUID: {
+		Definition: transformers.DeterministicUUIDTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewDeterministicUUIDTransformer(cfg.Parameters)
+		},
+	},
 	transformers.Template: {
 		Definition: transformers.TemplateTransformerDefinition(),
 		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
@@ -66,6 +96,66 @@ var TransformersMap = map[transformers.TransformerType]struct {
 			return transformers.NewHstoreTransformer(cfg.Parameters)
 		},
 	},
+	transformers.HMAC: {
+		Definition: transformers.HMACTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewHMACTransformer(cfg.Parameters)
+		},
+	},
+	transformers.DateShift: {
+		Definition: transformers.DateShiftTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewDateShiftTransformer(cfg.Parameters, cfg.DynamicParameters)
+		},
+	},
+	transformers.NumericNoise: {
+		Definition: transformers.NumericNoiseTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewNumericNoiseTransformer(cfg.Parameters, cfg.DynamicParameters)
+		},
+	},
+	transformers.Redact: {
+		Definition: transformers.RedactTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewRedactTransformer(cfg.Parameters, cfg.DynamicParameters)
+		},
+	},
+	transformers.RegexReplace: {
+		Definition: transformers.RegexReplaceTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewRegexReplaceTransformer(cfg.Parameters)
+		},
+	},
+	transformers.RowTemplate: {
+		Definition: transformers.RowTemplateTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewRowTemplateTransformer(cfg.Parameters)
+		},
+	},
+	transformers.Lookup: {
+		Definition: transformers.LookupTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewLookupTransformer(cfg.Parameters)
+		},
+	},
+	transformers.WASM: {
+		Definition: transformers.WASMTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewWASMTransformer(cfg.Parameters)
+		},
+	},
+	transformers.FakeData: {
+		Definition: transformers.FakeDataTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewFakeDataTransformer(cfg.Parameters, cfg.DynamicParameters)
+		},
+	},
+	transformers.FormatPreserving: {
+		Definition: transformers.FormatPreservingTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewFormatPreservingTransformer(cfg.Parameters, cfg.DynamicParameters)
+		},
+	},
 	transformers.PhoneNumber: {
 		Definition: transformers.PhoneNumberTransformerDefinition(),
 		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
@@ -191,6 +281,30 @@ func (b *TransformerBuilder) New(cfg *transformers.Config) (t transformers.Trans
 		}
 	}()
 
+	if cfg.Name == transformers.Lookup {
+		return transformers.NewLookupTransformer(cfg.Parameters, transformers.WithLookupLogger(b.logger))
+	}
+	return build(cfg)
+}
+
+func init() {
+	// the json path transformer builds its inner transformers from the
+	// transformers map, so it's registered here to avoid an initialisation
+	// cycle
+	TransformersMap[transformers.JSONPath] = struct {
+		Definition *transformers.Definition
+		BuildFn    func(cfg *transformers.Config) (transformers.Transformer, error)
+	}{
+		Definition: transformers.JSONPathTransformerDefinition(),
+		BuildFn: func(cfg *transformers.Config) (transformers.Transformer, error) {
+			return transformers.NewJSONPathTransformer(cfg.Parameters, build)
+		},
+	}
+}
+
+// build returns the transformer for the configuration on input, without
+// instrumentation.
+func build(cfg *transformers.Config) (transformers.Transformer, error) {
 	transformer, ok := TransformersMap[cfg.Name]
 	if !ok {
 		return nil, fmt.Errorf("%w: unexpected transformer name '%s'", transformers.ErrUnsupportedTransformer, cfg.Name)
@@ -39,6 +39,32 @@ func TestTransformerBuilder_New(t *testing.T) {
 			},
 			wantErr: transformers.ErrUnknownParameter,
 		},
+		{
+			name: "valid json path transformer",
+			config: &transformers.Config{
+				Name: transformers.JSONPath,
+				Parameters: map[string]any{"rules": []any{
+					map[string]any{
+						"selector":    "$.email",
+						"transformer": map[string]any{"name": "masking", "parameters": map[string]any{"type": "email"}},
+					},
+				}},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "invalid inner transformer parameter for json path transformer",
+			config: &transformers.Config{
+				Name: transformers.JSONPath,
+				Parameters: map[string]any{"rules": []any{
+					map[string]any{
+						"selector":    "$.email",
+						"transformer": map[string]any{"name": "masking", "parameters": map[string]any{"invalid": "param"}},
+					},
+				}},
+			},
+			wantErr: transformers.ErrUnknownParameter,
+		},
 		{
 			name: "unsupported transformer",
 			config: &transformers.Config{
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ChainTransformer applies a sequence of transformers to a value, in order.
+// Each stage receives the output of the previous one, along with the type and
+// dynamic values of the original column value. A null output ends the chain.
+type ChainTransformer struct {
+	stages []Transformer
+}
+
+var ErrEmptyChain = errors.New("chain transformer: at least one stage is required")
+
+// NewChainTransformer returns a transformer that applies the stages on input
+// in order.
+func NewChainTransformer(stages ...Transformer) (*ChainTransformer, error) {
+	if len(stages) == 0 {
+		return nil, ErrEmptyChain
+	}
+	return &ChainTransformer{
+		stages: stages,
+	}, nil
+}
+
+func (t *ChainTransformer) Transform(ctx context.Context, value Value) (any, error) {
+	current := value.TransformValue
+	for i, stage := range t.stages {
+		if current == nil {
+			return nil, nil
+		}
+		transformed, err := stage.Transform(ctx, NewValue(current, value.TransformType, value.DynamicValues))
+		if err != nil {
+			return nil, fmt.Errorf("chain[%d] (%s): %w", i, stage.Type(), err)
+		}
+		current = transformed
+	}
+	return current, nil
+}
+
+// Stages returns the transformers of the chain, in the order they're applied.
+func (t *ChainTransformer) Stages() []Transformer {
+	return t.stages
+}
+
+func (t *ChainTransformer) IsDynamic() bool {
+	for _, stage := range t.stages {
+		if stage.IsDynamic() {
+			return true
+		}
+	}
+	return false
+}
+
+// CompatibleTypes returns the types supported by the first stage of the
+// chain. The compatibility of the following stages depends on the output of
+// the previous ones, and is validated when the rules are parsed.
+func (t *ChainTransformer) CompatibleTypes() []SupportedDataType {
+	return t.stages[0].CompatibleTypes()
+}
+
+func (t *ChainTransformer) Type() TransformerType {
+	return Chain
+}
+
+// ReferencedColumns returns the columns referenced by any of the stages.
+func (t *ChainTransformer) ReferencedColumns() []string {
+	var columns []string
+	for _, stage := range t.stages {
+		if referencer, ok := stage.(ColumnReferencer); ok {
+			columns = append(columns, referencer.ReferencedColumns()...)
+		}
+	}
+	return columns
+}
+
+func (t *ChainTransformer) Close() error {
+	var errs error
+	for _, stage := range t.stages {
+		errs = errors.Join(errs, stage.Close())
+	}
+	return errs
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// suffixTransformer appends a suffix to the value, and records the type and
+// dynamic values it receives.
+type suffixTransformer struct {
+	suffix    string
+	gotValues []Value
+}
+
+func (t *suffixTransformer) Transform(_ context.Context, v Value) (any, error) {
+	t.gotValues = append(t.gotValues, v)
+	s, ok := v.TransformValue.(string)
+	if !ok {
+		return nil, ErrUnsupportedValueType
+	}
+	return s + t.suffix, nil
+}
+
+func (t *suffixTransformer) IsDynamic() bool { return true }
+func (t *suffixTransformer) CompatibleTypes() []SupportedDataType {
+	return []SupportedDataType{AllDataTypes}
+}
+func (t *suffixTransformer) Type() TransformerType       { return Template }
+func (t *suffixTransformer) ReferencedColumns() []string { return []string{"id"} }
+func (t *suffixTransformer) Close() error                { return errors.New("oh noes") }
+
+// nullTransformer always returns a null value.
+type nullTransformer struct {
+	upperTransformer
+}
+
+func (t *nullTransformer) Transform(context.Context, Value) (any, error) { return nil, nil }
+
+func TestNewChainTransformer(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewChainTransformer()
+	require.ErrorIs(t, err, ErrEmptyChain)
+
+	suffix := &suffixTransformer{suffix: "!"}
+	chain, err := NewChainTransformer(&upperTransformer{}, suffix)
+	require.NoError(t, err)
+	require.Equal(t, Chain, chain.Type())
+	require.Equal(t, []SupportedDataType{StringDataType}, chain.CompatibleTypes())
+	require.True(t, chain.IsDynamic())
+	require.Equal(t, []string{"id"}, chain.ReferencedColumns())
+	require.Len(t, chain.Stages(), 2)
+	require.Error(t, chain.Close())
+}
+
+func TestChainTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	testDynamicValues := map[string]any{"id": 1}
+
+	tests := []struct {
+		name   string
+		value  any
+		stages func(*suffixTransformer) []Transformer
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:  "ok - stages applied in order",
+			value: "alice",
+			stages: func(s *suffixTransformer) []Transformer {
+				return []Transformer{&upperTransformer{}, s}
+			},
+			wantValue: "ALICE!",
+		},
+		{
+			name:  "ok - reverse order",
+			value: "alice",
+			stages: func(s *suffixTransformer) []Transformer {
+				return []Transformer{s, &upperTransformer{}}
+			},
+			wantValue: "ALICE!",
+		},
+		{
+			name:  "ok - null value",
+			value: nil,
+			stages: func(s *suffixTransformer) []Transformer {
+				return []Transformer{s}
+			},
+			wantValue: nil,
+		},
+		{
+			name:  "ok - null output ends the chain",
+			value: "alice",
+			stages: func(s *suffixTransformer) []Transformer {
+				return []Transformer{&nullTransformer{}, s}
+			},
+			wantValue: nil,
+		},
+		{
+			name:  "error - stage failure",
+			value: "alice",
+			stages: func(s *suffixTransformer) []Transformer {
+				return []Transformer{s, &upperTransformer{errOn: "alice!"}}
+			},
+			wantErr: errors.New("chain[1] (string): oh noes"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			suffix := &suffixTransformer{suffix: "!"}
+			chain, err := NewChainTransformer(tc.stages(suffix)...)
+			require.NoError(t, err)
+
+			got, err := chain.Transform(context.Background(), NewValue(tc.value, "text", testDynamicValues))
+			if tc.wantErr != nil {
+				require.EqualError(t, err, tc.wantErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantValue, got)
+
+			// each stage receives the original column metadata
+			for _, v := range suffix.gotValues {
+				require.Equal(t, "text", v.TransformType)
+				require.Equal(t, testDynamicValues, v.DynamicValues)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DateShiftTransformer shifts dates and timestamps by a number of days derived
+// from the value of a consistency key column (i.e. the patient id) and a
+// secret key. All the values sharing the same consistency key are shifted by
+// the same offset, which keeps the intervals between them (and therefore the
+// relative ordering of the date columns of a row), while different entities
+// get different offsets.
+type DateShiftTransformer struct {
+	key           []byte
+	minDays       int
+	maxDays       int
+	dynamicParams map[string]*DynamicParameter
+}
+
+const consistencyKeyParam = "consistency_key"
+
+// dateShiftLayout is a layout supported for the text representation of the
+// date and timestamp values, and the layout used to format the shifted value.
+type dateShiftLayout struct {
+	parse  string
+	format string
+}
+
+// postgres only includes the timezone minutes and seconds when they're not
+// zero, and the fractional seconds are parsed even if not in the layout.
+var dateShiftLayouts = []dateShiftLayout{
+	{parse: time.DateOnly, format: time.DateOnly},
+	{parse: "2006-01-02 15:04:05", format: "2006-01-02 15:04:05.999999"},
+	{parse: "2006-01-02 15:04:05Z07", format: "2006-01-02 15:04:05.999999Z07:00"},
+	{parse: "2006-01-02 15:04:05Z07:00", format: "2006-01-02 15:04:05.999999Z07:00"},
+	{parse: "2006-01-02 15:04:05Z07:00:00", format: "2006-01-02 15:04:05.999999Z07:00:00"},
+	{parse: time.RFC3339Nano, format: time.RFC3339Nano},
+}
+
+var (
+	errDateShiftMissingConsistencyKey = errors.New("date_shift: consistency_key dynamic parameter must be provided")
+	errDateShiftNullConsistencyKey    = errors.New("date_shift: consistency key value cannot be null")
+	errDateShiftInvalidRange          = errors.New("date_shift: min_days must be less than or equal to max_days")
+	errUnrecognizedDateFormat         = errors.New("date_shift: unrecognized date format")
+
+	dateShiftCompatibleTypes = []SupportedDataType{
+		DateDataType,
+		DatetimeDataType,
+		StringDataType,
+		ByteArrayDataType,
+	}
+	dateShiftParams = []Parameter{
+		{
+			Name:          "key",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_env",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_file",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "min_days",
+			SupportedType: "int",
+			Default:       -30,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "max_days",
+			SupportedType: "int",
+			Default:       30,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          consistencyKeyParam,
+			SupportedType: "any",
+			Default:       nil,
+			Dynamic:       true,
+			Required:      true,
+		},
+	}
+)
+
+func NewDateShiftTransformer(params, dynamicParams ParameterValues) (*DateShiftTransformer, error) {
+	key, err := getSecretKey(params)
+	if err != nil {
+		return nil, fmt.Errorf("date_shift: %w", err)
+	}
+
+	minDays, err := FindParameterWithDefault(params, "min_days", -30)
+	if err != nil {
+		return nil, fmt.Errorf("date_shift: min_days must be an integer: %w", err)
+	}
+	maxDays, err := FindParameterWithDefault(params, "max_days", 30)
+	if err != nil {
+		return nil, fmt.Errorf("date_shift: max_days must be an integer: %w", err)
+	}
+	if minDays > maxDays {
+		return nil, errDateShiftInvalidRange
+	}
+
+	dynamicParamMap, err := ParseDynamicParameters(dynamicParams)
+	if err != nil {
+		return nil, err
+	}
+	if dynamicParamMap[consistencyKeyParam] == nil {
+		return nil, errDateShiftMissingConsistencyKey
+	}
+
+	return &DateShiftTransformer{
+		key:           key,
+		minDays:       minDays,
+		maxDays:       maxDays,
+		dynamicParams: dynamicParamMap,
+	}, nil
+}
+
+// Transform shifts the date or timestamp on input by the offset of its
+// consistency key. Infinity values are returned unchanged. The value is
+// returned with the same type and, for text values, the same format as the
+// input.
+func (t *DateShiftTransformer) Transform(_ context.Context, value Value) (any, error) {
+	shiftable, err := isShiftableDate(value.TransformValue)
+	if err != nil {
+		return nil, err
+	}
+	if !shiftable {
+		return value.TransformValue, nil
+	}
+
+	days, err := t.offsetDays(value.DynamicValues)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := value.TransformValue.(type) {
+	case time.Time:
+		return v.AddDate(0, 0, days), nil
+	case pgtype.Date:
+		v.Time = v.Time.AddDate(0, 0, days)
+		return v, nil
+	case pgtype.Timestamp:
+		v.Time = v.Time.AddDate(0, 0, days)
+		return v, nil
+	case pgtype.Timestamptz:
+		v.Time = v.Time.AddDate(0, 0, days)
+		return v, nil
+	case []byte:
+		shifted, err := shiftDateText(string(v), days)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(shifted), nil
+	default:
+		shifted, err := shiftDateText(v.(string), days)
+		if err != nil {
+			return nil, err
+		}
+		return shifted, nil
+	}
+}
+
+func (t *DateShiftTransformer) CompatibleTypes() []SupportedDataType {
+	return dateShiftCompatibleTypes
+}
+
+func (t *DateShiftTransformer) Type() TransformerType {
+	return DateShift
+}
+
+func (t *DateShiftTransformer) IsDynamic() bool {
+	return true
+}
+
+func (t *DateShiftTransformer) Close() error {
+	return nil
+}
+
+func DateShiftTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: dateShiftCompatibleTypes,
+		Parameters:     dateShiftParams,
+	}
+}
+
+// special values that can't be shifted and are kept as is
+var specialDateValues = map[string]struct{}{
+	"infinity":  {},
+	"-infinity": {},
+}
+
+// isShiftableDate returns false if the value on input is null or infinity, in
+// which case it's kept as is. It returns an error if the value type is not
+// supported.
+func isShiftableDate(value any) (bool, error) {
+	switch v := value.(type) {
+	case nil, pgtype.InfinityModifier:
+		return false, nil
+	case time.Time:
+		return true, nil
+	case pgtype.Date:
+		return v.Valid && v.InfinityModifier == pgtype.Finite, nil
+	case pgtype.Timestamp:
+		return v.Valid && v.InfinityModifier == pgtype.Finite, nil
+	case pgtype.Timestamptz:
+		return v.Valid && v.InfinityModifier == pgtype.Finite, nil
+	case string:
+		_, found := specialDateValues[v]
+		return !found, nil
+	case []byte:
+		_, found := specialDateValues[string(v)]
+		return !found, nil
+	default:
+		return false, ErrUnsupportedValueType
+	}
+}
+
+// offsetDays returns the number of days to shift the values with the
+// consistency key on input, in the configured range. The offset is derived
+// from the HMAC of the key text representation, so that the same key always
+// gets the same offset.
+func (t *DateShiftTransformer) offsetDays(dynamicValues map[string]any) (int, error) {
+	consistencyKey, found := dynamicValues[t.dynamicParams[consistencyKeyParam].Column]
+	if !found {
+		return 0, fmt.Errorf("%w: column %q not found", ErrInvalidDynamicParameters, t.dynamicParams[consistencyKeyParam].Column)
+	}
+	input, isNull, err := hmacInput(consistencyKey)
+	if err != nil {
+		return 0, fmt.Errorf("date_shift: consistency key: %w", err)
+	}
+	if isNull {
+		return 0, errDateShiftNullConsistencyKey
+	}
+
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write(input)
+	sum := mac.Sum(nil)
+
+	rangeSize := uint64(t.maxDays - t.minDays + 1)
+	return t.minDays + int(binary.BigEndian.Uint64(sum[:8])%rangeSize), nil
+}
+
+func shiftDateText(value string, days int) (string, error) {
+	for _, layout := range dateShiftLayouts {
+		parsed, err := time.Parse(layout.parse, value)
+		if err == nil {
+			return parsed.AddDate(0, 0, days).Format(layout.format), nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q", errUnrecognizedDateFormat, value)
+}
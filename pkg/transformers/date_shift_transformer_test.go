@@ -0,0 +1,233 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDateShiftTransformer(t *testing.T) {
+	t.Parallel()
+
+	testDynamicParams := ParameterValues{"consistency_key": map[string]any{"column": "patient_id"}}
+
+	tests := []struct {
+		name          string
+		params        ParameterValues
+		dynamicParams ParameterValues
+
+		wantErr error
+	}{
+		{
+			name:          "ok",
+			params:        ParameterValues{"key": "secret", "min_days": -10, "max_days": 10},
+			dynamicParams: testDynamicParams,
+		},
+		{
+			name:          "error - no key",
+			params:        ParameterValues{},
+			dynamicParams: testDynamicParams,
+
+			wantErr: errKeyNotFound,
+		},
+		{
+			name:          "error - invalid min days",
+			params:        ParameterValues{"key": "secret", "min_days": "-10"},
+			dynamicParams: testDynamicParams,
+
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:          "error - invalid range",
+			params:        ParameterValues{"key": "secret", "min_days": 10, "max_days": 5},
+			dynamicParams: testDynamicParams,
+
+			wantErr: errDateShiftInvalidRange,
+		},
+		{
+			name:   "error - missing consistency key",
+			params: ParameterValues{"key": "secret"},
+
+			wantErr: errDateShiftMissingConsistencyKey,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewDateShiftTransformer(tc.params, tc.dynamicParams)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestDateShiftTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	// HMAC-SHA256 of "42" with key "secret" gives an offset of -26 days in the
+	// default range, and "43" an offset of -7 days
+	testDynamicValues := map[string]any{"patient_id": int64(42)}
+	testTime := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+	wantTime := time.Date(2024, 2, 18, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		value         any
+		dynamicValues map[string]any
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:          "ok - time",
+			value:         testTime,
+			dynamicValues: testDynamicValues,
+
+			wantValue: wantTime,
+		},
+		{
+			name:          "ok - different consistency key",
+			value:         testTime,
+			dynamicValues: map[string]any{"patient_id": "43"},
+
+			wantValue: time.Date(2024, 3, 8, 10, 30, 0, 0, time.UTC),
+		},
+		{
+			name:          "ok - pgtype date",
+			value:         pgtype.Date{Time: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), Valid: true},
+			dynamicValues: testDynamicValues,
+
+			wantValue: pgtype.Date{Time: time.Date(2024, 2, 18, 0, 0, 0, 0, time.UTC), Valid: true},
+		},
+		{
+			name:          "ok - pgtype timestamptz",
+			value:         pgtype.Timestamptz{Time: testTime, Valid: true},
+			dynamicValues: testDynamicValues,
+
+			wantValue: pgtype.Timestamptz{Time: wantTime, Valid: true},
+		},
+		{
+			name:          "ok - date string",
+			value:         "2024-03-15",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "2024-02-18",
+		},
+		{
+			name:          "ok - timestamp string",
+			value:         "2024-03-15 10:30:00.123456",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "2024-02-18 10:30:00.123456",
+		},
+		{
+			name:          "ok - timestamptz string",
+			value:         []byte("2024-03-15 10:30:00+02"),
+			dynamicValues: testDynamicValues,
+
+			wantValue: []byte("2024-02-18 10:30:00+02:00"),
+		},
+		{
+			name:          "ok - infinity string",
+			value:         "-infinity",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "-infinity",
+		},
+		{
+			name:          "ok - infinity modifier",
+			value:         pgtype.Infinity,
+			dynamicValues: testDynamicValues,
+
+			wantValue: pgtype.Infinity,
+		},
+		{
+			name:          "ok - infinity pgtype timestamp",
+			value:         pgtype.Timestamp{InfinityModifier: pgtype.Infinity, Valid: true},
+			dynamicValues: testDynamicValues,
+
+			wantValue: pgtype.Timestamp{InfinityModifier: pgtype.Infinity, Valid: true},
+		},
+		{
+			name:          "ok - null",
+			value:         nil,
+			dynamicValues: testDynamicValues,
+
+			wantValue: nil,
+		},
+		{
+			name:          "error - null consistency key",
+			value:         testTime,
+			dynamicValues: map[string]any{"patient_id": nil},
+
+			wantErr: errDateShiftNullConsistencyKey,
+		},
+		{
+			name:          "error - missing consistency key column",
+			value:         testTime,
+			dynamicValues: map[string]any{},
+
+			wantErr: ErrInvalidDynamicParameters,
+		},
+		{
+			name:          "error - unrecognized format",
+			value:         "15/03/2024",
+			dynamicValues: testDynamicValues,
+
+			wantErr: errUnrecognizedDateFormat,
+		},
+		{
+			name:          "error - unsupported value type",
+			value:         int64(1710498600),
+			dynamicValues: testDynamicValues,
+
+			wantErr: ErrUnsupportedValueType,
+		},
+	}
+
+	transformer, err := NewDateShiftTransformer(
+		ParameterValues{"key": "secret"},
+		ParameterValues{"consistency_key": map[string]any{"column": "patient_id"}},
+	)
+	require.NoError(t, err)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := transformer.Transform(context.Background(), Value{
+				TransformValue: tc.value,
+				DynamicValues:  tc.dynamicValues,
+			})
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, got)
+		})
+	}
+}
+
+func TestDateShiftTransformer_offsetDays(t *testing.T) {
+	t.Parallel()
+
+	transformer, err := NewDateShiftTransformer(
+		ParameterValues{"key": "secret", "min_days": -5, "max_days": 5},
+		ParameterValues{"consistency_key": map[string]any{"column": "patient_id"}},
+	)
+	require.NoError(t, err)
+
+	for i := range 100 {
+		days, err := transformer.offsetDays(map[string]any{"patient_id": i})
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, days, -5)
+		require.LessOrEqual(t, days, 5)
+	}
+
+	days, err := transformer.offsetDays(map[string]any{"patient_id": int64(42)})
+	require.NoError(t, err)
+	require.Equal(t, -5, days)
+}
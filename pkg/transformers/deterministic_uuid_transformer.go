@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DeterministicUUIDTransformer re-maps uuid values into the UUIDv5 computed
+// over the configured namespace and the original uuid. The same uuid is
+// always mapped to the same output for a given namespace, so it can be used to
+// anonymize uuid primary and foreign keys while keeping the referential
+// integrity across tables.
+type DeterministicUUIDTransformer struct {
+	namespace uuid.UUID
+}
+
+var (
+	deterministicUUIDParams = []Parameter{
+		{
+			Name:          "namespace",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "namespace_env",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "namespace_file",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+	}
+	deterministicUUIDCompatibleTypes = []SupportedDataType{
+		StringDataType,
+		ByteArrayDataType,
+		UUIDDataType,
+		UInt8ArrayOf16DataType,
+	}
+
+	errNamespaceNotFound             = errors.New("deterministic_uuid: one of namespace, namespace_env or namespace_file must be provided")
+	errMultipleNamespaces            = errors.New("deterministic_uuid: only one of namespace, namespace_env or namespace_file can be provided")
+	errInvalidUUIDNamespace          = errors.New("deterministic_uuid: namespace must be a valid uuid")
+	errInvalidDeterministicUUIDValue = errors.New("deterministic_uuid: invalid uuid value")
+)
+
+func NewDeterministicUUIDTransformer(params ParameterValues) (*DeterministicUUIDTransformer, error) {
+	namespaceStr, err := getSecretParameter(params, "namespace", errNamespaceNotFound, errMultipleNamespaces)
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := uuid.Parse(namespaceStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidUUIDNamespace, err)
+	}
+
+	return &DeterministicUUIDTransformer{
+		namespace: namespace,
+	}, nil
+}
+
+// Transform returns the re-mapped uuid in the same representation as the
+// value on input. Null values are not transformed.
+func (t *DeterministicUUIDTransformer) Transform(_ context.Context, value Value) (any, error) {
+	switch v := value.TransformValue.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidDeterministicUUIDValue, err)
+		}
+		return t.remap(id).String(), nil
+	case []byte:
+		// 16 bytes values are the binary representation of the uuid
+		if len(v) == 16 {
+			remapped := t.remap(uuid.UUID(v))
+			return remapped[:], nil
+		}
+		id, err := uuid.ParseBytes(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidDeterministicUUIDValue, err)
+		}
+		return []byte(t.remap(id).String()), nil
+	case [16]uint8:
+		return [16]uint8(t.remap(uuid.UUID(v))), nil
+	case uuid.UUID:
+		return t.remap(v), nil
+	case pgtype.UUID:
+		if !v.Valid {
+			return v, nil
+		}
+		return pgtype.UUID{Bytes: t.remap(uuid.UUID(v.Bytes)), Valid: true}, nil
+	default:
+		return nil, fmt.Errorf("deterministic_uuid: expected uuid, got %T: %w", value.TransformValue, ErrUnsupportedValueType)
+	}
+}
+
+func (t *DeterministicUUIDTransformer) CompatibleTypes() []SupportedDataType {
+	return deterministicUUIDCompatibleTypes
+}
+
+func (t *DeterministicUUIDTransformer) Type() TransformerType {
+	return DeterministicUUID
+}
+
+func (t *DeterministicUUIDTransformer) IsDynamic() bool {
+	return false
+}
+
+func (t *DeterministicUUIDTransformer) Close() error {
+	return nil
+}
+
+// remap returns the UUIDv5 of the binary representation of the uuid on input,
+// so that the output doesn't depend on the input representation.
+func (t *DeterministicUUIDTransformer) remap(id uuid.UUID) uuid.UUID {
+	return uuid.NewSHA1(t.namespace, id[:])
+}
+
+func DeterministicUUIDTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: deterministicUUIDCompatibleTypes,
+		Parameters:     deterministicUUIDParams,
+	}
+}
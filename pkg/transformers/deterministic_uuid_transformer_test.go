@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+const testUUIDNamespace = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+
+func TestNewDeterministicUUIDTransformer(t *testing.T) {
+	t.Parallel()
+
+	namespaceFile := filepath.Join(t.TempDir(), "namespace")
+	require.NoError(t, os.WriteFile(namespaceFile, []byte(testUUIDNamespace+"\n"), 0o600))
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+
+		wantErr error
+	}{
+		{
+			name:   "ok - namespace",
+			params: ParameterValues{"namespace": testUUIDNamespace},
+		},
+		{
+			name:   "ok - namespace file",
+			params: ParameterValues{"namespace_file": namespaceFile},
+		},
+		{
+			name:    "error - missing namespace",
+			params:  ParameterValues{},
+			wantErr: errNamespaceNotFound,
+		},
+		{
+			name:    "error - multiple namespaces",
+			params:  ParameterValues{"namespace": testUUIDNamespace, "namespace_file": namespaceFile},
+			wantErr: errMultipleNamespaces,
+		},
+		{
+			name:    "error - invalid namespace type",
+			params:  ParameterValues{"namespace": 1},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - invalid namespace",
+			params:  ParameterValues{"namespace": "not-a-uuid"},
+			wantErr: errInvalidUUIDNamespace,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewDeterministicUUIDTransformer(tc.params)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestDeterministicUUIDTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	input := uuid.MustParse("c0ffee00-0000-4000-8000-000000000001")
+	want := uuid.NewSHA1(uuid.MustParse(testUUIDNamespace), input[:])
+
+	tests := []struct {
+		name  string
+		value any
+
+		wantValue any
+		wantErr   error
+	}{
+		{name: "ok - nil", value: nil, wantValue: nil},
+		{name: "ok - string", value: input.String(), wantValue: want.String()},
+		{name: "ok - uppercase string", value: "C0FFEE00-0000-4000-8000-000000000001", wantValue: want.String()},
+		{name: "ok - text bytes", value: []byte(input.String()), wantValue: []byte(want.String())},
+		{name: "ok - binary bytes", value: input[:], wantValue: want[:]},
+		{name: "ok - uint8 array", value: [16]uint8(input), wantValue: [16]uint8(want)},
+		{name: "ok - uuid", value: input, wantValue: want},
+		{name: "ok - pgtype uuid", value: pgtype.UUID{Bytes: input, Valid: true}, wantValue: pgtype.UUID{Bytes: want, Valid: true}},
+		{name: "ok - null pgtype uuid", value: pgtype.UUID{}, wantValue: pgtype.UUID{}},
+		{name: "error - invalid string", value: "not-a-uuid", wantErr: errInvalidDeterministicUUIDValue},
+		{name: "error - unsupported type", value: 1, wantErr: ErrUnsupportedValueType},
+	}
+
+	transformer, err := NewDeterministicUUIDTransformer(ParameterValues{"namespace": testUUIDNamespace})
+	require.NoError(t, err)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := transformer.Transform(context.Background(), NewValue(tc.value, "uuid", nil))
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, got)
+		})
+	}
+}
+
+func TestDeterministicUUIDTransformer_Transform_namespace(t *testing.T) {
+	t.Parallel()
+
+	input := uuid.MustParse("c0ffee00-0000-4000-8000-000000000001").String()
+	transform := func(namespace string) any {
+		transformer, err := NewDeterministicUUIDTransformer(ParameterValues{"namespace": namespace})
+		require.NoError(t, err)
+		got, err := transformer.Transform(context.Background(), NewValue(input, "uuid", nil))
+		require.NoError(t, err)
+		return got
+	}
+
+	// the same namespace always produces the same output, and different
+	// namespaces produce different outputs
+	require.Equal(t, transform(testUUIDNamespace), transform(testUUIDNamespace))
+	require.NotEqual(t, transform(testUUIDNamespace), transform(uuid.NameSpaceURL.String()))
+	require.NotEqual(t, input, transform(testUUIDNamespace))
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"strings"
+)
+
+// fakeDataLocale contains the data sets and formats used to generate the fake
+// values of a locale.
+//
+// In the patterns, '#' is replaced by a random digit, '%' by a random non
+// zero digit and '@' by a random uppercase letter.
+type fakeDataLocale struct {
+	firstNames       []string
+	lastNames        []string
+	streets          []string
+	streetSuffixes   []string
+	cities           []string
+	regions          []string
+	postcodePatterns []string
+	phonePatterns    []string
+	companySuffixes  []string
+	// address builds the address from its parts, in the order used by the
+	// locale.
+	address func(number, street, suffix, postcode, city, region string) string
+}
+
+const (
+	fakeDataLocaleEnUS = "en_US"
+	fakeDataLocaleEnGB = "en_GB"
+	fakeDataLocaleDeDE = "de_DE"
+	fakeDataLocaleFrFR = "fr_FR"
+	fakeDataLocaleEsES = "es_ES"
+)
+
+// fake emails use the domains reserved for documentation, so that they can
+// never reach a real mailbox.
+var fakeDataEmailDomains = []string{"example.com", "example.net", "example.org"}
+
+var fakeDataLocales = map[string]*fakeDataLocale{
+	fakeDataLocaleEnUS: {
+		firstNames: []string{
+			"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda",
+			"David", "Elizabeth", "William", "Barbara", "Richard", "Susan", "Joseph", "Jessica",
+			"Thomas", "Sarah", "Charles", "Karen", "Daniel", "Nancy", "Matthew", "Lisa",
+			"Anthony", "Betty", "Mark", "Sandra", "Steven", "Ashley", "Andrew", "Emily",
+		},
+		lastNames: []string{
+			"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis",
+			"Rodriguez", "Martinez", "Hernandez", "Lopez", "Wilson", "Anderson", "Thomas", "Taylor",
+			"Moore", "Jackson", "Martin", "Lee", "Thompson", "White", "Harris", "Clark",
+			"Lewis", "Robinson", "Walker", "Young", "Allen", "King", "Wright", "Scott",
+		},
+		streets: []string{
+			"Main", "Oak", "Pine", "Maple", "Cedar", "Elm", "Washington", "Lake",
+			"Hill", "Park", "Walnut", "Sunset", "Lincoln", "Jackson", "Church", "River",
+		},
+		streetSuffixes: []string{"Street", "Avenue", "Road", "Lane", "Drive", "Court", "Boulevard", "Way"},
+		cities: []string{
+			"Springfield", "Riverside", "Franklin", "Greenville", "Bristol", "Clinton", "Fairview", "Salem",
+			"Madison", "Georgetown", "Arlington", "Ashland", "Dover", "Oxford", "Jackson", "Burlington",
+		},
+		regions:          []string{"CA", "TX", "NY", "FL", "IL", "PA", "OH", "GA", "NC", "MI", "WA", "AZ", "MA", "CO", "OR", "VA"},
+		postcodePatterns: []string{"%####"},
+		// 555-01XX numbers are reserved for fictional use
+		phonePatterns:   []string{"+1 %##-555-01##"},
+		companySuffixes: []string{"Inc.", "LLC", "Group", "& Sons", "Corp.", "Holdings"},
+		address: func(number, street, suffix, postcode, city, region string) string {
+			return number + " " + street + " " + suffix + ", " + city + ", " + region + " " + postcode
+		},
+	},
+	fakeDataLocaleEnGB: {
+		firstNames: []string{
+			"Oliver", "Amelia", "George", "Isla", "Harry", "Ava", "Jack", "Emily",
+			"Jacob", "Sophia", "Charlie", "Grace", "Thomas", "Lily", "Oscar", "Olivia",
+			"William", "Freya", "James", "Poppy", "Alfie", "Ella", "Henry", "Evie",
+		},
+		lastNames: []string{
+			"Smith", "Jones", "Taylor", "Brown", "Williams", "Wilson", "Johnson", "Davies",
+			"Robinson", "Wright", "Thompson", "Evans", "Walker", "White", "Roberts", "Green",
+			"Hall", "Wood", "Jackson", "Clarke", "Hughes", "Edwards", "Turner", "Cooper",
+		},
+		streets: []string{
+			"High", "Station", "Church", "Victoria", "Green", "Manor", "Park", "Queens",
+			"Kings", "Mill", "Windsor", "Grange", "York", "Albert", "Chapel", "Springfield",
+		},
+		streetSuffixes: []string{"Street", "Road", "Lane", "Close", "Gardens", "Crescent", "Avenue", "Way"},
+		cities: []string{
+			"London", "Manchester", "Birmingham", "Leeds", "Bristol", "Sheffield", "Liverpool", "Nottingham",
+			"Leicester", "Norwich", "Brighton", "Cambridge", "Oxford", "York", "Exeter", "Bath",
+		},
+		postcodePatterns: []string{"@# #@@", "@## #@@", "@@# #@@", "@@## #@@"},
+		// 07700 900XXX numbers are reserved for drama use
+		phonePatterns:   []string{"+44 7700 900###"},
+		companySuffixes: []string{"Ltd", "PLC", "Group", "& Co.", "Holdings", "Partners"},
+		address: func(number, street, suffix, postcode, city, _ string) string {
+			return number + " " + street + " " + suffix + ", " + city + " " + postcode
+		},
+	},
+	fakeDataLocaleDeDE: {
+		firstNames: []string{
+			"Lukas", "Anna", "Leon", "Lena", "Maximilian", "Marie", "Felix", "Sophie",
+			"Jonas", "Laura", "Paul", "Hannah", "Finn", "Lea", "Elias", "Emma",
+			"Jürgen", "Ursula", "Stefan", "Sabine", "Andreas", "Petra", "Michael", "Katrin",
+		},
+		lastNames: []string{
+			"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker",
+			"Schulz", "Hoffmann", "Schäfer", "Koch", "Bauer", "Richter", "Klein", "Wolf",
+			"Schröder", "Neumann", "Schwarz", "Zimmermann", "Braun", "Krüger", "Hofmann", "Hartmann",
+		},
+		streets: []string{
+			"Haupt", "Bahnhof", "Garten", "Schul", "Dorf", "Berg", "Kirch", "Linden",
+			"Wald", "Ring", "Birken", "Mühlen", "Feld", "Wiesen", "Sonnen", "Rosen",
+		},
+		streetSuffixes: []string{"straße", "weg", "allee", "gasse", "platz"},
+		cities: []string{
+			"Berlin", "Hamburg", "München", "Köln", "Frankfurt am Main", "Stuttgart", "Düsseldorf", "Leipzig",
+			"Dortmund", "Essen", "Bremen", "Dresden", "Hannover", "Nürnberg", "Bonn", "Freiburg",
+		},
+		postcodePatterns: []string{"%####"},
+		phonePatterns:    []string{"+49 30 %#######", "+49 151 ########", "+49 89 %#######"},
+		companySuffixes:  []string{"GmbH", "AG", "KG", "GmbH & Co. KG", "OHG", "e.K."},
+		address: func(number, street, suffix, postcode, city, _ string) string {
+			return street + suffix + " " + number + ", " + postcode + " " + city
+		},
+	},
+	fakeDataLocaleFrFR: {
+		firstNames: []string{
+			"Gabriel", "Louise", "Léo", "Emma", "Raphaël", "Jade", "Louis", "Alice",
+			"Arthur", "Chloé", "Jules", "Léa", "Hugo", "Manon", "Lucas", "Camille",
+			"Nicolas", "Sophie", "Pierre", "Claire", "François", "Hélène", "Antoine", "Élise",
+		},
+		lastNames: []string{
+			"Martin", "Bernard", "Dubois", "Thomas", "Robert", "Richard", "Petit", "Durand",
+			"Leroy", "Moreau", "Simon", "Laurent", "Lefebvre", "Michel", "Garcia", "David",
+			"Bertrand", "Roux", "Vincent", "Fournier", "Morel", "Girard", "André", "Lefèvre",
+		},
+		streets: []string{
+			"de la Paix", "Victor Hugo", "de la République", "Jean Jaurès", "de la Gare", "Pasteur", "du Moulin", "des Lilas",
+			"de l'Église", "du Château", "Voltaire", "des Écoles", "Gambetta", "de Verdun", "du Port", "des Roses",
+		},
+		streetSuffixes: []string{"rue", "avenue", "boulevard", "place", "allée", "impasse"},
+		cities: []string{
+			"Paris", "Marseille", "Lyon", "Toulouse", "Nice", "Nantes", "Strasbourg", "Montpellier",
+			"Bordeaux", "Lille", "Rennes", "Reims", "Grenoble", "Dijon", "Angers", "Nîmes",
+		},
+		postcodePatterns: []string{"%####"},
+		phonePatterns:    []string{"+33 6 ## ## ## ##", "+33 7 ## ## ## ##", "+33 1 ## ## ## ##"},
+		companySuffixes:  []string{"SARL", "SA", "SAS", "et Fils", "Associés", "Groupe"},
+		address: func(number, street, suffix, postcode, city, _ string) string {
+			return number + " " + suffix + " " + street + ", " + postcode + " " + city
+		},
+	},
+	fakeDataLocaleEsES: {
+		firstNames: []string{
+			"Hugo", "Lucía", "Martín", "Sofía", "Pablo", "María", "Mateo", "Martina",
+			"Daniel", "Julia", "Alejandro", "Paula", "Lucas", "Valeria", "Álvaro", "Carmen",
+			"Javier", "Elena", "Carlos", "Laura", "Sergio", "Ana", "Diego", "Isabel",
+		},
+		lastNames: []string{
+			"García", "Rodríguez", "González", "Fernández", "López", "Martínez", "Sánchez", "Pérez",
+			"Gómez", "Martín", "Jiménez", "Ruiz", "Hernández", "Díaz", "Moreno", "Muñoz",
+			"Álvarez", "Romero", "Alonso", "Gutiérrez", "Navarro", "Torres", "Domínguez", "Vázquez",
+		},
+		streets: []string{
+			"Mayor", "Real", "de la Iglesia", "del Sol", "de Cervantes", "de la Constitución", "San Juan", "del Carmen",
+			"de Goya", "de la Paz", "Nueva", "del Mar", "de Alcalá", "de Colón", "del Prado", "de las Flores",
+		},
+		streetSuffixes: []string{"Calle", "Avenida", "Plaza", "Paseo", "Camino", "Ronda"},
+		cities: []string{
+			"Madrid", "Barcelona", "Valencia", "Sevilla", "Zaragoza", "Málaga", "Murcia", "Palma",
+			"Bilbao", "Alicante", "Córdoba", "Valladolid", "Vigo", "Gijón", "Granada", "Salamanca",
+		},
+		postcodePatterns: []string{"%####"},
+		phonePatterns:    []string{"+34 6## ### ###", "+34 91# ### ###"},
+		companySuffixes:  []string{"S.L.", "S.A.", "y Asociados", "Grupo", "e Hijos", "S.L.U."},
+		address: func(number, street, suffix, postcode, city, _ string) string {
+			return suffix + " " + street + ", " + number + ", " + postcode + " " + city
+		},
+	},
+}
+
+// fakeDataEmailReplacer transliterates the non ASCII letters used in the
+// locale names, so that they can be used in the email local part.
+var fakeDataEmailReplacer = strings.NewReplacer(
+	"ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss",
+	"á", "a", "à", "a", "â", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "î", "i", "ï", "i",
+	"ó", "o", "ô", "o",
+	"ú", "u", "ù", "u", "û", "u",
+	"ç", "c", "ñ", "n",
+)
+
+func fakeDataLocaleNames() []any {
+	return []any{fakeDataLocaleEnUS, fakeDataLocaleEnGB, fakeDataLocaleDeDE, fakeDataLocaleFrFR, fakeDataLocaleEsES}
+}
@@ -0,0 +1,394 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FakeDataTransformer replaces values with realistic fake data (names,
+// addresses, companies, emails or phone numbers) generated from the value of a
+// seed column (i.e. the primary key) and a secret key. The same row always
+// gets the same fake identity, across tables and snapshot runs, and the
+// columns of the row configured with the same key and seed are consistent
+// with each other (i.e. the fake email matches the fake first and last name).
+type FakeDataTransformer struct {
+	key           []byte
+	kind          string
+	locale        *fakeDataLocale
+	dynamicParams map[string]*DynamicParameter
+
+	unique bool
+	// seen keeps the values already generated by the transformer, along
+	// with the digest of the seed they were generated for, to detect
+	// duplicates when unique is enabled.
+	seenMu sync.Mutex
+	seen   map[string][sha256.Size]byte
+}
+
+const (
+	fakeDataFirstName = "first_name"
+	fakeDataLastName  = "last_name"
+	fakeDataFullName  = "full_name"
+	fakeDataAddress   = "address"
+	fakeDataCompany   = "company"
+	fakeDataEmail     = "email"
+	fakeDataPhone     = "phone"
+
+	// fakeDataMaxUniqueAttempts is the number of suffixes tried before
+	// giving up on finding a unique value.
+	fakeDataMaxUniqueAttempts = 10
+	// fakeDataMaxSeenValues bounds the memory used to track the generated
+	// values. Once reached, uniqueness is no longer enforced for new values.
+	fakeDataMaxSeenValues = 1_000_000
+
+	// fakeDataPersonStream is the random stream used for the names, shared
+	// by all kinds so that the names are the same for all the columns of a
+	// row.
+	fakeDataPersonStream = "person"
+)
+
+var (
+	errFakeDataInvalidKind   = errors.New("fake_data: kind must be one of first_name, last_name, full_name, address, company, email or phone")
+	errFakeDataInvalidLocale = errors.New("fake_data: unsupported locale")
+	errFakeDataMissingSeed   = errors.New("fake_data: seed dynamic parameter must be provided")
+	errFakeDataNullSeedValue = errors.New("fake_data: seed value cannot be null")
+	fakeDataCompatibleTypes  = []SupportedDataType{StringDataType, CitextDataType, ByteArrayDataType}
+	fakeDataKinds            = []any{fakeDataFirstName, fakeDataLastName, fakeDataFullName, fakeDataAddress, fakeDataCompany, fakeDataEmail, fakeDataPhone}
+	fakeDataParams           = []Parameter{
+		{
+			Name:          "kind",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      true,
+			Values:        fakeDataKinds,
+		},
+		{
+			Name:          "locale",
+			SupportedType: "string",
+			Default:       fakeDataLocaleEnUS,
+			Dynamic:       false,
+			Required:      false,
+			Values:        fakeDataLocaleNames(),
+		},
+		{
+			Name:          "unique",
+			SupportedType: "boolean",
+			Default:       false,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_env",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_file",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          seedParam,
+			SupportedType: "any",
+			Default:       nil,
+			Dynamic:       true,
+			Required:      true,
+		},
+	}
+)
+
+func NewFakeDataTransformer(params, dynamicParams ParameterValues) (*FakeDataTransformer, error) {
+	key, err := getSecretKey(params)
+	if err != nil {
+		return nil, fmt.Errorf("fake_data: %w", err)
+	}
+
+	kind, found, err := FindParameter[string](params, "kind")
+	if err != nil {
+		return nil, fmt.Errorf("fake_data: kind must be a string: %w", err)
+	}
+	if !found {
+		return nil, errFakeDataInvalidKind
+	}
+	switch kind {
+	case fakeDataFirstName, fakeDataLastName, fakeDataFullName, fakeDataAddress, fakeDataCompany, fakeDataEmail, fakeDataPhone:
+	default:
+		return nil, errFakeDataInvalidKind
+	}
+
+	localeName, err := FindParameterWithDefault(params, "locale", fakeDataLocaleEnUS)
+	if err != nil {
+		return nil, fmt.Errorf("fake_data: locale must be a string: %w", err)
+	}
+	locale, found := fakeDataLocales[localeName]
+	if !found {
+		return nil, fmt.Errorf("%w: %q", errFakeDataInvalidLocale, localeName)
+	}
+
+	unique, err := FindParameterWithDefault(params, "unique", false)
+	if err != nil {
+		return nil, fmt.Errorf("fake_data: unique must be a boolean: %w", err)
+	}
+
+	dynamicParamMap, err := ParseDynamicParameters(dynamicParams)
+	if err != nil {
+		return nil, err
+	}
+	if dynamicParamMap[seedParam] == nil {
+		return nil, errFakeDataMissingSeed
+	}
+
+	t := &FakeDataTransformer{
+		key:           key,
+		kind:          kind,
+		locale:        locale,
+		dynamicParams: dynamicParamMap,
+		unique:        unique,
+	}
+	if unique {
+		t.seen = map[string][sha256.Size]byte{}
+	}
+	return t, nil
+}
+
+// Transform replaces the value on input with the fake value generated for the
+// seed column value of the row. The value on input is not used, other than to
+// return the fake value with the same type.
+func (t *FakeDataTransformer) Transform(_ context.Context, value Value) (any, error) {
+	switch value.TransformValue.(type) {
+	case string, []byte:
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+
+	seed, err := t.seedDigest(value.DynamicValues)
+	if err != nil {
+		return nil, err
+	}
+
+	fake := t.generate(seed, 0)
+	if t.unique {
+		fake = t.uniqueValue(seed, fake)
+	}
+
+	if _, ok := value.TransformValue.([]byte); ok {
+		return []byte(fake), nil
+	}
+	return fake, nil
+}
+
+func (t *FakeDataTransformer) CompatibleTypes() []SupportedDataType {
+	return fakeDataCompatibleTypes
+}
+
+func (t *FakeDataTransformer) Type() TransformerType {
+	return FakeData
+}
+
+func (t *FakeDataTransformer) IsDynamic() bool {
+	return true
+}
+
+func (t *FakeDataTransformer) Close() error {
+	return nil
+}
+
+func FakeDataTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: fakeDataCompatibleTypes,
+		Parameters:     fakeDataParams,
+	}
+}
+
+// seedDigest returns the HMAC of the seed column value text representation,
+// which is used to derive all the random values for the row.
+func (t *FakeDataTransformer) seedDigest(dynamicValues map[string]any) ([sha256.Size]byte, error) {
+	var digest [sha256.Size]byte
+	seed, found := dynamicValues[t.dynamicParams[seedParam].Column]
+	if !found {
+		return digest, fmt.Errorf("%w: column %q not found", ErrInvalidDynamicParameters, t.dynamicParams[seedParam].Column)
+	}
+	input, isNull, err := hmacInput(seed)
+	if err != nil {
+		return digest, fmt.Errorf("fake_data: seed: %w", err)
+	}
+	if isNull {
+		return digest, errFakeDataNullSeedValue
+	}
+
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write(input)
+	copy(digest[:], mac.Sum(nil))
+	return digest, nil
+}
+
+// generate returns the fake value for the seed digest on input. A non zero
+// attempt is used to generate alternative values when the previous ones were
+// already taken.
+func (t *FakeDataTransformer) generate(seed [sha256.Size]byte, attempt int) string {
+	person := newFakeDataRand(seed, fakeDataPersonStream)
+	firstName := fakeDataPick(person, t.locale.firstNames)
+	lastName := fakeDataPick(person, t.locale.lastNames)
+
+	r := newFakeDataRand(seed, t.kind)
+	var suffix string
+	if attempt > 0 {
+		unique := newFakeDataRand(seed, "unique:"+strconv.Itoa(attempt))
+		suffix = strconv.FormatUint(2+unique.intn(9998), 10)
+	}
+
+	switch t.kind {
+	case fakeDataFirstName:
+		return withFakeDataSuffix(firstName, suffix)
+	case fakeDataLastName:
+		return withFakeDataSuffix(lastName, suffix)
+	case fakeDataFullName:
+		return withFakeDataSuffix(firstName+" "+lastName, suffix)
+	case fakeDataCompany:
+		return withFakeDataSuffix(fakeDataPick(r, t.locale.lastNames)+" "+fakeDataPick(r, t.locale.companySuffixes), suffix)
+	case fakeDataEmail:
+		localPart := fakeDataEmailPart(firstName) + "." + fakeDataEmailPart(lastName) + suffix
+		return localPart + "@" + fakeDataPick(r, fakeDataEmailDomains)
+	case fakeDataPhone:
+		// phone numbers are regenerated rather than suffixed, to keep them
+		// valid
+		if attempt > 0 {
+			r = newFakeDataRand(seed, t.kind+":"+strconv.Itoa(attempt))
+		}
+		return r.fillPattern(fakeDataPick(r, t.locale.phonePatterns))
+	default:
+		number := strconv.FormatUint(1+r.intn(199), 10)
+		if suffix != "" {
+			number = suffix
+		}
+		street := fakeDataPick(r, t.locale.streets)
+		streetSuffix := fakeDataPick(r, t.locale.streetSuffixes)
+		city := fakeDataPick(r, t.locale.cities)
+		var region string
+		if len(t.locale.regions) > 0 {
+			region = fakeDataPick(r, t.locale.regions)
+		}
+		postcode := r.fillPattern(fakeDataPick(r, t.locale.postcodePatterns))
+		return t.locale.address(number, street, streetSuffix, postcode, city, region)
+	}
+}
+
+// uniqueValue returns the fake value on input if it was not generated before
+// for a different seed. Otherwise, alternative values are generated until a
+// unique one is found or the attempts are exhausted, in which case the last
+// one is returned. Since the alternatives depend on the values previously
+// seen by the transformer, uniqueness is best effort and duplicates can still
+// happen across different pgstream runs.
+func (t *FakeDataTransformer) uniqueValue(seed [sha256.Size]byte, fake string) string {
+	t.seenMu.Lock()
+	defer t.seenMu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		seenSeed, found := t.seen[fake]
+		if !found {
+			if len(t.seen) < fakeDataMaxSeenValues {
+				t.seen[fake] = seed
+			}
+			return fake
+		}
+		if seenSeed == seed || attempt > fakeDataMaxUniqueAttempts {
+			return fake
+		}
+		fake = t.generate(seed, attempt)
+	}
+}
+
+// fakeDataRand is a deterministic random source derived from a seed digest
+// and a stream label, using HMAC-SHA256 in counter mode. Unlike math/rand, its
+// output is guaranteed to be stable across Go versions, which is required
+// for the fake values to be stable.
+type fakeDataRand struct {
+	seed    [sha256.Size]byte
+	stream  string
+	counter uint64
+}
+
+func newFakeDataRand(seed [sha256.Size]byte, stream string) *fakeDataRand {
+	return &fakeDataRand{seed: seed, stream: stream}
+}
+
+func (r *fakeDataRand) uint64() uint64 {
+	mac := hmac.New(sha256.New, r.seed[:])
+	mac.Write([]byte(r.stream))
+	mac.Write(binary.BigEndian.AppendUint64(nil, r.counter))
+	r.counter++
+	return binary.BigEndian.Uint64(mac.Sum(nil)[:8])
+}
+
+// intn returns a random number in [0, n). The modulo bias is negligible for
+// the small ranges used.
+func (r *fakeDataRand) intn(n int) uint64 {
+	return r.uint64() % uint64(n)
+}
+
+// fillPattern replaces the placeholders of the pattern on input with random
+// characters: '#' with a digit, '%' with a non zero digit and '@' with an
+// uppercase letter.
+func (r *fakeDataRand) fillPattern(pattern string) string {
+	var b strings.Builder
+	b.Grow(len(pattern))
+	for _, c := range pattern {
+		switch c {
+		case '#':
+			b.WriteByte(byte('0' + r.intn(10)))
+		case '%':
+			b.WriteByte(byte('1' + r.intn(9)))
+		case '@':
+			b.WriteByte(byte('A' + r.intn(26)))
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func fakeDataPick(r *fakeDataRand, values []string) string {
+	return values[r.intn(len(values))]
+}
+
+func withFakeDataSuffix(value, suffix string) string {
+	if suffix == "" {
+		return value
+	}
+	return value + " " + suffix
+}
+
+// fakeDataEmailPart returns the lowercase ASCII version of the name on input,
+// with any character not valid for an email local part removed.
+func fakeDataEmailPart(name string) string {
+	name = fakeDataEmailReplacer.Replace(strings.ToLower(name))
+	var b strings.Builder
+	b.Grow(len(name))
+	for _, c := range name {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
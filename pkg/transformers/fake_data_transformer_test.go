@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFakeDataTransformer(t *testing.T) {
+	t.Parallel()
+
+	testDynamicParams := ParameterValues{"seed": map[string]any{"column": "id"}}
+
+	tests := []struct {
+		name          string
+		params        ParameterValues
+		dynamicParams ParameterValues
+
+		wantErr error
+	}{
+		{
+			name:          "ok",
+			params:        ParameterValues{"key": "secret", "kind": "email", "locale": "de_DE", "unique": true},
+			dynamicParams: testDynamicParams,
+		},
+		{
+			name:          "error - no key",
+			params:        ParameterValues{"kind": "email"},
+			dynamicParams: testDynamicParams,
+
+			wantErr: errKeyNotFound,
+		},
+		{
+			name:          "error - missing kind",
+			params:        ParameterValues{"key": "secret"},
+			dynamicParams: testDynamicParams,
+
+			wantErr: errFakeDataInvalidKind,
+		},
+		{
+			name:          "error - invalid kind",
+			params:        ParameterValues{"key": "secret", "kind": "ssn"},
+			dynamicParams: testDynamicParams,
+
+			wantErr: errFakeDataInvalidKind,
+		},
+		{
+			name:          "error - invalid locale",
+			params:        ParameterValues{"key": "secret", "kind": "email", "locale": "xx_XX"},
+			dynamicParams: testDynamicParams,
+
+			wantErr: errFakeDataInvalidLocale,
+		},
+		{
+			name:          "error - invalid unique",
+			params:        ParameterValues{"key": "secret", "kind": "email", "unique": "yes"},
+			dynamicParams: testDynamicParams,
+
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:   "error - missing seed",
+			params: ParameterValues{"key": "secret", "kind": "email"},
+
+			wantErr: errFakeDataMissingSeed,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewFakeDataTransformer(tc.params, tc.dynamicParams)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestFakeDataTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	testDynamicValues := map[string]any{"id": int64(42)}
+
+	tests := []struct {
+		name          string
+		kind          string
+		locale        string
+		value         any
+		dynamicValues map[string]any
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:          "ok - first name",
+			kind:          fakeDataFirstName,
+			value:         "Alice",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "Richard",
+		},
+		{
+			name:          "ok - last name",
+			kind:          fakeDataLastName,
+			value:         "Doe",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "Moore",
+		},
+		{
+			name:          "ok - full name",
+			kind:          fakeDataFullName,
+			value:         "Alice Doe",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "Richard Moore",
+		},
+		{
+			name:          "ok - email matches names",
+			kind:          fakeDataEmail,
+			value:         "alice@acme.io",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "richard.moore@example.com",
+		},
+		{
+			name:          "ok - address",
+			kind:          fakeDataAddress,
+			value:         "1 Infinite Loop",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "137 Elm Road, Riverside, MA 39523",
+		},
+		{
+			name:          "ok - company",
+			kind:          fakeDataCompany,
+			value:         "Acme",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "Lewis Holdings",
+		},
+		{
+			name:          "ok - phone",
+			kind:          fakeDataPhone,
+			value:         "+1 415 123 4567",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "+1 250-555-0150",
+		},
+		{
+			name:          "ok - locale",
+			kind:          fakeDataAddress,
+			locale:        fakeDataLocaleDeDE,
+			value:         "Unter den Linden 1",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "Bergallee 137, 17952 Hamburg",
+		},
+		{
+			name:          "ok - transliterated email",
+			kind:          fakeDataEmail,
+			locale:        fakeDataLocaleFrFR,
+			value:         "alice@acme.io",
+			dynamicValues: testDynamicValues,
+
+			wantValue: "raphael.leroy@example.com",
+		},
+		{
+			name:          "ok - same seed value with different type",
+			kind:          fakeDataFullName,
+			value:         []byte("Alice Doe"),
+			dynamicValues: map[string]any{"id": "42"},
+
+			wantValue: []byte("Richard Moore"),
+		},
+		{
+			name:          "error - unsupported value type",
+			kind:          fakeDataFirstName,
+			value:         42,
+			dynamicValues: testDynamicValues,
+
+			wantErr: ErrUnsupportedValueType,
+		},
+		{
+			name:          "error - seed column not found",
+			kind:          fakeDataFirstName,
+			value:         "Alice",
+			dynamicValues: map[string]any{"other": 1},
+
+			wantErr: ErrInvalidDynamicParameters,
+		},
+		{
+			name:          "error - null seed value",
+			kind:          fakeDataFirstName,
+			value:         "Alice",
+			dynamicValues: map[string]any{"id": nil},
+
+			wantErr: errFakeDataNullSeedValue,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			params := ParameterValues{"key": "secret", "kind": tc.kind}
+			if tc.locale != "" {
+				params["locale"] = tc.locale
+			}
+			transformer, err := NewFakeDataTransformer(params, ParameterValues{"seed": map[string]any{"column": "id"}})
+			require.NoError(t, err)
+
+			got, err := transformer.Transform(context.Background(), NewValue(tc.value, "", tc.dynamicValues))
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, got)
+		})
+	}
+}
+
+func TestFakeDataTransformer_Transform_unique(t *testing.T) {
+	t.Parallel()
+
+	for _, kind := range []string{fakeDataFirstName, fakeDataEmail, fakeDataPhone} {
+		t.Run(kind, func(t *testing.T) {
+			t.Parallel()
+
+			transformer, err := NewFakeDataTransformer(
+				ParameterValues{"key": "secret", "kind": kind, "unique": true},
+				ParameterValues{"seed": map[string]any{"column": "id"}},
+			)
+			require.NoError(t, err)
+
+			transform := func(id int) string {
+				got, err := transformer.Transform(context.Background(), NewValue("value", "", map[string]any{"id": id}))
+				require.NoError(t, err)
+				return got.(string)
+			}
+
+			// the first names have far fewer values than ids, so there are
+			// collisions that need to be suffixed
+			seen := map[string]int{}
+			for id := range 100 {
+				value := transform(id)
+				prevID, found := seen[value]
+				require.False(t, found, fmt.Sprintf("value %q generated for ids %d and %d", value, prevID, id))
+				seen[value] = id
+			}
+
+			// the values are stable for the same seed
+			for value, id := range seen {
+				require.Equal(t, value, transform(id))
+			}
+		})
+	}
+}
@@ -0,0 +1,401 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"unicode"
+
+	"github.com/xataio/pgstream/pkg/transformers/generators"
+)
+
+// FormatPreservingTransformer masks structured identifiers (credit card
+// numbers, SSNs, phone numbers...) keeping their format, so that the masked
+// values still pass the downstream validation. Digits are replaced by digits
+// and letters by letters of the same case, while separators are kept in
+// place. A number of leading and trailing characters can be preserved (i.e.
+// the last 4 digits of a card number).
+type FormatPreservingTransformer struct {
+	format         string
+	preservePrefix int
+	preserveSuffix int
+	onInvalid      string
+	generator      generators.Generator
+	dynamicParams  map[string]*DynamicParameter
+}
+
+const (
+	fpCreditCardFormat  = "credit_card"
+	fpSSNFormat         = "ssn"
+	fpPhoneNumberFormat = "phone_number"
+	fpGenericFormat     = "generic"
+
+	fpOnInvalidPassthrough = "passthrough"
+	fpOnInvalidNull        = "null"
+	fpOnInvalidError       = "error"
+
+	seedParam = "seed"
+
+	// fpGeneratorSize is the number of bytes produced by every call to the
+	// generator. Longer values chain multiple calls.
+	fpGeneratorSize = 32
+)
+
+var (
+	errInvalidFormatPreservingFormat    = errors.New("format_preserving: format must be one of 'credit_card', 'ssn', 'phone_number' or 'generic'")
+	errInvalidFormatPreservingOnInvalid = errors.New("format_preserving: on_invalid must be one of 'passthrough', 'null' or 'error'")
+	errInvalidFormatPreservingPreserve  = errors.New("format_preserving: preserve_prefix and preserve_suffix must be greater than or equal to 0")
+	errInvalidFormatPreservingGenerator = errors.New("format_preserving: generator must be one of 'random' or 'deterministic'")
+	errValueDoesNotMatchFormat          = errors.New("format_preserving: value doesn't match the expected format")
+	errMissingSeedValue                 = errors.New("format_preserving: missing value for seed dynamic parameter")
+
+	formatPreservingCompatibleTypes = []SupportedDataType{
+		StringDataType,
+		ByteArrayDataType,
+	}
+	formatPreservingParams = []Parameter{
+		{
+			Name:          "format",
+			SupportedType: "string",
+			Default:       fpGenericFormat,
+			Dynamic:       false,
+			Required:      false,
+			Values:        []any{fpCreditCardFormat, fpSSNFormat, fpPhoneNumberFormat, fpGenericFormat},
+		},
+		{
+			Name:          "preserve_prefix",
+			SupportedType: "int",
+			Default:       0,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "preserve_suffix",
+			SupportedType: "int",
+			Default:       0,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "on_invalid",
+			SupportedType: "string",
+			Default:       fpOnInvalidPassthrough,
+			Dynamic:       false,
+			Required:      false,
+			Values:        []any{fpOnInvalidPassthrough, fpOnInvalidNull, fpOnInvalidError},
+		},
+		{
+			Name:          "generator",
+			SupportedType: "string",
+			Default:       "random",
+			Dynamic:       false,
+			Required:      false,
+			Values:        []any{"random", "deterministic"},
+		},
+		{
+			Name:          seedParam,
+			SupportedType: "any",
+			Default:       nil,
+			Dynamic:       true,
+			Required:      false,
+		},
+	}
+)
+
+// NewFormatPreservingTransformer creates a new FormatPreservingTransformer.
+// When the deterministic generator is used, the mask is derived from the
+// seed dynamic parameter if provided (i.e. the primary key column), or from
+// the value itself otherwise.
+func NewFormatPreservingTransformer(params, dynamicParams ParameterValues) (*FormatPreservingTransformer, error) {
+	format, err := FindParameterWithDefault(params, "format", fpGenericFormat)
+	if err != nil {
+		return nil, fmt.Errorf("format_preserving: format must be a string: %w", err)
+	}
+	switch format {
+	case fpCreditCardFormat, fpSSNFormat, fpPhoneNumberFormat, fpGenericFormat:
+	default:
+		return nil, errInvalidFormatPreservingFormat
+	}
+
+	preservePrefix, err := FindParameterWithDefault(params, "preserve_prefix", 0)
+	if err != nil {
+		return nil, fmt.Errorf("format_preserving: preserve_prefix must be an integer: %w", err)
+	}
+	preserveSuffix, err := FindParameterWithDefault(params, "preserve_suffix", 0)
+	if err != nil {
+		return nil, fmt.Errorf("format_preserving: preserve_suffix must be an integer: %w", err)
+	}
+	if preservePrefix < 0 || preserveSuffix < 0 {
+		return nil, errInvalidFormatPreservingPreserve
+	}
+
+	onInvalid, err := FindParameterWithDefault(params, "on_invalid", fpOnInvalidPassthrough)
+	if err != nil {
+		return nil, fmt.Errorf("format_preserving: on_invalid must be a string: %w", err)
+	}
+	switch onInvalid {
+	case fpOnInvalidPassthrough, fpOnInvalidNull, fpOnInvalidError:
+	default:
+		return nil, errInvalidFormatPreservingOnInvalid
+	}
+
+	generatorType, err := FindParameterWithDefault(params, "generator", "random")
+	if err != nil {
+		return nil, fmt.Errorf("format_preserving: generator must be a string: %w", err)
+	}
+	var generator generators.Generator
+	switch generatorType {
+	case "random":
+		generator = generators.NewRandomBytesGenerator(fpGeneratorSize)
+	case "deterministic":
+		generator, err = generators.NewDeterministicBytesGenerator(fpGeneratorSize)
+		if err != nil {
+			return nil, fmt.Errorf("format_preserving: error creating deterministic generator: %w", err)
+		}
+	default:
+		return nil, errInvalidFormatPreservingGenerator
+	}
+
+	dynamicParamMap, err := ParseDynamicParameters(dynamicParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FormatPreservingTransformer{
+		format:         format,
+		preservePrefix: preservePrefix,
+		preserveSuffix: preserveSuffix,
+		onInvalid:      onInvalid,
+		generator:      generator,
+		dynamicParams:  dynamicParamMap,
+	}, nil
+}
+
+func (t *FormatPreservingTransformer) Transform(_ context.Context, value Value) (any, error) {
+	var v string
+	switch val := value.TransformValue.(type) {
+	case string:
+		v = val
+	case []byte:
+		v = string(val)
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+
+	masked := []rune(v)
+	positions, ok := t.maskablePositions(masked)
+	if !ok {
+		switch t.onInvalid {
+		case fpOnInvalidNull:
+			return nil, nil
+		case fpOnInvalidError:
+			return nil, errValueDoesNotMatchFormat
+		default:
+			return value.TransformValue, nil
+		}
+	}
+
+	// the preserved characters are not masked
+	if t.preservePrefix+t.preserveSuffix >= len(positions) {
+		return v, nil
+	}
+	preserved := make([]bool, len(positions))
+	for i := range positions {
+		preserved[i] = i < t.preservePrefix || i >= len(positions)-t.preserveSuffix
+	}
+
+	seed, err := t.seed(v, value.DynamicValues)
+	if err != nil {
+		return nil, err
+	}
+	data, err := t.generate(seed, len(positions))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, pos := range positions {
+		if !preserved[i] {
+			masked[pos] = maskRune(masked[pos], data[i])
+		}
+	}
+
+	switch t.format {
+	case fpCreditCardFormat:
+		fixLuhnCheckDigit(masked, positions, preserved)
+	case fpSSNFormat:
+		fixSSN(masked, positions, preserved)
+	}
+
+	return string(masked), nil
+}
+
+func (t *FormatPreservingTransformer) CompatibleTypes() []SupportedDataType {
+	return formatPreservingCompatibleTypes
+}
+
+func (t *FormatPreservingTransformer) Type() TransformerType {
+	return FormatPreserving
+}
+
+func (t *FormatPreservingTransformer) IsDynamic() bool {
+	return len(t.dynamicParams) > 0
+}
+
+func (t *FormatPreservingTransformer) Close() error {
+	return nil
+}
+
+func FormatPreservingTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: formatPreservingCompatibleTypes,
+		Parameters:     formatPreservingParams,
+	}
+}
+
+// maskablePositions returns the positions of the characters to be masked in
+// the value on input, and false if the value doesn't match the configured
+// format.
+func (t *FormatPreservingTransformer) maskablePositions(value []rune) ([]int, bool) {
+	positions := make([]int, 0, len(value))
+	for i, r := range value {
+		switch {
+		case unicode.IsDigit(r) && r < unicode.MaxASCII:
+			positions = append(positions, i)
+		case t.format == fpGenericFormat:
+			if unicode.IsLetter(r) {
+				positions = append(positions, i)
+			}
+		case r == ' ' || r == '-':
+		case t.format == fpPhoneNumberFormat && (r == '.' || r == '(' || r == ')' || (r == '+' && i == 0)):
+		default:
+			return nil, false
+		}
+	}
+
+	switch t.format {
+	case fpCreditCardFormat:
+		return positions, len(positions) >= 12 && len(positions) <= 19
+	case fpSSNFormat:
+		return positions, len(positions) == 9
+	case fpPhoneNumberFormat:
+		return positions, len(positions) >= 7 && len(positions) <= 15
+	default:
+		return positions, true
+	}
+}
+
+func (t *FormatPreservingTransformer) seed(value string, dynamicValues map[string]any) ([]byte, error) {
+	seedDynamicParam := t.dynamicParams[seedParam]
+	if seedDynamicParam == nil {
+		return []byte(value), nil
+	}
+	seed, found := dynamicValues[seedDynamicParam.Column]
+	if !found || seed == nil {
+		return nil, errMissingSeedValue
+	}
+	switch s := seed.(type) {
+	case []byte:
+		return s, nil
+	default:
+		return fmt.Appendf(nil, "%v", s), nil
+	}
+}
+
+// generate returns n bytes from the generator. When more bytes than the
+// generator size are needed, the previous output is used as the input for the
+// next call.
+func (t *FormatPreservingTransformer) generate(seed []byte, n int) ([]byte, error) {
+	data := make([]byte, 0, n+fpGeneratorSize)
+	for len(data) < n {
+		out, err := t.generator.Generate(seed)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, out...)
+		seed = out
+	}
+	return data[:n], nil
+}
+
+// maskRune returns a character of the same class as the one on input, based
+// on the random byte provided.
+func maskRune(r rune, b byte) rune {
+	switch {
+	case unicode.IsDigit(r):
+		return rune('0' + b%10)
+	case unicode.IsUpper(r):
+		return rune('A' + b%26)
+	case unicode.IsLetter(r):
+		return rune('a' + b%26)
+	default:
+		return r
+	}
+}
+
+// fixLuhnCheckDigit updates the rightmost masked digit so that the card
+// number passes the Luhn check. It's usually the check digit itself, unless
+// it's preserved.
+func fixLuhnCheckDigit(value []rune, positions []int, preserved []bool) {
+	fixed := -1
+	sum := 0
+	for i := len(positions) - 1; i >= 0; i-- {
+		if fixed == -1 && !preserved[i] {
+			fixed = i
+			continue
+		}
+		sum += luhnDigitValue(int(value[positions[i]]-'0'), len(positions)-1-i)
+	}
+	if fixed == -1 {
+		return
+	}
+
+	for d := range 10 {
+		if (sum+luhnDigitValue(d, len(positions)-1-fixed))%10 == 0 {
+			value[positions[fixed]] = rune('0' + d)
+			return
+		}
+	}
+}
+
+// luhnDigitValue returns the contribution to the Luhn sum of the digit at the
+// position on input, counting from the rightmost digit.
+func luhnDigitValue(digit, position int) int {
+	if position%2 == 0 {
+		return digit
+	}
+	digit *= 2
+	if digit > 9 {
+		digit -= 9
+	}
+	return digit
+}
+
+// fixSSN updates the masked digits of the SSN on input so that it's not in
+// one of the ranges never assigned: area number 000, 666 or 900-999, group
+// number 00 and serial number 0000.
+func fixSSN(value []rune, positions []int, preserved []bool) {
+	digit := func(i int) rune { return value[positions[i]] }
+	set := func(i int, r rune) bool {
+		if preserved[i] {
+			return false
+		}
+		value[positions[i]] = r
+		return true
+	}
+
+	if digit(0) == '9' {
+		set(0, '8')
+	}
+	area := string([]rune{digit(0), digit(1), digit(2)})
+	if area == "000" || area == "666" {
+		_ = set(2, '1') || set(1, '1') || set(0, '1')
+	}
+	if digit(3) == '0' && digit(4) == '0' {
+		_ = set(4, '1') || set(3, '1')
+	}
+	if digit(5) == '0' && digit(6) == '0' && digit(7) == '0' && digit(8) == '0' {
+		_ = set(8, '1') || set(7, '1') || set(6, '1') || set(5, '1')
+	}
+}
@@ -0,0 +1,320 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFormatPreservingTransformer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		params        ParameterValues
+		dynamicParams ParameterValues
+
+		wantErr error
+	}{
+		{
+			name:   "ok - default",
+			params: ParameterValues{},
+		},
+		{
+			name: "ok - with seed",
+			params: ParameterValues{
+				"format":          "credit_card",
+				"preserve_suffix": 4,
+				"generator":       "deterministic",
+			},
+			dynamicParams: ParameterValues{
+				"seed": map[string]any{"column": "id"},
+			},
+		},
+		{
+			name:    "error - invalid format",
+			params:  ParameterValues{"format": "iban"},
+			wantErr: errInvalidFormatPreservingFormat,
+		},
+		{
+			name:    "error - invalid preserve prefix type",
+			params:  ParameterValues{"preserve_prefix": "4"},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - negative preserve suffix",
+			params:  ParameterValues{"preserve_suffix": -1},
+			wantErr: errInvalidFormatPreservingPreserve,
+		},
+		{
+			name:    "error - invalid on invalid",
+			params:  ParameterValues{"on_invalid": "skip"},
+			wantErr: errInvalidFormatPreservingOnInvalid,
+		},
+		{
+			name:    "error - invalid generator",
+			params:  ParameterValues{"generator": "seeded"},
+			wantErr: errInvalidFormatPreservingGenerator,
+		},
+		{
+			name:          "error - invalid dynamic parameters",
+			params:        ParameterValues{},
+			dynamicParams: ParameterValues{"seed": "id"},
+			wantErr:       ErrInvalidDynamicParameters,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewFormatPreservingTransformer(tc.params, tc.dynamicParams)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestFormatPreservingTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		params        ParameterValues
+		dynamicParams ParameterValues
+		value         Value
+
+		wantPattern string
+		wantValue   any
+		wantErr     error
+	}{
+		{
+			name:   "ok - credit card with separators",
+			params: ParameterValues{"format": "credit_card"},
+			value:  Value{TransformValue: "4111-1111-1111-1111"},
+
+			wantPattern: `^\d{4}-\d{4}-\d{4}-\d{4}$`,
+		},
+		{
+			name:   "ok - credit card with preserved suffix",
+			params: ParameterValues{"format": "credit_card", "preserve_suffix": 4, "generator": "deterministic"},
+			value:  Value{TransformValue: []byte("4111 1111 1111 1111")},
+
+			wantPattern: `^\d{4} \d{4} \d{4} 1111$`,
+		},
+		{
+			name:   "ok - credit card with preserved prefix and suffix",
+			params: ParameterValues{"format": "credit_card", "preserve_prefix": 6, "preserve_suffix": 4},
+			value:  Value{TransformValue: "378282246310005"},
+
+			wantPattern: `^378282\d{5}0005$`,
+		},
+		{
+			name:   "ok - all characters preserved",
+			params: ParameterValues{"format": "credit_card", "preserve_prefix": 10, "preserve_suffix": 10},
+			value:  Value{TransformValue: "4111111111111111"},
+
+			wantValue: "4111111111111111",
+		},
+		{
+			name:   "ok - ssn",
+			params: ParameterValues{"format": "ssn", "preserve_suffix": 4},
+			value:  Value{TransformValue: "123-45-6789"},
+
+			wantPattern: `^\d{3}-\d{2}-6789$`,
+		},
+		{
+			name:   "ok - phone number",
+			params: ParameterValues{"format": "phone_number", "preserve_prefix": 1},
+			value:  Value{TransformValue: "+1 (555) 123.4567"},
+
+			wantPattern: `^\+1 \(\d{3}\) \d{3}\.\d{4}$`,
+		},
+		{
+			name:   "ok - generic",
+			params: ParameterValues{"preserve_prefix": 2},
+			value:  Value{TransformValue: "AB-12cd/é"},
+
+			wantPattern: `^AB-\d{2}[a-z]{2}/[a-z]$`,
+		},
+		{
+			name:   "ok - invalid format passthrough",
+			params: ParameterValues{"format": "credit_card"},
+			value:  Value{TransformValue: "not a card"},
+
+			wantValue: "not a card",
+		},
+		{
+			name:   "ok - invalid format null",
+			params: ParameterValues{"format": "ssn", "on_invalid": "null"},
+			value:  Value{TransformValue: "123-45-678"},
+
+			wantValue: nil,
+		},
+		{
+			name:   "error - invalid format",
+			params: ParameterValues{"format": "phone_number", "on_invalid": "error"},
+			value:  Value{TransformValue: "555-CALL-NOW"},
+
+			wantErr: errValueDoesNotMatchFormat,
+		},
+		{
+			name:          "error - missing seed value",
+			params:        ParameterValues{"generator": "deterministic"},
+			dynamicParams: ParameterValues{"seed": map[string]any{"column": "id"}},
+			value:         Value{TransformValue: "abc", DynamicValues: map[string]any{}},
+
+			wantErr: errMissingSeedValue,
+		},
+		{
+			name:   "error - unsupported value type",
+			params: ParameterValues{},
+			value:  Value{TransformValue: 4111111111111111},
+
+			wantErr: ErrUnsupportedValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transformer, err := NewFormatPreservingTransformer(tc.params, tc.dynamicParams)
+			require.NoError(t, err)
+
+			got, err := transformer.Transform(context.Background(), tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantPattern == "" {
+				require.Equal(t, tc.wantValue, got)
+				return
+			}
+
+			masked, ok := got.(string)
+			require.True(t, ok)
+			require.Regexp(t, regexp.MustCompile(tc.wantPattern), masked)
+			if tc.params["format"] == fpCreditCardFormat {
+				require.True(t, isLuhnValid(masked), masked)
+			}
+		})
+	}
+}
+
+func TestFormatPreservingTransformer_Transform_deterministic(t *testing.T) {
+	t.Parallel()
+
+	transformer, err := NewFormatPreservingTransformer(
+		ParameterValues{"format": "credit_card", "generator": "deterministic"},
+		ParameterValues{"seed": map[string]any{"column": "id"}},
+	)
+	require.NoError(t, err)
+
+	transform := func(card string, id any) any {
+		got, err := transformer.Transform(context.Background(), Value{
+			TransformValue: card,
+			DynamicValues:  map[string]any{"id": id},
+		})
+		require.NoError(t, err)
+		return got
+	}
+
+	// the same seed produces the same mask, different seeds produce different
+	// masks for the same value
+	require.Equal(t, transform("4111111111111111", int64(1)), transform("4111111111111111", int64(1)))
+	require.NotEqual(t, transform("4111111111111111", int64(1)), transform("4111111111111111", int64(2)))
+
+	// values longer than the generator size are masked in full
+	generic, err := NewFormatPreservingTransformer(ParameterValues{"generator": "deterministic"}, nil)
+	require.NoError(t, err)
+	long := "abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyz"
+	got, err := generic.Transform(context.Background(), Value{TransformValue: long})
+	require.NoError(t, err)
+	require.Regexp(t, regexp.MustCompile(`^[a-z]{52}$`), got)
+	require.NotEqual(t, long[32:], got.(string)[32:])
+}
+
+func TestFixLuhnCheckDigit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		value     string
+		preserved []bool
+
+		wantValue string
+	}{
+		{
+			name:      "check digit masked",
+			value:     "4111111111111112",
+			wantValue: "4111111111111111",
+		},
+		{
+			name:      "check digit preserved",
+			value:     "4111111111121111",
+			preserved: []bool{false, false, false, false, false, false, false, false, false, false, false, false, true, true, true, true},
+			wantValue: "4111111111111111",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value := []rune(tc.value)
+			positions := make([]int, len(value))
+			for i := range positions {
+				positions[i] = i
+			}
+			preserved := tc.preserved
+			if preserved == nil {
+				preserved = make([]bool, len(value))
+			}
+
+			fixLuhnCheckDigit(value, positions, preserved)
+			require.Equal(t, tc.wantValue, string(value))
+		})
+	}
+}
+
+func TestFixSSN(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value string
+
+		wantValue string
+	}{
+		{name: "valid", value: "123456789", wantValue: "123456789"},
+		{name: "area 9xx", value: "912456789", wantValue: "812456789"},
+		{name: "area 000", value: "000456789", wantValue: "001456789"},
+		{name: "area 666", value: "666456789", wantValue: "661456789"},
+		{name: "group 00", value: "123006789", wantValue: "123016789"},
+		{name: "serial 0000", value: "123450000", wantValue: "123450001"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value := []rune(tc.value)
+			positions := []int{0, 1, 2, 3, 4, 5, 6, 7, 8}
+			fixSSN(value, positions, make([]bool, len(positions)))
+			require.Equal(t, tc.wantValue, string(value))
+		})
+	}
+}
+
+func isLuhnValid(value string) bool {
+	sum, position := 0, 0
+	for i := len(value) - 1; i >= 0; i-- {
+		if value[i] < '0' || value[i] > '9' {
+			continue
+		}
+		sum += luhnDigitValue(int(value[i]-'0'), position)
+		position++
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,376 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// HMACTransformer pseudonymizes values by replacing them with their keyed
+// HMAC-SHA256 hash. The same input always produces the same output for a
+// given key, so the transformed values can still be used to join tables.
+type HMACTransformer struct {
+	key           []byte
+	outputFormat  string
+	integerSize   int
+	preserveNulls bool
+	preserveEmpty bool
+}
+
+const (
+	hmacHexFormat     = "hex"
+	hmacBase64Format  = "base64"
+	hmacIntegerFormat = "integer"
+
+	uuidTypeName = "uuid"
+)
+
+var (
+	errKeyNotFound             = errors.New("one of key, key_env or key_file must be provided")
+	errMultipleKeys            = errors.New("only one of key, key_env or key_file can be provided")
+	errEmptyKey                = errors.New("key cannot be empty")
+	errInvalidHMACOutputFormat = errors.New("hmac: output_format must be one of 'hex', 'base64' or 'integer'")
+	errInvalidHMACIntegerSize  = errors.New("hmac: integer_size must be one of 2, 4 or 8")
+	errUnsupportedHMACNumeric  = errors.New("hmac: unsupported numeric value")
+	hmacCompatibleTypes        = []SupportedDataType{
+		StringDataType,
+		CitextDataType,
+		ByteArrayDataType,
+		UUIDDataType,
+		UInt8ArrayOf16DataType,
+		Integer16DataType,
+		Integer32DataType,
+		Integer64DataType,
+		Float32DataType,
+		Float64DataType,
+		NumericDataType,
+	}
+	hmacParams = []Parameter{
+		{
+			Name:          "key",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_env",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_file",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "output_format",
+			SupportedType: "string",
+			Default:       hmacHexFormat,
+			Dynamic:       false,
+			Required:      false,
+			Values:        []any{hmacHexFormat, hmacBase64Format, hmacIntegerFormat},
+		},
+		{
+			Name:          "integer_size",
+			SupportedType: "int",
+			Default:       8,
+			Dynamic:       false,
+			Required:      false,
+			Values:        []any{2, 4, 8},
+		},
+		{
+			Name:          "preserve_nulls",
+			SupportedType: "boolean",
+			Default:       true,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "preserve_empty",
+			SupportedType: "boolean",
+			Default:       true,
+			Dynamic:       false,
+			Required:      false,
+		},
+	}
+)
+
+// NewHMACTransformer creates a new HMACTransformer. The key can be provided
+// directly, or read from an environment variable or a file (i.e. a mounted
+// secret), to avoid having it in the configuration.
+func NewHMACTransformer(params ParameterValues) (*HMACTransformer, error) {
+	key, err := getSecretKey(params)
+	if err != nil {
+		return nil, fmt.Errorf("hmac: %w", err)
+	}
+
+	outputFormat, err := FindParameterWithDefault(params, "output_format", hmacHexFormat)
+	if err != nil {
+		return nil, fmt.Errorf("hmac: output_format must be a string: %w", err)
+	}
+	switch outputFormat {
+	case hmacHexFormat, hmacBase64Format, hmacIntegerFormat:
+	default:
+		return nil, errInvalidHMACOutputFormat
+	}
+
+	integerSize, err := FindParameterWithDefault(params, "integer_size", 8)
+	if err != nil {
+		return nil, fmt.Errorf("hmac: integer_size must be an integer: %w", err)
+	}
+	switch integerSize {
+	case 2, 4, 8:
+	default:
+		return nil, errInvalidHMACIntegerSize
+	}
+
+	preserveNulls, err := FindParameterWithDefault(params, "preserve_nulls", true)
+	if err != nil {
+		return nil, fmt.Errorf("hmac: preserve_nulls must be a boolean: %w", err)
+	}
+
+	preserveEmpty, err := FindParameterWithDefault(params, "preserve_empty", true)
+	if err != nil {
+		return nil, fmt.Errorf("hmac: preserve_empty must be a boolean: %w", err)
+	}
+
+	return &HMACTransformer{
+		key:           key,
+		outputFormat:  outputFormat,
+		integerSize:   integerSize,
+		preserveNulls: preserveNulls,
+		preserveEmpty: preserveEmpty,
+	}, nil
+}
+
+// Transform returns the HMAC of the input value, in the configured output
+// format. Values are hashed using their text representation, so that the same
+// value produces the same output regardless of how it was decoded.
+func (t *HMACTransformer) Transform(_ context.Context, value Value) (any, error) {
+	input, isNull, err := hmacInput(value.TransformValue)
+	if err != nil {
+		return nil, err
+	}
+	if isNull && t.preserveNulls {
+		return nil, nil
+	}
+	if !isNull && len(input) == 0 && t.preserveEmpty {
+		return value.TransformValue, nil
+	}
+
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write(input)
+	sum := mac.Sum(nil)
+
+	// uuid columns can only hold uuid values, so the hash is truncated to
+	// fit, regardless of the output format.
+	if value.TransformType == uuidTypeName {
+		hashUUID, err := uuid.FromBytes(sum[:16])
+		if err != nil {
+			return nil, err
+		}
+		return hashUUID.String(), nil
+	}
+
+	switch t.outputFormat {
+	case hmacBase64Format:
+		return base64.StdEncoding.EncodeToString(sum), nil
+	case hmacIntegerFormat:
+		// keep the sign bit unset so that the value is always positive and
+		// fits in the signed integer type of the configured size
+		bits := t.integerSize*8 - 1
+		return int64(binary.BigEndian.Uint64(sum[:8]) >> (64 - bits)), nil
+	default:
+		return hex.EncodeToString(sum), nil
+	}
+}
+
+func (t *HMACTransformer) CompatibleTypes() []SupportedDataType {
+	return hmacCompatibleTypes
+}
+
+// OutputType returns the type of the hash in the configured output format.
+func (t *HMACTransformer) OutputType() SupportedDataType {
+	if t.outputFormat == hmacIntegerFormat {
+		return Integer64DataType
+	}
+	return StringDataType
+}
+
+func (t *HMACTransformer) Type() TransformerType {
+	return HMAC
+}
+
+func (t *HMACTransformer) IsDynamic() bool {
+	return false
+}
+
+func (t *HMACTransformer) Close() error {
+	return nil
+}
+
+func HMACTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: hmacCompatibleTypes,
+		Parameters:     hmacParams,
+	}
+}
+
+// getSecretKey returns the secret key provided either directly in the key
+// parameter, or in the environment variable or file named by the key_env and
+// key_file parameters.
+func getSecretKey(params ParameterValues) ([]byte, error) {
+	key, err := getSecretParameter(params, "key", errKeyNotFound, errMultipleKeys)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, errEmptyKey
+	}
+	return []byte(key), nil
+}
+
+// getSecretParameter returns the value of the parameter with the name on
+// input, provided either directly, or in the environment variable or file
+// named by the <name>_env and <name>_file parameters. Exactly one of them must
+// be provided, otherwise the not found or multiple errors on input are
+// returned.
+func getSecretParameter(params ParameterValues, name string, errNotFound, errMultiple error) (string, error) {
+	value, valueFound, err := FindParameter[string](params, name)
+	if err != nil {
+		return "", fmt.Errorf("%s must be a string: %w", name, err)
+	}
+	envName := name + "_env"
+	valueEnv, valueEnvFound, err := FindParameter[string](params, envName)
+	if err != nil {
+		return "", fmt.Errorf("%s must be a string: %w", envName, err)
+	}
+	fileName := name + "_file"
+	valueFile, valueFileFound, err := FindParameter[string](params, fileName)
+	if err != nil {
+		return "", fmt.Errorf("%s must be a string: %w", fileName, err)
+	}
+
+	found := 0
+	for _, f := range []bool{valueFound, valueEnvFound, valueFileFound} {
+		if f {
+			found++
+		}
+	}
+	switch found {
+	case 0:
+		return "", errNotFound
+	case 1:
+	default:
+		return "", errMultiple
+	}
+
+	switch {
+	case valueEnvFound:
+		value = os.Getenv(valueEnv)
+	case valueFileFound:
+		content, err := os.ReadFile(valueFile)
+		if err != nil {
+			return "", fmt.Errorf("reading %s: %w", fileName, err)
+		}
+		value = strings.TrimRight(string(content), "\r\n")
+	}
+	return value, nil
+}
+
+// hmacInput returns the canonical text representation of the value on input
+// to be hashed, and whether the value is null.
+func hmacInput(value any) ([]byte, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, true, nil
+	case string:
+		return []byte(v), false, nil
+	case []byte:
+		return v, false, nil
+	case [16]uint8:
+		return []byte(uuid.UUID(v).String()), false, nil
+	case uuid.UUID:
+		return []byte(v.String()), false, nil
+	case pgtype.UUID:
+		if !v.Valid {
+			return nil, true, nil
+		}
+		return []byte(uuid.UUID(v.Bytes).String()), false, nil
+	case int:
+		return []byte(strconv.FormatInt(int64(v), 10)), false, nil
+	case int16:
+		return []byte(strconv.FormatInt(int64(v), 10)), false, nil
+	case int32:
+		return []byte(strconv.FormatInt(int64(v), 10)), false, nil
+	case int64:
+		return []byte(strconv.FormatInt(v, 10)), false, nil
+	case float32:
+		return []byte(strconv.FormatFloat(float64(v), 'f', -1, 32)), false, nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), false, nil
+	case pgtype.Numeric:
+		if !v.Valid {
+			return nil, true, nil
+		}
+		s, err := numericText(v)
+		if err != nil {
+			return nil, false, err
+		}
+		return []byte(s), false, nil
+	default:
+		return nil, false, ErrUnsupportedValueType
+	}
+}
+
+// numericText returns the decimal representation of the numeric on input
+// without trailing zeros, so that it matches the representation of the same
+// value when decoded as a float (i.e. 1.50 and 1.5 are hashed the same way).
+func numericText(n pgtype.Numeric) (string, error) {
+	if n.NaN {
+		return "NaN", nil
+	}
+	if n.InfinityModifier != pgtype.Finite || n.Int == nil {
+		return "", errUnsupportedHMACNumeric
+	}
+
+	if n.Exp >= 0 {
+		exp := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n.Exp)), nil)
+		return new(big.Int).Mul(n.Int, exp).String(), nil
+	}
+
+	digits := new(big.Int).Abs(n.Int).String()
+	scale := int(-n.Exp)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	intPart, fracPart := digits[:len(digits)-scale], strings.TrimRight(digits[len(digits)-scale:], "0")
+
+	s := intPart
+	if fracPart != "" {
+		s += "." + fracPart
+	}
+	if n.Int.Sign() < 0 && s != "0" {
+		s = "-" + s
+	}
+	return s, nil
+}
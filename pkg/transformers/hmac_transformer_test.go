@@ -0,0 +1,318 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHMACTransformer(t *testing.T) {
+	t.Parallel()
+
+	keyFile := filepath.Join(t.TempDir(), "hmac_key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("file-key\n"), 0o600))
+	emptyKeyFile := filepath.Join(t.TempDir(), "empty_key")
+	require.NoError(t, os.WriteFile(emptyKeyFile, []byte("\n"), 0o600))
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+
+		wantKey []byte
+		wantErr error
+	}{
+		{
+			name:   "ok - key",
+			params: ParameterValues{"key": "secret"},
+
+			wantKey: []byte("secret"),
+		},
+		{
+			name:   "ok - key file",
+			params: ParameterValues{"key_file": keyFile, "output_format": "integer", "integer_size": 4},
+
+			wantKey: []byte("file-key"),
+		},
+		{
+			name:   "error - no key",
+			params: ParameterValues{},
+
+			wantErr: errKeyNotFound,
+		},
+		{
+			name:   "error - multiple keys",
+			params: ParameterValues{"key": "secret", "key_file": keyFile},
+
+			wantErr: errMultipleKeys,
+		},
+		{
+			name:   "error - empty key file",
+			params: ParameterValues{"key_file": emptyKeyFile},
+
+			wantErr: errEmptyKey,
+		},
+		{
+			name:   "error - key env not set",
+			params: ParameterValues{"key_env": "PGSTREAM_TEST_HMAC_KEY_NOT_SET"},
+
+			wantErr: errEmptyKey,
+		},
+		{
+			name:   "error - invalid key type",
+			params: ParameterValues{"key": 123},
+
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:   "error - invalid output format",
+			params: ParameterValues{"key": "secret", "output_format": "base32"},
+
+			wantErr: errInvalidHMACOutputFormat,
+		},
+		{
+			name:   "error - invalid integer size",
+			params: ParameterValues{"key": "secret", "integer_size": 3},
+
+			wantErr: errInvalidHMACIntegerSize,
+		},
+		{
+			name:   "error - invalid preserve nulls",
+			params: ParameterValues{"key": "secret", "preserve_nulls": "yes"},
+
+			wantErr: ErrInvalidParameters,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transformer, err := NewHMACTransformer(tc.params)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			require.Equal(t, tc.wantKey, transformer.key)
+		})
+	}
+}
+
+func TestNewHMACTransformer_keyEnv(t *testing.T) {
+	t.Setenv("PGSTREAM_TEST_HMAC_KEY", "env-key")
+
+	transformer, err := NewHMACTransformer(ParameterValues{"key_env": "PGSTREAM_TEST_HMAC_KEY"})
+	require.NoError(t, err)
+	require.Equal(t, []byte("env-key"), transformer.key)
+}
+
+func TestHMACTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	// HMAC-SHA256 of "alice@example.com" with key "secret"
+	const testHash = "a398d49ce1980b3642bc4dbd110121e3c953e1eadb497d50dea23e9611f83ee7"
+	const testUUID = "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+		value  Value
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:   "ok - hex",
+			params: ParameterValues{"key": "secret"},
+			value:  Value{TransformValue: "alice@example.com", TransformType: "text"},
+
+			wantValue: testHash,
+		},
+		{
+			name:   "ok - bytes",
+			params: ParameterValues{"key": "secret"},
+			value:  Value{TransformValue: []byte("alice@example.com"), TransformType: "bytea"},
+
+			wantValue: testHash,
+		},
+		{
+			name:   "ok - base64",
+			params: ParameterValues{"key": "secret", "output_format": "base64"},
+			value:  Value{TransformValue: "alice@example.com", TransformType: "text"},
+
+			wantValue: "o5jUnOGYCzZCvE29EQEh48lT4erbSX1Q3qI+lhH4Puc=",
+		},
+		{
+			name:   "ok - integer",
+			params: ParameterValues{"key": "secret", "output_format": "integer"},
+			value:  Value{TransformValue: "alice@example.com", TransformType: "text"},
+
+			wantValue: int64(0xa398d49ce1980b36 >> 1),
+		},
+		{
+			name:   "ok - integer with size",
+			params: ParameterValues{"key": "secret", "output_format": "integer", "integer_size": 2},
+			value:  Value{TransformValue: "alice@example.com", TransformType: "text"},
+
+			wantValue: int64(0xa398 >> 1),
+		},
+		{
+			name:   "ok - uuid column",
+			params: ParameterValues{"key": "secret", "output_format": "base64"},
+			value:  Value{TransformValue: "alice@example.com", TransformType: "uuid"},
+
+			wantValue: "a398d49c-e198-0b36-42bc-4dbd110121e3",
+		},
+		{
+			name:   "ok - null preserved",
+			params: ParameterValues{"key": "secret"},
+			value:  Value{TransformValue: nil, TransformType: "text"},
+
+			wantValue: nil,
+		},
+		{
+			name:   "ok - null not preserved",
+			params: ParameterValues{"key": "secret", "preserve_nulls": false, "preserve_empty": false},
+			value:  Value{TransformValue: pgtype.UUID{}, TransformType: "text"},
+
+			// HMAC-SHA256 of an empty input with key "secret"
+			wantValue: "f9e66e179b6747ae54108f82f8ade8b3c25d76fd30afde6c395822c530196169",
+		},
+		{
+			name:   "ok - empty string preserved",
+			params: ParameterValues{"key": "secret"},
+			value:  Value{TransformValue: "", TransformType: "text"},
+
+			wantValue: "",
+		},
+		{
+			name:   "ok - empty string not preserved",
+			params: ParameterValues{"key": "secret", "preserve_empty": false},
+			value:  Value{TransformValue: "", TransformType: "text"},
+
+			wantValue: "f9e66e179b6747ae54108f82f8ade8b3c25d76fd30afde6c395822c530196169",
+		},
+		{
+			name:   "error - unsupported value type",
+			params: ParameterValues{"key": "secret"},
+			value:  Value{TransformValue: true, TransformType: "boolean"},
+
+			wantErr: ErrUnsupportedValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transformer, err := NewHMACTransformer(tc.params)
+			require.NoError(t, err)
+
+			got, err := transformer.Transform(context.Background(), tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, got)
+		})
+	}
+
+	t.Run("ok - same value with different representations", func(t *testing.T) {
+		t.Parallel()
+
+		transformer, err := NewHMACTransformer(ParameterValues{"key": "secret"})
+		require.NoError(t, err)
+
+		equivalentValues := [][]Value{
+			{
+				{TransformValue: testUUID, TransformType: "uuid"},
+				{TransformValue: [16]uint8(uuid.MustParse(testUUID)), TransformType: "uuid"},
+				{TransformValue: uuid.MustParse(testUUID), TransformType: "uuid"},
+				{TransformValue: pgtype.UUID{Bytes: uuid.MustParse(testUUID), Valid: true}, TransformType: "uuid"},
+			},
+			{
+				{TransformValue: float64(42), TransformType: "integer"},
+				{TransformValue: int32(42), TransformType: "integer"},
+				{TransformValue: int64(42), TransformType: "integer"},
+				{TransformValue: pgtype.Numeric{Int: big.NewInt(42), Valid: true}, TransformType: "numeric"},
+				{TransformValue: pgtype.Numeric{Int: big.NewInt(4200), Exp: -2, Valid: true}, TransformType: "numeric"},
+			},
+			{
+				{TransformValue: float64(-0.05), TransformType: "numeric"},
+				{TransformValue: pgtype.Numeric{Int: big.NewInt(-50), Exp: -3, Valid: true}, TransformType: "numeric"},
+			},
+		}
+
+		for _, values := range equivalentValues {
+			want, err := transformer.Transform(context.Background(), values[0])
+			require.NoError(t, err)
+			for _, v := range values[1:] {
+				got, err := transformer.Transform(context.Background(), v)
+				require.NoError(t, err)
+				require.Equal(t, want, got, "%T: %v", v.TransformValue, v.TransformValue)
+			}
+		}
+	})
+}
+
+func TestNumericText(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		numeric pgtype.Numeric
+
+		wantText string
+		wantErr  error
+	}{
+		{
+			name:     "ok - integer",
+			numeric:  pgtype.Numeric{Int: big.NewInt(123), Valid: true},
+			wantText: "123",
+		},
+		{
+			name:     "ok - positive exponent",
+			numeric:  pgtype.Numeric{Int: big.NewInt(12), Exp: 3, Valid: true},
+			wantText: "12000",
+		},
+		{
+			name:     "ok - trailing zeros",
+			numeric:  pgtype.Numeric{Int: big.NewInt(150), Exp: -2, Valid: true},
+			wantText: "1.5",
+		},
+		{
+			name:     "ok - leading zeros",
+			numeric:  pgtype.Numeric{Int: big.NewInt(-5), Exp: -3, Valid: true},
+			wantText: "-0.005",
+		},
+		{
+			name:     "ok - zero",
+			numeric:  pgtype.Numeric{Int: big.NewInt(0), Exp: -2, Valid: true},
+			wantText: "0",
+		},
+		{
+			name:     "ok - NaN",
+			numeric:  pgtype.Numeric{NaN: true, Valid: true},
+			wantText: "NaN",
+		},
+		{
+			name:    "error - infinity",
+			numeric: pgtype.Numeric{InfinityModifier: pgtype.Infinity, Valid: true},
+			wantErr: errUnsupportedHMACNumeric,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			text, err := numericText(tc.numeric)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantText, text)
+		})
+	}
+}
@@ -72,6 +72,13 @@ func (i *Transformer) IsDynamic() bool {
 	return i.inner.IsDynamic()
 }
 
+func (i *Transformer) ReferencedColumns() []string {
+	if referencer, ok := i.inner.(transformers.ColumnReferencer); ok {
+		return referencer.ReferencedColumns()
+	}
+	return nil
+}
+
 func (i *Transformer) Close() error {
 	return i.inner.Close()
 }
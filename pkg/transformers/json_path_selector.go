@@ -0,0 +1,185 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// jsonPathSelector is a parsed JSONPath-like selector, such as
+// `$.addresses[*].street` or `$['profile data'].email`. It supports object
+// keys in dot or bracket notation, array indexes and wildcards for both
+// object and array members.
+type jsonPathSelector struct {
+	raw      string
+	segments []jsonPathSegment
+}
+
+type jsonPathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// jsonPathAction is applied to every value matched by a selector. It returns
+// the new value for the match, or whether the match should be removed from its
+// parent object or array.
+type jsonPathAction func(value any) (newValue any, remove bool, err error)
+
+var errInvalidJSONPathSelector = errors.New("invalid json path selector")
+
+func newJSONPathSelector(selector string) (*jsonPathSelector, error) {
+	invalidErr := func(reason string) error {
+		return fmt.Errorf("%w %q: %s", errInvalidJSONPathSelector, selector, reason)
+	}
+
+	if !strings.HasPrefix(selector, "$") {
+		return nil, invalidErr("must start with $")
+	}
+
+	segments := []jsonPathSegment{}
+	rest := selector[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			if key == "" {
+				return nil, invalidErr("empty key")
+			}
+			rest = rest[end:]
+			if key == "*" {
+				segments = append(segments, jsonPathSegment{wildcard: true})
+				continue
+			}
+			segments = append(segments, jsonPathSegment{key: key})
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, invalidErr("unclosed bracket")
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				segments = append(segments, jsonPathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, jsonPathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, invalidErr(fmt.Sprintf("invalid array index %q", inner))
+				}
+				segments = append(segments, jsonPathSegment{index: index, isIndex: true})
+			}
+		default:
+			return nil, invalidErr(fmt.Sprintf("unexpected character %q", rest[0]))
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, invalidErr("the root document can't be selected")
+	}
+
+	return &jsonPathSelector{
+		raw:      selector,
+		segments: segments,
+	}, nil
+}
+
+// apply runs the action on every value matched by the selector in the parsed
+// JSON document on input, which is modified in place. Paths that don't exist
+// in the document are ignored. It returns the updated document.
+func (s *jsonPathSelector) apply(doc any, action jsonPathAction) (any, error) {
+	return applyJSONPathSegments(doc, s.segments, action)
+}
+
+func applyJSONPathSegments(node any, segments []jsonPathSegment, action jsonPathAction) (any, error) {
+	segment := segments[0]
+	last := len(segments) == 1
+
+	// returns the new value for the child, or whether it should be removed
+	applyChild := func(child any) (any, bool, error) {
+		if last {
+			return action(child)
+		}
+		newChild, err := applyJSONPathSegments(child, segments[1:], action)
+		return newChild, false, err
+	}
+
+	switch n := node.(type) {
+	case map[string]any:
+		if segment.isIndex {
+			return n, nil
+		}
+		keys := []string{segment.key}
+		if segment.wildcard {
+			keys = make([]string, 0, len(n))
+			for key := range n {
+				keys = append(keys, key)
+			}
+			// keep the errors deterministic
+			slices.Sort(keys)
+		}
+		for _, key := range keys {
+			child, found := n[key]
+			if !found {
+				continue
+			}
+			newChild, remove, err := applyChild(child)
+			if err != nil {
+				return nil, err
+			}
+			if remove {
+				delete(n, key)
+				continue
+			}
+			n[key] = newChild
+		}
+		return n, nil
+
+	case []any:
+		switch {
+		case segment.wildcard:
+			result := n[:0]
+			for _, child := range n {
+				newChild, remove, err := applyChild(child)
+				if err != nil {
+					return nil, err
+				}
+				if !remove {
+					result = append(result, newChild)
+				}
+			}
+			return result, nil
+		case segment.isIndex:
+			if segment.index >= len(n) {
+				return n, nil
+			}
+			newChild, remove, err := applyChild(n[segment.index])
+			if err != nil {
+				return nil, err
+			}
+			if remove {
+				return slices.Delete(n, segment.index, segment.index+1), nil
+			}
+			n[segment.index] = newChild
+			return n, nil
+		default:
+			return n, nil
+		}
+
+	default:
+		// scalar values have no children, the path doesn't exist
+		return node, nil
+	}
+}
@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewJSONPathSelector(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		selector string
+
+		wantSegments []jsonPathSegment
+		wantErr      error
+	}{
+		{
+			name:     "ok - dot notation",
+			selector: "$.profile.email",
+			wantSegments: []jsonPathSegment{
+				{key: "profile"},
+				{key: "email"},
+			},
+		},
+		{
+			name:     "ok - bracket notation",
+			selector: `$['profile data']["e.mail"]`,
+			wantSegments: []jsonPathSegment{
+				{key: "profile data"},
+				{key: "e.mail"},
+			},
+		},
+		{
+			name:     "ok - array index and wildcards",
+			selector: "$.addresses[*].lines[0].*",
+			wantSegments: []jsonPathSegment{
+				{key: "addresses"},
+				{wildcard: true},
+				{key: "lines"},
+				{index: 0, isIndex: true},
+				{wildcard: true},
+			},
+		},
+		{
+			name:     "error - missing root",
+			selector: "profile.email",
+			wantErr:  errInvalidJSONPathSelector,
+		},
+		{
+			name:     "error - root document",
+			selector: "$",
+			wantErr:  errInvalidJSONPathSelector,
+		},
+		{
+			name:     "error - empty key",
+			selector: "$..email",
+			wantErr:  errInvalidJSONPathSelector,
+		},
+		{
+			name:     "error - unclosed bracket",
+			selector: "$.addresses[0",
+			wantErr:  errInvalidJSONPathSelector,
+		},
+		{
+			name:     "error - negative index",
+			selector: "$.addresses[-1]",
+			wantErr:  errInvalidJSONPathSelector,
+		},
+		{
+			name:     "error - unexpected character",
+			selector: "$email",
+			wantErr:  errInvalidJSONPathSelector,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			selector, err := newJSONPathSelector(tc.selector)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			require.Equal(t, tc.wantSegments, selector.segments)
+		})
+	}
+}
+
+func TestJSONPathSelector_apply(t *testing.T) {
+	t.Parallel()
+
+	replace := func(v any) (any, bool, error) { return "x", false, nil }
+	remove := func(v any) (any, bool, error) { return nil, true, nil }
+
+	newDoc := func() map[string]any {
+		return map[string]any{
+			"email": "alice@acme.com",
+			"addresses": []any{
+				map[string]any{"street": "1 Main St", "city": "Springfield"},
+				map[string]any{"city": "Shelbyville"},
+				"not an object",
+			},
+			"tags": []any{"a", "b", "c"},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		selector string
+		action   jsonPathAction
+
+		wantDoc any
+	}{
+		{
+			name:     "replace key",
+			selector: "$.email",
+			action:   replace,
+			wantDoc: func() any {
+				doc := newDoc()
+				doc["email"] = "x"
+				return doc
+			}(),
+		},
+		{
+			name:     "replace in array of objects, skipping missing keys",
+			selector: "$.addresses[*].street",
+			action:   replace,
+			wantDoc: func() any {
+				doc := newDoc()
+				doc["addresses"].([]any)[0].(map[string]any)["street"] = "x"
+				return doc
+			}(),
+		},
+		{
+			name:     "delete key in array of objects",
+			selector: "$.addresses[*].city",
+			action:   remove,
+			wantDoc: func() any {
+				doc := newDoc()
+				delete(doc["addresses"].([]any)[0].(map[string]any), "city")
+				delete(doc["addresses"].([]any)[1].(map[string]any), "city")
+				return doc
+			}(),
+		},
+		{
+			name:     "delete array element",
+			selector: "$.tags[1]",
+			action:   remove,
+			wantDoc: func() any {
+				doc := newDoc()
+				doc["tags"] = []any{"a", "c"}
+				return doc
+			}(),
+		},
+		{
+			name:     "object wildcard",
+			selector: "$.addresses[0].*",
+			action:   replace,
+			wantDoc: func() any {
+				doc := newDoc()
+				doc["addresses"].([]any)[0] = map[string]any{"street": "x", "city": "x"}
+				return doc
+			}(),
+		},
+		{
+			name:     "missing paths are ignored",
+			selector: "$.profile.email",
+			action:   replace,
+			wantDoc:  newDoc(),
+		},
+		{
+			name:     "out of range index is ignored",
+			selector: "$.tags[5]",
+			action:   replace,
+			wantDoc:  newDoc(),
+		},
+		{
+			name:     "index on object is ignored",
+			selector: "$[0]",
+			action:   replace,
+			wantDoc:  newDoc(),
+		},
+		{
+			name:     "key on scalar is ignored",
+			selector: "$.email.domain",
+			action:   replace,
+			wantDoc:  newDoc(),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			selector, err := newJSONPathSelector(tc.selector)
+			require.NoError(t, err)
+
+			got, err := selector.apply(newDoc(), tc.action)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantDoc, got)
+		})
+	}
+}
@@ -0,0 +1,314 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/xataio/pgstream/internal/json"
+)
+
+// JSONPathTransformer applies transformers to the values within a json
+// document selected with JSONPath-like selectors, such as
+// `$.addresses[*].street`, leaving the rest of the document untouched. Each
+// rule either transforms the matched values with an inner transformer, or
+// deletes them from the document.
+type JSONPathTransformer struct {
+	rules []*jsonPathRule
+}
+
+type jsonPathRule struct {
+	selector    *jsonPathSelector
+	operation   string
+	transformer Transformer
+}
+
+// BuildFn builds the transformer for the configuration on input.
+type BuildFn func(cfg *Config) (Transformer, error)
+
+const (
+	jsonPathTransformOpName = "transform"
+	jsonPathDeleteOpName    = "delete"
+)
+
+var (
+	errJSONPathRulesMustBeProvided       = errors.New("json_path: rules parameter must be provided")
+	errJSONPathSelectorMustBeProvided    = errors.New("json_path: selector must be provided in the rule definition")
+	errJSONPathTransformerMustBeProvided = errors.New("json_path: transformer must be provided in the 'transform' rule definition")
+	errJSONPathInvalidOperation          = errors.New("json_path: unknown operation, must be one of 'transform' or 'delete'")
+
+	jsonPathCompatibleTypes = []SupportedDataType{
+		JSONDataType,
+	}
+	jsonPathParams = []Parameter{
+		{
+			Name:          "rules",
+			SupportedType: "array",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      true,
+		},
+	}
+)
+
+// NewJSONPathTransformer returns a json path transformer for the parameters on
+// input. The inner transformers of the rules are built with the build function
+// provided.
+func NewJSONPathTransformer(params ParameterValues, build BuildFn) (*JSONPathTransformer, error) {
+	rulesAny, found, err := FindParameter[[]any](params, "rules")
+	if err != nil {
+		return nil, fmt.Errorf("json_path: rules must be an array: %w", err)
+	}
+	if !found || len(rulesAny) == 0 {
+		return nil, errJSONPathRulesMustBeProvided
+	}
+
+	t := &JSONPathTransformer{
+		rules: make([]*jsonPathRule, 0, len(rulesAny)),
+	}
+	for idx, ruleAny := range rulesAny {
+		rule, err := newJSONPathRule(ruleAny, build)
+		if err != nil {
+			t.Close()
+			return nil, fmt.Errorf("json_path: rule[%d]: %w", idx, err)
+		}
+		t.rules = append(t.rules, rule)
+	}
+
+	return t, nil
+}
+
+// Transform applies the rules in the order they were provided. Parsed json
+// values (maps and slices) are returned with the same structure, so they're
+// serialised like the untransformed jsonb values, while string and byte
+// values are serialised back to their original type. Selectors that don't
+// match any value in the document are ignored.
+func (t *JSONPathTransformer) Transform(ctx context.Context, value Value) (any, error) {
+	var doc any
+	switch val := value.TransformValue.(type) {
+	case map[string]any, []any:
+		// the document is modified in place, make sure the original value
+		// is not altered
+		doc = copyJSONValue(val)
+	case string:
+		if err := json.Unmarshal([]byte(val), &doc); err != nil {
+			return nil, fmt.Errorf("json_path: error unmarshalling value: %w", err)
+		}
+	case []byte:
+		if err := json.Unmarshal(val, &doc); err != nil {
+			return nil, fmt.Errorf("json_path: error unmarshalling value: %w", err)
+		}
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+
+	var err error
+	for idx, rule := range t.rules {
+		doc, err = rule.selector.apply(doc, rule.action(ctx, value.DynamicValues))
+		if err != nil {
+			return nil, fmt.Errorf("json_path: cannot apply rule[%d] with selector %s: %w", idx, rule.selector.raw, err)
+		}
+	}
+
+	switch value.TransformValue.(type) {
+	case string, []byte:
+		docBytes, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("json_path: error marshalling the result: %w", err)
+		}
+		if _, ok := value.TransformValue.(string); ok {
+			return string(docBytes), nil
+		}
+		return docBytes, nil
+	default:
+		return doc, nil
+	}
+}
+
+// ReferencedColumns returns the row columns referenced by the inner
+// transformers, if any.
+func (t *JSONPathTransformer) ReferencedColumns() []string {
+	columns := []string{}
+	for _, rule := range t.rules {
+		referencer, ok := rule.transformer.(ColumnReferencer)
+		if !ok {
+			continue
+		}
+		for _, column := range referencer.ReferencedColumns() {
+			if !slices.Contains(columns, column) {
+				columns = append(columns, column)
+			}
+		}
+	}
+	slices.Sort(columns)
+	return columns
+}
+
+func (t *JSONPathTransformer) CompatibleTypes() []SupportedDataType {
+	return jsonPathCompatibleTypes
+}
+
+func (t *JSONPathTransformer) Type() TransformerType {
+	return JSONPath
+}
+
+func (t *JSONPathTransformer) IsDynamic() bool {
+	for _, rule := range t.rules {
+		if rule.transformer != nil && rule.transformer.IsDynamic() {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *JSONPathTransformer) Close() error {
+	var errs error
+	for _, rule := range t.rules {
+		if rule.transformer == nil {
+			continue
+		}
+		if err := rule.transformer.Close(); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+func JSONPathTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: jsonPathCompatibleTypes,
+		Parameters:     jsonPathParams,
+	}
+}
+
+func newJSONPathRule(ruleAny any, build BuildFn) (*jsonPathRule, error) {
+	ruleParams, ok := ruleAny.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid element type in rules array, got %T: %w", ruleAny, ErrInvalidParameters)
+	}
+
+	selectorStr, found, err := FindParameter[string](ruleParams, "selector")
+	if err != nil {
+		return nil, fmt.Errorf("selector must be a string: %w", err)
+	}
+	if !found {
+		return nil, errJSONPathSelectorMustBeProvided
+	}
+	selector, err := newJSONPathSelector(selectorStr)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &jsonPathRule{selector: selector}
+	rule.operation, err = FindParameterWithDefault(ruleParams, "operation", jsonPathTransformOpName)
+	if err != nil {
+		return nil, fmt.Errorf("operation must be a string: %w", err)
+	}
+
+	switch rule.operation {
+	case jsonPathDeleteOpName:
+		return rule, nil
+	case jsonPathTransformOpName:
+	default:
+		return nil, errJSONPathInvalidOperation
+	}
+
+	transformerParams, found, err := FindParameter[map[string]any](ruleParams, "transformer")
+	if err != nil {
+		return nil, fmt.Errorf("transformer must be an object: %w", err)
+	}
+	if !found {
+		return nil, errJSONPathTransformerMustBeProvided
+	}
+	cfg, err := jsonPathTransformerConfig(transformerParams)
+	if err != nil {
+		return nil, err
+	}
+	rule.transformer, err = build(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("building %s transformer: %w", cfg.Name, err)
+	}
+
+	return rule, nil
+}
+
+func jsonPathTransformerConfig(params map[string]any) (*Config, error) {
+	name, found, err := FindParameter[string](params, "name")
+	if err != nil {
+		return nil, fmt.Errorf("transformer name must be a string: %w", err)
+	}
+	if !found {
+		return nil, errJSONPathTransformerMustBeProvided
+	}
+	parameters, err := FindParameterWithDefault(params, "parameters", map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("transformer parameters must be an object: %w", err)
+	}
+	dynamicParameters, err := FindParameterWithDefault(params, "dynamic_parameters", map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("transformer dynamic_parameters must be an object: %w", err)
+	}
+
+	return &Config{
+		Name:              TransformerType(name),
+		Parameters:        parameters,
+		DynamicParameters: dynamicParameters,
+	}, nil
+}
+
+func (r *jsonPathRule) action(ctx context.Context, dynamicValues map[string]any) jsonPathAction {
+	if r.operation == jsonPathDeleteOpName {
+		return func(any) (any, bool, error) {
+			return nil, true, nil
+		}
+	}
+
+	return func(v any) (any, bool, error) {
+		newValue, err := r.transformer.Transform(ctx, NewValue(v, jsonValueTypeName(v), dynamicValues))
+		if err != nil {
+			return nil, false, err
+		}
+		// byte values would be serialised as base64 in the json document
+		if b, ok := newValue.([]byte); ok {
+			newValue = string(b)
+		}
+		return newValue, false, nil
+	}
+}
+
+// jsonValueTypeName returns the postgres type name matching the json value on
+// input, to be used as the inner transformers value type.
+func jsonValueTypeName(v any) string {
+	switch v.(type) {
+	case string:
+		return "text"
+	case float64:
+		return "numeric"
+	case bool:
+		return "boolean"
+	default:
+		return "jsonb"
+	}
+}
+
+func copyJSONValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(val))
+		for key, child := range val {
+			c[key] = copyJSONValue(child)
+		}
+		return c
+	case []any:
+		c := make([]any, len(val))
+		for i, child := range val {
+			c[i] = copyJSONValue(child)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testJSONPathBuildFn(cfg *Config) (Transformer, error) {
+	switch cfg.Name {
+	case LiteralString:
+		return NewLiteralStringTransformer(cfg.Parameters)
+	case Masking:
+		return NewMaskingTransformer(cfg.Parameters)
+	case RowTemplate:
+		return NewRowTemplateTransformer(cfg.Parameters)
+	default:
+		return nil, ErrUnsupportedTransformer
+	}
+}
+
+func TestNewJSONPathTransformer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+
+		wantIsDynamic         bool
+		wantReferencedColumns []string
+		wantErr               error
+	}{
+		{
+			name: "ok",
+			params: ParameterValues{
+				"rules": []any{
+					map[string]any{
+						"selector":    "$.email",
+						"transformer": map[string]any{"name": "masking", "parameters": map[string]any{"type": "email"}},
+					},
+					map[string]any{"selector": "$.ssn", "operation": "delete"},
+				},
+			},
+			wantIsDynamic:         false,
+			wantReferencedColumns: []string{},
+		},
+		{
+			name: "ok - dynamic inner transformer",
+			params: ParameterValues{
+				"rules": []any{
+					map[string]any{
+						"selector":    "$.email",
+						"transformer": map[string]any{"name": "row_template", "parameters": map[string]any{"template": "user_{{ .id }}@example.com"}},
+					},
+				},
+			},
+			wantIsDynamic:         true,
+			wantReferencedColumns: []string{"id"},
+		},
+		{
+			name:    "error - missing rules",
+			params:  ParameterValues{},
+			wantErr: errJSONPathRulesMustBeProvided,
+		},
+		{
+			name:    "error - empty rules",
+			params:  ParameterValues{"rules": []any{}},
+			wantErr: errJSONPathRulesMustBeProvided,
+		},
+		{
+			name:    "error - invalid rule type",
+			params:  ParameterValues{"rules": []any{"$.email"}},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - missing selector",
+			params:  ParameterValues{"rules": []any{map[string]any{"operation": "delete"}}},
+			wantErr: errJSONPathSelectorMustBeProvided,
+		},
+		{
+			name:    "error - invalid selector",
+			params:  ParameterValues{"rules": []any{map[string]any{"selector": "email", "operation": "delete"}}},
+			wantErr: errInvalidJSONPathSelector,
+		},
+		{
+			name:    "error - invalid operation",
+			params:  ParameterValues{"rules": []any{map[string]any{"selector": "$.email", "operation": "set"}}},
+			wantErr: errJSONPathInvalidOperation,
+		},
+		{
+			name:    "error - missing transformer",
+			params:  ParameterValues{"rules": []any{map[string]any{"selector": "$.email"}}},
+			wantErr: errJSONPathTransformerMustBeProvided,
+		},
+		{
+			name: "error - building inner transformer",
+			params: ParameterValues{"rules": []any{map[string]any{
+				"selector":    "$.email",
+				"transformer": map[string]any{"name": "unknown"},
+			}}},
+			wantErr: ErrUnsupportedTransformer,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transformer, err := NewJSONPathTransformer(tc.params, testJSONPathBuildFn)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			require.Equal(t, tc.wantIsDynamic, transformer.IsDynamic())
+			require.Equal(t, tc.wantReferencedColumns, transformer.ReferencedColumns())
+			require.NoError(t, transformer.Close())
+		})
+	}
+}
+
+func TestJSONPathTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	maskingTransformer, err := NewMaskingTransformer(ParameterValues{"type": "address"})
+	require.NoError(t, err)
+	maskedStreet, err := maskingTransformer.Transform(context.Background(), NewValue("1 Main St", "text", nil))
+	require.NoError(t, err)
+
+	rules := []any{
+		map[string]any{
+			"selector":    "$.profile.email",
+			"transformer": map[string]any{"name": "row_template", "parameters": map[string]any{"template": "user_{{ .id }}@example.com"}},
+		},
+		map[string]any{
+			"selector":    "$.addresses[*].street",
+			"transformer": map[string]any{"name": "masking", "parameters": map[string]any{"type": "address"}},
+		},
+		map[string]any{"selector": "$.ssn", "operation": "delete"},
+		map[string]any{
+			"selector":    "$.nickname",
+			"transformer": map[string]any{"name": "literal_string", "parameters": map[string]any{"literal": "anonymous"}},
+		},
+	}
+
+	newDoc := func() map[string]any {
+		return map[string]any{
+			"profile": map[string]any{"email": "alice@acme.com", "age": float64(30)},
+			"addresses": []any{
+				map[string]any{"street": "1 Main St", "zip": "12345"},
+				map[string]any{"zip": "54321"},
+			},
+			"ssn": "123-45-6789",
+		}
+	}
+	wantDoc := map[string]any{
+		"profile": map[string]any{"email": "user_42@example.com", "age": float64(30)},
+		"addresses": []any{
+			map[string]any{"street": maskedStreet, "zip": "12345"},
+			map[string]any{"zip": "54321"},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		rules []any
+		value any
+
+		wantValue  any
+		wantErr    error
+		wantErrMsg string
+	}{
+		{
+			name:      "ok - parsed json",
+			rules:     rules,
+			value:     newDoc(),
+			wantValue: wantDoc,
+		},
+		{
+			name:      "ok - string",
+			rules:     rules,
+			value:     `{"profile":{"email":"alice@acme.com"},"ssn":"123-45-6789","tags":[1,2]}`,
+			wantValue: `{"profile":{"email":"user_42@example.com"},"tags":[1,2]}`,
+		},
+		{
+			name:      "ok - bytes",
+			rules:     rules,
+			value:     []byte(`[{"ssn":"123-45-6789"}]`),
+			wantValue: []byte(`[{"ssn":"123-45-6789"}]`),
+		},
+		{
+			name: "ok - delete array elements",
+			rules: []any{
+				map[string]any{"selector": "$.addresses[*]", "operation": "delete"},
+			},
+			value:     newDoc(),
+			wantValue: func() any { doc := newDoc(); doc["addresses"] = []any{}; return doc }(),
+		},
+		{
+			name:       "error - invalid json",
+			rules:      rules,
+			value:      `{"profile":`,
+			wantErrMsg: "json_path: error unmarshalling value",
+		},
+		{
+			name: "error - inner transformer",
+			rules: []any{map[string]any{
+				"selector":    "$.profile.age",
+				"transformer": map[string]any{"name": "masking"},
+			}},
+			value:   newDoc(),
+			wantErr: ErrUnsupportedValueType,
+		},
+		{
+			name:    "error - unsupported value type",
+			rules:   rules,
+			value:   42,
+			wantErr: ErrUnsupportedValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transformer, err := NewJSONPathTransformer(ParameterValues{"rules": tc.rules}, testJSONPathBuildFn)
+			require.NoError(t, err)
+
+			original := copyJSONValue(tc.value)
+			got, err := transformer.Transform(context.Background(), NewValue(tc.value, "jsonb", map[string]any{"id": 42}))
+			if tc.wantErrMsg != "" {
+				require.ErrorContains(t, err, tc.wantErrMsg)
+				return
+			}
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				require.Nil(t, got)
+				return
+			}
+			// serialised values are compared as json, since the key order is
+			// not guaranteed
+			switch want := tc.wantValue.(type) {
+			case string:
+				require.IsType(t, "", got)
+				require.JSONEq(t, want, got.(string))
+			case []byte:
+				require.IsType(t, []byte{}, got)
+				require.JSONEq(t, string(want), string(got.([]byte)))
+			default:
+				require.Equal(t, tc.wantValue, got)
+			}
+			// the value on input is not modified
+			require.Equal(t, original, tc.value)
+		})
+	}
+}
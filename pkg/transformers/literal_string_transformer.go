@@ -50,6 +50,11 @@ func (lst *LiteralStringTransformer) CompatibleTypes() []SupportedDataType {
 	return literalStringCompatibleTypes
 }
 
+// OutputType returns the type of the literal, regardless of the input type.
+func (lst *LiteralStringTransformer) OutputType() SupportedDataType {
+	return StringDataType
+}
+
 func (lst *LiteralStringTransformer) Type() TransformerType {
 	return LiteralString
 }
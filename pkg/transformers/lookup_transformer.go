@@ -0,0 +1,416 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+// LookupTransformer replaces values using a key to value dictionary, loaded
+// from a CSV file or a SQL query when the transformer is created. The
+// dictionary can be periodically reloaded in the background, and the values
+// not found in it are handled as per the on_missing parameter.
+type LookupTransformer struct {
+	dictionary   atomic.Pointer[map[string]string]
+	load         lookupLoadFn
+	onMissing    string
+	defaultValue string
+	logger       loglib.Logger
+
+	conn      pglib.Querier
+	cancel    context.CancelFunc
+	reloadWg  sync.WaitGroup
+	closeOnce sync.Once
+}
+
+type LookupOption func(*LookupTransformer)
+
+// lookupLoadFn loads the dictionary. The size hint is the number of entries of
+// the previously loaded dictionary, if any.
+type lookupLoadFn func(ctx context.Context, sizeHint int) (map[string]string, error)
+
+const (
+	lookupOnMissingPassthrough = "passthrough"
+	lookupOnMissingDefault     = "default"
+	lookupOnMissingNull        = "null"
+)
+
+var (
+	errLookupSourceMustBeProvided   = errors.New("lookup: one of csv_path or query must be provided")
+	errLookupMultipleSources        = errors.New("lookup: only one of csv_path or query can be provided")
+	errLookupPostgresURLNotFound    = errors.New("lookup: postgres_url must be provided when using query")
+	errLookupInvalidOnMissing       = errors.New("lookup: on_missing must be one of passthrough, default or null")
+	errLookupDefaultValueNotFound   = errors.New("lookup: default_value must be provided when on_missing is default")
+	errLookupInvalidCSVDelimiter    = errors.New("lookup: csv_delimiter must be a single character")
+	errLookupInvalidReloadInterval  = errors.New("lookup: reload_interval must be a positive duration")
+	errLookupInvalidCSVRecordLength = errors.New("lookup: csv records must have exactly two fields, key and value")
+
+	lookupCompatibleTypes = []SupportedDataType{
+		StringDataType,
+		CitextDataType,
+		ByteArrayDataType,
+	}
+	lookupParams = []Parameter{
+		{
+			Name:          "csv_path",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "csv_delimiter",
+			SupportedType: "string",
+			Default:       ",",
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "csv_has_header",
+			SupportedType: "boolean",
+			Default:       false,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "query",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "postgres_url",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "reload_interval",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "on_missing",
+			SupportedType: "string",
+			Default:       lookupOnMissingPassthrough,
+			Dynamic:       false,
+			Required:      false,
+			Values:        []any{lookupOnMissingPassthrough, lookupOnMissingDefault, lookupOnMissingNull},
+		},
+		{
+			Name:          "default_value",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+	}
+)
+
+// NewLookupTransformer returns a lookup transformer for the parameters on
+// input. The dictionary is loaded before returning, and an error is returned
+// if it can't be loaded.
+func NewLookupTransformer(params ParameterValues, opts ...LookupOption) (*LookupTransformer, error) {
+	csvPath, err := FindParameterWithDefault(params, "csv_path", "")
+	if err != nil {
+		return nil, fmt.Errorf("lookup: csv_path must be a string: %w", err)
+	}
+	query, err := FindParameterWithDefault(params, "query", "")
+	if err != nil {
+		return nil, fmt.Errorf("lookup: query must be a string: %w", err)
+	}
+	switch {
+	case csvPath == "" && query == "":
+		return nil, errLookupSourceMustBeProvided
+	case csvPath != "" && query != "":
+		return nil, errLookupMultipleSources
+	}
+
+	onMissing, err := FindParameterWithDefault(params, "on_missing", lookupOnMissingPassthrough)
+	if err != nil {
+		return nil, fmt.Errorf("lookup: on_missing must be a string: %w", err)
+	}
+	defaultValue, foundDefault, err := FindParameter[string](params, "default_value")
+	if err != nil {
+		return nil, fmt.Errorf("lookup: default_value must be a string: %w", err)
+	}
+	switch onMissing {
+	case lookupOnMissingPassthrough, lookupOnMissingNull:
+	case lookupOnMissingDefault:
+		if !foundDefault {
+			return nil, errLookupDefaultValueNotFound
+		}
+	default:
+		return nil, errLookupInvalidOnMissing
+	}
+
+	reloadIntervalStr, err := FindParameterWithDefault(params, "reload_interval", "")
+	if err != nil {
+		return nil, fmt.Errorf("lookup: reload_interval must be a string: %w", err)
+	}
+	var reloadInterval time.Duration
+	if reloadIntervalStr != "" {
+		reloadInterval, err = time.ParseDuration(reloadIntervalStr)
+		if err != nil || reloadInterval <= 0 {
+			return nil, errLookupInvalidReloadInterval
+		}
+	}
+
+	t := &LookupTransformer{
+		onMissing:    onMissing,
+		defaultValue: defaultValue,
+		logger:       loglib.NewNoopLogger(),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if csvPath != "" {
+		t.load, err = newCSVLookupLoadFn(csvPath, params)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		url, found, err := FindParameter[string](params, "postgres_url")
+		if err != nil {
+			return nil, fmt.Errorf("lookup: postgres_url must be a string: %w", err)
+		}
+		if !found {
+			return nil, errLookupPostgresURLNotFound
+		}
+		pool, err := pglib.NewConnPool(context.Background(), url)
+		if err != nil {
+			return nil, fmt.Errorf("lookup: failed to create connection pool: %w", err)
+		}
+		t.conn = pool
+		t.load = newPostgresLookupLoadFn(pool, query)
+	}
+
+	if err := t.reload(context.Background()); err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	if reloadInterval > 0 {
+		t.startReloading(reloadInterval)
+	}
+
+	return t, nil
+}
+
+// WithLookupLogger logs the errors of the background reloads, which keep the
+// previous dictionary.
+func WithLookupLogger(l loglib.Logger) LookupOption {
+	return func(t *LookupTransformer) {
+		t.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "lookup_transformer",
+		})
+	}
+}
+
+func (t *LookupTransformer) Transform(_ context.Context, value Value) (any, error) {
+	var key string
+	switch val := value.TransformValue.(type) {
+	case string:
+		key = val
+	case []byte:
+		key = string(val)
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+
+	dictionary := *t.dictionary.Load()
+	if mapped, found := dictionary[key]; found {
+		return mapped, nil
+	}
+
+	switch t.onMissing {
+	case lookupOnMissingDefault:
+		return t.defaultValue, nil
+	case lookupOnMissingNull:
+		return nil, nil
+	default:
+		return value.TransformValue, nil
+	}
+}
+
+func (t *LookupTransformer) CompatibleTypes() []SupportedDataType {
+	return lookupCompatibleTypes
+}
+
+func (t *LookupTransformer) Type() TransformerType {
+	return Lookup
+}
+
+func (t *LookupTransformer) IsDynamic() bool {
+	return false
+}
+
+// Close stops the background reloading, if enabled, and closes the source
+// database connection.
+func (t *LookupTransformer) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		if t.cancel != nil {
+			t.cancel()
+			t.reloadWg.Wait()
+		}
+		if t.conn != nil {
+			err = t.conn.Close(context.Background())
+		}
+	})
+	return err
+}
+
+func LookupTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: lookupCompatibleTypes,
+		Parameters:     lookupParams,
+	}
+}
+
+// reload loads a new dictionary and swaps it with the current one, so that
+// the lookups in progress are not blocked while loading.
+func (t *LookupTransformer) reload(ctx context.Context) error {
+	sizeHint := 0
+	if current := t.dictionary.Load(); current != nil {
+		sizeHint = len(*current)
+	}
+	dictionary, err := t.load(ctx, sizeHint)
+	if err != nil {
+		return fmt.Errorf("lookup: loading dictionary: %w", err)
+	}
+	t.dictionary.Store(&dictionary)
+	return nil
+}
+
+// startReloading reloads the dictionary periodically in the background until
+// the transformer is closed. Failed reloads keep the previous dictionary, and
+// are logged with the number of consecutive failures so that a stale
+// dictionary can be noticed.
+func (t *LookupTransformer) startReloading(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.reloadWg.Add(1)
+	go func() {
+		defer t.reloadWg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		failures := 0
+		lastReload := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := t.reload(ctx); err != nil {
+					if errors.Is(err, context.Canceled) {
+						return
+					}
+					failures++
+					t.logger.Warn(err, "lookup transformer: reloading dictionary failed, keeping the previous one", loglib.Fields{
+						"consecutive_failures": failures,
+						"dictionary_age":       time.Since(lastReload).String(),
+					})
+					continue
+				}
+				failures = 0
+				lastReload = time.Now()
+			}
+		}
+	}()
+}
+
+func newCSVLookupLoadFn(path string, params ParameterValues) (lookupLoadFn, error) {
+	delimiter, err := FindParameterWithDefault(params, "csv_delimiter", ",")
+	if err != nil {
+		return nil, fmt.Errorf("lookup: csv_delimiter must be a string: %w", err)
+	}
+	if utf8.RuneCountInString(delimiter) != 1 {
+		return nil, errLookupInvalidCSVDelimiter
+	}
+	comma, _ := utf8.DecodeRuneInString(delimiter)
+
+	hasHeader, err := FindParameterWithDefault(params, "csv_has_header", false)
+	if err != nil {
+		return nil, fmt.Errorf("lookup: csv_has_header must be a boolean: %w", err)
+	}
+
+	return func(_ context.Context, sizeHint int) (map[string]string, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		r := csv.NewReader(f)
+		r.Comma = comma
+		r.FieldsPerRecord = -1
+		// the record slice is reused, the field strings are not
+		r.ReuseRecord = true
+
+		dictionary := make(map[string]string, sizeHint)
+		for line := 1; ; line++ {
+			record, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				return dictionary, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			if line == 1 && hasHeader {
+				continue
+			}
+			if len(record) != 2 {
+				return nil, fmt.Errorf("%w: line %d has %d fields", errLookupInvalidCSVRecordLength, line, len(record))
+			}
+			dictionary[record[0]] = record[1]
+		}
+	}, nil
+}
+
+// newPostgresLookupLoadFn returns a load function for the query on input,
+// which must return the key and value text columns. Rows with null keys or
+// values are ignored.
+func newPostgresLookupLoadFn(conn pglib.Querier, query string) lookupLoadFn {
+	return func(ctx context.Context, sizeHint int) (map[string]string, error) {
+		rows, err := conn.Query(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		dictionary := make(map[string]string, sizeHint)
+		var key, value pgtype.Text
+		for rows.Next() {
+			if err := rows.Scan(&key, &value); err != nil {
+				return nil, err
+			}
+			if !key.Valid || !value.Valid {
+				continue
+			}
+			dictionary[key.String] = value.String
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return dictionary, nil
+	}
+}
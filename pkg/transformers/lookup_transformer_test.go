@@ -0,0 +1,375 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pglibmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+func writeTestLookupCSV(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, "regions.csv")
+	// write to a temporary file first so that the reload never reads a
+	// partially written file
+	tmpPath := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmpPath, []byte(content), 0o600))
+	require.NoError(t, os.Rename(tmpPath, path))
+	return path
+}
+
+func TestNewLookupTransformer(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	csvPath := writeTestLookupCSV(t, dir, "code,name\nEU-W,Western Europe\n\"US,E\",Eastern US\n")
+	invalidCSVPath := filepath.Join(dir, "invalid.csv")
+	require.NoError(t, os.WriteFile(invalidCSVPath, []byte("EU-W,Western Europe,extra\n"), 0o600))
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+
+		wantDictionary map[string]string
+		wantErr        error
+	}{
+		{
+			name: "ok - csv with header",
+			params: ParameterValues{
+				"csv_path":       csvPath,
+				"csv_has_header": true,
+			},
+			wantDictionary: map[string]string{
+				"EU-W": "Western Europe",
+				"US,E": "Eastern US",
+			},
+		},
+		{
+			name: "ok - csv without header",
+			params: ParameterValues{
+				"csv_path":   csvPath,
+				"on_missing": "null",
+			},
+			wantDictionary: map[string]string{
+				"code": "name",
+				"EU-W": "Western Europe",
+				"US,E": "Eastern US",
+			},
+		},
+		{
+			name:    "error - no source",
+			params:  ParameterValues{},
+			wantErr: errLookupSourceMustBeProvided,
+		},
+		{
+			name: "error - multiple sources",
+			params: ParameterValues{
+				"csv_path": csvPath,
+				"query":    "SELECT code, name FROM regions",
+			},
+			wantErr: errLookupMultipleSources,
+		},
+		{
+			name: "error - query without postgres url",
+			params: ParameterValues{
+				"query": "SELECT code, name FROM regions",
+			},
+			wantErr: errLookupPostgresURLNotFound,
+		},
+		{
+			name: "error - invalid on_missing",
+			params: ParameterValues{
+				"csv_path":   csvPath,
+				"on_missing": "error",
+			},
+			wantErr: errLookupInvalidOnMissing,
+		},
+		{
+			name: "error - default without default_value",
+			params: ParameterValues{
+				"csv_path":   csvPath,
+				"on_missing": "default",
+			},
+			wantErr: errLookupDefaultValueNotFound,
+		},
+		{
+			name: "error - invalid reload_interval",
+			params: ParameterValues{
+				"csv_path":        csvPath,
+				"reload_interval": "-1m",
+			},
+			wantErr: errLookupInvalidReloadInterval,
+		},
+		{
+			name: "error - invalid csv_delimiter",
+			params: ParameterValues{
+				"csv_path":      csvPath,
+				"csv_delimiter": ";;",
+			},
+			wantErr: errLookupInvalidCSVDelimiter,
+		},
+		{
+			name: "error - invalid csv record",
+			params: ParameterValues{
+				"csv_path": invalidCSVPath,
+			},
+			wantErr: errLookupInvalidCSVRecordLength,
+		},
+		{
+			name: "error - csv file not found",
+			params: ParameterValues{
+				"csv_path": filepath.Join(dir, "missing.csv"),
+			},
+			wantErr: os.ErrNotExist,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			lt, err := NewLookupTransformer(tc.params)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			defer lt.Close()
+			require.Equal(t, tc.wantDictionary, *lt.dictionary.Load())
+		})
+	}
+}
+
+func TestLookupTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	dictionary := map[string]string{"EU-W": "Western Europe"}
+
+	tests := []struct {
+		name         string
+		onMissing    string
+		defaultValue string
+		value        any
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "ok - found",
+			onMissing: lookupOnMissingPassthrough,
+			value:     "EU-W",
+			wantValue: "Western Europe",
+		},
+		{
+			name:      "ok - found bytes",
+			onMissing: lookupOnMissingPassthrough,
+			value:     []byte("EU-W"),
+			wantValue: "Western Europe",
+		},
+		{
+			name:      "ok - missing passthrough",
+			onMissing: lookupOnMissingPassthrough,
+			value:     "AP-S",
+			wantValue: "AP-S",
+		},
+		{
+			name:         "ok - missing default",
+			onMissing:    lookupOnMissingDefault,
+			defaultValue: "Unknown",
+			value:        "AP-S",
+			wantValue:    "Unknown",
+		},
+		{
+			name:      "ok - missing null",
+			onMissing: lookupOnMissingNull,
+			value:     "AP-S",
+			wantValue: nil,
+		},
+		{
+			name:      "error - unsupported value type",
+			onMissing: lookupOnMissingPassthrough,
+			value:     42,
+			wantErr:   ErrUnsupportedValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			lt := &LookupTransformer{
+				onMissing:    tc.onMissing,
+				defaultValue: tc.defaultValue,
+			}
+			lt.dictionary.Store(&dictionary)
+
+			got, err := lt.Transform(context.Background(), NewValue(tc.value, "text", nil))
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, got)
+		})
+	}
+}
+
+func TestLookupTransformer_reload(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	csvPath := writeTestLookupCSV(t, dir, "EU-W;Western Europe\n")
+
+	logger := &mockLookupLogger{}
+	lt, err := NewLookupTransformer(ParameterValues{
+		"csv_path":        csvPath,
+		"csv_delimiter":   ";",
+		"reload_interval": "10ms",
+	}, WithLookupLogger(logger))
+	require.NoError(t, err)
+	defer lt.Close()
+
+	transform := func(key string) any {
+		got, err := lt.Transform(context.Background(), NewValue(key, "text", nil))
+		require.NoError(t, err)
+		return got
+	}
+	require.Equal(t, "Western Europe", transform("EU-W"))
+
+	writeTestLookupCSV(t, dir, "EU-W;West Europe\nAP-S;South Asia\n")
+	require.Eventually(t, func() bool {
+		return transform("AP-S") == "South Asia"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "West Europe", transform("EU-W"))
+
+	require.Empty(t, logger.getWarnings())
+
+	// the previous dictionary is kept when the reload fails, and the failures
+	// are logged
+	require.NoError(t, os.Remove(csvPath))
+	require.Eventually(t, func() bool {
+		return len(logger.getWarnings()) >= 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "South Asia", transform("AP-S"))
+	warnings := logger.getWarnings()
+	require.Equal(t, 1, warnings[0]["consecutive_failures"])
+	require.Equal(t, 2, warnings[1]["consecutive_failures"])
+
+	require.NoError(t, lt.Close())
+	// closing is idempotent
+	require.NoError(t, lt.Close())
+}
+
+type mockLookupLogger struct {
+	loglib.NoopLogger
+	mutex    sync.Mutex
+	warnings []loglib.Fields
+}
+
+func (m *mockLookupLogger) Warn(_ error, _ string, fields ...loglib.Fields) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.warnings = append(m.warnings, fields...)
+}
+
+func (m *mockLookupLogger) WithFields(loglib.Fields) loglib.Logger {
+	return m
+}
+
+func (m *mockLookupLogger) getWarnings() []loglib.Fields {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]loglib.Fields{}, m.warnings...)
+}
+
+func TestPostgresLookupLoadFn(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	testRows := [][2]pgtype.Text{
+		{{String: "EU-W", Valid: true}, {String: "Western Europe", Valid: true}},
+		{{}, {String: "No key", Valid: true}},
+		{{String: "AP-S", Valid: true}, {}},
+		{{String: "US-E", Valid: true}, {String: "Eastern US", Valid: true}},
+	}
+
+	tests := []struct {
+		name    string
+		querier *pglibmocks.Querier
+
+		wantDictionary map[string]string
+		wantErr        error
+	}{
+		{
+			name: "ok",
+			querier: &pglibmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					require.Equal(t, "SELECT code, name FROM regions", query)
+					return &pglibmocks.Rows{
+						NextFn: func(i uint) bool { return i <= uint(len(testRows)) },
+						ScanFn: func(i uint, dest ...any) error {
+							require.Len(t, dest, 2)
+							*dest[0].(*pgtype.Text) = testRows[i-1][0]
+							*dest[1].(*pgtype.Text) = testRows[i-1][1]
+							return nil
+						},
+						ErrFn: func() error { return nil },
+					}, nil
+				},
+			},
+			wantDictionary: map[string]string{
+				"EU-W": "Western Europe",
+				"US-E": "Eastern US",
+			},
+		},
+		{
+			name: "error - query",
+			querier: &pglibmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					return nil, errTest
+				},
+			},
+			wantErr: errTest,
+		},
+		{
+			name: "error - scan",
+			querier: &pglibmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					return &pglibmocks.Rows{
+						NextFn: func(i uint) bool { return true },
+						ScanFn: func(i uint, dest ...any) error { return errTest },
+					}, nil
+				},
+			},
+			wantErr: errTest,
+		},
+		{
+			name: "error - rows",
+			querier: &pglibmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					return &pglibmocks.Rows{
+						NextFn: func(i uint) bool { return false },
+						ErrFn:  func() error { return errTest },
+					}, nil
+				},
+			},
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			load := newPostgresLookupLoadFn(tc.querier, "SELECT code, name FROM regions")
+			dictionary, err := load(context.Background(), 0)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantDictionary, dictionary)
+		})
+	}
+}
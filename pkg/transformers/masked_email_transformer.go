@@ -0,0 +1,297 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MaskedEmailTransformer masks email addresses deterministically, replacing
+// the local part with its keyed HMAC-SHA256 hash. The same source address
+// always produces the same masked address for a given key, so the masked
+// values are consistent across tables and runs. The domain can be kept, so
+// that the downstream logic routing by domain still works, or mapped to a
+// safe sink domain.
+type MaskedEmailTransformer struct {
+	key             []byte
+	preserveDomain  bool
+	preservePlusTag bool
+	sinkDomain      string
+	localPartLength int
+	lenient         bool
+}
+
+const (
+	maskedEmailStrictParsing  = "strict"
+	maskedEmailLenientParsing = "lenient"
+
+	defaultMaskedEmailSinkDomain      = "example.com"
+	defaultMaskedEmailLocalPartLength = 16
+	minMaskedEmailLocalPartLength     = 8
+	maxMaskedEmailLocalPartLength     = 2 * sha256.Size
+
+	maxEmailLocalPartLength = 64
+)
+
+var (
+	maskedEmailParams = []Parameter{
+		{
+			Name:          "key",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_env",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_file",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "preserve_domain",
+			SupportedType: "boolean",
+			Default:       false,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "preserve_plus_tag",
+			SupportedType: "boolean",
+			Default:       false,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "sink_domain",
+			SupportedType: "string",
+			Default:       defaultMaskedEmailSinkDomain,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "local_part_length",
+			SupportedType: "int",
+			Default:       defaultMaskedEmailLocalPartLength,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "parsing",
+			SupportedType: "string",
+			Default:       maskedEmailStrictParsing,
+			Dynamic:       false,
+			Required:      false,
+			Values:        []any{maskedEmailStrictParsing, maskedEmailLenientParsing},
+		},
+	}
+	maskedEmailCompatibleTypes = []SupportedDataType{
+		StringDataType,
+		CitextDataType,
+	}
+
+	ErrInvalidEmail = errors.New("masked_email: invalid email address")
+
+	errMaskedEmailDomainConflict      = errors.New("masked_email: sink_domain can't be used with preserve_domain")
+	errInvalidMaskedEmailSinkDomain   = errors.New("masked_email: sink_domain must be a valid domain name")
+	errInvalidMaskedEmailLocalPartLen = fmt.Errorf("masked_email: local_part_length must be between %d and %d", minMaskedEmailLocalPartLength, maxMaskedEmailLocalPartLength)
+	errInvalidMaskedEmailParsing      = errors.New("masked_email: parsing must be one of 'strict' or 'lenient'")
+)
+
+func NewMaskedEmailTransformer(params ParameterValues) (*MaskedEmailTransformer, error) {
+	key, err := getSecretKey(params)
+	if err != nil {
+		return nil, fmt.Errorf("masked_email: %w", err)
+	}
+
+	preserveDomain, err := FindParameterWithDefault(params, "preserve_domain", false)
+	if err != nil {
+		return nil, fmt.Errorf("masked_email: preserve_domain must be a boolean: %w", err)
+	}
+	preservePlusTag, err := FindParameterWithDefault(params, "preserve_plus_tag", false)
+	if err != nil {
+		return nil, fmt.Errorf("masked_email: preserve_plus_tag must be a boolean: %w", err)
+	}
+
+	sinkDomain, sinkDomainFound, err := FindParameter[string](params, "sink_domain")
+	if err != nil {
+		return nil, fmt.Errorf("masked_email: sink_domain must be a string: %w", err)
+	}
+	if sinkDomainFound && preserveDomain {
+		return nil, errMaskedEmailDomainConflict
+	}
+	if !sinkDomainFound {
+		sinkDomain = defaultMaskedEmailSinkDomain
+	}
+	sinkDomain = strings.ToLower(strings.TrimPrefix(sinkDomain, "@"))
+	if !isValidEmailDomain(sinkDomain) {
+		return nil, errInvalidMaskedEmailSinkDomain
+	}
+
+	localPartLength, err := FindParameterWithDefault(params, "local_part_length", defaultMaskedEmailLocalPartLength)
+	if err != nil {
+		return nil, fmt.Errorf("masked_email: local_part_length must be an integer: %w", err)
+	}
+	if localPartLength < minMaskedEmailLocalPartLength || localPartLength > maxMaskedEmailLocalPartLength {
+		return nil, errInvalidMaskedEmailLocalPartLen
+	}
+
+	parsing, err := FindParameterWithDefault(params, "parsing", maskedEmailStrictParsing)
+	if err != nil {
+		return nil, fmt.Errorf("masked_email: parsing must be a string: %w", err)
+	}
+	switch parsing {
+	case maskedEmailStrictParsing, maskedEmailLenientParsing:
+	default:
+		return nil, errInvalidMaskedEmailParsing
+	}
+
+	return &MaskedEmailTransformer{
+		key:             key,
+		preserveDomain:  preserveDomain,
+		preservePlusTag: preservePlusTag,
+		sinkDomain:      sinkDomain,
+		localPartLength: localPartLength,
+		lenient:         parsing == maskedEmailLenientParsing,
+	}, nil
+}
+
+func (t *MaskedEmailTransformer) Transform(_ context.Context, value Value) (any, error) {
+	switch v := value.TransformValue.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return t.transform(v)
+	case []byte:
+		return t.transform(string(v))
+	default:
+		return nil, fmt.Errorf("masked_email: expected string, got %T: %w", value.TransformValue, ErrUnsupportedValueType)
+	}
+}
+
+func (t *MaskedEmailTransformer) transform(email string) (string, error) {
+	localPart, domain, ok := parseEmail(email)
+	if !ok {
+		if !t.lenient {
+			return "", ErrInvalidEmail
+		}
+		// malformed values are hashed as a whole, so that the output is
+		// always a valid address in the sink domain
+		return t.hash(strings.TrimSpace(email)) + "@" + t.sinkDomain, nil
+	}
+
+	// domains are case insensitive, so they're normalised to produce the
+	// same masked address regardless of the case
+	domain = strings.ToLower(domain)
+	// the plus tag is not part of the hash when preserved, so that all the
+	// tagged addresses of a mailbox share the same masked local part
+	masked := t.hash(localPart + "@" + domain)
+	if base, tag, found := strings.Cut(localPart, "+"); found && t.preservePlusTag {
+		masked = t.hash(base+"@"+domain) + "+" + tag
+	}
+
+	if !t.preserveDomain {
+		domain = t.sinkDomain
+	}
+	return masked + "@" + domain, nil
+}
+
+func (t *MaskedEmailTransformer) hash(input string) string {
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(input))
+	return hex.EncodeToString(mac.Sum(nil))[:t.localPartLength]
+}
+
+func (t *MaskedEmailTransformer) CompatibleTypes() []SupportedDataType {
+	return maskedEmailCompatibleTypes
+}
+
+func (t *MaskedEmailTransformer) Type() TransformerType {
+	return MaskedEmail
+}
+
+func (t *MaskedEmailTransformer) IsDynamic() bool {
+	return false
+}
+
+func (t *MaskedEmailTransformer) Close() error {
+	return nil
+}
+
+func MaskedEmailTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: maskedEmailCompatibleTypes,
+		Parameters:     maskedEmailParams,
+	}
+}
+
+// parseEmail splits the email address on input into its local part and
+// domain, and returns false if the address is not valid. Only the plain
+// `local@domain` form is supported, without display names or quoted local
+// parts.
+func parseEmail(email string) (string, string, bool) {
+	localPart, domain, found := strings.Cut(email, "@")
+	if !found || !isValidEmailLocalPart(localPart) || !isValidEmailDomain(domain) {
+		return "", "", false
+	}
+	return localPart, domain, true
+}
+
+func isValidEmailLocalPart(localPart string) bool {
+	if localPart == "" || len(localPart) > maxEmailLocalPartLength {
+		return false
+	}
+	if strings.HasPrefix(localPart, ".") || strings.HasSuffix(localPart, ".") || strings.Contains(localPart, "..") {
+		return false
+	}
+	for _, r := range localPart {
+		switch {
+		case isASCIIAlphanumeric(r), r == '.':
+		case strings.ContainsRune("!#$%&'*+/=?^_`{|}~-", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isValidEmailDomain returns true if the domain on input is a valid host name
+// with at least two labels.
+func isValidEmailDomain(domain string) bool {
+	if len(domain) > 253 {
+		return false
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !isASCIIAlphanumeric(r) && r != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isASCIIAlphanumeric(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMaskedEmailTransformer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+
+		wantErr error
+	}{
+		{
+			name:   "ok - defaults",
+			params: ParameterValues{"key": "secret"},
+		},
+		{
+			name: "ok - all parameters",
+			params: ParameterValues{
+				"key":               "secret",
+				"preserve_plus_tag": true,
+				"sink_domain":       "@masked.example.org",
+				"local_part_length": 32,
+				"parsing":           "lenient",
+			},
+		},
+		{
+			name:    "error - missing key",
+			params:  ParameterValues{},
+			wantErr: errKeyNotFound,
+		},
+		{
+			name:    "error - sink domain with preserve domain",
+			params:  ParameterValues{"key": "secret", "preserve_domain": true, "sink_domain": "example.org"},
+			wantErr: errMaskedEmailDomainConflict,
+		},
+		{
+			name:    "error - invalid sink domain",
+			params:  ParameterValues{"key": "secret", "sink_domain": "localhost"},
+			wantErr: errInvalidMaskedEmailSinkDomain,
+		},
+		{
+			name:    "error - invalid local part length",
+			params:  ParameterValues{"key": "secret", "local_part_length": 4},
+			wantErr: errInvalidMaskedEmailLocalPartLen,
+		},
+		{
+			name:    "error - invalid parsing",
+			params:  ParameterValues{"key": "secret", "parsing": "relaxed"},
+			wantErr: errInvalidMaskedEmailParsing,
+		},
+		{
+			name:    "error - invalid preserve domain type",
+			params:  ParameterValues{"key": "secret", "preserve_domain": "yes"},
+			wantErr: ErrInvalidParameters,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewMaskedEmailTransformer(tc.params)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestMaskedEmailTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	newTransformer := func(t *testing.T, params ParameterValues) *MaskedEmailTransformer {
+		params["key"] = "secret"
+		transformer, err := NewMaskedEmailTransformer(params)
+		require.NoError(t, err)
+		return transformer
+	}
+	transform := func(t *testing.T, transformer *MaskedEmailTransformer, value any) string {
+		got, err := transformer.Transform(context.Background(), NewValue(value, "text", nil))
+		require.NoError(t, err)
+		gotStr, ok := got.(string)
+		require.True(t, ok)
+		return gotStr
+	}
+
+	t.Run("ok - sink domain", func(t *testing.T) {
+		t.Parallel()
+
+		transformer := newTransformer(t, ParameterValues{})
+		got := transform(t, transformer, "alice.smith+news@Company.com")
+		localPart, domain, found := strings.Cut(got, "@")
+		require.True(t, found)
+		require.Len(t, localPart, defaultMaskedEmailLocalPartLength)
+		require.Equal(t, "example.com", domain)
+
+		// deterministic, and the domain case doesn't change the output
+		require.Equal(t, got, transform(t, transformer, []byte("alice.smith+news@company.com")))
+		// different addresses in the same sink domain don't collide
+		require.NotEqual(t, got, transform(t, transformer, "alice.smith+news@other.com"))
+		require.NotEqual(t, got, transform(t, transformer, "alice.smith+promo@company.com"))
+	})
+
+	t.Run("ok - preserve domain", func(t *testing.T) {
+		t.Parallel()
+
+		transformer := newTransformer(t, ParameterValues{"preserve_domain": true, "local_part_length": 10})
+		got := transform(t, transformer, "alice@Company.com")
+		localPart, domain, found := strings.Cut(got, "@")
+		require.True(t, found)
+		require.Len(t, localPart, 10)
+		require.NotEqual(t, "alice", localPart)
+		require.Equal(t, "company.com", domain)
+	})
+
+	t.Run("ok - preserve plus tag", func(t *testing.T) {
+		t.Parallel()
+
+		transformer := newTransformer(t, ParameterValues{"preserve_plus_tag": true, "sink_domain": "masked.example.org"})
+		news := transform(t, transformer, "alice+news@company.com")
+		promo := transform(t, transformer, "alice+promo@company.com")
+		untagged := transform(t, transformer, "alice@company.com")
+
+		newsBase, found := strings.CutSuffix(news, "+news@masked.example.org")
+		require.True(t, found)
+		promoBase, found := strings.CutSuffix(promo, "+promo@masked.example.org")
+		require.True(t, found)
+		require.Equal(t, newsBase, promoBase)
+		require.Equal(t, newsBase+"@masked.example.org", untagged)
+	})
+
+	t.Run("ok - key changes the output", func(t *testing.T) {
+		t.Parallel()
+
+		transformer := newTransformer(t, ParameterValues{})
+		other, err := NewMaskedEmailTransformer(ParameterValues{"key": "other"})
+		require.NoError(t, err)
+		require.NotEqual(t, transform(t, transformer, "alice@company.com"), transform(t, other, "alice@company.com"))
+	})
+
+	t.Run("ok - null", func(t *testing.T) {
+		t.Parallel()
+
+		got, err := newTransformer(t, ParameterValues{}).Transform(context.Background(), NewValue(nil, "text", nil))
+		require.NoError(t, err)
+		require.Nil(t, got)
+	})
+
+	t.Run("ok - lenient parsing", func(t *testing.T) {
+		t.Parallel()
+
+		transformer := newTransformer(t, ParameterValues{"parsing": "lenient", "preserve_domain": true})
+		got := transform(t, transformer, " not-an-email ")
+		localPart, domain, found := strings.Cut(got, "@")
+		require.True(t, found)
+		require.Len(t, localPart, defaultMaskedEmailLocalPartLength)
+		require.Equal(t, "example.com", domain)
+		require.Equal(t, got, transform(t, transformer, "not-an-email"))
+	})
+
+	t.Run("error - strict parsing", func(t *testing.T) {
+		t.Parallel()
+
+		transformer := newTransformer(t, ParameterValues{})
+		for _, invalid := range []string{
+			"not-an-email",
+			"@company.com",
+			"alice@",
+			"alice@localhost",
+			"alice@@company.com",
+			"alice..smith@company.com",
+			"alice smith@company.com",
+			"alice@-company.com",
+			"Alice <alice@company.com>",
+		} {
+			_, err := transformer.Transform(context.Background(), NewValue(invalid, "text", nil))
+			require.ErrorIs(t, err, ErrInvalidEmail, invalid)
+		}
+	})
+
+	t.Run("error - unsupported type", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newTransformer(t, ParameterValues{}).Transform(context.Background(), NewValue(1, "int", nil))
+		require.ErrorIs(t, err, ErrUnsupportedValueType)
+	})
+}
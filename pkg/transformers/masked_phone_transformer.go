@@ -0,0 +1,357 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// MaskedPhoneTransformer masks phone numbers keeping them valid looking, so
+// that they still pass the downstream validation (i.e. SMS providers in
+// staging environments). The input is parsed on a best effort basis: the
+// international or trunk prefix and the country code are preserved, as well
+// as the separators, and the subscriber digits are replaced by digits derived
+// from the keyed HMAC-SHA256 of the seed. The same number always produces the
+// same masked number for a given key, whether it's in E.164 or national
+// format. Values that can't be parsed as a phone number are masked keeping
+// their character classes.
+type MaskedPhoneTransformer struct {
+	key            []byte
+	preservePrefix int
+	seed           *DynamicParameter
+}
+
+const (
+	minPhoneNumberDigits = 7
+	maxPhoneNumberDigits = 15
+
+	// internationalCallPrefix is the prefix used instead of + to dial
+	// international numbers in most countries
+	internationalCallPrefix = "00"
+	nanpCountryCode         = "1"
+	nanpNumberLength        = 10
+	trunkPrefix             = '0'
+)
+
+var (
+	maskedPhoneParams = []Parameter{
+		{
+			Name:          "key",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_env",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_file",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "preserve_prefix",
+			SupportedType: "int",
+			Default:       0,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          seedParam,
+			SupportedType: "any",
+			Default:       nil,
+			Dynamic:       true,
+			Required:      false,
+		},
+	}
+	maskedPhoneCompatibleTypes = []SupportedDataType{
+		StringDataType,
+		ByteArrayDataType,
+	}
+
+	errInvalidMaskedPhonePreservePrefix = errors.New("masked_phone: preserve_prefix must be greater than or equal to 0")
+	errMaskedPhoneMissingSeedValue      = errors.New("masked_phone: missing value for seed dynamic parameter")
+
+	// phoneExtensionRegex matches the extension at the end of a phone number,
+	// i.e. `ext. 123` or `x123`
+	phoneExtensionRegex = regexp.MustCompile(`(?i)\s*(?:ext\.?|extension|x|#)\s*\d{1,6}\s*$`)
+
+	// twoDigitCountryCodes are the two digit country calling codes. Country
+	// codes are prefix free, so the ones starting with 1 or 7 have a single
+	// digit, and the rest have three digits.
+	twoDigitCountryCodes = map[string]struct{}{
+		"20": {}, "27": {}, "30": {}, "31": {}, "32": {}, "33": {}, "34": {}, "36": {}, "39": {},
+		"40": {}, "41": {}, "43": {}, "44": {}, "45": {}, "46": {}, "47": {}, "48": {}, "49": {},
+		"51": {}, "52": {}, "53": {}, "54": {}, "55": {}, "56": {}, "57": {}, "58": {},
+		"60": {}, "61": {}, "62": {}, "63": {}, "64": {}, "65": {}, "66": {},
+		"81": {}, "82": {}, "84": {}, "86": {},
+		"90": {}, "91": {}, "92": {}, "93": {}, "94": {}, "95": {}, "98": {},
+	}
+)
+
+func NewMaskedPhoneTransformer(params, dynamicParams ParameterValues) (*MaskedPhoneTransformer, error) {
+	key, err := getSecretKey(params)
+	if err != nil {
+		return nil, fmt.Errorf("masked_phone: %w", err)
+	}
+
+	preservePrefix, err := FindParameterWithDefault(params, "preserve_prefix", 0)
+	if err != nil {
+		return nil, fmt.Errorf("masked_phone: preserve_prefix must be an integer: %w", err)
+	}
+	if preservePrefix < 0 {
+		return nil, errInvalidMaskedPhonePreservePrefix
+	}
+
+	dynamicParamMap, err := ParseDynamicParameters(dynamicParams)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MaskedPhoneTransformer{
+		key:            key,
+		preservePrefix: preservePrefix,
+		seed:           dynamicParamMap[seedParam],
+	}, nil
+}
+
+func (t *MaskedPhoneTransformer) Transform(_ context.Context, value Value) (any, error) {
+	switch v := value.TransformValue.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return t.transform(v, value.DynamicValues)
+	case []byte:
+		masked, err := t.transform(string(v), value.DynamicValues)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(masked), nil
+	default:
+		return nil, fmt.Errorf("masked_phone: expected string, got %T: %w", value.TransformValue, ErrUnsupportedValueType)
+	}
+}
+
+func (t *MaskedPhoneTransformer) transform(value string, dynamicValues map[string]any) (string, error) {
+	number, ok := parsePhoneNumber(value)
+	seed := []byte(value)
+	if ok {
+		// the national number is used as the seed, so that the number is
+		// masked the same way regardless of its format
+		seed = []byte(number.digits[number.nationalStart:])
+	}
+	if t.seed != nil {
+		seedValue, found := dynamicValues[t.seed.Column]
+		if !found || seedValue == nil {
+			return "", errMaskedPhoneMissingSeedValue
+		}
+		seed = fmt.Appendf(nil, "%v", seedValue)
+	}
+	stream := newHMACStream(t.key, seed)
+
+	if !ok {
+		return maskCharacterClasses(value, stream), nil
+	}
+	return number.mask(t.preservePrefix, stream), nil
+}
+
+func (t *MaskedPhoneTransformer) CompatibleTypes() []SupportedDataType {
+	return maskedPhoneCompatibleTypes
+}
+
+func (t *MaskedPhoneTransformer) Type() TransformerType {
+	return MaskedPhone
+}
+
+func (t *MaskedPhoneTransformer) IsDynamic() bool {
+	return t.seed != nil
+}
+
+func (t *MaskedPhoneTransformer) Close() error {
+	return nil
+}
+
+func MaskedPhoneTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: maskedPhoneCompatibleTypes,
+		Parameters:     maskedPhoneParams,
+	}
+}
+
+// phoneNumber is a parsed phone number. The digits before the national start
+// are the international prefix and country code, or the trunk prefix, and are
+// always preserved.
+type phoneNumber struct {
+	value []rune
+	// positions of the digits in the value
+	positions     []int
+	digits        string
+	nationalStart int
+	nanp          bool
+	// extension is the suffix of the value with the phone extension, if any
+	extension string
+}
+
+// parsePhoneNumber parses the phone number on input, allowing for the usual
+// separators, and returns false if it doesn't look like a phone number.
+func parsePhoneNumber(value string) (*phoneNumber, bool) {
+	number := &phoneNumber{}
+	if loc := phoneExtensionRegex.FindStringIndex(value); loc != nil {
+		value, number.extension = value[:loc[0]], value[loc[0]:]
+	}
+
+	number.value = []rune(value)
+	international := false
+	digits := strings.Builder{}
+	for i, r := range number.value {
+		switch {
+		case r >= '0' && r <= '9':
+			number.positions = append(number.positions, i)
+			digits.WriteRune(r)
+		case r == '+' && digits.Len() == 0 && !international:
+			international = true
+		case r == ' ' || r == '-' || r == '.' || r == '/' || r == '(' || r == ')':
+		default:
+			return nil, false
+		}
+	}
+	number.digits = digits.String()
+
+	maxDigits := maxPhoneNumberDigits
+	if !international && strings.HasPrefix(number.digits, internationalCallPrefix) {
+		maxDigits += len(internationalCallPrefix)
+	}
+	if len(number.digits) < minPhoneNumberDigits || len(number.digits) > maxDigits {
+		return nil, false
+	}
+
+	switch {
+	case international:
+		number.nationalStart = countryCodeLength(number.digits)
+	case strings.HasPrefix(number.digits, internationalCallPrefix):
+		number.nationalStart = len(internationalCallPrefix) + countryCodeLength(number.digits[len(internationalCallPrefix):])
+	case len(number.digits) == nanpNumberLength+1 && strings.HasPrefix(number.digits, nanpCountryCode):
+		number.nationalStart = len(nanpCountryCode)
+	case number.digits[0] == trunkPrefix:
+		number.nationalStart = 1
+	}
+
+	// national numbers without prefix are assumed to be NANP numbers when
+	// they have the expected length, since their format is the most common
+	// one without trunk prefix
+	countryCode := strings.TrimPrefix(number.digits[:number.nationalStart], internationalCallPrefix)
+	nationalLength := len(number.digits) - number.nationalStart
+	number.nanp = nationalLength == nanpNumberLength && (countryCode == nanpCountryCode || number.nationalStart == 0)
+	// the national number must have some digits left to be masked
+	if number.nationalStart >= len(number.digits) {
+		return nil, false
+	}
+	return number, true
+}
+
+// mask replaces the national number digits after the preserved prefix with
+// digits from the stream, and returns the masked number in its original
+// format. The masked national number never starts with 0, so that it can't be
+// mistaken for a trunk prefix, and NANP numbers keep valid area and exchange
+// codes.
+func (n *phoneNumber) mask(preservePrefix int, stream *hmacStream) string {
+	masked := make([]rune, len(n.value))
+	copy(masked, n.value)
+
+	for i := n.nationalStart + preservePrefix; i < len(n.positions); i++ {
+		b := stream.next()
+		nationalPos := i - n.nationalStart
+		var digit rune
+		switch {
+		case n.nanp && (nationalPos == 0 || nationalPos == 3):
+			digit = rune('2' + b%8)
+		case nationalPos == 0:
+			digit = rune('1' + b%9)
+		default:
+			digit = rune('0' + b%10)
+		}
+		masked[n.positions[i]] = digit
+	}
+
+	// the extension label is kept as is, only its digits are masked
+	extension := []rune(n.extension)
+	for i, r := range extension {
+		if r >= '0' && r <= '9' {
+			extension[i] = rune('0' + stream.next()%10)
+		}
+	}
+	return string(masked) + string(extension)
+}
+
+// countryCodeLength returns the length of the country calling code the
+// digits on input start with.
+func countryCodeLength(digits string) int {
+	switch {
+	case digits == "":
+		return 0
+	case digits[0] == '1' || digits[0] == '7':
+		return 1
+	case len(digits) < 2:
+		return len(digits)
+	}
+	if _, found := twoDigitCountryCodes[digits[:2]]; found {
+		return 2
+	}
+	return min(3, len(digits))
+}
+
+// maskCharacterClasses masks the digits and letters on input with characters
+// of the same class from the stream, keeping the rest of the characters.
+func maskCharacterClasses(value string, stream *hmacStream) string {
+	masked := []rune(value)
+	for i, r := range masked {
+		if (unicode.IsDigit(r) && r < unicode.MaxASCII) || unicode.IsLetter(r) {
+			masked[i] = maskRune(r, stream.next())
+		}
+	}
+	return string(masked)
+}
+
+// hmacStream produces an arbitrary number of bytes from the keyed HMAC-SHA256
+// of the seed and a block counter.
+type hmacStream struct {
+	key     []byte
+	seed    []byte
+	counter uint32
+	block   []byte
+}
+
+func newHMACStream(key, seed []byte) *hmacStream {
+	return &hmacStream{
+		key:  key,
+		seed: seed,
+	}
+}
+
+func (s *hmacStream) next() byte {
+	if len(s.block) == 0 {
+		mac := hmac.New(sha256.New, s.key)
+		mac.Write(s.seed)
+		mac.Write(binary.BigEndian.AppendUint32(nil, s.counter))
+		s.block = mac.Sum(nil)
+		s.counter++
+	}
+	b := s.block[0]
+	s.block = s.block[1:]
+	return b
+}
@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMaskedPhoneTransformer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		params        ParameterValues
+		dynamicParams ParameterValues
+
+		wantErr error
+	}{
+		{
+			name:   "ok",
+			params: ParameterValues{"key": "secret"},
+		},
+		{
+			name:          "ok - with preserve prefix and seed",
+			params:        ParameterValues{"key": "secret", "preserve_prefix": 3},
+			dynamicParams: ParameterValues{"seed": map[string]any{"column": "id"}},
+		},
+		{
+			name:    "error - missing key",
+			params:  ParameterValues{},
+			wantErr: errKeyNotFound,
+		},
+		{
+			name:    "error - invalid preserve prefix type",
+			params:  ParameterValues{"key": "secret", "preserve_prefix": "3"},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - negative preserve prefix",
+			params:  ParameterValues{"key": "secret", "preserve_prefix": -1},
+			wantErr: errInvalidMaskedPhonePreservePrefix,
+		},
+		{
+			name:          "error - invalid seed",
+			params:        ParameterValues{"key": "secret"},
+			dynamicParams: ParameterValues{"seed": "id"},
+			wantErr:       ErrInvalidDynamicParameters,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewMaskedPhoneTransformer(tc.params, tc.dynamicParams)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestMaskedPhoneTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+		value  any
+
+		// wantPattern is the expected format of the masked value
+		wantPattern string
+		wantErr     error
+	}{
+		{
+			name:        "ok - e164",
+			value:       "+14155552671",
+			wantPattern: `^\+1[2-9]\d\d[2-9]\d{6}$`,
+		},
+		{
+			name:        "ok - e164 with two digit country code",
+			value:       "+442079460958",
+			wantPattern: `^\+44[1-9]\d{9}$`,
+		},
+		{
+			name:        "ok - e164 with three digit country code",
+			value:       "+353 1 234 5678",
+			wantPattern: `^\+353 [1-9] \d{3} \d{4}$`,
+		},
+		{
+			name:        "ok - international call prefix",
+			value:       "0049 30 1234567",
+			wantPattern: `^0049 [1-9]\d \d{7}$`,
+		},
+		{
+			name:        "ok - national with trunk prefix",
+			value:       "020 7946 0958",
+			wantPattern: `^0[1-9]\d \d{4} \d{4}$`,
+		},
+		{
+			name:        "ok - nanp national",
+			value:       "(415) 555-2671",
+			wantPattern: `^\([2-9]\d\d\) [2-9]\d\d-\d{4}$`,
+		},
+		{
+			name:        "ok - nanp with country code and dots",
+			value:       "1.415.555.2671",
+			wantPattern: `^1\.[2-9]\d\d\.[2-9]\d\d\.\d{4}$`,
+		},
+		{
+			name:        "ok - extension",
+			value:       "+1 415 555 2671 ext. 42",
+			wantPattern: `^\+1 [2-9]\d\d [2-9]\d\d \d{4} ext\. \d\d$`,
+		},
+		{
+			name:        "ok - preserve prefix",
+			params:      ParameterValues{"preserve_prefix": 3},
+			value:       "+1 415 555 2671",
+			wantPattern: `^\+1 415 [2-9]\d\d \d{4}$`,
+		},
+		{
+			name:        "ok - bytes",
+			value:       []byte("+14155552671"),
+			wantPattern: `^\+1[2-9]\d\d[2-9]\d{6}$`,
+		},
+		{
+			name:        "ok - unparseable free form",
+			value:       "call Bob: 555-1234",
+			wantPattern: `^[a-z]{4} [A-Z][a-z]{2}: \d{3}-\d{4}$`,
+		},
+		{
+			name:        "ok - too short",
+			value:       "12-34",
+			wantPattern: `^\d\d-\d\d$`,
+		},
+		{
+			name:    "error - unsupported type",
+			value:   14155552671,
+			wantErr: ErrUnsupportedValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			params := ParameterValues{"key": "secret"}
+			for k, v := range tc.params {
+				params[k] = v
+			}
+			transformer, err := NewMaskedPhoneTransformer(params, nil)
+			require.NoError(t, err)
+
+			got, err := transformer.Transform(context.Background(), NewValue(tc.value, "text", nil))
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			require.IsType(t, tc.value, got)
+			masked, original := toString(got), toString(tc.value)
+			require.Regexp(t, regexp.MustCompile(tc.wantPattern), masked)
+			require.NotEqual(t, original, masked)
+
+			// masking is deterministic
+			again, err := transformer.Transform(context.Background(), NewValue(tc.value, "text", nil))
+			require.NoError(t, err)
+			require.Equal(t, got, again)
+		})
+	}
+}
+
+func TestMaskedPhoneTransformer_Transform_consistency(t *testing.T) {
+	t.Parallel()
+
+	transformer, err := NewMaskedPhoneTransformer(ParameterValues{"key": "secret"}, nil)
+	require.NoError(t, err)
+	otherKeyTransformer, err := NewMaskedPhoneTransformer(ParameterValues{"key": "other"}, nil)
+	require.NoError(t, err)
+
+	transform := func(tr *MaskedPhoneTransformer, value string, dynamicValues map[string]any) string {
+		got, err := tr.Transform(context.Background(), NewValue(value, "text", dynamicValues))
+		require.NoError(t, err)
+		return got.(string)
+	}
+
+	// the same number is masked the same way regardless of its format
+	nationalDigits := regexp.MustCompile(`\D`)
+	e164 := transform(transformer, "+442079460958", nil)
+	national := transform(transformer, "020 7946 0958", nil)
+	require.Equal(t, nationalDigits.ReplaceAllString(e164, "")[2:], nationalDigits.ReplaceAllString(national, "")[1:])
+
+	// different keys produce different numbers
+	require.NotEqual(t, e164, transform(otherKeyTransformer, "+442079460958", nil))
+
+	// with a seed, the masked number depends on the seed value
+	seeded, err := NewMaskedPhoneTransformer(ParameterValues{"key": "secret"}, ParameterValues{"seed": map[string]any{"column": "id"}})
+	require.NoError(t, err)
+	require.True(t, seeded.IsDynamic())
+	require.Equal(t,
+		transform(seeded, "+442079460958", map[string]any{"id": 1}),
+		transform(seeded, "+442079460958", map[string]any{"id": 1}))
+	require.NotEqual(t,
+		transform(seeded, "+442079460958", map[string]any{"id": 1}),
+		transform(seeded, "+442079460958", map[string]any{"id": 2}))
+
+	_, err = seeded.Transform(context.Background(), NewValue("+442079460958", "text", map[string]any{}))
+	require.ErrorIs(t, err, errMaskedPhoneMissingSeedValue)
+}
+
+func TestCountryCodeLength(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		digits string
+		want   int
+	}{
+		{digits: "14155552671", want: 1},
+		{digits: "74951234567", want: 1},
+		{digits: "442079460958", want: 2},
+		{digits: "861012345678", want: 2},
+		{digits: "35312345678", want: 3},
+		{digits: "9715012345678", want: 3},
+		{digits: "", want: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.digits, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.want, countryCodeLength(tc.digits))
+		})
+	}
+}
+
+func toString(value any) string {
+	if b, ok := value.([]byte); ok {
+		return string(b)
+	}
+	return value.(string)
+}
@@ -9,9 +9,11 @@ import (
 )
 
 type Transformer struct {
-	TransformFn       func(transformers.Value) (any, error)
-	IsDynamicFn       func() bool
-	CompatibleTypesFn func() []transformers.SupportedDataType
+	TransformFn         func(transformers.Value) (any, error)
+	IsDynamicFn         func() bool
+	CompatibleTypesFn   func() []transformers.SupportedDataType
+	ReferencedColumnsFn func() []string
+	CloseFn             func() error
 }
 
 func (m *Transformer) Transform(_ context.Context, val transformers.Value) (any, error) {
@@ -33,6 +35,16 @@ func (m *Transformer) IsDynamic() bool {
 	return false
 }
 
+func (m *Transformer) ReferencedColumns() []string {
+	if m.ReferencedColumnsFn != nil {
+		return m.ReferencedColumnsFn()
+	}
+	return nil
+}
+
 func (m *Transformer) Close() error {
+	if m.CloseFn != nil {
+		return m.CloseFn()
+	}
 	return nil
 }
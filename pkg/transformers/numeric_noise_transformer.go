@@ -0,0 +1,542 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// NumericNoiseTransformer adds bounded random noise to numeric values, so that
+// the transformed values are realistic in aggregate (i.e. salaries or revenue
+// figures) without exposing the exact values. The noise can be drawn from a
+// uniform or a gaussian distribution, and be either an absolute amount or a
+// percentage of the value. When a consistency key is configured, the noise is
+// derived from the HMAC of the key value, so that the same row always gets the
+// same noise and re-snapshots are stable.
+type NumericNoiseTransformer struct {
+	distribution noiseDistribution
+	mode         noiseMode
+	noise        *big.Rat
+	granularity  *big.Rat
+	minValue     *big.Rat
+	maxValue     *big.Rat
+	key          []byte
+	// consistencyKey is the column used to seed the noise, if any
+	consistencyKey *DynamicParameter
+}
+
+type noiseDistribution string
+
+const (
+	uniformNoise  noiseDistribution = "uniform"
+	gaussianNoise noiseDistribution = "gaussian"
+)
+
+type noiseMode string
+
+const (
+	absoluteNoise   noiseMode = "absolute"
+	percentageNoise noiseMode = "percentage"
+)
+
+// gaussianNoiseBound is the number of standard deviations the gaussian noise
+// is truncated at, so that the noise is bounded.
+const gaussianNoiseBound = 3
+
+// noiseSource is a source of random numbers for the noise.
+type noiseSource interface {
+	Float64() float64
+	NormFloat64() float64
+}
+
+// globalNoiseSource uses the concurrency safe top level math/rand functions.
+type globalNoiseSource struct{}
+
+func (globalNoiseSource) Float64() float64     { return rand.Float64() }
+func (globalNoiseSource) NormFloat64() float64 { return rand.NormFloat64() }
+
+var (
+	errNumericNoiseInvalidNoise        = errors.New("numeric_noise: noise must be greater than 0")
+	errNumericNoiseInvalidGranularity  = errors.New("numeric_noise: granularity must be greater than 0")
+	errNumericNoiseInvalidRange        = errors.New("numeric_noise: min_value must be less than or equal to max_value")
+	errNumericNoiseInvalidDistribution = errors.New("numeric_noise: distribution must be one of uniform or gaussian")
+	errNumericNoiseInvalidMode         = errors.New("numeric_noise: mode must be one of absolute or percentage")
+	errNumericNoiseNullConsistencyKey  = errors.New("numeric_noise: consistency key value cannot be null")
+
+	// numericTypeModifierRegex matches the precision and optional scale of
+	// the numeric type names, i.e. numeric(10,2)
+	numericTypeModifierRegex = regexp.MustCompile(`^(?:numeric|decimal)\((\d+)(?:,\s*(-?\d+))?\)$`)
+
+	numericNoiseCompatibleTypes = []SupportedDataType{
+		Integer16DataType,
+		Integer32DataType,
+		Integer64DataType,
+		Float32DataType,
+		Float64DataType,
+		NumericDataType,
+	}
+	numericNoiseParams = []Parameter{
+		{
+			Name:          "noise",
+			SupportedType: "float",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      true,
+		},
+		{
+			Name:          "mode",
+			SupportedType: "string",
+			Default:       string(percentageNoise),
+			Dynamic:       false,
+			Required:      false,
+			Values:        []any{string(percentageNoise), string(absoluteNoise)},
+		},
+		{
+			Name:          "distribution",
+			SupportedType: "string",
+			Default:       string(uniformNoise),
+			Dynamic:       false,
+			Required:      false,
+			Values:        []any{string(uniformNoise), string(gaussianNoise)},
+		},
+		{
+			Name:          "granularity",
+			SupportedType: "float",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "min_value",
+			SupportedType: "float",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "max_value",
+			SupportedType: "float",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_env",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "key_file",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          consistencyKeyParam,
+			SupportedType: "any",
+			Default:       nil,
+			Dynamic:       true,
+			Required:      false,
+		},
+	}
+)
+
+func NewNumericNoiseTransformer(params, dynamicParams ParameterValues) (*NumericNoiseTransformer, error) {
+	t := &NumericNoiseTransformer{}
+
+	noise, found, err := findNumberParameter(params, "noise")
+	if err != nil {
+		return nil, fmt.Errorf("numeric_noise: noise must be a number: %w", err)
+	}
+	if !found || noise <= 0 {
+		return nil, errNumericNoiseInvalidNoise
+	}
+	t.noise = newRat(noise)
+
+	mode, err := FindParameterWithDefault(params, "mode", string(percentageNoise))
+	if err != nil {
+		return nil, fmt.Errorf("numeric_noise: mode must be a string: %w", err)
+	}
+	switch t.mode = noiseMode(mode); t.mode {
+	case absoluteNoise:
+	case percentageNoise:
+		t.noise.Quo(t.noise, big.NewRat(100, 1))
+	default:
+		return nil, errNumericNoiseInvalidMode
+	}
+
+	distribution, err := FindParameterWithDefault(params, "distribution", string(uniformNoise))
+	if err != nil {
+		return nil, fmt.Errorf("numeric_noise: distribution must be a string: %w", err)
+	}
+	switch t.distribution = noiseDistribution(distribution); t.distribution {
+	case uniformNoise, gaussianNoise:
+	default:
+		return nil, errNumericNoiseInvalidDistribution
+	}
+
+	granularity, found, err := findNumberParameter(params, "granularity")
+	if err != nil {
+		return nil, fmt.Errorf("numeric_noise: granularity must be a number: %w", err)
+	}
+	if found {
+		if granularity <= 0 {
+			return nil, errNumericNoiseInvalidGranularity
+		}
+		t.granularity = newRat(granularity)
+	}
+
+	minValue, found, err := findNumberParameter(params, "min_value")
+	if err != nil {
+		return nil, fmt.Errorf("numeric_noise: min_value must be a number: %w", err)
+	}
+	if found {
+		t.minValue = newRat(minValue)
+	}
+	maxValue, found, err := findNumberParameter(params, "max_value")
+	if err != nil {
+		return nil, fmt.Errorf("numeric_noise: max_value must be a number: %w", err)
+	}
+	if found {
+		t.maxValue = newRat(maxValue)
+	}
+	if t.minValue != nil && t.maxValue != nil && t.minValue.Cmp(t.maxValue) > 0 {
+		return nil, errNumericNoiseInvalidRange
+	}
+
+	dynamicParamMap, err := ParseDynamicParameters(dynamicParams)
+	if err != nil {
+		return nil, err
+	}
+	// the secret key is only used to seed the noise with the consistency key,
+	// otherwise the noise is random
+	if t.consistencyKey = dynamicParamMap[consistencyKeyParam]; t.consistencyKey != nil {
+		if t.key, err = getSecretKey(params); err != nil {
+			return nil, fmt.Errorf("numeric_noise: %w", err)
+		}
+	}
+
+	return t, nil
+}
+
+// Transform adds noise to the value on input, and returns it with the same
+// type. Integer values are kept as integers, and numeric values keep their
+// scale. The result is rounded to the granularity and clamped to the
+// configured range, as well as to the range of the column type, so that it
+// can't overflow the target column. Null, NaN and infinity values are
+// returned unchanged.
+func (t *NumericNoiseTransformer) Transform(_ context.Context, value Value) (any, error) {
+	source, err := t.noiseSource(value.DynamicValues)
+	if err != nil {
+		return nil, err
+	}
+	return t.transform(source, value.TransformValue, value.TransformType)
+}
+
+func (t *NumericNoiseTransformer) transform(source noiseSource, value any, pgType string) (any, error) {
+	v, ok, err := toRat(value)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return value, nil
+	}
+
+	result := new(big.Rat).Add(v, t.sampleNoise(source, v))
+	if t.granularity != nil {
+		result = roundToMultiple(result, t.granularity)
+	}
+	result = clampRat(result, t.minValue, t.maxValue)
+
+	return fromRat(result, value, pgType)
+}
+
+func (t *NumericNoiseTransformer) CompatibleTypes() []SupportedDataType {
+	return numericNoiseCompatibleTypes
+}
+
+func (t *NumericNoiseTransformer) Type() TransformerType {
+	return NumericNoise
+}
+
+func (t *NumericNoiseTransformer) IsDynamic() bool {
+	return t.consistencyKey != nil
+}
+
+func (t *NumericNoiseTransformer) Close() error {
+	return nil
+}
+
+func NumericNoiseTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: numericNoiseCompatibleTypes,
+		Parameters:     numericNoiseParams,
+	}
+}
+
+// noiseSource returns the source of the noise for the row. If a consistency
+// key is configured, the source is seeded with the HMAC of its value.
+func (t *NumericNoiseTransformer) noiseSource(dynamicValues map[string]any) (noiseSource, error) {
+	if t.consistencyKey == nil {
+		return globalNoiseSource{}, nil
+	}
+
+	consistencyKey, found := dynamicValues[t.consistencyKey.Column]
+	if !found {
+		return nil, fmt.Errorf("%w: column %q not found", ErrInvalidDynamicParameters, t.consistencyKey.Column)
+	}
+	input, isNull, err := hmacInput(consistencyKey)
+	if err != nil {
+		return nil, fmt.Errorf("numeric_noise: consistency key: %w", err)
+	}
+	if isNull {
+		return nil, errNumericNoiseNullConsistencyKey
+	}
+
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write(input)
+	sum := mac.Sum(nil)
+	return rand.New(rand.NewPCG(binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16]))), nil
+}
+
+// sampleNoise returns the noise to be added to the value on input. Uniform
+// noise is within [-noise, noise], and gaussian noise has a standard deviation
+// of noise, truncated at gaussianNoiseBound standard deviations. Percentage
+// noise is relative to the absolute value on input.
+func (t *NumericNoiseTransformer) sampleNoise(source noiseSource, value *big.Rat) *big.Rat {
+	var factor float64
+	switch t.distribution {
+	case gaussianNoise:
+		factor = math.Max(-gaussianNoiseBound, math.Min(gaussianNoiseBound, source.NormFloat64()))
+	default:
+		factor = 2*source.Float64() - 1
+	}
+
+	noise := new(big.Rat).Mul(t.noise, newRat(factor))
+	if t.mode == percentageNoise {
+		noise.Mul(noise, new(big.Rat).Abs(value))
+	}
+	return noise
+}
+
+// findNumberParameter returns the parameter as a float, accepting both
+// integer and float values, since whole numbers are decoded as integers.
+func findNumberParameter(params ParameterValues, name string) (float64, bool, error) {
+	switch v := params[name].(type) {
+	case nil:
+		return 0, false, nil
+	case int:
+		return float64(v), true, nil
+	case int64:
+		return float64(v), true, nil
+	case float64:
+		return v, true, nil
+	default:
+		return 0, true, fmt.Errorf("got %T: %w", v, ErrInvalidParameters)
+	}
+}
+
+func newRat(f float64) *big.Rat {
+	return new(big.Rat).SetFloat64(f)
+}
+
+// toRat returns the exact value of the number on input, and false if it's
+// null, NaN or infinity and can't be transformed.
+func toRat(value any) (*big.Rat, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false, nil
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), true, nil
+	case int16:
+		return new(big.Rat).SetInt64(int64(v)), true, nil
+	case int32:
+		return new(big.Rat).SetInt64(int64(v)), true, nil
+	case int64:
+		return new(big.Rat).SetInt64(v), true, nil
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil, false, nil
+		}
+		return newRat(float64(v)), true, nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false, nil
+		}
+		return newRat(v), true, nil
+	case pgtype.Numeric:
+		if !v.Valid || v.NaN || v.InfinityModifier != pgtype.Finite || v.Int == nil {
+			return nil, false, nil
+		}
+		return new(big.Rat).Mul(new(big.Rat).SetInt(v.Int), pow10(v.Exp)), true, nil
+	default:
+		return nil, false, ErrUnsupportedValueType
+	}
+}
+
+// fromRat converts the result back to the type of the original value. The
+// result is clamped to the range of the column type on input, and rounded to
+// its scale.
+func fromRat(result *big.Rat, original any, pgType string) (any, error) {
+	limits := columnTypeLimits(pgType)
+
+	switch original.(type) {
+	case int:
+		return int(roundedInt64(result, limits, math.MinInt, math.MaxInt)), nil
+	case int16:
+		return int16(roundedInt64(result, limits, math.MinInt16, math.MaxInt16)), nil
+	case int32:
+		return int32(roundedInt64(result, limits, math.MinInt32, math.MaxInt32)), nil
+	case int64:
+		return roundedInt64(result, limits, math.MinInt64, math.MaxInt64), nil
+	case float32:
+		result = limits.apply(result)
+		f, _ := result.Float64()
+		return float32(math.Max(-math.MaxFloat32, math.Min(math.MaxFloat32, f))), nil
+	case float64:
+		f, _ := limits.apply(result).Float64()
+		return f, nil
+	case pgtype.Numeric:
+		scale := limits.scale
+		if !limits.hasScale {
+			scale = max(-original.(pgtype.Numeric).Exp, 0)
+		}
+		result = limits.apply(result)
+		return pgtype.Numeric{
+			Int:   roundHalfAwayFromZero(new(big.Rat).Mul(result, pow10(scale))),
+			Exp:   -scale,
+			Valid: true,
+		}, nil
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+}
+
+// typeLimits are the range and scale of the values of a column type.
+type typeLimits struct {
+	min, max *big.Rat
+	scale    int32
+	hasScale bool
+}
+
+// columnTypeLimits returns the limits of the postgres column type on input,
+// so that the transformed values don't overflow the column. Integer types have
+// a scale of 0, and numeric types with a type modifier (i.e. numeric(10,2))
+// are limited by their precision and scale.
+func columnTypeLimits(pgType string) typeLimits {
+	switch pgType := strings.ToLower(strings.TrimSpace(pgType)); pgType {
+	case "smallint", "int2":
+		return integerLimits(math.MinInt16, math.MaxInt16)
+	case "integer", "int", "int4":
+		return integerLimits(math.MinInt32, math.MaxInt32)
+	case "bigint", "int8":
+		return integerLimits(math.MinInt64, math.MaxInt64)
+	default:
+		matches := numericTypeModifierRegex.FindStringSubmatch(pgType)
+		if matches == nil {
+			return typeLimits{}
+		}
+		precision, _ := strconv.Atoi(matches[1])
+		scale := 0
+		if matches[2] != "" {
+			scale, _ = strconv.Atoi(matches[2])
+		}
+		// the max absolute value has precision-scale integer digits and scale
+		// decimal digits, i.e. 999.99 for numeric(5,2)
+		maxValue := new(big.Rat).Sub(pow10(int32(precision-scale)), pow10(int32(-scale)))
+		return typeLimits{
+			min:      new(big.Rat).Neg(maxValue),
+			max:      maxValue,
+			scale:    int32(scale),
+			hasScale: true,
+		}
+	}
+}
+
+func integerLimits(minValue, maxValue int64) typeLimits {
+	return typeLimits{
+		min:      new(big.Rat).SetInt64(minValue),
+		max:      new(big.Rat).SetInt64(maxValue),
+		scale:    0,
+		hasScale: true,
+	}
+}
+
+// apply rounds the value to the scale of the type, clamping it to its range.
+func (l typeLimits) apply(value *big.Rat) *big.Rat {
+	value = clampRat(value, l.min, l.max)
+	if !l.hasScale {
+		return value
+	}
+	scaleFactor := pow10(l.scale)
+	rounded := new(big.Rat).SetInt(roundHalfAwayFromZero(new(big.Rat).Mul(value, scaleFactor)))
+	// rounding can't exceed the range, since its bounds are multiples of the
+	// scale
+	return rounded.Quo(rounded, scaleFactor)
+}
+
+func roundedInt64(value *big.Rat, limits typeLimits, minValue, maxValue int64) int64 {
+	value = limits.apply(clampRat(value, new(big.Rat).SetInt64(minValue), new(big.Rat).SetInt64(maxValue)))
+	return roundHalfAwayFromZero(value).Int64()
+}
+
+func clampRat(value, minValue, maxValue *big.Rat) *big.Rat {
+	if minValue != nil && value.Cmp(minValue) < 0 {
+		return new(big.Rat).Set(minValue)
+	}
+	if maxValue != nil && value.Cmp(maxValue) > 0 {
+		return new(big.Rat).Set(maxValue)
+	}
+	return value
+}
+
+// roundToMultiple rounds the value to the nearest multiple of the granularity.
+func roundToMultiple(value, granularity *big.Rat) *big.Rat {
+	multiple := roundHalfAwayFromZero(new(big.Rat).Quo(value, granularity))
+	return new(big.Rat).Mul(new(big.Rat).SetInt(multiple), granularity)
+}
+
+func roundHalfAwayFromZero(value *big.Rat) *big.Int {
+	half := big.NewRat(1, 2)
+	if value.Sign() < 0 {
+		half.Neg(half)
+	}
+	shifted := new(big.Rat).Add(value, half)
+	// Quo truncates towards zero
+	return new(big.Int).Quo(shifted.Num(), shifted.Denom())
+}
+
+// pow10 returns 10^exp, which can be a negative exponent.
+func pow10(exp int32) *big.Rat {
+	if exp < 0 {
+		return new(big.Rat).Inv(new(big.Rat).SetInt(pow10Int(-exp)))
+	}
+	return new(big.Rat).SetInt(pow10Int(exp))
+}
+
+func pow10Int(exp int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)
+}
@@ -0,0 +1,373 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+type constantNoiseSource struct {
+	value float64
+}
+
+func (s constantNoiseSource) Float64() float64     { return s.value }
+func (s constantNoiseSource) NormFloat64() float64 { return s.value }
+
+func TestNewNumericNoiseTransformer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		params        ParameterValues
+		dynamicParams ParameterValues
+
+		wantErr error
+	}{
+		{
+			name:   "ok - percentage",
+			params: ParameterValues{"noise": 10, "granularity": 1000, "min_value": 0, "max_value": 1000000.5},
+		},
+		{
+			name:          "ok - absolute gaussian with consistency key",
+			params:        ParameterValues{"noise": 2.5, "mode": "absolute", "distribution": "gaussian", "key": "secret"},
+			dynamicParams: ParameterValues{"consistency_key": map[string]any{"column": "id"}},
+		},
+		{
+			name:    "error - missing noise",
+			params:  ParameterValues{},
+			wantErr: errNumericNoiseInvalidNoise,
+		},
+		{
+			name:    "error - negative noise",
+			params:  ParameterValues{"noise": -1},
+			wantErr: errNumericNoiseInvalidNoise,
+		},
+		{
+			name:    "error - invalid noise type",
+			params:  ParameterValues{"noise": "10"},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - invalid mode",
+			params:  ParameterValues{"noise": 10, "mode": "relative"},
+			wantErr: errNumericNoiseInvalidMode,
+		},
+		{
+			name:    "error - invalid distribution",
+			params:  ParameterValues{"noise": 10, "distribution": "laplace"},
+			wantErr: errNumericNoiseInvalidDistribution,
+		},
+		{
+			name:    "error - invalid granularity",
+			params:  ParameterValues{"noise": 10, "granularity": 0},
+			wantErr: errNumericNoiseInvalidGranularity,
+		},
+		{
+			name:    "error - invalid range",
+			params:  ParameterValues{"noise": 10, "min_value": 10, "max_value": 5},
+			wantErr: errNumericNoiseInvalidRange,
+		},
+		{
+			name:          "error - consistency key without secret key",
+			params:        ParameterValues{"noise": 10},
+			dynamicParams: ParameterValues{"consistency_key": map[string]any{"column": "id"}},
+			wantErr:       errKeyNotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewNumericNoiseTransformer(tc.params, tc.dynamicParams)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestNumericNoiseTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	consistencyKeyParams := ParameterValues{"consistency_key": map[string]any{"column": "id"}}
+
+	tests := []struct {
+		name          string
+		params        ParameterValues
+		dynamicParams ParameterValues
+		value         any
+		pgType        string
+
+		wantMin float64
+		wantMax float64
+	}{
+		{
+			name:    "ok - float percentage",
+			params:  ParameterValues{"noise": 10},
+			value:   float64(50000),
+			pgType:  "double precision",
+			wantMin: 45000,
+			wantMax: 55000,
+		},
+		{
+			name:    "ok - integer absolute",
+			params:  ParameterValues{"noise": 5, "mode": "absolute"},
+			value:   int32(100),
+			pgType:  "integer",
+			wantMin: 95,
+			wantMax: 105,
+		},
+		{
+			name:    "ok - gaussian is bounded",
+			params:  ParameterValues{"noise": 1, "mode": "absolute", "distribution": "gaussian"},
+			value:   float64(10),
+			pgType:  "double precision",
+			wantMin: 7,
+			wantMax: 13,
+		},
+		{
+			name:    "ok - clamped to min and max",
+			params:  ParameterValues{"noise": 50, "min_value": 90, "max_value": 110},
+			value:   int64(100),
+			pgType:  "bigint",
+			wantMin: 90,
+			wantMax: 110,
+		},
+		{
+			name:    "ok - clamped to column type",
+			params:  ParameterValues{"noise": 50},
+			value:   int16(math.MaxInt16),
+			pgType:  "smallint",
+			wantMin: math.MaxInt16 / 2,
+			wantMax: math.MaxInt16,
+		},
+		{
+			name:          "ok - consistency key",
+			params:        ParameterValues{"noise": 10, "key": "secret"},
+			dynamicParams: consistencyKeyParams,
+			value:         float64(1000),
+			pgType:        "numeric(10,2)",
+			wantMin:       900,
+			wantMax:       1100,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transformer, err := NewNumericNoiseTransformer(tc.params, tc.dynamicParams)
+			require.NoError(t, err)
+
+			for i := 0; i < 100; i++ {
+				got, err := transformer.Transform(context.Background(), NewValue(tc.value, tc.pgType, map[string]any{"id": i}))
+				require.NoError(t, err)
+				require.IsType(t, tc.value, got)
+
+				f, _ := mustRat(t, got).Float64()
+				require.GreaterOrEqual(t, f, tc.wantMin)
+				require.LessOrEqual(t, f, tc.wantMax)
+			}
+		})
+	}
+}
+
+func TestNumericNoiseTransformer_Transform_consistencyKey(t *testing.T) {
+	t.Parallel()
+
+	transformer, err := NewNumericNoiseTransformer(
+		ParameterValues{"noise": 10, "key": "secret", "granularity": 0.01},
+		ParameterValues{"consistency_key": map[string]any{"column": "id"}},
+	)
+	require.NoError(t, err)
+	require.True(t, transformer.IsDynamic())
+
+	transform := func(id any) any {
+		got, err := transformer.Transform(context.Background(), NewValue(float64(1000), "double precision", map[string]any{"id": id}))
+		require.NoError(t, err)
+		return got
+	}
+
+	// the same row key always gets the same noise, and different keys get
+	// different noise
+	require.Equal(t, transform(int64(42)), transform(int64(42)))
+	require.NotEqual(t, transform(int64(42)), transform(int64(43)))
+
+	_, err = transformer.Transform(context.Background(), NewValue(float64(1000), "double precision", map[string]any{"id": nil}))
+	require.ErrorIs(t, err, errNumericNoiseNullConsistencyKey)
+
+	_, err = transformer.Transform(context.Background(), NewValue(float64(1000), "double precision", map[string]any{}))
+	require.ErrorIs(t, err, ErrInvalidDynamicParameters)
+}
+
+func TestNumericNoiseTransformer_Transform_exact(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+		source noiseSource
+		value  any
+		pgType string
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "ok - null",
+			params:    ParameterValues{"noise": 10},
+			value:     nil,
+			wantValue: nil,
+		},
+		{
+			name:      "ok - NaN",
+			params:    ParameterValues{"noise": 10},
+			value:     math.NaN(),
+			pgType:    "double precision",
+			wantValue: math.NaN(),
+		},
+		{
+			name:   "ok - granularity",
+			params: ParameterValues{"noise": 10, "granularity": 1000},
+			// 1 => +10%
+			source:    constantNoiseSource{value: 1},
+			value:     float64(52345),
+			pgType:    "double precision",
+			wantValue: float64(58000),
+		},
+		{
+			name:   "ok - json number in integer column is rounded",
+			params: ParameterValues{"noise": 0.7, "mode": "absolute"},
+			// 1 => +0.7
+			source:    constantNoiseSource{value: 1},
+			value:     float64(10),
+			pgType:    "integer",
+			wantValue: float64(11),
+		},
+		{
+			name:   "ok - json number in numeric column keeps its scale",
+			params: ParameterValues{"noise": 0.12345, "mode": "absolute"},
+			// 0 => -0.12345
+			source:    constantNoiseSource{value: 0},
+			value:     float64(10.5),
+			pgType:    "numeric(5,2)",
+			wantValue: float64(10.38),
+		},
+		{
+			name:   "ok - numeric clamped to precision",
+			params: ParameterValues{"noise": 50},
+			// 1 => +50%
+			source:    constantNoiseSource{value: 1},
+			value:     pgtype.Numeric{Int: big.NewInt(90000), Exp: -2, Valid: true},
+			pgType:    "numeric(5,2)",
+			wantValue: pgtype.Numeric{Int: big.NewInt(99999), Exp: -2, Valid: true},
+		},
+		{
+			name:   "ok - numeric without type modifier keeps the value scale",
+			params: ParameterValues{"noise": 0.0004, "mode": "absolute"},
+			// 1 => +0.0004
+			source:    constantNoiseSource{value: 1},
+			value:     pgtype.Numeric{Int: big.NewInt(1234), Exp: -3, Valid: true},
+			pgType:    "numeric",
+			wantValue: pgtype.Numeric{Int: big.NewInt(1234), Exp: -3, Valid: true},
+		},
+		{
+			name:   "ok - invalid numeric",
+			params: ParameterValues{"noise": 10},
+			value:  pgtype.Numeric{NaN: true, Valid: true},
+			pgType: "numeric",
+
+			wantValue: pgtype.Numeric{NaN: true, Valid: true},
+		},
+		{
+			name:   "ok - float32 range",
+			params: ParameterValues{"noise": 50},
+			// 1 => +50%
+			source:    constantNoiseSource{value: 1},
+			value:     float32(math.MaxFloat32),
+			pgType:    "real",
+			wantValue: float32(math.MaxFloat32),
+		},
+		{
+			name:   "ok - int64 range",
+			params: ParameterValues{"noise": 50},
+			// 0 => -50%
+			source:    constantNoiseSource{value: 0},
+			value:     int64(math.MinInt64),
+			pgType:    "bigint",
+			wantValue: int64(math.MinInt64),
+		},
+		{
+			name:    "error - unsupported type",
+			params:  ParameterValues{"noise": 10},
+			value:   "100",
+			pgType:  "text",
+			wantErr: ErrUnsupportedValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transformer, err := NewNumericNoiseTransformer(tc.params, nil)
+			require.NoError(t, err)
+
+			got, err := transformer.transform(tc.source, tc.value, tc.pgType)
+			require.ErrorIs(t, err, tc.wantErr)
+			if f, ok := tc.wantValue.(float64); ok && math.IsNaN(f) {
+				require.True(t, math.IsNaN(got.(float64)))
+				return
+			}
+			require.Equal(t, tc.wantValue, got)
+		})
+	}
+}
+
+func TestColumnTypeLimits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pgType string
+
+		wantMin   string
+		wantMax   string
+		wantScale int32
+	}{
+		{pgType: "smallint", wantMin: "-32768", wantMax: "32767"},
+		{pgType: "integer", wantMin: "-2147483648", wantMax: "2147483647"},
+		{pgType: "bigint", wantMin: "-9223372036854775808", wantMax: "9223372036854775807"},
+		{pgType: "numeric(5,2)", wantMin: "-999.99", wantMax: "999.99", wantScale: 2},
+		{pgType: "numeric(3)", wantMin: "-999", wantMax: "999"},
+		{pgType: "decimal(2, 5)", wantMin: "-0.00099", wantMax: "0.00099", wantScale: 5},
+		{pgType: "numeric(3,-2)", wantMin: "-99900", wantMax: "99900", wantScale: -2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pgType, func(t *testing.T) {
+			t.Parallel()
+
+			limits := columnTypeLimits(tc.pgType)
+			require.True(t, limits.hasScale)
+			require.Equal(t, tc.wantScale, limits.scale)
+			require.Equal(t, tc.wantMin, limits.min.FloatString(int(max(tc.wantScale, 0))))
+			require.Equal(t, tc.wantMax, limits.max.FloatString(int(max(tc.wantScale, 0))))
+		})
+	}
+
+	for _, pgType := range []string{"numeric", "double precision", "real"} {
+		require.False(t, columnTypeLimits(pgType).hasScale)
+	}
+}
+
+func mustRat(t *testing.T, value any) *big.Rat {
+	r, ok, err := toRat(value)
+	require.NoError(t, err)
+	require.True(t, ok)
+	return r
+}
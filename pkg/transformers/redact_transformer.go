@@ -0,0 +1,367 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/big"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RedactTransformer replaces the values with a placeholder, for the columns
+// whose data needs to be dropped but can't be set to NULL because of the
+// target constraints. The placeholder is picked based on the column type (i.e.
+// an empty string for text, 0 for numbers, the epoch for timestamps and `{}`
+// for json), unless a fixed placeholder is configured for the column. The
+// values can optionally be set to NULL instead, and the redaction can be
+// limited to a percentage of the rows.
+type RedactTransformer struct {
+	placeholder    any
+	hasPlaceholder bool
+	nullify        bool
+	percentage     float64
+	// samplingKey is the column used to select the rows to redact, if any
+	samplingKey *DynamicParameter
+}
+
+// redactKind is the kind of placeholder used for a column type.
+type redactKind int
+
+const (
+	redactUnknown redactKind = iota
+	redactText
+	redactNumber
+	redactBoolean
+	redactDate
+	redactTimestamp
+	redactTimestampTZ
+	redactTime
+	redactJSON
+	redactUUID
+	redactBytes
+	redactArray
+)
+
+// redactSamplingBuckets is the number of buckets the sampling key hash is
+// mapped to, which allows for percentages with two decimals.
+const redactSamplingBuckets = 10000
+
+var (
+	errRedactInvalidPercentage    = errors.New("redact: percentage must be a value between 0 and 100")
+	errRedactPlaceholderAndNull   = errors.New("redact: placeholder and nullify can't be used together")
+	errRedactUnsupportedType      = errors.New("redact: no placeholder for the column type, a placeholder must be configured")
+	errRedactNullSamplingKeyValue = errors.New("redact: sampling key value cannot be null")
+
+	unixEpoch = time.Unix(0, 0).UTC()
+
+	redactCompatibleTypes = []SupportedDataType{
+		AllDataTypes,
+	}
+	redactParams = []Parameter{
+		{
+			Name:          "placeholder",
+			SupportedType: "any",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "nullify",
+			SupportedType: "boolean",
+			Default:       false,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "percentage",
+			SupportedType: "float",
+			Default:       100.0,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "sampling_key",
+			SupportedType: "any",
+			Default:       nil,
+			Dynamic:       true,
+			Required:      false,
+		},
+	}
+	redactKindsByType = map[string]redactKind{
+		"text":                        redactText,
+		"character varying":           redactText,
+		"varchar":                     redactText,
+		"character":                   redactText,
+		"char":                        redactText,
+		"bpchar":                      redactText,
+		"citext":                      redactText,
+		"name":                        redactText,
+		"smallint":                    redactNumber,
+		"integer":                     redactNumber,
+		"bigint":                      redactNumber,
+		"int2":                        redactNumber,
+		"int4":                        redactNumber,
+		"int8":                        redactNumber,
+		"real":                        redactNumber,
+		"double precision":            redactNumber,
+		"float4":                      redactNumber,
+		"float8":                      redactNumber,
+		"numeric":                     redactNumber,
+		"decimal":                     redactNumber,
+		"boolean":                     redactBoolean,
+		"bool":                        redactBoolean,
+		"date":                        redactDate,
+		"timestamp":                   redactTimestamp,
+		"timestamp without time zone": redactTimestamp,
+		"timestamp with time zone":    redactTimestampTZ,
+		"timestamptz":                 redactTimestampTZ,
+		"time":                        redactTime,
+		"time without time zone":      redactTime,
+		"json":                        redactJSON,
+		"jsonb":                       redactJSON,
+		"uuid":                        redactUUID,
+		"bytea":                       redactBytes,
+	}
+)
+
+func NewRedactTransformer(params, dynamicParams ParameterValues) (*RedactTransformer, error) {
+	t := &RedactTransformer{}
+
+	var err error
+	t.placeholder, t.hasPlaceholder = params["placeholder"]
+	if t.nullify, err = FindParameterWithDefault(params, "nullify", false); err != nil {
+		return nil, fmt.Errorf("redact: nullify must be a boolean: %w", err)
+	}
+	if t.nullify && t.hasPlaceholder {
+		return nil, errRedactPlaceholderAndNull
+	}
+
+	percentage, found, err := findNumberParameter(params, "percentage")
+	if err != nil {
+		return nil, fmt.Errorf("redact: percentage must be a number: %w", err)
+	}
+	t.percentage = 100
+	if found {
+		if percentage < 0 || percentage > 100 {
+			return nil, errRedactInvalidPercentage
+		}
+		t.percentage = percentage
+	}
+
+	dynamicParamMap, err := ParseDynamicParameters(dynamicParams)
+	if err != nil {
+		return nil, err
+	}
+	t.samplingKey = dynamicParamMap["sampling_key"]
+
+	return t, nil
+}
+
+// Transform replaces the value on input with the placeholder if the row is
+// sampled for redaction. Null values are returned unchanged.
+func (t *RedactTransformer) Transform(_ context.Context, value Value) (any, error) {
+	if value.TransformValue == nil {
+		return nil, nil
+	}
+
+	sampled, err := t.sampled(value.DynamicValues)
+	if err != nil {
+		return nil, err
+	}
+	if !sampled {
+		return value.TransformValue, nil
+	}
+
+	switch {
+	case t.nullify:
+		return nil, nil
+	case t.hasPlaceholder:
+		return t.placeholder, nil
+	default:
+		return typePlaceholder(value.TransformValue, value.TransformType)
+	}
+}
+
+func (t *RedactTransformer) CompatibleTypes() []SupportedDataType {
+	return redactCompatibleTypes
+}
+
+func (t *RedactTransformer) Type() TransformerType {
+	return Redact
+}
+
+func (t *RedactTransformer) IsDynamic() bool {
+	return t.samplingKey != nil
+}
+
+func (t *RedactTransformer) Close() error {
+	return nil
+}
+
+func RedactTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: redactCompatibleTypes,
+		Parameters:     redactParams,
+	}
+}
+
+// sampled returns true if the row is to be redacted. When a sampling key is
+// configured, the rows are selected based on the hash of its value, so that
+// the same rows are redacted on every run.
+func (t *RedactTransformer) sampled(dynamicValues map[string]any) (bool, error) {
+	switch {
+	case t.percentage >= 100:
+		return true, nil
+	case t.percentage <= 0:
+		return false, nil
+	case t.samplingKey == nil:
+		return rand.Float64()*100 < t.percentage, nil
+	}
+
+	samplingKey, found := dynamicValues[t.samplingKey.Column]
+	if !found {
+		return false, fmt.Errorf("%w: column %q not found", ErrInvalidDynamicParameters, t.samplingKey.Column)
+	}
+	input, isNull, err := hmacInput(samplingKey)
+	if err != nil {
+		return false, fmt.Errorf("redact: sampling key: %w", err)
+	}
+	if isNull {
+		return false, errRedactNullSamplingKeyValue
+	}
+
+	h := fnv.New64a()
+	h.Write(input)
+	return float64(h.Sum64()%redactSamplingBuckets) < t.percentage*redactSamplingBuckets/100, nil
+}
+
+// typePlaceholder returns the placeholder for the column type on input, in
+// the same representation as the value (i.e. a string for the values decoded
+// from the wal, or a time for the ones read by the snapshot).
+func typePlaceholder(value any, pgType string) (any, error) {
+	switch redactKindForType(pgType) {
+	case redactText:
+		if _, ok := value.([]byte); ok {
+			return []byte{}, nil
+		}
+		return "", nil
+	case redactNumber:
+		return zeroNumber(value), nil
+	case redactBoolean:
+		if _, ok := value.(string); ok {
+			return "false", nil
+		}
+		return false, nil
+	case redactDate:
+		return epochTime(value, "1970-01-01"), nil
+	case redactTimestamp:
+		return epochTime(value, "1970-01-01 00:00:00"), nil
+	case redactTimestampTZ:
+		return epochTime(value, "1970-01-01 00:00:00+00"), nil
+	case redactTime:
+		if _, ok := value.(pgtype.Time); ok {
+			return pgtype.Time{Valid: true}, nil
+		}
+		return "00:00:00", nil
+	case redactJSON:
+		switch value.(type) {
+		case []byte:
+			return []byte("{}"), nil
+		case string:
+			return "{}", nil
+		default:
+			return map[string]any{}, nil
+		}
+	case redactUUID:
+		switch value.(type) {
+		case [16]uint8:
+			return [16]uint8{}, nil
+		case pgtype.UUID:
+			return pgtype.UUID{Valid: true}, nil
+		default:
+			return "00000000-0000-0000-0000-000000000000", nil
+		}
+	case redactBytes:
+		if _, ok := value.(string); ok {
+			return `\x`, nil
+		}
+		return []byte{}, nil
+	case redactArray:
+		switch value.(type) {
+		case []any:
+			return []any{}, nil
+		case []string:
+			return []string{}, nil
+		default:
+			return "{}", nil
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", errRedactUnsupportedType, pgType)
+	}
+}
+
+// redactKindForType returns the kind of placeholder for the postgres type
+// name on input, in either its formatted or internal form. Type modifiers such
+// as the length or precision are ignored.
+func redactKindForType(pgType string) redactKind {
+	if IsArrayType(pgType) {
+		return redactArray
+	}
+	name, modifiers, found := strings.Cut(pgType, "(")
+	if found {
+		// the modifiers can be in the middle of the type name, i.e.
+		// timestamp(3) with time zone
+		if _, suffix, ok := strings.Cut(modifiers, ")"); ok {
+			name += suffix
+		}
+	}
+	return redactKindsByType[strings.TrimSpace(name)]
+}
+
+// zeroNumber returns 0 with the type of the numeric value on input.
+func zeroNumber(value any) any {
+	switch value.(type) {
+	case int:
+		return 0
+	case int16:
+		return int16(0)
+	case int32:
+		return int32(0)
+	case int64:
+		return int64(0)
+	case float32:
+		return float32(0)
+	case float64:
+		return float64(0)
+	case pgtype.Numeric:
+		return pgtype.Numeric{Int: big.NewInt(0), Valid: true}
+	case string:
+		return "0"
+	default:
+		return 0
+	}
+}
+
+// epochTime returns the unix epoch with the type of the value on input, or
+// the text representation on input if it's not a time value.
+func epochTime(value any, text string) any {
+	switch value.(type) {
+	case time.Time:
+		return unixEpoch
+	case pgtype.Date:
+		return pgtype.Date{Time: unixEpoch, Valid: true}
+	case pgtype.Timestamp:
+		return pgtype.Timestamp{Time: unixEpoch, Valid: true}
+	case pgtype.Timestamptz:
+		return pgtype.Timestamptz{Time: unixEpoch, Valid: true}
+	default:
+		return text
+	}
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRedactTransformer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		params        ParameterValues
+		dynamicParams ParameterValues
+
+		wantErr error
+	}{
+		{
+			name:   "ok - defaults",
+			params: ParameterValues{},
+		},
+		{
+			name:          "ok - placeholder with sampling",
+			params:        ParameterValues{"placeholder": "redacted", "percentage": 12.5},
+			dynamicParams: ParameterValues{"sampling_key": map[string]any{"column": "id"}},
+		},
+		{
+			name:   "ok - nullify",
+			params: ParameterValues{"nullify": true, "percentage": 0},
+		},
+		{
+			name:    "error - invalid nullify",
+			params:  ParameterValues{"nullify": "yes"},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - invalid percentage type",
+			params:  ParameterValues{"percentage": "10"},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - percentage out of range",
+			params:  ParameterValues{"percentage": 101},
+			wantErr: errRedactInvalidPercentage,
+		},
+		{
+			name:    "error - placeholder and nullify",
+			params:  ParameterValues{"placeholder": "", "nullify": true},
+			wantErr: errRedactPlaceholderAndNull,
+		},
+		{
+			name:          "error - invalid sampling key",
+			params:        ParameterValues{},
+			dynamicParams: ParameterValues{"sampling_key": "id"},
+			wantErr:       ErrInvalidDynamicParameters,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewRedactTransformer(tc.params, tc.dynamicParams)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestRedactTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	epoch := time.Unix(0, 0).UTC()
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+		value  any
+		pgType string
+
+		wantValue any
+		wantErr   error
+	}{
+		{name: "ok - null", value: nil, pgType: "text", wantValue: nil},
+		{name: "ok - text", value: "alice", pgType: "text", wantValue: ""},
+		{name: "ok - varchar with length", value: "alice", pgType: "character varying(255)", wantValue: ""},
+		{name: "ok - bpchar bytes", value: []byte("alice"), pgType: "bpchar", wantValue: []byte{}},
+		{name: "ok - integer", value: int32(42), pgType: "integer", wantValue: int32(0)},
+		{name: "ok - json number", value: float64(42), pgType: "bigint", wantValue: float64(0)},
+		{
+			name:      "ok - numeric",
+			value:     pgtype.Numeric{Int: big.NewInt(1234), Exp: -2, Valid: true},
+			pgType:    "numeric(10,2)",
+			wantValue: pgtype.Numeric{Int: big.NewInt(0), Valid: true},
+		},
+		{name: "ok - boolean", value: true, pgType: "boolean", wantValue: false},
+		{name: "ok - date text", value: "2024-05-01", pgType: "date", wantValue: "1970-01-01"},
+		{name: "ok - timestamp", value: time.Now(), pgType: "timestamp without time zone", wantValue: epoch},
+		{name: "ok - timestamp text with precision", value: "2024-05-01 10:00:00.123", pgType: "timestamp(3) without time zone", wantValue: "1970-01-01 00:00:00"},
+		{name: "ok - timestamptz text", value: "2024-05-01 10:00:00+02", pgType: "timestamp with time zone", wantValue: "1970-01-01 00:00:00+00"},
+		{
+			name:      "ok - pgtype timestamptz",
+			value:     pgtype.Timestamptz{Time: time.Now(), Valid: true},
+			pgType:    "timestamptz",
+			wantValue: pgtype.Timestamptz{Time: epoch, Valid: true},
+		},
+		{name: "ok - time", value: "10:30:00", pgType: "time without time zone", wantValue: "00:00:00"},
+		{name: "ok - jsonb text", value: `{"a": 1}`, pgType: "jsonb", wantValue: "{}"},
+		{name: "ok - jsonb map", value: map[string]any{"a": 1}, pgType: "jsonb", wantValue: map[string]any{}},
+		{name: "ok - uuid", value: "c0ffee00-0000-4000-8000-000000000000", pgType: "uuid", wantValue: "00000000-0000-0000-0000-000000000000"},
+		{name: "ok - uuid bytes", value: [16]uint8{1, 2, 3}, pgType: "uuid", wantValue: [16]uint8{}},
+		{name: "ok - bytea", value: []byte{1, 2}, pgType: "bytea", wantValue: []byte{}},
+		{name: "ok - text array", value: "{a,b}", pgType: "text[]", wantValue: "{}"},
+		{name: "ok - int array", value: []any{int32(1)}, pgType: "_int4", wantValue: []any{}},
+		{
+			name:      "ok - placeholder override",
+			params:    ParameterValues{"placeholder": "redacted@example.com"},
+			value:     "alice@example.com",
+			pgType:    "text",
+			wantValue: "redacted@example.com",
+		},
+		{
+			name:      "ok - placeholder override for unknown type",
+			params:    ParameterValues{"placeholder": "0.0.0.0"},
+			value:     "10.0.0.1",
+			pgType:    "inet",
+			wantValue: "0.0.0.0",
+		},
+		{
+			name:      "ok - nullify",
+			params:    ParameterValues{"nullify": true},
+			value:     "alice",
+			pgType:    "text",
+			wantValue: nil,
+		},
+		{
+			name:      "ok - percentage 0",
+			params:    ParameterValues{"percentage": 0},
+			value:     "alice",
+			pgType:    "text",
+			wantValue: "alice",
+		},
+		{
+			name:    "error - unsupported type without placeholder",
+			value:   "10.0.0.1",
+			pgType:  "inet",
+			wantErr: errRedactUnsupportedType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			params := tc.params
+			if params == nil {
+				params = ParameterValues{}
+			}
+			transformer, err := NewRedactTransformer(params, nil)
+			require.NoError(t, err)
+
+			got, err := transformer.Transform(context.Background(), NewValue(tc.value, tc.pgType, nil))
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, got)
+		})
+	}
+}
+
+func TestRedactTransformer_Transform_sampling(t *testing.T) {
+	t.Parallel()
+
+	transformer, err := NewRedactTransformer(
+		ParameterValues{"percentage": 30},
+		ParameterValues{"sampling_key": map[string]any{"column": "id"}},
+	)
+	require.NoError(t, err)
+	require.True(t, transformer.IsDynamic())
+
+	transform := func(id any) any {
+		got, err := transformer.Transform(context.Background(), NewValue("alice", "text", map[string]any{"id": id}))
+		require.NoError(t, err)
+		return got
+	}
+
+	redacted := 0
+	for i := range 1000 {
+		got := transform(i)
+		// the same rows are always redacted
+		require.Equal(t, got, transform(i))
+		if got == "" {
+			redacted++
+		}
+	}
+	require.InDelta(t, 300, redacted, 60)
+
+	_, err = transformer.Transform(context.Background(), NewValue("alice", "text", map[string]any{"id": nil}))
+	require.ErrorIs(t, err, errRedactNullSamplingKeyValue)
+
+	_, err = transformer.Transform(context.Background(), NewValue("alice", "text", map[string]any{}))
+	require.ErrorIs(t, err, ErrInvalidDynamicParameters)
+}
@@ -0,0 +1,216 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// RegexReplaceTransformer applies a list of regular expression find/replace
+// rules to text values, in the order they're defined. The replacements can
+// reference the pattern capture groups with $1 or ${name}.
+type RegexReplaceTransformer struct {
+	rules          []*regexReplaceRule
+	maxInputLength int
+}
+
+type regexReplaceRule struct {
+	regex        *regexp.Regexp
+	replacement  string
+	replaceFirst bool
+}
+
+const defaultRegexMaxInputLength = 10000
+
+var (
+	errRegexRulesMustBeProvided   = errors.New("regex_replace: rules parameter must be provided")
+	errRegexRulesCannotBeEmpty    = errors.New("regex_replace: rules cannot be empty")
+	errRegexPatternMustBeProvided = errors.New("pattern must be provided in the rule definition")
+	errInvalidRegexPattern        = errors.New("invalid pattern")
+	errInvalidMaxInputLength      = errors.New("regex_replace: max_input_length must be greater than 0")
+	errRegexInputTooLong          = errors.New("regex_replace: value exceeds max_input_length")
+
+	regexReplaceCompatibleTypes = []SupportedDataType{
+		StringDataType,
+		ByteArrayDataType,
+	}
+	regexReplaceParams = []Parameter{
+		{
+			Name:          "rules",
+			SupportedType: "array",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      true,
+		},
+		{
+			Name:          "max_input_length",
+			SupportedType: "int",
+			Default:       defaultRegexMaxInputLength,
+			Dynamic:       false,
+			Required:      false,
+		},
+	}
+)
+
+func NewRegexReplaceTransformer(params ParameterValues) (*RegexReplaceTransformer, error) {
+	rules, err := getRegexReplaceRules(params)
+	if err != nil {
+		return nil, err
+	}
+
+	maxInputLength, err := FindParameterWithDefault(params, "max_input_length", defaultRegexMaxInputLength)
+	if err != nil {
+		return nil, fmt.Errorf("regex_replace: max_input_length must be an integer: %w", err)
+	}
+	if maxInputLength <= 0 {
+		return nil, errInvalidMaxInputLength
+	}
+
+	return &RegexReplaceTransformer{
+		rules:          rules,
+		maxInputLength: maxInputLength,
+	}, nil
+}
+
+// Transform applies the replacement rules to the value on input. Values longer
+// than the max input length return an error instead of being processed.
+func (t *RegexReplaceTransformer) Transform(_ context.Context, value Value) (any, error) {
+	switch v := value.TransformValue.(type) {
+	case string:
+		res, err := t.replace(v)
+		if err != nil {
+			return nil, err
+		}
+		return res, nil
+	case []byte:
+		res, err := t.replace(string(v))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(res), nil
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+}
+
+func (t *RegexReplaceTransformer) CompatibleTypes() []SupportedDataType {
+	return regexReplaceCompatibleTypes
+}
+
+func (t *RegexReplaceTransformer) Type() TransformerType {
+	return RegexReplace
+}
+
+func (t *RegexReplaceTransformer) IsDynamic() bool {
+	return false
+}
+
+func (t *RegexReplaceTransformer) Close() error {
+	return nil
+}
+
+func RegexReplaceTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: regexReplaceCompatibleTypes,
+		Parameters:     regexReplaceParams,
+	}
+}
+
+func (t *RegexReplaceTransformer) replace(value string) (string, error) {
+	if len(value) > t.maxInputLength {
+		return "", fmt.Errorf("%w: %d bytes, max %d", errRegexInputTooLong, len(value), t.maxInputLength)
+	}
+
+	for _, rule := range t.rules {
+		value = rule.apply(value)
+	}
+	return value, nil
+}
+
+func (r *regexReplaceRule) apply(value string) string {
+	if !r.replaceFirst {
+		return r.regex.ReplaceAllString(value, r.replacement)
+	}
+
+	match := r.regex.FindStringSubmatchIndex(value)
+	if match == nil {
+		return value
+	}
+	res := make([]byte, 0, len(value))
+	res = append(res, value[:match[0]]...)
+	res = r.regex.ExpandString(res, r.replacement, value, match)
+	res = append(res, value[match[1]:]...)
+	return string(res)
+}
+
+// getRegexReplaceRules compiles the rules on input, reporting the index of the
+// offending rule on error.
+func getRegexReplaceRules(params ParameterValues) ([]*regexReplaceRule, error) {
+	arrayAny, found, err := FindParameter[[]any](params, "rules")
+	if err != nil {
+		return nil, fmt.Errorf("regex_replace: rules must be an array: %w", err)
+	}
+	if !found {
+		return nil, errRegexRulesMustBeProvided
+	}
+	if len(arrayAny) == 0 {
+		return nil, errRegexRulesCannotBeEmpty
+	}
+
+	rules := make([]*regexReplaceRule, 0, len(arrayAny))
+	for idx, valAny := range arrayAny {
+		rule, err := newRegexReplaceRule(valAny)
+		if err != nil {
+			return nil, fmt.Errorf("regex_replace: rule[%d]: %w", idx, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func newRegexReplaceRule(ruleAny any) (*regexReplaceRule, error) {
+	val, ok := ruleAny.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("invalid element type in rules array, got %T: %w", ruleAny, ErrInvalidParameters)
+	}
+
+	pattern, found, err := FindParameter[string](val, "pattern")
+	if err != nil {
+		return nil, fmt.Errorf("pattern must be a string: %w", err)
+	}
+	if !found || pattern == "" {
+		return nil, errRegexPatternMustBeProvided
+	}
+
+	replacement, err := FindParameterWithDefault(val, "replacement", "")
+	if err != nil {
+		return nil, fmt.Errorf("replacement must be a string: %w", err)
+	}
+
+	caseInsensitive, err := FindParameterWithDefault(val, "case_insensitive", false)
+	if err != nil {
+		return nil, fmt.Errorf("case_insensitive must be a boolean: %w", err)
+	}
+
+	replaceFirst, err := FindParameterWithDefault(val, "replace_first", false)
+	if err != nil {
+		return nil, fmt.Errorf("replace_first must be a boolean: %w", err)
+	}
+
+	if caseInsensitive {
+		pattern = "(?i)" + pattern
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", errInvalidRegexPattern, pattern, err)
+	}
+
+	return &regexReplaceRule{
+		regex:        regex,
+		replacement:  replacement,
+		replaceFirst: replaceFirst,
+	}, nil
+}
@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRegexReplaceTransformer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+
+		wantErr    error
+		wantErrMsg string
+	}{
+		{
+			name: "ok",
+			params: ParameterValues{
+				"rules": []any{
+					map[string]any{"pattern": `\d+`, "replacement": "#", "case_insensitive": true, "replace_first": true},
+				},
+				"max_input_length": 100,
+			},
+		},
+		{
+			name:    "error - missing rules",
+			params:  ParameterValues{},
+			wantErr: errRegexRulesMustBeProvided,
+		},
+		{
+			name:    "error - empty rules",
+			params:  ParameterValues{"rules": []any{}},
+			wantErr: errRegexRulesCannotBeEmpty,
+		},
+		{
+			name:    "error - invalid rules type",
+			params:  ParameterValues{"rules": "a"},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - invalid rule type",
+			params:  ParameterValues{"rules": []any{"a"}},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - missing pattern",
+			params:  ParameterValues{"rules": []any{map[string]any{"replacement": "a"}}},
+			wantErr: errRegexPatternMustBeProvided,
+		},
+		{
+			name: "error - invalid pattern",
+			params: ParameterValues{"rules": []any{
+				map[string]any{"pattern": "a"},
+				map[string]any{"pattern": "(a"},
+			}},
+			wantErr:    errInvalidRegexPattern,
+			wantErrMsg: "rule[1]",
+		},
+		{
+			name:    "error - invalid replace first",
+			params:  ParameterValues{"rules": []any{map[string]any{"pattern": "a", "replace_first": "yes"}}},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - invalid max input length",
+			params:  ParameterValues{"rules": []any{map[string]any{"pattern": "a"}}, "max_input_length": 0},
+			wantErr: errInvalidMaxInputLength,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewRegexReplaceTransformer(tc.params)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErrMsg != "" {
+				require.ErrorContains(t, err, tc.wantErrMsg)
+			}
+		})
+	}
+}
+
+func TestRegexReplaceTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		rules []any
+		value any
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "ok - replace all",
+			rules:     []any{map[string]any{"pattern": `\d`, "replacement": "#"}},
+			value:     "call 555-1234",
+			wantValue: "call ###-####",
+		},
+		{
+			name:      "ok - replace first",
+			rules:     []any{map[string]any{"pattern": `\d+`, "replacement": "#", "replace_first": true}},
+			value:     "call 555-1234",
+			wantValue: "call #-1234",
+		},
+		{
+			name:      "ok - replace first without match",
+			rules:     []any{map[string]any{"pattern": `\d+`, "replacement": "#", "replace_first": true}},
+			value:     "no digits",
+			wantValue: "no digits",
+		},
+		{
+			name:      "ok - capture groups",
+			rules:     []any{map[string]any{"pattern": `(?P<user>\w+)@(\w+)\.com`, "replacement": "${user}_x@$2.example"}},
+			value:     []byte("mail alice@acme.com"),
+			wantValue: []byte("mail alice_x@acme.example"),
+		},
+		{
+			name:      "ok - capture groups replace first",
+			rules:     []any{map[string]any{"pattern": `(\w)(\w+)`, "replacement": "$1***", "replace_first": true}},
+			value:     "secret word",
+			wantValue: "s*** word",
+		},
+		{
+			name:      "ok - case insensitive",
+			rules:     []any{map[string]any{"pattern": "password", "replacement": "[redacted]", "case_insensitive": true}},
+			value:     "my PassWord is",
+			wantValue: "my [redacted] is",
+		},
+		{
+			name: "ok - rules applied in order",
+			rules: []any{
+				map[string]any{"pattern": "cat", "replacement": "dog"},
+				map[string]any{"pattern": "dog", "replacement": "bird"},
+			},
+			value:     "cat and dog",
+			wantValue: "bird and bird",
+		},
+		{
+			name:      "ok - empty replacement",
+			rules:     []any{map[string]any{"pattern": `\s+`}},
+			value:     "a b  c",
+			wantValue: "abc",
+		},
+		{
+			name:    "error - input too long",
+			rules:   []any{map[string]any{"pattern": "a"}},
+			value:   strings.Repeat("a", 21),
+			wantErr: errRegexInputTooLong,
+		},
+		{
+			name:    "error - unsupported value type",
+			rules:   []any{map[string]any{"pattern": "a"}},
+			value:   1,
+			wantErr: ErrUnsupportedValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transformer, err := NewRegexReplaceTransformer(ParameterValues{
+				"rules":            tc.rules,
+				"max_input_length": 20,
+			})
+			require.NoError(t, err)
+
+			got, err := transformer.Transform(context.Background(), Value{TransformValue: tc.value})
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, got)
+		})
+	}
+}
@@ -0,0 +1,260 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// RowTemplateTransformer builds the column value from a go template evaluated
+// against the other columns of the row, which can be referenced by name
+// (i.e. "user_{{ .id }}@example.com"). NULL column values render as an empty
+// string.
+type RowTemplateTransformer struct {
+	template          *template.Template
+	referencedColumns []string
+	onMissingColumn   string
+}
+
+const (
+	rowTemplateOnMissingError = "error"
+	rowTemplateOnMissingEmpty = "empty"
+	rowTemplateOnMissingKeep  = "keep"
+)
+
+var (
+	errRowTemplateMustBeProvided = errors.New("row_template: template parameter must be provided")
+	errRowTemplateMissingColumn  = errors.New("row_template: referenced column not found in the row")
+	errInvalidOnMissingColumn    = errors.New("row_template: on_missing_column must be one of error, empty or keep")
+
+	rowTemplateCompatibleTypes = []SupportedDataType{
+		StringDataType,
+		ByteArrayDataType,
+	}
+	rowTemplateParams = []Parameter{
+		{
+			Name:          "template",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      true,
+		},
+		{
+			Name:          "on_missing_column",
+			SupportedType: "string",
+			Default:       rowTemplateOnMissingError,
+			Dynamic:       false,
+			Required:      false,
+			Values:        []any{rowTemplateOnMissingError, rowTemplateOnMissingEmpty, rowTemplateOnMissingKeep},
+		},
+	}
+
+	rowTemplateFuncs = template.FuncMap{
+		"lower":  func(v any) string { return strings.ToLower(rowTemplateString(v)) },
+		"upper":  func(v any) string { return strings.ToUpper(rowTemplateString(v)) },
+		"sha256": rowTemplateSHA256,
+		"substr": rowTemplateSubstr,
+		"now":    func() time.Time { return time.Now().UTC() },
+	}
+)
+
+func NewRowTemplateTransformer(params ParameterValues) (*RowTemplateTransformer, error) {
+	templateStr, found, err := FindParameter[string](params, "template")
+	if err != nil {
+		return nil, fmt.Errorf("row_template: template must be a string: %w", err)
+	}
+	if !found {
+		return nil, errRowTemplateMustBeProvided
+	}
+
+	onMissingColumn, err := FindParameterWithDefault(params, "on_missing_column", rowTemplateOnMissingError)
+	if err != nil {
+		return nil, fmt.Errorf("row_template: on_missing_column must be a string: %w", err)
+	}
+	switch onMissingColumn {
+	case rowTemplateOnMissingError, rowTemplateOnMissingEmpty, rowTemplateOnMissingKeep:
+	default:
+		return nil, errInvalidOnMissingColumn
+	}
+
+	tmpl, err := template.New("").Option("missingkey=error").Funcs(rowTemplateFuncs).Parse(templateStr)
+	if err != nil {
+		return nil, fmt.Errorf("row_template: error parsing template: %w", err)
+	}
+
+	return &RowTemplateTransformer{
+		template:          tmpl,
+		referencedColumns: templateReferencedFields(tmpl),
+		onMissingColumn:   onMissingColumn,
+	}, nil
+}
+
+// Transform evaluates the template with the row column values. Columns
+// referenced by the template that are not part of the row (i.e. unchanged
+// TOAST values) are handled as configured by the on_missing_column parameter:
+// an error is returned, they're rendered as an empty string, or the value on
+// input is kept.
+func (t *RowTemplateTransformer) Transform(_ context.Context, value Value) (any, error) {
+	row := make(map[string]any, len(value.DynamicValues)+len(t.referencedColumns))
+	for name, v := range value.DynamicValues {
+		if v == nil {
+			v = ""
+		}
+		row[name] = v
+	}
+
+	for _, column := range t.referencedColumns {
+		if _, found := row[column]; found {
+			continue
+		}
+		switch t.onMissingColumn {
+		case rowTemplateOnMissingKeep:
+			return value.TransformValue, nil
+		case rowTemplateOnMissingEmpty:
+			row[column] = ""
+		default:
+			return nil, fmt.Errorf("%w: %s", errRowTemplateMissingColumn, column)
+		}
+	}
+
+	var buf strings.Builder
+	if err := t.template.Execute(&buf, row); err != nil {
+		return nil, fmt.Errorf("row_template: error executing template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// ReferencedColumns returns the names of the row columns the template refers
+// to.
+func (t *RowTemplateTransformer) ReferencedColumns() []string {
+	return t.referencedColumns
+}
+
+func (t *RowTemplateTransformer) CompatibleTypes() []SupportedDataType {
+	return rowTemplateCompatibleTypes
+}
+
+// OutputType returns the type of the rendered template.
+func (t *RowTemplateTransformer) OutputType() SupportedDataType {
+	return StringDataType
+}
+
+func (t *RowTemplateTransformer) Type() TransformerType {
+	return RowTemplate
+}
+
+func (t *RowTemplateTransformer) IsDynamic() bool {
+	return true
+}
+
+func (t *RowTemplateTransformer) Close() error {
+	return nil
+}
+
+func RowTemplateTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: rowTemplateCompatibleTypes,
+		Parameters:     rowTemplateParams,
+	}
+}
+
+// templateReferencedFields returns the sorted top level field names used in
+// the template (i.e. "id" for {{ .id }}, or {{ $.id }} anywhere). Fields
+// within range and with blocks are relative to a different value and are not
+// included.
+func templateReferencedFields(tmpl *template.Template) []string {
+	fields := map[string]struct{}{}
+	var walk func(node parse.Node, root bool)
+	walk = func(node parse.Node, root bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child, root)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe, root)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd, root)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg, root)
+			}
+		case *parse.FieldNode:
+			if root {
+				fields[n.Ident[0]] = struct{}{}
+			}
+		case *parse.VariableNode:
+			if n.Ident[0] == "$" && len(n.Ident) > 1 {
+				fields[n.Ident[1]] = struct{}{}
+			}
+		case *parse.ChainNode:
+			walk(n.Node, root)
+		case *parse.IfNode:
+			walk(n.Pipe, root)
+			walk(n.List, root)
+			walk(n.ElseList, root)
+		case *parse.RangeNode:
+			walk(n.Pipe, root)
+			walk(n.List, false)
+			walk(n.ElseList, root)
+		case *parse.WithNode:
+			walk(n.Pipe, root)
+			walk(n.List, false)
+			walk(n.ElseList, root)
+		}
+	}
+	walk(tmpl.Tree.Root, true)
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func rowTemplateString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func rowTemplateSHA256(v any) string {
+	sum := sha256.Sum256([]byte(rowTemplateString(v)))
+	return hex.EncodeToString(sum[:])
+}
+
+// rowTemplateSubstr returns the substring of length characters from the start
+// character position. The bounds are clamped to the length of the value, and a
+// negative length returns the remainder of the value.
+func rowTemplateSubstr(start, length int, v any) string {
+	runes := []rune(rowTemplateString(v))
+	start = max(0, min(start, len(runes)))
+	end := len(runes)
+	if length >= 0 {
+		end = min(start+length, len(runes))
+	}
+	return string(runes[start:end])
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRowTemplateTransformer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+
+		wantReferencedColumns []string
+		wantErr               error
+		wantErrMsg            string
+	}{
+		{
+			name: "ok",
+			params: ParameterValues{
+				"template":          `{{ .last_name }}, {{ .first_name | upper }}{{ if .title }} ({{ .title }}){{ end }}`,
+				"on_missing_column": "empty",
+			},
+			wantReferencedColumns: []string{"first_name", "last_name", "title"},
+		},
+		{
+			name: "ok - range and with blocks",
+			params: ParameterValues{
+				"template": `{{ with .address }}{{ .city }} {{ $.zip }}{{ end }}{{ range .tags }}{{ .name }}{{ end }}`,
+			},
+			wantReferencedColumns: []string{"address", "tags", "zip"},
+		},
+		{
+			name:    "error - missing template",
+			params:  ParameterValues{},
+			wantErr: errRowTemplateMustBeProvided,
+		},
+		{
+			name:    "error - invalid template type",
+			params:  ParameterValues{"template": 1},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - invalid on missing column",
+			params:  ParameterValues{"template": "{{ .id }}", "on_missing_column": "ignore"},
+			wantErr: errInvalidOnMissingColumn,
+		},
+		{
+			name:       "error - invalid template",
+			params:     ParameterValues{"template": "{{ .id "},
+			wantErrMsg: "row_template: error parsing template",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transformer, err := NewRowTemplateTransformer(tc.params)
+			if tc.wantErrMsg != "" {
+				require.ErrorContains(t, err, tc.wantErrMsg)
+				return
+			}
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			require.Equal(t, tc.wantReferencedColumns, transformer.ReferencedColumns())
+		})
+	}
+}
+
+func TestRowTemplateTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		template        string
+		onMissingColumn string
+		value           any
+		row             map[string]any
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "ok - synthetic email",
+			template:  "user_{{ .id }}@example.com",
+			value:     "alice@acme.com",
+			row:       map[string]any{"id": 42},
+			wantValue: "user_42@example.com",
+		},
+		{
+			name:      "ok - full name from other columns",
+			template:  "{{ .first_name }} {{ .last_name | upper }}",
+			value:     []byte("Alice Smith"),
+			row:       map[string]any{"first_name": "Jane", "last_name": []byte("Doe")},
+			wantValue: "Jane DOE",
+		},
+		{
+			name:      "ok - null values render as empty",
+			template:  "{{ .first_name }}{{ if .middle_name }} {{ .middle_name }}{{ end }} {{ .last_name }}",
+			value:     "Alice Smith",
+			row:       map[string]any{"first_name": "Jane", "middle_name": nil, "last_name": "Doe"},
+			wantValue: "Jane Doe",
+		},
+		{
+			name:      "ok - function library",
+			template:  `{{ lower .code }}-{{ substr 0 3 .name }}-{{ substr 10 3 .name }}-{{ sha256 .id | substr 0 8 }}`,
+			value:     "value",
+			row:       map[string]any{"code": "ABC", "name": "Alexander", "id": 1},
+			wantValue: "abc-Ale--6b86b273",
+		},
+		{
+			name:      "ok - missing column rendered as empty",
+			template:  "{{ .first_name }} {{ .last_name }}",
+			value:     "Alice Smith",
+			row:       map[string]any{"first_name": "Jane"},
+			wantValue: "Jane ",
+
+			onMissingColumn: rowTemplateOnMissingEmpty,
+		},
+		{
+			name:      "ok - missing column keeps the value",
+			template:  "{{ .first_name }} {{ .last_name }}",
+			value:     "Alice Smith",
+			row:       map[string]any{"first_name": "Jane"},
+			wantValue: "Alice Smith",
+
+			onMissingColumn: rowTemplateOnMissingKeep,
+		},
+		{
+			name:     "error - missing column",
+			template: "{{ .first_name }} {{ .last_name }}",
+			value:    "Alice Smith",
+			row:      map[string]any{"first_name": "Jane"},
+			wantErr:  errRowTemplateMissingColumn,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			params := ParameterValues{"template": tc.template}
+			if tc.onMissingColumn != "" {
+				params["on_missing_column"] = tc.onMissingColumn
+			}
+			transformer, err := NewRowTemplateTransformer(params)
+			require.NoError(t, err)
+
+			got, err := transformer.Transform(context.Background(), NewValue(tc.value, "text", tc.row))
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, got)
+		})
+	}
+
+	t.Run("ok - now", func(t *testing.T) {
+		t.Parallel()
+
+		transformer, err := NewRowTemplateTransformer(ParameterValues{"template": `{{ now.Format "2006" }}`})
+		require.NoError(t, err)
+
+		got, err := transformer.Transform(context.Background(), NewValue("value", "text", map[string]any{}))
+		require.NoError(t, err)
+		require.Equal(t, time.Now().UTC().Format("2006"), got)
+	})
+}
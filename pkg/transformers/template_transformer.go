@@ -62,6 +62,11 @@ func (t *TemplateTransformer) CompatibleTypes() []SupportedDataType {
 	return templateCompatibleTypes
 }
 
+// OutputType returns the type of the rendered template.
+func (t *TemplateTransformer) OutputType() SupportedDataType {
+	return StringDataType
+}
+
 func (t *TemplateTransformer) Type() TransformerType {
 	return Template
 }
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build wasip1
+
+// Test module for the wasm transformer. It reverses the value on input, and
+// uses special values to exercise the failure modes of the host.
+package main
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/xataio/pgstream/pkg/transformers/wasmguest"
+)
+
+var sink [][]byte
+
+func init() {
+	wasmguest.Register(func(value []byte, metadata wasmguest.Metadata) ([]byte, error) {
+		switch string(value) {
+		case "error":
+			return nil, errors.New("oh noes")
+		case "null":
+			return nil, wasmguest.ErrNull
+		case "panic":
+			panic("oh noes")
+		case "loop":
+			for {
+				sink = append(sink[:0], value)
+			}
+		case "oom":
+			for {
+				sink = append(sink, make([]byte, 1<<20))
+			}
+		case "metadata":
+			prefix, _ := metadata.Config["prefix"].(string)
+			return []byte(prefix + metadata.Type), nil
+		}
+
+		transformed := slices.Clone(value)
+		slices.Reverse(transformed)
+		return transformed, nil
+	})
+}
+
+func main() {}
@@ -16,6 +16,21 @@ type Transformer interface {
 	Close() error
 }
 
+// ColumnReferencer is implemented by the transformers that derive the value
+// from other columns of the row, so that the referenced columns can be
+// resolved before the transformer is applied.
+type ColumnReferencer interface {
+	ReferencedColumns() []string
+}
+
+// OutputTyper is implemented by the transformers that can return a value of a
+// different type than the one on input, so that the compatibility of the
+// transformers chained after them can be validated. An empty output type means
+// the input type is kept.
+type OutputTyper interface {
+	OutputType() SupportedDataType
+}
+
 type Value struct {
 	TransformValue any
 	TransformType  string
@@ -59,6 +74,24 @@ const (
 	JSON                   TransformerType = "json"
 	Hstore                 TransformerType = "hstore"
 	PGAnonymizer           TransformerType = "pg_anonymizer"
+	HMAC                   TransformerType = "hmac"
+	FormatPreserving       TransformerType = "format_preserving"
+	DateShift              TransformerType = "date_shift"
+	RegexReplace           TransformerType = "regex_replace"
+	RowTemplate            TransformerType = "row_template"
+	JSONPath               TransformerType = "json_path"
+	Lookup                 TransformerType = "lookup"
+	FakeData               TransformerType = "fake_data"
+	WASM                   TransformerType = "wasm"
+	MaskedEmail            TransformerType = "masked_email"
+	NumericNoise           TransformerType = "numeric_noise"
+	Redact                 TransformerType = "redact"
+	MaskedPhone            TransformerType = "masked_phone"
+	DeterministicUUID      TransformerType = "deterministic_uuid"
+	// Chain is the type of the transformers applying a sequence of
+	// transformers to a column. It's built from the column rules, and can't be
+	// configured by name.
+	Chain TransformerType = "chain"
 )
 
 type SupportedDataType string
@@ -77,6 +110,7 @@ const (
 	UInteger64DataType     SupportedDataType = "uinteger64"
 	Float32DataType        SupportedDataType = "float32"
 	Float64DataType        SupportedDataType = "float64"
+	NumericDataType        SupportedDataType = "numeric"
 	UUIDDataType           SupportedDataType = "uuid"
 	UInt8ArrayOf16DataType SupportedDataType = "uint8_array_of_16"
 	DateDataType           SupportedDataType = "date"
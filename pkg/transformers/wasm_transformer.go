@@ -0,0 +1,415 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// WASMTransformer transforms values with a custom function exported by a wasm
+// module, so that proprietary transformation logic can be plugged in without
+// being part of pgstream. The module is compiled once, and instantiated for
+// each of the concurrent transformations, with its memory and the duration of
+// each invocation limited. See the wasmguest package for the module ABI.
+type WASMTransformer struct {
+	runtime  wazero.Runtime
+	module   wazero.CompiledModule
+	metadata wasmMetadata
+	onError  string
+	timeout  time.Duration
+
+	// instances keeps the idle module instances, to be reused by the following
+	// transformations
+	instances chan *wasmInstance
+}
+
+type wasmInstance struct {
+	module    api.Module
+	alloc     api.Function
+	transform api.Function
+}
+
+// wasmMetadata is the column metadata passed as JSON to the module on each
+// transformation.
+type wasmMetadata struct {
+	Type   string         `json:"type"`
+	Config map[string]any `json:"config"`
+}
+
+const (
+	wasmAllocFunction     = "pgstream_alloc"
+	wasmTransformFunction = "pgstream_transform"
+
+	wasmStatusOK    byte = 0
+	wasmStatusNull  byte = 1
+	wasmStatusError byte = 2
+
+	wasmOnErrorError       = "error"
+	wasmOnErrorPassthrough = "passthrough"
+	wasmOnErrorNull        = "null"
+
+	defaultWASMMemoryLimitMB = 64
+	defaultWASMTimeout       = time.Second
+	// wasm memory pages are 64KiB
+	wasmPagesPerMB = 16
+)
+
+var (
+	errWASMModulePathNotFound  = errors.New("wasm: module_path must be provided")
+	errWASMInvalidOnError      = errors.New("wasm: on_error must be one of error, passthrough or null")
+	errWASMInvalidMemoryLimit  = errors.New("wasm: memory_limit_mb must be a positive integer")
+	errWASMInvalidTimeout      = errors.New("wasm: timeout must be a positive duration")
+	errWASMMissingExport       = errors.New("wasm: module doesn't export the required function")
+	errWASMInvalidResult       = errors.New("wasm: invalid transform result")
+	errWASMTransformationError = errors.New("wasm: transformation error")
+
+	// the compilation cache is shared by all the wasm transformers, so that
+	// the modules used by more than one column are only compiled once
+	wasmCompilationCache = wazero.NewCompilationCache()
+
+	wasmCompatibleTypes = []SupportedDataType{
+		StringDataType,
+		CitextDataType,
+		ByteArrayDataType,
+	}
+	wasmParams = []Parameter{
+		{
+			Name:          "module_path",
+			SupportedType: "string",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      true,
+		},
+		{
+			Name:          "config",
+			SupportedType: "object",
+			Default:       nil,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "on_error",
+			SupportedType: "string",
+			Default:       wasmOnErrorError,
+			Dynamic:       false,
+			Required:      false,
+			Values:        []any{wasmOnErrorError, wasmOnErrorPassthrough, wasmOnErrorNull},
+		},
+		{
+			Name:          "memory_limit_mb",
+			SupportedType: "int",
+			Default:       defaultWASMMemoryLimitMB,
+			Dynamic:       false,
+			Required:      false,
+		},
+		{
+			Name:          "timeout",
+			SupportedType: "string",
+			Default:       defaultWASMTimeout.String(),
+			Dynamic:       false,
+			Required:      false,
+		},
+	}
+)
+
+// NewWASMTransformer compiles the wasm module for the parameters on input. A
+// first instance of the module is created before returning, so that an error
+// is returned if the module doesn't implement the expected ABI.
+func NewWASMTransformer(params ParameterValues) (*WASMTransformer, error) {
+	modulePath, err := FindParameterWithDefault(params, "module_path", "")
+	if err != nil {
+		return nil, fmt.Errorf("wasm: module_path must be a string: %w", err)
+	}
+	if modulePath == "" {
+		return nil, errWASMModulePathNotFound
+	}
+
+	config, err := FindParameterWithDefault(params, "config", map[string]any{})
+	if err != nil {
+		return nil, fmt.Errorf("wasm: config must be an object: %w", err)
+	}
+
+	onError, err := FindParameterWithDefault(params, "on_error", wasmOnErrorError)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: on_error must be a string: %w", err)
+	}
+	switch onError {
+	case wasmOnErrorError, wasmOnErrorPassthrough, wasmOnErrorNull:
+	default:
+		return nil, errWASMInvalidOnError
+	}
+
+	memoryLimitMB, err := FindParameterWithDefault(params, "memory_limit_mb", defaultWASMMemoryLimitMB)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: memory_limit_mb must be an integer: %w", err)
+	}
+	if memoryLimitMB <= 0 {
+		return nil, errWASMInvalidMemoryLimit
+	}
+
+	timeout := defaultWASMTimeout
+	timeoutStr, err := FindParameterWithDefault(params, "timeout", "")
+	if err != nil {
+		return nil, fmt.Errorf("wasm: timeout must be a string: %w", err)
+	}
+	if timeoutStr != "" {
+		timeout, err = time.ParseDuration(timeoutStr)
+		if err != nil || timeout <= 0 {
+			return nil, errWASMInvalidTimeout
+		}
+	}
+
+	moduleBytes, err := os.ReadFile(modulePath)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: reading module: %w", err)
+	}
+
+	ctx := context.Background()
+	// the module is closed when the context of a call is done, so that the
+	// invocations exceeding the timeout are interrupted
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(memoryLimitMB*wasmPagesPerMB)).
+		WithCloseOnContextDone(true).
+		WithCompilationCache(wasmCompilationCache))
+	t := &WASMTransformer{
+		runtime: r,
+		metadata: wasmMetadata{
+			Config: config,
+		},
+		onError:   onError,
+		timeout:   timeout,
+		instances: make(chan *wasmInstance, runtime.GOMAXPROCS(0)),
+	}
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		t.Close()
+		return nil, fmt.Errorf("wasm: instantiating wasi: %w", err)
+	}
+
+	t.module, err = r.CompileModule(ctx, moduleBytes)
+	if err != nil {
+		t.Close()
+		return nil, fmt.Errorf("wasm: compiling module: %w", err)
+	}
+
+	instance, err := t.newInstance(ctx)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	t.release(ctx, instance)
+
+	return t, nil
+}
+
+func (t *WASMTransformer) Transform(ctx context.Context, value Value) (any, error) {
+	var input []byte
+	switch val := value.TransformValue.(type) {
+	case string:
+		input = []byte(val)
+	case []byte:
+		input = val
+	default:
+		return nil, ErrUnsupportedValueType
+	}
+
+	metadata := t.metadata
+	metadata.Type = value.TransformType
+	metadataBytes, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: marshaling metadata: %w", err)
+	}
+
+	output, isNull, err := t.call(ctx, input, metadataBytes)
+	switch {
+	case err != nil:
+		return t.handleError(value, err)
+	case isNull:
+		return nil, nil
+	}
+
+	if _, ok := value.TransformValue.(string); ok {
+		return string(output), nil
+	}
+	return output, nil
+}
+
+func (t *WASMTransformer) CompatibleTypes() []SupportedDataType {
+	return wasmCompatibleTypes
+}
+
+func (t *WASMTransformer) Type() TransformerType {
+	return WASM
+}
+
+func (t *WASMTransformer) IsDynamic() bool {
+	return false
+}
+
+// Close closes the wasm runtime, along with all the module instances.
+func (t *WASMTransformer) Close() error {
+	return t.runtime.Close(context.Background())
+}
+
+func WASMTransformerDefinition() *Definition {
+	return &Definition{
+		SupportedTypes: wasmCompatibleTypes,
+		Parameters:     wasmParams,
+	}
+}
+
+// call runs the transform function of an idle module instance. The instances
+// that fail with anything other than a transformation error reported by the
+// module are discarded, since their state can't be trusted anymore.
+func (t *WASMTransformer) call(ctx context.Context, input, metadata []byte) ([]byte, bool, error) {
+	instance, err := t.acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	output, isNull, err := instance.call(callCtx, input, metadata)
+	if err != nil && !errors.Is(err, errWASMTransformationError) {
+		instance.module.Close(ctx)
+		return nil, false, err
+	}
+
+	t.release(ctx, instance)
+	return output, isNull, err
+}
+
+func (t *WASMTransformer) handleError(value Value, err error) (any, error) {
+	switch t.onError {
+	case wasmOnErrorPassthrough:
+		return value.TransformValue, nil
+	case wasmOnErrorNull:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (t *WASMTransformer) acquire(ctx context.Context) (*wasmInstance, error) {
+	select {
+	case instance := <-t.instances:
+		return instance, nil
+	default:
+		return t.newInstance(ctx)
+	}
+}
+
+// release keeps the instance for the following transformations, unless there
+// are enough idle instances already.
+func (t *WASMTransformer) release(ctx context.Context, instance *wasmInstance) {
+	select {
+	case t.instances <- instance:
+	default:
+		instance.module.Close(ctx)
+	}
+}
+
+func (t *WASMTransformer) newInstance(ctx context.Context) (*wasmInstance, error) {
+	// the module instances are anonymous, so that the module can be
+	// instantiated more than once in the runtime. The wasm reactor modules
+	// are initialised with the _initialize function.
+	cfg := wazero.NewModuleConfig().WithName("")
+	if _, found := t.module.ExportedFunctions()["_initialize"]; found {
+		cfg = cfg.WithStartFunctions("_initialize")
+	}
+	module, err := t.runtime.InstantiateModule(ctx, t.module, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: instantiating module: %w", err)
+	}
+
+	instance := &wasmInstance{
+		module:    module,
+		alloc:     module.ExportedFunction(wasmAllocFunction),
+		transform: module.ExportedFunction(wasmTransformFunction),
+	}
+	switch {
+	case instance.alloc == nil:
+		module.Close(ctx)
+		return nil, fmt.Errorf("%w: %s", errWASMMissingExport, wasmAllocFunction)
+	case instance.transform == nil:
+		module.Close(ctx)
+		return nil, fmt.Errorf("%w: %s", errWASMMissingExport, wasmTransformFunction)
+	case module.Memory() == nil:
+		module.Close(ctx)
+		return nil, fmt.Errorf("%w: memory", errWASMMissingExport)
+	}
+	return instance, nil
+}
+
+func (i *wasmInstance) call(ctx context.Context, input, metadata []byte) ([]byte, bool, error) {
+	inputPtr, err := i.write(ctx, input)
+	if err != nil {
+		return nil, false, err
+	}
+	metadataPtr, err := i.write(ctx, metadata)
+	if err != nil {
+		return nil, false, err
+	}
+
+	results, err := i.transform.Call(ctx, uint64(inputPtr), uint64(len(input)), uint64(metadataPtr), uint64(len(metadata)))
+	if err != nil {
+		return nil, false, fmt.Errorf("wasm: calling %s: %w", wasmTransformFunction, err)
+	}
+	if len(results) != 1 {
+		return nil, false, errWASMInvalidResult
+	}
+
+	resultPtr, resultLen := uint32(results[0]>>32), uint32(results[0])
+	result, ok := i.module.Memory().Read(resultPtr, resultLen)
+	if !ok || len(result) == 0 {
+		return nil, false, errWASMInvalidResult
+	}
+
+	// the result is a view of the module memory, it needs to be copied before
+	// the instance is reused
+	switch result[0] {
+	case wasmStatusOK:
+		output := make([]byte, len(result)-1)
+		copy(output, result[1:])
+		return output, false, nil
+	case wasmStatusNull:
+		return nil, true, nil
+	case wasmStatusError:
+		return nil, false, fmt.Errorf("%w: %s", errWASMTransformationError, result[1:])
+	default:
+		return nil, false, fmt.Errorf("%w: unknown status %d", errWASMInvalidResult, result[0])
+	}
+}
+
+// write allocates a buffer in the module memory and copies the data on input
+// to it, returning the pointer to the buffer.
+func (i *wasmInstance) write(ctx context.Context, data []byte) (uint32, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	results, err := i.alloc.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("wasm: calling %s: %w", wasmAllocFunction, err)
+	}
+	if len(results) != 1 {
+		return 0, errWASMInvalidResult
+	}
+
+	ptr := uint32(results[0])
+	if !i.module.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("%w: %s returned an out of range pointer", errWASMInvalidResult, wasmAllocFunction)
+	}
+	return ptr, nil
+}
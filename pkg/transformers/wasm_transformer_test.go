@@ -0,0 +1,241 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformers
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// buildTestWASMModule builds the test module in testdata/wasm, which
+// implements the wasm transformer ABI using the wasmguest package.
+func buildTestWASMModule(t *testing.T) string {
+	t.Helper()
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain required to build the test wasm module")
+	}
+
+	modulePath := filepath.Join(t.TempDir(), "transformer.wasm")
+	cmd := exec.Command(goBin, "build", "-buildmode=c-shared", "-o", modulePath, "./testdata/wasm")
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+	return modulePath
+}
+
+func TestNewWASMTransformer(t *testing.T) {
+	t.Parallel()
+
+	modulePath := buildTestWASMModule(t)
+
+	invalidModulePath := filepath.Join(t.TempDir(), "invalid.wasm")
+	require.NoError(t, os.WriteFile(invalidModulePath, []byte("oh noes"), 0o600))
+	// valid wasm module without any exports
+	emptyModulePath := filepath.Join(t.TempDir(), "empty.wasm")
+	require.NoError(t, os.WriteFile(emptyModulePath, []byte("\x00asm\x01\x00\x00\x00"), 0o600))
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+
+		wantErr         error
+		wantErrContains string
+	}{
+		{
+			name: "ok",
+			params: ParameterValues{
+				"module_path":     modulePath,
+				"config":          map[string]any{"prefix": "p-"},
+				"on_error":        "null",
+				"memory_limit_mb": 128,
+				"timeout":         "500ms",
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "error - module_path not provided",
+			params:  ParameterValues{},
+			wantErr: errWASMModulePathNotFound,
+		},
+		{
+			name:    "error - invalid module_path",
+			params:  ParameterValues{"module_path": 1},
+			wantErr: ErrInvalidParameters,
+		},
+		{
+			name:    "error - invalid on_error",
+			params:  ParameterValues{"module_path": modulePath, "on_error": "ignore"},
+			wantErr: errWASMInvalidOnError,
+		},
+		{
+			name:    "error - invalid memory_limit_mb",
+			params:  ParameterValues{"module_path": modulePath, "memory_limit_mb": 0},
+			wantErr: errWASMInvalidMemoryLimit,
+		},
+		{
+			name:    "error - invalid timeout",
+			params:  ParameterValues{"module_path": modulePath, "timeout": "-1s"},
+			wantErr: errWASMInvalidTimeout,
+		},
+		{
+			name:    "error - module not found",
+			params:  ParameterValues{"module_path": filepath.Join(t.TempDir(), "missing.wasm")},
+			wantErr: os.ErrNotExist,
+		},
+		{
+			name:            "error - invalid module",
+			params:          ParameterValues{"module_path": invalidModulePath},
+			wantErrContains: "wasm: compiling module",
+		},
+		{
+			name:    "error - missing exports",
+			params:  ParameterValues{"module_path": emptyModulePath},
+			wantErr: errWASMMissingExport,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			wt, err := NewWASMTransformer(tc.params)
+			if tc.wantErrContains != "" {
+				require.ErrorContains(t, err, tc.wantErrContains)
+				return
+			}
+			require.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			require.NoError(t, wt.Close())
+		})
+	}
+}
+
+func TestWASMTransformer_Transform(t *testing.T) {
+	t.Parallel()
+
+	modulePath := buildTestWASMModule(t)
+
+	tests := []struct {
+		name   string
+		params ParameterValues
+		value  Value
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "ok - string",
+			params:    ParameterValues{},
+			value:     NewValue("hello", "text", nil),
+			wantValue: "olleh",
+		},
+		{
+			name:      "ok - bytes",
+			params:    ParameterValues{},
+			value:     NewValue([]byte("hello"), "bytea", nil),
+			wantValue: []byte("olleh"),
+		},
+		{
+			name:      "ok - empty value",
+			params:    ParameterValues{},
+			value:     NewValue("", "text", nil),
+			wantValue: "",
+		},
+		{
+			name:      "ok - metadata",
+			params:    ParameterValues{"config": map[string]any{"prefix": "p-"}},
+			value:     NewValue("metadata", "varchar", nil),
+			wantValue: "p-varchar",
+		},
+		{
+			name:      "ok - null",
+			params:    ParameterValues{},
+			value:     NewValue("null", "text", nil),
+			wantValue: nil,
+		},
+		{
+			name:    "error - transformation error",
+			params:  ParameterValues{},
+			value:   NewValue("error", "text", nil),
+			wantErr: errWASMTransformationError,
+		},
+		{
+			name:      "ok - transformation error passthrough",
+			params:    ParameterValues{"on_error": "passthrough"},
+			value:     NewValue("error", "text", nil),
+			wantValue: "error",
+		},
+		{
+			name:      "ok - transformation error null",
+			params:    ParameterValues{"on_error": "null"},
+			value:     NewValue("error", "text", nil),
+			wantValue: nil,
+		},
+		{
+			name:      "ok - module panic passthrough",
+			params:    ParameterValues{"on_error": "passthrough"},
+			value:     NewValue("panic", "text", nil),
+			wantValue: "panic",
+		},
+		{
+			name:      "ok - timeout null",
+			params:    ParameterValues{"on_error": "null", "timeout": "50ms"},
+			value:     NewValue("loop", "text", nil),
+			wantValue: nil,
+		},
+		{
+			name:    "error - unsupported value type",
+			params:  ParameterValues{},
+			value:   NewValue(1, "integer", nil),
+			wantErr: ErrUnsupportedValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tc.params["module_path"] = modulePath
+			wt, err := NewWASMTransformer(tc.params)
+			require.NoError(t, err)
+			defer wt.Close()
+
+			got, err := wt.Transform(context.Background(), tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, got)
+		})
+	}
+
+	t.Run("error - module failures", func(t *testing.T) {
+		t.Parallel()
+
+		wt, err := NewWASMTransformer(ParameterValues{
+			"module_path":     modulePath,
+			"memory_limit_mb": 64,
+			"timeout":         "50ms",
+		})
+		require.NoError(t, err)
+		defer wt.Close()
+
+		for _, value := range []string{"panic", "loop", "oom"} {
+			_, err := wt.Transform(context.Background(), NewValue(value, "text", nil))
+			require.Error(t, err, value)
+			require.NotErrorIs(t, err, errWASMTransformationError, value)
+
+			// failed instances are replaced, so the following transformations
+			// keep working
+			got, err := wt.Transform(context.Background(), NewValue("hello", "text", nil))
+			require.NoError(t, err)
+			require.Equal(t, "olleh", got)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build wasip1
+
+// Package wasmguest implements the guest side of the pgstream wasm
+// transformer ABI, so that custom transformers can be written as plain Go
+// functions and built as a wasm reactor module:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o transformer.wasm .
+//
+// The module exports the following functions, on top of its memory:
+//
+//   - pgstream_alloc(size u32) u32: allocates a buffer of the given size in the
+//     module memory, where the host writes the inputs of the transformation.
+//   - pgstream_transform(value_ptr, value_len, metadata_ptr, metadata_len u32) u64:
+//     transforms the value bytes, with the column metadata passed as JSON. It
+//     returns the pointer (high 32 bits) and length (low 32 bits) of the
+//     result, which starts with a status byte (0: ok, 1: null, 2: error),
+//     followed by the transformed value, or the error message.
+package wasmguest
+
+import (
+	"encoding/json"
+	"errors"
+	"unsafe"
+)
+
+// Metadata is the column metadata passed by the host on each transformation.
+type Metadata struct {
+	// Type is the postgres type of the column.
+	Type string `json:"type"`
+	// Config is the module configuration, as provided in the transformer
+	// parameters.
+	Config map[string]any `json:"config"`
+}
+
+// TransformFn transforms the value on input. It can return ErrNull to set the
+// column to null.
+type TransformFn func(value []byte, metadata Metadata) ([]byte, error)
+
+// ErrNull can be returned by the transform function to set the column value
+// to null.
+var ErrNull = errors.New("null value")
+
+const (
+	statusOK    byte = 0
+	statusNull  byte = 1
+	statusError byte = 2
+)
+
+var (
+	transformFn TransformFn
+	// allocations keeps the buffers allocated by the host referenced until
+	// they've been read, so that they're not garbage collected.
+	allocations [][]byte
+	// result is kept referenced until the next transformation, so that the
+	// host can read it.
+	result []byte
+)
+
+// Register sets the transform function of the module. It must be called
+// from an init function, before the host calls any of the exported functions.
+func Register(fn TransformFn) {
+	transformFn = fn
+}
+
+//go:wasmexport pgstream_alloc
+func alloc(size uint32) uint32 {
+	buf := make([]byte, size)
+	allocations = append(allocations, buf)
+	return bufferPtr(buf)
+}
+
+//go:wasmexport pgstream_transform
+func transform(valuePtr, valueLen, metadataPtr, metadataLen uint32) uint64 {
+	value := readBuffer(valuePtr, valueLen)
+	metadataBytes := readBuffer(metadataPtr, metadataLen)
+	allocations = nil
+
+	result = transformValue(value, metadataBytes)
+	return uint64(bufferPtr(result))<<32 | uint64(len(result))
+}
+
+func transformValue(value, metadataBytes []byte) []byte {
+	if transformFn == nil {
+		return errorResult(errors.New("no transform function registered"))
+	}
+
+	metadata := Metadata{}
+	if err := json.Unmarshal(metadataBytes, &metadata); err != nil {
+		return errorResult(err)
+	}
+
+	transformed, err := transformFn(value, metadata)
+	switch {
+	case errors.Is(err, ErrNull):
+		return []byte{statusNull}
+	case err != nil:
+		return errorResult(err)
+	default:
+		return append([]byte{statusOK}, transformed...)
+	}
+}
+
+func errorResult(err error) []byte {
+	return append([]byte{statusError}, err.Error()...)
+}
+
+func readBuffer(ptr, size uint32) []byte {
+	if size == 0 {
+		return []byte{}
+	}
+	// copy the buffer, so that it's not tied to the host allocation
+	buf := make([]byte, size)
+	copy(buf, unsafe.Slice((*byte)(unsafe.Pointer(uintptr(ptr))), size))
+	return buf
+}
+
+func bufferPtr(buf []byte) uint32 {
+	if len(buf) == 0 {
+		return 0
+	}
+	return uint32(uintptr(unsafe.Pointer(&buf[0])))
+}
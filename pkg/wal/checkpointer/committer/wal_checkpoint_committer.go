@@ -0,0 +1,317 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package committer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Committer wraps a checkpointer backend, and controls the cadence at which
+// the processed positions are committed to it. The positions are accumulated
+// and committed on the configured interval, or as soon as the uncommitted
+// events or bytes exceed the configured thresholds, which bounds the events
+// replayed after a crash.
+type Committer struct {
+	logger               loglib.Logger
+	next                 checkpointer.Checkpoint
+	parser               replication.LSNParser
+	clock                clockwork.Clock
+	commitInterval       time.Duration
+	maxUncommittedEvents uint64
+	maxUncommittedBytes  uint64
+	shutdownCommit       bool
+	metrics              *metrics
+
+	// mutex protects the pending positions and the lag counters, which are
+	// updated on every checkpoint and commit
+	mutex   sync.Mutex
+	pending []wal.CommitPosition
+	// uncommittedEvents is the number of processed events not yet committed,
+	// including the ones of an ongoing commit
+	uncommittedEvents uint64
+	// processed and committed are the last processed and committed LSNs, only
+	// tracked when an LSN parser is configured
+	processed replication.LSN
+	committed replication.LSN
+
+	// commitMutex serialises the commits of the loop, the thresholds and the
+	// final one on close
+	commitMutex sync.Mutex
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type Config struct {
+	// CommitInterval is the interval at which the processed positions are
+	// committed. If not set, they're committed as soon as they're processed,
+	// unless an uncommitted threshold is configured.
+	CommitInterval time.Duration
+	// MaxUncommittedEvents forces a commit when the number of processed events
+	// not yet committed reaches it. Disabled if not set.
+	MaxUncommittedEvents uint64
+	// MaxUncommittedBytes forces a commit when the WAL distance between the
+	// processed and the committed LSN reaches it. Only supported for postgres
+	// sources. Disabled if not set.
+	MaxUncommittedBytes uint64
+	// DisableShutdownCommit skips the final synchronous commit on shutdown,
+	// in which case the uncommitted events are replayed on restart.
+	DisableShutdownCommit bool
+}
+
+type Option func(c *Committer)
+
+type metrics struct {
+	lagEvents metric.Int64ObservableGauge
+	lagBytes  metric.Int64ObservableGauge
+}
+
+// New returns a committer that commits the processed positions to the
+// checkpoint on input following the configured semantics. It must be closed
+// to stop the commit loop.
+func New(cfg *Config, next checkpointer.Checkpoint, opts ...Option) *Committer {
+	c := &Committer{
+		logger:               loglib.NewNoopLogger(),
+		next:                 next,
+		clock:                clockwork.NewRealClock(),
+		commitInterval:       cfg.CommitInterval,
+		maxUncommittedEvents: cfg.MaxUncommittedEvents,
+		maxUncommittedBytes:  cfg.MaxUncommittedBytes,
+		shutdownCommit:       !cfg.DisableShutdownCommit,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	if c.commitInterval > 0 {
+		c.wg.Add(1)
+		go c.commitLoop(ctx)
+	}
+
+	return c
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(c *Committer) {
+		c.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_checkpoint_committer",
+		})
+	}
+}
+
+// WithLSNParser configures the parser of postgres commit positions, which
+// enables the uncommitted bytes threshold and lag. Only the max position is
+// kept pending, since the LSNs are ordered.
+func WithLSNParser(p replication.LSNParser) Option {
+	return func(c *Committer) {
+		c.parser = p
+	}
+}
+
+// WithInstrumentation reports the checkpoint lag, as the number of events and
+// the WAL distance between the processed and the committed positions.
+func WithInstrumentation(instrumentation *otel.Instrumentation) Option {
+	return func(c *Committer) {
+		if instrumentation == nil || instrumentation.Meter == nil {
+			return
+		}
+		if err := c.initMetrics(instrumentation.Meter); err != nil {
+			// should never happen
+			panic(err)
+		}
+	}
+}
+
+// Checkpoint keeps track of the processed positions on input, and commits them
+// when none of the semantics defer it, or when an uncommitted threshold is
+// reached.
+func (c *Committer) Checkpoint(ctx context.Context, positions []wal.CommitPosition) error {
+	if len(positions) == 0 {
+		return nil
+	}
+
+	if err := c.track(positions); err != nil {
+		return err
+	}
+
+	if !c.shouldCommit() {
+		return nil
+	}
+	return c.commit(ctx)
+}
+
+// Close stops the commit loop, and commits the pending positions
+// synchronously unless the shutdown commit is disabled.
+func (c *Committer) Close() error {
+	c.cancel()
+	c.wg.Wait()
+
+	if !c.shutdownCommit {
+		c.mutex.Lock()
+		uncommitted := c.uncommittedEvents
+		c.mutex.Unlock()
+		if uncommitted > 0 {
+			c.logger.Warn(nil, "checkpoint committer: skipping shutdown commit, uncommitted events will be replayed", loglib.Fields{
+				"uncommitted_events": uncommitted,
+			})
+		}
+		return nil
+	}
+	return c.commit(context.Background())
+}
+
+func (c *Committer) commitLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := c.clock.NewTicker(c.commitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.Chan():
+			if err := c.commit(ctx); err != nil {
+				c.logger.Error(err, "committing checkpoint")
+			}
+		}
+	}
+}
+
+func (c *Committer) track(positions []wal.CommitPosition) error {
+	if c.parser == nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		c.pending = append(c.pending, positions...)
+		c.uncommittedEvents += uint64(len(positions))
+		return nil
+	}
+
+	var min, max replication.LSN
+	var maxPosition wal.CommitPosition
+	for i, position := range positions {
+		lsn, err := c.parser.FromString(string(position))
+		if err != nil {
+			return err
+		}
+		if i == 0 || lsn < min {
+			min = lsn
+		}
+		if lsn > max {
+			max, maxPosition = lsn, position
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.committed == 0 {
+		// the committed position is unknown until the first commit, so the
+		// distance is measured from the first processed one
+		c.committed = min
+	}
+	if max > c.processed {
+		c.processed = max
+		c.pending = []wal.CommitPosition{maxPosition}
+	}
+	c.uncommittedEvents += uint64(len(positions))
+	return nil
+}
+
+func (c *Committer) shouldCommit() bool {
+	if c.commitInterval == 0 && c.maxUncommittedEvents == 0 && c.maxUncommittedBytes == 0 {
+		return true
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.maxUncommittedEvents > 0 && c.uncommittedEvents >= c.maxUncommittedEvents {
+		return true
+	}
+	return c.maxUncommittedBytes > 0 && c.uncommittedBytes() >= c.maxUncommittedBytes
+}
+
+// commit checkpoints the pending positions to the wrapped checkpoint. Failed
+// positions are kept pending and attempted again on the next commit.
+func (c *Committer) commit(ctx context.Context) error {
+	c.commitMutex.Lock()
+	defer c.commitMutex.Unlock()
+
+	c.mutex.Lock()
+	positions, events, processed := c.pending, c.uncommittedEvents, c.processed
+	c.pending = nil
+	c.mutex.Unlock()
+
+	if len(positions) == 0 {
+		return nil
+	}
+
+	if err := c.next(ctx, positions); err != nil {
+		c.mutex.Lock()
+		if c.parser == nil || len(c.pending) == 0 {
+			// newer positions are committed after the failed ones
+			c.pending = append(positions, c.pending...)
+		}
+		c.mutex.Unlock()
+		return fmt.Errorf("committing checkpoint: %w", err)
+	}
+
+	c.mutex.Lock()
+	c.uncommittedEvents -= events
+	if processed > c.committed {
+		c.committed = processed
+	}
+	c.mutex.Unlock()
+	return nil
+}
+
+// uncommittedBytes returns the WAL distance between the processed and the
+// committed LSN. Must be called with the mutex held.
+func (c *Committer) uncommittedBytes() uint64 {
+	if c.processed <= c.committed {
+		return 0
+	}
+	return uint64(c.processed - c.committed)
+}
+
+func (c *Committer) initMetrics(meter metric.Meter) error {
+	c.metrics = &metrics{}
+
+	var err error
+	c.metrics.lagEvents, err = meter.Int64ObservableGauge("pgstream.checkpoint.lag.events",
+		metric.WithUnit("events"),
+		metric.WithDescription("Number of processed events not yet committed to the checkpointer, which would be replayed after a crash"))
+	if err != nil {
+		return err
+	}
+
+	c.metrics.lagBytes, err = meter.Int64ObservableGauge("pgstream.checkpoint.lag.bytes",
+		metric.WithUnit("bytes"),
+		metric.WithDescription("WAL distance between the processed and the committed LSN. Only reported for postgres sources"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		o.ObserveInt64(c.metrics.lagEvents, int64(c.uncommittedEvents))
+		if c.parser != nil {
+			o.ObserveInt64(c.metrics.lagBytes, int64(c.uncommittedBytes()))
+		}
+		return nil
+	}, c.metrics.lagEvents, c.metrics.lagBytes)
+	return err
+}
@@ -0,0 +1,211 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package committer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+type mockCheckpoint struct {
+	mutex     sync.Mutex
+	err       error
+	positions [][]wal.CommitPosition
+}
+
+func (m *mockCheckpoint) checkpoint(ctx context.Context, positions []wal.CommitPosition) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.positions = append(m.positions, positions)
+	return nil
+}
+
+func (m *mockCheckpoint) setErr(err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.err = err
+}
+
+func (m *mockCheckpoint) committed() [][]wal.CommitPosition {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.positions
+}
+
+func TestCommitter_Checkpoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config Config
+		opts   []Option
+		calls  [][]wal.CommitPosition
+
+		wantCommitted [][]wal.CommitPosition
+	}{
+		{
+			name:   "ok - commits every checkpoint by default",
+			config: Config{},
+			calls:  [][]wal.CommitPosition{{"1"}, {"2", "3"}},
+
+			wantCommitted: [][]wal.CommitPosition{{"1"}, {"2", "3"}},
+		},
+		{
+			name:   "ok - deferred to the commit interval",
+			config: Config{CommitInterval: time.Hour},
+			calls:  [][]wal.CommitPosition{{"1"}, {"2", "3"}},
+
+			wantCommitted: nil,
+		},
+		{
+			name:   "ok - max uncommitted events reached",
+			config: Config{CommitInterval: time.Hour, MaxUncommittedEvents: 3},
+			calls:  [][]wal.CommitPosition{{"1"}, {"2", "3"}, {"4"}},
+
+			wantCommitted: [][]wal.CommitPosition{{"1", "2", "3"}},
+		},
+		{
+			name:   "ok - max uncommitted bytes reached",
+			config: Config{MaxUncommittedBytes: 0x20},
+			opts:   []Option{WithLSNParser(pgreplication.NewLSNParser())},
+			calls:  [][]wal.CommitPosition{{"0/10"}, {"0/20", "0/18"}, {"0/38"}, {"0/40"}},
+
+			wantCommitted: [][]wal.CommitPosition{{"0/38"}},
+		},
+		{
+			name:   "ok - max uncommitted bytes ignored without lsn parser",
+			config: Config{MaxUncommittedBytes: 1},
+			calls:  [][]wal.CommitPosition{{"1"}, {"2"}},
+
+			wantCommitted: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockCheckpoint{}
+			c := New(&tc.config, mock.checkpoint, append(tc.opts, func(c *Committer) { c.shutdownCommit = false })...)
+			defer c.Close()
+
+			for _, positions := range tc.calls {
+				require.NoError(t, c.Checkpoint(context.Background(), positions))
+			}
+			require.Equal(t, tc.wantCommitted, mock.committed())
+		})
+	}
+}
+
+func TestCommitter_commit(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	t.Run("ok - commit loop", func(t *testing.T) {
+		t.Parallel()
+
+		mock := &mockCheckpoint{}
+		c := New(&Config{CommitInterval: time.Millisecond}, mock.checkpoint)
+		defer c.Close()
+
+		require.NoError(t, c.Checkpoint(context.Background(), []wal.CommitPosition{"1"}))
+		require.Eventually(t, func() bool {
+			return len(mock.committed()) == 1
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("ok - failed commit is attempted again", func(t *testing.T) {
+		t.Parallel()
+
+		mock := &mockCheckpoint{err: errTest}
+		c := New(&Config{CommitInterval: time.Hour}, mock.checkpoint)
+
+		require.NoError(t, c.Checkpoint(context.Background(), []wal.CommitPosition{"1"}))
+		require.ErrorIs(t, c.commit(context.Background()), errTest)
+		require.NoError(t, c.Checkpoint(context.Background(), []wal.CommitPosition{"2"}))
+		require.Equal(t, uint64(2), c.uncommittedEvents)
+
+		mock.setErr(nil)
+		require.NoError(t, c.commit(context.Background()))
+		require.Equal(t, [][]wal.CommitPosition{{"1", "2"}}, mock.committed())
+		require.Equal(t, uint64(0), c.uncommittedEvents)
+		require.NoError(t, c.Close())
+		require.Len(t, mock.committed(), 1)
+	})
+
+	t.Run("ok - failed commit with lsn parser keeps the max position", func(t *testing.T) {
+		t.Parallel()
+
+		mock := &mockCheckpoint{err: errTest}
+		c := New(&Config{CommitInterval: time.Hour}, mock.checkpoint, WithLSNParser(pgreplication.NewLSNParser()))
+
+		require.NoError(t, c.Checkpoint(context.Background(), []wal.CommitPosition{"0/2", "0/1"}))
+		require.ErrorIs(t, c.commit(context.Background()), errTest)
+		require.Equal(t, []wal.CommitPosition{"0/2"}, c.pending)
+
+		mock.setErr(nil)
+		require.NoError(t, c.Checkpoint(context.Background(), []wal.CommitPosition{"0/3"}))
+		require.NoError(t, c.Close())
+		require.Equal(t, [][]wal.CommitPosition{{"0/3"}}, mock.committed())
+		require.Equal(t, uint64(0), c.uncommittedBytes())
+	})
+
+	t.Run("error - invalid lsn", func(t *testing.T) {
+		t.Parallel()
+
+		mock := &mockCheckpoint{}
+		c := New(&Config{}, mock.checkpoint, WithLSNParser(pgreplication.NewLSNParser()))
+		defer c.Close()
+
+		require.Error(t, c.Checkpoint(context.Background(), []wal.CommitPosition{"invalid"}))
+		require.Empty(t, mock.committed())
+	})
+}
+
+func TestCommitter_Close(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config Config
+
+		wantCommitted [][]wal.CommitPosition
+	}{
+		{
+			name:   "ok - shutdown commit",
+			config: Config{CommitInterval: time.Hour},
+
+			wantCommitted: [][]wal.CommitPosition{{"1"}},
+		},
+		{
+			name:   "ok - shutdown commit disabled",
+			config: Config{CommitInterval: time.Hour, DisableShutdownCommit: true},
+
+			wantCommitted: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mock := &mockCheckpoint{}
+			c := New(&tc.config, mock.checkpoint)
+
+			require.NoError(t, c.Checkpoint(context.Background(), []wal.CommitPosition{"1"}))
+			require.NoError(t, c.Close())
+			require.Equal(t, tc.wantCommitted, mock.committed())
+		})
+	}
+}
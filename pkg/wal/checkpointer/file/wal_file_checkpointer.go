@@ -0,0 +1,297 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xataio/pgstream/internal/json"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// Checkpointer is a file implementation of a wal checkpointer. It persists the
+// last checkpointed LSN into a local file on a configurable interval, and only
+// checkpoints the positions to the wrapped checkpoint once they're durable, so
+// that the source is never ahead of the file.
+type Checkpointer struct {
+	logger        loglib.Logger
+	path          string
+	flushInterval time.Duration
+	next          checkpointer.Checkpoint
+	parser        replication.LSNParser
+
+	// mutex protects the pending position, which is updated on every
+	// checkpoint
+	mutex   sync.Mutex
+	pending replication.LSN
+
+	// flushMutex serialises the flushes of the loop and the final one on close
+	flushMutex sync.Mutex
+	// flushed is the last LSN persisted to the file, and synced the last one
+	// checkpointed to the wrapped checkpoint
+	flushed replication.LSN
+	synced  replication.LSN
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type Config struct {
+	// Path of the checkpoint file. The directory must exist.
+	Path string
+	// FlushInterval is the interval at which the checkpointed LSN is persisted
+	// to the file. Defaults to 1s.
+	FlushInterval time.Duration
+	// Reset ignores the checkpoint file when it's corrupted, instead of
+	// failing. The replication will be resumed from the source position.
+	Reset bool
+}
+
+type Option func(c *Checkpointer)
+
+// checkpointFile is the content of the checkpoint file. The checksum is used
+// to detect corrupted files.
+type checkpointFile struct {
+	LSN       string    `json:"lsn"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Checksum  uint32    `json:"checksum"`
+}
+
+const defaultFlushInterval = time.Second
+
+var (
+	errMissingPath = errors.New("checkpoint file path must be provided")
+	// ErrCorruptedCheckpoint is returned when the checkpoint file can't be
+	// read, which requires it to be reset.
+	ErrCorruptedCheckpoint = errors.New("corrupted checkpoint file")
+)
+
+// New returns a file checkpointer that persists the LSN to the configured
+// file, and checkpoints it to the checkpoint on input once it's durable. It
+// fails if the existing checkpoint file is corrupted, unless it's configured
+// to be reset.
+func New(cfg *Config, next checkpointer.Checkpoint, opts ...Option) (*Checkpointer, error) {
+	if cfg.Path == "" {
+		return nil, errMissingPath
+	}
+
+	c := &Checkpointer{
+		logger:        loglib.NewNoopLogger(),
+		path:          cfg.Path,
+		flushInterval: cfg.flushInterval(),
+		next:          next,
+		parser:        pgreplication.NewLSNParser(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	lsn, err := c.read()
+	switch {
+	case errors.Is(err, ErrCorruptedCheckpoint) && cfg.Reset:
+		c.logger.Warn(err, "resetting corrupted checkpoint file", loglib.Fields{"path": c.path})
+	case err != nil:
+		return nil, err
+	}
+	c.pending = lsn
+	c.flushed = lsn
+	c.synced = lsn
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.wg.Add(1)
+	go c.flushLoop(ctx)
+
+	return c, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(c *Checkpointer) {
+		c.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_file_checkpointer",
+		})
+	}
+}
+
+// SyncLSN keeps track of the max LSN of the positions on input, which will be
+// persisted on the next flush.
+func (c *Checkpointer) SyncLSN(ctx context.Context, positions []wal.CommitPosition) error {
+	if len(positions) == 0 {
+		return nil
+	}
+
+	// we only need the max pg wal offset
+	var max replication.LSN
+	for _, position := range positions {
+		lsn, err := c.parser.FromString(string(position))
+		if err != nil {
+			return err
+		}
+		if lsn > max {
+			max = lsn
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if max > c.pending {
+		c.pending = max
+	}
+	return nil
+}
+
+// LastSyncedLSN returns the LSN persisted to the file, or 0 if there's none.
+func (c *Checkpointer) LastSyncedLSN(ctx context.Context) (replication.LSN, error) {
+	c.flushMutex.Lock()
+	defer c.flushMutex.Unlock()
+	return c.flushed, nil
+}
+
+// Close stops the flush loop, and persists the pending LSN.
+func (c *Checkpointer) Close() error {
+	c.cancel()
+	c.wg.Wait()
+	return c.flush(context.Background())
+}
+
+func (c *Checkpointer) flushLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.flush(ctx); err != nil {
+				c.logger.Error(err, "flushing checkpoint file", loglib.Fields{"path": c.path})
+			}
+		}
+	}
+}
+
+// flush persists the pending LSN to the file, and checkpoints it to the wrapped
+// checkpoint once it's durable. Failed steps are retried on the next flush.
+func (c *Checkpointer) flush(ctx context.Context) error {
+	c.flushMutex.Lock()
+	defer c.flushMutex.Unlock()
+
+	c.mutex.Lock()
+	pending := c.pending
+	c.mutex.Unlock()
+
+	if pending > c.flushed {
+		if err := c.write(pending); err != nil {
+			return err
+		}
+		c.flushed = pending
+	}
+
+	if c.next != nil && c.flushed > c.synced {
+		if err := c.next(ctx, []wal.CommitPosition{wal.CommitPosition(c.parser.ToString(c.flushed))}); err != nil {
+			return fmt.Errorf("checkpointing flushed position: %w", err)
+		}
+		c.synced = c.flushed
+	}
+
+	return nil
+}
+
+// read returns the LSN in the checkpoint file, or 0 if it doesn't exist.
+func (c *Checkpointer) read() (replication.LSN, error) {
+	content, err := os.ReadFile(c.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("reading checkpoint file: %w", err)
+	}
+
+	var file checkpointFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return 0, fmt.Errorf("%w %s: %w", ErrCorruptedCheckpoint, c.path, err)
+	}
+	if file.Checksum != checksum(file.LSN) {
+		return 0, fmt.Errorf("%w %s: checksum mismatch", ErrCorruptedCheckpoint, c.path)
+	}
+	lsn, err := c.parser.FromString(file.LSN)
+	if err != nil {
+		return 0, fmt.Errorf("%w %s: %w", ErrCorruptedCheckpoint, c.path, err)
+	}
+	return lsn, nil
+}
+
+// write replaces the checkpoint file atomically, by writing a temporary file in
+// the same directory and renaming it once it's synced to disk, so that a crash
+// never leaves a partially written checkpoint.
+func (c *Checkpointer) write(lsn replication.LSN) error {
+	lsnStr := c.parser.ToString(lsn)
+	content, err := json.Marshal(checkpointFile{
+		LSN:       lsnStr,
+		UpdatedAt: time.Now().UTC(),
+		Checksum:  checksum(lsnStr),
+	})
+	if err != nil {
+		return fmt.Errorf("marshalling checkpoint file: %w", err)
+	}
+
+	dir := filepath.Dir(c.path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(c.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary checkpoint file: %w", err)
+	}
+	defer func() {
+		// no-op once the file has been renamed
+		os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing temporary checkpoint file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("syncing temporary checkpoint file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temporary checkpoint file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("renaming checkpoint file: %w", err)
+	}
+
+	// the rename is only durable once the directory is synced
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("opening checkpoint directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("syncing checkpoint directory: %w", err)
+	}
+	return nil
+}
+
+func (c *Config) flushInterval() time.Duration {
+	if c.FlushInterval > 0 {
+		return c.FlushInterval
+	}
+	return defaultFlushInterval
+}
+
+func checksum(lsn string) uint32 {
+	return crc32.ChecksumIEEE([]byte(lsn))
+}
@@ -0,0 +1,304 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package file
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	validFile := func(t *testing.T, path string) {
+		content := fmt.Sprintf(`{"lsn":"1/3","updated_at":"2024-01-01T00:00:00Z","checksum":%d}`, checksum("1/3"))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+
+	tests := []struct {
+		name      string
+		setupFile func(t *testing.T, path string)
+		reset     bool
+
+		wantLSN replication.LSN
+		wantErr error
+	}{
+		{
+			name: "ok - no checkpoint file",
+
+			wantLSN: 0,
+		},
+		{
+			name:      "ok - checkpoint file",
+			setupFile: validFile,
+
+			wantLSN: replication.LSN(1<<32 + 3),
+		},
+		{
+			name: "ok - corrupted checkpoint file with reset",
+			setupFile: func(t *testing.T, path string) {
+				require.NoError(t, os.WriteFile(path, []byte(`{"lsn":"1/`), 0o600))
+			},
+			reset: true,
+
+			wantLSN: 0,
+		},
+		{
+			name: "error - truncated checkpoint file",
+			setupFile: func(t *testing.T, path string) {
+				require.NoError(t, os.WriteFile(path, []byte(`{"lsn":"1/`), 0o600))
+			},
+
+			wantErr: ErrCorruptedCheckpoint,
+		},
+		{
+			name: "error - checksum mismatch",
+			setupFile: func(t *testing.T, path string) {
+				content := fmt.Sprintf(`{"lsn":"1/4","updated_at":"2024-01-01T00:00:00Z","checksum":%d}`, checksum("1/3"))
+				require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			},
+
+			wantErr: ErrCorruptedCheckpoint,
+		},
+		{
+			name: "error - invalid lsn",
+			setupFile: func(t *testing.T, path string) {
+				content := fmt.Sprintf(`{"lsn":"invalid","updated_at":"2024-01-01T00:00:00Z","checksum":%d}`, checksum("invalid"))
+				require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			},
+
+			wantErr: ErrCorruptedCheckpoint,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "checkpoint.json")
+			if tc.setupFile != nil {
+				tc.setupFile(t, path)
+			}
+
+			c, err := New(&Config{Path: path, Reset: tc.reset}, nil)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			defer c.Close()
+
+			lsn, err := c.LastSyncedLSN(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, tc.wantLSN, lsn)
+		})
+	}
+
+	t.Run("error - missing path", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := New(&Config{}, nil)
+		require.ErrorIs(t, err, errMissingPath)
+	})
+}
+
+func TestCheckpointer_flush(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	t.Run("ok - checkpoints once persisted", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "checkpoint.json")
+		var synced []wal.CommitPosition
+		c, err := New(&Config{Path: path, FlushInterval: time.Hour}, func(ctx context.Context, positions []wal.CommitPosition) error {
+			// the position must be durable before it's checkpointed
+			lsn := readCheckpointFile(t, path)
+			require.Equal(t, string(positions[0]), lsn)
+			synced = append(synced, positions...)
+			return nil
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/1", "0/3", "0/2"}))
+		require.NoError(t, c.flush(context.Background()))
+		// no new positions, nothing to flush
+		require.NoError(t, c.flush(context.Background()))
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/2"}))
+		require.NoError(t, c.flush(context.Background()))
+		require.Equal(t, []wal.CommitPosition{"0/3"}, synced)
+
+		// the pending position is flushed on close
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/4"}))
+		require.NoError(t, c.Close())
+		require.Equal(t, []wal.CommitPosition{"0/3", "0/4"}, synced)
+		require.Equal(t, "0/4", readCheckpointFile(t, path))
+
+		// no temporary files are left behind
+		entries, err := os.ReadDir(filepath.Dir(path))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+	})
+
+	t.Run("ok - failed checkpoint is retried", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "checkpoint.json")
+		calls := 0
+		c, err := New(&Config{Path: path, FlushInterval: time.Hour}, func(ctx context.Context, positions []wal.CommitPosition) error {
+			calls++
+			if calls == 1 {
+				return errTest
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		defer c.Close()
+
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/1"}))
+		require.ErrorIs(t, c.flush(context.Background()), errTest)
+		require.Equal(t, "0/1", readCheckpointFile(t, path))
+		require.NoError(t, c.flush(context.Background()))
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("ok - flush loop", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "checkpoint.json")
+		c, err := New(&Config{Path: path, FlushInterval: time.Millisecond}, nil)
+		require.NoError(t, err)
+		defer c.Close()
+
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/1"}))
+		require.Eventually(t, func() bool {
+			lsn, err := c.LastSyncedLSN(context.Background())
+			return err == nil && lsn == replication.LSN(1)
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("error - invalid position", func(t *testing.T) {
+		t.Parallel()
+
+		c, err := New(&Config{Path: filepath.Join(t.TempDir(), "checkpoint.json"), FlushInterval: time.Hour}, nil)
+		require.NoError(t, err)
+		defer c.Close()
+
+		require.Error(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"invalid"}))
+	})
+}
+
+const crashHelperEnv = "PGSTREAM_FILE_CHECKPOINTER_CRASH_HELPER"
+
+// TestCheckpointer_crash kills a process checkpointing to a file at random
+// points, and verifies the source is never checkpointed ahead of the file, and
+// that the replication resumes from the last durable position.
+func TestCheckpointer_crash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping crash simulation in short mode")
+	}
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	parser := pgreplication.NewLSNParser()
+
+	var durable replication.LSN
+	for i := range 5 {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCheckpointerCrashHelper$")
+		cmd.Env = append(os.Environ(), crashHelperEnv+"="+path)
+		stdout, err := cmd.StdoutPipe()
+		require.NoError(t, err)
+		require.NoError(t, cmd.Start())
+
+		var start, lastSynced replication.LSN
+		scanner := bufio.NewScanner(stdout)
+		syncedLines := 0
+		var killOnce sync.Once
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 2 {
+				continue
+			}
+			lsn, err := parser.FromString(fields[1])
+			require.NoError(t, err)
+			switch fields[0] {
+			case "start":
+				start = lsn
+			case "synced":
+				require.GreaterOrEqual(t, lsn, lastSynced)
+				lastSynced = lsn
+				syncedLines++
+			}
+			// kill the process at a different point on every run
+			if syncedLines >= 3+i*7 {
+				killOnce.Do(func() { require.NoError(t, cmd.Process.Kill()) })
+			}
+		}
+		cmd.Wait()
+
+		// the process resumes from the durable position
+		require.Equal(t, durable, start, "run %d", i)
+
+		// the checkpoint file is never left corrupted, and it's never behind
+		// the position checkpointed to the source
+		c, err := New(&Config{Path: path, FlushInterval: time.Hour}, nil)
+		require.NoError(t, err, "run %d", i)
+		durable, err = c.LastSyncedLSN(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, c.Close())
+		require.GreaterOrEqual(t, durable, lastSynced, "run %d", i)
+		require.Greater(t, durable, start, "run %d", i)
+	}
+}
+
+// TestCheckpointerCrashHelper is run as a separate process by the crash
+// simulation. It checkpoints increasing positions until it's killed, printing
+// the positions checkpointed to the source.
+func TestCheckpointerCrashHelper(t *testing.T) {
+	path := os.Getenv(crashHelperEnv)
+	if path == "" {
+		t.Skip("only run by the crash simulation")
+	}
+
+	parser := pgreplication.NewLSNParser()
+	c, err := New(&Config{Path: path, FlushInterval: time.Millisecond}, func(ctx context.Context, positions []wal.CommitPosition) error {
+		fmt.Fprintf(os.Stdout, "synced %s\n", positions[0])
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "creating checkpointer: %v\n", err)
+		os.Exit(1)
+	}
+
+	start, _ := c.LastSyncedLSN(context.Background())
+	fmt.Fprintf(os.Stdout, "start %s\n", parser.ToString(start))
+	for lsn := start + 1; ; lsn++ {
+		if err := c.SyncLSN(context.Background(), []wal.CommitPosition{wal.CommitPosition(parser.ToString(lsn))}); err != nil {
+			fmt.Fprintf(os.Stderr, "checkpointing: %v\n", err)
+			os.Exit(1)
+		}
+		time.Sleep(10 * time.Microsecond)
+	}
+}
+
+func readCheckpointFile(t *testing.T, path string) string {
+	t.Helper()
+	c := &Checkpointer{path: path, parser: pgreplication.NewLSNParser()}
+	lsn, err := c.read()
+	require.NoError(t, err)
+	return c.parser.ToString(lsn)
+}
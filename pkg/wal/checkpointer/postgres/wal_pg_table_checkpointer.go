@@ -0,0 +1,278 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// TableCheckpointer is a postgres implementation of a wal checkpointer that
+// persists the last processed LSN of a pipeline into a table, so that the
+// replication can be resumed from it. Every pipeline has its own row,
+// identified by the pipeline id, and the LSN of a row never moves backwards.
+type TableCheckpointer struct {
+	querier         pglib.Querier
+	parser          replication.LSNParser
+	table           string
+	pipelineID      string
+	pipelineVersion string
+}
+
+type TableConfig struct {
+	// URL of the postgres database where the checkpoints table is stored.
+	URL string
+	// PipelineID identifies the checkpoint row of the pipeline. Pipelines
+	// sharing the checkpoints table must use different ids.
+	PipelineID string
+	// Table is the schema qualified name of the checkpoints table. Defaults to
+	// pgstream.checkpoints.
+	Table string
+	// PipelineVersion is the version of the pipeline configuration, stored
+	// with the checkpoint row so that the running versions can be listed.
+	PipelineVersion string
+}
+
+// PipelineCheckpoint contains the details of a checkpoint row.
+type PipelineCheckpoint struct {
+	PipelineID      string    `json:"pipeline_id"`
+	PipelineVersion string    `json:"pipeline_version"`
+	LSN             string    `json:"lsn"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// PipelineCheckpoints is a list of checkpoint rows.
+type PipelineCheckpoints []*PipelineCheckpoint
+
+const (
+	defaultCheckpointsSchema = "pgstream"
+	defaultCheckpointsTable  = "checkpoints"
+)
+
+var (
+	errMissingCheckpointsURL        = errors.New("checkpoints table URL must be provided")
+	errMissingCheckpointsPipelineID = errors.New("checkpoints table pipeline id must be provided")
+)
+
+// NewTableCheckpointer returns a postgres checkpointer that persists the LSN
+// into the configured table, creating it if it doesn't exist.
+func NewTableCheckpointer(ctx context.Context, cfg *TableConfig) (*TableCheckpointer, error) {
+	if cfg.URL == "" {
+		return nil, errMissingCheckpointsURL
+	}
+	if cfg.PipelineID == "" {
+		return nil, errMissingCheckpointsPipelineID
+	}
+	schema, table, err := cfg.qualifiedTable()
+	if err != nil {
+		return nil, err
+	}
+
+	querier, err := pglib.NewConnPool(ctx, cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("creating checkpoints postgres connection pool: %w", err)
+	}
+
+	c := &TableCheckpointer{
+		querier:         querier,
+		parser:          pgreplication.NewLSNParser(),
+		table:           pglib.QuoteQualifiedIdentifier(schema, table),
+		pipelineID:      cfg.PipelineID,
+		pipelineVersion: cfg.PipelineVersion,
+	}
+
+	if err := c.createTable(ctx, schema); err != nil {
+		querier.Close(ctx)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// SyncLSN persists the max LSN of the positions on input into the checkpoints
+// table.
+func (c *TableCheckpointer) SyncLSN(ctx context.Context, positions []wal.CommitPosition) error {
+	return c.syncLSN(ctx, c.querier, positions)
+}
+
+// SyncLSNInTx persists the max LSN of the positions on input into the
+// checkpoints table, as part of the transaction on input. The table must be
+// stored in the database the transaction belongs to.
+func (c *TableCheckpointer) SyncLSNInTx(ctx context.Context, tx pglib.Tx, positions []wal.CommitPosition) error {
+	return c.syncLSN(ctx, tx, positions)
+}
+
+// LastSyncedLSN returns the LSN persisted for the pipeline, or 0 if there's
+// none.
+func (c *TableCheckpointer) LastSyncedLSN(ctx context.Context) (replication.LSN, error) {
+	var lsn string
+	query := fmt.Sprintf(`SELECT lsn::text FROM %s WHERE pipeline_id = $1`, c.table)
+	if err := c.querier.QueryRow(ctx, []any{&lsn}, query, c.pipelineID); err != nil {
+		if errors.Is(err, pglib.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("reading checkpointed LSN: %w", err)
+	}
+	return c.parser.FromString(lsn)
+}
+
+// ListPipelineCheckpoints returns the checkpoint rows of all the pipelines in
+// the configured checkpoints table, along with the version of the pipeline
+// configuration that last checkpointed them.
+func ListPipelineCheckpoints(ctx context.Context, cfg *TableConfig) (PipelineCheckpoints, error) {
+	if cfg.URL == "" {
+		return nil, errMissingCheckpointsURL
+	}
+	schema, table, err := cfg.qualifiedTable()
+	if err != nil {
+		return nil, err
+	}
+
+	querier, err := pglib.NewConnPool(ctx, cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("creating checkpoints postgres connection pool: %w", err)
+	}
+	defer querier.Close(ctx)
+
+	return listPipelineCheckpoints(ctx, querier, pglib.QuoteQualifiedIdentifier(schema, table))
+}
+
+func (c *TableCheckpointer) Close() error {
+	return c.querier.Close(context.Background())
+}
+
+type execer interface {
+	Exec(ctx context.Context, query string, args ...any) (pglib.CommandTag, error)
+}
+
+func (c *TableCheckpointer) syncLSN(ctx context.Context, conn execer, positions []wal.CommitPosition) error {
+	if len(positions) == 0 {
+		return nil
+	}
+
+	// we only need the max pg wal offset
+	var max replication.LSN
+	for _, position := range positions {
+		lsn, err := c.parser.FromString(string(position))
+		if err != nil {
+			return err
+		}
+		if lsn > max {
+			max = lsn
+		}
+	}
+
+	// the condition makes sure a delayed checkpoint doesn't move the position
+	// backwards
+	query := fmt.Sprintf(`INSERT INTO %[1]s(pipeline_id, lsn, updated_at, pipeline_version) VALUES($1, $2::pg_lsn, now(), $3)
+	ON CONFLICT (pipeline_id) DO UPDATE SET lsn = EXCLUDED.lsn, updated_at = EXCLUDED.updated_at, pipeline_version = EXCLUDED.pipeline_version
+	WHERE %[1]s.lsn < EXCLUDED.lsn`, c.table)
+	if _, err := conn.Exec(ctx, query, c.pipelineID, c.parser.ToString(max), c.pipelineVersion); err != nil {
+		return fmt.Errorf("persisting checkpointed LSN: %w", err)
+	}
+	return nil
+}
+
+func (c *TableCheckpointer) createTable(ctx context.Context, schema string) error {
+	if _, err := c.querier.Exec(ctx, fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s`, pglib.QuoteIdentifier(schema))); err != nil {
+		return fmt.Errorf("creating checkpoints schema: %w", err)
+	}
+
+	createTableQuery := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+	pipeline_id TEXT PRIMARY KEY,
+	lsn PG_LSN NOT NULL,
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	pipeline_version TEXT)`, c.table)
+	if _, err := c.querier.Exec(ctx, createTableQuery); err != nil {
+		return fmt.Errorf("creating checkpoints table: %w", err)
+	}
+
+	// tables created by previous versions don't have the pipeline version
+	alterTableQuery := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS pipeline_version TEXT`, c.table)
+	if _, err := c.querier.Exec(ctx, alterTableQuery); err != nil {
+		return fmt.Errorf("adding pipeline version to checkpoints table: %w", err)
+	}
+	return nil
+}
+
+func listPipelineCheckpoints(ctx context.Context, querier pglib.Querier, table string) (PipelineCheckpoints, error) {
+	query := fmt.Sprintf(`SELECT pipeline_id, COALESCE(pipeline_version, ''), lsn::text, updated_at FROM %s ORDER BY pipeline_id`, table)
+	rows, err := querier.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("listing pipeline checkpoints: %w", err)
+	}
+	defer rows.Close()
+
+	checkpoints := PipelineCheckpoints{}
+	for rows.Next() {
+		checkpoint := &PipelineCheckpoint{}
+		if err := rows.Scan(&checkpoint.PipelineID, &checkpoint.PipelineVersion, &checkpoint.LSN, &checkpoint.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning pipeline checkpoint: %w", err)
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return checkpoints, nil
+}
+
+func (c *TableConfig) qualifiedTable() (schema, table string, err error) {
+	if c.Table == "" {
+		return defaultCheckpointsSchema, defaultCheckpointsTable, nil
+	}
+	qn, err := pglib.NewQualifiedName(c.Table)
+	if err != nil {
+		return "", "", fmt.Errorf("checkpoints table %q: %w", c.Table, err)
+	}
+	schema = qn.Schema()
+	if schema == "" {
+		schema = "public"
+	}
+	return schema, qn.Name(), nil
+}
+
+func (c *PipelineCheckpoint) PrettyPrint() string {
+	if c == nil {
+		return ""
+	}
+
+	version := c.PipelineVersion
+	if version == "" {
+		version = "unknown"
+	}
+
+	var prettyPrint strings.Builder
+	prettyPrint.WriteString(fmt.Sprintf("Pipeline %s:\n", c.PipelineID))
+	prettyPrint.WriteString(fmt.Sprintf(" - Version: %s\n", version))
+	prettyPrint.WriteString(fmt.Sprintf(" - LSN: %s\n", c.LSN))
+	prettyPrint.WriteString(fmt.Sprintf(" - Updated at: %s\n", c.UpdatedAt.Format(time.RFC3339)))
+
+	return prettyPrint.String()
+}
+
+func (c PipelineCheckpoints) PrettyPrint() string {
+	if len(c) == 0 {
+		return "No pipeline checkpoints found"
+	}
+
+	var prettyPrint strings.Builder
+	for i, checkpoint := range c {
+		if i > 0 {
+			prettyPrint.WriteByte('\n')
+		}
+		prettyPrint.WriteString(checkpoint.PrettyPrint())
+	}
+
+	return prettyPrint.String()
+}
@@ -0,0 +1,315 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	replicationmocks "github.com/xataio/pgstream/pkg/wal/replication/mocks"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+const (
+	testPipelineID      = "test_pipeline"
+	testPipelineVersion = "test_version"
+	testTable           = `"pgstream"."checkpoints"`
+)
+
+const testUpsertQuery = `INSERT INTO "pgstream"."checkpoints"(pipeline_id, lsn, updated_at, pipeline_version) VALUES($1, $2::pg_lsn, now(), $3)
+	ON CONFLICT (pipeline_id) DO UPDATE SET lsn = EXCLUDED.lsn, updated_at = EXCLUDED.updated_at, pipeline_version = EXCLUDED.pipeline_version
+	WHERE "pgstream"."checkpoints".lsn < EXCLUDED.lsn`
+
+func TestTableCheckpointer_SyncLSN(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name   string
+		pos    []wal.CommitPosition
+		parser replication.LSNParser
+		execFn func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error)
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			pos:  []wal.CommitPosition{"0/1", "1/3", "0/2"},
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				require.Equal(t, testUpsertQuery, query)
+				require.Equal(t, []any{testPipelineID, "1/3", testPipelineVersion}, args)
+				return pglib.CommandTag{}, nil
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - empty positions",
+			pos:  []wal.CommitPosition{},
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				return pglib.CommandTag{}, errors.New("execFn: should not be called")
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "error - parsing lsn",
+			pos:  []wal.CommitPosition{"0/1"},
+			parser: &replicationmocks.LSNParser{
+				FromStringFn: func(s string) (replication.LSN, error) {
+					return 0, errTest
+				},
+			},
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				return pglib.CommandTag{}, errors.New("execFn: should not be called")
+			},
+
+			wantErr: errTest,
+		},
+		{
+			name: "error - persisting lsn",
+			pos:  []wal.CommitPosition{"0/1"},
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				return pglib.CommandTag{}, errTest
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			t.Run("querier", func(t *testing.T) {
+				c := newTestTableCheckpointer(&pgmocks.Querier{ExecFn: tc.execFn})
+				if tc.parser != nil {
+					c.parser = tc.parser
+				}
+				err := c.SyncLSN(context.Background(), tc.pos)
+				require.ErrorIs(t, err, tc.wantErr)
+			})
+
+			t.Run("transaction", func(t *testing.T) {
+				c := newTestTableCheckpointer(&pgmocks.Querier{
+					ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+						return pglib.CommandTag{}, errors.New("ExecFn: should not be called")
+					},
+				})
+				if tc.parser != nil {
+					c.parser = tc.parser
+				}
+				err := c.SyncLSNInTx(context.Background(), &pgmocks.Tx{ExecFn: tc.execFn}, tc.pos)
+				require.ErrorIs(t, err, tc.wantErr)
+			})
+		})
+	}
+}
+
+func TestTableCheckpointer_LastSyncedLSN(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name       string
+		queryRowFn func(ctx context.Context, dest []any, query string, args ...any) error
+
+		wantLSN replication.LSN
+		wantErr error
+	}{
+		{
+			name: "ok",
+			queryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+				require.Equal(t, `SELECT lsn::text FROM "pgstream"."checkpoints" WHERE pipeline_id = $1`, query)
+				require.Equal(t, []any{testPipelineID}, args)
+				lsn, ok := dest[0].(*string)
+				require.True(t, ok)
+				*lsn = "1/3"
+				return nil
+			},
+
+			wantLSN: replication.LSN(1<<32 + 3),
+			wantErr: nil,
+		},
+		{
+			name: "ok - no checkpoint",
+			queryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+				return pglib.ErrNoRows
+			},
+
+			wantLSN: 0,
+			wantErr: nil,
+		},
+		{
+			name: "error - reading checkpoint",
+			queryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+				return errTest
+			},
+
+			wantLSN: 0,
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestTableCheckpointer(&pgmocks.Querier{QueryRowFn: tc.queryRowFn})
+			lsn, err := c.LastSyncedLSN(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantLSN, lsn)
+		})
+	}
+}
+
+func TestListPipelineCheckpoints(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	testUpdatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testRows := func(scanErr error) *pgmocks.Rows {
+		return &pgmocks.Rows{
+			NextFn: func(i uint) bool { return i <= 2 },
+			ScanFn: func(i uint, dest ...any) error {
+				if scanErr != nil {
+					return scanErr
+				}
+				require.Len(t, dest, 4)
+				*dest[0].(*string) = fmt.Sprintf("pipeline_%d", i)
+				if i == 1 {
+					*dest[1].(*string) = testPipelineVersion
+				}
+				*dest[2].(*string) = "1/3"
+				*dest[3].(*time.Time) = testUpdatedAt
+				return nil
+			},
+			ErrFn: func() error { return nil },
+		}
+	}
+
+	tests := []struct {
+		name    string
+		queryFn func(ctx context.Context, i uint, query string, args ...any) (pglib.Rows, error)
+
+		wantCheckpoints PipelineCheckpoints
+		wantErr         error
+	}{
+		{
+			name: "ok",
+			queryFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.Rows, error) {
+				require.Equal(t, `SELECT pipeline_id, COALESCE(pipeline_version, ''), lsn::text, updated_at FROM "pgstream"."checkpoints" ORDER BY pipeline_id`, query)
+				return testRows(nil), nil
+			},
+
+			wantCheckpoints: PipelineCheckpoints{
+				{PipelineID: "pipeline_1", PipelineVersion: testPipelineVersion, LSN: "1/3", UpdatedAt: testUpdatedAt},
+				{PipelineID: "pipeline_2", LSN: "1/3", UpdatedAt: testUpdatedAt},
+			},
+		},
+		{
+			name: "error - querying checkpoints",
+			queryFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.Rows, error) {
+				return nil, errTest
+			},
+
+			wantErr: errTest,
+		},
+		{
+			name: "error - scanning checkpoint",
+			queryFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.Rows, error) {
+				return testRows(errTest), nil
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checkpoints, err := listPipelineCheckpoints(context.Background(), &pgmocks.Querier{QueryFn: tc.queryFn}, testTable)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantCheckpoints, checkpoints)
+		})
+	}
+}
+
+func TestTableConfig_qualifiedTable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		table string
+
+		wantSchema string
+		wantTable  string
+		wantErr    error
+	}{
+		{
+			name:  "ok - default",
+			table: "",
+
+			wantSchema: "pgstream",
+			wantTable:  "checkpoints",
+		},
+		{
+			name:  "ok - schema qualified",
+			table: "ops.pipeline_checkpoints",
+
+			wantSchema: "ops",
+			wantTable:  "pipeline_checkpoints",
+		},
+		{
+			name:  "ok - public schema",
+			table: "pipeline_checkpoints",
+
+			wantSchema: "public",
+			wantTable:  "pipeline_checkpoints",
+		},
+		{
+			name:  "error - invalid name",
+			table: "a.b.c",
+
+			wantErr: errors.New("checkpoints table \"a.b.c\": unexpected qualified name format"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &TableConfig{Table: tc.table}
+			schema, table, err := cfg.qualifiedTable()
+			if tc.wantErr != nil {
+				require.EqualError(t, err, tc.wantErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantSchema, schema)
+			require.Equal(t, tc.wantTable, table)
+		})
+	}
+}
+
+func newTestTableCheckpointer(querier pglib.Querier) *TableCheckpointer {
+	return &TableCheckpointer{
+		querier:         querier,
+		parser:          pgreplication.NewLSNParser(),
+		table:           testTable,
+		pipelineID:      testPipelineID,
+		pipelineVersion: testPipelineVersion,
+	}
+}
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/xataio/pgstream/pkg/backoff"
+	"github.com/xataio/pgstream/pkg/tls"
+)
+
+type Config struct {
+	// Mode is the redis deployment mode, one of standalone, sentinel or
+	// cluster. Defaults to standalone.
+	Mode Mode
+	// Addresses are the host:port addresses of the redis server in standalone
+	// mode, of the sentinels in sentinel mode, or the cluster seed nodes in
+	// cluster mode.
+	Addresses []string
+	// MasterName is the name of the master monitored by the sentinels.
+	// Required in sentinel mode.
+	MasterName string
+	Username   string
+	Password   string
+	// SentinelUsername and SentinelPassword are used to authenticate with the
+	// sentinels, when they differ from the redis server ones.
+	SentinelUsername string
+	SentinelPassword string
+	// DB is the redis database. Not supported in cluster mode.
+	DB  int
+	TLS tls.Config
+
+	// StreamID identifies the checkpoint key of the stream. Streams sharing
+	// the redis deployment must use different ids.
+	StreamID string
+	// KeyPrefix is prepended to the stream id to build the checkpoint key.
+	// Defaults to pgstream:checkpoint:.
+	KeyPrefix string
+	// KeyTTL is the expiration of the checkpoint key, refreshed on every
+	// commit. The key is persisted without expiration if not set.
+	KeyTTL time.Duration
+	// CommitInterval is the interval at which the checkpointed LSN is
+	// committed to redis. Defaults to 1s.
+	CommitInterval time.Duration
+	// MaxUncommittedWindow is the maximum time the checkpointed positions can
+	// remain uncommitted while redis is unavailable, before the checkpoints
+	// block until a commit succeeds. Defaults to 1m.
+	MaxUncommittedWindow time.Duration
+	// RetryPolicy is the backoff policy used to retry failed commits. Commits
+	// that exhaust the retries are attempted again on the next interval.
+	// Defaults to an exponential backoff.
+	RetryPolicy backoff.Config
+}
+
+type Mode string
+
+const (
+	ModeStandalone Mode = "standalone"
+	ModeSentinel   Mode = "sentinel"
+	ModeCluster    Mode = "cluster"
+)
+
+const (
+	defaultKeyPrefix            = "pgstream:checkpoint:"
+	defaultCommitInterval       = time.Second
+	defaultMaxUncommittedWindow = time.Minute
+
+	defaultRetryInitialInterval = 100 * time.Millisecond
+	defaultRetryMaxInterval     = 10 * time.Second
+)
+
+var (
+	errMissingAddresses  = errors.New("redis checkpointer addresses must be provided")
+	errMissingStreamID   = errors.New("redis checkpointer stream id must be provided")
+	errMissingMasterName = errors.New("redis checkpointer master name must be provided in sentinel mode")
+	errClusterDBNotZero  = errors.New("redis checkpointer database must be 0 in cluster mode")
+	errUnsupportedMode   = errors.New("unsupported redis checkpointer mode")
+	errMultipleAddresses = errors.New("redis checkpointer requires a single address in standalone mode")
+	errInvalidKeyTTL     = errors.New("redis checkpointer key ttl can't be negative")
+)
+
+func (c *Config) validate() error {
+	if len(c.Addresses) == 0 {
+		return errMissingAddresses
+	}
+	if c.StreamID == "" {
+		return errMissingStreamID
+	}
+	if c.KeyTTL < 0 {
+		return errInvalidKeyTTL
+	}
+	switch c.mode() {
+	case ModeStandalone:
+		if len(c.Addresses) > 1 {
+			return errMultipleAddresses
+		}
+	case ModeSentinel:
+		if c.MasterName == "" {
+			return errMissingMasterName
+		}
+	case ModeCluster:
+		if c.DB != 0 {
+			return errClusterDBNotZero
+		}
+	default:
+		return fmt.Errorf("%w: %s", errUnsupportedMode, c.Mode)
+	}
+	return nil
+}
+
+// newClient returns the redis client for the configured deployment mode.
+func (c *Config) newClient() (redis.UniversalClient, error) {
+	tlsConfig, err := tls.NewConfig(&c.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("building redis tls config: %w", err)
+	}
+
+	switch c.mode() {
+	case ModeSentinel:
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       c.MasterName,
+			SentinelAddrs:    c.Addresses,
+			SentinelUsername: c.SentinelUsername,
+			SentinelPassword: c.SentinelPassword,
+			Username:         c.Username,
+			Password:         c.Password,
+			DB:               c.DB,
+			TLSConfig:        tlsConfig,
+		}), nil
+	case ModeCluster:
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     c.Addresses,
+			Username:  c.Username,
+			Password:  c.Password,
+			TLSConfig: tlsConfig,
+		}), nil
+	default:
+		return redis.NewClient(&redis.Options{
+			Addr:      c.Addresses[0],
+			Username:  c.Username,
+			Password:  c.Password,
+			DB:        c.DB,
+			TLSConfig: tlsConfig,
+		}), nil
+	}
+}
+
+func (c *Config) mode() Mode {
+	if c.Mode == "" {
+		return ModeStandalone
+	}
+	return c.Mode
+}
+
+func (c *Config) key() string {
+	if c.KeyPrefix != "" {
+		return c.KeyPrefix + c.StreamID
+	}
+	return defaultKeyPrefix + c.StreamID
+}
+
+func (c *Config) commitInterval() time.Duration {
+	if c.CommitInterval > 0 {
+		return c.CommitInterval
+	}
+	return defaultCommitInterval
+}
+
+func (c *Config) maxUncommittedWindow() time.Duration {
+	if c.MaxUncommittedWindow > 0 {
+		return c.MaxUncommittedWindow
+	}
+	return defaultMaxUncommittedWindow
+}
+
+func (c *Config) retryPolicy() *backoff.Config {
+	if c.RetryPolicy.DisableRetries {
+		return &backoff.Config{DisableRetries: true}
+	}
+	if c.RetryPolicy.Constant != nil || c.RetryPolicy.Exponential != nil {
+		return &c.RetryPolicy
+	}
+	return &backoff.Config{
+		Exponential: &backoff.ExponentialConfig{
+			InitialInterval: defaultRetryInitialInterval,
+			MaxInterval:     defaultRetryMaxInterval,
+		},
+	}
+}
@@ -0,0 +1,96 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config Config
+
+		wantErr error
+	}{
+		{
+			name:   "ok - standalone",
+			config: Config{Addresses: []string{"localhost:6379"}, StreamID: "test"},
+		},
+		{
+			name: "ok - sentinel",
+			config: Config{
+				Mode:       ModeSentinel,
+				Addresses:  []string{"sentinel-1:26379", "sentinel-2:26379"},
+				MasterName: "mymaster",
+				StreamID:   "test",
+			},
+		},
+		{
+			name: "ok - cluster",
+			config: Config{
+				Mode:      ModeCluster,
+				Addresses: []string{"node-1:6379", "node-2:6379"},
+				StreamID:  "test",
+			},
+		},
+		{
+			name:    "error - missing addresses",
+			config:  Config{StreamID: "test"},
+			wantErr: errMissingAddresses,
+		},
+		{
+			name:    "error - missing stream id",
+			config:  Config{Addresses: []string{"localhost:6379"}},
+			wantErr: errMissingStreamID,
+		},
+		{
+			name:    "error - negative key ttl",
+			config:  Config{Addresses: []string{"localhost:6379"}, StreamID: "test", KeyTTL: -1},
+			wantErr: errInvalidKeyTTL,
+		},
+		{
+			name:    "error - multiple standalone addresses",
+			config:  Config{Addresses: []string{"node-1:6379", "node-2:6379"}, StreamID: "test"},
+			wantErr: errMultipleAddresses,
+		},
+		{
+			name:    "error - sentinel without master name",
+			config:  Config{Mode: ModeSentinel, Addresses: []string{"sentinel-1:26379"}, StreamID: "test"},
+			wantErr: errMissingMasterName,
+		},
+		{
+			name:    "error - cluster with database",
+			config:  Config{Mode: ModeCluster, Addresses: []string{"node-1:6379"}, StreamID: "test", DB: 1},
+			wantErr: errClusterDBNotZero,
+		},
+		{
+			name:    "error - unsupported mode",
+			config:  Config{Mode: "invalid", Addresses: []string{"localhost:6379"}, StreamID: "test"},
+			wantErr: errUnsupportedMode,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.config.validate()
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestConfig_key(t *testing.T) {
+	t.Parallel()
+
+	cfg := Config{StreamID: "test"}
+	require.Equal(t, "pgstream:checkpoint:test", cfg.key())
+
+	cfg.KeyPrefix = "offsets/"
+	require.Equal(t, "offsets/test", cfg.key())
+}
@@ -0,0 +1,287 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/redis/go-redis/v9"
+	"github.com/xataio/pgstream/pkg/backoff"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// Checkpointer is a redis implementation of a wal checkpointer. It commits the
+// last checkpointed LSN of a stream into a redis key on a configurable
+// interval, and only checkpoints the positions to the wrapped checkpoint once
+// they're committed, so that the source is never ahead of redis. Failed
+// commits are retried in the background, and the checkpoints only block when
+// the positions have been uncommitted for longer than the configured window.
+type Checkpointer struct {
+	logger               loglib.Logger
+	client               redisClient
+	key                  string
+	keyTTL               time.Duration
+	commitInterval       time.Duration
+	maxUncommittedWindow time.Duration
+	backoffProvider      backoff.Provider
+	next                 checkpointer.Checkpoint
+	parser               replication.LSNParser
+	clock                clockwork.Clock
+
+	// mutex protects the pending and committed positions, which are updated
+	// on every checkpoint and commit respectively
+	mutex     sync.Mutex
+	pending   replication.LSN
+	committed replication.LSN
+	// uncommittedSince is the time since the oldest uncommitted position was
+	// checkpointed, zero if they're all committed
+	uncommittedSince time.Time
+	// commitChan is closed and replaced on every successful commit, to wake up
+	// the checkpoints blocked on the uncommitted window
+	commitChan chan struct{}
+
+	// commitMutex serialises the commits of the loop and the final one on
+	// close
+	commitMutex sync.Mutex
+	// synced is the last LSN checkpointed to the wrapped checkpoint
+	synced replication.LSN
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type redisClient interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+	Set(ctx context.Context, key string, value any, expiration time.Duration) *redis.StatusCmd
+	Close() error
+}
+
+type Option func(c *Checkpointer)
+
+// New returns a redis checkpointer that commits the LSN to the configured
+// redis deployment, and checkpoints it to the checkpoint on input once it's
+// committed. The last committed LSN is read on creation, so redis must be
+// available.
+func New(ctx context.Context, cfg *Config, next checkpointer.Checkpoint, opts ...Option) (*Checkpointer, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	client, err := cfg.newClient()
+	if err != nil {
+		return nil, err
+	}
+
+	c := newCheckpointer(cfg, client, next)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if err := c.start(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func newCheckpointer(cfg *Config, client redisClient, next checkpointer.Checkpoint) *Checkpointer {
+	return &Checkpointer{
+		logger:               loglib.NewNoopLogger(),
+		client:               client,
+		key:                  cfg.key(),
+		keyTTL:               cfg.KeyTTL,
+		commitInterval:       cfg.commitInterval(),
+		maxUncommittedWindow: cfg.maxUncommittedWindow(),
+		backoffProvider:      backoff.NewProvider(cfg.retryPolicy()),
+		next:                 next,
+		parser:               pgreplication.NewLSNParser(),
+		clock:                clockwork.NewRealClock(),
+		commitChan:           make(chan struct{}),
+	}
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(c *Checkpointer) {
+		c.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_redis_checkpointer",
+		})
+	}
+}
+
+// SyncLSN keeps track of the max LSN of the positions on input, which will be
+// committed on the next interval. It blocks while the positions have been
+// uncommitted for longer than the max uncommitted window, until a commit
+// succeeds or the context is cancelled.
+func (c *Checkpointer) SyncLSN(ctx context.Context, positions []wal.CommitPosition) error {
+	if len(positions) == 0 {
+		return nil
+	}
+
+	// we only need the max pg wal offset
+	var max replication.LSN
+	for _, position := range positions {
+		lsn, err := c.parser.FromString(string(position))
+		if err != nil {
+			return err
+		}
+		if lsn > max {
+			max = lsn
+		}
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if max > c.pending {
+		c.pending = max
+	}
+	if c.pending > c.committed && c.uncommittedSince.IsZero() {
+		c.uncommittedSince = c.clock.Now()
+	}
+
+	for !c.uncommittedSince.IsZero() && c.clock.Since(c.uncommittedSince) > c.maxUncommittedWindow {
+		c.logger.Warn(nil, "redis checkpointer: max uncommitted window reached, checkpoints blocked", loglib.Fields{
+			"uncommitted_since": c.uncommittedSince,
+		})
+		commitChan := c.commitChan
+		c.mutex.Unlock()
+		select {
+		case <-ctx.Done():
+			c.mutex.Lock()
+			return ctx.Err()
+		case <-commitChan:
+		}
+		c.mutex.Lock()
+	}
+	return nil
+}
+
+// LastSyncedLSN returns the LSN committed to redis, or 0 if there's none.
+func (c *Checkpointer) LastSyncedLSN(ctx context.Context) (replication.LSN, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.committed, nil
+}
+
+// Close stops the commit loop, and commits the pending LSN synchronously.
+func (c *Checkpointer) Close() error {
+	c.cancel()
+	c.wg.Wait()
+	commitErr := c.commit(context.Background())
+	if err := c.client.Close(); err != nil {
+		return errors.Join(commitErr, fmt.Errorf("closing redis client: %w", err))
+	}
+	return commitErr
+}
+
+// start reads the committed LSN and starts the commit loop.
+func (c *Checkpointer) start(ctx context.Context) error {
+	lsn, err := c.read(ctx)
+	if err != nil {
+		return err
+	}
+	c.pending = lsn
+	c.committed = lsn
+	c.synced = lsn
+
+	loopCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.wg.Add(1)
+	go c.commitLoop(loopCtx)
+	return nil
+}
+
+func (c *Checkpointer) commitLoop(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := c.clock.NewTicker(c.commitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.Chan():
+			if err := c.commit(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				c.logger.Error(err, "committing redis checkpoint", loglib.Fields{"key": c.key})
+			}
+		}
+	}
+}
+
+// commit writes the pending LSN to redis, retrying with backoff, and
+// checkpoints it to the wrapped checkpoint once it's committed. Failed steps
+// are attempted again on the next commit.
+func (c *Checkpointer) commit(ctx context.Context) error {
+	c.commitMutex.Lock()
+	defer c.commitMutex.Unlock()
+
+	c.mutex.Lock()
+	pending, committed := c.pending, c.committed
+	c.mutex.Unlock()
+
+	if pending > committed {
+		if err := c.write(ctx, pending); err != nil {
+			return err
+		}
+
+		c.mutex.Lock()
+		c.committed = pending
+		c.uncommittedSince = time.Time{}
+		if c.pending > c.committed {
+			// positions checkpointed while committing are now the oldest ones
+			c.uncommittedSince = c.clock.Now()
+		}
+		close(c.commitChan)
+		c.commitChan = make(chan struct{})
+		c.mutex.Unlock()
+		committed = pending
+	}
+
+	if c.next != nil && committed > c.synced {
+		if err := c.next(ctx, []wal.CommitPosition{wal.CommitPosition(c.parser.ToString(committed))}); err != nil {
+			return fmt.Errorf("checkpointing committed position: %w", err)
+		}
+		c.synced = committed
+	}
+
+	return nil
+}
+
+func (c *Checkpointer) write(ctx context.Context, lsn replication.LSN) error {
+	lsnStr := c.parser.ToString(lsn)
+	bo := c.backoffProvider(ctx)
+	err := bo.RetryNotify(func() error {
+		return c.client.Set(ctx, c.key, lsnStr, c.keyTTL).Err()
+	}, func(err error, d time.Duration) {
+		c.logger.Warn(err, "redis checkpointer: commit failed, retrying", loglib.Fields{
+			"key":         c.key,
+			"lsn":         lsnStr,
+			"retry_after": d,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("committing LSN to redis: %w", err)
+	}
+	return nil
+}
+
+// read returns the LSN committed to redis, or 0 if there's none.
+func (c *Checkpointer) read(ctx context.Context) (replication.LSN, error) {
+	lsn, err := c.client.Get(ctx, c.key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("reading committed LSN from redis: %w", err)
+	}
+	return c.parser.FromString(lsn)
+}
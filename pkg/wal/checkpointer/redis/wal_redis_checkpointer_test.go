@@ -0,0 +1,314 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/backoff"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+)
+
+const testKey = "pgstream:checkpoint:test_stream"
+
+// fakeClient is an in memory redis client, which fails the commands while
+// the error is set.
+type fakeClient struct {
+	mutex  sync.Mutex
+	values map[string]string
+	ttls   map[string]time.Duration
+	err    error
+	sets   int
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		values: map[string]string{},
+		ttls:   map[string]time.Duration{},
+	}
+}
+
+func (f *fakeClient) Get(ctx context.Context, key string) *redis.StringCmd {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.err != nil {
+		return redis.NewStringResult("", f.err)
+	}
+	value, found := f.values[key]
+	if !found {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(value, nil)
+}
+
+func (f *fakeClient) Set(ctx context.Context, key string, value any, expiration time.Duration) *redis.StatusCmd {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.sets++
+	if f.err != nil {
+		return redis.NewStatusResult("", f.err)
+	}
+	f.values[key] = value.(string)
+	f.ttls[key] = expiration
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (f *fakeClient) Close() error {
+	return nil
+}
+
+func (f *fakeClient) setErr(err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.err = err
+}
+
+func (f *fakeClient) value(key string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.values[key]
+}
+
+func newTestCheckpointer(t *testing.T, client *fakeClient, clock clockwork.Clock, next func(context.Context, []wal.CommitPosition) error) *Checkpointer {
+	t.Helper()
+	c := newCheckpointer(&Config{
+		StreamID:             "test_stream",
+		KeyTTL:               time.Hour,
+		CommitInterval:       time.Hour,
+		MaxUncommittedWindow: time.Minute,
+		RetryPolicy: backoff.Config{
+			Constant: &backoff.ConstantConfig{Interval: time.Millisecond, MaxRetries: 2},
+		},
+	}, client, next)
+	if clock != nil {
+		c.clock = clock
+	}
+	require.NoError(t, c.start(context.Background()))
+	return c
+}
+
+func TestCheckpointer_start(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name   string
+		client func() *fakeClient
+
+		wantLSN replication.LSN
+		wantErr error
+	}{
+		{
+			name:   "ok - no committed lsn",
+			client: newFakeClient,
+
+			wantLSN: 0,
+		},
+		{
+			name: "ok - committed lsn",
+			client: func() *fakeClient {
+				c := newFakeClient()
+				c.values[testKey] = "1/3"
+				return c
+			},
+
+			wantLSN: replication.LSN(1<<32 + 3),
+		},
+		{
+			name: "error - reading committed lsn",
+			client: func() *fakeClient {
+				c := newFakeClient()
+				c.err = errTest
+				return c
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newCheckpointer(&Config{StreamID: "test_stream"}, tc.client(), nil)
+			err := c.start(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			defer c.Close()
+
+			lsn, err := c.LastSyncedLSN(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, tc.wantLSN, lsn)
+		})
+	}
+}
+
+func TestCheckpointer_commit(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	t.Run("ok - checkpoints once committed", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient()
+		var synced []wal.CommitPosition
+		c := newTestCheckpointer(t, client, nil, func(ctx context.Context, positions []wal.CommitPosition) error {
+			// the position must be committed before it's checkpointed
+			require.Equal(t, string(positions[0]), client.value(testKey))
+			synced = append(synced, positions...)
+			return nil
+		})
+
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/1", "0/3", "0/2"}))
+		require.NoError(t, c.commit(context.Background()))
+		// no new positions, nothing to commit
+		require.NoError(t, c.commit(context.Background()))
+		require.Equal(t, 1, client.sets)
+		require.Equal(t, time.Hour, client.ttls[testKey])
+		require.Equal(t, []wal.CommitPosition{"0/3"}, synced)
+
+		// the pending position is committed on close
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/4"}))
+		require.NoError(t, c.Close())
+		require.Equal(t, []wal.CommitPosition{"0/3", "0/4"}, synced)
+		require.Equal(t, "0/4", client.value(testKey))
+	})
+
+	t.Run("ok - failed commit is retried", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient()
+		c := newTestCheckpointer(t, client, nil, nil)
+		defer c.Close()
+		client.setErr(errTest)
+
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/1"}))
+		require.ErrorIs(t, c.commit(context.Background()), errTest)
+		// the initial attempt and the configured retries
+		require.Equal(t, 3, client.sets)
+
+		client.setErr(nil)
+		require.NoError(t, c.commit(context.Background()))
+		require.Equal(t, "0/1", client.value(testKey))
+	})
+
+	t.Run("ok - commit loop", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient()
+		c := newCheckpointer(&Config{StreamID: "test_stream", CommitInterval: time.Millisecond}, client, nil)
+		require.NoError(t, c.start(context.Background()))
+		defer c.Close()
+
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/1"}))
+		require.Eventually(t, func() bool {
+			return client.value(testKey) == "0/1"
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("error - checkpointing committed position", func(t *testing.T) {
+		t.Parallel()
+
+		calls := 0
+		c := newTestCheckpointer(t, newFakeClient(), nil, func(ctx context.Context, positions []wal.CommitPosition) error {
+			calls++
+			if calls == 1 {
+				return errTest
+			}
+			return nil
+		})
+		defer c.Close()
+
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/1"}))
+		require.ErrorIs(t, c.commit(context.Background()), errTest)
+		require.NoError(t, c.commit(context.Background()))
+		require.Equal(t, 2, calls)
+	})
+}
+
+func TestCheckpointer_SyncLSN(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	t.Run("ok - blocked until committed", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient()
+		clock := clockwork.NewFakeClock()
+		c := newTestCheckpointer(t, client, clock, nil)
+		client.setErr(errTest)
+		defer c.Close()
+
+		// the positions are uncommitted, but within the window
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/1"}))
+		require.ErrorIs(t, c.commit(context.Background()), errTest)
+		clock.Advance(30 * time.Second)
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/2"}))
+
+		// once the window is exceeded, checkpoints block until a commit
+		// succeeds
+		clock.Advance(time.Minute)
+		done := make(chan error, 1)
+		go func() {
+			done <- c.SyncLSN(context.Background(), []wal.CommitPosition{"0/3"})
+		}()
+		select {
+		case err := <-done:
+			t.Fatalf("checkpoint not blocked: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		client.setErr(nil)
+		require.NoError(t, c.commit(context.Background()))
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("checkpoint still blocked after commit")
+		}
+		require.Equal(t, "0/3", client.value(testKey))
+	})
+
+	t.Run("error - context cancelled while blocked", func(t *testing.T) {
+		t.Parallel()
+
+		client := newFakeClient()
+		clock := clockwork.NewFakeClock()
+		c := newTestCheckpointer(t, client, clock, nil)
+		client.setErr(errTest)
+
+		require.NoError(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"0/1"}))
+		clock.Advance(2 * time.Minute)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := c.SyncLSN(ctx, []wal.CommitPosition{"0/2"})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		client.setErr(nil)
+		require.NoError(t, c.Close())
+		require.Equal(t, "0/2", client.value(testKey))
+	})
+
+	t.Run("error - invalid position", func(t *testing.T) {
+		t.Parallel()
+
+		c := newTestCheckpointer(t, newFakeClient(), nil, nil)
+		defer c.Close()
+
+		require.Error(t, c.SyncLSN(context.Background(), []wal.CommitPosition{"invalid"}))
+	})
+}
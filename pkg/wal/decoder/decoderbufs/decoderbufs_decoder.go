@@ -0,0 +1,422 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package decoderbufs decodes the logical replication messages produced by the
+// decoderbufs postgres output plugin (https://github.com/debezium/postgres-decoderbufs),
+// which encodes the table changes using protobuf instead of JSON.
+package decoderbufs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/wal"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Decoder converts the decoderbufs RowMessage protobuf messages into wal data.
+// The messages are decoded directly from the protobuf wire format, following
+// the decoderbufs.proto definition:
+//
+//	message RowMessage {
+//	  optional uint32 transaction_id = 1;
+//	  optional uint64 commit_time = 2;
+//	  optional string table = 3;
+//	  optional Op op = 4;
+//	  repeated DatumMessage new_tuple = 5;
+//	  repeated DatumMessage old_tuple = 6;
+//	  repeated TypeInfo new_typeinfo = 7;
+//	}
+//
+// Unknown fields are ignored, so that messages produced by newer versions of
+// the plugin can still be decoded.
+type Decoder struct {
+	typeNames map[int64]string
+}
+
+// Op is the operation of a decoderbufs RowMessage.
+type Op int32
+
+const (
+	OpUnknown Op = -1
+	OpInsert  Op = 0
+	OpUpdate  Op = 1
+	OpDelete  Op = 2
+	OpBegin   Op = 3
+	OpCommit  Op = 4
+)
+
+// RowMessage fields
+const (
+	rowTransactionIDField protowire.Number = 1
+	rowCommitTimeField    protowire.Number = 2
+	rowTableField         protowire.Number = 3
+	rowOpField            protowire.Number = 4
+	rowNewTupleField      protowire.Number = 5
+	rowOldTupleField      protowire.Number = 6
+)
+
+// DatumMessage fields
+const (
+	datumColumnNameField protowire.Number = 1
+	datumColumnTypeField protowire.Number = 2
+	datumInt32Field      protowire.Number = 3
+	datumInt64Field      protowire.Number = 4
+	datumFloatField      protowire.Number = 5
+	datumDoubleField     protowire.Number = 6
+	datumBoolField       protowire.Number = 7
+	datumStringField     protowire.Number = 8
+	datumBytesField      protowire.Number = 9
+	datumPointField      protowire.Number = 10
+	datumMissingField    protowire.Number = 11
+)
+
+// Point fields
+const (
+	pointXField protowire.Number = 1
+	pointYField protowire.Number = 2
+)
+
+// timestampLayout matches the wal2json timestamp format, so that the events
+// are the same regardless of the output plugin.
+const timestampLayout = "2006-01-02 15:04:05.999999-07"
+
+var (
+	errInvalidMessage  = errors.New("invalid decoderbufs message")
+	errUnsupportedOp   = errors.New("unsupported decoderbufs operation")
+	errInvalidWireType = errors.New("unexpected protobuf wire type")
+)
+
+type rowMessage struct {
+	transactionID uint32
+	commitTime    uint64
+	table         string
+	op            Op
+	newTuple      []datum
+	oldTuple      []datum
+}
+
+type datum struct {
+	columnName string
+	columnType int64
+	value      any
+	// missing is set for the unchanged TOAST values, which are not included
+	// in the message
+	missing bool
+}
+
+// NewDecoder returns a decoderbufs message decoder.
+func NewDecoder() *Decoder {
+	return &Decoder{
+		typeNames: builtinTypeNames,
+	}
+}
+
+// Decode returns the wal data for the decoderbufs RowMessage on input. The
+// begin and commit messages don't contain table changes, and return no data.
+//
+// The message doesn't contain the LSN of the change, which needs to be set by
+// the caller from the replication message position. The column types are
+// identified by their OID, and converted into the postgres type names for the
+// builtin types. The OID is used as type name for any other type. Unchanged
+// TOAST values are not included in the columns.
+func (d *Decoder) Decode(msg []byte) (*wal.Data, error) {
+	row, err := parseRowMessage(msg)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidMessage, err)
+	}
+
+	var action wal.Action
+	switch row.op {
+	case OpInsert:
+		action = wal.ActionInsert
+	case OpUpdate:
+		action = wal.ActionUpdate
+	case OpDelete:
+		action = wal.ActionDelete
+	case OpBegin, OpCommit:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: %d", errUnsupportedOp, row.op)
+	}
+
+	schema, table, err := splitQualifiedTable(row.table)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidMessage, err)
+	}
+
+	data := &wal.Data{
+		Action:   string(action),
+		Schema:   schema,
+		Table:    table,
+		Columns:  d.toColumns(row.newTuple),
+		Identity: d.toColumns(row.oldTuple),
+		XID:      row.transactionID,
+	}
+	if row.commitTime > 0 {
+		data.Timestamp = time.UnixMicro(int64(row.commitTime)).UTC().Format(timestampLayout)
+	}
+	return data, nil
+}
+
+func (d *Decoder) toColumns(tuple []datum) []wal.Column {
+	if len(tuple) == 0 {
+		return nil
+	}
+	columns := make([]wal.Column, 0, len(tuple))
+	for _, datum := range tuple {
+		if datum.missing {
+			continue
+		}
+		columns = append(columns, wal.Column{
+			Name:  datum.columnName,
+			Type:  d.typeName(datum.columnType),
+			Value: datum.value,
+		})
+	}
+	return columns
+}
+
+func (d *Decoder) typeName(oid int64) string {
+	if name, found := d.typeNames[oid]; found {
+		return name
+	}
+	return strconv.FormatInt(oid, 10)
+}
+
+func parseRowMessage(b []byte) (*rowMessage, error) {
+	// the op is an enum with UNKNOWN as first value, which is the proto2
+	// default when it's not set
+	row := &rowMessage{op: OpUnknown}
+	err := parseFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case rowTransactionIDField:
+			v, n, err := consumeVarint(typ, b)
+			row.transactionID = uint32(v)
+			return n, err
+		case rowCommitTimeField:
+			v, n, err := consumeVarint(typ, b)
+			row.commitTime = v
+			return n, err
+		case rowTableField:
+			v, n, err := consumeBytes(typ, b)
+			row.table = string(v)
+			return n, err
+		case rowOpField:
+			v, n, err := consumeVarint(typ, b)
+			row.op = Op(int32(v))
+			return n, err
+		case rowNewTupleField, rowOldTupleField:
+			v, n, err := consumeBytes(typ, b)
+			if err != nil {
+				return n, err
+			}
+			datum, err := parseDatum(v)
+			if err != nil {
+				return n, err
+			}
+			if num == rowNewTupleField {
+				row.newTuple = append(row.newTuple, *datum)
+			} else {
+				row.oldTuple = append(row.oldTuple, *datum)
+			}
+			return n, nil
+		default:
+			return skipField(num, typ, b)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+func parseDatum(b []byte) (*datum, error) {
+	d := &datum{}
+	err := parseFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case datumColumnNameField:
+			v, n, err := consumeBytes(typ, b)
+			d.columnName = string(v)
+			return n, err
+		case datumColumnTypeField:
+			v, n, err := consumeVarint(typ, b)
+			d.columnType = int64(v)
+			return n, err
+		case datumInt32Field:
+			v, n, err := consumeVarint(typ, b)
+			d.value = int32(v)
+			return n, err
+		case datumInt64Field:
+			v, n, err := consumeVarint(typ, b)
+			d.value = int64(v)
+			return n, err
+		case datumFloatField:
+			v, n, err := consumeFixed32(typ, b)
+			d.value = math.Float32frombits(v)
+			return n, err
+		case datumDoubleField:
+			v, n, err := consumeFixed64(typ, b)
+			d.value = math.Float64frombits(v)
+			return n, err
+		case datumBoolField:
+			v, n, err := consumeVarint(typ, b)
+			d.value = protowire.DecodeBool(v)
+			return n, err
+		case datumStringField:
+			v, n, err := consumeBytes(typ, b)
+			d.value = string(v)
+			return n, err
+		case datumBytesField:
+			v, n, err := consumeBytes(typ, b)
+			d.value = append([]byte(nil), v...)
+			return n, err
+		case datumPointField:
+			v, n, err := consumeBytes(typ, b)
+			if err != nil {
+				return n, err
+			}
+			point, err := parsePoint(v)
+			d.value = point
+			return n, err
+		case datumMissingField:
+			v, n, err := consumeVarint(typ, b)
+			d.missing = protowire.DecodeBool(v)
+			return n, err
+		default:
+			return skipField(num, typ, b)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("parsing datum: %w", err)
+	}
+	return d, nil
+}
+
+// parsePoint returns the postgres text representation of the point message on
+// input.
+func parsePoint(b []byte) (string, error) {
+	var x, y float64
+	err := parseFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch num {
+		case pointXField, pointYField:
+			v, n, err := consumeFixed64(typ, b)
+			if num == pointXField {
+				x = math.Float64frombits(v)
+			} else {
+				y = math.Float64frombits(v)
+			}
+			return n, err
+		default:
+			return skipField(num, typ, b)
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("parsing point: %w", err)
+	}
+	return "(" + strconv.FormatFloat(x, 'g', -1, 64) + "," + strconv.FormatFloat(y, 'g', -1, 64) + ")", nil
+}
+
+// parseFields iterates over the fields of the protobuf message on input. The
+// parse function consumes the field value, returning the number of bytes read.
+func parseFields(b []byte, parse func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		n, err := parse(num, typ, b)
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func consumeVarint(typ protowire.Type, b []byte) (uint64, int, error) {
+	if typ != protowire.VarintType {
+		return 0, 0, fmt.Errorf("%w %d, expected varint", errInvalidWireType, typ)
+	}
+	v, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return 0, 0, protowire.ParseError(n)
+	}
+	return v, n, nil
+}
+
+func consumeFixed32(typ protowire.Type, b []byte) (uint32, int, error) {
+	if typ != protowire.Fixed32Type {
+		return 0, 0, fmt.Errorf("%w %d, expected fixed32", errInvalidWireType, typ)
+	}
+	v, n := protowire.ConsumeFixed32(b)
+	if n < 0 {
+		return 0, 0, protowire.ParseError(n)
+	}
+	return v, n, nil
+}
+
+func consumeFixed64(typ protowire.Type, b []byte) (uint64, int, error) {
+	if typ != protowire.Fixed64Type {
+		return 0, 0, fmt.Errorf("%w %d, expected fixed64", errInvalidWireType, typ)
+	}
+	v, n := protowire.ConsumeFixed64(b)
+	if n < 0 {
+		return 0, 0, protowire.ParseError(n)
+	}
+	return v, n, nil
+}
+
+func consumeBytes(typ protowire.Type, b []byte) ([]byte, int, error) {
+	if typ != protowire.BytesType {
+		return nil, 0, fmt.Errorf("%w %d, expected length delimited", errInvalidWireType, typ)
+	}
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return nil, 0, protowire.ParseError(n)
+	}
+	return v, n, nil
+}
+
+func skipField(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+	n := protowire.ConsumeFieldValue(num, typ, b)
+	if n < 0 {
+		return 0, protowire.ParseError(n)
+	}
+	return n, nil
+}
+
+// splitQualifiedTable splits the table name of the message, which is quoted
+// as needed by the plugin (i.e. public."MyTable"), into its schema and table.
+func splitQualifiedTable(qualifiedTable string) (string, string, error) {
+	parts := []string{}
+	var part strings.Builder
+	quoted := false
+	for i := 0; i < len(qualifiedTable); i++ {
+		c := qualifiedTable[i]
+		switch {
+		case c == '"' && quoted && i+1 < len(qualifiedTable) && qualifiedTable[i+1] == '"':
+			// escaped quote within a quoted identifier
+			part.WriteByte('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(c)
+		}
+	}
+	parts = append(parts, part.String())
+
+	if quoted || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("unexpected qualified table name %q", qualifiedTable)
+	}
+	return parts[0], parts[1], nil
+}
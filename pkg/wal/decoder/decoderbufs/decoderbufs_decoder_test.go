@@ -0,0 +1,280 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package decoderbufs
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// test helpers to encode the decoderbufs messages in the protobuf wire format
+
+type testRow struct {
+	transactionID uint32
+	commitTime    uint64
+	table         string
+	op            Op
+	newTuple      [][]byte
+	oldTuple      [][]byte
+	// unknown adds a field not defined in the decoderbufs.proto
+	unknown bool
+}
+
+func (r testRow) encode() []byte {
+	b := []byte{}
+	if r.transactionID > 0 {
+		b = protowire.AppendTag(b, rowTransactionIDField, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(r.transactionID))
+	}
+	if r.commitTime > 0 {
+		b = protowire.AppendTag(b, rowCommitTimeField, protowire.VarintType)
+		b = protowire.AppendVarint(b, r.commitTime)
+	}
+	b = protowire.AppendTag(b, rowTableField, protowire.BytesType)
+	b = protowire.AppendString(b, r.table)
+	b = protowire.AppendTag(b, rowOpField, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(int64(r.op)))
+	for _, datum := range r.newTuple {
+		b = protowire.AppendTag(b, rowNewTupleField, protowire.BytesType)
+		b = protowire.AppendBytes(b, datum)
+	}
+	for _, datum := range r.oldTuple {
+		b = protowire.AppendTag(b, rowOldTupleField, protowire.BytesType)
+		b = protowire.AppendBytes(b, datum)
+	}
+	if r.unknown {
+		b = protowire.AppendTag(b, 100, protowire.BytesType)
+		b = protowire.AppendString(b, "unknown")
+	}
+	return b
+}
+
+func testDatum(name string, oid int64, appendValue func(b []byte) []byte) []byte {
+	b := protowire.AppendTag(nil, datumColumnNameField, protowire.BytesType)
+	b = protowire.AppendString(b, name)
+	b = protowire.AppendTag(b, datumColumnTypeField, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(oid))
+	if appendValue != nil {
+		b = appendValue(b)
+	}
+	return b
+}
+
+func varintValue(num protowire.Number, v uint64) func([]byte) []byte {
+	return func(b []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, v)
+	}
+}
+
+func stringValue(num protowire.Number, v string) func([]byte) []byte {
+	return func(b []byte) []byte {
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendString(b, v)
+	}
+}
+
+func TestDecoder_Decode(t *testing.T) {
+	t.Parallel()
+
+	commitTime := time.Date(2024, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	idDatum := testDatum("id", 20, varintValue(datumInt64Field, 42))
+	nameDatum := testDatum("name", 1043, stringValue(datumStringField, "alice"))
+
+	point := protowire.AppendTag(nil, pointXField, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, math.Float64bits(1.5))
+	point = protowire.AppendTag(point, pointYField, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, math.Float64bits(-2))
+
+	tests := []struct {
+		name string
+		msg  []byte
+
+		wantData *wal.Data
+		wantErr  error
+	}{
+		{
+			name: "ok - insert with all datum types",
+			msg: testRow{
+				transactionID: 770,
+				commitTime:    uint64(commitTime.UnixMicro()),
+				table:         "public.users",
+				op:            OpInsert,
+				newTuple: [][]byte{
+					idDatum,
+					testDatum("age", 23, varintValue(datumInt32Field, negativeVarint(-7))),
+					testDatum("score", 700, func(b []byte) []byte {
+						b = protowire.AppendTag(b, datumFloatField, protowire.Fixed32Type)
+						return protowire.AppendFixed32(b, math.Float32bits(1.25))
+					}),
+					testDatum("ratio", 701, func(b []byte) []byte {
+						b = protowire.AppendTag(b, datumDoubleField, protowire.Fixed64Type)
+						return protowire.AppendFixed64(b, math.Float64bits(0.5))
+					}),
+					testDatum("active", 16, varintValue(datumBoolField, protowire.EncodeBool(true))),
+					nameDatum,
+					testDatum("avatar", 17, func(b []byte) []byte {
+						b = protowire.AppendTag(b, datumBytesField, protowire.BytesType)
+						return protowire.AppendBytes(b, []byte{0xde, 0xad})
+					}),
+					testDatum("location", 600, func(b []byte) []byte {
+						b = protowire.AppendTag(b, datumPointField, protowire.BytesType)
+						return protowire.AppendBytes(b, point)
+					}),
+					testDatum("deleted_at", 1184, nil),
+					testDatum("mood", 16390, stringValue(datumStringField, "happy")),
+				},
+			}.encode(),
+			wantData: &wal.Data{
+				Action:    "I",
+				Timestamp: "2024-01-02 03:04:05.123456+00",
+				Schema:    "public",
+				Table:     "users",
+				XID:       770,
+				Columns: []wal.Column{
+					{Name: "id", Type: "bigint", Value: int64(42)},
+					{Name: "age", Type: "integer", Value: int32(-7)},
+					{Name: "score", Type: "real", Value: float32(1.25)},
+					{Name: "ratio", Type: "double precision", Value: float64(0.5)},
+					{Name: "active", Type: "boolean", Value: true},
+					{Name: "name", Type: "character varying", Value: "alice"},
+					{Name: "avatar", Type: "bytea", Value: []byte{0xde, 0xad}},
+					{Name: "location", Type: "point", Value: "(1.5,-2)"},
+					{Name: "deleted_at", Type: "timestamp with time zone", Value: nil},
+					{Name: "mood", Type: "16390", Value: "happy"},
+				},
+			},
+		},
+		{
+			name: "ok - update with unchanged toast value and unknown fields",
+			msg: testRow{
+				table: `"MySchema"."My""Table"`,
+				op:    OpUpdate,
+				newTuple: [][]byte{
+					idDatum,
+					testDatum("bio", 25, varintValue(datumMissingField, protowire.EncodeBool(true))),
+				},
+				oldTuple: [][]byte{idDatum},
+				unknown:  true,
+			}.encode(),
+			wantData: &wal.Data{
+				Action:   "U",
+				Schema:   "MySchema",
+				Table:    `My"Table`,
+				Columns:  []wal.Column{{Name: "id", Type: "bigint", Value: int64(42)}},
+				Identity: []wal.Column{{Name: "id", Type: "bigint", Value: int64(42)}},
+			},
+		},
+		{
+			name: "ok - delete",
+			msg: testRow{
+				table:    "public.users",
+				op:       OpDelete,
+				oldTuple: [][]byte{idDatum, nameDatum},
+			}.encode(),
+			wantData: &wal.Data{
+				Action: "D",
+				Schema: "public",
+				Table:  "users",
+				Identity: []wal.Column{
+					{Name: "id", Type: "bigint", Value: int64(42)},
+					{Name: "name", Type: "character varying", Value: "alice"},
+				},
+			},
+		},
+		{
+			name:     "ok - begin",
+			msg:      testRow{op: OpBegin, transactionID: 770}.encode(),
+			wantData: nil,
+		},
+		{
+			name:     "ok - commit",
+			msg:      testRow{op: OpCommit, transactionID: 770}.encode(),
+			wantData: nil,
+		},
+		{
+			name:    "error - unknown op",
+			msg:     testRow{table: "public.users", op: OpUnknown}.encode(),
+			wantErr: errUnsupportedOp,
+		},
+		{
+			name:    "error - missing op",
+			msg:     stringValue(rowTableField, "public.users")(nil),
+			wantErr: errUnsupportedOp,
+		},
+		{
+			name:    "error - truncated message",
+			msg:     testRow{table: "public.users", op: OpInsert, newTuple: [][]byte{nameDatum}}.encode()[:20],
+			wantErr: errInvalidMessage,
+		},
+		{
+			name:    "error - unexpected wire type",
+			msg:     stringValue(rowOpField, "insert")(nil),
+			wantErr: errInvalidWireType,
+		},
+		{
+			name:    "error - unqualified table",
+			msg:     testRow{table: "users", op: OpInsert}.encode(),
+			wantErr: errInvalidMessage,
+		},
+	}
+
+	decoder := NewDecoder()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := decoder.Decode(tc.msg)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantData, data)
+		})
+	}
+}
+
+func Test_splitQualifiedTable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		qualifiedTable string
+
+		wantSchema string
+		wantTable  string
+		wantErr    bool
+	}{
+		{name: "unquoted", qualifiedTable: "public.users", wantSchema: "public", wantTable: "users"},
+		{name: "quoted table", qualifiedTable: `public."Users"`, wantSchema: "public", wantTable: "Users"},
+		{name: "quoted with dot", qualifiedTable: `"my.schema"."my.table"`, wantSchema: "my.schema", wantTable: "my.table"},
+		{name: "escaped quote", qualifiedTable: `public."a""b"`, wantSchema: "public", wantTable: `a"b`},
+		{name: "unqualified", qualifiedTable: "users", wantErr: true},
+		{name: "too many parts", qualifiedTable: "a.b.c", wantErr: true},
+		{name: "unterminated quote", qualifiedTable: `public."users`, wantErr: true},
+		{name: "empty", qualifiedTable: "", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			schema, table, err := splitQualifiedTable(tc.qualifiedTable)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantSchema, schema)
+			require.Equal(t, tc.wantTable, table)
+		})
+	}
+}
+
+// negativeVarint returns the varint encoding of a negative int32, which is
+// sign extended to 64 bits in the protobuf wire format.
+func negativeVarint(v int32) uint64 {
+	return uint64(int64(v))
+}
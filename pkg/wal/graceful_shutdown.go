@@ -0,0 +1,260 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+// GracefulShutdown coordinates the shutdown of a WAL stream, making sure the
+// events that have already been read are processed and checkpointed before the
+// connections are closed. The shutdown stops the WAL reader, waits for the
+// in-flight events, flushes the processors and the checkpoint store, and
+// closes the remaining connections, in that order.
+type GracefulShutdown struct {
+	logger  loglib.Logger
+	timeout time.Duration
+	signals []os.Signal
+	notify  func(c chan<- os.Signal, sig ...os.Signal)
+	stop    func(c chan<- os.Signal)
+	exit    func(code int)
+
+	mutex    sync.Mutex
+	stopping bool
+	inFlight sync.WaitGroup
+	stopped  chan struct{}
+	hooks    map[shutdownStage][]func() error
+
+	once sync.Once
+	err  error
+}
+
+type ShutdownConfig struct {
+	// ShutdownTimeout is the maximum time the shutdown can take after a signal
+	// is received before the process is forced to exit. Defaults to 30s.
+	ShutdownTimeout time.Duration
+}
+
+type ShutdownOption func(*GracefulShutdown)
+
+type shutdownStage uint
+
+const (
+	shutdownStageReader shutdownStage = iota
+	shutdownStageProcessor
+	shutdownStageCheckpoint
+	shutdownStageClose
+)
+
+var shutdownStages = []shutdownStage{
+	shutdownStageReader,
+	shutdownStageProcessor,
+	shutdownStageCheckpoint,
+	shutdownStageClose,
+}
+
+var ErrShuttingDown = errors.New("shutdown in progress, event not processed")
+
+const defaultShutdownTimeout = 30 * time.Second
+
+func (c *ShutdownConfig) shutdownTimeout() time.Duration {
+	if c.ShutdownTimeout > 0 {
+		return c.ShutdownTimeout
+	}
+	return defaultShutdownTimeout
+}
+
+// NewGracefulShutdown returns a shutdown coordinator. The hooks for the
+// components of the stream need to be registered before the shutdown starts.
+func NewGracefulShutdown(cfg *ShutdownConfig, opts ...ShutdownOption) *GracefulShutdown {
+	g := &GracefulShutdown{
+		logger:  loglib.NewNoopLogger(),
+		timeout: cfg.shutdownTimeout(),
+		signals: []os.Signal{syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT},
+		notify:  signal.Notify,
+		stop:    signal.Stop,
+		exit:    os.Exit,
+		stopped: make(chan struct{}),
+		hooks:   map[shutdownStage][]func() error{},
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	return g
+}
+
+func WithShutdownLogger(l loglib.Logger) ShutdownOption {
+	return func(g *GracefulShutdown) {
+		g.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "graceful_shutdown",
+		})
+	}
+}
+
+// WithShutdownSignals overrides the signals that start the shutdown when
+// watched. Defaults to SIGHUP, SIGINT, SIGTERM and SIGQUIT.
+func WithShutdownSignals(signals ...os.Signal) ShutdownOption {
+	return func(g *GracefulShutdown) {
+		g.signals = signals
+	}
+}
+
+// ReaderContext returns a child of the context on input that is cancelled when
+// the shutdown starts. It must be used by the WAL reader, so that it stops
+// producing new events.
+func (g *GracefulShutdown) ReaderContext(ctx context.Context) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		defer cancel()
+		select {
+		case <-g.stopped:
+		case <-ctx.Done():
+		}
+	}()
+	return ctx
+}
+
+// TrackEvents wraps the event processing function on input so that the events
+// being processed when the shutdown starts are waited on. The events are
+// processed with a context that is cancelled by the context on input instead
+// of the reader one, so that the in-flight events are not interrupted when the
+// reader is stopped. Events received once the shutdown has started are
+// rejected.
+func (g *GracefulShutdown) TrackEvents(ctx context.Context, processEvent func(context.Context, *Event) error) func(context.Context, *Event) error {
+	return func(eventCtx context.Context, event *Event) error {
+		g.mutex.Lock()
+		if g.stopping {
+			g.mutex.Unlock()
+			return ErrShuttingDown
+		}
+		g.inFlight.Add(1)
+		g.mutex.Unlock()
+		defer g.inFlight.Done()
+
+		processCtx, cancel := context.WithCancel(context.WithoutCancel(eventCtx))
+		defer cancel()
+		stop := context.AfterFunc(ctx, cancel)
+		defer stop()
+
+		return processEvent(processCtx, event)
+	}
+}
+
+// OnReaderStop registers a hook to stop the WAL reader. It runs before the
+// in-flight events are waited on.
+func (g *GracefulShutdown) OnReaderStop(fn func() error) {
+	g.addHook(shutdownStageReader, fn)
+}
+
+// OnProcessorFlush registers a hook to flush the pending events of a
+// processor. It runs once all the in-flight events have been processed.
+func (g *GracefulShutdown) OnProcessorFlush(fn func() error) {
+	g.addHook(shutdownStageProcessor, fn)
+}
+
+// OnCheckpointFlush registers a hook to flush the checkpoint store. It runs
+// once all the processors have been flushed.
+func (g *GracefulShutdown) OnCheckpointFlush(fn func() error) {
+	g.addHook(shutdownStageCheckpoint, fn)
+}
+
+// OnClose registers a hook to close a connection once the checkpoint store has
+// been flushed.
+func (g *GracefulShutdown) OnClose(fn func() error) {
+	g.addHook(shutdownStageClose, fn)
+}
+
+// Shutdown runs the shutdown sequence. The hooks within a stage run in the
+// reverse order of registration, so that components registered after their
+// dependencies are closed first. It's safe to call multiple times, the
+// sequence only runs once and subsequent calls wait for it to complete.
+func (g *GracefulShutdown) Shutdown() error {
+	g.once.Do(func() {
+		g.logger.Info("shutting down...")
+
+		g.mutex.Lock()
+		g.stopping = true
+		close(g.stopped)
+		g.mutex.Unlock()
+
+		var errs error
+		for _, stage := range shutdownStages {
+			if stage == shutdownStageProcessor {
+				g.logger.Debug("waiting for in-flight events...")
+				g.inFlight.Wait()
+			}
+
+			g.mutex.Lock()
+			hooks := slices.Clone(g.hooks[stage])
+			g.mutex.Unlock()
+			for _, hook := range slices.Backward(hooks) {
+				if err := hook(); err != nil {
+					g.logger.Error(err, "shutdown hook failed")
+					errs = errors.Join(errs, err)
+				}
+			}
+		}
+
+		g.err = errs
+		g.logger.Info("shutdown complete")
+	})
+	return g.err
+}
+
+// Watch blocks until one of the shutdown signals is received or the context is
+// cancelled. On signal, the shutdown sequence is run and the process exits
+// with code 0 once it completes. If it doesn't complete within the shutdown
+// timeout, or a second signal is received, the process is forced to exit with
+// code 1.
+func (g *GracefulShutdown) Watch(ctx context.Context) {
+	sigc := make(chan os.Signal, 1)
+	g.notify(sigc, g.signals...)
+	defer g.stop(sigc)
+
+	select {
+	case <-ctx.Done():
+		return
+	case sig := <-sigc:
+		g.logger.Info("signal received, starting graceful shutdown", loglib.Fields{
+			"signal":  sig.String(),
+			"timeout": g.timeout.String(),
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Shutdown()
+	}()
+
+	timer := time.NewTimer(g.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		g.exit(0)
+	case <-timer.C:
+		g.logger.Error(nil, "shutdown timeout exceeded, forcing exit")
+		g.exit(1)
+	case sig := <-sigc:
+		g.logger.Error(nil, "second signal received, forcing exit", loglib.Fields{"signal": sig.String()})
+		g.exit(1)
+	}
+}
+
+func (g *GracefulShutdown) addHook(stage shutdownStage, fn func() error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.hooks[stage] = append(g.hooks[stage], fn)
+}
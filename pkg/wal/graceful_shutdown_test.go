@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGracefulShutdown(timeout time.Duration) (*GracefulShutdown, chan os.Signal, chan int) {
+	sigc := make(chan os.Signal, 2)
+	exitc := make(chan int, 1)
+	g := NewGracefulShutdown(&ShutdownConfig{ShutdownTimeout: timeout})
+	g.notify = func(c chan<- os.Signal, _ ...os.Signal) {
+		go func() {
+			for sig := range sigc {
+				c <- sig
+			}
+		}()
+	}
+	g.stop = func(chan<- os.Signal) {}
+	g.exit = func(code int) { exitc <- code }
+	return g, sigc, exitc
+}
+
+func waitForExit(t *testing.T, exitc chan int) int {
+	select {
+	case code := <-exitc:
+		return code
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for exit")
+		return -1
+	}
+}
+
+func TestGracefulShutdown_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	g := NewGracefulShutdown(&ShutdownConfig{})
+
+	var mutex sync.Mutex
+	calls := []string{}
+	hook := func(name string, err error) func() error {
+		return func() error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			calls = append(calls, name)
+			return err
+		}
+	}
+
+	g.OnClose(hook("replication handler", nil))
+	g.OnCheckpointFlush(hook("checkpointer", errTest))
+	g.OnProcessorFlush(hook("processor", nil))
+	g.OnProcessorFlush(hook("snapshot processor", nil))
+	g.OnReaderStop(hook("snapshot generator", nil))
+	g.OnReaderStop(hook("listener", nil))
+
+	// the in-flight event is waited on before the processors are flushed
+	eventStarted := make(chan struct{})
+	releaseEvent := make(chan struct{})
+	processEvent := g.TrackEvents(context.Background(), func(context.Context, *Event) error {
+		close(eventStarted)
+		<-releaseEvent
+		hook("event", nil)()
+		return nil
+	})
+	go processEvent(context.Background(), &Event{})
+	<-eventStarted
+
+	shutdownErrs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			shutdownErrs <- g.Shutdown()
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(calls) == 2
+	}, time.Second, 10*time.Millisecond)
+	close(releaseEvent)
+
+	for range 2 {
+		require.ErrorIs(t, <-shutdownErrs, errTest)
+	}
+	require.Equal(t, []string{
+		"listener",
+		"snapshot generator",
+		"event",
+		"snapshot processor",
+		"processor",
+		"checkpointer",
+		"replication handler",
+	}, calls)
+}
+
+func TestGracefulShutdown_TrackEvents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("in-flight event not cancelled by the reader context", func(t *testing.T) {
+		t.Parallel()
+
+		g := NewGracefulShutdown(&ShutdownConfig{})
+		readerCtx := g.ReaderContext(context.Background())
+
+		shutdownDone := make(chan struct{})
+		processEvent := g.TrackEvents(context.Background(), func(ctx context.Context, _ *Event) error {
+			// the shutdown waits for the in-flight event, so it's triggered in
+			// the background
+			go func() {
+				defer close(shutdownDone)
+				g.Shutdown()
+			}()
+			<-readerCtx.Done()
+			return ctx.Err()
+		})
+		require.NoError(t, processEvent(readerCtx, &Event{}))
+		<-shutdownDone
+
+		err := processEvent(readerCtx, &Event{})
+		require.ErrorIs(t, err, ErrShuttingDown)
+	})
+
+	t.Run("event cancelled by the processing context", func(t *testing.T) {
+		t.Parallel()
+
+		g := NewGracefulShutdown(&ShutdownConfig{})
+		ctx, cancel := context.WithCancel(context.Background())
+
+		processEvent := g.TrackEvents(ctx, func(ctx context.Context, _ *Event) error {
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		err := processEvent(context.Background(), &Event{})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestGracefulShutdown_ReaderContext(t *testing.T) {
+	t.Parallel()
+
+	g := NewGracefulShutdown(&ShutdownConfig{})
+	readerCtx := g.ReaderContext(context.Background())
+	require.NoError(t, readerCtx.Err())
+
+	require.NoError(t, g.Shutdown())
+	select {
+	case <-readerCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for reader context to be cancelled")
+	}
+}
+
+func TestGracefulShutdown_Watch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("exits with code 0 once shutdown completes", func(t *testing.T) {
+		t.Parallel()
+
+		g, sigc, exitc := newTestGracefulShutdown(time.Minute)
+		closed := false
+		g.OnClose(func() error {
+			closed = true
+			return nil
+		})
+
+		go g.Watch(context.Background())
+		sigc <- syscall.SIGTERM
+
+		require.Equal(t, 0, waitForExit(t, exitc))
+		require.True(t, closed)
+	})
+
+	t.Run("exits with code 1 on shutdown timeout", func(t *testing.T) {
+		t.Parallel()
+
+		g, sigc, exitc := newTestGracefulShutdown(10 * time.Millisecond)
+		release := make(chan struct{})
+		defer close(release)
+		g.OnClose(func() error {
+			<-release
+			return nil
+		})
+
+		go g.Watch(context.Background())
+		sigc <- syscall.SIGTERM
+
+		require.Equal(t, 1, waitForExit(t, exitc))
+	})
+
+	t.Run("exits with code 1 on second signal", func(t *testing.T) {
+		t.Parallel()
+
+		g, sigc, exitc := newTestGracefulShutdown(time.Minute)
+		hookStarted := make(chan struct{})
+		release := make(chan struct{})
+		defer close(release)
+		g.OnClose(func() error {
+			close(hookStarted)
+			<-release
+			return nil
+		})
+
+		go g.Watch(context.Background())
+		sigc <- syscall.SIGTERM
+		<-hookStarted
+		sigc <- syscall.SIGINT
+
+		require.Equal(t, 1, waitForExit(t, exitc))
+	})
+
+	t.Run("returns when the context is cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		g, _, exitc := newTestGracefulShutdown(time.Minute)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		g.Watch(ctx)
+		require.Empty(t, exitc)
+	})
+}
+
+func TestGracefulShutdown_WithShutdownSignals(t *testing.T) {
+	t.Parallel()
+
+	g := NewGracefulShutdown(&ShutdownConfig{}, WithShutdownSignals(syscall.SIGINT, syscall.SIGTERM))
+	notified := make(chan []os.Signal, 1)
+	g.notify = func(_ chan<- os.Signal, signals ...os.Signal) {
+		notified <- signals
+	}
+	g.stop = func(chan<- os.Signal) {}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Watch(ctx)
+	}()
+
+	require.Equal(t, []os.Signal{syscall.SIGINT, syscall.SIGTERM}, <-notified)
+	cancel()
+	<-done
+}
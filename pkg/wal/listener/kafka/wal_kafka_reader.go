@@ -91,7 +91,9 @@ func (r *Reader) Listen(ctx context.Context) error {
 				return fmt.Errorf("error unmarshaling message value into wal data: %w", err)
 			}
 
-			if err = r.processRecord(ctx, event); err != nil {
+			// continue the trace started by the producer of the message, if
+			// any, when processing the event
+			if err = r.processRecord(kafka.ExtractTraceContext(ctx, msg), event); err != nil {
 				if errors.Is(err, context.Canceled) {
 					return fmt.Errorf("canceled: %w", err)
 				}
@@ -15,6 +15,7 @@ import (
 	kafkamocks "github.com/xataio/pgstream/pkg/kafka/mocks"
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/wal"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestReader_Listen(t *testing.T) {
@@ -74,6 +75,29 @@ func TestReader_Listen(t *testing.T) {
 
 			wantErr: context.Canceled,
 		},
+		{
+			name: "ok - trace context propagated",
+			reader: func(doneChan chan struct{}) *kafkamocks.Reader {
+				var once sync.Once
+				return &kafkamocks.Reader{
+					FetchMessageFn: func(ctx context.Context) (*kafka.Message, error) {
+						defer once.Do(func() { doneChan <- struct{}{} })
+						msg := *testMessage
+						msg.Headers = []kafka.Header{{Key: "traceparent", Value: []byte("00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01")}}
+						return &msg, nil
+					},
+				}
+			},
+			processRecord: func(ctx context.Context, d *wal.Event) error {
+				spanContext := trace.SpanContextFromContext(ctx)
+				require.True(t, spanContext.IsRemote())
+				require.Equal(t, "0102030405060708090a0b0c0d0e0f10", spanContext.TraceID().String())
+				require.Equal(t, &testWalEvent, d)
+				return nil
+			},
+
+			wantErr: context.Canceled,
+		},
 		{
 			name: "error - fetching message",
 			reader: func(doneChan chan struct{}) *kafkamocks.Reader {
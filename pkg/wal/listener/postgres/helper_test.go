@@ -58,3 +58,13 @@ type mockGenerator struct {
 func (m *mockGenerator) CreateSnapshot(ctx context.Context) error {
 	return m.createSnapshotFn(ctx)
 }
+
+type mockPublicationRefresher struct {
+	refreshFn func(context.Context) (bool, error)
+	calls     uint
+}
+
+func (m *mockPublicationRefresher) RefreshPublication(ctx context.Context) (bool, error) {
+	m.calls++
+	return m.refreshFn(ctx)
+}
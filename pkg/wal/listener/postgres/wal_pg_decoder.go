@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+)
+
+// wal2json output format versions supported by the listener. Format version 2
+// is the default, and its records were already decoded before format version
+// 1 was supported. Format version 1 transactions are translated into the same
+// per change events, so the processors don't depend on the configured format.
+const (
+	formatVersion1 = 1
+	formatVersion2 = 2
+)
+
+// wal2json format version 2 actions for the records that don't represent a
+// table change.
+const (
+	beginAction   = "B"
+	commitAction  = "C"
+	messageAction = "M"
+)
+
+// wal2json format version 1 change kinds.
+const (
+	insertKind   = "insert"
+	updateKind   = "update"
+	deleteKind   = "delete"
+	truncateKind = "truncate"
+	messageKind  = "message"
+)
+
+// transactionContext keeps track of the transaction the wal2json format
+// version 2 records belong to, assembled from its begin record.
+type transactionContext struct {
+	timestamp string
+	lsn       string
+	xid       uint32
+}
+
+// wal2jsonV1Transaction is a wal2json format version 1 message, which
+// contains all the changes of a transaction.
+type wal2jsonV1Transaction struct {
+	Timestamp string             `json:"timestamp"`
+	NextLSN   string             `json:"nextlsn"`
+	Changes   []wal2jsonV1Change `json:"change"`
+}
+
+type wal2jsonV1Change struct {
+	Kind         string             `json:"kind"`
+	Schema       string             `json:"schema"`
+	Table        string             `json:"table"`
+	ColumnNames  []string           `json:"columnnames"`
+	ColumnTypes  []string           `json:"columntypes"`
+	ColumnValues []any              `json:"columnvalues"`
+	OldKeys      *wal2jsonV1OldKeys `json:"oldkeys"`
+}
+
+type wal2jsonV1OldKeys struct {
+	KeyNames  []string `json:"keynames"`
+	KeyTypes  []string `json:"keytypes"`
+	KeyValues []any    `json:"keyvalues"`
+}
+
+var v1KindActions = map[string]string{
+	insertKind:   "I",
+	updateKind:   "U",
+	deleteKind:   "D",
+	truncateKind: "T",
+}
+
+// decodeWALData returns the table changes contained in the replication
+// message on input, according to the configured output plugin and format
+// version. Messages that don't contain table changes, such as the transaction
+// begin/commit records, return no data. The lsn is the position of the
+// replication message, used for the plugins that don't include it in the
+// message.
+func (l *Listener) decodeWALData(msg []byte, lsn replication.LSN) ([]*wal.Data, error) {
+	if l.decoderbufs != nil {
+		return l.decodeDecoderbufs(msg, lsn)
+	}
+	if l.formatVersion == formatVersion1 {
+		return l.decodeFormatV1(msg)
+	}
+	return l.decodeFormatV2(msg)
+}
+
+// decodeDecoderbufs parses a decoderbufs message, which contains a single
+// change. The begin and commit messages don't contain table changes, and
+// return no data.
+func (l *Listener) decodeDecoderbufs(msg []byte, lsn replication.LSN) ([]*wal.Data, error) {
+	data, err := l.decoderbufs.Decode(msg)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	data.LSN = l.lsnParser.ToString(lsn)
+	return []*wal.Data{data}, nil
+}
+
+// decodeFormatV2 parses a wal2json format version 2 message, which contains a
+// single record. The begin and commit records are used to keep track of the
+// transaction context, which is used to complete the change records.
+func (l *Listener) decodeFormatV2(msg []byte) ([]*wal.Data, error) {
+	data := &wal.Data{}
+	if err := l.walDataDeserialiser(msg, data); err != nil {
+		return nil, err
+	}
+
+	switch data.Action {
+	case beginAction:
+		l.currentTx = &transactionContext{
+			timestamp: data.Timestamp,
+			lsn:       data.LSN,
+			xid:       data.XID,
+		}
+		return nil, nil
+	case commitAction:
+		l.currentTx = nil
+		if l.commitEvents {
+			return []*wal.Data{data}, nil
+		}
+		return nil, nil
+	case messageAction:
+		// logical decoding messages are not replicated
+		return nil, nil
+	}
+
+	if l.currentTx != nil {
+		if data.Timestamp == "" {
+			data.Timestamp = l.currentTx.timestamp
+		}
+		if data.LSN == "" {
+			data.LSN = l.currentTx.lsn
+		}
+		if data.XID == 0 {
+			data.XID = l.currentTx.xid
+		}
+	}
+
+	return []*wal.Data{data}, nil
+}
+
+// decodeFormatV1 parses a wal2json format version 1 message, which contains
+// all the changes of a transaction. Since the changes don't have their own
+// LSN, they're all assigned the transaction end LSN.
+func (l *Listener) decodeFormatV1(msg []byte) ([]*wal.Data, error) {
+	tx := &wal2jsonV1Transaction{}
+	if err := l.walDataDeserialiser(msg, tx); err != nil {
+		return nil, err
+	}
+
+	dataList := make([]*wal.Data, 0, len(tx.Changes))
+	for _, change := range tx.Changes {
+		if change.Kind == messageKind {
+			continue
+		}
+
+		action, found := v1KindActions[change.Kind]
+		if !found {
+			return nil, fmt.Errorf("unsupported wal2json change kind: %q", change.Kind)
+		}
+
+		columns, err := v1Columns(change.ColumnNames, change.ColumnTypes, change.ColumnValues)
+		if err != nil {
+			return nil, fmt.Errorf("parsing columns of %s.%s: %w", change.Schema, change.Table, err)
+		}
+
+		var identity []wal.Column
+		if change.OldKeys != nil {
+			identity, err = v1Columns(change.OldKeys.KeyNames, change.OldKeys.KeyTypes, change.OldKeys.KeyValues)
+			if err != nil {
+				return nil, fmt.Errorf("parsing identity of %s.%s: %w", change.Schema, change.Table, err)
+			}
+		}
+
+		dataList = append(dataList, &wal.Data{
+			Action:    action,
+			Timestamp: tx.Timestamp,
+			LSN:       tx.NextLSN,
+			Schema:    change.Schema,
+			Table:     change.Table,
+			Columns:   columns,
+			Identity:  identity,
+		})
+	}
+
+	return dataList, nil
+}
+
+func v1Columns(names, types []string, values []any) ([]wal.Column, error) {
+	if len(names) != len(types) || len(names) != len(values) {
+		return nil, fmt.Errorf("mismatched column names (%d), types (%d) and values (%d)", len(names), len(types), len(values))
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	columns := make([]wal.Column, 0, len(names))
+	for i := range names {
+		columns = append(columns, wal.Column{
+			Name:  names[i],
+			Type:  types[i],
+			Value: values[i],
+		})
+	}
+	return columns, nil
+}
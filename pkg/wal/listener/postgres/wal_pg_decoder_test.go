@@ -0,0 +1,357 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/decoder/decoderbufs"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestListener_decodeFormatV2(t *testing.T) {
+	t.Parallel()
+
+	l := &Listener{
+		walDataDeserialiser: json.Unmarshal,
+		formatVersion:       formatVersion2,
+	}
+
+	messages := []struct {
+		name string
+		msg  string
+
+		wantData []*wal.Data
+	}{
+		{
+			name: "change outside transaction",
+			msg:  `{"action":"I","timestamp":"2024-01-01 10:00:00.000000+00","lsn":"0/1","schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":1}]}`,
+
+			wantData: []*wal.Data{
+				{
+					Action:    "I",
+					Timestamp: "2024-01-01 10:00:00.000000+00",
+					LSN:       "0/1",
+					Schema:    "public",
+					Table:     "users",
+					Columns:   []wal.Column{{Name: "id", Type: "integer", Value: float64(1)}},
+				},
+			},
+		},
+		{
+			name: "begin",
+			msg:  `{"action":"B","timestamp":"2024-01-01 11:00:00.000000+00","lsn":"0/2","nextlsn":"0/5"}`,
+
+			wantData: nil,
+		},
+		{
+			name: "update completed with transaction context",
+			msg:  `{"action":"U","schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":1}],"identity":[{"name":"id","type":"integer","value":1}]}`,
+
+			wantData: []*wal.Data{
+				{
+					Action:    "U",
+					Timestamp: "2024-01-01 11:00:00.000000+00",
+					LSN:       "0/2",
+					Schema:    "public",
+					Table:     "users",
+					Columns:   []wal.Column{{Name: "id", Type: "integer", Value: float64(1)}},
+					Identity:  []wal.Column{{Name: "id", Type: "integer", Value: float64(1)}},
+				},
+			},
+		},
+		{
+			name: "message",
+			msg:  `{"action":"M","transactional":true,"prefix":"test","content":"hello"}`,
+
+			wantData: nil,
+		},
+		{
+			name: "truncate",
+			msg:  `{"action":"T","lsn":"0/3","schema":"public","table":"users"}`,
+
+			wantData: []*wal.Data{
+				{
+					Action:    "T",
+					Timestamp: "2024-01-01 11:00:00.000000+00",
+					LSN:       "0/3",
+					Schema:    "public",
+					Table:     "users",
+				},
+			},
+		},
+		{
+			name: "commit",
+			msg:  `{"action":"C","timestamp":"2024-01-01 11:00:00.000000+00","lsn":"0/4","nextlsn":"0/5"}`,
+
+			wantData: nil,
+		},
+		{
+			name: "delete after commit",
+			msg:  `{"action":"D","schema":"public","table":"users","identity":[{"name":"id","type":"integer","value":1}]}`,
+
+			wantData: []*wal.Data{
+				{
+					Action:   "D",
+					Schema:   "public",
+					Table:    "users",
+					Identity: []wal.Column{{Name: "id", Type: "integer", Value: float64(1)}},
+				},
+			},
+		},
+	}
+
+	// the messages are decoded in order, since the transaction context is
+	// kept between them
+	for _, m := range messages {
+		dataList, err := l.decodeWALData([]byte(m.msg), 0)
+		require.NoError(t, err, m.name)
+		require.Equal(t, m.wantData, dataList, m.name)
+	}
+}
+
+func TestListener_decodeFormatV2_commitEvents(t *testing.T) {
+	t.Parallel()
+
+	l := &Listener{
+		walDataDeserialiser: json.Unmarshal,
+		formatVersion:       formatVersion2,
+		commitEvents:        true,
+	}
+
+	messages := []struct {
+		name string
+		msg  string
+
+		wantData []*wal.Data
+	}{
+		{
+			name: "begin",
+			msg:  `{"action":"B","xid":1234,"timestamp":"2024-01-01 11:00:00.000000+00","lsn":"0/2","nextlsn":"0/5"}`,
+
+			wantData: nil,
+		},
+		{
+			name: "insert stamped with the transaction id",
+			msg:  `{"action":"I","lsn":"0/3","schema":"public","table":"users","columns":[{"name":"id","type":"integer","value":1}]}`,
+
+			wantData: []*wal.Data{
+				{
+					Action:    "I",
+					Timestamp: "2024-01-01 11:00:00.000000+00",
+					LSN:       "0/3",
+					Schema:    "public",
+					Table:     "users",
+					Columns:   []wal.Column{{Name: "id", Type: "integer", Value: float64(1)}},
+					XID:       1234,
+				},
+			},
+		},
+		{
+			name: "commit",
+			msg:  `{"action":"C","xid":1234,"timestamp":"2024-01-01 11:00:00.000000+00","lsn":"0/4","nextlsn":"0/5"}`,
+
+			wantData: []*wal.Data{
+				{
+					Action:    "C",
+					Timestamp: "2024-01-01 11:00:00.000000+00",
+					LSN:       "0/4",
+					XID:       1234,
+				},
+			},
+		},
+	}
+
+	for _, m := range messages {
+		dataList, err := l.decodeWALData([]byte(m.msg), 0)
+		require.NoError(t, err, m.name)
+		require.Equal(t, m.wantData, dataList, m.name)
+	}
+}
+
+func TestListener_decodeFormatV1(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		msg  string
+
+		wantData   []*wal.Data
+		wantErrMsg string
+	}{
+		{
+			name: "ok - transaction",
+			msg: `{"timestamp":"2024-01-01 10:00:00.000000+00","nextlsn":"0/10","change":[
+				{"kind":"insert","schema":"public","table":"users","columnnames":["id","name"],"columntypes":["integer","text"],"columnvalues":[1,"alice"]},
+				{"kind":"message","transactional":true,"prefix":"test","content":"hello"},
+				{"kind":"update","schema":"public","table":"users","columnnames":["id","name"],"columntypes":["integer","text"],"columnvalues":[1,"bob"],"oldkeys":{"keynames":["id"],"keytypes":["integer"],"keyvalues":[1]}},
+				{"kind":"delete","schema":"public","table":"users","oldkeys":{"keynames":["id"],"keytypes":["integer"],"keyvalues":[1]}},
+				{"kind":"truncate","schema":"public","table":"users"}
+			]}`,
+
+			wantData: []*wal.Data{
+				{
+					Action:    "I",
+					Timestamp: "2024-01-01 10:00:00.000000+00",
+					LSN:       "0/10",
+					Schema:    "public",
+					Table:     "users",
+					Columns: []wal.Column{
+						{Name: "id", Type: "integer", Value: float64(1)},
+						{Name: "name", Type: "text", Value: "alice"},
+					},
+				},
+				{
+					Action:    "U",
+					Timestamp: "2024-01-01 10:00:00.000000+00",
+					LSN:       "0/10",
+					Schema:    "public",
+					Table:     "users",
+					Columns: []wal.Column{
+						{Name: "id", Type: "integer", Value: float64(1)},
+						{Name: "name", Type: "text", Value: "bob"},
+					},
+					Identity: []wal.Column{{Name: "id", Type: "integer", Value: float64(1)}},
+				},
+				{
+					Action:    "D",
+					Timestamp: "2024-01-01 10:00:00.000000+00",
+					LSN:       "0/10",
+					Schema:    "public",
+					Table:     "users",
+					Identity:  []wal.Column{{Name: "id", Type: "integer", Value: float64(1)}},
+				},
+				{
+					Action:    "T",
+					Timestamp: "2024-01-01 10:00:00.000000+00",
+					LSN:       "0/10",
+					Schema:    "public",
+					Table:     "users",
+				},
+			},
+		},
+		{
+			name: "ok - empty transaction",
+			msg:  `{"timestamp":"2024-01-01 10:00:00.000000+00","nextlsn":"0/10","change":[]}`,
+
+			wantData: []*wal.Data{},
+		},
+		{
+			name: "error - unsupported kind",
+			msg:  `{"change":[{"kind":"unknown","schema":"public","table":"users"}]}`,
+
+			wantErrMsg: `unsupported wal2json change kind: "unknown"`,
+		},
+		{
+			name: "error - mismatched columns",
+			msg:  `{"change":[{"kind":"insert","schema":"public","table":"users","columnnames":["id","name"],"columntypes":["integer"],"columnvalues":[1]}]}`,
+
+			wantErrMsg: "parsing columns of public.users: mismatched column names (2), types (1) and values (1)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := &Listener{
+				walDataDeserialiser: json.Unmarshal,
+				formatVersion:       formatVersion1,
+			}
+
+			dataList, err := l.decodeWALData([]byte(tc.msg), 0)
+			if tc.wantErrMsg != "" {
+				require.EqualError(t, err, tc.wantErrMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantData, dataList)
+		})
+	}
+}
+
+func TestListener_decodeDecoderbufs(t *testing.T) {
+	t.Parallel()
+
+	// decoderbufs RowMessage with the transaction id (1), table (3), op (4)
+	// and a new tuple datum (5) with the column name (1), type oid (2) and
+	// int32 value (3)
+	rowMessage := func(op decoderbufs.Op) []byte {
+		datum := protowire.AppendTag(nil, 1, protowire.BytesType)
+		datum = protowire.AppendString(datum, "id")
+		datum = protowire.AppendTag(datum, 2, protowire.VarintType)
+		datum = protowire.AppendVarint(datum, 23)
+		datum = protowire.AppendTag(datum, 3, protowire.VarintType)
+		datum = protowire.AppendVarint(datum, 1)
+
+		b := protowire.AppendTag(nil, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1234)
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, `"public"."users"`)
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(op))
+		if op == decoderbufs.OpInsert {
+			b = protowire.AppendTag(b, 5, protowire.BytesType)
+			b = protowire.AppendBytes(b, datum)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name string
+		msg  []byte
+
+		wantData   []*wal.Data
+		wantErrMsg string
+	}{
+		{
+			name: "ok - insert",
+			msg:  rowMessage(decoderbufs.OpInsert),
+
+			wantData: []*wal.Data{
+				{
+					Action:  "I",
+					Schema:  "public",
+					Table:   "users",
+					LSN:     testLSNStr,
+					XID:     1234,
+					Columns: []wal.Column{{Name: "id", Type: "integer", Value: int32(1)}},
+				},
+			},
+		},
+		{
+			name: "ok - begin",
+			msg:  rowMessage(decoderbufs.OpBegin),
+
+			wantData: nil,
+		},
+		{
+			name: "error - invalid message",
+			msg:  []byte{0xff},
+
+			wantErrMsg: "invalid decoderbufs message: unexpected EOF",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := &Listener{
+				lsnParser:   newMockLSNParser(),
+				decoderbufs: decoderbufs.NewDecoder(),
+			}
+
+			dataList, err := l.decodeWALData(tc.msg, testLSN)
+			if tc.wantErrMsg != "" {
+				require.ErrorContains(t, err, tc.wantErrMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantData, dataList)
+		})
+	}
+}
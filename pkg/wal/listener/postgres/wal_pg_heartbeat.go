@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// Heartbeat periodically writes to a heartbeat table in the source database,
+// so that the replication slot keeps advancing on databases that are mostly
+// idle. Without it, the confirmed flush LSN would never move forward when
+// there are no decodable changes, and the source would retain WAL forever.
+type Heartbeat struct {
+	logger        loglib.Logger
+	querier       pglib.Querier
+	interval      time.Duration
+	schema        string
+	table         string
+	forwardEvents bool
+}
+
+type HeartbeatConfig struct {
+	// Interval at which the heartbeat table is written. Defaults to 30
+	// seconds.
+	Interval time.Duration
+	// TableName is the qualified name of the heartbeat table in the source
+	// database. It will be created if it doesn't exist. Defaults to
+	// pgstream.heartbeat. Tables without a schema will use the public schema.
+	TableName string
+	// ForwardEvents will send the heartbeat events downstream instead of
+	// swallowing them in the listener.
+	ForwardEvents bool
+}
+
+type HeartbeatOption func(h *Heartbeat)
+
+const (
+	defaultHeartbeatInterval  = 30 * time.Second
+	defaultHeartbeatTableName = "pgstream.heartbeat"
+	defaultSchemaName         = "public"
+)
+
+func NewHeartbeat(ctx context.Context, pgURL string, cfg HeartbeatConfig, opts ...HeartbeatOption) (*Heartbeat, error) {
+	qualifiedName, err := pglib.NewQualifiedName(cfg.QualifiedTableName())
+	if err != nil {
+		return nil, fmt.Errorf("parsing heartbeat table name: %w", err)
+	}
+
+	pool, err := pglib.NewConnPool(ctx, pgURL)
+	if err != nil {
+		return nil, fmt.Errorf("creating heartbeat postgres connection pool: %w", err)
+	}
+
+	h := &Heartbeat{
+		logger:        loglib.NewNoopLogger(),
+		querier:       pool,
+		interval:      cfg.interval(),
+		schema:        qualifiedName.Schema(),
+		table:         qualifiedName.Name(),
+		forwardEvents: cfg.ForwardEvents,
+	}
+	if h.schema == "" {
+		h.schema = defaultSchemaName
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h, nil
+}
+
+func WithHeartbeatLogger(logger loglib.Logger) HeartbeatOption {
+	return func(h *Heartbeat) {
+		h.logger = loglib.NewLogger(logger).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_postgres_heartbeat",
+		})
+	}
+}
+
+// Init creates the heartbeat table if it doesn't exist.
+func (h *Heartbeat) Init(ctx context.Context) error {
+	if _, err := h.querier.Exec(ctx, h.createTableQuery()); err != nil {
+		return fmt.Errorf("creating heartbeat table %s: %w", h.quotedTableName(), err)
+	}
+	return nil
+}
+
+// Run writes to the heartbeat table periodically until the context is
+// cancelled.
+func (h *Heartbeat) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.beat(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close closes the heartbeat internal resources.
+func (h *Heartbeat) Close() error {
+	return h.querier.Close(context.Background())
+}
+
+// isHeartbeatEvent returns true if the wal data on input was produced by a
+// heartbeat write.
+func (h *Heartbeat) isHeartbeatEvent(d *wal.Data) bool {
+	return d != nil && d.Schema == h.schema && d.Table == h.table
+}
+
+func (h *Heartbeat) beat(ctx context.Context) {
+	if _, err := h.querier.Exec(ctx, h.writeQuery()); err != nil {
+		h.logger.Warn(err, "writing replication heartbeat", loglib.Fields{
+			"heartbeat_table": h.quotedTableName(),
+		})
+		return
+	}
+	h.logger.Trace("replication heartbeat written")
+}
+
+func (h *Heartbeat) createTableQuery() string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (id int PRIMARY KEY, last_heartbeat timestamptz NOT NULL)", h.quotedTableName())
+}
+
+func (h *Heartbeat) writeQuery() string {
+	return fmt.Sprintf("INSERT INTO %s (id, last_heartbeat) VALUES (1, now()) ON CONFLICT (id) DO UPDATE SET last_heartbeat = EXCLUDED.last_heartbeat", h.quotedTableName())
+}
+
+func (h *Heartbeat) quotedTableName() string {
+	return pglib.QuoteQualifiedIdentifier(h.schema, h.table)
+}
+
+func (c *HeartbeatConfig) interval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return defaultHeartbeatInterval
+}
+
+// QualifiedTableName returns the configured heartbeat table name, or the
+// default one if not provided.
+func (c *HeartbeatConfig) QualifiedTableName() string {
+	if c.TableName != "" {
+		return c.TableName
+	}
+	return defaultHeartbeatTableName
+}
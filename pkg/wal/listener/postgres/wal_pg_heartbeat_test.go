@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+)
+
+func newTestHeartbeat(querier pglib.Querier, forwardEvents bool) *Heartbeat {
+	return &Heartbeat{
+		logger:        loglib.NewNoopLogger(),
+		querier:       querier,
+		interval:      time.Millisecond,
+		schema:        "pgstream",
+		table:         "heartbeat",
+		forwardEvents: forwardEvents,
+	}
+}
+
+func TestHeartbeat_Init(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name    string
+		execErr error
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+		},
+		{
+			name:    "error - creating table",
+			execErr: errTest,
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := newTestHeartbeat(&pgmocks.Querier{
+				ExecFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.CommandTag, error) {
+					require.Equal(t, `CREATE TABLE IF NOT EXISTS "pgstream"."heartbeat" (id int PRIMARY KEY, last_heartbeat timestamptz NOT NULL)`, query)
+					return pglib.CommandTag{}, tc.execErr
+				},
+			}, false)
+
+			err := h.Init(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestHeartbeat_Run(t *testing.T) {
+	t.Parallel()
+
+	beats := make(chan struct{}, 10)
+	h := newTestHeartbeat(&pgmocks.Querier{
+		ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+			require.Equal(t, `INSERT INTO "pgstream"."heartbeat" (id, last_heartbeat) VALUES (1, now()) ON CONFLICT (id) DO UPDATE SET last_heartbeat = EXCLUDED.last_heartbeat`, query)
+			// errors writing the heartbeat should not stop the heartbeat
+			if i == 1 {
+				return pglib.CommandTag{}, errors.New("oh noes")
+			}
+			select {
+			case beats <- struct{}{}:
+			default:
+			}
+			return pglib.CommandTag{}, nil
+		},
+	}, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Run(ctx)
+	}()
+
+	select {
+	case <-beats:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for heartbeat")
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for heartbeat to stop")
+	}
+}
+
+func TestListener_processWALEvent_heartbeat(t *testing.T) {
+	t.Parallel()
+
+	heartbeatData := wal.Data{Action: "U", Schema: "pgstream", Table: "heartbeat"}
+	tableData := wal.Data{Action: "I", Schema: "public", Table: "test"}
+
+	tests := []struct {
+		name          string
+		data          wal.Data
+		forwardEvents bool
+
+		wantEvent *wal.Event
+	}{
+		{
+			name: "heartbeat event is swallowed",
+			data: heartbeatData,
+
+			wantEvent: &wal.Event{CommitPosition: wal.CommitPosition(testLSNStr)},
+		},
+		{
+			name:          "heartbeat event is forwarded",
+			data:          heartbeatData,
+			forwardEvents: true,
+
+			wantEvent: &wal.Event{Data: &heartbeatData, CommitPosition: wal.CommitPosition(testLSNStr)},
+		},
+		{
+			name: "non heartbeat event",
+			data: tableData,
+
+			wantEvent: &wal.Event{Data: &tableData, CommitPosition: wal.CommitPosition(testLSNStr)},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotEvent *wal.Event
+			l := &Listener{
+				logger:    loglib.NewNoopLogger(),
+				lsnParser: newMockLSNParser(),
+				heartbeat: newTestHeartbeat(&pgmocks.Querier{}, tc.forwardEvents),
+				walDataDeserialiser: func(_ []byte, out any) error {
+					*(out.(*wal.Data)) = tc.data
+					return nil
+				},
+				processEvent: func(_ context.Context, event *wal.Event) error {
+					gotEvent = event
+					return nil
+				},
+			}
+
+			err := l.processWALEvent(context.Background(), &replication.Message{
+				LSN:  testLSN,
+				Data: []byte("test-data"),
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.wantEvent, gotEvent)
+		})
+	}
+}
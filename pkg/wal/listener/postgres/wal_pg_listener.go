@@ -6,11 +6,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/backoff"
 	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
 	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/decoder/decoderbufs"
 	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
 )
 
 // Listener contains the environment for subscribing and listening to
@@ -20,22 +25,47 @@ type Listener struct {
 	logger             loglib.Logger
 	lsnParser          replication.LSNParser
 	snapshotGenerator  snapshotGenerator
+	slotMonitor        *SlotMonitor
+	heartbeat          *Heartbeat
+	publication        publicationRefresher
+	reconnectBackoff   backoff.Provider
+	// standbyStatusInterval is the interval at which the replication progress
+	// is reported to postgres, regardless of the events being processed.
+	standbyStatusInterval time.Duration
 
 	// Function called for processing WAL events.
 	processEvent listenerProcessWalEvent
 
 	walDataDeserialiser func([]byte, any) error
+	// formatVersion is the wal2json format version of the replication
+	// messages.
+	formatVersion int
+	// decoderbufs decodes the replication messages when the slot uses the
+	// decoderbufs output plugin, instead of wal2json.
+	decoderbufs *decoderbufs.Decoder
+	// currentTx is the transaction being received, when the transaction
+	// records are included in the replication messages.
+	currentTx *transactionContext
+	// commitEvents forwards the transaction commit records as commit events.
+	commitEvents bool
+	metrics      *listenerMetrics
 }
 
 type replicationHandler interface {
 	StartReplication(ctx context.Context) error
 	StartReplicationFromLSN(ctx context.Context, lsn replication.LSN) error
 	ReceiveMessage(ctx context.Context) (*replication.Message, error)
+	SendStandbyStatusUpdate(ctx context.Context) error
 	GetCurrentLSN(ctx context.Context) (replication.LSN, error)
 	GetLSNParser() replication.LSNParser
+	ResetConnection(ctx context.Context) error
 	Close() error
 }
 
+type publicationRefresher interface {
+	RefreshPublication(ctx context.Context) (bool, error)
+}
+
 type snapshotGenerator interface {
 	CreateSnapshot(context.Context) error
 }
@@ -45,13 +75,21 @@ type listenerProcessWalEvent func(context.Context, *wal.Event) error
 
 type Option func(l *Listener)
 
+const (
+	defaultReconnectInitialInterval = time.Second
+	defaultReconnectMaxInterval     = 5 * time.Minute
+	defaultStandbyStatusInterval    = 10 * time.Second
+)
+
 func New(handler replicationHandler, processEvent listenerProcessWalEvent, opts ...Option) *Listener {
 	l := &Listener{
-		logger:              loglib.NewNoopLogger(),
-		replicationHandler:  handler,
-		processEvent:        processEvent,
-		walDataDeserialiser: json.Unmarshal,
-		lsnParser:           handler.GetLSNParser(),
+		logger:                loglib.NewNoopLogger(),
+		replicationHandler:    handler,
+		processEvent:          processEvent,
+		walDataDeserialiser:   json.Unmarshal,
+		lsnParser:             handler.GetLSNParser(),
+		standbyStatusInterval: defaultStandbyStatusInterval,
+		formatVersion:         formatVersion2,
 	}
 
 	for _, opt := range opts {
@@ -75,8 +113,107 @@ func WithInitialSnapshot(sg snapshotGenerator) Option {
 	}
 }
 
+// WithPublicationRefresh will refresh the publication tables when a schema
+// change is received, so that the tables created while the listener is
+// running that match the include table list are published. When the decoded
+// tables change, the replication is restarted from the last synced position
+// so that the changes of the new tables are decoded.
+func WithPublicationRefresh(r publicationRefresher) Option {
+	return func(l *Listener) {
+		l.publication = r
+	}
+}
+
+// WithSlotMonitor will run the slot monitor on input while the listener is
+// running.
+func WithSlotMonitor(m *SlotMonitor) Option {
+	return func(l *Listener) {
+		l.slotMonitor = m
+	}
+}
+
+// WithHeartbeat will make the listener write heartbeats to the source
+// database while it's running. Heartbeat events will be swallowed by the
+// listener unless they're configured to be forwarded.
+func WithHeartbeat(h *Heartbeat) Option {
+	return func(l *Listener) {
+		l.heartbeat = h
+	}
+}
+
+// WithReconnect will make the listener re-establish the replication
+// connection when it's lost, using the backoff policy on input. The
+// replication is resumed from the last checkpointed position, so events that
+// have been processed but not checkpointed yet will be received again. If the
+// policy is not set, a default exponential backoff policy is applied. It
+// shouldn't be used with a replication handler retrier, which already resets
+// the connection when receiving a message fails.
+func WithReconnect(cfg backoff.Config) Option {
+	return func(l *Listener) {
+		if !cfg.IsSet() {
+			cfg = defaultReconnectBackoffConfig()
+		}
+		l.reconnectBackoff = backoff.NewProvider(&cfg)
+	}
+}
+
+// WithStandbyStatusInterval sets the interval at which the standby status
+// updates are sent to postgres to keep the replication connection alive. It
+// should be lower than the source wal_sender_timeout. Defaults to 10s.
+func WithStandbyStatusInterval(interval time.Duration) Option {
+	return func(l *Listener) {
+		if interval > 0 {
+			l.standbyStatusInterval = interval
+		}
+	}
+}
+
+// WithFormatVersion sets the wal2json format version of the replication
+// messages. Defaults to format version 2.
+func WithFormatVersion(version int) Option {
+	return func(l *Listener) {
+		if version > 0 {
+			l.formatVersion = version
+		}
+	}
+}
+
+// WithOutputPlugin sets the output plugin used by the replication slot, which
+// determines how the replication messages are decoded. Defaults to wal2json.
+func WithOutputPlugin(name string) Option {
+	return func(l *Listener) {
+		if name == pgreplication.PluginDecoderbufs {
+			l.decoderbufs = decoderbufs.NewDecoder()
+		}
+	}
+}
+
+// WithCommitEvents will make the listener forward the transaction commit
+// records as commit events, so that processors can identify the end of each
+// transaction. Requires the transaction records to be included in the
+// replication messages.
+func WithCommitEvents() Option {
+	return func(l *Listener) {
+		l.commitEvents = true
+	}
+}
+
 // Listen starts the subscription process to listen for updates from PG.
 func (l *Listener) Listen(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if l.slotMonitor != nil {
+		go l.slotMonitor.Run(ctx)
+	}
+
+	if l.heartbeat != nil {
+		if err := l.heartbeat.Init(ctx); err != nil {
+			return err
+		}
+		go l.heartbeat.Run(ctx)
+	}
+
 	if l.snapshotGenerator != nil {
 		if err := l.snapshotAndListen(ctx); err != nil {
 			l.logger.Error(err, "pg snapshot and listen")
@@ -93,6 +230,9 @@ func (l *Listener) Listen(ctx context.Context) error {
 
 // Close closes the listener internal resources
 func (l *Listener) Close() error {
+	if l.heartbeat != nil {
+		return l.heartbeat.Close()
+	}
 	return nil
 }
 
@@ -114,6 +254,14 @@ func (l *Listener) snapshotAndListen(ctx context.Context) error {
 }
 
 func (l *Listener) listen(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// the standby status updates are sent independently of the message
+	// processing, so that slow events don't make postgres terminate the
+	// replication connection.
+	go l.sendStandbyStatusUpdates(ctx)
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -124,6 +272,12 @@ func (l *Listener) listen(ctx context.Context) error {
 				if errors.Is(err, replication.ErrConnTimeout) {
 					continue
 				}
+				if l.isReconnectable(err) {
+					if reconnectErr := l.reconnect(ctx, err); reconnectErr != nil {
+						return fmt.Errorf("receiving message: %w: reconnecting: %w", err, reconnectErr)
+					}
+					continue
+				}
 				return fmt.Errorf("receiving message: %w", err)
 			}
 
@@ -131,10 +285,16 @@ func (l *Listener) listen(ctx context.Context) error {
 				continue
 			}
 
+			// reply straight away when postgres requests it, without waiting
+			// for the keep alive to be processed
+			if msg.ReplyRequested {
+				l.sendStandbyStatusUpdate(ctx)
+			}
+
 			l.logger.Trace("", loglib.Fields{
-				"wal_end":     l.lsnParser.ToString(msg.LSN),
-				"server_time": msg.ServerTime,
-				"wal_data":    msg.Data,
+				loglib.LSNField: l.lsnParser.ToString(msg.LSN),
+				"server_time":   msg.ServerTime,
+				"wal_data":      msg.Data,
 			})
 
 			if err := l.processWALEvent(ctx, msg); err != nil {
@@ -144,6 +304,77 @@ func (l *Listener) listen(ctx context.Context) error {
 	}
 }
 
+// reconnect re-establishes the replication connection after it's been lost,
+// retrying with the configured backoff policy. The replication handler resumes
+// the replication from the last checkpointed position. If the replication slot
+// is still in use by the backend of the previous connection, it will keep
+// retrying until it's released.
+func (l *Listener) reconnect(ctx context.Context, connErr error) error {
+	l.logger.Warn(connErr, "replication connection lost, reconnecting")
+
+	bo := l.reconnectBackoff(ctx)
+	err := bo.RetryNotify(func() error {
+		err := l.replicationHandler.ResetConnection(ctx)
+		if err != nil && errors.Is(err, context.Canceled) {
+			return fmt.Errorf("%w: %w", err, backoff.ErrPermanent)
+		}
+		return err
+	}, func(err error, d time.Duration) {
+		if errors.Is(err, replication.ErrSlotInUse) {
+			l.logger.Warn(err, "replication slot still in use by another backend, retrying reconnect", loglib.Fields{
+				"retry_delay": d.String(),
+			})
+			return
+		}
+		l.logger.Warn(err, "retrying replication reconnect after error", loglib.Fields{
+			"retry_delay": d.String(),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	l.logger.Info("replication connection re-established")
+	return nil
+}
+
+// sendStandbyStatusUpdates reports the replication progress to postgres at the
+// configured interval until the context is cancelled.
+func (l *Listener) sendStandbyStatusUpdates(ctx context.Context) {
+	ticker := time.NewTicker(l.standbyStatusInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.sendStandbyStatusUpdate(ctx)
+		}
+	}
+}
+
+func (l *Listener) sendStandbyStatusUpdate(ctx context.Context) {
+	// errors are not fatal, since a broken connection will be detected when
+	// receiving the next message
+	if err := l.replicationHandler.SendStandbyStatusUpdate(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		l.logger.Warn(err, "sending standby status update")
+	}
+}
+
+func (l *Listener) isReconnectable(err error) bool {
+	return l.reconnectBackoff != nil && !errors.Is(err, context.Canceled)
+}
+
+func defaultReconnectBackoffConfig() backoff.Config {
+	return backoff.Config{
+		Exponential: &backoff.ExponentialConfig{
+			InitialInterval: defaultReconnectInitialInterval,
+			MaxInterval:     defaultReconnectMaxInterval,
+		},
+	}
+}
+
 func (l *Listener) processWALEvent(ctx context.Context, msg *replication.Message) error {
 	// if there's no data, it's a keep alive. If a reply is not requested,
 	// no need to process this message.
@@ -151,14 +382,68 @@ func (l *Listener) processWALEvent(ctx context.Context, msg *replication.Message
 		return nil
 	}
 
-	event := &wal.Event{}
-	if msg.Data != nil {
-		event.Data = &wal.Data{}
-		if err := l.walDataDeserialiser(msg.Data, event.Data); err != nil {
-			return fmt.Errorf("error unmarshaling wal data: %w", err)
+	commitPosition := wal.CommitPosition(l.lsnParser.ToString(msg.LSN))
+	if msg.Data == nil {
+		return l.processEvent(ctx, &wal.Event{CommitPosition: commitPosition})
+	}
+
+	dataList, err := l.decodeWALData(msg.Data, msg.LSN)
+	if err != nil {
+		return fmt.Errorf("error unmarshaling wal data: %w", err)
+	}
+	// messages without table changes only need to be checkpointed
+	if len(dataList) == 0 {
+		return l.processEvent(ctx, &wal.Event{CommitPosition: commitPosition})
+	}
+	l.metrics.record(ctx, dataList, len(msg.Data))
+
+	schemaChanged := false
+	for _, data := range dataList {
+		if isSchemaLogInsert(data) {
+			schemaChanged = true
+		}
+		event := &wal.Event{
+			Data:           data,
+			CommitPosition: commitPosition,
+		}
+		// heartbeat events only need to be checkpointed, so they're turned
+		// into keep alive events unless they need to be forwarded downstream
+		if l.heartbeat != nil && !l.heartbeat.forwardEvents && l.heartbeat.isHeartbeatEvent(event.Data) {
+			event.Data = nil
+		}
+		if err := l.processEvent(ctx, event); err != nil {
+			return err
 		}
 	}
-	event.CommitPosition = wal.CommitPosition(l.lsnParser.ToString(msg.LSN))
 
-	return l.processEvent(ctx, event)
+	if schemaChanged {
+		return l.refreshPublication(ctx)
+	}
+	return nil
+}
+
+// refreshPublication refreshes the publication tables, restarting the
+// replication if the decoded tables have changed.
+func (l *Listener) refreshPublication(ctx context.Context) error {
+	if l.publication == nil {
+		return nil
+	}
+
+	changed, err := l.publication.RefreshPublication(ctx)
+	if err != nil {
+		return fmt.Errorf("refreshing publication: %w", err)
+	}
+	if !changed {
+		return nil
+	}
+
+	l.logger.Info("publication tables changed, restarting replication")
+	if err := l.replicationHandler.ResetConnection(ctx); err != nil {
+		return fmt.Errorf("restarting replication after publication refresh: %w", err)
+	}
+	return nil
+}
+
+func isSchemaLogInsert(d *wal.Data) bool {
+	return d.Schema == schemalog.SchemaName && d.Table == schemalog.TableName && d.IsInsert()
 }
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type listenerMetrics struct {
+	tables         *otel.TableAttributes
+	receivedEvents metric.Int64Counter
+	receivedBytes  metric.Int64Counter
+}
+
+// WithInstrumentation exports the number of events and bytes received from
+// the replication per table.
+func WithInstrumentation(instrumentation *otel.Instrumentation) Option {
+	return func(l *Listener) {
+		if instrumentation == nil || instrumentation.Meter == nil {
+			return
+		}
+		var err error
+		l.metrics, err = newListenerMetrics(instrumentation.Meter, instrumentation.Tables)
+		if err != nil {
+			// should never happen
+			panic(err)
+		}
+	}
+}
+
+func newListenerMetrics(meter metric.Meter, tables *otel.TableAttributes) (*listenerMetrics, error) {
+	m := &listenerMetrics{tables: tables}
+
+	var err error
+	m.receivedEvents, err = meter.Int64Counter("pgstream.listener.events",
+		metric.WithUnit("events"),
+		metric.WithDescription("Number of wal data events received from the replication"))
+	if err != nil {
+		return nil, err
+	}
+
+	m.receivedBytes, err = meter.Int64Counter("pgstream.listener.bytes",
+		metric.WithUnit("bytes"),
+		metric.WithDescription("Size of the replication messages the wal data events were decoded from"))
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// record reports the data events decoded from a replication message of the
+// size on input. When the message contains multiple events, its size is
+// split evenly between them. Transaction commit events are not table
+// operations, so they're not reported.
+func (m *listenerMetrics) record(ctx context.Context, dataList []*wal.Data, msgSize int) {
+	if m == nil || len(dataList) == 0 {
+		return
+	}
+	eventSize := int64(msgSize / len(dataList))
+	for _, data := range dataList {
+		if wal.Action(data.Action) == wal.ActionCommit {
+			continue
+		}
+		attrs := metric.WithAttributes(m.tables.Get(data.Schema, data.Table)...)
+		m.receivedEvents.Add(ctx, 1, attrs)
+		m.receivedBytes.Add(ctx, eventSize, attrs)
+	}
+}
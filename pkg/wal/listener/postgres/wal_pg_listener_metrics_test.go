@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestListenerMetrics_record(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	m, err := newListenerMetrics(meterProvider.Meter("test"), otel.NewTableAttributes(1))
+	require.NoError(t, err)
+
+	m.record(context.Background(), []*wal.Data{
+		{Action: "I", Schema: "public", Table: "users"},
+		{Action: "I", Schema: "public", Table: "orders"},
+		{Action: string(wal.ActionCommit)},
+	}, 30)
+	m.record(context.Background(), []*wal.Data{{Action: "U", Schema: "public", Table: "users"}}, 5)
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	values := map[string]map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			values[m.Name] = map[string]int64{}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				schemaName, _ := dp.Attributes.Value(attribute.Key(otel.SchemaAttributeKey))
+				tableName, _ := dp.Attributes.Value(attribute.Key(otel.TableAttributeKey))
+				values[m.Name][schemaName.AsString()+"."+tableName.AsString()] = dp.Value
+			}
+		}
+	}
+
+	require.Equal(t, map[string]map[string]int64{
+		"pgstream.listener.events": {"public.users": 2, "other.other": 1},
+		"pgstream.listener.bytes":  {"public.users": 15, "other.other": 10},
+	}, values)
+
+	// nil metrics are a noop
+	var nilMetrics *listenerMetrics
+	nilMetrics.record(context.Background(), []*wal.Data{{Schema: "public", Table: "users"}}, 1)
+}
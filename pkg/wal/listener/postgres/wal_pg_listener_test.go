@@ -11,7 +11,9 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/backoff"
 	"github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/replication"
 	replicationmocks "github.com/xataio/pgstream/pkg/wal/replication/mocks"
@@ -78,6 +80,38 @@ func TestListener_Listen(t *testing.T) {
 
 			wantErr: context.Canceled,
 		},
+		{
+			name: "ok - transaction record checkpointed",
+			replicationHandler: func(doneChan chan struct{}) *replicationmocks.Handler {
+				h := newMockReplicationHandler()
+				h.ReceiveMessageFn = func(ctx context.Context, i uint64) (*replication.Message, error) {
+					defer func() {
+						if i == 1 {
+							doneChan <- struct{}{}
+						}
+					}()
+					switch i {
+					case 1:
+						return newMockMessage(), nil
+					default:
+						return emptyMessage, nil
+					}
+				}
+				return h
+			},
+			processEventFn: func(_ context.Context, event *wal.Event) error {
+				require.Equal(t, &wal.Event{
+					CommitPosition: wal.CommitPosition(testLSNStr),
+				}, event)
+				return nil
+			},
+			deserialiser: func(_ []byte, out any) error {
+				*(out.(*wal.Data)) = wal.Data{Action: "B"}
+				return nil
+			},
+
+			wantErr: context.Canceled,
+		},
 		{
 			name: "ok - with initial snapshot",
 			replicationHandler: func(doneChan chan struct{}) *replicationmocks.Handler {
@@ -385,3 +419,267 @@ func TestListener_Listen(t *testing.T) {
 		})
 	}
 }
+
+func TestListener_Listen_reconnect(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	errConnLost := errors.New("connection lost")
+	testReconnectPolicy := backoff.Config{
+		Constant: &backoff.ConstantConfig{
+			Interval:   time.Millisecond,
+			MaxRetries: 3,
+		},
+	}
+
+	tests := []struct {
+		name              string
+		reconnectPolicy   *backoff.Config
+		resetConnectionFn func(ctx context.Context, i uint) error
+
+		wantResetCalls uint
+		wantErr        error
+	}{
+		{
+			name:            "ok - connection re-established",
+			reconnectPolicy: &testReconnectPolicy,
+			resetConnectionFn: func(ctx context.Context, i uint) error {
+				return nil
+			},
+
+			wantResetCalls: 1,
+			wantErr:        context.Canceled,
+		},
+		{
+			name:            "ok - replication slot in use, retried",
+			reconnectPolicy: &testReconnectPolicy,
+			resetConnectionFn: func(ctx context.Context, i uint) error {
+				if i == 1 {
+					return fmt.Errorf("startReplication: %w", replication.ErrSlotInUse)
+				}
+				return nil
+			},
+
+			wantResetCalls: 2,
+			wantErr:        context.Canceled,
+		},
+		{
+			name:            "error - reconnect retries exhausted",
+			reconnectPolicy: &testReconnectPolicy,
+			resetConnectionFn: func(ctx context.Context, i uint) error {
+				return errTest
+			},
+
+			wantResetCalls: 4,
+			wantErr:        errTest,
+		},
+		{
+			name:            "error - reconnect disabled",
+			reconnectPolicy: nil,
+
+			wantResetCalls: 0,
+			wantErr:        errConnLost,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			var resetCalls uint
+			h := newMockReplicationHandler()
+			h.ResetConnectionFn = func(ctx context.Context) error {
+				resetCalls++
+				return tc.resetConnectionFn(ctx, resetCalls)
+			}
+			h.ReceiveMessageFn = func(ctx context.Context, i uint64) (*replication.Message, error) {
+				switch i {
+				case 1:
+					return nil, errConnLost
+				case 2:
+					return newMockMessage(), nil
+				default:
+					return nil, errors.New("unexpected call to receive message")
+				}
+			}
+
+			opts := []Option{WithLogger(log.NewNoopLogger())}
+			if tc.reconnectPolicy != nil {
+				opts = append(opts, WithReconnect(*tc.reconnectPolicy))
+			}
+
+			l := New(h, func(ctx context.Context, e *wal.Event) error {
+				// the message received after reconnecting is processed
+				cancel()
+				return nil
+			}, opts...)
+			l.walDataDeserialiser = func(b []byte, a any) error { return nil }
+			l.lsnParser = newMockLSNParser()
+
+			err := l.listen(ctx)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantResetCalls, resetCalls)
+		})
+	}
+}
+
+func TestListener_Listen_standbyStatusUpdates(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok - status updates sent while processing a slow event", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		const wantStatusUpdates = 3
+		statusUpdatesSent := make(chan struct{})
+		h := newMockReplicationHandler()
+		h.SendStandbyStatusUpdateFn = func(ctx context.Context, i uint64) error {
+			if i == wantStatusUpdates {
+				close(statusUpdatesSent)
+			}
+			return nil
+		}
+		h.ReceiveMessageFn = func(ctx context.Context, i uint64) (*replication.Message, error) {
+			if i == 1 {
+				return newMockMessage(), nil
+			}
+			return nil, errors.New("unexpected call to receive message")
+		}
+
+		l := New(h, func(ctx context.Context, e *wal.Event) error {
+			// the processing doesn't finish until the status updates have
+			// been sent
+			defer cancel()
+			select {
+			case <-statusUpdatesSent:
+			case <-ctx.Done():
+				t.Error("timeout waiting for standby status updates")
+			}
+			return nil
+		}, WithLogger(log.NewNoopLogger()), WithStandbyStatusInterval(time.Millisecond))
+		l.walDataDeserialiser = func(b []byte, a any) error { return nil }
+		l.lsnParser = newMockLSNParser()
+
+		err := l.listen(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+		require.GreaterOrEqual(t, h.GetSendStandbyStatusUpdateCalls(), uint64(wantStatusUpdates))
+	})
+
+	t.Run("ok - keep alive with reply requested replied before processing", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		h := newMockReplicationHandler()
+		h.ReceiveMessageFn = func(ctx context.Context, i uint64) (*replication.Message, error) {
+			if i == 1 {
+				return newMockKeepAliveMessage(true), nil
+			}
+			return nil, errors.New("unexpected call to receive message")
+		}
+
+		l := New(h, func(ctx context.Context, e *wal.Event) error {
+			defer cancel()
+			require.Equal(t, uint64(1), h.GetSendStandbyStatusUpdateCalls())
+			return nil
+		}, WithLogger(log.NewNoopLogger()), WithStandbyStatusInterval(time.Hour))
+		l.lsnParser = newMockLSNParser()
+
+		err := l.listen(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestListener_processWALEvent_publicationRefresh(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	schemaLogInsert := &wal.Data{Action: "I", Schema: schemalog.SchemaName, Table: schemalog.TableName}
+	tableInsert := &wal.Data{Action: "I", Schema: "public", Table: "users"}
+
+	tests := []struct {
+		name      string
+		data      *wal.Data
+		refreshFn func(ctx context.Context) (bool, error)
+
+		wantRefreshCalls uint
+		wantResetCalls   uint
+		wantErr          error
+	}{
+		{
+			name: "ok - decoded tables changed",
+			data: schemaLogInsert,
+			refreshFn: func(ctx context.Context) (bool, error) {
+				return true, nil
+			},
+
+			wantRefreshCalls: 1,
+			wantResetCalls:   1,
+		},
+		{
+			name: "ok - decoded tables unchanged",
+			data: schemaLogInsert,
+			refreshFn: func(ctx context.Context) (bool, error) {
+				return false, nil
+			},
+
+			wantRefreshCalls: 1,
+			wantResetCalls:   0,
+		},
+		{
+			name: "ok - not a schema change",
+			data: tableInsert,
+			refreshFn: func(ctx context.Context) (bool, error) {
+				return false, errors.New("unexpected call to refresh publication")
+			},
+
+			wantRefreshCalls: 0,
+			wantResetCalls:   0,
+		},
+		{
+			name: "error - refreshing publication",
+			data: schemaLogInsert,
+			refreshFn: func(ctx context.Context) (bool, error) {
+				return false, errTest
+			},
+
+			wantRefreshCalls: 1,
+			wantResetCalls:   0,
+			wantErr:          errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var resetCalls uint
+			h := newMockReplicationHandler()
+			h.ResetConnectionFn = func(ctx context.Context) error {
+				resetCalls++
+				return nil
+			}
+
+			refresher := &mockPublicationRefresher{refreshFn: tc.refreshFn}
+			l := New(h, func(ctx context.Context, e *wal.Event) error {
+				return nil
+			}, WithLogger(log.NewNoopLogger()), WithPublicationRefresh(refresher))
+			l.walDataDeserialiser = func(b []byte, a any) error {
+				*(a.(*wal.Data)) = *tc.data
+				return nil
+			}
+			l.lsnParser = newMockLSNParser()
+
+			err := l.processWALEvent(context.Background(), newMockMessage())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantRefreshCalls, refresher.calls)
+			require.Equal(t, tc.wantResetCalls, resetCalls)
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+)
+
+// SlotMonitor periodically checks the state of the replication slot, warning
+// when the WAL retained on the source is at risk of filling the disk or
+// invalidating the slot.
+type SlotMonitor struct {
+	logger           loglib.Logger
+	statusRetriever  slotStatusRetriever
+	checkInterval    time.Duration
+	retainedWALLimit int64
+	pauseSnapshots   bool
+
+	overLimit atomic.Bool
+}
+
+type SlotMonitorConfig struct {
+	// CheckInterval is the interval at which the replication slot status is
+	// checked. Defaults to 1 minute.
+	CheckInterval time.Duration
+	// RetainedWALLimitBytes is the hard limit of WAL retained by the slot on
+	// the source. When reached, pgstream will log loudly. Zero disables the
+	// limit.
+	RetainedWALLimitBytes int64
+	// PauseSnapshotsOnLimit will pause new snapshot table work while the
+	// retained WAL limit is exceeded, to prioritise draining the slot.
+	PauseSnapshotsOnLimit bool
+}
+
+type slotStatusRetriever interface {
+	GetReplicationSlotStatus(ctx context.Context) (*replication.SlotStatus, error)
+	GetReplicationSlotName() string
+}
+
+type SlotMonitorOption func(m *SlotMonitor)
+
+const (
+	defaultSlotCheckInterval = time.Minute
+
+	walStatusUnreserved = "unreserved"
+	walStatusLost       = "lost"
+)
+
+func NewSlotMonitor(cfg SlotMonitorConfig, retriever slotStatusRetriever, opts ...SlotMonitorOption) *SlotMonitor {
+	m := &SlotMonitor{
+		logger:           loglib.NewNoopLogger(),
+		statusRetriever:  retriever,
+		checkInterval:    cfg.checkInterval(),
+		retainedWALLimit: cfg.RetainedWALLimitBytes,
+		pauseSnapshots:   cfg.PauseSnapshotsOnLimit,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+func WithSlotMonitorLogger(logger loglib.Logger) SlotMonitorOption {
+	return func(m *SlotMonitor) {
+		m.logger = loglib.NewLogger(logger).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_postgres_slot_monitor",
+		})
+	}
+}
+
+// Run checks the replication slot status periodically until the context is
+// cancelled.
+func (m *SlotMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		m.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsOverLimit returns true if the last check found the retained WAL limit was
+// exceeded.
+func (m *SlotMonitor) IsOverLimit() bool {
+	return m.overLimit.Load()
+}
+
+// WaitForCapacity blocks while the retained WAL limit is exceeded, if pausing
+// snapshots is enabled. It returns an error if the context is cancelled while
+// waiting.
+func (m *SlotMonitor) WaitForCapacity(ctx context.Context) error {
+	if !m.pauseSnapshots {
+		return nil
+	}
+
+	for m.IsOverLimit() {
+		m.logger.Warn(nil, "retained WAL limit exceeded, pausing snapshot work until the replication slot is drained")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.checkInterval):
+		}
+	}
+	return nil
+}
+
+func (m *SlotMonitor) check(ctx context.Context) {
+	status, err := m.statusRetriever.GetReplicationSlotStatus(ctx)
+	if err != nil {
+		m.logger.Warn(err, "checking replication slot status")
+		return
+	}
+
+	logFields := loglib.Fields{
+		"slot_name":                 m.statusRetriever.GetReplicationSlotName(),
+		"active":                    status.Active,
+		"retained_wal_bytes":        status.RetainedWALBytes,
+		"confirmed_flush_lag_bytes": status.ConfirmedFlushLagBytes,
+		"wal_status":                status.WALStatus,
+		"safe_wal_size_bytes":       status.SafeWALSizeBytes,
+		"max_slot_wal_keep_size":    status.MaxSlotWALKeepSizeBytes,
+	}
+	m.logger.Debug("replication slot status", logFields)
+
+	switch status.WALStatus {
+	case walStatusUnreserved:
+		m.logger.Error(nil, "replication slot WAL is no longer reserved, the slot will be invalidated unless it catches up before the next checkpoint", logFields)
+	case walStatusLost:
+		m.logger.Error(nil, "replication slot has been invalidated by the source, required WAL files have been removed", logFields)
+	}
+
+	if m.retainedWALLimit <= 0 {
+		return
+	}
+
+	if status.RetainedWALBytes >= m.retainedWALLimit {
+		logFields["retained_wal_limit_bytes"] = m.retainedWALLimit
+		m.logger.Error(nil, "replication slot retained WAL limit exceeded, the source WAL disk is at risk", logFields)
+		m.overLimit.Store(true)
+		return
+	}
+
+	if m.overLimit.Swap(false) {
+		m.logger.Info("replication slot retained WAL back under the limit", logFields)
+	}
+}
+
+func (c *SlotMonitorConfig) checkInterval() time.Duration {
+	if c.CheckInterval > 0 {
+		return c.CheckInterval
+	}
+	return defaultSlotCheckInterval
+}
@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	replicationmocks "github.com/xataio/pgstream/pkg/wal/replication/mocks"
+)
+
+func TestSlotMonitor_check(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name             string
+		status           *replication.SlotStatus
+		statusErr        error
+		retainedWALLimit int64
+		initialOverLimit bool
+
+		wantOverLimit bool
+	}{
+		{
+			name:             "under limit",
+			status:           &replication.SlotStatus{RetainedWALBytes: 10, WALStatus: "reserved"},
+			retainedWALLimit: 100,
+
+			wantOverLimit: false,
+		},
+		{
+			name:             "over limit",
+			status:           &replication.SlotStatus{RetainedWALBytes: 100, WALStatus: "extended"},
+			retainedWALLimit: 100,
+
+			wantOverLimit: true,
+		},
+		{
+			name:             "back under limit",
+			status:           &replication.SlotStatus{RetainedWALBytes: 10, WALStatus: "reserved"},
+			retainedWALLimit: 100,
+			initialOverLimit: true,
+
+			wantOverLimit: false,
+		},
+		{
+			name:             "limit disabled",
+			status:           &replication.SlotStatus{RetainedWALBytes: 1000, WALStatus: "unreserved"},
+			retainedWALLimit: 0,
+
+			wantOverLimit: false,
+		},
+		{
+			name:             "error retrieving status keeps previous state",
+			statusErr:        errTest,
+			retainedWALLimit: 100,
+			initialOverLimit: true,
+
+			wantOverLimit: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := NewSlotMonitor(SlotMonitorConfig{
+				RetainedWALLimitBytes: tc.retainedWALLimit,
+			}, &replicationmocks.Handler{
+				GetReplicationSlotStatusFn: func(context.Context) (*replication.SlotStatus, error) {
+					return tc.status, tc.statusErr
+				},
+			})
+			m.overLimit.Store(tc.initialOverLimit)
+
+			m.check(context.Background())
+			require.Equal(t, tc.wantOverLimit, m.IsOverLimit())
+		})
+	}
+}
+
+func TestSlotMonitor_WaitForCapacity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		pauseSnapshots bool
+		overLimit      bool
+		ctxTimeout     time.Duration
+
+		wantErr error
+	}{
+		{
+			name:           "pause disabled",
+			pauseSnapshots: false,
+			overLimit:      true,
+
+			wantErr: nil,
+		},
+		{
+			name:           "under limit",
+			pauseSnapshots: true,
+			overLimit:      false,
+
+			wantErr: nil,
+		},
+		{
+			name:           "over limit until context is cancelled",
+			pauseSnapshots: true,
+			overLimit:      true,
+			ctxTimeout:     10 * time.Millisecond,
+
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := NewSlotMonitor(SlotMonitorConfig{
+				CheckInterval:         time.Millisecond,
+				PauseSnapshotsOnLimit: tc.pauseSnapshots,
+			}, &replicationmocks.Handler{})
+			m.overLimit.Store(tc.overLimit)
+
+			ctx := context.Background()
+			if tc.ctxTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.ctxTimeout)
+				defer cancel()
+			}
+
+			err := m.WaitForCapacity(ctx)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestSlotMonitor_WaitForCapacity_resumes(t *testing.T) {
+	t.Parallel()
+
+	m := NewSlotMonitor(SlotMonitorConfig{
+		CheckInterval:         time.Millisecond,
+		PauseSnapshotsOnLimit: true,
+	}, &replicationmocks.Handler{})
+	m.overLimit.Store(true)
+
+	go func() {
+		time.Sleep(5 * time.Millisecond)
+		m.overLimit.Store(false)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, m.WaitForCapacity(ctx))
+}
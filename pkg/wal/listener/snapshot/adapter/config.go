@@ -4,6 +4,8 @@ package adapter
 
 import (
 	"strings"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
 )
 
 type SnapshotConfig struct {
@@ -11,11 +13,20 @@ type SnapshotConfig struct {
 	ExcludedTables []string
 }
 
-const publicSchema = "public"
+const (
+	publicSchema   = "public"
+	wildcardSchema = "*"
+)
 
 func schemaTableMap(tables []string) map[string][]string {
 	schemaTableMap := make(map[string][]string, len(tables))
 	for _, table := range tables {
+		// regular expressions are matched against the qualified table name,
+		// so they can apply to any schema
+		if pglib.IsTableRegexp(table) {
+			schemaTableMap[wildcardSchema] = append(schemaTableMap[wildcardSchema], table)
+			continue
+		}
 		schemaName := publicSchema
 		tableName := table
 		tableSplit := strings.Split(table, ".")
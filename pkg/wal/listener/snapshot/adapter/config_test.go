@@ -25,6 +25,14 @@ func TestSnapshotConfig_schemaTableMap(t *testing.T) {
 				"test_schema": {"c"},
 			},
 		},
+		{
+			name:   "ok - patterns",
+			tables: []string{"public.data_tenant_*", `^tenant_\d+\.events$`},
+			wantMap: map[string][]string{
+				"public": {"data_tenant_*"},
+				"*":      {`^tenant_\d+\.events$`},
+			},
+		},
 	}
 
 	for _, tc := range tests {
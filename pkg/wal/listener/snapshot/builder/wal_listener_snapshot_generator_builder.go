@@ -12,6 +12,7 @@ import (
 	"github.com/xataio/pgstream/pkg/schemalog"
 	schemaloginstrumentation "github.com/xataio/pgstream/pkg/schemalog/instrumentation"
 	schemalogpg "github.com/xataio/pgstream/pkg/schemalog/postgres"
+	"github.com/xataio/pgstream/pkg/snapshot"
 	"github.com/xataio/pgstream/pkg/snapshot/generator"
 	generatorinstrumentation "github.com/xataio/pgstream/pkg/snapshot/generator/instrumentation"
 	pgsnapshotgenerator "github.com/xataio/pgstream/pkg/snapshot/generator/postgres/data"
@@ -46,8 +47,9 @@ var errSchemaSnapshotNotConfigured = errors.New("no schema snapshot has been con
 // │ │         │  │         │  │        │  │         │ │
 // │ └─────────┘  └─────────┘  └────────┘  └─────────┘ │
 // └───────────────────────────────────────────────────┘
-
-func NewSnapshotGenerator(ctx context.Context, cfg *SnapshotListenerConfig, p listener.Processor, logger loglib.Logger, instrumentation *otel.Instrumentation) (listenersnapshot.Generator, error) {
+//
+// Additional data snapshot generator options can be provided on input.
+func NewSnapshotGenerator(ctx context.Context, cfg *SnapshotListenerConfig, p listener.Processor, logger loglib.Logger, instrumentation *otel.Instrumentation, dataOpts ...pgsnapshotgenerator.Option) (listenersnapshot.Generator, error) {
 	var g generator.SnapshotGenerator
 	var err error
 
@@ -58,10 +60,15 @@ func NewSnapshotGenerator(ctx context.Context, cfg *SnapshotListenerConfig, p li
 		}
 		if !cfg.DisableProgressTracking {
 			opts = append(opts, pgsnapshotgenerator.WithProgressTracking())
+		} else {
+			// without progress bars, the progress is reported per table in
+			// the logs
+			opts = append(opts, pgsnapshotgenerator.WithTableEventHandler(logTableEvent(logger)))
 		}
 		if instrumentation.IsEnabled() {
 			opts = append(opts, pgsnapshotgenerator.WithInstrumentation(instrumentation))
 		}
+		opts = append(opts, dataOpts...)
 		g, err = pgsnapshotgenerator.NewSnapshotGenerator(ctx, cfg.Data, p, opts...)
 		if err != nil {
 			return nil, err
@@ -122,7 +129,10 @@ func newSchemaSnapshotGenerator(ctx context.Context, cfg *SchemaSnapshotConfig,
 		}
 		schemaLogStore = schemalog.NewStoreCache(schemaLogStore)
 		if instrumentation.IsEnabled() {
-			schemaLogStore = schemaloginstrumentation.NewStore(schemaLogStore, instrumentation)
+			schemaLogStore, err = schemaloginstrumentation.NewStore(schemaLogStore, instrumentation)
+			if err != nil {
+				return nil, fmt.Errorf("create instrumented schema log store: %w", err)
+			}
 		}
 		return schemalogsnapshotgenerator.NewSnapshotGenerator(
 			schemaLogStore,
@@ -146,3 +156,17 @@ func newSchemaSnapshotGenerator(ctx context.Context, cfg *SchemaSnapshotConfig,
 		return nil, errSchemaSnapshotNotConfigured
 	}
 }
+
+func logTableEvent(logger loglib.Logger) func(context.Context, *snapshot.TableEvent) {
+	return func(_ context.Context, event *snapshot.TableEvent) {
+		fields := loglib.Fields{"schema": event.Schema, "table": event.Table}
+		switch {
+		case event.Type == snapshot.TableSnapshotStarted:
+			logger.Info("table data snapshot started", fields)
+		case event.Err == nil:
+			// failed table snapshots are already logged by the generator
+			fields["rows"] = event.Rows
+			logger.Info("table data snapshot completed", fields)
+		}
+	}
+}
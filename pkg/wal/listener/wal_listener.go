@@ -20,4 +20,5 @@ type Processor interface {
 	ProcessWALEvent(context.Context, *wal.Event) error
 	Name() string
 	Close() error
+	Ping(context.Context) error
 }
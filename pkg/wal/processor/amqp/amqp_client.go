@@ -0,0 +1,228 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/tls"
+)
+
+// client publishes messages to a topic exchange with publisher confirms. The
+// connection and channel are created lazily, and recreated on the next publish
+// when they're closed or publishing fails, so that the sink recovers from
+// connection drops.
+type client struct {
+	url            string
+	exchange       string
+	amqpConfig     amqp.Config
+	confirmTimeout time.Duration
+	dial           dialFn
+	logger         loglib.Logger
+
+	mutex    sync.Mutex
+	conn     amqpConnection
+	channel  amqpChannel
+	confirms chan amqp.Confirmation
+}
+
+type dialFn func(url string, cfg amqp.Config) (amqpConnection, error)
+
+type amqpConnection interface {
+	Channel() (amqpChannel, error)
+	IsClosed() bool
+	Close() error
+}
+
+type amqpChannel interface {
+	Confirm(noWait bool) error
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+	IsClosed() bool
+	Close() error
+}
+
+// publishing is a message to be published to the exchange with the routing
+// key.
+type publishing struct {
+	routingKey string
+	msg        amqp.Publishing
+}
+
+// maxUnconfirmedMessages is the maximum number of messages published before
+// waiting for their confirmations, which bounds the confirmations buffered by
+// the channel.
+const maxUnconfirmedMessages = 256
+
+var (
+	errMessageNacked = errors.New("message not acknowledged by the broker")
+	errChannelClosed = errors.New("amqp channel closed")
+)
+
+func newClient(cfg *Config, logger loglib.Logger) (*client, error) {
+	tlsConfig, err := tls.NewConfig(&cfg.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("amqp tls config: %w", err)
+	}
+
+	return &client{
+		url:      cfg.URL,
+		exchange: cfg.Exchange,
+		amqpConfig: amqp.Config{
+			TLSClientConfig: tlsConfig,
+			Properties: amqp.Table{
+				"connection_name": "pgstream",
+			},
+		},
+		confirmTimeout: cfg.confirmTimeout(),
+		dial:           dial,
+		logger:         logger,
+	}, nil
+}
+
+// publish publishes the messages to the exchange in order, and waits for the
+// broker to confirm them. If an error is returned, some of the messages might
+// have been published already.
+func (c *client) publish(ctx context.Context, msgs []*publishing) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.ensureChannel(); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(msgs); start += maxUnconfirmedMessages {
+		end := min(start+maxUnconfirmedMessages, len(msgs))
+		if err := c.publishConfirmed(ctx, msgs[start:end]); err != nil {
+			// the pending confirmations can't be matched with the messages
+			// of the next publish, so the channel is recreated
+			c.resetChannel()
+			return err
+		}
+	}
+	return nil
+}
+
+// ping connects to the broker and declares the exchange if needed.
+func (c *client) ping(context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.ensureChannel()
+}
+
+func (c *client) close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var errs error
+	if c.channel != nil {
+		errs = errors.Join(errs, c.channel.Close())
+		c.channel = nil
+	}
+	if c.conn != nil {
+		errs = errors.Join(errs, c.conn.Close())
+		c.conn = nil
+	}
+	return errs
+}
+
+func (c *client) publishConfirmed(ctx context.Context, msgs []*publishing) error {
+	ctx, cancel := context.WithTimeout(ctx, c.confirmTimeout)
+	defer cancel()
+
+	for _, msg := range msgs {
+		if err := c.channel.PublishWithContext(ctx, c.exchange, msg.routingKey, false, false, msg.msg); err != nil {
+			return fmt.Errorf("publishing message with routing key %s: %w", msg.routingKey, err)
+		}
+	}
+
+	for range msgs {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for publisher confirms: %w", ctx.Err())
+		case confirm, ok := <-c.confirms:
+			if !ok {
+				return errChannelClosed
+			}
+			if !confirm.Ack {
+				return fmt.Errorf("%w: delivery tag %d", errMessageNacked, confirm.DeliveryTag)
+			}
+		}
+	}
+	return nil
+}
+
+// ensureChannel opens a new channel in confirm mode if there's none or it's
+// closed, reconnecting to the broker if the connection is closed too.
+func (c *client) ensureChannel() error {
+	if c.channel != nil && !c.channel.IsClosed() {
+		return nil
+	}
+	if c.channel != nil {
+		c.logger.Warn(errChannelClosed, "amqp sink: channel closed, recovering")
+		c.channel = nil
+	}
+
+	if c.conn == nil || c.conn.IsClosed() {
+		conn, err := c.dial(c.url, c.amqpConfig)
+		if err != nil {
+			return fmt.Errorf("connecting to amqp broker: %w", err)
+		}
+		c.conn = conn
+	}
+
+	channel, err := c.conn.Channel()
+	if err != nil {
+		// the connection might be broken even if it's not closed yet, so it's
+		// recreated on the next attempt
+		c.conn.Close()
+		c.conn = nil
+		return fmt.Errorf("opening amqp channel: %w", err)
+	}
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		return fmt.Errorf("enabling publisher confirms: %w", err)
+	}
+	if err := channel.ExchangeDeclare(c.exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		channel.Close()
+		return fmt.Errorf("declaring exchange %s: %w", c.exchange, err)
+	}
+
+	c.channel = channel
+	c.confirms = channel.NotifyPublish(make(chan amqp.Confirmation, maxUnconfirmedMessages))
+	return nil
+}
+
+func (c *client) resetChannel() {
+	if c.channel != nil {
+		c.channel.Close()
+		c.channel = nil
+	}
+	if c.conn != nil && c.conn.IsClosed() {
+		c.conn = nil
+	}
+}
+
+// connection adapts the amqp connection to the amqpConnection interface.
+type connection struct {
+	*amqp.Connection
+}
+
+func dial(url string, cfg amqp.Config) (amqpConnection, error) {
+	conn, err := amqp.DialConfig(url, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &connection{Connection: conn}, nil
+}
+
+func (c *connection) Channel() (amqpChannel, error) {
+	return c.Connection.Channel()
+}
@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package amqp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+type mockConnection struct {
+	channelFn func() (amqpChannel, error)
+	closed    bool
+}
+
+func (m *mockConnection) Channel() (amqpChannel, error) { return m.channelFn() }
+func (m *mockConnection) IsClosed() bool                { return m.closed }
+func (m *mockConnection) Close() error {
+	m.closed = true
+	return nil
+}
+
+type mockChannel struct {
+	confirmErr  error
+	exchangeErr error
+	publishFn   func(deliveryTag uint64, key string, msg amqp.Publishing) error
+	// nackTags are the delivery tags that are not acknowledged
+	nackTags map[uint64]bool
+	// noConfirms disables the publisher confirms
+	noConfirms bool
+
+	closed      bool
+	exchange    string
+	deliveryTag uint64
+	confirms    chan amqp.Confirmation
+	published   []string
+}
+
+func (m *mockChannel) Confirm(bool) error { return m.confirmErr }
+
+func (m *mockChannel) ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, _ amqp.Table) error {
+	if m.exchangeErr != nil {
+		return m.exchangeErr
+	}
+	if kind != amqp.ExchangeTopic || !durable || autoDelete || internal || noWait {
+		return errors.New("unexpected exchange declaration")
+	}
+	m.exchange = name
+	return nil
+}
+
+func (m *mockChannel) NotifyPublish(confirms chan amqp.Confirmation) chan amqp.Confirmation {
+	m.confirms = confirms
+	return confirms
+}
+
+func (m *mockChannel) PublishWithContext(_ context.Context, exchange, key string, _, _ bool, msg amqp.Publishing) error {
+	if exchange != m.exchange {
+		return errors.New("unexpected exchange")
+	}
+	m.deliveryTag++
+	if m.publishFn != nil {
+		if err := m.publishFn(m.deliveryTag, key, msg); err != nil {
+			return err
+		}
+	}
+	m.published = append(m.published, key)
+	if !m.noConfirms {
+		m.confirms <- amqp.Confirmation{DeliveryTag: m.deliveryTag, Ack: !m.nackTags[m.deliveryTag]}
+	}
+	return nil
+}
+
+func (m *mockChannel) IsClosed() bool { return m.closed }
+func (m *mockChannel) Close() error {
+	m.closed = true
+	return nil
+}
+
+func newTestClient(dial dialFn) *client {
+	return &client{
+		url:            "amqp://localhost:5672",
+		exchange:       "pgstream",
+		confirmTimeout: time.Second,
+		dial:           dial,
+		logger:         loglib.NewNoopLogger(),
+	}
+}
+
+func newTestPublishings(keys ...string) []*publishing {
+	msgs := make([]*publishing, 0, len(keys))
+	for _, key := range keys {
+		msgs = append(msgs, &publishing{routingKey: key, msg: amqp.Publishing{Body: []byte(key)}})
+	}
+	return msgs
+}
+
+func TestClient_publish(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	testMsgs := newTestPublishings("public.users.insert", "public.users.update", "public.orders.delete")
+
+	tests := []struct {
+		name    string
+		channel *mockChannel
+		dialErr error
+		timeout time.Duration
+
+		wantPublished []string
+		wantErr       error
+	}{
+		{
+			name:    "ok",
+			channel: &mockChannel{},
+
+			wantPublished: []string{"public.users.insert", "public.users.update", "public.orders.delete"},
+		},
+		{
+			name:    "error - dialing",
+			channel: &mockChannel{},
+			dialErr: errTest,
+
+			wantErr: errTest,
+		},
+		{
+			name:    "error - enabling confirms",
+			channel: &mockChannel{confirmErr: errTest},
+
+			wantErr: errTest,
+		},
+		{
+			name:    "error - declaring exchange",
+			channel: &mockChannel{exchangeErr: errTest},
+
+			wantErr: errTest,
+		},
+		{
+			name: "error - publishing",
+			channel: &mockChannel{publishFn: func(deliveryTag uint64, _ string, _ amqp.Publishing) error {
+				if deliveryTag == 2 {
+					return errTest
+				}
+				return nil
+			}},
+
+			wantErr: errTest,
+		},
+		{
+			name:    "error - message nacked",
+			channel: &mockChannel{nackTags: map[uint64]bool{2: true}},
+
+			wantErr: errMessageNacked,
+		},
+		{
+			name:    "error - confirm timeout",
+			channel: &mockChannel{noConfirms: true},
+			timeout: time.Millisecond,
+
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestClient(func(url string, _ amqp.Config) (amqpConnection, error) {
+				require.Equal(t, "amqp://localhost:5672", url)
+				if tc.dialErr != nil {
+					return nil, tc.dialErr
+				}
+				return &mockConnection{channelFn: func() (amqpChannel, error) { return tc.channel, nil }}, nil
+			})
+			if tc.timeout > 0 {
+				c.confirmTimeout = tc.timeout
+			}
+
+			err := c.publish(context.Background(), testMsgs)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				// the channel is recreated on the next publish
+				require.Nil(t, c.channel)
+				return
+			}
+			require.Equal(t, tc.wantPublished, tc.channel.published)
+			require.Equal(t, "pgstream", tc.channel.exchange)
+		})
+	}
+}
+
+func TestClient_publish_recovery(t *testing.T) {
+	t.Parallel()
+
+	dials := 0
+	channels := []*mockChannel{}
+	conn := &mockConnection{}
+	conn.channelFn = func() (amqpChannel, error) {
+		channel := &mockChannel{}
+		channels = append(channels, channel)
+		return channel, nil
+	}
+	c := newTestClient(func(string, amqp.Config) (amqpConnection, error) {
+		dials++
+		conn.closed = false
+		return conn, nil
+	})
+
+	require.NoError(t, c.publish(context.Background(), newTestPublishings("a")))
+	require.Equal(t, 1, dials)
+	require.Len(t, channels, 1)
+
+	// the channel is closed by the broker, it's reopened on the same
+	// connection
+	channels[0].closed = true
+	require.NoError(t, c.publish(context.Background(), newTestPublishings("b")))
+	require.Equal(t, 1, dials)
+	require.Len(t, channels, 2)
+
+	// the connection drops, the client reconnects
+	channels[1].closed = true
+	conn.closed = true
+	require.NoError(t, c.publish(context.Background(), newTestPublishings("c")))
+	require.Equal(t, 2, dials)
+	require.Len(t, channels, 3)
+	require.Equal(t, []string{"c"}, channels[2].published)
+
+	// the connection is recreated if the channel can't be opened
+	conn.channelFn = func() (amqpChannel, error) { return nil, errors.New("oh noes") }
+	channels[2].closed = true
+	require.Error(t, c.publish(context.Background(), newTestPublishings("d")))
+	require.Nil(t, c.conn)
+
+	require.NoError(t, c.close())
+}
+
+func TestClient_publish_chunks(t *testing.T) {
+	t.Parallel()
+
+	channel := &mockChannel{}
+	c := newTestClient(func(string, amqp.Config) (amqpConnection, error) {
+		return &mockConnection{channelFn: func() (amqpChannel, error) { return channel, nil }}, nil
+	})
+
+	// more messages than the confirmations buffer, which would block the
+	// mock channel if they were not confirmed in chunks
+	keys := make([]string, 2*maxUnconfirmedMessages+1)
+	for i := range keys {
+		keys[i] = "public.test.insert"
+	}
+	require.NoError(t, c.publish(context.Background(), newTestPublishings(keys...)))
+	require.Len(t, channel.published, len(keys))
+}
@@ -0,0 +1,322 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/backoff"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+// AMQPSink is a wal processor that publishes the events to a topic exchange of
+// an AMQP 0-9-1 broker, such as RabbitMQ. Events are routed with the
+// `{schema}.{table}.{action}` routing key, so that consumers can bind their
+// queues to the tables and actions they're interested in (i.e.
+// `public.users.*` or `*.*.delete`). Messages are persistent, and a batch is
+// only checkpointed once all its messages have been confirmed by the broker.
+type AMQPSink struct {
+	logger          loglib.Logger
+	batchSender     batchSender
+	instrumentation *otel.Instrumentation
+	publisher       publisher
+	serialiser      func(any) ([]byte, error)
+	backoffProvider backoff.Provider
+
+	expiration      string
+	priority        uint8
+	tablePriorities map[string]uint8
+
+	// optional checkpointer callback to mark what was safely processed
+	checkpointer checkpointer.Checkpoint
+}
+
+type Option func(*AMQPSink)
+
+type batchSender interface {
+	SendMessage(context.Context, *batch.WALMessage[message]) error
+	Close()
+}
+
+type publisher interface {
+	publish(ctx context.Context, msgs []*publishing) error
+	ping(ctx context.Context) error
+	close() error
+}
+
+// message is a single event to be published to the exchange
+type message struct {
+	msg *publishing
+}
+
+const (
+	// approximate size of the message properties, excluding the body and
+	// headers
+	messageOverheadBytes = 64
+
+	publicSchema = "public"
+	maxPriority  = 255
+)
+
+var (
+	errMissingURL      = errors.New("missing amqp broker url")
+	errMissingExchange = errors.New("missing amqp exchange")
+	errInvalidPriority = fmt.Errorf("amqp message priority must be between 0 and %d", maxPriority)
+	errInvalidTTL      = errors.New("amqp message ttl must be at least 1ms")
+)
+
+func NewAMQPSink(ctx context.Context, cfg *Config, opts ...Option) (*AMQPSink, error) {
+	if cfg.URL == "" {
+		return nil, errMissingURL
+	}
+	if cfg.Exchange == "" {
+		return nil, errMissingExchange
+	}
+
+	s := &AMQPSink{
+		logger:          loglib.NewNoopLogger(),
+		serialiser:      json.Marshal,
+		backoffProvider: backoff.NewProvider(cfg.retryPolicy()),
+		tablePriorities: make(map[string]uint8, len(cfg.TablePriorities)),
+	}
+
+	if cfg.MessageTTL != 0 {
+		// the expiration is set in milliseconds
+		if cfg.MessageTTL < time.Millisecond {
+			return nil, errInvalidTTL
+		}
+		s.expiration = strconv.FormatInt(cfg.MessageTTL.Milliseconds(), 10)
+	}
+
+	var err error
+	if s.priority, err = parsePriority(cfg.Priority); err != nil {
+		return nil, err
+	}
+	for table, priority := range cfg.TablePriorities {
+		// unqualified table names belong to the public schema
+		if !strings.Contains(table, ".") {
+			table = publicSchema + "." + table
+		}
+		if s.tablePriorities[table], err = parsePriority(priority); err != nil {
+			return nil, fmt.Errorf("table %s: %w", table, err)
+		}
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.publisher, err = newClient(cfg, s.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	s.batchSender, err = batch.NewSender(ctx, &cfg.Batch, s.sendBatch, s.logger, batch.WithInstrumentation(s.instrumentation, s.Name()))
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *AMQPSink) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "amqp_sink",
+		})
+	}
+}
+
+func WithCheckpoint(c checkpointer.Checkpoint) Option {
+	return func(s *AMQPSink) {
+		s.checkpointer = c
+	}
+}
+
+// WithInstrumentation reports the metrics of the batches sent by the sink.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(s *AMQPSink) {
+		s.instrumentation = i
+	}
+}
+
+// ProcessWALEvent is called on every new message from the wal. It can be called
+// concurrently.
+func (s *AMQPSink) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Panic("[PANIC] Panic while processing replication event", loglib.Fields{
+				"wal_data":    walEvent,
+				"panic":       r,
+				"stack_trace": debug.Stack(),
+			})
+
+			retErr = fmt.Errorf("amqp sink: understanding event: %v", r)
+		}
+	}()
+
+	msg := message{}
+	if walEvent.Data != nil {
+		var err error
+		msg, err = s.newMessage(walEvent.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.batchSender.SendMessage(ctx, batch.NewWALMessage(msg, walEvent.CommitPosition))
+}
+
+func (s *AMQPSink) Name() string {
+	return "amqp-sink"
+}
+
+func (s *AMQPSink) Close() error {
+	s.batchSender.Close()
+	return s.publisher.close()
+}
+
+// Ping checks the broker is reachable and the exchange can be declared.
+func (s *AMQPSink) Ping(ctx context.Context) error {
+	if err := s.publisher.ping(ctx); err != nil {
+		return fmt.Errorf("amqp broker: %w", err)
+	}
+	return nil
+}
+
+func (s *AMQPSink) newMessage(data *wal.Data) (message, error) {
+	body, err := s.serialiser(data)
+	if err != nil {
+		return message{}, fmt.Errorf("marshalling event: %w", err)
+	}
+
+	priority, found := s.tablePriorities[data.Schema+"."+data.Table]
+	if !found {
+		priority = s.priority
+	}
+
+	return message{
+		msg: &publishing{
+			routingKey: routingKey(data),
+			msg: amqp.Publishing{
+				Headers: amqp.Table{
+					"schema": data.Schema,
+					"table":  data.Table,
+					"action": data.Action,
+					"lsn":    data.LSN,
+				},
+				ContentType:  "application/json",
+				DeliveryMode: amqp.Persistent,
+				Priority:     priority,
+				Expiration:   s.expiration,
+				Body:         body,
+			},
+		},
+	}, nil
+}
+
+func (s *AMQPSink) sendBatch(ctx context.Context, batch *batch.Batch[message]) error {
+	messages := batch.GetMessages()
+	s.logger.Debug("amqp sink: publishing message batch", loglib.Fields{
+		"batch_size":             len(messages),
+		"batch_commit_positions": len(batch.GetCommitPositions()),
+	})
+
+	if len(messages) > 0 {
+		msgs := make([]*publishing, 0, len(messages))
+		for _, m := range messages {
+			msgs = append(msgs, m.msg)
+		}
+		if err := s.publishMessages(ctx, msgs); err != nil {
+			s.logger.Error(err, "failed to publish to amqp")
+			return fmt.Errorf("amqp sink: %w", err)
+		}
+	}
+
+	positions := batch.GetCommitPositions()
+	if s.checkpointer != nil && len(positions) > 0 {
+		if err := s.checkpointer(ctx, positions); err != nil {
+			s.logger.Warn(err, "amqp sink: error updating commit position")
+		}
+	}
+
+	return nil
+}
+
+// publishMessages publishes the messages in order, retrying the whole batch
+// if the connection drops or any of the messages is not confirmed. Retries
+// can publish the same message more than once.
+func (s *AMQPSink) publishMessages(ctx context.Context, msgs []*publishing) error {
+	return s.backoffProvider(ctx).RetryNotify(
+		func() error {
+			err := s.publisher.publish(ctx, msgs)
+			if err != nil && errors.Is(err, context.Canceled) {
+				return fmt.Errorf("%w: %w", err, backoff.ErrPermanent)
+			}
+			return err
+		},
+		func(err error, d time.Duration) {
+			s.logger.Warn(err, "amqp sink: publish failed, retrying", loglib.Fields{
+				"retry_after": d,
+			})
+		})
+}
+
+// routingKey returns the `{schema}.{table}.{action}` routing key of the event,
+// where the action is one of insert, update, delete or truncate.
+func routingKey(data *wal.Data) string {
+	return data.Schema + "." + data.Table + "." + actionName(data.Action)
+}
+
+func actionName(action string) string {
+	switch wal.Action(action) {
+	case wal.ActionInsert:
+		return "insert"
+	case wal.ActionUpdate:
+		return "update"
+	case wal.ActionDelete:
+		return "delete"
+	case wal.ActionTruncate:
+		return "truncate"
+	case wal.ActionCommit:
+		return "commit"
+	default:
+		return strings.ToLower(action)
+	}
+}
+
+func parsePriority(priority int) (uint8, error) {
+	if priority < 0 || priority > maxPriority {
+		return 0, errInvalidPriority
+	}
+	return uint8(priority), nil
+}
+
+func (m message) Size() int {
+	if m.msg == nil {
+		return 0
+	}
+	size := len(m.msg.msg.Body) + len(m.msg.routingKey) + messageOverheadBytes
+	for k, v := range m.msg.msg.Headers {
+		if s, ok := v.(string); ok {
+			size += len(k) + len(s)
+		}
+	}
+	return size
+}
+
+func (m message) IsEmpty() bool {
+	return m.msg == nil
+}
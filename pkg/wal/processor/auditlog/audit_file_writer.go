@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal/processor/filelog"
+)
+
+// fileWriter appends the audit records as JSON lines to a rotating file.
+type fileWriter struct {
+	file *filelog.RotatingFile
+}
+
+var errMissingPath = errors.New("missing audit log file path")
+
+func newFileWriter(cfg *FileConfig, logger loglib.Logger) (*fileWriter, error) {
+	if cfg.Path == "" {
+		return nil, errMissingPath
+	}
+	file, err := filelog.NewRotatingFile(cfg.fileLogConfig(), logger)
+	if err != nil {
+		return nil, err
+	}
+	return &fileWriter{file: file}, nil
+}
+
+// write appends the entries on input to the file with a single write, so that
+// they're never split across rotated files. The batches are sent sequentially,
+// so the writes are not concurrent.
+func (w *fileWriter) write(_ context.Context, entries []entry) error {
+	buf := bytes.Buffer{}
+	for _, e := range entries {
+		buf.Write(e.line)
+	}
+	return w.file.Write(buf.Bytes())
+}
+
+func (w *fileWriter) ping(context.Context) error {
+	return nil
+}
+
+func (w *fileWriter) close() error {
+	return w.file.Close()
+}
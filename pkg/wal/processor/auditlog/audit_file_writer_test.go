@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+func TestFileWriter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+		w, err := newFileWriter(&FileConfig{Path: path}, loglib.NewNoopLogger())
+		require.NoError(t, err)
+
+		require.NoError(t, w.write(context.Background(), []entry{
+			{record: &Record{}, line: []byte("{\"action\":\"insert\"}\n")},
+			{record: &Record{}, line: []byte("{\"action\":\"delete\"}\n")},
+		}))
+		require.NoError(t, w.write(context.Background(), []entry{
+			{record: &Record{}, line: []byte("{\"action\":\"update\"}\n")},
+		}))
+		require.NoError(t, w.close())
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "{\"action\":\"insert\"}\n{\"action\":\"delete\"}\n{\"action\":\"update\"}\n", string(content))
+	})
+
+	t.Run("error - missing path", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newFileWriter(&FileConfig{}, loglib.NewNoopLogger())
+		require.ErrorIs(t, err, errMissingPath)
+	})
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/xataio/pgstream/internal/json"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+)
+
+// postgresWriter inserts the audit records into a postgres table, which is
+// created if it doesn't exist.
+type postgresWriter struct {
+	pgConn pglib.Querier
+	table  string
+	// quotedTable is the table name to use in the queries
+	quotedTable string
+}
+
+var errMissingURL = errors.New("missing audit log postgres URL")
+
+var auditTableColumns = []string{
+	"timestamp", "lsn", "schema", "table", "action", "user", "application",
+	"primary_key", "changed_columns", "old_values", "new_values",
+}
+
+const createAuditTableQuery = `CREATE TABLE IF NOT EXISTS %[1]s(
+	"timestamp" TIMESTAMPTZ NOT NULL,
+	"lsn" TEXT NOT NULL,
+	"schema" TEXT NOT NULL,
+	"table" TEXT NOT NULL,
+	"action" TEXT NOT NULL,
+	"user" TEXT,
+	"application" TEXT,
+	"primary_key" JSONB,
+	"changed_columns" TEXT[],
+	"old_values" JSONB,
+	"new_values" JSONB
+);
+CREATE INDEX IF NOT EXISTS %[2]s ON %[1]s("timestamp")`
+
+func newPostgresWriter(ctx context.Context, cfg *PostgresConfig) (*postgresWriter, error) {
+	if cfg.URL == "" {
+		return nil, errMissingURL
+	}
+
+	tableName, err := pglib.NewQualifiedName(cfg.table())
+	if err != nil {
+		return nil, fmt.Errorf("audit log table: %w", err)
+	}
+
+	pgConn, err := pglib.NewConnPool(ctx, cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("creating audit log postgres connection: %w", err)
+	}
+
+	w := &postgresWriter{
+		pgConn:      pgConn,
+		table:       cfg.table(),
+		quotedTable: quotedTableName(tableName),
+	}
+	if err := w.createTable(ctx, tableName); err != nil {
+		pgConn.Close(context.Background())
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *postgresWriter) createTable(ctx context.Context, tableName *pglib.QualifiedName) error {
+	if tableName.Schema() != "" {
+		if _, err := w.pgConn.Exec(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", pglib.QuoteIdentifier(tableName.Schema()))); err != nil {
+			return fmt.Errorf("creating audit log schema: %w", err)
+		}
+	}
+	indexName := pglib.QuoteIdentifier(tableName.Name() + "_timestamp_idx")
+	if _, err := w.pgConn.Exec(ctx, fmt.Sprintf(createAuditTableQuery, w.quotedTable, indexName)); err != nil {
+		return fmt.Errorf("creating audit log table: %w", err)
+	}
+	return nil
+}
+
+// write inserts the entries on input into the audit table in a single copy.
+func (w *postgresWriter) write(ctx context.Context, entries []entry) error {
+	rows := make([][]any, 0, len(entries))
+	for _, e := range entries {
+		row, err := auditTableRow(e.record)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+
+	// the column names are sanitised by the copy
+	columns := make([]string, len(auditTableColumns))
+	copy(columns, auditTableColumns)
+	if _, err := w.pgConn.CopyFrom(ctx, w.table, columns, rows); err != nil {
+		return fmt.Errorf("copying records to audit log table: %w", err)
+	}
+	return nil
+}
+
+// purge deletes the audit records older than the timestamp on input.
+func (w *postgresWriter) purge(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := w.pgConn.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE "timestamp" < $1`, w.quotedTable), before)
+	if err != nil {
+		return 0, fmt.Errorf("deleting expired audit log records: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (w *postgresWriter) ping(ctx context.Context) error {
+	return w.pgConn.Ping(ctx)
+}
+
+func (w *postgresWriter) close() error {
+	return w.pgConn.Close(context.Background())
+}
+
+func auditTableRow(r *Record) ([]any, error) {
+	primaryKey, err := jsonbValue(r.PrimaryKey)
+	if err != nil {
+		return nil, fmt.Errorf("encoding primary key: %w", err)
+	}
+	oldValues, err := jsonbValue(r.OldValues)
+	if err != nil {
+		return nil, fmt.Errorf("encoding old values: %w", err)
+	}
+	newValues, err := jsonbValue(r.NewValues)
+	if err != nil {
+		return nil, fmt.Errorf("encoding new values: %w", err)
+	}
+
+	var changedColumns any
+	if len(r.ChangedColumns) > 0 {
+		changedColumns = r.ChangedColumns
+	}
+
+	return []any{
+		r.Timestamp, r.LSN, r.Schema, r.Table, r.Action, nullableString(r.User), nullableString(r.Application),
+		primaryKey, changedColumns, oldValues, newValues,
+	}, nil
+}
+
+// jsonbValue returns the JSON document for the values on input, or nil if
+// there are none, so that they're inserted as null.
+func jsonbValue(values map[string]any) (any, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	doc, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func nullableString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func quotedTableName(tableName *pglib.QualifiedName) string {
+	if tableName.Schema() == "" {
+		return pglib.QuoteIdentifier(tableName.Name())
+	}
+	return pglib.QuoteQualifiedIdentifier(tableName.Schema(), tableName.Name())
+}
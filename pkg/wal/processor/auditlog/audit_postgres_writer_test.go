@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+)
+
+func TestPostgresWriter_createTable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		table   string
+		execErr error
+
+		wantQueries []string
+		wantErr     error
+	}{
+		{
+			name:  "ok - qualified table",
+			table: "audit.changes",
+
+			wantQueries: []string{
+				`CREATE SCHEMA IF NOT EXISTS "audit"`,
+				fmt.Sprintf(createAuditTableQuery, `"audit"."changes"`, `"changes_timestamp_idx"`),
+			},
+		},
+		{
+			name:  "ok - unqualified table",
+			table: "changes",
+
+			wantQueries: []string{
+				fmt.Sprintf(createAuditTableQuery, `"changes"`, `"changes_timestamp_idx"`),
+			},
+		},
+		{
+			name:    "error - creating schema",
+			table:   "audit.changes",
+			execErr: errTest,
+
+			wantQueries: []string{`CREATE SCHEMA IF NOT EXISTS "audit"`},
+			wantErr:     errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tableName, err := pglib.NewQualifiedName(tc.table)
+			require.NoError(t, err)
+
+			queries := []string{}
+			w := &postgresWriter{
+				pgConn: &pgmocks.Querier{
+					ExecFn: func(_ context.Context, _ uint, query string, args ...any) (pglib.CommandTag, error) {
+						queries = append(queries, query)
+						return pglib.CommandTag{}, tc.execErr
+					},
+				},
+				table:       tc.table,
+				quotedTable: quotedTableName(tableName),
+			}
+
+			err = w.createTable(context.Background(), tableName)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantQueries, queries)
+		})
+	}
+}
+
+func TestPostgresWriter_write(t *testing.T) {
+	t.Parallel()
+
+	testTimestamp := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	entries := []entry{
+		{record: &Record{
+			Timestamp:      testTimestamp,
+			LSN:            "0/15D68C8",
+			Schema:         "public",
+			Table:          "users",
+			Action:         "update",
+			User:           "admin",
+			PrimaryKey:     map[string]any{"id": 1},
+			ChangedColumns: []string{"name"},
+			NewValues:      map[string]any{"name": "alice"},
+		}},
+		{record: &Record{
+			Timestamp: testTimestamp,
+			LSN:       "0/15D68D0",
+			Schema:    "public",
+			Table:     "users",
+			Action:    "truncate",
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		copyErr error
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+		},
+		{
+			name:    "error - copying records",
+			copyErr: errTest,
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			w := &postgresWriter{
+				pgConn: &pgmocks.Querier{
+					CopyFromFn: func(_ context.Context, tableName string, columnNames []string, srcRows [][]any) (int64, error) {
+						require.Equal(t, "audit.changes", tableName)
+						require.Equal(t, auditTableColumns, columnNames)
+						require.Equal(t, [][]any{
+							{
+								testTimestamp, "0/15D68C8", "public", "users", "update", "admin", nil,
+								[]byte(`{"id":1}`), []string{"name"}, nil, []byte(`{"name":"alice"}`),
+							},
+							{
+								testTimestamp, "0/15D68D0", "public", "users", "truncate", nil, nil,
+								nil, nil, nil, nil,
+							},
+						}, srcRows)
+						return int64(len(srcRows)), tc.copyErr
+					},
+				},
+				table: "audit.changes",
+			}
+
+			err := w.write(context.Background(), entries)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestPostgresWriter_purge(t *testing.T) {
+	t.Parallel()
+
+	before := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	w := &postgresWriter{
+		pgConn: &pgmocks.Querier{
+			ExecFn: func(_ context.Context, _ uint, query string, args ...any) (pglib.CommandTag, error) {
+				require.Equal(t, `DELETE FROM "audit"."changes" WHERE "timestamp" < $1`, query)
+				require.Equal(t, []any{before}, args)
+				return pglib.CommandTag{CommandTag: pgconn.NewCommandTag("DELETE 3")}, nil
+			},
+		},
+		table:       "audit.changes",
+		quotedTable: `"audit"."changes"`,
+	}
+
+	deleted, err := w.purge(context.Background(), before)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), deleted)
+}
+
+var errTest = errors.New("oh noes")
@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"slices"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/filter"
+)
+
+// Record is a single entry of the audit log, describing a change to a row (or
+// a table truncate).
+type Record struct {
+	Timestamp   time.Time `json:"timestamp"`
+	LSN         string    `json:"lsn"`
+	Schema      string    `json:"schema"`
+	Table       string    `json:"table"`
+	Action      string    `json:"action"`
+	User        string    `json:"user,omitempty"`
+	Application string    `json:"application,omitempty"`
+	// PrimaryKey has the values of the primary key columns of the row.
+	PrimaryKey map[string]any `json:"primary_key,omitempty"`
+	// ChangedColumns are the names of the columns set by the change. Updates
+	// are only narrowed down to the columns that changed when the old values
+	// of the row are available (REPLICA IDENTITY FULL).
+	ChangedColumns []string `json:"changed_columns,omitempty"`
+	// OldValues and NewValues are only set when the values are included.
+	OldValues map[string]any `json:"old_values,omitempty"`
+	NewValues map[string]any `json:"new_values,omitempty"`
+}
+
+const redactedValue = "[REDACTED]"
+
+var actionNames = map[wal.Action]string{
+	wal.ActionInsert:   "insert",
+	wal.ActionUpdate:   "update",
+	wal.ActionDelete:   "delete",
+	wal.ActionTruncate: "truncate",
+}
+
+// recordBuilder builds the audit records of the wal events.
+type recordBuilder struct {
+	includeValues bool
+	// redacted has the names of the redacted columns, either unqualified or
+	// qualified with their schema and table
+	redacted map[string]struct{}
+	clock    func() time.Time
+}
+
+func newRecordBuilder(cfg *Config, clock func() time.Time) *recordBuilder {
+	redacted := make(map[string]struct{}, len(cfg.RedactedColumns))
+	for _, col := range cfg.RedactedColumns {
+		redacted[col] = struct{}{}
+	}
+	return &recordBuilder{
+		includeValues: cfg.IncludeValues,
+		redacted:      redacted,
+		clock:         clock,
+	}
+}
+
+// build returns the audit record for the wal data on input, or nil if it's
+// not a table operation.
+func (b *recordBuilder) build(d *wal.Data) *Record {
+	action, found := actionNames[wal.Action(d.Action)]
+	if !found {
+		return nil
+	}
+
+	timestamp, err := d.GetTimestamp()
+	if err != nil {
+		// the events without a commit timestamp (i.e. snapshots) are recorded
+		// at the time they're processed
+		timestamp = b.clock()
+	}
+
+	r := &Record{
+		Timestamp: timestamp.UTC(),
+		LSN:       d.LSN,
+		Schema:    d.Schema,
+		Table:     d.Table,
+		Action:    action,
+	}
+	if d.Metadata.Audit != nil {
+		r.User = d.Metadata.Audit.User
+		r.Application = d.Metadata.Audit.Application
+	}
+
+	switch wal.Action(d.Action) {
+	case wal.ActionInsert:
+		r.PrimaryKey = b.primaryKey(d, d.Columns)
+		r.ChangedColumns = columnNames(d.Columns)
+	case wal.ActionUpdate:
+		r.PrimaryKey = b.primaryKey(d, d.Columns)
+		r.ChangedColumns = changedColumns(d)
+	case wal.ActionDelete:
+		r.PrimaryKey = b.primaryKey(d, d.Identity)
+		r.ChangedColumns = columnNames(d.Identity)
+	}
+
+	if b.includeValues {
+		r.OldValues = b.values(d, d.Identity)
+		r.NewValues = b.values(d, d.Columns)
+	}
+	return r
+}
+
+// primaryKey returns the values of the primary key columns of the row, or of
+// its identity if the primary key columns are not known.
+func (b *recordBuilder) primaryKey(d *wal.Data, cols []wal.Column) map[string]any {
+	var keyCols []wal.Column
+	for _, col := range cols {
+		if d.Metadata.IsIDColumn(col.ID) {
+			keyCols = append(keyCols, col)
+		}
+	}
+	if len(keyCols) == 0 {
+		keyCols = d.Identity
+	}
+	return b.values(d, keyCols)
+}
+
+func (b *recordBuilder) values(d *wal.Data, cols []wal.Column) map[string]any {
+	if len(cols) == 0 {
+		return nil
+	}
+	values := make(map[string]any, len(cols))
+	for _, col := range cols {
+		if b.isRedacted(d, col.Name) {
+			values[col.Name] = redactedValue
+			continue
+		}
+		values[col.Name] = col.Value
+	}
+	return values
+}
+
+func (b *recordBuilder) isRedacted(d *wal.Data, column string) bool {
+	if len(b.redacted) == 0 {
+		return false
+	}
+	if _, found := b.redacted[column]; found {
+		return true
+	}
+	_, found := b.redacted[d.Schema+"."+d.Table+"."+column]
+	return found
+}
+
+// changedColumns returns the names of the columns that differ between the old
+// and the new values of the update on input. Columns without an old value are
+// considered changed, since the previous value is unknown.
+func changedColumns(d *wal.Data) []string {
+	changed := []string{}
+	for _, col := range d.Columns {
+		i := slices.IndexFunc(d.Identity, func(old wal.Column) bool { return old.Name == col.Name })
+		if i == -1 || !filter.ColumnValuesEqual(col.Type, d.Identity[i].Value, col.Value) {
+			changed = append(changed, col.Name)
+		}
+	}
+	return changed
+}
+
+func columnNames(cols []wal.Column) []string {
+	names := make([]string, 0, len(cols))
+	for _, col := range cols {
+		names = append(names, col.Name)
+	}
+	return names
+}
@@ -0,0 +1,195 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func newTestData(action wal.Action) *wal.Data {
+	d := &wal.Data{
+		Action:    string(action),
+		Timestamp: "2024-05-01 10:00:00.5+00",
+		LSN:       "0/15D68C8",
+		Schema:    "public",
+		Table:     "users",
+		Metadata: wal.Metadata{
+			InternalColIDs: []string{"col-1"},
+		},
+	}
+	switch action {
+	case wal.ActionInsert, wal.ActionUpdate:
+		d.Columns = []wal.Column{
+			{ID: "col-1", Name: "id", Type: "integer", Value: 1},
+			{ID: "col-2", Name: "name", Type: "text", Value: "alice"},
+			{ID: "col-3", Name: "password", Type: "text", Value: "secret"},
+		}
+	}
+	switch action {
+	case wal.ActionUpdate, wal.ActionDelete:
+		d.Identity = []wal.Column{
+			{ID: "col-1", Name: "id", Type: "integer", Value: 1},
+		}
+	}
+	return d
+}
+
+func TestRecordBuilder_build(t *testing.T) {
+	t.Parallel()
+
+	testTimestamp := time.Date(2024, 5, 1, 10, 0, 0, 500000000, time.UTC)
+	testNow := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	newTestRecord := func(action string) *Record {
+		return &Record{
+			Timestamp:  testTimestamp,
+			LSN:        "0/15D68C8",
+			Schema:     "public",
+			Table:      "users",
+			Action:     action,
+			PrimaryKey: map[string]any{"id": 1},
+		}
+	}
+
+	tests := []struct {
+		name string
+		cfg  *Config
+		data func() *wal.Data
+
+		wantRecord func() *Record
+	}{
+		{
+			name: "insert",
+			cfg:  &Config{},
+			data: func() *wal.Data { return newTestData(wal.ActionInsert) },
+
+			wantRecord: func() *Record {
+				r := newTestRecord("insert")
+				r.ChangedColumns = []string{"id", "name", "password"}
+				return r
+			},
+		},
+		{
+			name: "update without the old values",
+			cfg:  &Config{},
+			data: func() *wal.Data { return newTestData(wal.ActionUpdate) },
+
+			wantRecord: func() *Record {
+				r := newTestRecord("update")
+				r.ChangedColumns = []string{"name", "password"}
+				return r
+			},
+		},
+		{
+			name: "update with the old values",
+			cfg:  &Config{},
+			data: func() *wal.Data {
+				d := newTestData(wal.ActionUpdate)
+				d.Identity = []wal.Column{
+					{ID: "col-1", Name: "id", Type: "integer", Value: 1},
+					{ID: "col-2", Name: "name", Type: "text", Value: "bob"},
+					{ID: "col-3", Name: "password", Type: "text", Value: "secret"},
+				}
+				return d
+			},
+
+			wantRecord: func() *Record {
+				r := newTestRecord("update")
+				r.ChangedColumns = []string{"name"}
+				return r
+			},
+		},
+		{
+			name: "delete",
+			cfg:  &Config{},
+			data: func() *wal.Data { return newTestData(wal.ActionDelete) },
+
+			wantRecord: func() *Record {
+				r := newTestRecord("delete")
+				r.ChangedColumns = []string{"id"}
+				return r
+			},
+		},
+		{
+			name: "truncate",
+			cfg:  &Config{},
+			data: func() *wal.Data { return newTestData(wal.ActionTruncate) },
+
+			wantRecord: func() *Record {
+				r := newTestRecord("truncate")
+				r.PrimaryKey = nil
+				return r
+			},
+		},
+		{
+			name: "primary key from the identity",
+			cfg:  &Config{},
+			data: func() *wal.Data {
+				d := newTestData(wal.ActionUpdate)
+				d.Metadata.InternalColIDs = nil
+				return d
+			},
+
+			wantRecord: func() *Record {
+				r := newTestRecord("update")
+				r.ChangedColumns = []string{"name", "password"}
+				return r
+			},
+		},
+		{
+			name: "audit context and processing timestamp",
+			cfg:  &Config{},
+			data: func() *wal.Data {
+				d := newTestData(wal.ActionDelete)
+				d.Timestamp = ""
+				d.Metadata.Audit = &wal.AuditInfo{User: "admin", Application: "psql", Command: "DELETE"}
+				return d
+			},
+
+			wantRecord: func() *Record {
+				r := newTestRecord("delete")
+				r.Timestamp = testNow
+				r.User = "admin"
+				r.Application = "psql"
+				r.ChangedColumns = []string{"id"}
+				return r
+			},
+		},
+		{
+			name: "values with redacted columns",
+			cfg: &Config{
+				IncludeValues:   true,
+				RedactedColumns: []string{"password", "public.users.id", "public.orders.name"},
+			},
+			data: func() *wal.Data { return newTestData(wal.ActionUpdate) },
+
+			wantRecord: func() *Record {
+				r := newTestRecord("update")
+				r.PrimaryKey = map[string]any{"id": redactedValue}
+				r.ChangedColumns = []string{"name", "password"}
+				r.OldValues = map[string]any{"id": redactedValue}
+				r.NewValues = map[string]any{"id": redactedValue, "name": "alice", "password": redactedValue}
+				return r
+			},
+		},
+		{
+			name: "commit event",
+			cfg:  &Config{},
+			data: func() *wal.Data { return &wal.Data{Action: string(wal.ActionCommit)} },
+
+			wantRecord: func() *Record { return nil },
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			builder := newRecordBuilder(tc.cfg, func() time.Time { return testNow })
+			require.Equal(t, tc.wantRecord(), builder.build(tc.data()))
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"time"
+
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	"github.com/xataio/pgstream/pkg/wal/processor/filelog"
+)
+
+type Config struct {
+	// File writes the audit records as JSON lines to a local file. It can't
+	// be combined with Postgres.
+	File *FileConfig
+	// Postgres writes the audit records to a postgres table. It can't be
+	// combined with File.
+	Postgres *PostgresConfig
+	// IncludeValues adds the old and new values of the changed rows to the
+	// audit records. Only the primary key and the changed column names are
+	// recorded otherwise.
+	IncludeValues bool
+	// RedactedColumns are the columns whose values are replaced in the audit
+	// records, either by name (i.e. password), which applies to all tables,
+	// or qualified with their schema and table (i.e. public.users.password).
+	RedactedColumns []string
+
+	Batch batch.Config
+}
+
+type FileConfig struct {
+	// Path is the file the audit records are written to. Rotated files are
+	// compressed and kept in the same directory.
+	Path string
+	// RotationInterval is the maximum time records are written to the same
+	// file before it's rotated. Defaults to 1h.
+	RotationInterval time.Duration
+	// MaxFileBytes is the size in bytes at which the file is rotated,
+	// regardless of the rotation interval. Defaults to 0 (disabled).
+	MaxFileBytes int64
+	// MaxRotations is the number of rotated files retained. Defaults to 24.
+	MaxRotations int
+}
+
+type PostgresConfig struct {
+	// URL is the postgres database the audit table is in.
+	URL string
+	// Table is the audit table, created if it doesn't exist. Defaults to
+	// pgstream.audit_log.
+	Table string
+	// Retention is how long the audit records are kept in the table. Older
+	// records are deleted periodically. Defaults to 0 (kept forever).
+	Retention time.Duration
+}
+
+const (
+	defaultTable = "pgstream.audit_log"
+	// purgeInterval is how often the records beyond the retention are
+	// deleted from the audit table
+	purgeInterval = time.Hour
+)
+
+func (c *FileConfig) fileLogConfig() *filelog.Config {
+	return &filelog.Config{
+		Path:             c.Path,
+		RotationInterval: c.RotationInterval,
+		MaxFileBytes:     c.MaxFileBytes,
+		MaxRotations:     c.MaxRotations,
+	}
+}
+
+func (c *PostgresConfig) table() string {
+	if c.Table != "" {
+		return c.Table
+	}
+	return defaultTable
+}
@@ -0,0 +1,251 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/xataio/pgstream/internal/json"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+// AuditLogSink is a wal processor that keeps a compact journal of the changes,
+// with one record per changed row: when it changed, who changed it (when the
+// pg_audit context is available), its primary key and the changed columns.
+// The records are written to append only JSON lines files, or to a postgres
+// table. It's meant to be attached alongside the main target with a router.
+type AuditLogSink struct {
+	logger          loglib.Logger
+	batchSender     batchSender
+	instrumentation *otel.Instrumentation
+	builder         *recordBuilder
+	writer          recordWriter
+	serialiser      func(any) ([]byte, error)
+
+	// optional checkpointer callback to mark what was safely processed
+	checkpointer checkpointer.Checkpoint
+
+	// cancelPurge stops the deletion of the expired records, if enabled
+	cancelPurge context.CancelFunc
+	purgeWg     sync.WaitGroup
+}
+
+type Option func(*AuditLogSink)
+
+type batchSender interface {
+	SendMessage(context.Context, *batch.WALMessage[entry]) error
+	Close()
+}
+
+type recordWriter interface {
+	write(context.Context, []entry) error
+	ping(context.Context) error
+	close() error
+}
+
+// entry is an audit record along with its JSON line
+type entry struct {
+	record *Record
+	line   []byte
+}
+
+const sinkName = "audit_log_sink"
+
+var (
+	errMissingDestination   = errors.New("missing audit log destination, either a file or a postgres table")
+	errMultipleDestinations = errors.New("only one audit log destination can be configured")
+)
+
+// NewAuditLogSink returns a sink that writes the audit records to the
+// configured destination.
+func NewAuditLogSink(ctx context.Context, cfg *Config, opts ...Option) (*AuditLogSink, error) {
+	switch {
+	case cfg.File == nil && cfg.Postgres == nil:
+		return nil, errMissingDestination
+	case cfg.File != nil && cfg.Postgres != nil:
+		return nil, errMultipleDestinations
+	}
+
+	s := &AuditLogSink{
+		logger:      loglib.NewNoopLogger(),
+		builder:     newRecordBuilder(cfg, time.Now),
+		serialiser:  json.Marshal,
+		cancelPurge: func() {},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	var pgWriter *postgresWriter
+	var err error
+	switch {
+	case cfg.File != nil:
+		s.writer, err = newFileWriter(cfg.File, s.logger)
+	case cfg.Postgres != nil:
+		pgWriter, err = newPostgresWriter(ctx, cfg.Postgres)
+		s.writer = pgWriter
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.batchSender, err = batch.NewSender(ctx, &cfg.Batch, s.sendBatch, s.logger, batch.WithInstrumentation(s.instrumentation, s.Name()))
+	if err != nil {
+		s.writer.close()
+		return nil, err
+	}
+
+	if pgWriter != nil && cfg.Postgres.Retention > 0 {
+		purgeCtx, cancel := context.WithCancel(ctx)
+		s.cancelPurge = cancel
+		s.purgeWg.Add(1)
+		go func() {
+			defer s.purgeWg.Done()
+			s.purgeExpired(purgeCtx, pgWriter, cfg.Postgres.Retention, purgeInterval)
+		}()
+	}
+
+	return s, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *AuditLogSink) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: sinkName,
+		})
+	}
+}
+
+func WithCheckpoint(c checkpointer.Checkpoint) Option {
+	return func(s *AuditLogSink) {
+		s.checkpointer = c
+	}
+}
+
+// WithInstrumentation reports the metrics of the batches sent by the sink.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(s *AuditLogSink) {
+		s.instrumentation = i
+	}
+}
+
+// ProcessWALEvent is called on every new message from the wal. It can be called
+// concurrently.
+func (s *AuditLogSink) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Panic("[PANIC] Panic while processing replication event", loglib.Fields{
+				"wal_data":    walEvent,
+				"panic":       r,
+				"stack_trace": debug.Stack(),
+			})
+
+			err = fmt.Errorf("audit log sink: understanding event: %w:  %v", processor.ErrPanic, r)
+		}
+	}()
+
+	e := entry{}
+	// schema changes are not recorded, only the changes to the rows
+	if walEvent.Data != nil && !processor.IsSchemaLogEvent(walEvent.Data) {
+		if record := s.builder.build(walEvent.Data); record != nil {
+			line, err := s.serialiser(record)
+			if err != nil {
+				return fmt.Errorf("marshalling audit record: %w", err)
+			}
+			e = entry{
+				record: record,
+				line:   append(line, '\n'),
+			}
+		}
+	}
+
+	return s.batchSender.SendMessage(ctx, batch.NewWALMessage(e, walEvent.CommitPosition))
+}
+
+func (s *AuditLogSink) Name() string {
+	return sinkName
+}
+
+// Ping checks the connectivity with the audit log destination.
+func (s *AuditLogSink) Ping(ctx context.Context) error {
+	return s.writer.ping(ctx)
+}
+
+// Close stops the batch sending and the deletion of the expired records, and
+// closes the destination.
+func (s *AuditLogSink) Close() error {
+	s.batchSender.Close()
+	s.cancelPurge()
+	s.purgeWg.Wait()
+	return s.writer.close()
+}
+
+func (s *AuditLogSink) sendBatch(ctx context.Context, batch *batch.Batch[entry]) error {
+	entries := batch.GetMessages()
+	s.logger.Debug("audit log sink: writing audit records", loglib.Fields{
+		"batch_size":             len(entries),
+		"batch_commit_positions": len(batch.GetCommitPositions()),
+	})
+
+	if len(entries) > 0 {
+		if err := s.writer.write(ctx, entries); err != nil {
+			s.logger.Error(err, "failed to write audit records")
+			return fmt.Errorf("audit log sink: %w", err)
+		}
+	}
+
+	positions := batch.GetCommitPositions()
+	if s.checkpointer != nil && len(positions) > 0 {
+		if err := s.checkpointer(ctx, positions); err != nil {
+			s.logger.Warn(err, "audit log sink: error updating commit position")
+		}
+	}
+
+	return nil
+}
+
+type purger interface {
+	purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// purgeExpired deletes the audit records older than the retention on every
+// interval, until the context is cancelled.
+func (s *AuditLogSink) purgeExpired(ctx context.Context, p purger, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := p.purge(ctx, s.builder.clock().Add(-retention))
+		switch {
+		case err != nil && ctx.Err() == nil:
+			s.logger.Warn(err, "audit log sink: deleting expired audit records")
+		case deleted > 0:
+			s.logger.Debug("audit log sink: deleted expired audit records", loglib.Fields{"deleted_records": deleted})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e entry) Size() int {
+	return len(e.line)
+}
+
+func (e entry) IsEmpty() bool {
+	return e.record == nil
+}
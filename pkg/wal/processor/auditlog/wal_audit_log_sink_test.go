@@ -0,0 +1,229 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package auditlog
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/json"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	batchmocks "github.com/xataio/pgstream/pkg/wal/processor/batch/mocks"
+)
+
+type mockWriter struct {
+	writeFn func(context.Context, []entry) error
+}
+
+func (m *mockWriter) write(ctx context.Context, entries []entry) error {
+	return m.writeFn(ctx, entries)
+}
+
+func (m *mockWriter) ping(context.Context) error { return nil }
+
+func (m *mockWriter) close() error { return nil }
+
+type mockPurger struct {
+	mutex   sync.Mutex
+	befores []time.Time
+}
+
+func (m *mockPurger) purge(_ context.Context, before time.Time) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.befores = append(m.befores, before)
+	return 1, nil
+}
+
+func (m *mockPurger) purges() []time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]time.Time{}, m.befores...)
+}
+
+func TestNewAuditLogSink(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  *Config
+
+		wantErr error
+	}{
+		{
+			name:    "error - missing destination",
+			cfg:     &Config{},
+			wantErr: errMissingDestination,
+		},
+		{
+			name: "error - multiple destinations",
+			cfg: &Config{
+				File:     &FileConfig{Path: "audit.jsonl"},
+				Postgres: &PostgresConfig{URL: "postgres://localhost:5432"},
+			},
+			wantErr: errMultipleDestinations,
+		},
+		{
+			name:    "error - missing postgres url",
+			cfg:     &Config{Postgres: &PostgresConfig{}},
+			wantErr: errMissingURL,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewAuditLogSink(context.Background(), tc.cfg)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestAuditLogSink_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	testNow := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	testPosition := wal.CommitPosition("0/15D68C8")
+
+	tests := []struct {
+		name  string
+		event *wal.Event
+
+		wantLine string
+	}{
+		{
+			name: "row change",
+			event: &wal.Event{
+				Data:           newTestData(wal.ActionDelete),
+				CommitPosition: testPosition,
+			},
+
+			wantLine: `{"timestamp":"2024-05-01T10:00:00.5Z","lsn":"0/15D68C8","schema":"public","table":"users","action":"delete","primary_key":{"id":1},"changed_columns":["id"]}` + "\n",
+		},
+		{
+			name: "schema change",
+			event: &wal.Event{
+				Data: &wal.Data{
+					Action: string(wal.ActionInsert),
+					Schema: schemalog.SchemaName,
+					Table:  schemalog.TableName,
+				},
+				CommitPosition: testPosition,
+			},
+		},
+		{
+			name:  "keep alive",
+			event: &wal.Event{CommitPosition: testPosition},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sender := batchmocks.NewBatchSender[entry]()
+			s := &AuditLogSink{
+				logger:      loglib.NewNoopLogger(),
+				batchSender: sender,
+				builder:     newRecordBuilder(&Config{}, func() time.Time { return testNow }),
+				serialiser:  json.Marshal,
+			}
+
+			go func() {
+				defer sender.Close()
+				require.NoError(t, s.ProcessWALEvent(context.Background(), tc.event))
+			}()
+
+			msgs := sender.GetWALMessages()
+			require.Len(t, msgs, 1)
+			require.Equal(t, testPosition, msgs[0].GetPosition())
+			require.Equal(t, tc.wantLine, string(msgs[0].GetMessage().line))
+		})
+	}
+}
+
+func TestAuditLogSink_sendBatch(t *testing.T) {
+	t.Parallel()
+
+	testEntries := []entry{{record: &Record{Action: "insert"}, line: []byte("{}\n")}}
+	testPositions := []wal.CommitPosition{"0/15D68C8"}
+
+	tests := []struct {
+		name     string
+		writeErr error
+
+		wantCheckpoint bool
+		wantErr        error
+	}{
+		{
+			name: "ok",
+
+			wantCheckpoint: true,
+		},
+		{
+			name:     "error - writing records",
+			writeErr: errTest,
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			checkpointed := false
+			s := &AuditLogSink{
+				logger: loglib.NewNoopLogger(),
+				writer: &mockWriter{
+					writeFn: func(_ context.Context, entries []entry) error {
+						require.Equal(t, testEntries, entries)
+						return tc.writeErr
+					},
+				},
+				checkpointer: func(_ context.Context, positions []wal.CommitPosition) error {
+					require.Equal(t, testPositions, positions)
+					checkpointed = true
+					return nil
+				},
+			}
+
+			err := s.sendBatch(context.Background(), batch.NewBatch(testEntries, testPositions))
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantCheckpoint, checkpointed)
+		})
+	}
+}
+
+func TestAuditLogSink_purgeExpired(t *testing.T) {
+	t.Parallel()
+
+	testNow := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	s := &AuditLogSink{
+		logger:  loglib.NewNoopLogger(),
+		builder: newRecordBuilder(&Config{}, func() time.Time { return testNow }),
+	}
+	purger := &mockPurger{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.purgeExpired(ctx, purger, 24*time.Hour, time.Millisecond)
+	}()
+
+	require.Eventually(t, func() bool { return len(purger.purges()) >= 2 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	for _, before := range purger.purges() {
+		require.Equal(t, testNow.Add(-24*time.Hour), before)
+	}
+}
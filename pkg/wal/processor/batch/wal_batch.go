@@ -4,12 +4,15 @@ package batch
 
 import (
 	"github.com/xataio/pgstream/pkg/wal"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Batch[T Message] struct {
 	messages   []T
 	positions  []wal.CommitPosition
 	totalBytes int
+	// links to the spans the batch messages were produced in
+	links []trace.Link
 }
 
 func NewBatch[T Message](messages []T, positions []wal.CommitPosition) *Batch[T] {
@@ -31,6 +34,9 @@ func (b *Batch[T]) add(m *WALMessage[T]) {
 	if !m.message.IsEmpty() {
 		b.messages = append(b.messages, m.message)
 		b.totalBytes += m.message.Size()
+		if m.spanContext.IsValid() {
+			b.links = append(b.links, trace.Link{SpanContext: m.spanContext})
+		}
 	}
 
 	if m.position != "" && m.position != wal.ZeroLSN {
@@ -43,10 +49,12 @@ func (b *Batch[T]) drain() *Batch[T] {
 		messages:   b.messages,
 		positions:  b.positions,
 		totalBytes: b.totalBytes,
+		links:      b.links,
 	}
 
 	b.messages = []T{}
 	b.totalBytes = 0
+	b.links = nil
 	b.positions = []wal.CommitPosition{}
 	return batch
 }
@@ -11,6 +11,9 @@ import (
 
 	synclib "github.com/xataio/pgstream/internal/sync"
 	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Sender[T Message] struct {
@@ -35,13 +38,22 @@ type Sender[T Message] struct {
 	cancelFn context.CancelFunc
 
 	sendBatchFn sendBatchFn[T]
+	metrics     *senderMetrics
+	tracer      trace.Tracer
+	spanAttrs   trace.SpanStartOption
+	stats       *senderStats
 }
 
 type sendBatchFn[T Message] func(context.Context, *Batch[T]) error
 
 var errSendStopped = errors.New("stop processing, sending has stopped")
 
-func NewSender[T Message](ctx context.Context, config *Config, sendfn sendBatchFn[T], logger loglib.Logger) (*Sender[T], error) {
+func NewSender[T Message](ctx context.Context, config *Config, sendfn sendBatchFn[T], logger loglib.Logger, opts ...SenderOption) (*Sender[T], error) {
+	options := &senderOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	s := &Sender[T]{
 		batchSendInterval: config.GetBatchTimeout(),
 		maxBatchBytes:     config.GetMaxBatchBytes(),
@@ -54,6 +66,9 @@ func NewSender[T Message](ctx context.Context, config *Config, sendfn sendBatchF
 		wg:                &sync.WaitGroup{},
 		cancelFn:          func() {},
 		ignoreSendErrors:  config.IgnoreSendErrors,
+		metrics:           options.metrics,
+		tracer:            options.tracer,
+		spanAttrs:         trace.WithAttributes(attribute.String(processorAttributeKey, options.processorName)),
 	}
 
 	if config.AutoTune.Enabled {
@@ -69,6 +84,7 @@ func NewSender[T Message](ctx context.Context, config *Config, sendfn sendBatchF
 		return nil, err
 	}
 	s.queueBytesSema = synclib.NewWeightedSemaphore(int64(maxQueueBytes))
+	s.stats = newSenderStats(options.processorName, maxQueueBytes)
 
 	// start the send process in the background
 	go func() {
@@ -89,6 +105,9 @@ func (s *Sender[T]) SendMessage(ctx context.Context, msg *WALMessage[T]) error {
 	if msg == nil {
 		return nil
 	}
+	if s.tracer != nil {
+		msg.spanContext = trace.SpanContextFromContext(ctx)
+	}
 	// make sure we don't reach the queue memory limit before adding the new
 	// message to the channel. This will block until messages have been read
 	// from the channel and their size is released
@@ -99,6 +118,7 @@ func (s *Sender[T]) SendMessage(ctx context.Context, msg *WALMessage[T]) error {
 			return err
 		}
 	}
+	s.stats.addQueuedBytes(msgSize)
 
 	// Add the message to the channel for processing. This will block if the
 	// channel is full, or if there are no readers for the channel. If there are no
@@ -135,8 +155,11 @@ func (s *Sender[T]) send(ctx context.Context) error {
 		for batch := range batchChan {
 			// If the send fails, the writer goroutine returns an error over the
 			// error channel and shuts down.
+			s.stats.setInFlight(len(batch.messages), batch.totalBytes)
 			err := s.sendBatch(context.Background(), batch)
 			s.queueBytesSema.Release(int64(batch.totalBytes))
+			s.stats.addQueuedBytes(-int64(batch.totalBytes))
+			s.stats.setInFlight(0, 0)
 			if err != nil {
 				s.logger.Error(err, "failed to send batch")
 				if s.ignoreSendErrors {
@@ -158,6 +181,7 @@ func (s *Sender[T]) send(ctx context.Context) error {
 		case sendErr := <-sendErrChan:
 			return sendErr
 		}
+		s.stats.setPending(0, 0)
 		return nil
 	}
 
@@ -193,6 +217,7 @@ func (s *Sender[T]) send(ctx context.Context) error {
 				}
 
 				msgBatch.add(msg)
+				s.stats.setPending(len(msgBatch.messages), msgBatch.totalBytes)
 
 				// if the batch has reached the max allowed size, don't wait for
 				// the next tick and send. If we receive a keep alive, send
@@ -230,6 +255,7 @@ func (s *Sender[T]) Close() {
 		if s.batchBytesTuner != nil {
 			s.batchBytesTuner.close()
 		}
+		s.stats.unregister()
 	})
 }
 
@@ -254,7 +280,19 @@ func (s *Sender[T]) maxBatchSizeReached(batchSize int) bool {
 	return int64(batchSize) >= s.maxBatchSize
 }
 
-func (s *Sender[T]) sendBatch(ctx context.Context, batch *Batch[T]) error {
+// sendBatch sends the batch on input. The batch span is linked to the spans of
+// all its messages, since a batch aggregates messages from multiple traces.
+func (s *Sender[T]) sendBatch(ctx context.Context, batch *Batch[T]) (err error) {
+	ctx, span := otel.StartSpan(ctx, s.tracer, "batch.sendBatch", s.spanAttrs, trace.WithLinks(batch.links...))
+	defer otel.CloseSpan(span, err)
+
+	startTime := time.Now()
+	err = s.doSendBatch(ctx, batch)
+	s.metrics.record(ctx, len(batch.messages), batch.totalBytes, time.Since(startTime), err)
+	return err
+}
+
+func (s *Sender[T]) doSendBatch(ctx context.Context, batch *Batch[T]) error {
 	// if a batch bytes tuner is configured, and an optimal setting has not yet
 	// been found, use the tuner to send the batch
 	if s.batchBytesTuner != nil && !s.batchBytesTuner.hasConverged() {
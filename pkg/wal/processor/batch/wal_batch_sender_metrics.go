@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package batch
+
+import (
+	"context"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type SenderOption func(*senderOptions)
+
+type senderOptions struct {
+	metrics       *senderMetrics
+	tracer        trace.Tracer
+	processorName string
+}
+
+type senderMetrics struct {
+	batchSize    metric.Int64Histogram
+	batchBytes   metric.Int64Histogram
+	flushLatency metric.Int64Histogram
+	flushErrors  metric.Int64Counter
+	attributes   metric.MeasurementOption
+}
+
+const processorAttributeKey = "processor"
+
+// WithInstrumentation reports the size and the flush latency of the batches
+// sent, with the name of the processor on input as attribute, so that the
+// batching of the processors can be told apart. The batches sent are traced
+// with links to the spans of their messages. The processor name is also used
+// to identify the sender queue stats, even if the instrumentation is disabled.
+func WithInstrumentation(instrumentation *otel.Instrumentation, processorName string) SenderOption {
+	return func(o *senderOptions) {
+		o.processorName = processorName
+		if instrumentation == nil {
+			return
+		}
+		o.tracer = instrumentation.Tracer
+		if instrumentation.Meter == nil {
+			return
+		}
+		var err error
+		o.metrics, err = newSenderMetrics(instrumentation.Meter, processorName)
+		if err != nil {
+			// should never happen
+			panic(err)
+		}
+	}
+}
+
+func newSenderMetrics(meter metric.Meter, processorName string) (*senderMetrics, error) {
+	m := &senderMetrics{
+		attributes: metric.WithAttributes(attribute.String(processorAttributeKey, processorName)),
+	}
+
+	var err error
+	m.batchSize, err = meter.Int64Histogram("pgstream.batch.size",
+		metric.WithUnit("messages"),
+		metric.WithDescription("Distribution of the number of messages of the batches sent by the processor"))
+	if err != nil {
+		return nil, err
+	}
+
+	m.batchBytes, err = meter.Int64Histogram("pgstream.batch.bytes",
+		metric.WithUnit("bytes"),
+		metric.WithDescription("Distribution of the size in bytes of the batches sent by the processor"))
+	if err != nil {
+		return nil, err
+	}
+
+	m.flushLatency, err = meter.Int64Histogram("pgstream.batch.flush.latency",
+		metric.WithUnit("ms"),
+		metric.WithDescription("Distribution of the time taken by the processor to send a batch"))
+	if err != nil {
+		return nil, err
+	}
+
+	m.flushErrors, err = meter.Int64Counter("pgstream.batch.flush.errors",
+		metric.WithUnit("errors"),
+		metric.WithDescription("Number of batches the processor failed to send"))
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// record reports the metrics of a batch once it's been sent. The batches that
+// only contain commit positions are not reported.
+func (m *senderMetrics) record(ctx context.Context, messages, bytes int, latency time.Duration, err error) {
+	if m == nil || messages == 0 {
+		return
+	}
+	m.batchSize.Record(ctx, int64(messages), m.attributes)
+	m.batchBytes.Record(ctx, int64(bytes), m.attributes)
+	m.flushLatency.Record(ctx, latency.Milliseconds(), m.attributes)
+	if err != nil {
+		m.flushErrors.Add(ctx, 1, m.attributes)
+	}
+}
@@ -0,0 +1,121 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package batch
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// SenderStats is a snapshot of the queue of a batch sender.
+type SenderStats struct {
+	// Processor is the name of the processor the sender belongs to.
+	Processor string `json:"processor"`
+	// QueuedBytes is the size of the messages accepted by the sender that
+	// haven't been sent yet, including the batches being built and sent.
+	QueuedBytes   int64 `json:"queued_bytes"`
+	MaxQueueBytes int64 `json:"max_queue_bytes"`
+	// PendingMessages and PendingBytes are the size of the batch being built.
+	PendingMessages int64 `json:"pending_messages"`
+	PendingBytes    int64 `json:"pending_bytes"`
+	// InFlightMessages and InFlightBytes are the size of the batch being
+	// sent, if any.
+	InFlightMessages int64 `json:"in_flight_messages"`
+	InFlightBytes    int64 `json:"in_flight_bytes"`
+}
+
+// senderStats keeps the queue counters of a batch sender. They're updated by
+// the sender goroutines, and read concurrently when the stats are retrieved.
+type senderStats struct {
+	processor     string
+	maxQueueBytes int64
+
+	queuedBytes      atomic.Int64
+	pendingMessages  atomic.Int64
+	pendingBytes     atomic.Int64
+	inFlightMessages atomic.Int64
+	inFlightBytes    atomic.Int64
+}
+
+// senders keeps the stats of all the open batch senders of the process, so
+// that they can be retrieved for diagnostics without threading the senders
+// through the processors.
+var senders = &senderRegistry{stats: map[*senderStats]struct{}{}}
+
+type senderRegistry struct {
+	mutex sync.Mutex
+	stats map[*senderStats]struct{}
+}
+
+// Stats returns the queue stats of all the open batch senders of the process,
+// sorted by processor.
+func Stats() []SenderStats {
+	senders.mutex.Lock()
+	stats := make([]SenderStats, 0, len(senders.stats))
+	for s := range senders.stats {
+		stats = append(stats, s.snapshot())
+	}
+	senders.mutex.Unlock()
+
+	slices.SortStableFunc(stats, func(a, b SenderStats) int {
+		return cmp.Compare(a.Processor, b.Processor)
+	})
+	return stats
+}
+
+func newSenderStats(processor string, maxQueueBytes int64) *senderStats {
+	s := &senderStats{
+		processor:     processor,
+		maxQueueBytes: maxQueueBytes,
+	}
+	senders.mutex.Lock()
+	senders.stats[s] = struct{}{}
+	senders.mutex.Unlock()
+	return s
+}
+
+func (s *senderStats) unregister() {
+	if s == nil {
+		return
+	}
+	senders.mutex.Lock()
+	delete(senders.stats, s)
+	senders.mutex.Unlock()
+}
+
+func (s *senderStats) addQueuedBytes(bytes int64) {
+	if s == nil {
+		return
+	}
+	s.queuedBytes.Add(bytes)
+}
+
+func (s *senderStats) setPending(messages, bytes int) {
+	if s == nil {
+		return
+	}
+	s.pendingMessages.Store(int64(messages))
+	s.pendingBytes.Store(int64(bytes))
+}
+
+func (s *senderStats) setInFlight(messages, bytes int) {
+	if s == nil {
+		return
+	}
+	s.inFlightMessages.Store(int64(messages))
+	s.inFlightBytes.Store(int64(bytes))
+}
+
+func (s *senderStats) snapshot() SenderStats {
+	return SenderStats{
+		Processor:        s.processor,
+		QueuedBytes:      s.queuedBytes.Load(),
+		MaxQueueBytes:    s.maxQueueBytes,
+		PendingMessages:  s.pendingMessages.Load(),
+		PendingBytes:     s.pendingBytes.Load(),
+		InFlightMessages: s.inFlightMessages.Load(),
+		InFlightBytes:    s.inFlightBytes.Load(),
+	}
+}
@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package batch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestSender_stats(t *testing.T) {
+	t.Parallel()
+
+	const processorName = "test-stats-processor"
+	senderStats := func() (SenderStats, bool) {
+		for _, stats := range Stats() {
+			if stats.Processor == processorName {
+				return stats, true
+			}
+		}
+		return SenderStats{}, false
+	}
+
+	ctx := context.Background()
+	sending := make(chan struct{}, 2)
+	release := make(chan struct{})
+	sender, err := NewSender(ctx, &Config{
+		BatchTimeout:  time.Minute,
+		MaxBatchSize:  2,
+		MaxBatchBytes: 50,
+		MaxQueueBytes: 100,
+	}, func(context.Context, *Batch[*mockMessage]) error {
+		sending <- struct{}{}
+		<-release
+		return nil
+	}, log.NewNoopLogger(), WithInstrumentation(nil, processorName))
+	require.NoError(t, err)
+	defer sender.Close()
+
+	// the first batch is sent once full, and blocks while the next message
+	// is added to the pending batch
+	for i := range 3 {
+		require.NoError(t, sender.SendMessage(ctx, NewWALMessage(&mockMessage{id: uint(i)}, wal.CommitPosition("1"))))
+		if i == 1 {
+			<-sending
+		}
+	}
+
+	require.Eventually(t, func() bool {
+		stats, found := senderStats()
+		return found && stats.PendingMessages == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	stats, _ := senderStats()
+	require.Equal(t, SenderStats{
+		Processor:        processorName,
+		QueuedBytes:      3,
+		MaxQueueBytes:    100,
+		PendingMessages:  1,
+		PendingBytes:     1,
+		InFlightMessages: 2,
+		InFlightBytes:    2,
+	}, stats)
+
+	close(release)
+	require.Eventually(t, func() bool {
+		stats, _ := senderStats()
+		return stats.InFlightMessages == 0 && stats.QueuedBytes == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the stats of closed senders are not reported
+	sender.Close()
+	_, found := senderStats()
+	require.False(t, found)
+}
@@ -12,7 +12,12 @@ import (
 	"github.com/stretchr/testify/require"
 	syncmocks "github.com/xataio/pgstream/internal/sync/mocks"
 	"github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/wal"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestSender_SendMessage(t *testing.T) {
@@ -464,3 +469,51 @@ func TestSender(t *testing.T) {
 		}
 	}
 }
+
+func TestSender_sendBatch_spanLinks(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	newSpanContext := func(i byte) trace.SpanContext {
+		return trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{i},
+			SpanID:     trace.SpanID{i},
+			TraceFlags: trace.FlagsSampled,
+		})
+	}
+
+	options := &senderOptions{}
+	WithInstrumentation(&otel.Instrumentation{Tracer: tracer}, "test-processor")(options)
+	sender := &Sender[*mockMessage]{
+		logger: log.NewNoopLogger(),
+		sendBatchFn: func(ctx context.Context, b *Batch[*mockMessage]) error {
+			return nil
+		},
+		tracer:    options.tracer,
+		spanAttrs: trace.WithAttributes(attribute.String(processorAttributeKey, options.processorName)),
+	}
+
+	batch := &Batch[*mockMessage]{}
+	for i, ctx := range []context.Context{
+		trace.ContextWithSpanContext(context.Background(), newSpanContext(1)),
+		context.Background(),
+		trace.ContextWithSpanContext(context.Background(), newSpanContext(2)),
+	} {
+		msg := NewWALMessage(&mockMessage{id: uint(i + 1)}, "")
+		msg.spanContext = trace.SpanContextFromContext(ctx)
+		batch.add(msg)
+	}
+
+	require.NoError(t, sender.sendBatch(context.Background(), batch.drain()))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "batch.sendBatch", spans[0].Name())
+	require.Equal(t, []attribute.KeyValue{attribute.String(processorAttributeKey, "test-processor")}, spans[0].Attributes())
+	links := spans[0].Links()
+	require.Len(t, links, 2)
+	require.Equal(t, newSpanContext(1), links[0].SpanContext)
+	require.Equal(t, newSpanContext(2), links[1].SpanContext)
+}
@@ -2,7 +2,10 @@
 
 package batch
 
-import "github.com/xataio/pgstream/pkg/wal"
+import (
+	"github.com/xataio/pgstream/pkg/wal"
+	"go.opentelemetry.io/otel/trace"
+)
 
 type Message interface {
 	Size() int
@@ -14,6 +17,9 @@ type Message interface {
 type WALMessage[T Message] struct {
 	message  T
 	position wal.CommitPosition
+	// spanContext is the span the message was produced in, if any, linked
+	// from the span of the batch it's sent in.
+	spanContext trace.SpanContext
 }
 
 func NewWALMessage[T Message](msg T, pos wal.CommitPosition) *WALMessage[T] {
@@ -0,0 +1,47 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/xataio/pgstream/internal/json"
+	"golang.org/x/oauth2/google"
+)
+
+const bigqueryScope = "https://www.googleapis.com/auth/bigquery"
+
+// findCredentials returns the credentials of the service account or
+// authorized user JSON key on input, or the application default credentials
+// if none is provided.
+func findCredentials(ctx context.Context, path string) (*google.Credentials, error) {
+	if path == "" {
+		return google.FindDefaultCredentials(ctx, bigqueryScope)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading credentials file: %w", err)
+	}
+	return google.CredentialsFromJSON(ctx, b, bigqueryScope)
+}
+
+// credentialsProject returns the project of the credentials on input. The
+// authorized user credentials don't have one, so their quota project is used
+// instead, followed by the GOOGLE_CLOUD_PROJECT environment variable.
+func credentialsProject(creds *google.Credentials) string {
+	if creds.ProjectID != "" {
+		return creds.ProjectID
+	}
+	if len(creds.JSON) > 0 {
+		f := struct {
+			QuotaProjectID string `json:"quota_project_id"`
+		}{}
+		if err := json.Unmarshal(creds.JSON, &f); err == nil && f.QuotaProjectID != "" {
+			return f.QuotaProjectID
+		}
+	}
+	return os.Getenv("GOOGLE_CLOUD_PROJECT")
+}
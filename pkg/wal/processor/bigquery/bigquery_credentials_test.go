@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2/google"
+)
+
+func TestFindCredentials(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	tests := []struct {
+		name string
+		path string
+
+		wantProject string
+		wantErr     bool
+	}{
+		{
+			name:        "ok - service account",
+			path:        writeFile("service_account.json", `{"type":"service_account","project_id":"test-project","client_email":"pgstream@test-project.iam.gserviceaccount.com","private_key":"key"}`),
+			wantProject: "test-project",
+		},
+		{
+			name:        "ok - authorized user",
+			path:        writeFile("user.json", `{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"token","quota_project_id":"quota-project"}`),
+			wantProject: "quota-project",
+		},
+		{
+			name:    "error - unsupported credentials",
+			path:    writeFile("unknown.json", `{"type":"unknown"}`),
+			wantErr: true,
+		},
+		{
+			name:    "error - missing file",
+			path:    filepath.Join(dir, "missing.json"),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			creds, err := findCredentials(context.Background(), tc.path)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantProject, credentialsProject(creds))
+		})
+	}
+}
+
+func TestCredentialsProject(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "test-project", credentialsProject(&google.Credentials{
+		ProjectID: "test-project",
+		JSON:      []byte(`{"quota_project_id":"quota-project"}`),
+	}))
+	require.Equal(t, "quota-project", credentialsProject(&google.Credentials{
+		JSON: []byte(`{"type":"authorized_user","quota_project_id":"quota-project"}`),
+	}))
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// apiError is an error returned by the BigQuery APIs, with the gRPC code of
+// the error. The errors of the REST API are mapped to their gRPC codes.
+type apiError struct {
+	code    codes.Code
+	message string
+	// retryDelay is how long the server asked to wait before retrying, when
+	// the request was rejected by a quota
+	retryDelay time.Duration
+}
+
+// httpErrorBody is the body of the REST API errors.
+type httpErrorBody struct {
+	Error *httpErrorDetails `json:"error"`
+}
+
+type httpErrorDetails struct {
+	Message string `json:"message"`
+	Errors  []struct {
+		Reason string `json:"reason"`
+	} `json:"errors"`
+}
+
+// retryableCodes are the codes of the errors caused by transient failures or
+// quotas, which succeed when retried
+var retryableCodes = []codes.Code{
+	codes.Unknown,
+	codes.DeadlineExceeded,
+	codes.ResourceExhausted,
+	codes.Aborted,
+	codes.Internal,
+	codes.Unavailable,
+}
+
+// schemaMismatchMessage is the message of the errors returned when the rows
+// are appended before the write stream picks up the columns recently added
+// to the table
+const schemaMismatchMessage = "Input schema has more fields than BigQuery schema"
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.code, e.message)
+}
+
+func (e *apiError) retryable() bool {
+	if e.code == codes.InvalidArgument && strings.Contains(e.message, schemaMismatchMessage) {
+		return true
+	}
+	return slices.Contains(retryableCodes, e.code)
+}
+
+// isPermanent returns true if the error on input won't succeed when retried.
+// Errors that are not returned by BigQuery, such as connection errors, are
+// considered transient.
+func isPermanent(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && !apiErr.retryable()
+}
+
+func isCode(err error, code codes.Code) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.code == code
+}
+
+// newHTTPError returns the error of the REST API response on input. Quota and
+// rate limit errors are mapped to resource exhausted, even when their status
+// is forbidden.
+func newHTTPError(statusCode int, body []byte) *apiError {
+	apiErr := &apiError{code: httpStatusCode(statusCode), message: strings.TrimSpace(string(body))}
+
+	b := &httpErrorBody{}
+	if err := json.Unmarshal(body, b); err != nil || b.Error == nil {
+		return apiErr
+	}
+
+	apiErr.message = b.Error.Message
+	for _, e := range b.Error.Errors {
+		switch e.Reason {
+		case "rateLimitExceeded", "quotaExceeded":
+			apiErr.code = codes.ResourceExhausted
+		case "backendError", "internalError":
+			apiErr.code = codes.Unavailable
+		}
+	}
+	return apiErr
+}
+
+func httpStatusCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	default:
+		if statusCode >= http.StatusInternalServerError {
+			return codes.Internal
+		}
+		return codes.Unknown
+	}
+}
+
+// newStatusError returns the error of the gRPC status on input, including
+// the retry delay of the quota errors.
+func newStatusError(st *status.Status) *apiError {
+	apiErr := &apiError{code: st.Code(), message: st.Message()}
+	for _, detail := range st.Details() {
+		if retryInfo, ok := detail.(*errdetails.RetryInfo); ok && retryInfo.GetRetryDelay() != nil {
+			apiErr.retryDelay = retryInfo.GetRetryDelay().AsDuration()
+		}
+	}
+	return apiErr
+}
+
+// mapGRPCError returns the api error of the gRPC errors, leaving the other
+// errors unchanged.
+func mapGRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if st, ok := status.FromError(err); ok {
+		return newStatusError(st)
+	}
+	return err
+}
@@ -0,0 +1,532 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"github.com/google/uuid"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// field is a column of a BigQuery table, as represented in the REST API.
+type field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode,omitempty"`
+}
+
+// rowSchema is the proto schema of the rows appended to a table. The rows are
+// encoded as proto2 messages with one optional field per column, numbered in
+// the order of the fields, so that null values are omitted.
+type rowSchema struct {
+	fields     []field
+	index      map[string]int
+	descriptor *descriptorpb.DescriptorProto
+}
+
+const (
+	// opColumn and lsnColumn are the columns added to the change log tables,
+	// with the operation and the LSN of each change
+	opColumn  = "_op"
+	lsnColumn = "_lsn"
+
+	// changeTypeColumn and changeSequenceColumn are the pseudo columns of the
+	// BigQuery change data capture, with the type of the change and its order
+	changeTypeColumn     = "_CHANGE_TYPE"
+	changeSequenceColumn = "_CHANGE_SEQUENCE_NUMBER"
+
+	changeTypeUpsert = "UPSERT"
+	changeTypeDelete = "DELETE"
+
+	typeInt64      = "INT64"
+	typeFloat64    = "FLOAT64"
+	typeBool       = "BOOL"
+	typeNumeric    = "NUMERIC"
+	typeBigNumeric = "BIGNUMERIC"
+	typeString     = "STRING"
+	typeBytes      = "BYTES"
+	typeJSON       = "JSON"
+	typeDate       = "DATE"
+	typeDatetime   = "DATETIME"
+	typeTime       = "TIME"
+	typeTimestamp  = "TIMESTAMP"
+
+	modeNullable = "NULLABLE"
+	modeRepeated = "REPEATED"
+
+	rowMessageName = "pgstream_row"
+
+	// numeric precision and scale limits of the BigQuery numeric types
+	maxNumericScale            = 9
+	maxNumericIntegerDigits    = 29
+	maxBigNumericScale         = 38
+	maxBigNumericIntegerDigits = 38
+
+	secondsPerDay  = 24 * 60 * 60
+	datetimeLayout = "2006-01-02 15:04:05.999999"
+)
+
+var (
+	// pgTypeModifiers matches the modifiers of a postgres type, i.e. the
+	// precision and scale of numeric(10,2)
+	pgTypeModifiers = regexp.MustCompile(`\(\s*([0-9]+)\s*(?:,\s*([0-9]+)\s*)?\)`)
+	protoFieldName  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+	// timeLayouts are the formats of the postgres timestamps and dates, with
+	// and without time zone
+	timeLayouts = []string{
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999Z07",
+		"2006-01-02 15:04:05.999999999",
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999",
+		time.DateOnly,
+	}
+
+	errUnsupportedValue = errors.New("unsupported value")
+	errUnknownColumn    = errors.New("column not found in the table schema")
+)
+
+// bigqueryType returns the BigQuery type for the postgres type on input.
+// Booleans, integers, floating point numbers, numerics with a precision that
+// fits the BigQuery numerics, json, binary data, dates, times and timestamps
+// are mapped to their BigQuery types, and all the other types, including
+// arrays, are written as strings.
+func bigqueryType(pgType string) string {
+	t := strings.ToLower(strings.TrimSpace(pgType))
+	if strings.HasSuffix(t, "[]") {
+		return typeString
+	}
+	var modifiers []string
+	if m := pgTypeModifiers.FindStringSubmatch(t); m != nil {
+		modifiers = m[1:]
+		t = strings.Join(strings.Fields(strings.Replace(t, m[0], " ", 1)), " ")
+	}
+
+	switch t {
+	case "boolean", "bool":
+		return typeBool
+	case "smallint", "integer", "int", "int2", "int4", "bigint", "int8",
+		"smallserial", "serial", "bigserial", "serial2", "serial4", "serial8":
+		return typeInt64
+	case "real", "float4", "double precision", "float8":
+		return typeFloat64
+	case "numeric", "decimal":
+		if len(modifiers) == 0 {
+			return typeString
+		}
+		precision, _ := strconv.Atoi(modifiers[0])
+		scale, _ := strconv.Atoi(modifiers[1])
+		switch {
+		case scale <= maxNumericScale && precision-scale <= maxNumericIntegerDigits:
+			return typeNumeric
+		case scale <= maxBigNumericScale && precision-scale <= maxBigNumericIntegerDigits:
+			return typeBigNumeric
+		default:
+			return typeString
+		}
+	case "json", "jsonb":
+		return typeJSON
+	case "bytea":
+		return typeBytes
+	case "date":
+		return typeDate
+	case "time", "time without time zone":
+		return typeTime
+	case "timestamp", "timestamp without time zone":
+		return typeDatetime
+	case "timestamptz", "timestamp with time zone":
+		return typeTimestamp
+	default:
+		return typeString
+	}
+}
+
+// normalizeType returns the standard SQL name of the BigQuery type on input,
+// since the REST API returns the legacy names of some types.
+func normalizeType(t string) string {
+	switch t = strings.ToUpper(t); t {
+	case "INTEGER":
+		return typeInt64
+	case "FLOAT":
+		return typeFloat64
+	case "BOOLEAN":
+		return typeBool
+	default:
+		return t
+	}
+}
+
+// tableFields returns the fields of the BigQuery table for the schema log
+// table on input. All the fields are nullable, since deletes are written with
+// only the identity columns in change log mode, and columns can only be added
+// as nullable.
+func tableFields(tableDef *schemalog.Table) []field {
+	fields := make([]field, 0, len(tableDef.Columns))
+	for _, col := range tableDef.Columns {
+		fields = append(fields, field{Name: col.Name, Type: bigqueryType(col.DataType), Mode: modeNullable})
+	}
+	return fields
+}
+
+// columnFields returns the fields for the wal columns on input.
+func columnFields(columns []wal.Column) []field {
+	fields := make([]field, 0, len(columns))
+	for _, col := range columns {
+		fields = append(fields, field{Name: col.Name, Type: bigqueryType(col.Type), Mode: modeNullable})
+	}
+	return fields
+}
+
+// missingFields returns the fields on input that are not part of the table
+// fields. Column names are case insensitive in BigQuery.
+func missingFields(tableFields, fields []field) []field {
+	existing := make(map[string]struct{}, len(tableFields))
+	for _, f := range tableFields {
+		existing[strings.ToLower(f.Name)] = struct{}{}
+	}
+	missing := []field{}
+	for _, f := range fields {
+		if _, found := existing[strings.ToLower(f.Name)]; !found {
+			missing = append(missing, f)
+			existing[strings.ToLower(f.Name)] = struct{}{}
+		}
+	}
+	return missing
+}
+
+// newRowSchema returns the row schema for the table fields on input. Repeated
+// and record fields are not written by the sink, and are left out of the
+// schema.
+func newRowSchema(tableFields []field) *rowSchema {
+	s := &rowSchema{
+		index: make(map[string]int, len(tableFields)),
+		descriptor: &descriptorpb.DescriptorProto{
+			Name: proto.String(rowMessageName),
+		},
+	}
+	for _, f := range tableFields {
+		typ := normalizeType(f.Type)
+		protoType, supported := protoFieldType(typ)
+		if !supported || f.Mode == modeRepeated {
+			continue
+		}
+
+		number := int32(len(s.fields) + 1)
+		fieldDescriptor := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(f.Name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   protoType.Enum(),
+		}
+		if !protoFieldName.MatchString(f.Name) {
+			fieldDescriptor.Name = proto.String(fmt.Sprintf("_field%d", number))
+			// the column_name option maps the field to a column whose name
+			// is not a valid proto field name
+			fieldDescriptor.Options = &descriptorpb.FieldOptions{}
+			proto.SetExtension(fieldDescriptor.Options, storagepb.E_ColumnName, f.Name)
+		}
+
+		s.index[strings.ToLower(f.Name)] = len(s.fields)
+		s.fields = append(s.fields, field{Name: f.Name, Type: typ, Mode: f.Mode})
+		s.descriptor.Field = append(s.descriptor.Field, fieldDescriptor)
+	}
+	return s
+}
+
+func protoFieldType(typ string) (descriptorpb.FieldDescriptorProto_Type, bool) {
+	switch typ {
+	case typeInt64, typeTimestamp:
+		return descriptorpb.FieldDescriptorProto_TYPE_INT64, true
+	case typeFloat64:
+		return descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, true
+	case typeBool:
+		return descriptorpb.FieldDescriptorProto_TYPE_BOOL, true
+	case typeDate:
+		return descriptorpb.FieldDescriptorProto_TYPE_INT32, true
+	case typeBytes:
+		return descriptorpb.FieldDescriptorProto_TYPE_BYTES, true
+	case typeString, typeNumeric, typeBigNumeric, typeJSON, typeDatetime, typeTime, "GEOGRAPHY", "INTERVAL":
+		return descriptorpb.FieldDescriptorProto_TYPE_STRING, true
+	default:
+		return 0, false
+	}
+}
+
+// encode returns the serialized proto message of the row with the columns on
+// input. Null values are omitted.
+func (s *rowSchema) encode(columns []wal.Column) ([]byte, error) {
+	b := []byte{}
+	for _, col := range columns {
+		i, found := s.index[strings.ToLower(col.Name)]
+		if !found {
+			return nil, fmt.Errorf("%w: %s", errUnknownColumn, col.Name)
+		}
+		if col.Value == nil {
+			continue
+		}
+		var err error
+		if b, err = appendValue(b, protowire.Number(i+1), s.fields[i].Type, col.Value); err != nil {
+			return nil, fmt.Errorf("column %s: %w", col.Name, err)
+		}
+	}
+	return b, nil
+}
+
+func appendValue(b []byte, number protowire.Number, typ string, v any) ([]byte, error) {
+	switch typ {
+	case typeInt64:
+		i, err := toInt64(v)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, number, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(i)), nil
+	case typeFloat64:
+		f, err := toFloat64(v)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, number, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(f)), nil
+	case typeBool:
+		value, err := toBool(v)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, number, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(value)), nil
+	case typeTimestamp:
+		t, err := toTime(v, true)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, number, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(t.UnixMicro())), nil
+	case typeDate:
+		t, err := toTime(v, false)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, number, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(int64(daysSinceEpoch(t)))), nil
+	case typeDatetime:
+		t, err := toTime(v, false)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		return protowire.AppendString(b, t.Format(datetimeLayout)), nil
+	case typeBytes:
+		value, err := toBytes(v)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		return protowire.AppendBytes(b, value), nil
+	default:
+		s, err := toString(v)
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		return protowire.AppendString(b, s), nil
+	}
+}
+
+func toBool(v any) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("%w: %q is not a boolean", errUnsupportedValue, v)
+		}
+		return b, nil
+	default:
+		return false, fmt.Errorf("%w: %T is not a boolean", errUnsupportedValue, v)
+	}
+}
+
+func toInt64(v any) (int64, error) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("%w: %d overflows int64", errUnsupportedValue, v)
+		}
+		return int64(v), nil
+	case float32:
+		return toInt64(float64(v))
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("%w: %v is not an integer", errUnsupportedValue, v)
+		}
+		return int64(v), nil
+	case string:
+		return parseInt64(v)
+	case json.Number:
+		return parseInt64(v.String())
+	default:
+		return 0, fmt.Errorf("%w: %T is not an integer", errUnsupportedValue, v)
+	}
+}
+
+func parseInt64(s string) (int64, error) {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not an integer", errUnsupportedValue, s)
+	}
+	return i, nil
+}
+
+func toFloat64(v any) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case string:
+		return parseFloat64(v)
+	case json.Number:
+		return parseFloat64(v.String())
+	default:
+		i, err := toInt64(v)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %T is not a number", errUnsupportedValue, v)
+		}
+		return float64(i), nil
+	}
+}
+
+func parseFloat64(s string) (float64, error) {
+	// postgres writes the special floating point values capitalised
+	switch s {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a number", errUnsupportedValue, s)
+	}
+	return f, nil
+}
+
+// toTime returns the time for the timestamp or date on input. Timestamps
+// without time zone are returned with the wall clock of the value in UTC.
+func toTime(v any, withTimeZone bool) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		if withTimeZone {
+			return v, nil
+		}
+		return time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), time.UTC), nil
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("%w: %q is not a timestamp", errUnsupportedValue, v)
+	default:
+		return time.Time{}, fmt.Errorf("%w: %T is not a timestamp", errUnsupportedValue, v)
+	}
+}
+
+func daysSinceEpoch(t time.Time) int32 {
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix()
+	days := date / secondsPerDay
+	if date%secondsPerDay < 0 {
+		days--
+	}
+	return int32(days)
+}
+
+// toBytes returns the binary value on input. The bytea values of the wal are
+// hex encoded strings with the \x prefix.
+func toBytes(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		if hexValue, found := strings.CutPrefix(v, `\x`); found {
+			b, err := hex.DecodeString(hexValue)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid hex bytea: %w", errUnsupportedValue, err)
+			}
+			return b, nil
+		}
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("%w: %T is not binary data", errUnsupportedValue, v)
+	}
+}
+
+// toString returns the string value of the value on input. Values decoded
+// from json and arrays are encoded back into json, and the values of the
+// snapshot, such as uuids and numerics, are converted into their postgres
+// text representation.
+func toString(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case json.Number:
+		return v.String(), nil
+	case [16]byte:
+		return uuid.UUID(v).String(), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case driver.Valuer:
+		value, err := v.Value()
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", errUnsupportedValue, err)
+		}
+		if value == nil {
+			return "", fmt.Errorf("%w: null %T", errUnsupportedValue, v)
+		}
+		return toString(value)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", errUnsupportedValue, err)
+		}
+		return string(b), nil
+	}
+}
@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+var testTableDef = &schemalog.Table{
+	Name: "users",
+	Columns: []schemalog.Column{
+		{Name: "id", DataType: "bigint"},
+		{Name: "name", DataType: "text", Nullable: true},
+		{Name: "created_at", DataType: "timestamp with time zone", Nullable: true},
+	},
+	PrimaryKeyColumns: []string{"id"},
+}
+
+func TestBigqueryType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"boolean":                     typeBool,
+		"integer":                     typeInt64,
+		"bigserial":                   typeInt64,
+		"double precision":            typeFloat64,
+		"numeric":                     typeString,
+		"numeric(10,2)":               typeNumeric,
+		"numeric(10)":                 typeNumeric,
+		"numeric(40,2)":               typeBigNumeric,
+		"numeric(30,20)":              typeBigNumeric,
+		"numeric(80,2)":               typeString,
+		"text":                        typeString,
+		"character varying(255)":      typeString,
+		"uuid":                        typeString,
+		"jsonb":                       typeJSON,
+		"bytea":                       typeBytes,
+		"date":                        typeDate,
+		"time without time zone":      typeTime,
+		"timestamp without time zone": typeDatetime,
+		"timestamp(3) with time zone": typeTimestamp,
+		"integer[]":                   typeString,
+		"USER-DEFINED":                typeString,
+	}
+
+	for pgType, wantType := range tests {
+		require.Equal(t, wantType, bigqueryType(pgType), pgType)
+	}
+}
+
+func TestTableFields(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, []field{
+		{Name: "id", Type: typeInt64, Mode: modeNullable},
+		{Name: "name", Type: typeString, Mode: modeNullable},
+		{Name: "created_at", Type: typeTimestamp, Mode: modeNullable},
+	}, tableFields(testTableDef))
+}
+
+func TestMissingFields(t *testing.T) {
+	t.Parallel()
+
+	existing := []field{
+		{Name: "id", Type: typeInt64},
+		{Name: "Name", Type: typeString},
+	}
+	fields := []field{
+		{Name: "id", Type: typeInt64},
+		{Name: "name", Type: typeString},
+		{Name: "email", Type: typeString},
+		{Name: "email", Type: typeString},
+	}
+	require.Equal(t, []field{{Name: "email", Type: typeString}}, missingFields(existing, fields))
+	require.Empty(t, missingFields(existing, existing))
+}
+
+func TestNewRowSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := newRowSchema([]field{
+		{Name: "id", Type: "INTEGER", Mode: modeNullable},
+		{Name: "tags", Type: typeString, Mode: modeRepeated},
+		{Name: "address", Type: "RECORD", Mode: modeNullable},
+		{Name: "first name", Type: typeString, Mode: modeNullable},
+		{Name: "created_at", Type: typeTimestamp, Mode: modeNullable},
+	})
+
+	require.Equal(t, []field{
+		{Name: "id", Type: typeInt64, Mode: modeNullable},
+		{Name: "first name", Type: typeString, Mode: modeNullable},
+		{Name: "created_at", Type: typeTimestamp, Mode: modeNullable},
+	}, schema.fields)
+	require.Len(t, schema.descriptor.Field, 3)
+
+	wantFields := []struct {
+		name string
+		typ  descriptorpb.FieldDescriptorProto_Type
+	}{
+		{name: "id", typ: descriptorpb.FieldDescriptorProto_TYPE_INT64},
+		{name: "_field2", typ: descriptorpb.FieldDescriptorProto_TYPE_STRING},
+		{name: "created_at", typ: descriptorpb.FieldDescriptorProto_TYPE_INT64},
+	}
+	for i, want := range wantFields {
+		f := schema.descriptor.Field[i]
+		require.Equal(t, want.name, f.GetName())
+		require.Equal(t, want.typ, f.GetType())
+		require.Equal(t, int32(i+1), f.GetNumber())
+		require.Equal(t, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL, f.GetLabel())
+	}
+
+	// the column name option is kept when the descriptor is serialized
+	b, err := proto.Marshal(schema.descriptor)
+	require.NoError(t, err)
+	descriptor := &descriptorpb.DescriptorProto{}
+	require.NoError(t, proto.Unmarshal(b, descriptor))
+	require.Equal(t, "first name", proto.GetExtension(descriptor.Field[1].GetOptions(), storagepb.E_ColumnName))
+}
+
+func TestRowSchema_encode(t *testing.T) {
+	t.Parallel()
+
+	schema := newRowSchema([]field{
+		{Name: "id", Type: typeInt64},
+		{Name: "name", Type: typeString},
+		{Name: "score", Type: typeFloat64},
+		{Name: "active", Type: typeBool},
+		{Name: "created_at", Type: typeTimestamp},
+		{Name: "birthday", Type: typeDate},
+		{Name: "updated_at", Type: typeDatetime},
+		{Name: "avatar", Type: typeBytes},
+		{Name: "settings", Type: typeJSON},
+		{Name: "balance", Type: typeNumeric},
+	})
+
+	tests := []struct {
+		name    string
+		columns []wal.Column
+
+		wantValues map[protowire.Number]any
+		wantErr    error
+	}{
+		{
+			name: "wal values",
+			columns: []wal.Column{
+				{Name: "id", Value: float64(42)},
+				{Name: "Name", Value: "alice"},
+				{Name: "score", Value: "NaN"},
+				{Name: "active", Value: true},
+				{Name: "created_at", Value: "2024-01-02 03:04:05.123456+02"},
+				{Name: "birthday", Value: "1969-12-31"},
+				{Name: "updated_at", Value: "2024-01-02 03:04:05.5"},
+				{Name: "avatar", Value: `\x0102`},
+				{Name: "settings", Value: map[string]any{"theme": "dark"}},
+				{Name: "balance", Value: "12.34"},
+			},
+
+			wantValues: map[protowire.Number]any{
+				1:  uint64(42),
+				2:  "alice",
+				3:  math.Float64bits(math.NaN()),
+				4:  uint64(1),
+				5:  uint64(time.Date(2024, 1, 2, 1, 4, 5, 123456000, time.UTC).UnixMicro()),
+				6:  uint64(math.MaxUint64), // -1 days as a varint
+				7:  "2024-01-02 03:04:05.5",
+				8:  string([]byte{1, 2}),
+				9:  `{"theme":"dark"}`,
+				10: "12.34",
+			},
+		},
+		{
+			name: "nulls are omitted",
+			columns: []wal.Column{
+				{Name: "id", Value: int64(1)},
+				{Name: "name", Value: nil},
+			},
+
+			wantValues: map[protowire.Number]any{
+				1: uint64(1),
+			},
+		},
+		{
+			name: "error - unknown column",
+			columns: []wal.Column{
+				{Name: "email", Value: "alice@example.com"},
+			},
+
+			wantErr: errUnknownColumn,
+		},
+		{
+			name: "error - unsupported value",
+			columns: []wal.Column{
+				{Name: "id", Value: "forty-two"},
+			},
+
+			wantErr: errUnsupportedValue,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := schema.encode(tc.columns)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantValues, decodeRow(t, b))
+		})
+	}
+}
+
+// decodeRow returns the values of the encoded row, as varints, fixed 64 bit
+// values or strings.
+func decodeRow(t *testing.T, b []byte) map[protowire.Number]any {
+	values := map[protowire.Number]any{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			values[num], n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			values[num], n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			var value []byte
+			value, n = protowire.ConsumeBytes(b)
+			values[num] = string(value)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+	}
+	return values
+}
@@ -0,0 +1,316 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// tableClient manages the BigQuery datasets and tables.
+type tableClient interface {
+	// createDataset creates the dataset, unless it already exists
+	createDataset(ctx context.Context, project, dataset string) error
+	// getTableFields returns the fields of the table, or a not found error if
+	// the table doesn't exist
+	getTableFields(ctx context.Context, ref tableRef) ([]field, error)
+	createTable(ctx context.Context, ref tableRef, def *tableDefinition) error
+	// addFields adds the fields to the table, and returns all the table fields
+	addFields(ctx context.Context, ref tableRef, fields []field) ([]field, error)
+	// query runs the GoogleSQL statement on input, and waits for it to
+	// complete
+	query(ctx context.Context, project, statement string) error
+	// ping checks that the datasets of the project can be listed with the
+	// credentials of the client
+	ping(ctx context.Context, project string) error
+}
+
+// tableRef identifies a BigQuery table.
+type tableRef struct {
+	project string
+	dataset string
+	table   string
+}
+
+// tableDefinition is the definition of the tables created by the sink. The
+// tables with a primary key are clustered by it.
+type tableDefinition struct {
+	fields       []field
+	primaryKey   []string
+	maxStaleness time.Duration
+}
+
+// httpTableClient manages the tables through the BigQuery REST API. The
+// requests are authenticated by the http client.
+type httpTableClient struct {
+	client   *http.Client
+	baseURL  string
+	location string
+}
+
+type restTableReference struct {
+	ProjectID string `json:"projectId"`
+	DatasetID string `json:"datasetId"`
+	TableID   string `json:"tableId,omitempty"`
+}
+
+type restDataset struct {
+	DatasetReference restTableReference `json:"datasetReference"`
+	Location         string             `json:"location,omitempty"`
+}
+
+type restTable struct {
+	TableReference   *restTableReference   `json:"tableReference,omitempty"`
+	Schema           *restSchema           `json:"schema,omitempty"`
+	TableConstraints *restTableConstraints `json:"tableConstraints,omitempty"`
+	Clustering       *restClustering       `json:"clustering,omitempty"`
+	MaxStaleness     string                `json:"maxStaleness,omitempty"`
+}
+
+// restSchema keeps the fields as raw json, so that the attributes of the
+// existing fields that are not known by the sink, such as their descriptions
+// or policy tags, are kept when the schema is patched.
+type restSchema struct {
+	Fields []json.RawMessage `json:"fields"`
+}
+
+type restTableConstraints struct {
+	PrimaryKey restPrimaryKey `json:"primaryKey"`
+}
+
+type restPrimaryKey struct {
+	Columns []string `json:"columns"`
+}
+
+type restClustering struct {
+	Fields []string `json:"fields"`
+}
+
+type restQueryRequest struct {
+	Query        string `json:"query"`
+	UseLegacySQL bool   `json:"useLegacySql"`
+	TimeoutMs    int64  `json:"timeoutMs"`
+}
+
+type restQueryResponse struct {
+	JobComplete  bool `json:"jobComplete"`
+	JobReference struct {
+		JobID    string `json:"jobId"`
+		Location string `json:"location"`
+	} `json:"jobReference"`
+	Errors []struct {
+		Reason  string `json:"reason"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+const (
+	// maxClusteringFields is the maximum number of clustering fields of a
+	// BigQuery table
+	maxClusteringFields = 4
+	queryTimeout        = 30 * time.Second
+)
+
+func newHTTPTableClient(client *http.Client, baseURL, location string) *httpTableClient {
+	return &httpTableClient{
+		client:   client,
+		baseURL:  baseURL,
+		location: location,
+	}
+}
+
+func (c *httpTableClient) createDataset(ctx context.Context, project, dataset string) error {
+	body := &restDataset{
+		DatasetReference: restTableReference{ProjectID: project, DatasetID: dataset},
+		Location:         c.location,
+	}
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/projects/%s/datasets", url.PathEscape(project)), body, nil)
+	if isCode(err, codes.AlreadyExists) {
+		return nil
+	}
+	return err
+}
+
+func (c *httpTableClient) getTableFields(ctx context.Context, ref tableRef) ([]field, error) {
+	t := &restTable{}
+	if err := c.do(ctx, http.MethodGet, tablePath(ref), nil, t); err != nil {
+		return nil, err
+	}
+	if t.Schema == nil {
+		return []field{}, nil
+	}
+	return parseFields(t.Schema.Fields)
+}
+
+func (c *httpTableClient) createTable(ctx context.Context, ref tableRef, def *tableDefinition) error {
+	fields, err := marshalFields(def.fields)
+	if err != nil {
+		return err
+	}
+	body := &restTable{
+		TableReference: &restTableReference{ProjectID: ref.project, DatasetID: ref.dataset, TableID: ref.table},
+		Schema:         &restSchema{Fields: fields},
+	}
+	if len(def.primaryKey) > 0 {
+		body.TableConstraints = &restTableConstraints{PrimaryKey: restPrimaryKey{Columns: def.primaryKey}}
+		body.Clustering = &restClustering{Fields: def.primaryKey[:min(len(def.primaryKey), maxClusteringFields)]}
+	}
+	if def.maxStaleness > 0 {
+		body.MaxStaleness = formatInterval(def.maxStaleness)
+	}
+
+	path := fmt.Sprintf("/projects/%s/datasets/%s/tables", url.PathEscape(ref.project), url.PathEscape(ref.dataset))
+	return c.do(ctx, http.MethodPost, path, body, nil)
+}
+
+func (c *httpTableClient) addFields(ctx context.Context, ref tableRef, fields []field) ([]field, error) {
+	t := &restTable{}
+	if err := c.do(ctx, http.MethodGet, tablePath(ref), nil, t); err != nil {
+		return nil, err
+	}
+	if t.Schema == nil {
+		t.Schema = &restSchema{}
+	}
+	existing, err := parseFields(t.Schema.Fields)
+	if err != nil {
+		return nil, err
+	}
+	added, err := marshalFields(missingFields(existing, fields))
+	if err != nil {
+		return nil, err
+	}
+	if len(added) == 0 {
+		return existing, nil
+	}
+
+	patch := &restTable{Schema: &restSchema{Fields: append(t.Schema.Fields, added...)}}
+	updated := &restTable{}
+	if err := c.do(ctx, http.MethodPatch, tablePath(ref), patch, updated); err != nil {
+		return nil, err
+	}
+	if updated.Schema == nil {
+		return nil, fmt.Errorf("patching table %s: missing schema in response", ref)
+	}
+	return parseFields(updated.Schema.Fields)
+}
+
+func (c *httpTableClient) query(ctx context.Context, project, statement string) error {
+	resp := &restQueryResponse{}
+	body := &restQueryRequest{Query: statement, TimeoutMs: queryTimeout.Milliseconds()}
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/projects/%s/queries", url.PathEscape(project)), body, resp); err != nil {
+		return err
+	}
+
+	for !resp.JobComplete {
+		path := fmt.Sprintf("/projects/%s/queries/%s?location=%s&timeoutMs=%d&maxResults=0",
+			url.PathEscape(project), url.PathEscape(resp.JobReference.JobID), url.QueryEscape(resp.JobReference.Location), queryTimeout.Milliseconds())
+		next := &restQueryResponse{}
+		if err := c.do(ctx, http.MethodGet, path, nil, next); err != nil {
+			return err
+		}
+		if next.JobReference.JobID == "" {
+			next.JobReference = resp.JobReference
+		}
+		resp = next
+	}
+	if len(resp.Errors) > 0 {
+		return &apiError{code: codes.InvalidArgument, message: resp.Errors[0].Message}
+	}
+	return nil
+}
+
+func (c *httpTableClient) ping(ctx context.Context, project string) error {
+	return c.do(ctx, http.MethodGet, fmt.Sprintf("/projects/%s/datasets?maxResults=1", url.PathEscape(project)), nil, nil)
+}
+
+func (c *httpTableClient) do(ctx context.Context, method, path string, body, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newHTTPError(resp.StatusCode, b)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(b, result); err != nil {
+		return fmt.Errorf("parsing response: %w", err)
+	}
+	return nil
+}
+
+func (r tableRef) String() string {
+	return r.project + "." + r.dataset + "." + r.table
+}
+
+// quoted returns the table path quoted for GoogleSQL.
+func (r tableRef) quoted() string {
+	return "`" + r.project + "`.`" + r.dataset + "`.`" + r.table + "`"
+}
+
+func tablePath(ref tableRef) string {
+	return fmt.Sprintf("/projects/%s/datasets/%s/tables/%s", url.PathEscape(ref.project), url.PathEscape(ref.dataset), url.PathEscape(ref.table))
+}
+
+func parseFields(raw []json.RawMessage) ([]field, error) {
+	fields := make([]field, 0, len(raw))
+	for _, r := range raw {
+		f := field{}
+		if err := json.Unmarshal(r, &f); err != nil {
+			return nil, fmt.Errorf("parsing table schema: %w", err)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func marshalFields(fields []field) ([]json.RawMessage, error) {
+	raw := make([]json.RawMessage, 0, len(fields))
+	for _, f := range fields {
+		b, err := json.Marshal(f)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, b)
+	}
+	return raw, nil
+}
+
+// formatInterval returns the duration on input in the canonical format of the
+// BigQuery intervals, Y-M D H:M:S.
+func formatInterval(d time.Duration) string {
+	hours := int64(d / time.Hour)
+	minutes := int64(d % time.Hour / time.Minute)
+	seconds := int64(d % time.Minute / time.Second)
+	return fmt.Sprintf("0-0 0 %d:%d:%d", hours, minutes, seconds)
+}
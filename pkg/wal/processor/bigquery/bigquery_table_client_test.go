@@ -0,0 +1,338 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+	"google.golang.org/grpc/codes"
+)
+
+var testTable = tableRef{project: "test-project", dataset: "public", table: "users"}
+
+func TestHTTPTableClient_createDataset(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+
+		wantErr bool
+	}{
+		{
+			name: "ok",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodPost, r.Method)
+				require.Equal(t, "/projects/test-project/datasets", r.URL.Path)
+				require.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+				require.JSONEq(t, `{"datasetReference":{"projectId":"test-project","datasetId":"public"},"location":"EU"}`, readBody(t, r))
+				fmt.Fprint(w, `{}`)
+			},
+		},
+		{
+			name: "already exists",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"error":{"code":409,"message":"Already Exists: Dataset test-project:public","errors":[{"reason":"duplicate"}]}}`)
+			},
+		},
+		{
+			name: "error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"error":{"code":403,"message":"Access Denied","errors":[{"reason":"accessDenied"}]}}`)
+			},
+
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, closeServer := newTestTableClient(t, tc.handler)
+			defer closeServer()
+
+			err := client.createDataset(context.Background(), "test-project", "public")
+			if tc.wantErr {
+				require.True(t, isCode(err, codes.PermissionDenied), err)
+				require.True(t, isPermanent(err))
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestHTTPTableClient_getTableFields(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+
+		wantFields []field
+		wantCode   codes.Code
+	}{
+		{
+			name: "ok",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodGet, r.Method)
+				require.Equal(t, "/projects/test-project/datasets/public/tables/users", r.URL.Path)
+				fmt.Fprint(w, `{"schema":{"fields":[{"name":"id","type":"INTEGER","mode":"REQUIRED"},{"name":"name","type":"STRING","description":"user name"}]}}`)
+			},
+
+			wantFields: []field{
+				{Name: "id", Type: "INTEGER", Mode: "REQUIRED"},
+				{Name: "name", Type: typeString},
+			},
+		},
+		{
+			name: "not found",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error":{"code":404,"message":"Not found: Table test-project:public.users","errors":[{"reason":"notFound"}]}}`)
+			},
+
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "rate limit exceeded",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				fmt.Fprint(w, `{"error":{"code":403,"message":"Exceeded rate limits","errors":[{"reason":"rateLimitExceeded"}]}}`)
+			},
+
+			wantCode: codes.ResourceExhausted,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, closeServer := newTestTableClient(t, tc.handler)
+			defer closeServer()
+
+			fields, err := client.getTableFields(context.Background(), testTable)
+			if tc.wantCode != codes.OK {
+				require.True(t, isCode(err, tc.wantCode), err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantFields, fields)
+		})
+	}
+}
+
+func TestHTTPTableClient_createTable(t *testing.T) {
+	t.Parallel()
+
+	client, closeServer := newTestTableClient(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/projects/test-project/datasets/public/tables", r.URL.Path)
+		require.JSONEq(t, `{
+			"tableReference":{"projectId":"test-project","datasetId":"public","tableId":"users"},
+			"schema":{"fields":[{"name":"id","type":"INT64","mode":"NULLABLE"},{"name":"name","type":"STRING","mode":"NULLABLE"}]},
+			"tableConstraints":{"primaryKey":{"columns":["id"]}},
+			"clustering":{"fields":["id"]},
+			"maxStaleness":"0-0 0 1:30:0"
+		}`, readBody(t, r))
+		fmt.Fprint(w, `{}`)
+	})
+	defer closeServer()
+
+	err := client.createTable(context.Background(), testTable, &tableDefinition{
+		fields: []field{
+			{Name: "id", Type: typeInt64, Mode: modeNullable},
+			{Name: "name", Type: typeString, Mode: modeNullable},
+		},
+		primaryKey:   []string{"id"},
+		maxStaleness: 90 * time.Minute,
+	})
+	require.NoError(t, err)
+}
+
+func TestHTTPTableClient_addFields(t *testing.T) {
+	t.Parallel()
+
+	patched := false
+	client, closeServer := newTestTableClient(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/projects/test-project/datasets/public/tables/users", r.URL.Path)
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, `{"schema":{"fields":[{"name":"id","type":"INTEGER","policyTags":{"names":["tag"]}}]}}`)
+		case http.MethodPatch:
+			patched = true
+			// the attributes of the existing fields are kept
+			require.JSONEq(t, `{"schema":{"fields":[{"name":"id","type":"INTEGER","policyTags":{"names":["tag"]}},{"name":"email","type":"STRING","mode":"NULLABLE"}]}}`, readBody(t, r))
+			fmt.Fprint(w, `{"schema":{"fields":[{"name":"id","type":"INTEGER"},{"name":"email","type":"STRING","mode":"NULLABLE"}]}}`)
+		default:
+			t.Errorf("unexpected method %s", r.Method)
+		}
+	})
+	defer closeServer()
+
+	fields, err := client.addFields(context.Background(), testTable, []field{
+		{Name: "ID", Type: typeInt64, Mode: modeNullable},
+		{Name: "email", Type: typeString, Mode: modeNullable},
+	})
+	require.NoError(t, err)
+	require.True(t, patched)
+	require.Equal(t, []field{
+		{Name: "id", Type: "INTEGER"},
+		{Name: "email", Type: typeString, Mode: modeNullable},
+	}, fields)
+}
+
+func TestHTTPTableClient_query(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+
+		wantErr bool
+	}{
+		{
+			name: "ok",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodPost:
+					require.Equal(t, "/projects/test-project/queries", r.URL.Path)
+					require.JSONEq(t, `{"query":"TRUNCATE TABLE t","useLegacySql":false,"timeoutMs":30000}`, readBody(t, r))
+					fmt.Fprint(w, `{"jobComplete":false,"jobReference":{"jobId":"job-1","location":"EU"}}`)
+				case http.MethodGet:
+					require.Equal(t, "/projects/test-project/queries/job-1", r.URL.Path)
+					require.Equal(t, "EU", r.URL.Query().Get("location"))
+					fmt.Fprint(w, `{"jobComplete":true}`)
+				}
+			},
+		},
+		{
+			name: "error - job failed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"jobComplete":true,"errors":[{"reason":"invalidQuery","message":"Table not found"}]}`)
+			},
+
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client, closeServer := newTestTableClient(t, tc.handler)
+			defer closeServer()
+
+			err := client.query(context.Background(), "test-project", "TRUNCATE TABLE t")
+			if tc.wantErr {
+				require.True(t, isCode(err, codes.InvalidArgument), err)
+				require.ErrorContains(t, err, "Table not found")
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestHTTPTableClient_ping(t *testing.T) {
+	t.Parallel()
+
+	client, closeServer := newTestTableClient(t, func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+		require.Equal(t, "/projects/test-project/datasets", r.URL.Path)
+		require.Equal(t, "1", r.URL.Query().Get("maxResults"))
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"datasets":[]}`)
+	})
+	defer closeServer()
+
+	require.NoError(t, client.ping(context.Background(), "test-project"))
+}
+
+func TestNewHTTPError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+
+		wantErr *apiError
+	}{
+		{
+			name:       "api error",
+			statusCode: http.StatusBadRequest,
+			body:       `{"error":{"code":400,"message":"Invalid field name","errors":[{"reason":"invalid"}]}}`,
+
+			wantErr: &apiError{code: codes.InvalidArgument, message: "Invalid field name"},
+		},
+		{
+			name:       "quota exceeded",
+			statusCode: http.StatusForbidden,
+			body:       `{"error":{"code":403,"message":"Quota exceeded","errors":[{"reason":"quotaExceeded"}]}}`,
+
+			wantErr: &apiError{code: codes.ResourceExhausted, message: "Quota exceeded"},
+		},
+		{
+			name:       "backend error",
+			statusCode: http.StatusInternalServerError,
+			body:       `{"error":{"code":500,"message":"Backend error","errors":[{"reason":"backendError"}]}}`,
+
+			wantErr: &apiError{code: codes.Unavailable, message: "Backend error"},
+		},
+		{
+			name:       "not json",
+			statusCode: http.StatusBadGateway,
+			body:       "bad gateway\n",
+
+			wantErr: &apiError{code: codes.Unavailable, message: "bad gateway"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantErr, newHTTPError(tc.statusCode, []byte(tc.body)))
+		})
+	}
+}
+
+func TestFormatInterval(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "0-0 0 0:15:0", formatInterval(15*time.Minute))
+	require.Equal(t, "0-0 0 26:0:5", formatInterval(26*time.Hour+5*time.Second))
+}
+
+func newTestTableClient(t *testing.T, handler http.HandlerFunc) (*httpTableClient, func()) {
+	server := httptest.NewServer(handler)
+	client := &http.Client{Transport: &oauth2.Transport{
+		Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "test-token"}),
+		Base:   server.Client().Transport,
+	}}
+	return newHTTPTableClient(client, server.URL, "EU"), server.Close
+}
+
+func readBody(t *testing.T, r *http.Request) string {
+	b, err := io.ReadAll(r.Body)
+	require.NoError(t, err)
+	require.True(t, json.Valid(b))
+	return string(b)
+}
@@ -0,0 +1,204 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"cloud.google.com/go/bigquery/storage/managedwriter"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// writeClient appends rows to the BigQuery tables.
+type writeClient interface {
+	// createWriteStream creates a committed write stream for the table, and
+	// returns its name
+	createWriteStream(ctx context.Context, ref tableRef) (string, error)
+	appendRows(ctx context.Context, req *appendRowsRequest) error
+	finalizeWriteStream(ctx context.Context, stream string) error
+	close() error
+}
+
+// appendRowsRequest appends the proto encoded rows to the write stream. The
+// offset is the position of the first row in a committed stream, and is nil
+// for the default stream of the table.
+type appendRowsRequest struct {
+	writeStream string
+	offset      *int64
+	descriptor  *descriptorpb.DescriptorProto
+	rows        [][]byte
+}
+
+// managedWriteClient uses the BigQuery Storage Write API through the managed
+// writer of the BigQuery client library. A managed stream is kept open for
+// each write stream the rows are appended to, until it's finalized or the
+// client is closed.
+type managedWriteClient struct {
+	client *managedwriter.Client
+	// ctx is the context of the connections of the managed streams, which
+	// outlive the requests
+	ctx context.Context
+
+	mutex   sync.Mutex
+	streams map[string]*managedStream
+}
+
+// managedStream is an open managed stream, with the descriptor of the rows
+// last appended to it.
+type managedStream struct {
+	stream     *managedwriter.ManagedStream
+	descriptor *descriptorpb.DescriptorProto
+}
+
+func newManagedWriteClient(ctx context.Context, projectID string, opts ...option.ClientOption) (*managedWriteClient, error) {
+	client, err := managedwriter.NewClient(ctx, projectID, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating storage write api client: %w", err)
+	}
+	return &managedWriteClient{
+		client:  client,
+		ctx:     ctx,
+		streams: map[string]*managedStream{},
+	}, nil
+}
+
+func (c *managedWriteClient) createWriteStream(ctx context.Context, ref tableRef) (string, error) {
+	stream, err := c.client.CreateWriteStream(ctx, &storagepb.CreateWriteStreamRequest{
+		Parent:      tableResource(ref),
+		WriteStream: &storagepb.WriteStream{Type: storagepb.WriteStream_COMMITTED},
+	})
+	if err != nil {
+		return "", mapGRPCError(err)
+	}
+	return stream.GetName(), nil
+}
+
+func (c *managedWriteClient) appendRows(ctx context.Context, req *appendRowsRequest) error {
+	ms, err := c.managedStream(ctx, req.writeStream, req.descriptor)
+	if err != nil {
+		return err
+	}
+
+	opts := []managedwriter.AppendOption{}
+	if req.offset != nil {
+		opts = append(opts, managedwriter.WithOffset(*req.offset))
+	}
+	// the schema of the default stream changes when columns are added to the
+	// table
+	if !proto.Equal(ms.descriptor, req.descriptor) {
+		opts = append(opts, managedwriter.UpdateSchemaDescriptor(req.descriptor))
+		ms.descriptor = req.descriptor
+	}
+
+	result, err := ms.stream.AppendRows(ctx, req.rows, opts...)
+	if err != nil {
+		return mapGRPCError(err)
+	}
+	resp, err := result.FullResponse(ctx)
+	if rowErr := rowErrors(resp.GetRowErrors()); rowErr != nil {
+		return rowErr
+	}
+	err = mapGRPCError(err)
+	// the stream expired or was finalized, and won't accept more rows
+	if isCode(err, codes.NotFound) || isCode(err, codes.FailedPrecondition) {
+		c.closeStream(req.writeStream)
+	}
+	return err
+}
+
+func (c *managedWriteClient) finalizeWriteStream(ctx context.Context, stream string) error {
+	ms, err := c.managedStream(ctx, stream, nil)
+	if err != nil {
+		return err
+	}
+	defer c.closeStream(stream)
+
+	if _, err := ms.stream.Finalize(ctx); err != nil {
+		return mapGRPCError(err)
+	}
+	return nil
+}
+
+func (c *managedWriteClient) close() error {
+	c.mutex.Lock()
+	names := make([]string, 0, len(c.streams))
+	for name := range c.streams {
+		names = append(names, name)
+	}
+	c.mutex.Unlock()
+
+	for _, name := range names {
+		c.closeStream(name)
+	}
+	return c.client.Close()
+}
+
+// managedStream returns the open managed stream of the write stream on input,
+// opening it with the descriptor on input if needed.
+func (c *managedWriteClient) managedStream(ctx context.Context, name string, descriptor *descriptorpb.DescriptorProto) (*managedStream, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if ms, found := c.streams[name]; found {
+		return ms, nil
+	}
+
+	// the stream is looked up first, since the errors of the managed stream
+	// creation don't keep their status code
+	if _, err := c.client.GetWriteStream(ctx, &storagepb.GetWriteStreamRequest{Name: name}); err != nil {
+		return nil, mapGRPCError(err)
+	}
+
+	opts := []managedwriter.WriterOption{managedwriter.WithStreamName(name)}
+	if descriptor != nil {
+		opts = append(opts, managedwriter.WithSchemaDescriptor(descriptor))
+	}
+	stream, err := c.client.NewManagedStream(c.ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("opening write stream %s: %w", name, err)
+	}
+	ms := &managedStream{stream: stream, descriptor: descriptor}
+	c.streams[name] = ms
+	return ms, nil
+}
+
+func (c *managedWriteClient) closeStream(name string) {
+	c.mutex.Lock()
+	ms, found := c.streams[name]
+	delete(c.streams, name)
+	c.mutex.Unlock()
+
+	if found {
+		// the errors of the stream were already returned by its appends
+		ms.stream.Close() //nolint:errcheck
+	}
+}
+
+// rowErrors returns the error of the rows rejected by an append request, if
+// any. Rows rejected individually fail the whole request, which is reported as
+// an invalid argument error with the message of the first rejected row.
+func rowErrors(errs []*storagepb.RowError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &apiError{
+		code:    codes.InvalidArgument,
+		message: fmt.Sprintf("%d rows rejected, row %d: %s", len(errs), errs[0].GetIndex(), errs[0].GetMessage()),
+	}
+}
+
+func tableResource(ref tableRef) string {
+	return managedwriter.TableParentFromParts(ref.project, ref.dataset, ref.table)
+}
+
+// defaultStream returns the name of the default stream of the table, used by
+// the change data capture.
+func defaultStream(ref tableRef) string {
+	return tableResource(ref) + "/streams/_default"
+}
@@ -0,0 +1,323 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery/storage/apiv1/storagepb"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"google.golang.org/api/option"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestManagedWriteClient_createWriteStream(t *testing.T) {
+	t.Parallel()
+
+	server := &fakeWriteServer{
+		createWriteStreamFn: func(req *storagepb.CreateWriteStreamRequest) (*storagepb.WriteStream, error) {
+			require.Equal(t, "projects/test-project/datasets/public/tables/users", req.GetParent())
+			require.Equal(t, storagepb.WriteStream_COMMITTED, req.GetWriteStream().GetType())
+			return &storagepb.WriteStream{Name: "projects/test-project/datasets/public/tables/users/streams/s1"}, nil
+		},
+	}
+	client := newTestWriteClient(t, server)
+
+	stream, err := client.createWriteStream(context.Background(), testTable)
+	require.NoError(t, err)
+	require.Equal(t, "projects/test-project/datasets/public/tables/users/streams/s1", stream)
+
+	server.createWriteStreamFn = func(*storagepb.CreateWriteStreamRequest) (*storagepb.WriteStream, error) {
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+	_, err = client.createWriteStream(context.Background(), testTable)
+	require.True(t, isCode(err, codes.PermissionDenied), err)
+}
+
+func TestManagedWriteClient_appendRows(t *testing.T) {
+	t.Parallel()
+
+	schema := newRowSchema([]field{{Name: "id", Type: typeInt64}})
+	row, err := schema.encode([]wal.Column{{Name: "id", Value: int64(1)}})
+	require.NoError(t, err)
+	offset := int64(10)
+	okResponse := &storagepb.AppendRowsResponse{
+		Response: &storagepb.AppendRowsResponse_AppendResult_{AppendResult: &storagepb.AppendRowsResponse_AppendResult{}},
+	}
+
+	tests := []struct {
+		name     string
+		offset   *int64
+		response *storagepb.AppendRowsResponse
+		getErr   error
+
+		wantCode       codes.Code
+		wantRetryDelay time.Duration
+		wantErrMsg     string
+	}{
+		{
+			name:     "ok",
+			offset:   &offset,
+			response: okResponse,
+		},
+		{
+			name:     "ok - default stream",
+			response: okResponse,
+		},
+		{
+			name: "error - quota exceeded",
+			response: func() *storagepb.AppendRowsResponse {
+				retryInfo, err := anypb.New(&errdetails.RetryInfo{RetryDelay: durationpb.New(2 * time.Second)})
+				require.NoError(t, err)
+				return &storagepb.AppendRowsResponse{Response: &storagepb.AppendRowsResponse_Error{Error: &spb.Status{
+					Code:    int32(codes.ResourceExhausted),
+					Message: "quota exceeded",
+					Details: []*anypb.Any{retryInfo},
+				}}}
+			}(),
+
+			wantCode:       codes.ResourceExhausted,
+			wantRetryDelay: 2 * time.Second,
+		},
+		{
+			name: "error - row errors",
+			response: &storagepb.AppendRowsResponse{
+				Response:  &storagepb.AppendRowsResponse_Error{Error: &spb.Status{Code: int32(codes.InvalidArgument), Message: "rows rejected"}},
+				RowErrors: []*storagepb.RowError{{Index: 1, Message: "invalid value"}},
+			},
+
+			wantCode:   codes.InvalidArgument,
+			wantErrMsg: "InvalidArgument: 1 rows rejected, row 1: invalid value",
+		},
+		{
+			name:   "error - offset already exists",
+			offset: &offset,
+			response: &storagepb.AppendRowsResponse{
+				Response: &storagepb.AppendRowsResponse_Error{Error: &spb.Status{Code: int32(codes.AlreadyExists), Message: "offset already exists"}},
+			},
+
+			wantCode: codes.AlreadyExists,
+		},
+		{
+			name:   "error - stream not found",
+			getErr: status.Error(codes.NotFound, "stream not found"),
+
+			wantCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			stream := "projects/test-project/datasets/public/tables/users/streams/_default"
+			server := &fakeWriteServer{
+				getWriteStreamErr: tc.getErr,
+				appendRowsFn: func(req *storagepb.AppendRowsRequest) (*storagepb.AppendRowsResponse, error) {
+					require.Equal(t, stream, req.GetWriteStream())
+					if tc.offset != nil {
+						require.Equal(t, *tc.offset, req.GetOffset().GetValue())
+					} else {
+						require.Nil(t, req.GetOffset())
+					}
+					require.True(t, proto.Equal(schema.descriptor, req.GetProtoRows().GetWriterSchema().GetProtoDescriptor()))
+					require.Equal(t, [][]byte{row}, req.GetProtoRows().GetRows().GetSerializedRows())
+					return tc.response, nil
+				},
+			}
+			client := newTestWriteClient(t, server)
+
+			err := client.appendRows(context.Background(), &appendRowsRequest{
+				writeStream: stream,
+				offset:      tc.offset,
+				descriptor:  schema.descriptor,
+				rows:        [][]byte{row},
+			})
+			if tc.wantCode != codes.OK {
+				require.True(t, isCode(err, tc.wantCode), err)
+				apiErr := &apiError{}
+				require.ErrorAs(t, err, &apiErr)
+				require.Equal(t, tc.wantRetryDelay, apiErr.retryDelay)
+				if tc.wantErrMsg != "" {
+					require.EqualError(t, err, tc.wantErrMsg)
+				}
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestManagedWriteClient_appendRows_schemaUpdate(t *testing.T) {
+	t.Parallel()
+
+	schemas := []*rowSchema{
+		newRowSchema([]field{{Name: "id", Type: typeInt64}}),
+		newRowSchema([]field{{Name: "id", Type: typeInt64}, {Name: "name", Type: typeString}}),
+	}
+	server := &fakeWriteServer{
+		appendRowsFn: func(req *storagepb.AppendRowsRequest) (*storagepb.AppendRowsResponse, error) {
+			return &storagepb.AppendRowsResponse{
+				Response: &storagepb.AppendRowsResponse_AppendResult_{AppendResult: &storagepb.AppendRowsResponse_AppendResult{}},
+			}, nil
+		},
+	}
+	client := newTestWriteClient(t, server)
+
+	stream := defaultStream(testTable)
+	for _, schema := range append(schemas, schemas[1]) {
+		row, err := schema.encode([]wal.Column{{Name: "id", Value: int64(1)}})
+		require.NoError(t, err)
+		err = client.appendRows(context.Background(), &appendRowsRequest{
+			writeStream: stream,
+			descriptor:  schema.descriptor,
+			rows:        [][]byte{row},
+		})
+		require.NoError(t, err)
+	}
+
+	// the stream is opened once, and the schema is only sent when it changes
+	requests := server.getAppendRequests()
+	require.Len(t, requests, 3)
+	require.True(t, proto.Equal(schemas[0].descriptor, requests[0].GetProtoRows().GetWriterSchema().GetProtoDescriptor()))
+	require.True(t, proto.Equal(schemas[1].descriptor, requests[1].GetProtoRows().GetWriterSchema().GetProtoDescriptor()))
+	require.Nil(t, requests[2].GetProtoRows().GetWriterSchema())
+	require.Len(t, client.streams, 1)
+}
+
+func TestManagedWriteClient_finalizeWriteStream(t *testing.T) {
+	t.Parallel()
+
+	stream := "projects/test-project/datasets/public/tables/users/streams/s1"
+	finalized := []string{}
+	server := &fakeWriteServer{
+		appendRowsFn: func(req *storagepb.AppendRowsRequest) (*storagepb.AppendRowsResponse, error) {
+			return &storagepb.AppendRowsResponse{
+				Response: &storagepb.AppendRowsResponse_AppendResult_{AppendResult: &storagepb.AppendRowsResponse_AppendResult{
+					Offset: wrapperspb.Int64(0),
+				}},
+			}, nil
+		},
+		finalizeWriteStreamFn: func(req *storagepb.FinalizeWriteStreamRequest) (*storagepb.FinalizeWriteStreamResponse, error) {
+			finalized = append(finalized, req.GetName())
+			return &storagepb.FinalizeWriteStreamResponse{RowCount: 1}, nil
+		},
+	}
+	client := newTestWriteClient(t, server)
+
+	schema := newRowSchema([]field{{Name: "id", Type: typeInt64}})
+	row, err := schema.encode([]wal.Column{{Name: "id", Value: int64(1)}})
+	require.NoError(t, err)
+	offset := int64(0)
+	err = client.appendRows(context.Background(), &appendRowsRequest{
+		writeStream: stream,
+		offset:      &offset,
+		descriptor:  schema.descriptor,
+		rows:        [][]byte{row},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, client.finalizeWriteStream(context.Background(), stream))
+	require.Equal(t, []string{stream}, finalized)
+	require.Empty(t, client.streams)
+}
+
+func TestDefaultStream(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "projects/test-project/datasets/public/tables/users/streams/_default", defaultStream(testTable))
+}
+
+// fakeWriteServer is a Storage Write API server that records the append
+// requests received.
+type fakeWriteServer struct {
+	storagepb.UnimplementedBigQueryWriteServer
+
+	createWriteStreamFn   func(req *storagepb.CreateWriteStreamRequest) (*storagepb.WriteStream, error)
+	getWriteStreamErr     error
+	appendRowsFn          func(req *storagepb.AppendRowsRequest) (*storagepb.AppendRowsResponse, error)
+	finalizeWriteStreamFn func(req *storagepb.FinalizeWriteStreamRequest) (*storagepb.FinalizeWriteStreamResponse, error)
+
+	mutex          sync.Mutex
+	appendRequests []*storagepb.AppendRowsRequest
+}
+
+func newTestWriteClient(t *testing.T, fake *fakeWriteServer) *managedWriteClient {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	storagepb.RegisterBigQueryWriteServer(server, fake)
+	go server.Serve(listener) //nolint:errcheck
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := newManagedWriteClient(ctx, "test-project", option.WithGRPCConn(conn))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		client.close()
+		cancel()
+	})
+	return client
+}
+
+func (f *fakeWriteServer) CreateWriteStream(_ context.Context, req *storagepb.CreateWriteStreamRequest) (*storagepb.WriteStream, error) {
+	return f.createWriteStreamFn(req)
+}
+
+func (f *fakeWriteServer) GetWriteStream(_ context.Context, req *storagepb.GetWriteStreamRequest) (*storagepb.WriteStream, error) {
+	if f.getWriteStreamErr != nil {
+		return nil, f.getWriteStreamErr
+	}
+	return &storagepb.WriteStream{Name: req.GetName(), Type: storagepb.WriteStream_COMMITTED}, nil
+}
+
+func (f *fakeWriteServer) AppendRows(stream storagepb.BigQueryWrite_AppendRowsServer) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		f.mutex.Lock()
+		f.appendRequests = append(f.appendRequests, req)
+		f.mutex.Unlock()
+
+		resp, err := f.appendRowsFn(req)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *fakeWriteServer) FinalizeWriteStream(_ context.Context, req *storagepb.FinalizeWriteStreamRequest) (*storagepb.FinalizeWriteStreamResponse, error) {
+	return f.finalizeWriteStreamFn(req)
+}
+
+func (f *fakeWriteServer) getAppendRequests() []*storagepb.AppendRowsRequest {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]*storagepb.AppendRowsRequest{}, f.appendRequests...)
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/backoff"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+type Config struct {
+	// ProjectID is the Google Cloud project of the datasets. Defaults to the
+	// project of the credentials.
+	ProjectID string
+	// CredentialsFile is the path to the service account or authorized user
+	// JSON key used to authenticate. Defaults to the application default
+	// credentials, which are looked up in the GOOGLE_APPLICATION_CREDENTIALS
+	// file, the gcloud default credentials file, and the metadata server of
+	// the Google Cloud compute environments, in that order.
+	CredentialsFile string
+	// Dataset is the template of the dataset each table is written to. The
+	// {schema} and {table} placeholders are replaced with the name of the
+	// source schema and table. Required.
+	Dataset string
+	// Table is the template of the name of the target tables, with the same
+	// placeholders as the dataset template. Defaults to {schema}_{table}.
+	Table string
+	// Location of the datasets created by the sink. Defaults to the
+	// BigQuery default location.
+	Location string
+	// Mode is how updates and deletes are written to the tables. Defaults to
+	// change_log.
+	Mode Mode
+	// MaxStaleness is the max staleness of the tables created in upsert mode,
+	// which allows BigQuery to apply the changes in the background instead
+	// of when the table is queried. Defaults to 0, applying the changes at
+	// query time.
+	MaxStaleness time.Duration
+	// RetryPolicy is the backoff policy used to retry the requests that fail
+	// with transient errors, including the ones rejected because of quotas.
+	// Defaults to an exponential backoff.
+	RetryPolicy backoff.Config
+	// APIEndpoint and StorageEndpoint override the BigQuery REST API and
+	// Storage Write API endpoints.
+	APIEndpoint     string
+	StorageEndpoint string
+
+	Batch batch.Config
+}
+
+// Mode is the way the changes are written to the BigQuery tables.
+type Mode string
+
+const (
+	// ModeChangeLog appends every change to the table as a new row, through
+	// a committed write stream per table, with the operation and the LSN of
+	// the change in the _op and _lsn columns. The current state of the
+	// source table can then be built downstream, for example with MERGE
+	// statements.
+	ModeChangeLog Mode = "change_log"
+	// ModeUpsert applies the changes to the table with the BigQuery change
+	// data capture, upserting or deleting the rows by primary key through the
+	// default stream of the table. The tables need a primary key.
+	ModeUpsert Mode = "upsert"
+)
+
+const (
+	schemaPlaceholder = "{schema}"
+	tablePlaceholder  = "{table}"
+
+	defaultTableTemplate   = schemaPlaceholder + "_" + tablePlaceholder
+	defaultAPIEndpoint     = "https://bigquery.googleapis.com/bigquery/v2"
+	defaultStorageEndpoint = "bigquerystorage.googleapis.com:443"
+
+	defaultRetryInitialInterval = time.Second
+	defaultRetryMaxInterval     = time.Minute
+	defaultRetryMaxRetries      = 10
+)
+
+// invalidNameChars matches the characters replaced with underscores in the
+// dataset and table names
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func (c *Config) validate() error {
+	if c.Dataset == "" {
+		return errMissingDataset
+	}
+	switch c.mode() {
+	case ModeChangeLog, ModeUpsert:
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", errUnsupportedMode, c.Mode)
+	}
+}
+
+func (c *Config) mode() Mode {
+	if c.Mode != "" {
+		return c.Mode
+	}
+	return ModeChangeLog
+}
+
+func (c *Config) tableTemplate() string {
+	if c.Table != "" {
+		return c.Table
+	}
+	return defaultTableTemplate
+}
+
+func (c *Config) apiEndpoint() string {
+	if c.APIEndpoint != "" {
+		return strings.TrimSuffix(c.APIEndpoint, "/")
+	}
+	return defaultAPIEndpoint
+}
+
+func (c *Config) storageEndpoint() string {
+	if c.StorageEndpoint != "" {
+		return c.StorageEndpoint
+	}
+	return defaultStorageEndpoint
+}
+
+func (c *Config) retryPolicy() *backoff.Config {
+	if c.RetryPolicy.DisableRetries {
+		return &backoff.Config{DisableRetries: true}
+	}
+	if c.RetryPolicy.Constant != nil || c.RetryPolicy.Exponential != nil {
+		return &c.RetryPolicy
+	}
+	return &backoff.Config{
+		Exponential: &backoff.ExponentialConfig{
+			InitialInterval: defaultRetryInitialInterval,
+			MaxInterval:     defaultRetryMaxInterval,
+			MaxRetries:      defaultRetryMaxRetries,
+		},
+	}
+}
+
+// tableNamer resolves the dataset and table templates for the source tables.
+type tableNamer struct {
+	project         string
+	datasetTemplate string
+	tableTemplate   string
+}
+
+func (n *tableNamer) tableRef(schema, table string) tableRef {
+	replacer := strings.NewReplacer(schemaPlaceholder, schema, tablePlaceholder, table)
+	return tableRef{
+		project: n.project,
+		dataset: invalidNameChars.ReplaceAllString(replacer.Replace(n.datasetTemplate), "_"),
+		table:   invalidNameChars.ReplaceAllString(replacer.Replace(n.tableTemplate), "_"),
+	}
+}
@@ -0,0 +1,796 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/backoff"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+)
+
+// BigQuerySink is a wal processor that writes the events to BigQuery tables
+// through the Storage Write API. The tables are created from the schema log
+// events, or from the columns of the first event of the table, and the
+// columns added to the source tables are added to the target tables. Other
+// schema changes are not replicated. Depending on the mode, the changes are
+// either appended to change log tables through a committed write stream per
+// table, or applied to the tables with the BigQuery change data capture.
+type BigQuerySink struct {
+	logger          loglib.Logger
+	batchSender     batchSender
+	instrumentation *otel.Instrumentation
+	tableClient     tableClient
+	writeClient     writeClient
+	backoffProvider backoff.Provider
+	lsnParser       replication.LSNParser
+	namer           *tableNamer
+	mode            Mode
+	maxStaleness    time.Duration
+
+	// tables and datasets keep the state of the target tables and the
+	// datasets known to exist. They're only used when sending a batch, which
+	// doesn't happen concurrently.
+	tables   map[tableRef]*tableState
+	datasets map[string]struct{}
+
+	// schemas keeps the latest schema log entry of each schema, to compute
+	// the schema changes
+	schemasMutex sync.Mutex
+	schemas      map[string]*schemalog.LogEntry
+
+	// optional checkpointer callback to mark what was safely processed
+	checkpointer checkpointer.Checkpoint
+}
+
+type Option func(*BigQuerySink)
+
+type batchSender interface {
+	SendMessage(context.Context, *batch.WALMessage[message]) error
+	Close()
+}
+
+// message holds the changes of an event to the target tables. Schema log
+// events can change multiple tables.
+type message struct {
+	event   *wal.Event
+	changes []tableChange
+}
+
+// tableChange is a change to a target table. The fields are the ones the
+// table needs for the rows, and are added to the table if missing. The
+// primary key is used when the table is created.
+type tableChange struct {
+	table      tableRef
+	fields     []field
+	primaryKey []string
+	rows       [][]wal.Column
+	truncate   bool
+}
+
+// tableState is the state of a target table. The stream is the committed
+// stream of the table in change log mode, with the offset of the next row,
+// and the default stream of the table in upsert mode.
+type tableState struct {
+	fields []field
+	schema *rowSchema
+	stream string
+	offset int64
+}
+
+const (
+	// maxAppendRequestBytes is the size of the rows sent in a single append
+	// request, within the 10MB limit of the Storage Write API
+	maxAppendRequestBytes = 9 << 20
+
+	opInsert   = "INSERT"
+	opUpdate   = "UPDATE"
+	opDelete   = "DELETE"
+	opTruncate = "TRUNCATE"
+)
+
+var (
+	// changeLogFields are the fields added to the tables in change log mode
+	changeLogFields = []field{
+		{Name: opColumn, Type: typeString, Mode: modeNullable},
+		{Name: lsnColumn, Type: typeInt64, Mode: modeNullable},
+	}
+	// changeDataCaptureFields are the pseudo columns of the rows written in
+	// upsert mode, which are not part of the tables
+	changeDataCaptureFields = []field{
+		{Name: changeTypeColumn, Type: typeString, Mode: modeNullable},
+		{Name: changeSequenceColumn, Type: typeString, Mode: modeNullable},
+	}
+)
+
+var (
+	errMissingDataset         = errors.New("missing bigquery dataset")
+	errUnsupportedMode        = errors.New("unsupported bigquery mode")
+	errMissingProjectID       = errors.New("missing bigquery project id, and none found in the credentials")
+	errMissingIdentityColumns = errors.New("missing identity columns")
+)
+
+func NewBigQuerySink(ctx context.Context, cfg *Config, opts ...Option) (*BigQuerySink, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	creds, err := findCredentials(ctx, cfg.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("bigquery credentials: %w", err)
+	}
+	projectID := cfg.ProjectID
+	if projectID == "" {
+		if projectID = credentialsProject(creds); projectID == "" {
+			return nil, errMissingProjectID
+		}
+	}
+
+	s := &BigQuerySink{
+		logger:          loglib.NewNoopLogger(),
+		tableClient:     newHTTPTableClient(oauth2.NewClient(ctx, creds.TokenSource), cfg.apiEndpoint(), cfg.Location),
+		backoffProvider: backoff.NewProvider(cfg.retryPolicy()),
+		lsnParser:       pgreplication.NewLSNParser(),
+		namer: &tableNamer{
+			project:         projectID,
+			datasetTemplate: cfg.Dataset,
+			tableTemplate:   cfg.tableTemplate(),
+		},
+		mode:         cfg.mode(),
+		maxStaleness: cfg.MaxStaleness,
+		tables:       map[tableRef]*tableState{},
+		datasets:     map[string]struct{}{},
+		schemas:      map[string]*schemalog.LogEntry{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.writeClient, err = newManagedWriteClient(ctx, projectID,
+		option.WithCredentials(creds),
+		option.WithEndpoint(cfg.storageEndpoint()))
+	if err != nil {
+		return nil, err
+	}
+
+	s.batchSender, err = batch.NewSender(ctx, &cfg.Batch, s.sendBatch, s.logger, batch.WithInstrumentation(s.instrumentation, s.Name()))
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *BigQuerySink) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "bigquery_sink",
+		})
+	}
+}
+
+func WithCheckpoint(c checkpointer.Checkpoint) Option {
+	return func(s *BigQuerySink) {
+		s.checkpointer = c
+	}
+}
+
+// WithInstrumentation reports the metrics of the batches sent by the sink.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(s *BigQuerySink) {
+		s.instrumentation = i
+	}
+}
+
+// ProcessWALEvent is called on every new message from the wal. It can be called
+// concurrently.
+func (s *BigQuerySink) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Panic("[PANIC] Panic while processing replication event", loglib.Fields{
+				"wal_data":    walEvent,
+				"panic":       r,
+				"stack_trace": debug.Stack(),
+			})
+
+			retErr = fmt.Errorf("bigquery sink: understanding event: %w: %v", processor.ErrPanic, r)
+		}
+	}()
+
+	msg, err := s.newMessage(walEvent)
+	if err != nil {
+		return err
+	}
+
+	return s.batchSender.SendMessage(ctx, batch.NewWALMessage(msg, walEvent.CommitPosition))
+}
+
+func (s *BigQuerySink) Name() string {
+	return "bigquery-sink"
+}
+
+func (s *BigQuerySink) Ping(ctx context.Context) error {
+	return s.tableClient.ping(ctx, s.namer.project)
+}
+
+func (s *BigQuerySink) Close() error {
+	if s.batchSender != nil {
+		s.batchSender.Close()
+	}
+	if s.writeClient == nil {
+		return nil
+	}
+	return s.writeClient.close()
+}
+
+// newMessage returns the message for the event on input. Events that aren't
+// written to the tables, such as keep alives, are returned as empty messages
+// so that their position is still checkpointed.
+func (s *BigQuerySink) newMessage(event *wal.Event) (message, error) {
+	data := event.Data
+	if data == nil {
+		return message{}, nil
+	}
+	if processor.IsSchemaLogEvent(data) {
+		changes, err := s.schemaChanges(data)
+		if err != nil || len(changes) == 0 {
+			return message{}, err
+		}
+		return message{event: event, changes: changes}, nil
+	}
+
+	switch wal.Action(data.Action) {
+	case wal.ActionInsert, wal.ActionUpdate, wal.ActionDelete, wal.ActionTruncate:
+	default:
+		return message{}, nil
+	}
+
+	var change *tableChange
+	var err error
+	if s.mode == ModeUpsert {
+		change, err = s.upsertChange(data)
+	} else {
+		change, err = s.changeLogChange(data)
+	}
+	switch {
+	case errors.Is(err, errMissingIdentityColumns):
+		s.logger.Warn(err, "bigquery sink: skipping event, the table needs a replica identity", loglib.Fields{
+			"schema": data.Schema,
+			"table":  data.Table,
+			"action": data.Action,
+		})
+		return message{}, nil
+	case err != nil:
+		return message{}, fmt.Errorf("bigquery sink: table %s.%s: %w", data.Schema, data.Table, err)
+	}
+	return message{event: event, changes: []tableChange{*change}}, nil
+}
+
+// changeLogChange returns the change log row of the event on input, with the
+// operation and the LSN of the change. Deletes only include the identity
+// columns of the row, and truncates only the operation and the LSN.
+func (s *BigQuerySink) changeLogChange(data *wal.Data) (*tableChange, error) {
+	lsn, err := s.lsnParser.FromString(data.LSN)
+	if err != nil {
+		return nil, fmt.Errorf("parsing event lsn %q: %w", data.LSN, err)
+	}
+
+	change := &tableChange{
+		table:      s.namer.tableRef(data.Schema, data.Table),
+		fields:     slices.Clone(changeLogFields),
+		primaryKey: []string{},
+	}
+	var columns []wal.Column
+	var op string
+	switch wal.Action(data.Action) {
+	case wal.ActionInsert:
+		columns, op = data.Columns, opInsert
+	case wal.ActionUpdate:
+		columns, op = data.Columns, opUpdate
+	case wal.ActionDelete:
+		if columns = identityColumns(data); len(columns) == 0 {
+			return nil, errMissingIdentityColumns
+		}
+		op = opDelete
+	case wal.ActionTruncate:
+		op = opTruncate
+	}
+
+	row := make([]wal.Column, 0, len(columns)+2)
+	row = append(row, columns...)
+	row = append(row,
+		wal.Column{Name: opColumn, Value: op},
+		wal.Column{Name: lsnColumn, Value: int64(lsn)})
+	change.fields = append(columnFields(columns), change.fields...)
+	change.rows = [][]wal.Column{row}
+	return change, nil
+}
+
+// upsertChange returns the change data capture rows of the event on input,
+// ordered by the LSN of the event. Deletes only include the identity columns
+// of the row, and updates that change the identity of the row also delete the
+// row with the old identity.
+func (s *BigQuerySink) upsertChange(data *wal.Data) (*tableChange, error) {
+	change := &tableChange{table: s.namer.tableRef(data.Schema, data.Table)}
+	action := wal.Action(data.Action)
+	if action == wal.ActionTruncate {
+		change.truncate = true
+		return change, nil
+	}
+
+	identity := identityColumns(data)
+	if len(identity) == 0 {
+		return nil, errMissingIdentityColumns
+	}
+	for _, col := range identity {
+		change.primaryKey = append(change.primaryKey, col.Name)
+	}
+
+	cdcRow := func(columns []wal.Column, changeType string) []wal.Column {
+		row := make([]wal.Column, 0, len(columns)+2)
+		row = append(row, columns...)
+		row = append(row, wal.Column{Name: changeTypeColumn, Value: changeType})
+		if data.LSN != "" {
+			row = append(row, wal.Column{Name: changeSequenceColumn, Value: data.LSN})
+		}
+		return row
+	}
+
+	if action == wal.ActionDelete || (action == wal.ActionUpdate && identityChanged(data)) {
+		change.rows = append(change.rows, cdcRow(identity, changeTypeDelete))
+	}
+	if action != wal.ActionDelete {
+		change.rows = append(change.rows, cdcRow(data.Columns, changeTypeUpsert))
+		change.fields = columnFields(data.Columns)
+	} else {
+		change.fields = columnFields(identity)
+	}
+	return change, nil
+}
+
+// schemaChanges returns the changes to the target tables for the schema log
+// event on input. The first time a schema is seen, since the previous schema
+// is not known, all its tables are created or their missing columns added.
+// In upsert mode, the tables without a primary key are skipped, since they
+// can't be written with the change data capture.
+func (s *BigQuerySink) schemaChanges(data *wal.Data) ([]tableChange, error) {
+	logEntry, err := processor.WalDataToLogEntry(data)
+	if err != nil {
+		return nil, fmt.Errorf("bigquery sink: parsing schema log event: %w", err)
+	}
+
+	s.schemasMutex.Lock()
+	defer s.schemasMutex.Unlock()
+
+	previous, found := s.schemas[logEntry.SchemaName]
+	if found && logEntry.Version <= previous.Version {
+		return nil, nil
+	}
+	s.schemas[logEntry.SchemaName] = logEntry
+
+	tables := []schemalog.Table{}
+	diff := schemalog.ComputeSchemaDiff(previous, logEntry)
+	tables = append(tables, diff.TablesAdded...)
+	for _, tableDiff := range diff.TablesChanged {
+		if len(tableDiff.ColumnsAdded) == 0 {
+			continue
+		}
+		if tableDef, found := logEntry.GetTableByName(tableDiff.TableName); found {
+			tables = append(tables, tableDef)
+		}
+	}
+
+	changes := make([]tableChange, 0, len(tables))
+	for i := range tables {
+		tableDef := &tables[i]
+		if logEntry.IsMaterializedView(tableDef.Name) {
+			continue
+		}
+		change := tableChange{
+			table:      s.namer.tableRef(logEntry.SchemaName, tableDef.Name),
+			fields:     tableFields(tableDef),
+			primaryKey: []string{},
+		}
+		switch {
+		case s.mode == ModeChangeLog:
+			change.fields = append(change.fields, changeLogFields...)
+		case len(tableDef.PrimaryKeyColumns) == 0:
+			s.logger.Warn(nil, "bigquery sink: skipping table without primary key in upsert mode", loglib.Fields{
+				"schema": logEntry.SchemaName,
+				"table":  tableDef.Name,
+			})
+			continue
+		default:
+			change.primaryKey = tableDef.PrimaryKeyColumns
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (s *BigQuerySink) sendBatch(ctx context.Context, batch *batch.Batch[message]) error {
+	messages := batch.GetMessages()
+	s.logger.Debug("bigquery sink: writing message batch", loglib.Fields{
+		"batch_size":             len(messages),
+		"batch_commit_positions": len(batch.GetCommitPositions()),
+	})
+
+	if len(messages) > 0 {
+		if err := s.writeMessages(ctx, messages); err != nil {
+			s.logger.Error(err, "failed to write to bigquery")
+			return fmt.Errorf("bigquery sink: %w", err)
+		}
+	}
+
+	positions := batch.GetCommitPositions()
+	if s.checkpointer != nil && len(positions) > 0 {
+		if err := s.checkpointer(ctx, positions); err != nil {
+			s.logger.Warn(err, "bigquery sink: error updating commit position")
+		}
+	}
+
+	return nil
+}
+
+// writeMessages applies the changes of the messages on input in order. The
+// rows of each table are appended in bulk, before the schema of the table is
+// changed or the table truncated.
+func (s *BigQuerySink) writeMessages(ctx context.Context, messages []message) error {
+	tables := []tableRef{}
+	pending := map[tableRef][][]wal.Column{}
+	flush := func(ref tableRef) error {
+		rows, found := pending[ref]
+		if !found {
+			return nil
+		}
+		delete(pending, ref)
+		tables = slices.DeleteFunc(tables, func(pendingTable tableRef) bool { return pendingTable == ref })
+		return s.appendRows(ctx, ref, rows)
+	}
+
+	for _, msg := range messages {
+		for _, change := range msg.changes {
+			if change.truncate {
+				if err := flush(change.table); err != nil {
+					return err
+				}
+				if err := s.truncate(ctx, change.table); err != nil {
+					return err
+				}
+				continue
+			}
+
+			state, found := s.tables[change.table]
+			if !found || len(missingFields(state.fields, change.fields)) > 0 {
+				if err := flush(change.table); err != nil {
+					return err
+				}
+				if err := s.syncTable(ctx, &change); err != nil {
+					return fmt.Errorf("table %s: %w", change.table, err)
+				}
+			}
+
+			if len(change.rows) > 0 {
+				if _, found := pending[change.table]; !found {
+					tables = append(tables, change.table)
+				}
+				pending[change.table] = append(pending[change.table], change.rows...)
+			}
+		}
+	}
+
+	for len(tables) > 0 {
+		if err := flush(tables[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncTable makes sure the table of the change exists and has the fields of
+// the change. The table is created, along with its dataset, if it doesn't
+// exist. When fields are added to a table in change log mode, its committed
+// stream is finalized, so that the rows with the new fields are appended to a
+// new stream using the updated schema.
+func (s *BigQuerySink) syncTable(ctx context.Context, change *tableChange) error {
+	ref := change.table
+	state, found := s.tables[ref]
+	if !found {
+		var fields []field
+		err := s.retry(ctx, func() error {
+			var err error
+			fields, err = s.tableClient.getTableFields(ctx, ref)
+			return err
+		})
+		switch {
+		case isCode(err, codes.NotFound):
+			if fields, err = s.createTable(ctx, change); err != nil {
+				return err
+			}
+		case err != nil:
+			return fmt.Errorf("retrieving table schema: %w", err)
+		}
+		state = &tableState{fields: fields}
+	}
+
+	if missing := missingFields(state.fields, change.fields); len(missing) > 0 {
+		s.logger.Info("bigquery sink: adding columns to table", loglib.Fields{
+			"table":   ref.String(),
+			"columns": len(missing),
+		})
+		var fields []field
+		err := s.retry(ctx, func() error {
+			var err error
+			fields, err = s.tableClient.addFields(ctx, ref, missing)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("adding columns: %w", err)
+		}
+		state.fields = fields
+		if err := s.finalizeStream(ctx, state); err != nil {
+			return err
+		}
+	}
+
+	schemaFields := state.fields
+	if s.mode == ModeUpsert {
+		schemaFields = append(slices.Clone(state.fields), changeDataCaptureFields...)
+		state.stream = defaultStream(ref)
+	}
+	state.schema = newRowSchema(schemaFields)
+	s.tables[ref] = state
+	return nil
+}
+
+// truncate deletes all the rows of the table in upsert mode. Tables that
+// don't exist yet have nothing to truncate.
+func (s *BigQuerySink) truncate(ctx context.Context, ref tableRef) error {
+	statement := "TRUNCATE TABLE " + ref.quoted()
+	err := s.retry(ctx, func() error { return s.tableClient.query(ctx, ref.project, statement) })
+	if err != nil && !isCode(err, codes.NotFound) {
+		return fmt.Errorf("truncating table %s: %w", ref, err)
+	}
+	return nil
+}
+
+// createTable creates the table of the change, and its dataset if needed, and
+// returns the fields of the table.
+func (s *BigQuerySink) createTable(ctx context.Context, change *tableChange) ([]field, error) {
+	ref := change.table
+	if _, found := s.datasets[ref.dataset]; !found {
+		if err := s.retry(ctx, func() error { return s.tableClient.createDataset(ctx, ref.project, ref.dataset) }); err != nil {
+			return nil, fmt.Errorf("creating dataset %s: %w", ref.dataset, err)
+		}
+		s.datasets[ref.dataset] = struct{}{}
+	}
+
+	def := &tableDefinition{
+		fields:     missingFields(nil, change.fields),
+		primaryKey: change.primaryKey,
+	}
+	if s.mode == ModeUpsert {
+		def.maxStaleness = s.maxStaleness
+	}
+	s.logger.Info("bigquery sink: creating table", loglib.Fields{
+		"table": ref.String(),
+	})
+	err := s.retry(ctx, func() error { return s.tableClient.createTable(ctx, ref, def) })
+	switch {
+	case isCode(err, codes.AlreadyExists):
+		// the table was created concurrently, retrieve its actual fields
+		var fields []field
+		err := s.retry(ctx, func() error {
+			var err error
+			fields, err = s.tableClient.getTableFields(ctx, ref)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("retrieving table schema: %w", err)
+		}
+		return fields, nil
+	case err != nil:
+		return nil, fmt.Errorf("creating table: %w", err)
+	}
+	return def.fields, nil
+}
+
+// appendRows appends the rows on input to the table, in as many requests as
+// needed to keep them within the size limit of the Storage Write API.
+func (s *BigQuerySink) appendRows(ctx context.Context, ref tableRef, rows [][]wal.Column) error {
+	state := s.tables[ref]
+	encoded := make([][]byte, 0, len(rows))
+	size := 0
+	for _, row := range rows {
+		b, err := state.schema.encode(row)
+		if err != nil {
+			return fmt.Errorf("encoding row for table %s: %w", ref, err)
+		}
+		if size+len(b) > maxAppendRequestBytes && len(encoded) > 0 {
+			if err := s.append(ctx, ref, state, encoded); err != nil {
+				return err
+			}
+			encoded, size = encoded[:0], 0
+		}
+		encoded = append(encoded, b)
+		size += len(b)
+	}
+	return s.append(ctx, ref, state, encoded)
+}
+
+// append sends the rows on input in a single append request. In change log
+// mode the committed stream of the table is created when needed, and the rows
+// are appended at the offset of the stream, so that retrying a request that
+// was already applied doesn't duplicate the rows.
+func (s *BigQuerySink) append(ctx context.Context, ref tableRef, state *tableState, rows [][]byte) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	err := s.retry(ctx, func() error {
+		if state.stream == "" {
+			stream, err := s.writeClient.createWriteStream(ctx, ref)
+			if err != nil {
+				return fmt.Errorf("creating write stream: %w", err)
+			}
+			state.stream, state.offset = stream, 0
+		}
+
+		req := &appendRowsRequest{
+			writeStream: state.stream,
+			descriptor:  state.schema.descriptor,
+			rows:        rows,
+		}
+		if s.mode == ModeChangeLog {
+			req.offset = &state.offset
+		}
+		err := s.writeClient.appendRows(ctx, req)
+		switch {
+		case err == nil, s.mode == ModeChangeLog && isCode(err, codes.AlreadyExists):
+			// the rows at the offset were appended by a previous attempt
+			return nil
+		case s.mode == ModeChangeLog && (isCode(err, codes.NotFound) || isCode(err, codes.FailedPrecondition)):
+			// the stream expired or was finalized, retry with a new stream
+			state.stream = ""
+			return &apiError{code: codes.Unavailable, message: err.Error()}
+		default:
+			return err
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("appending rows to table %s: %w", ref, err)
+	}
+	if s.mode == ModeChangeLog {
+		state.offset += int64(len(rows))
+	}
+	return nil
+}
+
+// finalizeStream finalizes the committed stream of the table, if any. The
+// stream is discarded even if it can't be finalized, since the committed rows
+// are visible regardless.
+func (s *BigQuerySink) finalizeStream(ctx context.Context, state *tableState) error {
+	if s.mode != ModeChangeLog || state.stream == "" {
+		return nil
+	}
+	stream := state.stream
+	state.stream, state.offset = "", 0
+	if err := s.writeClient.finalizeWriteStream(ctx, stream); err != nil {
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		s.logger.Warn(err, "bigquery sink: finalizing write stream", loglib.Fields{
+			"stream": stream,
+		})
+	}
+	return nil
+}
+
+// retry runs the operation on input, retrying it while it fails with transient
+// errors. When BigQuery rejects a request because of a quota and returns a
+// retry delay, the retry waits at least that long.
+func (s *BigQuerySink) retry(ctx context.Context, op func() error) error {
+	return s.backoffProvider(ctx).RetryNotify(
+		func() error {
+			err := op()
+			switch {
+			case err == nil:
+				return nil
+			case isPermanent(err) || errors.Is(err, context.Canceled):
+				return fmt.Errorf("%w: %w", err, backoff.ErrPermanent)
+			}
+
+			var apiErr *apiError
+			if errors.As(err, &apiErr) && apiErr.retryDelay > 0 {
+				select {
+				case <-ctx.Done():
+					return fmt.Errorf("%w: %w", ctx.Err(), backoff.ErrPermanent)
+				case <-time.After(apiErr.retryDelay):
+				}
+			}
+			return err
+		},
+		func(err error, d time.Duration) {
+			s.logger.Warn(err, "bigquery sink: request failed, retrying", loglib.Fields{
+				"retry_after": d,
+			})
+		})
+}
+
+// identityColumns returns the replica identity columns of the event on input,
+// or the primary key columns identified by the injector when the event has no
+// replica identity.
+func identityColumns(data *wal.Data) []wal.Column {
+	if len(data.Identity) > 0 {
+		return data.Identity
+	}
+	pkColumns := []wal.Column{}
+	for _, col := range data.Columns {
+		if slices.Contains(data.Metadata.InternalColIDs, col.ID) {
+			pkColumns = append(pkColumns, col)
+		}
+	}
+	return pkColumns
+}
+
+// identityChanged returns true if the update on input sets new values for the
+// replica identity columns.
+func identityChanged(data *wal.Data) bool {
+	for _, identityCol := range data.Identity {
+		idx := slices.IndexFunc(data.Columns, func(col wal.Column) bool { return col.Name == identityCol.Name })
+		if idx == -1 || fmt.Sprint(data.Columns[idx].Value) != fmt.Sprint(identityCol.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Size returns an estimate of the size of the rows of the message, since the
+// rows are only encoded when the batch is sent.
+func (m message) Size() int {
+	size := 0
+	for _, change := range m.changes {
+		for _, row := range change.rows {
+			for _, col := range row {
+				size += len(col.Name) + valueSize(col.Value)
+			}
+		}
+	}
+	return size
+}
+
+func (m message) IsEmpty() bool {
+	return m.event == nil
+}
+
+func valueSize(v any) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	default:
+		return 8
+	}
+}
@@ -0,0 +1,796 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package bigquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/backoff"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+	"google.golang.org/grpc/codes"
+)
+
+// mockTableClient keeps the fields of the tables in memory, and records the
+// calls made to it.
+type mockTableClient struct {
+	tables map[tableRef][]field
+	errFn  func(call string) error
+	calls  []string
+}
+
+func (m *mockTableClient) call(call string) error {
+	m.calls = append(m.calls, call)
+	if m.errFn != nil {
+		return m.errFn(call)
+	}
+	return nil
+}
+
+func (m *mockTableClient) createDataset(_ context.Context, project, dataset string) error {
+	return m.call(fmt.Sprintf("createDataset %s.%s", project, dataset))
+}
+
+func (m *mockTableClient) getTableFields(_ context.Context, ref tableRef) ([]field, error) {
+	if err := m.call("getTableFields " + ref.String()); err != nil {
+		return nil, err
+	}
+	fields, found := m.tables[ref]
+	if !found {
+		return nil, &apiError{code: codes.NotFound, message: "Not found: Table " + ref.String()}
+	}
+	return fields, nil
+}
+
+func (m *mockTableClient) createTable(_ context.Context, ref tableRef, def *tableDefinition) error {
+	if err := m.call(fmt.Sprintf("createTable %s %v pk=%v", ref, fieldNames(def.fields), def.primaryKey)); err != nil {
+		return err
+	}
+	m.tables[ref] = def.fields
+	return nil
+}
+
+func (m *mockTableClient) addFields(_ context.Context, ref tableRef, fields []field) ([]field, error) {
+	if err := m.call(fmt.Sprintf("addFields %s %v", ref, fieldNames(fields))); err != nil {
+		return nil, err
+	}
+	m.tables[ref] = append(m.tables[ref], fields...)
+	return m.tables[ref], nil
+}
+
+func (m *mockTableClient) query(_ context.Context, project, statement string) error {
+	return m.call(fmt.Sprintf("query %s %s", project, statement))
+}
+
+func (m *mockTableClient) ping(_ context.Context, project string) error {
+	return m.call("ping " + project)
+}
+
+// mockWriteClient records the rows appended to each stream.
+type mockWriteClient struct {
+	appendRowsFn func(req *appendRowsRequest) error
+	streams      int
+	calls        []string
+	rows         map[string][][]byte
+}
+
+func (m *mockWriteClient) createWriteStream(_ context.Context, ref tableRef) (string, error) {
+	m.streams++
+	stream := fmt.Sprintf("%s/streams/s%d", tableResource(ref), m.streams)
+	m.calls = append(m.calls, "createWriteStream "+stream)
+	return stream, nil
+}
+
+func (m *mockWriteClient) appendRows(_ context.Context, req *appendRowsRequest) error {
+	call := fmt.Sprintf("appendRows %s rows=%d", req.writeStream, len(req.rows))
+	if req.offset != nil {
+		call += fmt.Sprintf(" offset=%d", *req.offset)
+	}
+	m.calls = append(m.calls, call)
+	if m.appendRowsFn != nil {
+		if err := m.appendRowsFn(req); err != nil {
+			return err
+		}
+	}
+	m.rows[req.writeStream] = append(m.rows[req.writeStream], req.rows...)
+	return nil
+}
+
+func (m *mockWriteClient) finalizeWriteStream(_ context.Context, stream string) error {
+	m.calls = append(m.calls, "finalizeWriteStream "+stream)
+	return nil
+}
+
+func (m *mockWriteClient) close() error {
+	return nil
+}
+
+var errTest = errors.New("oh noes")
+
+func newTestSink(mode Mode, tables *mockTableClient, writer *mockWriteClient) *BigQuerySink {
+	return &BigQuerySink{
+		logger:      loglib.NewNoopLogger(),
+		tableClient: tables,
+		writeClient: writer,
+		backoffProvider: backoff.NewProvider(&backoff.Config{
+			Constant: &backoff.ConstantConfig{Interval: time.Millisecond, MaxRetries: 2},
+		}),
+		lsnParser: pgreplication.NewLSNParser(),
+		namer: &tableNamer{
+			project:         "test-project",
+			datasetTemplate: schemaPlaceholder,
+			tableTemplate:   tablePlaceholder,
+		},
+		mode:     mode,
+		tables:   map[tableRef]*tableState{},
+		datasets: map[string]struct{}{},
+		schemas:  map[string]*schemalog.LogEntry{},
+	}
+}
+
+func newTestMocks() (*mockTableClient, *mockWriteClient) {
+	return &mockTableClient{tables: map[tableRef][]field{}}, &mockWriteClient{rows: map[string][][]byte{}}
+}
+
+func newTestData(action wal.Action, tableName string, id int) *wal.Data {
+	data := &wal.Data{
+		Action: string(action),
+		LSN:    "0/10",
+		Schema: "public",
+		Table:  tableName,
+	}
+	switch action {
+	case wal.ActionInsert, wal.ActionUpdate:
+		data.Columns = []wal.Column{
+			{Name: "id", Type: "integer", Value: id},
+			{Name: "name", Type: "text", Value: "a"},
+		}
+	}
+	switch action {
+	case wal.ActionUpdate, wal.ActionDelete:
+		data.Identity = []wal.Column{{Name: "id", Type: "integer", Value: id}}
+	}
+	return data
+}
+
+func newTestSchemaLogEvent(version int64, schema string) *wal.Event {
+	return &wal.Event{
+		Data: &wal.Data{
+			Action: string(wal.ActionInsert),
+			Schema: schemalog.SchemaName,
+			Table:  schemalog.TableName,
+			Columns: []wal.Column{
+				{Name: "id", Value: "cmdrsfh1u0k6667mfvu0"},
+				{Name: "version", Value: version},
+				{Name: "schema_name", Value: "public"},
+				{Name: "schema", Value: schema},
+			},
+		},
+		CommitPosition: "0/20",
+	}
+}
+
+func fieldNames(fields []field) []string {
+	names := make([]string, 0, len(fields))
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func TestNewBigQuerySink(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	userCredentials := filepath.Join(dir, "user.json")
+	require.NoError(t, os.WriteFile(userCredentials, []byte(`{"type":"authorized_user","quota_project_id":"quota-project"}`), 0o600))
+	noProjectCredentials := filepath.Join(dir, "no_project.json")
+	require.NoError(t, os.WriteFile(noProjectCredentials, []byte(`{"type":"authorized_user"}`), 0o600))
+
+	tests := []struct {
+		name string
+		cfg  *Config
+
+		wantProject string
+		wantErr     error
+	}{
+		{
+			name: "ok - project of the credentials",
+			cfg: &Config{
+				CredentialsFile: userCredentials,
+				Dataset:         "pgstream_{schema}",
+			},
+
+			wantProject: "quota-project",
+		},
+		{
+			name: "ok - configured project",
+			cfg: &Config{
+				ProjectID:       "test-project",
+				CredentialsFile: noProjectCredentials,
+				Dataset:         "pgstream_{schema}",
+				Mode:            ModeUpsert,
+			},
+
+			wantProject: "test-project",
+		},
+		{
+			name: "error - missing project",
+			cfg: &Config{
+				CredentialsFile: noProjectCredentials,
+				Dataset:         "pgstream",
+			},
+
+			wantErr: errMissingProjectID,
+		},
+		{
+			name: "error - missing dataset",
+			cfg: &Config{
+				ProjectID: "test-project",
+			},
+
+			wantErr: errMissingDataset,
+		},
+		{
+			name: "error - unsupported mode",
+			cfg: &Config{
+				ProjectID: "test-project",
+				Dataset:   "pgstream",
+				Mode:      "merge",
+			},
+
+			wantErr: errUnsupportedMode,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			s, err := NewBigQuerySink(ctx, tc.cfg)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantProject, s.namer.project)
+			require.Equal(t, tc.cfg.mode(), s.mode)
+		})
+	}
+}
+
+func TestTableNamer_tableRef(t *testing.T) {
+	t.Parallel()
+
+	namer := &tableNamer{project: "test-project", datasetTemplate: "pgstream_{schema}", tableTemplate: defaultTableTemplate}
+	require.Equal(t, tableRef{project: "test-project", dataset: "pgstream_public", table: "public_users"}, namer.tableRef("public", "users"))
+	require.Equal(t, tableRef{project: "test-project", dataset: "pgstream_my_schema", table: "my_schema_user_events"}, namer.tableRef("my-schema", "user events"))
+}
+
+func TestBigQuerySink_newMessage(t *testing.T) {
+	t.Parallel()
+
+	usersTable := tableRef{project: "test-project", dataset: "public", table: "users"}
+	identityUpdate := newTestData(wal.ActionUpdate, "users", 2)
+	identityUpdate.Identity = []wal.Column{{Name: "id", Type: "integer", Value: 1}}
+
+	tests := []struct {
+		name string
+		mode Mode
+		data *wal.Data
+
+		wantChanges []tableChange
+	}{
+		{
+			name: "change log - insert",
+			mode: ModeChangeLog,
+			data: newTestData(wal.ActionInsert, "users", 1),
+
+			wantChanges: []tableChange{
+				{
+					table: usersTable,
+					fields: []field{
+						{Name: "id", Type: typeInt64, Mode: modeNullable},
+						{Name: "name", Type: typeString, Mode: modeNullable},
+						{Name: opColumn, Type: typeString, Mode: modeNullable},
+						{Name: lsnColumn, Type: typeInt64, Mode: modeNullable},
+					},
+					primaryKey: []string{},
+					rows: [][]wal.Column{{
+						{Name: "id", Type: "integer", Value: 1},
+						{Name: "name", Type: "text", Value: "a"},
+						{Name: opColumn, Value: opInsert},
+						{Name: lsnColumn, Value: int64(16)},
+					}},
+				},
+			},
+		},
+		{
+			name: "change log - delete",
+			mode: ModeChangeLog,
+			data: newTestData(wal.ActionDelete, "users", 1),
+
+			wantChanges: []tableChange{
+				{
+					table: usersTable,
+					fields: []field{
+						{Name: "id", Type: typeInt64, Mode: modeNullable},
+						{Name: opColumn, Type: typeString, Mode: modeNullable},
+						{Name: lsnColumn, Type: typeInt64, Mode: modeNullable},
+					},
+					primaryKey: []string{},
+					rows: [][]wal.Column{{
+						{Name: "id", Type: "integer", Value: 1},
+						{Name: opColumn, Value: opDelete},
+						{Name: lsnColumn, Value: int64(16)},
+					}},
+				},
+			},
+		},
+		{
+			name: "change log - truncate",
+			mode: ModeChangeLog,
+			data: newTestData(wal.ActionTruncate, "users", 0),
+
+			wantChanges: []tableChange{
+				{
+					table:      usersTable,
+					fields:     changeLogFields,
+					primaryKey: []string{},
+					rows: [][]wal.Column{{
+						{Name: opColumn, Value: opTruncate},
+						{Name: lsnColumn, Value: int64(16)},
+					}},
+				},
+			},
+		},
+		{
+			name: "upsert - update changing the identity",
+			mode: ModeUpsert,
+			data: identityUpdate,
+
+			wantChanges: []tableChange{
+				{
+					table: usersTable,
+					fields: []field{
+						{Name: "id", Type: typeInt64, Mode: modeNullable},
+						{Name: "name", Type: typeString, Mode: modeNullable},
+					},
+					primaryKey: []string{"id"},
+					rows: [][]wal.Column{
+						{
+							{Name: "id", Type: "integer", Value: 1},
+							{Name: changeTypeColumn, Value: changeTypeDelete},
+							{Name: changeSequenceColumn, Value: "0/10"},
+						},
+						{
+							{Name: "id", Type: "integer", Value: 2},
+							{Name: "name", Type: "text", Value: "a"},
+							{Name: changeTypeColumn, Value: changeTypeUpsert},
+							{Name: changeSequenceColumn, Value: "0/10"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "upsert - delete",
+			mode: ModeUpsert,
+			data: newTestData(wal.ActionDelete, "users", 1),
+
+			wantChanges: []tableChange{
+				{
+					table:      usersTable,
+					fields:     []field{{Name: "id", Type: typeInt64, Mode: modeNullable}},
+					primaryKey: []string{"id"},
+					rows: [][]wal.Column{{
+						{Name: "id", Type: "integer", Value: 1},
+						{Name: changeTypeColumn, Value: changeTypeDelete},
+						{Name: changeSequenceColumn, Value: "0/10"},
+					}},
+				},
+			},
+		},
+		{
+			name: "upsert - truncate",
+			mode: ModeUpsert,
+			data: newTestData(wal.ActionTruncate, "users", 0),
+
+			wantChanges: []tableChange{
+				{table: usersTable, truncate: true},
+			},
+		},
+		{
+			name: "skipped - missing identity",
+			mode: ModeUpsert,
+			data: newTestData(wal.ActionInsert, "users", 1),
+		},
+		{
+			name: "skipped - keep alive",
+			mode: ModeChangeLog,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tables, writer := newTestMocks()
+			s := newTestSink(tc.mode, tables, writer)
+			msg, err := s.newMessage(&wal.Event{Data: tc.data, CommitPosition: "0/10"})
+			require.NoError(t, err)
+			require.Equal(t, tc.wantChanges, msg.changes)
+			require.Equal(t, len(tc.wantChanges) == 0, msg.IsEmpty())
+		})
+	}
+}
+
+func TestBigQuerySink_schemaChanges(t *testing.T) {
+	t.Parallel()
+
+	schemaV1 := `{"tables":[
+		{"name":"users","pgstream_id":"t1","columns":[{"name":"id","type":"bigint","pgstream_id":"c1"},{"name":"name","type":"text","nullable":true,"pgstream_id":"c2"}],"primary_key_columns":["id"]},
+		{"name":"events","pgstream_id":"t2","columns":[{"name":"payload","type":"jsonb","pgstream_id":"c3"}]}
+	]}`
+	schemaV2 := `{"tables":[
+		{"name":"users","pgstream_id":"t1","columns":[{"name":"id","type":"bigint","pgstream_id":"c1"},{"name":"name","type":"text","nullable":true,"pgstream_id":"c2"},{"name":"email","type":"text","nullable":true,"pgstream_id":"c4"}],"primary_key_columns":["id"]},
+		{"name":"events","pgstream_id":"t2","columns":[{"name":"payload","type":"jsonb","pgstream_id":"c3"}]}
+	]}`
+
+	t.Run("change log", func(t *testing.T) {
+		t.Parallel()
+
+		tables, writer := newTestMocks()
+		s := newTestSink(ModeChangeLog, tables, writer)
+
+		changes, err := s.schemaChanges(newTestSchemaLogEvent(1, schemaV1).Data)
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		// the order of the added tables is not deterministic
+		slices.SortFunc(changes, func(a, b tableChange) int {
+			return strings.Compare(a.table.table, b.table.table)
+		})
+		require.Equal(t, "events", changes[0].table.table)
+		require.Equal(t, []field{
+			{Name: "payload", Type: typeJSON, Mode: modeNullable},
+			{Name: opColumn, Type: typeString, Mode: modeNullable},
+			{Name: lsnColumn, Type: typeInt64, Mode: modeNullable},
+		}, changes[0].fields)
+		require.Equal(t, "users", changes[1].table.table)
+		require.Equal(t, []string{"id", "name", opColumn, lsnColumn}, fieldNames(changes[1].fields))
+
+		// older versions are ignored
+		changes, err = s.schemaChanges(newTestSchemaLogEvent(1, schemaV2).Data)
+		require.NoError(t, err)
+		require.Empty(t, changes)
+
+		// only the tables with columns added are changed
+		changes, err = s.schemaChanges(newTestSchemaLogEvent(2, schemaV2).Data)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		require.Equal(t, "users", changes[0].table.table)
+		require.Equal(t, []string{"id", "name", "email", opColumn, lsnColumn}, fieldNames(changes[0].fields))
+	})
+
+	t.Run("upsert skips tables without primary key", func(t *testing.T) {
+		t.Parallel()
+
+		tables, writer := newTestMocks()
+		s := newTestSink(ModeUpsert, tables, writer)
+
+		changes, err := s.schemaChanges(newTestSchemaLogEvent(1, schemaV1).Data)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		require.Equal(t, "users", changes[0].table.table)
+		require.Equal(t, []string{"id"}, changes[0].primaryKey)
+		require.Equal(t, []string{"id", "name"}, fieldNames(changes[0].fields))
+	})
+}
+
+func TestBigQuerySink_sendBatch(t *testing.T) {
+	t.Parallel()
+
+	testPositions := []wal.CommitPosition{"0/10", "0/20"}
+	usersStream := "projects/test-project/datasets/public/tables/users/streams/"
+	eventsStream := "projects/test-project/datasets/public/tables/events/streams/"
+
+	emailInsert := newTestData(wal.ActionInsert, "users", 3)
+	emailInsert.Columns = append(emailInsert.Columns, wal.Column{Name: "email", Type: "text", Value: "c@example.com"})
+	identityInsert := func(id int) *wal.Data {
+		data := newTestData(wal.ActionInsert, "users", id)
+		data.Identity = []wal.Column{{Name: "id", Type: "integer", Value: id}}
+		return data
+	}
+
+	tests := []struct {
+		name         string
+		mode         Mode
+		tables       map[tableRef][]field
+		tableErrFn   func(call string) error
+		appendRowsFn func(req *appendRowsRequest) error
+		data         []*wal.Data
+
+		wantTableCalls []string
+		wantWriteCalls []string
+		wantErr        error
+	}{
+		{
+			name: "ok - change log tables created, rows appended in bulk per table",
+			mode: ModeChangeLog,
+			data: []*wal.Data{
+				newTestData(wal.ActionInsert, "users", 1),
+				newTestData(wal.ActionInsert, "events", 1),
+				newTestData(wal.ActionDelete, "users", 1),
+				newTestData(wal.ActionInsert, "users", 2),
+			},
+
+			wantTableCalls: []string{
+				"getTableFields test-project.public.users",
+				"createDataset test-project.public",
+				"createTable test-project.public.users [id name _op _lsn] pk=[]",
+				"getTableFields test-project.public.events",
+				"createTable test-project.public.events [id name _op _lsn] pk=[]",
+			},
+			wantWriteCalls: []string{
+				"createWriteStream " + usersStream + "s1",
+				"appendRows " + usersStream + "s1 rows=3 offset=0",
+				"createWriteStream " + eventsStream + "s2",
+				"appendRows " + eventsStream + "s2 rows=1 offset=0",
+			},
+		},
+		{
+			name: "ok - columns added to existing table, rows written to a new stream",
+			mode: ModeChangeLog,
+			tables: map[tableRef][]field{
+				testTable: append([]field{{Name: "id", Type: "INTEGER"}, {Name: "name", Type: typeString}}, changeLogFields...),
+			},
+			data: []*wal.Data{
+				newTestData(wal.ActionInsert, "users", 1),
+				newTestData(wal.ActionInsert, "users", 2),
+				emailInsert,
+			},
+
+			wantTableCalls: []string{
+				"getTableFields test-project.public.users",
+				"addFields test-project.public.users [email]",
+			},
+			wantWriteCalls: []string{
+				"createWriteStream " + usersStream + "s1",
+				"appendRows " + usersStream + "s1 rows=2 offset=0",
+				"finalizeWriteStream " + usersStream + "s1",
+				"createWriteStream " + usersStream + "s2",
+				"appendRows " + usersStream + "s2 rows=1 offset=0",
+			},
+		},
+		{
+			name: "ok - rows already appended at the offset",
+			mode: ModeChangeLog,
+			appendRowsFn: func() func(*appendRowsRequest) error {
+				calls := 0
+				return func(*appendRowsRequest) error {
+					calls++
+					if calls == 1 {
+						return &apiError{code: codes.Unavailable, message: "connection reset"}
+					}
+					return &apiError{code: codes.AlreadyExists, message: "offset already exists"}
+				}
+			}(),
+			data: []*wal.Data{newTestData(wal.ActionInsert, "users", 1)},
+
+			wantTableCalls: []string{
+				"getTableFields test-project.public.users",
+				"createDataset test-project.public",
+				"createTable test-project.public.users [id name _op _lsn] pk=[]",
+			},
+			wantWriteCalls: []string{
+				"createWriteStream " + usersStream + "s1",
+				"appendRows " + usersStream + "s1 rows=1 offset=0",
+				"appendRows " + usersStream + "s1 rows=1 offset=0",
+			},
+		},
+		{
+			name: "ok - expired stream recreated",
+			mode: ModeChangeLog,
+			appendRowsFn: func() func(*appendRowsRequest) error {
+				calls := 0
+				return func(*appendRowsRequest) error {
+					calls++
+					if calls == 1 {
+						return &apiError{code: codes.NotFound, message: "stream not found"}
+					}
+					return nil
+				}
+			}(),
+			data: []*wal.Data{newTestData(wal.ActionInsert, "users", 1)},
+
+			wantTableCalls: []string{
+				"getTableFields test-project.public.users",
+				"createDataset test-project.public",
+				"createTable test-project.public.users [id name _op _lsn] pk=[]",
+			},
+			wantWriteCalls: []string{
+				"createWriteStream " + usersStream + "s1",
+				"appendRows " + usersStream + "s1 rows=1 offset=0",
+				"createWriteStream " + usersStream + "s2",
+				"appendRows " + usersStream + "s2 rows=1 offset=0",
+			},
+		},
+		{
+			name: "ok - upsert through the default stream, truncate applied in order",
+			mode: ModeUpsert,
+			data: []*wal.Data{
+				identityInsert(1),
+				newTestData(wal.ActionDelete, "users", 1),
+				newTestData(wal.ActionTruncate, "users", 0),
+				identityInsert(2),
+			},
+
+			wantTableCalls: []string{
+				"getTableFields test-project.public.users",
+				"createDataset test-project.public",
+				"createTable test-project.public.users [id name] pk=[id]",
+				"query test-project TRUNCATE TABLE `test-project`.`public`.`users`",
+			},
+			wantWriteCalls: []string{
+				"appendRows " + usersStream + "_default rows=2",
+				"appendRows " + usersStream + "_default rows=1",
+			},
+		},
+		{
+			name: "error - rows rejected",
+			mode: ModeUpsert,
+			appendRowsFn: func(*appendRowsRequest) error {
+				return &apiError{code: codes.InvalidArgument, message: "table is not CDC enabled"}
+			},
+			tables: map[tableRef][]field{
+				testTable: {{Name: "id", Type: "INTEGER"}, {Name: "name", Type: typeString}},
+			},
+			data: []*wal.Data{identityInsert(1)},
+
+			wantTableCalls: []string{
+				"getTableFields test-project.public.users",
+			},
+			wantWriteCalls: []string{
+				"appendRows " + usersStream + "_default rows=1",
+			},
+			wantErr: &apiError{code: codes.InvalidArgument, message: "table is not CDC enabled"},
+		},
+		{
+			name: "error - transient errors exhaust the retries",
+			mode: ModeChangeLog,
+			tableErrFn: func(string) error {
+				return &apiError{code: codes.Unavailable, message: "backend error"}
+			},
+			data: []*wal.Data{newTestData(wal.ActionInsert, "users", 1)},
+
+			wantTableCalls: []string{
+				"getTableFields test-project.public.users",
+				"getTableFields test-project.public.users",
+				"getTableFields test-project.public.users",
+			},
+			wantErr: &apiError{code: codes.Unavailable, message: "backend error"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			tables, writer := newTestMocks()
+			if tc.tables != nil {
+				tables.tables = tc.tables
+			}
+			tables.errFn = tc.tableErrFn
+			writer.appendRowsFn = tc.appendRowsFn
+
+			checkpoints := [][]wal.CommitPosition{}
+			s := newTestSink(tc.mode, tables, writer)
+			s.checkpointer = func(_ context.Context, positions []wal.CommitPosition) error {
+				checkpoints = append(checkpoints, positions)
+				return nil
+			}
+
+			b := batch.NewBatch([]message{}, testPositions)
+			for _, data := range tc.data {
+				msg, err := s.newMessage(&wal.Event{Data: data, CommitPosition: "0/10"})
+				require.NoError(t, err)
+				b = batch.NewBatch(append(b.GetMessages(), msg), testPositions)
+			}
+
+			err := s.sendBatch(context.Background(), b)
+			require.Equal(t, tc.wantTableCalls, tables.calls)
+			require.Equal(t, tc.wantWriteCalls, writer.calls)
+			if tc.wantErr != nil {
+				require.ErrorAs(t, err, new(*apiError))
+				require.ErrorContains(t, err, tc.wantErr.Error())
+				require.Empty(t, checkpoints)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, [][]wal.CommitPosition{testPositions}, checkpoints)
+		})
+	}
+}
+
+func TestBigQuerySink_sendBatch_offsets(t *testing.T) {
+	t.Parallel()
+
+	tables, writer := newTestMocks()
+	s := newTestSink(ModeChangeLog, tables, writer)
+
+	for i := range 2 {
+		msgs := []message{}
+		for id := range 3 {
+			msg, err := s.newMessage(&wal.Event{Data: newTestData(wal.ActionInsert, "users", i*3+id), CommitPosition: "0/10"})
+			require.NoError(t, err)
+			msgs = append(msgs, msg)
+		}
+		require.NoError(t, s.sendBatch(context.Background(), batch.NewBatch(msgs, nil)))
+	}
+
+	stream := "projects/test-project/datasets/public/tables/users/streams/s1"
+	require.Equal(t, []string{
+		"createWriteStream " + stream,
+		"appendRows " + stream + " rows=3 offset=0",
+		"appendRows " + stream + " rows=3 offset=3",
+	}, writer.calls)
+	require.Len(t, writer.rows[stream], 6)
+}
+
+func TestBigQuerySink_retry_retryDelay(t *testing.T) {
+	t.Parallel()
+
+	tables, writer := newTestMocks()
+	s := newTestSink(ModeChangeLog, tables, writer)
+
+	calls := 0
+	start := time.Now()
+	err := s.retry(context.Background(), func() error {
+		calls++
+		if calls == 1 {
+			return &apiError{code: codes.ResourceExhausted, message: "quota exceeded", retryDelay: 50 * time.Millisecond}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = s.retry(ctx, func() error { return errTest })
+	require.Error(t, err)
+}
+
+func TestMessage_Size(t *testing.T) {
+	t.Parallel()
+
+	msg := message{
+		event: &wal.Event{},
+		changes: []tableChange{{
+			rows: [][]wal.Column{{
+				{Name: "id", Value: 1},
+				{Name: "name", Value: "alice"},
+				{Name: "deleted_at", Value: nil},
+			}},
+		}},
+	}
+	require.Equal(t, 2+8+4+5+10, msg.Size())
+	require.False(t, msg.IsEmpty())
+	require.True(t, message{}.IsEmpty())
+}
@@ -0,0 +1,197 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// client runs the statements on ClickHouse. Insert statements include their
+// data after the FORMAT clause.
+type client interface {
+	exec(ctx context.Context, query string) error
+	ping(ctx context.Context) error
+	close() error
+}
+
+// queryError is an error returned by ClickHouse, with the exception code if
+// any.
+type queryError struct {
+	code    int
+	status  int
+	message string
+}
+
+// retryableCodes are the ClickHouse exception codes of the errors that can
+// succeed when retried.
+var retryableCodes = map[int]struct{}{
+	3:   {}, // UNEXPECTED_END_OF_FILE
+	159: {}, // TIMEOUT_EXCEEDED
+	202: {}, // TOO_MANY_SIMULTANEOUS_QUERIES
+	203: {}, // NO_FREE_CONNECTION
+	209: {}, // SOCKET_TIMEOUT
+	210: {}, // NETWORK_ERROR
+	241: {}, // MEMORY_LIMIT_EXCEEDED
+	242: {}, // TABLE_IS_READ_ONLY
+	252: {}, // TOO_MANY_PARTS
+	285: {}, // TOO_FEW_LIVE_REPLICAS
+	319: {}, // UNKNOWN_STATUS_OF_INSERT
+	425: {}, // SYSTEM_ERROR
+	999: {}, // KEEPER_EXCEPTION
+}
+
+// exceptionCode matches the code of the exceptions returned by the
+// database/sql drivers, such as "code: 60, message: Table default.test does
+// not exist".
+var exceptionCode = regexp.MustCompile(`code: ([0-9]+)`)
+
+func (e *queryError) Error() string {
+	switch {
+	case e.code != 0:
+		return fmt.Sprintf("clickhouse error code %d: %s", e.code, e.message)
+	case e.status != 0:
+		return fmt.Sprintf("clickhouse error status %d: %s", e.status, e.message)
+	default:
+		return e.message
+	}
+}
+
+// retryable returns true if the error is transient. Errors without a code
+// are only transient if they're not a client error.
+func (e *queryError) retryable() bool {
+	if e.code != 0 {
+		_, found := retryableCodes[e.code]
+		return found
+	}
+	return e.status == 0 || e.status >= http.StatusInternalServerError
+}
+
+// isPermanent returns true if the error on input is returned by ClickHouse for
+// a statement that will always fail, such as a syntax error, an unknown table
+// or a row that can't be parsed.
+func isPermanent(err error) bool {
+	qErr := &queryError{}
+	return errors.As(err, &qErr) && !qErr.retryable()
+}
+
+// httpClient sends the statements to the ClickHouse HTTP interface.
+type httpClient struct {
+	client   *http.Client
+	url      *url.URL
+	user     string
+	password string
+}
+
+func newHTTPClient(client *http.Client, rawURL, user, password string) (*httpClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing clickhouse url: %w", err)
+	}
+	// errors are only reported in the response status once the whole
+	// statement has been executed
+	query := u.Query()
+	query.Set("wait_end_of_query", "1")
+	u.RawQuery = query.Encode()
+
+	return &httpClient{
+		client:   client,
+		url:      u,
+		user:     user,
+		password: password,
+	}, nil
+}
+
+func (c *httpClient) exec(ctx context.Context, query string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url.String(), strings.NewReader(query))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	return c.do(req)
+}
+
+func (c *httpClient) ping(ctx context.Context) error {
+	pingURL := c.url.JoinPath("ping")
+	pingURL.RawQuery = ""
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pingURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req)
+}
+
+func (c *httpClient) close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *httpClient) do(req *http.Request) error {
+	if c.user != "" {
+		req.Header.Set("X-ClickHouse-User", c.user)
+		req.Header.Set("X-ClickHouse-Key", c.password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		return &queryError{message: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	code, _ := strconv.Atoi(resp.Header.Get("X-ClickHouse-Exception-Code"))
+	return &queryError{
+		code:    code,
+		status:  resp.StatusCode,
+		message: strings.TrimSpace(string(body)),
+	}
+}
+
+// sqlClient runs the statements with a database/sql ClickHouse driver.
+type sqlClient struct {
+	db *sql.DB
+}
+
+func (c *sqlClient) exec(ctx context.Context, query string) error {
+	if _, err := c.db.ExecContext(ctx, query); err != nil {
+		return newSQLQueryError(err)
+	}
+	return nil
+}
+
+func (c *sqlClient) ping(ctx context.Context) error {
+	return c.db.PingContext(ctx)
+}
+
+func (c *sqlClient) close() error {
+	return c.db.Close()
+}
+
+// newSQLQueryError returns the error of the database/sql driver on input as a
+// query error, with the exception code parsed from the error message.
+func newSQLQueryError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	qErr := &queryError{message: err.Error()}
+	if m := exceptionCode.FindStringSubmatch(err.Error()); m != nil {
+		qErr.code, _ = strconv.Atoi(m[1])
+	}
+	return qErr
+}
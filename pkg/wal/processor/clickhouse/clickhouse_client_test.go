@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPClient_exec(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+
+		wantErr       error
+		wantPermanent bool
+	}{
+		{
+			name: "ok",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodPost, r.Method)
+				require.Equal(t, "1", r.URL.Query().Get("wait_end_of_query"))
+				require.Equal(t, "pgstream", r.Header.Get("X-ClickHouse-User"))
+				require.Equal(t, "secret", r.Header.Get("X-ClickHouse-Key"))
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, "SELECT 1", string(body))
+			},
+		},
+		{
+			name: "error - unknown table",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-ClickHouse-Exception-Code", "60")
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, "Code: 60. DB::Exception: Table default.test does not exist.\n")
+			},
+
+			wantErr:       &queryError{code: 60, status: http.StatusNotFound, message: "Code: 60. DB::Exception: Table default.test does not exist."},
+			wantPermanent: true,
+		},
+		{
+			name: "error - too many parts",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-ClickHouse-Exception-Code", "252")
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprint(w, "Code: 252. DB::Exception: Too many parts")
+			},
+
+			wantErr: &queryError{code: 252, status: http.StatusInternalServerError, message: "Code: 252. DB::Exception: Too many parts"},
+		},
+		{
+			name: "error - proxy unavailable",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+
+			wantErr: &queryError{status: http.StatusBadGateway, message: ""},
+		},
+		{
+			name: "error - unauthorised without code",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			},
+
+			wantErr:       &queryError{status: http.StatusUnauthorized, message: ""},
+			wantPermanent: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(tc.handler)
+			defer server.Close()
+
+			c, err := newHTTPClient(server.Client(), server.URL, "pgstream", "secret")
+			require.NoError(t, err)
+
+			err = c.exec(context.Background(), "SELECT 1")
+			require.Equal(t, tc.wantErr, err)
+			if err != nil {
+				require.Equal(t, tc.wantPermanent, isPermanent(err))
+			}
+		})
+	}
+}
+
+func TestHTTPClient_ping(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/ping", r.URL.Path)
+		require.Empty(t, r.URL.RawQuery)
+		fmt.Fprint(w, "Ok.\n")
+	}))
+	defer server.Close()
+
+	c, err := newHTTPClient(server.Client(), server.URL, "", "")
+	require.NoError(t, err)
+	require.NoError(t, c.ping(context.Background()))
+}
+
+func TestNewSQLQueryError(t *testing.T) {
+	t.Parallel()
+
+	err := newSQLQueryError(errors.New("code: 62, message: Syntax error"))
+	require.Equal(t, &queryError{code: 62, message: "code: 62, message: Syntax error"}, err)
+	require.True(t, isPermanent(err))
+
+	err = newSQLQueryError(errors.New("dial tcp 127.0.0.1:9000: connect: connection refused"))
+	require.False(t, isPermanent(err))
+
+	err = newSQLQueryError(fmt.Errorf("exec: %w", context.Canceled))
+	require.ErrorIs(t, err, context.Canceled)
+	require.False(t, isPermanent(err))
+}
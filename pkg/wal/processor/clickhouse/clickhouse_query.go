@@ -0,0 +1,274 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouse
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// table is the database qualified name of a target table. Tables of the
+// public schema keep their name, and the tables of other schemas are prefixed
+// with the schema name, <schema>_<table>.
+type table struct {
+	database string
+	name     string
+}
+
+const (
+	// versionColumn and isDeletedColumn are the columns added to the
+	// ReplacingMergeTree tables, with the LSN of the last change of the row
+	// and whether the change was a delete.
+	versionColumn   = "_version"
+	isDeletedColumn = "_is_deleted"
+
+	publicSchema = "public"
+
+	// maxDecimalPrecision is the largest precision of the ClickHouse decimals
+	maxDecimalPrecision = 76
+)
+
+var (
+	// pgTypeModifiers matches the modifiers of a postgres type, i.e. the
+	// precision and scale of numeric(10,2)
+	pgTypeModifiers = regexp.MustCompile(`\(\s*([0-9]+)\s*(?:,\s*([0-9]+)\s*)?\)`)
+
+	identifierEscaper = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+	stringEscaper     = strings.NewReplacer("\\", "\\\\", "'", "\\'")
+)
+
+func newTable(database, schema, name string) table {
+	if schema != publicSchema {
+		name = schema + "_" + name
+	}
+	return table{database: database, name: name}
+}
+
+func (t table) String() string {
+	return quoteIdentifier(t.database) + "." + quoteIdentifier(t.name)
+}
+
+func createDatabaseQuery(database string) string {
+	return fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", quoteIdentifier(database))
+}
+
+// createTableQuery creates the table for the schema log table definition on
+// input, ordered by its primary key. Primary key columns can't be nullable,
+// since they're part of the sorting key.
+func createTableQuery(t table, tableDef *schemalog.Table, mode Mode) string {
+	columns := make([]string, 0, len(tableDef.Columns)+2)
+	for _, col := range tableDef.Columns {
+		columns = append(columns, columnDefinition(tableDef, col))
+	}
+
+	engine := "MergeTree"
+	if mode == ModeReplacingMergeTree {
+		columns = append(columns,
+			quoteIdentifier(versionColumn)+" UInt64",
+			quoteIdentifier(isDeletedColumn)+" UInt8")
+		engine = fmt.Sprintf("ReplacingMergeTree(%s, %s)", quoteIdentifier(versionColumn), quoteIdentifier(isDeletedColumn))
+	}
+
+	orderBy := "tuple()"
+	if len(tableDef.PrimaryKeyColumns) > 0 {
+		keys := make([]string, 0, len(tableDef.PrimaryKeyColumns))
+		for _, key := range tableDef.PrimaryKeyColumns {
+			keys = append(keys, quoteIdentifier(key))
+		}
+		orderBy = "(" + strings.Join(keys, ", ") + ")"
+	}
+
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = %s ORDER BY %s",
+		t, strings.Join(columns, ", "), engine, orderBy)
+}
+
+// addColumnsQuery adds the columns on input to the table, unless they already
+// exist.
+func addColumnsQuery(t table, tableDef *schemalog.Table, columns []schemalog.Column) string {
+	adds := make([]string, 0, len(columns))
+	for _, col := range columns {
+		adds = append(adds, "ADD COLUMN IF NOT EXISTS "+columnDefinition(tableDef, col))
+	}
+	return fmt.Sprintf("ALTER TABLE %s %s", t, strings.Join(adds, ", "))
+}
+
+func renameColumnQuery(t table, oldName, newName string) string {
+	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN IF EXISTS %s TO %s", t, quoteIdentifier(oldName), quoteIdentifier(newName))
+}
+
+func renameTableQuery(oldTable, newTable table) string {
+	return fmt.Sprintf("RENAME TABLE %s TO %s", oldTable, newTable)
+}
+
+// insertQuery inserts the JSON encoded rows on input. Timestamps are parsed
+// with the best effort format, which supports the postgres timestamps with
+// their time zone offset.
+func insertQuery(t table, rows [][]byte) string {
+	b := strings.Builder{}
+	fmt.Fprintf(&b, "INSERT INTO %s SETTINGS date_time_input_format = 'best_effort' FORMAT JSONEachRow\n", t)
+	for _, row := range rows {
+		b.Write(row)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// deleteQuery deletes the row matching the identity columns on input, with a
+// lightweight delete.
+func deleteQuery(t table, identity []wal.Column) (string, error) {
+	conditions := make([]string, 0, len(identity))
+	for _, col := range identity {
+		if col.Value == nil {
+			conditions = append(conditions, fmt.Sprintf("isNull(%s)", quoteIdentifier(col.Name)))
+			continue
+		}
+		literal, err := quoteLiteral(col.Value)
+		if err != nil {
+			return "", fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		conditions = append(conditions, fmt.Sprintf("%s = %s", quoteIdentifier(col.Name), literal))
+	}
+	return fmt.Sprintf("DELETE FROM %s WHERE %s", t, strings.Join(conditions, " AND ")), nil
+}
+
+func truncateQuery(t table) string {
+	return fmt.Sprintf("TRUNCATE TABLE IF EXISTS %s", t)
+}
+
+func columnDefinition(tableDef *schemalog.Table, col schemalog.Column) string {
+	typ := clickhouseType(col.DataType)
+	if col.Nullable && !tableDef.IsPrimaryKey(col.Name) {
+		typ = "Nullable(" + typ + ")"
+	}
+	return quoteIdentifier(col.Name) + " " + typ
+}
+
+// clickhouseType returns the ClickHouse type for the postgres type on input.
+// Booleans, integers, floating point numbers, numerics with a precision,
+// uuids, dates and timestamps are mapped to their ClickHouse types, and all
+// the other types, including json and arrays, are written as strings.
+func clickhouseType(pgType string) string {
+	t := strings.ToLower(strings.TrimSpace(pgType))
+	if strings.HasSuffix(t, "[]") {
+		return "String"
+	}
+	var modifiers []string
+	if m := pgTypeModifiers.FindStringSubmatch(t); m != nil {
+		modifiers = m[1:]
+		t = strings.Join(strings.Fields(strings.Replace(t, m[0], " ", 1)), " ")
+	}
+
+	switch t {
+	case "boolean", "bool":
+		return "Bool"
+	case "smallint", "int2", "smallserial", "serial2":
+		return "Int16"
+	case "integer", "int", "int4", "serial", "serial4":
+		return "Int32"
+	case "bigint", "int8", "bigserial", "serial8":
+		return "Int64"
+	case "real", "float4":
+		return "Float32"
+	case "double precision", "float8":
+		return "Float64"
+	case "numeric", "decimal":
+		if len(modifiers) == 0 {
+			return "String"
+		}
+		precision, _ := strconv.Atoi(modifiers[0])
+		scale, _ := strconv.Atoi(modifiers[1])
+		if precision <= 0 || precision > maxDecimalPrecision {
+			return "String"
+		}
+		return fmt.Sprintf("Decimal(%d, %d)", precision, scale)
+	case "uuid":
+		return "UUID"
+	case "date":
+		return "Date32"
+	case "timestamp", "timestamp without time zone":
+		return "DateTime64(6)"
+	case "timestamptz", "timestamp with time zone":
+		return "DateTime64(6, 'UTC')"
+	default:
+		return "String"
+	}
+}
+
+// rowJSON returns the JSON object of the columns on input, in the format
+// expected by JSONEachRow. Values of json and array columns that have been
+// decoded are encoded back into strings. The version and is deleted columns
+// are only included if the version is not nil.
+func rowJSON(columns []wal.Column, version *uint64, isDeleted bool) ([]byte, error) {
+	row := make(map[string]any, len(columns)+2)
+	for _, col := range columns {
+		value, err := columnValue(col)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		row[col.Name] = value
+	}
+	if version != nil {
+		row[versionColumn] = *version
+		row[isDeletedColumn] = 0
+		if isDeleted {
+			row[isDeletedColumn] = 1
+		}
+	}
+	return json.Marshal(row)
+}
+
+func columnValue(col wal.Column) (any, error) {
+	if col.Value == nil || clickhouseType(col.Type) != "String" {
+		return col.Value, nil
+	}
+	switch v := col.Value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+}
+
+// quoteLiteral returns the ClickHouse literal for the value on input.
+func quoteLiteral(value any) (string, error) {
+	switch v := value.(type) {
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case string:
+		return quoteString(v), nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return quoteString(string(b)), nil
+	}
+}
+
+func quoteIdentifier(name string) string {
+	return "`" + identifierEscaper.Replace(name) + "`"
+}
+
+func quoteString(s string) string {
+	return "'" + stringEscaper.Replace(s) + "'"
+}
@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouse
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+var testTableDef = &schemalog.Table{
+	Name: "users",
+	Columns: []schemalog.Column{
+		{Name: "id", DataType: "bigint"},
+		{Name: "name", DataType: "text", Nullable: true},
+		{Name: "created_at", DataType: "timestamp with time zone", Nullable: true},
+	},
+	PrimaryKeyColumns: []string{"id"},
+}
+
+func TestNewTable(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "`analytics`.`users`", newTable("analytics", "public", "users").String())
+	require.Equal(t, "`analytics`.`billing_invoices`", newTable("analytics", "billing", "invoices").String())
+	require.Equal(t, "`default`.`we\\`ird`", newTable("default", "public", "we`ird").String())
+}
+
+func TestCreateTableQuery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		tableDef *schemalog.Table
+		mode     Mode
+
+		wantQuery string
+	}{
+		{
+			name:     "replacing merge tree",
+			tableDef: testTableDef,
+			mode:     ModeReplacingMergeTree,
+
+			wantQuery: "CREATE TABLE IF NOT EXISTS `default`.`users` (`id` Int64, `name` Nullable(String), `created_at` Nullable(DateTime64(6, 'UTC')), `_version` UInt64, `_is_deleted` UInt8) ENGINE = ReplacingMergeTree(`_version`, `_is_deleted`) ORDER BY (`id`)",
+		},
+		{
+			name:     "lightweight delete",
+			tableDef: testTableDef,
+			mode:     ModeLightweightDelete,
+
+			wantQuery: "CREATE TABLE IF NOT EXISTS `default`.`users` (`id` Int64, `name` Nullable(String), `created_at` Nullable(DateTime64(6, 'UTC'))) ENGINE = MergeTree ORDER BY (`id`)",
+		},
+		{
+			name: "nullable primary key without sorting key",
+			tableDef: &schemalog.Table{
+				Name: "events",
+				Columns: []schemalog.Column{
+					{Name: "payload", DataType: "jsonb", Nullable: true},
+				},
+			},
+			mode: ModeLightweightDelete,
+
+			wantQuery: "CREATE TABLE IF NOT EXISTS `default`.`events` (`payload` Nullable(String)) ENGINE = MergeTree ORDER BY tuple()",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			q := createTableQuery(newTable("default", "public", tc.tableDef.Name), tc.tableDef, tc.mode)
+			require.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestAddColumnsQuery(t *testing.T) {
+	t.Parallel()
+
+	q := addColumnsQuery(newTable("default", "public", "users"), testTableDef, testTableDef.Columns[:2])
+	require.Equal(t, "ALTER TABLE `default`.`users` ADD COLUMN IF NOT EXISTS `id` Int64, ADD COLUMN IF NOT EXISTS `name` Nullable(String)", q)
+}
+
+func TestInsertQuery(t *testing.T) {
+	t.Parallel()
+
+	q := insertQuery(newTable("default", "public", "users"), [][]byte{[]byte(`{"id":1}`), []byte(`{"id":2}`)})
+	require.Equal(t, "INSERT INTO `default`.`users` SETTINGS date_time_input_format = 'best_effort' FORMAT JSONEachRow\n{\"id\":1}\n{\"id\":2}\n", q)
+}
+
+func TestDeleteQuery(t *testing.T) {
+	t.Parallel()
+
+	q, err := deleteQuery(newTable("default", "public", "users"), []wal.Column{
+		{Name: "id", Value: float64(1)},
+		{Name: "tenant", Value: "o'neil\\"},
+		{Name: "active", Value: true},
+		{Name: "deleted_at", Value: nil},
+		{Name: "count", Value: json.Number("12345678901234567890")},
+		{Name: "tags", Value: []any{"a"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "DELETE FROM `default`.`users` WHERE `id` = 1 AND `tenant` = 'o\\'neil\\\\' AND `active` = true AND isNull(`deleted_at`) AND `count` = 12345678901234567890 AND `tags` = '[\"a\"]'", q)
+}
+
+func TestClickhouseType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"boolean":                        "Bool",
+		"smallint":                       "Int16",
+		"integer":                        "Int32",
+		"serial":                         "Int32",
+		"bigint":                         "Int64",
+		"real":                           "Float32",
+		"double precision":               "Float64",
+		"numeric(10,2)":                  "Decimal(10, 2)",
+		"numeric(10)":                    "Decimal(10, 0)",
+		"numeric":                        "String",
+		"numeric(100,2)":                 "String",
+		"uuid":                           "UUID",
+		"date":                           "Date32",
+		"timestamp without time zone":    "DateTime64(6)",
+		"timestamp(3) without time zone": "DateTime64(6)",
+		"timestamp with time zone":       "DateTime64(6, 'UTC')",
+		"timestamptz":                    "DateTime64(6, 'UTC')",
+		"character varying(255)":         "String",
+		"jsonb":                          "String",
+		"integer[]":                      "String",
+	}
+
+	for pgType, want := range tests {
+		t.Run(pgType, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, want, clickhouseType(pgType))
+		})
+	}
+}
+
+func TestRowJSON(t *testing.T) {
+	t.Parallel()
+
+	columns := []wal.Column{
+		{Name: "id", Type: "bigint", Value: float64(1)},
+		{Name: "doc", Type: "jsonb", Value: map[string]any{"a": float64(1)}},
+		{Name: "tags", Type: "text[]", Value: []any{"a", "b"}},
+		{Name: "raw", Type: "json", Value: `{"b":2}`},
+		{Name: "price", Type: "numeric", Value: json.Number("1.50")},
+		{Name: "deleted_at", Type: "timestamp with time zone", Value: nil},
+	}
+
+	row, err := rowJSON(columns, nil, false)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":1,"doc":"{\"a\":1}","tags":"[\"a\",\"b\"]","raw":"{\"b\":2}","price":"1.50","deleted_at":null}`, string(row))
+
+	version := uint64(42)
+	row, err = rowJSON(columns[:1], &version, true)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":1,"_version":42,"_is_deleted":1}`, string(row))
+}
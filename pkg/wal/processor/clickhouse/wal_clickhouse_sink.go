@@ -0,0 +1,581 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouse
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/backoff"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	"github.com/xataio/pgstream/pkg/wal/processor/validate"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// ClickHouseSink is a wal processor that writes the events to ClickHouse
+// tables, through the HTTP interface or a database/sql driver. The inserts of
+// a batch are grouped per table into multi row INSERT statements. Depending
+// on the mode of the table, updates and deletes are either written as new
+// versions of the rows of a ReplacingMergeTree table, or applied with
+// lightweight deletes. The tables are created from the schema log events, and
+// the columns added or renamed in the source tables are added or renamed in
+// the target tables. Other schema changes are not replicated.
+type ClickHouseSink struct {
+	logger          loglib.Logger
+	batchSender     batchSender
+	instrumentation *otel.Instrumentation
+	client          client
+	backoffProvider backoff.Provider
+	lsnParser       replication.LSNParser
+	database        string
+	mode            Mode
+	tableModes      map[string]Mode
+	// deadLetterQueue receives the events rejected by ClickHouse. If not
+	// set, rejected events stop the processing.
+	deadLetterQueue validate.DeadLetterQueue
+
+	// schemas keeps the latest schema log entry of each schema, to compute
+	// the DDL of the schema changes
+	schemasMutex sync.Mutex
+	schemas      map[string]*schemalog.LogEntry
+
+	// optional checkpointer callback to mark what was safely processed
+	checkpointer checkpointer.Checkpoint
+}
+
+type Option func(*ClickHouseSink)
+
+type batchSender interface {
+	SendMessage(context.Context, *batch.WALMessage[message]) error
+	Close()
+}
+
+// message is a change to be applied to a table. The statements are run
+// before the rows are inserted. Messages with statements but no table, such
+// as the DDL of schema changes, apply to all tables.
+type message struct {
+	event      *wal.Event
+	table      table
+	rows       [][]byte
+	statements []string
+}
+
+var (
+	errMissingURL             = errors.New("missing clickhouse url or dsn")
+	errURLAndDSN              = errors.New("clickhouse url and dsn can't be combined")
+	errDriverNotRegistered    = errors.New("clickhouse driver not registered, import github.com/ClickHouse/clickhouse-go/v2 to register it")
+	errUnsupportedMode        = errors.New("unsupported clickhouse table mode")
+	errMissingIdentityColumns = errors.New("missing identity columns")
+)
+
+func NewClickHouseSink(ctx context.Context, cfg *Config, opts ...Option) (*ClickHouseSink, error) {
+	if cfg.URL == "" && cfg.DSN == "" {
+		return nil, errMissingURL
+	}
+	if cfg.URL != "" && cfg.DSN != "" {
+		return nil, errURLAndDSN
+	}
+	tableModes, err := cfg.tableModes()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ClickHouseSink{
+		logger:          loglib.NewNoopLogger(),
+		backoffProvider: backoff.NewProvider(cfg.retryPolicy()),
+		lsnParser:       pgreplication.NewLSNParser(),
+		database:        cfg.database(),
+		mode:            cfg.mode(),
+		tableModes:      tableModes,
+		schemas:         map[string]*schemalog.LogEntry{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.client, err = newClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.DeadLetterFile != "" {
+		if s.deadLetterQueue, err = validate.NewFileDeadLetterQueue(cfg.DeadLetterFile); err != nil {
+			s.client.close()
+			return nil, err
+		}
+	}
+
+	s.batchSender, err = batch.NewSender(ctx, &cfg.Batch, s.sendBatch, s.logger, batch.WithInstrumentation(s.instrumentation, s.Name()))
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func newClient(cfg *Config) (client, error) {
+	if cfg.URL != "" {
+		return newHTTPClient(&http.Client{}, cfg.URL, cfg.User, cfg.Password)
+	}
+
+	driverName := cfg.driverName()
+	if !slices.Contains(sql.Drivers(), driverName) {
+		return nil, fmt.Errorf("%w: %q", errDriverNotRegistered, driverName)
+	}
+	db, err := sql.Open(driverName, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("opening clickhouse connection: %w", err)
+	}
+	return &sqlClient{db: db}, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *ClickHouseSink) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "clickhouse_sink",
+		})
+	}
+}
+
+func WithCheckpoint(c checkpointer.Checkpoint) Option {
+	return func(s *ClickHouseSink) {
+		s.checkpointer = c
+	}
+}
+
+// WithInstrumentation reports the metrics of the batches sent by the sink.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(s *ClickHouseSink) {
+		s.instrumentation = i
+	}
+}
+
+// WithDeadLetterQueue sets the dead letter queue the events rejected by
+// ClickHouse are sent to, instead of the dead letter file.
+func WithDeadLetterQueue(q validate.DeadLetterQueue) Option {
+	return func(s *ClickHouseSink) {
+		s.deadLetterQueue = q
+	}
+}
+
+// ProcessWALEvent is called on every new message from the wal. It can be called
+// concurrently.
+func (s *ClickHouseSink) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Panic("[PANIC] Panic while processing replication event", loglib.Fields{
+				"wal_data":    walEvent,
+				"panic":       r,
+				"stack_trace": debug.Stack(),
+			})
+
+			retErr = fmt.Errorf("clickhouse sink: understanding event: %w: %v", processor.ErrPanic, r)
+		}
+	}()
+
+	msg, err := s.newMessage(walEvent)
+	if err != nil {
+		return err
+	}
+
+	return s.batchSender.SendMessage(ctx, batch.NewWALMessage(msg, walEvent.CommitPosition))
+}
+
+func (s *ClickHouseSink) Name() string {
+	return "clickhouse-sink"
+}
+
+func (s *ClickHouseSink) Close() error {
+	if s.batchSender != nil {
+		s.batchSender.Close()
+	}
+	var err error
+	if s.deadLetterQueue != nil {
+		err = s.deadLetterQueue.Close()
+	}
+	return errors.Join(err, s.client.close())
+}
+
+func (s *ClickHouseSink) Ping(ctx context.Context) error {
+	return s.client.ping(ctx)
+}
+
+// newMessage returns the message for the event on input. Events that aren't
+// applied to the tables, such as keep alives, are returned as empty messages
+// so that their position is still checkpointed.
+func (s *ClickHouseSink) newMessage(event *wal.Event) (message, error) {
+	data := event.Data
+	if data == nil {
+		return message{}, nil
+	}
+	if processor.IsSchemaLogEvent(data) {
+		statements, err := s.schemaStatements(data)
+		if err != nil || len(statements) == 0 {
+			return message{}, err
+		}
+		return message{event: event, statements: statements}, nil
+	}
+
+	t := newTable(s.database, data.Schema, data.Table)
+	msg := message{event: event, table: t}
+	switch wal.Action(data.Action) {
+	case wal.ActionInsert, wal.ActionUpdate, wal.ActionDelete:
+	case wal.ActionTruncate:
+		msg.statements = []string{truncateQuery(t)}
+		return msg, nil
+	default:
+		return message{}, nil
+	}
+
+	var err error
+	if s.tableMode(data.Schema, data.Table) == ModeReplacingMergeTree {
+		msg.rows, err = s.replacingRows(data)
+	} else {
+		msg.statements, msg.rows, err = s.lightweightDeleteChanges(t, data)
+	}
+	switch {
+	case errors.Is(err, errMissingIdentityColumns):
+		s.logger.Warn(err, "clickhouse sink: skipping event, the table needs a replica identity", loglib.Fields{
+			"schema": data.Schema,
+			"table":  data.Table,
+			"action": data.Action,
+		})
+		return message{}, nil
+	case err != nil:
+		return message{}, fmt.Errorf("clickhouse sink: table %s.%s: %w", data.Schema, data.Table, err)
+	}
+	return msg, nil
+}
+
+// replacingRows returns the rows of the event on input for a
+// ReplacingMergeTree table, versioned with the LSN of the event. Deletes only
+// include the identity columns of the row, and updates that change the
+// identity of the row also delete the row with the old identity.
+func (s *ClickHouseSink) replacingRows(data *wal.Data) ([][]byte, error) {
+	lsn, err := s.lsnParser.FromString(data.LSN)
+	if err != nil {
+		return nil, fmt.Errorf("parsing event lsn %q: %w", data.LSN, err)
+	}
+	version := uint64(lsn)
+
+	action := wal.Action(data.Action)
+	rows := [][]byte{}
+	if action == wal.ActionDelete || (action == wal.ActionUpdate && identityChanged(data)) {
+		identity := identityColumns(data)
+		if len(identity) == 0 {
+			return nil, errMissingIdentityColumns
+		}
+		row, err := rowJSON(identity, &version, true)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+	if action != wal.ActionDelete {
+		row, err := rowJSON(data.Columns, &version, false)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// lightweightDeleteChanges returns the statements and rows of the event on
+// input for a MergeTree table. Deletes are applied with a lightweight delete,
+// and updates delete the old row before inserting the new one.
+func (s *ClickHouseSink) lightweightDeleteChanges(t table, data *wal.Data) ([]string, [][]byte, error) {
+	var statements []string
+	if action := wal.Action(data.Action); action == wal.ActionUpdate || action == wal.ActionDelete {
+		identity := identityColumns(data)
+		if len(identity) == 0 {
+			return nil, nil, errMissingIdentityColumns
+		}
+		q, err := deleteQuery(t, identity)
+		if err != nil {
+			return nil, nil, err
+		}
+		statements = []string{q}
+		if action == wal.ActionDelete {
+			return statements, nil, nil
+		}
+	}
+
+	row, err := rowJSON(data.Columns, nil, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return statements, [][]byte{row}, nil
+}
+
+// schemaStatements returns the DDL statements that apply the schema changes of
+// the schema log event on input. The first time a schema is seen, since the
+// previous schema is not known, the tables are created and their columns added
+// if they don't exist already.
+func (s *ClickHouseSink) schemaStatements(data *wal.Data) ([]string, error) {
+	logEntry, err := processor.WalDataToLogEntry(data)
+	if err != nil {
+		return nil, fmt.Errorf("clickhouse sink: parsing schema log event: %w", err)
+	}
+
+	s.schemasMutex.Lock()
+	defer s.schemasMutex.Unlock()
+
+	previous, found := s.schemas[logEntry.SchemaName]
+	if found && logEntry.Version <= previous.Version {
+		return nil, nil
+	}
+	statements := []string{}
+	if len(s.schemas) == 0 {
+		statements = append(statements, createDatabaseQuery(s.database))
+	}
+	s.schemas[logEntry.SchemaName] = logEntry
+
+	schemaName := logEntry.SchemaName
+	diff := schemalog.ComputeSchemaDiff(previous, logEntry)
+	for i := range diff.TablesAdded {
+		tableDef := &diff.TablesAdded[i]
+		t := newTable(s.database, schemaName, tableDef.Name)
+		statements = append(statements, createTableQuery(t, tableDef, s.tableMode(schemaName, tableDef.Name)))
+		if previous == nil && len(tableDef.Columns) > 0 {
+			statements = append(statements, addColumnsQuery(t, tableDef, tableDef.Columns))
+		}
+	}
+
+	for _, tableDiff := range diff.TablesChanged {
+		tableDef, found := logEntry.GetTableByName(tableDiff.TableName)
+		if !found {
+			continue
+		}
+		t := newTable(s.database, schemaName, tableDef.Name)
+		if tableDiff.TableNameChange != nil {
+			statements = append(statements, renameTableQuery(newTable(s.database, schemaName, tableDiff.TableNameChange.Old), t))
+		}
+		for _, columnDiff := range tableDiff.ColumnsChanged {
+			if columnDiff.NameChange != nil {
+				statements = append(statements, renameColumnQuery(t, columnDiff.NameChange.Old, columnDiff.NameChange.New))
+			}
+		}
+		if len(tableDiff.ColumnsAdded) > 0 {
+			statements = append(statements, addColumnsQuery(t, &tableDef, tableDiff.ColumnsAdded))
+		}
+	}
+
+	return statements, nil
+}
+
+func (s *ClickHouseSink) tableMode(schema, tableName string) Mode {
+	if mode, found := s.tableModes[schema+"."+tableName]; found {
+		return mode
+	}
+	return s.mode
+}
+
+func (s *ClickHouseSink) sendBatch(ctx context.Context, batch *batch.Batch[message]) error {
+	messages := batch.GetMessages()
+	s.logger.Debug("clickhouse sink: writing message batch", loglib.Fields{
+		"batch_size":             len(messages),
+		"batch_commit_positions": len(batch.GetCommitPositions()),
+	})
+
+	if len(messages) > 0 {
+		if err := s.writeMessages(ctx, messages); err != nil {
+			s.logger.Error(err, "failed to write to clickhouse")
+			return fmt.Errorf("clickhouse sink: %w", err)
+		}
+	}
+
+	positions := batch.GetCommitPositions()
+	if s.checkpointer != nil && len(positions) > 0 {
+		if err := s.checkpointer(ctx, positions); err != nil {
+			s.logger.Warn(err, "clickhouse sink: error updating commit position")
+		}
+	}
+
+	return nil
+}
+
+// writeMessages applies the messages on input in order. The rows of each table
+// are inserted in bulk, before any statement that applies to the table is run.
+func (s *ClickHouseSink) writeMessages(ctx context.Context, messages []message) error {
+	tables := []table{}
+	pending := map[table][]message{}
+	flush := func(t table) error {
+		msgs, found := pending[t]
+		if !found {
+			return nil
+		}
+		delete(pending, t)
+		tables = slices.DeleteFunc(tables, func(pendingTable table) bool { return pendingTable == t })
+		return s.insert(ctx, t, msgs)
+	}
+	flushAll := func() error {
+		for len(tables) > 0 {
+			if err := flush(tables[0]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, msg := range messages {
+		if len(msg.statements) > 0 {
+			var err error
+			if msg.table == (table{}) {
+				err = flushAll()
+			} else {
+				err = flush(msg.table)
+			}
+			if err != nil {
+				return err
+			}
+			if err := s.execStatements(ctx, msg); err != nil {
+				return err
+			}
+		}
+
+		if len(msg.rows) > 0 {
+			if _, found := pending[msg.table]; !found {
+				tables = append(tables, msg.table)
+			}
+			pending[msg.table] = append(pending[msg.table], msg)
+		}
+	}
+
+	return flushAll()
+}
+
+// insert inserts the rows of the messages on input into the table with a
+// single statement. If the rows are rejected and there's a dead letter queue,
+// the rows of each message are inserted separately, so that only the rejected
+// ones are sent to the dead letter queue.
+func (s *ClickHouseSink) insert(ctx context.Context, t table, msgs []message) error {
+	rows := make([][]byte, 0, len(msgs))
+	for _, msg := range msgs {
+		rows = append(rows, msg.rows...)
+	}
+	err := s.exec(ctx, insertQuery(t, rows))
+	switch {
+	case err == nil:
+		return nil
+	case !isPermanent(err) || s.deadLetterQueue == nil:
+		return fmt.Errorf("inserting rows into table %s: %w", t, err)
+	case len(msgs) == 1:
+		return s.sendToDeadLetterQueue(ctx, msgs[0], err)
+	}
+
+	for _, msg := range msgs {
+		if err := s.exec(ctx, insertQuery(t, msg.rows)); err != nil {
+			if !isPermanent(err) {
+				return fmt.Errorf("inserting rows into table %s: %w", t, err)
+			}
+			if err := s.sendToDeadLetterQueue(ctx, msg, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *ClickHouseSink) execStatements(ctx context.Context, msg message) error {
+	for _, statement := range msg.statements {
+		err := s.exec(ctx, statement)
+		switch {
+		case err == nil:
+		case isPermanent(err) && s.deadLetterQueue != nil:
+			return s.sendToDeadLetterQueue(ctx, msg, err)
+		default:
+			return fmt.Errorf("executing %q: %w", statement, err)
+		}
+	}
+	return nil
+}
+
+// exec runs the statement on input, retrying it while it fails with transient
+// errors.
+func (s *ClickHouseSink) exec(ctx context.Context, query string) error {
+	return s.backoffProvider(ctx).RetryNotify(
+		func() error {
+			err := s.client.exec(ctx, query)
+			if err != nil && (isPermanent(err) || errors.Is(err, context.Canceled)) {
+				return fmt.Errorf("%w: %w", err, backoff.ErrPermanent)
+			}
+			return err
+		},
+		func(err error, d time.Duration) {
+			s.logger.Warn(err, "clickhouse sink: statement failed, retrying", loglib.Fields{
+				"retry_after": d,
+			})
+		})
+}
+
+func (s *ClickHouseSink) sendToDeadLetterQueue(ctx context.Context, msg message, reason error) error {
+	s.logger.Warn(reason, "clickhouse sink: event rejected, sending to the dead letter queue", loglib.Fields{
+		"table":           msg.table.String(),
+		"commit_position": msg.event.CommitPosition,
+	})
+	if err := s.deadLetterQueue.Send(ctx, msg.event, reason); err != nil {
+		return fmt.Errorf("sending rejected event to the dead letter queue: %w", err)
+	}
+	return nil
+}
+
+// identityColumns returns the columns identifying the row of the event on
+// input, which are the replica identity columns, falling back to the primary
+// key columns identified by the injector.
+func identityColumns(data *wal.Data) []wal.Column {
+	if len(data.Identity) > 0 {
+		return data.Identity
+	}
+	pkColumns := []wal.Column{}
+	for _, col := range data.Columns {
+		if slices.Contains(data.Metadata.InternalColIDs, col.ID) {
+			pkColumns = append(pkColumns, col)
+		}
+	}
+	return pkColumns
+}
+
+// identityChanged returns true if the update on input changes the values of
+// the replica identity columns of the row.
+func identityChanged(data *wal.Data) bool {
+	for _, identityCol := range data.Identity {
+		idx := slices.IndexFunc(data.Columns, func(col wal.Column) bool { return col.Name == identityCol.Name })
+		if idx == -1 || fmt.Sprint(data.Columns[idx].Value) != fmt.Sprint(identityCol.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m message) Size() int {
+	size := 0
+	for _, row := range m.rows {
+		size += len(row)
+	}
+	for _, statement := range m.statements {
+		size += len(statement)
+	}
+	return size
+}
+
+func (m message) IsEmpty() bool {
+	return m.event == nil
+}
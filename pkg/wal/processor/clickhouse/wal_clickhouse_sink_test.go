@@ -0,0 +1,502 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/backoff"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	batchmocks "github.com/xataio/pgstream/pkg/wal/processor/batch/mocks"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+type mockClient struct {
+	execFn  func(query string) error
+	queries []string
+}
+
+func (m *mockClient) exec(_ context.Context, query string) error {
+	m.queries = append(m.queries, query)
+	if m.execFn != nil {
+		return m.execFn(query)
+	}
+	return nil
+}
+
+func (m *mockClient) ping(context.Context) error {
+	return nil
+}
+
+func (m *mockClient) close() error {
+	return nil
+}
+
+type mockDeadLetterQueue struct {
+	events  []*wal.Event
+	reasons []error
+}
+
+func (m *mockDeadLetterQueue) Send(_ context.Context, event *wal.Event, reason error) error {
+	m.events = append(m.events, event)
+	m.reasons = append(m.reasons, reason)
+	return nil
+}
+
+func (m *mockDeadLetterQueue) Close() error {
+	return nil
+}
+
+var (
+	errTest         = errors.New("oh noes")
+	errTestRejected = &queryError{code: 27, message: "Cannot parse input"}
+)
+
+func newTestSink(client client) *ClickHouseSink {
+	return &ClickHouseSink{
+		logger: loglib.NewNoopLogger(),
+		client: client,
+		backoffProvider: backoff.NewProvider(&backoff.Config{
+			Constant: &backoff.ConstantConfig{Interval: time.Millisecond, MaxRetries: 2},
+		}),
+		lsnParser: pgreplication.NewLSNParser(),
+		database:  defaultDatabase,
+		mode:      ModeReplacingMergeTree,
+		tableModes: map[string]Mode{
+			"public.events": ModeLightweightDelete,
+		},
+		schemas: map[string]*schemalog.LogEntry{},
+	}
+}
+
+func newTestData(action wal.Action, tableName string, id int) *wal.Data {
+	data := &wal.Data{
+		Action: string(action),
+		LSN:    "0/10",
+		Schema: "public",
+		Table:  tableName,
+	}
+	switch action {
+	case wal.ActionInsert, wal.ActionUpdate:
+		data.Columns = []wal.Column{
+			{Name: "id", Type: "integer", Value: id},
+			{Name: "name", Type: "text", Value: "a"},
+		}
+	}
+	switch action {
+	case wal.ActionUpdate, wal.ActionDelete:
+		data.Identity = []wal.Column{{Name: "id", Type: "integer", Value: id}}
+	}
+	return data
+}
+
+func newTestSchemaLogEvent(version int64, schema string) *wal.Event {
+	return &wal.Event{
+		Data: &wal.Data{
+			Action: string(wal.ActionInsert),
+			Schema: schemalog.SchemaName,
+			Table:  schemalog.TableName,
+			Columns: []wal.Column{
+				{Name: "id", Value: "cmdrsfh1u0k6667mfvu0"},
+				{Name: "version", Value: version},
+				{Name: "schema_name", Value: "public"},
+				{Name: "schema", Value: schema},
+			},
+		},
+		CommitPosition: "0/20",
+	}
+}
+
+func TestNewClickHouseSink(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config *Config
+
+		wantErr error
+	}{
+		{
+			name:    "error - missing url",
+			config:  &Config{},
+			wantErr: errMissingURL,
+		},
+		{
+			name:    "error - url and dsn",
+			config:  &Config{URL: "http://localhost:8123", DSN: "clickhouse://localhost:9000"},
+			wantErr: errURLAndDSN,
+		},
+		{
+			name:    "error - unsupported mode",
+			config:  &Config{URL: "http://localhost:8123", Mode: "collapsing"},
+			wantErr: errUnsupportedMode,
+		},
+		{
+			name:    "error - unsupported table mode",
+			config:  &Config{URL: "http://localhost:8123", TableModes: map[string]Mode{"users": "collapsing"}},
+			wantErr: errUnsupportedMode,
+		},
+		{
+			name:    "error - driver not registered",
+			config:  &Config{DSN: "clickhouse://localhost:9000", DriverName: "unknown"},
+			wantErr: errDriverNotRegistered,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewClickHouseSink(context.Background(), tc.config)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestConfig_tableModes(t *testing.T) {
+	t.Parallel()
+
+	cfg := &Config{TableModes: map[string]Mode{
+		"users":          ModeLightweightDelete,
+		"billing.events": ModeReplacingMergeTree,
+	}}
+	modes, err := cfg.tableModes()
+	require.NoError(t, err)
+	require.Equal(t, map[string]Mode{
+		"public.users":   ModeLightweightDelete,
+		"billing.events": ModeReplacingMergeTree,
+	}, modes)
+}
+
+func TestClickHouseSink_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	usersTable := newTable(defaultDatabase, "public", "users")
+	eventsTable := newTable(defaultDatabase, "public", "events")
+	deleteWithoutIdentity := newTestData(wal.ActionDelete, "users", 1)
+	deleteWithoutIdentity.Identity = nil
+	updatedKey := newTestData(wal.ActionUpdate, "users", 2)
+	updatedKey.Identity = []wal.Column{{Name: "id", Type: "integer", Value: 1}}
+
+	tests := []struct {
+		name string
+		data *wal.Data
+
+		wantMsg *message
+		wantErr error
+	}{
+		{
+			name: "ok - replacing insert",
+			data: newTestData(wal.ActionInsert, "users", 1),
+
+			wantMsg: &message{
+				table: usersTable,
+				rows:  [][]byte{[]byte(`{"_is_deleted":0,"_version":16,"id":1,"name":"a"}`)},
+			},
+		},
+		{
+			name: "ok - replacing delete",
+			data: newTestData(wal.ActionDelete, "users", 1),
+
+			wantMsg: &message{
+				table: usersTable,
+				rows:  [][]byte{[]byte(`{"_is_deleted":1,"_version":16,"id":1}`)},
+			},
+		},
+		{
+			name: "ok - replacing update of the identity",
+			data: updatedKey,
+
+			wantMsg: &message{
+				table: usersTable,
+				rows: [][]byte{
+					[]byte(`{"_is_deleted":1,"_version":16,"id":1}`),
+					[]byte(`{"_is_deleted":0,"_version":16,"id":2,"name":"a"}`),
+				},
+			},
+		},
+		{
+			name: "ok - lightweight delete update",
+			data: newTestData(wal.ActionUpdate, "events", 1),
+
+			wantMsg: &message{
+				table:      eventsTable,
+				statements: []string{"DELETE FROM `default`.`events` WHERE `id` = 1"},
+				rows:       [][]byte{[]byte(`{"id":1,"name":"a"}`)},
+			},
+		},
+		{
+			name: "ok - lightweight delete",
+			data: newTestData(wal.ActionDelete, "events", 1),
+
+			wantMsg: &message{
+				table:      eventsTable,
+				statements: []string{"DELETE FROM `default`.`events` WHERE `id` = 1"},
+			},
+		},
+		{
+			name: "ok - truncate",
+			data: newTestData(wal.ActionTruncate, "users", 0),
+
+			wantMsg: &message{
+				table:      usersTable,
+				statements: []string{"TRUNCATE TABLE IF EXISTS `default`.`users`"},
+			},
+		},
+		{
+			name: "ok - delete without identity skipped",
+			data: deleteWithoutIdentity,
+
+			wantMsg: &message{},
+		},
+		{
+			name: "ok - keep alive",
+			data: nil,
+
+			wantMsg: &message{},
+		},
+		{
+			name: "error - invalid lsn",
+			data: func() *wal.Data {
+				d := newTestData(wal.ActionInsert, "users", 1)
+				d.LSN = "invalid"
+				return d
+			}(),
+
+			wantErr: errors.New("parsing event lsn"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockBatchSender := batchmocks.NewBatchSender[message]()
+			s := newTestSink(&mockClient{})
+			s.batchSender = mockBatchSender
+
+			event := &wal.Event{Data: tc.data, CommitPosition: "0/10"}
+			doneChan := make(chan struct{})
+			go func() {
+				defer close(doneChan)
+				msgs := mockBatchSender.GetWALMessages()
+				if tc.wantMsg == nil {
+					require.Empty(t, msgs)
+					return
+				}
+				require.Len(t, msgs, 1)
+				require.Equal(t, wal.CommitPosition("0/10"), msgs[0].GetPosition())
+				got := msgs[0].GetMessage()
+				if !got.IsEmpty() {
+					require.Equal(t, event, got.event)
+					tc.wantMsg.event = event
+				}
+				require.Equal(t, *tc.wantMsg, got)
+			}()
+
+			err := s.ProcessWALEvent(context.Background(), event)
+			if tc.wantErr != nil {
+				require.ErrorContains(t, err, tc.wantErr.Error())
+			} else {
+				require.NoError(t, err)
+			}
+			mockBatchSender.Close()
+			<-doneChan
+		})
+	}
+}
+
+func TestClickHouseSink_schemaStatements(t *testing.T) {
+	t.Parallel()
+
+	s := newTestSink(&mockClient{})
+
+	msg, err := s.newMessage(newTestSchemaLogEvent(1, `{"tables":[{"name":"users","pgstream_id":"t1","primary_key_columns":["id"],"columns":[{"name":"id","type":"bigint","pgstream_id":"c1"}]}]}`))
+	require.NoError(t, err)
+	require.Equal(t, table{}, msg.table)
+	require.Equal(t, []string{
+		"CREATE DATABASE IF NOT EXISTS `default`",
+		"CREATE TABLE IF NOT EXISTS `default`.`users` (`id` Int64, `_version` UInt64, `_is_deleted` UInt8) ENGINE = ReplacingMergeTree(`_version`, `_is_deleted`) ORDER BY (`id`)",
+		"ALTER TABLE `default`.`users` ADD COLUMN IF NOT EXISTS `id` Int64",
+	}, msg.statements)
+
+	msg, err = s.newMessage(newTestSchemaLogEvent(2, `{"tables":[{"name":"customers","pgstream_id":"t1","primary_key_columns":["id"],"columns":[{"name":"id","type":"bigint","pgstream_id":"c1"},{"name":"email","type":"text","nullable":true,"pgstream_id":"c2"}]}]}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"RENAME TABLE `default`.`users` TO `default`.`customers`",
+		"ALTER TABLE `default`.`customers` ADD COLUMN IF NOT EXISTS `email` Nullable(String)",
+	}, msg.statements)
+
+	msg, err = s.newMessage(newTestSchemaLogEvent(3, `{"tables":[{"name":"customers","pgstream_id":"t1","primary_key_columns":["id"],"columns":[{"name":"id","type":"bigint","pgstream_id":"c1"},{"name":"contact","type":"text","nullable":true,"pgstream_id":"c2"}]}]}`))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"ALTER TABLE `default`.`customers` RENAME COLUMN IF EXISTS `email` TO `contact`",
+	}, msg.statements)
+
+	// older versions are ignored
+	msg, err = s.newMessage(newTestSchemaLogEvent(2, `{"tables":[]}`))
+	require.NoError(t, err)
+	require.True(t, msg.IsEmpty())
+}
+
+func TestClickHouseSink_sendBatch(t *testing.T) {
+	t.Parallel()
+
+	testPositions := []wal.CommitPosition{"0/10", "0/20"}
+
+	s := newTestSink(&mockClient{})
+	newMsg := func(data *wal.Data) message {
+		msg, err := s.newMessage(&wal.Event{Data: data, CommitPosition: "0/10"})
+		require.NoError(t, err)
+		return msg
+	}
+	schemaMsg, err := s.newMessage(newTestSchemaLogEvent(1, `{"tables":[]}`))
+	require.NoError(t, err)
+
+	testMessages := []message{
+		newMsg(newTestData(wal.ActionInsert, "users", 1)),
+		newMsg(newTestData(wal.ActionInsert, "events", 1)),
+		newMsg(newTestData(wal.ActionInsert, "users", 2)),
+		newMsg(newTestData(wal.ActionDelete, "events", 1)),
+		newMsg(newTestData(wal.ActionInsert, "events", 2)),
+		schemaMsg,
+		newMsg(newTestData(wal.ActionInsert, "users", 3)),
+	}
+
+	tests := []struct {
+		name     string
+		execFn   func(query string) error
+		dlq      *mockDeadLetterQueue
+		messages []message
+
+		wantQueries     []string
+		wantCheckpoints [][]wal.CommitPosition
+		wantDLQEvents   int
+		wantErr         error
+	}{
+		{
+			name:     "ok - rows inserted in bulk before the statements of their table",
+			messages: testMessages,
+
+			wantQueries: []string{
+				"INSERT INTO `default`.`events` FORMAT JSONEachRow\n{\"id\":1,\"name\":\"a\"}\n",
+				"DELETE FROM `default`.`events` WHERE `id` = 1",
+				"INSERT INTO `default`.`users` FORMAT JSONEachRow\n{\"_is_deleted\":0,\"_version\":16,\"id\":1,\"name\":\"a\"}\n{\"_is_deleted\":0,\"_version\":16,\"id\":2,\"name\":\"a\"}\n",
+				"INSERT INTO `default`.`events` FORMAT JSONEachRow\n{\"id\":2,\"name\":\"a\"}\n",
+				"CREATE DATABASE IF NOT EXISTS `default`",
+				"INSERT INTO `default`.`users` FORMAT JSONEachRow\n{\"_is_deleted\":0,\"_version\":16,\"id\":3,\"name\":\"a\"}\n",
+			},
+			wantCheckpoints: [][]wal.CommitPosition{testPositions},
+		},
+		{
+			name:     "ok - transient errors retried",
+			messages: testMessages[:1],
+			execFn: func() func(string) error {
+				calls := 0
+				return func(string) error {
+					calls++
+					if calls == 1 {
+						return &queryError{code: 252, message: "Too many parts"}
+					}
+					return nil
+				}
+			}(),
+
+			wantQueries: []string{
+				"INSERT INTO `default`.`users` FORMAT JSONEachRow\n{\"_is_deleted\":0,\"_version\":16,\"id\":1,\"name\":\"a\"}\n",
+				"INSERT INTO `default`.`users` FORMAT JSONEachRow\n{\"_is_deleted\":0,\"_version\":16,\"id\":1,\"name\":\"a\"}\n",
+			},
+			wantCheckpoints: [][]wal.CommitPosition{testPositions},
+		},
+		{
+			name:     "ok - rejected rows sent to the dead letter queue",
+			messages: []message{testMessages[0], testMessages[2], testMessages[3]},
+			dlq:      &mockDeadLetterQueue{},
+			execFn: func(query string) error {
+				if strings.Contains(query, `"id":2`) || strings.HasPrefix(query, "DELETE") {
+					return errTestRejected
+				}
+				return nil
+			},
+
+			wantQueries: []string{
+				"DELETE FROM `default`.`events` WHERE `id` = 1",
+				"INSERT INTO `default`.`users` FORMAT JSONEachRow\n{\"_is_deleted\":0,\"_version\":16,\"id\":1,\"name\":\"a\"}\n{\"_is_deleted\":0,\"_version\":16,\"id\":2,\"name\":\"a\"}\n",
+				"INSERT INTO `default`.`users` FORMAT JSONEachRow\n{\"_is_deleted\":0,\"_version\":16,\"id\":1,\"name\":\"a\"}\n",
+				"INSERT INTO `default`.`users` FORMAT JSONEachRow\n{\"_is_deleted\":0,\"_version\":16,\"id\":2,\"name\":\"a\"}\n",
+			},
+			wantCheckpoints: [][]wal.CommitPosition{testPositions},
+			wantDLQEvents:   2,
+		},
+		{
+			name:     "error - rejected rows without dead letter queue",
+			messages: testMessages[:1],
+			execFn:   func(string) error { return errTestRejected },
+
+			wantQueries: []string{
+				"INSERT INTO `default`.`users` FORMAT JSONEachRow\n{\"_is_deleted\":0,\"_version\":16,\"id\":1,\"name\":\"a\"}\n",
+			},
+			wantCheckpoints: [][]wal.CommitPosition{},
+			wantErr:         errTestRejected,
+		},
+		{
+			name:     "error - transient errors not sent to the dead letter queue",
+			messages: testMessages[:1],
+			dlq:      &mockDeadLetterQueue{},
+			execFn:   func(string) error { return &queryError{message: errTest.Error()} },
+
+			wantQueries: []string{
+				"INSERT INTO `default`.`users` FORMAT JSONEachRow\n{\"_is_deleted\":0,\"_version\":16,\"id\":1,\"name\":\"a\"}\n",
+				"INSERT INTO `default`.`users` FORMAT JSONEachRow\n{\"_is_deleted\":0,\"_version\":16,\"id\":1,\"name\":\"a\"}\n",
+				"INSERT INTO `default`.`users` FORMAT JSONEachRow\n{\"_is_deleted\":0,\"_version\":16,\"id\":1,\"name\":\"a\"}\n",
+			},
+			wantCheckpoints: [][]wal.CommitPosition{},
+			wantErr:         &queryError{message: errTest.Error()},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &mockClient{execFn: tc.execFn}
+			sink := newTestSink(client)
+			if tc.dlq != nil {
+				sink.deadLetterQueue = tc.dlq
+			}
+			checkpoints := [][]wal.CommitPosition{}
+			sink.checkpointer = func(_ context.Context, positions []wal.CommitPosition) error {
+				checkpoints = append(checkpoints, positions)
+				return nil
+			}
+
+			err := sink.sendBatch(context.Background(), batch.NewBatch(tc.messages, testPositions))
+			if tc.wantErr != nil {
+				require.ErrorAs(t, err, new(*queryError))
+				require.ErrorContains(t, err, tc.wantErr.Error())
+			} else {
+				require.NoError(t, err)
+			}
+			// the settings of the inserts are covered by the query tests
+			for i, q := range client.queries {
+				client.queries[i] = strings.Replace(q, " SETTINGS date_time_input_format = 'best_effort'", "", 1)
+			}
+			require.Equal(t, tc.wantQueries, client.queries)
+			require.Equal(t, tc.wantCheckpoints, checkpoints)
+			if tc.dlq != nil {
+				require.Len(t, tc.dlq.events, tc.wantDLQEvents)
+				for _, reason := range tc.dlq.reasons {
+					require.ErrorIs(t, reason, errTestRejected)
+				}
+			}
+		})
+	}
+}
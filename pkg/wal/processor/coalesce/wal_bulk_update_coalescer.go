@@ -0,0 +1,452 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// BulkUpdateCoalescer is a decorator around a wal processor that collects the
+// update events of the same row within a time window, and sends them to the
+// wrapped processor as a single update. The merged update has the latest
+// values of the union of the columns of the collected updates, and the
+// identity of the row before the first one. This reduces the load on remote
+// sinks for tables with very frequent updates of the same rows (e.g. a
+// last_seen_at timestamp).
+//
+// The window of a row starts with its first collected update, so that rows
+// that are updated continuously are still sent once per window. A delete of a
+// row sends its pending update first, and truncates and schema changes send
+// the pending updates of the affected tables. Any other event is sent
+// immediately, which means the merged updates can be sent after events that
+// were produced later, but the updates of a row are never reordered with
+// respect to the other changes of the same row.
+type BulkUpdateCoalescer struct {
+	logger    loglib.Logger
+	processor processor.Processor
+	clock     func() time.Time
+
+	// tables is the set of tables the updates are coalesced for. If empty,
+	// the updates of all tables are coalesced.
+	tables          map[string]struct{}
+	window          time.Duration
+	maxBufferedRows int
+
+	// mutex guards the pending updates, and serialises the calls to the
+	// wrapped processor
+	mutex sync.Mutex
+	// pending has the merged update of each row, by the key of the row after
+	// the update, and order has them in order of arrival of their first
+	// update
+	pending map[string]*pendingUpdate
+	order   []*pendingUpdate
+	// lastPosition is the latest commit position received while there are
+	// pending updates. It's used to make sure the positions sent to the
+	// wrapped processor never move past the pending updates.
+	lastPosition wal.CommitPosition
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type pendingUpdate struct {
+	key           string
+	event         *wal.Event
+	firstSeen     time.Time
+	firstPosition wal.CommitPosition
+}
+
+type Config struct {
+	// Tables are the tables whose updates are coalesced. Tables should be
+	// schema qualified. If no schema is provided, the public schema will be
+	// assumed. If empty, the updates of all tables are coalesced.
+	Tables []string
+	// Window is the maximum time the updates of a row are collected before
+	// being sent merged. Defaults to 1s.
+	Window time.Duration
+	// MaxBufferedRows is the maximum number of rows with pending updates.
+	// When reached, the oldest pending update is sent regardless of the
+	// window. Defaults to 10000.
+	MaxBufferedRows int
+}
+
+type Option func(c *BulkUpdateCoalescer)
+
+const (
+	defaultWindow          = time.Second
+	defaultMaxBufferedRows = 10000
+
+	publicSchema = "public"
+	keySeparator = "\x00"
+)
+
+// New will return a bulk update coalescer wrapper around the processor on
+// input. The pending updates are sent when their window expires, even if no
+// new events are received, until the processor is closed.
+func New(cfg *Config, p processor.Processor, opts ...Option) *BulkUpdateCoalescer {
+	c := newBulkUpdateCoalescer(cfg, p)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	flushCtx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.flushOnWindow(flushCtx)
+	}()
+
+	return c
+}
+
+func newBulkUpdateCoalescer(cfg *Config, p processor.Processor) *BulkUpdateCoalescer {
+	tables := make(map[string]struct{}, len(cfg.Tables))
+	for _, table := range cfg.Tables {
+		tables[qualifiedTableName(table)] = struct{}{}
+	}
+
+	return &BulkUpdateCoalescer{
+		logger:          loglib.NewNoopLogger(),
+		processor:       p,
+		clock:           time.Now,
+		tables:          tables,
+		window:          cfg.window(),
+		maxBufferedRows: cfg.maxBufferedRows(),
+		pending:         map[string]*pendingUpdate{},
+	}
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(c *BulkUpdateCoalescer) {
+		c.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_bulk_update_coalescer",
+		})
+	}
+}
+
+// ProcessWALEvent collects the update events of the configured tables, and
+// sends any other event to the wrapped processor, sending the pending updates
+// it depends on first.
+func (c *BulkUpdateCoalescer) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.flushExpired(ctx); err != nil {
+		return err
+	}
+
+	data := event.Data
+	switch {
+	case data == nil:
+		return c.processImmediately(ctx, event)
+	case processor.IsSchemaLogEvent(data):
+		// schema changes could affect the pending updates
+		if err := c.flushAll(ctx); err != nil {
+			return err
+		}
+		return c.processor.ProcessWALEvent(ctx, event)
+	case !c.coalescesTable(data):
+		return c.processImmediately(ctx, event)
+	}
+
+	switch wal.Action(data.Action) {
+	case wal.ActionUpdate:
+		return c.addUpdate(ctx, event)
+	case wal.ActionDelete:
+		if key, ok := rowKey(data, data.Identity); ok {
+			if err := c.flushKey(ctx, key); err != nil {
+				return err
+			}
+		}
+	case wal.ActionTruncate:
+		if err := c.flushTable(ctx, data.Schema, data.Table); err != nil {
+			return err
+		}
+	}
+	return c.processImmediately(ctx, event)
+}
+
+func (c *BulkUpdateCoalescer) Name() string {
+	return c.processor.Name()
+}
+
+// Close stops the window flushing and sends any pending updates to the
+// wrapped processor before closing it.
+func (c *BulkUpdateCoalescer) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+
+	c.mutex.Lock()
+	err := c.flushAll(context.Background())
+	c.mutex.Unlock()
+
+	return errors.Join(err, c.processor.Close())
+}
+
+func (c *BulkUpdateCoalescer) Ping(ctx context.Context) error {
+	return c.processor.Ping(ctx)
+}
+
+func (c *BulkUpdateCoalescer) flushOnWindow(ctx context.Context) {
+	ticker := time.NewTicker(c.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.mutex.Lock()
+			// if the flush fails, the remaining updates are kept pending and
+			// the error is returned by the next ProcessWALEvent call
+			if err := c.flushExpired(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				c.logger.Error(err, "flushing bulk update window")
+			}
+			c.mutex.Unlock()
+		}
+	}
+}
+
+// addUpdate merges the update event on input with the pending update of its
+// row, if any, or adds it as a new pending update otherwise. Updates whose row
+// can't be identified are sent immediately. Must be called with the mutex
+// held.
+func (c *BulkUpdateCoalescer) addUpdate(ctx context.Context, event *wal.Event) error {
+	data := event.Data
+	oldKey, oldKeyFound := rowKey(data, data.Identity)
+	newKey, newKeyFound := rowKey(data, data.Columns)
+	// updates that don't include the replica identity didn't change it, so
+	// the row is identified by its pgstream identity columns, if any
+	if len(data.Identity) == 0 && newKeyFound {
+		oldKey, oldKeyFound = newKey, true
+	}
+	if !oldKeyFound || !newKeyFound {
+		if oldKeyFound {
+			if err := c.flushKey(ctx, oldKey); err != nil {
+				return err
+			}
+		}
+		return c.processImmediately(ctx, event)
+	}
+
+	// if the update moves the row to the key of a different pending row, that
+	// row's update needs to be sent first
+	if existing, found := c.pending[newKey]; found && newKey != oldKey {
+		if err := c.flush(ctx, existing); err != nil {
+			return err
+		}
+	}
+
+	if event.CommitPosition != "" {
+		c.lastPosition = event.CommitPosition
+	}
+
+	if pending, found := c.pending[oldKey]; found {
+		pending.event = mergeUpdates(pending.event, event)
+		if newKey != oldKey {
+			delete(c.pending, oldKey)
+			pending.key = newKey
+			c.pending[newKey] = pending
+		}
+		return nil
+	}
+
+	pending := &pendingUpdate{
+		key:           newKey,
+		event:         event,
+		firstSeen:     c.clock(),
+		firstPosition: event.CommitPosition,
+	}
+	c.pending[newKey] = pending
+	c.order = append(c.order, pending)
+
+	if len(c.order) > c.maxBufferedRows {
+		return c.flush(ctx, c.order[0])
+	}
+	return nil
+}
+
+// processImmediately sends the event on input to the wrapped processor
+// without collecting it. If there are pending updates, the event commit
+// position is replaced by the position of the oldest one, so that it doesn't
+// checkpoint past them. Must be called with the mutex held.
+func (c *BulkUpdateCoalescer) processImmediately(ctx context.Context, event *wal.Event) error {
+	if len(c.order) > 0 && event.CommitPosition != "" {
+		c.lastPosition = event.CommitPosition
+		event.CommitPosition = c.order[0].firstPosition
+	}
+	return c.processor.ProcessWALEvent(ctx, event)
+}
+
+// flushExpired sends the pending updates whose window has expired. Must be
+// called with the mutex held.
+func (c *BulkUpdateCoalescer) flushExpired(ctx context.Context) error {
+	now := c.clock()
+	for len(c.order) > 0 && now.Sub(c.order[0].firstSeen) >= c.window {
+		if err := c.flush(ctx, c.order[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushAll sends all the pending updates. Must be called with the mutex held.
+func (c *BulkUpdateCoalescer) flushAll(ctx context.Context) error {
+	for len(c.order) > 0 {
+		if err := c.flush(ctx, c.order[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushTable sends the pending updates of the table on input. Must be called
+// with the mutex held.
+func (c *BulkUpdateCoalescer) flushTable(ctx context.Context, schema, table string) error {
+	tableUpdates := []*pendingUpdate{}
+	for _, pending := range c.order {
+		if pending.event.Data.Schema == schema && pending.event.Data.Table == table {
+			tableUpdates = append(tableUpdates, pending)
+		}
+	}
+	for _, pending := range tableUpdates {
+		if err := c.flush(ctx, pending); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushKey sends the pending update of the row with the key on input, if any.
+// Must be called with the mutex held.
+func (c *BulkUpdateCoalescer) flushKey(ctx context.Context, key string) error {
+	pending, found := c.pending[key]
+	if !found {
+		return nil
+	}
+	return c.flush(ctx, pending)
+}
+
+// flush sends the pending update on input to the wrapped processor. It's sent
+// with the position of the oldest remaining pending update, or with the latest
+// position received if there are none left, so that the position is only
+// checkpointed once all the updates it covers have been sent. The pending
+// update is kept if it fails to be sent. Must be called with the mutex held.
+func (c *BulkUpdateCoalescer) flush(ctx context.Context, pending *pendingUpdate) error {
+	i := slices.Index(c.order, pending)
+	remaining := slices.Concat(c.order[:i], c.order[i+1:])
+
+	event := pending.event
+	if event.CommitPosition != "" {
+		event.CommitPosition = c.lastPosition
+		if len(remaining) > 0 {
+			event.CommitPosition = remaining[0].firstPosition
+		}
+	}
+	if err := c.processor.ProcessWALEvent(ctx, event); err != nil {
+		return fmt.Errorf("flushing pending update: %w", err)
+	}
+
+	c.order = remaining
+	delete(c.pending, pending.key)
+	return nil
+}
+
+func (c *BulkUpdateCoalescer) coalescesTable(data *wal.Data) bool {
+	if len(c.tables) == 0 {
+		return true
+	}
+	_, found := c.tables[data.Schema+"."+data.Table]
+	return found
+}
+
+// mergeUpdates returns an update event with the latest values of the columns
+// of both updates on input, and the identity of the first one, which is the
+// state of the row before both updates.
+func mergeUpdates(first, next *wal.Event) *wal.Event {
+	data := *next.Data
+	data.Identity = first.Data.Identity
+	data.Columns = slices.Clone(next.Data.Columns)
+	// the columns missing from the latest update (e.g. unchanged TOAST
+	// values) keep their previous value
+	for _, col := range first.Data.Columns {
+		if !slices.ContainsFunc(data.Columns, func(c wal.Column) bool { return c.Name == col.Name }) {
+			data.Columns = append(data.Columns, col)
+		}
+	}
+
+	merged := *next
+	merged.Data = &data
+	return &merged
+}
+
+// rowKey returns the key of the event row from the values of its key columns
+// found in the columns on input. The key columns are the pgstream identity
+// columns if available, or the replica identity columns otherwise. It returns
+// false if the row has no key columns, or any of them is missing.
+func rowKey(data *wal.Data, columns []wal.Column) (string, bool) {
+	var key strings.Builder
+	key.WriteString(data.Schema + keySeparator + data.Table + keySeparator)
+
+	writeValue := func(match func(wal.Column) bool) bool {
+		i := slices.IndexFunc(columns, match)
+		if i < 0 {
+			return false
+		}
+		key.WriteString(fmt.Sprintf("%s=%v%s", columns[i].Name, columns[i].Value, keySeparator))
+		return true
+	}
+
+	if len(data.Metadata.InternalColIDs) > 0 {
+		for _, id := range data.Metadata.InternalColIDs {
+			if !writeValue(func(c wal.Column) bool { return c.ID == id }) {
+				return "", false
+			}
+		}
+		return key.String(), true
+	}
+
+	if len(data.Identity) == 0 {
+		return "", false
+	}
+	for _, identityCol := range data.Identity {
+		if !writeValue(func(c wal.Column) bool { return c.Name == identityCol.Name }) {
+			return "", false
+		}
+	}
+	return key.String(), true
+}
+
+func qualifiedTableName(table string) string {
+	if !strings.Contains(table, ".") {
+		return publicSchema + "." + table
+	}
+	return table
+}
+
+func (c *Config) window() time.Duration {
+	if c.Window > 0 {
+		return c.Window
+	}
+	return defaultWindow
+}
+
+func (c *Config) maxBufferedRows() int {
+	if c.MaxBufferedRows > 0 {
+		return c.MaxBufferedRows
+	}
+	return defaultMaxBufferedRows
+}
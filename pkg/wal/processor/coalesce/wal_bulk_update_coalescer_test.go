@@ -0,0 +1,376 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+type processedEvent struct {
+	table    string
+	action   string
+	identity []wal.Column
+	columns  []wal.Column
+	position wal.CommitPosition
+}
+
+type testClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestUpdate(table string, oldID, newID int, columns []wal.Column, position string) *wal.Event {
+	return &wal.Event{
+		Data: &wal.Data{
+			Action:   "U",
+			Schema:   "public",
+			Table:    table,
+			Identity: []wal.Column{{Name: "id", Type: "integer", Value: oldID}},
+			Columns:  append([]wal.Column{{Name: "id", Type: "integer", Value: newID}}, columns...),
+		},
+		CommitPosition: wal.CommitPosition(position),
+	}
+}
+
+func newTestEvent(action, table string, id int, position string) *wal.Event {
+	event := &wal.Event{
+		Data: &wal.Data{
+			Action: action,
+			Schema: "public",
+			Table:  table,
+		},
+		CommitPosition: wal.CommitPosition(position),
+	}
+	switch action {
+	case "I":
+		event.Data.Columns = []wal.Column{{Name: "id", Type: "integer", Value: id}}
+	case "D":
+		event.Data.Identity = []wal.Column{{Name: "id", Type: "integer", Value: id}}
+	}
+	return event
+}
+
+func newTestCoalescer(processErr error) (*BulkUpdateCoalescer, *testClock, *[]processedEvent) {
+	processed := []processedEvent{}
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := newBulkUpdateCoalescer(&Config{
+		Tables:          []string{"users"},
+		Window:          time.Minute,
+		MaxBufferedRows: 2,
+	}, &mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+			if processErr != nil {
+				return processErr
+			}
+			e := processedEvent{position: event.CommitPosition}
+			if event.Data != nil {
+				e.table = event.Data.Table
+				e.action = event.Data.Action
+				if event.Data.Action == "U" {
+					e.identity = event.Data.Identity
+					e.columns = event.Data.Columns
+				}
+			}
+			processed = append(processed, e)
+			return nil
+		},
+		CloseFn: func() error { return nil },
+	})
+	c.clock = clock.Now
+	return c, clock, &processed
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	c := New(&Config{
+		Tables: []string{"users", "other.accounts"},
+	}, &mocks.Processor{CloseFn: func() error { return nil }})
+	require.Equal(t, map[string]struct{}{"public.users": {}, "other.accounts": {}}, c.tables)
+	require.Equal(t, defaultWindow, c.window)
+	require.Equal(t, defaultMaxBufferedRows, c.maxBufferedRows)
+	require.NoError(t, c.Close())
+}
+
+func TestBulkUpdateCoalescer_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	idCol := func(id int) wal.Column { return wal.Column{Name: "id", Type: "integer", Value: id} }
+	nameCol := func(name string) wal.Column { return wal.Column{Name: "name", Type: "text", Value: name} }
+	seenCol := func(seen int) wal.Column { return wal.Column{Name: "last_seen", Type: "bigint", Value: seen} }
+
+	t.Run("updates of the same row merged when the window expires", func(t *testing.T) {
+		t.Parallel()
+
+		c, clock, processed := newTestCoalescer(nil)
+		ctx := context.Background()
+
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 1, []wal.Column{nameCol("a"), seenCol(1)}, "0/1")))
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 1, []wal.Column{seenCol(2)}, "0/2")))
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 1, []wal.Column{seenCol(3)}, "0/3")))
+		require.Empty(t, *processed)
+
+		clock.advance(time.Minute)
+		require.NoError(t, c.ProcessWALEvent(ctx, &wal.Event{CommitPosition: "0/4"}))
+		require.Equal(t, []processedEvent{
+			{
+				table:    "users",
+				action:   "U",
+				identity: []wal.Column{idCol(1)},
+				columns:  []wal.Column{idCol(1), seenCol(3), nameCol("a")},
+				position: "0/3",
+			},
+			{position: "0/4"},
+		}, *processed)
+		require.Empty(t, c.pending)
+		require.Empty(t, c.order)
+	})
+
+	t.Run("update changing the primary key merged", func(t *testing.T) {
+		t.Parallel()
+
+		c, _, processed := newTestCoalescer(nil)
+		ctx := context.Background()
+
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 2, []wal.Column{nameCol("a")}, "0/1")))
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 2, 2, []wal.Column{nameCol("b")}, "0/2")))
+		require.NoError(t, c.Close())
+		require.Equal(t, []processedEvent{
+			{
+				table:    "users",
+				action:   "U",
+				identity: []wal.Column{idCol(1)},
+				columns:  []wal.Column{idCol(2), nameCol("b")},
+				position: "0/2",
+			},
+		}, *processed)
+	})
+
+	t.Run("delete sends the pending update of the row first", func(t *testing.T) {
+		t.Parallel()
+
+		c, _, processed := newTestCoalescer(nil)
+		ctx := context.Background()
+
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 1, []wal.Column{nameCol("a")}, "0/1")))
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 2, 2, []wal.Column{nameCol("b")}, "0/2")))
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestEvent("D", "users", 2, "0/3")))
+		require.Equal(t, []processedEvent{
+			{
+				table:    "users",
+				action:   "U",
+				identity: []wal.Column{idCol(2)},
+				columns:  []wal.Column{idCol(2), nameCol("b")},
+				position: "0/1",
+			},
+			{table: "users", action: "D", position: "0/1"},
+		}, *processed)
+		require.Len(t, c.order, 1)
+	})
+
+	t.Run("truncate sends the pending updates of the table", func(t *testing.T) {
+		t.Parallel()
+
+		c, _, processed := newTestCoalescer(nil)
+		ctx := context.Background()
+
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 1, []wal.Column{nameCol("a")}, "0/1")))
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestEvent("T", "users", 0, "0/2")))
+		require.Equal(t, []processedEvent{
+			{
+				table:    "users",
+				action:   "U",
+				identity: []wal.Column{idCol(1)},
+				columns:  []wal.Column{idCol(1), nameCol("a")},
+				position: "0/1",
+			},
+			{table: "users", action: "T", position: "0/2"},
+		}, *processed)
+	})
+
+	t.Run("schema log event sends all the pending updates", func(t *testing.T) {
+		t.Parallel()
+
+		c, _, processed := newTestCoalescer(nil)
+		ctx := context.Background()
+
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 1, []wal.Column{nameCol("a")}, "0/1")))
+		schemaLogEvent := &wal.Event{
+			Data:           &wal.Data{Action: "I", Schema: schemalog.SchemaName, Table: schemalog.TableName},
+			CommitPosition: "0/2",
+		}
+		require.NoError(t, c.ProcessWALEvent(ctx, schemaLogEvent))
+		require.Equal(t, []processedEvent{
+			{
+				table:    "users",
+				action:   "U",
+				identity: []wal.Column{idCol(1)},
+				columns:  []wal.Column{idCol(1), nameCol("a")},
+				position: "0/1",
+			},
+			{table: schemalog.TableName, action: "I", position: "0/2"},
+		}, *processed)
+	})
+
+	t.Run("oldest pending update sent when the buffer is full", func(t *testing.T) {
+		t.Parallel()
+
+		c, _, processed := newTestCoalescer(nil)
+		ctx := context.Background()
+
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 1, []wal.Column{nameCol("a")}, "0/1")))
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 2, 2, []wal.Column{nameCol("b")}, "0/2")))
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 3, 3, []wal.Column{nameCol("c")}, "0/3")))
+		require.Equal(t, []processedEvent{
+			{
+				table:    "users",
+				action:   "U",
+				identity: []wal.Column{idCol(1)},
+				columns:  []wal.Column{idCol(1), nameCol("a")},
+				position: "0/2",
+			},
+		}, *processed)
+		require.Len(t, c.order, 2)
+	})
+
+	t.Run("other events are not delayed past the pending positions", func(t *testing.T) {
+		t.Parallel()
+
+		c, clock, processed := newTestCoalescer(nil)
+		ctx := context.Background()
+
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 1, []wal.Column{nameCol("a")}, "0/1")))
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestEvent("I", "users", 2, "0/2")))
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("other", 1, 1, nil, "0/3")))
+		require.NoError(t, c.ProcessWALEvent(ctx, &wal.Event{CommitPosition: "0/4"}))
+		require.Equal(t, []processedEvent{
+			{table: "users", action: "I", position: "0/1"},
+			{
+				table:    "other",
+				action:   "U",
+				identity: []wal.Column{idCol(1)},
+				columns:  []wal.Column{idCol(1)},
+				position: "0/1",
+			},
+			{position: "0/1"},
+		}, *processed)
+
+		clock.advance(time.Minute)
+		require.NoError(t, c.ProcessWALEvent(ctx, &wal.Event{CommitPosition: "0/5"}))
+		require.Equal(t, processedEvent{
+			table:    "users",
+			action:   "U",
+			identity: []wal.Column{idCol(1)},
+			columns:  []wal.Column{idCol(1), nameCol("a")},
+			position: "0/4",
+		}, (*processed)[3])
+		require.Equal(t, processedEvent{position: "0/5"}, (*processed)[4])
+	})
+
+	t.Run("update without identity processed immediately", func(t *testing.T) {
+		t.Parallel()
+
+		c, _, processed := newTestCoalescer(nil)
+		ctx := context.Background()
+
+		event := newTestUpdate("users", 1, 1, []wal.Column{nameCol("a")}, "0/1")
+		event.Data.Identity = nil
+		require.NoError(t, c.ProcessWALEvent(ctx, event))
+		require.Equal(t, []processedEvent{
+			{table: "users", action: "U", columns: []wal.Column{idCol(1), nameCol("a")}, position: "0/1"},
+		}, *processed)
+	})
+
+	t.Run("pgstream identity columns used as the row key", func(t *testing.T) {
+		t.Parallel()
+
+		c, _, processed := newTestCoalescer(nil)
+		ctx := context.Background()
+
+		newEvent := func(email, name, position string) *wal.Event {
+			return &wal.Event{
+				Data: &wal.Data{
+					Action: "U",
+					Schema: "public",
+					Table:  "users",
+					Columns: []wal.Column{
+						{ID: "col-1", Name: "email", Type: "text", Value: email},
+						{ID: "col-2", Name: "name", Type: "text", Value: name},
+					},
+					Metadata: wal.Metadata{InternalColIDs: []string{"col-1"}},
+				},
+				CommitPosition: wal.CommitPosition(position),
+			}
+		}
+
+		require.NoError(t, c.ProcessWALEvent(ctx, newEvent("a@example.com", "a", "0/1")))
+		require.NoError(t, c.ProcessWALEvent(ctx, newEvent("a@example.com", "b", "0/2")))
+		require.Len(t, c.order, 1)
+		require.NoError(t, c.Close())
+		require.Len(t, *processed, 1)
+		require.Equal(t, "b", (*processed)[0].columns[1].Value)
+	})
+
+	t.Run("error - flushing keeps the pending update", func(t *testing.T) {
+		t.Parallel()
+
+		c, _, _ := newTestCoalescer(errTest)
+		ctx := context.Background()
+
+		require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 1, []wal.Column{nameCol("a")}, "0/1")))
+		err := c.ProcessWALEvent(ctx, newTestEvent("D", "users", 1, "0/2"))
+		require.ErrorIs(t, err, errTest)
+		require.Len(t, c.order, 1)
+		require.Len(t, c.pending, 1)
+	})
+}
+
+func TestBulkUpdateCoalescer_flushOnWindow(t *testing.T) {
+	t.Parallel()
+
+	processedChan := make(chan *wal.Event, 1)
+	c := New(&Config{
+		Window: 10 * time.Millisecond,
+	}, &mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+			processedChan <- event
+			return nil
+		},
+		CloseFn: func() error { return nil },
+	})
+	defer c.Close()
+
+	ctx := context.Background()
+	require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 1, []wal.Column{{Name: "name", Value: "a"}}, "0/1")))
+	require.NoError(t, c.ProcessWALEvent(ctx, newTestUpdate("users", 1, 1, []wal.Column{{Name: "name", Value: "b"}}, "0/2")))
+
+	select {
+	case event := <-processedChan:
+		require.Equal(t, "b", event.Data.Columns[1].Value)
+		require.Equal(t, wal.CommitPosition("0/2"), event.CommitPosition)
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for the window flush")
+	}
+}
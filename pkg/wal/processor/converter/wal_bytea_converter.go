@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ByteaConverter decodes the textual representation of postgres bytea values
+// into raw bytes. Both the hex (\x1a2b3c) and the escape (ab\000\377) output
+// formats are supported, and the format is detected from the value.
+type ByteaConverter struct {
+	outputFormat string
+}
+
+type ByteaConfig struct {
+	// OutputFormat of the converted values. One of bytes, which produces
+	// []byte values, or base64, which produces base64 encoded strings for
+	// sinks that don't handle binary data natively. Defaults to bytes.
+	OutputFormat string
+}
+
+const (
+	byteaDataType = "bytea"
+
+	ByteaOutputFormatBytes  = "bytes"
+	ByteaOutputFormatBase64 = "base64"
+
+	byteaHexPrefix = `\x`
+)
+
+var (
+	errUnsupportedByteaOutputFormat = errors.New("unsupported bytea output format, must be one of 'bytes' or 'base64'")
+	errUnexpectedByteaValueType     = errors.New("unexpected bytea value type")
+	errInvalidByteaHexFormat        = errors.New("invalid bytea hex format")
+	errInvalidByteaEscapeFormat     = errors.New("invalid bytea escape format")
+)
+
+func NewByteaConverter(cfg *ByteaConfig) (*ByteaConverter, error) {
+	outputFormat := cfg.OutputFormat
+	switch outputFormat {
+	case "":
+		outputFormat = ByteaOutputFormatBytes
+	case ByteaOutputFormatBytes, ByteaOutputFormatBase64:
+	default:
+		return nil, errUnsupportedByteaOutputFormat
+	}
+
+	return &ByteaConverter{
+		outputFormat: outputFormat,
+	}, nil
+}
+
+// Convert decodes the bytea value on input and returns it in the configured
+// output format. Values that have already been decoded are not decoded again.
+func (c *ByteaConverter) Convert(value any) (any, error) {
+	var decoded []byte
+	switch v := value.(type) {
+	case []byte:
+		decoded = v
+	case string:
+		var err error
+		decoded, err = decodeBytea(v)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %T", errUnexpectedByteaValueType, value)
+	}
+
+	if c.outputFormat == ByteaOutputFormatBase64 {
+		return base64.StdEncoding.EncodeToString(decoded), nil
+	}
+	return decoded, nil
+}
+
+func decodeBytea(s string) ([]byte, error) {
+	if strings.HasPrefix(s, byteaHexPrefix) {
+		decoded, err := hex.DecodeString(s[len(byteaHexPrefix):])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errInvalidByteaHexFormat, err)
+		}
+		return decoded, nil
+	}
+	return decodeByteaEscape(s)
+}
+
+// decodeByteaEscape decodes the bytea escape format, where backslashes are
+// represented by a double backslash, and non printable bytes are represented
+// by a backslash followed by their three digit octal value.
+func decodeByteaEscape(s string) ([]byte, error) {
+	decoded := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			decoded = append(decoded, s[i])
+			continue
+		}
+
+		switch {
+		case i+1 < len(s) && s[i+1] == '\\':
+			decoded = append(decoded, '\\')
+			i++
+		case i+3 < len(s) && s[i+1] >= '0' && s[i+1] <= '3' && isOctalDigit(s[i+2]) && isOctalDigit(s[i+3]):
+			decoded = append(decoded, (s[i+1]-'0')<<6|(s[i+2]-'0')<<3|(s[i+3]-'0'))
+			i += 3
+		default:
+			return nil, fmt.Errorf("%w: unexpected backslash at position %d", errInvalidByteaEscapeFormat, i)
+		}
+	}
+	return decoded, nil
+}
+
+func isOctalDigit(b byte) bool {
+	return b >= '0' && b <= '7'
+}
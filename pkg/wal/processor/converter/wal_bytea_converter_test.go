@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestByteaConverter_Convert(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		outputFormat string
+		value        any
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "ok - hex format",
+			value:     `\x1a2b3c`,
+			wantValue: []byte{0x1a, 0x2b, 0x3c},
+		},
+		{
+			name:      "ok - empty hex format",
+			value:     `\x`,
+			wantValue: []byte{},
+		},
+		{
+			name:      "ok - escape format",
+			value:     `ab\000\377\\c`,
+			wantValue: []byte{'a', 'b', 0x00, 0xff, '\\', 'c'},
+		},
+		{
+			name:      "ok - already decoded",
+			value:     []byte{0x01, 0x02},
+			wantValue: []byte{0x01, 0x02},
+		},
+		{
+			name:         "ok - base64 output format",
+			outputFormat: ByteaOutputFormatBase64,
+			value:        `\x1a2b3c`,
+			wantValue:    "Gis8",
+		},
+		{
+			name:         "ok - base64 output format already decoded",
+			outputFormat: ByteaOutputFormatBase64,
+			value:        []byte{0x1a, 0x2b, 0x3c},
+			wantValue:    "Gis8",
+		},
+		{
+			name:    "error - invalid hex format",
+			value:   `\x1a2`,
+			wantErr: errInvalidByteaHexFormat,
+		},
+		{
+			name:    "error - invalid escape format",
+			value:   `ab\9`,
+			wantErr: errInvalidByteaEscapeFormat,
+		},
+		{
+			name:    "error - octal value out of range",
+			value:   `\477`,
+			wantErr: errInvalidByteaEscapeFormat,
+		},
+		{
+			name:    "error - unexpected value type",
+			value:   1,
+			wantErr: errUnexpectedByteaValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := NewByteaConverter(&ByteaConfig{OutputFormat: tc.outputFormat})
+			require.NoError(t, err)
+
+			value, err := c.Convert(tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, value)
+		})
+	}
+}
@@ -0,0 +1,207 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/types"
+)
+
+// Converter is a decorator around a wal processor that converts the wal event
+// column values based on their postgres data type, so that downstream sinks
+// receive them in a consistent format.
+type Converter struct {
+	logger     loglib.Logger
+	processor  processor.Processor
+	converters map[string]ColumnConverter
+	// registry has the user registered converters, which take precedence over
+	// the built in ones
+	registry *types.Registry
+}
+
+// ColumnConverter converts a wal event column value into its normalised
+// representation.
+type ColumnConverter interface {
+	Convert(value any) (any, error)
+}
+
+type Config struct {
+	// Bytea enables the conversion of bytea column values. Disabled if not
+	// provided.
+	Bytea *ByteaConfig
+	// NormalizeTimestamps enables the conversion of timestamp, timestamptz,
+	// date and timetz column values to UTC.
+	NormalizeTimestamps bool
+	// GeometricTypes enables the conversion of point, line, lseg, box, path,
+	// polygon and circle column values into structured types.
+	GeometricTypes bool
+	// NetworkAddresses enables the conversion of inet and cidr column values
+	// into net.IP and *net.IPNet values.
+	NetworkAddresses bool
+	// FullTextSearchTypes enables the conversion of tsvector and tsquery column
+	// values into structured types.
+	FullTextSearchTypes bool
+}
+
+type Option func(c *Converter)
+
+var errMissingConverterConfig = errors.New("missing type conversion configuration")
+
+// New will return a converter processor wrapper that will convert incoming
+// wal event column values for the configured postgres data types.
+func New(cfg *Config, processor processor.Processor, opts ...Option) (*Converter, error) {
+	c := &Converter{
+		logger:     loglib.NewNoopLogger(),
+		processor:  processor,
+		converters: map[string]ColumnConverter{},
+	}
+
+	if cfg.Bytea != nil {
+		byteaConverter, err := NewByteaConverter(cfg.Bytea)
+		if err != nil {
+			return nil, err
+		}
+		c.converters[byteaDataType] = byteaConverter
+	}
+
+	if cfg.NormalizeTimestamps {
+		for dataType, normalizer := range timestampNormalizers() {
+			c.converters[dataType] = normalizer
+		}
+	}
+
+	if cfg.GeometricTypes {
+		for dataType, converter := range geometricConverters() {
+			c.converters[dataType] = converter
+		}
+	}
+
+	if cfg.NetworkAddresses {
+		for dataType, converter := range networkAddressConverters() {
+			c.converters[dataType] = converter
+		}
+	}
+
+	if cfg.FullTextSearchTypes {
+		for dataType, converter := range fullTextSearchConverters() {
+			c.converters[dataType] = converter
+		}
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if len(c.converters) == 0 && c.registry == nil {
+		return nil, errMissingConverterConfig
+	}
+
+	return c, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(c *Converter) {
+		c.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_converter",
+		})
+	}
+}
+
+// WithTypeRegistry sets the registry of custom type converters, so that the
+// column values of the registered types are converted as well.
+func WithTypeRegistry(r *types.Registry) Option {
+	return func(c *Converter) {
+		c.registry = r
+	}
+}
+
+func (c *Converter) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	if event.Data != nil {
+		c.convertColumns(event.Data, event.Data.Columns)
+		c.convertColumns(event.Data, event.Data.Identity)
+	}
+
+	return c.processor.ProcessWALEvent(ctx, event)
+}
+
+func (c *Converter) Name() string {
+	return c.processor.Name()
+}
+
+func (c *Converter) Close() error {
+	return c.processor.Close()
+}
+
+func (c *Converter) Ping(ctx context.Context) error {
+	return c.processor.Ping(ctx)
+}
+
+func (c *Converter) convertColumns(data *wal.Data, columns []wal.Column) {
+	for i, col := range columns {
+		if col.Value == nil {
+			continue
+		}
+
+		converter, found := c.converter(col.Type)
+		if !found {
+			continue
+		}
+
+		newValue, err := converter.Convert(col.Value)
+		if err != nil {
+			// keep the original value if it can't be converted
+			c.logger.Error(err, "converting column value", loglib.Fields{
+				"column_name": col.Name,
+				"column_type": col.Type,
+				"schema":      data.Schema,
+				"table":       data.Table,
+			})
+			continue
+		}
+		columns[i].Value = newValue
+	}
+}
+
+// converter returns the converter for the data type on input. The data types
+// with type modifiers, such as timestamp(3) with time zone, fall back to the
+// converter of their base type if there's none for the exact type.
+func (c *Converter) converter(dataType string) (ColumnConverter, bool) {
+	if converter, found := c.lookup(dataType); found {
+		return converter, true
+	}
+	if baseType := baseDataType(dataType); baseType != dataType {
+		return c.lookup(baseType)
+	}
+	return nil, false
+}
+
+func (c *Converter) lookup(dataType string) (ColumnConverter, bool) {
+	if c.registry != nil {
+		if converter, found := c.registry.Converter(dataType); found {
+			return converter, true
+		}
+	}
+	converter, found := c.converters[dataType]
+	return converter, found
+}
+
+// baseDataType removes the type modifiers from the data type on input, such
+// as the precision of timestamp(3) with time zone, which wal2json includes in
+// the type names by default.
+func baseDataType(dataType string) string {
+	start := strings.IndexByte(dataType, '(')
+	if start <= 0 {
+		return dataType
+	}
+	end := strings.IndexByte(dataType[start:], ')')
+	if end < 0 {
+		return dataType
+	}
+	return dataType[:start] + dataType[start+end+1:]
+}
@@ -0,0 +1,294 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/types"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config *Config
+		opts   []Option
+
+		wantErr error
+	}{
+		{
+			name:   "ok - bytea",
+			config: &Config{Bytea: &ByteaConfig{}},
+		},
+		{
+			name:   "ok - geometric types",
+			config: &Config{GeometricTypes: true},
+		},
+		{
+			name:   "ok - network addresses",
+			config: &Config{NetworkAddresses: true},
+		},
+		{
+			name:   "ok - full text search types",
+			config: &Config{FullTextSearchTypes: true},
+		},
+		{
+			name:   "ok - type registry",
+			config: &Config{},
+			opts:   []Option{WithTypeRegistry(types.NewRegistry())},
+		},
+		{
+			name:    "error - missing configuration",
+			config:  &Config{},
+			wantErr: errMissingConverterConfig,
+		},
+		{
+			name:    "error - invalid bytea output format",
+			config:  &Config{Bytea: &ByteaConfig{OutputFormat: "invalid"}},
+			wantErr: errUnsupportedByteaOutputFormat,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := New(tc.config, &mocks.Processor{}, tc.opts...)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestConverter_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	newEvent := func(cols, identity []wal.Column) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action:   "U",
+				Schema:   "public",
+				Table:    "test",
+				Columns:  cols,
+				Identity: identity,
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		event       *wal.Event
+		processorFn func(*testing.T) func(context.Context, *wal.Event) error
+
+		wantEvent *wal.Event
+		wantErr   error
+	}{
+		{
+			name:      "ok - keep alive event",
+			event:     &wal.Event{CommitPosition: "1/CF54A048"},
+			wantEvent: &wal.Event{CommitPosition: "1/CF54A048"},
+		},
+		{
+			name: "ok - bytea columns converted",
+			event: newEvent(
+				[]wal.Column{
+					{Name: "id", Type: "integer", Value: 1},
+					{Name: "data", Type: "bytea", Value: `\x0102ff`},
+					{Name: "empty", Type: "bytea", Value: nil},
+				},
+				[]wal.Column{
+					{Name: "data", Type: "bytea", Value: `\x01`},
+				},
+			),
+			wantEvent: newEvent(
+				[]wal.Column{
+					{Name: "id", Type: "integer", Value: 1},
+					{Name: "data", Type: "bytea", Value: []byte{0x01, 0x02, 0xff}},
+					{Name: "empty", Type: "bytea", Value: nil},
+				},
+				[]wal.Column{
+					{Name: "data", Type: "bytea", Value: []byte{0x01}},
+				},
+			),
+		},
+		{
+			name: "ok - invalid bytea value kept",
+			event: newEvent(
+				[]wal.Column{{Name: "data", Type: "bytea", Value: `\xzz`}},
+				nil,
+			),
+			wantEvent: newEvent(
+				[]wal.Column{{Name: "data", Type: "bytea", Value: `\xzz`}},
+				nil,
+			),
+		},
+		{
+			name: "ok - temporal columns with type modifiers normalized",
+			event: newEvent(
+				[]wal.Column{
+					{Name: "created_at", Type: "timestamp(3) with time zone", Value: "2024-01-02 03:04:05.123+02"},
+					{Name: "updated_at", Type: "timestamp(6) without time zone", Value: "2024-01-02 03:04:05.123456"},
+					{Name: "starts_at", Type: "time(6) with time zone", Value: "03:04:05.123456+02"},
+					{Name: "name", Type: "character varying(255)", Value: "alice"},
+				},
+				nil,
+			),
+			wantEvent: newEvent(
+				[]wal.Column{
+					{Name: "created_at", Type: "timestamp(3) with time zone", Value: "2024-01-02T01:04:05.123Z"},
+					{Name: "updated_at", Type: "timestamp(6) without time zone", Value: "2024-01-02T03:04:05.123456Z"},
+					{Name: "starts_at", Type: "time(6) with time zone", Value: "01:04:05.123456Z"},
+					{Name: "name", Type: "character varying(255)", Value: "alice"},
+				},
+				nil,
+			),
+		},
+		{
+			name: "ok - registered type converters",
+			event: newEvent(
+				[]wal.Column{
+					{Name: "mood", Type: "mood", Value: "happy"},
+					{Name: "status", Type: "status", Value: "active"},
+				},
+				nil,
+			),
+			wantEvent: newEvent(
+				[]wal.Column{
+					{Name: "mood", Type: "mood", Value: "HAPPY"},
+					{Name: "status", Type: "status", Value: "active"},
+				},
+				nil,
+			),
+		},
+		{
+			name:  "error - processing event",
+			event: &wal.Event{},
+			processorFn: func(t *testing.T) func(context.Context, *wal.Event) error {
+				return func(context.Context, *wal.Event) error { return errTest }
+			},
+			wantEvent: &wal.Event{},
+			wantErr:   errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			processorFn := func(_ context.Context, event *wal.Event) error {
+				require.Equal(t, tc.wantEvent, event)
+				return nil
+			}
+			if tc.processorFn != nil {
+				processorFn = tc.processorFn(t)
+			}
+
+			registry := types.NewRegistry()
+			registry.Register("mood", types.TypeConverterFunc(func(value any) (any, error) {
+				return strings.ToUpper(value.(string)), nil
+			}))
+			registry.Register("status", types.TypeConverterFunc(func(any) (any, error) {
+				return nil, errTest
+			}))
+
+			c, err := New(&Config{Bytea: &ByteaConfig{}, NormalizeTimestamps: true}, &mocks.Processor{
+				ProcessWALEventFn: processorFn,
+			}, WithTypeRegistry(registry))
+			require.NoError(t, err)
+
+			err = c.ProcessWALEvent(context.Background(), tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestBaseDataType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dataType string
+
+		wantDataType string
+	}{
+		{
+			name:         "no type modifier",
+			dataType:     "timestamp with time zone",
+			wantDataType: "timestamp with time zone",
+		},
+		{
+			name:         "type modifier before the time zone",
+			dataType:     "timestamp(3) with time zone",
+			wantDataType: "timestamp with time zone",
+		},
+		{
+			name:         "time with type modifier",
+			dataType:     "time(6) with time zone",
+			wantDataType: "time with time zone",
+		},
+		{
+			name:         "trailing type modifier",
+			dataType:     "numeric(10,2)",
+			wantDataType: "numeric",
+		},
+		{
+			name:         "array with type modifier",
+			dataType:     "timestamp(6) without time zone[]",
+			wantDataType: "timestamp without time zone[]",
+		},
+		{
+			name:         "unclosed type modifier",
+			dataType:     "timestamp(3",
+			wantDataType: "timestamp(3",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.wantDataType, baseDataType(tc.dataType))
+		})
+	}
+}
+
+func TestConverter_ProcessWALEvent_registryPrecedence(t *testing.T) {
+	t.Parallel()
+
+	registry := types.NewRegistry()
+	registry.Register("bytea", types.TypeConverterFunc(func(any) (any, error) {
+		return "overridden", nil
+	}))
+
+	var gotEvent *wal.Event
+	c, err := New(&Config{Bytea: &ByteaConfig{}}, &mocks.Processor{
+		ProcessWALEventFn: func(_ context.Context, event *wal.Event) error {
+			gotEvent = event
+			return nil
+		},
+	}, WithTypeRegistry(registry))
+	require.NoError(t, err)
+
+	newEvent := func(value any) *wal.Event {
+		return &wal.Event{Data: &wal.Data{Columns: []wal.Column{{Name: "data", Type: "bytea", Value: value}}}}
+	}
+
+	// the registered converter takes precedence over the built in one
+	err = c.ProcessWALEvent(context.Background(), newEvent(`\x01`))
+	require.NoError(t, err)
+	require.Equal(t, newEvent("overridden"), gotEvent)
+
+	// and the built in one is used again once it's unregistered
+	registry.Unregister("bytea")
+	err = c.ProcessWALEvent(context.Background(), newEvent(`\x01`))
+	require.NoError(t, err)
+	require.Equal(t, newEvent([]byte{0x01}), gotEvent)
+}
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xataio/pgstream/pkg/wal/processor/postgres/types/fts"
+)
+
+// FullTextSearchConverter converts the postgres text representation of
+// tsvector and tsquery column values into their structured fts types, so that
+// sinks can index the lexemes and query nodes without parsing them.
+type FullTextSearchConverter struct {
+	dataType string
+}
+
+var errUnexpectedFullTextSearchValueType = errors.New("unexpected full text search value type")
+
+// fullTextSearchConverters returns the converters for the tsvector and tsquery
+// data types.
+func fullTextSearchConverters() map[string]ColumnConverter {
+	return map[string]ColumnConverter{
+		fts.TSVectorDataType: &FullTextSearchConverter{dataType: fts.TSVectorDataType},
+		fts.TSQueryDataType:  &FullTextSearchConverter{dataType: fts.TSQueryDataType},
+	}
+}
+
+// Convert parses the full text search value on input into its structured type.
+func (c *FullTextSearchConverter) Convert(value any) (any, error) {
+	v, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", errUnexpectedFullTextSearchValueType, value)
+	}
+	return fts.Parse(c.dataType, v)
+}
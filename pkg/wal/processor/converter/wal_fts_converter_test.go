@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal/processor/postgres/types/fts"
+)
+
+func TestFullTextSearchConverter_Convert(t *testing.T) {
+	t.Parallel()
+
+	converters := fullTextSearchConverters()
+
+	tests := []struct {
+		name     string
+		dataType string
+		value    any
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:     "ok - tsvector",
+			dataType: "tsvector",
+			value:    "'cat':3A 'fat':2,4",
+			wantValue: fts.TSVector{Lexemes: []fts.Lexeme{
+				{Word: "cat", Positions: []fts.Position{{Index: 3, Weight: "A"}}},
+				{Word: "fat", Positions: []fts.Position{{Index: 2, Weight: "D"}, {Index: 4, Weight: "D"}}},
+			}},
+		},
+		{
+			name:     "ok - tsquery",
+			dataType: "tsquery",
+			value:    "'fat' & !'rat':*",
+			wantValue: fts.TSQuery{Nodes: []fts.TSNode{
+				{Kind: fts.OperandNode, Value: "fat"},
+				{Kind: fts.AndNode},
+				{Kind: fts.NotNode},
+				{Kind: fts.OperandNode, Value: "rat", Prefix: true},
+			}},
+		},
+		{
+			name:     "error - invalid format",
+			dataType: "tsquery",
+			value:    "'fat' &",
+			wantErr:  fts.ErrInvalidFormat,
+		},
+		{
+			name:     "error - unexpected value type",
+			dataType: "tsvector",
+			value:    []string{"fat"},
+			wantErr:  errUnexpectedFullTextSearchValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := converters[tc.dataType].Convert(tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, value)
+		})
+	}
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/xataio/pgstream/pkg/wal/processor/postgres/types/geometry"
+)
+
+// GeometricConverter converts the postgres text representation of geometric
+// column values into their structured geometry types, so that sinks receive
+// them as JSON objects instead of opaque strings.
+type GeometricConverter struct {
+	dataType string
+}
+
+var errUnexpectedGeometricValueType = errors.New("unexpected geometric value type")
+
+// geometricConverters returns the converters for all the built in geometric
+// data types.
+func geometricConverters() map[string]ColumnConverter {
+	converters := map[string]ColumnConverter{}
+	for _, dataType := range []string{
+		geometry.PointDataType,
+		geometry.LineDataType,
+		geometry.LineSegmentDataType,
+		geometry.BoxDataType,
+		geometry.PathDataType,
+		geometry.PolygonDataType,
+		geometry.CircleDataType,
+	} {
+		converters[dataType] = &GeometricConverter{dataType: dataType}
+	}
+	return converters
+}
+
+// Convert parses the geometric value on input into its structured type.
+func (c *GeometricConverter) Convert(value any) (any, error) {
+	v, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", errUnexpectedGeometricValueType, value)
+	}
+	return geometry.Parse(c.dataType, v)
+}
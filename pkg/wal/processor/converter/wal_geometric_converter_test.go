@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal/processor/postgres/types/geometry"
+)
+
+func TestGeometricConverter_Convert(t *testing.T) {
+	t.Parallel()
+
+	converters := geometricConverters()
+
+	tests := []struct {
+		name     string
+		dataType string
+		value    any
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "ok - point",
+			dataType:  "point",
+			value:     "(1.5,-2)",
+			wantValue: geometry.Point{X: 1.5, Y: -2},
+		},
+		{
+			name:     "ok - circle",
+			dataType: "circle",
+			value:    "<(1,2),3>",
+			wantValue: geometry.Circle{
+				Center: geometry.Point{X: 1, Y: 2},
+				Radius: 3,
+			},
+		},
+		{
+			name:     "ok - polygon",
+			dataType: "polygon",
+			value:    "((0,0),(1,1),(1,0))",
+			wantValue: geometry.Polygon{
+				Points: []geometry.Point{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 1, Y: 0}},
+			},
+		},
+		{
+			name:     "error - invalid format",
+			dataType: "lseg",
+			value:    "(1,2)",
+			wantErr:  geometry.ErrInvalidFormat,
+		},
+		{
+			name:     "error - unexpected value type",
+			dataType: "point",
+			value:    1,
+			wantErr:  errUnexpectedGeometricValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := converters[tc.dataType].Convert(tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, value)
+		})
+	}
+}
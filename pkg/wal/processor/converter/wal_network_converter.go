@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// NetworkAddressConverter converts the postgres text representation of inet
+// and cidr column values into their net types, so that sinks can work with
+// the addresses and ranges without parsing them.
+//
+// An inet value is converted into a net.IP, unless it has a netmask, in which
+// case it's converted into a *net.IPNet holding the host address (not the
+// network one) and the mask. A cidr value is always converted into a
+// *net.IPNet.
+type NetworkAddressConverter struct {
+	dataType string
+}
+
+const (
+	inetDataType = "inet"
+	cidrDataType = "cidr"
+)
+
+var (
+	errUnexpectedNetworkValueType = errors.New("unexpected network address value type")
+	errInvalidNetworkAddress      = errors.New("invalid network address")
+)
+
+// networkAddressConverters returns the converters for the inet and cidr data
+// types.
+func networkAddressConverters() map[string]ColumnConverter {
+	return map[string]ColumnConverter{
+		inetDataType: &NetworkAddressConverter{dataType: inetDataType},
+		cidrDataType: &NetworkAddressConverter{dataType: cidrDataType},
+	}
+}
+
+// Convert parses the network address value on input into its net type.
+func (c *NetworkAddressConverter) Convert(value any) (any, error) {
+	v, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", errUnexpectedNetworkValueType, value)
+	}
+	if c.dataType == cidrDataType {
+		return parseCIDR(v)
+	}
+	return parseInet(v)
+}
+
+func parseInet(value string) (any, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("%w: %q", errInvalidNetworkAddress, value)
+		}
+		return normaliseIP(ip), nil
+	}
+
+	ip, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", errInvalidNetworkAddress, value)
+	}
+	// a netmask covering the whole address is the same as no netmask
+	if ones, bits := network.Mask.Size(); ones == bits {
+		return normaliseIP(ip), nil
+	}
+	// the address length needs to match the netmask one, which is 16 bytes
+	// for IPv4-mapped IPv6 addresses
+	if len(network.Mask) == net.IPv4len {
+		ip = ip.To4()
+	}
+	return &net.IPNet{IP: ip, Mask: network.Mask}, nil
+}
+
+func parseCIDR(value string) (*net.IPNet, error) {
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", errInvalidNetworkAddress, value)
+	}
+	return network, nil
+}
+
+// normaliseIP returns IPv4 addresses in their 4 byte representation, as they
+// are returned for the cidr values.
+func normaliseIP(ip net.IP) net.IP {
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4
+	}
+	return ip
+}
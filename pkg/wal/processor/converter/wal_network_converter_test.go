@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkAddressConverter_Convert(t *testing.T) {
+	t.Parallel()
+
+	converters := networkAddressConverters()
+
+	tests := []struct {
+		name     string
+		dataType string
+		value    any
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "ok - inet ipv4",
+			dataType:  "inet",
+			value:     "192.168.1.5",
+			wantValue: net.IPv4(192, 168, 1, 5).To4(),
+		},
+		{
+			name:      "ok - inet ipv6",
+			dataType:  "inet",
+			value:     "2001:db8::1",
+			wantValue: net.ParseIP("2001:db8::1"),
+		},
+		{
+			name:     "ok - inet ipv4 with netmask",
+			dataType: "inet",
+			value:    "192.168.1.5/24",
+			wantValue: &net.IPNet{
+				IP:   net.IPv4(192, 168, 1, 5).To4(),
+				Mask: net.CIDRMask(24, 32),
+			},
+		},
+		{
+			name:     "ok - inet ipv6 with netmask",
+			dataType: "inet",
+			value:    "2001:db8::1/64",
+			wantValue: &net.IPNet{
+				IP:   net.ParseIP("2001:db8::1"),
+				Mask: net.CIDRMask(64, 128),
+			},
+		},
+		{
+			name:      "ok - inet with full netmask",
+			dataType:  "inet",
+			value:     "10.0.0.1/32",
+			wantValue: net.IPv4(10, 0, 0, 1).To4(),
+		},
+		{
+			name:     "ok - cidr ipv4",
+			dataType: "cidr",
+			value:    "192.168.1.0/24",
+			wantValue: &net.IPNet{
+				IP:   net.IPv4(192, 168, 1, 0).To4(),
+				Mask: net.CIDRMask(24, 32),
+			},
+		},
+		{
+			name:     "ok - cidr ipv6",
+			dataType: "cidr",
+			value:    "2001:db8::/32",
+			wantValue: &net.IPNet{
+				IP:   net.ParseIP("2001:db8::"),
+				Mask: net.CIDRMask(32, 128),
+			},
+		},
+		{
+			name:     "error - invalid inet",
+			dataType: "inet",
+			value:    "192.168.1",
+			wantErr:  errInvalidNetworkAddress,
+		},
+		{
+			name:     "error - invalid inet netmask",
+			dataType: "inet",
+			value:    "192.168.1.5/33",
+			wantErr:  errInvalidNetworkAddress,
+		},
+		{
+			name:     "error - cidr without netmask",
+			dataType: "cidr",
+			value:    "192.168.1.0",
+			wantErr:  errInvalidNetworkAddress,
+		},
+		{
+			name:     "error - unexpected value type",
+			dataType: "inet",
+			value:    []byte("192.168.1.5"),
+			wantErr:  errUnexpectedNetworkValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := converters[tc.dataType].Convert(tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantValue == nil {
+				require.Nil(t, value)
+				return
+			}
+			require.Equal(t, tc.wantValue, value)
+		})
+	}
+}
+
+func TestNetworkAddressConverter_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	converters := networkAddressConverters()
+
+	tests := []struct {
+		dataType string
+		value    string
+	}{
+		{dataType: "inet", value: "192.168.1.5"},
+		{dataType: "inet", value: "192.168.1.5/24"},
+		{dataType: "inet", value: "2001:db8::1"},
+		{dataType: "inet", value: "2001:db8::1/64"},
+		{dataType: "cidr", value: "10.0.0.0/8"},
+		{dataType: "cidr", value: "2001:db8::/32"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.dataType+" "+tc.value, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := converters[tc.dataType].Convert(tc.value)
+			require.NoError(t, err)
+			require.Equal(t, tc.value, fmt.Sprint(value))
+		})
+	}
+}
@@ -0,0 +1,108 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TimestampNormalizer converts temporal column values to UTC, so that they
+// have a consistent format regardless of the postgres server timezone
+// setting. Timestamps and dates are serialised as RFC3339Nano strings, and
+// times with time zone are serialised as the RFC3339Nano time portion.
+// Timestamps without time zone are assumed to be in UTC.
+type TimestampNormalizer struct {
+	layouts      []string
+	outputLayout string
+}
+
+const (
+	timestampDataType   = "timestamp without time zone"
+	timestamptzDataType = "timestamp with time zone"
+	dateDataType        = "date"
+	timetzDataType      = "time with time zone"
+
+	timeOutputLayout = "15:04:05.999999999Z07:00"
+)
+
+// postgres only includes the timezone minutes and seconds when they're not
+// zero, and the fractional seconds are parsed even if not in the layout.
+var (
+	timestampLayouts = []string{
+		"2006-01-02 15:04:05Z07",
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02 15:04:05Z07:00:00",
+		"2006-01-02 15:04:05",
+		time.RFC3339Nano,
+	}
+	dateLayouts = []string{
+		time.DateOnly,
+	}
+	timetzLayouts = []string{
+		"15:04:05Z07",
+		"15:04:05Z07:00",
+		"15:04:05Z07:00:00",
+	}
+)
+
+var (
+	errUnexpectedTemporalValueType = errors.New("unexpected temporal value type")
+	errUnrecognizedTemporalFormat  = errors.New("unrecognized temporal value format")
+)
+
+// special values that can't be represented as a time and are kept as is
+var specialTemporalValues = map[string]struct{}{
+	"infinity":  {},
+	"-infinity": {},
+}
+
+func newTimestampNormalizer(layouts []string, outputLayout string) *TimestampNormalizer {
+	return &TimestampNormalizer{
+		layouts:      layouts,
+		outputLayout: outputLayout,
+	}
+}
+
+// timestampNormalizers returns the normalizers for all the supported temporal
+// data types.
+func timestampNormalizers() map[string]ColumnConverter {
+	timestampNormalizer := newTimestampNormalizer(timestampLayouts, time.RFC3339Nano)
+	return map[string]ColumnConverter{
+		timestampDataType:   timestampNormalizer,
+		timestamptzDataType: timestampNormalizer,
+		dateDataType:        newTimestampNormalizer(dateLayouts, time.RFC3339Nano),
+		timetzDataType:      newTimestampNormalizer(timetzLayouts, timeOutputLayout),
+	}
+}
+
+// Convert parses the temporal value on input and returns it in UTC,
+// serialised with the normalizer output layout.
+func (n *TimestampNormalizer) Convert(value any) (any, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(n.outputLayout), nil
+	case string:
+		if _, found := specialTemporalValues[v]; found {
+			return v, nil
+		}
+		t, err := n.parse(v)
+		if err != nil {
+			return nil, err
+		}
+		return t.UTC().Format(n.outputLayout), nil
+	default:
+		return nil, fmt.Errorf("%w: %T", errUnexpectedTemporalValueType, value)
+	}
+}
+
+func (n *TimestampNormalizer) parse(value string) (time.Time, error) {
+	for _, layout := range n.layouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: %q", errUnrecognizedTemporalFormat, value)
+}
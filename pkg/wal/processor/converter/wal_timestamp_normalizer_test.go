@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package converter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampNormalizer_Convert(t *testing.T) {
+	t.Parallel()
+
+	normalizers := timestampNormalizers()
+
+	tests := []struct {
+		name     string
+		dataType string
+		value    any
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "ok - timestamptz with hour offset",
+			dataType:  timestamptzDataType,
+			value:     "2024-01-02 03:04:05.123456+02",
+			wantValue: "2024-01-02T01:04:05.123456Z",
+		},
+		{
+			name:      "ok - timestamptz with minute offset",
+			dataType:  timestamptzDataType,
+			value:     "2024-01-02 03:04:05+05:30",
+			wantValue: "2024-01-01T21:34:05Z",
+		},
+		{
+			name:      "ok - timestamptz in utc",
+			dataType:  timestamptzDataType,
+			value:     "2024-01-02 03:04:05.5+00",
+			wantValue: "2024-01-02T03:04:05.5Z",
+		},
+		{
+			name:      "ok - timestamp without time zone",
+			dataType:  timestampDataType,
+			value:     "2024-01-02 03:04:05.123",
+			wantValue: "2024-01-02T03:04:05.123Z",
+		},
+		{
+			name:      "ok - rfc3339",
+			dataType:  timestamptzDataType,
+			value:     "2024-01-02T03:04:05-01:00",
+			wantValue: "2024-01-02T04:04:05Z",
+		},
+		{
+			name:      "ok - time.Time",
+			dataType:  timestamptzDataType,
+			value:     time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("test", 3600)),
+			wantValue: "2024-01-02T02:04:05Z",
+		},
+		{
+			name:      "ok - date",
+			dataType:  dateDataType,
+			value:     "2024-01-02",
+			wantValue: "2024-01-02T00:00:00Z",
+		},
+		{
+			name:      "ok - timetz",
+			dataType:  timetzDataType,
+			value:     "03:04:05.25+02",
+			wantValue: "01:04:05.25Z",
+		},
+		{
+			name:      "ok - infinity",
+			dataType:  timestamptzDataType,
+			value:     "infinity",
+			wantValue: "infinity",
+		},
+		{
+			name:     "error - unrecognized format",
+			dataType: timestamptzDataType,
+			value:    "not a timestamp",
+			wantErr:  errUnrecognizedTemporalFormat,
+		},
+		{
+			name:     "error - unexpected value type",
+			dataType: dateDataType,
+			value:    1,
+			wantErr:  errUnexpectedTemporalValueType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := normalizers[tc.dataType].Convert(tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, value)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dynamodb
+
+import (
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+type Config struct {
+	// Region is the AWS region of the DynamoDB tables. Defaults to the region
+	// of the AWS default configuration (i.e. AWS_REGION).
+	Region string
+	// Endpoint overrides the DynamoDB endpoint, i.e. to use DynamoDB local.
+	Endpoint string
+	// Tables maps the source tables, in <schema>.<table> format, to the
+	// DynamoDB table their rows are written to. Tables in the public schema
+	// can be referenced by their name only.
+	Tables map[string]string
+	// Table is the catch-all DynamoDB table the rows of the source tables
+	// without a mapping in Tables are written to. The items written to it
+	// include the qualified name of their source table in the _table
+	// attribute, which needs to be part of the table primary key. If not
+	// provided, the events of the tables without a mapping are skipped.
+	Table string
+	// VersionAttribute is the item attribute that keeps the LSN of the last
+	// change applied to the item, used to skip changes older than the item.
+	// Defaults to _version.
+	VersionAttribute string
+
+	Batch batch.Config
+}
+
+const (
+	defaultVersionAttribute = "_version"
+	publicSchema            = "public"
+)
+
+func (c *Config) versionAttribute() string {
+	if c.VersionAttribute != "" {
+		return c.VersionAttribute
+	}
+	return defaultVersionAttribute
+}
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dynamodb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// attributeValue returns the DynamoDB attribute value for the column on input.
+// Boolean columns are mapped to BOOL, numeric columns to N, and json columns
+// to M (or L for arrays). Null values are mapped to NULL, and all other values
+// to S.
+func attributeValue(col wal.Column) (types.AttributeValue, error) {
+	if col.Value == nil {
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	}
+
+	// the type modifiers are not relevant for the mapping (i.e. numeric(10,2))
+	pgType, _, _ := strings.Cut(col.Type, "(")
+	switch strings.TrimSpace(pgType) {
+	case "boolean", "bool":
+		if b, ok := col.Value.(bool); ok {
+			return &types.AttributeValueMemberBOOL{Value: b}, nil
+		}
+	case "smallint", "integer", "bigint", "int2", "int4", "int8",
+		"numeric", "decimal", "real", "double precision", "float4", "float8":
+		// special numeric values (NaN, Infinity) can't be represented as
+		// numbers, and are kept as strings
+		if n, ok := numberString(col.Value); ok {
+			return &types.AttributeValueMemberN{Value: n}, nil
+		}
+	case "json", "jsonb":
+		value, err := jsonAttributeValue(col.Value)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", col.Name, err)
+		}
+		return value, nil
+	}
+
+	s, err := stringValue(col.Value)
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %w", col.Name, err)
+	}
+	return &types.AttributeValueMemberS{Value: s}, nil
+}
+
+// jsonAttributeValue returns the attribute value of a json column, which can
+// be either the JSON document or its decoded value.
+func jsonAttributeValue(value any) (types.AttributeValue, error) {
+	var doc []byte
+	switch v := value.(type) {
+	case string:
+		doc = []byte(v)
+	case []byte:
+		doc = v
+	default:
+		return decodedAttributeValue(value)
+	}
+
+	var decoded any
+	if err := json.Unmarshal(doc, &decoded); err != nil {
+		return nil, fmt.Errorf("decoding json value: %w", err)
+	}
+	return decodedAttributeValue(decoded)
+}
+
+// decodedAttributeValue returns the attribute value of a decoded JSON value.
+func decodedAttributeValue(value any) (types.AttributeValue, error) {
+	switch v := value.(type) {
+	case nil:
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case bool:
+		return &types.AttributeValueMemberBOOL{Value: v}, nil
+	case string:
+		return &types.AttributeValueMemberS{Value: v}, nil
+	case map[string]any:
+		m := make(map[string]types.AttributeValue, len(v))
+		for key, value := range v {
+			attr, err := decodedAttributeValue(value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = attr
+		}
+		return &types.AttributeValueMemberM{Value: m}, nil
+	case []any:
+		l := make([]types.AttributeValue, 0, len(v))
+		for _, value := range v {
+			attr, err := decodedAttributeValue(value)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, attr)
+		}
+		return &types.AttributeValueMemberL{Value: l}, nil
+	}
+
+	if n, ok := numberString(value); ok {
+		return &types.AttributeValueMemberN{Value: n}, nil
+	}
+	return nil, fmt.Errorf("unsupported json value type %T", value)
+}
+
+// numberString returns the DynamoDB number representation of the value on
+// input, and whether it's a valid number.
+func numberString(value any) (string, bool) {
+	switch v := value.(type) {
+	case int:
+		return strconv.Itoa(v), true
+	case int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), true
+	case float32:
+		return floatString(float64(v))
+	case float64:
+		return floatString(v)
+	case string:
+		// numeric values are decoded as strings to keep their precision
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", false
+		}
+		return v, true
+	default:
+		return "", false
+	}
+}
+
+func floatString(f float64) (string, bool) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", false
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), true
+}
+
+func stringValue(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+
+	// composite values, such as arrays, are kept as their JSON representation
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("encoding value: %w", err)
+	}
+	return string(b), nil
+}
+
+// itemSize returns an approximation of the size of the item on input, as the
+// length of its attribute names and values.
+func itemSize(item map[string]types.AttributeValue) int {
+	size := 0
+	for name, value := range item {
+		size += len(name) + attributeSize(value)
+	}
+	return size
+}
+
+func attributeSize(value types.AttributeValue) int {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return len(v.Value)
+	case *types.AttributeValueMemberN:
+		return len(v.Value)
+	case *types.AttributeValueMemberM:
+		return itemSize(v.Value)
+	case *types.AttributeValueMemberL:
+		size := 0
+		for _, value := range v.Value {
+			size += attributeSize(value)
+		}
+		return size
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dynamodb
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestAttributeValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		col  wal.Column
+
+		wantValue types.AttributeValue
+		wantErr   bool
+	}{
+		{
+			name:      "null",
+			col:       wal.Column{Name: "col", Type: "integer", Value: nil},
+			wantValue: &types.AttributeValueMemberNULL{Value: true},
+		},
+		{
+			name:      "boolean",
+			col:       wal.Column{Name: "col", Type: "boolean", Value: true},
+			wantValue: &types.AttributeValueMemberBOOL{Value: true},
+		},
+		{
+			name:      "integer",
+			col:       wal.Column{Name: "col", Type: "bigint", Value: int64(42)},
+			wantValue: &types.AttributeValueMemberN{Value: "42"},
+		},
+		{
+			name:      "float",
+			col:       wal.Column{Name: "col", Type: "double precision", Value: 1e21},
+			wantValue: &types.AttributeValueMemberN{Value: "1000000000000000000000"},
+		},
+		{
+			name:      "numeric with type modifiers",
+			col:       wal.Column{Name: "col", Type: "numeric(20,10)", Value: "12345678901.1234567890"},
+			wantValue: &types.AttributeValueMemberN{Value: "12345678901.1234567890"},
+		},
+		{
+			name:      "numeric nan",
+			col:       wal.Column{Name: "col", Type: "numeric", Value: "NaN"},
+			wantValue: &types.AttributeValueMemberS{Value: "NaN"},
+		},
+		{
+			name:      "float infinity",
+			col:       wal.Column{Name: "col", Type: "real", Value: math.Inf(1)},
+			wantValue: &types.AttributeValueMemberS{Value: "+Inf"},
+		},
+		{
+			name: "jsonb",
+			col:  wal.Column{Name: "col", Type: "jsonb", Value: `{"a":1,"b":[true,"c",null],"d":{"e":1.5}}`},
+			wantValue: &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"a": &types.AttributeValueMemberN{Value: "1"},
+				"b": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+					&types.AttributeValueMemberBOOL{Value: true},
+					&types.AttributeValueMemberS{Value: "c"},
+					&types.AttributeValueMemberNULL{Value: true},
+				}},
+				"d": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+					"e": &types.AttributeValueMemberN{Value: "1.5"},
+				}},
+			}},
+		},
+		{
+			name: "decoded json",
+			col:  wal.Column{Name: "col", Type: "json", Value: map[string]any{"a": "b"}},
+			wantValue: &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"a": &types.AttributeValueMemberS{Value: "b"},
+			}},
+		},
+		{
+			name:      "text",
+			col:       wal.Column{Name: "col", Type: "text", Value: "hello"},
+			wantValue: &types.AttributeValueMemberS{Value: "hello"},
+		},
+		{
+			name:      "timestamp",
+			col:       wal.Column{Name: "col", Type: "timestamp with time zone", Value: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)},
+			wantValue: &types.AttributeValueMemberS{Value: "2024-01-02T03:04:05.000000006Z"},
+		},
+		{
+			name:      "stringer",
+			col:       wal.Column{Name: "col", Type: "inet", Value: net.ParseIP("10.0.0.1")},
+			wantValue: &types.AttributeValueMemberS{Value: "10.0.0.1"},
+		},
+		{
+			name:      "array",
+			col:       wal.Column{Name: "col", Type: "integer[]", Value: []any{1, 2}},
+			wantValue: &types.AttributeValueMemberS{Value: "[1,2]"},
+		},
+		{
+			name:    "error - invalid json",
+			col:     wal.Column{Name: "col", Type: "jsonb", Value: `{"a":`},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := attributeValue(tc.col)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantValue, value)
+		})
+	}
+}
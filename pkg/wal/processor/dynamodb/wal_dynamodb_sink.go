@@ -0,0 +1,436 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// DynamoDBSink is a wal processor that writes the events to DynamoDB tables.
+// Inserts and updates are written with PutItem requests, replacing the whole
+// item, and deletes with DeleteItem requests keyed by the primary key columns of
+// the row. Updates that change the primary key delete the item with the old
+// key before putting the new one. Every item keeps the LSN of the last change applied to it in the
+// version attribute, and the requests are conditioned on it, so that changes
+// older than the item (i.e. replayed after a restart) are skipped. Truncates
+// and schema changes are not replicated, so the target tables need to exist,
+// with a primary key matching the primary key columns of the source tables.
+type DynamoDBSink struct {
+	logger           loglib.Logger
+	batchSender      batchSender
+	instrumentation  *otel.Instrumentation
+	client           client
+	lsnParser        replication.LSNParser
+	versionAttribute string
+	// tables maps the qualified source table names to their DynamoDB table
+	tables        map[string]string
+	catchAllTable string
+
+	// optional checkpointer callback to mark what was safely processed
+	checkpointer checkpointer.Checkpoint
+}
+
+type Option func(*DynamoDBSink)
+
+type batchSender interface {
+	SendMessage(context.Context, *batch.WALMessage[message]) error
+	Close()
+}
+
+type client interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+}
+
+// message is the DynamoDB request that applies a single row change
+type message struct {
+	table string
+	// item is set for put requests, and key for delete requests
+	item map[string]types.AttributeValue
+	key  map[string]types.AttributeValue
+	// oldKey is set for put requests of updates that change the primary key,
+	// so that the item with the old key is deleted first
+	oldKey  map[string]types.AttributeValue
+	version uint64
+	size    int
+}
+
+const (
+	// TableAttribute is the attribute of the items written to the catch-all
+	// table that has the qualified name of their source table.
+	TableAttribute = "_table"
+
+	// the change is only applied if the item doesn't exist, or it's not more
+	// recent than the change
+	versionCondition = "attribute_not_exists(#version) OR #version <= :version"
+)
+
+var (
+	errMissingTable           = errors.New("missing dynamodb table configuration")
+	errMissingIdentityColumns = errors.New("missing identity columns")
+)
+
+func NewDynamoDBSink(ctx context.Context, cfg *Config, opts ...Option) (*DynamoDBSink, error) {
+	if len(cfg.Tables) == 0 && cfg.Table == "" {
+		return nil, errMissingTable
+	}
+
+	awsOpts := []func(*awsconfig.LoadOptions) error{}
+	if cfg.Region != "" {
+		awsOpts = append(awsOpts, awsconfig.WithRegion(cfg.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("loading aws config: %w", err)
+	}
+	client := dynamodb.NewFromConfig(awsCfg, func(o *dynamodb.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+	})
+
+	s := newDynamoDBSink(client, cfg)
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.batchSender, err = batch.NewSender(ctx, &cfg.Batch, s.sendBatch, s.logger, batch.WithInstrumentation(s.instrumentation, s.Name()))
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func newDynamoDBSink(client client, cfg *Config) *DynamoDBSink {
+	tables := make(map[string]string, len(cfg.Tables))
+	for sourceTable, table := range cfg.Tables {
+		if !strings.Contains(sourceTable, ".") {
+			sourceTable = publicSchema + "." + sourceTable
+		}
+		tables[sourceTable] = table
+	}
+
+	return &DynamoDBSink{
+		logger:           loglib.NewNoopLogger(),
+		client:           client,
+		lsnParser:        pgreplication.NewLSNParser(),
+		versionAttribute: cfg.versionAttribute(),
+		tables:           tables,
+		catchAllTable:    cfg.Table,
+	}
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *DynamoDBSink) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "dynamodb_sink",
+		})
+	}
+}
+
+func WithCheckpoint(c checkpointer.Checkpoint) Option {
+	return func(s *DynamoDBSink) {
+		s.checkpointer = c
+	}
+}
+
+// WithInstrumentation reports the metrics of the batches sent by the sink.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(s *DynamoDBSink) {
+		s.instrumentation = i
+	}
+}
+
+// ProcessWALEvent is called on every new message from the wal. It can be called
+// concurrently.
+func (s *DynamoDBSink) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Panic("[PANIC] Panic while processing replication event", loglib.Fields{
+				"wal_data":    walEvent,
+				"panic":       r,
+				"stack_trace": debug.Stack(),
+			})
+
+			retErr = fmt.Errorf("dynamodb sink: understanding event: %v", r)
+		}
+	}()
+
+	msg, err := s.newMessage(walEvent.Data)
+	if err != nil {
+		return err
+	}
+
+	return s.batchSender.SendMessage(ctx, batch.NewWALMessage(msg, walEvent.CommitPosition))
+}
+
+func (s *DynamoDBSink) Name() string {
+	return "dynamodb-sink"
+}
+
+func (s *DynamoDBSink) Close() error {
+	s.batchSender.Close()
+	return nil
+}
+
+// Ping checks the target tables exist.
+func (s *DynamoDBSink) Ping(ctx context.Context) error {
+	tables := slices.Collect(maps.Values(s.tables))
+	if s.catchAllTable != "" {
+		tables = append(tables, s.catchAllTable)
+	}
+	slices.Sort(tables)
+	for _, table := range slices.Compact(tables) {
+		if _, err := s.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)}); err != nil {
+			return fmt.Errorf("describing dynamodb table %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// newMessage returns the message for the event data on input. Events that
+// aren't written, such as schema changes or changes to tables without a
+// target table, are returned as empty messages so that their position is
+// still checkpointed.
+func (s *DynamoDBSink) newMessage(data *wal.Data) (message, error) {
+	if data == nil || processor.IsSchemaLogEvent(data) {
+		return message{}, nil
+	}
+
+	sourceTable := data.Schema + "." + data.Table
+	table, found := s.targetTable(sourceTable)
+	if !found {
+		return message{}, nil
+	}
+
+	var columns, oldKeyColumns []wal.Column
+	switch wal.Action(data.Action) {
+	case wal.ActionInsert:
+		columns = data.Columns
+	case wal.ActionUpdate:
+		columns = data.Columns
+		oldKeyColumns = changedKeyColumns(data)
+	case wal.ActionDelete:
+		columns = keyColumns(data)
+		if len(columns) == 0 {
+			s.logger.Warn(errMissingIdentityColumns, "dynamodb sink: skipping event, the table needs a replica identity", data.LogFields())
+			return message{}, nil
+		}
+	case wal.ActionTruncate:
+		s.logger.Warn(nil, "dynamodb sink: skipping truncate event, not supported by dynamodb", data.LogFields())
+		return message{}, nil
+	default:
+		return message{}, nil
+	}
+
+	lsn, err := s.lsnParser.FromString(data.LSN)
+	if err != nil {
+		return message{}, fmt.Errorf("parsing event lsn %q: %w", data.LSN, err)
+	}
+
+	attributes, err := s.attributes(columns, table, sourceTable)
+	if err != nil {
+		return message{}, err
+	}
+
+	msg := message{
+		table:   table,
+		version: uint64(lsn),
+	}
+	if wal.Action(data.Action) == wal.ActionDelete {
+		msg.key = attributes
+	} else {
+		attributes[s.versionAttribute] = versionValue(msg.version)
+		msg.item = attributes
+	}
+	msg.size = itemSize(attributes)
+
+	if len(oldKeyColumns) > 0 {
+		if msg.oldKey, err = s.attributes(oldKeyColumns, table, sourceTable); err != nil {
+			return message{}, err
+		}
+		msg.size += itemSize(msg.oldKey)
+	}
+	return msg, nil
+}
+
+// attributes returns the item attributes of the columns on input, written to
+// the DynamoDB table on input.
+func (s *DynamoDBSink) attributes(columns []wal.Column, table, sourceTable string) (map[string]types.AttributeValue, error) {
+	attributes := make(map[string]types.AttributeValue, len(columns)+2)
+	var err error
+	for _, col := range columns {
+		if attributes[col.Name], err = attributeValue(col); err != nil {
+			return nil, fmt.Errorf("mapping event for table %s: %w", sourceTable, err)
+		}
+	}
+	// rows of different source tables are told apart by their table in the
+	// catch-all table
+	if table == s.catchAllTable {
+		attributes[TableAttribute] = &types.AttributeValueMemberS{Value: sourceTable}
+	}
+	return attributes, nil
+}
+
+func (s *DynamoDBSink) targetTable(sourceTable string) (string, bool) {
+	if table, found := s.tables[sourceTable]; found {
+		return table, true
+	}
+	return s.catchAllTable, s.catchAllTable != ""
+}
+
+func (s *DynamoDBSink) sendBatch(ctx context.Context, batch *batch.Batch[message]) error {
+	messages := batch.GetMessages()
+	s.logger.Debug("dynamodb sink: writing message batch", loglib.Fields{
+		"batch_size":             len(messages),
+		"batch_commit_positions": len(batch.GetCommitPositions()),
+	})
+
+	// the changes to the same item need to be applied in order, and the
+	// conditional writes are not supported by BatchWriteItem, so the messages
+	// are written one by one
+	for _, msg := range messages {
+		if err := s.write(ctx, msg); err != nil {
+			s.logger.Error(err, "failed to write to dynamodb")
+			return fmt.Errorf("dynamodb sink: %w", err)
+		}
+	}
+
+	positions := batch.GetCommitPositions()
+	if s.checkpointer != nil && len(positions) > 0 {
+		if err := s.checkpointer(ctx, positions); err != nil {
+			s.logger.Warn(err, "dynamodb sink: error updating commit position")
+		}
+	}
+
+	return nil
+}
+
+func (s *DynamoDBSink) write(ctx context.Context, msg message) error {
+	if msg.oldKey != nil {
+		if err := s.deleteItem(ctx, msg.table, msg.oldKey, msg.version); err != nil {
+			return err
+		}
+	}
+	if msg.key != nil {
+		return s.deleteItem(ctx, msg.table, msg.key, msg.version)
+	}
+
+	_, err := s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 aws.String(msg.table),
+		Item:                      msg.item,
+		ConditionExpression:       aws.String(versionCondition),
+		ExpressionAttributeNames:  s.conditionNames(),
+		ExpressionAttributeValues: conditionValues(versionValue(msg.version)),
+	})
+	return s.checkWriteErr(err, msg.table, msg.version)
+}
+
+func (s *DynamoDBSink) deleteItem(ctx context.Context, table string, key map[string]types.AttributeValue, version uint64) error {
+	_, err := s.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:                 aws.String(table),
+		Key:                       key,
+		ConditionExpression:       aws.String(versionCondition),
+		ExpressionAttributeNames:  s.conditionNames(),
+		ExpressionAttributeValues: conditionValues(versionValue(version)),
+	})
+	return s.checkWriteErr(err, table, version)
+}
+
+func (s *DynamoDBSink) conditionNames() map[string]string {
+	return map[string]string{"#version": s.versionAttribute}
+}
+
+func conditionValues(version types.AttributeValue) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{":version": version}
+}
+
+// checkWriteErr returns the error of the write request on input, ignoring the
+// failed version conditions.
+func (s *DynamoDBSink) checkWriteErr(err error, table string, version uint64) error {
+	var conditionErr *types.ConditionalCheckFailedException
+	switch {
+	case errors.As(err, &conditionErr):
+		// the item has a more recent change already applied
+		s.logger.Debug("dynamodb sink: skipping outdated change", loglib.Fields{
+			loglib.TableField: table,
+			"version":         version,
+		})
+		return nil
+	case err != nil:
+		return fmt.Errorf("writing to table %s: %w", table, err)
+	default:
+		return nil
+	}
+}
+
+// keyColumns returns the columns of the item key for the delete event on
+// input. The replica identity columns are narrowed down to the primary key
+// columns identified by the injector, if any, since the replica identity can
+// include all the columns of the row.
+func keyColumns(data *wal.Data) []wal.Column {
+	pkColumns := []wal.Column{}
+	for _, col := range data.Identity {
+		if slices.Contains(data.Metadata.InternalColIDs, col.ID) {
+			pkColumns = append(pkColumns, col)
+		}
+	}
+	if len(pkColumns) > 0 {
+		return pkColumns
+	}
+	return data.Identity
+}
+
+// changedKeyColumns returns the columns of the old item key for the update
+// event on input, if the update changes the primary key. Postgres only
+// includes the old identity in updates that change it, unless the table has
+// replica identity full, in which case the key is identified the same way as
+// for deletes.
+func changedKeyColumns(data *wal.Data) []wal.Column {
+	if len(data.Identity) == 0 {
+		return nil
+	}
+	oldKey := keyColumns(data)
+	for _, oldCol := range oldKey {
+		i := slices.IndexFunc(data.Columns, func(col wal.Column) bool { return col.Name == oldCol.Name })
+		if i == -1 || !reflect.DeepEqual(data.Columns[i].Value, oldCol.Value) {
+			return oldKey
+		}
+	}
+	return nil
+}
+
+func versionValue(version uint64) types.AttributeValue {
+	return &types.AttributeValueMemberN{Value: strconv.FormatUint(version, 10)}
+}
+
+func (m message) Size() int {
+	return m.size
+}
+
+func (m message) IsEmpty() bool {
+	return m.table == ""
+}
@@ -0,0 +1,465 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	batchmocks "github.com/xataio/pgstream/pkg/wal/processor/batch/mocks"
+)
+
+type mockClient struct {
+	putItemFn    func(*dynamodb.PutItemInput) error
+	deleteItemFn func(*dynamodb.DeleteItemInput) error
+
+	puts      []*dynamodb.PutItemInput
+	deletes   []*dynamodb.DeleteItemInput
+	described []string
+}
+
+func (m *mockClient) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.puts = append(m.puts, params)
+	if m.putItemFn != nil {
+		if err := m.putItemFn(params); err != nil {
+			return nil, err
+		}
+	}
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *mockClient) DeleteItem(_ context.Context, params *dynamodb.DeleteItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	m.deletes = append(m.deletes, params)
+	if m.deleteItemFn != nil {
+		if err := m.deleteItemFn(params); err != nil {
+			return nil, err
+		}
+	}
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func (m *mockClient) DescribeTable(_ context.Context, params *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	m.described = append(m.described, aws.ToString(params.TableName))
+	return &dynamodb.DescribeTableOutput{}, nil
+}
+
+func newTestData(action wal.Action, table string, id int) *wal.Data {
+	data := &wal.Data{
+		Action: string(action),
+		LSN:    "0/10",
+		Schema: "public",
+		Table:  table,
+	}
+	switch action {
+	case wal.ActionInsert, wal.ActionUpdate:
+		data.Columns = []wal.Column{
+			{ID: "id-col", Name: "id", Type: "integer", Value: id},
+			{ID: "doc-col", Name: "doc", Type: "jsonb", Value: `{"a":true}`},
+		}
+	}
+	switch action {
+	case wal.ActionUpdate, wal.ActionDelete:
+		data.Identity = []wal.Column{{ID: "id-col", Name: "id", Type: "integer", Value: id}}
+	}
+	return data
+}
+
+func newTestSink(client client, cfg *Config) *DynamoDBSink {
+	return newDynamoDBSink(client, cfg)
+}
+
+func TestNewDynamoDBSink(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewDynamoDBSink(context.Background(), &Config{})
+	require.ErrorIs(t, err, errMissingTable)
+}
+
+func TestDynamoDBSink_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	testCommitPosition := wal.CommitPosition("0/10")
+	testDoc := &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+		"a": &types.AttributeValueMemberBOOL{Value: true},
+	}}
+	testVersion := &types.AttributeValueMemberN{Value: "16"}
+
+	tests := []struct {
+		name  string
+		cfg   *Config
+		event *wal.Event
+
+		wantMsgs []*batch.WALMessage[message]
+		wantErr  error
+	}{
+		{
+			name:  "ok - insert into mapped table",
+			cfg:   &Config{Tables: map[string]string{"users": "users_table"}},
+			event: &wal.Event{Data: newTestData(wal.ActionInsert, "users", 1), CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{
+					table: "users_table",
+					item: map[string]types.AttributeValue{
+						"id":       &types.AttributeValueMemberN{Value: "1"},
+						"doc":      testDoc,
+						"_version": testVersion,
+					},
+					version: 16,
+					size:    18,
+				}, testCommitPosition),
+			},
+		},
+		{
+			name: "ok - update into catch-all table with custom version attribute",
+			cfg: &Config{
+				Tables:           map[string]string{"public.orders": "orders_table"},
+				Table:            "pgstream",
+				VersionAttribute: "lsn",
+			},
+			event: &wal.Event{Data: newTestData(wal.ActionUpdate, "users", 1), CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{
+					table: "pgstream",
+					item: map[string]types.AttributeValue{
+						"id":     &types.AttributeValueMemberN{Value: "1"},
+						"doc":    testDoc,
+						"lsn":    testVersion,
+						"_table": &types.AttributeValueMemberS{Value: "public.users"},
+					},
+					version: 16,
+					size:    31,
+				}, testCommitPosition),
+			},
+		},
+		{
+			name: "ok - delete keyed by primary key columns",
+			cfg:  &Config{Table: "pgstream"},
+			event: &wal.Event{
+				Data: &wal.Data{
+					Action: "D",
+					LSN:    "0/10",
+					Schema: "public",
+					Table:  "users",
+					// replica identity full
+					Identity: []wal.Column{
+						{ID: "id-col", Name: "id", Type: "integer", Value: 1},
+						{ID: "name-col", Name: "name", Type: "text", Value: "alice"},
+					},
+					Metadata: wal.Metadata{InternalColIDs: []string{"id-col"}},
+				},
+				CommitPosition: testCommitPosition,
+			},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{
+					table: "pgstream",
+					key: map[string]types.AttributeValue{
+						"id":     &types.AttributeValueMemberN{Value: "1"},
+						"_table": &types.AttributeValueMemberS{Value: "public.users"},
+					},
+					version: 16,
+					size:    21,
+				}, testCommitPosition),
+			},
+		},
+		{
+			name: "ok - update changing the primary key",
+			cfg:  &Config{Tables: map[string]string{"users": "users_table"}},
+			event: &wal.Event{
+				Data: func() *wal.Data {
+					data := newTestData(wal.ActionUpdate, "users", 2)
+					data.Identity = []wal.Column{{ID: "id-col", Name: "id", Type: "integer", Value: 1}}
+					return data
+				}(),
+				CommitPosition: testCommitPosition,
+			},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{
+					table: "users_table",
+					item: map[string]types.AttributeValue{
+						"id":       &types.AttributeValueMemberN{Value: "2"},
+						"doc":      testDoc,
+						"_version": testVersion,
+					},
+					oldKey: map[string]types.AttributeValue{
+						"id": &types.AttributeValueMemberN{Value: "1"},
+					},
+					version: 16,
+					size:    21,
+				}, testCommitPosition),
+			},
+		},
+		{
+			name: "ok - update with replica identity full keeping the primary key",
+			cfg:  &Config{Tables: map[string]string{"users": "users_table"}},
+			event: &wal.Event{
+				Data: func() *wal.Data {
+					data := newTestData(wal.ActionUpdate, "users", 1)
+					data.Identity = []wal.Column{
+						{ID: "id-col", Name: "id", Type: "integer", Value: 1},
+						{ID: "doc-col", Name: "doc", Type: "jsonb", Value: `{"a":false}`},
+					}
+					data.Metadata = wal.Metadata{InternalColIDs: []string{"id-col"}}
+					return data
+				}(),
+				CommitPosition: testCommitPosition,
+			},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{
+					table: "users_table",
+					item: map[string]types.AttributeValue{
+						"id":       &types.AttributeValueMemberN{Value: "1"},
+						"doc":      testDoc,
+						"_version": testVersion,
+					},
+					version: 16,
+					size:    18,
+				}, testCommitPosition),
+			},
+		},
+		{
+			name:  "ok - table without target table",
+			cfg:   &Config{Tables: map[string]string{"orders": "orders_table"}},
+			event: &wal.Event{Data: newTestData(wal.ActionInsert, "users", 1), CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, testCommitPosition),
+			},
+		},
+		{
+			name: "ok - delete without identity",
+			cfg:  &Config{Table: "pgstream"},
+			event: &wal.Event{
+				Data:           &wal.Data{Action: "D", LSN: "0/10", Schema: "public", Table: "users"},
+				CommitPosition: testCommitPosition,
+			},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, testCommitPosition),
+			},
+		},
+		{
+			name: "ok - truncate",
+			cfg:  &Config{Table: "pgstream"},
+			event: &wal.Event{
+				Data:           &wal.Data{Action: "T", LSN: "0/10", Schema: "public", Table: "users"},
+				CommitPosition: testCommitPosition,
+			},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, testCommitPosition),
+			},
+		},
+		{
+			name: "ok - schema log event",
+			cfg:  &Config{Table: "pgstream"},
+			event: &wal.Event{
+				Data:           &wal.Data{Action: "I", Schema: schemalog.SchemaName, Table: schemalog.TableName},
+				CommitPosition: testCommitPosition,
+			},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, testCommitPosition),
+			},
+		},
+		{
+			name:  "ok - keep alive",
+			cfg:   &Config{Table: "pgstream"},
+			event: &wal.Event{CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, testCommitPosition),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockBatchSender := batchmocks.NewBatchSender[message]()
+			s := newTestSink(&mockClient{}, tc.cfg)
+			s.batchSender = mockBatchSender
+
+			doneChan := make(chan struct{})
+			go func() {
+				defer close(doneChan)
+				require.Equal(t, tc.wantMsgs, mockBatchSender.GetWALMessages())
+			}()
+
+			err := s.ProcessWALEvent(context.Background(), tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+			mockBatchSender.Close()
+			<-doneChan
+		})
+	}
+}
+
+func TestDynamoDBSink_ProcessWALEvent_invalidLSN(t *testing.T) {
+	t.Parallel()
+
+	s := newTestSink(&mockClient{}, &Config{Table: "pgstream"})
+	data := newTestData(wal.ActionInsert, "users", 1)
+	data.LSN = "invalid"
+
+	err := s.ProcessWALEvent(context.Background(), &wal.Event{Data: data})
+	require.Error(t, err)
+}
+
+func TestDynamoDBSink_sendBatch(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	testPut := message{
+		table:   "users_table",
+		item:    map[string]types.AttributeValue{"id": &types.AttributeValueMemberN{Value: "1"}},
+		version: 16,
+	}
+	testDelete := message{
+		table:   "users_table",
+		key:     map[string]types.AttributeValue{"id": &types.AttributeValueMemberN{Value: "2"}},
+		version: 17,
+	}
+	testMove := message{
+		table:   "users_table",
+		item:    map[string]types.AttributeValue{"id": &types.AttributeValueMemberN{Value: "3"}},
+		oldKey:  map[string]types.AttributeValue{"id": &types.AttributeValueMemberN{Value: "1"}},
+		version: 18,
+	}
+	wantPut := &dynamodb.PutItemInput{
+		TableName:                 aws.String("users_table"),
+		Item:                      testPut.item,
+		ConditionExpression:       aws.String(versionCondition),
+		ExpressionAttributeNames:  map[string]string{"#version": "_version"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":version": &types.AttributeValueMemberN{Value: "16"}},
+	}
+	wantDelete := &dynamodb.DeleteItemInput{
+		TableName:                 aws.String("users_table"),
+		Key:                       testDelete.key,
+		ConditionExpression:       aws.String(versionCondition),
+		ExpressionAttributeNames:  map[string]string{"#version": "_version"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":version": &types.AttributeValueMemberN{Value: "17"}},
+	}
+	wantMoveDelete := &dynamodb.DeleteItemInput{
+		TableName:                 aws.String("users_table"),
+		Key:                       testMove.oldKey,
+		ConditionExpression:       aws.String(versionCondition),
+		ExpressionAttributeNames:  map[string]string{"#version": "_version"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":version": &types.AttributeValueMemberN{Value: "18"}},
+	}
+	wantMovePut := &dynamodb.PutItemInput{
+		TableName:                 aws.String("users_table"),
+		Item:                      testMove.item,
+		ConditionExpression:       aws.String(versionCondition),
+		ExpressionAttributeNames:  map[string]string{"#version": "_version"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":version": &types.AttributeValueMemberN{Value: "18"}},
+	}
+
+	tests := []struct {
+		name         string
+		batch        *batch.Batch[message]
+		putItemFn    func(*dynamodb.PutItemInput) error
+		deleteItemFn func(*dynamodb.DeleteItemInput) error
+
+		wantPuts      []*dynamodb.PutItemInput
+		wantDeletes   []*dynamodb.DeleteItemInput
+		wantPositions []wal.CommitPosition
+		wantErr       error
+	}{
+		{
+			name:          "ok",
+			batch:         batch.NewBatch([]message{testPut, testDelete}, []wal.CommitPosition{"0/10", "0/11"}),
+			wantPuts:      []*dynamodb.PutItemInput{wantPut},
+			wantDeletes:   []*dynamodb.DeleteItemInput{wantDelete},
+			wantPositions: []wal.CommitPosition{"0/10", "0/11"},
+		},
+		{
+			name:  "ok - outdated changes are skipped",
+			batch: batch.NewBatch([]message{testPut, testDelete}, []wal.CommitPosition{"0/10", "0/11"}),
+			putItemFn: func(*dynamodb.PutItemInput) error {
+				return &types.ConditionalCheckFailedException{Message: aws.String("outdated")}
+			},
+			deleteItemFn: func(*dynamodb.DeleteItemInput) error {
+				return &types.ConditionalCheckFailedException{Message: aws.String("outdated")}
+			},
+			wantPuts:      []*dynamodb.PutItemInput{wantPut},
+			wantDeletes:   []*dynamodb.DeleteItemInput{wantDelete},
+			wantPositions: []wal.CommitPosition{"0/10", "0/11"},
+		},
+		{
+			name:          "ok - update changing the primary key",
+			batch:         batch.NewBatch([]message{testMove}, []wal.CommitPosition{"0/12"}),
+			wantPuts:      []*dynamodb.PutItemInput{wantMovePut},
+			wantDeletes:   []*dynamodb.DeleteItemInput{wantMoveDelete},
+			wantPositions: []wal.CommitPosition{"0/12"},
+		},
+		{
+			name:  "ok - update changing the primary key of an outdated item",
+			batch: batch.NewBatch([]message{testMove}, []wal.CommitPosition{"0/12"}),
+			deleteItemFn: func(*dynamodb.DeleteItemInput) error {
+				return &types.ConditionalCheckFailedException{Message: aws.String("outdated")}
+			},
+			wantPuts:      []*dynamodb.PutItemInput{wantMovePut},
+			wantDeletes:   []*dynamodb.DeleteItemInput{wantMoveDelete},
+			wantPositions: []wal.CommitPosition{"0/12"},
+		},
+		{
+			name:  "error - deleting the item with the old key",
+			batch: batch.NewBatch([]message{testMove}, []wal.CommitPosition{"0/12"}),
+			deleteItemFn: func(*dynamodb.DeleteItemInput) error {
+				return errTest
+			},
+			wantDeletes: []*dynamodb.DeleteItemInput{wantMoveDelete},
+			wantErr:     errTest,
+		},
+		{
+			name:          "ok - keep alive",
+			batch:         batch.NewBatch([]message{}, []wal.CommitPosition{"0/10"}),
+			wantPositions: []wal.CommitPosition{"0/10"},
+		},
+		{
+			name:  "error - writing item",
+			batch: batch.NewBatch([]message{testPut, testDelete}, []wal.CommitPosition{"0/10", "0/11"}),
+			putItemFn: func(*dynamodb.PutItemInput) error {
+				return errTest
+			},
+			wantPuts: []*dynamodb.PutItemInput{wantPut},
+			wantErr:  errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := &mockClient{putItemFn: tc.putItemFn, deleteItemFn: tc.deleteItemFn}
+			s := newTestSink(client, &Config{Table: "users_table"})
+			var gotPositions []wal.CommitPosition
+			s.checkpointer = func(_ context.Context, positions []wal.CommitPosition) error {
+				gotPositions = positions
+				return nil
+			}
+
+			err := s.sendBatch(context.Background(), tc.batch)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantPositions, gotPositions)
+			require.Equal(t, tc.wantPuts, client.puts)
+			require.Equal(t, tc.wantDeletes, client.deletes)
+		})
+	}
+}
+
+func TestDynamoDBSink_Ping(t *testing.T) {
+	t.Parallel()
+
+	client := &mockClient{}
+	s := newTestSink(client, &Config{
+		Tables: map[string]string{"users": "users_table", "public.orders": "pgstream", "other.users": "users_table"},
+		Table:  "pgstream",
+	})
+
+	require.NoError(t, s.Ping(context.Background()))
+	require.Equal(t, []string{"pgstream", "users_table"}, client.described)
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filelog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// ArchiveReader reads the events archived by a file log sink, from the
+// retained rotated files and the current file. It can be used while the sink
+// is writing to the files.
+type ArchiveReader struct {
+	path string
+}
+
+const tmpFileExt = ".tmp"
+
+// NewArchiveReader returns a reader for the events archived by the file log
+// sink writing to the path on input.
+func NewArchiveReader(path string) (*ArchiveReader, error) {
+	if path == "" {
+		return nil, errMissingPath
+	}
+	return &ArchiveReader{path: path}, nil
+}
+
+// ReadEvents calls the function on input with every archived event, in the
+// order they were written, oldest rotated file first. It stops at the first
+// error returned by the function. Files removed by the sink retention while
+// they're being listed are skipped.
+func (r *ArchiveReader) ReadEvents(ctx context.Context, fn func(*wal.Data) error) error {
+	files, err := r.archivedFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := r.readFile(ctx, file, fn); err != nil {
+			return fmt.Errorf("reading archived file %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// archivedFiles returns the rotated files in chronological order, followed by
+// the current file. Rotated files are compressed in the background, so the
+// compressed copy is preferred when both are found.
+func (r *ArchiveReader) archivedFiles() ([]string, error) {
+	rotated, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return nil, fmt.Errorf("listing archived files: %w", err)
+	}
+
+	filesByRotation := make(map[string]string, len(rotated))
+	for _, file := range rotated {
+		if strings.HasSuffix(file, tmpFileExt) {
+			continue
+		}
+		rotation := strings.TrimSuffix(file, compressedFileExt)
+		if existing, found := filesByRotation[rotation]; found && strings.HasSuffix(existing, compressedFileExt) {
+			continue
+		}
+		filesByRotation[rotation] = file
+	}
+
+	// the rotation timestamp format sorts chronologically
+	rotations := make([]string, 0, len(filesByRotation))
+	for rotation := range filesByRotation {
+		rotations = append(rotations, rotation)
+	}
+	slices.Sort(rotations)
+
+	files := make([]string, 0, len(rotations)+1)
+	for _, rotation := range rotations {
+		files = append(files, filesByRotation[rotation])
+	}
+	return append(files, r.path), nil
+}
+
+func (r *ArchiveReader) readFile(ctx context.Context, path string, fn func(*wal.Data) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// the rotated file might have been compressed or removed by the
+			// sink since it was listed
+			if path != r.path && !strings.HasSuffix(path, compressedFileExt) {
+				return r.readFile(ctx, path+compressedFileExt, fn)
+			}
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, compressedFileExt) {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	bufReader := bufio.NewReader(reader)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, readErr := bufReader.ReadBytes('\n')
+		// a line without the trailing new line is being written by the sink,
+		// and is left for the next read
+		if readErr == nil && len(bytes.TrimSpace(line)) > 0 {
+			data := &wal.Data{}
+			if err := json.Unmarshal(line, data); err != nil {
+				return fmt.Errorf("parsing log line: %w", err)
+			}
+			if err := fn(data); err != nil {
+				return err
+			}
+		}
+		if errors.Is(readErr, io.EOF) {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
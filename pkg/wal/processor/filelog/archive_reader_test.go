@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filelog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestNewArchiveReader(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewArchiveReader("")
+	require.ErrorIs(t, err, errMissingPath)
+}
+
+func TestArchiveReader_ReadEvents(t *testing.T) {
+	t.Parallel()
+
+	readIDs := func(t *testing.T, reader *ArchiveReader) []float64 {
+		ids := []float64{}
+		err := reader.ReadEvents(context.Background(), func(data *wal.Data) error {
+			ids = append(ids, data.Columns[0].Value.(float64))
+			return nil
+		})
+		require.NoError(t, err)
+		return ids
+	}
+
+	t.Run("ok - rotated and current files", func(t *testing.T) {
+		t.Parallel()
+
+		cfg := &Config{Path: filepath.Join(t.TempDir(), "events.log"), RotationInterval: time.Minute}
+		file, clock := newTestRotatingFile(t, cfg)
+		for id := 1; id <= 6; id++ {
+			require.NoError(t, file.write(newTestLogLine(t, id).line))
+			if id%2 == 0 {
+				clock.advance(time.Minute)
+			}
+		}
+		require.NoError(t, file.close())
+
+		// the oldest rotation is left uncompressed, as if the compression
+		// was still in progress
+		rotated, err := filepath.Glob(cfg.Path + ".*" + compressedFileExt)
+		require.NoError(t, err)
+		require.Len(t, rotated, 2)
+		oldest := rotated[0][:len(rotated[0])-len(compressedFileExt)]
+		content := readGzipFile(t, rotated[0])
+		require.NoError(t, os.WriteFile(oldest, []byte(content), 0o644))
+		require.NoError(t, os.Remove(rotated[0]))
+		// incomplete temporary files are ignored
+		require.NoError(t, os.WriteFile(rotated[1]+tmpFileExt, []byte("{"), 0o644))
+
+		reader, err := NewArchiveReader(cfg.Path)
+		require.NoError(t, err)
+		require.Equal(t, []float64{1, 2, 3, 4, 5, 6}, readIDs(t, reader))
+	})
+
+	t.Run("ok - incomplete last line", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "events.log")
+		line := newTestLogLine(t, 1).line
+		require.NoError(t, os.WriteFile(path, append(line, line[:10]...), 0o644))
+
+		reader, err := NewArchiveReader(path)
+		require.NoError(t, err)
+		require.Equal(t, []float64{1}, readIDs(t, reader))
+	})
+
+	t.Run("ok - no files", func(t *testing.T) {
+		t.Parallel()
+
+		reader, err := NewArchiveReader(filepath.Join(t.TempDir(), "events.log"))
+		require.NoError(t, err)
+		require.Empty(t, readIDs(t, reader))
+	})
+
+	t.Run("error - callback error", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "events.log")
+		line := newTestLogLine(t, 1).line
+		require.NoError(t, os.WriteFile(path, append(line, line...), 0o644))
+		errTest := errors.New("oh noes")
+
+		reader, err := NewArchiveReader(path)
+		require.NoError(t, err)
+		calls := 0
+		err = reader.ReadEvents(context.Background(), func(data *wal.Data) error {
+			calls++
+			return errTest
+		})
+		require.ErrorIs(t, err, errTest)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("error - invalid line", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "events.log")
+		require.NoError(t, os.WriteFile(path, []byte("not json\n"), 0o644))
+
+		reader, err := NewArchiveReader(path)
+		require.NoError(t, err)
+		err = reader.ReadEvents(context.Background(), func(data *wal.Data) error { return nil })
+		require.Error(t, err)
+	})
+}
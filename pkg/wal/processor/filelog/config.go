@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filelog
+
+import (
+	"time"
+
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+type Config struct {
+	// Path is the file the events are written to. Rotated files are kept in
+	// the same directory, with the rotation timestamp appended to their name.
+	Path string
+	// RotationInterval is the maximum time events are written to the same
+	// file before it's rotated. Defaults to 1h.
+	RotationInterval time.Duration
+	// MaxFileBytes is the size in bytes at which the file is rotated,
+	// regardless of the rotation interval. Defaults to 0 (disabled).
+	MaxFileBytes int64
+	// MaxRotations is the number of compressed rotated files retained. The
+	// oldest files are deleted once the limit is reached. Defaults to 24.
+	MaxRotations int
+	// TailBufferSize is the number of events buffered for each tail
+	// subscriber. Events to subscribers with a full buffer are dropped.
+	// Defaults to 1000.
+	TailBufferSize int
+
+	Batch batch.Config
+}
+
+const (
+	defaultRotationInterval = time.Hour
+	defaultMaxRotations     = 24
+	defaultTailBufferSize   = 1000
+)
+
+func (c *Config) rotationInterval() time.Duration {
+	if c.RotationInterval > 0 {
+		return c.RotationInterval
+	}
+	return defaultRotationInterval
+}
+
+func (c *Config) maxRotations() int {
+	if c.MaxRotations > 0 {
+		return c.MaxRotations
+	}
+	return defaultMaxRotations
+}
+
+func (c *Config) tailBufferSize() int {
+	if c.TailBufferSize > 0 {
+		return c.TailBufferSize
+	}
+	return defaultTailBufferSize
+}
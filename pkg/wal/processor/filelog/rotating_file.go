@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filelog
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+// rotatingFile is an append only file that is rotated when it reaches the
+// configured age or size. Rotated files are compressed with gzip in the
+// background, and only the configured number of them are retained. It's not
+// safe for concurrent use.
+type rotatingFile struct {
+	logger       loglib.Logger
+	clock        func() time.Time
+	path         string
+	interval     time.Duration
+	maxBytes     int64
+	maxRotations int
+
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	// compressMutex serialises the compression and cleanup of the rotated
+	// files
+	compressMutex sync.Mutex
+	compressWg    sync.WaitGroup
+}
+
+const (
+	rotationTimestampFormat = "20060102T150405.000000000"
+	compressedFileExt       = ".gz"
+)
+
+func newRotatingFile(cfg *Config, logger loglib.Logger, clock func() time.Time) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating log directory: %w", err)
+	}
+
+	f := &rotatingFile{
+		logger:       logger,
+		clock:        clock,
+		path:         cfg.Path,
+		interval:     cfg.rotationInterval(),
+		maxBytes:     cfg.MaxFileBytes,
+		maxRotations: cfg.maxRotations(),
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// write appends the bytes on input to the current file, rotating it first if
+// it's due. The bytes are never split across files.
+func (f *rotatingFile) write(p []byte) error {
+	if f.rotationDue(len(p)) {
+		if err := f.rotate(); err != nil {
+			return fmt.Errorf("rotating log file: %w", err)
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing to log file: %w", err)
+	}
+	return nil
+}
+
+// openReader returns a read only handle to the current file, along with the
+// number of bytes written so far. The handle remains valid if the file is
+// rotated.
+func (f *rotatingFile) openReader() (*os.File, int64, error) {
+	r, err := os.Open(f.path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("opening log file for reading: %w", err)
+	}
+	return r, f.size, nil
+}
+
+// close closes the current file and waits for the pending compressions to
+// complete.
+func (f *rotatingFile) close() error {
+	err := f.file.Close()
+	f.compressWg.Wait()
+	return err
+}
+
+func (f *rotatingFile) rotationDue(nextWrite int) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.clock().Sub(f.openedAt) >= f.interval {
+		return true
+	}
+	return f.maxBytes > 0 && f.size+int64(nextWrite) > f.maxBytes
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("retrieving log file size: %w", err)
+	}
+
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = f.clock()
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+
+	rotatedPath := f.path + "." + f.clock().UTC().Format(rotationTimestampFormat)
+	if err := os.Rename(f.path, rotatedPath); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.compressWg.Add(1)
+	go func() {
+		defer f.compressWg.Done()
+		f.compressMutex.Lock()
+		defer f.compressMutex.Unlock()
+
+		if err := compressFile(rotatedPath); err != nil {
+			f.logger.Error(err, "compressing rotated log file", loglib.Fields{"file": rotatedPath})
+			return
+		}
+		if err := f.removeOldRotations(); err != nil {
+			f.logger.Error(err, "removing old rotated log files")
+		}
+	}()
+
+	return nil
+}
+
+// removeOldRotations deletes the oldest compressed rotated files beyond the
+// configured retention. The rotation timestamp format sorts chronologically.
+func (f *rotatingFile) removeOldRotations() error {
+	rotated, err := filepath.Glob(f.path + ".*" + compressedFileExt)
+	if err != nil {
+		return err
+	}
+	if len(rotated) <= f.maxRotations {
+		return nil
+	}
+
+	slices.Sort(rotated)
+	var errs error
+	for _, path := range rotated[:len(rotated)-f.maxRotations] {
+		if err := os.Remove(path); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+// compressFile writes a gzip compressed copy of the file on input and removes
+// the original once the copy is complete.
+func compressFile(path string) (retErr error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpPath := path + compressedFileExt + ".tmp"
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			dst.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	gzipWriter := gzip.NewWriter(dst)
+	if _, err := io.Copy(gzipWriter, src); err != nil {
+		return err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path+compressedFileExt); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// RotatingFile is an append only file rotated and retained as per the file log
+// configuration, for processors that write their own lines. It's not safe for
+// concurrent use.
+type RotatingFile struct {
+	file *rotatingFile
+}
+
+// NewRotatingFile opens the file at the configured path, creating it if
+// needed. The batch and tail settings of the configuration are not used.
+func NewRotatingFile(cfg *Config, logger loglib.Logger) (*RotatingFile, error) {
+	file, err := newRotatingFile(cfg, logger, time.Now)
+	if err != nil {
+		return nil, err
+	}
+	return &RotatingFile{file: file}, nil
+}
+
+// Write appends the bytes on input to the current file, rotating it first if
+// it's due. The bytes are never split across files.
+func (f *RotatingFile) Write(p []byte) error {
+	return f.file.write(p)
+}
+
+// Close closes the current file and waits for the pending compressions of the
+// rotated files to complete.
+func (f *RotatingFile) Close() error {
+	return f.file.close()
+}
@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filelog
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+type testClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestRotatingFile(t *testing.T, cfg *Config) (*rotatingFile, *testClock) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	f, err := newRotatingFile(cfg, loglib.NewNoopLogger(), clock.Now)
+	require.NoError(t, err)
+	return f, clock
+}
+
+func readGzipFile(t *testing.T, path string) string {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func TestRotatingFile_write(t *testing.T) {
+	t.Parallel()
+
+	t.Run("appends to existing file", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "logs", "events.log")
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte("a\n"), 0o644))
+
+		f, _ := newTestRotatingFile(t, &Config{Path: path})
+		require.Equal(t, int64(2), f.size)
+		require.NoError(t, f.write([]byte("b\n")))
+		require.NoError(t, f.close())
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\n", string(content))
+	})
+
+	t.Run("rotates on interval", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "events.log")
+		f, clock := newTestRotatingFile(t, &Config{Path: path, RotationInterval: time.Hour})
+
+		require.NoError(t, f.write([]byte("a\n")))
+		clock.advance(59 * time.Minute)
+		require.NoError(t, f.write([]byte("b\n")))
+		clock.advance(time.Minute)
+		require.NoError(t, f.write([]byte("c\n")))
+		require.NoError(t, f.close())
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "c\n", string(content))
+
+		rotated := path + ".20240101T010000.000000000.gz"
+		require.Equal(t, "a\nb\n", readGzipFile(t, rotated))
+		_, err = os.Stat(path + ".20240101T010000.000000000")
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("rotates on size", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "events.log")
+		f, clock := newTestRotatingFile(t, &Config{Path: path, MaxFileBytes: 5})
+
+		require.NoError(t, f.write([]byte("aa\n")))
+		clock.advance(time.Second)
+		// a single write bigger than the max size is not split
+		require.NoError(t, f.write([]byte("bbbbbb\n")))
+		clock.advance(time.Second)
+		require.NoError(t, f.write([]byte("c\n")))
+		require.NoError(t, f.close())
+
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "c\n", string(content))
+		require.Equal(t, "aa\n", readGzipFile(t, path+".20240101T000001.000000000.gz"))
+		require.Equal(t, "bbbbbb\n", readGzipFile(t, path+".20240101T000002.000000000.gz"))
+	})
+
+	t.Run("retains max rotations", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "events.log")
+		f, clock := newTestRotatingFile(t, &Config{Path: path, MaxFileBytes: 1, MaxRotations: 2})
+
+		for _, line := range []string{"a\n", "b\n", "c\n", "d\n"} {
+			require.NoError(t, f.write([]byte(line)))
+			clock.advance(time.Second)
+		}
+		require.NoError(t, f.close())
+
+		rotated, err := filepath.Glob(path + ".*")
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			path + ".20240101T000002.000000000.gz",
+			path + ".20240101T000003.000000000.gz",
+		}, rotated)
+		require.Equal(t, "b\n", readGzipFile(t, rotated[0]))
+		require.Equal(t, "c\n", readGzipFile(t, rotated[1]))
+	})
+}
@@ -0,0 +1,334 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filelog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/xataio/pgstream/internal/json"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+// FileLogSink is a wal processor that writes the events as JSON lines to a
+// local file, rotating it hourly or when it reaches the configured size. It
+// can be used to keep an audit trail of the replicated changes, or to monitor
+// them live with Tail.
+type FileLogSink struct {
+	logger          loglib.Logger
+	batchSender     batchSender
+	instrumentation *otel.Instrumentation
+	serialiser      func(any) ([]byte, error)
+	tailBufferSize  int
+
+	// optional checkpointer callback to mark what was safely processed
+	checkpointer checkpointer.Checkpoint
+
+	// mutex guards the file writes and the tail subscribers, so that a new
+	// subscriber doesn't miss or duplicate events written concurrently
+	mutex       sync.Mutex
+	file        *rotatingFile
+	subscribers map[*tailSubscriber]struct{}
+	closed      bool
+}
+
+type Option func(*FileLogSink)
+
+type batchSender interface {
+	SendMessage(context.Context, *batch.WALMessage[logLine]) error
+	Close()
+}
+
+type tailSubscriber struct {
+	events chan wal.Data
+}
+
+// logLine is a single serialised wal event
+type logLine struct {
+	data *wal.Data
+	line []byte
+}
+
+var (
+	errMissingPath     = errors.New("missing file log path")
+	errInvalidTailSize = errors.New("tail size must be zero or greater")
+	errSinkClosed      = errors.New("file log sink is closed")
+)
+
+func NewFileLogSink(ctx context.Context, cfg *Config, opts ...Option) (*FileLogSink, error) {
+	if cfg.Path == "" {
+		return nil, errMissingPath
+	}
+
+	s := &FileLogSink{
+		logger:         loglib.NewNoopLogger(),
+		serialiser:     json.Marshal,
+		tailBufferSize: cfg.tailBufferSize(),
+		subscribers:    map[*tailSubscriber]struct{}{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	var err error
+	s.file, err = newRotatingFile(cfg, s.logger, time.Now)
+	if err != nil {
+		return nil, err
+	}
+
+	s.batchSender, err = batch.NewSender(ctx, &cfg.Batch, s.sendBatch, s.logger, batch.WithInstrumentation(s.instrumentation, s.Name()))
+	if err != nil {
+		s.file.close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *FileLogSink) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "file_log_sink",
+		})
+	}
+}
+
+func WithCheckpoint(c checkpointer.Checkpoint) Option {
+	return func(s *FileLogSink) {
+		s.checkpointer = c
+	}
+}
+
+// WithInstrumentation reports the metrics of the batches sent by the sink.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(s *FileLogSink) {
+		s.instrumentation = i
+	}
+}
+
+// ProcessWALEvent is called on every new message from the wal. It can be called
+// concurrently.
+func (s *FileLogSink) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) error {
+	line := logLine{}
+	if walEvent.Data != nil {
+		walDataBytes, err := s.serialiser(walEvent.Data)
+		if err != nil {
+			return fmt.Errorf("marshalling event: %w", err)
+		}
+		line = logLine{
+			data: walEvent.Data,
+			line: append(walDataBytes, '\n'),
+		}
+	}
+
+	return s.batchSender.SendMessage(ctx, batch.NewWALMessage(line, walEvent.CommitPosition))
+}
+
+// Tail returns a channel with the last n events written to the current file,
+// followed by the events written from then on. Events are delivered on a best
+// effort basis, they're dropped if the subscriber doesn't keep up, so that
+// the write path is never blocked. The channel is closed when the context is
+// cancelled or the sink is closed.
+func (s *FileLogSink) Tail(ctx context.Context, n int) (<-chan wal.Data, error) {
+	if n < 0 {
+		return nil, errInvalidTailSize
+	}
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil, errSinkClosed
+	}
+	reader, size, err := s.file.openReader()
+	if err != nil {
+		s.mutex.Unlock()
+		return nil, err
+	}
+	sub := &tailSubscriber{events: make(chan wal.Data, s.tailBufferSize)}
+	s.subscribers[sub] = struct{}{}
+	s.mutex.Unlock()
+
+	tailChan := make(chan wal.Data)
+	go func() {
+		defer close(tailChan)
+		defer s.unsubscribe(sub)
+
+		lastEvents, err := readLastEvents(io.LimitReader(reader, size), n)
+		reader.Close()
+		if err != nil {
+			s.logger.Warn(err, "file log sink: reading last events for tail")
+		}
+
+		for _, event := range lastEvents {
+			select {
+			case tailChan <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-sub.events:
+				if !ok {
+					return
+				}
+				select {
+				case tailChan <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return tailChan, nil
+}
+
+func (s *FileLogSink) Name() string {
+	return "file-log-sink"
+}
+
+// Close stops the batch sending and closes the file. Any open tail channels
+// are closed.
+func (s *FileLogSink) Close() error {
+	s.batchSender.Close()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	for sub := range s.subscribers {
+		close(sub.events)
+		delete(s.subscribers, sub)
+	}
+	return s.file.close()
+}
+
+// Ping is a no-op, the log file is local and opened when the sink is created.
+func (s *FileLogSink) Ping(context.Context) error {
+	return nil
+}
+
+func (s *FileLogSink) sendBatch(ctx context.Context, batch *batch.Batch[logLine]) error {
+	lines := batch.GetMessages()
+	s.logger.Debug("file log sink: writing event batch", loglib.Fields{
+		"batch_size":             len(lines),
+		"batch_commit_positions": len(batch.GetCommitPositions()),
+	})
+
+	if len(lines) > 0 {
+		if err := s.writeLines(lines); err != nil {
+			s.logger.Error(err, "failed to write to log file")
+			return fmt.Errorf("file log sink: %w", err)
+		}
+	}
+
+	positions := batch.GetCommitPositions()
+	if s.checkpointer != nil && len(positions) > 0 {
+		if err := s.checkpointer(ctx, positions); err != nil {
+			s.logger.Warn(err, "file log sink: error updating commit position")
+		}
+	}
+
+	return nil
+}
+
+// writeLines writes the lines on input to the file with a single write, and
+// publishes them to the tail subscribers.
+func (s *FileLogSink) writeLines(lines []logLine) error {
+	buf := bytes.Buffer{}
+	for _, l := range lines {
+		buf.Write(l.line)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return errSinkClosed
+	}
+	if err := s.file.write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	for sub := range s.subscribers {
+		dropped := 0
+		for _, l := range lines {
+			select {
+			case sub.events <- *l.data:
+			default:
+				dropped++
+			}
+		}
+		if dropped > 0 {
+			s.logger.Warn(nil, "file log sink: tail subscriber is not keeping up, dropping events", loglib.Fields{
+				"dropped_events": dropped,
+			})
+		}
+	}
+	return nil
+}
+
+func (s *FileLogSink) unsubscribe(sub *tailSubscriber) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.subscribers, sub)
+}
+
+// readLastEvents returns the last n events from the JSON lines on input.
+func readLastEvents(r io.Reader, n int) ([]wal.Data, error) {
+	if n == 0 {
+		return nil, nil
+	}
+
+	lines := make([][]byte, 0, n)
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if len(lines) == n {
+				lines = lines[1:]
+			}
+			lines = append(lines, line)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	events := make([]wal.Data, 0, len(lines))
+	for _, line := range lines {
+		event := wal.Data{}
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, fmt.Errorf("parsing log line: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (l logLine) Size() int {
+	return len(l.line)
+}
+
+func (l logLine) IsEmpty() bool {
+	return l.line == nil
+}
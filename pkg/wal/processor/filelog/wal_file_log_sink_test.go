@@ -0,0 +1,285 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filelog
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/json"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	batchmocks "github.com/xataio/pgstream/pkg/wal/processor/batch/mocks"
+)
+
+func newTestData(id int) *wal.Data {
+	return &wal.Data{
+		Action: "I",
+		Schema: "public",
+		Table:  "test",
+		Columns: []wal.Column{
+			{Name: "id", Type: "integer", Value: float64(id)},
+		},
+	}
+}
+
+func newTestLogLine(t *testing.T, id int) logLine {
+	data := newTestData(id)
+	dataBytes, err := json.Marshal(data)
+	require.NoError(t, err)
+	return logLine{data: data, line: append(dataBytes, '\n')}
+}
+
+func newTestSink(t *testing.T, cfg *Config) *FileLogSink {
+	cfg.Path = filepath.Join(t.TempDir(), "events.log")
+	file, err := newRotatingFile(cfg, loglib.NewNoopLogger(), time.Now)
+	require.NoError(t, err)
+
+	return &FileLogSink{
+		logger:         loglib.NewNoopLogger(),
+		batchSender:    batchmocks.NewBatchSender[logLine](),
+		serialiser:     json.Marshal,
+		tailBufferSize: cfg.tailBufferSize(),
+		file:           file,
+		subscribers:    map[*tailSubscriber]struct{}{},
+	}
+}
+
+func receiveEvents(t *testing.T, events <-chan wal.Data, n int) []wal.Data {
+	received := []wal.Data{}
+	for len(received) < n {
+		select {
+		case event := <-events:
+			received = append(received, event)
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for tail events, received %d out of %d", len(received), n)
+		}
+	}
+	return received
+}
+
+func TestNewFileLogSink(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewFileLogSink(context.Background(), &Config{})
+	require.ErrorIs(t, err, errMissingPath)
+}
+
+func TestFileLogSink_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	testCommitPosition := wal.CommitPosition("0/1")
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name       string
+		event      *wal.Event
+		serialiser func(any) ([]byte, error)
+
+		wantMsgs []*batch.WALMessage[logLine]
+		wantErr  error
+	}{
+		{
+			name:  "ok",
+			event: &wal.Event{Data: newTestData(1), CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[logLine]{
+				batch.NewWALMessage(newTestLogLine(t, 1), testCommitPosition),
+			},
+		},
+		{
+			name:  "ok - keep alive",
+			event: &wal.Event{CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[logLine]{
+				batch.NewWALMessage(logLine{}, testCommitPosition),
+			},
+		},
+		{
+			name:       "error - serialising event",
+			event:      &wal.Event{Data: newTestData(1), CommitPosition: testCommitPosition},
+			serialiser: func(any) ([]byte, error) { return nil, errTest },
+			wantMsgs:   []*batch.WALMessage[logLine]{},
+			wantErr:    errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockBatchSender := batchmocks.NewBatchSender[logLine]()
+			s := &FileLogSink{
+				batchSender: mockBatchSender,
+				serialiser:  json.Marshal,
+			}
+			if tc.serialiser != nil {
+				s.serialiser = tc.serialiser
+			}
+
+			doneChan := make(chan struct{})
+			go func() {
+				defer close(doneChan)
+				require.Equal(t, tc.wantMsgs, mockBatchSender.GetWALMessages())
+			}()
+
+			err := s.ProcessWALEvent(context.Background(), tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+			mockBatchSender.Close()
+			<-doneChan
+		})
+	}
+}
+
+func TestFileLogSink_sendBatch(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name          string
+		batch         *batch.Batch[logLine]
+		checkpointErr error
+
+		wantContent   string
+		wantPositions []wal.CommitPosition
+	}{
+		{
+			name:          "ok",
+			batch:         batch.NewBatch([]logLine{newTestLogLine(t, 1), newTestLogLine(t, 2)}, []wal.CommitPosition{"0/1", "0/2"}),
+			wantContent:   string(newTestLogLine(t, 1).line) + string(newTestLogLine(t, 2).line),
+			wantPositions: []wal.CommitPosition{"0/1", "0/2"},
+		},
+		{
+			name:          "ok - keep alive",
+			batch:         batch.NewBatch([]logLine{}, []wal.CommitPosition{"0/1"}),
+			wantContent:   "",
+			wantPositions: []wal.CommitPosition{"0/1"},
+		},
+		{
+			name:          "ok - checkpoint error",
+			batch:         batch.NewBatch([]logLine{newTestLogLine(t, 1)}, []wal.CommitPosition{"0/1"}),
+			checkpointErr: errTest,
+			wantContent:   string(newTestLogLine(t, 1).line),
+			wantPositions: []wal.CommitPosition{"0/1"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newTestSink(t, &Config{})
+			defer s.Close()
+			var gotPositions []wal.CommitPosition
+			s.checkpointer = func(_ context.Context, positions []wal.CommitPosition) error {
+				gotPositions = positions
+				return tc.checkpointErr
+			}
+
+			err := s.sendBatch(context.Background(), tc.batch)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantPositions, gotPositions)
+
+			content, err := os.ReadFile(s.file.path)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantContent, string(content))
+		})
+	}
+
+	t.Run("error - sink closed", func(t *testing.T) {
+		t.Parallel()
+
+		s := newTestSink(t, &Config{})
+		require.NoError(t, s.Close())
+
+		err := s.sendBatch(context.Background(), batch.NewBatch([]logLine{newTestLogLine(t, 1)}, nil))
+		require.ErrorIs(t, err, errSinkClosed)
+	})
+}
+
+func TestFileLogSink_Tail(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("last events followed by live events", func(t *testing.T) {
+		t.Parallel()
+
+		s := newTestSink(t, &Config{})
+		defer s.Close()
+		require.NoError(t, s.sendBatch(ctx, batch.NewBatch([]logLine{
+			newTestLogLine(t, 1), newTestLogLine(t, 2), newTestLogLine(t, 3),
+		}, nil)))
+
+		tailCtx, cancel := context.WithCancel(ctx)
+		events, err := s.Tail(tailCtx, 2)
+		require.NoError(t, err)
+
+		require.NoError(t, s.sendBatch(ctx, batch.NewBatch([]logLine{newTestLogLine(t, 4)}, nil)))
+		require.Equal(t, []wal.Data{*newTestData(2), *newTestData(3), *newTestData(4)}, receiveEvents(t, events, 3))
+
+		cancel()
+		_, open := <-events
+		require.False(t, open)
+	})
+
+	t.Run("more events requested than written", func(t *testing.T) {
+		t.Parallel()
+
+		s := newTestSink(t, &Config{})
+		defer s.Close()
+		require.NoError(t, s.sendBatch(ctx, batch.NewBatch([]logLine{newTestLogLine(t, 1)}, nil)))
+
+		events, err := s.Tail(ctx, 10)
+		require.NoError(t, err)
+		require.Equal(t, []wal.Data{*newTestData(1)}, receiveEvents(t, events, 1))
+	})
+
+	t.Run("slow subscriber drops events", func(t *testing.T) {
+		t.Parallel()
+
+		s := newTestSink(t, &Config{TailBufferSize: 1})
+		defer s.Close()
+		events, err := s.Tail(ctx, 0)
+		require.NoError(t, err)
+
+		// the write is not blocked by the subscriber
+		require.NoError(t, s.sendBatch(ctx, batch.NewBatch([]logLine{
+			newTestLogLine(t, 1), newTestLogLine(t, 2), newTestLogLine(t, 3),
+		}, nil)))
+		require.Equal(t, []wal.Data{*newTestData(1)}, receiveEvents(t, events, 1))
+	})
+
+	t.Run("channel closed when the sink is closed", func(t *testing.T) {
+		t.Parallel()
+
+		s := newTestSink(t, &Config{})
+		events, err := s.Tail(ctx, 0)
+		require.NoError(t, err)
+		require.NoError(t, s.Close())
+
+		select {
+		case _, open := <-events:
+			require.False(t, open)
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for tail channel to close")
+		}
+
+		_, err = s.Tail(ctx, 0)
+		require.ErrorIs(t, err, errSinkClosed)
+	})
+
+	t.Run("error - invalid tail size", func(t *testing.T) {
+		t.Parallel()
+
+		s := newTestSink(t, &Config{})
+		defer s.Close()
+		_, err := s.Tail(ctx, -1)
+		require.ErrorIs(t, err, errInvalidTailSize)
+	})
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filter
+
+import (
+	"errors"
+	"fmt"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// ActionFilter restricts the actions of the data events that are streamed.
+// Only one of Allow or Deny can be provided. Supported actions are insert
+// (I), update (U), delete (D) and truncate (T).
+type ActionFilter struct {
+	// Allow is the list of actions to keep. Events for any other action are
+	// skipped.
+	Allow []wal.Action
+	// Deny is the list of actions to skip.
+	Deny []wal.Action
+}
+
+// TableActionFilter overrides the default action filter for the configured
+// tables.
+type TableActionFilter struct {
+	// Tables the action filter applies to. Tables should be schema qualified.
+	// If no schema is provided, the public schema will be assumed. Wildcards
+	// "*" are supported.
+	Tables  []string
+	Actions ActionFilter
+}
+
+type actionFilter struct {
+	actions map[wal.Action]struct{}
+	// allow is true when the actions are the ones to keep, and false when
+	// they're the ones to skip
+	allow bool
+}
+
+type tableActionFilter struct {
+	tables pglib.SchemaTableMap
+	filter *actionFilter
+}
+
+var (
+	errAllowDenyActions     = errors.New("cannot use both allow and deny lists for action filtering")
+	errUnsupportedAction    = errors.New("unsupported action, must be one of 'I', 'U', 'D' or 'T'")
+	errMissingFilterTables  = errors.New("table action filter requires at least one table")
+	errMissingFilterActions = errors.New("action filter requires an allow or deny list")
+)
+
+func (af *ActionFilter) compile() (*actionFilter, error) {
+	if len(af.Allow) > 0 && len(af.Deny) > 0 {
+		return nil, errAllowDenyActions
+	}
+	if len(af.Allow) == 0 && len(af.Deny) == 0 {
+		return nil, errMissingFilterActions
+	}
+
+	filter := &actionFilter{
+		actions: make(map[wal.Action]struct{}, len(af.Allow)+len(af.Deny)),
+		allow:   len(af.Allow) > 0,
+	}
+	actions := af.Allow
+	if !filter.allow {
+		actions = af.Deny
+	}
+	for _, action := range actions {
+		switch action {
+		case wal.ActionInsert, wal.ActionUpdate, wal.ActionDelete, wal.ActionTruncate:
+			filter.actions[action] = struct{}{}
+		default:
+			return nil, fmt.Errorf("%w: %q", errUnsupportedAction, action)
+		}
+	}
+	return filter, nil
+}
+
+func (tf *TableActionFilter) compile() (*tableActionFilter, error) {
+	if len(tf.Tables) == 0 {
+		return nil, errMissingFilterTables
+	}
+	filter, err := tf.Actions.compile()
+	if err != nil {
+		return nil, fmt.Errorf("table action filter %v: %w", tf.Tables, err)
+	}
+	tables, err := pglib.NewSchemaTableMap(tf.Tables)
+	if err != nil {
+		return nil, fmt.Errorf("table action filter: %w", err)
+	}
+	return &tableActionFilter{
+		tables: tables,
+		filter: filter,
+	}, nil
+}
+
+// skips returns true if the action on input is filtered out.
+func (f *actionFilter) skips(action wal.Action) bool {
+	_, found := f.actions[action]
+	return found != f.allow
+}
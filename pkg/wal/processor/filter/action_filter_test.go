@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestActionFilter_compile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		actionFilter ActionFilter
+
+		wantSkipped []wal.Action
+		wantErr     error
+	}{
+		{
+			name:         "ok - allow list",
+			actionFilter: ActionFilter{Allow: []wal.Action{wal.ActionInsert, wal.ActionUpdate}},
+			wantSkipped:  []wal.Action{wal.ActionDelete, wal.ActionTruncate},
+		},
+		{
+			name:         "ok - deny list",
+			actionFilter: ActionFilter{Deny: []wal.Action{wal.ActionDelete, wal.ActionTruncate}},
+			wantSkipped:  []wal.Action{wal.ActionDelete, wal.ActionTruncate},
+		},
+		{
+			name:         "error - allow and deny lists",
+			actionFilter: ActionFilter{Allow: []wal.Action{wal.ActionInsert}, Deny: []wal.Action{wal.ActionDelete}},
+			wantErr:      errAllowDenyActions,
+		},
+		{
+			name:         "error - no actions",
+			actionFilter: ActionFilter{},
+			wantErr:      errMissingFilterActions,
+		},
+		{
+			name:         "error - unsupported action",
+			actionFilter: ActionFilter{Deny: []wal.Action{wal.ActionCommit}},
+			wantErr:      errUnsupportedAction,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.actionFilter.compile()
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+
+			skipped := []wal.Action{}
+			for _, action := range []wal.Action{wal.ActionInsert, wal.ActionUpdate, wal.ActionDelete, wal.ActionTruncate} {
+				if got.skips(action) {
+					skipped = append(skipped, action)
+				}
+			}
+			require.Equal(t, tc.wantSkipped, skipped)
+		})
+	}
+}
+
+func TestTableActionFilter_compile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		tableActionFilter TableActionFilter
+
+		wantErr error
+	}{
+		{
+			name:              "ok",
+			tableActionFilter: TableActionFilter{Tables: []string{"analytics.*"}, Actions: ActionFilter{Allow: []wal.Action{wal.ActionInsert}}},
+		},
+		{
+			name:              "error - no tables",
+			tableActionFilter: TableActionFilter{Actions: ActionFilter{Allow: []wal.Action{wal.ActionInsert}}},
+			wantErr:           errMissingFilterTables,
+		},
+		{
+			name:              "error - invalid actions",
+			tableActionFilter: TableActionFilter{Tables: []string{"analytics.*"}},
+			wantErr:           errMissingFilterActions,
+		},
+		{
+			name:              "error - invalid table",
+			tableActionFilter: TableActionFilter{Tables: []string{"invalid.table.name"}, Actions: ActionFilter{Allow: []wal.Action{wal.ActionInsert}}},
+			wantErr:           pglib.ErrInvalidTableName,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := tc.tableActionFilter.compile()
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/xataio/pgstream/internal/json"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// ChangedColumnsFilter only forwards the update events of the configured
+// tables when at least one of the significant columns changed. The old values
+// of the significant columns are required to compare them, which means the
+// tables need to have their replica identity set to full, unless all the
+// significant columns are part of the replica identity. Updates for which the
+// old values are missing are always forwarded.
+type ChangedColumnsFilter struct {
+	// Tables the filter applies to. Tables should be schema qualified. If no
+	// schema is provided, the public schema will be assumed. Wildcards "*"
+	// are supported.
+	Tables []string
+	// Columns are the significant columns. Updates that don't change any of
+	// them are skipped.
+	Columns []string
+}
+
+type changedColumnsFilter struct {
+	tables  pglib.SchemaTableMap
+	columns []string
+}
+
+var (
+	errMissingChangedColumnsTables  = errors.New("changed columns filter requires at least one table")
+	errMissingChangedColumnsColumns = errors.New("changed columns filter requires at least one column")
+)
+
+func (cf *ChangedColumnsFilter) compile() (*changedColumnsFilter, error) {
+	if len(cf.Tables) == 0 {
+		return nil, errMissingChangedColumnsTables
+	}
+	if len(cf.Columns) == 0 {
+		return nil, fmt.Errorf("changed columns filter %v: %w", cf.Tables, errMissingChangedColumnsColumns)
+	}
+	tables, err := pglib.NewSchemaTableMap(cf.Tables)
+	if err != nil {
+		return nil, fmt.Errorf("changed columns filter: %w", err)
+	}
+	return &changedColumnsFilter{
+		tables:  tables,
+		columns: cf.Columns,
+	}, nil
+}
+
+// unchanged returns true if none of the significant columns differ between
+// the old and the new values of the update on input. The significant columns
+// for which the old value is missing are returned, in which case the update
+// can't be considered unchanged. Columns missing from the new values are
+// unchanged TOAST values, which postgres doesn't include in the wal.
+func (f *changedColumnsFilter) unchanged(data *wal.Data) (bool, []string) {
+	var missingOldValues []string
+	changed := false
+	for _, name := range f.columns {
+		oldColumn := findColumn(data.Identity, name)
+		if oldColumn == nil {
+			missingOldValues = append(missingOldValues, name)
+			continue
+		}
+		newColumn := findColumn(data.Columns, name)
+		if newColumn == nil {
+			continue
+		}
+		if !ColumnValuesEqual(newColumn.Type, oldColumn.Value, newColumn.Value) {
+			changed = true
+		}
+	}
+	return !changed && len(missingOldValues) == 0, missingOldValues
+}
+
+func findColumn(columns []wal.Column, name string) *wal.Column {
+	for i := range columns {
+		if columns[i].Name == name {
+			return &columns[i]
+		}
+	}
+	return nil
+}
+
+// ColumnValuesEqual compares the values on input as per their postgres type,
+// so that different representations of the same value are equal, such as
+// jsonb documents with different key order or whitespace, or numeric values
+// with different scales (1.50 and 1.5).
+func ColumnValuesEqual(columnType string, a, b any) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch baseType(columnType) {
+	case "json", "jsonb":
+		aJSON, aOK := normaliseJSON(a)
+		bJSON, bOK := normaliseJSON(b)
+		if aOK && bOK {
+			return reflect.DeepEqual(aJSON, bJSON)
+		}
+	case "numeric", "decimal", "smallint", "integer", "bigint", "int2", "int4", "int8",
+		"real", "double precision", "float4", "float8":
+		aNum, aOK := new(big.Rat).SetString(fmt.Sprint(a))
+		bNum, bOK := new(big.Rat).SetString(fmt.Sprint(b))
+		if aOK && bOK {
+			return aNum.Cmp(bNum) == 0
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// baseType returns the column type without its modifiers, so that
+// numeric(10,2) is numeric.
+func baseType(columnType string) string {
+	if i := strings.IndexByte(columnType, '('); i >= 0 {
+		columnType = columnType[:i]
+	}
+	return strings.TrimSpace(columnType)
+}
+
+// normaliseJSON decodes the json value on input, which can be the json text
+// or an already decoded value.
+func normaliseJSON(v any) (any, bool) {
+	var raw []byte
+	switch value := v.(type) {
+	case string:
+		raw = []byte(value)
+	case []byte:
+		raw = value
+	default:
+		var err error
+		if raw, err = json.Marshal(value); err != nil {
+			return nil, false
+		}
+	}
+	var decoded any
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestChangedColumnsFilter_compile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		filter ChangedColumnsFilter
+
+		wantErr error
+	}{
+		{
+			name:    "ok",
+			filter:  ChangedColumnsFilter{Tables: []string{"public.users"}, Columns: []string{"email"}},
+			wantErr: nil,
+		},
+		{
+			name:    "error - no tables",
+			filter:  ChangedColumnsFilter{Columns: []string{"email"}},
+			wantErr: errMissingChangedColumnsTables,
+		},
+		{
+			name:    "error - no columns",
+			filter:  ChangedColumnsFilter{Tables: []string{"public.users"}},
+			wantErr: errMissingChangedColumnsColumns,
+		},
+		{
+			name:    "error - invalid table pattern",
+			filter:  ChangedColumnsFilter{Tables: []string{"public.users_["}, Columns: []string{"email"}},
+			wantErr: pglib.ErrInvalidTablePattern,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := tc.filter.compile()
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestChangedColumnsFilter_unchanged(t *testing.T) {
+	t.Parallel()
+
+	filter := &changedColumnsFilter{columns: []string{"email", "settings"}}
+
+	tests := []struct {
+		name string
+		data *wal.Data
+
+		wantUnchanged        bool
+		wantMissingOldValues []string
+	}{
+		{
+			name: "unchanged",
+			data: &wal.Data{
+				Identity: []wal.Column{{Name: "email", Value: "a@example.com"}, {Name: "settings", Type: "jsonb", Value: `{"a": 1, "b": 2}`}},
+				Columns:  []wal.Column{{Name: "email", Value: "a@example.com"}, {Name: "settings", Type: "jsonb", Value: `{"b":2,"a":1}`}},
+			},
+			wantUnchanged: true,
+		},
+		{
+			name: "changed",
+			data: &wal.Data{
+				Identity: []wal.Column{{Name: "email", Value: "a@example.com"}, {Name: "settings", Type: "jsonb", Value: `{"a": 1}`}},
+				Columns:  []wal.Column{{Name: "email", Value: "a@example.com"}, {Name: "settings", Type: "jsonb", Value: `{"a": 2}`}},
+			},
+			wantUnchanged: false,
+		},
+		{
+			name: "unchanged toast value",
+			data: &wal.Data{
+				Identity: []wal.Column{{Name: "email", Value: "a@example.com"}, {Name: "settings", Type: "jsonb", Value: `{"a": 1}`}},
+				Columns:  []wal.Column{{Name: "email", Value: "a@example.com"}},
+			},
+			wantUnchanged: true,
+		},
+		{
+			name: "missing old values",
+			data: &wal.Data{
+				Identity: []wal.Column{{Name: "id", Value: 1}},
+				Columns:  []wal.Column{{Name: "id", Value: 1}, {Name: "email", Value: "a@example.com"}, {Name: "settings", Value: nil}},
+			},
+			wantUnchanged:        false,
+			wantMissingOldValues: []string{"email", "settings"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			unchanged, missingOldValues := filter.unchanged(tc.data)
+			require.Equal(t, tc.wantUnchanged, unchanged)
+			require.Equal(t, tc.wantMissingOldValues, missingOldValues)
+		})
+	}
+}
+
+func TestColumnValuesEqual(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		columnType string
+		a, b       any
+
+		wantEqual bool
+	}{
+		{name: "text equal", columnType: "text", a: "a", b: "a", wantEqual: true},
+		{name: "text different", columnType: "text", a: "a", b: "b", wantEqual: false},
+		{name: "both nil", columnType: "text", a: nil, b: nil, wantEqual: true},
+		{name: "one nil", columnType: "text", a: nil, b: "a", wantEqual: false},
+		{name: "numeric different scale", columnType: "numeric(10,2)", a: "1.50", b: 1.5, wantEqual: true},
+		{name: "numeric different", columnType: "numeric", a: "1.50", b: "1.51", wantEqual: false},
+		{name: "numeric not a number", columnType: "numeric", a: "NaN", b: "NaN", wantEqual: true},
+		{name: "bigint representations", columnType: "bigint", a: int64(10), b: float64(10), wantEqual: true},
+		{name: "jsonb key order and whitespace", columnType: "jsonb", a: `{"a": [1, 2], "b": null}`, b: `{"b":null,"a":[1,2]}`, wantEqual: true},
+		{name: "jsonb decoded value", columnType: "jsonb", a: map[string]any{"a": 1}, b: `{"a": 1.0}`, wantEqual: true},
+		{name: "jsonb different", columnType: "jsonb", a: `{"a": 1}`, b: `{"a": "1"}`, wantEqual: false},
+		{name: "invalid json", columnType: "json", a: `{`, b: `{`, wantEqual: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantEqual, ColumnValuesEqual(tc.columnType, tc.a, tc.b))
+		})
+	}
+}
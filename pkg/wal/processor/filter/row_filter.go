@@ -0,0 +1,151 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filter
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// RowFilter is a boolean expression evaluated for the data events of the
+// configured tables, to filter them based on their column values. The
+// expressions use the expr language (https://expr-lang.org), and have access
+// to the following variables:
+//   - action: the event action, one of "I", "U", "D" or "T"
+//   - schema and table: the event schema and table names
+//   - new: map of the new column values by column name (inserts and updates)
+//   - old: map of the identity column values by column name (updates and
+//     deletes). It contains all the columns when the table replica identity
+//     is full.
+//
+// Missing columns evaluate to nil, so that `new.status == "draft"` is false
+// for the events without a status column. The nil coalescing operator can be
+// used to provide a default value, i.e. `(old.amount ?? 0) > 0`.
+type RowFilter struct {
+	// Name identifies the row filter in the logs and metrics. Defaults to the
+	// expression.
+	Name string
+	// Tables the row filter applies to. Tables should be schema qualified. If
+	// no schema is provided, the public schema will be assumed. Wildcards "*"
+	// are supported. If no tables are provided, it applies to all tables.
+	Tables []string
+	// Expression must evaluate to a boolean. Only the events for which it
+	// evaluates to true are kept, unless Exclude is set.
+	Expression string
+	// Exclude skips the events for which the expression evaluates to true,
+	// instead of keeping them.
+	Exclude bool
+}
+
+// RowFilterErrorPolicy defines what happens to the events for which the
+// evaluation of a row filter expression fails.
+type RowFilterErrorPolicy string
+
+const (
+	// RowFilterErrorPass keeps the event, as if it wasn't filtered.
+	RowFilterErrorPass RowFilterErrorPolicy = "pass"
+	// RowFilterErrorDrop skips the event.
+	RowFilterErrorDrop RowFilterErrorPolicy = "drop"
+)
+
+// rowFilterEnv is the environment the row filter expressions are evaluated
+// against.
+type rowFilterEnv struct {
+	Action string         `expr:"action"`
+	Schema string         `expr:"schema"`
+	Table  string         `expr:"table"`
+	New    map[string]any `expr:"new"`
+	Old    map[string]any `expr:"old"`
+}
+
+type rowFilter struct {
+	name    string
+	tables  pglib.SchemaTableMap
+	program *vm.Program
+	exclude bool
+}
+
+var (
+	errMissingRowFilterExpression  = errors.New("row filter expression cannot be empty")
+	errInvalidRowFilterExpression  = errors.New("invalid row filter expression")
+	errInvalidRowFilterErrorPolicy = errors.New("row filter error policy must be one of 'pass' or 'drop'")
+)
+
+// compile validates and compiles the row filter expression, so that invalid
+// expressions are reported at startup.
+func (rf *RowFilter) compile() (*rowFilter, error) {
+	if rf.Expression == "" {
+		return nil, errMissingRowFilterExpression
+	}
+
+	program, err := expr.Compile(rf.Expression, expr.Env(rowFilterEnv{}), expr.AsBool())
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", errInvalidRowFilterExpression, rf.Expression, err)
+	}
+
+	filter := &rowFilter{
+		name:    rf.Name,
+		program: program,
+		exclude: rf.Exclude,
+	}
+	if filter.name == "" {
+		filter.name = rf.Expression
+	}
+	if len(rf.Tables) > 0 {
+		filter.tables, err = pglib.NewSchemaTableMap(rf.Tables)
+		if err != nil {
+			return nil, fmt.Errorf("row filter %s: %w", filter.name, err)
+		}
+	}
+	return filter, nil
+}
+
+func (p RowFilterErrorPolicy) validate() error {
+	switch p {
+	case "", RowFilterErrorPass, RowFilterErrorDrop:
+		return nil
+	default:
+		return errInvalidRowFilterErrorPolicy
+	}
+}
+
+func (f *rowFilter) appliesTo(data *wal.Data) bool {
+	return len(f.tables) == 0 || f.tables.ContainsSchemaTable(data.Schema, data.Table)
+}
+
+// matches returns whether the expression evaluates to true for the event on
+// input.
+func (f *rowFilter) matches(env *rowFilterEnv) (bool, error) {
+	result, err := expr.Run(f.program, env)
+	if err != nil {
+		return false, err
+	}
+	matched, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression returned %T instead of boolean", result)
+	}
+	return matched, nil
+}
+
+func newRowFilterEnv(data *wal.Data) *rowFilterEnv {
+	return &rowFilterEnv{
+		Action: data.Action,
+		Schema: data.Schema,
+		Table:  data.Table,
+		New:    columnValues(data.Columns),
+		Old:    columnValues(data.Identity),
+	}
+}
+
+func columnValues(columns []wal.Column) map[string]any {
+	values := make(map[string]any, len(columns))
+	for _, col := range columns {
+		values[col.Name] = col.Value
+	}
+	return values
+}
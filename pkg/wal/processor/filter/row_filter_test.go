@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestRowFilter_compile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		rowFilter RowFilter
+
+		wantName string
+		wantErr  error
+	}{
+		{
+			name:      "ok - name defaults to expression",
+			rowFilter: RowFilter{Expression: `action == "D"`},
+			wantName:  `action == "D"`,
+		},
+		{
+			name:      "ok - with name and tables",
+			rowFilter: RowFilter{Name: "deletes", Tables: []string{"public.orders"}, Expression: `action == "D"`},
+			wantName:  "deletes",
+		},
+		{
+			name:      "error - empty expression",
+			rowFilter: RowFilter{},
+			wantErr:   errMissingRowFilterExpression,
+		},
+		{
+			name:      "error - syntax error",
+			rowFilter: RowFilter{Expression: `action ==`},
+			wantErr:   errInvalidRowFilterExpression,
+		},
+		{
+			name:      "error - unknown variable",
+			rowFilter: RowFilter{Expression: `status == "draft"`},
+			wantErr:   errInvalidRowFilterExpression,
+		},
+		{
+			name:      "error - non boolean expression",
+			rowFilter: RowFilter{Expression: `table`},
+			wantErr:   errInvalidRowFilterExpression,
+		},
+		{
+			name:      "error - invalid table",
+			rowFilter: RowFilter{Tables: []string{"invalid.table.name"}, Expression: "true"},
+			wantErr:   pglib.ErrInvalidTableName,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tc.rowFilter.compile()
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			require.Equal(t, tc.wantName, got.name)
+		})
+	}
+}
+
+func TestRowFilter_matches(t *testing.T) {
+	t.Parallel()
+
+	updateData := &wal.Data{
+		Action: "U",
+		Schema: "public",
+		Table:  "posts",
+		Columns: []wal.Column{
+			{Name: "id", Value: float64(1)},
+			{Name: "status", Value: "draft"},
+		},
+		Identity: []wal.Column{
+			{Name: "id", Value: float64(1)},
+		},
+	}
+	deleteData := &wal.Data{
+		Action: "D",
+		Schema: "public",
+		Table:  "payments",
+		Identity: []wal.Column{
+			{Name: "id", Value: float64(2)},
+			{Name: "amount", Value: float64(10.5)},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		data       *wal.Data
+
+		wantMatch bool
+		wantErr   bool
+	}{
+		{name: "new column value", expression: `new.status == "draft"`, data: updateData, wantMatch: true},
+		{name: "action and old column value", expression: `action == "D" && old.amount > 0`, data: deleteData, wantMatch: true},
+		{name: "schema and table", expression: `schema == "public" && table == "payments"`, data: deleteData, wantMatch: true},
+		{name: "missing column is nil", expression: `new.status == "draft"`, data: deleteData, wantMatch: false},
+		{name: "missing column with default", expression: `(old.amount ?? 0) > 0`, data: updateData, wantMatch: false},
+		{name: "column membership", expression: `"amount" in old`, data: updateData, wantMatch: false},
+		{name: "invalid comparison with missing column", expression: `old.amount > 0`, data: updateData, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			rowFilter, err := (&RowFilter{Expression: tc.expression}).compile()
+			require.NoError(t, err)
+
+			matched, err := rowFilter.matches(newRowFilterEnv(tc.data))
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantMatch, matched)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"slices"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// SamplingFilter only forwards a percentage of the rows of the configured
+// tables.
+type SamplingFilter struct {
+	// Tables the filter applies to. Tables should be schema qualified. If no
+	// schema is provided, the public schema will be assumed. Wildcards "*"
+	// are supported.
+	Tables []string
+	// Percentage of rows forwarded, between 0 and 100.
+	Percentage float64
+	// Mode defines how the rows are sampled. Defaults to hash.
+	Mode SamplingMode
+	// KeyColumns are the columns hashed to sample the rows in hash mode.
+	// Defaults to the primary key columns identified by the injector. Rows
+	// without key columns are always forwarded.
+	KeyColumns []string
+}
+
+// SamplingMode defines how the sampled rows are selected.
+type SamplingMode string
+
+const (
+	// SamplingModeHash samples the rows by the hash of their key, so that all
+	// the events of a sampled row are forwarded, and none of the events of
+	// the rows that are not sampled.
+	SamplingModeHash SamplingMode = "hash"
+	// SamplingModeRandom samples every event independently, which is only
+	// consistent for insert only tables.
+	SamplingModeRandom SamplingMode = "random"
+)
+
+type samplingFilter struct {
+	tables     pglib.SchemaTableMap
+	mode       SamplingMode
+	keyColumns []string
+	// threshold is the number of hash buckets out of samplingBuckets that are
+	// sampled
+	threshold uint64
+}
+
+// samplingBuckets is the number of buckets the row hashes are distributed
+// across, which allows percentages with up to 4 decimals.
+const samplingBuckets = 1_000_000
+
+var (
+	errMissingSamplingTables  = errors.New("sampling filter requires at least one table")
+	errInvalidSamplingPercent = errors.New("sampling filter percentage must be between 0 and 100")
+	errInvalidSamplingMode    = errors.New("unsupported sampling filter mode")
+)
+
+func (sf *SamplingFilter) compile() (*samplingFilter, error) {
+	if len(sf.Tables) == 0 {
+		return nil, errMissingSamplingTables
+	}
+	if sf.Percentage < 0 || sf.Percentage > 100 {
+		return nil, fmt.Errorf("sampling filter %v: %w: %v", sf.Tables, errInvalidSamplingPercent, sf.Percentage)
+	}
+	mode := sf.Mode
+	switch mode {
+	case "":
+		mode = SamplingModeHash
+	case SamplingModeHash, SamplingModeRandom:
+	default:
+		return nil, fmt.Errorf("sampling filter %v: %w: %s", sf.Tables, errInvalidSamplingMode, mode)
+	}
+	tables, err := pglib.NewSchemaTableMap(sf.Tables)
+	if err != nil {
+		return nil, fmt.Errorf("sampling filter: %w", err)
+	}
+	return &samplingFilter{
+		tables:     tables,
+		mode:       mode,
+		keyColumns: sf.KeyColumns,
+		threshold:  uint64(math.Round(sf.Percentage / 100 * samplingBuckets)),
+	}, nil
+}
+
+// samples returns true if the event on input is part of the sample. Events
+// for which the key columns can't be found are part of the sample, and found
+// is false.
+func (f *samplingFilter) samples(data *wal.Data) (sampled, found bool) {
+	// 0 and 100 percentages don't need to identify the row
+	switch f.threshold {
+	case 0:
+		return false, true
+	case samplingBuckets:
+		return true, true
+	}
+
+	if f.mode == SamplingModeRandom {
+		return rand.Uint64N(samplingBuckets) < f.threshold, true
+	}
+
+	key := f.key(data)
+	if len(key) == 0 {
+		return true, false
+	}
+	h := fnv.New64a()
+	for _, col := range key {
+		// the separator prevents values from different columns from being
+		// hashed the same way, such as ("ab", "c") and ("a", "bc")
+		fmt.Fprintf(h, "%v\x00", col.Value)
+	}
+	return h.Sum64()%samplingBuckets < f.threshold, true
+}
+
+// key returns the columns identifying the row of the event on input. Delete
+// events only have the identity columns.
+func (f *samplingFilter) key(data *wal.Data) []wal.Column {
+	columns := data.Columns
+	if len(columns) == 0 {
+		columns = data.Identity
+	}
+
+	var key []wal.Column
+	if len(f.keyColumns) == 0 {
+		for _, col := range columns {
+			if slices.Contains(data.Metadata.InternalColIDs, col.ID) {
+				key = append(key, col)
+			}
+		}
+		return key
+	}
+
+	for _, name := range f.keyColumns {
+		col := findColumn(columns, name)
+		if col == nil {
+			return nil
+		}
+		key = append(key, *col)
+	}
+	return key
+}
@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestSamplingFilter_compile(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		filter SamplingFilter
+
+		wantMode      SamplingMode
+		wantThreshold uint64
+		wantErr       error
+	}{
+		{
+			name:          "ok - default mode",
+			filter:        SamplingFilter{Tables: []string{"public.clicks"}, Percentage: 1.5},
+			wantMode:      SamplingModeHash,
+			wantThreshold: 15_000,
+		},
+		{
+			name:          "ok - random mode",
+			filter:        SamplingFilter{Tables: []string{"public.clicks"}, Percentage: 0.07, Mode: SamplingModeRandom},
+			wantMode:      SamplingModeRandom,
+			wantThreshold: 700,
+		},
+		{
+			name:    "error - no tables",
+			filter:  SamplingFilter{Percentage: 1},
+			wantErr: errMissingSamplingTables,
+		},
+		{
+			name:    "error - invalid percentage",
+			filter:  SamplingFilter{Tables: []string{"public.clicks"}, Percentage: -1},
+			wantErr: errInvalidSamplingPercent,
+		},
+		{
+			name:    "error - invalid mode",
+			filter:  SamplingFilter{Tables: []string{"public.clicks"}, Percentage: 1, Mode: "first"},
+			wantErr: errInvalidSamplingMode,
+		},
+		{
+			name:    "error - invalid table pattern",
+			filter:  SamplingFilter{Tables: []string{"public.clicks_["}, Percentage: 1},
+			wantErr: pglib.ErrInvalidTablePattern,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			filter, err := tc.filter.compile()
+			require.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			require.Equal(t, tc.wantMode, filter.mode)
+			require.Equal(t, tc.wantThreshold, filter.threshold)
+		})
+	}
+}
+
+func TestSamplingFilter_samples(t *testing.T) {
+	t.Parallel()
+
+	newData := func(id int) *wal.Data {
+		return &wal.Data{
+			Action: "I",
+			Columns: []wal.Column{
+				{ID: "col_1", Name: "id", Value: id},
+				{ID: "col_2", Name: "url", Value: "/home"},
+			},
+			Metadata: wal.Metadata{InternalColIDs: []string{"col_1"}},
+		}
+	}
+
+	newFilter := func(percentage float64, mode SamplingMode, keyColumns ...string) *samplingFilter {
+		f, err := (&SamplingFilter{Tables: []string{"*"}, Percentage: percentage, Mode: mode, KeyColumns: keyColumns}).compile()
+		require.NoError(t, err)
+		return f
+	}
+
+	// countSampled returns the number of the first n rows that are sampled
+	countSampled := func(f *samplingFilter, n int) int {
+		count := 0
+		for id := range n {
+			sampled, found := f.samples(newData(id))
+			require.True(t, found)
+			if sampled {
+				count++
+			}
+		}
+		return count
+	}
+
+	t.Run("percentage short circuit", func(t *testing.T) {
+		t.Parallel()
+
+		// the key is not required to sample all or none of the rows
+		sampled, found := newFilter(0, SamplingModeHash).samples(&wal.Data{Action: "I"})
+		require.False(t, sampled)
+		require.True(t, found)
+
+		sampled, found = newFilter(100, SamplingModeHash).samples(&wal.Data{Action: "I"})
+		require.True(t, sampled)
+		require.True(t, found)
+	})
+
+	t.Run("hash mode is deterministic", func(t *testing.T) {
+		t.Parallel()
+
+		f := newFilter(10, SamplingModeHash)
+		count := countSampled(f, 10_000)
+		require.InDelta(t, 1_000, count, 150)
+		require.Equal(t, count, countSampled(f, 10_000))
+
+		// the key columns can be configured
+		require.Equal(t, count, countSampled(newFilter(10, SamplingModeHash, "id"), 10_000))
+	})
+
+	t.Run("random mode", func(t *testing.T) {
+		t.Parallel()
+
+		require.InDelta(t, 5_000, countSampled(newFilter(50, SamplingModeRandom), 10_000), 500)
+	})
+
+	t.Run("missing key columns", func(t *testing.T) {
+		t.Parallel()
+
+		sampled, found := newFilter(10, SamplingModeHash, "session_id").samples(newData(1))
+		require.True(t, sampled)
+		require.False(t, found)
+
+		sampled, found = newFilter(10, SamplingModeHash).samples(&wal.Data{Action: "I", Columns: []wal.Column{{Name: "id", Value: 1}}})
+		require.True(t, sampled)
+		require.False(t, found)
+	})
+
+	t.Run("delete events use the identity columns", func(t *testing.T) {
+		t.Parallel()
+
+		f := newFilter(50, SamplingModeHash, "id")
+		for id := range 100 {
+			insertSampled, _ := f.samples(newData(id))
+			deleteSampled, found := f.samples(&wal.Data{Action: "D", Identity: []wal.Column{{Name: "id", Value: id}}})
+			require.True(t, found)
+			require.Equal(t, insertSampled, deleteSampled)
+		}
+	})
+}
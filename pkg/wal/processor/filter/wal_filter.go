@@ -5,58 +5,117 @@ package filter
 import (
 	"context"
 	"errors"
+	"sync"
 
 	"github.com/xataio/pgstream/internal/json"
 	pglib "github.com/xataio/pgstream/internal/postgres"
 	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/schemalog"
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/processor"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 // Filter is a processor wrapper that filter table WAL events based on the
-// configured table include/exclude lists.
+// configured table include/exclude lists, action filters, changed columns
+// filters, sampling filters and row filter expressions.
 type Filter struct {
-	processor       processor.Processor
-	includeTableMap pglib.SchemaTableMap
-	excludeTableMap pglib.SchemaTableMap
-	logger          loglib.Logger
+	processor          processor.Processor
+	includeTableMap    pglib.SchemaTableMap
+	excludeTableMap    pglib.SchemaTableMap
+	actionFilter       *actionFilter
+	tableActionFilters []*tableActionFilter
+	changedColumns     []*changedColumnsFilter
+	sampling           []*samplingFilter
+	rowFilters         []*rowFilter
+	dropOnError        bool
+	logger             loglib.Logger
+	metrics            *metrics
+	// missingOldValuesTables keeps the tables for which the missing old values
+	// of the significant columns have already been reported, to only warn
+	// once per table
+	missingOldValuesTables sync.Map
+	// missingSamplingKeyTables keeps the tables for which the missing sampling
+	// key columns have already been reported, to only warn once per table
+	missingSamplingKeyTables sync.Map
+}
+
+type metrics struct {
+	rowFilterMatches metric.Int64Counter
+	rowFilterErrors  metric.Int64Counter
+	skippedActions   metric.Int64Counter
+	skippedUnchanged metric.Int64Counter
+	sampled          metric.Int64Counter
+	dropped          metric.Int64Counter
+	tables           *otel.TableAttributes
 }
 
 type Config struct {
 	// List of tables to allow. Tables should be schema qualified. If no schema
-	// is provided, the public schema will be assumed. Wildcards "*" are
-	// supported.
+	// is provided, the public schema will be assumed. Wildcards "*", glob
+	// patterns (public.data_tenant_*) and regular expressions matched against
+	// the qualified table name (^public\.data_tenant_\d+$) are supported.
 	IncludeTables []string
-	// List of tables to skip. Tables should be schema qualified. If no schema
-	// is provided, the public schema will be assumed. Wildcards "*" are
-	// supported.
+	// List of tables to skip. It takes precedence over the include list when
+	// a table matches both. Same format as the include list.
 	ExcludeTables []string
+	// Actions is the default action filter for the data events of the tables
+	// that are not filtered by the include/exclude lists.
+	Actions *ActionFilter
+	// TableActions override the default action filter for the configured
+	// tables. When a table matches more than one of them, the first one
+	// applies.
+	TableActions []TableActionFilter
+	// ChangedColumns skip the updates of the configured tables that don't
+	// change any of their significant columns. When a table matches more than
+	// one of them, the first one applies.
+	ChangedColumns []ChangedColumnsFilter
+	// Sampling only forwards a percentage of the rows of the configured
+	// tables. When a table matches more than one of them, the first one
+	// applies.
+	Sampling []SamplingFilter
+	// RowFilters are evaluated in order for the data events of the tables
+	// that are not filtered by the include/exclude lists. An event is skipped
+	// as soon as one of them rejects it.
+	RowFilters []RowFilter
+	// RowFilterErrorPolicy defines what happens to the events for which a
+	// row filter evaluation fails. Defaults to pass.
+	RowFilterErrorPolicy RowFilterErrorPolicy
 }
 
 type Option func(*Filter)
 
-var (
-	errIncludeExcludeList     = errors.New("cannot use both include and exclude lists for table filtering")
-	errMissingFilteringConfig = errors.New("missing filtering configuration")
+var errMissingFilteringConfig = errors.New("missing filtering configuration")
+
+const (
+	rowFilterAttributeKey = "row_filter"
+	schemaAttributeKey    = otel.SchemaAttributeKey
+	tableAttributeKey     = otel.TableAttributeKey
+	actionAttributeKey    = "action"
 )
 
 // New will return a filter processor wrapper that will skip WAL events as per
-// the configuration provided. Only one of include or exclude table list can be
-// provided (not both).
+// the configuration provided. The table patterns and row filter expressions
+// are compiled on creation, and an error is returned if any of them is
+// invalid.
 func New(
 	processor processor.Processor, cfg *Config, opts ...Option,
 ) (*Filter, error) {
-	if len(cfg.ExcludeTables) > 0 && len(cfg.IncludeTables) > 0 {
-		return nil, errIncludeExcludeList
-	}
-	if len(cfg.ExcludeTables) == 0 && len(cfg.IncludeTables) == 0 {
+	if len(cfg.ExcludeTables) == 0 && len(cfg.IncludeTables) == 0 && len(cfg.RowFilters) == 0 &&
+		cfg.Actions == nil && len(cfg.TableActions) == 0 && len(cfg.ChangedColumns) == 0 && len(cfg.Sampling) == 0 {
 		return nil, errMissingFilteringConfig
 	}
+	if err := cfg.RowFilterErrorPolicy.validate(); err != nil {
+		return nil, err
+	}
 
 	f := &Filter{
-		processor: processor,
-		logger:    loglib.NewNoopLogger(),
+		processor:   processor,
+		logger:      loglib.NewNoopLogger(),
+		dropOnError: cfg.RowFilterErrorPolicy == RowFilterErrorDrop,
 	}
 
 	var err error
@@ -74,6 +133,45 @@ func New(
 		}
 	}
 
+	if cfg.Actions != nil {
+		f.actionFilter, err = cfg.Actions.compile()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range cfg.TableActions {
+		tableActionFilter, err := cfg.TableActions[i].compile()
+		if err != nil {
+			return nil, err
+		}
+		f.tableActionFilters = append(f.tableActionFilters, tableActionFilter)
+	}
+
+	for i := range cfg.ChangedColumns {
+		changedColumnsFilter, err := cfg.ChangedColumns[i].compile()
+		if err != nil {
+			return nil, err
+		}
+		f.changedColumns = append(f.changedColumns, changedColumnsFilter)
+	}
+
+	for i := range cfg.Sampling {
+		samplingFilter, err := cfg.Sampling[i].compile()
+		if err != nil {
+			return nil, err
+		}
+		f.sampling = append(f.sampling, samplingFilter)
+	}
+
+	for i := range cfg.RowFilters {
+		rowFilter, err := cfg.RowFilters[i].compile()
+		if err != nil {
+			return nil, err
+		}
+		f.rowFilters = append(f.rowFilters, rowFilter)
+	}
+
 	for _, opt := range opts {
 		opt(f)
 	}
@@ -89,13 +187,31 @@ func WithLogger(logger loglib.Logger) Option {
 	}
 }
 
-// WithDefaultIncludeTables will use the tables on input as default include
-// table list or add them to the configured one if provided. If the filter has
-// an exclude table list configured, the default include table list won't apply,
-// since only one can be provided.
+// WithInstrumentation exports the number of events matched by each row
+// filter, the number of row filter evaluation errors, the number of events
+// skipped by the action filters per table and action, the number of updates
+// skipped by the changed columns filters per table, and the number of events
+// sampled and dropped by the sampling filters per table.
+func WithInstrumentation(instrumentation *otel.Instrumentation) Option {
+	return func(f *Filter) {
+		if instrumentation == nil || instrumentation.Meter == nil {
+			return
+		}
+		if err := f.initMetrics(instrumentation.Meter); err != nil {
+			// should never happen
+			panic(err)
+		}
+		f.metrics.tables = instrumentation.Tables
+	}
+}
+
+// WithDefaultIncludeTables will add the tables on input to the configured
+// include table list. If the filter has an exclude table list configured, or
+// only filters rows, the default include table list won't apply, since it
+// would skip all the other tables.
 func WithDefaultIncludeTables(tables []string) Option {
 	return func(f *Filter) {
-		if len(f.excludeTableMap) > 0 {
+		if len(f.includeTableMap) == 0 {
 			return
 		}
 		for _, table := range tables {
@@ -116,7 +232,27 @@ func (f *Filter) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
 		f.updateSchemaEvent(event)
 	default:
 		// data events
-		if f.skipEvent(event) {
+		if f.skipTable(event.Data) {
+			f.logger.Trace("skipping event", loglib.Fields{"schema": event.Data.Schema, "table": event.Data.Table})
+			return nil
+		}
+		// the action filters are checked before the row filters, since they
+		// don't require evaluating any expression
+		if f.skipAction(ctx, event.Data) {
+			f.logger.Trace("skipping event action", loglib.Fields{"schema": event.Data.Schema, "table": event.Data.Table, "action": event.Data.Action})
+			// only send the commit position along, so that the skipped event
+			// is still checkpointed
+			return f.processor.ProcessWALEvent(ctx, &wal.Event{CommitPosition: event.CommitPosition})
+		}
+		if f.skipUnchanged(ctx, event.Data) {
+			f.logger.Trace("skipping unchanged update", loglib.Fields{"schema": event.Data.Schema, "table": event.Data.Table})
+			return f.processor.ProcessWALEvent(ctx, &wal.Event{CommitPosition: event.CommitPosition})
+		}
+		if f.skipSample(ctx, event.Data) {
+			f.logger.Trace("skipping event not sampled", loglib.Fields{"schema": event.Data.Schema, "table": event.Data.Table})
+			return f.processor.ProcessWALEvent(ctx, &wal.Event{CommitPosition: event.CommitPosition})
+		}
+		if f.skipRow(ctx, event.Data) {
 			f.logger.Trace("skipping event", loglib.Fields{"schema": event.Data.Schema, "table": event.Data.Table})
 			return nil
 		}
@@ -133,18 +269,223 @@ func (f *Filter) Close() error {
 	return f.processor.Close()
 }
 
-// skip event for table if it's not in the include table list or if it's in the exclude one
-func (f *Filter) skipEvent(event *wal.Event) bool {
-	if len(f.includeTableMap) > 0 {
-		return !f.includeTableMap.ContainsSchemaTable(event.Data.Schema, event.Data.Table)
+func (f *Filter) Ping(ctx context.Context) error {
+	return f.processor.Ping(ctx)
+}
+
+func (f *Filter) skipTable(data *wal.Data) bool {
+	return f.filtersTable(data.Schema, data.Table)
+}
+
+// filtersTable returns true if the table is not in the include table list, or
+// if it's in the exclude one.
+func (f *Filter) filtersTable(schema, table string) bool {
+	if len(f.includeTableMap) > 0 && !f.includeTableMap.ContainsSchemaTable(schema, table) {
+		return true
+	}
+	if len(f.excludeTableMap) > 0 && f.excludeTableMap.ContainsSchemaTable(schema, table) {
+		return true
+	}
+	return false
+}
+
+// skipAction returns true if the event action is filtered out by the first
+// table action filter that applies to the event table, or by the default
+// action filter if none does.
+func (f *Filter) skipAction(ctx context.Context, data *wal.Data) bool {
+	filter := f.actionFilter
+	for _, tableFilter := range f.tableActionFilters {
+		if tableFilter.tables.ContainsSchemaTable(data.Schema, data.Table) {
+			filter = tableFilter.filter
+			break
+		}
+	}
+	// commit events are not table operations
+	if filter == nil || wal.Action(data.Action) == wal.ActionCommit || !filter.skips(wal.Action(data.Action)) {
+		return false
+	}
+
+	if f.metrics != nil {
+		f.metrics.skippedActions.Add(ctx, 1,
+			metric.WithAttributes(f.metrics.tables.Get(data.Schema, data.Table)...),
+			metric.WithAttributes(attribute.String(actionAttributeKey, data.Action)),
+		)
+	}
+	return true
+}
+
+// skipUnchanged returns true if the event is an update that doesn't change
+// any of the significant columns of the first changed columns filter that
+// applies to the event table.
+func (f *Filter) skipUnchanged(ctx context.Context, data *wal.Data) bool {
+	if len(f.changedColumns) == 0 || !data.IsUpdate() {
+		return false
+	}
+
+	for _, filter := range f.changedColumns {
+		if !filter.tables.ContainsSchemaTable(data.Schema, data.Table) {
+			continue
+		}
+
+		unchanged, missingOldValues := filter.unchanged(data)
+		if len(missingOldValues) > 0 {
+			table := pglib.QuoteQualifiedIdentifier(data.Schema, data.Table)
+			if _, warned := f.missingOldValuesTables.LoadOrStore(table, struct{}{}); !warned {
+				f.logger.Warn(nil, "missing old values for significant columns, forwarding updates. The table replica identity should be set to full", loglib.Fields{
+					"schema":  data.Schema,
+					"table":   data.Table,
+					"columns": missingOldValues,
+				})
+			}
+		}
+		if !unchanged {
+			return false
+		}
+
+		if f.metrics != nil {
+			f.metrics.skippedUnchanged.Add(ctx, 1, metric.WithAttributes(f.metrics.tables.Get(data.Schema, data.Table)...))
+		}
+		return true
+	}
+	return false
+}
+
+// skipSample returns true if the event is a row change that isn't part of the
+// sample of the first sampling filter that applies to the event table.
+func (f *Filter) skipSample(ctx context.Context, data *wal.Data) bool {
+	if len(f.sampling) == 0 {
+		return false
 	}
-	if len(f.excludeTableMap) > 0 {
-		return f.excludeTableMap.ContainsSchemaTable(event.Data.Schema, event.Data.Table)
+	switch wal.Action(data.Action) {
+	case wal.ActionInsert, wal.ActionUpdate, wal.ActionDelete:
+	default:
+		// truncates and commits are not row changes
+		return false
 	}
 
+	for _, filter := range f.sampling {
+		if !filter.tables.ContainsSchemaTable(data.Schema, data.Table) {
+			continue
+		}
+
+		sampled, found := filter.samples(data)
+		if !found {
+			table := pglib.QuoteQualifiedIdentifier(data.Schema, data.Table)
+			if _, warned := f.missingSamplingKeyTables.LoadOrStore(table, struct{}{}); !warned {
+				f.logger.Warn(nil, "missing sampling key columns, forwarding events. The key columns should be configured, or the injector enabled", loglib.Fields{
+					"schema":      data.Schema,
+					"table":       data.Table,
+					"key_columns": filter.keyColumns,
+				})
+			}
+		}
+
+		if f.metrics != nil {
+			counter := f.metrics.sampled
+			if !sampled {
+				counter = f.metrics.dropped
+			}
+			counter.Add(ctx, 1, metric.WithAttributes(f.metrics.tables.Get(data.Schema, data.Table)...))
+		}
+		return !sampled
+	}
 	return false
 }
 
+// skipRow evaluates the row filters that apply to the event table, and
+// returns true as soon as one of them rejects the event.
+func (f *Filter) skipRow(ctx context.Context, data *wal.Data) bool {
+	// commit events are not table operations
+	if len(f.rowFilters) == 0 || wal.Action(data.Action) == wal.ActionCommit {
+		return false
+	}
+
+	var env *rowFilterEnv
+	for _, rowFilter := range f.rowFilters {
+		if !rowFilter.appliesTo(data) {
+			continue
+		}
+		if env == nil {
+			env = newRowFilterEnv(data)
+		}
+
+		matched, err := rowFilter.matches(env)
+		if err != nil {
+			f.logger.Debug("row filter evaluation failed", loglib.Fields{
+				"row_filter":  rowFilter.name,
+				"schema":      data.Schema,
+				"table":       data.Table,
+				"error":       err.Error(),
+				"drop_events": f.dropOnError,
+			})
+			if f.metrics != nil {
+				f.metrics.rowFilterErrors.Add(ctx, 1, metric.WithAttributes(attribute.String(rowFilterAttributeKey, rowFilter.name)))
+			}
+			if f.dropOnError {
+				return true
+			}
+			continue
+		}
+
+		if matched && f.metrics != nil {
+			f.metrics.rowFilterMatches.Add(ctx, 1, metric.WithAttributes(attribute.String(rowFilterAttributeKey, rowFilter.name)))
+		}
+		if matched == rowFilter.exclude {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Filter) initMetrics(meter metric.Meter) error {
+	f.metrics = &metrics{}
+
+	var err error
+	f.metrics.rowFilterMatches, err = meter.Int64Counter("pgstream.filter.row_filter.matches",
+		metric.WithUnit("events"),
+		metric.WithDescription("Number of wal events for which the row filter expression evaluated to true"))
+	if err != nil {
+		return err
+	}
+
+	f.metrics.rowFilterErrors, err = meter.Int64Counter("pgstream.filter.row_filter.errors",
+		metric.WithUnit("errors"),
+		metric.WithDescription("Number of wal events for which the row filter expression evaluation failed"))
+	if err != nil {
+		return err
+	}
+
+	f.metrics.skippedActions, err = meter.Int64Counter("pgstream.filter.action.skipped",
+		metric.WithUnit("events"),
+		metric.WithDescription("Number of wal events skipped by the action filters"))
+	if err != nil {
+		return err
+	}
+
+	f.metrics.skippedUnchanged, err = meter.Int64Counter("pgstream.filter.changed_columns.skipped",
+		metric.WithUnit("events"),
+		metric.WithDescription("Number of update wal events skipped by the changed columns filters"))
+	if err != nil {
+		return err
+	}
+
+	f.metrics.sampled, err = meter.Int64Counter("pgstream.filter.sampling.sampled",
+		metric.WithUnit("events"),
+		metric.WithDescription("Number of wal events forwarded by the sampling filters"))
+	if err != nil {
+		return err
+	}
+
+	f.metrics.dropped, err = meter.Int64Counter("pgstream.filter.sampling.dropped",
+		metric.WithUnit("events"),
+		metric.WithDescription("Number of wal events skipped by the sampling filters"))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (f *Filter) updateSchemaEvent(event *wal.Event) {
 	if !event.Data.IsInsert() {
 		// we only care about insert actions for schema log events
@@ -196,15 +537,8 @@ func (f *Filter) filterTablesFromSchema(schemaName string, schema *schemalog.Sch
 	}
 	filteredTables := make([]schemalog.Table, 0, len(schema.Tables))
 	for _, table := range schema.Tables {
-		switch {
-		case len(f.includeTableMap) != 0:
-			if f.includeTableMap.ContainsSchemaTable(schemaName, table.Name) {
-				filteredTables = append(filteredTables, table)
-			}
-		case len(f.excludeTableMap) != 0:
-			if !f.excludeTableMap.ContainsSchemaTable(schemaName, table.Name) {
-				filteredTables = append(filteredTables, table)
-			}
+		if !f.filtersTable(schemaName, table.Name) {
+			filteredTables = append(filteredTables, table)
 		}
 	}
 
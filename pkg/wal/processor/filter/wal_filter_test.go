@@ -5,6 +5,7 @@ package filter
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -12,9 +13,13 @@ import (
 	"github.com/stretchr/testify/require"
 	pglib "github.com/xataio/pgstream/internal/postgres"
 	"github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/schemalog"
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 )
 
 func Test_New(t *testing.T) {
@@ -67,10 +72,35 @@ func Test_New(t *testing.T) {
 		{
 			name: "both included and excluded configured",
 			config: &Config{
-				IncludeTables: []string{"public.users"},
-				ExcludeTables: []string{"public.orders"},
+				IncludeTables: []string{"public.data_tenant_*"},
+				ExcludeTables: []string{`^public\.data_tenant_0\d*$`},
 			},
-			wantErr: errIncludeExcludeList,
+			wantIncluded: pglib.SchemaTableMap{
+				"public": map[string]struct{}{
+					"data_tenant_*": {},
+					"default":       {},
+				},
+			},
+			wantExcluded: pglib.SchemaTableMap{
+				"*": map[string]struct{}{
+					`^public\.data_tenant_0\d*$`: {},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "invalid table pattern in included",
+			config: &Config{
+				IncludeTables: []string{"public.data_[tenant"},
+			},
+			wantErr: pglib.ErrInvalidTablePattern,
+		},
+		{
+			name: "invalid table pattern in excluded",
+			config: &Config{
+				ExcludeTables: []string{`^public\.(data`},
+			},
+			wantErr: pglib.ErrInvalidTablePattern,
 		},
 		{
 			name: "invalid table name in included",
@@ -91,6 +121,87 @@ func Test_New(t *testing.T) {
 			config:  &Config{},
 			wantErr: errMissingFilteringConfig,
 		},
+		{
+			name: "valid row filters configuration",
+			config: &Config{
+				RowFilters:           []RowFilter{{Expression: `new.status != "draft"`}},
+				RowFilterErrorPolicy: RowFilterErrorDrop,
+			},
+			// the default include tables don't apply without include list
+			wantErr: nil,
+		},
+		{
+			name: "invalid row filter expression",
+			config: &Config{
+				RowFilters: []RowFilter{{Expression: `new.status ==`}},
+			},
+			wantErr: errInvalidRowFilterExpression,
+		},
+		{
+			name: "empty row filter expression",
+			config: &Config{
+				RowFilters: []RowFilter{{Name: "empty"}},
+			},
+			wantErr: errMissingRowFilterExpression,
+		},
+		{
+			name: "invalid row filter error policy",
+			config: &Config{
+				RowFilters:           []RowFilter{{Expression: "true"}},
+				RowFilterErrorPolicy: "ignore",
+			},
+			wantErr: errInvalidRowFilterErrorPolicy,
+		},
+		{
+			name: "valid action filters configuration",
+			config: &Config{
+				Actions:      &ActionFilter{Deny: []wal.Action{wal.ActionTruncate}},
+				TableActions: []TableActionFilter{{Tables: []string{"analytics.*"}, Actions: ActionFilter{Allow: []wal.Action{wal.ActionInsert}}}},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "invalid action filter",
+			config: &Config{
+				Actions: &ActionFilter{Deny: []wal.Action{"X"}},
+			},
+			wantErr: errUnsupportedAction,
+		},
+		{
+			name: "invalid table action filter",
+			config: &Config{
+				TableActions: []TableActionFilter{{Actions: ActionFilter{Deny: []wal.Action{wal.ActionDelete}}}},
+			},
+			wantErr: errMissingFilterTables,
+		},
+		{
+			name: "valid changed columns configuration",
+			config: &Config{
+				ChangedColumns: []ChangedColumnsFilter{{Tables: []string{"public.users"}, Columns: []string{"email"}}},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "invalid changed columns filter",
+			config: &Config{
+				ChangedColumns: []ChangedColumnsFilter{{Tables: []string{"public.users"}}},
+			},
+			wantErr: errMissingChangedColumnsColumns,
+		},
+		{
+			name: "valid sampling configuration",
+			config: &Config{
+				Sampling: []SamplingFilter{{Tables: []string{"public.clicks"}, Percentage: 1}},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "invalid sampling filter",
+			config: &Config{
+				Sampling: []SamplingFilter{{Tables: []string{"public.clicks"}, Percentage: 101}},
+			},
+			wantErr: errInvalidSamplingPercent,
+		},
 	}
 
 	for _, tc := range tests {
@@ -392,6 +503,47 @@ func TestFilter_ProcessWALEvent(t *testing.T) {
 			wantProcessCalls: 1,
 			wantErr:          nil,
 		},
+		{
+			name:  "event matches included pattern",
+			event: testEvent,
+			processor: &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					require.Equal(t, testEvent, walEvent)
+					return nil
+				},
+			},
+			included: pglib.SchemaTableMap{
+				testSchema: {
+					"test_*": {},
+				},
+			},
+			excluded: nil,
+
+			wantProcessCalls: 1,
+			wantErr:          nil,
+		},
+		{
+			name:  "event matches included and excluded patterns",
+			event: testEvent,
+			processor: &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					return errors.New("ProcessWALEventFn: should not be called")
+				},
+			},
+			included: pglib.SchemaTableMap{
+				testSchema: {
+					"test_*": {},
+				},
+			},
+			excluded: pglib.SchemaTableMap{
+				"*": {
+					`^test_schema\.test_tab.+$`: {},
+				},
+			},
+
+			wantProcessCalls: 0,
+			wantErr:          nil,
+		},
 	}
 
 	for _, tc := range tests {
@@ -506,3 +658,322 @@ func TestFilter_filterTablesFromSchema(t *testing.T) {
 		})
 	}
 }
+
+func TestFilter_ProcessWALEvent_rowFilters(t *testing.T) {
+	t.Parallel()
+
+	newEvent := func(action, table string, columns, identity []wal.Column) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action:   action,
+				Schema:   "public",
+				Table:    table,
+				Columns:  columns,
+				Identity: identity,
+			},
+		}
+	}
+	draftPost := newEvent("I", "posts", []wal.Column{{Name: "status", Value: "draft"}}, nil)
+	publishedPost := newEvent("I", "posts", []wal.Column{{Name: "status", Value: "published"}}, nil)
+	positiveDelete := newEvent("D", "payments", nil, []wal.Column{{Name: "amount", Value: float64(10)}})
+	zeroDelete := newEvent("D", "payments", nil, []wal.Column{{Name: "amount", Value: float64(0)}})
+	paymentInsert := newEvent("I", "payments", []wal.Column{{Name: "amount", Value: float64(10)}}, nil)
+	// the default replica identity only includes the primary key
+	deleteWithoutAmount := newEvent("D", "payments", nil, []wal.Column{{Name: "id", Value: float64(1)}})
+	commit := &wal.Event{Data: &wal.Data{Action: "C", XID: 1}}
+
+	rowFilters := []RowFilter{
+		{Name: "drafts", Expression: `new.status == "draft"`, Exclude: true},
+		{Name: "positive_deletes", Tables: []string{"public.payments"}, Expression: `action == "D" && old.amount > 0`},
+	}
+
+	tests := []struct {
+		name        string
+		errorPolicy RowFilterErrorPolicy
+
+		wantProcessed []*wal.Event
+		wantMatches   map[string]int64
+		wantErrors    map[string]int64
+	}{
+		{
+			name:          "pass on error",
+			errorPolicy:   RowFilterErrorPass,
+			wantProcessed: []*wal.Event{publishedPost, positiveDelete, deleteWithoutAmount, commit},
+			wantMatches:   map[string]int64{"drafts": 1, "positive_deletes": 1},
+			wantErrors:    map[string]int64{"positive_deletes": 1},
+		},
+		{
+			name:          "drop on error",
+			errorPolicy:   RowFilterErrorDrop,
+			wantProcessed: []*wal.Event{publishedPost, positiveDelete, commit},
+			wantMatches:   map[string]int64{"drafts": 1, "positive_deletes": 1},
+			wantErrors:    map[string]int64{"positive_deletes": 1},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			processed := []*wal.Event{}
+			reader := sdkmetric.NewManualReader()
+			meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+			f, err := New(&mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+					processed = append(processed, event)
+					return nil
+				},
+			}, &Config{
+				RowFilters:           rowFilters,
+				RowFilterErrorPolicy: tc.errorPolicy,
+			}, WithInstrumentation(&otel.Instrumentation{Meter: meterProvider.Meter("test")}))
+			require.NoError(t, err)
+
+			for _, event := range []*wal.Event{draftPost, publishedPost, positiveDelete, zeroDelete, paymentInsert, deleteWithoutAmount, commit} {
+				require.NoError(t, f.ProcessWALEvent(context.Background(), event))
+			}
+			require.Equal(t, tc.wantProcessed, processed)
+
+			rm := metricdata.ResourceMetrics{}
+			require.NoError(t, reader.Collect(context.Background(), &rm))
+			counts := map[string]map[string]int64{}
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					counts[m.Name] = map[string]int64{}
+					for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+						name, _ := dp.Attributes.Value(attribute.Key(rowFilterAttributeKey))
+						counts[m.Name][name.AsString()] = dp.Value
+					}
+				}
+			}
+			require.Equal(t, tc.wantMatches, counts["pgstream.filter.row_filter.matches"])
+			require.Equal(t, tc.wantErrors, counts["pgstream.filter.row_filter.errors"])
+		})
+	}
+}
+
+func TestFilter_ProcessWALEvent_actionFilters(t *testing.T) {
+	t.Parallel()
+
+	newEvent := func(action, schema, table, position string) *wal.Event {
+		return &wal.Event{
+			Data:           &wal.Data{Action: action, Schema: schema, Table: table},
+			CommitPosition: wal.CommitPosition(position),
+		}
+	}
+	eventsInsert := newEvent("I", "analytics", "events", "0/1")
+	eventsDelete := newEvent("D", "analytics", "events", "0/2")
+	eventsTruncate := newEvent("T", "analytics", "events", "0/3")
+	ordersDelete := newEvent("D", "public", "orders", "0/4")
+	ordersTruncate := newEvent("T", "public", "orders", "0/5")
+	usersDelete := newEvent("D", "public", "users", "0/6")
+	excludedDelete := newEvent("D", "public", "excluded", "0/7")
+	commit := newEvent("C", "", "", "0/8")
+
+	processed := []*wal.Event{}
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	f, err := New(&mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+			processed = append(processed, event)
+			return nil
+		},
+	}, &Config{
+		ExcludeTables: []string{"public.excluded"},
+		Actions:       &ActionFilter{Deny: []wal.Action{wal.ActionTruncate}},
+		TableActions: []TableActionFilter{
+			// append only target
+			{Tables: []string{"analytics.*"}, Actions: ActionFilter{Allow: []wal.Action{wal.ActionInsert}}},
+			// forward everything
+			{Tables: []string{"public.orders"}, Actions: ActionFilter{Allow: []wal.Action{"I", "U", "D", "T"}}},
+			{Tables: []string{"public.users"}, Actions: ActionFilter{Deny: []wal.Action{wal.ActionDelete}}},
+		},
+	}, WithInstrumentation(&otel.Instrumentation{Meter: meterProvider.Meter("test")}))
+	require.NoError(t, err)
+
+	for _, event := range []*wal.Event{eventsInsert, eventsDelete, eventsTruncate, ordersDelete, ordersTruncate, usersDelete, excludedDelete, commit} {
+		require.NoError(t, f.ProcessWALEvent(context.Background(), event))
+	}
+
+	// the events skipped by the action filters are sent without data, so that
+	// their position is checkpointed, while the excluded tables are dropped
+	require.Equal(t, []*wal.Event{
+		eventsInsert,
+		{CommitPosition: eventsDelete.CommitPosition},
+		{CommitPosition: eventsTruncate.CommitPosition},
+		ordersDelete,
+		ordersTruncate,
+		{CommitPosition: usersDelete.CommitPosition},
+		commit,
+	}, processed)
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	counts := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != "pgstream.filter.action.skipped" {
+				continue
+			}
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				schema, _ := dp.Attributes.Value(attribute.Key(schemaAttributeKey))
+				table, _ := dp.Attributes.Value(attribute.Key(tableAttributeKey))
+				action, _ := dp.Attributes.Value(attribute.Key(actionAttributeKey))
+				counts[schema.AsString()+"."+table.AsString()+":"+action.AsString()] = dp.Value
+			}
+		}
+	}
+	require.Equal(t, map[string]int64{
+		"analytics.events:D": 1,
+		"analytics.events:T": 1,
+		"public.users:D":     1,
+	}, counts)
+}
+
+func TestFilter_ProcessWALEvent_changedColumns(t *testing.T) {
+	t.Parallel()
+
+	newUpdate := func(table, position string, oldValues, newValues map[string]any) *wal.Event {
+		data := &wal.Data{Action: "U", Schema: "public", Table: table}
+		for _, name := range []string{"id", "email", "last_seen"} {
+			if v, found := oldValues[name]; found {
+				data.Identity = append(data.Identity, wal.Column{Name: name, Type: "text", Value: v})
+			}
+			if v, found := newValues[name]; found {
+				data.Columns = append(data.Columns, wal.Column{Name: name, Type: "text", Value: v})
+			}
+		}
+		return &wal.Event{Data: data, CommitPosition: wal.CommitPosition(position)}
+	}
+	onlyLastSeen := newUpdate("users", "0/1",
+		map[string]any{"id": "1", "email": "a@example.com", "last_seen": "monday"},
+		map[string]any{"id": "1", "email": "a@example.com", "last_seen": "tuesday"})
+	emailChanged := newUpdate("users", "0/2",
+		map[string]any{"id": "1", "email": "a@example.com", "last_seen": "monday"},
+		map[string]any{"id": "1", "email": "b@example.com", "last_seen": "monday"})
+	missingOldValues := newUpdate("users", "0/3",
+		map[string]any{"id": "1"},
+		map[string]any{"id": "1", "email": "a@example.com", "last_seen": "tuesday"})
+	otherTable := newUpdate("orders", "0/4",
+		map[string]any{"id": "1", "email": "a@example.com"},
+		map[string]any{"id": "1", "email": "a@example.com"})
+	insert := &wal.Event{
+		Data:           &wal.Data{Action: "I", Schema: "public", Table: "users", Columns: []wal.Column{{Name: "email", Value: "a@example.com"}}},
+		CommitPosition: "0/5",
+	}
+
+	processed := []*wal.Event{}
+	f, err := New(&mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+			processed = append(processed, event)
+			return nil
+		},
+	}, &Config{
+		ChangedColumns: []ChangedColumnsFilter{
+			{Tables: []string{"public.users"}, Columns: []string{"email"}},
+		},
+	})
+	require.NoError(t, err)
+
+	for _, event := range []*wal.Event{onlyLastSeen, emailChanged, missingOldValues, missingOldValues, otherTable, insert} {
+		require.NoError(t, f.ProcessWALEvent(context.Background(), event))
+	}
+
+	// the unchanged updates are sent without data, so that their position is
+	// checkpointed
+	require.Equal(t, []*wal.Event{
+		{CommitPosition: onlyLastSeen.CommitPosition},
+		emailChanged,
+		missingOldValues,
+		missingOldValues,
+		otherTable,
+		insert,
+	}, processed)
+
+	_, warned := f.missingOldValuesTables.Load(`"public"."users"`)
+	require.True(t, warned)
+}
+
+func TestFilter_ProcessWALEvent_sampling(t *testing.T) {
+	t.Parallel()
+
+	newEvent := func(action wal.Action, table string, id int) *wal.Event {
+		data := &wal.Data{Action: string(action), Schema: "public", Table: table}
+		columns := []wal.Column{{Name: "id", Type: "integer", Value: id}}
+		if action == wal.ActionDelete {
+			data.Identity = columns
+		} else {
+			data.Columns = columns
+		}
+		return &wal.Event{Data: data, CommitPosition: wal.CommitPosition(fmt.Sprintf("0/%d", id))}
+	}
+
+	processed := []*wal.Event{}
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	f, err := New(&mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+			processed = append(processed, event)
+			return nil
+		},
+	}, &Config{
+		Sampling: []SamplingFilter{
+			{Tables: []string{"public.clicks"}, Percentage: 50, KeyColumns: []string{"id"}},
+			{Tables: []string{"public.*"}, Percentage: 0},
+		},
+	}, WithInstrumentation(&otel.Instrumentation{Meter: meterProvider.Meter("test")}))
+	require.NoError(t, err)
+
+	sampledIDs := map[int]bool{}
+	sampledCount := 0
+	for id := range 100 {
+		sampled, found := f.sampling[0].samples(newEvent(wal.ActionInsert, "clicks", id).Data)
+		require.True(t, found)
+		sampledIDs[id] = sampled
+		if sampled {
+			sampledCount++
+		}
+	}
+	require.NotZero(t, sampledCount)
+	require.NotEqual(t, 100, sampledCount)
+
+	for id := range 100 {
+		for _, action := range []wal.Action{wal.ActionInsert, wal.ActionUpdate, wal.ActionDelete} {
+			processed = processed[:0]
+			event := newEvent(action, "clicks", id)
+			require.NoError(t, f.ProcessWALEvent(context.Background(), event))
+			// all the events of a row are either sampled or not, and the
+			// skipped ones are sent without data to be checkpointed
+			if sampledIDs[id] {
+				require.Equal(t, []*wal.Event{event}, processed)
+			} else {
+				require.Equal(t, []*wal.Event{{CommitPosition: event.CommitPosition}}, processed)
+			}
+		}
+	}
+
+	processed = processed[:0]
+	truncate := &wal.Event{Data: &wal.Data{Action: "T", Schema: "public", Table: "users"}, CommitPosition: "0/1"}
+	insert := newEvent(wal.ActionInsert, "users", 1)
+	for _, event := range []*wal.Event{truncate, insert} {
+		require.NoError(t, f.ProcessWALEvent(context.Background(), event))
+	}
+	require.Equal(t, []*wal.Event{truncate, {CommitPosition: insert.CommitPosition}}, processed)
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	counts := map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				table, _ := dp.Attributes.Value(attribute.Key(tableAttributeKey))
+				counts[m.Name+":"+table.AsString()] = dp.Value
+			}
+		}
+	}
+	require.Equal(t, map[string]int64{
+		"pgstream.filter.sampling.sampled:clicks": int64(3 * sampledCount),
+		"pgstream.filter.sampling.dropped:clicks": int64(3 * (100 - sampledCount)),
+		"pgstream.filter.sampling.dropped:users":  1,
+	}, counts)
+}
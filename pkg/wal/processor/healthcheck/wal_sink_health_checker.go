@@ -0,0 +1,243 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// SinkHealthChecker is a decorator around a wal processor that makes sure the
+// sink the processor writes to is reachable before sending it any events. The
+// sink is pinged at startup, and then on the configured interval. While the
+// sink is unreachable, the processing of the wal events blocks, which stops the
+// wal consumption until it recovers, instead of failing or retrying each event.
+type SinkHealthChecker struct {
+	logger    loglib.Logger
+	processor processor.Processor
+
+	interval       time.Duration
+	maxStartupWait time.Duration
+	// pauseHandler is notified when the processing is paused or resumed
+	pauseHandler func(paused bool)
+
+	// mutex guards the health state
+	mutex     sync.Mutex
+	healthy   bool
+	downSince time.Time
+	recovered chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type Config struct {
+	// Interval is the interval at which the sink is pinged. It's also used as
+	// the timeout of each ping. Defaults to 10s.
+	Interval time.Duration
+	// MaxStartupWait is the maximum time to wait for the sink to be reachable
+	// at startup, after which an error is returned. If not set, the startup
+	// waits until the sink is reachable or the context is cancelled.
+	MaxStartupWait time.Duration
+}
+
+type Option func(h *SinkHealthChecker)
+
+const defaultInterval = 10 * time.Second
+
+var ErrSinkUnreachable = errors.New("sink unreachable")
+
+// New will return a sink health checker wrapper around the processor on
+// input. It blocks until the sink is reachable, or the configured max startup
+// wait is exceeded, and keeps pinging the sink on the configured interval
+// until closed.
+func New(ctx context.Context, cfg *Config, p processor.Processor, opts ...Option) (*SinkHealthChecker, error) {
+	h := newSinkHealthChecker(cfg, p)
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	if err := h.waitForSink(ctx); err != nil {
+		return nil, err
+	}
+
+	monitorCtx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		h.monitorSink(monitorCtx)
+	}()
+
+	return h, nil
+}
+
+func newSinkHealthChecker(cfg *Config, p processor.Processor) *SinkHealthChecker {
+	return &SinkHealthChecker{
+		logger:         loglib.NewNoopLogger(),
+		processor:      p,
+		interval:       cfg.interval(),
+		maxStartupWait: cfg.MaxStartupWait,
+		pauseHandler:   func(bool) {},
+		healthy:        true,
+	}
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(h *SinkHealthChecker) {
+		h.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_sink_health_checker",
+		})
+	}
+}
+
+// WithPauseHandler registers a function that's called with true when the wal
+// event processing is paused because the sink is unreachable, and with false
+// when it's resumed.
+func WithPauseHandler(fn func(paused bool)) Option {
+	return func(h *SinkHealthChecker) {
+		h.pauseHandler = fn
+	}
+}
+
+// ProcessWALEvent sends the event on input to the wrapped processor, blocking
+// while the sink is unreachable.
+func (h *SinkHealthChecker) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	for {
+		h.mutex.Lock()
+		healthy, recovered := h.healthy, h.recovered
+		h.mutex.Unlock()
+
+		if healthy {
+			return h.processor.ProcessWALEvent(ctx, event)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-recovered:
+		}
+	}
+}
+
+func (h *SinkHealthChecker) Name() string {
+	return h.processor.Name()
+}
+
+// Close stops the sink monitoring and closes the wrapped processor.
+func (h *SinkHealthChecker) Close() error {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	h.wg.Wait()
+	return h.processor.Close()
+}
+
+func (h *SinkHealthChecker) Ping(ctx context.Context) error {
+	return h.processor.Ping(ctx)
+}
+
+// waitForSink pings the sink until it's reachable. It returns an error if the
+// sink is still unreachable after the max startup wait.
+func (h *SinkHealthChecker) waitForSink(ctx context.Context) error {
+	waitCtx := ctx
+	if h.maxStartupWait > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, h.maxStartupWait)
+		defer cancel()
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		err := h.ping(waitCtx)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if waitCtx.Err() != nil {
+			return fmt.Errorf("%w after %s: %w", ErrSinkUnreachable, time.Since(start).Round(time.Millisecond), err)
+		}
+
+		h.logger.Warn(err, "sink unreachable at startup, waiting before processing wal events", loglib.Fields{
+			"sink":     h.processor.Name(),
+			"interval": h.interval.String(),
+		})
+
+		select {
+		case <-waitCtx.Done():
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *SinkHealthChecker) monitorSink(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.checkSink(ctx)
+		}
+	}
+}
+
+// checkSink pings the sink and updates the health state. When the sink
+// becomes unreachable, the wal event processing is paused until a following
+// ping succeeds.
+func (h *SinkHealthChecker) checkSink(ctx context.Context) {
+	err := h.ping(ctx)
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	switch {
+	case err != nil && h.healthy:
+		h.logger.Error(err, "sink unreachable, pausing wal event processing", loglib.Fields{
+			"sink": h.processor.Name(),
+		})
+		h.healthy = false
+		h.downSince = time.Now()
+		h.recovered = make(chan struct{})
+		h.pauseHandler(true)
+	case err == nil && !h.healthy:
+		h.logger.Info("sink recovered, resuming wal event processing", loglib.Fields{
+			"sink":     h.processor.Name(),
+			"downtime": time.Since(h.downSince).Round(time.Millisecond).String(),
+		})
+		h.healthy = true
+		close(h.recovered)
+		h.pauseHandler(false)
+	}
+}
+
+func (h *SinkHealthChecker) ping(ctx context.Context) error {
+	pingCtx, cancel := context.WithTimeout(ctx, h.interval)
+	defer cancel()
+	return h.processor.Ping(pingCtx)
+}
+
+func (c *Config) interval() time.Duration {
+	if c.Interval > 0 {
+		return c.Interval
+	}
+	return defaultInterval
+}
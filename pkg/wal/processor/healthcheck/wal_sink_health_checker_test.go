@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name          string
+		config        *Config
+		failingPings  int32
+		alwaysFailing bool
+
+		wantErr   error
+		wantPings int32
+	}{
+		{
+			name:      "ok - sink reachable",
+			config:    &Config{Interval: time.Millisecond},
+			wantPings: 1,
+		},
+		{
+			name:         "ok - sink reachable after retries",
+			config:       &Config{Interval: time.Millisecond},
+			failingPings: 2,
+			wantPings:    3,
+		},
+		{
+			name:          "error - max startup wait exceeded",
+			config:        &Config{Interval: time.Millisecond, MaxStartupWait: 20 * time.Millisecond},
+			alwaysFailing: true,
+			wantErr:       ErrSinkUnreachable,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			pings := atomic.Int32{}
+			p := &mocks.Processor{
+				PingFn: func(context.Context) error {
+					if n := pings.Add(1); tc.alwaysFailing || n <= tc.failingPings {
+						return errTest
+					}
+					return nil
+				},
+			}
+
+			h, err := New(context.Background(), tc.config, p)
+			require.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				require.ErrorIs(t, err, errTest)
+				return
+			}
+			defer h.Close()
+			require.GreaterOrEqual(t, pings.Load(), tc.wantPings)
+		})
+	}
+
+	t.Run("error - context cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		p := &mocks.Processor{
+			PingFn: func(context.Context) error {
+				cancel()
+				return errTest
+			},
+		}
+
+		_, err := New(ctx, &Config{Interval: time.Millisecond}, p)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestSinkHealthChecker_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	testEvent := &wal.Event{CommitPosition: wal.CommitPosition("1")}
+	errTest := errors.New("oh noes")
+
+	t.Run("ok - sink healthy", func(t *testing.T) {
+		t.Parallel()
+
+		processed := 0
+		h := newSinkHealthChecker(&Config{}, &mocks.Processor{
+			ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+				processed++
+				return nil
+			},
+		})
+
+		require.NoError(t, h.ProcessWALEvent(context.Background(), testEvent))
+		require.Equal(t, 1, processed)
+	})
+
+	t.Run("ok - processing blocked until sink recovers", func(t *testing.T) {
+		t.Parallel()
+
+		unreachable := atomic.Bool{}
+		unreachable.Store(true)
+		processed := make(chan *wal.Event, 1)
+		pauses := []bool{}
+		h := newSinkHealthChecker(&Config{}, &mocks.Processor{
+			ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+				processed <- event
+				return nil
+			},
+			PingFn: func(context.Context) error {
+				if unreachable.Load() {
+					return errTest
+				}
+				return nil
+			},
+		})
+		WithPauseHandler(func(paused bool) { pauses = append(pauses, paused) })(h)
+
+		h.checkSink(context.Background())
+		require.False(t, h.healthy)
+		// the handler is only notified of the state changes
+		h.checkSink(context.Background())
+		require.Equal(t, []bool{true}, pauses)
+
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- h.ProcessWALEvent(context.Background(), testEvent)
+		}()
+
+		select {
+		case <-processed:
+			t.Fatal("event processed while the sink is unreachable")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		unreachable.Store(false)
+		h.checkSink(context.Background())
+		require.True(t, h.healthy)
+		require.Equal(t, []bool{true, false}, pauses)
+
+		require.NoError(t, <-errChan)
+		require.Equal(t, testEvent, <-processed)
+	})
+
+	t.Run("error - context cancelled while sink unreachable", func(t *testing.T) {
+		t.Parallel()
+
+		h := newSinkHealthChecker(&Config{}, &mocks.Processor{
+			ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+				return errors.New("unexpected call to ProcessWALEventFn")
+			},
+			PingFn: func(context.Context) error { return errTest },
+		})
+
+		h.checkSink(context.Background())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := h.ProcessWALEvent(ctx, testEvent)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestSinkHealthChecker_Close(t *testing.T) {
+	t.Parallel()
+
+	closed := false
+	h, err := New(context.Background(), &Config{Interval: time.Millisecond}, &mocks.Processor{
+		CloseFn: func() error {
+			closed = true
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, h.Close())
+	require.True(t, closed)
+}
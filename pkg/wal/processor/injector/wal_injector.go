@@ -6,7 +6,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"slices"
 
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/otel"
@@ -97,7 +96,12 @@ func WithLogger(l loglib.Logger) Option {
 
 func WithInstrumentation(instr *otel.Instrumentation) Option {
 	return func(in *Injector) {
-		in.schemaLogStore = schemaloginstrumentation.NewStore(in.schemaLogStore, instr)
+		var err error
+		in.schemaLogStore, err = schemaloginstrumentation.NewStore(in.schemaLogStore, instr)
+		if err != nil {
+			// this should never happen
+			panic(err)
+		}
 	}
 }
 
@@ -162,6 +166,10 @@ func (in *Injector) Close() error {
 	return in.schemaLogStore.Close()
 }
 
+func (in *Injector) Ping(ctx context.Context) error {
+	return in.processor.Ping(ctx)
+}
+
 func (in *Injector) inject(ctx context.Context, data *wal.Data) error {
 	if data == nil {
 		return nil
@@ -294,6 +302,6 @@ func primaryKeyFinder(c *schemalog.Column, tbl *schemalog.Table) bool {
 		return c.Name == notNullUniqueCol.Name
 	default:
 		// single or composite primary key
-		return slices.Contains(tbl.PrimaryKeyColumns, c.Name)
+		return tbl.IsPrimaryKey(c.Name)
 	}
 }
@@ -0,0 +1,262 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package instrumentation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ApplyLatencyTracker measures the end to end replication latency, from the
+// commit of the events in the source database until the processor
+// acknowledges them by checkpointing a position at or past theirs. The events
+// are tracked by LSN as they're sent to the processor, so it only supports
+// postgres sources. The snapshot events are not tracked, since they
+// have no source commit.
+type ApplyLatencyTracker struct {
+	meter      metric.Meter
+	metrics    *applyLatencyMetrics
+	tables     *otel.TableAttributes
+	parser     replication.LSNParser
+	clock      clockwork.Clock
+	target     attribute.KeyValue
+	clockSkew  time.Duration
+	maxPending int
+
+	// mutex protects the pending events, added on every event and removed on
+	// every checkpoint
+	mutex   sync.Mutex
+	pending []pendingEvent
+}
+
+type pendingEvent struct {
+	lsn        replication.LSN
+	commitTime time.Time
+	attributes attribute.Set
+}
+
+type applyLatencyMetrics struct {
+	applyLatency metric.Int64Histogram
+	staleness    metric.Int64ObservableGauge
+	clockSkew    metric.Int64ObservableGauge
+}
+
+type ApplyLatencyOption func(t *ApplyLatencyTracker)
+
+// defaultMaxPendingEvents bounds the memory used by the events not yet
+// acknowledged. Past it, the oldest ones are dropped without being recorded.
+const defaultMaxPendingEvents = 100000
+
+// NewApplyLatencyTracker returns a tracker that reports the apply latency
+// metrics with the instrumentation on input. The processor must be wrapped
+// with it and its checkpoints routed through it for the latency to be
+// recorded.
+func NewApplyLatencyTracker(instrumentation *otel.Instrumentation, parser replication.LSNParser, opts ...ApplyLatencyOption) (*ApplyLatencyTracker, error) {
+	t := &ApplyLatencyTracker{
+		meter:      instrumentation.Meter,
+		tables:     instrumentation.Tables,
+		parser:     parser,
+		clock:      clockwork.NewRealClock(),
+		maxPending: defaultMaxPendingEvents,
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if err := t.initMetrics(); err != nil {
+		return nil, fmt.Errorf("initialising apply latency metrics: %w", err)
+	}
+
+	return t, nil
+}
+
+// WithClockSkew configures the difference between the source database clock
+// and the local one, which the recorded latency is corrected by. It's
+// reported as a gauge.
+func WithClockSkew(skew time.Duration) ApplyLatencyOption {
+	return func(t *ApplyLatencyTracker) {
+		t.clockSkew = skew
+	}
+}
+
+// Wrap returns a processor that tracks the events sent to the processor on
+// input. The processor name is used as the target attribute of the metrics.
+func (t *ApplyLatencyTracker) Wrap(p processor.Processor) processor.Processor {
+	t.target = attribute.String(targetAttributeKey, p.Name())
+	return &applyLatencyProcessor{
+		Processor: p,
+		tracker:   t,
+	}
+}
+
+// Checkpoint returns a checkpoint that records the latency of the events
+// acknowledged by the positions on input, once they've been checkpointed
+// successfully.
+func (t *ApplyLatencyTracker) Checkpoint(next checkpointer.Checkpoint) checkpointer.Checkpoint {
+	return func(ctx context.Context, positions []wal.CommitPosition) error {
+		if err := next(ctx, positions); err != nil {
+			return err
+		}
+		t.Acknowledge(ctx, positions)
+		return nil
+	}
+}
+
+// Acknowledge records the latency of the pending events up to the max
+// position on input. The positions that are not valid LSNs are ignored.
+func (t *ApplyLatencyTracker) Acknowledge(ctx context.Context, positions []wal.CommitPosition) {
+	var max replication.LSN
+	for _, position := range positions {
+		lsn, err := t.parser.FromString(string(position))
+		if err == nil && lsn > max {
+			max = lsn
+		}
+	}
+	if max == 0 {
+		return
+	}
+
+	now := t.clock.Now()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	// the changes of concurrent transactions are not in LSN order across
+	// transactions, so all the pending events need to be checked
+	pending := t.pending[:0]
+	for _, event := range t.pending {
+		if event.lsn > max {
+			pending = append(pending, event)
+			continue
+		}
+		t.metrics.applyLatency.Record(ctx, t.latency(now, event.commitTime).Milliseconds(),
+			metric.WithAttributeSet(event.attributes), metric.WithAttributes(t.target))
+	}
+	t.pending = pending
+}
+
+func (t *ApplyLatencyTracker) track(event *wal.Event) {
+	if t.metrics == nil || event.Data == nil || event.CommitPosition == wal.ZeroLSN {
+		return
+	}
+	lsn, err := t.parser.FromString(string(event.CommitPosition))
+	if err != nil {
+		return
+	}
+	commitTime, err := event.Data.GetTimestamp()
+	if err != nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.pending = append(t.pending, pendingEvent{
+		lsn:        lsn,
+		commitTime: commitTime,
+		attributes: attribute.NewSet(t.tables.Get(event.Data.Schema, event.Data.Table)...),
+	})
+	if len(t.pending) > t.maxPending {
+		t.pending = t.pending[len(t.pending)-t.maxPending:]
+	}
+}
+
+// latency returns the time passed since the source commit time on input,
+// corrected by the clock skew. Skew estimation errors are floored at zero.
+func (t *ApplyLatencyTracker) latency(now, commitTime time.Time) time.Duration {
+	latency := now.Sub(commitTime) + t.clockSkew
+	if latency < 0 {
+		return 0
+	}
+	return latency
+}
+
+func (t *ApplyLatencyTracker) initMetrics() error {
+	if t.meter == nil {
+		return nil
+	}
+	t.metrics = &applyLatencyMetrics{}
+
+	var err error
+	t.metrics.applyLatency, err = t.meter.Int64Histogram("pgstream.target.apply.latency",
+		metric.WithUnit("ms"),
+		metric.WithDescription("Distribution of the time passed since the wal event was committed in the source until it was acknowledged by the processor"))
+	if err != nil {
+		return err
+	}
+
+	t.metrics.staleness, err = t.meter.Int64ObservableGauge("pgstream.target.apply.staleness",
+		metric.WithUnit("ms"),
+		metric.WithDescription("Time passed since the source commit of the oldest wal event not yet acknowledged by the processor, or 0 when all events are acknowledged"))
+	if err != nil {
+		return err
+	}
+
+	t.metrics.clockSkew, err = t.meter.Int64ObservableGauge("pgstream.source.clock_skew",
+		metric.WithUnit("ms"),
+		metric.WithDescription("Difference between the source database clock and the local clock, estimated on startup"))
+	if err != nil {
+		return err
+	}
+
+	_, err = t.meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		var staleness time.Duration
+		if len(t.pending) > 0 {
+			staleness = t.latency(t.clock.Now(), t.pending[0].commitTime)
+		}
+		o.ObserveInt64(t.metrics.staleness, staleness.Milliseconds(), metric.WithAttributes(t.target))
+		o.ObserveInt64(t.metrics.clockSkew, t.clockSkew.Milliseconds())
+		return nil
+	}, t.metrics.staleness, t.metrics.clockSkew)
+	return err
+}
+
+type applyLatencyProcessor struct {
+	processor.Processor
+	tracker *ApplyLatencyTracker
+}
+
+// ProcessWALEvent tracks the event before sending it to the wrapped
+// processor, since it can be acknowledged before the call returns.
+func (p *applyLatencyProcessor) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	p.tracker.track(event)
+	return p.Processor.ProcessWALEvent(ctx, event)
+}
+
+// clockSkewRounds is the number of round trips used to estimate the clock
+// skew. The one with the shortest round trip is the most accurate.
+const clockSkewRounds = 3
+
+// EstimateClockSkew returns the difference between the postgres server clock
+// and the local one, assuming the server reads its clock halfway through the
+// round trip.
+func EstimateClockSkew(ctx context.Context, querier pglib.Querier) (time.Duration, error) {
+	var skew time.Duration
+	minRoundTrip := time.Duration(-1)
+	for range clockSkewRounds {
+		var serverTime time.Time
+		start := time.Now()
+		if err := querier.QueryRow(ctx, []any{&serverTime}, "SELECT clock_timestamp()"); err != nil {
+			return 0, fmt.Errorf("querying source clock: %w", err)
+		}
+		roundTrip := time.Since(start)
+		if minRoundTrip < 0 || roundTrip < minRoundTrip {
+			minRoundTrip = roundTrip
+			skew = serverTime.Sub(start.Add(roundTrip / 2))
+		}
+	}
+	return skew, nil
+}
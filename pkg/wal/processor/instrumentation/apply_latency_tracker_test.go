@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestApplyLatencyTracker(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 1, 1, 0, 0, 5, 0, time.UTC))
+	tracker, err := NewApplyLatencyTracker(&otel.Instrumentation{
+		Meter: meterProvider.Meter("test"),
+	}, pgreplication.NewLSNParser(), WithClockSkew(time.Second))
+	require.NoError(t, err)
+	tracker.clock = clock
+
+	var checkpointErr error
+	checkpoint := tracker.Checkpoint(func(ctx context.Context, positions []wal.CommitPosition) error {
+		return checkpointErr
+	})
+	p := tracker.Wrap(&mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error { return nil },
+	})
+
+	newEvent := func(position, table, timestamp string) *wal.Event {
+		return &wal.Event{
+			Data:           &wal.Data{Schema: "public", Table: table, Timestamp: timestamp},
+			CommitPosition: wal.CommitPosition(position),
+		}
+	}
+	ctx := context.Background()
+	require.NoError(t, p.ProcessWALEvent(ctx, newEvent("0/10", "users", "2024-01-01 00:00:00+00")))
+	require.NoError(t, p.ProcessWALEvent(ctx, newEvent("0/20", "orders", "2024-01-01 00:00:01+00")))
+	// snapshot and keep alive events are not tracked
+	require.NoError(t, p.ProcessWALEvent(ctx, newEvent(wal.ZeroLSN, "users", "2024-01-01 00:00:00+00")))
+	require.NoError(t, p.ProcessWALEvent(ctx, &wal.Event{CommitPosition: "0/30"}))
+
+	collect := func() (map[string]metricdata.HistogramDataPoint[int64], map[string]int64) {
+		rm := metricdata.ResourceMetrics{}
+		require.NoError(t, reader.Collect(ctx, &rm))
+		histograms := map[string]metricdata.HistogramDataPoint[int64]{}
+		gauges := map[string]int64{}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				switch data := m.Data.(type) {
+				case metricdata.Histogram[int64]:
+					for _, dp := range data.DataPoints {
+						target, _ := dp.Attributes.Value(attribute.Key(targetAttributeKey))
+						require.Equal(t, "mock", target.AsString())
+						tableName, _ := dp.Attributes.Value(attribute.Key(otel.TableAttributeKey))
+						histograms[tableName.AsString()] = dp
+					}
+				case metricdata.Gauge[int64]:
+					for _, dp := range data.DataPoints {
+						gauges[m.Name] = dp.Value
+					}
+				}
+			}
+		}
+		return histograms, gauges
+	}
+
+	histograms, gauges := collect()
+	require.Empty(t, histograms)
+	require.Equal(t, map[string]int64{
+		"pgstream.target.apply.staleness": 6000,
+		"pgstream.source.clock_skew":      1000,
+	}, gauges)
+
+	// failed checkpoints don't acknowledge the events
+	checkpointErr = errTest
+	require.ErrorIs(t, checkpoint(ctx, []wal.CommitPosition{"0/15"}), errTest)
+	histograms, _ = collect()
+	require.Empty(t, histograms)
+
+	checkpointErr = nil
+	require.NoError(t, checkpoint(ctx, []wal.CommitPosition{"0/15", "invalid"}))
+	histograms, gauges = collect()
+	require.Len(t, histograms, 1)
+	require.Equal(t, uint64(1), histograms["users"].Count)
+	require.Equal(t, int64(6000), histograms["users"].Sum)
+	require.Equal(t, int64(5000), gauges["pgstream.target.apply.staleness"])
+
+	clock.Advance(time.Second)
+	require.NoError(t, checkpoint(ctx, []wal.CommitPosition{"0/30"}))
+	histograms, gauges = collect()
+	require.Equal(t, int64(6000), histograms["orders"].Sum)
+	require.Equal(t, int64(0), gauges["pgstream.target.apply.staleness"])
+}
+
+func TestEstimateClockSkew(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name    string
+		querier pglib.Querier
+
+		wantSkew time.Duration
+		wantErr  error
+	}{
+		{
+			name: "ok",
+			querier: &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					require.Equal(t, "SELECT clock_timestamp()", query)
+					serverTime, ok := dest[0].(*time.Time)
+					require.True(t, ok)
+					*serverTime = time.Now().Add(time.Hour)
+					return nil
+				},
+			},
+
+			wantSkew: time.Hour,
+			wantErr:  nil,
+		},
+		{
+			name: "error - querying source clock",
+			querier: &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					return errTest
+				},
+			},
+
+			wantSkew: 0,
+			wantErr:  errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			skew, err := EstimateClockSkew(context.Background(), tc.querier)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.InDelta(t, tc.wantSkew, skew, float64(time.Second))
+		})
+	}
+}
@@ -4,12 +4,16 @@ package instrumentation
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/healthcheck"
+	"github.com/xataio/pgstream/pkg/wal/processor/validate"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
@@ -21,15 +25,32 @@ type Processor struct {
 	tracer     trace.Tracer
 	meter      metric.Meter
 	metrics    *metrics
+	tables     *otel.TableAttributes
 	targetType string
+
+	// mutex protects the last event timestamps, updated on every event and
+	// read on every metrics collection
+	mutex               sync.Mutex
+	lastEventTimestamps map[attribute.Distinct]tableTimestamp
+}
+
+type tableTimestamp struct {
+	attributes attribute.Set
+	timestamp  time.Time
 }
 
 type metrics struct {
 	processLag        metric.Int64Histogram
 	processingLatency metric.Int64Histogram
+	processingErrors  metric.Int64Counter
+	processedEvents   metric.Int64Counter
+	lastEventTime     metric.Int64ObservableGauge
 }
 
-const targetAttributeKey = "target"
+const (
+	targetAttributeKey     = "target"
+	errorClassAttributeKey = "error_class"
+)
 
 func NewProcessor(p processor.Processor, instrumentation *otel.Instrumentation) (processor.Processor, error) {
 	if instrumentation == nil {
@@ -41,7 +62,10 @@ func NewProcessor(p processor.Processor, instrumentation *otel.Instrumentation)
 		tracer:     instrumentation.Tracer,
 		meter:      instrumentation.Meter,
 		metrics:    &metrics{},
+		tables:     instrumentation.Tables,
 		targetType: p.Name(),
+
+		lastEventTimestamps: map[attribute.Distinct]tableTimestamp{},
 	}
 
 	if err := processor.initMetrics(); err != nil {
@@ -56,15 +80,25 @@ func (i *Processor) ProcessWALEvent(ctx context.Context, event *wal.Event) (err
 	defer otel.CloseSpan(span, err)
 
 	if i.meter != nil {
+		attrs := []attribute.KeyValue{i.targetAttribute()}
+		if event.Data != nil {
+			attrs = append(attrs, i.tables.Get(event.Data.Schema, event.Data.Table)...)
+		}
+
 		startTime := time.Now()
 		defer func() {
-			i.metrics.processingLatency.Record(ctx, int64(time.Since(startTime).Nanoseconds()), metric.WithAttributes(i.targetAttribute()))
+			i.metrics.processingLatency.Record(ctx, int64(time.Since(startTime).Nanoseconds()), metric.WithAttributes(attrs...))
+			if err != nil {
+				i.metrics.processingErrors.Add(ctx, 1, metric.WithAttributes(append(attrs, attribute.String(errorClassAttributeKey, errorClass(err)))...))
+			}
 		}()
 
 		if event.Data != nil {
+			i.metrics.processedEvents.Add(ctx, 1, metric.WithAttributes(attrs...))
 			timestamp, err := event.Data.GetTimestamp()
 			if err == nil {
-				i.metrics.processLag.Record(ctx, time.Since(timestamp).Nanoseconds(), metric.WithAttributes(i.targetAttribute()))
+				i.metrics.processLag.Record(ctx, time.Since(timestamp).Nanoseconds(), metric.WithAttributes(attrs...))
+				i.trackLastEventTimestamp(attribute.NewSet(attrs[1:]...), timestamp)
 			}
 		}
 	}
@@ -79,6 +113,10 @@ func (i *Processor) Close() error {
 	return i.inner.Close()
 }
 
+func (i *Processor) Ping(ctx context.Context) error {
+	return i.inner.Ping(ctx)
+}
+
 func (i *Processor) initMetrics() error {
 	if i.meter == nil {
 		return nil
@@ -99,7 +137,76 @@ func (i *Processor) initMetrics() error {
 		return err
 	}
 
-	return nil
+	i.metrics.processingErrors, err = i.meter.Int64Counter("pgstream.target.processing.errors",
+		metric.WithUnit("errors"),
+		metric.WithDescription("Number of wal events that failed to be processed by the wal event processor"))
+	if err != nil {
+		return err
+	}
+
+	i.metrics.processedEvents, err = i.meter.Int64Counter("pgstream.target.processing.events",
+		metric.WithUnit("events"),
+		metric.WithDescription("Number of wal data events received by the wal event processor"))
+	if err != nil {
+		return err
+	}
+
+	i.metrics.lastEventTime, err = i.meter.Int64ObservableGauge("pgstream.table.last_event.timestamp",
+		metric.WithUnit("s"),
+		metric.WithDescription("Unix timestamp of the commit of the last wal event processed for the table"))
+	if err != nil {
+		return err
+	}
+
+	_, err = i.meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		i.mutex.Lock()
+		defer i.mutex.Unlock()
+		for _, t := range i.lastEventTimestamps {
+			o.ObserveInt64(i.metrics.lastEventTime, t.timestamp.Unix(), metric.WithAttributeSet(t.attributes), metric.WithAttributes(i.targetAttribute()))
+		}
+		return nil
+	}, i.metrics.lastEventTime)
+	return err
+}
+
+// trackLastEventTimestamp keeps the latest event timestamp of the table
+// attributes on input. Tables in the "other" bucket share the latest one.
+func (i *Processor) trackLastEventTimestamp(tableAttrs attribute.Set, timestamp time.Time) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	key := tableAttrs.Equivalent()
+	if last, found := i.lastEventTimestamps[key]; found && !timestamp.After(last.timestamp) {
+		return
+	}
+	i.lastEventTimestamps[key] = tableTimestamp{attributes: tableAttrs, timestamp: timestamp}
+}
+
+// errorClass returns the class of the processing error on input, to tell apart
+// the errors caused by the events from the ones caused by the target or the
+// shutdown.
+func errorClass(err error) string {
+	switch {
+	case errors.Is(err, wal.ErrShuttingDown):
+		return "shutdown"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, processor.ErrPanic):
+		return "panic"
+	case errors.Is(err, healthcheck.ErrSinkUnreachable):
+		return "sink_unreachable"
+	case errors.Is(err, validate.ErrSchemaValidation):
+		return "validation"
+	case errors.Is(err, processor.ErrTableNotFound),
+		errors.Is(err, processor.ErrColumnNotFound),
+		errors.Is(err, processor.ErrIDNotFound),
+		errors.Is(err, processor.ErrVersionNotFound),
+		errors.Is(err, processor.ErrIncompatibleWalData):
+		return "schema"
+	default:
+		return "other"
+	}
 }
 
 func (i *Processor) targetAttribute() attribute.KeyValue {
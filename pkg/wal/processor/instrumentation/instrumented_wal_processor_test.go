@@ -0,0 +1,115 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package instrumentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/healthcheck"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/validate"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestProcessor_ProcessWALEvent_tableMetrics(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	p, err := NewProcessor(&mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+			if walEvent.Data != nil && walEvent.Data.Table == "orders" {
+				return errTest
+			}
+			return nil
+		},
+	}, &otel.Instrumentation{
+		Meter:  meterProvider.Meter("test"),
+		Tables: otel.NewTableAttributes(2),
+	})
+	require.NoError(t, err)
+
+	newEvent := func(table, timestamp string) *wal.Event {
+		return &wal.Event{Data: &wal.Data{Schema: "public", Table: table, Timestamp: timestamp}}
+	}
+	require.NoError(t, p.ProcessWALEvent(context.Background(), newEvent("users", "2024-01-01 00:00:02+00")))
+	require.NoError(t, p.ProcessWALEvent(context.Background(), newEvent("users", "2024-01-01 00:00:01+00")))
+	require.ErrorIs(t, p.ProcessWALEvent(context.Background(), newEvent("orders", "2024-01-01 00:00:03+00")), errTest)
+	// the cardinality cap has been reached
+	require.NoError(t, p.ProcessWALEvent(context.Background(), newEvent("items", "2024-01-01 00:00:04+00")))
+	require.NoError(t, p.ProcessWALEvent(context.Background(), newEvent("products", "2024-01-01 00:00:05+00")))
+	// keep alive events are not table operations
+	require.NoError(t, p.ProcessWALEvent(context.Background(), &wal.Event{CommitPosition: "1"}))
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	values := map[string]map[string]int64{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			values[m.Name] = map[string]int64{}
+			var dataPoints []metricdata.DataPoint[int64]
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				dataPoints = data.DataPoints
+			case metricdata.Gauge[int64]:
+				dataPoints = data.DataPoints
+			default:
+				continue
+			}
+			for _, dp := range dataPoints {
+				schemaName, _ := dp.Attributes.Value(attribute.Key(otel.SchemaAttributeKey))
+				tableName, _ := dp.Attributes.Value(attribute.Key(otel.TableAttributeKey))
+				target, _ := dp.Attributes.Value(attribute.Key(targetAttributeKey))
+				require.Equal(t, "mock", target.AsString())
+				values[m.Name][schemaName.AsString()+"."+tableName.AsString()] = dp.Value
+			}
+		}
+	}
+
+	require.Equal(t, map[string]int64{"public.users": 2, "public.orders": 1, "other.other": 2}, values["pgstream.target.processing.events"])
+	require.Equal(t, map[string]int64{"public.orders": 1}, values["pgstream.target.processing.errors"])
+	require.Equal(t, map[string]int64{
+		"public.users":  1704067202,
+		"public.orders": 1704067203,
+		"other.other":   1704067205,
+	}, values["pgstream.table.last_event.timestamp"])
+}
+
+func TestErrorClass(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+
+		wantClass string
+	}{
+		{name: "shutdown", err: wal.ErrShuttingDown, wantClass: "shutdown"},
+		{name: "canceled", err: fmt.Errorf("sending batch: %w", context.Canceled), wantClass: "canceled"},
+		{name: "timeout", err: context.DeadlineExceeded, wantClass: "timeout"},
+		{name: "panic", err: processor.ErrPanic, wantClass: "panic"},
+		{name: "sink unreachable", err: healthcheck.ErrSinkUnreachable, wantClass: "sink_unreachable"},
+		{name: "validation", err: fmt.Errorf("%w: missing column", validate.ErrSchemaValidation), wantClass: "validation"},
+		{name: "schema", err: processor.ErrTableNotFound, wantClass: "schema"},
+		{name: "other", err: errors.New("oh noes"), wantClass: "other"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantClass, errorClass(tc.err))
+		})
+	}
+}
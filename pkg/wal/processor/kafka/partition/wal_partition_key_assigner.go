@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package partition
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// PartitionKeyAssigner is a decorator around a kafka wal processor that
+// computes the kafka partition key of the wal events, which determines the
+// partition they're routed to. Kafka only guarantees the ordering of the
+// events within a partition.
+type PartitionKeyAssigner struct {
+	logger    loglib.Logger
+	processor processor.Processor
+	keyFunc   KeyFunc
+}
+
+type Config struct {
+	// Strategy used to compute the partition key of the events.
+	Strategy Strategy
+	// TenantColumn is the name of the column whose value is used as the
+	// partition key. Required for the tenant id strategy.
+	TenantColumn string
+}
+
+// Strategy defines how the partition key of the events is computed.
+type Strategy string
+
+const (
+	// PKHash uses the hash of the primary key columns of the event row, so
+	// that all the events for a row are kept in order.
+	PKHash Strategy = "pk_hash"
+	// TableRoundRobin uses the qualified table name, so that all the events
+	// for a table are kept in order, and the tables are distributed across
+	// the partitions.
+	TableRoundRobin Strategy = "table_round_robin"
+	// TenantID uses the value of the configured tenant column, so that all
+	// the events for a tenant are kept in order.
+	TenantID Strategy = "tenant_id"
+)
+
+// KeyFunc returns the partition key for the wal data on input. If an empty
+// key is returned, the default kafka writer key will be used.
+type KeyFunc func(*wal.Data) ([]byte, error)
+
+type Option func(a *PartitionKeyAssigner)
+
+var (
+	errUnsupportedStrategy  = errors.New("unsupported partitioning strategy")
+	errMissingTenantColumn  = errors.New("tenant column is required for the tenant id partitioning strategy")
+	errPrimaryKeyNotFound   = errors.New("primary key columns not found")
+	errTenantColumnNotFound = errors.New("tenant column not found")
+)
+
+// New will return a partition key assigner wrapper around the processor on
+// input, using the configured strategy to compute the partition keys, unless a
+// custom key function is provided.
+func New(cfg *Config, p processor.Processor, opts ...Option) (*PartitionKeyAssigner, error) {
+	a := &PartitionKeyAssigner{
+		logger:    loglib.NewNoopLogger(),
+		processor: p,
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if a.keyFunc != nil {
+		return a, nil
+	}
+
+	switch cfg.Strategy {
+	case PKHash:
+		a.keyFunc = primaryKeyHash
+	case TableRoundRobin:
+		a.keyFunc = tableKey
+	case TenantID:
+		if cfg.TenantColumn == "" {
+			return nil, errMissingTenantColumn
+		}
+		a.keyFunc = tenantKey(cfg.TenantColumn)
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnsupportedStrategy, cfg.Strategy)
+	}
+
+	return a, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(a *PartitionKeyAssigner) {
+		a.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_partition_key_assigner",
+		})
+	}
+}
+
+// WithKeyFunc sets a custom function to compute the partition keys, which
+// takes precedence over the configured strategy.
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(a *PartitionKeyAssigner) {
+		a.keyFunc = fn
+	}
+}
+
+// ProcessWALEvent computes the partition key of the event on input before
+// passing it to the wrapped processor. Schema log events are not assigned a
+// key, so that they're routed to the same partition as their schema writes.
+// If the key can't be computed, the default kafka writer key is used.
+func (a *PartitionKeyAssigner) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	if event.Data == nil || processor.IsSchemaLogEvent(event.Data) {
+		return a.processor.ProcessWALEvent(ctx, event)
+	}
+
+	key, err := a.keyFunc(event.Data)
+	if err != nil {
+		a.logger.Warn(err, "computing partition key, using default key", loglib.Fields{
+			"schema": event.Data.Schema,
+			"table":  event.Data.Table,
+		})
+	}
+	if err == nil && len(key) > 0 {
+		event.Kafka = &wal.KafkaMetadata{PartitionKey: key}
+	}
+
+	return a.processor.ProcessWALEvent(ctx, event)
+}
+
+func (a *PartitionKeyAssigner) Name() string {
+	return a.processor.Name()
+}
+
+func (a *PartitionKeyAssigner) Close() error {
+	return a.processor.Close()
+}
+
+func (a *PartitionKeyAssigner) Ping(ctx context.Context) error {
+	return a.processor.Ping(ctx)
+}
+
+// primaryKeyHash returns the hash of the qualified table name and the primary
+// key column values. The pgstream identity columns are used when available,
+// and the replica identity otherwise.
+func primaryKeyHash(data *wal.Data) ([]byte, error) {
+	pkColumns := primaryKeyColumns(data)
+	if len(pkColumns) == 0 {
+		return nil, errPrimaryKeyNotFound
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s.%s", data.Schema, data.Table)
+	for _, col := range pkColumns {
+		fmt.Fprintf(h, "|%v", col.Value)
+	}
+	return []byte(strconv.FormatUint(h.Sum64(), 16)), nil
+}
+
+func primaryKeyColumns(data *wal.Data) []wal.Column {
+	ids := data.Metadata.InternalColIDs
+	if len(ids) == 0 {
+		return data.Identity
+	}
+
+	// delete events only have the identity columns
+	columns := data.Columns
+	if len(columns) == 0 {
+		columns = data.Identity
+	}
+
+	pkColumns := make([]wal.Column, 0, len(ids))
+	for _, col := range columns {
+		if slices.Contains(ids, col.ID) {
+			pkColumns = append(pkColumns, col)
+		}
+	}
+	return pkColumns
+}
+
+func tableKey(data *wal.Data) ([]byte, error) {
+	return []byte(data.Schema + "." + data.Table), nil
+}
+
+func tenantKey(tenantColumn string) KeyFunc {
+	return func(data *wal.Data) ([]byte, error) {
+		for _, columns := range [][]wal.Column{data.Columns, data.Identity} {
+			for _, col := range columns {
+				if col.Name == tenantColumn && col.Value != nil {
+					return fmt.Appendf(nil, "%v", col.Value), nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", errTenantColumnNotFound, tenantColumn)
+	}
+}
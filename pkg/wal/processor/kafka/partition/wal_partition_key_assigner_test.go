@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package partition
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  *Config
+		opts []Option
+
+		wantErr error
+	}{
+		{
+			name: "ok - pk hash",
+			cfg:  &Config{Strategy: PKHash},
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - custom key func",
+			cfg:  &Config{},
+			opts: []Option{WithKeyFunc(func(d *wal.Data) ([]byte, error) { return nil, nil })},
+
+			wantErr: nil,
+		},
+		{
+			name: "error - missing tenant column",
+			cfg:  &Config{Strategy: TenantID},
+
+			wantErr: errMissingTenantColumn,
+		},
+		{
+			name: "error - unsupported strategy",
+			cfg:  &Config{Strategy: "random"},
+
+			wantErr: errUnsupportedStrategy,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := New(tc.cfg, &mocks.Processor{}, tc.opts...)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestPartitionKeyAssigner_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	testColumns := []wal.Column{
+		{ID: "t1_id", Name: "id", Type: "integer", Value: 1},
+		{ID: "t1_tenant", Name: "tenant", Type: "text", Value: "acme"},
+		{ID: "t1_name", Name: "name", Type: "text", Value: "alice"},
+	}
+	testMetadata := wal.Metadata{InternalColIDs: []string{"t1_id"}}
+
+	newTestEvent := func(action string, columns, identity []wal.Column, metadata wal.Metadata) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action:   action,
+				Schema:   "public",
+				Table:    "users",
+				Columns:  columns,
+				Identity: identity,
+				Metadata: metadata,
+			},
+			CommitPosition: wal.CommitPosition("0/1"),
+		}
+	}
+
+	pkHashKey, err := primaryKeyHash(newTestEvent("I", testColumns, nil, testMetadata).Data)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		cfg   *Config
+		opts  []Option
+		event *wal.Event
+
+		wantKey []byte
+		wantErr error
+	}{
+		{
+			name:  "ok - pk hash",
+			cfg:   &Config{Strategy: PKHash},
+			event: newTestEvent("I", testColumns, nil, testMetadata),
+
+			wantKey: pkHashKey,
+		},
+		{
+			name:  "ok - pk hash same key for delete",
+			cfg:   &Config{Strategy: PKHash},
+			event: newTestEvent("D", nil, testColumns[:1], testMetadata),
+
+			wantKey: pkHashKey,
+		},
+		{
+			name:  "ok - pk hash without primary key uses default key",
+			cfg:   &Config{Strategy: PKHash},
+			event: newTestEvent("I", testColumns, nil, wal.Metadata{}),
+
+			wantKey: nil,
+		},
+		{
+			name:  "ok - table",
+			cfg:   &Config{Strategy: TableRoundRobin},
+			event: newTestEvent("I", testColumns, nil, testMetadata),
+
+			wantKey: []byte("public.users"),
+		},
+		{
+			name:  "ok - tenant id",
+			cfg:   &Config{Strategy: TenantID, TenantColumn: "tenant"},
+			event: newTestEvent("I", testColumns, nil, testMetadata),
+
+			wantKey: []byte("acme"),
+		},
+		{
+			name:  "ok - tenant id from identity",
+			cfg:   &Config{Strategy: TenantID, TenantColumn: "tenant"},
+			event: newTestEvent("D", nil, testColumns, testMetadata),
+
+			wantKey: []byte("acme"),
+		},
+		{
+			name:  "ok - tenant column not found uses default key",
+			cfg:   &Config{Strategy: TenantID, TenantColumn: "org"},
+			event: newTestEvent("I", testColumns, nil, testMetadata),
+
+			wantKey: nil,
+		},
+		{
+			name: "ok - custom key func",
+			cfg:  &Config{Strategy: PKHash},
+			opts: []Option{WithKeyFunc(func(d *wal.Data) ([]byte, error) {
+				return []byte("custom-" + d.Table), nil
+			})},
+			event: newTestEvent("I", testColumns, nil, testMetadata),
+
+			wantKey: []byte("custom-users"),
+		},
+		{
+			name: "ok - schema log event",
+			cfg:  &Config{Strategy: TableRoundRobin},
+			event: &wal.Event{
+				Data: &wal.Data{
+					Action: "I",
+					Schema: schemalog.SchemaName,
+					Table:  schemalog.TableName,
+				},
+			},
+
+			wantKey: nil,
+		},
+		{
+			name:  "ok - keep alive event",
+			cfg:   &Config{Strategy: TableRoundRobin},
+			event: &wal.Event{CommitPosition: wal.CommitPosition("0/1")},
+
+			wantKey: nil,
+		},
+		{
+			name:  "error - processing event",
+			cfg:   &Config{Strategy: TableRoundRobin},
+			event: newTestEvent("I", testColumns, nil, testMetadata),
+
+			wantKey: []byte("public.users"),
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := New(tc.cfg, &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					if tc.wantKey == nil {
+						require.Nil(t, walEvent.Kafka)
+					} else {
+						require.Equal(t, &wal.KafkaMetadata{PartitionKey: tc.wantKey}, walEvent.Kafka)
+					}
+					return tc.wantErr
+				},
+			}, tc.opts...)
+			require.NoError(t, err)
+
+			err = a.ProcessWALEvent(context.Background(), tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
@@ -9,7 +9,6 @@ import (
 	"runtime/debug"
 	"time"
 
-	"github.com/xataio/pgstream/internal/json"
 	"github.com/xataio/pgstream/pkg/kafka"
 	kafkainstrumentation "github.com/xataio/pgstream/pkg/kafka/instrumentation"
 	loglib "github.com/xataio/pgstream/pkg/log"
@@ -18,6 +17,7 @@ import (
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
 	"github.com/xataio/pgstream/pkg/wal/processor"
 	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	"github.com/xataio/pgstream/pkg/wal/processor/serializer"
 )
 
 // BatchWriter is a kafka writer that uses batches to send the data to the
@@ -27,11 +27,16 @@ type BatchWriter struct {
 	logger        loglib.Logger
 	batchSender   batchSender
 	maxBatchBytes int64
+	// instrumentation is used to report the batching metrics
+	instrumentation *otel.Instrumentation
 
 	// optional checkpointer callback to mark what was safely processed
 	checkpointer checkpointer.Checkpoint
 
-	serialiser func(any) ([]byte, error)
+	serialiser func(*wal.Data) ([]byte, error)
+	// contentType is included in the message headers when a custom serializer
+	// is configured
+	contentType string
 }
 
 type Option func(*BatchWriter)
@@ -41,11 +46,13 @@ type batchSender interface {
 	Close()
 }
 
+const contentTypeHeader = "content-type"
+
 var errRecordTooLarge = errors.New("record too large")
 
 func NewBatchWriter(ctx context.Context, config *Config, opts ...Option) (*BatchWriter, error) {
 	w := &BatchWriter{
-		serialiser:    json.Marshal,
+		serialiser:    serializer.NewJSONSerializer().Serialize,
 		logger:        loglib.NewNoopLogger(),
 		maxBatchBytes: config.Batch.GetMaxBatchBytes(),
 	}
@@ -76,7 +83,7 @@ func NewBatchWriter(ctx context.Context, config *Config, opts ...Option) (*Batch
 		opt(w)
 	}
 
-	w.batchSender, err = batch.NewSender(ctx, &config.Batch, w.sendBatch, w.logger)
+	w.batchSender, err = batch.NewSender(ctx, &config.Batch, w.sendBatch, w.logger, batch.WithInstrumentation(w.instrumentation, w.Name()))
 	if err != nil {
 		return nil, err
 	}
@@ -98,8 +105,19 @@ func WithCheckpoint(c checkpointer.Checkpoint) Option {
 	}
 }
 
+// WithSerializer sets the serializer used to encode the wal data into the
+// kafka message value, instead of the default JSON one. Its content type is
+// set in the message headers.
+func WithSerializer(s serializer.Serializer) Option {
+	return func(w *BatchWriter) {
+		w.serialiser = s.Serialize
+		w.contentType = s.ContentType()
+	}
+}
+
 func WithInstrumentation(i *otel.Instrumentation) Option {
 	return func(w *BatchWriter) {
+		w.instrumentation = i
 		instrumentedWriter, err := kafkainstrumentation.NewWriter(w.writer, i)
 		if err != nil {
 			w.logger.Error(err, "initialising kafka writer instrumentation")
@@ -145,9 +163,15 @@ func (w *BatchWriter) ProcessWALEvent(ctx context.Context, walEvent *wal.Event)
 		}
 
 		kafkaMsg = kafka.Message{
-			Key:   w.getMessageKey(walEvent.Data),
+			Key:   w.getMessageKey(walEvent),
 			Value: walDataBytes,
 		}
+		if w.contentType != "" {
+			kafkaMsg.Headers = []kafka.Header{{Key: contentTypeHeader, Value: []byte(w.contentType)}}
+		}
+		// propagate the trace context of the event, so that the consumers of
+		// the topic can continue the trace
+		kafka.InjectTraceContext(ctx, &kafkaMsg)
 	}
 
 	msg := batch.NewWALMessage(kafkaMsg, walEvent.CommitPosition)
@@ -163,6 +187,10 @@ func (w *BatchWriter) Close() error {
 	return w.writer.Close()
 }
 
+func (w *BatchWriter) Ping(ctx context.Context) error {
+	return w.writer.Ping(ctx)
+}
+
 func (w *BatchWriter) sendBatch(ctx context.Context, batch *batch.Batch[kafka.Message]) error {
 	messages := batch.GetMessages()
 	w.logger.Debug("kafka batch writer: sending message batch", loglib.Fields{
@@ -201,8 +229,14 @@ func (w *BatchWriter) sendBatch(ctx context.Context, batch *batch.Batch[kafka.Me
 // and therefore which order the events will be executed in. For schema logs,
 // the event schema is that of the pgstream schema, so we extract the underlying
 // user schema they're linked to, to make sure they're routed to the same
-// partition as their writes. This gives us ordering per schema.
-func (w BatchWriter) getMessageKey(walData *wal.Data) []byte {
+// partition as their writes. This gives us ordering per schema, unless a
+// partition key has been assigned to the event.
+func (w BatchWriter) getMessageKey(walEvent *wal.Event) []byte {
+	if walEvent.Kafka != nil && len(walEvent.Kafka.PartitionKey) > 0 {
+		return walEvent.Kafka.PartitionKey
+	}
+
+	walData := walEvent.Data
 	eventKey := walData.Schema
 	if processor.IsSchemaLogEvent(walData) {
 		var schemaName string
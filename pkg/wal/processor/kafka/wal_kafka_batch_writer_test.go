@@ -44,12 +44,13 @@ func TestBatchKafkaWriter_ProcessWALEvent(t *testing.T) {
 	testCommitPosition := wal.CommitPosition(testLSNStr)
 
 	testBytes := []byte("test")
-	mockMarshaler := func(any) ([]byte, error) { return testBytes, nil }
+	mockMarshaler := func(*wal.Data) ([]byte, error) { return testBytes, nil }
 
 	tests := []struct {
 		name            string
 		walEvent        *wal.Event
-		eventSerialiser func(any) ([]byte, error)
+		eventSerialiser func(*wal.Data) ([]byte, error)
+		contentType     string
 		batchSender     *batchmocks.BatchSender[kafka.Message]
 
 		wantMsgs []*batch.WALMessage[kafka.Message]
@@ -68,6 +69,38 @@ func TestBatchKafkaWriter_ProcessWALEvent(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "ok - with partition key",
+			walEvent: &wal.Event{
+				Data:           testWalEvent.Data,
+				CommitPosition: testCommitPosition,
+				Kafka:          &wal.KafkaMetadata{PartitionKey: []byte("test-key")},
+			},
+			batchSender: batchmocks.NewBatchSender[kafka.Message](),
+
+			wantMsgs: []*batch.WALMessage[kafka.Message]{
+				batch.NewWALMessage(kafka.Message{
+					Key:   []byte("test-key"),
+					Value: testBytes,
+				}, testCommitPosition),
+			},
+			wantErr: nil,
+		},
+		{
+			name:        "ok - with content type",
+			walEvent:    testWalEvent,
+			contentType: "application/msgpack",
+			batchSender: batchmocks.NewBatchSender[kafka.Message](),
+
+			wantMsgs: []*batch.WALMessage[kafka.Message]{
+				batch.NewWALMessage(kafka.Message{
+					Key:     []byte(testSchema),
+					Value:   testBytes,
+					Headers: []kafka.Header{{Key: "content-type", Value: []byte("application/msgpack")}},
+				}, testCommitPosition),
+			},
+			wantErr: nil,
+		},
 		{
 			name: "ok - keep alive",
 			walEvent: &wal.Event{
@@ -107,7 +140,7 @@ func TestBatchKafkaWriter_ProcessWALEvent(t *testing.T) {
 		{
 			name:            "ok - wal event too large, message dropped",
 			walEvent:        testWalEvent,
-			eventSerialiser: func(any) ([]byte, error) { return []byte(strings.Repeat("a", 101)), nil },
+			eventSerialiser: func(*wal.Data) ([]byte, error) { return []byte(strings.Repeat("a", 101)), nil },
 			batchSender:     batchmocks.NewBatchSender[kafka.Message](),
 
 			wantMsgs: []*batch.WALMessage[kafka.Message]{},
@@ -116,7 +149,7 @@ func TestBatchKafkaWriter_ProcessWALEvent(t *testing.T) {
 		{
 			name:            "error - marshaling event",
 			walEvent:        testWalEvent,
-			eventSerialiser: func(any) ([]byte, error) { return nil, errTest },
+			eventSerialiser: func(*wal.Data) ([]byte, error) { return nil, errTest },
 			batchSender:     batchmocks.NewBatchSender[kafka.Message](),
 
 			wantMsgs: []*batch.WALMessage[kafka.Message]{},
@@ -167,6 +200,7 @@ func TestBatchKafkaWriter_ProcessWALEvent(t *testing.T) {
 				logger:        loglib.NewNoopLogger(),
 				maxBatchBytes: 100,
 				serialiser:    mockMarshaler,
+				contentType:   tc.contentType,
 				batchSender:   tc.batchSender,
 			}
 
@@ -0,0 +1,297 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// MigrationSafeMode is a decorator around a wal processor that pauses the
+// processing of the wal events while a schema migration is running, as
+// signalled by a postgres advisory lock held by the migration tool wrapper.
+// While the lock is held, the events are buffered in memory instead of being
+// sent to the wrapped processor, which means they're not acknowledged either.
+// Once the buffer is full, the processing blocks until the lock is released,
+// keeping the remaining events in the replication slot. When the lock is
+// released, the buffered events are flushed in order and the processing
+// resumes.
+type MigrationSafeMode struct {
+	logger    loglib.Logger
+	processor processor.Processor
+	lockCheck lockCheckFn
+
+	checkInterval     time.Duration
+	maxBufferedEvents int
+
+	// mutex guards the pause state and the buffer, and serialises the calls
+	// to the wrapped processor
+	mutex   sync.Mutex
+	paused  bool
+	buffer  []*wal.Event
+	resumed chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	closer func() error
+}
+
+type Config struct {
+	// URL of the postgres database where the migration lock is acquired.
+	// Defaults to the source postgres URL.
+	URL string
+	// LockName is the name of the advisory lock held while the migrations
+	// run. The lock key is computed with hashtextextended(LockName, 0).
+	// Defaults to pgstream_migration_lock.
+	LockName string
+	// CheckInterval is the interval at which the lock is checked. Defaults to
+	// 1s.
+	CheckInterval time.Duration
+	// MaxBufferedEvents is the maximum number of events kept in memory while
+	// the lock is held. Defaults to 100000.
+	MaxBufferedEvents int
+}
+
+type Option func(m *MigrationSafeMode)
+
+// lockCheckFn returns true if the migration lock is currently held.
+type lockCheckFn func(ctx context.Context) (bool, error)
+
+const (
+	defaultLockName          = "pgstream_migration_lock"
+	defaultCheckInterval     = time.Second
+	defaultMaxBufferedEvents = 100000
+)
+
+// the advisory locks taken with a bigint key are reported in pg_locks with the
+// high 32 bits in classid, the low 32 bits in objid and objsubid set to 1.
+const lockHeldQuery = `SELECT EXISTS (
+	SELECT 1 FROM pg_locks
+	WHERE locktype = 'advisory'
+	AND granted
+	AND objsubid = 1
+	AND database = (SELECT oid FROM pg_database WHERE datname = current_database())
+	AND ((classid::bigint << 32) | objid::bigint) = hashtextextended($1, 0)
+)`
+
+var errMissingURL = errors.New("migration safe mode requires a postgres URL")
+
+// New will return a migration safe mode wrapper around the processor on
+// input. It performs an initial check of the migration lock, and keeps
+// checking it on the configured interval until closed.
+func New(ctx context.Context, cfg *Config, p processor.Processor, opts ...Option) (*MigrationSafeMode, error) {
+	if cfg.URL == "" {
+		return nil, errMissingURL
+	}
+
+	conn, err := pglib.NewConnPool(ctx, cfg.URL, pglib.WithMaxConnections(1))
+	if err != nil {
+		return nil, fmt.Errorf("creating migration lock connection: %w", err)
+	}
+
+	lockName := cfg.lockName()
+	m := newMigrationSafeMode(cfg, p, func(ctx context.Context) (bool, error) {
+		held := false
+		if err := conn.QueryRow(ctx, []any{&held}, lockHeldQuery, lockName); err != nil {
+			return false, fmt.Errorf("checking migration lock: %w", err)
+		}
+		return held, nil
+	})
+	m.closer = func() error { return conn.Close(context.Background()) }
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if err := m.checkLock(ctx); err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+
+	monitorCtx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.monitorLock(monitorCtx)
+	}()
+
+	return m, nil
+}
+
+func newMigrationSafeMode(cfg *Config, p processor.Processor, lockCheck lockCheckFn) *MigrationSafeMode {
+	return &MigrationSafeMode{
+		logger:            loglib.NewNoopLogger(),
+		processor:         p,
+		lockCheck:         lockCheck,
+		checkInterval:     cfg.checkInterval(),
+		maxBufferedEvents: cfg.maxBufferedEvents(),
+	}
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(m *MigrationSafeMode) {
+		m.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_migration_safe_mode",
+		})
+	}
+}
+
+// ProcessWALEvent sends the event on input to the wrapped processor, unless
+// the migration lock is held, in which case it's buffered until the lock is
+// released. It blocks while the lock is held and the buffer is full.
+func (m *MigrationSafeMode) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	for {
+		m.mutex.Lock()
+		if !m.paused {
+			err := m.flushBuffer(ctx)
+			if err == nil {
+				err = m.processor.ProcessWALEvent(ctx, event)
+			}
+			m.mutex.Unlock()
+			return err
+		}
+
+		// keep alive events are buffered too, since they could otherwise
+		// checkpoint positions past the buffered events
+		if len(m.buffer) < m.maxBufferedEvents {
+			m.buffer = append(m.buffer, event)
+			m.mutex.Unlock()
+			return nil
+		}
+
+		resumed := m.resumed
+		m.mutex.Unlock()
+
+		m.logger.Warn(nil, "migration safe mode buffer is full, waiting for the migration lock to be released", loglib.Fields{
+			"max_buffered_events": m.maxBufferedEvents,
+		})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-resumed:
+		}
+	}
+}
+
+func (m *MigrationSafeMode) Name() string {
+	return m.processor.Name()
+}
+
+// Close stops the migration lock monitoring and closes the wrapped processor.
+// Any buffered events are discarded. Since they were never acknowledged, they
+// will be received again when the replication restarts.
+func (m *MigrationSafeMode) Close() error {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+
+	m.mutex.Lock()
+	if len(m.buffer) > 0 {
+		m.logger.Warn(nil, "discarding unacknowledged events buffered during migration", loglib.Fields{
+			"buffered_events": len(m.buffer),
+		})
+		m.buffer = nil
+	}
+	m.mutex.Unlock()
+
+	var errs error
+	if m.closer != nil {
+		errs = m.closer()
+	}
+	return errors.Join(errs, m.processor.Close())
+}
+
+func (m *MigrationSafeMode) Ping(ctx context.Context) error {
+	return m.processor.Ping(ctx)
+}
+
+func (m *MigrationSafeMode) monitorLock(ctx context.Context) {
+	ticker := time.NewTicker(m.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.checkLock(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				m.logger.Error(err, "checking migration lock")
+			}
+		}
+	}
+}
+
+// checkLock updates the pause state based on the migration lock. When the
+// lock is released, the buffered events are flushed to the wrapped processor.
+// If the lock can't be checked, the current state is kept.
+func (m *MigrationSafeMode) checkLock(ctx context.Context) error {
+	held, err := m.lockCheck(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	switch {
+	case held && !m.paused:
+		m.logger.Info("migration lock acquired, pausing wal event processing")
+		m.paused = true
+		m.resumed = make(chan struct{})
+	case !held && m.paused:
+		m.logger.Info("migration lock released, resuming wal event processing", loglib.Fields{
+			"buffered_events": len(m.buffer),
+		})
+		m.paused = false
+		close(m.resumed)
+		// if the flush fails, the remaining events are kept in the buffer and
+		// the error is returned by the next ProcessWALEvent call
+		return m.flushBuffer(ctx)
+	}
+	return nil
+}
+
+// flushBuffer sends the buffered events to the wrapped processor in order.
+// Must be called with the mutex held.
+func (m *MigrationSafeMode) flushBuffer(ctx context.Context) error {
+	for len(m.buffer) > 0 {
+		if err := m.processor.ProcessWALEvent(ctx, m.buffer[0]); err != nil {
+			return fmt.Errorf("flushing events buffered during migration: %w", err)
+		}
+		m.buffer[0] = nil
+		m.buffer = m.buffer[1:]
+	}
+	m.buffer = nil
+	return nil
+}
+
+func (c *Config) lockName() string {
+	if c.LockName != "" {
+		return c.LockName
+	}
+	return defaultLockName
+}
+
+func (c *Config) checkInterval() time.Duration {
+	if c.CheckInterval > 0 {
+		return c.CheckInterval
+	}
+	return defaultCheckInterval
+}
+
+func (c *Config) maxBufferedEvents() int {
+	if c.MaxBufferedEvents > 0 {
+		return c.MaxBufferedEvents
+	}
+	return defaultMaxBufferedEvents
+}
@@ -0,0 +1,189 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package migration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+func TestMigrationSafeMode_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	testEvent := func(lsn string) *wal.Event {
+		return &wal.Event{CommitPosition: wal.CommitPosition(lsn)}
+	}
+	errTest := errors.New("oh noes")
+
+	t.Run("ok - lock not held", func(t *testing.T) {
+		t.Parallel()
+
+		processed := []*wal.Event{}
+		m := newMigrationSafeMode(&Config{}, &mocks.Processor{
+			ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+				processed = append(processed, event)
+				return nil
+			},
+		}, func(ctx context.Context) (bool, error) { return false, nil })
+
+		require.NoError(t, m.checkLock(context.Background()))
+		require.NoError(t, m.ProcessWALEvent(context.Background(), testEvent("1")))
+		require.Equal(t, []*wal.Event{testEvent("1")}, processed)
+	})
+
+	t.Run("ok - events buffered while lock held and flushed on release", func(t *testing.T) {
+		t.Parallel()
+
+		held := true
+		processed := []*wal.Event{}
+		m := newMigrationSafeMode(&Config{}, &mocks.Processor{
+			ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+				processed = append(processed, event)
+				return nil
+			},
+		}, func(ctx context.Context) (bool, error) { return held, nil })
+
+		require.NoError(t, m.checkLock(context.Background()))
+		require.NoError(t, m.ProcessWALEvent(context.Background(), testEvent("1")))
+		require.NoError(t, m.ProcessWALEvent(context.Background(), testEvent("2")))
+		require.Empty(t, processed)
+		require.Len(t, m.buffer, 2)
+
+		held = false
+		require.NoError(t, m.checkLock(context.Background()))
+		require.Equal(t, []*wal.Event{testEvent("1"), testEvent("2")}, processed)
+		require.Empty(t, m.buffer)
+
+		require.NoError(t, m.ProcessWALEvent(context.Background(), testEvent("3")))
+		require.Equal(t, []*wal.Event{testEvent("1"), testEvent("2"), testEvent("3")}, processed)
+	})
+
+	t.Run("ok - lock check error keeps current state", func(t *testing.T) {
+		t.Parallel()
+
+		var checkErr error
+		m := newMigrationSafeMode(&Config{}, &mocks.Processor{
+			ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+				return nil
+			},
+		}, func(ctx context.Context) (bool, error) { return true, checkErr })
+
+		require.NoError(t, m.checkLock(context.Background()))
+		require.True(t, m.paused)
+
+		checkErr = errTest
+		require.ErrorIs(t, m.checkLock(context.Background()), errTest)
+		require.True(t, m.paused)
+	})
+
+	t.Run("ok - buffer full blocks until lock released", func(t *testing.T) {
+		t.Parallel()
+
+		held := true
+		processed := make(chan *wal.Event, 3)
+		m := newMigrationSafeMode(&Config{MaxBufferedEvents: 1}, &mocks.Processor{
+			ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+				processed <- event
+				return nil
+			},
+		}, func(ctx context.Context) (bool, error) { return held, nil })
+
+		require.NoError(t, m.checkLock(context.Background()))
+		require.NoError(t, m.ProcessWALEvent(context.Background(), testEvent("1")))
+
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- m.ProcessWALEvent(context.Background(), testEvent("2"))
+		}()
+
+		select {
+		case err := <-errChan:
+			t.Fatalf("unexpected process return while buffer full: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		held = false
+		require.NoError(t, m.checkLock(context.Background()))
+		require.NoError(t, <-errChan)
+		require.Equal(t, testEvent("1"), <-processed)
+		require.Equal(t, testEvent("2"), <-processed)
+	})
+
+	t.Run("error - buffer full and context cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		m := newMigrationSafeMode(&Config{MaxBufferedEvents: 1}, &mocks.Processor{
+			ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+				return errors.New("unexpected call to ProcessWALEventFn")
+			},
+		}, func(ctx context.Context) (bool, error) { return true, nil })
+
+		require.NoError(t, m.checkLock(context.Background()))
+		require.NoError(t, m.ProcessWALEvent(context.Background(), testEvent("1")))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := m.ProcessWALEvent(ctx, testEvent("2"))
+		require.ErrorIs(t, err, context.Canceled)
+		require.Len(t, m.buffer, 1)
+	})
+
+	t.Run("error - flushing buffered events", func(t *testing.T) {
+		t.Parallel()
+
+		held := true
+		fail := true
+		processed := []*wal.Event{}
+		m := newMigrationSafeMode(&Config{}, &mocks.Processor{
+			ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+				if fail && event.CommitPosition == "2" {
+					return errTest
+				}
+				processed = append(processed, event)
+				return nil
+			},
+		}, func(ctx context.Context) (bool, error) { return held, nil })
+
+		require.NoError(t, m.checkLock(context.Background()))
+		require.NoError(t, m.ProcessWALEvent(context.Background(), testEvent("1")))
+		require.NoError(t, m.ProcessWALEvent(context.Background(), testEvent("2")))
+
+		held = false
+		require.ErrorIs(t, m.checkLock(context.Background()), errTest)
+		require.Equal(t, []*wal.Event{testEvent("1")}, processed)
+		require.Equal(t, []*wal.Event{testEvent("2")}, m.buffer)
+
+		// the remaining buffered events are sent before the next event
+		err := m.ProcessWALEvent(context.Background(), testEvent("3"))
+		require.ErrorIs(t, err, errTest)
+
+		fail = false
+		require.NoError(t, m.ProcessWALEvent(context.Background(), testEvent("3")))
+		require.Equal(t, []*wal.Event{testEvent("1"), testEvent("2"), testEvent("3")}, processed)
+	})
+}
+
+func TestMigrationSafeMode_Close(t *testing.T) {
+	t.Parallel()
+
+	closed := false
+	m := newMigrationSafeMode(&Config{}, &mocks.Processor{
+		CloseFn: func() error {
+			closed = true
+			return nil
+		},
+	}, func(ctx context.Context) (bool, error) { return true, nil })
+
+	require.NoError(t, m.checkLock(context.Background()))
+	require.NoError(t, m.ProcessWALEvent(context.Background(), &wal.Event{}))
+
+	require.NoError(t, m.Close())
+	require.True(t, closed)
+	require.Empty(t, m.buffer)
+}
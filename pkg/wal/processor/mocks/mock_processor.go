@@ -11,6 +11,7 @@ import (
 type Processor struct {
 	ProcessWALEventFn func(ctx context.Context, walEvent *wal.Event) error
 	CloseFn           func() error
+	PingFn            func(ctx context.Context) error
 	processCalls      uint
 }
 
@@ -33,3 +34,10 @@ func (m *Processor) Close() error {
 func (m *Processor) Name() string {
 	return "mock"
 }
+
+func (m *Processor) Ping(ctx context.Context) error {
+	if m.PingFn != nil {
+		return m.PingFn(ctx)
+	}
+	return nil
+}
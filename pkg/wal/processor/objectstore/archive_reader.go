@@ -0,0 +1,397 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// ArchiveReader reads the events archived by an object store sink, from the
+// objects under the configured prefix. The objects are read in the order of
+// their first LSN, and the rows of each object in the order they were
+// written. Since each object has the rows of a single table, the events of
+// different tables are only ordered by LSN within each batch.
+type ArchiveReader struct {
+	storage   objectStorage
+	prefix    string
+	lsnParser replication.LSNParser
+}
+
+// archivedObject is an object written by the object store sink, as identified
+// by its key
+type archivedObject struct {
+	key      string
+	schema   string
+	table    string
+	firstLSN replication.LSN
+	lastLSN  replication.LSN
+	format   Format
+}
+
+// localTimestampFormat is the format of the timestamps without time zone read
+// from the parquet objects
+const localTimestampFormat = "2006-01-02 15:04:05.999999"
+
+var actionCodes = map[string]wal.Action{
+	"insert":   wal.ActionInsert,
+	"update":   wal.ActionUpdate,
+	"delete":   wal.ActionDelete,
+	"truncate": wal.ActionTruncate,
+}
+
+var errInvalidRow = errors.New("invalid archived row")
+
+// NewArchiveReader returns a reader for the events archived by the object
+// store sink with the configuration on input. The format of the objects is
+// derived from their key, so the configured format is ignored.
+func NewArchiveReader(ctx context.Context, cfg *Config) (*ArchiveReader, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	storage, err := newObjectStorage(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return newArchiveReader(storage, cfg.Prefix), nil
+}
+
+func newArchiveReader(storage objectStorage, prefix string) *ArchiveReader {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &ArchiveReader{
+		storage:   storage,
+		prefix:    prefix,
+		lsnParser: pgreplication.NewLSNParser(),
+	}
+}
+
+// ReadEvents calls the function on input with every archived event, until it
+// returns an error. When the context has a replay range (see
+// wal.WithReplayContext), the objects with an LSN range outside of it are not
+// downloaded.
+func (r *ArchiveReader) ReadEvents(ctx context.Context, fn func(*wal.Data) error) error {
+	objects, err := r.archivedObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	var start, end replication.LSN
+	rc, isReplay := wal.ReplayContextFromContext(ctx)
+	if isReplay {
+		if start, err = r.lsnParser.FromString(rc.StartLSN); err != nil {
+			return fmt.Errorf("parsing replay start lsn: %w", err)
+		}
+		if end, err = r.lsnParser.FromString(rc.EndLSN); err != nil {
+			return fmt.Errorf("parsing replay end lsn: %w", err)
+		}
+	}
+
+	for _, obj := range objects {
+		if isReplay && (obj.lastLSN < start || obj.firstLSN > end) {
+			continue
+		}
+		if err := r.readObject(ctx, obj, fn); err != nil {
+			return fmt.Errorf("reading archived object %s: %w", obj.key, err)
+		}
+	}
+	return nil
+}
+
+func (r *ArchiveReader) Close() error {
+	return r.storage.close()
+}
+
+// archivedObjects returns the objects written by the sink, sorted by their
+// first LSN. Objects with a key that doesn't follow the sink format are
+// ignored.
+func (r *ArchiveReader) archivedObjects(ctx context.Context) ([]*archivedObject, error) {
+	keys, err := r.storage.listObjects(ctx, r.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*archivedObject, 0, len(keys))
+	for _, key := range keys {
+		if obj, ok := parseObjectKey(key, r.prefix); ok {
+			objects = append(objects, obj)
+		}
+	}
+	slices.SortStableFunc(objects, func(a, b *archivedObject) int {
+		if c := cmp.Compare(a.firstLSN, b.firstLSN); c != 0 {
+			return c
+		}
+		return strings.Compare(a.key, b.key)
+	})
+	return objects, nil
+}
+
+// parseObjectKey parses the keys in the format
+// <prefix><schema>.<table>/date=<date>/<first lsn>-<last lsn>-<hash>.<ext>, as
+// written by the sink.
+func parseObjectKey(key, prefix string) (*archivedObject, bool) {
+	parts := strings.Split(strings.TrimPrefix(key, prefix), "/")
+	if len(parts) != 3 || !strings.HasPrefix(parts[1], "date=") {
+		return nil, false
+	}
+	schema, table, found := strings.Cut(parts[0], ".")
+	if !found {
+		return nil, false
+	}
+	name, ext, found := strings.Cut(parts[2], ".")
+	if !found {
+		return nil, false
+	}
+	format := Format(ext)
+	if format != FormatJSONL && format != FormatParquet {
+		return nil, false
+	}
+	lsns := strings.Split(name, "-")
+	if len(lsns) != 3 {
+		return nil, false
+	}
+	firstLSN, err := strconv.ParseUint(lsns[0], 16, 64)
+	if err != nil {
+		return nil, false
+	}
+	lastLSN, err := strconv.ParseUint(lsns[1], 16, 64)
+	if err != nil {
+		return nil, false
+	}
+
+	return &archivedObject{
+		key:      key,
+		schema:   schema,
+		table:    table,
+		firstLSN: replication.LSN(firstLSN),
+		lastLSN:  replication.LSN(lastLSN),
+		format:   format,
+	}, true
+}
+
+func (r *ArchiveReader) readObject(ctx context.Context, obj *archivedObject, fn func(*wal.Data) error) error {
+	body, err := r.storage.getObject(ctx, obj.key)
+	if err != nil {
+		return err
+	}
+
+	var rows [][]wal.Column
+	switch obj.format {
+	case FormatParquet:
+		rows, err = readParquetRows(body)
+	default:
+		rows, err = readJSONLRows(body)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, fields := range rows {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := rowData(obj.schema, obj.table, fields)
+		if err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rowData returns the wal data of the row on input, with the metadata columns
+// as the action, LSN and timestamp of the event. The row columns are the
+// identity of deletes.
+func rowData(schema, table string, fields []wal.Column) (*wal.Data, error) {
+	data := &wal.Data{
+		Schema: schema,
+		Table:  table,
+	}
+	columns := []wal.Column{}
+	for _, field := range fields {
+		switch field.Name {
+		case ActionColumn:
+			action, _ := field.Value.(string)
+			code, found := actionCodes[action]
+			if !found {
+				return nil, fmt.Errorf("%w: unknown action %v", errInvalidRow, field.Value)
+			}
+			data.Action = string(code)
+		case LSNColumn:
+			data.LSN, _ = field.Value.(string)
+		case CommitTimestampColumn:
+			data.Timestamp, _ = field.Value.(string)
+		default:
+			columns = append(columns, field)
+		}
+	}
+	if data.Action == "" || data.LSN == "" {
+		return nil, fmt.Errorf("%w: missing action or lsn", errInvalidRow)
+	}
+
+	switch wal.Action(data.Action) {
+	case wal.ActionInsert, wal.ActionUpdate:
+		data.Columns = columns
+	case wal.ActionDelete:
+		data.Identity = columns
+	}
+	return data, nil
+}
+
+// readJSONLRows returns the fields of the rows of a JSONL object, in the
+// order they were written.
+func readJSONLRows(body []byte) ([][]wal.Column, error) {
+	rows := [][]wal.Column{}
+	for line := range bytes.Lines(body) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		fields, err := readJSONFields(line)
+		if err != nil {
+			return nil, fmt.Errorf("parsing row: %w", err)
+		}
+		rows = append(rows, fields)
+	}
+	return rows, nil
+}
+
+// readJSONFields returns the fields of the JSON object on input, keeping their
+// order.
+func readJSONFields(line []byte) ([]wal.Column, error) {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("%w: not a JSON object", errInvalidRow)
+	}
+
+	fields := []wal.Column{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		name, _ := token.(string)
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("column %s: %w", name, err)
+		}
+		fields = append(fields, wal.Column{Name: name, Value: value})
+	}
+	return fields, nil
+}
+
+// readParquetRows returns the fields of the rows of a parquet object, with the
+// columns in the order of the parquet schema. Dates, timestamps and decimals
+// are returned as strings.
+func readParquetRows(body []byte) ([][]wal.Column, error) {
+	file, err := parquet.OpenFile(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, fmt.Errorf("opening parquet file: %w", err)
+	}
+
+	schema := file.Schema()
+	leaves := make([]parquet.LeafColumn, 0, len(schema.Fields()))
+	for _, field := range schema.Fields() {
+		leaf, found := schema.Lookup(field.Name())
+		if !found {
+			return nil, fmt.Errorf("missing parquet column %s", field.Name())
+		}
+		leaves = append(leaves, leaf)
+	}
+
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+
+	rows := make([][]wal.Column, 0, file.NumRows())
+	buf := make([]parquet.Row, 128)
+	for {
+		n, readErr := reader.ReadRows(buf)
+		for _, row := range buf[:n] {
+			fields := make([]wal.Column, 0, len(leaves))
+			for _, leaf := range leaves {
+				name := leaf.Path[len(leaf.Path)-1]
+				value, err := parquetGoValue(leaf.Node.Type(), row[leaf.ColumnIndex])
+				if err != nil {
+					return nil, fmt.Errorf("column %s: %w", name, err)
+				}
+				fields = append(fields, wal.Column{Name: name, Value: value})
+			}
+			rows = append(rows, fields)
+		}
+		if errors.Is(readErr, io.EOF) {
+			return rows, nil
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("reading parquet rows: %w", readErr)
+		}
+	}
+}
+
+// parquetGoValue returns the go value of the parquet value on input, as per
+// the logical type of its column.
+func parquetGoValue(typ parquet.Type, v parquet.Value) (any, error) {
+	if v.IsNull() {
+		return nil, nil
+	}
+
+	logicalType := typ.LogicalType()
+	switch {
+	case logicalType != nil && logicalType.Date != nil:
+		return time.Unix(int64(v.Int32())*secondsPerDay, 0).UTC().Format(time.DateOnly), nil
+	case logicalType != nil && logicalType.Timestamp != nil:
+		t := time.UnixMicro(v.Int64()).UTC()
+		if logicalType.Timestamp.IsAdjustedToUTC {
+			return t.Format(time.RFC3339Nano), nil
+		}
+		return t.Format(localTimestampFormat), nil
+	case logicalType != nil && logicalType.Decimal != nil:
+		return decimalString(v.ByteArray(), int(logicalType.Decimal.Scale)), nil
+	}
+
+	switch v.Kind() {
+	case parquet.Boolean:
+		return v.Boolean(), nil
+	case parquet.Int32:
+		return v.Int32(), nil
+	case parquet.Int64:
+		return v.Int64(), nil
+	case parquet.Float:
+		return v.Float(), nil
+	case parquet.Double:
+		return v.Double(), nil
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		return string(v.ByteArray()), nil
+	default:
+		return nil, fmt.Errorf("%w: parquet %s value", errUnsupportedValue, v.Kind())
+	}
+}
+
+// decimalString returns the decimal number for the big endian two's
+// complement unscaled value on input.
+func decimalString(b []byte, scale int) string {
+	unscaled := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	return new(big.Rat).SetFrac(unscaled, pow).FloatString(scale)
+}
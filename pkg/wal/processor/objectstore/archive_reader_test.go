@@ -0,0 +1,220 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+)
+
+func TestArchiveReader_ReadEvents(t *testing.T) {
+	t.Parallel()
+
+	timestamp := "2024-01-01 23:59:59.5+00"
+	events := []*wal.Data{
+		newTestData(wal.ActionInsert, "test", "0/10", timestamp),
+		newTestData(wal.ActionUpdate, "test", "0/20", timestamp),
+		newTestData(wal.ActionDelete, "other", "0/50", timestamp),
+		{Action: string(wal.ActionTruncate), LSN: "0/40", Timestamp: timestamp, Schema: "public", Table: "test"},
+	}
+	events[2].Identity = events[2].Columns
+	events[2].Columns = nil
+
+	wantData := func(action wal.Action, table, lsn string, value any) *wal.Data {
+		d := &wal.Data{
+			Action:    string(action),
+			LSN:       lsn,
+			Timestamp: "2024-01-01T23:59:59.5Z",
+			Schema:    "public",
+			Table:     table,
+		}
+		switch action {
+		case wal.ActionInsert, wal.ActionUpdate:
+			d.Columns = []wal.Column{{Name: "id", Value: value}}
+		case wal.ActionDelete:
+			d.Identity = []wal.Column{{Name: "id", Value: value}}
+		}
+		return d
+	}
+
+	tests := []struct {
+		name     string
+		format   Format
+		replay   *wal.ReplayContext
+		wantData []*wal.Data
+	}{
+		{
+			name:   "ok - jsonl",
+			format: FormatJSONL,
+			wantData: []*wal.Data{
+				wantData(wal.ActionInsert, "test", "0/10", float64(1)),
+				wantData(wal.ActionUpdate, "test", "0/20", float64(1)),
+				wantData(wal.ActionTruncate, "test", "0/40", nil),
+				wantData(wal.ActionDelete, "other", "0/50", float64(1)),
+			},
+		},
+		{
+			name:   "ok - parquet",
+			format: FormatParquet,
+			wantData: []*wal.Data{
+				wantData(wal.ActionInsert, "test", "0/10", int32(1)),
+				wantData(wal.ActionUpdate, "test", "0/20", int32(1)),
+				wantData(wal.ActionTruncate, "test", "0/40", nil),
+				wantData(wal.ActionDelete, "other", "0/50", int32(1)),
+			},
+		},
+		{
+			name:   "ok - objects outside of the replay range skipped",
+			format: FormatJSONL,
+			replay: &wal.ReplayContext{IsReplay: true, StartLSN: "0/50", EndLSN: "0/50"},
+			wantData: []*wal.Data{
+				wantData(wal.ActionDelete, "other", "0/50", float64(1)),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			storage := &mockStorage{}
+			cfg := &Config{Bucket: "bucket", Prefix: "cdc", Format: tc.format}
+			sink := newTestSink(storage, cfg)
+			msgs := make([]message, 0, len(events))
+			for _, event := range events {
+				msg, err := sink.newMessage(event)
+				require.NoError(t, err)
+				msgs = append(msgs, msg)
+			}
+			require.NoError(t, sink.sendBatch(ctx, batch.NewBatch(msgs, nil)))
+			// objects of other prefixes or not written by the sink are ignored
+			storage.objects["cdc/README.md"] = []byte("archive")
+			storage.objects["other/public.test/date=2024-01-01/0000000000000001-0000000000000001-abcd.jsonl"] = []byte("{}")
+
+			if tc.replay != nil {
+				ctx = wal.WithReplayContext(ctx, *tc.replay)
+			}
+			data := []*wal.Data{}
+			err := newArchiveReader(storage, cfg.Prefix).ReadEvents(ctx, func(d *wal.Data) error {
+				data = append(data, d)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.wantData, data)
+		})
+	}
+}
+
+func TestArchiveReader_ReadEvents_error(t *testing.T) {
+	t.Parallel()
+
+	storage := &mockStorage{objects: map[string][]byte{
+		"public.test/date=2024-01-01/0000000000000010-0000000000000010-abcd.jsonl": []byte(`{"_action":"insert","_lsn":"0/10","_commit_timestamp":null,"id":1}` + "\n"),
+		"public.test/date=2024-01-01/0000000000000020-0000000000000020-abcd.jsonl": []byte(`{"_action":"upsert","_lsn":"0/20"}` + "\n"),
+	}}
+
+	lsns := []string{}
+	err := newArchiveReader(storage, "").ReadEvents(context.Background(), func(d *wal.Data) error {
+		lsns = append(lsns, d.LSN)
+		return nil
+	})
+	require.ErrorIs(t, err, errInvalidRow)
+	require.Equal(t, []string{"0/10"}, lsns)
+
+	err = newArchiveReader(storage, "").ReadEvents(context.Background(), func(d *wal.Data) error {
+		return errTest
+	})
+	require.ErrorIs(t, err, errTest)
+}
+
+func TestParseObjectKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		key  string
+
+		wantObject *archivedObject
+	}{
+		{
+			name: "ok - jsonl",
+			key:  "cdc/public.test/date=2024-01-02/0000000000000010-0000000000000020-abcd.jsonl",
+			wantObject: &archivedObject{
+				key:      "cdc/public.test/date=2024-01-02/0000000000000010-0000000000000020-abcd.jsonl",
+				schema:   "public",
+				table:    "test",
+				firstLSN: replication.LSN(16),
+				lastLSN:  replication.LSN(32),
+				format:   FormatJSONL,
+			},
+		},
+		{
+			name: "ok - parquet",
+			key:  "cdc/public.test/date=2024-01-02/00000001000000FF-0000000100000100-abcd.parquet",
+			wantObject: &archivedObject{
+				key:      "cdc/public.test/date=2024-01-02/00000001000000FF-0000000100000100-abcd.parquet",
+				schema:   "public",
+				table:    "test",
+				firstLSN: replication.LSN(0x1000000FF),
+				lastLSN:  replication.LSN(0x100000100),
+				format:   FormatParquet,
+			},
+		},
+		{
+			name: "unknown extension",
+			key:  "cdc/public.test/date=2024-01-02/0000000000000010-0000000000000020-abcd.csv",
+		},
+		{
+			name: "missing date partition",
+			key:  "cdc/public.test/0000000000000010-0000000000000020-abcd.jsonl",
+		},
+		{
+			name: "missing schema",
+			key:  "cdc/test/date=2024-01-02/0000000000000010-0000000000000020-abcd.jsonl",
+		},
+		{
+			name: "invalid lsn",
+			key:  "cdc/public.test/date=2024-01-02/0/10-0000000000000020-abcd.jsonl",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			obj, ok := parseObjectKey(tc.key, "cdc/")
+			require.Equal(t, tc.wantObject != nil, ok)
+			require.Equal(t, tc.wantObject, obj)
+		})
+	}
+}
+
+func TestDecimalString(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value []byte
+		scale int
+
+		want string
+	}{
+		{name: "positive", value: []byte{0x00, 0x00, 0x01, 0x2c}, scale: 2, want: "3.00"},
+		{name: "negative", value: []byte{0xff, 0xff, 0xfe, 0xd4}, scale: 2, want: "-3.00"},
+		{name: "no scale", value: []byte{0x7f}, scale: 0, want: "127"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, decimalString(tc.value, tc.scale))
+		})
+	}
+}
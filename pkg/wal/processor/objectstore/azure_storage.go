@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+)
+
+// azureStorage uploads the objects as block blobs to an Azure Blob Storage
+// container, authorised with the shared key of the storage account. Blobs
+// larger than the maximum size of a single upload (256MiB) are uploaded in
+// blocks of the part size, committed together once all of them have been
+// uploaded.
+type azureStorage struct {
+	client    *azblob.Client
+	container string
+	partSize  int64
+}
+
+// newAzureStorage returns an Azure storage for the container on input. The
+// endpoint defaults to the blob endpoint of the storage account.
+func newAzureStorage(endpoint, accountName, accountKey, container string, partSize int64) (*azureStorage, error) {
+	credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+	if err != nil {
+		return nil, fmt.Errorf("creating azure shared key credential: %w", err)
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", accountName)
+	}
+	client, err := azblob.NewClientWithSharedKeyCredential(endpoint, credential, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating azure blob client: %w", err)
+	}
+
+	return &azureStorage{
+		client:    client,
+		container: container,
+		partSize:  partSize,
+	}, nil
+}
+
+// putObject uploads the blob on input, overwriting it if it already exists.
+func (s *azureStorage) putObject(ctx context.Context, key string, body []byte, contentType string) error {
+	_, err := s.client.UploadBuffer(ctx, s.container, key, body, &azblob.UploadBufferOptions{
+		BlockSize: s.partSize,
+		HTTPHeaders: &blob.HTTPHeaders{
+			BlobContentType: &contentType,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("putting blob %s: %w", key, err)
+	}
+	return nil
+}
+
+// listObjects returns the names of the blobs with the prefix on input, in
+// lexicographical order.
+func (s *azureStorage) listObjects(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	pager := s.client.NewListBlobsFlatPager(s.container, &azblob.ListBlobsFlatOptions{
+		Prefix: &prefix,
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing blobs: %w", err)
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name != nil {
+				keys = append(keys, *item.Name)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (s *azureStorage) getObject(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.client.DownloadStream(ctx, s.container, key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting blob %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading blob %s: %w", key, err)
+	}
+	return body, nil
+}
+
+// ping checks the container exists and is accessible with the credentials.
+func (s *azureStorage) ping(ctx context.Context) error {
+	if _, err := s.client.ServiceClient().NewContainerClient(s.container).GetProperties(ctx, nil); err != nil {
+		return fmt.Errorf("checking container: %w", err)
+	}
+	return nil
+}
+
+func (s *azureStorage) close() error {
+	return nil
+}
@@ -0,0 +1,114 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testAzureKey = base64.StdEncoding.EncodeToString([]byte("account-key"))
+
+func newTestAzureStorage(t *testing.T, endpoint string, partSize int64) *azureStorage {
+	s, err := newAzureStorage(endpoint, "account", testAzureKey, "container", partSize)
+	require.NoError(t, err)
+	return s
+}
+
+func TestAzureStorage_putObject(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok - single blob", func(t *testing.T) {
+		t.Parallel()
+
+		server, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey account:"))
+			require.Equal(t, "BlockBlob", r.Header.Get("X-Ms-Blob-Type"))
+			require.Equal(t, "application/x-ndjson", r.Header.Get("X-Ms-Blob-Content-Type"))
+			w.WriteHeader(http.StatusCreated)
+			return true
+		})
+		s := newTestAzureStorage(t, httpServer.URL, minPartSize)
+
+		err := s.putObject(context.Background(), "public.test/date=2024-01-02/a.jsonl", []byte("body"), "application/x-ndjson")
+		require.NoError(t, err)
+		require.Equal(t, []string{"PUT /container/public.test/date=2024-01-02/a.jsonl"}, server.getRequests())
+		require.Equal(t, []byte("body"), server.bodies["PUT /container/public.test/date=2024-01-02/a.jsonl"])
+	})
+
+	t.Run("error - forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		server, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			w.Header().Set("X-Ms-Error-Code", "AuthenticationFailed")
+			w.WriteHeader(http.StatusForbidden)
+			return true
+		})
+		s := newTestAzureStorage(t, httpServer.URL, minPartSize)
+
+		err := s.putObject(context.Background(), "key", []byte("body"), "application/x-ndjson")
+		require.ErrorContains(t, err, "AuthenticationFailed")
+		require.Len(t, server.getRequests(), 1)
+	})
+}
+
+func TestAzureStorage_ping(t *testing.T) {
+	t.Parallel()
+
+	server, httpServer := newFakeObjectServer(t, nil)
+	s := newTestAzureStorage(t, httpServer.URL, minPartSize)
+
+	require.NoError(t, s.ping(context.Background()))
+	require.Equal(t, []string{"GET /container"}, server.getRequests())
+}
+
+func TestNewAzureStorage(t *testing.T) {
+	t.Parallel()
+
+	s := newTestAzureStorage(t, "", minPartSize)
+	require.Equal(t, "https://account.blob.core.windows.net", s.client.URL())
+
+	_, err := newAzureStorage("", "account", "not base64!", "container", minPartSize)
+	require.Error(t, err)
+}
+
+func TestAzureStorage_listObjects(t *testing.T) {
+	t.Parallel()
+
+	server, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		require.Equal(t, "list", r.URL.Query().Get("comp"))
+		require.Equal(t, "cdc/", r.URL.Query().Get("prefix"))
+		w.Header().Set("Content-Type", "application/xml")
+		fmt.Fprint(w, `<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>`+
+			`<Blob><Name>cdc/a.jsonl</Name><Properties/></Blob><Blob><Name>cdc/b.jsonl</Name><Properties/></Blob>`+
+			`</Blobs><NextMarker/></EnumerationResults>`)
+		return true
+	})
+	s := newTestAzureStorage(t, httpServer.URL, minPartSize)
+
+	keys, err := s.listObjects(context.Background(), "cdc/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"cdc/a.jsonl", "cdc/b.jsonl"}, keys)
+	require.Equal(t, []string{"GET /container"}, server.getRequests())
+}
+
+func TestAzureStorage_getObject(t *testing.T) {
+	t.Parallel()
+
+	server, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		fmt.Fprint(w, "body")
+		return true
+	})
+	s := newTestAzureStorage(t, httpServer.URL, minPartSize)
+
+	body, err := s.getObject(context.Background(), "cdc/a.jsonl")
+	require.NoError(t, err)
+	require.Equal(t, []byte("body"), body)
+	require.Equal(t, []string{"GET /container/cdc/a.jsonl"}, server.getRequests())
+}
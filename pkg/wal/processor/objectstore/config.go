@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"fmt"
+
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+type Config struct {
+	// Provider is the object storage service the objects are uploaded to.
+	// Defaults to s3.
+	Provider Provider
+	// Bucket the objects are uploaded to. For Azure Blob Storage, it's the
+	// name of the container.
+	Bucket string
+	// Prefix is prepended to the key of all the objects, i.e. pgstream/.
+	Prefix string
+	// Format of the objects. Defaults to jsonl.
+	Format Format
+	// Endpoint overrides the object storage endpoint, i.e. to use MinIO, a GCS
+	// emulator or Azurite. Requests to custom S3 endpoints use path style
+	// URLs. For Azure Blob Storage, it's the URL of the storage account blob
+	// service.
+	Endpoint string
+	// Region is the region of the S3 bucket. Defaults to the region of the
+	// AWS default configuration (i.e. AWS_REGION).
+	Region string
+	// AccessKeyID and SecretAccessKey are the credentials used with S3.
+	// Defaults to the AWS default credential chain.
+	AccessKeyID     string
+	SecretAccessKey string
+	// CredentialsFile is the path to the service account JSON key file used
+	// with GCS. Defaults to the application default credentials.
+	CredentialsFile string
+	// AccountName and AccountKey are the shared key credentials of the Azure
+	// storage account.
+	AccountName string
+	AccountKey  string
+	// PartSize is the size in bytes from which the objects are uploaded in
+	// multiple parts, and the size of each part. Azure Blob Storage only
+	// uploads the blobs larger than 256MiB in parts. Defaults to 16MiB, with a
+	// minimum of 5MiB.
+	PartSize int64
+
+	// Batch thresholds are the size and time thresholds at which the buffered
+	// events are flushed into objects.
+	Batch batch.Config
+}
+
+type Provider string
+
+const (
+	ProviderS3    Provider = "s3"
+	ProviderGCS   Provider = "gcs"
+	ProviderAzure Provider = "azure"
+)
+
+type Format string
+
+const (
+	FormatJSONL   Format = "jsonl"
+	FormatParquet Format = "parquet"
+)
+
+const (
+	defaultPartSize = 16 * 1024 * 1024
+	minPartSize     = 5 * 1024 * 1024
+)
+
+func (c *Config) provider() Provider {
+	if c.Provider != "" {
+		return c.Provider
+	}
+	return ProviderS3
+}
+
+func (c *Config) format() Format {
+	if c.Format != "" {
+		return c.Format
+	}
+	return FormatJSONL
+}
+
+func (c *Config) partSize() int64 {
+	switch {
+	case c.PartSize <= 0:
+		return defaultPartSize
+	case c.PartSize < minPartSize:
+		return minPartSize
+	default:
+		return c.PartSize
+	}
+}
+
+func (c *Config) validate() error {
+	if c.Bucket == "" {
+		return errMissingBucket
+	}
+
+	switch c.format() {
+	case FormatJSONL, FormatParquet:
+	default:
+		return fmt.Errorf("%w: %q", errUnsupportedFormat, c.Format)
+	}
+
+	switch c.provider() {
+	case ProviderS3, ProviderGCS:
+	case ProviderAzure:
+		if c.AccountName == "" || c.AccountKey == "" {
+			return errMissingAzureCredentials
+		}
+	default:
+		return fmt.Errorf("%w: %q", errUnsupportedProvider, c.Provider)
+	}
+	return nil
+}
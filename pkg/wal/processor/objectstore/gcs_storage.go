@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+// gcsStorage uploads the objects to a Google Cloud Storage bucket with the
+// JSON API. Objects larger than the part size are uploaded with a resumable
+// upload, in chunks of the part size.
+type gcsStorage struct {
+	client   *storage.Client
+	bucket   *storage.BucketHandle
+	partSize int64
+}
+
+// newGCSStorage returns a GCS storage for the bucket on input, authorised with
+// the service account key file on input, or the application default
+// credentials if empty.
+func newGCSStorage(ctx context.Context, endpoint, credentialsFile, bucket string, partSize int64) (*gcsStorage, error) {
+	opts := []option.ClientOption{}
+	if endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+	if credentialsFile != "" {
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating gcs client: %w", err)
+	}
+
+	return newGCSStorageWithClient(client, bucket, partSize), nil
+}
+
+func newGCSStorageWithClient(client *storage.Client, bucket string, partSize int64) *gcsStorage {
+	return &gcsStorage{
+		client:   client,
+		bucket:   client.Bucket(bucket),
+		partSize: partSize,
+	}
+}
+
+// putObject uploads the object on input, overwriting it if it already exists.
+// The object keys are derived from their content, so the uploads are retried
+// even though they're not conditional.
+func (s *gcsStorage) putObject(ctx context.Context, key string, body []byte, contentType string) error {
+	obj := s.bucket.Object(key).Retryer(storage.WithPolicy(storage.RetryAlways))
+
+	w := obj.NewWriter(ctx)
+	w.ContentType = contentType
+	w.ChunkSize = int(s.partSize)
+	if _, err := w.Write(body); err != nil {
+		w.Close()
+		return fmt.Errorf("putting object %s: %w", key, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("putting object %s: %w", key, err)
+	}
+	return nil
+}
+
+// listObjects returns the names of the objects with the prefix on input, in
+// lexicographical order.
+func (s *gcsStorage) listObjects(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	query := &storage.Query{Prefix: prefix}
+	if err := query.SetAttrSelection([]string{"Name"}); err != nil {
+		return nil, fmt.Errorf("listing objects: %w", err)
+	}
+	it := s.bucket.Objects(ctx, query)
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			return keys, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing objects: %w", err)
+		}
+		keys = append(keys, attrs.Name)
+	}
+}
+
+func (s *gcsStorage) getObject(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.bucket.Object(key).NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("getting object %s: %w", key, err)
+	}
+	defer r.Close()
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading object %s: %w", key, err)
+	}
+	return body, nil
+}
+
+// ping checks the bucket exists and is accessible with the credentials.
+func (s *gcsStorage) ping(ctx context.Context) error {
+	if _, err := s.bucket.Attrs(ctx); err != nil {
+		return fmt.Errorf("checking bucket: %w", err)
+	}
+	return nil
+}
+
+func (s *gcsStorage) close() error {
+	return s.client.Close()
+}
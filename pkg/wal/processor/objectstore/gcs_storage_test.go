@@ -0,0 +1,104 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func newTestGCSStorage(t *testing.T, endpoint string) *gcsStorage {
+	client, err := storage.NewClient(context.Background(),
+		option.WithEndpoint(endpoint+"/storage/v1/"),
+		option.WithoutAuthentication())
+	require.NoError(t, err)
+	s := newGCSStorageWithClient(client, "bucket", minPartSize)
+	t.Cleanup(func() { s.close() })
+	return s
+}
+
+func TestGCSStorage_putObject(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+
+		server, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			require.Equal(t, "public.test/date=2024-01-02/a.jsonl", r.URL.Query().Get("name"))
+			fmt.Fprint(w, `{"bucket":"bucket","name":"public.test/date=2024-01-02/a.jsonl"}`)
+			return true
+		})
+		s := newTestGCSStorage(t, httpServer.URL)
+
+		err := s.putObject(context.Background(), "public.test/date=2024-01-02/a.jsonl", []byte("body"), "application/x-ndjson")
+		require.NoError(t, err)
+		require.Equal(t, []string{"POST /upload/storage/v1/b/bucket/o"}, server.getRequests())
+		body := string(server.bodies["POST /upload/storage/v1/b/bucket/o"])
+		require.Contains(t, body, `"contentType":"application/x-ndjson"`)
+		require.Contains(t, body, "\r\nbody\r\n")
+	})
+
+	t.Run("error - forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		_, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error":{"code":403,"message":"oh noes"}}`)
+			return true
+		})
+		s := newTestGCSStorage(t, httpServer.URL)
+
+		err := s.putObject(context.Background(), "key", []byte("body"), "application/x-ndjson")
+		require.ErrorContains(t, err, "oh noes")
+	})
+}
+
+func TestGCSStorage_ping(t *testing.T) {
+	t.Parallel()
+
+	server, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		fmt.Fprint(w, `{"name":"bucket"}`)
+		return true
+	})
+	s := newTestGCSStorage(t, httpServer.URL)
+
+	require.NoError(t, s.ping(context.Background()))
+	require.Equal(t, []string{"GET /storage/v1/b/bucket"}, server.getRequests())
+}
+
+func TestGCSStorage_listObjects(t *testing.T) {
+	t.Parallel()
+
+	server, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		require.Equal(t, "cdc/", r.URL.Query().Get("prefix"))
+		fmt.Fprint(w, `{"items":[{"name":"cdc/a.jsonl"},{"name":"cdc/b.jsonl"}]}`)
+		return true
+	})
+	s := newTestGCSStorage(t, httpServer.URL)
+
+	keys, err := s.listObjects(context.Background(), "cdc/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"cdc/a.jsonl", "cdc/b.jsonl"}, keys)
+	require.Equal(t, []string{"GET /storage/v1/b/bucket/o"}, server.getRequests())
+}
+
+func TestGCSStorage_getObject(t *testing.T) {
+	t.Parallel()
+
+	server, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		fmt.Fprint(w, "body")
+		return true
+	})
+	s := newTestGCSStorage(t, httpServer.URL)
+
+	body, err := s.getObject(context.Background(), "cdc/a.jsonl")
+	require.NoError(t, err)
+	require.Equal(t, []byte("body"), body)
+	require.Equal(t, []string{"GET /bucket/cdc/a.jsonl"}, server.getRequests())
+}
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// row is a change to a table row, as written to the objects. Besides the
+// values of the row columns, every row has the action, LSN and commit
+// timestamp of the change.
+type row struct {
+	action    string
+	lsn       string
+	timestamp *time.Time
+	// columns are the new values of the row for inserts and updates, and its
+	// identity for deletes
+	columns []wal.Column
+	// line is the row encoded as a JSON object
+	line []byte
+}
+
+// Metadata columns of the rows, prefixed so that they don't clash with the
+// table columns.
+const (
+	ActionColumn          = "_action"
+	LSNColumn             = "_lsn"
+	CommitTimestampColumn = "_commit_timestamp"
+)
+
+var actionNames = map[wal.Action]string{
+	wal.ActionInsert:   "insert",
+	wal.ActionUpdate:   "update",
+	wal.ActionDelete:   "delete",
+	wal.ActionTruncate: "truncate",
+}
+
+// newRow returns the row for the wal data on input, or nil if it's not a
+// table operation.
+func newRow(d *wal.Data) (*row, error) {
+	action, found := actionNames[wal.Action(d.Action)]
+	if !found {
+		return nil, nil
+	}
+
+	r := &row{
+		action: action,
+		lsn:    d.LSN,
+	}
+	// snapshot events don't have a commit timestamp
+	if timestamp, err := d.GetTimestamp(); err == nil {
+		utc := timestamp.UTC()
+		r.timestamp = &utc
+	}
+
+	switch wal.Action(d.Action) {
+	case wal.ActionInsert, wal.ActionUpdate:
+		r.columns = d.Columns
+	case wal.ActionDelete:
+		r.columns = d.Identity
+	}
+
+	var err error
+	if r.line, err = r.marshalJSON(); err != nil {
+		return nil, fmt.Errorf("encoding row: %w", err)
+	}
+	return r, nil
+}
+
+// marshalJSON encodes the row as a flat JSON object, with the metadata
+// columns first, followed by the row columns in order.
+func (r *row) marshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	writeField := func(name string, value any) error {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("column %s: %w", name, err)
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(v)
+		return nil
+	}
+
+	var timestamp any
+	if r.timestamp != nil {
+		timestamp = r.timestamp.Format(time.RFC3339Nano)
+	}
+	if err := writeField(ActionColumn, r.action); err != nil {
+		return nil, err
+	}
+	if err := writeField(LSNColumn, r.lsn); err != nil {
+		return nil, err
+	}
+	if err := writeField(CommitTimestampColumn, timestamp); err != nil {
+		return nil, err
+	}
+	for _, col := range r.columns {
+		if err := writeField(col.Name, col.Value); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// encoder encodes the rows of a table into an object.
+type encoder interface {
+	// encode returns the object with the rows on input. The table is the
+	// latest schema log definition of their table, or nil if there's none.
+	encode(table *schemalog.Table, rows []*row) ([]byte, error)
+	extension() string
+	contentType() string
+}
+
+func newEncoder(format Format) encoder {
+	if format == FormatParquet {
+		return &parquetEncoder{}
+	}
+	return &jsonlEncoder{}
+}
+
+// jsonlEncoder writes the rows as newline delimited JSON objects.
+type jsonlEncoder struct{}
+
+func (e *jsonlEncoder) encode(_ *schemalog.Table, rows []*row) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for _, r := range rows {
+		buf.Write(r.line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *jsonlEncoder) extension() string {
+	return "jsonl"
+}
+
+func (e *jsonlEncoder) contentType() string {
+	return "application/x-ndjson"
+}
+
+// parquetEncoder writes the rows as a parquet file, with the schema derived
+// from the schema log definition of the table.
+type parquetEncoder struct{}
+
+func (e *parquetEncoder) encode(table *schemalog.Table, rows []*row) ([]byte, error) {
+	columns, indexes := parquetColumns(table, rows)
+
+	values := make([][]any, 0, len(rows))
+	for _, r := range rows {
+		rowValues := make([]any, len(columns))
+		rowValues[0] = r.action
+		rowValues[1] = r.lsn
+		if r.timestamp != nil {
+			rowValues[2] = r.timestamp.UnixMicro()
+		}
+		for _, col := range r.columns {
+			i := indexes[col.Name]
+			v, err := columns[i].value(col.Value)
+			if err != nil {
+				return nil, fmt.Errorf("encoding row with lsn %s: %w", r.lsn, err)
+			}
+			rowValues[i] = v
+		}
+		values = append(values, rowValues)
+	}
+
+	return writeParquet(columns, values)
+}
+
+func (e *parquetEncoder) extension() string {
+	return "parquet"
+}
+
+func (e *parquetEncoder) contentType() string {
+	return "application/vnd.apache.parquet"
+}
+
+// parquetColumns returns the parquet columns for the rows on input, along with
+// the index of each column by name. The table columns follow the metadata
+// columns, in the order of the schema log definition of the table. Columns of
+// the rows missing from the definition, if any, are appended with the type of
+// their wal values.
+func parquetColumns(table *schemalog.Table, rows []*row) ([]parquetColumn, map[string]int) {
+	columns := []parquetColumn{
+		{name: ActionColumn, typ: parquetString},
+		{name: LSNColumn, typ: parquetString},
+		{name: CommitTimestampColumn, typ: parquetTimestamp, adjustedToUTC: true},
+	}
+	indexes := map[string]int{}
+	addColumn := func(name, pgType string) {
+		if _, found := indexes[name]; found {
+			return
+		}
+		indexes[name] = len(columns)
+		columns = append(columns, newParquetColumn(name, pgType))
+	}
+
+	if table != nil {
+		for _, col := range table.Columns {
+			addColumn(col.Name, col.DataType)
+		}
+	}
+	for _, r := range rows {
+		for _, col := range r.columns {
+			addColumn(col.Name, col.Type)
+		}
+	}
+	return columns, indexes
+}
@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestNewRow(t *testing.T) {
+	t.Parallel()
+
+	testTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	columns := []wal.Column{
+		{Name: "id", Type: "integer", Value: 1},
+		{Name: "name", Type: "text", Value: "a"},
+	}
+	identity := []wal.Column{{Name: "id", Type: "integer", Value: 1}}
+
+	tests := []struct {
+		name string
+		data *wal.Data
+
+		wantRow  *row
+		wantLine string
+	}{
+		{
+			name: "insert",
+			data: &wal.Data{Action: "I", LSN: "0/10", Timestamp: "2024-01-02 03:04:05.000000+00", Columns: columns},
+
+			wantRow:  &row{action: "insert", lsn: "0/10", timestamp: &testTime, columns: columns},
+			wantLine: `{"_action":"insert","_lsn":"0/10","_commit_timestamp":"2024-01-02T03:04:05Z","id":1,"name":"a"}`,
+		},
+		{
+			name: "delete",
+			data: &wal.Data{Action: "D", LSN: "0/11", Timestamp: "2024-01-02 03:04:05.000000+00", Columns: columns, Identity: identity},
+
+			wantRow:  &row{action: "delete", lsn: "0/11", timestamp: &testTime, columns: identity},
+			wantLine: `{"_action":"delete","_lsn":"0/11","_commit_timestamp":"2024-01-02T03:04:05Z","id":1}`,
+		},
+		{
+			name: "truncate",
+			data: &wal.Data{Action: "T", LSN: "0/12", Timestamp: "2024-01-02 03:04:05.000000+00"},
+
+			wantRow:  &row{action: "truncate", lsn: "0/12", timestamp: &testTime},
+			wantLine: `{"_action":"truncate","_lsn":"0/12","_commit_timestamp":"2024-01-02T03:04:05Z"}`,
+		},
+		{
+			name: "snapshot insert without commit timestamp",
+			data: &wal.Data{Action: "I", LSN: wal.ZeroLSN, Columns: columns},
+
+			wantRow:  &row{action: "insert", lsn: wal.ZeroLSN, columns: columns},
+			wantLine: `{"_action":"insert","_lsn":"0/0","_commit_timestamp":null,"id":1,"name":"a"}`,
+		},
+		{
+			name: "not a table operation",
+			data: &wal.Data{Action: "C", LSN: "0/13"},
+
+			wantRow: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := newRow(tc.data)
+			require.NoError(t, err)
+			if tc.wantRow == nil {
+				require.Nil(t, r)
+				return
+			}
+			require.Equal(t, tc.wantLine, string(r.line))
+			r.line = nil
+			require.Equal(t, tc.wantRow, r)
+		})
+	}
+}
+
+func TestJSONLEncoder_encode(t *testing.T) {
+	t.Parallel()
+
+	body, err := newEncoder(FormatJSONL).encode(nil, []*row{
+		{line: []byte(`{"a":1}`)},
+		{line: []byte(`{"a":2}`)},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "{\"a\":1}\n{\"a\":2}\n", string(body))
+}
+
+func TestParquetColumns(t *testing.T) {
+	t.Parallel()
+
+	metadataColumns := []parquetColumn{
+		{name: ActionColumn, typ: parquetString},
+		{name: LSNColumn, typ: parquetString},
+		{name: CommitTimestampColumn, typ: parquetTimestamp, adjustedToUTC: true},
+	}
+	rows := []*row{
+		{columns: []wal.Column{{Name: "id", Type: "bigint"}, {Name: "extra", Type: "boolean"}}},
+		{columns: []wal.Column{{Name: "id", Type: "bigint"}}},
+	}
+
+	t.Run("schema log table definition", func(t *testing.T) {
+		t.Parallel()
+
+		table := &schemalog.Table{
+			Name: "test",
+			Columns: []schemalog.Column{
+				{Name: "price", DataType: "numeric(10,2)"},
+				{Name: "id", DataType: "bigint"},
+			},
+		}
+		columns, indexes := parquetColumns(table, rows)
+		require.Equal(t, append(metadataColumns,
+			parquetColumn{name: "price", typ: parquetDecimal, precision: 10, scale: 2},
+			parquetColumn{name: "id", typ: parquetInt64},
+			// columns missing from the definition are appended
+			parquetColumn{name: "extra", typ: parquetBoolean},
+		), columns)
+		require.Equal(t, map[string]int{"price": 3, "id": 4, "extra": 5}, indexes)
+	})
+
+	t.Run("derived from the events", func(t *testing.T) {
+		t.Parallel()
+
+		columns, _ := parquetColumns(nil, rows)
+		require.Equal(t, append(metadataColumns,
+			parquetColumn{name: "id", typ: parquetInt64},
+			parquetColumn{name: "extra", typ: parquetBoolean},
+		), columns)
+	})
+}
+
+func TestParquetEncoder_encode(t *testing.T) {
+	t.Parallel()
+
+	testTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	e := newEncoder(FormatParquet)
+
+	body, err := e.encode(nil, []*row{
+		{action: "insert", lsn: "0/10", timestamp: &testTime, columns: []wal.Column{{Name: "id", Type: "integer", Value: float64(1)}}},
+		{action: "truncate", lsn: "0/11", timestamp: &testTime},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "PAR1", string(body[:4]))
+
+	_, err = e.encode(nil, []*row{
+		{action: "insert", lsn: "0/10", columns: []wal.Column{{Name: "id", Type: "integer", Value: "not a number"}}},
+	})
+	require.ErrorIs(t, err, errUnsupportedValue)
+}
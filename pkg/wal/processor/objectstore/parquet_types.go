@@ -0,0 +1,325 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xataio/pgstream/internal/json"
+)
+
+const (
+	// maxDecimalPrecision is the largest numeric precision written as a
+	// parquet decimal, since most readers don't support larger ones. Larger
+	// numerics, and numerics without a precision, are written as strings.
+	maxDecimalPrecision = 38
+	secondsPerDay       = 24 * 60 * 60
+)
+
+var (
+	// pgTypeModifiers matches the modifiers of a postgres type, i.e. the
+	// precision and scale of numeric(10,2)
+	pgTypeModifiers = regexp.MustCompile(`\(\s*([0-9]+)\s*(?:,\s*([0-9]+)\s*)?\)`)
+
+	// timeLayouts are the formats of the postgres timestamps and dates, with
+	// and without time zone
+	timeLayouts = []string{
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999Z07",
+		"2006-01-02 15:04:05.999999999",
+		time.RFC3339Nano,
+		"2006-01-02T15:04:05.999999999",
+		time.DateOnly,
+	}
+
+	errUnsupportedValue = errors.New("unsupported value")
+)
+
+// newParquetColumn returns the parquet column for the postgres column on
+// input. Booleans, integers, floating point numbers, numerics with a
+// precision, dates and timestamps are mapped to their parquet types, and all
+// the other types are written as strings.
+func newParquetColumn(name, pgType string) parquetColumn {
+	col := parquetColumn{name: name, typ: parquetString}
+
+	t := strings.ToLower(strings.TrimSpace(pgType))
+	if strings.HasSuffix(t, "[]") {
+		return col
+	}
+	var modifiers []string
+	if m := pgTypeModifiers.FindStringSubmatch(t); m != nil {
+		modifiers = m[1:]
+		t = strings.Join(strings.Fields(strings.Replace(t, m[0], " ", 1)), " ")
+	}
+
+	switch t {
+	case "boolean", "bool":
+		col.typ = parquetBoolean
+	case "smallint", "integer", "int", "int2", "int4", "smallserial", "serial", "serial2", "serial4":
+		col.typ = parquetInt32
+	case "bigint", "int8", "bigserial", "serial8":
+		col.typ = parquetInt64
+	case "real", "float4":
+		col.typ = parquetFloat
+	case "double precision", "float8":
+		col.typ = parquetDouble
+	case "numeric", "decimal":
+		if len(modifiers) == 0 {
+			break
+		}
+		precision, _ := strconv.Atoi(modifiers[0])
+		scale, _ := strconv.Atoi(modifiers[1])
+		if precision > 0 && precision <= maxDecimalPrecision {
+			col.typ = parquetDecimal
+			col.precision = precision
+			col.scale = scale
+		}
+	case "date":
+		col.typ = parquetDate
+	case "timestamp", "timestamp without time zone":
+		col.typ = parquetTimestamp
+	case "timestamptz", "timestamp with time zone":
+		col.typ = parquetTimestamp
+		col.adjustedToUTC = true
+	}
+	return col
+}
+
+// value converts the wal value on input into the go type of the physical type
+// of the column.
+func (c parquetColumn) value(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	var value any
+	var err error
+	switch c.typ {
+	case parquetBoolean:
+		value, err = toBool(v)
+	case parquetInt32:
+		var i int64
+		if i, err = toInt64(v); err == nil {
+			if i < math.MinInt32 || i > math.MaxInt32 {
+				return nil, fmt.Errorf("%w: %d overflows int32", errUnsupportedValue, i)
+			}
+			value = int32(i)
+		}
+	case parquetInt64:
+		value, err = toInt64(v)
+	case parquetFloat:
+		var f float64
+		if f, err = toFloat64(v); err == nil {
+			value = float32(f)
+		}
+	case parquetDouble:
+		value, err = toFloat64(v)
+	case parquetDate:
+		var t time.Time
+		if t, err = toTime(v, false); err == nil {
+			value = daysSinceEpoch(t)
+		}
+	case parquetTimestamp:
+		var t time.Time
+		if t, err = toTime(v, c.adjustedToUTC); err == nil {
+			value = t.UnixMicro()
+		}
+	case parquetDecimal:
+		value, err = toDecimal(v, c.scale)
+	default:
+		value, err = toString(v)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("column %s: %w", c.name, err)
+	}
+	return value, nil
+}
+
+func toBool(v any) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, fmt.Errorf("%w: %q is not a boolean", errUnsupportedValue, v)
+		}
+		return b, nil
+	default:
+		return false, fmt.Errorf("%w: %T is not a boolean", errUnsupportedValue, v)
+	}
+}
+
+func toInt64(v any) (int64, error) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, fmt.Errorf("%w: %d overflows int64", errUnsupportedValue, v)
+		}
+		return int64(v), nil
+	case float32:
+		return toInt64(float64(v))
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return 0, fmt.Errorf("%w: %v is not an integer", errUnsupportedValue, v)
+		}
+		return int64(v), nil
+	case string:
+		return parseInt64(v)
+	case fmt.Stringer:
+		// i.e. json.Number
+		return parseInt64(v.String())
+	default:
+		return 0, fmt.Errorf("%w: %T is not an integer", errUnsupportedValue, v)
+	}
+}
+
+func parseInt64(s string) (int64, error) {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not an integer", errUnsupportedValue, s)
+	}
+	return i, nil
+}
+
+func toFloat64(v any) (float64, error) {
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case string:
+		return parseFloat64(v)
+	case fmt.Stringer:
+		return parseFloat64(v.String())
+	default:
+		i, err := toInt64(v)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %T is not a number", errUnsupportedValue, v)
+		}
+		return float64(i), nil
+	}
+}
+
+func parseFloat64(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is not a number", errUnsupportedValue, s)
+	}
+	return f, nil
+}
+
+// toTime returns the time for the timestamp or date on input. Timestamps
+// without time zone are returned with the wall clock of the value in UTC, so
+// that they're written as local timestamps.
+func toTime(v any, adjustedToUTC bool) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		if adjustedToUTC {
+			return v, nil
+		}
+		return time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), time.UTC), nil
+	case string:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("%w: %q is not a timestamp", errUnsupportedValue, v)
+	default:
+		return time.Time{}, fmt.Errorf("%w: %T is not a timestamp", errUnsupportedValue, v)
+	}
+}
+
+func daysSinceEpoch(t time.Time) int32 {
+	date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix()
+	days := date / secondsPerDay
+	if date%secondsPerDay < 0 {
+		days--
+	}
+	return int32(days)
+}
+
+// toDecimal returns the unscaled value of the numeric on input, as a big endian
+// two's complement integer.
+func toDecimal(v any, scale int) ([]byte, error) {
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case fmt.Stringer:
+		s = v.String()
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %T is not a numeric", errUnsupportedValue, v)
+		}
+		s = strings.Trim(string(b), `"`)
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not a decimal number", errUnsupportedValue, s)
+	}
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	r.Mul(r, new(big.Rat).SetInt(pow))
+	unscaled := new(big.Int).Quo(r.Num(), r.Denom())
+	return twosComplement(unscaled), nil
+}
+
+func twosComplement(x *big.Int) []byte {
+	if x.Sign() >= 0 {
+		b := x.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return b
+	}
+	// the smallest number of bytes that fits the negative number, which is
+	// then offset by 2^(8*n)
+	n := new(big.Int).Not(x).BitLen()/8 + 1
+	offset := new(big.Int).Lsh(big.NewInt(1), uint(8*n))
+	return new(big.Int).Add(x, offset).Bytes()
+}
+
+func toString(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", errUnsupportedValue, err)
+		}
+		return string(b), nil
+	}
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewParquetColumn(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pgType string
+
+		wantColumn parquetColumn
+	}{
+		{pgType: "boolean", wantColumn: parquetColumn{typ: parquetBoolean}},
+		{pgType: "integer", wantColumn: parquetColumn{typ: parquetInt32}},
+		{pgType: "smallint", wantColumn: parquetColumn{typ: parquetInt32}},
+		{pgType: "bigint", wantColumn: parquetColumn{typ: parquetInt64}},
+		{pgType: "real", wantColumn: parquetColumn{typ: parquetFloat}},
+		{pgType: "double precision", wantColumn: parquetColumn{typ: parquetDouble}},
+		{pgType: "numeric(10,2)", wantColumn: parquetColumn{typ: parquetDecimal, precision: 10, scale: 2}},
+		{pgType: "numeric(5)", wantColumn: parquetColumn{typ: parquetDecimal, precision: 5}},
+		{pgType: "numeric", wantColumn: parquetColumn{typ: parquetString}},
+		{pgType: "numeric(50,2)", wantColumn: parquetColumn{typ: parquetString}},
+		{pgType: "date", wantColumn: parquetColumn{typ: parquetDate}},
+		{pgType: "timestamp without time zone", wantColumn: parquetColumn{typ: parquetTimestamp}},
+		{pgType: "timestamp(3) with time zone", wantColumn: parquetColumn{typ: parquetTimestamp, adjustedToUTC: true}},
+		{pgType: "timestamptz", wantColumn: parquetColumn{typ: parquetTimestamp, adjustedToUTC: true}},
+		{pgType: "character varying(50)", wantColumn: parquetColumn{typ: parquetString}},
+		{pgType: "jsonb", wantColumn: parquetColumn{typ: parquetString}},
+		{pgType: "integer[]", wantColumn: parquetColumn{typ: parquetString}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pgType, func(t *testing.T) {
+			t.Parallel()
+
+			tc.wantColumn.name = "col"
+			require.Equal(t, tc.wantColumn, newParquetColumn("col", tc.pgType))
+		})
+	}
+}
+
+func TestParquetColumn_value(t *testing.T) {
+	t.Parallel()
+
+	testTime := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+
+	tests := []struct {
+		name   string
+		column parquetColumn
+		value  any
+
+		wantValue any
+		wantErr   error
+	}{
+		{name: "null", column: parquetColumn{typ: parquetInt64}, value: nil, wantValue: nil},
+		{name: "boolean", column: parquetColumn{typ: parquetBoolean}, value: true, wantValue: true},
+		{name: "boolean string", column: parquetColumn{typ: parquetBoolean}, value: "f", wantValue: false},
+		{name: "int32 from float", column: parquetColumn{typ: parquetInt32}, value: float64(42), wantValue: int32(42)},
+		{name: "int32 overflow", column: parquetColumn{typ: parquetInt32}, value: int64(1 << 40), wantErr: errUnsupportedValue},
+		{name: "int64 from json number", column: parquetColumn{typ: parquetInt64}, value: json.Number("9007199254740993"), wantValue: int64(9007199254740993)},
+		{name: "int64 from fraction", column: parquetColumn{typ: parquetInt64}, value: 1.5, wantErr: errUnsupportedValue},
+		{name: "float", column: parquetColumn{typ: parquetFloat}, value: 0.5, wantValue: float32(0.5)},
+		{name: "double from int", column: parquetColumn{typ: parquetDouble}, value: 3, wantValue: float64(3)},
+		{name: "date string", column: parquetColumn{typ: parquetDate}, value: "1970-01-11", wantValue: int32(10)},
+		{name: "date before epoch", column: parquetColumn{typ: parquetDate}, value: "1969-12-31", wantValue: int32(-1)},
+		{
+			name:      "timestamp with time zone string",
+			column:    parquetColumn{typ: parquetTimestamp, adjustedToUTC: true},
+			value:     "2024-01-02 05:04:05.000006+02",
+			wantValue: testTime.UnixMicro(),
+		},
+		{
+			name:      "timestamp with half hour time zone",
+			column:    parquetColumn{typ: parquetTimestamp, adjustedToUTC: true},
+			value:     "2024-01-02 08:34:05.000006+05:30",
+			wantValue: testTime.UnixMicro(),
+		},
+		{
+			name:      "timestamp without time zone",
+			column:    parquetColumn{typ: parquetTimestamp},
+			value:     "2024-01-02 03:04:05.000006",
+			wantValue: testTime.UnixMicro(),
+		},
+		{
+			name:      "local timestamp keeps the wall clock",
+			column:    parquetColumn{typ: parquetTimestamp},
+			value:     time.Date(2024, 1, 2, 3, 4, 5, 6000, time.FixedZone("", 3600)),
+			wantValue: testTime.UnixMicro(),
+		},
+		{name: "invalid timestamp", column: parquetColumn{typ: parquetTimestamp}, value: "infinity", wantErr: errUnsupportedValue},
+		{name: "decimal", column: parquetColumn{typ: parquetDecimal, precision: 10, scale: 2}, value: "3.00", wantValue: []byte{0x01, 0x2c}},
+		{name: "negative decimal", column: parquetColumn{typ: parquetDecimal, precision: 10, scale: 2}, value: -1.29, wantValue: []byte{0xff, 0x7f}},
+		{name: "decimal sign byte", column: parquetColumn{typ: parquetDecimal, precision: 5, scale: 0}, value: 128, wantValue: []byte{0x00, 0x80}},
+		{name: "invalid decimal", column: parquetColumn{typ: parquetDecimal, precision: 5}, value: "NaN", wantErr: errUnsupportedValue},
+		{name: "string", column: parquetColumn{typ: parquetString}, value: "text", wantValue: "text"},
+		{name: "json string", column: parquetColumn{typ: parquetString}, value: map[string]any{"a": 1}, wantValue: `{"a":1}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := tc.column.value(tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, value)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetColumn is a column of the parquet files. All the columns are
+// optional, since the values of the rows are not known for all the actions
+// (i.e. deletes only have the identity of the row).
+type parquetColumn struct {
+	name string
+	typ  parquetType
+	// adjustedToUTC is set for the timestamps with time zone
+	adjustedToUTC bool
+	// precision and scale of the decimals
+	precision int
+	scale     int
+}
+
+type parquetType uint8
+
+// The values of the columns of each type are provided to the parquet writer as
+// the go type of their physical type: string, bool, int32, int64, float32,
+// float64, int32 (days since the epoch), int64 (microseconds since the epoch)
+// and []byte (unscaled big endian two's complement value) respectively.
+const (
+	parquetString parquetType = iota
+	parquetBoolean
+	parquetInt32
+	parquetInt64
+	parquetFloat
+	parquetDouble
+	parquetDate
+	parquetTimestamp
+	parquetDecimal
+)
+
+const parquetCreatedBy = "pgstream"
+
+// writeParquet returns a parquet file with the rows on input, written as a
+// single row group. The values of each row are in the order of the columns,
+// and nil values are written as nulls. The columns of the file are sorted by
+// name, as required by the parquet groups.
+func writeParquet(columns []parquetColumn, rows [][]any) ([]byte, error) {
+	group := make(parquet.Group, len(columns))
+	for _, col := range columns {
+		group[col.name] = parquet.Optional(col.node())
+	}
+	schema := parquet.NewSchema("schema", group)
+
+	// index of each column in the file, sorted by name
+	leafIndexes := make([]int, len(columns))
+	for i, col := range columns {
+		leaf, found := schema.Lookup(col.name)
+		if !found {
+			return nil, fmt.Errorf("column %s not found in the parquet schema", col.name)
+		}
+		leafIndexes[i] = leaf.ColumnIndex
+	}
+
+	parquetRows := make([]parquet.Row, 0, len(rows))
+	for _, values := range rows {
+		row := make(parquet.Row, len(columns))
+		for i, col := range columns {
+			value, err := col.parquetValue(values[i])
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", col.name, err)
+			}
+			definitionLevel := 1
+			if value.IsNull() {
+				definitionLevel = 0
+			}
+			row[leafIndexes[i]] = value.Level(0, definitionLevel, leafIndexes[i])
+		}
+		parquetRows = append(parquetRows, row)
+	}
+
+	buf := &bytes.Buffer{}
+	writer := parquet.NewWriter(buf, schema, parquet.CreatedBy(parquetCreatedBy, "", ""))
+	if _, err := writer.WriteRows(parquetRows); err != nil {
+		return nil, fmt.Errorf("writing parquet rows: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("closing parquet writer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// node returns the parquet schema node of the column values.
+func (c parquetColumn) node() parquet.Node {
+	switch c.typ {
+	case parquetBoolean:
+		return parquet.Leaf(parquet.BooleanType)
+	case parquetInt32:
+		return parquet.Int(32)
+	case parquetInt64:
+		return parquet.Int(64)
+	case parquetFloat:
+		return parquet.Leaf(parquet.FloatType)
+	case parquetDouble:
+		return parquet.Leaf(parquet.DoubleType)
+	case parquetDate:
+		return parquet.Date()
+	case parquetTimestamp:
+		return parquet.TimestampAdjusted(parquet.Microsecond, c.adjustedToUTC)
+	case parquetDecimal:
+		return parquet.Decimal(c.scale, c.precision, parquet.FixedLenByteArrayType(decimalSize(c.precision)))
+	default:
+		return parquet.String()
+	}
+}
+
+// parquetValue returns the parquet value for the value on input, which must
+// be of the go type of the physical type of the column.
+func (c parquetColumn) parquetValue(v any) (parquet.Value, error) {
+	if v == nil {
+		return parquet.NullValue(), nil
+	}
+
+	switch v := v.(type) {
+	case string:
+		return parquet.ByteArrayValue([]byte(v)), nil
+	case bool:
+		return parquet.BooleanValue(v), nil
+	case int32:
+		return parquet.Int32Value(v), nil
+	case int64:
+		return parquet.Int64Value(v), nil
+	case float32:
+		return parquet.FloatValue(v), nil
+	case float64:
+		return parquet.DoubleValue(v), nil
+	case []byte:
+		if c.typ != parquetDecimal {
+			return parquet.ByteArrayValue(v), nil
+		}
+		b, err := signExtend(v, decimalSize(c.precision))
+		if err != nil {
+			return parquet.Value{}, err
+		}
+		return parquet.FixedLenByteArrayValue(b), nil
+	default:
+		return parquet.Value{}, fmt.Errorf("%w: %T", errUnsupportedValue, v)
+	}
+}
+
+// decimalSize returns the number of bytes of the fixed length byte arrays
+// that fit the unscaled values of the decimals with the precision on input.
+func decimalSize(precision int) int {
+	maxUnscaled := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(precision)), nil)
+	// one more bit for the sign
+	return (maxUnscaled.BitLen() + 1 + 7) / 8
+}
+
+// signExtend returns the big endian two's complement value on input extended
+// to the size on input.
+func signExtend(b []byte, size int) ([]byte, error) {
+	if len(b) > size {
+		return nil, fmt.Errorf("%w: decimal value overflows %d bytes", errUnsupportedValue, size)
+	}
+	extended := make([]byte, size)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		for i := range size - len(b) {
+			extended[i] = 0xff
+		}
+	}
+	copy(extended[size-len(b):], b)
+	return extended, nil
+}
@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteParquet(t *testing.T) {
+	t.Parallel()
+
+	columns := []parquetColumn{
+		{name: "name", typ: parquetString},
+		{name: "active", typ: parquetBoolean},
+		{name: "count", typ: parquetInt32},
+		{name: "id", typ: parquetInt64},
+		{name: "ratio", typ: parquetFloat},
+		{name: "score", typ: parquetDouble},
+		{name: "birthday", typ: parquetDate},
+		{name: "created_at", typ: parquetTimestamp, adjustedToUTC: true},
+		{name: "price", typ: parquetDecimal, precision: 10, scale: 2},
+	}
+	rows := [][]any{
+		{"a", true, int32(1), int64(10), float32(0.5), 1.5, int32(19000), int64(1700000000000000), []byte{0x01, 0x2c}},
+		{nil, nil, nil, nil, nil, nil, nil, nil, nil},
+		{"c", false, int32(-3), int64(-30), float32(-0.25), -2.5, int32(-1), int64(-1), []byte{0xff}},
+	}
+
+	body, err := writeParquet(columns, rows)
+	require.NoError(t, err)
+
+	file, err := parquet.OpenFile(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	require.Equal(t, int64(len(rows)), file.NumRows())
+	require.Len(t, file.RowGroups(), 1)
+	require.Contains(t, file.Metadata().CreatedBy, parquetCreatedBy)
+
+	wantLogicalTypes := []*format.LogicalType{
+		{UTF8: &format.StringType{}},
+		nil,
+		{Integer: &format.IntType{BitWidth: 32, IsSigned: true}},
+		{Integer: &format.IntType{BitWidth: 64, IsSigned: true}},
+		nil,
+		nil,
+		{Date: &format.DateType{}},
+		{Timestamp: &format.TimestampType{IsAdjustedToUTC: true, Unit: format.TimeUnit{Micros: &format.MicroSeconds{}}}},
+		{Decimal: &format.DecimalType{Scale: 2, Precision: 10}},
+	}
+	schema := file.Schema()
+	leafIndexes := make([]int, len(columns))
+	for i, col := range columns {
+		leaf, found := schema.Lookup(col.name)
+		require.True(t, found, col.name)
+		require.True(t, leaf.Node.Optional(), col.name)
+		require.Equal(t, wantLogicalTypes[i], leaf.Node.Type().LogicalType(), col.name)
+		leafIndexes[i] = leaf.ColumnIndex
+	}
+
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+	readRows := make([]parquet.Row, len(rows)+1)
+	n, err := reader.ReadRows(readRows)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, len(rows), n)
+
+	wantRows := [][]any{
+		{"a", true, int32(1), int64(10), float32(0.5), 1.5, int32(19000), int64(1700000000000000), []byte{0x00, 0x00, 0x00, 0x01, 0x2c}},
+		{nil, nil, nil, nil, nil, nil, nil, nil, nil},
+		{"c", false, int32(-3), int64(-30), float32(-0.25), -2.5, int32(-1), int64(-1), []byte{0xff, 0xff, 0xff, 0xff, 0xff}},
+	}
+	for j, wantRow := range wantRows {
+		for i, col := range columns {
+			require.Equal(t, wantRow[i], goValue(col, readRows[j][leafIndexes[i]]), "column %s row %d", col.name, j)
+		}
+	}
+}
+
+func TestDecimalSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		precision int
+		wantSize  int
+	}{
+		{precision: 1, wantSize: 1},
+		{precision: 2, wantSize: 1},
+		{precision: 3, wantSize: 2},
+		{precision: 10, wantSize: 5},
+		{precision: 18, wantSize: 8},
+		{precision: 38, wantSize: 16},
+	}
+
+	for _, tc := range tests {
+		require.Equal(t, tc.wantSize, decimalSize(tc.precision), tc.precision)
+	}
+}
+
+func goValue(col parquetColumn, v parquet.Value) any {
+	if v.IsNull() {
+		return nil
+	}
+	switch col.typ {
+	case parquetBoolean:
+		return v.Boolean()
+	case parquetInt32, parquetDate:
+		return v.Int32()
+	case parquetInt64, parquetTimestamp:
+		return v.Int64()
+	case parquetFloat:
+		return v.Float()
+	case parquetDouble:
+		return v.Double()
+	case parquetDecimal:
+		return v.ByteArray()
+	default:
+		return string(v.ByteArray())
+	}
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Storage uploads the objects to an S3 bucket. Objects larger than the part
+// size are uploaded with a multipart upload, which is aborted if any of the
+// parts fails.
+type s3Storage struct {
+	client   *s3.Client
+	uploader *manager.Uploader
+	bucket   string
+}
+
+// newS3Storage returns an S3 storage for the bucket on input. The endpoint is
+// the default AWS endpoint of the region when empty, and custom endpoints use
+// path style URLs.
+func newS3Storage(awsCfg aws.Config, endpoint, bucket string, partSize int64) *s3Storage {
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+			// S3 compatible storages don't always support the default
+			// checksums of the SDK
+			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		}
+	})
+
+	return &s3Storage{
+		client: client,
+		uploader: manager.NewUploader(client, func(u *manager.Uploader) {
+			u.PartSize = partSize
+		}),
+		bucket: bucket,
+	}
+}
+
+// putObject uploads the object on input, overwriting it if it already exists.
+func (s *s3Storage) putObject(ctx context.Context, key string, body []byte, contentType string) error {
+	_, err := s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("putting object %s: %w", key, err)
+	}
+	return nil
+}
+
+// listObjects returns the keys of the objects with the prefix on input, in
+// lexicographical order.
+func (s *s3Storage) listObjects(ctx context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing objects: %w", err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	return keys, nil
+}
+
+func (s *s3Storage) getObject(ctx context.Context, key string) ([]byte, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting object %s: %w", key, err)
+	}
+	defer out.Body.Close()
+
+	body, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading object %s: %w", key, err)
+	}
+	return body, nil
+}
+
+// ping checks the bucket exists and is accessible with the credentials.
+func (s *s3Storage) ping(ctx context.Context) error {
+	if _, err := s.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)}); err != nil {
+		return fmt.Errorf("checking bucket: %w", err)
+	}
+	return nil
+}
+
+func (s *s3Storage) close() error {
+	return nil
+}
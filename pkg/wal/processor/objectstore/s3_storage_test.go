@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeObjectServer records the requests received, and replies with the
+// handler response, if any.
+type fakeObjectServer struct {
+	mutex    sync.Mutex
+	requests []string
+	bodies   map[string][]byte
+	handler  func(w http.ResponseWriter, r *http.Request) bool
+}
+
+func newFakeObjectServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request) bool) (*fakeObjectServer, *httptest.Server) {
+	f := &fakeObjectServer{bodies: map[string][]byte{}, handler: handler}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		f.mutex.Lock()
+		request := r.Method + " " + r.URL.Path
+		if partNumber := r.URL.Query().Get("partNumber"); partNumber != "" {
+			request += " part " + partNumber
+		}
+		f.requests = append(f.requests, request)
+		f.bodies[request] = body
+		f.mutex.Unlock()
+
+		if f.handler != nil && f.handler(w, r) {
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeObjectServer) getRequests() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string{}, f.requests...)
+}
+
+func newTestS3Storage(endpoint string, partSize int64) *s3Storage {
+	return newS3Storage(aws.Config{
+		Region:      "eu-west-1",
+		Credentials: credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	}, endpoint, "bucket", partSize)
+}
+
+// s3MultipartHandler replies to the multipart upload requests, failing the
+// upload of the part number on input, if any.
+func s3MultipartHandler(failedPart string) func(w http.ResponseWriter, r *http.Request) bool {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		switch {
+		case r.URL.Query().Has("uploads"):
+			fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>`)
+			return true
+		case failedPart != "" && r.URL.Query().Get("partNumber") == failedPart:
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `<Error><Code>AccessDenied</Code><Message>oh noes</Message></Error>`)
+			return true
+		case r.URL.Query().Has("partNumber"):
+			w.Header().Set("ETag", `"etag-`+r.URL.Query().Get("partNumber")+`"`)
+		case r.Method == http.MethodPost:
+			fmt.Fprint(w, `<CompleteMultipartUploadResult><Bucket>bucket</Bucket><Key>key</Key><ETag>"etag"</ETag></CompleteMultipartUploadResult>`)
+			return true
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+			return true
+		}
+		return false
+	}
+}
+
+func TestS3Storage_putObject(t *testing.T) {
+	t.Parallel()
+
+	testKey := "archive/public.test/date=2024-01-02/0000000000000010-0000000000000020-abcd.jsonl"
+
+	t.Run("ok - single request", func(t *testing.T) {
+		t.Parallel()
+
+		server, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			require.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/"))
+			require.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/s3/aws4_request")
+			require.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+			return false
+		})
+		s := newTestS3Storage(httpServer.URL, minPartSize)
+
+		err := s.putObject(context.Background(), testKey, []byte("body"), "application/x-ndjson")
+		require.NoError(t, err)
+		require.Equal(t, []string{"PUT /bucket/" + testKey}, server.getRequests())
+		require.Equal(t, []byte("body"), server.bodies["PUT /bucket/"+testKey])
+	})
+
+	t.Run("ok - multipart upload", func(t *testing.T) {
+		t.Parallel()
+
+		server, httpServer := newFakeObjectServer(t, s3MultipartHandler(""))
+		s := newTestS3Storage(httpServer.URL, minPartSize)
+
+		body := bytes.Repeat([]byte("0"), minPartSize+2)
+		err := s.putObject(context.Background(), "key", body, "application/x-ndjson")
+		require.NoError(t, err)
+		requests := server.getRequests()
+		require.Equal(t, "POST /bucket/key", requests[0])
+		// the parts are uploaded concurrently
+		require.ElementsMatch(t, []string{"PUT /bucket/key part 1", "PUT /bucket/key part 2"}, requests[1:3])
+		require.Equal(t, "POST /bucket/key", requests[3])
+		require.Len(t, server.bodies["PUT /bucket/key part 1"], minPartSize)
+		require.Len(t, server.bodies["PUT /bucket/key part 2"], 2)
+		require.Contains(t, string(server.bodies["POST /bucket/key"]), "<PartNumber>2</PartNumber>")
+	})
+
+	t.Run("error - multipart upload aborted on part failure", func(t *testing.T) {
+		t.Parallel()
+
+		server, httpServer := newFakeObjectServer(t, s3MultipartHandler("2"))
+		s := newTestS3Storage(httpServer.URL, minPartSize)
+
+		body := bytes.Repeat([]byte("0"), minPartSize+2)
+		err := s.putObject(context.Background(), "key", body, "application/x-ndjson")
+		require.ErrorContains(t, err, "AccessDenied")
+		requests := server.getRequests()
+		require.Equal(t, "DELETE /bucket/key", requests[len(requests)-1])
+	})
+
+	t.Run("error - forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		_, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			w.WriteHeader(http.StatusForbidden)
+			return true
+		})
+		s := newTestS3Storage(httpServer.URL, minPartSize)
+
+		err := s.putObject(context.Background(), "key", []byte("body"), "application/x-ndjson")
+		require.ErrorContains(t, err, "putting object key")
+	})
+}
+
+func TestS3Storage_ping(t *testing.T) {
+	t.Parallel()
+
+	server, httpServer := newFakeObjectServer(t, nil)
+	s := newTestS3Storage(httpServer.URL, minPartSize)
+
+	require.NoError(t, s.ping(context.Background()))
+	require.Equal(t, []string{"HEAD /bucket"}, server.getRequests())
+}
+
+func TestS3Storage_listObjects(t *testing.T) {
+	t.Parallel()
+
+	server, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+		require.Equal(t, "cdc/", r.URL.Query().Get("prefix"))
+		fmt.Fprint(w, `<ListBucketResult><Name>bucket</Name><Prefix>cdc/</Prefix><KeyCount>2</KeyCount><IsTruncated>false</IsTruncated>`+
+			`<Contents><Key>cdc/a.jsonl</Key></Contents><Contents><Key>cdc/b.jsonl</Key></Contents></ListBucketResult>`)
+		return true
+	})
+	s := newTestS3Storage(httpServer.URL, minPartSize)
+
+	keys, err := s.listObjects(context.Background(), "cdc/")
+	require.NoError(t, err)
+	require.Equal(t, []string{"cdc/a.jsonl", "cdc/b.jsonl"}, keys)
+	require.Equal(t, []string{"GET /bucket"}, server.getRequests())
+}
+
+func TestS3Storage_getObject(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+
+		server, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			fmt.Fprint(w, "body")
+			return true
+		})
+		s := newTestS3Storage(httpServer.URL, minPartSize)
+
+		body, err := s.getObject(context.Background(), "cdc/a.jsonl")
+		require.NoError(t, err)
+		require.Equal(t, []byte("body"), body)
+		require.Equal(t, []string{"GET /bucket/cdc/a.jsonl"}, server.getRequests())
+	})
+
+	t.Run("error - not found", func(t *testing.T) {
+		t.Parallel()
+
+		_, httpServer := newFakeObjectServer(t, func(w http.ResponseWriter, r *http.Request) bool {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>oh noes</Message></Error>`)
+			return true
+		})
+		s := newTestS3Storage(httpServer.URL, minPartSize)
+
+		_, err := s.getObject(context.Background(), "cdc/a.jsonl")
+		require.ErrorContains(t, err, "NoSuchKey")
+	})
+}
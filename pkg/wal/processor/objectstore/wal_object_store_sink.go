@@ -0,0 +1,381 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// ObjectStoreSink is a wal processor that archives the changes into objects in
+// S3, GCS or Azure Blob Storage. The events are buffered until the batch size
+// or time thresholds are reached, and the buffered rows of each table and
+// commit date are then written into one object, either as newline delimited
+// JSON or as a parquet file. The object keys are derived from the table, the
+// commit date, the LSN range and the content of the objects, so that objects
+// written again after a restart overwrite the existing ones instead of
+// duplicating them. The positions are only checkpointed once all the objects
+// of a batch have been uploaded.
+type ObjectStoreSink struct {
+	logger          loglib.Logger
+	batchSender     batchSender
+	instrumentation *otel.Instrumentation
+	storage         objectStorage
+	encoder         encoder
+	prefix          string
+	lsnParser       replication.LSNParser
+	clock           func() time.Time
+
+	// schemas keeps the latest schema log entry of each schema, used to
+	// derive the parquet schema of the tables
+	schemasMutex sync.RWMutex
+	schemas      map[string]*schemalog.LogEntry
+
+	// optional checkpointer callback to mark what was safely processed
+	checkpointer checkpointer.Checkpoint
+}
+
+type Option func(*ObjectStoreSink)
+
+type batchSender interface {
+	SendMessage(context.Context, *batch.WALMessage[message]) error
+	Close()
+}
+
+type objectStorage interface {
+	putObject(ctx context.Context, key string, body []byte, contentType string) error
+	listObjects(ctx context.Context, prefix string) ([]string, error)
+	getObject(ctx context.Context, key string) ([]byte, error)
+	ping(ctx context.Context) error
+	close() error
+}
+
+// message is a row change to be archived
+type message struct {
+	schema string
+	table  string
+	// date is the partition of the row, the day it was committed on
+	date string
+	lsn  replication.LSN
+	row  *row
+}
+
+// object is the group of rows of a batch written into the same object
+type object struct {
+	schema   string
+	table    string
+	date     string
+	firstLSN replication.LSN
+	lastLSN  replication.LSN
+	rows     []*row
+}
+
+const datePartitionFormat = "2006-01-02"
+
+var (
+	errMissingBucket           = errors.New("missing object store bucket")
+	errUnsupportedFormat       = errors.New("unsupported object format")
+	errUnsupportedProvider     = errors.New("unsupported object storage provider")
+	errMissingAzureCredentials = errors.New("azure blob storage requires the storage account name and key")
+	errMissingRegion           = errors.New("missing s3 bucket region")
+)
+
+func NewObjectStoreSink(ctx context.Context, cfg *Config, opts ...Option) (*ObjectStoreSink, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	storage, err := newObjectStorage(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s := newObjectStoreSink(storage, cfg)
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.batchSender, err = batch.NewSender(ctx, &cfg.Batch, s.sendBatch, s.logger, batch.WithInstrumentation(s.instrumentation, s.Name()))
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func newObjectStoreSink(storage objectStorage, cfg *Config) *ObjectStoreSink {
+	prefix := cfg.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return &ObjectStoreSink{
+		logger:    loglib.NewNoopLogger(),
+		storage:   storage,
+		encoder:   newEncoder(cfg.format()),
+		prefix:    prefix,
+		lsnParser: pgreplication.NewLSNParser(),
+		clock:     time.Now,
+		schemas:   map[string]*schemalog.LogEntry{},
+	}
+}
+
+func newObjectStorage(ctx context.Context, cfg *Config) (objectStorage, error) {
+	switch cfg.provider() {
+	case ProviderGCS:
+		return newGCSStorage(ctx, cfg.Endpoint, cfg.CredentialsFile, cfg.Bucket, cfg.partSize())
+	case ProviderAzure:
+		return newAzureStorage(cfg.Endpoint, cfg.AccountName, cfg.AccountKey, cfg.Bucket, cfg.partSize())
+	default:
+		awsOpts := []func(*awsconfig.LoadOptions) error{}
+		if cfg.Region != "" {
+			awsOpts = append(awsOpts, awsconfig.WithRegion(cfg.Region))
+		}
+		if cfg.AccessKeyID != "" {
+			awsOpts = append(awsOpts, awsconfig.WithCredentialsProvider(
+				credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, "")))
+		}
+		awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("loading aws config: %w", err)
+		}
+		if awsCfg.Region == "" {
+			return nil, errMissingRegion
+		}
+		return newS3Storage(awsCfg, cfg.Endpoint, cfg.Bucket, cfg.partSize()), nil
+	}
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *ObjectStoreSink) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "object_store_sink",
+		})
+	}
+}
+
+func WithCheckpoint(c checkpointer.Checkpoint) Option {
+	return func(s *ObjectStoreSink) {
+		s.checkpointer = c
+	}
+}
+
+// WithInstrumentation reports the metrics of the batches sent by the sink.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(s *ObjectStoreSink) {
+		s.instrumentation = i
+	}
+}
+
+// ProcessWALEvent is called on every new message from the wal. It can be called
+// concurrently.
+func (s *ObjectStoreSink) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Panic("[PANIC] Panic while processing replication event", loglib.Fields{
+				"wal_data":    walEvent,
+				"panic":       r,
+				"stack_trace": debug.Stack(),
+			})
+
+			retErr = fmt.Errorf("object store sink: understanding event: %w: %v", processor.ErrPanic, r)
+		}
+	}()
+
+	msg, err := s.newMessage(walEvent.Data)
+	if err != nil {
+		return err
+	}
+
+	return s.batchSender.SendMessage(ctx, batch.NewWALMessage(msg, walEvent.CommitPosition))
+}
+
+func (s *ObjectStoreSink) Name() string {
+	return "object-store-sink"
+}
+
+func (s *ObjectStoreSink) Close() error {
+	s.batchSender.Close()
+	return s.storage.close()
+}
+
+// Ping checks the bucket is accessible.
+func (s *ObjectStoreSink) Ping(ctx context.Context) error {
+	return s.storage.ping(ctx)
+}
+
+// newMessage returns the message for the event data on input. Events that
+// aren't archived, such as keep alives or schema changes, are returned as
+// empty messages so that their position is still checkpointed.
+func (s *ObjectStoreSink) newMessage(data *wal.Data) (message, error) {
+	if data == nil {
+		return message{}, nil
+	}
+	if processor.IsSchemaLogEvent(data) {
+		s.updateSchema(data)
+		return message{}, nil
+	}
+
+	r, err := newRow(data)
+	if err != nil {
+		return message{}, fmt.Errorf("object store sink: table %s.%s: %w", data.Schema, data.Table, err)
+	}
+	if r == nil {
+		return message{}, nil
+	}
+
+	lsn, err := s.lsnParser.FromString(data.LSN)
+	if err != nil {
+		return message{}, fmt.Errorf("parsing event lsn %q: %w", data.LSN, err)
+	}
+
+	// snapshot rows are partitioned by the day they're archived on
+	date := s.clock().UTC()
+	if r.timestamp != nil {
+		date = *r.timestamp
+	}
+
+	return message{
+		schema: data.Schema,
+		table:  data.Table,
+		date:   date.Format(datePartitionFormat),
+		lsn:    lsn,
+		row:    r,
+	}, nil
+}
+
+// updateSchema keeps the schema log entry of the event on input, if it's more
+// recent than the one already known for its schema.
+func (s *ObjectStoreSink) updateSchema(data *wal.Data) {
+	logEntry, err := processor.WalDataToLogEntry(data)
+	if err != nil {
+		s.logger.Warn(err, "object store sink: parsing schema log event, parquet schemas will be derived from the events")
+		return
+	}
+
+	s.schemasMutex.Lock()
+	defer s.schemasMutex.Unlock()
+	if current, found := s.schemas[logEntry.SchemaName]; !found || logEntry.Version >= current.Version {
+		s.schemas[logEntry.SchemaName] = logEntry
+	}
+}
+
+func (s *ObjectStoreSink) tableSchema(schema, table string) *schemalog.Table {
+	s.schemasMutex.RLock()
+	defer s.schemasMutex.RUnlock()
+	logEntry, found := s.schemas[schema]
+	if !found {
+		return nil
+	}
+	t, found := logEntry.GetTableByName(table)
+	if !found {
+		return nil
+	}
+	return &t
+}
+
+func (s *ObjectStoreSink) sendBatch(ctx context.Context, batch *batch.Batch[message]) error {
+	objects := groupObjects(batch.GetMessages())
+	s.logger.Debug("object store sink: writing message batch", loglib.Fields{
+		"batch_size":             len(batch.GetMessages()),
+		"batch_commit_positions": len(batch.GetCommitPositions()),
+		"objects":                len(objects),
+	})
+
+	for _, obj := range objects {
+		body, err := s.encoder.encode(s.tableSchema(obj.schema, obj.table), obj.rows)
+		if err != nil {
+			return fmt.Errorf("object store sink: encoding object for table %s.%s: %w", obj.schema, obj.table, err)
+		}
+
+		key := s.objectKey(obj, body)
+		if err := s.storage.putObject(ctx, key, body, s.encoder.contentType()); err != nil {
+			s.logger.Error(err, "failed to upload object", loglib.Fields{"key": key})
+			return fmt.Errorf("object store sink: %w", err)
+		}
+		s.logger.Debug("object store sink: object uploaded", loglib.Fields{
+			"key":        key,
+			"rows":       len(obj.rows),
+			"size_bytes": len(body),
+		})
+	}
+
+	positions := batch.GetCommitPositions()
+	if s.checkpointer != nil && len(positions) > 0 {
+		if err := s.checkpointer(ctx, positions); err != nil {
+			s.logger.Warn(err, "object store sink: error updating commit position")
+		}
+	}
+
+	return nil
+}
+
+// objectKey returns the key of the object on input, in the format
+// <prefix><schema>.<table>/date=<date>/<first lsn>-<last lsn>-<hash>.<ext>.
+// The LSNs are zero padded hexadecimal numbers, so that the objects of a
+// partition are sorted by LSN, and the hash of the content tells apart the
+// objects of the same LSN range, i.e. for the events of the same transaction
+// split across batches.
+func (s *ObjectStoreSink) objectKey(obj *object, body []byte) string {
+	hash := sha256.Sum256(body)
+	return fmt.Sprintf("%s%s.%s/date=%s/%016X-%016X-%s.%s",
+		s.prefix, obj.schema, obj.table, obj.date,
+		uint64(obj.firstLSN), uint64(obj.lastLSN), hex.EncodeToString(hash[:4]),
+		s.encoder.extension())
+}
+
+// groupObjects groups the messages on input by table and date, keeping the
+// order of the messages within each object.
+func groupObjects(messages []message) []*object {
+	objects := []*object{}
+	byKey := map[string]*object{}
+	for _, msg := range messages {
+		key := msg.schema + "." + msg.table + "/" + msg.date
+		obj, found := byKey[key]
+		if !found {
+			obj = &object{
+				schema:   msg.schema,
+				table:    msg.table,
+				date:     msg.date,
+				firstLSN: msg.lsn,
+				lastLSN:  msg.lsn,
+			}
+			byKey[key] = obj
+			objects = append(objects, obj)
+		}
+		obj.firstLSN = min(obj.firstLSN, msg.lsn)
+		obj.lastLSN = max(obj.lastLSN, msg.lsn)
+		obj.rows = append(obj.rows, msg.row)
+	}
+	return objects
+}
+
+func (m message) Size() int {
+	if m.row == nil {
+		return 0
+	}
+	return len(m.row.line)
+}
+
+func (m message) IsEmpty() bool {
+	return m.row == nil
+}
@@ -0,0 +1,421 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	batchmocks "github.com/xataio/pgstream/pkg/wal/processor/batch/mocks"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+)
+
+type mockStorage struct {
+	putObjectFn func(key string) error
+	objects     map[string][]byte
+	keys        []string
+	pings       int
+}
+
+func (m *mockStorage) putObject(_ context.Context, key string, body []byte, _ string) error {
+	if m.putObjectFn != nil {
+		if err := m.putObjectFn(key); err != nil {
+			return err
+		}
+	}
+	if m.objects == nil {
+		m.objects = map[string][]byte{}
+	}
+	m.objects[key] = body
+	m.keys = append(m.keys, key)
+	return nil
+}
+
+func (m *mockStorage) listObjects(_ context.Context, prefix string) ([]string, error) {
+	keys := []string{}
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+func (m *mockStorage) getObject(_ context.Context, key string) ([]byte, error) {
+	body, found := m.objects[key]
+	if !found {
+		return nil, fmt.Errorf("object %s not found", key)
+	}
+	return body, nil
+}
+
+func (m *mockStorage) ping(context.Context) error {
+	m.pings++
+	return nil
+}
+
+func (m *mockStorage) close() error {
+	return nil
+}
+
+var (
+	errTest  = errors.New("oh noes")
+	testTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+func newTestSink(storage objectStorage, cfg *Config) *ObjectStoreSink {
+	s := newObjectStoreSink(storage, cfg)
+	s.clock = func() time.Time { return testTime }
+	return s
+}
+
+func newTestData(action wal.Action, table, lsn, timestamp string) *wal.Data {
+	return &wal.Data{
+		Action:    string(action),
+		LSN:       lsn,
+		Timestamp: timestamp,
+		Schema:    "public",
+		Table:     table,
+		Columns:   []wal.Column{{Name: "id", Type: "integer", Value: 1}},
+	}
+}
+
+func newTestMessage(t *testing.T, table, date string, lsn replication.LSN) message {
+	r, err := newRow(newTestData(wal.ActionInsert, table, "0/10", ""))
+	require.NoError(t, err)
+	return message{schema: "public", table: table, date: date, lsn: lsn, row: r}
+}
+
+func TestNewObjectStoreSink(t *testing.T) {
+	t.Parallel()
+
+	gcsCredentialsFile := filepath.Join(t.TempDir(), "credentials.json")
+	err := os.WriteFile(gcsCredentialsFile, []byte(`{"type":"authorized_user","client_id":"id","client_secret":"secret","refresh_token":"token"}`), 0o600)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		cfg  *Config
+
+		wantErr error
+	}{
+		{
+			name:    "error - missing bucket",
+			cfg:     &Config{},
+			wantErr: errMissingBucket,
+		},
+		{
+			name:    "error - unsupported format",
+			cfg:     &Config{Bucket: "bucket", Format: "csv"},
+			wantErr: errUnsupportedFormat,
+		},
+		{
+			name:    "error - unsupported provider",
+			cfg:     &Config{Bucket: "bucket", Provider: "ftp"},
+			wantErr: errUnsupportedProvider,
+		},
+		{
+			name:    "error - missing azure credentials",
+			cfg:     &Config{Bucket: "bucket", Provider: ProviderAzure, AccountName: "account"},
+			wantErr: errMissingAzureCredentials,
+		},
+		{
+			name: "ok - gcs",
+			cfg:  &Config{Bucket: "bucket", Provider: ProviderGCS, CredentialsFile: gcsCredentialsFile, Format: FormatParquet},
+		},
+		{
+			name: "ok - azure",
+			cfg:  &Config{Bucket: "container", Provider: ProviderAzure, AccountName: "account", AccountKey: testAzureKey},
+		},
+		{
+			name: "ok - s3 with static credentials",
+			cfg:  &Config{Bucket: "bucket", Region: "eu-west-1", AccessKeyID: "key", SecretAccessKey: "secret"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			_, err := NewObjectStoreSink(ctx, tc.cfg)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestObjectStoreSink_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		event *wal.Event
+
+		wantMsgs []*batch.WALMessage[message]
+		wantErr  error
+	}{
+		{
+			name: "ok - insert",
+			event: &wal.Event{
+				Data:           newTestData(wal.ActionInsert, "test", "0/10", "2024-01-01 23:59:59.000000+00"),
+				CommitPosition: "0/10",
+			},
+
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{
+					schema: "public",
+					table:  "test",
+					date:   "2024-01-01",
+					lsn:    16,
+				}, "0/10"),
+			},
+		},
+		{
+			name: "ok - snapshot insert partitioned by the processing date",
+			event: &wal.Event{
+				Data: newTestData(wal.ActionInsert, "test", wal.ZeroLSN, ""),
+			},
+
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{
+					schema: "public",
+					table:  "test",
+					date:   "2024-01-02",
+					lsn:    0,
+				}, ""),
+			},
+		},
+		{
+			name:  "ok - keep alive",
+			event: &wal.Event{CommitPosition: "0/11"},
+
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, "0/11"),
+			},
+		},
+		{
+			name: "ok - commit",
+			event: &wal.Event{
+				Data:           &wal.Data{Action: string(wal.ActionCommit), LSN: "0/12"},
+				CommitPosition: "0/12",
+			},
+
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, "0/12"),
+			},
+		},
+		{
+			name: "error - invalid lsn",
+			event: &wal.Event{
+				Data: newTestData(wal.ActionInsert, "test", "invalid", ""),
+			},
+
+			wantMsgs: []*batch.WALMessage[message]{},
+			wantErr:  errors.New("parsing event lsn"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockBatchSender := batchmocks.NewBatchSender[message]()
+			s := newTestSink(&mockStorage{}, &Config{})
+			s.batchSender = mockBatchSender
+
+			doneChan := make(chan struct{})
+			go func() {
+				defer close(doneChan)
+				msgs := mockBatchSender.GetWALMessages()
+				// the row encoding is covered by the row tests
+				for _, msg := range msgs {
+					require.Equal(t, msg.GetMessage().row != nil, !msg.GetMessage().IsEmpty())
+				}
+				require.Equal(t, len(tc.wantMsgs), len(msgs))
+				for i, want := range tc.wantMsgs {
+					got := msgs[i].GetMessage()
+					got.row = nil
+					require.Equal(t, want.GetMessage(), got)
+				}
+			}()
+
+			err := s.ProcessWALEvent(context.Background(), tc.event)
+			if tc.wantErr != nil {
+				require.ErrorContains(t, err, tc.wantErr.Error())
+			} else {
+				require.NoError(t, err)
+			}
+			mockBatchSender.Close()
+			<-doneChan
+		})
+	}
+}
+
+func TestObjectStoreSink_ProcessWALEvent_schemaLog(t *testing.T) {
+	t.Parallel()
+
+	schemaLogEvent := func(version int64, schema string) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action: string(wal.ActionInsert),
+				Schema: schemalog.SchemaName,
+				Table:  schemalog.TableName,
+				Columns: []wal.Column{
+					{Name: "id", Value: "cmdrsfh1u0k6667mfvu0"},
+					{Name: "version", Value: version},
+					{Name: "schema_name", Value: "public"},
+					{Name: "schema", Value: schema},
+				},
+			},
+			CommitPosition: "0/10",
+		}
+	}
+
+	mockBatchSender := batchmocks.NewBatchSender[message]()
+	mockBatchSender.SendMessageFn = func(_ context.Context, msg *batch.WALMessage[message]) error {
+		require.True(t, msg.GetMessage().IsEmpty())
+		return nil
+	}
+	s := newTestSink(&mockStorage{}, &Config{})
+	s.batchSender = mockBatchSender
+
+	require.Nil(t, s.tableSchema("public", "test"))
+	require.NoError(t, s.ProcessWALEvent(context.Background(), schemaLogEvent(2, `{"tables":[{"name":"test","columns":[{"name":"id","type":"bigint"},{"name":"price","type":"numeric(10,2)"}]}]}`)))
+	// older versions are ignored
+	require.NoError(t, s.ProcessWALEvent(context.Background(), schemaLogEvent(1, `{"tables":[{"name":"test","columns":[{"name":"id","type":"text"}]}]}`)))
+
+	table := s.tableSchema("public", "test")
+	require.NotNil(t, table)
+	require.Equal(t, []schemalog.Column{
+		{Name: "id", DataType: "bigint"},
+		{Name: "price", DataType: "numeric(10,2)"},
+	}, table.Columns)
+	require.Nil(t, s.tableSchema("public", "other"))
+	require.Nil(t, s.tableSchema("other", "test"))
+}
+
+func TestObjectStoreSink_sendBatch(t *testing.T) {
+	t.Parallel()
+
+	testPositions := []wal.CommitPosition{"0/10", "0/30"}
+
+	tests := []struct {
+		name     string
+		storage  *mockStorage
+		batch    *batch.Batch[message]
+		checkErr error
+
+		wantKeys        []string
+		wantCheckpoints [][]wal.CommitPosition
+		wantErr         error
+	}{
+		{
+			name:    "ok - one object per table and date",
+			storage: &mockStorage{},
+			batch: batch.NewBatch([]message{
+				newTestMessage(t, "users", "2024-01-01", 0x10),
+				newTestMessage(t, "orders", "2024-01-01", 0x11),
+				newTestMessage(t, "users", "2024-01-01", 0x20),
+				newTestMessage(t, "users", "2024-01-02", 0x30),
+			}, testPositions),
+
+			wantKeys: []string{
+				"archive/public.users/date=2024-01-01/0000000000000010-0000000000000020-",
+				"archive/public.orders/date=2024-01-01/0000000000000011-0000000000000011-",
+				"archive/public.users/date=2024-01-02/0000000000000030-0000000000000030-",
+			},
+			wantCheckpoints: [][]wal.CommitPosition{testPositions},
+		},
+		{
+			name:    "ok - only positions",
+			storage: &mockStorage{},
+			batch:   batch.NewBatch([]message{}, testPositions),
+
+			wantKeys:        []string{},
+			wantCheckpoints: [][]wal.CommitPosition{testPositions},
+		},
+		{
+			name:    "ok - checkpoint error",
+			storage: &mockStorage{},
+			batch: batch.NewBatch([]message{
+				newTestMessage(t, "users", "2024-01-01", 0x10),
+			}, testPositions),
+			checkErr: errTest,
+
+			wantKeys: []string{
+				"archive/public.users/date=2024-01-01/0000000000000010-0000000000000010-",
+			},
+			wantCheckpoints: [][]wal.CommitPosition{testPositions},
+		},
+		{
+			name: "error - upload failure not checkpointed",
+			storage: &mockStorage{
+				putObjectFn: func(key string) error { return errTest },
+			},
+			batch: batch.NewBatch([]message{
+				newTestMessage(t, "users", "2024-01-01", 0x10),
+			}, testPositions),
+
+			wantKeys:        []string{},
+			wantCheckpoints: [][]wal.CommitPosition{},
+			wantErr:         errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newTestSink(tc.storage, &Config{Prefix: "archive"})
+			checkpoints := [][]wal.CommitPosition{}
+			s.checkpointer = func(_ context.Context, positions []wal.CommitPosition) error {
+				checkpoints = append(checkpoints, positions)
+				return tc.checkErr
+			}
+
+			err := s.sendBatch(context.Background(), tc.batch)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantCheckpoints, checkpoints)
+			require.Len(t, tc.storage.keys, len(tc.wantKeys))
+			for i, wantKey := range tc.wantKeys {
+				require.Regexp(t, "^"+wantKey+"[0-9a-f]{8}\\.jsonl$", tc.storage.keys[i])
+			}
+		})
+	}
+}
+
+func TestObjectStoreSink_objectKey(t *testing.T) {
+	t.Parallel()
+
+	s := newTestSink(&mockStorage{}, &Config{Prefix: "archive/", Format: FormatParquet})
+	obj := &object{schema: "public", table: "users", date: "2024-01-02", firstLSN: 0x16B3748, lastLSN: 0x1_0000_0010}
+
+	key := s.objectKey(obj, []byte("body"))
+	require.Equal(t, "archive/public.users/date=2024-01-02/00000000016B3748-0000000100000010-230d8358.parquet", key)
+	// the same content is always written to the same key
+	require.Equal(t, key, s.objectKey(obj, []byte("body")))
+	require.NotEqual(t, key, s.objectKey(obj, []byte("other body")))
+}
+
+func TestObjectStoreSink_Ping(t *testing.T) {
+	t.Parallel()
+
+	storage := &mockStorage{}
+	s := newTestSink(storage, &Config{})
+	require.NoError(t, s.Ping(context.Background()))
+	require.Equal(t, 1, storage.pings)
+}
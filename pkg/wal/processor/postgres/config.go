@@ -19,6 +19,9 @@ type Config struct {
 	BulkIngestEnabled bool
 	RetryPolicy       backoff.Config
 	IgnoreDDL         bool
+	// ConflictResolution defines how insert conflicts are resolved. It can't
+	// be combined with an on conflict action.
+	ConflictResolution ConflictResolutionConfig
 }
 
 const (
@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fkorder orders the insert events of a transaction so that the rows
+// referenced by a foreign key are written to the target postgres before the
+// rows referencing them.
+package fkorder
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// FKOrderingProcessor is a decorator around a wal processor that buffers the
+// events of a transaction, and sends its consecutive insert events sorted by
+// the foreign key dependencies of their tables, so that the parent rows are
+// inserted before the child rows. The relative order of any other event is not
+// modified.
+//
+// It relies on the events of a transaction being received consecutively with
+// their transaction id, so the listener needs to include the transaction
+// records. A transaction is sent to the wrapped processor when an event of a
+// different transaction, or without transaction, is received.
+type FKOrderingProcessor struct {
+	logger            loglib.Logger
+	processor         processor.Processor
+	graph             *ForeignKeyGraph
+	maxBufferedEvents int
+
+	// mutex guards the buffered transaction, and serialises the calls to the
+	// wrapped processor
+	mutex  sync.Mutex
+	xid    uint32
+	events []*wal.Event
+	// refreshGraph is set when a schema change is received, so that the
+	// foreign keys are reloaded before the following transaction is sorted
+	refreshGraph bool
+}
+
+type Config struct {
+	// URL of the postgres database the foreign keys are read from. Defaults
+	// to the target postgres URL.
+	URL string
+	// MaxBufferedEvents is the maximum number of events buffered per
+	// transaction. When reached, the buffered events are sorted and sent, and
+	// the following events of the transaction are sorted separately. Defaults
+	// to 10000.
+	MaxBufferedEvents int
+}
+
+type Option func(p *FKOrderingProcessor)
+
+const defaultMaxBufferedEvents = 10000
+
+// New will return a foreign key ordering wrapper around the processor on
+// input, using the foreign key graph on input to sort the events.
+func New(cfg *Config, graph *ForeignKeyGraph, p processor.Processor, opts ...Option) *FKOrderingProcessor {
+	f := &FKOrderingProcessor{
+		logger:            loglib.NewNoopLogger(),
+		processor:         p,
+		graph:             graph,
+		maxBufferedEvents: cfg.maxBufferedEvents(),
+	}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	return f
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(f *FKOrderingProcessor) {
+		f.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_fk_ordering",
+		})
+	}
+}
+
+// ProcessWALEvent buffers the data events of the current transaction, and
+// sends the buffered transaction to the wrapped processor once it's complete.
+func (f *FKOrderingProcessor) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	data := event.Data
+	switch {
+	case data == nil, data.XID == 0, wal.Action(data.Action) == wal.ActionCommit:
+		if err := f.flush(ctx); err != nil {
+			return err
+		}
+		return f.processor.ProcessWALEvent(ctx, event)
+	case processor.IsSchemaLogEvent(data):
+		// the schema change might add or remove foreign keys
+		f.refreshGraph = true
+		if err := f.flush(ctx); err != nil {
+			return err
+		}
+		return f.processor.ProcessWALEvent(ctx, event)
+	}
+
+	if data.XID != f.xid {
+		if err := f.flush(ctx); err != nil {
+			return err
+		}
+		f.xid = data.XID
+	}
+
+	f.events = append(f.events, event)
+	if len(f.events) >= f.maxBufferedEvents {
+		f.logger.Warn(nil, "max buffered events reached for transaction, sending events before the transaction is complete", loglib.Fields{
+			"xid":                 data.XID,
+			"max_buffered_events": f.maxBufferedEvents,
+		})
+		return f.flush(ctx)
+	}
+	return nil
+}
+
+func (f *FKOrderingProcessor) Name() string {
+	return f.processor.Name()
+}
+
+// Close sends the buffered events to the wrapped processor before closing it.
+func (f *FKOrderingProcessor) Close() error {
+	f.mutex.Lock()
+	err := f.flush(context.Background())
+	f.mutex.Unlock()
+
+	return errors.Join(err, f.graph.Close(), f.processor.Close())
+}
+
+func (f *FKOrderingProcessor) Ping(ctx context.Context) error {
+	return f.processor.Ping(ctx)
+}
+
+// flush sends the buffered events to the wrapped processor, with the
+// consecutive insert events sorted by the rank of their table. The commit
+// positions are kept in their original order, so that the positions sent to
+// the wrapped processor never go backwards. Must be called with the mutex
+// held.
+func (f *FKOrderingProcessor) flush(ctx context.Context) error {
+	if len(f.events) == 0 {
+		return nil
+	}
+
+	if f.refreshGraph {
+		if err := f.graph.Refresh(ctx); err != nil {
+			// keep sorting with the previous foreign keys, and try again with
+			// the next transaction
+			f.logger.Error(err, "refreshing foreign key graph")
+		} else {
+			f.refreshGraph = false
+		}
+	}
+
+	positions := make([]wal.CommitPosition, 0, len(f.events))
+	for _, event := range f.events {
+		positions = append(positions, event.CommitPosition)
+	}
+
+	f.sortInserts(f.events)
+
+	for i, event := range f.events {
+		event.CommitPosition = positions[i]
+		if err := f.processor.ProcessWALEvent(ctx, event); err != nil {
+			return fmt.Errorf("sending events of transaction %d: %w", f.xid, err)
+		}
+	}
+	f.events = nil
+	return nil
+}
+
+// sortInserts sorts the runs of consecutive insert events by the rank of their
+// table. The sort is stable, so the inserts of the same table, and of the
+// tables that are part of the same circular dependency, keep their order.
+func (f *FKOrderingProcessor) sortInserts(events []*wal.Event) {
+	for start := 0; start < len(events); {
+		if !events[start].Data.IsInsert() {
+			start++
+			continue
+		}
+		end := start + 1
+		for end < len(events) && events[end].Data.IsInsert() {
+			end++
+		}
+		slices.SortStableFunc(events[start:end], func(a, b *wal.Event) int {
+			return cmp.Compare(f.graph.Rank(a.Data.Schema, a.Data.Table), f.graph.Rank(b.Data.Schema, b.Data.Table))
+		})
+		start = end
+	}
+}
+
+func (c *Config) maxBufferedEvents() int {
+	if c.MaxBufferedEvents > 0 {
+		return c.MaxBufferedEvents
+	}
+	return defaultMaxBufferedEvents
+}
@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package fkorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+func TestFKOrderingProcessor_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	ranks := map[string]int{
+		"public.customers": 0,
+		"public.orders":    1,
+		"public.items":     2,
+	}
+
+	newEvent := func(xid uint32, action wal.Action, table string, pos int) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action: string(action),
+				Schema: "public",
+				Table:  table,
+				XID:    xid,
+			},
+			CommitPosition: wal.CommitPosition(fmt.Sprintf("%d", pos)),
+		}
+	}
+
+	// sent is a short representation of the events sent to the wrapped
+	// processor: table@position
+	type sent = string
+
+	tests := []struct {
+		name              string
+		events            []*wal.Event
+		maxBufferedEvents int
+		processErr        error
+
+		wantSent []sent
+		wantErr  error
+	}{
+		{
+			name: "inserts sorted by foreign key dependencies on transaction change",
+			events: []*wal.Event{
+				newEvent(1, wal.ActionInsert, "items", 1),
+				newEvent(1, wal.ActionInsert, "orders", 2),
+				newEvent(1, wal.ActionInsert, "customers", 3),
+				newEvent(1, wal.ActionInsert, "unknown", 4),
+				newEvent(2, wal.ActionInsert, "items", 5),
+			},
+
+			wantSent: []sent{"customers@1", "unknown@2", "orders@3", "items@4"},
+		},
+		{
+			name: "non insert events are not reordered",
+			events: []*wal.Event{
+				newEvent(1, wal.ActionInsert, "orders", 1),
+				newEvent(1, wal.ActionInsert, "customers", 2),
+				newEvent(1, wal.ActionDelete, "customers", 3),
+				newEvent(1, wal.ActionInsert, "items", 4),
+				newEvent(1, wal.ActionInsert, "orders", 5),
+				{CommitPosition: "6"},
+			},
+
+			wantSent: []sent{"customers@1", "orders@2", "customers@3", "orders@4", "items@5", "@6"},
+		},
+		{
+			name: "commit event flushes the transaction",
+			events: []*wal.Event{
+				newEvent(1, wal.ActionInsert, "orders", 1),
+				newEvent(1, wal.ActionInsert, "customers", 2),
+				newEvent(1, wal.ActionCommit, "", 3),
+			},
+
+			wantSent: []sent{"customers@1", "orders@2", "@3"},
+		},
+		{
+			name: "events without transaction are not buffered",
+			events: []*wal.Event{
+				newEvent(0, wal.ActionInsert, "orders", 1),
+				newEvent(0, wal.ActionInsert, "customers", 2),
+			},
+
+			wantSent: []sent{"orders@1", "customers@2"},
+		},
+		{
+			name: "max buffered events reached",
+			events: []*wal.Event{
+				newEvent(1, wal.ActionInsert, "orders", 1),
+				newEvent(1, wal.ActionInsert, "customers", 2),
+				newEvent(1, wal.ActionInsert, "items", 3),
+				newEvent(1, wal.ActionInsert, "customers", 4),
+			},
+			maxBufferedEvents: 2,
+
+			wantSent: []sent{"customers@1", "orders@2", "customers@3", "items@4"},
+		},
+		{
+			name: "error - processing buffered events",
+			events: []*wal.Event{
+				newEvent(1, wal.ActionInsert, "orders", 1),
+				{CommitPosition: "2"},
+			},
+			processErr: errTest,
+
+			wantSent: []sent{"orders@1"},
+			wantErr:  errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotSent := []sent{}
+			mockProcessor := &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+					table := ""
+					if event.Data != nil {
+						table = event.Data.Table
+					}
+					gotSent = append(gotSent, fmt.Sprintf("%s@%s", table, event.CommitPosition))
+					return tc.processErr
+				},
+			}
+
+			p := New(&Config{MaxBufferedEvents: tc.maxBufferedEvents}, &ForeignKeyGraph{ranks: ranks}, mockProcessor)
+
+			var err error
+			for _, event := range tc.events {
+				if err = p.ProcessWALEvent(context.Background(), event); err != nil {
+					break
+				}
+			}
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantSent, gotSent)
+		})
+	}
+}
+
+func TestFKOrderingProcessor_SchemaChange(t *testing.T) {
+	t.Parallel()
+
+	refreshed := false
+	graph := &ForeignKeyGraph{
+		ranks: map[string]int{},
+		conn: &pgmocks.Querier{
+			QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+				refreshed = true
+				return newMockForeignKeyRows([]ForeignKey{
+					{Name: "fk", Schema: "public", Table: "orders", ReferencedSchema: "public", ReferencedTable: "customers"},
+				}), nil
+			},
+		},
+	}
+
+	gotTables := []string{}
+	mockProcessor := &mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+			if event.Data != nil {
+				gotTables = append(gotTables, event.Data.Table)
+			}
+			return nil
+		},
+	}
+
+	p := New(&Config{}, graph, mockProcessor)
+
+	events := []*wal.Event{
+		{Data: &wal.Data{Action: "I", Schema: schemalog.SchemaName, Table: schemalog.TableName, XID: 1}},
+		{Data: &wal.Data{Action: "I", Schema: "public", Table: "orders", XID: 2}},
+		{Data: &wal.Data{Action: "I", Schema: "public", Table: "customers", XID: 2}},
+	}
+	for _, event := range events {
+		require.NoError(t, p.ProcessWALEvent(context.Background(), event))
+	}
+	// the schema log event is not buffered, and the graph is only refreshed
+	// when the next transaction is sent
+	require.Equal(t, []string{schemalog.TableName}, gotTables)
+	require.False(t, refreshed)
+
+	require.NoError(t, p.Close())
+	require.True(t, refreshed)
+	require.Equal(t, []string{schemalog.TableName, "customers", "orders"}, gotTables)
+}
@@ -0,0 +1,261 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package fkorder
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+)
+
+// ForeignKeyGraph keeps the dependencies between the tables of a postgres
+// database as defined by their foreign key constraints. Tables are ranked so
+// that the referenced tables come before the tables referencing them. Tables
+// that are part of a circular dependency share the same rank.
+type ForeignKeyGraph struct {
+	conn pglib.Querier
+
+	mutex sync.RWMutex
+	ranks map[string]int
+	// circularForeignKeys are the foreign keys that are part of a circular
+	// dependency, indexed by the referencing table
+	circularForeignKeys map[string][]ForeignKey
+}
+
+// ForeignKey is a foreign key constraint of the table, referencing the
+// referenced table.
+type ForeignKey struct {
+	Name             string
+	Schema           string
+	Table            string
+	ReferencedSchema string
+	ReferencedTable  string
+	Deferrable       bool
+}
+
+const foreignKeysQuery = `SELECT con.conname, cn.nspname, c.relname, pn.nspname, p.relname, con.condeferrable
+	FROM pg_catalog.pg_constraint con
+	JOIN pg_catalog.pg_class c ON c.oid = con.conrelid
+	JOIN pg_catalog.pg_namespace cn ON cn.oid = c.relnamespace
+	JOIN pg_catalog.pg_class p ON p.oid = con.confrelid
+	JOIN pg_catalog.pg_namespace pn ON pn.oid = p.relnamespace
+	WHERE con.contype = 'f'
+	ORDER BY cn.nspname, c.relname, con.conname`
+
+// NewForeignKeyGraph returns the foreign key graph of the postgres database on
+// input. The foreign keys are loaded on creation, and can be reloaded with
+// Refresh when the schema changes.
+func NewForeignKeyGraph(ctx context.Context, pgURL string) (*ForeignKeyGraph, error) {
+	conn, err := pglib.NewConnPool(ctx, pgURL)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &ForeignKeyGraph{conn: conn}
+	if err := g.Refresh(ctx); err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+	return g, nil
+}
+
+// Refresh reloads the foreign keys from the database and rebuilds the graph.
+func (g *ForeignKeyGraph) Refresh(ctx context.Context) error {
+	foreignKeys, err := g.getForeignKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	ranks, circularForeignKeys := buildGraph(foreignKeys)
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.ranks = ranks
+	g.circularForeignKeys = circularForeignKeys
+	return nil
+}
+
+// Rank returns the position of the table in the dependency order. Tables need
+// to be written in increasing rank order to satisfy their foreign keys.
+// Tables without foreign keys have rank 0.
+func (g *ForeignKeyGraph) Rank(schema, table string) int {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+	return g.ranks[tableKey(schema, table)]
+}
+
+// DeferConstraints returns the statements to run at the start and at the end
+// of a target transaction inserting into the tables on input, so that the
+// foreign key checks are deferred until the end of the transaction. The checks
+// are only deferred when both tables of a circular foreign key are inserted
+// into, since the inserts into the other tables are ordered by the processor.
+// The circular foreign keys that are not deferrable are temporarily made
+// deferrable, and restored once they've been checked, which locks their
+// tables. Tables are schema qualified, unquoted.
+func (g *ForeignKeyGraph) DeferConstraints(tables []string) (before, after []string) {
+	g.mutex.RLock()
+	defer g.mutex.RUnlock()
+
+	circular := []ForeignKey{}
+	for _, table := range tables {
+		for _, fk := range g.circularForeignKeys[table] {
+			referenced := tableKey(fk.ReferencedSchema, fk.ReferencedTable)
+			if slices.Contains(tables, referenced) && !slices.Contains(circular, fk) {
+				circular = append(circular, fk)
+			}
+		}
+	}
+	if len(circular) == 0 {
+		return nil, nil
+	}
+
+	notDeferrable := slices.DeleteFunc(circular, func(fk ForeignKey) bool { return fk.Deferrable })
+	for _, fk := range notDeferrable {
+		before = append(before, fmt.Sprintf("ALTER TABLE %s ALTER CONSTRAINT %s DEFERRABLE", fk.quotedTable(), pglib.QuoteIdentifier(fk.Name)))
+	}
+	before = append(before, "SET CONSTRAINTS ALL DEFERRED")
+
+	// run the deferred checks before the end of the transaction, so that the
+	// violations are reported with the queries, and so that the foreign keys
+	// can be made not deferrable again, since their definition can't be
+	// altered with pending checks
+	after = append(after, "SET CONSTRAINTS ALL IMMEDIATE")
+	for _, fk := range notDeferrable {
+		after = append(after, fmt.Sprintf("ALTER TABLE %s ALTER CONSTRAINT %s NOT DEFERRABLE", fk.quotedTable(), pglib.QuoteIdentifier(fk.Name)))
+	}
+	return before, after
+}
+
+func (g *ForeignKeyGraph) Close() error {
+	return g.conn.Close(context.Background())
+}
+
+func (g *ForeignKeyGraph) getForeignKeys(ctx context.Context) ([]ForeignKey, error) {
+	rows, err := g.conn.Query(ctx, foreignKeysQuery)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	foreignKeys := []ForeignKey{}
+	for rows.Next() {
+		fk := ForeignKey{}
+		if err := rows.Scan(&fk.Name, &fk.Schema, &fk.Table, &fk.ReferencedSchema, &fk.ReferencedTable, &fk.Deferrable); err != nil {
+			return nil, fmt.Errorf("scanning foreign key: %w", err)
+		}
+		foreignKeys = append(foreignKeys, fk)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return foreignKeys, nil
+}
+
+// buildGraph returns the rank of the tables referenced by the foreign keys,
+// and the foreign keys that are part of a circular dependency. The strongly
+// connected components of the graph are computed with Tarjan's algorithm,
+// which finds them in reverse topological order, meaning the referenced
+// tables are found before the tables referencing them.
+func buildGraph(foreignKeys []ForeignKey) (map[string]int, map[string][]ForeignKey) {
+	edges := map[string][]string{}
+	for _, fk := range foreignKeys {
+		from := tableKey(fk.Schema, fk.Table)
+		to := tableKey(fk.ReferencedSchema, fk.ReferencedTable)
+		if !slices.Contains(edges[from], to) {
+			edges[from] = append(edges[from], to)
+		}
+		if _, found := edges[to]; !found {
+			edges[to] = nil
+		}
+	}
+
+	// iterate in order to make the ranks deterministic
+	tables := make([]string, 0, len(edges))
+	for table := range edges {
+		slices.Sort(edges[table])
+		tables = append(tables, table)
+	}
+	slices.Sort(tables)
+
+	t := &tarjan{
+		edges:   edges,
+		index:   map[string]int{},
+		lowLink: map[string]int{},
+		onStack: map[string]bool{},
+		ranks:   map[string]int{},
+	}
+	for _, table := range tables {
+		if _, visited := t.index[table]; !visited {
+			t.strongConnect(table)
+		}
+	}
+
+	circularForeignKeys := map[string][]ForeignKey{}
+	for _, fk := range foreignKeys {
+		from := tableKey(fk.Schema, fk.Table)
+		to := tableKey(fk.ReferencedSchema, fk.ReferencedTable)
+		// foreign keys within the same component are part of a cycle, which
+		// includes the self references
+		if t.ranks[from] == t.ranks[to] {
+			circularForeignKeys[from] = append(circularForeignKeys[from], fk)
+		}
+	}
+
+	return t.ranks, circularForeignKeys
+}
+
+type tarjan struct {
+	edges   map[string][]string
+	index   map[string]int
+	lowLink map[string]int
+	onStack map[string]bool
+	stack   []string
+	// ranks is the index of the strongly connected component of each table
+	ranks      map[string]int
+	components int
+}
+
+func (t *tarjan) strongConnect(table string) {
+	t.index[table] = len(t.index)
+	t.lowLink[table] = t.index[table]
+	t.stack = append(t.stack, table)
+	t.onStack[table] = true
+
+	for _, referenced := range t.edges[table] {
+		if _, visited := t.index[referenced]; !visited {
+			t.strongConnect(referenced)
+			t.lowLink[table] = min(t.lowLink[table], t.lowLink[referenced])
+		} else if t.onStack[referenced] {
+			t.lowLink[table] = min(t.lowLink[table], t.index[referenced])
+		}
+	}
+
+	if t.lowLink[table] != t.index[table] {
+		return
+	}
+
+	// table is the root of a strongly connected component
+	for {
+		member := t.stack[len(t.stack)-1]
+		t.stack = t.stack[:len(t.stack)-1]
+		t.onStack[member] = false
+		t.ranks[member] = t.components
+		if member == table {
+			break
+		}
+	}
+	t.components++
+}
+
+func (fk ForeignKey) quotedTable() string {
+	return pglib.QuoteQualifiedIdentifier(fk.Schema, fk.Table)
+}
+
+func tableKey(schema, table string) string {
+	return schema + "." + table
+}
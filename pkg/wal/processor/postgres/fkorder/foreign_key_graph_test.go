@@ -0,0 +1,273 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package fkorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+)
+
+func TestForeignKeyGraph_Refresh(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name    string
+		querier *pgmocks.Querier
+
+		wantRanks    map[string]int
+		wantCircular map[string][]ForeignKey
+		wantErr      error
+	}{
+		{
+			name: "ok",
+			querier: &pgmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					require.Equal(t, foreignKeysQuery, query)
+					return newMockForeignKeyRows([]ForeignKey{
+						{Name: "orders_customer_fk", Schema: "public", Table: "orders", ReferencedSchema: "public", ReferencedTable: "customers"},
+					}), nil
+				},
+			},
+
+			wantRanks: map[string]int{
+				"public.customers": 0,
+				"public.orders":    1,
+			},
+			wantCircular: map[string][]ForeignKey{},
+			wantErr:      nil,
+		},
+		{
+			name: "error - querying foreign keys",
+			querier: &pgmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					return nil, errTest
+				},
+			},
+
+			wantRanks:    map[string]int{"public.a": 0},
+			wantCircular: map[string][]ForeignKey{},
+			wantErr:      errTest,
+		},
+		{
+			name: "error - scanning foreign keys",
+			querier: &pgmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					return &pgmocks.Rows{
+						NextFn:  func(i uint) bool { return i == 1 },
+						ScanFn:  func(i uint, dest ...any) error { return errTest },
+						CloseFn: func() {},
+						ErrFn:   func() error { return nil },
+					}, nil
+				},
+			},
+
+			wantRanks:    map[string]int{"public.a": 0},
+			wantCircular: map[string][]ForeignKey{},
+			wantErr:      errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := &ForeignKeyGraph{
+				conn:                tc.querier,
+				ranks:               map[string]int{"public.a": 0},
+				circularForeignKeys: map[string][]ForeignKey{},
+			}
+			err := g.Refresh(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantRanks, g.ranks)
+			require.Equal(t, tc.wantCircular, g.circularForeignKeys)
+		})
+	}
+}
+
+func TestBuildGraph(t *testing.T) {
+	t.Parallel()
+
+	fk := func(table, referencedTable string, deferrable bool) ForeignKey {
+		return ForeignKey{
+			Name:             table + "_" + referencedTable + "_fk",
+			Schema:           "public",
+			Table:            table,
+			ReferencedSchema: "public",
+			ReferencedTable:  referencedTable,
+			Deferrable:       deferrable,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		foreignKeys []ForeignKey
+
+		wantRanks    map[string]int
+		wantCircular map[string][]ForeignKey
+	}{
+		{
+			name:         "no foreign keys",
+			foreignKeys:  []ForeignKey{},
+			wantRanks:    map[string]int{},
+			wantCircular: map[string][]ForeignKey{},
+		},
+		{
+			name: "chain",
+			foreignKeys: []ForeignKey{
+				fk("order_items", "orders", false),
+				fk("orders", "customers", false),
+				fk("order_items", "products", false),
+			},
+			wantRanks: map[string]int{
+				"public.customers":   0,
+				"public.orders":      1,
+				"public.products":    2,
+				"public.order_items": 3,
+			},
+			wantCircular: map[string][]ForeignKey{},
+		},
+		{
+			name: "cross schema",
+			foreignKeys: []ForeignKey{
+				{Name: "fk", Schema: "sales", Table: "orders", ReferencedSchema: "public", ReferencedTable: "orders"},
+			},
+			wantRanks: map[string]int{
+				"public.orders": 0,
+				"sales.orders":  1,
+			},
+			wantCircular: map[string][]ForeignKey{},
+		},
+		{
+			name: "self reference",
+			foreignKeys: []ForeignKey{
+				fk("employees", "employees", false),
+				fk("employees", "departments", false),
+			},
+			wantRanks: map[string]int{
+				"public.departments": 0,
+				"public.employees":   1,
+			},
+			wantCircular: map[string][]ForeignKey{
+				"public.employees": {fk("employees", "employees", false)},
+			},
+		},
+		{
+			name: "cycle",
+			foreignKeys: []ForeignKey{
+				fk("a", "b", false),
+				fk("b", "c", true),
+				fk("c", "a", false),
+				fk("d", "a", false),
+				fk("a", "e", false),
+			},
+			wantRanks: map[string]int{
+				"public.e": 0,
+				"public.a": 1,
+				"public.b": 1,
+				"public.c": 1,
+				"public.d": 2,
+			},
+			wantCircular: map[string][]ForeignKey{
+				"public.a": {fk("a", "b", false)},
+				"public.b": {fk("b", "c", true)},
+				"public.c": {fk("c", "a", false)},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ranks, circular := buildGraph(tc.foreignKeys)
+			require.Equal(t, tc.wantRanks, ranks)
+			require.Equal(t, tc.wantCircular, circular)
+		})
+	}
+}
+
+func TestForeignKeyGraph_DeferConstraints(t *testing.T) {
+	t.Parallel()
+
+	g := &ForeignKeyGraph{
+		circularForeignKeys: map[string][]ForeignKey{
+			"public.a": {{Name: "a_b_fk", Schema: "public", Table: "a", ReferencedSchema: "public", ReferencedTable: "b"}},
+			"public.b": {{Name: "b_a_fk", Schema: "public", Table: "b", ReferencedSchema: "public", ReferencedTable: "a", Deferrable: true}},
+			"public.d": {{Name: "d_d_fk", Schema: "public", Table: "d", ReferencedSchema: "public", ReferencedTable: "d", Deferrable: true}},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		tables []string
+
+		wantBefore []string
+		wantAfter  []string
+	}{
+		{
+			name:   "no circular foreign keys",
+			tables: []string{"public.c", "public.e"},
+		},
+		{
+			name:   "circular foreign key with a single table inserted into",
+			tables: []string{"public.a"},
+		},
+		{
+			name:       "deferrable self reference",
+			tables:     []string{"public.c", "public.d"},
+			wantBefore: []string{"SET CONSTRAINTS ALL DEFERRED"},
+			wantAfter:  []string{"SET CONSTRAINTS ALL IMMEDIATE"},
+		},
+		{
+			name:   "not deferrable circular foreign key",
+			tables: []string{"public.a", "public.b", "public.a"},
+			wantBefore: []string{
+				`ALTER TABLE "public"."a" ALTER CONSTRAINT "a_b_fk" DEFERRABLE`,
+				"SET CONSTRAINTS ALL DEFERRED",
+			},
+			wantAfter: []string{
+				"SET CONSTRAINTS ALL IMMEDIATE",
+				`ALTER TABLE "public"."a" ALTER CONSTRAINT "a_b_fk" NOT DEFERRABLE`,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			before, after := g.DeferConstraints(tc.tables)
+			require.Equal(t, tc.wantBefore, before)
+			require.Equal(t, tc.wantAfter, after)
+		})
+	}
+}
+
+func newMockForeignKeyRows(foreignKeys []ForeignKey) *pgmocks.Rows {
+	return &pgmocks.Rows{
+		NextFn: func(i uint) bool { return i <= uint(len(foreignKeys)) },
+		ScanFn: func(i uint, dest ...any) error {
+			fk := foreignKeys[i-1]
+			if len(dest) != 6 {
+				return fmt.Errorf("unexpected number of scan destinations: %d", len(dest))
+			}
+			*dest[0].(*string) = fk.Name
+			*dest[1].(*string) = fk.Schema
+			*dest[2].(*string) = fk.Table
+			*dest[3].(*string) = fk.ReferencedSchema
+			*dest[4].(*string) = fk.ReferencedTable
+			*dest[5].(*bool) = fk.Deferrable
+			return nil
+		},
+		CloseFn: func() {},
+		ErrFn:   func() error { return nil },
+	}
+}
@@ -27,6 +27,8 @@ type BatchWriter struct {
 
 const batchWriter = "postgres_batch_writer"
 
+var errDeferredChecks = errors.New("deferred constraint checks failed")
+
 var errSchemaLogStoreNotProvided = errors.New("schema log store URL must be provided or DDL events will not be processed. If this is intended, set ignore DDL to true")
 
 // NewBatchWriter returns a postgres processor that batches and writes data to
@@ -54,7 +56,7 @@ func NewBatchWriter(ctx context.Context, config *Config, opts ...WriterOption) (
 		Writer: w,
 	}
 
-	bw.batchSender, err = batch.NewSender(ctx, &config.BatchConfig, bw.sendBatch, w.logger)
+	bw.batchSender, err = batch.NewSender(ctx, &config.BatchConfig, bw.sendBatch, w.logger, batch.WithInstrumentation(w.instrumentation, w.writerType))
 	if err != nil {
 		return nil, err
 	}
@@ -83,7 +85,7 @@ func (w *BatchWriter) ProcessWALEvent(ctx context.Context, walEvent *wal.Event)
 	}
 
 	for _, q := range queries {
-		w.logger.Debug("batching query", loglib.Fields{"sql": q.getSQL(), "args": q.getArgs(), "commit_position": walEvent.CommitPosition})
+		w.logger.Debug("batching query", walEvent.LogFields(), loglib.Fields{"sql": q.getSQL(), "args": q.getArgs()})
 		msg := batch.NewWALMessage(q, walEvent.CommitPosition)
 		if err := w.batchSender.SendMessage(ctx, msg); err != nil {
 			return err
@@ -106,10 +108,11 @@ func (w *BatchWriter) Close() error {
 
 func (w *BatchWriter) sendBatch(ctx context.Context, batch *batch.Batch[*query]) error {
 	queries := batch.GetMessages()
+	positions := batch.GetCommitPositions()
+	// we'll mostly process DML queries, so pre-allocate the max size
+	dmlQueries := make([]*query, 0, len(queries))
 	if len(queries) > 0 {
 		w.logger.Debug("sending batch", loglib.Fields{"batch_size": len(queries)})
-		// we'll mostly process DML queries, so pre-allocate the max size
-		dmlQueries := make([]*query, 0, len(queries))
 		for _, q := range queries {
 			if !q.isDDL {
 				dmlQueries = append(dmlQueries, q)
@@ -118,7 +121,7 @@ func (w *BatchWriter) sendBatch(ctx context.Context, batch *batch.Batch[*query])
 
 			// flush any previous DML queries before running the DDL query to ensure
 			// they are run in their own separate transaction
-			if err := w.flushQueries(ctx, dmlQueries); err != nil {
+			if err := w.flushQueries(ctx, dmlQueries, nil); err != nil {
 				w.logger.Error(err, "flushing DML queries")
 				return err
 			}
@@ -131,47 +134,82 @@ func (w *BatchWriter) sendBatch(ctx context.Context, batch *batch.Batch[*query])
 				return err
 			}
 		}
+	}
 
-		if err := w.flushQueries(ctx, dmlQueries); err != nil {
-			w.logger.Error(err, "flushing DML queries")
-			return err
-		}
+	// the commit positions are checkpointed along with the last DML queries
+	// of the batch when the checkpoint is transactional
+	var txPositions []wal.CommitPosition
+	if w.txCheckpoint != nil {
+		txPositions = positions
+	}
+	if err := w.flushQueries(ctx, dmlQueries, txPositions); err != nil {
+		w.logger.Error(err, "flushing DML queries")
+		return err
 	}
 
-	if w.checkpointer != nil && len(batch.GetCommitPositions()) > 0 {
-		return w.checkpointer(ctx, batch.GetCommitPositions())
+	if w.checkpointer != nil && len(positions) > 0 {
+		return w.checkpointer(ctx, positions)
 	}
 
 	return nil
 }
 
-func (w *BatchWriter) flushQueries(ctx context.Context, queries []*query) error {
-	if len(queries) == 0 {
-		return nil
-	}
-
-	var err error
-	for {
-		queries, err = w.execQueries(ctx, queries)
-		if err != nil {
+// flushQueries runs the queries on input in a transaction, retrying without
+// the failing query when there's a non internal error. The positions on input
+// are checkpointed within the transaction that succeeds, or in their own
+// transaction if none of the queries can be applied. When the deferred
+// constraint checks fail, the queries are retried without deferring them, so
+// that only the queries violating the constraints are skipped.
+func (w *BatchWriter) flushQueries(ctx context.Context, queries []*query, positions []wal.CommitPosition) error {
+	deferConstraints := true
+	for len(queries) > 0 {
+		retryQueries, committed, err := w.execQueries(ctx, queries, positions, deferConstraints)
+		switch {
+		case errors.Is(err, errDeferredChecks):
+			w.logger.Warn(err, "retrying queries without deferring constraints", loglib.Fields{
+				"queries": len(queries),
+			})
+			deferConstraints = false
+			continue
+		case err != nil:
 			return err
-		}
-
-		if len(queries) == 0 {
+		case committed:
 			return nil
 		}
+		queries = retryQueries
 	}
+
+	if len(positions) == 0 {
+		return nil
+	}
+	return w.pgConn.ExecInTx(ctx, func(tx pglib.Tx) error {
+		return w.txCheckpoint(ctx, tx, positions)
+	})
 }
 
-func (w *BatchWriter) execQueries(ctx context.Context, queries []*query) ([]*query, error) {
-	retryQueries := []*query{}
+// execQueries runs the queries on input in a transaction. It returns the
+// queries to retry if one of them failed with a non internal error, in which
+// case the failing query is skipped. Any other failure of the transaction is
+// returned as an error, since retrying without one of the queries would not
+// fix it.
+func (w *BatchWriter) execQueries(ctx context.Context, queries []*query, positions []wal.CommitPosition, deferConstraints bool) ([]*query, bool, error) {
+	var retryQueries []*query
+	var checkpointErr, deferErr, checkErr error
+	var deferStatements, checkStatements []string
+	if deferConstraints {
+		deferStatements, checkStatements = w.deferConstraints(queries)
+	}
 	err := w.pgConn.ExecInTx(ctx, func(tx pglib.Tx) error {
 		if err := w.setReplicationRoleToReplica(ctx, tx); err != nil {
 			return err
 		}
 
+		if deferErr = execStatements(ctx, tx, deferStatements); deferErr != nil {
+			return deferErr
+		}
+
 		for i, q := range queries {
-			if _, err := tx.Exec(ctx, q.sql, q.args...); err != nil {
+			if err := w.execQuery(ctx, tx, q); err != nil {
 				w.logger.Error(err, "executing sql query", loglib.Fields{
 					"sql":      q.sql,
 					"args":     q.args,
@@ -185,17 +223,54 @@ func (w *BatchWriter) execQueries(ctx context.Context, queries []*query) ([]*que
 			}
 		}
 
+		// the deferred checks fail at this point if the constraints are not
+		// satisfied, without identifying the queries violating them
+		if checkErr = execStatements(ctx, tx, checkStatements); checkErr != nil {
+			return checkErr
+		}
+
+		if len(positions) > 0 {
+			if checkpointErr = w.txCheckpoint(ctx, tx, positions); checkpointErr != nil {
+				return checkpointErr
+			}
+		}
+
 		return w.resetReplicationRole(ctx, tx)
 	})
-	if err != nil && w.isInternalError(err) {
-		// if there was an internal error in the tx, there's no point in
-		// retrying, return error and stop processing.
-		return nil, err
+	switch {
+	case err == nil:
+		return nil, true, nil
+	// the failed checkpoint aborted the transaction, and the queries can't be
+	// retried without it or their positions would never be checkpointed
+	case checkpointErr != nil:
+		return nil, false, fmt.Errorf("checkpointing in target transaction: %w", checkpointErr)
+	case deferErr != nil:
+		return nil, false, fmt.Errorf("deferring constraints: %w", deferErr)
+	case checkErr != nil:
+		return nil, false, fmt.Errorf("%w: %w", errDeferredChecks, checkErr)
+	// if there was an internal error in the tx, or the tx failed for a reason
+	// other than one of its queries, there's no point in retrying, return
+	// error and stop processing.
+	case retryQueries == nil, w.isInternalError(err):
+		return nil, false, err
 	}
 
-	// if there were no errors or no internal errors in the tx, return the
-	// queries to retry (none if the tx was successful)
-	return retryQueries, nil
+	// the failing query was not an internal error, retry without it
+	return retryQueries, false, nil
+}
+
+func (w *BatchWriter) execQuery(ctx context.Context, tx pglib.Tx, q *query) error {
+	tag, err := tx.Exec(ctx, q.sql, q.args...)
+	if err != nil {
+		return err
+	}
+
+	// the insert didn't affect any rows, which means there was a conflict with
+	// an existing row that needs resolving
+	if q.conflict != nil && w.conflictResolver != nil && tag.RowsAffected() == 0 {
+		return w.resolveConflict(ctx, tx, q.conflict)
+	}
+	return nil
 }
 
 func (w *BatchWriter) isInternalError(err error) bool {
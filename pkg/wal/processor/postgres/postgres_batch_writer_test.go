@@ -279,6 +279,103 @@ func TestBatchWriter_sendBatch(t *testing.T) {
 	}
 }
 
+func TestBatchWriter_sendBatch_txCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	testQuery := &query{
+		sql:  "INSERT INTO test(id, name) VALUES($1, $2)",
+		args: []any{1, "alice"},
+	}
+	checkpointSQL := "checkpoint"
+	errConstraintViolation := &pglib.ErrConstraintViolation{}
+
+	tests := []struct {
+		name    string
+		batch   *batch.Batch[*query]
+		execErr error
+		txErr   error
+
+		wantTxs     [][]string
+		wantChecked bool
+		wantErr     error
+	}{
+		{
+			name:  "ok - checkpoint in the batch transaction",
+			batch: batch.NewBatch([]*query{testQuery}, []wal.CommitPosition{testCommitPosition}),
+
+			wantTxs:     [][]string{{testQuery.sql, checkpointSQL}},
+			wantChecked: true,
+		},
+		{
+			name:  "ok - checkpoint without queries",
+			batch: batch.NewBatch([]*query{}, []wal.CommitPosition{testCommitPosition}),
+
+			wantTxs:     [][]string{{checkpointSQL}},
+			wantChecked: true,
+		},
+		{
+			name:    "ok - checkpoint after failing queries",
+			batch:   batch.NewBatch([]*query{testQuery}, []wal.CommitPosition{testCommitPosition}),
+			execErr: errConstraintViolation,
+
+			wantTxs:     [][]string{{testQuery.sql}, {checkpointSQL}},
+			wantChecked: true,
+		},
+		{
+			name:  "error - checkpointing in the batch transaction",
+			batch: batch.NewBatch([]*query{testQuery}, []wal.CommitPosition{testCommitPosition}),
+			// not an internal error, but the queries can't be retried
+			txErr: errConstraintViolation,
+
+			wantTxs:     [][]string{{testQuery.sql, checkpointSQL}},
+			wantChecked: false,
+			wantErr:     errConstraintViolation,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotTxs := [][]string{}
+			pgconn := &pgmocks.Querier{
+				ExecInTxFn: func(ctx context.Context, f func(tx pglib.Tx) error) error {
+					gotTxs = append(gotTxs, []string{})
+					mockTx := pgmocks.Tx{
+						ExecFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.CommandTag, error) {
+							gotTxs[len(gotTxs)-1] = append(gotTxs[len(gotTxs)-1], query)
+							return pglib.CommandTag{}, tc.execErr
+						},
+					}
+					return f(&mockTx)
+				},
+			}
+
+			checked := false
+			writer := &BatchWriter{
+				Writer: &Writer{
+					logger: loglib.NewNoopLogger(),
+					pgConn: pgconn,
+					txCheckpoint: func(ctx context.Context, tx pglib.Tx, positions []wal.CommitPosition) error {
+						require.Equal(t, []wal.CommitPosition{testCommitPosition}, positions)
+						gotTxs[len(gotTxs)-1] = append(gotTxs[len(gotTxs)-1], checkpointSQL)
+						return tc.txErr
+					},
+					checkpointer: func(ctx context.Context, positions []wal.CommitPosition) error {
+						checked = true
+						return nil
+					},
+				},
+			}
+
+			err := writer.sendBatch(context.Background(), tc.batch)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantTxs, gotTxs)
+			require.Equal(t, tc.wantChecked, checked)
+		})
+	}
+}
+
 func TestBatchWriter_flushQueries(t *testing.T) {
 	t.Parallel()
 
@@ -293,12 +390,14 @@ func TestBatchWriter_flushQueries(t *testing.T) {
 		}
 	}
 	execCalls := uint(0)
+	errPreconditionFailed := &pglib.ErrPreconditionFailed{}
 
 	tests := []struct {
-		name            string
-		pgconn          *pgmocks.Querier
-		queries         []*query
-		disableTriggers bool
+		name               string
+		pgconn             *pgmocks.Querier
+		queries            []*query
+		disableTriggers    bool
+		constraintDeferrer ConstraintDeferrer
 
 		wantExecCalls uint
 		wantErr       error
@@ -388,6 +487,140 @@ func TestBatchWriter_flushQueries(t *testing.T) {
 			wantExecCalls: 6,
 			wantErr:       nil,
 		},
+		{
+			name: "ok - deferred constraints",
+			pgconn: &pgmocks.Querier{
+				ExecInTxFn: func(ctx context.Context, f func(tx pglib.Tx) error) error {
+					mockTx := pgmocks.Tx{
+						ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+							execCalls++
+							switch i {
+							case 1:
+								require.Equal(t, "SET CONSTRAINTS ALL DEFERRED", query)
+							case 2:
+								require.Equal(t, testQuerySQL, query)
+								require.Equal(t, args1, args)
+							case 3:
+								require.Equal(t, "SET CONSTRAINTS ALL IMMEDIATE", query)
+							default:
+								return pglib.CommandTag{}, fmt.Errorf("unexpected call to tx ExecFn: %v", query)
+							}
+							return pglib.CommandTag{}, nil
+						},
+					}
+					return f(&mockTx)
+				},
+			},
+			queries: []*query{{schema: "public", table: "test", sql: testQuerySQL, args: args1, isInsert: true}},
+			constraintDeferrer: &mockConstraintDeferrer{
+				deferConstraintsFn: func(tables []string) ([]string, []string) {
+					require.Equal(t, []string{"public.test"}, tables)
+					return []string{"SET CONSTRAINTS ALL DEFERRED"}, []string{"SET CONSTRAINTS ALL IMMEDIATE"}
+				},
+			},
+
+			wantExecCalls: 3,
+			wantErr:       nil,
+		},
+		{
+			name: "ok - deferred checks failing, retried without deferring constraints",
+			pgconn: func() *pgmocks.Querier {
+				txCalls := 0
+				return &pgmocks.Querier{
+					ExecInTxFn: func(ctx context.Context, f func(tx pglib.Tx) error) error {
+						txCalls++
+						deferred := txCalls == 1
+						mockTx := pgmocks.Tx{
+							ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+								execCalls++
+								switch {
+								case deferred && i == 1:
+									require.Equal(t, "SET CONSTRAINTS ALL DEFERRED", query)
+								case deferred && i == 2, !deferred && i == 1:
+									require.Equal(t, testQuerySQL, query)
+									require.Equal(t, args1, args)
+								case deferred && i == 3:
+									require.Equal(t, "SET CONSTRAINTS ALL IMMEDIATE", query)
+									return pglib.CommandTag{}, &pglib.ErrConstraintViolation{}
+								default:
+									return pglib.CommandTag{}, fmt.Errorf("unexpected call to tx ExecFn: %v", query)
+								}
+								return pglib.CommandTag{}, nil
+							},
+						}
+						return f(&mockTx)
+					},
+				}
+			}(),
+			queries: []*query{{schema: "public", table: "test", sql: testQuerySQL, args: args1, isInsert: true}},
+			constraintDeferrer: &mockConstraintDeferrer{
+				deferConstraintsFn: func(tables []string) ([]string, []string) {
+					return []string{"SET CONSTRAINTS ALL DEFERRED"}, []string{"SET CONSTRAINTS ALL IMMEDIATE"}
+				},
+			},
+
+			wantExecCalls: 4,
+			wantErr:       nil,
+		},
+		{
+			name: "error - deferring constraints",
+			pgconn: &pgmocks.Querier{
+				ExecInTxFn: func(ctx context.Context, f func(tx pglib.Tx) error) error {
+					mockTx := pgmocks.Tx{
+						ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+							execCalls++
+							switch i {
+							case 1:
+								require.Equal(t, `ALTER TABLE "public"."test" ALTER CONSTRAINT "test_fk" DEFERRABLE`, query)
+								return pglib.CommandTag{}, errPreconditionFailed
+							default:
+								return pglib.CommandTag{}, fmt.Errorf("unexpected call to tx ExecFn: %v", query)
+							}
+						},
+					}
+					return f(&mockTx)
+				},
+			},
+			queries: []*query{{schema: "public", table: "test", sql: testQuerySQL, args: args1, isInsert: true}},
+			constraintDeferrer: &mockConstraintDeferrer{
+				deferConstraintsFn: func(tables []string) ([]string, []string) {
+					return []string{`ALTER TABLE "public"."test" ALTER CONSTRAINT "test_fk" DEFERRABLE`, "SET CONSTRAINTS ALL DEFERRED"}, []string{"SET CONSTRAINTS ALL IMMEDIATE"}
+				},
+			},
+
+			wantExecCalls: 1,
+			wantErr:       errPreconditionFailed,
+		},
+		{
+			name: "error - non internal error outside of the queries",
+			pgconn: &pgmocks.Querier{
+				ExecInTxFn: func(ctx context.Context, f func(tx pglib.Tx) error) error {
+					mockTx := pgmocks.Tx{
+						ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+							execCalls++
+							switch i {
+							case 1:
+								require.Equal(t, "SET session_replication_role = replica", query)
+							case 2:
+								require.Equal(t, testQuerySQL, query)
+							case 3:
+								require.Equal(t, "SET session_replication_role = DEFAULT", query)
+								return pglib.CommandTag{}, errPreconditionFailed
+							default:
+								return pglib.CommandTag{}, fmt.Errorf("unexpected call to tx ExecFn: %v", query)
+							}
+							return pglib.CommandTag{}, nil
+						},
+					}
+					return f(&mockTx)
+				},
+			},
+			queries:         []*query{testQuery(args1)},
+			disableTriggers: true,
+
+			wantExecCalls: 3,
+			wantErr:       errPreconditionFailed,
+		},
 		{
 			name: "error - internal error in tx exec",
 			pgconn: &pgmocks.Querier{
@@ -479,16 +712,25 @@ func TestBatchWriter_flushQueries(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			bw := &BatchWriter{
 				Writer: &Writer{
-					logger:          loglib.NewNoopLogger(),
-					pgConn:          tc.pgconn,
-					disableTriggers: tc.disableTriggers,
+					logger:             loglib.NewNoopLogger(),
+					pgConn:             tc.pgconn,
+					disableTriggers:    tc.disableTriggers,
+					constraintDeferrer: tc.constraintDeferrer,
 				},
 			}
 
-			err := bw.flushQueries(context.Background(), tc.queries)
+			err := bw.flushQueries(context.Background(), tc.queries, nil)
 			require.ErrorIs(t, err, tc.wantErr)
 			require.Equal(t, tc.wantExecCalls, execCalls)
 			execCalls = 0
 		})
 	}
 }
+
+type mockConstraintDeferrer struct {
+	deferConstraintsFn func(tables []string) (before, after []string)
+}
+
+func (m *mockConstraintDeferrer) DeferConstraints(tables []string) (before, after []string) {
+	return m.deferConstraintsFn(tables)
+}
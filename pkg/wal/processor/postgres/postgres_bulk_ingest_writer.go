@@ -49,7 +49,7 @@ func NewBulkIngestWriter(ctx context.Context, config *Config, opts ...WriterOpti
 
 	biw.batchSenderBuilder = func(ctx context.Context, schema, table string) (queryBatchSender, error) {
 		logger := w.logger.WithFields(loglib.Fields{"schema": schema, "table": table})
-		return batch.NewSender(ctx, &config.BatchConfig, biw.sendBatch, logger)
+		return batch.NewSender(ctx, &config.BatchConfig, biw.sendBatch, logger, batch.WithInstrumentation(w.instrumentation, w.writerType))
 	}
 
 	return biw, nil
@@ -81,15 +81,12 @@ func (w *BulkIngestWriter) ProcessWALEvent(ctx context.Context, walEvent *wal.Ev
 	}
 
 	for _, q := range queries {
-		logFields := loglib.Fields{
-			"schema":          q.schema,
-			"table":           q.table,
-			"commit_position": walEvent.CommitPosition,
-			"sql":             q.getSQL(),
-			"args":            q.getArgs(),
-		}
-
-		w.logger.Trace("batching query", logFields)
+		w.logger.Trace("batching query", walEvent.LogFields(), loglib.Fields{
+			loglib.SchemaField: q.schema,
+			loglib.TableField:  q.table,
+			"sql":              q.getSQL(),
+			"args":             q.getArgs(),
+		})
 		msg := batch.NewWALMessage(q, walEvent.CommitPosition)
 
 		batchSender, err := w.getBatchSender(ctx, q.schema, q.table)
@@ -0,0 +1,288 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/xataio/pgstream/internal/json"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// Row represents the values of a table row involved in an insert conflict,
+// indexed by column name. The LSN is the position of the write that produced
+// the values, and it will be empty when it's unknown.
+type Row struct {
+	LSN    string
+	Values map[string]any
+}
+
+// ConflictResolver selects the values to keep when an insert conflicts with an
+// existing row in the target. The returned row can combine source and target
+// values on a per column basis.
+type ConflictResolver interface {
+	Resolve(source, target Row) Row
+}
+
+// ConflictResolverFn is a function adapter for the ConflictResolver
+// interface.
+type ConflictResolverFn func(source, target Row) Row
+
+func (fn ConflictResolverFn) Resolve(source, target Row) Row {
+	return fn(source, target)
+}
+
+type ConflictResolutionConfig struct {
+	// Strategy to apply when an insert conflicts with an existing row. One of
+	// last_write_wins, source_always_wins or target_always_wins.
+	Strategy string
+	// LSNColumn is the target column where the LSN of the last write is
+	// stored. Required by the last_write_wins strategy.
+	LSNColumn string
+}
+
+const (
+	lastWriteWinsStrategy    = "last_write_wins"
+	sourceAlwaysWinsStrategy = "source_always_wins"
+	targetAlwaysWinsStrategy = "target_always_wins"
+)
+
+var (
+	errUnsupportedConflictResolutionStrategy = errors.New("unsupported conflict resolution strategy, must be one of 'last_write_wins', 'source_always_wins' or 'target_always_wins'")
+	errLSNColumnRequired                     = errors.New("lsn column must be provided for the last_write_wins conflict resolution strategy")
+	errConflictResolverWithOnConflictAction  = errors.New("on conflict action can't be set when a conflict resolver is configured")
+
+	// timeLayouts are the formats of the postgres timestamps and dates in the
+	// WAL events, with and without time zone
+	timeLayouts = []string{
+		"2006-01-02 15:04:05.999999999Z07:00",
+		"2006-01-02 15:04:05.999999999Z07",
+		"2006-01-02 15:04:05.999999999",
+		time.RFC3339Nano,
+		time.DateOnly,
+	}
+)
+
+// SourceAlwaysWins returns a conflict resolver that keeps the incoming source
+// values.
+func SourceAlwaysWins() ConflictResolver {
+	return ConflictResolverFn(func(source, _ Row) Row {
+		return source
+	})
+}
+
+// TargetAlwaysWins returns a conflict resolver that keeps the existing target
+// values.
+func TargetAlwaysWins() ConflictResolver {
+	return ConflictResolverFn(func(_, target Row) Row {
+		return target
+	})
+}
+
+// LastWriteWins returns a conflict resolver that keeps the row values written
+// at the highest LSN. If the target LSN is unknown, the source values win.
+func LastWriteWins() ConflictResolver {
+	parser := pgreplication.NewLSNParser()
+	return ConflictResolverFn(func(source, target Row) Row {
+		if target.LSN == "" {
+			return source
+		}
+		targetLSN, err := parser.FromString(target.LSN)
+		if err != nil {
+			return source
+		}
+		sourceLSN, err := parser.FromString(source.LSN)
+		if err != nil {
+			return source
+		}
+		if targetLSN > sourceLSN {
+			return target
+		}
+		return source
+	})
+}
+
+// Custom returns a conflict resolver that uses the function on input to
+// resolve conflicts.
+func Custom(fn func(source, target Row) Row) ConflictResolver {
+	return ConflictResolverFn(fn)
+}
+
+func (c *ConflictResolutionConfig) isSet() bool {
+	return c.Strategy != ""
+}
+
+func (c *ConflictResolutionConfig) resolver() (ConflictResolver, error) {
+	switch c.Strategy {
+	case lastWriteWinsStrategy:
+		if c.LSNColumn == "" {
+			return nil, errLSNColumnRequired
+		}
+		return LastWriteWins(), nil
+	case sourceAlwaysWinsStrategy:
+		return SourceAlwaysWins(), nil
+	case targetAlwaysWinsStrategy:
+		return TargetAlwaysWins(), nil
+	default:
+		return nil, errUnsupportedConflictResolutionStrategy
+	}
+}
+
+// conflict contains the information required to resolve an insert conflict
+// for a row identified by its primary keys.
+type conflict struct {
+	schema           string
+	table            string
+	columnNames      []string
+	primaryKeyNames  []string
+	primaryKeyValues []any
+	source           Row
+	lsnColumn        string
+}
+
+func (w *Writer) resolveConflict(ctx context.Context, tx pglib.Tx, c *conflict) error {
+	target, err := c.fetchTargetRow(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("fetching conflicting target row: %w", err)
+	}
+
+	resolved := w.conflictResolver.Resolve(c.source, target)
+
+	q := c.buildUpdateQuery(resolved, target)
+	if q.IsEmpty() {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, q.sql, q.args...); err != nil {
+		return fmt.Errorf("applying resolved conflict: %w", err)
+	}
+	return nil
+}
+
+func (c *conflict) fetchTargetRow(ctx context.Context, tx pglib.Tx) (Row, error) {
+	quotedCols := make([]string, 0, len(c.columnNames))
+	for _, col := range c.columnNames {
+		quotedCols = append(quotedCols, pglib.QuoteIdentifier(col))
+	}
+	whereQuery := c.buildWhereQuery(0)
+
+	values := make([]any, len(c.columnNames))
+	dest := make([]any, len(c.columnNames))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	sql := fmt.Sprintf("SELECT %s FROM %s %s FOR UPDATE", strings.Join(quotedCols, ", "), quotedTableName(c.schema, c.table), whereQuery)
+	if err := tx.QueryRow(ctx, dest, sql, c.primaryKeyValues...); err != nil {
+		return Row{}, err
+	}
+
+	target := Row{Values: make(map[string]any, len(c.columnNames))}
+	for i, col := range c.columnNames {
+		target.Values[col] = values[i]
+		if col == c.lsnColumn && values[i] != nil {
+			target.LSN = fmt.Sprintf("%v", values[i])
+		}
+	}
+	return target, nil
+}
+
+// buildUpdateQuery returns the query that updates the target row columns that
+// differ from the resolved values. It returns an empty query if the target row
+// already contains the resolved values. When the resolved row has a LSN, it's
+// stamped in the LSN column along with the resolved values.
+func (c *conflict) buildUpdateQuery(resolved, target Row) *query {
+	setCols := []string{}
+	setValues := []any{}
+	for _, col := range c.columnNames {
+		val, found := resolved.Values[col]
+		if col == c.lsnColumn && resolved.LSN != "" {
+			val, found = resolved.LSN, true
+		}
+		if !found || valuesEqual(val, target.Values[col]) {
+			continue
+		}
+		setValues = append(setValues, val)
+		setCols = append(setCols, fmt.Sprintf("%s = $%d", pglib.QuoteIdentifier(col), len(setValues)))
+	}
+
+	if len(setCols) == 0 {
+		return &query{}
+	}
+
+	return &query{
+		schema: c.schema,
+		table:  c.table,
+		sql:    fmt.Sprintf("UPDATE %s SET %s %s", quotedTableName(c.schema, c.table), strings.Join(setCols, ", "), c.buildWhereQuery(len(setValues))),
+		args:   append(setValues, c.primaryKeyValues...),
+	}
+}
+
+func (c *conflict) buildWhereQuery(placeholderOffset int) string {
+	conditions := make([]string, 0, len(c.primaryKeyNames))
+	for i, pk := range c.primaryKeyNames {
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", pglib.QuoteIdentifier(pk), i+placeholderOffset+1))
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// valuesEqual returns true if the resolved value matches the target value. The
+// resolved values come from the WAL event json, while the target values are
+// scanned by pgx, so they are normalised before being compared.
+func valuesEqual(resolved, target any) bool {
+	if reflect.DeepEqual(resolved, target) {
+		return true
+	}
+	if resolved == nil || target == nil {
+		return false
+	}
+
+	switch t := target.(type) {
+	case time.Time:
+		s, ok := resolved.(string)
+		if !ok {
+			return false
+		}
+		for _, layout := range timeLayouts {
+			if parsed, err := time.Parse(layout, s); err == nil {
+				return parsed.Equal(t)
+			}
+		}
+		return false
+	case [16]byte:
+		s, ok := resolved.(string)
+		if !ok {
+			return false
+		}
+		return strings.EqualFold(s, fmt.Sprintf("%x-%x-%x-%x-%x", t[0:4], t[4:6], t[6:8], t[8:10], t[10:16]))
+	}
+
+	return reflect.DeepEqual(normaliseValue(resolved), normaliseValue(target))
+}
+
+// normaliseValue returns the json representation of the value on input, so
+// that integers and floats, or serialised and decoded json values, can be
+// compared.
+func normaliseValue(v any) any {
+	raw, ok := v.([]byte)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(v); err != nil {
+			return v
+		}
+	}
+	var normalised any
+	if err := json.Unmarshal(raw, &normalised); err != nil {
+		if ok {
+			return string(raw)
+		}
+		return v
+	}
+	return normalised
+}
@@ -0,0 +1,512 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestConflictResolvers(t *testing.T) {
+	t.Parallel()
+
+	source := Row{LSN: "1/CF54A048", Values: map[string]any{"id": 1, "name": "alice"}}
+	olderTarget := Row{LSN: "1/CF54A000", Values: map[string]any{"id": 1, "name": "bob"}}
+	newerTarget := Row{LSN: "2/00000000", Values: map[string]any{"id": 1, "name": "bob"}}
+	unknownTarget := Row{Values: map[string]any{"id": 1, "name": "bob"}}
+
+	tests := []struct {
+		name     string
+		resolver ConflictResolver
+		target   Row
+
+		wantRow Row
+	}{
+		{
+			name:     "source always wins",
+			resolver: SourceAlwaysWins(),
+			target:   newerTarget,
+			wantRow:  source,
+		},
+		{
+			name:     "target always wins",
+			resolver: TargetAlwaysWins(),
+			target:   olderTarget,
+			wantRow:  olderTarget,
+		},
+		{
+			name:     "last write wins - source is newer",
+			resolver: LastWriteWins(),
+			target:   olderTarget,
+			wantRow:  source,
+		},
+		{
+			name:     "last write wins - target is newer",
+			resolver: LastWriteWins(),
+			target:   newerTarget,
+			wantRow:  newerTarget,
+		},
+		{
+			name:     "last write wins - unknown target lsn",
+			resolver: LastWriteWins(),
+			target:   unknownTarget,
+			wantRow:  source,
+		},
+		{
+			name: "custom - per column resolution",
+			resolver: Custom(func(source, target Row) Row {
+				return Row{Values: map[string]any{"id": source.Values["id"], "name": target.Values["name"]}}
+			}),
+			target:  olderTarget,
+			wantRow: Row{Values: map[string]any{"id": 1, "name": "bob"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantRow, tc.resolver.Resolve(source, tc.target))
+		})
+	}
+}
+
+func TestConflictResolutionConfig_resolver(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config ConflictResolutionConfig
+
+		wantErr error
+	}{
+		{
+			name:   "last write wins",
+			config: ConflictResolutionConfig{Strategy: lastWriteWinsStrategy, LSNColumn: "_lsn"},
+		},
+		{
+			name:   "source always wins",
+			config: ConflictResolutionConfig{Strategy: sourceAlwaysWinsStrategy},
+		},
+		{
+			name:   "target always wins",
+			config: ConflictResolutionConfig{Strategy: targetAlwaysWinsStrategy},
+		},
+		{
+			name:    "error - last write wins without lsn column",
+			config:  ConflictResolutionConfig{Strategy: lastWriteWinsStrategy},
+			wantErr: errLSNColumnRequired,
+		},
+		{
+			name:    "error - unsupported strategy",
+			config:  ConflictResolutionConfig{Strategy: "invalid"},
+			wantErr: errUnsupportedConflictResolutionStrategy,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := tc.config.resolver()
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestDMLAdapter_buildInsertQueries_withConflictResolution(t *testing.T) {
+	t.Parallel()
+
+	testData := &wal.Data{
+		Action: "I",
+		LSN:    "1/CF54A048",
+		Schema: "test",
+		Table:  "table",
+		Columns: []wal.Column{
+			{ID: "1", Name: "id", Value: 1},
+			{ID: "2", Name: "name", Value: "alice"},
+			{ID: "3", Name: "age", Value: 30},
+		},
+		Metadata: wal.Metadata{
+			InternalColIDs: []string{"1"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		lsnColumn string
+		data      *wal.Data
+
+		wantQueries []*query
+	}{
+		{
+			name: "ok",
+			data: testData,
+
+			wantQueries: []*query{
+				{
+					schema:      "test",
+					table:       "table",
+					columnNames: []string{`"id"`, `"name"`},
+					sql:         `INSERT INTO "test"."table"("id", "name") OVERRIDING SYSTEM VALUE VALUES($1, $2) ON CONFLICT ("id") DO NOTHING`,
+					args:        []any{1, "alice"},
+					isInsert:    true,
+					conflict: &conflict{
+						schema:           "test",
+						table:            "table",
+						columnNames:      []string{"id", "name"},
+						primaryKeyNames:  []string{"id"},
+						primaryKeyValues: []any{1},
+						source: Row{
+							LSN:    "1/CF54A048",
+							Values: map[string]any{"id": 1, "name": "alice"},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:      "ok - with lsn column",
+			lsnColumn: "_lsn",
+			data:      testData,
+
+			wantQueries: []*query{
+				{
+					schema:      "test",
+					table:       "table",
+					columnNames: []string{`"id"`, `"name"`, `"_lsn"`},
+					sql:         `INSERT INTO "test"."table"("id", "name", "_lsn") OVERRIDING SYSTEM VALUE VALUES($1, $2, $3) ON CONFLICT ("id") DO NOTHING`,
+					args:        []any{1, "alice", "1/CF54A048"},
+					isInsert:    true,
+					conflict: &conflict{
+						schema:           "test",
+						table:            "table",
+						columnNames:      []string{"id", "name", "_lsn"},
+						primaryKeyNames:  []string{"id"},
+						primaryKeyValues: []any{1},
+						source: Row{
+							LSN:    "1/CF54A048",
+							Values: map[string]any{"id": 1, "name": "alice", "_lsn": "1/CF54A048"},
+						},
+						lsnColumn: "_lsn",
+					},
+				},
+			},
+		},
+		{
+			name: "no primary keys",
+			data: &wal.Data{
+				Action:  "I",
+				Schema:  "test",
+				Table:   "table",
+				Columns: testData.Columns[:2],
+			},
+
+			wantQueries: []*query{
+				{
+					schema:      "test",
+					table:       "table",
+					columnNames: []string{`"id"`, `"name"`},
+					sql:         `INSERT INTO "test"."table"("id", "name") OVERRIDING SYSTEM VALUE VALUES($1, $2)`,
+					args:        []any{1, "alice"},
+					isInsert:    true,
+				},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := newDMLAdapter("", false, &ConflictResolutionConfig{LSNColumn: tc.lsnColumn}, latestServerVersion, loglib.NewNoopLogger())
+			require.NoError(t, err)
+
+			queries := a.buildInsertQueries(tc.data, schemaInfo{
+				generatedColumns: map[string]struct{}{`"age"`: {}},
+			})
+			require.Equal(t, tc.wantQueries, queries)
+		})
+	}
+}
+
+func TestDMLAdapter_buildUpdateQuery_withConflictResolution(t *testing.T) {
+	t.Parallel()
+
+	testData := &wal.Data{
+		Action: "U",
+		LSN:    "1/CF54A048",
+		Schema: "test",
+		Table:  "table",
+		Columns: []wal.Column{
+			{ID: "1", Name: "id", Value: 1},
+			{ID: "2", Name: "name", Value: "alice"},
+		},
+		Metadata: wal.Metadata{
+			InternalColIDs: []string{"1"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		lsnColumn string
+
+		wantQuery *query
+	}{
+		{
+			name: "ok",
+
+			wantQuery: &query{
+				schema: "test",
+				table:  "table",
+				sql:    `UPDATE "test"."table" SET "id" = $1, "name" = $2 WHERE "id" = $3`,
+				args:   []any{1, "alice", 1},
+			},
+		},
+		{
+			name:      "ok - with lsn column",
+			lsnColumn: "_lsn",
+
+			wantQuery: &query{
+				schema: "test",
+				table:  "table",
+				sql:    `UPDATE "test"."table" SET "id" = $1, "name" = $2, "_lsn" = $3 WHERE "id" = $4`,
+				args:   []any{1, "alice", "1/CF54A048", 1},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			a, err := newDMLAdapter("", false, &ConflictResolutionConfig{LSNColumn: tc.lsnColumn}, latestServerVersion, loglib.NewNoopLogger())
+			require.NoError(t, err)
+
+			query, err := a.buildUpdateQuery(testData, schemaInfo{})
+			require.NoError(t, err)
+			require.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestConflict_buildUpdateQuery(t *testing.T) {
+	t.Parallel()
+
+	testTime := time.Date(2024, 1, 2, 10, 30, 0, 0, time.UTC)
+	testUUID := [16]byte{0x6e, 0xcd, 0x8c, 0x99, 0x4f, 0x3b, 0x4e, 0x9c, 0x8a, 0x2d, 0x2c, 0x1e, 0x0f, 0x4b, 0x5a, 0x7c}
+
+	testConflict := &conflict{
+		schema:           "test",
+		table:            "table",
+		columnNames:      []string{"id", "name", "data", "created_at", "uuid", "_lsn"},
+		primaryKeyNames:  []string{"id"},
+		primaryKeyValues: []any{1},
+		lsnColumn:        "_lsn",
+	}
+	target := Row{
+		LSN: "1/CF54A000",
+		Values: map[string]any{
+			"id":         int32(1),
+			"name":       "bob",
+			"data":       map[string]any{"a": float64(1)},
+			"created_at": testTime,
+			"uuid":       testUUID,
+			"_lsn":       "1/CF54A000",
+		},
+	}
+
+	tests := []struct {
+		name     string
+		resolved Row
+
+		wantQuery *query
+	}{
+		{
+			name:     "target row already contains the resolved values",
+			resolved: target,
+
+			wantQuery: &query{},
+		},
+		{
+			name: "resolved values equal after normalisation",
+			resolved: Row{
+				LSN: "1/CF54A000",
+				Values: map[string]any{
+					"id":         float64(1),
+					"name":       "bob",
+					"data":       []byte(`{"a":1}`),
+					"created_at": "2024-01-02 10:30:00+00",
+					"uuid":       "6ecd8c99-4f3b-4e9c-8a2d-2c1e0f4b5a7c",
+					"_lsn":       "1/CF54A000",
+				},
+			},
+
+			wantQuery: &query{},
+		},
+		{
+			name: "resolved values differ",
+			resolved: Row{
+				LSN: "1/CF54A048",
+				Values: map[string]any{
+					"id":         float64(1),
+					"name":       "alice",
+					"data":       []byte(`{"a":2}`),
+					"created_at": "2024-01-02 11:30:00+00",
+				},
+			},
+
+			wantQuery: &query{
+				schema: "test",
+				table:  "table",
+				sql:    `UPDATE "test"."table" SET "name" = $1, "data" = $2, "created_at" = $3, "_lsn" = $4 WHERE "id" = $5`,
+				args:   []any{"alice", []byte(`{"a":2}`), "2024-01-02 11:30:00+00", "1/CF54A048", 1},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantQuery, testConflict.buildUpdateQuery(tc.resolved, target))
+		})
+	}
+}
+
+func TestBatchWriter_execQuery_withConflict(t *testing.T) {
+	t.Parallel()
+
+	testConflict := &conflict{
+		schema:           "test",
+		table:            "table",
+		columnNames:      []string{"id", "name", "_lsn"},
+		primaryKeyNames:  []string{"id"},
+		primaryKeyValues: []any{1},
+		source: Row{
+			LSN:    "1/CF54A048",
+			Values: map[string]any{"id": 1, "name": "alice", "_lsn": "1/CF54A048"},
+		},
+		lsnColumn: "_lsn",
+	}
+	testQuery := &query{
+		sql:      "INSERT",
+		args:     []any{1, "alice", "1/CF54A048"},
+		conflict: testConflict,
+	}
+	wantSelectSQL := `SELECT "id", "name", "_lsn" FROM "test"."table" WHERE "id" = $1 FOR UPDATE`
+
+	targetRowFn := func(lsn string) func(ctx context.Context, dest []any, query string, args ...any) error {
+		return func(ctx context.Context, dest []any, query string, args ...any) error {
+			require.Equal(t, wantSelectSQL, query)
+			require.Equal(t, []any{1}, args)
+			*(dest[0].(*any)) = 1
+			*(dest[1].(*any)) = "bob"
+			*(dest[2].(*any)) = lsn
+			return nil
+		}
+	}
+
+	tests := []struct {
+		name       string
+		resolver   ConflictResolver
+		queryRowFn func(ctx context.Context, dest []any, query string, args ...any) error
+		execFn     func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error)
+
+		wantErr error
+	}{
+		{
+			name:     "no conflict",
+			resolver: LastWriteWins(),
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				if i != 1 {
+					return pglib.CommandTag{}, fmt.Errorf("unexpected call to ExecFn: %d", i)
+				}
+				return pglib.CommandTag{CommandTag: pgconn.NewCommandTag("INSERT 0 1")}, nil
+			},
+		},
+		{
+			name:       "conflict - source wins",
+			resolver:   LastWriteWins(),
+			queryRowFn: targetRowFn("1/CF54A000"),
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				switch i {
+				case 1:
+					return pglib.CommandTag{CommandTag: pgconn.NewCommandTag("INSERT 0 0")}, nil
+				case 2:
+					require.Equal(t, `UPDATE "test"."table" SET "name" = $1, "_lsn" = $2 WHERE "id" = $3`, query)
+					require.Equal(t, []any{"alice", "1/CF54A048", 1}, args)
+					return pglib.CommandTag{CommandTag: pgconn.NewCommandTag("UPDATE 1")}, nil
+				default:
+					return pglib.CommandTag{}, fmt.Errorf("unexpected call to ExecFn: %d", i)
+				}
+			},
+		},
+		{
+			name:       "conflict - target wins",
+			resolver:   LastWriteWins(),
+			queryRowFn: targetRowFn("2/00000000"),
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				if i != 1 {
+					return pglib.CommandTag{}, fmt.Errorf("unexpected call to ExecFn: %d", i)
+				}
+				return pglib.CommandTag{CommandTag: pgconn.NewCommandTag("INSERT 0 0")}, nil
+			},
+		},
+		{
+			name:     "error - fetching target row",
+			resolver: SourceAlwaysWins(),
+			queryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+				return errTest
+			},
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				return pglib.CommandTag{CommandTag: pgconn.NewCommandTag("INSERT 0 0")}, nil
+			},
+
+			wantErr: errTest,
+		},
+		{
+			name:       "error - applying resolved row",
+			resolver:   SourceAlwaysWins(),
+			queryRowFn: targetRowFn("1/CF54A000"),
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				if i == 1 {
+					return pglib.CommandTag{CommandTag: pgconn.NewCommandTag("INSERT 0 0")}, nil
+				}
+				return pglib.CommandTag{}, errTest
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bw := &BatchWriter{
+				Writer: &Writer{
+					logger:           loglib.NewNoopLogger(),
+					conflictResolver: tc.resolver,
+				},
+			}
+
+			tx := &pgmocks.Tx{
+				QueryRowFn: tc.queryRowFn,
+				ExecFn:     tc.execFn,
+			}
+
+			err := bw.execQuery(context.Background(), tx, testQuery)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
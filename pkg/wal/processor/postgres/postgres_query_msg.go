@@ -9,6 +9,10 @@ type query struct {
 	columnNames []string
 	args        []any
 	isDDL       bool
+	isInsert    bool
+	// conflict is set when insert conflicts need to be resolved by the
+	// conflict resolver after the query is executed
+	conflict *conflict
 }
 
 const (
@@ -16,8 +20,8 @@ const (
 	stringOverhead    = 16 // string = pointer + length
 	sliceOverhead     = 24 // slice = pointer + length + capacity
 	// queryStructOverhead is the approximate size of the query struct itself
-	// (3 strings + 2 slices + 1 bool + padding)
-	queryStructOverhead = 104
+	// (3 strings + 2 slices + 2 bools + 1 pointer + padding)
+	queryStructOverhead = 112
 )
 
 // size returns the approximate size of the message including the SQL query and
@@ -25,6 +25,8 @@ type pgSchemaObserver struct {
 	materializedViews *synclib.Map[string, map[string]struct{}]
 	// columnTableSequences is a map of schema.table to a map of sequence column names.
 	columnTableSequences *synclib.Map[string, map[string]string]
+	// targetVersion is the postgres server version of the observed database.
+	targetVersion serverVersion
 }
 
 // newPGSchemaObserver returns a postgres observer that tracks schemas,
@@ -36,8 +38,17 @@ func newPGSchemaObserver(ctx context.Context, pgURL string, logger loglib.Logger
 	if err != nil {
 		return nil, err
 	}
+
+	targetVersion, err := queryServerVersion(ctx, pgConn)
+	if err != nil {
+		pgConn.Close(ctx)
+		return nil, err
+	}
+	logger.Info("target postgres server version detected", loglib.Fields{"version": targetVersion.major()})
+
 	return &pgSchemaObserver{
 		pgConn:                pgConn,
+		targetVersion:         targetVersion,
 		generatedTableColumns: synclib.NewMap[string, map[string]struct{}](),
 		materializedViews:     synclib.NewMap[string, map[string]struct{}](),
 		columnTableSequences:  synclib.NewMap[string, map[string]string](),
@@ -158,11 +169,29 @@ const generatedTableColumnsQuery = `SELECT attname FROM pg_attribute
 		AND attrelid = (SELECT c.oid FROM pg_class c JOIN pg_namespace n ON c.relnamespace=n.oid WHERE c.relname=$1 and n.nspname=$2)
 		AND (attgenerated != '' OR attidentity != '')`
 
+// generatedTableColumnsQueryPG10 is used for targets older than postgres 12,
+// which don't have the pg_attribute.attgenerated column.
+const generatedTableColumnsQueryPG10 = `SELECT attname FROM pg_attribute
+		WHERE attnum > 0
+		AND attrelid = (SELECT c.oid FROM pg_class c JOIN pg_namespace n ON c.relnamespace=n.oid WHERE c.relname=$1 and n.nspname=$2)
+		AND attidentity != ''`
+
 func (o *pgSchemaObserver) queryGeneratedColumnNames(ctx context.Context, schemaName, tableName string) (map[string]struct{}, error) {
 	columnNames := map[string]struct{}{}
+	// targets older than postgres 10 have neither generated nor identity
+	// columns
+	if !o.targetVersion.supportsIdentityColumns() {
+		return columnNames, nil
+	}
+
+	query := generatedTableColumnsQuery
+	if !o.targetVersion.supportsGeneratedColumns() {
+		query = generatedTableColumnsQueryPG10
+	}
+
 	// filter out generated columns (excluding identities) since they will
 	// be generated automatically, and they can't be overwriten.
-	rows, err := o.pgConn.Query(ctx, generatedTableColumnsQuery, tableName, schemaName)
+	rows, err := o.pgConn.Query(ctx, query, tableName, schemaName)
 	if err != nil {
 		return nil, fmt.Errorf("getting table generated column names for table %s.%s: %w", schemaName, tableName, err)
 	}
@@ -23,9 +23,10 @@ func TestPGSchemaObserver_getGeneratedColumnNames(t *testing.T) {
 	idColumn := `"id"`
 
 	tests := []struct {
-		name         string
-		tableColumns map[string]map[string]struct{}
-		pgConn       pglib.Querier
+		name          string
+		tableColumns  map[string]map[string]struct{}
+		pgConn        pglib.Querier
+		targetVersion serverVersion
 
 		wantColumns      map[string]struct{}
 		wantTableColumns map[string]map[string]struct{}
@@ -59,6 +60,47 @@ func TestPGSchemaObserver_getGeneratedColumnNames(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name:         "ok - target without generated columns",
+			tableColumns: map[string]map[string]struct{}{},
+			pgConn: &pgmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					require.Equal(t, generatedTableColumnsQueryPG10, query)
+					return &pgmocks.Rows{
+						CloseFn: func() {},
+						NextFn:  func(i uint) bool { return i == 1 },
+						ScanFn: func(_ uint, dest ...any) error {
+							*(dest[0].(*string)) = "id"
+							return nil
+						},
+						ErrFn: func() error { return nil },
+					}, nil
+				},
+			},
+			targetVersion: 110022,
+
+			wantColumns: map[string]struct{}{idColumn: {}},
+			wantTableColumns: map[string]map[string]struct{}{
+				quotedQualifiedTableName: {idColumn: {}},
+			},
+			wantErr: nil,
+		},
+		{
+			name:         "ok - target without identity columns",
+			tableColumns: map[string]map[string]struct{}{},
+			pgConn: &pgmocks.Querier{
+				QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+					return nil, errors.New("unexpected call to QueryFn")
+				},
+			},
+			targetVersion: 90600,
+
+			wantColumns: map[string]struct{}{},
+			wantTableColumns: map[string]map[string]struct{}{
+				quotedQualifiedTableName: {},
+			},
+			wantErr: nil,
+		},
 		{
 			name: "ok - existing table",
 			tableColumns: map[string]map[string]struct{}{
@@ -143,6 +185,7 @@ func TestPGSchemaObserver_getGeneratedColumnNames(t *testing.T) {
 				pgConn:                tc.pgConn,
 				generatedTableColumns: synclib.NewMapFromMap(tc.tableColumns),
 				logger:                loglib.NewNoopLogger(),
+				targetVersion:         tc.targetVersion,
 			}
 
 			colNames, err := o.getGeneratedColumnNames(context.TODO(), "test_schema", "test_table")
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+)
+
+// serverVersion is the postgres server version number, in the
+// server_version_num format (i.e. 160002 for 16.2). It's used to adapt the
+// queries sent to the target when it runs a different major version than the
+// source.
+type serverVersion int
+
+const (
+	pg10 serverVersion = 100000
+	pg12 serverVersion = 120000
+	pg18 serverVersion = 180000
+)
+
+// latestServerVersion is used when the target version is unknown, assuming
+// all the features are supported.
+const latestServerVersion serverVersion = 0
+
+// queryServerVersion returns the server version number of the postgres
+// database the connection on input is connected to.
+func queryServerVersion(ctx context.Context, conn pglib.Querier) (serverVersion, error) {
+	var versionNum string
+	if err := conn.QueryRow(ctx, []any{&versionNum}, "SHOW server_version_num"); err != nil {
+		return latestServerVersion, fmt.Errorf("retrieving server version: %w", err)
+	}
+	version, err := strconv.Atoi(versionNum)
+	if err != nil {
+		return latestServerVersion, fmt.Errorf("parsing server version %q: %w", versionNum, err)
+	}
+	return serverVersion(version), nil
+}
+
+func (v serverVersion) atLeast(version serverVersion) bool {
+	return v == latestServerVersion || v >= version
+}
+
+// major returns the major version number (i.e. 16 for 160002).
+func (v serverVersion) major() int {
+	return int(v) / 10000
+}
+
+// supportsIdentityColumns returns true if the identity columns (and the
+// OVERRIDING SYSTEM VALUE insert clause) are supported. Added in postgres 10.
+func (v serverVersion) supportsIdentityColumns() bool {
+	return v.atLeast(pg10)
+}
+
+// supportsGeneratedColumns returns true if the stored generated columns (and
+// the pg_attribute.attgenerated column) are supported. Added in postgres 12.
+func (v serverVersion) supportsGeneratedColumns() bool {
+	return v.atLeast(pg12)
+}
+
+// supportsVirtualGeneratedColumns returns true if the virtual generated
+// columns are supported. Added in postgres 18.
+func (v serverVersion) supportsVirtualGeneratedColumns() bool {
+	return v.atLeast(pg18)
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+)
+
+func TestQueryServerVersion(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name       string
+		versionNum string
+		queryErr   error
+
+		wantVersion serverVersion
+		wantErr     error
+	}{
+		{
+			name:       "ok",
+			versionNum: "160002",
+
+			wantVersion: 160002,
+		},
+		{
+			name:     "error - querying version",
+			queryErr: errTest,
+
+			wantVersion: latestServerVersion,
+			wantErr:     errTest,
+		},
+		{
+			name:       "error - invalid version",
+			versionNum: "sixteen",
+
+			wantVersion: latestServerVersion,
+			wantErr:     strconv.ErrSyntax,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			conn := &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					require.Equal(t, "SHOW server_version_num", query)
+					require.Len(t, dest, 1)
+					*(dest[0].(*string)) = tc.versionNum
+					return tc.queryErr
+				},
+			}
+
+			version, err := queryServerVersion(context.Background(), conn)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantVersion, version)
+		})
+	}
+}
+
+func TestServerVersion_supports(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		version serverVersion
+
+		wantMajor                  int
+		wantIdentityColumns        bool
+		wantGeneratedColumns       bool
+		wantVirtualGeneratedColumn bool
+	}{
+		{
+			name:    "postgres 9.6",
+			version: 90624,
+
+			wantMajor: 9,
+		},
+		{
+			name:    "postgres 11",
+			version: 110022,
+
+			wantMajor:           11,
+			wantIdentityColumns: true,
+		},
+		{
+			name:    "postgres 16",
+			version: 160002,
+
+			wantMajor:            16,
+			wantIdentityColumns:  true,
+			wantGeneratedColumns: true,
+		},
+		{
+			name:    "postgres 18",
+			version: 180000,
+
+			wantMajor:                  18,
+			wantIdentityColumns:        true,
+			wantGeneratedColumns:       true,
+			wantVirtualGeneratedColumn: true,
+		},
+		{
+			name:    "unknown version",
+			version: latestServerVersion,
+
+			wantMajor:                  0,
+			wantIdentityColumns:        true,
+			wantGeneratedColumns:       true,
+			wantVirtualGeneratedColumn: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantMajor, tc.version.major())
+			require.Equal(t, tc.wantIdentityColumns, tc.version.supportsIdentityColumns())
+			require.Equal(t, tc.wantGeneratedColumns, tc.version.supportsGeneratedColumns())
+			require.Equal(t, tc.wantVirtualGeneratedColumn, tc.version.supportsVirtualGeneratedColumns())
+		})
+	}
+}
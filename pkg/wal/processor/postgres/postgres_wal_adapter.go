@@ -45,20 +45,20 @@ type adapter struct {
 	schemaObserver schemaObserver
 }
 
-func newAdapter(ctx context.Context, schemaQuerier schemalogQuerier, logger loglib.Logger, pgURL string, onConflictAction string, forCopy bool) (*adapter, error) {
+func newAdapter(ctx context.Context, schemaQuerier schemalogQuerier, logger loglib.Logger, pgURL string, onConflictAction string, forCopy bool, conflictResolution *ConflictResolutionConfig) (*adapter, error) {
 	schemaObserver, err := newPGSchemaObserver(ctx, pgURL, logger)
 	if err != nil {
 		return nil, err
 	}
 
-	dmlAdapter, err := newDMLAdapter(onConflictAction, forCopy, logger)
+	dmlAdapter, err := newDMLAdapter(onConflictAction, forCopy, conflictResolution, schemaObserver.targetVersion, logger)
 	if err != nil {
 		return nil, err
 	}
 
 	var ddl ddlQueryAdapter
 	if schemaQuerier != nil {
-		ddl = newDDLAdapter(schemaQuerier)
+		ddl = newDDLAdapter(schemaQuerier, schemaObserver.targetVersion)
 	}
 	return &adapter{
 		dmlAdapter:      dmlAdapter,
@@ -6,7 +6,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"slices"
 	"strings"
 
 	pglib "github.com/xataio/pgstream/internal/postgres"
@@ -17,6 +16,9 @@ import (
 type ddlAdapter struct {
 	schemalogQuerier schemalogQuerier
 	schemaDiffer     schemaDiffer
+	// targetVersion is the target postgres server version, used to adapt
+	// the DDL to the features it supports.
+	targetVersion serverVersion
 }
 
 type schemalogQuerier interface {
@@ -29,10 +31,11 @@ type logEntryAdapter func(*wal.Data) (*schemalog.LogEntry, error)
 
 const cycleOptionYes = "YES"
 
-func newDDLAdapter(querier schemalogQuerier) *ddlAdapter {
+func newDDLAdapter(querier schemalogQuerier, targetVersion serverVersion) *ddlAdapter {
 	return &ddlAdapter{
 		schemalogQuerier: querier,
 		schemaDiffer:     schemalog.ComputeSchemaDiff,
+		targetVersion:    targetVersion,
 	}
 }
 
@@ -282,7 +285,7 @@ func (a *ddlAdapter) buildCreateTableQuery(schemaName string, table schemalog.Ta
 		columnDefinitions = append(columnDefinitions, a.buildColumnDefinition(&col))
 		// if there's a unique constraint associated to the column, and it's not
 		// the primary key, explicitly add it
-		if uniqueConstraint := a.buildUniqueConstraint(col); uniqueConstraint != "" && !slices.Contains(table.PrimaryKeyColumns, col.Name) {
+		if uniqueConstraint := a.buildUniqueConstraint(col); uniqueConstraint != "" && !table.IsPrimaryKey(col.Name) {
 			uniqueConstraints = append(uniqueConstraints, uniqueConstraint)
 		}
 	}
@@ -321,7 +324,13 @@ func (a *ddlAdapter) buildColumnDefinition(column *schemalog.Column) string {
 			colDefinition = fmt.Sprintf("%s GENERATED BY DEFAULT AS IDENTITY", colDefinition)
 		}
 	case column.GeneratedKind != "" && column.DefaultValue != nil:
-		switch column.GeneratedKind {
+		generatedKind := column.GeneratedKind
+		// virtual generated columns are stored on targets that don't support
+		// them, since the values are computed in the same way
+		if generatedKind == "v" && !a.targetVersion.supportsVirtualGeneratedColumns() {
+			generatedKind = "s"
+		}
+		switch generatedKind {
 		case "v":
 			colDefinition = fmt.Sprintf("%s GENERATED ALWAYS AS (%s) VIRTUAL", colDefinition, *column.DefaultValue)
 		case "s":
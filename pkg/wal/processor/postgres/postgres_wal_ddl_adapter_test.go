@@ -1119,7 +1119,7 @@ func TestDDLAdapter_schemaDiffToQueries(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			ddlAdapter := newDDLAdapter(nil)
+			ddlAdapter := newDDLAdapter(nil, latestServerVersion)
 
 			queries, err := ddlAdapter.schemaDiffToQueries(testSchema, tc.diff)
 			require.ErrorIs(t, err, tc.wantErr)
@@ -1140,9 +1140,10 @@ func TestDDLAdapter_buildColumnDefinition(t *testing.T) {
 	generatedStored := "s"
 
 	tests := []struct {
-		name   string
-		column schemalog.Column
-		want   string
+		name          string
+		column        schemalog.Column
+		targetVersion serverVersion
+		want          string
 	}{
 		{
 			name: "nullable column with no default",
@@ -1213,6 +1214,18 @@ func TestDDLAdapter_buildColumnDefinition(t *testing.T) {
 			},
 			want: "\"full_name\" text GENERATED ALWAYS AS (upper(name)) VIRTUAL",
 		},
+		{
+			name: "column with virtual generated column - target without virtual columns",
+			column: schemalog.Column{
+				Name:          "full_name",
+				DataType:      "text",
+				Nullable:      true,
+				GeneratedKind: generatedVirtual,
+				DefaultValue:  &generatedExpression,
+			},
+			targetVersion: 170002,
+			want:          "\"full_name\" text GENERATED ALWAYS AS (upper(name)) STORED",
+		},
 		{
 			name: "column with stored generated column",
 			column: schemalog.Column{
@@ -1240,7 +1253,7 @@ func TestDDLAdapter_buildColumnDefinition(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			ddlAdapter := newDDLAdapter(nil)
+			ddlAdapter := newDDLAdapter(nil, tc.targetVersion)
 			result := ddlAdapter.buildColumnDefinition(&tc.column)
 			require.Equal(t, tc.want, result)
 		})
@@ -1599,7 +1612,7 @@ func TestDDLAdapter_buildSequenceQueries(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
-			ddlAdapter := newDDLAdapter(nil)
+			ddlAdapter := newDDLAdapter(nil, latestServerVersion)
 
 			queries, dropQueries := ddlAdapter.buildSequenceQueries(testSchema, tc.diff)
 			require.Equal(t, tc.wantQueries, queries)
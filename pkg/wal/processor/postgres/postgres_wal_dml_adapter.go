@@ -32,18 +32,33 @@ type dmlAdapter struct {
 	logger           loglib.Logger
 	onConflictAction onConflictAction
 	forCopy          bool
+	// when enabled, insert conflicts are not resolved by the query itself.
+	// Instead, the query keeps the information required for the conflict
+	// resolver to resolve them when executed.
+	resolveConflicts bool
+	lsnColumn        string
+	// targetVersion is the target postgres server version, used to adapt
+	// the queries to the features it supports.
+	targetVersion serverVersion
 }
 
-func newDMLAdapter(action string, forCopy bool, logger loglib.Logger) (*dmlAdapter, error) {
+func newDMLAdapter(action string, forCopy bool, conflictResolution *ConflictResolutionConfig, targetVersion serverVersion, logger loglib.Logger) (*dmlAdapter, error) {
 	oca, err := parseOnConflictAction(action)
 	if err != nil {
 		return nil, err
 	}
-	return &dmlAdapter{
+	a := &dmlAdapter{
 		logger:           logger,
 		onConflictAction: oca,
 		forCopy:          forCopy,
-	}, nil
+		targetVersion:    targetVersion,
+	}
+	// conflict resolution is not supported for COPY
+	if conflictResolution != nil && !forCopy {
+		a.resolveConflicts = true
+		a.lsnColumn = conflictResolution.LSNColumn
+	}
+	return a, nil
 }
 
 func (a *dmlAdapter) walDataToQueries(d *wal.Data, schemaInfo schemaInfo) ([]*query, error) {
@@ -69,6 +84,16 @@ func (a *dmlAdapter) walDataToQueries(d *wal.Data, schemaInfo schemaInfo) ([]*qu
 	}
 }
 
+// buildOverridingClause returns the clause that allows the insert queries to
+// set the values of the identity columns, which are not supported by targets
+// older than postgres 10.
+func (a *dmlAdapter) buildOverridingClause() string {
+	if !a.targetVersion.supportsIdentityColumns() {
+		return ""
+	}
+	return " OVERRIDING SYSTEM VALUE"
+}
+
 func (a *dmlAdapter) buildTruncateQuery(d *wal.Data) *query {
 	return &query{
 		table:  d.Table,
@@ -97,6 +122,16 @@ func (a *dmlAdapter) buildInsertQueries(d *wal.Data, schemaInfo schemaInfo) []*q
 		return []*query{}
 	}
 
+	conflict := a.buildConflict(d, schemaInfo, values)
+	if conflict != nil && a.lsnColumn != "" && !slices.Contains(conflict.columnNames, a.lsnColumn) {
+		// keep track of the LSN of the write in the target row, so that it can
+		// be used to resolve future conflicts
+		names = append(names, pglib.QuoteIdentifier(a.lsnColumn))
+		values = append(values, d.LSN)
+		conflict.columnNames = append(conflict.columnNames, a.lsnColumn)
+		conflict.source.Values[a.lsnColumn] = d.LSN
+	}
+
 	placeholders := make([]string, 0, len(d.Columns))
 	for i := range names {
 		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
@@ -107,11 +142,14 @@ func (a *dmlAdapter) buildInsertQueries(d *wal.Data, schemaInfo schemaInfo) []*q
 			table:       d.Table,
 			schema:      d.Schema,
 			columnNames: names,
-			sql: fmt.Sprintf("INSERT INTO %s(%s) OVERRIDING SYSTEM VALUE VALUES(%s)%s",
+			sql: fmt.Sprintf("INSERT INTO %s(%s)%s VALUES(%s)%s",
 				quotedTableName(d.Schema, d.Table), strings.Join(names, ", "),
+				a.buildOverridingClause(),
 				strings.Join(placeholders, ", "),
 				a.buildOnConflictQuery(d, names)),
-			args: values,
+			args:     values,
+			conflict: conflict,
+			isInsert: true,
 		},
 	}
 
@@ -150,6 +188,13 @@ func (a *dmlAdapter) buildUpdateQuery(d *wal.Data, schemaInfo schemaInfo) (*quer
 		return &query{}, nil
 	}
 
+	if a.lsnColumn != "" && !slices.Contains(rowColumns, pglib.QuoteIdentifier(a.lsnColumn)) {
+		// keep track of the LSN of the write in the target row, so that it can
+		// be used to resolve future conflicts
+		rowColumns = append(rowColumns, pglib.QuoteIdentifier(a.lsnColumn))
+		rowValues = append(rowValues, d.LSN)
+	}
+
 	setQuery, setValues := a.buildSetQuery(d.Columns, rowColumns, rowValues)
 	// if there are no columns after filtering generated ones, no query to run
 	if setQuery == "" {
@@ -210,6 +255,17 @@ func (a *dmlAdapter) buildSetQuery(cols []wal.Column, rowColumns []string, rowVa
 }
 
 func (a *dmlAdapter) buildOnConflictQuery(d *wal.Data, filteredColumnNames []string) string {
+	if a.resolveConflicts {
+		// conflicts will be handled by the conflict resolver when the query is
+		// executed. Without primary keys there's no conflict target, so default
+		// to error behaviour.
+		primaryKeyCols := a.extractPrimaryKeyColumnNames(d.Metadata.InternalColIDs, d.Columns)
+		if len(primaryKeyCols) == 0 {
+			return ""
+		}
+		return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", strings.Join(primaryKeyCols, ","))
+	}
+
 	switch a.onConflictAction {
 	case onConflictUpdate:
 		// on conflict do update requires a conflict target. If there are no
@@ -232,6 +288,46 @@ func (a *dmlAdapter) buildOnConflictQuery(d *wal.Data, filteredColumnNames []str
 	}
 }
 
+// buildConflict returns the information required to resolve a conflict for the
+// insert of the row on input. It returns nil if conflict resolution is not
+// enabled or the row has no primary keys. The row values on input are expected
+// to be the ones returned by filterRowColumns.
+func (a *dmlAdapter) buildConflict(d *wal.Data, schemaInfo schemaInfo, rowValues []any) *conflict {
+	if !a.resolveConflicts {
+		return nil
+	}
+
+	primaryKeyCols := a.extractPrimaryKeyColumns(d.Metadata.InternalColIDs, d.Columns)
+	if len(primaryKeyCols) == 0 {
+		return nil
+	}
+
+	c := &conflict{
+		schema:           d.Schema,
+		table:            d.Table,
+		columnNames:      make([]string, 0, len(rowValues)),
+		primaryKeyNames:  make([]string, 0, len(primaryKeyCols)),
+		primaryKeyValues: make([]any, 0, len(primaryKeyCols)),
+		source: Row{
+			LSN:    d.LSN,
+			Values: make(map[string]any, len(rowValues)),
+		},
+		lsnColumn: a.lsnColumn,
+	}
+	for _, col := range primaryKeyCols {
+		c.primaryKeyNames = append(c.primaryKeyNames, col.Name)
+		c.primaryKeyValues = append(c.primaryKeyValues, serializeJSONBValue(col.Type, col.Value))
+	}
+	for _, col := range d.Columns {
+		if _, found := schemaInfo.generatedColumns[pglib.QuoteIdentifier(col.Name)]; found {
+			continue
+		}
+		c.source.Values[col.Name] = rowValues[len(c.columnNames)]
+		c.columnNames = append(c.columnNames, col.Name)
+	}
+	return c
+}
+
 func (a *dmlAdapter) extractPrimaryKeyColumns(colIDs []string, cols []wal.Column) []wal.Column {
 	primaryKeyColumns := make([]wal.Column, 0, len(colIDs))
 	for _, col := range cols {
@@ -32,6 +32,7 @@ func TestDMLAdapter_walDataToQueries(t *testing.T) {
 		generatedColumns map[string]struct{}
 		sequenceColumns  map[string]string
 		forCopy          bool
+		targetVersion    serverVersion
 
 		wantQueries []*query
 		wantErr     error
@@ -159,6 +160,34 @@ func TestDMLAdapter_walDataToQueries(t *testing.T) {
 					columnNames: quotedColumnNames,
 					sql:         fmt.Sprintf("INSERT INTO %s(\"id\", \"name\") OVERRIDING SYSTEM VALUE VALUES($1, $2)", quotedTestTable),
 					args:        []any{1, "alice"},
+					isInsert:    true,
+				},
+			},
+		},
+		{
+			name: "insert - target without identity columns",
+			walData: &wal.Data{
+				Action: "I",
+				Schema: testSchema,
+				Table:  testTable,
+				Columns: []wal.Column{
+					{ID: columnID(1), Name: "id", Value: 1},
+					{ID: columnID(2), Name: "name", Value: "alice"},
+				},
+				Metadata: wal.Metadata{
+					InternalColIDs: []string{columnID(1)},
+				},
+			},
+			targetVersion: 90600,
+
+			wantQueries: []*query{
+				{
+					schema:      testSchema,
+					table:       testTable,
+					columnNames: quotedColumnNames,
+					sql:         fmt.Sprintf("INSERT INTO %s(\"id\", \"name\") VALUES($1, $2)", quotedTestTable),
+					args:        []any{1, "alice"},
+					isInsert:    true,
 				},
 			},
 		},
@@ -188,6 +217,7 @@ func TestDMLAdapter_walDataToQueries(t *testing.T) {
 					columnNames: quotedColumnNames,
 					sql:         fmt.Sprintf("INSERT INTO %s(\"id\", \"name\") OVERRIDING SYSTEM VALUE VALUES($1, $2)", quotedTestTable),
 					args:        []any{float64(1), "alice"},
+					isInsert:    true,
 				},
 				{
 					schema: testSchema,
@@ -222,6 +252,7 @@ func TestDMLAdapter_walDataToQueries(t *testing.T) {
 					columnNames: quotedColumnNames,
 					sql:         fmt.Sprintf("INSERT INTO %s(\"id\", \"name\") OVERRIDING SYSTEM VALUE VALUES($1, $2)", quotedTestTable),
 					args:        []any{float64(1), "alice"},
+					isInsert:    true,
 				},
 			},
 		},
@@ -251,6 +282,7 @@ func TestDMLAdapter_walDataToQueries(t *testing.T) {
 					columnNames: quotedColumnNames,
 					sql:         fmt.Sprintf("INSERT INTO %s(\"id\", \"name\") OVERRIDING SYSTEM VALUE VALUES($1, $2)", quotedTestTable),
 					args:        []any{1, "alice"},
+					isInsert:    true,
 				},
 			},
 		},
@@ -278,6 +310,7 @@ func TestDMLAdapter_walDataToQueries(t *testing.T) {
 					columnNames: []string{`"id"`, `"name"`, `"created_at"`},
 					sql:         fmt.Sprintf("INSERT INTO %s(\"id\", \"name\", \"created_at\") OVERRIDING SYSTEM VALUE VALUES($1, $2, $3)", quotedTestTable),
 					args:        []any{1, "alice", pgtype.Timestamptz{Valid: true, InfinityModifier: pgtype.Infinity}},
+					isInsert:    true,
 				},
 			},
 		},
@@ -304,6 +337,7 @@ func TestDMLAdapter_walDataToQueries(t *testing.T) {
 					columnNames: quotedColumnNames,
 					sql:         fmt.Sprintf("INSERT INTO %s(\"id\", \"name\") OVERRIDING SYSTEM VALUE VALUES($1, $2) ON CONFLICT DO NOTHING", quotedTestTable),
 					args:        []any{1, "alice"},
+					isInsert:    true,
 				},
 			},
 		},
@@ -330,6 +364,7 @@ func TestDMLAdapter_walDataToQueries(t *testing.T) {
 					columnNames: quotedColumnNames,
 					sql:         fmt.Sprintf("INSERT INTO %s(\"id\", \"name\") OVERRIDING SYSTEM VALUE VALUES($1, $2) ON CONFLICT (\"id\") DO UPDATE SET \"id\" = EXCLUDED.\"id\", \"name\" = EXCLUDED.\"name\"", quotedTestTable),
 					args:        []any{1, "alice"},
+					isInsert:    true,
 				},
 			},
 		},
@@ -353,6 +388,7 @@ func TestDMLAdapter_walDataToQueries(t *testing.T) {
 					columnNames: quotedColumnNames,
 					sql:         fmt.Sprintf("INSERT INTO %s(\"id\", \"name\") OVERRIDING SYSTEM VALUE VALUES($1, $2)", quotedTestTable),
 					args:        []any{1, "alice"},
+					isInsert:    true,
 				},
 			},
 		},
@@ -502,6 +538,7 @@ func TestDMLAdapter_walDataToQueries(t *testing.T) {
 				logger:           log.NewNoopLogger(),
 				onConflictAction: tc.action,
 				forCopy:          tc.forCopy,
+				targetVersion:    tc.targetVersion,
 			}
 			queries, err := a.walDataToQueries(tc.walData, schemaInfo{
 				generatedColumns: tc.generatedColumns,
@@ -547,7 +584,7 @@ func Test_newDMLAdapter(t *testing.T) {
 		t.Run(tc.action, func(t *testing.T) {
 			t.Parallel()
 
-			_, err := newDMLAdapter(tc.action, false, log.NewNoopLogger())
+			_, err := newDMLAdapter(tc.action, false, nil, latestServerVersion, log.NewNoopLogger())
 			require.ErrorIs(t, err, tc.wantErr)
 		})
 	}
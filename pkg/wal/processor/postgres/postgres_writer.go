@@ -5,12 +5,14 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	pglib "github.com/xataio/pgstream/internal/postgres"
 	pglibretrier "github.com/xataio/pgstream/internal/postgres/retrier"
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
 	"github.com/xataio/pgstream/pkg/wal/processor/batch"
 )
@@ -20,10 +22,29 @@ type Writer struct {
 	pgConn          pglib.Querier
 	adapter         walAdapter
 	checkpointer    checkpointer.Checkpoint
+	txCheckpoint    TxCheckpoint
 	writerType      string
 	disableTriggers bool
+
+	conflictResolver   ConflictResolver
+	constraintDeferrer ConstraintDeferrer
+	// instrumentation is used to report the batching metrics
+	instrumentation *otel.Instrumentation
+}
+
+// ConstraintDeferrer returns the statements to run before and after the DML
+// queries of a target transaction inserting into the tables on input, to
+// defer their constraint checks until the end of the transaction, or none if
+// the checks don't need to be deferred. Tables are schema qualified, unquoted.
+type ConstraintDeferrer interface {
+	DeferConstraints(tables []string) (before, after []string)
 }
 
+// TxCheckpoint checkpoints the commit positions on input as part of the target
+// transaction, so that they are committed atomically with the queries of the
+// batch they belong to.
+type TxCheckpoint func(ctx context.Context, tx pglib.Tx, positions []wal.CommitPosition) error
+
 type queryBatchSender interface {
 	SendMessage(context.Context, *batch.WALMessage[*query]) error
 	Close()
@@ -58,7 +79,12 @@ func newWriter(ctx context.Context, config *Config, schemaLogStore schemalog.Sto
 
 	forCopy := writerType == bulkIngestWriter
 
-	w.adapter, err = newAdapter(ctx, schemaLogStore, w.logger, config.URL, config.OnConflictAction, forCopy)
+	conflictResolution, err := w.conflictResolution(config)
+	if err != nil {
+		return nil, err
+	}
+
+	w.adapter, err = newAdapter(ctx, schemaLogStore, w.logger, config.URL, config.OnConflictAction, forCopy, conflictResolution)
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +92,11 @@ func newWriter(ctx context.Context, config *Config, schemaLogStore schemalog.Sto
 	return w, nil
 }
 
+// Ping checks the connectivity with the target postgres database.
+func (w *Writer) Ping(ctx context.Context) error {
+	return w.pgConn.Ping(ctx)
+}
+
 func (w *Writer) close() error {
 	if err := w.adapter.close(); err != nil {
 		w.logger.Error(err, "closing adapter")
@@ -87,12 +118,64 @@ func WithCheckpoint(c checkpointer.Checkpoint) WriterOption {
 	}
 }
 
+// WithTxCheckpoint checkpoints the commit positions of every batch within the
+// last target transaction of the batch. It's only used by the batch writer.
+func WithTxCheckpoint(c TxCheckpoint) WriterOption {
+	return func(w *Writer) {
+		w.txCheckpoint = c
+	}
+}
+
+// WithConflictResolver sets the resolver used to handle insert conflicts. It
+// takes precedence over the conflict resolution strategy in the configuration.
+func WithConflictResolver(r ConflictResolver) WriterOption {
+	return func(w *Writer) {
+		w.conflictResolver = r
+	}
+}
+
+// WithConstraintDeferrer defers the constraint checks of the DML queries
+// until the end of each target transaction, as per the deferrer on input.
+func WithConstraintDeferrer(d ConstraintDeferrer) WriterOption {
+	return func(w *Writer) {
+		w.constraintDeferrer = d
+	}
+}
+
 func WithInstrumentation(i *otel.Instrumentation) WriterOption {
 	return func(w *Writer) {
+		w.instrumentation = i
 		w.adapter = newInstrumentedWalAdapter(w.adapter, i)
 	}
 }
 
+// conflictResolution returns the conflict resolution configuration to be used
+// by the adapter, or nil if insert conflicts don't need to be resolved. It will
+// initialise the conflict resolver from the configured strategy if one has not
+// been provided.
+func (w *Writer) conflictResolution(config *Config) (*ConflictResolutionConfig, error) {
+	if w.conflictResolver == nil && !config.ConflictResolution.isSet() {
+		return nil, nil
+	}
+
+	if _, err := parseOnConflictAction(config.OnConflictAction); err != nil {
+		return nil, err
+	}
+	if config.OnConflictAction != "" && config.OnConflictAction != "error" {
+		return nil, errConflictResolverWithOnConflictAction
+	}
+
+	if w.conflictResolver == nil {
+		var err error
+		w.conflictResolver, err = config.ConflictResolution.resolver()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &config.ConflictResolution, nil
+}
+
 func (w *Writer) setReplicationRoleToReplica(ctx context.Context, tx pglib.Tx) error {
 	if !w.disableTriggers {
 		return nil
@@ -115,3 +198,29 @@ func (w *Writer) resetReplicationRole(ctx context.Context, tx pglib.Tx) error {
 	}
 	return nil
 }
+
+// deferConstraints returns the statements to defer the constraint checks of
+// the tables inserted into by the queries on input, if a constraint deferrer
+// is configured.
+func (w *Writer) deferConstraints(queries []*query) (before, after []string) {
+	if w.constraintDeferrer == nil {
+		return nil, nil
+	}
+	tables := []string{}
+	for _, q := range queries {
+		table := q.schema + "." + q.table
+		if q.isInsert && !slices.Contains(tables, table) {
+			tables = append(tables, table)
+		}
+	}
+	return w.constraintDeferrer.DeferConstraints(tables)
+}
+
+func execStatements(ctx context.Context, tx pglib.Tx, statements []string) error {
+	for _, stmt := range statements {
+		if _, err := tx.Exec(ctx, stmt); err != nil {
+			return fmt.Errorf("executing %q: %w", stmt, err)
+		}
+	}
+	return nil
+}
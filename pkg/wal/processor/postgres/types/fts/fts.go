@@ -0,0 +1,420 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package fts
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TSVector is the postgres tsvector type, serialised as a space separated list
+// of quoted lexemes with their optional positions (i.e. `'cat':3 'fat':2,4A`).
+type TSVector struct {
+	Lexemes []Lexeme `json:"lexemes"`
+}
+
+// Lexeme is a normalised word of a tsvector, with the positions it appears at
+// in the original document.
+type Lexeme struct {
+	Word      string     `json:"word"`
+	Positions []Position `json:"positions,omitempty"`
+}
+
+// Position is the position of a lexeme in the document, labelled with one of
+// the A, B, C or D weights. D is the default weight and it's not included in
+// the text representation.
+type Position struct {
+	Index  int    `json:"index"`
+	Weight string `json:"weight"`
+}
+
+// TSQuery is the postgres tsquery type, serialised as the operands and
+// operators of the query in infix notation (i.e. `'fat' & ( 'rat' | 'cat' )`).
+// The nodes are kept in the same order, including the parentheses.
+type TSQuery struct {
+	Nodes []TSNode `json:"nodes"`
+}
+
+// TSNode is an operand, operator or parenthesis of a tsquery. Only operand
+// nodes have a value, prefix and weights, and only phrase nodes a distance.
+type TSNode struct {
+	Kind     string `json:"kind"`
+	Value    string `json:"value,omitempty"`
+	Prefix   bool   `json:"prefix,omitempty"`
+	Weights  string `json:"weights,omitempty"`
+	Distance int    `json:"distance,omitempty"`
+}
+
+const (
+	TSVectorDataType = "tsvector"
+	TSQueryDataType  = "tsquery"
+)
+
+const (
+	OperandNode    = "operand"
+	AndNode        = "and"
+	OrNode         = "or"
+	NotNode        = "not"
+	PhraseNode     = "phrase"
+	OpenParenNode  = "open_paren"
+	CloseParenNode = "close_paren"
+)
+
+const defaultWeight = "D"
+
+var (
+	ErrUnsupportedType = errors.New("unsupported full text search type")
+	ErrInvalidFormat   = errors.New("invalid full text search value format")
+)
+
+// Parse parses the postgres text representation of the full text search data
+// type on input into its structured type.
+func Parse(dataType, value string) (any, error) {
+	switch dataType {
+	case TSVectorDataType:
+		return parse(value, ParseTSVector)
+	case TSQueryDataType:
+		return parse(value, ParseTSQuery)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, dataType)
+	}
+}
+
+// parse makes sure no zero value is returned alongside an error.
+func parse[T any](value string, parseFn func(string) (T, error)) (any, error) {
+	v, err := parseFn(value)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func ParseTSVector(s string) (TSVector, error) {
+	lexemes := []Lexeme{}
+	for rest := strings.TrimSpace(s); rest != ""; rest = strings.TrimSpace(rest) {
+		word, n, err := parseWord(rest, isVectorDelimiter)
+		if err != nil {
+			return TSVector{}, fmt.Errorf("%w: %q", err, s)
+		}
+		rest = rest[n:]
+
+		lexeme := Lexeme{Word: word}
+		if strings.HasPrefix(rest, ":") {
+			positions, n, err := parsePositions(rest[1:])
+			if err != nil {
+				return TSVector{}, fmt.Errorf("%w: %q", err, s)
+			}
+			lexeme.Positions = positions
+			rest = rest[1+n:]
+		}
+		if rest != "" && !isSpace(rest[0]) {
+			return TSVector{}, fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+		}
+		lexemes = append(lexemes, lexeme)
+	}
+	return TSVector{Lexemes: lexemes}, nil
+}
+
+func ParseTSQuery(s string) (TSQuery, error) {
+	nodes := []TSNode{}
+	for rest := strings.TrimSpace(s); rest != ""; rest = strings.TrimSpace(rest) {
+		node, n, err := parseNode(rest)
+		if err != nil {
+			return TSQuery{}, fmt.Errorf("%w: %q", err, s)
+		}
+		nodes = append(nodes, node)
+		rest = rest[n:]
+	}
+	if err := validateNodes(nodes); err != nil {
+		return TSQuery{}, fmt.Errorf("%w: %q", err, s)
+	}
+	return TSQuery{Nodes: nodes}, nil
+}
+
+// The String methods return the postgres text representation of the values,
+// which is also what the Value methods use to write them to postgres targets.
+
+func (v TSVector) String() string {
+	parts := make([]string, 0, len(v.Lexemes))
+	for _, l := range v.Lexemes {
+		parts = append(parts, l.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (l Lexeme) String() string {
+	if len(l.Positions) == 0 {
+		return quoteWord(l.Word)
+	}
+	positions := make([]string, 0, len(l.Positions))
+	for _, p := range l.Positions {
+		positions = append(positions, p.String())
+	}
+	return quoteWord(l.Word) + ":" + strings.Join(positions, ",")
+}
+
+func (p Position) String() string {
+	if p.Weight == defaultWeight {
+		return strconv.Itoa(p.Index)
+	}
+	return strconv.Itoa(p.Index) + p.Weight
+}
+
+func (q TSQuery) String() string {
+	b := strings.Builder{}
+	for i, n := range q.Nodes {
+		// the not operator is written right before its operand
+		if i > 0 && q.Nodes[i-1].Kind != NotNode {
+			b.WriteByte(' ')
+		}
+		b.WriteString(n.String())
+	}
+	return b.String()
+}
+
+func (n TSNode) String() string {
+	switch n.Kind {
+	case OperandNode:
+		s := quoteWord(n.Value)
+		if n.Prefix || n.Weights != "" {
+			s += ":"
+			if n.Prefix {
+				s += "*"
+			}
+			s += n.Weights
+		}
+		return s
+	case AndNode:
+		return "&"
+	case OrNode:
+		return "|"
+	case NotNode:
+		return "!"
+	case PhraseNode:
+		if n.Distance == 1 {
+			return "<->"
+		}
+		return "<" + strconv.Itoa(n.Distance) + ">"
+	case OpenParenNode:
+		return "("
+	case CloseParenNode:
+		return ")"
+	default:
+		return ""
+	}
+}
+
+func (v TSVector) Value() (driver.Value, error) { return v.String(), nil }
+func (q TSQuery) Value() (driver.Value, error)  { return q.String(), nil }
+
+// parseNode parses the tsquery node at the start of the input, returning it
+// alongside the number of bytes consumed.
+func parseNode(s string) (TSNode, int, error) {
+	switch s[0] {
+	case '&':
+		return TSNode{Kind: AndNode}, 1, nil
+	case '|':
+		return TSNode{Kind: OrNode}, 1, nil
+	case '!':
+		return TSNode{Kind: NotNode}, 1, nil
+	case '(':
+		return TSNode{Kind: OpenParenNode}, 1, nil
+	case ')':
+		return TSNode{Kind: CloseParenNode}, 1, nil
+	case '<':
+		end := strings.IndexByte(s, '>')
+		if end < 0 {
+			return TSNode{}, 0, ErrInvalidFormat
+		}
+		distance := 1
+		if inner := s[1:end]; inner != "-" {
+			var err error
+			if distance, err = strconv.Atoi(inner); err != nil || distance < 0 {
+				return TSNode{}, 0, ErrInvalidFormat
+			}
+		}
+		return TSNode{Kind: PhraseNode, Distance: distance}, end + 1, nil
+	}
+
+	word, n, err := parseWord(s, isQueryDelimiter)
+	if err != nil {
+		return TSNode{}, 0, err
+	}
+	node := TSNode{Kind: OperandNode, Value: word}
+	if n < len(s) && s[n] == ':' {
+		n++
+		weights := [4]bool{}
+		for ; n < len(s); n++ {
+			c := upper(s[n])
+			if c == '*' {
+				node.Prefix = true
+				continue
+			}
+			if c < 'A' || c > 'D' {
+				break
+			}
+			weights[c-'A'] = true
+		}
+		for i, set := range weights {
+			if set {
+				node.Weights += string(rune('A' + i))
+			}
+		}
+	}
+	return node, n, nil
+}
+
+// validateNodes checks the operands and operators of the tsquery nodes are in
+// a valid infix order and the parentheses are balanced.
+func validateNodes(nodes []TSNode) error {
+	depth := 0
+	// whether the next node must be an operand, an opening parenthesis or a
+	// not operator
+	expectOperand := true
+	for _, n := range nodes {
+		switch n.Kind {
+		case OperandNode:
+			if !expectOperand {
+				return ErrInvalidFormat
+			}
+			expectOperand = false
+		case NotNode:
+			if !expectOperand {
+				return ErrInvalidFormat
+			}
+		case OpenParenNode:
+			if !expectOperand {
+				return ErrInvalidFormat
+			}
+			depth++
+		case CloseParenNode:
+			if expectOperand || depth == 0 {
+				return ErrInvalidFormat
+			}
+			depth--
+		default:
+			if expectOperand {
+				return ErrInvalidFormat
+			}
+			expectOperand = true
+		}
+	}
+	if depth != 0 || (len(nodes) > 0 && expectOperand) {
+		return ErrInvalidFormat
+	}
+	return nil
+}
+
+// parseWord parses the quoted or unquoted word at the start of the input,
+// returning it alongside the number of bytes consumed. Quotes are escaped by
+// doubling them, and any character can be escaped with a backslash.
+func parseWord(s string, isDelimiter func(byte) bool) (string, int, error) {
+	b := strings.Builder{}
+	if s[0] == '\'' {
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\' && i+1 < len(s):
+				i++
+				b.WriteByte(s[i])
+			case c == '\'' && i+1 < len(s) && s[i+1] == '\'':
+				i++
+				b.WriteByte('\'')
+			case c == '\'':
+				if b.Len() == 0 {
+					return "", 0, ErrInvalidFormat
+				}
+				return b.String(), i + 1, nil
+			default:
+				b.WriteByte(c)
+			}
+		}
+		// missing closing quote
+		return "", 0, ErrInvalidFormat
+	}
+
+	i := 0
+	for ; i < len(s) && !isDelimiter(s[i]); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	if i == 0 {
+		return "", 0, ErrInvalidFormat
+	}
+	return b.String(), i, nil
+}
+
+// parsePositions parses the comma separated list of lexeme positions at the
+// start of the input, returning them alongside the number of bytes consumed.
+func parsePositions(s string) ([]Position, int, error) {
+	positions := []Position{}
+	i := 0
+	for {
+		start := i
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		index, err := strconv.Atoi(s[start:i])
+		if err != nil || index <= 0 {
+			return nil, 0, ErrInvalidFormat
+		}
+		position := Position{Index: index, Weight: defaultWeight}
+		if i < len(s) {
+			if c := upper(s[i]); c >= 'A' && c <= 'D' {
+				position.Weight = string(c)
+				i++
+			} else if c == '*' {
+				// * is an alias of the default weight
+				i++
+			}
+		}
+		positions = append(positions, position)
+
+		if i >= len(s) || s[i] != ',' {
+			return positions, i, nil
+		}
+		i++
+	}
+}
+
+// quoteWord returns the word on input quoted, with its quotes and backslashes
+// escaped, the same way postgres outputs lexemes and operands.
+func quoteWord(word string) string {
+	b := strings.Builder{}
+	b.WriteByte('\'')
+	for i := 0; i < len(word); i++ {
+		switch word[i] {
+		case '\'':
+			b.WriteString("''")
+		case '\\':
+			b.WriteString(`\\`)
+		default:
+			b.WriteByte(word[i])
+		}
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+func isVectorDelimiter(c byte) bool {
+	return isSpace(c) || c == ':'
+}
+
+func isQueryDelimiter(c byte) bool {
+	return isSpace(c) || strings.IndexByte("&|!()<:", c) >= 0
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func upper(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
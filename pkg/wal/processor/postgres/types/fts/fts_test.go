@@ -0,0 +1,287 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package fts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/json"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dataType string
+		value    string
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:     "ok - tsvector",
+			dataType: TSVectorDataType,
+			value:    "'a':1,6,10 'and':8 'ate':9 'cat':3 'fat':2,11 'mat':7 'on':5 'rat':12 'sat':4",
+			wantValue: TSVector{Lexemes: []Lexeme{
+				{Word: "a", Positions: []Position{{Index: 1, Weight: "D"}, {Index: 6, Weight: "D"}, {Index: 10, Weight: "D"}}},
+				{Word: "and", Positions: []Position{{Index: 8, Weight: "D"}}},
+				{Word: "ate", Positions: []Position{{Index: 9, Weight: "D"}}},
+				{Word: "cat", Positions: []Position{{Index: 3, Weight: "D"}}},
+				{Word: "fat", Positions: []Position{{Index: 2, Weight: "D"}, {Index: 11, Weight: "D"}}},
+				{Word: "mat", Positions: []Position{{Index: 7, Weight: "D"}}},
+				{Word: "on", Positions: []Position{{Index: 5, Weight: "D"}}},
+				{Word: "rat", Positions: []Position{{Index: 12, Weight: "D"}}},
+				{Word: "sat", Positions: []Position{{Index: 4, Weight: "D"}}},
+			}},
+		},
+		{
+			name:     "ok - tsvector with weights",
+			dataType: TSVectorDataType,
+			value:    "'cat':3A 'fat':2B,4C 'rat':5",
+			wantValue: TSVector{Lexemes: []Lexeme{
+				{Word: "cat", Positions: []Position{{Index: 3, Weight: "A"}}},
+				{Word: "fat", Positions: []Position{{Index: 2, Weight: "B"}, {Index: 4, Weight: "C"}}},
+				{Word: "rat", Positions: []Position{{Index: 5, Weight: "D"}}},
+			}},
+		},
+		{
+			name:     "ok - tsvector without positions",
+			dataType: TSVectorDataType,
+			value:    "'a' 'and' 'ate' 'cat'",
+			wantValue: TSVector{Lexemes: []Lexeme{
+				{Word: "a"}, {Word: "and"}, {Word: "ate"}, {Word: "cat"},
+			}},
+		},
+		{
+			name:     "ok - tsvector with escaped characters",
+			dataType: TSVectorDataType,
+			value:    `'    ' 'Joe''s' 'a\\b' 'contains spaces':1`,
+			wantValue: TSVector{Lexemes: []Lexeme{
+				{Word: "    "},
+				{Word: "Joe's"},
+				{Word: `a\b`},
+				{Word: "contains spaces", Positions: []Position{{Index: 1, Weight: "D"}}},
+			}},
+		},
+		{
+			name:     "ok - unquoted tsvector",
+			dataType: TSVectorDataType,
+			value:    "fat:2b cat:3",
+			wantValue: TSVector{Lexemes: []Lexeme{
+				{Word: "fat", Positions: []Position{{Index: 2, Weight: "B"}}},
+				{Word: "cat", Positions: []Position{{Index: 3, Weight: "D"}}},
+			}},
+		},
+		{
+			name:      "ok - empty tsvector",
+			dataType:  TSVectorDataType,
+			value:     "",
+			wantValue: TSVector{Lexemes: []Lexeme{}},
+		},
+		{
+			name:     "ok - tsquery",
+			dataType: TSQueryDataType,
+			value:    "'fat' & ( 'rat' | 'cat' )",
+			wantValue: TSQuery{Nodes: []TSNode{
+				{Kind: OperandNode, Value: "fat"},
+				{Kind: AndNode},
+				{Kind: OpenParenNode},
+				{Kind: OperandNode, Value: "rat"},
+				{Kind: OrNode},
+				{Kind: OperandNode, Value: "cat"},
+				{Kind: CloseParenNode},
+			}},
+		},
+		{
+			name:     "ok - tsquery with negation",
+			dataType: TSQueryDataType,
+			value:    "'fat' & !( 'rat' | !'cat' )",
+			wantValue: TSQuery{Nodes: []TSNode{
+				{Kind: OperandNode, Value: "fat"},
+				{Kind: AndNode},
+				{Kind: NotNode},
+				{Kind: OpenParenNode},
+				{Kind: OperandNode, Value: "rat"},
+				{Kind: OrNode},
+				{Kind: NotNode},
+				{Kind: OperandNode, Value: "cat"},
+				{Kind: CloseParenNode},
+			}},
+		},
+		{
+			name:     "ok - tsquery with phrases",
+			dataType: TSQueryDataType,
+			value:    "'fat' <-> 'rat' <2> 'cat'",
+			wantValue: TSQuery{Nodes: []TSNode{
+				{Kind: OperandNode, Value: "fat"},
+				{Kind: PhraseNode, Distance: 1},
+				{Kind: OperandNode, Value: "rat"},
+				{Kind: PhraseNode, Distance: 2},
+				{Kind: OperandNode, Value: "cat"},
+			}},
+		},
+		{
+			name:     "ok - tsquery with prefix and weights",
+			dataType: TSQueryDataType,
+			value:    "'super':*AB & 'star':C",
+			wantValue: TSQuery{Nodes: []TSNode{
+				{Kind: OperandNode, Value: "super", Prefix: true, Weights: "AB"},
+				{Kind: AndNode},
+				{Kind: OperandNode, Value: "star", Weights: "C"},
+			}},
+		},
+		{
+			name:     "ok - unquoted tsquery",
+			dataType: TSQueryDataType,
+			value:    "fat&(rat|sup:ba*)",
+			wantValue: TSQuery{Nodes: []TSNode{
+				{Kind: OperandNode, Value: "fat"},
+				{Kind: AndNode},
+				{Kind: OpenParenNode},
+				{Kind: OperandNode, Value: "rat"},
+				{Kind: OrNode},
+				{Kind: OperandNode, Value: "sup", Prefix: true, Weights: "AB"},
+				{Kind: CloseParenNode},
+			}},
+		},
+		{
+			name:      "ok - empty tsquery",
+			dataType:  TSQueryDataType,
+			value:     "",
+			wantValue: TSQuery{Nodes: []TSNode{}},
+		},
+		{
+			name:     "error - unsupported type",
+			dataType: "regconfig",
+			value:    "english",
+			wantErr:  ErrUnsupportedType,
+		},
+		{
+			name:     "error - tsvector missing closing quote",
+			dataType: TSVectorDataType,
+			value:    "'cat':1 'fat",
+			wantErr:  ErrInvalidFormat,
+		},
+		{
+			name:     "error - tsvector invalid position",
+			dataType: TSVectorDataType,
+			value:    "'cat':a",
+			wantErr:  ErrInvalidFormat,
+		},
+		{
+			name:     "error - tsvector empty lexeme",
+			dataType: TSVectorDataType,
+			value:    "'cat' ''",
+			wantErr:  ErrInvalidFormat,
+		},
+		{
+			name:     "error - tsquery unbalanced parentheses",
+			dataType: TSQueryDataType,
+			value:    "'fat' & ( 'rat' | 'cat'",
+			wantErr:  ErrInvalidFormat,
+		},
+		{
+			name:     "error - tsquery missing operand",
+			dataType: TSQueryDataType,
+			value:    "'fat' & | 'cat'",
+			wantErr:  ErrInvalidFormat,
+		},
+		{
+			name:     "error - tsquery missing operator",
+			dataType: TSQueryDataType,
+			value:    "'fat' 'cat'",
+			wantErr:  ErrInvalidFormat,
+		},
+		{
+			name:     "error - tsquery invalid phrase distance",
+			dataType: TSQueryDataType,
+			value:    "'fat' <a> 'cat'",
+			wantErr:  ErrInvalidFormat,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := Parse(tc.dataType, tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, value)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	t.Parallel()
+
+	// the postgres output can be parsed back into the same text representation
+	tests := []struct {
+		dataType string
+		value    string
+	}{
+		{dataType: TSVectorDataType, value: "'a':1,6,10 'cat':3A 'fat':2B,4C 'rat'"},
+		{dataType: TSVectorDataType, value: `'Joe''s' 'a\\b':1`},
+		{dataType: TSVectorDataType, value: ""},
+		{dataType: TSQueryDataType, value: "'fat' & ( 'rat' | 'cat' )"},
+		{dataType: TSQueryDataType, value: "!'fat' & !( 'rat' <-> 'cat' )"},
+		{dataType: TSQueryDataType, value: "'super':*AB <3> 'Joe''s':C"},
+		{dataType: TSQueryDataType, value: "'fat' <0> 'rat'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.value, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := Parse(tc.dataType, tc.value)
+			require.NoError(t, err)
+			require.Equal(t, tc.value, parsed.(interface{ String() string }).String())
+		})
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value any
+
+		wantJSON string
+	}{
+		{
+			name: "tsvector",
+			value: TSVector{Lexemes: []Lexeme{
+				{Word: "cat", Positions: []Position{{Index: 3, Weight: "A"}, {Index: 5, Weight: "D"}}},
+				{Word: "fat"},
+			}},
+			wantJSON: `{"lexemes":[{"word":"cat","positions":[{"index":3,"weight":"A"},{"index":5,"weight":"D"}]},{"word":"fat"}]}`,
+		},
+		{
+			name:     "empty tsvector",
+			value:    TSVector{Lexemes: []Lexeme{}},
+			wantJSON: `{"lexemes":[]}`,
+		},
+		{
+			name: "tsquery",
+			value: TSQuery{Nodes: []TSNode{
+				{Kind: NotNode},
+				{Kind: OperandNode, Value: "super", Prefix: true, Weights: "A"},
+				{Kind: PhraseNode, Distance: 2},
+				{Kind: OperandNode, Value: "star"},
+			}},
+			wantJSON: `{"nodes":[{"kind":"not"},{"kind":"operand","value":"super","prefix":true,"weights":"A"},{"kind":"phrase","distance":2},{"kind":"operand","value":"star"}]}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := json.Marshal(tc.value)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.wantJSON, string(b))
+		})
+	}
+}
@@ -0,0 +1,314 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package geometry
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Point is the postgres point type, serialised as `(x,y)`.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Line is the postgres infinite line type, represented by the linear equation
+// Ax + By + C = 0 and serialised as `{A,B,C}`.
+type Line struct {
+	A float64 `json:"a"`
+	B float64 `json:"b"`
+	C float64 `json:"c"`
+}
+
+// LineSegment is the postgres lseg type, serialised as `[(x1,y1),(x2,y2)]`.
+type LineSegment struct {
+	Start Point `json:"start"`
+	End   Point `json:"end"`
+}
+
+// Box is the postgres box type, serialised as `(x1,y1),(x2,y2)`, where the
+// first point is the upper right corner and the second one the lower left.
+type Box struct {
+	UpperRight Point `json:"upper_right"`
+	LowerLeft  Point `json:"lower_left"`
+}
+
+// Path is the postgres path type, serialised as `[(x1,y1),...]` when it's open
+// and `((x1,y1),...)` when it's closed.
+type Path struct {
+	Points []Point `json:"points"`
+	Closed bool    `json:"closed"`
+}
+
+// Polygon is the postgres polygon type, serialised as `((x1,y1),...)`.
+type Polygon struct {
+	Points []Point `json:"points"`
+}
+
+// Circle is the postgres circle type, serialised as `<(x,y),r>`.
+type Circle struct {
+	Center Point   `json:"center"`
+	Radius float64 `json:"radius"`
+}
+
+const (
+	PointDataType       = "point"
+	LineDataType        = "line"
+	LineSegmentDataType = "lseg"
+	BoxDataType         = "box"
+	PathDataType        = "path"
+	PolygonDataType     = "polygon"
+	CircleDataType      = "circle"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported geometric type")
+	ErrInvalidFormat   = errors.New("invalid geometric value format")
+)
+
+// Parse parses the postgres text representation of the geometric data type on
+// input into its structured type.
+func Parse(dataType, value string) (any, error) {
+	switch dataType {
+	case PointDataType:
+		return parse(value, ParsePoint)
+	case LineDataType:
+		return parse(value, ParseLine)
+	case LineSegmentDataType:
+		return parse(value, ParseLineSegment)
+	case BoxDataType:
+		return parse(value, ParseBox)
+	case PathDataType:
+		return parse(value, ParsePath)
+	case PolygonDataType:
+		return parse(value, ParsePolygon)
+	case CircleDataType:
+		return parse(value, ParseCircle)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, dataType)
+	}
+}
+
+// parse makes sure no zero value is returned alongside an error.
+func parse[T any](value string, parseFn func(string) (T, error)) (any, error) {
+	v, err := parseFn(value)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func ParsePoint(s string) (Point, error) {
+	inner, err := trimDelimiters(s, '(', ')')
+	if err != nil {
+		return Point{}, err
+	}
+	values, err := parseNumbers(inner, 2)
+	if err != nil {
+		return Point{}, err
+	}
+	return Point{X: values[0], Y: values[1]}, nil
+}
+
+func ParseLine(s string) (Line, error) {
+	inner, err := trimDelimiters(s, '{', '}')
+	if err != nil {
+		return Line{}, err
+	}
+	values, err := parseNumbers(inner, 3)
+	if err != nil {
+		return Line{}, err
+	}
+	return Line{A: values[0], B: values[1], C: values[2]}, nil
+}
+
+func ParseLineSegment(s string) (LineSegment, error) {
+	inner, err := trimDelimiters(s, '[', ']')
+	if err != nil {
+		return LineSegment{}, err
+	}
+	points, err := parsePoints(inner, 2)
+	if err != nil {
+		return LineSegment{}, err
+	}
+	return LineSegment{Start: points[0], End: points[1]}, nil
+}
+
+func ParseBox(s string) (Box, error) {
+	points, err := parsePoints(strings.TrimSpace(s), 2)
+	if err != nil {
+		return Box{}, err
+	}
+	return Box{UpperRight: points[0], LowerLeft: points[1]}, nil
+}
+
+func ParsePath(s string) (Path, error) {
+	s = strings.TrimSpace(s)
+	closed := strings.HasPrefix(s, "((")
+	var inner string
+	var err error
+	if closed {
+		inner, err = trimDelimiters(s, '(', ')')
+	} else {
+		inner, err = trimDelimiters(s, '[', ']')
+	}
+	if err != nil {
+		return Path{}, err
+	}
+	points, err := parsePoints(inner, -1)
+	if err != nil {
+		return Path{}, err
+	}
+	return Path{Points: points, Closed: closed}, nil
+}
+
+func ParsePolygon(s string) (Polygon, error) {
+	inner, err := trimDelimiters(s, '(', ')')
+	if err != nil {
+		return Polygon{}, err
+	}
+	points, err := parsePoints(inner, -1)
+	if err != nil {
+		return Polygon{}, err
+	}
+	return Polygon{Points: points}, nil
+}
+
+func ParseCircle(s string) (Circle, error) {
+	inner, err := trimDelimiters(s, '<', '>')
+	if err != nil {
+		return Circle{}, err
+	}
+	// the center point is followed by the radius
+	i := strings.LastIndex(inner, ",")
+	if i < 0 {
+		return Circle{}, fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+	}
+	center, err := ParsePoint(inner[:i])
+	if err != nil {
+		return Circle{}, err
+	}
+	radius, err := parseNumbers(inner[i+1:], 1)
+	if err != nil {
+		return Circle{}, err
+	}
+	return Circle{Center: center, Radius: radius[0]}, nil
+}
+
+// The String methods return the postgres text representation of the values,
+// which is also what the Value methods use to write them to postgres targets.
+
+func (p Point) String() string {
+	return "(" + formatNumber(p.X) + "," + formatNumber(p.Y) + ")"
+}
+
+func (l Line) String() string {
+	return "{" + formatNumber(l.A) + "," + formatNumber(l.B) + "," + formatNumber(l.C) + "}"
+}
+
+func (l LineSegment) String() string {
+	return "[" + l.Start.String() + "," + l.End.String() + "]"
+}
+
+func (b Box) String() string {
+	return b.UpperRight.String() + "," + b.LowerLeft.String()
+}
+
+func (p Path) String() string {
+	if p.Closed {
+		return "(" + formatPoints(p.Points) + ")"
+	}
+	return "[" + formatPoints(p.Points) + "]"
+}
+
+func (p Polygon) String() string {
+	return "(" + formatPoints(p.Points) + ")"
+}
+
+func (c Circle) String() string {
+	return "<" + c.Center.String() + "," + formatNumber(c.Radius) + ">"
+}
+
+func (p Point) Value() (driver.Value, error)       { return p.String(), nil }
+func (l Line) Value() (driver.Value, error)        { return l.String(), nil }
+func (l LineSegment) Value() (driver.Value, error) { return l.String(), nil }
+func (b Box) Value() (driver.Value, error)         { return b.String(), nil }
+func (p Path) Value() (driver.Value, error)        { return p.String(), nil }
+func (p Polygon) Value() (driver.Value, error)     { return p.String(), nil }
+func (c Circle) Value() (driver.Value, error)      { return c.String(), nil }
+
+// trimDelimiters returns the value on input without the opening and closing
+// delimiters, failing if they're not present.
+func trimDelimiters(s string, open, closing byte) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != open || s[len(s)-1] != closing {
+		return "", fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+	}
+	return s[1 : len(s)-1], nil
+}
+
+// parsePoints parses a comma separated list of points. If count is not
+// negative, the list must have that exact number of points.
+func parsePoints(s string, count int) ([]Point, error) {
+	var points []Point
+	for rest := strings.TrimSpace(s); rest != ""; {
+		end := strings.IndexByte(rest, ')')
+		if end < 0 {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+		}
+		point, err := ParsePoint(rest[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+
+		rest = strings.TrimSpace(rest[end+1:])
+		if rest == "" {
+			break
+		}
+		if rest[0] != ',' {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+		}
+		rest = strings.TrimSpace(rest[1:])
+		if rest == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+		}
+	}
+	if len(points) == 0 || (count >= 0 && len(points) != count) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+	}
+	return points, nil
+}
+
+// parseNumbers parses a comma separated list with the exact count of numbers.
+func parseNumbers(s string, count int) ([]float64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != count {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+	}
+	values := make([]float64, 0, count)
+	for _, part := range parts {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalidFormat, s, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func formatPoints(points []Point) string {
+	parts := make([]string, 0, len(points))
+	for _, p := range points {
+		parts = append(parts, p.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
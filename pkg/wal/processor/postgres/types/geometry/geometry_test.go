@@ -0,0 +1,202 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package geometry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/json"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		dataType string
+		value    string
+
+		wantValue any
+		wantErr   error
+	}{
+		{
+			name:      "ok - point",
+			dataType:  PointDataType,
+			value:     "(1.5,-2)",
+			wantValue: Point{X: 1.5, Y: -2},
+		},
+		{
+			name:      "ok - point with spaces",
+			dataType:  PointDataType,
+			value:     " ( 1 , 2 ) ",
+			wantValue: Point{X: 1, Y: 2},
+		},
+		{
+			name:      "ok - line",
+			dataType:  LineDataType,
+			value:     "{1,-1,0}",
+			wantValue: Line{A: 1, B: -1, C: 0},
+		},
+		{
+			name:      "ok - lseg",
+			dataType:  LineSegmentDataType,
+			value:     "[(0,0),(1,1)]",
+			wantValue: LineSegment{Start: Point{X: 0, Y: 0}, End: Point{X: 1, Y: 1}},
+		},
+		{
+			name:      "ok - box",
+			dataType:  BoxDataType,
+			value:     "(2,2),(0,0)",
+			wantValue: Box{UpperRight: Point{X: 2, Y: 2}, LowerLeft: Point{X: 0, Y: 0}},
+		},
+		{
+			name:      "ok - open path",
+			dataType:  PathDataType,
+			value:     "[(0,0),(1,1),(2,0)]",
+			wantValue: Path{Points: []Point{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 2, Y: 0}}},
+		},
+		{
+			name:      "ok - closed path",
+			dataType:  PathDataType,
+			value:     "((0,0),(1,1))",
+			wantValue: Path{Points: []Point{{X: 0, Y: 0}, {X: 1, Y: 1}}, Closed: true},
+		},
+		{
+			name:      "ok - polygon",
+			dataType:  PolygonDataType,
+			value:     "((0,0),(1,1),(1,0))",
+			wantValue: Polygon{Points: []Point{{X: 0, Y: 0}, {X: 1, Y: 1}, {X: 1, Y: 0}}},
+		},
+		{
+			name:      "ok - circle",
+			dataType:  CircleDataType,
+			value:     "<(1,2),3.5>",
+			wantValue: Circle{Center: Point{X: 1, Y: 2}, Radius: 3.5},
+		},
+		{
+			name:     "error - unsupported type",
+			dataType: "geometry",
+			value:    "(1,2)",
+			wantErr:  ErrUnsupportedType,
+		},
+		{
+			name:     "error - invalid point number",
+			dataType: PointDataType,
+			value:    "(1,a)",
+			wantErr:  ErrInvalidFormat,
+		},
+		{
+			name:     "error - point missing coordinate",
+			dataType: PointDataType,
+			value:    "(1)",
+			wantErr:  ErrInvalidFormat,
+		},
+		{
+			name:     "error - lseg with a single point",
+			dataType: LineSegmentDataType,
+			value:    "[(0,0)]",
+			wantErr:  ErrInvalidFormat,
+		},
+		{
+			name:     "error - polygon trailing comma",
+			dataType: PolygonDataType,
+			value:    "((0,0),)",
+			wantErr:  ErrInvalidFormat,
+		},
+		{
+			name:     "error - circle missing radius",
+			dataType: CircleDataType,
+			value:    "<(1,2)>",
+			wantErr:  ErrInvalidFormat,
+		},
+		{
+			name:     "error - line with wrong delimiters",
+			dataType: LineDataType,
+			value:    "(1,2,3)",
+			wantErr:  ErrInvalidFormat,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := Parse(tc.dataType, tc.value)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, value)
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	t.Parallel()
+
+	// the text representation can be parsed back into the same value
+	values := map[string]string{
+		PointDataType:       "(1.5,-2)",
+		LineDataType:        "{1,-1,0}",
+		LineSegmentDataType: "[(0,0),(1,1)]",
+		BoxDataType:         "(2,2),(0,0)",
+		PathDataType:        "[(0,0),(1,1),(2,0)]",
+		PolygonDataType:     "((0,0),(1,1),(1,0))",
+		CircleDataType:      "<(1,2),3.5>",
+	}
+
+	for dataType, value := range values {
+		t.Run(dataType, func(t *testing.T) {
+			t.Parallel()
+
+			parsed, err := Parse(dataType, value)
+			require.NoError(t, err)
+			require.Equal(t, value, parsed.(interface{ String() string }).String())
+		})
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value any
+
+		wantJSON string
+	}{
+		{
+			name:     "point",
+			value:    Point{X: 1, Y: 2},
+			wantJSON: `{"x":1,"y":2}`,
+		},
+		{
+			name:     "line",
+			value:    Line{A: 1, B: -1, C: 0},
+			wantJSON: `{"a":1,"b":-1,"c":0}`,
+		},
+		{
+			name:     "lseg",
+			value:    LineSegment{Start: Point{X: 0, Y: 0}, End: Point{X: 1, Y: 1}},
+			wantJSON: `{"start":{"x":0,"y":0},"end":{"x":1,"y":1}}`,
+		},
+		{
+			name:     "path",
+			value:    Path{Points: []Point{{X: 0, Y: 0}}, Closed: true},
+			wantJSON: `{"points":[{"x":0,"y":0}],"closed":true}`,
+		},
+		{
+			name:     "circle",
+			value:    Circle{Center: Point{X: 1, Y: 2}, Radius: 3},
+			wantJSON: `{"center":{"x":1,"y":2},"radius":3}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			b, err := json.Marshal(tc.value)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.wantJSON, string(b))
+		})
+	}
+}
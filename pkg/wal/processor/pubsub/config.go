@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pubsub
+
+import (
+	"time"
+
+	"github.com/xataio/pgstream/pkg/backoff"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+type Config struct {
+	// ProjectID is the Google Cloud project the topics belong to.
+	ProjectID string
+	// DefaultTopic is the topic events are published to when their table has
+	// no topic configured in TableTopics. Events for tables without a topic
+	// are skipped if no default topic is configured.
+	DefaultTopic string
+	// TableTopics maps table names to the topic their events are published to.
+	// Table names can be qualified with the schema (<schema>.<table>), and
+	// default to the public schema otherwise.
+	TableTopics map[string]string
+	// Endpoint is the host:port of the Pub/Sub API. Defaults to the global
+	// endpoint, pubsub.googleapis.com:443. Regional endpoints are recommended
+	// when relying on ordered delivery.
+	Endpoint string
+	// EmulatorHost is the host:port of a Pub/Sub emulator. When set, requests
+	// are sent to the emulator without authentication. Defaults to the
+	// PUBSUB_EMULATOR_HOST environment variable.
+	EmulatorHost string
+	// CredentialsFile is the path to a service account JSON key file. If not
+	// provided, the application default credentials are used.
+	CredentialsFile string
+	// MaxOutstandingMessages is the maximum number of messages being published
+	// concurrently to a topic, after which publishing blocks. Defaults to 1000.
+	MaxOutstandingMessages int64
+	// RetryPolicy is the backoff policy used to retry publish requests that
+	// fail with a transient error. Defaults to an exponential backoff.
+	RetryPolicy backoff.Config
+
+	Batch batch.Config
+}
+
+const (
+	defaultMaxOutstandingMessages = 1000
+
+	defaultRetryInitialInterval = time.Second
+	defaultRetryMaxInterval     = time.Minute
+	defaultRetryMaxRetries      = 5
+)
+
+func (c *Config) maxOutstandingMessages() int64 {
+	if c.MaxOutstandingMessages > 0 {
+		return c.MaxOutstandingMessages
+	}
+	return defaultMaxOutstandingMessages
+}
+
+func (c *Config) retryPolicy() *backoff.Config {
+	if c.RetryPolicy.DisableRetries {
+		return &backoff.Config{DisableRetries: true}
+	}
+	if c.RetryPolicy.Constant != nil || c.RetryPolicy.Exponential != nil {
+		return &c.RetryPolicy
+	}
+	return &backoff.Config{
+		Exponential: &backoff.ExponentialConfig{
+			InitialInterval: defaultRetryInitialInterval,
+			MaxInterval:     defaultRetryMaxInterval,
+			MaxRetries:      defaultRetryMaxRetries,
+		},
+	}
+}
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// client publishes messages using the Pub/Sub client library. A publisher is
+// kept for each topic, with message ordering enabled, which bundles the
+// messages into publish requests and blocks once the max outstanding messages
+// are being published.
+type client struct {
+	client   *pubsub.Client
+	settings pubsub.PublishSettings
+
+	mutex      sync.Mutex
+	publishers map[string]*pubsub.Publisher
+}
+
+type publishMessage struct {
+	Data        []byte
+	Attributes  map[string]string
+	OrderingKey string
+}
+
+// newClient returns a client authenticated with the service account key file
+// of the config, or the application default credentials if none is provided.
+// Requests to the emulator are not authenticated.
+func newClient(ctx context.Context, cfg *Config, opts ...option.ClientOption) (*client, error) {
+	clientOpts := []option.ClientOption{}
+	if cfg.EmulatorHost != "" {
+		// the PUBSUB_EMULATOR_HOST environment variable is handled by the
+		// client library
+		clientOpts = append(clientOpts,
+			option.WithEndpoint(cfg.EmulatorHost),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())))
+	} else {
+		if cfg.Endpoint != "" {
+			clientOpts = append(clientOpts, option.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.CredentialsFile != "" {
+			clientOpts = append(clientOpts, option.WithCredentialsFile(cfg.CredentialsFile))
+		}
+	}
+
+	psClient, err := pubsub.NewClient(ctx, cfg.ProjectID, append(clientOpts, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("creating pubsub client: %w", err)
+	}
+
+	settings := pubsub.DefaultPublishSettings
+	settings.FlowControlSettings = pubsub.FlowControlSettings{
+		MaxOutstandingMessages: int(cfg.maxOutstandingMessages()),
+		MaxOutstandingBytes:    -1,
+		LimitExceededBehavior:  pubsub.FlowControlBlock,
+	}
+
+	return &client{
+		client:     psClient,
+		settings:   settings,
+		publishers: map[string]*pubsub.Publisher{},
+	}, nil
+}
+
+// publish publishes the messages on input to the topic, and returns the ones
+// that couldn't be published, in order. The publishing of an ordering key is
+// paused by the client library after an error, so that the messages are not
+// published out of order, and is resumed once all the messages are done.
+func (c *client) publish(ctx context.Context, topic string, msgs []*publishMessage) ([]*publishMessage, error) {
+	publisher := c.publisher(topic)
+
+	results := make([]*pubsub.PublishResult, 0, len(msgs))
+	for _, msg := range msgs {
+		results = append(results, publisher.Publish(ctx, &pubsub.Message{
+			Data:        msg.Data,
+			Attributes:  msg.Attributes,
+			OrderingKey: msg.OrderingKey,
+		}))
+	}
+
+	var failed []*publishMessage
+	var publishErr, pausedErr error
+	for i, result := range results {
+		_, err := result.Get(ctx)
+		if err == nil {
+			continue
+		}
+		failed = append(failed, msgs[i])
+		// the messages published after an error on the same ordering key
+		// fail with a paused error, which is not the cause
+		switch {
+		case errors.As(err, &pubsub.ErrPublishingPaused{}):
+			if pausedErr == nil {
+				pausedErr = err
+			}
+		case publishErr == nil:
+			publishErr = err
+		}
+	}
+
+	for _, msg := range failed {
+		if msg.OrderingKey != "" {
+			publisher.ResumePublish(msg.OrderingKey)
+		}
+	}
+
+	if publishErr == nil {
+		publishErr = pausedErr
+	}
+	return failed, publishErr
+}
+
+// getTopic checks the topic exists and is accessible with the configured
+// credentials.
+func (c *client) getTopic(ctx context.Context, topic string) error {
+	_, err := c.client.TopicAdminClient.GetTopic(ctx, &pubsubpb.GetTopicRequest{Topic: c.topicPath(topic)})
+	return err
+}
+
+// close publishes the outstanding messages of all the publishers, and closes
+// the client.
+func (c *client) close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, publisher := range c.publishers {
+		publisher.Stop()
+	}
+	c.publishers = map[string]*pubsub.Publisher{}
+	return c.client.Close()
+}
+
+func (c *client) publisher(topic string) *pubsub.Publisher {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if publisher, found := c.publishers[topic]; found {
+		return publisher
+	}
+	publisher := c.client.Publisher(c.topicPath(topic))
+	publisher.PublishSettings = c.settings
+	publisher.EnableMessageOrdering = true
+	c.publishers[topic] = publisher
+	return publisher
+}
+
+// topicPath returns the resource name for the topic. Topics can be provided
+// as a name within the configured project, or as a fully qualified resource
+// name (projects/<project>/topics/<topic>).
+func (c *client) topicPath(topic string) string {
+	if strings.HasPrefix(topic, "projects/") {
+		return topic
+	}
+	return fmt.Sprintf("projects/%s/topics/%s", c.client.Project(), topic)
+}
+
+// isRetriable returns true if the publish error can be retried, as per the
+// Pub/Sub publish retry guidelines. The messages that were paused after an
+// error on their ordering key can be published again once it's resumed.
+func isRetriable(err error) bool {
+	if errors.As(err, &pubsub.ErrPublishingPaused{}) {
+		return true
+	}
+	if errors.Is(err, pubsub.ErrOversizedMessage) {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Aborted,
+		codes.DeadlineExceeded,
+		codes.Internal,
+		codes.ResourceExhausted,
+		codes.Unavailable,
+		codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,331 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/pubsub/v2"
+	"cloud.google.com/go/pubsub/v2/apiv1/pubsubpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestClient_publish(t *testing.T) {
+	t.Parallel()
+
+	testMsgs := []*publishMessage{
+		{
+			Data:        []byte(`{"action":"I"}`),
+			Attributes:  map[string]string{"table": "users"},
+			OrderingKey: "1",
+		},
+		{
+			Data:       []byte(`{"action":"U"}`),
+			Attributes: map[string]string{"table": "orders"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		topic     string
+		publishFn func(*pubsubpb.PublishRequest) (*pubsubpb.PublishResponse, error)
+
+		wantTopic  string
+		wantFailed []*publishMessage
+		wantCode   codes.Code
+	}{
+		{
+			name:      "ok",
+			topic:     "events",
+			wantTopic: "projects/test-project/topics/events",
+		},
+		{
+			name:      "ok - qualified topic",
+			topic:     "projects/other-project/topics/events",
+			wantTopic: "projects/other-project/topics/events",
+		},
+		{
+			name:  "error - permanent error",
+			topic: "events",
+			publishFn: func(*pubsubpb.PublishRequest) (*pubsubpb.PublishResponse, error) {
+				return nil, status.Error(codes.NotFound, "topic not found")
+			},
+			wantTopic:  "projects/test-project/topics/events",
+			wantFailed: testMsgs,
+			wantCode:   codes.NotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := &fakePublisherServer{publishFn: tc.publishFn}
+			c := newTestClient(t, server)
+
+			failed, err := c.publish(context.Background(), tc.topic, testMsgs)
+			require.Equal(t, tc.wantCode, status.Code(err), err)
+			require.Equal(t, tc.wantFailed, failed)
+
+			requests := server.getPublishRequests()
+			require.NotEmpty(t, requests)
+			gotMsgs := []*pubsubpb.PubsubMessage{}
+			for _, req := range requests {
+				require.Equal(t, tc.wantTopic, req.GetTopic())
+				gotMsgs = append(gotMsgs, req.GetMessages()...)
+			}
+			require.Len(t, gotMsgs, len(testMsgs))
+			for _, want := range testMsgs {
+				require.True(t, containsMessage(gotMsgs, want), string(want.Data))
+			}
+		})
+	}
+}
+
+func TestClient_publish_resume(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	calls := 0
+	server := &fakePublisherServer{
+		publishFn: func(*pubsubpb.PublishRequest) (*pubsubpb.PublishResponse, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			calls++
+			if calls == 1 {
+				return nil, status.Error(codes.InvalidArgument, "invalid message")
+			}
+			return nil, nil
+		},
+	}
+	c := newTestClient(t, server)
+
+	msgs := []*publishMessage{
+		{Data: []byte("1"), OrderingKey: "1"},
+		{Data: []byte("2"), OrderingKey: "1"},
+	}
+	failed, err := c.publish(context.Background(), "events", msgs)
+	require.Equal(t, codes.InvalidArgument, status.Code(err), err)
+	require.Equal(t, msgs, failed)
+
+	// the ordering key is resumed once the failed messages are returned, so
+	// they can be published again
+	failed, err = c.publish(context.Background(), "events", failed)
+	require.NoError(t, err)
+	require.Empty(t, failed)
+}
+
+func TestClient_getTopic(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		getTopicFn func(*pubsubpb.GetTopicRequest) (*pubsubpb.Topic, error)
+
+		wantCode codes.Code
+	}{
+		{
+			name: "ok",
+			getTopicFn: func(req *pubsubpb.GetTopicRequest) (*pubsubpb.Topic, error) {
+				require.Equal(t, "projects/test-project/topics/events", req.GetTopic())
+				return &pubsubpb.Topic{Name: req.GetTopic()}, nil
+			},
+		},
+		{
+			name: "error - not found",
+			getTopicFn: func(*pubsubpb.GetTopicRequest) (*pubsubpb.Topic, error) {
+				return nil, status.Error(codes.NotFound, "topic not found")
+			},
+			wantCode: codes.NotFound,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := newTestClient(t, &fakePublisherServer{getTopicFn: tc.getTopicFn})
+
+			err := c.getTopic(context.Background(), "events")
+			require.Equal(t, tc.wantCode, status.Code(err), err)
+		})
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok - emulator", func(t *testing.T) {
+		t.Parallel()
+
+		// the emulator is not authenticated, so the credentials file is not
+		// read
+		c, err := newClient(context.Background(), &Config{
+			ProjectID:              "test-project",
+			EmulatorHost:           "localhost:8085",
+			CredentialsFile:        filepath.Join(t.TempDir(), "missing.json"),
+			MaxOutstandingMessages: 10,
+		})
+		require.NoError(t, err)
+		defer c.close()
+
+		require.Equal(t, "projects/test-project/topics/events", c.topicPath("events"))
+		require.Equal(t, pubsub.FlowControlSettings{
+			MaxOutstandingMessages: 10,
+			MaxOutstandingBytes:    -1,
+			LimitExceededBehavior:  pubsub.FlowControlBlock,
+		}, c.settings.FlowControlSettings)
+
+		publisher := c.publisher("events")
+		require.True(t, publisher.EnableMessageOrdering)
+		require.Equal(t, c.settings, publisher.PublishSettings)
+		require.Same(t, publisher, c.publisher("events"))
+	})
+
+	t.Run("error - missing credentials file", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newClient(context.Background(), &Config{
+			ProjectID:       "test-project",
+			CredentialsFile: filepath.Join(t.TempDir(), "missing.json"),
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestIsRetriable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+
+		wantRetriable bool
+	}{
+		{
+			name:          "unavailable",
+			err:           status.Error(codes.Unavailable, "unavailable"),
+			wantRetriable: true,
+		},
+		{
+			name:          "resource exhausted",
+			err:           status.Error(codes.ResourceExhausted, "quota exceeded"),
+			wantRetriable: true,
+		},
+		{
+			name:          "publishing paused",
+			err:           fmt.Errorf("publishing: %w", pubsub.ErrPublishingPaused{OrderingKey: "1"}),
+			wantRetriable: true,
+		},
+		{
+			name:          "not found",
+			err:           status.Error(codes.NotFound, "topic not found"),
+			wantRetriable: false,
+		},
+		{
+			name:          "permission denied",
+			err:           status.Error(codes.PermissionDenied, "permission denied"),
+			wantRetriable: false,
+		},
+		{
+			name:          "oversized message",
+			err:           pubsub.ErrOversizedMessage,
+			wantRetriable: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantRetriable, isRetriable(tc.err))
+		})
+	}
+}
+
+// fakePublisherServer is a Pub/Sub publisher server that accepts all the
+// messages, unless the publish function returns an error.
+type fakePublisherServer struct {
+	pubsubpb.UnimplementedPublisherServer
+
+	publishFn  func(*pubsubpb.PublishRequest) (*pubsubpb.PublishResponse, error)
+	getTopicFn func(*pubsubpb.GetTopicRequest) (*pubsubpb.Topic, error)
+
+	mutex    sync.Mutex
+	requests []*pubsubpb.PublishRequest
+}
+
+func newTestClient(t *testing.T, fake *fakePublisherServer) *client {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	pubsubpb.RegisterPublisherServer(server, fake)
+	go server.Serve(listener) //nolint:errcheck
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	c, err := newClient(context.Background(), &Config{ProjectID: "test-project"}, option.WithGRPCConn(conn))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		c.close()
+	})
+	return c
+}
+
+func (f *fakePublisherServer) Publish(_ context.Context, req *pubsubpb.PublishRequest) (*pubsubpb.PublishResponse, error) {
+	f.mutex.Lock()
+	f.requests = append(f.requests, req)
+	f.mutex.Unlock()
+
+	if f.publishFn != nil {
+		resp, err := f.publishFn(req)
+		if err != nil || resp != nil {
+			return resp, err
+		}
+	}
+	ids := make([]string, 0, len(req.GetMessages()))
+	for i := range req.GetMessages() {
+		ids = append(ids, fmt.Sprintf("%d", i))
+	}
+	return &pubsubpb.PublishResponse{MessageIds: ids}, nil
+}
+
+func (f *fakePublisherServer) GetTopic(_ context.Context, req *pubsubpb.GetTopicRequest) (*pubsubpb.Topic, error) {
+	if f.getTopicFn == nil {
+		return nil, errors.New("unexpected get topic request")
+	}
+	return f.getTopicFn(req)
+}
+
+func (f *fakePublisherServer) getPublishRequests() []*pubsubpb.PublishRequest {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.requests
+}
+
+func containsMessage(msgs []*pubsubpb.PubsubMessage, want *publishMessage) bool {
+	for _, msg := range msgs {
+		if string(msg.GetData()) == string(want.Data) && msg.GetOrderingKey() == want.OrderingKey &&
+			len(msg.GetAttributes()) == len(want.Attributes) && msg.GetAttributes()["table"] == want.Attributes["table"] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,381 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/backoff"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	"golang.org/x/sync/errgroup"
+)
+
+// PubSubSink is a wal processor that publishes the events to Google Cloud
+// Pub/Sub topics, routed by table. Messages carry the table, action and lsn
+// as attributes, and use the stringified primary key as ordering key so that
+// subscriptions with message ordering enabled receive the changes to a row in
+// order.
+type PubSubSink struct {
+	logger          loglib.Logger
+	batchSender     batchSender
+	instrumentation *otel.Instrumentation
+	publisher       publisher
+	serialiser      func(any) ([]byte, error)
+	backoffProvider backoff.Provider
+
+	defaultTopic string
+	tableTopics  map[string]string
+
+	// optional checkpointer callback to mark what was safely processed
+	checkpointer checkpointer.Checkpoint
+}
+
+type Option func(*PubSubSink)
+
+type batchSender interface {
+	SendMessage(context.Context, *batch.WALMessage[message]) error
+	Close()
+}
+
+type publisher interface {
+	// publish returns the messages that couldn't be published, in order
+	publish(ctx context.Context, topic string, msgs []*publishMessage) ([]*publishMessage, error)
+	getTopic(ctx context.Context, topic string) error
+	close() error
+}
+
+// message is a single event to be published to a topic
+type message struct {
+	topic string
+	msg   *publishMessage
+}
+
+const (
+	// limits for a single publish request, as documented in
+	// https://cloud.google.com/pubsub/quotas#resource_limits
+	maxOrderingKeyBytes    = 1024
+	maxPublishRequestBytes = int(pubsub.MaxPublishRequestBytes)
+	// approximate size of the proto encoding of a message, excluding the
+	// data and attributes
+	messageOverheadBytes = 16
+
+	publicSchema = "public"
+)
+
+var (
+	errMissingProjectID = errors.New("missing pubsub project id")
+	errMissingTopic     = errors.New("at least one of default topic or table topics must be provided")
+	errMessageTooLarge  = errors.New("message too large")
+)
+
+func NewPubSubSink(ctx context.Context, cfg *Config, opts ...Option) (*PubSubSink, error) {
+	if cfg.ProjectID == "" {
+		return nil, errMissingProjectID
+	}
+	if cfg.DefaultTopic == "" && len(cfg.TableTopics) == 0 {
+		return nil, errMissingTopic
+	}
+
+	s := &PubSubSink{
+		logger:          loglib.NewNoopLogger(),
+		serialiser:      json.Marshal,
+		backoffProvider: backoff.NewProvider(cfg.retryPolicy()),
+		defaultTopic:    cfg.DefaultTopic,
+		tableTopics:     make(map[string]string, len(cfg.TableTopics)),
+	}
+
+	for table, topic := range cfg.TableTopics {
+		// unqualified table names belong to the public schema
+		if !strings.Contains(table, ".") {
+			table = publicSchema + "." + table
+		}
+		s.tableTopics[table] = topic
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	var err error
+	s.publisher, err = newClient(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s.batchSender, err = batch.NewSender(ctx, &cfg.Batch, s.sendBatch, s.logger, batch.WithInstrumentation(s.instrumentation, s.Name()))
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *PubSubSink) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "pubsub_sink",
+		})
+	}
+}
+
+func WithCheckpoint(c checkpointer.Checkpoint) Option {
+	return func(s *PubSubSink) {
+		s.checkpointer = c
+	}
+}
+
+// WithInstrumentation reports the metrics of the batches sent by the sink.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(s *PubSubSink) {
+		s.instrumentation = i
+	}
+}
+
+// ProcessWALEvent is called on every new message from the wal. It can be called
+// concurrently.
+func (s *PubSubSink) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Panic("[PANIC] Panic while processing replication event", loglib.Fields{
+				"wal_data":    walEvent,
+				"panic":       r,
+				"stack_trace": debug.Stack(),
+			})
+
+			retErr = fmt.Errorf("pubsub sink: understanding event: %v", r)
+		}
+	}()
+
+	msg := message{}
+	if walEvent.Data != nil {
+		topic := s.topic(walEvent.Data)
+		// events for tables without a topic are not published, but their
+		// position is still checkpointed
+		if topic != "" {
+			var err error
+			msg, err = s.newMessage(topic, walEvent.Data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.batchSender.SendMessage(ctx, batch.NewWALMessage(msg, walEvent.CommitPosition))
+}
+
+func (s *PubSubSink) Name() string {
+	return "pubsub-sink"
+}
+
+func (s *PubSubSink) Close() error {
+	s.batchSender.Close()
+	return s.publisher.close()
+}
+
+// Ping checks all the configured topics are accessible.
+func (s *PubSubSink) Ping(ctx context.Context) error {
+	topics := make([]string, 0, len(s.tableTopics)+1)
+	if s.defaultTopic != "" {
+		topics = append(topics, s.defaultTopic)
+	}
+	for _, topic := range s.tableTopics {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+
+	for _, topic := range slices.Compact(topics) {
+		if err := s.publisher.getTopic(ctx, topic); err != nil {
+			return fmt.Errorf("pubsub topic %s: %w", topic, err)
+		}
+	}
+	return nil
+}
+
+func (s *PubSubSink) newMessage(topic string, data *wal.Data) (message, error) {
+	dataBytes, err := s.serialiser(data)
+	if err != nil {
+		return message{}, fmt.Errorf("marshalling event: %w", err)
+	}
+
+	msg := message{
+		topic: topic,
+		msg: &publishMessage{
+			Data: dataBytes,
+			Attributes: map[string]string{
+				"schema": data.Schema,
+				"table":  data.Table,
+				"action": data.Action,
+				"lsn":    data.LSN,
+			},
+			OrderingKey: orderingKey(data),
+		},
+	}
+
+	if len(msg.msg.OrderingKey) > maxOrderingKeyBytes {
+		s.logger.Warn(nil, "pubsub sink: primary key is too large to be used as ordering key, publishing unordered", loglib.Fields{
+			"schema": data.Schema,
+			"table":  data.Table,
+			"size":   len(msg.msg.OrderingKey),
+		})
+		msg.msg.OrderingKey = ""
+	}
+
+	if msg.Size() > maxPublishRequestBytes {
+		s.logger.Warn(errMessageTooLarge, "pubsub sink: wal event is larger than the max publish request size, skipping", loglib.Fields{
+			"max_bytes": maxPublishRequestBytes,
+			"size":      msg.Size(),
+			"schema":    data.Schema,
+			"table":     data.Table,
+		})
+		return message{}, nil
+	}
+
+	return msg, nil
+}
+
+// topic returns the topic the event on input should be published to, or an
+// empty string if there's none.
+func (s *PubSubSink) topic(data *wal.Data) string {
+	if topic, found := s.tableTopics[data.Schema+"."+data.Table]; found {
+		return topic
+	}
+	return s.defaultTopic
+}
+
+func (s *PubSubSink) sendBatch(ctx context.Context, batch *batch.Batch[message]) error {
+	messages := batch.GetMessages()
+	s.logger.Debug("pubsub sink: publishing message batch", loglib.Fields{
+		"batch_size":             len(messages),
+		"batch_commit_positions": len(batch.GetCommitPositions()),
+	})
+
+	if len(messages) > 0 {
+		if err := s.publishMessages(ctx, messages); err != nil {
+			s.logger.Error(err, "failed to publish to pubsub")
+			return fmt.Errorf("pubsub sink: %w", err)
+		}
+	}
+
+	positions := batch.GetCommitPositions()
+	if s.checkpointer != nil && len(positions) > 0 {
+		if err := s.checkpointer(ctx, positions); err != nil {
+			s.logger.Warn(err, "pubsub sink: error updating commit position")
+		}
+	}
+
+	return nil
+}
+
+// publishMessages publishes the messages to their topics. Topics are published
+// to concurrently, while the messages of the same topic are published in order
+// to preserve the ordering of the messages with the same ordering key.
+func (s *PubSubSink) publishMessages(ctx context.Context, messages []message) error {
+	topicMessages := map[string][]*publishMessage{}
+	for _, m := range messages {
+		topicMessages[m.topic] = append(topicMessages[m.topic], m.msg)
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	for topic, msgs := range topicMessages {
+		eg.Go(func() error {
+			if err := s.publishTopicMessages(ctx, topic, msgs); err != nil {
+				return fmt.Errorf("publishing to topic %s: %w", topic, err)
+			}
+			return nil
+		})
+	}
+	return eg.Wait()
+}
+
+// publishTopicMessages publishes the messages to the topic, retrying the
+// ones that failed with a transient error. Only the messages that couldn't be
+// published are retried, in order.
+func (s *PubSubSink) publishTopicMessages(ctx context.Context, topic string, msgs []*publishMessage) error {
+	pending := msgs
+	return s.backoffProvider(ctx).RetryNotify(
+		func() error {
+			var err error
+			pending, err = s.publisher.publish(ctx, topic, pending)
+			return retryError(err)
+		},
+		func(err error, d time.Duration) {
+			s.logger.Warn(err, "pubsub sink: publish failed, retrying", loglib.Fields{
+				"topic":       topic,
+				"failed":      len(pending),
+				"retry_after": d,
+			})
+		})
+}
+
+// orderingKey returns the stringified primary key of the event, or an empty
+// string if the primary key can't be identified. Primary keys are identified
+// by the pgstream column ids added by the injector, falling back to the
+// replica identity columns.
+func orderingKey(data *wal.Data) string {
+	var pkColumns []wal.Column
+	if ids := data.Metadata.InternalColIDs; len(ids) > 0 {
+		// delete events only have the identity columns
+		columns := data.Columns
+		if len(columns) == 0 {
+			columns = data.Identity
+		}
+		for _, col := range columns {
+			if slices.Contains(ids, col.ID) {
+				pkColumns = append(pkColumns, col)
+			}
+		}
+	} else {
+		pkColumns = data.Identity
+	}
+
+	values := make([]string, 0, len(pkColumns))
+	for _, col := range pkColumns {
+		values = append(values, fmt.Sprintf("%v", col.Value))
+	}
+	return strings.Join(values, "|")
+}
+
+// retryError returns a backoff permanent error if the given error is not
+// retriable
+func retryError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.Canceled) || !isRetriable(err) {
+		return fmt.Errorf("%w: %w", err, backoff.ErrPermanent)
+	}
+	return err
+}
+
+// publishMessageSize returns the approximate size of the message in a publish
+// request.
+func publishMessageSize(msg *publishMessage) int {
+	size := len(msg.Data) + len(msg.OrderingKey) + messageOverheadBytes
+	for k, v := range msg.Attributes {
+		size += len(k) + len(v)
+	}
+	return size
+}
+
+func (m message) Size() int {
+	if m.msg == nil {
+		return 0
+	}
+	return publishMessageSize(m.msg)
+}
+
+func (m message) IsEmpty() bool {
+	return m.msg == nil
+}
@@ -0,0 +1,483 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/pubsub/v2"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/backoff"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	batchmocks "github.com/xataio/pgstream/pkg/wal/processor/batch/mocks"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type mockPublisher struct {
+	publishFn  func(call uint, topic string, msgs []*publishMessage) ([]*publishMessage, error)
+	getTopicFn func(topic string) error
+
+	mutex    sync.Mutex
+	calls    uint
+	messages map[string][]string
+}
+
+func (m *mockPublisher) getTopic(_ context.Context, topic string) error {
+	if m.getTopicFn != nil {
+		return m.getTopicFn(topic)
+	}
+	return nil
+}
+
+func (m *mockPublisher) publish(_ context.Context, topic string, msgs []*publishMessage) ([]*publishMessage, error) {
+	m.mutex.Lock()
+	m.calls++
+	call := m.calls
+	m.mutex.Unlock()
+
+	var failed []*publishMessage
+	var err error
+	if m.publishFn != nil {
+		failed, err = m.publishFn(call, topic, msgs)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, msg := range msgs {
+		if slices.Contains(failed, msg) {
+			continue
+		}
+		if m.messages == nil {
+			m.messages = map[string][]string{}
+		}
+		m.messages[topic] = append(m.messages[topic], msg.OrderingKey)
+	}
+	return failed, err
+}
+
+func (m *mockPublisher) close() error {
+	return nil
+}
+
+func newTestData(table string, id int) *wal.Data {
+	return &wal.Data{
+		Action: "I",
+		LSN:    "0/1",
+		Schema: "public",
+		Table:  table,
+		Columns: []wal.Column{
+			{ID: "id-1", Name: "id", Type: "integer", Value: float64(id)},
+			{ID: "id-2", Name: "name", Type: "text", Value: "alice"},
+		},
+		Metadata: wal.Metadata{
+			InternalColIDs: []string{"id-1"},
+		},
+	}
+}
+
+func newTestMessage(t *testing.T, topic string, data *wal.Data, orderingKey string) message {
+	dataBytes, err := json.Marshal(data)
+	require.NoError(t, err)
+	return message{
+		topic: topic,
+		msg: &publishMessage{
+			Data: dataBytes,
+			Attributes: map[string]string{
+				"schema": data.Schema,
+				"table":  data.Table,
+				"action": data.Action,
+				"lsn":    data.LSN,
+			},
+			OrderingKey: orderingKey,
+		},
+	}
+}
+
+func newTestSink(publisher publisher) *PubSubSink {
+	return &PubSubSink{
+		logger:       loglib.NewNoopLogger(),
+		publisher:    publisher,
+		serialiser:   json.Marshal,
+		defaultTopic: "default",
+		tableTopics: map[string]string{
+			"public.users": "users",
+		},
+		backoffProvider: func(ctx context.Context) backoff.Backoff {
+			return backoff.NewConstantBackoff(ctx, &backoff.ConstantConfig{
+				Interval:   0,
+				MaxRetries: 2,
+			})
+		},
+	}
+}
+
+func TestNewPubSubSink(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  *Config
+
+		wantTableTopics map[string]string
+		wantErr         error
+	}{
+		{
+			name: "ok",
+			cfg: &Config{
+				ProjectID:    "test-project",
+				DefaultTopic: "events",
+				TableTopics: map[string]string{
+					"users":      "public-users",
+					"auth.users": "auth-users",
+				},
+				EmulatorHost: "localhost:8085",
+			},
+			wantTableTopics: map[string]string{
+				"public.users": "public-users",
+				"auth.users":   "auth-users",
+			},
+		},
+		{
+			name:    "error - missing project id",
+			cfg:     &Config{DefaultTopic: "events"},
+			wantErr: errMissingProjectID,
+		},
+		{
+			name:    "error - missing topic",
+			cfg:     &Config{ProjectID: "test-project"},
+			wantErr: errMissingTopic,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			s, err := NewPubSubSink(ctx, tc.cfg)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			defer s.publisher.close()
+			require.Equal(t, tc.wantTableTopics, s.tableTopics)
+		})
+	}
+}
+
+func TestPubSubSink_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	testCommitPosition := wal.CommitPosition("0/1")
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name       string
+		event      *wal.Event
+		serialiser func(any) ([]byte, error)
+
+		wantMsgs []*batch.WALMessage[message]
+		wantErr  error
+	}{
+		{
+			name:  "ok - table topic",
+			event: &wal.Event{Data: newTestData("users", 1), CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(newTestMessage(t, "users", newTestData("users", 1), "1"), testCommitPosition),
+			},
+		},
+		{
+			name:  "ok - default topic",
+			event: &wal.Event{Data: newTestData("orders", 1), CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(newTestMessage(t, "default", newTestData("orders", 1), "1"), testCommitPosition),
+			},
+		},
+		{
+			name:  "ok - keep alive",
+			event: &wal.Event{CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, testCommitPosition),
+			},
+		},
+		{
+			name:       "error - serialising event",
+			event:      &wal.Event{Data: newTestData("users", 1), CommitPosition: testCommitPosition},
+			serialiser: func(any) ([]byte, error) { return nil, errTest },
+			wantMsgs:   []*batch.WALMessage[message]{},
+			wantErr:    errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockBatchSender := batchmocks.NewBatchSender[message]()
+			s := newTestSink(&mockPublisher{})
+			s.batchSender = mockBatchSender
+			if tc.serialiser != nil {
+				s.serialiser = tc.serialiser
+			}
+
+			doneChan := make(chan struct{})
+			go func() {
+				defer close(doneChan)
+				require.Equal(t, tc.wantMsgs, mockBatchSender.GetWALMessages())
+			}()
+
+			err := s.ProcessWALEvent(context.Background(), tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+			mockBatchSender.Close()
+			<-doneChan
+		})
+	}
+
+	t.Run("ok - no topic for table", func(t *testing.T) {
+		t.Parallel()
+
+		mockBatchSender := batchmocks.NewBatchSender[message]()
+		s := newTestSink(&mockPublisher{})
+		s.defaultTopic = ""
+		s.batchSender = mockBatchSender
+
+		doneChan := make(chan struct{})
+		go func() {
+			defer close(doneChan)
+			require.Equal(t, []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, testCommitPosition),
+			}, mockBatchSender.GetWALMessages())
+		}()
+
+		err := s.ProcessWALEvent(context.Background(), &wal.Event{Data: newTestData("orders", 1), CommitPosition: testCommitPosition})
+		require.NoError(t, err)
+		mockBatchSender.Close()
+		<-doneChan
+	})
+}
+
+func TestPubSubSink_sendBatch(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	testMessages := []message{
+		newTestMessage(t, "users", newTestData("users", 1), "1"),
+		newTestMessage(t, "default", newTestData("orders", 1), "1"),
+		newTestMessage(t, "users", newTestData("users", 2), "2"),
+	}
+
+	tests := []struct {
+		name      string
+		batch     *batch.Batch[message]
+		publishFn func(call uint, topic string, msgs []*publishMessage) ([]*publishMessage, error)
+
+		wantMessages  map[string][]string
+		wantPositions []wal.CommitPosition
+		wantErr       error
+	}{
+		{
+			name:  "ok",
+			batch: batch.NewBatch(testMessages, []wal.CommitPosition{"0/1", "0/2"}),
+			wantMessages: map[string][]string{
+				"users":   {"1", "2"},
+				"default": {"1"},
+			},
+			wantPositions: []wal.CommitPosition{"0/1", "0/2"},
+		},
+		{
+			name:          "ok - keep alive",
+			batch:         batch.NewBatch([]message{}, []wal.CommitPosition{"0/1"}),
+			wantPositions: []wal.CommitPosition{"0/1"},
+		},
+		{
+			name:  "ok - transient error retried",
+			batch: batch.NewBatch(testMessages[:1], []wal.CommitPosition{"0/1"}),
+			publishFn: func(call uint, _ string, msgs []*publishMessage) ([]*publishMessage, error) {
+				if call == 1 {
+					return msgs, status.Error(codes.Unavailable, "unavailable")
+				}
+				return nil, nil
+			},
+			wantMessages:  map[string][]string{"users": {"1"}},
+			wantPositions: []wal.CommitPosition{"0/1"},
+		},
+		{
+			name:  "ok - only failed messages retried",
+			batch: batch.NewBatch([]message{testMessages[0], testMessages[2]}, []wal.CommitPosition{"0/1"}),
+			publishFn: func(call uint, _ string, msgs []*publishMessage) ([]*publishMessage, error) {
+				if call == 1 {
+					require.Len(t, msgs, 2)
+					return msgs[1:], pubsub.ErrPublishingPaused{OrderingKey: "2"}
+				}
+				require.Len(t, msgs, 1)
+				return nil, nil
+			},
+			wantMessages:  map[string][]string{"users": {"1", "2"}},
+			wantPositions: []wal.CommitPosition{"0/1"},
+		},
+		{
+			name:  "error - permanent error",
+			batch: batch.NewBatch(testMessages[:1], []wal.CommitPosition{"0/1"}),
+			publishFn: func(call uint, _ string, msgs []*publishMessage) ([]*publishMessage, error) {
+				if call > 1 {
+					return msgs, errors.New("unexpected retry")
+				}
+				return msgs, status.Error(codes.NotFound, "topic not found")
+			},
+			wantErr: backoff.ErrPermanent,
+		},
+		{
+			name:  "error - retries exhausted",
+			batch: batch.NewBatch(testMessages[:1], []wal.CommitPosition{"0/1"}),
+			publishFn: func(_ uint, _ string, msgs []*publishMessage) ([]*publishMessage, error) {
+				return msgs, errTest
+			},
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			publisher := &mockPublisher{publishFn: tc.publishFn}
+			s := newTestSink(publisher)
+			var gotPositions []wal.CommitPosition
+			s.checkpointer = func(_ context.Context, positions []wal.CommitPosition) error {
+				gotPositions = positions
+				return nil
+			}
+
+			err := s.sendBatch(context.Background(), tc.batch)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantMessages, publisher.messages)
+			require.Equal(t, tc.wantPositions, gotPositions)
+		})
+	}
+}
+
+func TestPubSubSink_Ping(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name       string
+		getTopicFn func(topic string) error
+
+		wantTopics []string
+		wantErr    error
+	}{
+		{
+			name:       "ok",
+			wantTopics: []string{"default", "users"},
+		},
+		{
+			name: "error - topic not accessible",
+			getTopicFn: func(topic string) error {
+				if topic == "users" {
+					return errTest
+				}
+				return nil
+			},
+			wantTopics: []string{"default", "users"},
+			wantErr:    errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotTopics := []string{}
+			s := &PubSubSink{
+				publisher: &mockPublisher{
+					getTopicFn: func(topic string) error {
+						gotTopics = append(gotTopics, topic)
+						if tc.getTopicFn != nil {
+							return tc.getTopicFn(topic)
+						}
+						return nil
+					},
+				},
+				defaultTopic: "default",
+				tableTopics: map[string]string{
+					"public.users":  "users",
+					"public.orders": "default",
+				},
+			}
+
+			err := s.Ping(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantTopics, gotTopics)
+		})
+	}
+}
+
+func TestOrderingKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		data *wal.Data
+
+		wantKey string
+	}{
+		{
+			name:    "primary key",
+			data:    newTestData("users", 42),
+			wantKey: "42",
+		},
+		{
+			name: "composite primary key",
+			data: &wal.Data{
+				Columns: []wal.Column{
+					{ID: "id-1", Name: "tenant", Value: "acme"},
+					{ID: "id-2", Name: "id", Value: float64(7)},
+					{ID: "id-3", Name: "name", Value: "alice"},
+				},
+				Metadata: wal.Metadata{InternalColIDs: []string{"id-1", "id-2"}},
+			},
+			wantKey: "acme|7",
+		},
+		{
+			name: "delete event",
+			data: &wal.Data{
+				Action:   "D",
+				Identity: []wal.Column{{ID: "id-1", Name: "id", Value: float64(42)}},
+				Metadata: wal.Metadata{InternalColIDs: []string{"id-1"}},
+			},
+			wantKey: "42",
+		},
+		{
+			name: "replica identity",
+			data: &wal.Data{
+				Identity: []wal.Column{{Name: "id", Value: float64(42)}},
+			},
+			wantKey: "42",
+		},
+		{
+			name:    "no primary key",
+			data:    &wal.Data{Columns: []wal.Column{{Name: "name", Value: "alice"}}},
+			wantKey: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantKey, orderingKey(tc.data))
+		})
+	}
+}
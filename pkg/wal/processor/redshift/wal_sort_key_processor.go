@@ -0,0 +1,467 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package redshift
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// SortKeyProcessor is a decorator around a wal processor that buffers the
+// insert events of the configured tables within a time window, and sends them
+// to the wrapped processor sorted by the table sort key column. Columnar
+// stores like Redshift compress and scan the data more efficiently when rows
+// are inserted in sort key order.
+//
+// Events that can't be reordered (updates, deletes and truncates of the
+// configured tables, and schema changes) flush the buffer before being
+// processed. Events for other tables are not delayed. The inserts are only
+// sorted within a window, so the ones with a sort key lower than the keys
+// emitted in a previous window are sent out of order. A warning can be logged
+// for them, for tables whose sort key is expected to increase.
+type SortKeyProcessor struct {
+	logger    loglib.Logger
+	processor processor.Processor
+	clock     func() time.Time
+
+	sortKeys          map[string]string
+	window            time.Duration
+	maxBufferedEvents int
+	warnOutOfOrder    bool
+
+	// mutex guards the buffer state, and serialises the calls to the wrapped
+	// processor
+	mutex       sync.Mutex
+	buffer      []*wal.Event
+	bufferStart time.Time
+	// firstPosition is the commit position of the first buffered event, and
+	// lastPosition the latest commit position received since. They're used to
+	// make sure the positions sent to the wrapped processor never move past
+	// the buffered events.
+	firstPosition wal.CommitPosition
+	lastPosition  wal.CommitPosition
+	// watermarks keeps the highest sort key emitted per table, only tracked
+	// when the out of order warning is enabled
+	watermarks map[string]any
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type Config struct {
+	// Tables maps the table names to the column used as their sort key.
+	// Tables should be schema qualified. If no schema is provided, the public
+	// schema will be assumed.
+	Tables map[string]string
+	// Window is the maximum time the insert events are buffered before being
+	// sent sorted. Defaults to 1s.
+	Window time.Duration
+	// MaxBufferedEvents is the maximum number of events buffered. When
+	// reached, the buffer is sent sorted regardless of the window. Defaults
+	// to 10000.
+	MaxBufferedEvents int
+	// WarnOutOfOrder logs a warning, at most once per window and table, when
+	// the inserts of a table have a sort key lower than the keys emitted in a
+	// previous window. Useful for tables whose sort key is expected to
+	// increase, such as a creation timestamp. Defaults to false.
+	WarnOutOfOrder bool
+}
+
+type Option func(s *SortKeyProcessor)
+
+const (
+	defaultWindow            = time.Second
+	defaultMaxBufferedEvents = 10000
+
+	publicSchema = "public"
+)
+
+var errMissingSortKeyTables = errors.New("missing sort key tables configuration")
+
+// New will return a sort key processor wrapper around the processor on input.
+// The buffered events are flushed when the window expires, even if no new
+// events are received, until the processor is closed.
+func New(cfg *Config, p processor.Processor, opts ...Option) (*SortKeyProcessor, error) {
+	s, err := newSortKeyProcessor(cfg, p)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	flushCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.flushOnWindow(flushCtx)
+	}()
+
+	return s, nil
+}
+
+func newSortKeyProcessor(cfg *Config, p processor.Processor) (*SortKeyProcessor, error) {
+	if len(cfg.Tables) == 0 {
+		return nil, errMissingSortKeyTables
+	}
+
+	sortKeys := make(map[string]string, len(cfg.Tables))
+	for table, column := range cfg.Tables {
+		if column == "" {
+			return nil, fmt.Errorf("missing sort key column for table %s", table)
+		}
+		sortKeys[qualifiedTableName(table)] = column
+	}
+
+	return &SortKeyProcessor{
+		logger:            loglib.NewNoopLogger(),
+		processor:         p,
+		clock:             time.Now,
+		sortKeys:          sortKeys,
+		window:            cfg.window(),
+		maxBufferedEvents: cfg.maxBufferedEvents(),
+		warnOutOfOrder:    cfg.WarnOutOfOrder,
+		watermarks:        map[string]any{},
+	}, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *SortKeyProcessor) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_sort_key_processor",
+		})
+	}
+}
+
+// ProcessWALEvent buffers the insert events of the configured tables, and
+// sends any other event to the wrapped processor, flushing the buffer first
+// if the event can't be reordered.
+func (s *SortKeyProcessor) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.windowExpired() {
+		if err := s.flushBuffer(ctx); err != nil {
+			return err
+		}
+	}
+
+	data := event.Data
+	switch {
+	case data == nil:
+		return s.processImmediately(ctx, event)
+	case processor.IsSchemaLogEvent(data):
+		// schema changes could affect the buffered tables
+		if err := s.flushBuffer(ctx); err != nil {
+			return err
+		}
+		return s.processor.ProcessWALEvent(ctx, event)
+	}
+
+	_, found := s.sortKeys[data.Schema+"."+data.Table]
+	switch {
+	case !found:
+		return s.processImmediately(ctx, event)
+	case !data.IsInsert():
+		if err := s.flushBuffer(ctx); err != nil {
+			return err
+		}
+		return s.processor.ProcessWALEvent(ctx, event)
+	}
+
+	if len(s.buffer) == 0 {
+		s.bufferStart = s.clock()
+		s.firstPosition = event.CommitPosition
+	}
+	s.buffer = append(s.buffer, event)
+	s.lastPosition = event.CommitPosition
+
+	if len(s.buffer) >= s.maxBufferedEvents {
+		return s.flushBuffer(ctx)
+	}
+	return nil
+}
+
+func (s *SortKeyProcessor) Name() string {
+	return s.processor.Name()
+}
+
+// Close stops the window flushing and sends any remaining buffered events to
+// the wrapped processor before closing it.
+func (s *SortKeyProcessor) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+
+	s.mutex.Lock()
+	err := s.flushBuffer(context.Background())
+	s.mutex.Unlock()
+
+	return errors.Join(err, s.processor.Close())
+}
+
+func (s *SortKeyProcessor) Ping(ctx context.Context) error {
+	return s.processor.Ping(ctx)
+}
+
+func (s *SortKeyProcessor) flushOnWindow(ctx context.Context) {
+	ticker := time.NewTicker(s.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			if s.windowExpired() {
+				// if the flush fails, the remaining events are kept in the
+				// buffer and the error is returned by the next ProcessWALEvent
+				// call
+				if err := s.flushBuffer(ctx); err != nil && !errors.Is(err, context.Canceled) {
+					s.logger.Error(err, "flushing sort key window")
+				}
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// processImmediately sends the event on input to the wrapped processor
+// without buffering it. If there are buffered events, the event commit
+// position is replaced by the first buffered position, so that it doesn't
+// checkpoint past them. Must be called with the mutex held.
+func (s *SortKeyProcessor) processImmediately(ctx context.Context, event *wal.Event) error {
+	if len(s.buffer) > 0 && event.CommitPosition != "" {
+		s.lastPosition = event.CommitPosition
+		event.CommitPosition = s.firstPosition
+	}
+	return s.processor.ProcessWALEvent(ctx, event)
+}
+
+// flushBuffer sends the buffered events to the wrapped processor, grouped by
+// table in order of arrival and sorted by their sort key. All the events but
+// the last one are sent with the first buffered commit position, and the last
+// one with the latest position received, so that the position is only
+// checkpointed once all the buffered events have been processed. Must be
+// called with the mutex held.
+func (s *SortKeyProcessor) flushBuffer(ctx context.Context) error {
+	if len(s.buffer) == 0 {
+		return nil
+	}
+
+	s.sortBuffer()
+	if s.warnOutOfOrder {
+		s.warnOutOfOrderEvents()
+	}
+	for len(s.buffer) > 0 {
+		event := s.buffer[0]
+		if event.CommitPosition != "" {
+			event.CommitPosition = s.firstPosition
+			if len(s.buffer) == 1 {
+				event.CommitPosition = s.lastPosition
+			}
+		}
+		if err := s.processor.ProcessWALEvent(ctx, event); err != nil {
+			return fmt.Errorf("flushing sort key buffer: %w", err)
+		}
+		if s.warnOutOfOrder {
+			s.updateWatermark(event.Data)
+		}
+		s.buffer[0] = nil
+		s.buffer = s.buffer[1:]
+	}
+	s.buffer = nil
+	return nil
+}
+
+func (s *SortKeyProcessor) sortBuffer() {
+	tableOrder := map[string]int{}
+	for _, event := range s.buffer {
+		table := event.Data.Schema + "." + event.Data.Table
+		if _, found := tableOrder[table]; !found {
+			tableOrder[table] = len(tableOrder)
+		}
+	}
+
+	slices.SortStableFunc(s.buffer, func(a, b *wal.Event) int {
+		tableA := a.Data.Schema + "." + a.Data.Table
+		tableB := b.Data.Schema + "." + b.Data.Table
+		if tableA != tableB {
+			return tableOrder[tableA] - tableOrder[tableB]
+		}
+		sortKey := s.sortKeys[tableA]
+		return compareSortKeys(columnValue(a.Data, sortKey), columnValue(b.Data, sortKey))
+	})
+}
+
+// warnOutOfOrderEvents logs a warning for each table with buffered events
+// whose sort key is lower than the keys emitted in previous windows. Must be
+// called with the mutex held, once the buffer is sorted.
+func (s *SortKeyProcessor) warnOutOfOrderEvents() {
+	tables := []string{}
+	outOfOrder := map[string]int{}
+	lowest := map[string]any{}
+	for _, event := range s.buffer {
+		table := event.Data.Schema + "." + event.Data.Table
+		watermark, found := s.watermarks[table]
+		if !found {
+			continue
+		}
+		value := columnValue(event.Data, s.sortKeys[table])
+		if value == nil || compareSortKeys(value, watermark) >= 0 {
+			continue
+		}
+		// the buffer is sorted, so the first one has the lowest sort key
+		if outOfOrder[table] == 0 {
+			tables = append(tables, table)
+			lowest[table] = value
+		}
+		outOfOrder[table]++
+	}
+
+	for _, table := range tables {
+		s.logger.Warn(nil, "insert events have a sort key lower than the keys already emitted, processing out of order", loglib.Fields{
+			"table":         table,
+			"sort_key":      s.sortKeys[table],
+			"events":        outOfOrder[table],
+			"lowest_value":  lowest[table],
+			"last_sort_key": s.watermarks[table],
+		})
+	}
+}
+
+func (s *SortKeyProcessor) updateWatermark(data *wal.Data) {
+	table := data.Schema + "." + data.Table
+	value := columnValue(data, s.sortKeys[table])
+	if value == nil {
+		return
+	}
+	if watermark, found := s.watermarks[table]; !found || compareSortKeys(value, watermark) > 0 {
+		s.watermarks[table] = value
+	}
+}
+
+func (s *SortKeyProcessor) windowExpired() bool {
+	return len(s.buffer) > 0 && s.clock().Sub(s.bufferStart) >= s.window
+}
+
+func columnValue(data *wal.Data, name string) any {
+	for _, col := range data.Columns {
+		if col.Name == name {
+			return col.Value
+		}
+	}
+	return nil
+}
+
+// compareSortKeys compares the sort key values on input. Numbers are compared
+// numerically and times chronologically, while any other values are compared
+// by their text representation. Null values are sorted last, like postgres
+// does for ascending order.
+func compareSortKeys(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1
+			case fa > fb:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Compare(tb)
+		}
+	}
+
+	return strings.Compare(toString(a), toString(b))
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
+
+func toString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func qualifiedTableName(table string) string {
+	if !strings.Contains(table, ".") {
+		return publicSchema + "." + table
+	}
+	return table
+}
+
+func (c *Config) window() time.Duration {
+	if c.Window > 0 {
+		return c.Window
+	}
+	return defaultWindow
+}
+
+func (c *Config) maxBufferedEvents() int {
+	if c.MaxBufferedEvents > 0 {
+		return c.MaxBufferedEvents
+	}
+	return defaultMaxBufferedEvents
+}
@@ -0,0 +1,357 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package redshift
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+type processedEvent struct {
+	table    string
+	action   string
+	sortKey  any
+	position wal.CommitPosition
+}
+
+type testClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestEvent(action, table string, sortKey any, position string) *wal.Event {
+	return &wal.Event{
+		Data: &wal.Data{
+			Action: action,
+			Schema: "public",
+			Table:  table,
+			Columns: []wal.Column{
+				{Name: "id", Type: "integer", Value: 1},
+				{Name: "created_at", Type: "bigint", Value: sortKey},
+			},
+		},
+		CommitPosition: wal.CommitPosition(position),
+	}
+}
+
+type mockLogger struct {
+	loglib.NoopLogger
+	warnings []loglib.Fields
+}
+
+func (m *mockLogger) Warn(_ error, _ string, fields ...loglib.Fields) {
+	m.warnings = append(m.warnings, fields...)
+}
+
+func newTestProcessor(t *testing.T, processErr error) (*SortKeyProcessor, *testClock, *[]processedEvent) {
+	processed := []processedEvent{}
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s, err := newSortKeyProcessor(&Config{
+		Tables:            map[string]string{"events": "created_at"},
+		Window:            time.Minute,
+		MaxBufferedEvents: 4,
+	}, &mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+			if processErr != nil {
+				return processErr
+			}
+			e := processedEvent{position: event.CommitPosition}
+			if event.Data != nil {
+				e.table = event.Data.Table
+				e.action = event.Data.Action
+				e.sortKey = columnValue(event.Data, "created_at")
+			}
+			processed = append(processed, e)
+			return nil
+		},
+		CloseFn: func() error { return nil },
+	})
+	require.NoError(t, err)
+	s.clock = clock.Now
+	return s, clock, &processed
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+
+		s, err := New(&Config{
+			Tables: map[string]string{"events": "created_at", "other.logs": "id"},
+		}, &mocks.Processor{CloseFn: func() error { return nil }})
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"public.events": "created_at", "other.logs": "id"}, s.sortKeys)
+		require.Equal(t, defaultWindow, s.window)
+		require.Equal(t, defaultMaxBufferedEvents, s.maxBufferedEvents)
+		require.NoError(t, s.Close())
+	})
+
+	t.Run("error - missing tables", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := New(&Config{}, &mocks.Processor{})
+		require.ErrorIs(t, err, errMissingSortKeyTables)
+	})
+
+	t.Run("error - missing sort key column", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := New(&Config{Tables: map[string]string{"events": ""}}, &mocks.Processor{})
+		require.ErrorContains(t, err, "missing sort key column for table events")
+	})
+}
+
+func TestSortKeyProcessor_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	t.Run("inserts sorted when the window expires", func(t *testing.T) {
+		t.Parallel()
+
+		s, clock, processed := newTestProcessor(t, nil)
+		ctx := context.Background()
+
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 3, "0/1")))
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", nil, "0/2")))
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 1, "0/3")))
+		require.Empty(t, *processed)
+
+		clock.advance(time.Minute)
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 5, "0/4")))
+		require.Equal(t, []processedEvent{
+			{table: "events", action: "I", sortKey: 1, position: "0/1"},
+			{table: "events", action: "I", sortKey: 3, position: "0/1"},
+			{table: "events", action: "I", sortKey: nil, position: "0/3"},
+		}, *processed)
+		require.Len(t, s.buffer, 1)
+	})
+
+	t.Run("inserts sorted when the buffer is full", func(t *testing.T) {
+		t.Parallel()
+
+		s, _, processed := newTestProcessor(t, nil)
+		ctx := context.Background()
+
+		for i, key := range []int{4, 2, 3, 1} {
+			require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", key, fmt.Sprintf("0/%d", i+1))))
+		}
+		require.Equal(t, []processedEvent{
+			{table: "events", action: "I", sortKey: 1, position: "0/1"},
+			{table: "events", action: "I", sortKey: 2, position: "0/1"},
+			{table: "events", action: "I", sortKey: 3, position: "0/1"},
+			{table: "events", action: "I", sortKey: 4, position: "0/4"},
+		}, *processed)
+		require.Empty(t, s.buffer)
+	})
+
+	t.Run("update flushes the buffer", func(t *testing.T) {
+		t.Parallel()
+
+		s, _, processed := newTestProcessor(t, nil)
+		ctx := context.Background()
+
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 2, "0/1")))
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 1, "0/2")))
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("U", "events", 1, "0/3")))
+		require.Equal(t, []processedEvent{
+			{table: "events", action: "I", sortKey: 1, position: "0/1"},
+			{table: "events", action: "I", sortKey: 2, position: "0/2"},
+			{table: "events", action: "U", sortKey: 1, position: "0/3"},
+		}, *processed)
+	})
+
+	t.Run("schema log event flushes the buffer", func(t *testing.T) {
+		t.Parallel()
+
+		s, _, processed := newTestProcessor(t, nil)
+		ctx := context.Background()
+
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 2, "0/1")))
+		schemaLogEvent := &wal.Event{
+			Data:           &wal.Data{Action: "I", Schema: schemalog.SchemaName, Table: schemalog.TableName},
+			CommitPosition: "0/2",
+		}
+		require.NoError(t, s.ProcessWALEvent(ctx, schemaLogEvent))
+		require.Equal(t, []processedEvent{
+			{table: "events", action: "I", sortKey: 2, position: "0/1"},
+			{table: schemalog.TableName, action: "I", position: "0/2"},
+		}, *processed)
+	})
+
+	t.Run("other events are not delayed past the buffered positions", func(t *testing.T) {
+		t.Parallel()
+
+		s, clock, processed := newTestProcessor(t, nil)
+		ctx := context.Background()
+
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 2, "0/1")))
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("U", "other", nil, "0/2")))
+		require.NoError(t, s.ProcessWALEvent(ctx, &wal.Event{CommitPosition: "0/3"}))
+		require.Equal(t, []processedEvent{
+			{table: "other", action: "U", position: "0/1"},
+			{position: "0/1"},
+		}, *processed)
+
+		clock.advance(time.Minute)
+		require.NoError(t, s.ProcessWALEvent(ctx, &wal.Event{CommitPosition: "0/4"}))
+		require.Equal(t, []processedEvent{
+			{table: "other", action: "U", position: "0/1"},
+			{position: "0/1"},
+			{table: "events", action: "I", sortKey: 2, position: "0/3"},
+			{position: "0/4"},
+		}, *processed)
+	})
+
+	t.Run("out of window inserts buffered", func(t *testing.T) {
+		t.Parallel()
+
+		s, clock, processed := newTestProcessor(t, nil)
+		logger := &mockLogger{}
+		s.logger = logger
+		ctx := context.Background()
+
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 5, "0/1")))
+		clock.advance(time.Minute)
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 6, "0/2")))
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 3, "0/3")))
+		require.Equal(t, []processedEvent{
+			{table: "events", action: "I", sortKey: 5, position: "0/1"},
+		}, *processed)
+
+		require.NoError(t, s.Close())
+		require.Equal(t, []processedEvent{
+			{table: "events", action: "I", sortKey: 5, position: "0/1"},
+			{table: "events", action: "I", sortKey: 3, position: "0/2"},
+			{table: "events", action: "I", sortKey: 6, position: "0/3"},
+		}, *processed)
+		require.Empty(t, logger.warnings)
+	})
+
+	t.Run("out of order warning logged once per window", func(t *testing.T) {
+		t.Parallel()
+
+		s, clock, processed := newTestProcessor(t, nil)
+		logger := &mockLogger{}
+		s.logger = logger
+		s.warnOutOfOrder = true
+		ctx := context.Background()
+
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 5, "0/1")))
+		clock.advance(time.Minute)
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 2, "0/2")))
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 6, "0/3")))
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 3, "0/4")))
+		require.Empty(t, logger.warnings)
+
+		require.NoError(t, s.Close())
+		require.Equal(t, []processedEvent{
+			{table: "events", action: "I", sortKey: 5, position: "0/1"},
+			{table: "events", action: "I", sortKey: 2, position: "0/2"},
+			{table: "events", action: "I", sortKey: 3, position: "0/2"},
+			{table: "events", action: "I", sortKey: 6, position: "0/4"},
+		}, *processed)
+		require.Equal(t, []loglib.Fields{
+			{
+				"table":         "public.events",
+				"sort_key":      "created_at",
+				"events":        2,
+				"lowest_value":  2,
+				"last_sort_key": 5,
+			},
+		}, logger.warnings)
+	})
+
+	t.Run("error - flushing buffer keeps the events", func(t *testing.T) {
+		t.Parallel()
+
+		s, _, _ := newTestProcessor(t, errTest)
+		ctx := context.Background()
+
+		require.NoError(t, s.ProcessWALEvent(ctx, newTestEvent("I", "events", 1, "0/1")))
+		err := s.ProcessWALEvent(ctx, newTestEvent("D", "events", nil, "0/2"))
+		require.ErrorIs(t, err, errTest)
+		require.Len(t, s.buffer, 1)
+	})
+}
+
+func TestSortKeyProcessor_flushOnWindow(t *testing.T) {
+	t.Parallel()
+
+	processedChan := make(chan *wal.Event, 2)
+	s, err := New(&Config{
+		Tables: map[string]string{"events": "created_at"},
+		Window: 10 * time.Millisecond,
+	}, &mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+			processedChan <- event
+			return nil
+		},
+		CloseFn: func() error { return nil },
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.ProcessWALEvent(context.Background(), newTestEvent("I", "events", 2, "0/1")))
+	require.NoError(t, s.ProcessWALEvent(context.Background(), newTestEvent("I", "events", 1, "0/2")))
+
+	for _, want := range []any{1, 2} {
+		select {
+		case event := <-processedChan:
+			require.Equal(t, want, columnValue(event.Data, "created_at"))
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for the window flush")
+		}
+	}
+}
+
+func TestCompareSortKeys(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		a, b any
+
+		want int
+	}{
+		{name: "numbers", a: int64(2), b: 10.5, want: -1},
+		{name: "equal numbers", a: int32(2), b: float64(2), want: 0},
+		{name: "strings", a: "2024-01-02 00:00:00", b: "2024-01-01 00:00:00", want: 1},
+		{name: "times", a: now, b: now.Add(time.Second), want: -1},
+		{name: "null last", a: nil, b: 1, want: 1},
+		{name: "both null", a: nil, b: nil, want: 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.want, compareSortKeys(tc.a, tc.b))
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package rewind
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// RewindProcessor is a decorator around a wal processor that can re-process
+// on demand the events archived by a sink within an LSN range, without
+// resetting the replication slot. It can be used to re-drive the events
+// through the pipeline after fixing a bug in a sink. Live events are sent to
+// the wrapped processor unchanged.
+//
+// The replayed events are sent with a replay context in the context (see
+// wal.IsReplay), and without commit position, so that the replay progress is
+// never checkpointed and can't be confused with the live progress.
+type RewindProcessor struct {
+	logger    loglib.Logger
+	processor processor.Processor
+	archive   Archive
+	lsnParser replication.LSNParser
+
+	rewinding atomic.Bool
+}
+
+// Archive provides the events archived by a sink, such as the file log or
+// object store sinks.
+type Archive interface {
+	// ReadEvents calls the function on input with every archived event, in
+	// the order they were written, until it returns an error.
+	ReadEvents(ctx context.Context, fn func(*wal.Data) error) error
+}
+
+type Option func(r *RewindProcessor)
+
+var (
+	errInvalidLSN       = errors.New("invalid rewind LSN")
+	errInvalidLSNRange  = errors.New("rewind start LSN must be lower than or equal to the end LSN")
+	errRewindInProgress = errors.New("a rewind is already in progress")
+)
+
+// New will return a rewind processor wrapper around the processor on input,
+// which replays the events from the archive on input.
+func New(p processor.Processor, archive Archive, opts ...Option) *RewindProcessor {
+	r := &RewindProcessor{
+		logger:    loglib.NewNoopLogger(),
+		processor: p,
+		archive:   archive,
+		lsnParser: pgreplication.NewLSNParser(),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(r *RewindProcessor) {
+		r.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_rewind_processor",
+		})
+	}
+}
+
+// ProcessWALEvent sends the live events to the wrapped processor.
+func (r *RewindProcessor) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	return r.processor.ProcessWALEvent(ctx, event)
+}
+
+// Rewind re-processes the archived events with an LSN within the inclusive
+// range on input through the wrapped processor. It returns once all the
+// events in the range have been sent, and fails if another rewind is in
+// progress. Archived events with an invalid LSN are skipped.
+func (r *RewindProcessor) Rewind(ctx context.Context, startLSN, endLSN string) error {
+	start, err := r.lsnParser.FromString(startLSN)
+	if err != nil {
+		return fmt.Errorf("%w %q: %w", errInvalidLSN, startLSN, err)
+	}
+	end, err := r.lsnParser.FromString(endLSN)
+	if err != nil {
+		return fmt.Errorf("%w %q: %w", errInvalidLSN, endLSN, err)
+	}
+	if start > end {
+		return errInvalidLSNRange
+	}
+
+	if !r.rewinding.CompareAndSwap(false, true) {
+		return errRewindInProgress
+	}
+	defer r.rewinding.Store(false)
+
+	logFields := loglib.Fields{
+		"start_lsn": startLSN,
+		"end_lsn":   endLSN,
+	}
+	r.logger.Info("rewinding archived events", logFields)
+
+	replayCtx := wal.WithReplayContext(ctx, wal.ReplayContext{
+		IsReplay: true,
+		StartLSN: startLSN,
+		EndLSN:   endLSN,
+	})
+	replayed, skipped := 0, 0
+	err = r.archive.ReadEvents(replayCtx, func(data *wal.Data) error {
+		lsn, err := r.lsnParser.FromString(data.LSN)
+		if err != nil {
+			skipped++
+			return nil
+		}
+		if lsn < start || lsn > end {
+			return nil
+		}
+
+		if err := r.processor.ProcessWALEvent(replayCtx, &wal.Event{Data: data}); err != nil {
+			return fmt.Errorf("replaying event with LSN %s: %w", data.LSN, err)
+		}
+		replayed++
+		return nil
+	})
+
+	logFields["replayed_events"] = replayed
+	if skipped > 0 {
+		logFields["skipped_events"] = skipped
+		r.logger.Warn(nil, "skipped archived events with invalid LSN", logFields)
+	}
+	if err != nil {
+		r.logger.Error(err, "rewinding archived events", logFields)
+		return fmt.Errorf("rewinding archived events: %w", err)
+	}
+
+	r.logger.Info("archived events rewound", logFields)
+	return nil
+}
+
+func (r *RewindProcessor) Name() string {
+	return r.processor.Name()
+}
+
+func (r *RewindProcessor) Close() error {
+	return r.processor.Close()
+}
+
+func (r *RewindProcessor) Ping(ctx context.Context) error {
+	return r.processor.Ping(ctx)
+}
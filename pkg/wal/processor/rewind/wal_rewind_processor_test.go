@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package rewind
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/filelog"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+	"github.com/xataio/pgstream/pkg/wal/processor/objectstore"
+)
+
+type mockArchive struct {
+	events []*wal.Data
+	err    error
+}
+
+func (m *mockArchive) ReadEvents(ctx context.Context, fn func(*wal.Data) error) error {
+	for _, event := range m.events {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return m.err
+}
+
+var (
+	_ Archive = (*filelog.ArchiveReader)(nil)
+	_ Archive = (*objectstore.ArchiveReader)(nil)
+)
+
+func TestRewindProcessor_Rewind(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	archive := &mockArchive{
+		events: []*wal.Data{
+			{Action: "I", LSN: "0/10"},
+			{Action: "I", LSN: "0/20"},
+			{Action: "I", LSN: "invalid"},
+			{Action: "U", LSN: "0/30"},
+			{Action: "D", LSN: "0/40"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		archive    *mockArchive
+		startLSN   string
+		endLSN     string
+		processErr error
+
+		wantLSNs []string
+		wantErr  error
+	}{
+		{
+			name:     "ok - inclusive range",
+			archive:  archive,
+			startLSN: "0/20",
+			endLSN:   "0/30",
+			wantLSNs: []string{"0/20", "0/30"},
+		},
+		{
+			name:     "ok - single LSN",
+			archive:  archive,
+			startLSN: "0/40",
+			endLSN:   "0/40",
+			wantLSNs: []string{"0/40"},
+		},
+		{
+			name:     "ok - no events in range",
+			archive:  archive,
+			startLSN: "0/50",
+			endLSN:   "0/60",
+			wantLSNs: []string{},
+		},
+		{
+			name:     "error - invalid start LSN",
+			archive:  archive,
+			startLSN: "invalid",
+			endLSN:   "0/60",
+			wantLSNs: []string{},
+			wantErr:  errInvalidLSN,
+		},
+		{
+			name:     "error - invalid range",
+			archive:  archive,
+			startLSN: "0/30",
+			endLSN:   "0/20",
+			wantLSNs: []string{},
+			wantErr:  errInvalidLSNRange,
+		},
+		{
+			name:       "error - processing event",
+			archive:    archive,
+			startLSN:   "0/10",
+			endLSN:     "0/40",
+			processErr: errTest,
+			wantLSNs:   []string{"0/10"},
+			wantErr:    errTest,
+		},
+		{
+			name:     "error - reading archive",
+			archive:  &mockArchive{err: errTest},
+			startLSN: "0/10",
+			endLSN:   "0/40",
+			wantLSNs: []string{},
+			wantErr:  errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			lsns := []string{}
+			r := New(&mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+					rc, found := wal.ReplayContextFromContext(ctx)
+					require.True(t, found)
+					require.Equal(t, wal.ReplayContext{IsReplay: true, StartLSN: tc.startLSN, EndLSN: tc.endLSN}, rc)
+					// replayed events are never checkpointed
+					require.Empty(t, event.CommitPosition)
+
+					lsns = append(lsns, event.Data.LSN)
+					return tc.processErr
+				},
+			}, tc.archive)
+
+			err := r.Rewind(context.Background(), tc.startLSN, tc.endLSN)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantLSNs, lsns)
+		})
+	}
+}
+
+func TestRewindProcessor_Rewind_inProgress(t *testing.T) {
+	t.Parallel()
+
+	var r *RewindProcessor
+	nestedErr := errors.New("nested rewind not attempted")
+	r = New(&mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+			nestedErr = r.Rewind(ctx, "0/10", "0/10")
+			return nil
+		},
+	}, &mockArchive{events: []*wal.Data{{Action: "I", LSN: "0/10"}}})
+
+	require.NoError(t, r.Rewind(context.Background(), "0/10", "0/10"))
+	require.ErrorIs(t, nestedErr, errRewindInProgress)
+
+	// once finished, a new rewind can be started
+	require.NoError(t, r.Rewind(context.Background(), "0/10", "0/10"))
+}
+
+func TestRewindProcessor_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	event := &wal.Event{Data: &wal.Data{Action: "I", LSN: "0/10"}, CommitPosition: "0/10"}
+	r := New(&mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+			require.False(t, wal.IsReplay(ctx))
+			require.Equal(t, event, walEvent)
+			return nil
+		},
+	}, &mockArchive{})
+
+	require.NoError(t, r.ProcessWALEvent(context.Background(), event))
+}
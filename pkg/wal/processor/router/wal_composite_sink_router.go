@@ -0,0 +1,156 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// CompositeSinkRouter is a processor that routes the wal events to a
+// different processor depending on their action, so that for example inserts
+// can be sent to a search index while deletes are sent to a soft delete
+// service. Events for actions without a registered processor are sent to the
+// default processor, or skipped if there's none.
+//
+// Keep alive and schema log events are sent to all processors, since every
+// sink needs to checkpoint their position and keep track of schema changes.
+type CompositeSinkRouter struct {
+	logger           loglib.Logger
+	routes           map[wal.Action]processor.Processor
+	defaultProcessor processor.Processor
+	// processors contains all the unique processors, including the default
+	// one, in a consistent order
+	processors []processor.Processor
+}
+
+type Option func(*CompositeSinkRouter)
+
+var (
+	errMissingRoutes    = errors.New("at least one action route must be provided")
+	errUnsupportedRoute = errors.New("unsupported action route")
+	errNilProcessor     = errors.New("route processor can't be nil")
+)
+
+// NewCompositeSinkRouter returns a router for the action routes on input.
+func NewCompositeSinkRouter(routes map[wal.Action]processor.Processor, opts ...Option) (*CompositeSinkRouter, error) {
+	if len(routes) == 0 {
+		return nil, errMissingRoutes
+	}
+
+	r := &CompositeSinkRouter{
+		logger: loglib.NewNoopLogger(),
+		routes: make(map[wal.Action]processor.Processor, len(routes)),
+	}
+
+	for action, p := range routes {
+		switch action {
+		case wal.ActionInsert, wal.ActionUpdate, wal.ActionDelete, wal.ActionTruncate:
+		default:
+			return nil, fmt.Errorf("%w: %q", errUnsupportedRoute, action)
+		}
+		if p == nil {
+			return nil, fmt.Errorf("%w: %q", errNilProcessor, action)
+		}
+		r.routes[action] = p
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	// iterate the actions in a deterministic order so that the processors
+	// are always called and closed in the same order
+	for _, action := range []wal.Action{wal.ActionInsert, wal.ActionUpdate, wal.ActionDelete, wal.ActionTruncate} {
+		if p, found := r.routes[action]; found {
+			r.addProcessor(p)
+		}
+	}
+	if r.defaultProcessor != nil {
+		r.addProcessor(r.defaultProcessor)
+	}
+
+	return r, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(r *CompositeSinkRouter) {
+		r.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "composite_sink_router",
+		})
+	}
+}
+
+// WithDefaultProcessor sets the processor for the events with an action
+// without a registered processor, such as a no-op or a dead letter queue
+// processor.
+func WithDefaultProcessor(p processor.Processor) Option {
+	return func(r *CompositeSinkRouter) {
+		r.defaultProcessor = p
+	}
+}
+
+// ProcessWALEvent routes the event on input to the processor registered for
+// its action.
+func (r *CompositeSinkRouter) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	if event.Data == nil || processor.IsSchemaLogEvent(event.Data) {
+		for _, p := range r.processors {
+			if err := p.ProcessWALEvent(ctx, event); err != nil {
+				return fmt.Errorf("%s: %w", p.Name(), err)
+			}
+		}
+		return nil
+	}
+
+	p, found := r.routes[wal.Action(event.Data.Action)]
+	if !found {
+		if r.defaultProcessor == nil {
+			r.logger.Debug("composite sink router: no processor for event action, skipping", event.LogFields())
+			return nil
+		}
+		p = r.defaultProcessor
+	}
+
+	return p.ProcessWALEvent(ctx, event)
+}
+
+func (r *CompositeSinkRouter) Name() string {
+	return "composite-sink-router"
+}
+
+// Close closes all the processors, returning the combined errors.
+func (r *CompositeSinkRouter) Close() error {
+	var errs error
+	for _, p := range r.processors {
+		if err := p.Close(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("closing %s: %w", p.Name(), err))
+		}
+	}
+	return errs
+}
+
+// Ping checks the connectivity of all the processors, returning the combined
+// errors.
+func (r *CompositeSinkRouter) Ping(ctx context.Context) error {
+	var errs error
+	for _, p := range r.processors {
+		if err := p.Ping(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("pinging %s: %w", p.Name(), err))
+		}
+	}
+	return errs
+}
+
+func (r *CompositeSinkRouter) addProcessor(p processor.Processor) {
+	for _, existing := range r.processors {
+		if existing == p {
+			return
+		}
+	}
+	r.processors = append(r.processors, p)
+}
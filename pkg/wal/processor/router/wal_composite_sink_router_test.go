@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+func newTestEvent(action wal.Action) *wal.Event {
+	return &wal.Event{
+		Data: &wal.Data{
+			Action: string(action),
+			Schema: "public",
+			Table:  "users",
+		},
+		CommitPosition: "0/1",
+	}
+}
+
+func newTestProcessor(err error) *mocks.Processor {
+	return &mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error { return err },
+	}
+}
+
+func TestNewCompositeSinkRouter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		routes map[wal.Action]processor.Processor
+
+		wantErr error
+	}{
+		{
+			name: "ok",
+			routes: map[wal.Action]processor.Processor{
+				wal.ActionInsert: newTestProcessor(nil),
+				wal.ActionDelete: newTestProcessor(nil),
+			},
+		},
+		{
+			name:    "error - no routes",
+			routes:  map[wal.Action]processor.Processor{},
+			wantErr: errMissingRoutes,
+		},
+		{
+			name: "error - unsupported action",
+			routes: map[wal.Action]processor.Processor{
+				wal.Action("X"): newTestProcessor(nil),
+			},
+			wantErr: errUnsupportedRoute,
+		},
+		{
+			name: "error - nil processor",
+			routes: map[wal.Action]processor.Processor{
+				wal.ActionInsert: nil,
+			},
+			wantErr: errNilProcessor,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewCompositeSinkRouter(tc.routes)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestCompositeSinkRouter_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name             string
+		event            *wal.Event
+		insertErr        error
+		withDefault      bool
+		withSharedUpdate bool
+
+		wantInsertCalls  uint
+		wantDeleteCalls  uint
+		wantDefaultCalls uint
+		wantErr          error
+	}{
+		{
+			name:            "insert routed",
+			event:           newTestEvent(wal.ActionInsert),
+			wantInsertCalls: 1,
+		},
+		{
+			name:            "delete routed",
+			event:           newTestEvent(wal.ActionDelete),
+			withDefault:     true,
+			wantDeleteCalls: 1,
+		},
+		{
+			name:             "unrouted action sent to default",
+			event:            newTestEvent(wal.ActionUpdate),
+			withDefault:      true,
+			wantDefaultCalls: 1,
+		},
+		{
+			name:  "unrouted action skipped without default",
+			event: newTestEvent(wal.ActionTruncate),
+		},
+		{
+			name:             "keep alive sent to all processors once",
+			event:            &wal.Event{CommitPosition: "0/1"},
+			withDefault:      true,
+			withSharedUpdate: true,
+			wantInsertCalls:  1,
+			wantDeleteCalls:  1,
+			wantDefaultCalls: 1,
+		},
+		{
+			name: "schema log event sent to all processors",
+			event: &wal.Event{
+				Data: &wal.Data{
+					Action: string(wal.ActionInsert),
+					Schema: schemalog.SchemaName,
+					Table:  schemalog.TableName,
+				},
+			},
+			wantInsertCalls: 1,
+			wantDeleteCalls: 1,
+		},
+		{
+			name:            "error - routed processor",
+			event:           newTestEvent(wal.ActionInsert),
+			insertErr:       errTest,
+			wantInsertCalls: 1,
+			wantErr:         errTest,
+		},
+		{
+			name:            "error - broadcast stops on first error",
+			event:           &wal.Event{CommitPosition: "0/1"},
+			insertErr:       errTest,
+			withDefault:     true,
+			wantInsertCalls: 1,
+			wantErr:         errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			insertProcessor := newTestProcessor(tc.insertErr)
+			deleteProcessor := newTestProcessor(nil)
+			defaultProcessor := newTestProcessor(nil)
+
+			routes := map[wal.Action]processor.Processor{
+				wal.ActionInsert: insertProcessor,
+				wal.ActionDelete: deleteProcessor,
+			}
+			if tc.withSharedUpdate {
+				routes[wal.ActionUpdate] = insertProcessor
+			}
+			opts := []Option{}
+			if tc.withDefault {
+				opts = append(opts, WithDefaultProcessor(defaultProcessor))
+			}
+
+			r, err := NewCompositeSinkRouter(routes, opts...)
+			require.NoError(t, err)
+
+			err = r.ProcessWALEvent(context.Background(), tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantInsertCalls, insertProcessor.GetProcessCalls())
+			require.Equal(t, tc.wantDeleteCalls, deleteProcessor.GetProcessCalls())
+			require.Equal(t, tc.wantDefaultCalls, defaultProcessor.GetProcessCalls())
+		})
+	}
+}
+
+func TestCompositeSinkRouter_Close(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	closeCalls := map[string]uint{}
+	newCloseProcessor := func(name string, err error) *mocks.Processor {
+		return &mocks.Processor{
+			CloseFn: func() error {
+				closeCalls[name]++
+				return err
+			},
+		}
+	}
+
+	shared := newCloseProcessor("shared", nil)
+	failing := newCloseProcessor("failing", errTest)
+	r, err := NewCompositeSinkRouter(map[wal.Action]processor.Processor{
+		wal.ActionInsert: shared,
+		wal.ActionUpdate: shared,
+		wal.ActionDelete: failing,
+	}, WithDefaultProcessor(newCloseProcessor("default", nil)))
+	require.NoError(t, err)
+
+	err = r.Close()
+	require.ErrorIs(t, err, errTest)
+	require.Equal(t, map[string]uint{"shared": 1, "failing": 1, "default": 1}, closeCalls)
+}
+
+func TestCompositeSinkRouter_Ping(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	pingCalls := map[string]uint{}
+	newPingProcessor := func(name string, err error) *mocks.Processor {
+		return &mocks.Processor{
+			PingFn: func(context.Context) error {
+				pingCalls[name]++
+				return err
+			},
+		}
+	}
+
+	shared := newPingProcessor("shared", nil)
+	r, err := NewCompositeSinkRouter(map[wal.Action]processor.Processor{
+		wal.ActionInsert: shared,
+		wal.ActionUpdate: shared,
+		wal.ActionDelete: newPingProcessor("failing", errTest),
+	}, WithDefaultProcessor(newPingProcessor("default", nil)))
+	require.NoError(t, err)
+
+	err = r.Ping(context.Background())
+	require.ErrorIs(t, err, errTest)
+	require.Equal(t, map[string]uint{"shared": 1, "failing": 1, "default": 1}, pingCalls)
+}
@@ -0,0 +1,423 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// FanOutRouter is a processor that dispatches the wal events to every route
+// matching their table and action, so that a single listener can replicate
+// different tables to different targets. Keep alive, commit and schema log
+// events are sent to all routes, since every target needs to checkpoint their
+// position and keep track of schema changes.
+//
+// Each route processor is given its own checkpoint, and a position is only
+// checkpointed once all the routes the event was sent to have checkpointed
+// it, so that no event is acknowledged before every matching target has
+// processed it.
+type FanOutRouter struct {
+	logger     loglib.Logger
+	routes     []*fanOutRoute
+	checkpoint checkpointer.Checkpoint
+	metrics    *fanOutMetrics
+
+	// mutex guards the pending positions of the router and of the routes
+	mutex sync.Mutex
+	seq   uint64
+	// pending are the positions of the events received that haven't been
+	// checkpointed yet, in order
+	pending []pendingPosition
+}
+
+// FanOutConfig is the configuration of the fan out router routes. Routes are
+// evaluated in order, and an event can be sent to more than one route.
+type FanOutConfig struct {
+	Routes []Route
+}
+
+// Route describes the events sent to a route processor.
+type Route struct {
+	// Name identifies the route in the logs and metrics. It must be unique.
+	// Defaults to route_<index>.
+	Name string
+	// Tables sent to the route. Tables should be schema qualified. If no
+	// schema is provided, the public schema will be assumed. Wildcards "*",
+	// glob patterns and regular expressions are supported. Defaults to all
+	// tables.
+	Tables []string
+	// Actions sent to the route. Defaults to all actions.
+	Actions []wal.Action
+	// FailurePolicy defines what happens when the route processor fails to
+	// process an event. Defaults to fail.
+	FailurePolicy FailurePolicy
+}
+
+// FailurePolicy defines how the errors of a route processor are handled.
+type FailurePolicy string
+
+const (
+	// FailurePolicyFail returns the route processor error, stopping the
+	// pipeline.
+	FailurePolicyFail FailurePolicy = "fail"
+	// FailurePolicyIsolate logs the route processor error and skips the event
+	// for that route, so that the other routes keep processing. The skipped
+	// events are considered processed by the route for checkpointing
+	// purposes.
+	FailurePolicyIsolate FailurePolicy = "isolate"
+)
+
+// NewRouteProcessorFn returns the processor for the route at the index on
+// input, which must use the checkpoint provided.
+type NewRouteProcessorFn func(routeIndex int, checkpoint checkpointer.Checkpoint) (processor.Processor, error)
+
+type FanOutOption func(*FanOutRouter)
+
+type fanOutRoute struct {
+	name          string
+	processor     processor.Processor
+	tables        pglib.SchemaTableMap
+	actions       []wal.Action
+	failurePolicy FailurePolicy
+	// pending are the positions sent to the route processor that it hasn't
+	// checkpointed yet, in order
+	pending []pendingPosition
+}
+
+type pendingPosition struct {
+	seq      uint64
+	position wal.CommitPosition
+}
+
+type fanOutMetrics struct {
+	routedEvents metric.Int64Counter
+	routeErrors  metric.Int64Counter
+}
+
+const routeAttributeKey = "route"
+
+var (
+	errMissingFanOutRoutes     = errors.New("fan out router requires at least one route")
+	errDuplicateRouteName      = errors.New("duplicate route name")
+	errInvalidFailurePolicy    = errors.New("route failure policy must be one of 'fail' or 'isolate'")
+	errUnsupportedRouteAction  = errors.New("unsupported route action, must be one of 'I', 'U', 'D' or 'T'")
+	errMissingRouteProcessorFn = errors.New("fan out router requires a route processor constructor")
+)
+
+// NewFanOutRouter returns a router for the routes on input. The route
+// processors are created with newProcessor, and are closed if the router can't
+// be created. The checkpoint on input is called with the positions processed
+// by all the matching routes. It can be nil if the events don't need to be
+// checkpointed.
+func NewFanOutRouter(cfg *FanOutConfig, checkpoint checkpointer.Checkpoint, newProcessor NewRouteProcessorFn, opts ...FanOutOption) (_ *FanOutRouter, err error) {
+	if len(cfg.Routes) == 0 {
+		return nil, errMissingFanOutRoutes
+	}
+	if newProcessor == nil {
+		return nil, errMissingRouteProcessorFn
+	}
+
+	r := &FanOutRouter{
+		logger:     loglib.NewNoopLogger(),
+		checkpoint: checkpoint,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	defer func() {
+		if err != nil {
+			r.Close()
+		}
+	}()
+
+	for i := range cfg.Routes {
+		route, err := newFanOutRoute(i, &cfg.Routes[i])
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(r.routes, func(existing *fanOutRoute) bool { return existing.name == route.name }) {
+			return nil, fmt.Errorf("%w: %s", errDuplicateRouteName, route.name)
+		}
+
+		var routeCheckpoint checkpointer.Checkpoint
+		if checkpoint != nil {
+			routeCheckpoint = r.routeCheckpoint(route)
+		}
+		route.processor, err = newProcessor(i, routeCheckpoint)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", route.name, err)
+		}
+		r.routes = append(r.routes, route)
+	}
+
+	return r, nil
+}
+
+func WithFanOutLogger(l loglib.Logger) FanOutOption {
+	return func(r *FanOutRouter) {
+		r.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "fan_out_router",
+		})
+	}
+}
+
+// WithFanOutInstrumentation exports the number of events sent to each route,
+// and the number of errors returned by each route processor.
+func WithFanOutInstrumentation(instrumentation *otel.Instrumentation) FanOutOption {
+	return func(r *FanOutRouter) {
+		if instrumentation == nil || instrumentation.Meter == nil {
+			return
+		}
+		if err := r.initMetrics(instrumentation.Meter); err != nil {
+			// should never happen
+			panic(err)
+		}
+	}
+}
+
+// ProcessWALEvent sends the event on input to all the matching routes.
+func (r *FanOutRouter) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	routes := r.matchingRoutes(event)
+	seq := r.track(event, routes)
+
+	for _, route := range routes {
+		if r.metrics != nil {
+			r.metrics.routedEvents.Add(ctx, 1, metric.WithAttributes(attribute.String(routeAttributeKey, route.name)))
+		}
+
+		err := route.processor.ProcessWALEvent(ctx, event)
+		if err == nil {
+			continue
+		}
+
+		if r.metrics != nil {
+			r.metrics.routeErrors.Add(ctx, 1, metric.WithAttributes(attribute.String(routeAttributeKey, route.name)))
+		}
+		if route.failurePolicy != FailurePolicyIsolate {
+			return fmt.Errorf("route %s: %w", route.name, err)
+		}
+
+		r.logger.Error(err, "route processor failed, skipping event for route", loglib.Fields{
+			"route":           route.name,
+			"commit_position": event.CommitPosition,
+			"severity":        "DATALOSS",
+		})
+		// the route won't checkpoint the event, so release its position to
+		// not block the checkpointing of the other routes
+		if err := r.release(ctx, route, seq); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *FanOutRouter) Name() string {
+	return "fan-out-router"
+}
+
+// Close closes all the route processors, returning the combined errors.
+func (r *FanOutRouter) Close() error {
+	var errs error
+	for _, route := range r.routes {
+		if err := route.processor.Close(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("closing route %s: %w", route.name, err))
+		}
+	}
+	return errs
+}
+
+// Ping checks the connectivity of all the route processors, returning the
+// combined errors.
+func (r *FanOutRouter) Ping(ctx context.Context) error {
+	var errs error
+	for _, route := range r.routes {
+		if err := route.processor.Ping(ctx); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("pinging route %s: %w", route.name, err))
+		}
+	}
+	return errs
+}
+
+func (r *FanOutRouter) matchingRoutes(event *wal.Event) []*fanOutRoute {
+	if event.Data == nil || processor.IsSchemaLogEvent(event.Data) || wal.Action(event.Data.Action) == wal.ActionCommit {
+		return r.routes
+	}
+
+	routes := make([]*fanOutRoute, 0, len(r.routes))
+	for _, route := range r.routes {
+		if route.matches(event.Data) {
+			routes = append(routes, route)
+		}
+	}
+	if len(routes) == 0 {
+		r.logger.Trace("no route for event, skipping", event.LogFields())
+	}
+	return routes
+}
+
+// track registers the position of the event as pending for the router and
+// the routes on input, and returns its sequence number. The events that
+// don't match any route are checkpointed with the next position checkpointed
+// by the routes.
+func (r *FanOutRouter) track(event *wal.Event, routes []*fanOutRoute) uint64 {
+	if r.checkpoint == nil {
+		return 0
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.seq++
+	pending := pendingPosition{seq: r.seq, position: event.CommitPosition}
+	r.pending = append(r.pending, pending)
+	for _, route := range routes {
+		route.pending = append(route.pending, pending)
+	}
+	return r.seq
+}
+
+// routeCheckpoint returns the checkpoint for the route on input, which
+// releases the positions checkpointed by the route processor.
+func (r *FanOutRouter) routeCheckpoint(route *fanOutRoute) checkpointer.Checkpoint {
+	return func(ctx context.Context, positions []wal.CommitPosition) error {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		// the positions checkpointed cover all the previous positions sent to
+		// the route
+		for _, position := range positions {
+			i := slices.IndexFunc(route.pending, func(p pendingPosition) bool { return p.position == position })
+			if i >= 0 {
+				route.pending = route.pending[i+1:]
+			}
+		}
+		return r.checkpointProcessed(ctx)
+	}
+}
+
+// release removes the pending position of the route with the sequence number
+// on input.
+func (r *FanOutRouter) release(ctx context.Context, route *fanOutRoute, seq uint64) error {
+	if r.checkpoint == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	route.pending = slices.DeleteFunc(route.pending, func(p pendingPosition) bool { return p.seq == seq })
+	return r.checkpointProcessed(ctx)
+}
+
+// checkpointProcessed checkpoints the pending positions that have been
+// processed by all the routes they were sent to. Must be called with the mutex
+// held, so that the positions are checkpointed in order.
+func (r *FanOutRouter) checkpointProcessed(ctx context.Context) error {
+	oldestPending := uint64(math.MaxUint64)
+	for _, route := range r.routes {
+		if len(route.pending) > 0 {
+			oldestPending = min(oldestPending, route.pending[0].seq)
+		}
+	}
+
+	processed := 0
+	for processed < len(r.pending) && r.pending[processed].seq < oldestPending {
+		processed++
+	}
+	if processed == 0 {
+		return nil
+	}
+
+	positions := make([]wal.CommitPosition, 0, processed)
+	for _, p := range r.pending[:processed] {
+		positions = append(positions, p.position)
+	}
+	if err := r.checkpoint(ctx, positions); err != nil {
+		return fmt.Errorf("checkpointing fan out router positions: %w", err)
+	}
+	r.pending = r.pending[processed:]
+	return nil
+}
+
+func (r *FanOutRouter) initMetrics(meter metric.Meter) error {
+	r.metrics = &fanOutMetrics{}
+
+	var err error
+	r.metrics.routedEvents, err = meter.Int64Counter("pgstream.router.route.events",
+		metric.WithUnit("events"),
+		metric.WithDescription("Number of wal events sent to the route processor"))
+	if err != nil {
+		return err
+	}
+
+	r.metrics.routeErrors, err = meter.Int64Counter("pgstream.router.route.errors",
+		metric.WithUnit("errors"),
+		metric.WithDescription("Number of wal events the route processor failed to process"))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func newFanOutRoute(i int, cfg *Route) (*fanOutRoute, error) {
+	route := &fanOutRoute{
+		name:          cfg.Name,
+		failurePolicy: cfg.FailurePolicy,
+		actions:       cfg.Actions,
+	}
+	if route.name == "" {
+		route.name = fmt.Sprintf("route_%d", i)
+	}
+
+	switch route.failurePolicy {
+	case "":
+		route.failurePolicy = FailurePolicyFail
+	case FailurePolicyFail, FailurePolicyIsolate:
+	default:
+		return nil, fmt.Errorf("route %s: %w", route.name, errInvalidFailurePolicy)
+	}
+
+	for _, action := range cfg.Actions {
+		switch action {
+		case wal.ActionInsert, wal.ActionUpdate, wal.ActionDelete, wal.ActionTruncate:
+		default:
+			return nil, fmt.Errorf("route %s: %w: %q", route.name, errUnsupportedRouteAction, action)
+		}
+	}
+
+	if len(cfg.Tables) > 0 {
+		var err error
+		route.tables, err = pglib.NewSchemaTableMap(cfg.Tables)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", route.name, err)
+		}
+	}
+
+	return route, nil
+}
+
+func (r *fanOutRoute) matches(data *wal.Data) bool {
+	if len(r.actions) > 0 && !slices.Contains(r.actions, wal.Action(data.Action)) {
+		return false
+	}
+	return len(r.tables) == 0 || r.tables.ContainsSchemaTable(data.Schema, data.Table)
+}
@@ -0,0 +1,261 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+func TestNewFanOutRouter(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name         string
+		routes       []Route
+		processorErr error
+
+		wantClosed int
+		wantErr    error
+	}{
+		{
+			name: "ok",
+			routes: []Route{
+				{Name: "postgres", Tables: []string{"public.*"}},
+				{Tables: []string{"search.*"}, Actions: []wal.Action{wal.ActionInsert}, FailurePolicy: FailurePolicyIsolate},
+			},
+		},
+		{
+			name:    "error - no routes",
+			routes:  []Route{},
+			wantErr: errMissingFanOutRoutes,
+		},
+		{
+			name: "error - duplicate route name",
+			routes: []Route{
+				{Name: "route_1"},
+				{},
+			},
+			wantClosed: 1,
+			wantErr:    errDuplicateRouteName,
+		},
+		{
+			name:    "error - invalid failure policy",
+			routes:  []Route{{FailurePolicy: "retry"}},
+			wantErr: errInvalidFailurePolicy,
+		},
+		{
+			name:    "error - unsupported action",
+			routes:  []Route{{Actions: []wal.Action{wal.ActionCommit}}},
+			wantErr: errUnsupportedRouteAction,
+		},
+		{
+			name:    "error - invalid table pattern",
+			routes:  []Route{{Tables: []string{"public.users_["}}},
+			wantErr: pglib.ErrInvalidTablePattern,
+		},
+		{
+			name: "error - creating route processor",
+			routes: []Route{
+				{Name: "a"},
+				{Name: "b"},
+			},
+			processorErr: errTest,
+			wantClosed:   1,
+			wantErr:      errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			closed := 0
+			newProcessor := func(i int, checkpoint checkpointer.Checkpoint) (processor.Processor, error) {
+				require.NotNil(t, checkpoint)
+				if tc.processorErr != nil && i > 0 {
+					return nil, tc.processorErr
+				}
+				return &mocks.Processor{
+					CloseFn: func() error {
+						closed++
+						return nil
+					},
+				}, nil
+			}
+
+			_, err := NewFanOutRouter(&FanOutConfig{Routes: tc.routes}, func(context.Context, []wal.CommitPosition) error { return nil }, newProcessor)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantClosed, closed)
+		})
+	}
+}
+
+func TestFanOutRouter_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	newEvent := func(action wal.Action, schema, table string, position wal.CommitPosition) *wal.Event {
+		return &wal.Event{
+			Data:           &wal.Data{Action: string(action), Schema: schema, Table: table},
+			CommitPosition: position,
+		}
+	}
+
+	usersInsert := newEvent(wal.ActionInsert, "public", "users", "1")
+	usersDelete := newEvent(wal.ActionDelete, "public", "users", "2")
+	docsInsert := newEvent(wal.ActionInsert, "search", "docs", "3")
+	unrouted := newEvent(wal.ActionInsert, "other", "table", "4")
+	schemaLog := newEvent(wal.ActionInsert, schemalog.SchemaName, schemalog.TableName, "5")
+	keepAlive := &wal.Event{CommitPosition: "6"}
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name          string
+		failurePolicy FailurePolicy
+		searchErr     error
+
+		wantPostgres []*wal.Event
+		wantSearch   []*wal.Event
+		wantErr      error
+	}{
+		{
+			name: "ok",
+
+			wantPostgres: []*wal.Event{usersInsert, usersDelete, schemaLog, keepAlive},
+			wantSearch:   []*wal.Event{usersInsert, docsInsert, schemaLog, keepAlive},
+		},
+		{
+			name:          "route error isolated",
+			failurePolicy: FailurePolicyIsolate,
+			searchErr:     errTest,
+
+			wantPostgres: []*wal.Event{usersInsert, usersDelete, schemaLog, keepAlive},
+			wantSearch:   []*wal.Event{usersInsert, docsInsert, schemaLog, keepAlive},
+		},
+		{
+			name:      "error - route error",
+			searchErr: errTest,
+
+			wantPostgres: []*wal.Event{usersInsert},
+			wantSearch:   []*wal.Event{usersInsert},
+			wantErr:      errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotPostgres := []*wal.Event{}
+			gotSearch := []*wal.Event{}
+			newProcessor := func(i int, _ checkpointer.Checkpoint) (processor.Processor, error) {
+				if i == 0 {
+					return &mocks.Processor{
+						ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+							gotPostgres = append(gotPostgres, event)
+							return nil
+						},
+					}, nil
+				}
+				return &mocks.Processor{
+					ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+						gotSearch = append(gotSearch, event)
+						return tc.searchErr
+					},
+				}, nil
+			}
+
+			r, err := NewFanOutRouter(&FanOutConfig{
+				Routes: []Route{
+					{Name: "postgres", Tables: []string{"public.users"}},
+					{Name: "search", Tables: []string{"public.users", "search.*"}, Actions: []wal.Action{wal.ActionInsert}, FailurePolicy: tc.failurePolicy},
+				},
+			}, nil, newProcessor)
+			require.NoError(t, err)
+
+			for _, event := range []*wal.Event{usersInsert, usersDelete, docsInsert, unrouted, schemaLog, keepAlive} {
+				if err = r.ProcessWALEvent(context.Background(), event); err != nil {
+					break
+				}
+			}
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantPostgres, gotPostgres)
+			require.Equal(t, tc.wantSearch, gotSearch)
+		})
+	}
+}
+
+func TestFanOutRouter_checkpoint(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	checkpointed := []wal.CommitPosition{}
+	checkpoint := func(ctx context.Context, positions []wal.CommitPosition) error {
+		checkpointed = append(checkpointed, positions...)
+		return nil
+	}
+
+	routeCheckpoints := map[int]checkpointer.Checkpoint{}
+	r, err := NewFanOutRouter(&FanOutConfig{
+		Routes: []Route{
+			{Name: "a", Tables: []string{"public.a", "public.ab"}},
+			{Name: "b", Tables: []string{"public.b", "public.ab"}, FailurePolicy: FailurePolicyIsolate},
+		},
+	}, checkpoint, func(i int, checkpoint checkpointer.Checkpoint) (processor.Processor, error) {
+		routeCheckpoints[i] = checkpoint
+		return &mocks.Processor{
+			ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+				if i == 1 && event.Data != nil && event.Data.Table == "b" {
+					return errTest
+				}
+				return nil
+			},
+		}, nil
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	process := func(table string, position wal.CommitPosition) {
+		require.NoError(t, r.ProcessWALEvent(ctx, &wal.Event{
+			Data:           &wal.Data{Action: "I", Schema: "public", Table: table},
+			CommitPosition: position,
+		}))
+	}
+
+	process("ab", "1")
+	process("a", "2")
+	process("none", "3")
+
+	// route b hasn't processed position 1 yet
+	require.NoError(t, routeCheckpoints[0](ctx, []wal.CommitPosition{"1", "2"}))
+	require.Empty(t, checkpointed)
+
+	// the position not sent to any route is checkpointed with the previous
+	// ones
+	require.NoError(t, routeCheckpoints[1](ctx, []wal.CommitPosition{"1"}))
+	require.Equal(t, []wal.CommitPosition{"1", "2", "3"}, checkpointed)
+
+	// the failed event of the isolated route is released
+	process("b", "4")
+	require.Equal(t, []wal.CommitPosition{"1", "2", "3", "4"}, checkpointed)
+
+	// the positions are checkpointed once all the routes checkpoint them
+	require.NoError(t, r.ProcessWALEvent(ctx, &wal.Event{CommitPosition: "5"}))
+	require.NoError(t, routeCheckpoints[1](ctx, []wal.CommitPosition{"5"}))
+	require.Equal(t, []wal.CommitPosition{"1", "2", "3", "4"}, checkpointed)
+	require.NoError(t, routeCheckpoints[0](ctx, []wal.CommitPosition{"5"}))
+	require.Equal(t, []wal.CommitPosition{"1", "2", "3", "4", "5"}, checkpointed)
+}
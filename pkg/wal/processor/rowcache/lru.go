@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package rowcache
+
+import (
+	"container/list"
+	"strings"
+
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// LRU is a least recently used cache of row column values, bounded by number
+// of entries and total byte size. It is not safe for concurrent use.
+type LRU struct {
+	maxEntries int
+	maxBytes   int64
+
+	size    int64
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+type lruEntry struct {
+	key     string
+	columns []wal.Column
+	size    int64
+}
+
+func NewLRU(maxEntries int, maxBytes int64) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ll:         list.New(),
+		entries:    map[string]*list.Element{},
+	}
+}
+
+// Get returns the cached columns for the key on input, marking it as recently
+// used.
+func (c *LRU) Get(key string) ([]wal.Column, bool) {
+	elem, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+	c.ll.MoveToFront(elem)
+	return elem.Value.(*lruEntry).columns, true
+}
+
+// Put stores the columns for the key on input, evicting the least recently used
+// entries if any of the limits is exceeded. Entries bigger than the max byte
+// size are not cached.
+func (c *LRU) Put(key string, columns []wal.Column, size int64) {
+	c.Remove(key)
+	if c.maxBytes > 0 && size > c.maxBytes {
+		return
+	}
+
+	c.entries[key] = c.ll.PushFront(&lruEntry{
+		key:     key,
+		columns: columns,
+		size:    size,
+	})
+	c.size += size
+
+	for (c.maxEntries > 0 && c.ll.Len() > c.maxEntries) || (c.maxBytes > 0 && c.size > c.maxBytes) {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+// Remove evicts the key on input from the cache, if present.
+func (c *LRU) Remove(key string) {
+	if elem, found := c.entries[key]; found {
+		c.removeElement(elem)
+	}
+}
+
+// RemovePrefix evicts all the keys with the prefix on input.
+func (c *LRU) RemovePrefix(prefix string) {
+	for key, elem := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.removeElement(elem)
+		}
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *LRU) Len() int {
+	return c.ll.Len()
+}
+
+// Size returns the total byte size of the cached entries.
+func (c *LRU) Size() int64 {
+	return c.size
+}
+
+func (c *LRU) removeElement(elem *list.Element) {
+	entry := c.ll.Remove(elem).(*lruEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package rowcache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestLRU(t *testing.T) {
+	t.Parallel()
+
+	testColumns := func(value string) []wal.Column {
+		return []wal.Column{{Name: "col", Type: "text", Value: value}}
+	}
+
+	t.Run("max entries exceeded evicts least recently used", func(t *testing.T) {
+		t.Parallel()
+
+		c := NewLRU(2, 0)
+		c.Put("a", testColumns("a"), 1)
+		c.Put("b", testColumns("b"), 1)
+		// access a so that b becomes the least recently used
+		_, found := c.Get("a")
+		require.True(t, found)
+		c.Put("c", testColumns("c"), 1)
+
+		require.Equal(t, 2, c.Len())
+		_, found = c.Get("b")
+		require.False(t, found)
+		cols, found := c.Get("a")
+		require.True(t, found)
+		require.Equal(t, testColumns("a"), cols)
+	})
+
+	t.Run("max bytes exceeded evicts least recently used", func(t *testing.T) {
+		t.Parallel()
+
+		c := NewLRU(0, 10)
+		c.Put("a", testColumns("a"), 4)
+		c.Put("b", testColumns("b"), 4)
+		c.Put("c", testColumns("c"), 4)
+
+		require.Equal(t, 2, c.Len())
+		require.Equal(t, int64(8), c.Size())
+		_, found := c.Get("a")
+		require.False(t, found)
+	})
+
+	t.Run("entries bigger than max bytes are not cached", func(t *testing.T) {
+		t.Parallel()
+
+		c := NewLRU(0, 10)
+		c.Put("a", testColumns("a"), 4)
+		c.Put("a", testColumns("big"), 11)
+
+		require.Equal(t, 0, c.Len())
+		require.Equal(t, int64(0), c.Size())
+	})
+
+	t.Run("put replaces existing entry", func(t *testing.T) {
+		t.Parallel()
+
+		c := NewLRU(0, 0)
+		c.Put("a", testColumns("a"), 4)
+		c.Put("a", testColumns("b"), 2)
+
+		require.Equal(t, 1, c.Len())
+		require.Equal(t, int64(2), c.Size())
+		cols, found := c.Get("a")
+		require.True(t, found)
+		require.Equal(t, testColumns("b"), cols)
+	})
+
+	t.Run("remove prefix", func(t *testing.T) {
+		t.Parallel()
+
+		c := NewLRU(0, 0)
+		c.Put("public\x00a\x00id=1", testColumns("a"), 1)
+		c.Put("public\x00a\x00id=2", testColumns("a"), 1)
+		c.Put("public\x00b\x00id=1", testColumns("b"), 1)
+		c.RemovePrefix("public\x00a\x00")
+
+		require.Equal(t, 1, c.Len())
+		require.Equal(t, int64(1), c.Size())
+		_, found := c.Get("public\x00b\x00id=1")
+		require.True(t, found)
+	})
+}
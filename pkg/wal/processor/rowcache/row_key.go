@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package rowcache
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+const (
+	schemaNameColumn = "schema_name"
+	keySeparator     = "\x00"
+)
+
+// RowKey returns the key identifying the row of the wal event using the
+// pgstream identity columns if available, or the replica identity otherwise.
+func RowKey(data *wal.Data) (string, bool) {
+	if len(data.Metadata.InternalColIDs) > 0 {
+		return identityKey(data, data.Columns, data.Metadata.InternalColIDs)
+	}
+	return identityKey(data, data.Identity, nil)
+}
+
+// OldRowKey returns the key identifying the row of the wal event before it
+// was modified.
+func OldRowKey(data *wal.Data) (string, bool) {
+	return identityKey(data, data.Identity, data.Metadata.InternalColIDs)
+}
+
+// TableKeyPrefix returns the prefix shared by the keys of all the rows of the
+// wal event table.
+func TableKeyPrefix(data *wal.Data) string {
+	return data.Schema + keySeparator + data.Table + keySeparator
+}
+
+// SchemaKeyPrefix returns the prefix shared by the keys of all the rows of the
+// tables in the schema on input.
+func SchemaKeyPrefix(schemaName string) string {
+	return schemaName + keySeparator
+}
+
+// SchemaLogEventSchemaName returns the name of the schema modified by the
+// schema log event on input.
+func SchemaLogEventSchemaName(data *wal.Data) (string, bool) {
+	for _, col := range data.Columns {
+		if col.Name == schemaNameColumn {
+			schemaName, ok := col.Value.(string)
+			return schemaName, ok
+		}
+	}
+	return "", false
+}
+
+// ValueSize returns an approximation of the in memory size of the value.
+func ValueSize(value any) int64 {
+	switch v := value.(type) {
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	default:
+		return int64(len(fmt.Sprint(v)))
+	}
+}
+
+// identityKey builds the row key from the values of the columns with the
+// identity ids on input. If no ids are provided, all the columns are used.
+func identityKey(data *wal.Data, columns []wal.Column, ids []string) (string, bool) {
+	if len(columns) == 0 {
+		return "", false
+	}
+
+	var key strings.Builder
+	key.WriteString(TableKeyPrefix(data))
+
+	if len(ids) == 0 {
+		for _, col := range columns {
+			key.WriteString(fmt.Sprintf("%s=%v%s", col.Name, col.Value, keySeparator))
+		}
+		return key.String(), true
+	}
+
+	for _, id := range ids {
+		found := false
+		for _, col := range columns {
+			if col.ID == id {
+				key.WriteString(fmt.Sprintf("%s=%v%s", col.Name, col.Value, keySeparator))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+	return key.String(), true
+}
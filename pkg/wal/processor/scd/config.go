@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package scd
+
+import (
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+type Config struct {
+	// URL is the target postgres database where the row versions are written.
+	URL string
+	// ValidFromColumn is the timestamp column of the target tables set when a
+	// row version is inserted. Defaults to valid_from.
+	ValidFromColumn string
+	// ValidToColumn is the timestamp column of the target tables set when a
+	// row version is closed. The current version of a row is the one where
+	// it's null. Defaults to valid_to.
+	ValidToColumn string
+	// MaxConflictRetries is the number of times an update is retried when the
+	// current version of the row is closed concurrently. Defaults to 3.
+	MaxConflictRetries int
+
+	Batch batch.Config
+}
+
+const (
+	defaultValidFromColumn    = "valid_from"
+	defaultValidToColumn      = "valid_to"
+	defaultMaxConflictRetries = 3
+)
+
+func (c *Config) validFromColumn() string {
+	if c.ValidFromColumn != "" {
+		return c.ValidFromColumn
+	}
+	return defaultValidFromColumn
+}
+
+func (c *Config) validToColumn() string {
+	if c.ValidToColumn != "" {
+		return c.ValidToColumn
+	}
+	return defaultValidToColumn
+}
+
+func (c *Config) maxConflictRetries() int {
+	if c.MaxConflictRetries > 0 {
+		return c.MaxConflictRetries
+	}
+	return defaultMaxConflictRetries
+}
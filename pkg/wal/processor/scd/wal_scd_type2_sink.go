@@ -0,0 +1,387 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package scd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+
+	"github.com/xataio/pgstream/internal/json"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+// SCDType2Sink is a wal processor that keeps the history of the rows in a
+// postgres target, as slowly changing dimensions of type 2. Instead of being
+// updated in place, each change to a row inserts a new version of it, valid
+// from the time of the change, and closes the previous version. Deletes only
+// close the current version of the row.
+//
+// The target tables must have the valid from and valid to timestamp columns,
+// and no unique constraints on the source primary key, since all the versions
+// of a row share it.
+type SCDType2Sink struct {
+	logger          loglib.Logger
+	pgConn          pglib.Querier
+	batchSender     batchSender
+	instrumentation *otel.Instrumentation
+
+	validFromColumn    string
+	validToColumn      string
+	maxConflictRetries int
+
+	// optional checkpointer callback to mark what was safely processed
+	checkpointer checkpointer.Checkpoint
+}
+
+type Option func(*SCDType2Sink)
+
+type batchSender interface {
+	SendMessage(context.Context, *batch.WALMessage[*rowChange]) error
+	Close()
+}
+
+// rowChange is a dml event to be applied to the target row versions
+type rowChange struct {
+	data *wal.Data
+}
+
+const sinkName = "scd_type2_sink"
+
+var (
+	errMissingURL       = errors.New("missing scd type 2 target postgres URL")
+	errMissingIdentity  = errors.New("unable to identify the row, no primary keys or previous values available")
+	errConcurrentUpdate = errors.New("current row version closed by a concurrent update")
+)
+
+// NewSCDType2Sink returns a sink that writes the versions of the rows to the
+// configured postgres target.
+func NewSCDType2Sink(ctx context.Context, cfg *Config, opts ...Option) (*SCDType2Sink, error) {
+	if cfg.URL == "" {
+		return nil, errMissingURL
+	}
+
+	s := &SCDType2Sink{
+		logger:             loglib.NewNoopLogger(),
+		validFromColumn:    cfg.validFromColumn(),
+		validToColumn:      cfg.validToColumn(),
+		maxConflictRetries: cfg.maxConflictRetries(),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	var err error
+	s.pgConn, err = pglib.NewConnPool(ctx, cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("creating scd type 2 target connection: %w", err)
+	}
+
+	s.batchSender, err = batch.NewSender(ctx, &cfg.Batch, s.sendBatch, s.logger, batch.WithInstrumentation(s.instrumentation, s.Name()))
+	if err != nil {
+		s.pgConn.Close(context.Background())
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *SCDType2Sink) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: sinkName,
+		})
+	}
+}
+
+func WithCheckpoint(c checkpointer.Checkpoint) Option {
+	return func(s *SCDType2Sink) {
+		s.checkpointer = c
+	}
+}
+
+// WithInstrumentation reports the metrics of the batches sent by the sink.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(s *SCDType2Sink) {
+		s.instrumentation = i
+	}
+}
+
+// ProcessWALEvent is called on every new message from the wal. It can be called
+// concurrently.
+func (s *SCDType2Sink) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Panic("[PANIC] Panic while processing replication event", loglib.Fields{
+				"wal_data":    walEvent,
+				"panic":       r,
+				"stack_trace": debug.Stack(),
+			})
+
+			err = fmt.Errorf("scd type 2 sink: understanding event: %w:  %v", processor.ErrPanic, r)
+		}
+	}()
+
+	change := &rowChange{}
+	// schema changes are not replicated, the target tables keep the columns
+	// of all the row versions
+	if walEvent.Data != nil && !processor.IsSchemaLogEvent(walEvent.Data) {
+		change.data = walEvent.Data
+	}
+
+	return s.batchSender.SendMessage(ctx, batch.NewWALMessage(change, walEvent.CommitPosition))
+}
+
+func (s *SCDType2Sink) Name() string {
+	return sinkName
+}
+
+// Ping checks the connectivity with the target postgres database.
+func (s *SCDType2Sink) Ping(ctx context.Context) error {
+	return s.pgConn.Ping(ctx)
+}
+
+func (s *SCDType2Sink) Close() error {
+	s.batchSender.Close()
+	return s.pgConn.Close(context.Background())
+}
+
+func (s *SCDType2Sink) sendBatch(ctx context.Context, batch *batch.Batch[*rowChange]) error {
+	changes := batch.GetMessages()
+	s.logger.Debug("scd type 2 sink: writing row versions", loglib.Fields{"batch_size": len(changes)})
+
+	for _, change := range changes {
+		if err := s.applyChange(ctx, change.data); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.logger.Error(err, "applying row change", loglib.Fields{
+				"schema":   change.data.Schema,
+				"table":    change.data.Table,
+				"action":   change.data.Action,
+				"severity": "DATALOSS",
+			})
+		}
+	}
+
+	positions := batch.GetCommitPositions()
+	if s.checkpointer != nil && len(positions) > 0 {
+		if err := s.checkpointer(ctx, positions); err != nil {
+			s.logger.Warn(err, "scd type 2 sink: error updating commit position")
+		}
+	}
+
+	return nil
+}
+
+// applyChange writes the row version changes for the event on input, in a
+// transaction of its own. Updates are retried when the current version of the
+// row is closed concurrently, since the new version of the row must be based
+// on the latest one.
+func (s *SCDType2Sink) applyChange(ctx context.Context, d *wal.Data) error {
+	var err error
+	for attempt := 0; attempt <= s.maxConflictRetries; attempt++ {
+		err = s.pgConn.ExecInTx(ctx, func(tx pglib.Tx) error {
+			return s.applyChangeInTx(ctx, tx, d)
+		})
+		if !errors.Is(err, errConcurrentUpdate) {
+			return err
+		}
+		s.logger.Debug("scd type 2 sink: retrying row update", loglib.Fields{
+			"schema":  d.Schema,
+			"table":   d.Table,
+			"attempt": attempt + 1,
+		})
+	}
+	return err
+}
+
+func (s *SCDType2Sink) applyChangeInTx(ctx context.Context, tx pglib.Tx, d *wal.Data) error {
+	switch wal.Action(d.Action) {
+	case wal.ActionInsert:
+		return s.insertVersion(ctx, tx, d)
+	case wal.ActionUpdate:
+		closed, err := s.closeVersion(ctx, tx, d)
+		if err != nil {
+			return err
+		}
+		if !closed {
+			// the current version might not exist because the row was
+			// created before the history was kept, in which case the new
+			// version is the first one. Otherwise, it was closed by a
+			// concurrent update.
+			exists, err := s.versionExists(ctx, tx, d)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return errConcurrentUpdate
+			}
+		}
+		return s.insertVersion(ctx, tx, d)
+	case wal.ActionDelete:
+		closed, err := s.closeVersion(ctx, tx, d)
+		if err != nil {
+			return err
+		}
+		if !closed {
+			s.logger.Debug("scd type 2 sink: no current row version to close on delete", loglib.Fields{
+				"schema": d.Schema,
+				"table":  d.Table,
+			})
+		}
+		return nil
+	case wal.ActionTruncate:
+		// the history is kept, only the current versions are closed
+		_, err := tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET %s = NOW() WHERE %s IS NULL",
+			pglib.QuoteQualifiedIdentifier(d.Schema, d.Table),
+			pglib.QuoteIdentifier(s.validToColumn),
+			pglib.QuoteIdentifier(s.validToColumn)))
+		return err
+	default:
+		return nil
+	}
+}
+
+// insertVersion inserts the row on input as the current version, valid from
+// now. When the previous version is closed in the same transaction, its valid
+// to matches the valid from of the new version.
+func (s *SCDType2Sink) insertVersion(ctx context.Context, tx pglib.Tx, d *wal.Data) error {
+	names := make([]string, 0, len(d.Columns)+1)
+	placeholders := make([]string, 0, len(d.Columns)+1)
+	values := make([]any, 0, len(d.Columns))
+	for i, col := range d.Columns {
+		names = append(names, pglib.QuoteIdentifier(col.Name))
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		values = append(values, serializeValue(col.Type, col.Value))
+	}
+	names = append(names, pglib.QuoteIdentifier(s.validFromColumn))
+	placeholders = append(placeholders, "NOW()")
+
+	_, err := tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s(%s) VALUES(%s)",
+		pglib.QuoteQualifiedIdentifier(d.Schema, d.Table),
+		strings.Join(names, ", "),
+		strings.Join(placeholders, ", ")), values...)
+	if err != nil {
+		return fmt.Errorf("inserting row version: %w", err)
+	}
+	return nil
+}
+
+// closeVersion sets the valid to of the current version of the row. The
+// current version predicate acts as an optimistic lock: if a concurrent
+// update already closed it, no rows are affected. It returns true if the
+// current version was closed.
+func (s *SCDType2Sink) closeVersion(ctx context.Context, tx pglib.Tx, d *wal.Data) (bool, error) {
+	whereQuery, whereValues, err := s.buildWhereQuery(d)
+	if err != nil {
+		return false, err
+	}
+
+	validTo := pglib.QuoteIdentifier(s.validToColumn)
+	tag, err := tx.Exec(ctx, fmt.Sprintf("UPDATE %s SET %s = NOW() WHERE %s AND %s IS NULL",
+		pglib.QuoteQualifiedIdentifier(d.Schema, d.Table), validTo, whereQuery, validTo), whereValues...)
+	if err != nil {
+		return false, fmt.Errorf("closing current row version: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (s *SCDType2Sink) versionExists(ctx context.Context, tx pglib.Tx, d *wal.Data) (bool, error) {
+	whereQuery, whereValues, err := s.buildWhereQuery(d)
+	if err != nil {
+		return false, err
+	}
+
+	exists := false
+	if err := tx.QueryRow(ctx, []any{&exists}, fmt.Sprintf("SELECT EXISTS(SELECT 1 FROM %s WHERE %s)",
+		pglib.QuoteQualifiedIdentifier(d.Schema, d.Table), whereQuery), whereValues...); err != nil {
+		return false, fmt.Errorf("checking row versions: %w", err)
+	}
+	return exists, nil
+}
+
+// buildWhereQuery returns the condition identifying the versions of the row,
+// using the previous values of the row if available, or its primary keys
+// otherwise.
+func (s *SCDType2Sink) buildWhereQuery(d *wal.Data) (string, []any, error) {
+	var cols []wal.Column
+	switch {
+	case len(d.Identity) > 0:
+		cols = d.Identity
+	case len(d.Metadata.InternalColIDs) > 0:
+		for _, col := range d.Columns {
+			if slices.Contains(d.Metadata.InternalColIDs, col.ID) {
+				cols = append(cols, col)
+			}
+		}
+	}
+	if len(cols) == 0 {
+		// without a where clause we'd be closing the versions of all rows
+		return "", nil, errMissingIdentity
+	}
+
+	conditions := make([]string, 0, len(cols))
+	values := make([]any, 0, len(cols))
+	for i, col := range cols {
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", pglib.QuoteIdentifier(col.Name), i+1))
+		values = append(values, serializeValue(col.Type, col.Value))
+	}
+	return strings.Join(conditions, " AND "), values, nil
+}
+
+// serializeValue marshals the json values decoded into maps and slices, so
+// that they can be written to json columns.
+func serializeValue(colType string, val any) any {
+	if colType != "json" && colType != "jsonb" {
+		return val
+	}
+	switch val.(type) {
+	case map[string]any, []any:
+		if jsonBytes, err := json.Marshal(val); err == nil {
+			return jsonBytes
+		}
+	}
+	return val
+}
+
+const (
+	rowChangeOverhead = 64
+	columnOverhead    = 48
+)
+
+// Size returns the approximate size of the row change, based on the size of
+// its column names and values.
+func (c *rowChange) Size() int {
+	if c.IsEmpty() {
+		return 0
+	}
+
+	size := rowChangeOverhead + len(c.data.Schema) + len(c.data.Table)
+	for _, cols := range [][]wal.Column{c.data.Columns, c.data.Identity} {
+		for _, col := range cols {
+			size += columnOverhead + len(col.Name) + len(col.Type)
+			switch v := col.Value.(type) {
+			case string:
+				size += len(v)
+			case []byte:
+				size += len(v)
+			}
+		}
+	}
+	return size
+}
+
+func (c *rowChange) IsEmpty() bool {
+	return c == nil || c.data == nil
+}
@@ -0,0 +1,357 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package scd
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	batchmocks "github.com/xataio/pgstream/pkg/wal/processor/batch/mocks"
+)
+
+type testQuery struct {
+	sql  string
+	args []any
+}
+
+const (
+	testCloseQuery  = `UPDATE "public"."users" SET "valid_to" = NOW() WHERE "id" = $1 AND "valid_to" IS NULL`
+	testInsertQuery = `INSERT INTO "public"."users"("id", "name", "valid_from") VALUES($1, $2, NOW())`
+	testExistsQuery = `SELECT EXISTS(SELECT 1 FROM "public"."users" WHERE "id" = $1)`
+)
+
+func newTestData(action wal.Action) *wal.Data {
+	d := &wal.Data{
+		Action: string(action),
+		Schema: "public",
+		Table:  "users",
+		Columns: []wal.Column{
+			{ID: "col-1", Name: "id", Type: "integer", Value: 1},
+			{ID: "col-2", Name: "name", Type: "text", Value: "alice"},
+		},
+	}
+	if action == wal.ActionUpdate || action == wal.ActionDelete {
+		d.Identity = []wal.Column{{ID: "col-1", Name: "id", Type: "integer", Value: 1}}
+	}
+	return d
+}
+
+func commandTag(rowsAffected string) pglib.CommandTag {
+	return pglib.CommandTag{CommandTag: pgconn.NewCommandTag("UPDATE " + rowsAffected)}
+}
+
+func TestSCDType2Sink_applyChange(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name string
+		data *wal.Data
+		// closedRows returns the number of rows closed by the update query for
+		// the attempt on input
+		closedRows func(attempt uint) string
+		exists     bool
+		execErr    error
+
+		wantQueries []testQuery
+		wantErr     error
+	}{
+		{
+			name: "ok - insert",
+			data: newTestData(wal.ActionInsert),
+
+			wantQueries: []testQuery{
+				{sql: testInsertQuery, args: []any{1, "alice"}},
+			},
+		},
+		{
+			name:       "ok - update",
+			data:       newTestData(wal.ActionUpdate),
+			closedRows: func(uint) string { return "1" },
+
+			wantQueries: []testQuery{
+				{sql: testCloseQuery, args: []any{1}},
+				{sql: testInsertQuery, args: []any{1, "alice"}},
+			},
+		},
+		{
+			name: "ok - update with primary keys",
+			data: func() *wal.Data {
+				d := newTestData(wal.ActionUpdate)
+				d.Identity = nil
+				d.Metadata.InternalColIDs = []string{"col-1"}
+				return d
+			}(),
+			closedRows: func(uint) string { return "1" },
+
+			wantQueries: []testQuery{
+				{sql: testCloseQuery, args: []any{1}},
+				{sql: testInsertQuery, args: []any{1, "alice"}},
+			},
+		},
+		{
+			name:       "ok - update without previous version",
+			data:       newTestData(wal.ActionUpdate),
+			closedRows: func(uint) string { return "0" },
+			exists:     false,
+
+			wantQueries: []testQuery{
+				{sql: testCloseQuery, args: []any{1}},
+				{sql: testExistsQuery, args: []any{1}},
+				{sql: testInsertQuery, args: []any{1, "alice"}},
+			},
+		},
+		{
+			name: "ok - update retried after concurrent update",
+			data: newTestData(wal.ActionUpdate),
+			closedRows: func(attempt uint) string {
+				if attempt == 1 {
+					return "0"
+				}
+				return "1"
+			},
+			exists: true,
+
+			wantQueries: []testQuery{
+				{sql: testCloseQuery, args: []any{1}},
+				{sql: testExistsQuery, args: []any{1}},
+				{sql: testCloseQuery, args: []any{1}},
+				{sql: testInsertQuery, args: []any{1, "alice"}},
+			},
+		},
+		{
+			name:       "error - concurrent update retries exhausted",
+			data:       newTestData(wal.ActionUpdate),
+			closedRows: func(uint) string { return "0" },
+			exists:     true,
+
+			wantQueries: []testQuery{
+				{sql: testCloseQuery, args: []any{1}},
+				{sql: testExistsQuery, args: []any{1}},
+				{sql: testCloseQuery, args: []any{1}},
+				{sql: testExistsQuery, args: []any{1}},
+			},
+			wantErr: errConcurrentUpdate,
+		},
+		{
+			name:       "ok - delete",
+			data:       newTestData(wal.ActionDelete),
+			closedRows: func(uint) string { return "1" },
+
+			wantQueries: []testQuery{
+				{sql: testCloseQuery, args: []any{1}},
+			},
+		},
+		{
+			name:       "ok - delete without current version",
+			data:       newTestData(wal.ActionDelete),
+			closedRows: func(uint) string { return "0" },
+
+			wantQueries: []testQuery{
+				{sql: testCloseQuery, args: []any{1}},
+			},
+		},
+		{
+			name: "ok - truncate",
+			data: &wal.Data{Action: string(wal.ActionTruncate), Schema: "public", Table: "users"},
+
+			wantQueries: []testQuery{
+				{sql: `UPDATE "public"."users" SET "valid_to" = NOW() WHERE "valid_to" IS NULL`},
+			},
+		},
+		{
+			name: "ok - jsonb value",
+			data: &wal.Data{
+				Action:  string(wal.ActionInsert),
+				Schema:  "public",
+				Table:   "users",
+				Columns: []wal.Column{{Name: "doc", Type: "jsonb", Value: map[string]any{"a": 1}}},
+			},
+
+			wantQueries: []testQuery{
+				{sql: `INSERT INTO "public"."users"("doc", "valid_from") VALUES($1, NOW())`, args: []any{[]byte(`{"a":1}`)}},
+			},
+		},
+		{
+			name: "error - missing identity",
+			data: func() *wal.Data {
+				d := newTestData(wal.ActionDelete)
+				d.Identity = nil
+				return d
+			}(),
+
+			wantQueries: []testQuery{},
+			wantErr:     errMissingIdentity,
+		},
+		{
+			name:    "error - inserting row version",
+			data:    newTestData(wal.ActionInsert),
+			execErr: errTest,
+
+			wantQueries: []testQuery{
+				{sql: testInsertQuery, args: []any{1, "alice"}},
+			},
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			queries := []testQuery{}
+			attempt := uint(0)
+			s := &SCDType2Sink{
+				logger:             loglib.NewNoopLogger(),
+				validFromColumn:    defaultValidFromColumn,
+				validToColumn:      defaultValidToColumn,
+				maxConflictRetries: 1,
+				pgConn: &pgmocks.Querier{
+					ExecInTxFn: func(ctx context.Context, fn func(tx pglib.Tx) error) error {
+						attempt++
+						return fn(&pgmocks.Tx{
+							ExecFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.CommandTag, error) {
+								queries = append(queries, testQuery{sql: query, args: args})
+								if tc.execErr != nil {
+									return pglib.CommandTag{}, tc.execErr
+								}
+								if query == testCloseQuery {
+									return commandTag(tc.closedRows(attempt)), nil
+								}
+								return commandTag("1"), nil
+							},
+							QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+								queries = append(queries, testQuery{sql: query, args: args})
+								exists, ok := dest[0].(*bool)
+								require.True(t, ok)
+								*exists = tc.exists
+								return nil
+							},
+						})
+					},
+				},
+			}
+
+			err := s.applyChange(context.Background(), tc.data)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantQueries, queries)
+		})
+	}
+}
+
+func TestSCDType2Sink_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	testCommitPosition := wal.CommitPosition("0/1")
+	testData := newTestData(wal.ActionInsert)
+
+	tests := []struct {
+		name  string
+		event *wal.Event
+
+		wantMsgs []*batch.WALMessage[*rowChange]
+	}{
+		{
+			name:  "ok - dml event",
+			event: &wal.Event{Data: testData, CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[*rowChange]{
+				batch.NewWALMessage(&rowChange{data: testData}, testCommitPosition),
+			},
+		},
+		{
+			name: "ok - schema log event",
+			event: &wal.Event{
+				Data:           &wal.Data{Action: "I", Schema: schemalog.SchemaName, Table: schemalog.TableName},
+				CommitPosition: testCommitPosition,
+			},
+			wantMsgs: []*batch.WALMessage[*rowChange]{
+				batch.NewWALMessage(&rowChange{}, testCommitPosition),
+			},
+		},
+		{
+			name:  "ok - keep alive",
+			event: &wal.Event{CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[*rowChange]{
+				batch.NewWALMessage(&rowChange{}, testCommitPosition),
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockBatchSender := batchmocks.NewBatchSender[*rowChange]()
+			s := &SCDType2Sink{
+				logger:      loglib.NewNoopLogger(),
+				batchSender: mockBatchSender,
+			}
+
+			doneChan := make(chan struct{})
+			go func() {
+				defer close(doneChan)
+				require.Equal(t, tc.wantMsgs, mockBatchSender.GetWALMessages())
+			}()
+
+			err := s.ProcessWALEvent(context.Background(), tc.event)
+			require.NoError(t, err)
+			mockBatchSender.Close()
+			<-doneChan
+		})
+	}
+}
+
+func TestSCDType2Sink_sendBatch(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	testPositions := []wal.CommitPosition{"0/1", "0/2"}
+
+	inserted := []string{}
+	checkpointed := []wal.CommitPosition{}
+	s := &SCDType2Sink{
+		logger:             loglib.NewNoopLogger(),
+		validFromColumn:    defaultValidFromColumn,
+		validToColumn:      defaultValidToColumn,
+		maxConflictRetries: 1,
+		pgConn: &pgmocks.Querier{
+			ExecInTxFn: func(ctx context.Context, fn func(tx pglib.Tx) error) error {
+				return fn(&pgmocks.Tx{
+					ExecFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.CommandTag, error) {
+						// the failing change is logged, and the following ones
+						// are still applied
+						if args[1] == "bob" {
+							return pglib.CommandTag{}, errTest
+						}
+						inserted = append(inserted, args[1].(string))
+						return commandTag("1"), nil
+					},
+				})
+			},
+		},
+		checkpointer: func(ctx context.Context, positions []wal.CommitPosition) error {
+			checkpointed = append(checkpointed, positions...)
+			return nil
+		},
+	}
+
+	bob := newTestData(wal.ActionInsert)
+	bob.Columns[1].Value = "bob"
+	err := s.sendBatch(context.Background(), batch.NewBatch([]*rowChange{
+		{data: bob},
+		{data: newTestData(wal.ActionInsert)},
+	}, testPositions))
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice"}, inserted)
+	require.Equal(t, testPositions, checkpointed)
+}
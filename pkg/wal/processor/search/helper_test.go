@@ -53,6 +53,10 @@ func (m *mockStore) SendDocuments(ctx context.Context, docs []Document) ([]Docum
 	return m.sendDocumentsFn(ctx, m.sendDocumentsCalls, docs)
 }
 
+func (m *mockStore) Ping(ctx context.Context) error {
+	return nil
+}
+
 const (
 	testSchemaName = "test_schema"
 	testTableName  = "test_table"
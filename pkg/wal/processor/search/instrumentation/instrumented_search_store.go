@@ -91,6 +91,10 @@ func (s *SearchStore) SendDocuments(ctx context.Context, docs []search.Document)
 	return docErrs, err
 }
 
+func (s *SearchStore) Ping(ctx context.Context) error {
+	return s.inner.Ping(ctx)
+}
+
 func (s *SearchStore) GetMapper() search.Mapper {
 	return s.inner.GetMapper()
 }
@@ -9,6 +9,7 @@ import (
 	"runtime/debug"
 
 	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/schemalog"
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
@@ -20,10 +21,11 @@ import (
 // BatchIndexer is the environment for ingesting the WAL logical
 // replication events into a search store using the pgstream flow
 type BatchIndexer struct {
-	store       Store
-	adapter     walAdapter
-	logger      loglib.Logger
-	batchSender batchSender
+	store           Store
+	adapter         walAdapter
+	logger          loglib.Logger
+	batchSender     batchSender
+	instrumentation *otel.Instrumentation
 
 	skipSchema func(schemaName string) bool
 
@@ -54,7 +56,7 @@ func NewBatchIndexer(ctx context.Context, config IndexerConfig, store Store, lsn
 	}
 
 	var err error
-	indexer.batchSender, err = batch.NewSender(ctx, &config.Batch, indexer.sendBatch, indexer.logger)
+	indexer.batchSender, err = batch.NewSender(ctx, &config.Batch, indexer.sendBatch, indexer.logger, batch.WithInstrumentation(indexer.instrumentation, indexer.Name()))
 	if err != nil {
 		return nil, err
 	}
@@ -76,6 +78,13 @@ func WithCheckpoint(c checkpointer.Checkpoint) Option {
 	}
 }
 
+// WithInstrumentation reports the metrics of the batches sent by the indexer.
+func WithInstrumentation(instrumentation *otel.Instrumentation) Option {
+	return func(i *BatchIndexer) {
+		i.instrumentation = instrumentation
+	}
+}
+
 // ProcessWALEvent is responsible for sending the wal event to the search
 // store and committing the event position. It can be called concurrently.
 func (i *BatchIndexer) ProcessWALEvent(ctx context.Context, event *wal.Event) (err error) {
@@ -90,9 +99,8 @@ func (i *BatchIndexer) ProcessWALEvent(ctx context.Context, event *wal.Event) (e
 		}
 	}()
 
-	i.logger.Trace("search batch indexer: received wal event", loglib.Fields{
-		"wal_data":            event.Data,
-		"wal_commit_position": event.CommitPosition,
+	i.logger.Trace("search batch indexer: received wal event", event.LogFields(), loglib.Fields{
+		"wal_data": event.Data,
 	})
 
 	msg, err := i.adapter.walEventToMsg(event)
@@ -120,6 +128,10 @@ func (i *BatchIndexer) Close() error {
 	return nil
 }
 
+func (i *BatchIndexer) Ping(ctx context.Context) error {
+	return i.store.Ping(ctx)
+}
+
 func (i *BatchIndexer) sendBatch(ctx context.Context, batch *batch.Batch[*msg]) error {
 	// we'll mostly process writes, so pre-allocate the "max" amount
 	writes := make([]Document, 0, len(batch.GetMessages()))
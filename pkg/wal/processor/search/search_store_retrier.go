@@ -89,6 +89,10 @@ func (s *StoreRetrier) DeleteTableDocuments(ctx context.Context, schemaName stri
 	return s.inner.DeleteTableDocuments(ctx, schemaName, tableIDs)
 }
 
+func (s *StoreRetrier) Ping(ctx context.Context) error {
+	return s.inner.Ping(ctx)
+}
+
 // SendDocuments will go over failed documents, identifying any with retriable
 // errors and retrying them with the configured backoff policy.
 func (s *StoreRetrier) SendDocuments(ctx context.Context, docs []Document) ([]DocumentError, error) {
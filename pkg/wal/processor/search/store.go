@@ -16,6 +16,8 @@ type Store interface {
 	// data operations
 	DeleteTableDocuments(ctx context.Context, schemaName string, tableIDs []string) error
 	SendDocuments(ctx context.Context, docs []Document) ([]DocumentError, error)
+	// Ping checks the connectivity with the search store
+	Ping(ctx context.Context) error
 }
 
 type Mapper interface {
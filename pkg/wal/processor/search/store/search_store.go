@@ -182,6 +182,15 @@ func (s *Store) SendDocuments(ctx context.Context, docs []search.Document) ([]se
 	return s.adapter.BulkItemsToSearchDocErrs(failed), nil
 }
 
+// Ping checks the search store is reachable by querying for the schema log
+// index.
+func (s *Store) Ping(ctx context.Context) error {
+	if _, err := s.client.IndexExists(ctx, schemalogIndexName); err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
 func (s *Store) DeleteSchema(ctx context.Context, schemaName string) error {
 	index := s.indexNameAdapter.SchemaNameToIndex(schemaName)
 	exists, err := s.client.IndexExists(ctx, index.NameWithVersion())
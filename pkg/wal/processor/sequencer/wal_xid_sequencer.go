@@ -0,0 +1,246 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sequencer
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// XIDSequencer is a decorator around a wal processor that makes sure the
+// events of a transaction are sent to the wrapped processor in LSN order. The
+// events are buffered by transaction id until the transaction commit event is
+// received, and then sent sorted by their LSN, with a sequence number that
+// increases monotonically within the transaction.
+//
+// It requires the listener to include the transaction id of the events, and
+// to emit the commit events. Events that don't belong to a transaction are
+// sent to the wrapped processor immediately. Commit events are not sent to the
+// wrapped processor, only their commit position is.
+type XIDSequencer struct {
+	logger            loglib.Logger
+	processor         processor.Processor
+	lsnParser         replication.LSNParser
+	maxBufferedEvents int
+
+	// mutex guards the transactions state, and serialises the calls to the
+	// wrapped processor
+	mutex        sync.Mutex
+	transactions map[uint32]*transaction
+	// openXIDs keeps the ids of the transactions being buffered, in order of
+	// arrival
+	openXIDs []uint32
+}
+
+type Config struct {
+	// MaxBufferedEvents is the maximum number of events buffered per
+	// transaction. When reached, the buffered events are sent sorted before
+	// the transaction is committed, and the sequence continues with the
+	// following events. Defaults to 10000.
+	MaxBufferedEvents int
+}
+
+type Option func(s *XIDSequencer)
+
+// transaction keeps the events of a transaction until it's committed.
+type transaction struct {
+	events []*sequencedEvent
+	// seq is the sequence number of the last event sent for the transaction
+	seq uint64
+	// firstPosition is the commit position of the first event of the
+	// transaction, used to make sure the positions sent to the wrapped
+	// processor never move past the buffered events.
+	firstPosition wal.CommitPosition
+	lastLSN       replication.LSN
+}
+
+type sequencedEvent struct {
+	event *wal.Event
+	lsn   replication.LSN
+}
+
+const defaultMaxBufferedEvents = 10000
+
+// New will return a transaction sequencer wrapper around the processor on
+// input.
+func New(cfg *Config, p processor.Processor, opts ...Option) *XIDSequencer {
+	s := &XIDSequencer{
+		logger:            loglib.NewNoopLogger(),
+		processor:         p,
+		lsnParser:         pgreplication.NewLSNParser(),
+		maxBufferedEvents: cfg.maxBufferedEvents(),
+		transactions:      map[uint32]*transaction{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *XIDSequencer) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_xid_sequencer",
+		})
+	}
+}
+
+// ProcessWALEvent buffers the events that belong to a transaction until the
+// transaction is committed, and sends any other event to the wrapped
+// processor.
+func (s *XIDSequencer) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data := event.Data
+	switch {
+	case data == nil, data.XID == 0:
+		return s.processImmediately(ctx, event)
+	case wal.Action(data.Action) == wal.ActionCommit:
+		return s.commit(ctx, data.XID, event.CommitPosition)
+	}
+
+	tx, found := s.transactions[data.XID]
+	if !found {
+		tx = &transaction{firstPosition: event.CommitPosition}
+		s.transactions[data.XID] = tx
+		s.openXIDs = append(s.openXIDs, data.XID)
+	}
+
+	// events with an invalid LSN are kept right after the previous event of
+	// the transaction
+	lsn, err := s.lsnParser.FromString(data.LSN)
+	if err != nil {
+		lsn = tx.lastLSN
+	}
+	tx.lastLSN = max(tx.lastLSN, lsn)
+	tx.events = append(tx.events, &sequencedEvent{event: event, lsn: lsn})
+
+	if len(tx.events) >= s.maxBufferedEvents {
+		s.logger.Warn(nil, "max buffered events reached for transaction, sending events before commit", loglib.Fields{
+			"xid":                 data.XID,
+			"max_buffered_events": s.maxBufferedEvents,
+		})
+		return s.flush(ctx, data.XID, tx, s.heldPosition())
+	}
+	return nil
+}
+
+func (s *XIDSequencer) Name() string {
+	return s.processor.Name()
+}
+
+// Close sends the events of the transactions that haven't been committed yet
+// to the wrapped processor before closing it. Their commit positions are not
+// checkpointed, so they will be received again when the replication is
+// resumed.
+func (s *XIDSequencer) Close() error {
+	s.mutex.Lock()
+	var err error
+	position := s.heldPosition()
+	for _, xid := range s.openXIDs {
+		if flushErr := s.flush(context.Background(), xid, s.transactions[xid], position); flushErr != nil {
+			err = flushErr
+			break
+		}
+	}
+	s.transactions = map[uint32]*transaction{}
+	s.openXIDs = nil
+	s.mutex.Unlock()
+
+	return errors.Join(err, s.processor.Close())
+}
+
+func (s *XIDSequencer) Ping(ctx context.Context) error {
+	return s.processor.Ping(ctx)
+}
+
+// commit sends the buffered events of the transaction to the wrapped
+// processor in LSN order, and removes the transaction state, followed by the
+// commit position. If there are other open transactions, the first position
+// of the oldest one is sent instead. Must be called with the mutex held.
+func (s *XIDSequencer) commit(ctx context.Context, xid uint32, commitPosition wal.CommitPosition) error {
+	tx, found := s.transactions[xid]
+	if !found {
+		return s.processImmediately(ctx, &wal.Event{CommitPosition: commitPosition})
+	}
+
+	heldPosition := s.heldPosition()
+	delete(s.transactions, xid)
+	s.openXIDs = slices.DeleteFunc(s.openXIDs, func(id uint32) bool { return id == xid })
+	if len(s.openXIDs) > 0 {
+		commitPosition = s.heldPosition()
+	}
+
+	if err := s.flush(ctx, xid, tx, heldPosition); err != nil {
+		return err
+	}
+	// the commit position is sent as a keep alive event once all the events of
+	// the transaction have been processed
+	return s.processor.ProcessWALEvent(ctx, &wal.Event{CommitPosition: commitPosition})
+}
+
+// flush sends the buffered events of the transaction to the wrapped processor
+// sorted by LSN, with the commit position on input. Must be called with the
+// mutex held.
+func (s *XIDSequencer) flush(ctx context.Context, xid uint32, tx *transaction, position wal.CommitPosition) error {
+	slices.SortStableFunc(tx.events, func(a, b *sequencedEvent) int {
+		return cmp.Compare(a.lsn, b.lsn)
+	})
+
+	for len(tx.events) > 0 {
+		event := tx.events[0].event
+		if event.CommitPosition != "" {
+			event.CommitPosition = position
+		}
+		tx.seq++
+		event.Data.Seq = tx.seq
+		if err := s.processor.ProcessWALEvent(ctx, event); err != nil {
+			return fmt.Errorf("sending events of transaction %d: %w", xid, err)
+		}
+		tx.events[0] = nil
+		tx.events = tx.events[1:]
+	}
+	tx.events = nil
+	return nil
+}
+
+// processImmediately sends the event on input to the wrapped processor
+// without buffering it. If there are open transactions, the event commit
+// position is replaced by the first buffered position, so that it doesn't
+// checkpoint past them. Must be called with the mutex held.
+func (s *XIDSequencer) processImmediately(ctx context.Context, event *wal.Event) error {
+	if len(s.openXIDs) > 0 && event.CommitPosition != "" {
+		event.CommitPosition = s.heldPosition()
+	}
+	return s.processor.ProcessWALEvent(ctx, event)
+}
+
+// heldPosition returns the commit position of the first event of the oldest
+// open transaction, or an empty position if there are none. Must be called
+// with the mutex held.
+func (s *XIDSequencer) heldPosition() wal.CommitPosition {
+	if len(s.openXIDs) == 0 {
+		return ""
+	}
+	return s.transactions[s.openXIDs[0]].firstPosition
+}
+
+func (c *Config) maxBufferedEvents() int {
+	if c.MaxBufferedEvents > 0 {
+		return c.MaxBufferedEvents
+	}
+	return defaultMaxBufferedEvents
+}
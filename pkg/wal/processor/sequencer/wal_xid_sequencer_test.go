@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package sequencer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+type processedEvent struct {
+	xid      uint32
+	lsn      string
+	seq      uint64
+	position wal.CommitPosition
+}
+
+func newTestEvent(action string, xid uint32, lsn, position string) *wal.Event {
+	return &wal.Event{
+		Data: &wal.Data{
+			Action: action,
+			Schema: "public",
+			Table:  "users",
+			LSN:    lsn,
+			XID:    xid,
+		},
+		CommitPosition: wal.CommitPosition(position),
+	}
+}
+
+func newTestSequencer(processErr error, maxBufferedEvents int) (*XIDSequencer, *[]processedEvent) {
+	processed := []processedEvent{}
+	s := New(&Config{MaxBufferedEvents: maxBufferedEvents}, &mocks.Processor{
+		ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+			if processErr != nil {
+				return processErr
+			}
+			e := processedEvent{position: event.CommitPosition}
+			if event.Data != nil {
+				e.xid = event.Data.XID
+				e.lsn = event.Data.LSN
+				e.seq = event.Data.Seq
+			}
+			processed = append(processed, e)
+			return nil
+		},
+	})
+	return s, &processed
+}
+
+func TestXIDSequencer_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name              string
+		events            []*wal.Event
+		maxBufferedEvents int
+		processErr        error
+
+		wantProcessed    []processedEvent
+		wantErr          error
+		wantTransactions int
+	}{
+		{
+			name: "ok - events reordered within transaction",
+			events: []*wal.Event{
+				newTestEvent("I", 10, "0/30", "0/30"),
+				newTestEvent("U", 10, "0/10", "0/10"),
+				newTestEvent("D", 10, "0/20", "0/20"),
+				newTestEvent("C", 10, "0/40", "0/40"),
+			},
+
+			wantProcessed: []processedEvent{
+				{xid: 10, lsn: "0/10", seq: 1, position: "0/30"},
+				{xid: 10, lsn: "0/20", seq: 2, position: "0/30"},
+				{xid: 10, lsn: "0/30", seq: 3, position: "0/30"},
+				{position: "0/40"},
+			},
+		},
+		{
+			name: "ok - event with invalid lsn kept after previous event",
+			events: []*wal.Event{
+				newTestEvent("I", 10, "0/20", "0/20"),
+				newTestEvent("I", 10, "invalid", "0/21"),
+				newTestEvent("I", 10, "0/10", "0/22"),
+				newTestEvent("C", 10, "0/30", "0/30"),
+			},
+
+			wantProcessed: []processedEvent{
+				{xid: 10, lsn: "0/10", seq: 1, position: "0/20"},
+				{xid: 10, lsn: "0/20", seq: 2, position: "0/20"},
+				{xid: 10, lsn: "invalid", seq: 3, position: "0/20"},
+				{position: "0/30"},
+			},
+		},
+		{
+			name: "ok - events outside transactions sent immediately",
+			events: []*wal.Event{
+				newTestEvent("I", 0, "0/10", "0/10"),
+				{CommitPosition: "0/11"},
+			},
+
+			wantProcessed: []processedEvent{
+				{lsn: "0/10", position: "0/10"},
+				{position: "0/11"},
+			},
+		},
+		{
+			name: "ok - keep alive events don't checkpoint past open transactions",
+			events: []*wal.Event{
+				newTestEvent("I", 10, "0/10", "0/10"),
+				{CommitPosition: "0/11"},
+				newTestEvent("C", 10, "0/12", "0/12"),
+			},
+
+			wantProcessed: []processedEvent{
+				{position: "0/10"},
+				{xid: 10, lsn: "0/10", seq: 1, position: "0/10"},
+				{position: "0/12"},
+			},
+		},
+		{
+			name: "ok - overlapping transactions",
+			events: []*wal.Event{
+				newTestEvent("I", 10, "0/10", "0/10"),
+				newTestEvent("I", 11, "0/20", "0/20"),
+				newTestEvent("C", 11, "0/21", "0/21"),
+				newTestEvent("C", 10, "0/22", "0/22"),
+			},
+
+			wantProcessed: []processedEvent{
+				{xid: 11, lsn: "0/20", seq: 1, position: "0/10"},
+				{position: "0/10"},
+				{xid: 10, lsn: "0/10", seq: 1, position: "0/10"},
+				{position: "0/22"},
+			},
+		},
+		{
+			name: "ok - sequence restarts for each transaction",
+			events: []*wal.Event{
+				newTestEvent("I", 10, "0/10", "0/10"),
+				newTestEvent("C", 10, "0/11", "0/11"),
+				newTestEvent("I", 11, "0/20", "0/20"),
+				newTestEvent("C", 11, "0/21", "0/21"),
+			},
+
+			wantProcessed: []processedEvent{
+				{xid: 10, lsn: "0/10", seq: 1, position: "0/10"},
+				{position: "0/11"},
+				{xid: 11, lsn: "0/20", seq: 1, position: "0/20"},
+				{position: "0/21"},
+			},
+		},
+		{
+			name: "ok - max buffered events reached",
+			events: []*wal.Event{
+				newTestEvent("I", 10, "0/20", "0/20"),
+				newTestEvent("I", 10, "0/10", "0/21"),
+				newTestEvent("I", 10, "0/30", "0/30"),
+				newTestEvent("C", 10, "0/40", "0/40"),
+			},
+			maxBufferedEvents: 2,
+
+			wantProcessed: []processedEvent{
+				{xid: 10, lsn: "0/10", seq: 1, position: "0/20"},
+				{xid: 10, lsn: "0/20", seq: 2, position: "0/20"},
+				{xid: 10, lsn: "0/30", seq: 3, position: "0/20"},
+				{position: "0/40"},
+			},
+		},
+		{
+			name: "ok - commit of unknown transaction",
+			events: []*wal.Event{
+				newTestEvent("C", 10, "0/40", "0/40"),
+			},
+
+			wantProcessed: []processedEvent{
+				{position: "0/40"},
+			},
+		},
+		{
+			name: "ok - uncommitted transaction buffered",
+			events: []*wal.Event{
+				newTestEvent("I", 10, "0/10", "0/10"),
+			},
+
+			wantProcessed:    []processedEvent{},
+			wantTransactions: 1,
+		},
+		{
+			name: "error - processing events",
+			events: []*wal.Event{
+				newTestEvent("I", 10, "0/10", "0/10"),
+				newTestEvent("C", 10, "0/11", "0/11"),
+			},
+			processErr: errTest,
+
+			wantProcessed: []processedEvent{},
+			wantErr:       errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			s, processed := newTestSequencer(tc.processErr, tc.maxBufferedEvents)
+
+			var err error
+			for _, event := range tc.events {
+				if err = s.ProcessWALEvent(context.Background(), event); err != nil {
+					break
+				}
+			}
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantProcessed, *processed)
+			if tc.wantErr == nil {
+				require.Len(t, s.transactions, tc.wantTransactions)
+				require.Len(t, s.openXIDs, tc.wantTransactions)
+			}
+		})
+	}
+}
+
+func TestXIDSequencer_Close(t *testing.T) {
+	t.Parallel()
+
+	s, processed := newTestSequencer(nil, 0)
+	require.NoError(t, s.ProcessWALEvent(context.Background(), newTestEvent("I", 10, "0/20", "0/20")))
+	require.NoError(t, s.ProcessWALEvent(context.Background(), newTestEvent("I", 10, "0/10", "0/21")))
+
+	require.NoError(t, s.Close())
+	require.Equal(t, []processedEvent{
+		{xid: 10, lsn: "0/10", seq: 1, position: "0/20"},
+		{xid: 10, lsn: "0/20", seq: 2, position: "0/20"},
+	}, *processed)
+	require.Empty(t, s.transactions)
+}
@@ -0,0 +1,56 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package serializer
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/rs/xid"
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// MsgpackSerializer encodes the wal data as MessagePack, using the same field
+// names as the JSON serializer.
+type MsgpackSerializer struct{}
+
+const msgpackContentType = "application/msgpack"
+
+// msgpack encodes text marshalers as binary, so the schema ids are registered
+// to be encoded as strings instead, like in JSON.
+func init() {
+	msgpack.Register(xid.ID{}, func(enc *msgpack.Encoder, v reflect.Value) error {
+		return enc.EncodeString(v.Interface().(xid.ID).String())
+	}, func(dec *msgpack.Decoder, v reflect.Value) error {
+		s, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		id, err := xid.FromString(s)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(id))
+		return nil
+	})
+}
+
+func NewMsgpackSerializer() *MsgpackSerializer {
+	return &MsgpackSerializer{}
+}
+
+func (s *MsgpackSerializer) Serialize(data *wal.Data) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(data); err != nil {
+		return nil, fmt.Errorf("encoding wal data as msgpack: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *MsgpackSerializer) ContentType() string {
+	return msgpackContentType
+}
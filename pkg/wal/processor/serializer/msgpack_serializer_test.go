@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package serializer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestMsgpackSerializer_Serialize(t *testing.T) {
+	t.Parallel()
+
+	s := NewMsgpackSerializer()
+	b, err := s.Serialize(newTestData())
+	require.NoError(t, err)
+	require.Equal(t, "application/msgpack", s.ContentType())
+
+	// the field names match the json serializer ones
+	var got map[string]any
+	require.NoError(t, msgpack.Unmarshal(b, &got))
+	require.Equal(t, "U", got["action"])
+	require.Equal(t, "0/1", got["lsn"])
+	require.Equal(t, "test", got["table"])
+	require.EqualValues(t, 5, got["xid"])
+	require.EqualValues(t, 2, got["seq"])
+
+	columns, ok := got["columns"].([]any)
+	require.True(t, ok)
+	require.Len(t, columns, 3)
+	require.Equal(t, map[string]any{"id": "col-2", "name": "name", "type": "text", "value": "alice"}, columns[1])
+	require.Nil(t, columns[2].(map[string]any)["value"])
+
+	metadata, ok := got["metadata"].(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, testSchemaID.String(), metadata["schema_id"])
+	require.Equal(t, "table-1", metadata["table_pgstream_id"])
+	require.Equal(t, []any{"col-1"}, metadata["id_col_pgstream_id"])
+}
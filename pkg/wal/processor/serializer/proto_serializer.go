@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package serializer
+
+import (
+	"fmt"
+
+	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/wal"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ProtoSerializer encodes the wal data in the protobuf wire format, following
+// the proto3 definition below:
+//
+//	message Data {
+//	  string action = 1;
+//	  string timestamp = 2;
+//	  string lsn = 3;
+//	  string schema = 4;
+//	  string table = 5;
+//	  repeated Column columns = 6;
+//	  repeated Column identity = 7;
+//	  Metadata metadata = 8;
+//	  uint32 xid = 9;
+//	  uint64 seq = 10;
+//	}
+//
+//	message Column {
+//	  string id = 1;
+//	  string name = 2;
+//	  string type = 3;
+//	  bytes value = 4;
+//	}
+//
+//	message Metadata {
+//	  string schema_id = 1;
+//	  string table_pgstream_id = 2;
+//	  repeated string id_col_pgstream_id = 3;
+//	  string version_col_pgstream_id = 4;
+//	}
+//
+// Column values can be of any type, so they're JSON encoded. Null values are
+// not included in the message.
+type ProtoSerializer struct{}
+
+const protoContentType = "application/x-protobuf"
+
+// Data fields
+const (
+	dataActionField    protowire.Number = 1
+	dataTimestampField protowire.Number = 2
+	dataLSNField       protowire.Number = 3
+	dataSchemaField    protowire.Number = 4
+	dataTableField     protowire.Number = 5
+	dataColumnsField   protowire.Number = 6
+	dataIdentityField  protowire.Number = 7
+	dataMetadataField  protowire.Number = 8
+	dataXIDField       protowire.Number = 9
+	dataSeqField       protowire.Number = 10
+)
+
+// Column fields
+const (
+	columnIDField    protowire.Number = 1
+	columnNameField  protowire.Number = 2
+	columnTypeField  protowire.Number = 3
+	columnValueField protowire.Number = 4
+)
+
+// Metadata fields
+const (
+	metadataSchemaIDField           protowire.Number = 1
+	metadataTablePgstreamIDField    protowire.Number = 2
+	metadataInternalColIDsField     protowire.Number = 3
+	metadataInternalColVersionField protowire.Number = 4
+)
+
+func NewProtoSerializer() *ProtoSerializer {
+	return &ProtoSerializer{}
+}
+
+func (s *ProtoSerializer) Serialize(data *wal.Data) ([]byte, error) {
+	b := make([]byte, 0, wal.EstimatedSize(*data))
+	b = appendString(b, dataActionField, data.Action)
+	b = appendString(b, dataTimestampField, data.Timestamp)
+	b = appendString(b, dataLSNField, data.LSN)
+	b = appendString(b, dataSchemaField, data.Schema)
+	b = appendString(b, dataTableField, data.Table)
+
+	var err error
+	for _, col := range data.Columns {
+		if b, err = appendColumn(b, dataColumnsField, col); err != nil {
+			return nil, err
+		}
+	}
+	for _, col := range data.Identity {
+		if b, err = appendColumn(b, dataIdentityField, col); err != nil {
+			return nil, err
+		}
+	}
+
+	if metadata := encodeMetadata(data.Metadata); len(metadata) > 0 {
+		b = protowire.AppendTag(b, dataMetadataField, protowire.BytesType)
+		b = protowire.AppendBytes(b, metadata)
+	}
+	if data.XID > 0 {
+		b = protowire.AppendTag(b, dataXIDField, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(data.XID))
+	}
+	if data.Seq > 0 {
+		b = protowire.AppendTag(b, dataSeqField, protowire.VarintType)
+		b = protowire.AppendVarint(b, data.Seq)
+	}
+	return b, nil
+}
+
+func (s *ProtoSerializer) ContentType() string {
+	return protoContentType
+}
+
+func appendColumn(b []byte, field protowire.Number, col wal.Column) ([]byte, error) {
+	c := appendString(nil, columnIDField, col.ID)
+	c = appendString(c, columnNameField, col.Name)
+	c = appendString(c, columnTypeField, col.Type)
+	if col.Value != nil {
+		value, err := json.Marshal(col.Value)
+		if err != nil {
+			return nil, fmt.Errorf("encoding column %s value: %w", col.Name, err)
+		}
+		c = protowire.AppendTag(c, columnValueField, protowire.BytesType)
+		c = protowire.AppendBytes(c, value)
+	}
+
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendBytes(b, c), nil
+}
+
+func encodeMetadata(m wal.Metadata) []byte {
+	var b []byte
+	if !m.SchemaID.IsNil() {
+		b = appendString(b, metadataSchemaIDField, m.SchemaID.String())
+	}
+	b = appendString(b, metadataTablePgstreamIDField, m.TablePgstreamID)
+	for _, id := range m.InternalColIDs {
+		// repeated values are included even if empty, to keep their position
+		b = protowire.AppendTag(b, metadataInternalColIDsField, protowire.BytesType)
+		b = protowire.AppendString(b, id)
+	}
+	return appendString(b, metadataInternalColVersionField, m.InternalColVersion)
+}
+
+// appendString appends the string field on input, unless it's empty, which is
+// the proto3 default value.
+func appendString(b []byte, field protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package serializer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// testField is a decoded protobuf field, with the value of the bytes and
+// varint wire types.
+type testField struct {
+	number protowire.Number
+	bytes  []byte
+	varint uint64
+}
+
+func decodeTestFields(t *testing.T, b []byte) []testField {
+	t.Helper()
+	fields := []testField{}
+	for len(b) > 0 {
+		number, wireType, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		field := testField{number: number}
+		switch wireType {
+		case protowire.BytesType:
+			field.bytes, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			field.varint, n = protowire.ConsumeVarint(b)
+		default:
+			require.Failf(t, "unexpected wire type", "%v", wireType)
+		}
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+func TestProtoSerializer_Serialize(t *testing.T) {
+	t.Parallel()
+
+	s := NewProtoSerializer()
+	require.Equal(t, "application/x-protobuf", s.ContentType())
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+
+		b, err := s.Serialize(newTestData())
+		require.NoError(t, err)
+
+		fields := decodeTestFields(t, b)
+		require.Len(t, fields, 12)
+		require.Equal(t, testField{number: dataActionField, bytes: []byte("U")}, fields[0])
+		require.Equal(t, testField{number: dataTimestampField, bytes: []byte("2024-01-02 03:04:05.123+00")}, fields[1])
+		require.Equal(t, testField{number: dataLSNField, bytes: []byte("0/1")}, fields[2])
+		require.Equal(t, testField{number: dataSchemaField, bytes: []byte("public")}, fields[3])
+		require.Equal(t, testField{number: dataTableField, bytes: []byte("test")}, fields[4])
+		require.Equal(t, testField{number: dataXIDField, varint: 5}, fields[10])
+		require.Equal(t, testField{number: dataSeqField, varint: 2}, fields[11])
+
+		require.Equal(t, dataColumnsField, fields[5].number)
+		require.Equal(t, []testField{
+			{number: columnIDField, bytes: []byte("col-2")},
+			{number: columnNameField, bytes: []byte("name")},
+			{number: columnTypeField, bytes: []byte("text")},
+			{number: columnValueField, bytes: []byte(`"alice"`)},
+		}, decodeTestFields(t, fields[6].bytes))
+		// null values are not included
+		require.Equal(t, []testField{
+			{number: columnIDField, bytes: []byte("col-3")},
+			{number: columnNameField, bytes: []byte("deleted_at")},
+			{number: columnTypeField, bytes: []byte("timestamptz")},
+		}, decodeTestFields(t, fields[7].bytes))
+		require.Equal(t, dataIdentityField, fields[8].number)
+
+		require.Equal(t, dataMetadataField, fields[9].number)
+		require.Equal(t, []testField{
+			{number: metadataSchemaIDField, bytes: []byte(testSchemaID.String())},
+			{number: metadataTablePgstreamIDField, bytes: []byte("table-1")},
+			{number: metadataInternalColIDsField, bytes: []byte("col-1")},
+			{number: metadataInternalColVersionField, bytes: []byte("col-2")},
+		}, decodeTestFields(t, fields[9].bytes))
+	})
+
+	t.Run("ok - default values omitted", func(t *testing.T) {
+		t.Parallel()
+
+		b, err := s.Serialize(&wal.Data{Action: "T", Schema: "public", Table: "test"})
+		require.NoError(t, err)
+		require.Equal(t, []testField{
+			{number: dataActionField, bytes: []byte("T")},
+			{number: dataSchemaField, bytes: []byte("public")},
+			{number: dataTableField, bytes: []byte("test")},
+		}, decodeTestFields(t, b))
+	})
+
+	t.Run("error - encoding column value", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := s.Serialize(&wal.Data{
+			Columns: []wal.Column{{Name: "invalid", Value: make(chan int)}},
+		})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,37 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package serializer defines the wire format used by the sinks to encode the
+// wal data before sending it to their targets.
+package serializer
+
+import (
+	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// Serializer encodes the wal data into the sink wire format. Sink
+// implementors can provide their own to customise the format of the messages.
+type Serializer interface {
+	Serialize(data *wal.Data) ([]byte, error)
+	// ContentType is the media type of the serialised data, which the sinks
+	// include in the message metadata when their targets support it.
+	ContentType() string
+}
+
+// JSONSerializer encodes the wal data as JSON. It's the default serializer of
+// the sinks.
+type JSONSerializer struct{}
+
+const jsonContentType = "application/json"
+
+func NewJSONSerializer() *JSONSerializer {
+	return &JSONSerializer{}
+}
+
+func (s *JSONSerializer) Serialize(data *wal.Data) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+func (s *JSONSerializer) ContentType() string {
+	return jsonContentType
+}
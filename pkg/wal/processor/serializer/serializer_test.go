@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package serializer
+
+import (
+	"testing"
+
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+var testSchemaID = xid.New()
+
+func newTestData() *wal.Data {
+	return &wal.Data{
+		Action:    "U",
+		Timestamp: "2024-01-02 03:04:05.123+00",
+		LSN:       "0/1",
+		Schema:    "public",
+		Table:     "test",
+		Columns: []wal.Column{
+			{ID: "col-1", Name: "id", Type: "integer", Value: int64(1)},
+			{ID: "col-2", Name: "name", Type: "text", Value: "alice"},
+			{ID: "col-3", Name: "deleted_at", Type: "timestamptz", Value: nil},
+		},
+		Identity: []wal.Column{
+			{ID: "col-1", Name: "id", Type: "integer", Value: int64(1)},
+		},
+		Metadata: wal.Metadata{
+			SchemaID:           testSchemaID,
+			TablePgstreamID:    "table-1",
+			InternalColIDs:     []string{"col-1"},
+			InternalColVersion: "col-2",
+		},
+		XID: 5,
+		Seq: 2,
+	}
+}
+
+func TestJSONSerializer_Serialize(t *testing.T) {
+	t.Parallel()
+
+	s := NewJSONSerializer()
+	b, err := s.Serialize(newTestData())
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"action":"U",
+		"timestamp":"2024-01-02 03:04:05.123+00",
+		"lsn":"0/1",
+		"schema":"public",
+		"table":"test",
+		"columns":[
+			{"id":"col-1","name":"id","type":"integer","value":1},
+			{"id":"col-2","name":"name","type":"text","value":"alice"},
+			{"id":"col-3","name":"deleted_at","type":"timestamptz","value":null}
+		],
+		"identity":[{"id":"col-1","name":"id","type":"integer","value":1}],
+		"metadata":{
+			"schema_id":"`+testSchemaID.String()+`",
+			"table_pgstream_id":"table-1",
+			"id_col_pgstream_id":["col-1"],
+			"version_col_pgstream_id":"col-2"
+		},
+		"xid":5,
+		"seq":2
+	}`, string(b))
+	require.Equal(t, "application/json", s.ContentType())
+}
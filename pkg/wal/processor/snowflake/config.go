@@ -0,0 +1,235 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package snowflake
+
+import (
+	"strings"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/backoff"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+)
+
+type Config struct {
+	// DSN is the Snowflake connection string, in the format supported by the
+	// driver (<user>:<password>@<account>/<database>/<schema>?<params>).
+	DSN string
+	// DriverName is the name the database/sql Snowflake driver is registered
+	// with. Defaults to "snowflake", the name used by
+	// github.com/snowflakedb/gosnowflake, which needs to be imported by the
+	// binary so that the driver is registered.
+	DriverName string
+	// Warehouse is the virtual warehouse used to run the statements. If not
+	// provided, the default warehouse of the user is used. The name is
+	// quoted, so warehouses created without quotes need to be provided in
+	// upper case. The same applies to the role and session parameters.
+	Warehouse string
+	// WarehouseAutoResume enables the auto resume of the warehouse and
+	// resumes it on startup if it's suspended, so that loads don't fail while
+	// the warehouse is suspended. Requires the warehouse to be set.
+	WarehouseAutoResume bool
+	// Role is the role used to run the statements. If not provided, the
+	// default role of the user is used.
+	Role string
+	// SessionParameters are the session parameters set before the
+	// statements of each batch are run, such as QUERY_TAG or TIMEZONE.
+	SessionParameters map[string]string
+	// Stage is the internal stage the JSON files are uploaded to before being
+	// bulk loaded into the tables. Defaults to the user stage, @~/pgstream.
+	Stage string
+	// Schema and Table are the templates of the schema and name of the target
+	// table of each source table. The {schema} and {table} placeholders are
+	// replaced with the name of the source schema and table. Default to
+	// {schema} and {table}.
+	Schema string
+	Table  string
+	// Mode is how the changes are written to the tables. Defaults to merge.
+	Mode Mode
+	// PrivateKeyFile is the path to the PEM encoded, unencrypted, RSA private
+	// key used for the key pair authentication of the user. When set, the
+	// key is added to the DSN. Required in streaming mode.
+	PrivateKeyFile string
+	// Streaming configures the Snowpipe Streaming mode.
+	Streaming StreamingConfig
+	// RetryPolicy is the backoff policy used to retry the flushes and
+	// requests that fail. Defaults to an exponential backoff.
+	RetryPolicy backoff.Config
+
+	Batch batch.Config
+}
+
+type StreamingConfig struct {
+	// Account is the account identifier, in the organization-account format
+	// (myorg-myaccount) or as the account locator, with its region when
+	// needed (xy12345.us-east-1). Required in streaming mode.
+	Account string
+	// User is the name of the user the key pair belongs to. Required in
+	// streaming mode.
+	User string
+	// Database is the database of the target tables, which the Snowpipe
+	// Streaming requests need even when it's part of the DSN. Required in
+	// streaming mode.
+	Database string
+	// Pipe is the template of the pipe the rows of each table are streamed
+	// through, with the same placeholders as the table template, and the
+	// {target_table} placeholder replaced with the name of the target table.
+	// Defaults to the default pipe of the target table,
+	// {target_table}-STREAMING.
+	Pipe string
+	// Channel is the name of the channel opened on each pipe. Defaults to
+	// pgstream.
+	Channel string
+	// CommitTimeout is how long to wait for the rows of a batch to be
+	// committed to the tables before failing the batch. Defaults to 1
+	// minute.
+	CommitTimeout time.Duration
+	// Endpoint overrides the url of the account, which defaults to
+	// https://<account>.snowflakecomputing.com.
+	Endpoint string
+}
+
+// Mode is the way the changes are written to the Snowflake tables.
+type Mode string
+
+const (
+	// ModeMerge stages the changes of each table in a batch as a compressed
+	// NDJSON file, loads it into a temporary table with COPY INTO, and
+	// applies it to the table with a single MERGE INTO statement, upserting
+	// or deleting the rows by their identity. The LSN of the last change of
+	// each row is kept in the _pgstream_lsn column, so that flushes retried
+	// after a failure don't apply older changes. The inserts into tables
+	// without identity are appended with COPY INTO.
+	ModeMerge Mode = "merge"
+	// ModeStreaming appends every change to the table as a new row through
+	// Snowpipe Streaming, with the operation and the LSN of the change in the
+	// _pgstream_op and _pgstream_lsn columns. The LSN is the offset token of
+	// the channels, so that the rows already committed are skipped when a
+	// channel is reopened.
+	ModeStreaming Mode = "streaming"
+)
+
+const (
+	defaultDriverName = "snowflake"
+	defaultStage      = "@~/pgstream"
+
+	schemaPlaceholder      = "{schema}"
+	tablePlaceholder       = "{table}"
+	targetTablePlaceholder = "{target_table}"
+
+	defaultPipeTemplate  = targetTablePlaceholder + "-STREAMING"
+	defaultChannel       = "pgstream"
+	defaultCommitTimeout = time.Minute
+
+	defaultRetryInitialInterval = time.Second
+	defaultRetryMaxInterval     = time.Minute
+	defaultRetryMaxRetries      = 10
+)
+
+func (c *Config) driverName() string {
+	if c.DriverName != "" {
+		return c.DriverName
+	}
+	return defaultDriverName
+}
+
+func (c *Config) stage() string {
+	switch {
+	case c.Stage == "":
+		return defaultStage
+	case strings.HasPrefix(c.Stage, "@"):
+		return c.Stage
+	default:
+		return "@" + c.Stage
+	}
+}
+
+func (c *Config) mode() Mode {
+	if c.Mode != "" {
+		return c.Mode
+	}
+	return ModeMerge
+}
+
+func (c *Config) schemaTemplate() string {
+	if c.Schema != "" {
+		return c.Schema
+	}
+	return schemaPlaceholder
+}
+
+func (c *Config) tableTemplate() string {
+	if c.Table != "" {
+		return c.Table
+	}
+	return tablePlaceholder
+}
+
+func (c *Config) retryPolicy() *backoff.Config {
+	if c.RetryPolicy.DisableRetries {
+		return &backoff.Config{DisableRetries: true}
+	}
+	if c.RetryPolicy.Constant != nil || c.RetryPolicy.Exponential != nil {
+		return &c.RetryPolicy
+	}
+	return &backoff.Config{
+		Exponential: &backoff.ExponentialConfig{
+			InitialInterval: defaultRetryInitialInterval,
+			MaxInterval:     defaultRetryMaxInterval,
+			MaxRetries:      defaultRetryMaxRetries,
+		},
+	}
+}
+
+func (c *StreamingConfig) pipeTemplate() string {
+	if c.Pipe != "" {
+		return c.Pipe
+	}
+	return defaultPipeTemplate
+}
+
+func (c *StreamingConfig) channel() string {
+	if c.Channel != "" {
+		return c.Channel
+	}
+	return defaultChannel
+}
+
+func (c *StreamingConfig) commitTimeout() time.Duration {
+	if c.CommitTimeout > 0 {
+		return c.CommitTimeout
+	}
+	return defaultCommitTimeout
+}
+
+func (c *StreamingConfig) endpoint() string {
+	if c.Endpoint != "" {
+		return strings.TrimSuffix(c.Endpoint, "/")
+	}
+	return "https://" + strings.ToLower(c.Account) + ".snowflakecomputing.com"
+}
+
+// tableNamer resolves the schema, table and pipe templates for the source
+// tables.
+type tableNamer struct {
+	schemaTemplate string
+	tableTemplate  string
+	pipeTemplate   string
+}
+
+func (n *tableNamer) table(schema, name string) table {
+	replacer := strings.NewReplacer(schemaPlaceholder, schema, tablePlaceholder, name)
+	return table{
+		schema: replacer.Replace(n.schemaTemplate),
+		name:   replacer.Replace(n.tableTemplate),
+	}
+}
+
+// pipe returns the name of the pipe of the source table on input, which
+// writes to the target table on input.
+func (n *tableNamer) pipe(schema, name string, target table) string {
+	return strings.NewReplacer(
+		schemaPlaceholder, schema,
+		tablePlaceholder, name,
+		targetTablePlaceholder, target.name,
+	).Replace(n.pipeTemplate)
+}
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package snowflake
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyPairAuth signs the JWTs of the Snowflake key pair authentication. The
+// token is reused until it's about to expire.
+type keyPairAuth struct {
+	// qualifiedUser is the account and user of the token claims, in the
+	// ACCOUNT.USER format
+	qualifiedUser string
+	fingerprint   string
+	key           *rsa.PrivateKey
+	now           func() time.Time
+
+	mutex  sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+const (
+	// jwtLifetime is the lifetime of the signed tokens, within the one hour
+	// limit accepted by Snowflake
+	jwtLifetime = 59 * time.Minute
+	// jwtRefreshMargin is how long before its expiry a token is replaced
+	jwtRefreshMargin = 5 * time.Minute
+
+	keyPairTokenType = "KEYPAIR_JWT"
+)
+
+var errInvalidPrivateKey = errors.New("invalid snowflake private key")
+
+func newKeyPairAuth(account, user string, key *rsa.PrivateKey) (*keyPairAuth, error) {
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidPrivateKey, err)
+	}
+	digest := sha256.Sum256(publicKey)
+
+	return &keyPairAuth{
+		qualifiedUser: jwtAccount(account) + "." + strings.ToUpper(user),
+		fingerprint:   "SHA256:" + base64.StdEncoding.EncodeToString(digest[:]),
+		key:           key,
+		now:           time.Now,
+	}, nil
+}
+
+// jwt returns a valid token, signing a new one when needed.
+func (a *keyPairAuth) jwt() (string, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	now := a.now()
+	if a.token != "" && now.Add(jwtRefreshMargin).Before(a.expiry) {
+		return a.token, nil
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	expiry := now.Add(jwtLifetime)
+	claims, err := json.Marshal(map[string]any{
+		"iss": a.qualifiedUser + "." + a.fingerprint,
+		"sub": a.qualifiedUser,
+		"iat": now.Unix(),
+		"exp": expiry.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing snowflake token: %w", err)
+	}
+	a.token, a.expiry = unsigned+"."+base64.RawURLEncoding.EncodeToString(signature), expiry
+	return a.token, nil
+}
+
+// parsePrivateKey parses the PEM encoded RSA private key on input, in PKCS8
+// or PKCS1 format. Encrypted keys are not supported.
+func parsePrivateKey(pemKey []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errInvalidPrivateKey
+	}
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		return nil, fmt.Errorf("%w: encrypted keys are not supported", errInvalidPrivateKey)
+	}
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		key, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%w: not an RSA key", errInvalidPrivateKey)
+		}
+		return key, nil
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidPrivateKey, err)
+	}
+	return key, nil
+}
+
+// dsnWithPrivateKey returns the DSN on input with the parameters of the key
+// pair authentication of the driver, which expects the key as base64 URL
+// encoded PKCS8.
+func dsnWithPrivateKey(dsn string, key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errInvalidPrivateKey, err)
+	}
+	params := url.Values{
+		"authenticator": {"SNOWFLAKE_JWT"},
+		"privateKey":    {base64.URLEncoding.EncodeToString(der)},
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return dsn + separator + params.Encode(), nil
+}
+
+// jwtAccount returns the account identifier as expected in the token claims,
+// upper case and without the region and cloud of the account locators.
+func jwtAccount(account string) string {
+	account, _, _ = strings.Cut(account, ".")
+	return strings.ToUpper(account)
+}
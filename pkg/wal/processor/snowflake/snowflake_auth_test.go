@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package snowflake
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func TestKeyPairAuth_jwt(t *testing.T) {
+	t.Parallel()
+
+	key := newTestKey(t)
+	auth, err := newKeyPairAuth("xy12345.us-east-1", "loader", key)
+	require.NoError(t, err)
+	now := time.Unix(1700000000, 0)
+	auth.now = func() time.Time { return now }
+
+	token, err := auth.jwt()
+	require.NoError(t, err)
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	claims := map[string]any{}
+	require.NoError(t, json.Unmarshal(claimsJSON, &claims))
+	require.Equal(t, "XY12345.LOADER."+auth.fingerprint, claims["iss"])
+	require.Equal(t, "XY12345.LOADER", claims["sub"])
+	require.Equal(t, float64(now.Unix()), claims["iat"])
+	require.Equal(t, float64(now.Add(jwtLifetime).Unix()), claims["exp"])
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	digest := sha256.Sum256(publicKey)
+	require.Equal(t, "SHA256:"+base64.StdEncoding.EncodeToString(digest[:]), auth.fingerprint)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	signed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	require.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, signed[:], signature))
+
+	// the token is reused until it's about to expire
+	now = now.Add(jwtLifetime - jwtRefreshMargin - time.Minute)
+	cached, err := auth.jwt()
+	require.NoError(t, err)
+	require.Equal(t, token, cached)
+
+	now = now.Add(time.Minute)
+	refreshed, err := auth.jwt()
+	require.NoError(t, err)
+	require.NotEqual(t, token, refreshed)
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	t.Parallel()
+
+	key := newTestKey(t)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		pemKey []byte
+
+		wantErr error
+	}{
+		{
+			name:   "ok - pkcs8",
+			pemKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		},
+		{
+			name:   "ok - pkcs1",
+			pemKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+		{
+			name:    "error - encrypted key",
+			pemKey:  pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: pkcs8}),
+			wantErr: errInvalidPrivateKey,
+		},
+		{
+			name:    "error - not pem",
+			pemKey:  []byte("invalid"),
+			wantErr: errInvalidPrivateKey,
+		},
+		{
+			name:    "error - invalid key",
+			pemKey:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("invalid")}),
+			wantErr: errInvalidPrivateKey,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parsePrivateKey(tc.pemKey)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr == nil {
+				require.True(t, key.Equal(got))
+			}
+		})
+	}
+}
+
+func TestDSNWithPrivateKey(t *testing.T) {
+	t.Parallel()
+
+	key := newTestKey(t)
+
+	tests := []struct {
+		name string
+		dsn  string
+
+		wantPrefix string
+	}{
+		{
+			name:       "ok",
+			dsn:        "loader@myorg-myaccount/analytics/public",
+			wantPrefix: "loader@myorg-myaccount/analytics/public?",
+		},
+		{
+			name:       "ok - with parameters",
+			dsn:        "loader@myorg-myaccount/analytics/public?warehouse=loader_wh",
+			wantPrefix: "loader@myorg-myaccount/analytics/public?warehouse=loader_wh&",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dsn, err := dsnWithPrivateKey(tc.dsn, key)
+			require.NoError(t, err)
+			require.True(t, strings.HasPrefix(dsn, tc.wantPrefix), dsn)
+
+			params, err := url.ParseQuery(strings.TrimPrefix(dsn, tc.wantPrefix))
+			require.NoError(t, err)
+			require.Equal(t, "SNOWFLAKE_JWT", params.Get("authenticator"))
+			der, err := base64.URLEncoding.DecodeString(params.Get("privateKey"))
+			require.NoError(t, err)
+			parsed, err := x509.ParsePKCS8PrivateKey(der)
+			require.NoError(t, err)
+			require.True(t, key.Equal(parsed))
+		})
+	}
+}
+
+func TestJWTAccount(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "MYORG-MYACCOUNT", jwtAccount("myorg-myaccount"))
+	require.Equal(t, "XY12345", jwtAccount("xy12345.us-east-1.aws"))
+}
@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package snowflake
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// apiError is an error returned by the Snowpipe Streaming API, with the http
+// status of the response.
+type apiError struct {
+	statusCode int
+	// code is the Snowflake error code of the response, if any
+	code    string
+	message string
+}
+
+// errorBody is the body of the error responses of the Snowflake APIs.
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// retryableStatusCodes are the http status codes of the responses caused by
+// transient failures or rate limits, which succeed when retried
+var retryableStatusCodes = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("%d %s", e.statusCode, http.StatusText(e.statusCode))
+	if e.code != "" {
+		msg += " (" + e.code + ")"
+	}
+	if e.message != "" {
+		msg += ": " + e.message
+	}
+	return msg
+}
+
+func (e *apiError) retryable() bool {
+	return slices.Contains(retryableStatusCodes, e.statusCode)
+}
+
+// isPermanent returns true if the error on input won't succeed when retried.
+// Errors that are not returned by the API, such as connection errors or the
+// errors of the driver, are considered transient.
+func isPermanent(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && !apiErr.retryable()
+}
+
+// newHTTPError returns the error of the response on input. The body is kept as
+// the message when it's not a Snowflake error.
+func newHTTPError(statusCode int, body []byte) *apiError {
+	apiErr := &apiError{statusCode: statusCode, message: strings.TrimSpace(string(body))}
+	b := &errorBody{}
+	if err := json.Unmarshal(body, b); err == nil && (b.Code != "" || b.Message != "") {
+		apiErr.code, apiErr.message = b.Code, b.Message
+	}
+	return apiErr
+}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package snowflake
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+type query struct {
+	sql  string
+	args []any
+}
+
+// table is the schema qualified name of a target table. Identifiers are
+// quoted, which makes them case sensitive in Snowflake, so the target tables
+// need to be created with the same case as in postgres.
+type table struct {
+	schema string
+	name   string
+}
+
+const (
+	// opColumn and lsnColumn are the columns with the operation and the LSN
+	// of the changes. The target tables get the LSN of the last change of
+	// each row in merge mode, and both columns in streaming mode.
+	opColumn  = "_pgstream_op"
+	lsnColumn = "_pgstream_lsn"
+	// seqColumn is the column of the staging tables with the order of the
+	// changes within a flush, to order the changes with the same LSN
+	seqColumn = "_pgstream_seq"
+	// stagingSuffix is appended to the name of the tables to name their
+	// staging tables
+	stagingSuffix = "_pgstream_staging"
+
+	lsnColumnType = "NUMBER(20,0)"
+	opColumnType  = "VARCHAR"
+)
+
+func (t table) String() string {
+	return quoteIdentifier(t.schema) + "." + quoteIdentifier(t.name)
+}
+
+// staging returns the staging table of the table.
+func (t table) staging() table {
+	return table{schema: t.schema, name: t.name + stagingSuffix}
+}
+
+// putQuery uploads the local file on input to the stage. Files are compressed
+// by the driver before they're uploaded, which adds the .gz extension.
+func putQuery(filePath, stage string) query {
+	return query{
+		sql: fmt.Sprintf("PUT %s %s AUTO_COMPRESS = TRUE OVERWRITE = TRUE", quoteString("file://"+filePath), stage),
+	}
+}
+
+// copyQuery bulk loads the staged JSON file on input into the table, matching
+// the keys of the JSON objects with the column names. The file is removed from
+// the stage once it's loaded.
+func copyQuery(t table, stage, fileName string) query {
+	return query{
+		sql: fmt.Sprintf("COPY INTO %s FROM %s FILES = (%s) FILE_FORMAT = (TYPE = 'JSON') MATCH_BY_COLUMN_NAME = CASE_SENSITIVE PURGE = TRUE",
+			t, stage, quoteString(fileName)),
+	}
+}
+
+// stagingQueries return the statements that create the temporary table the
+// changes of the table on input are loaded into before being merged. The
+// staging table has the columns of the table, and the operation and the
+// position of each change. It's replaced on every flush, so that it matches
+// the current columns of the table and is empty.
+func stagingQueries(t table) []query {
+	staging := t.staging()
+	return []query{
+		{sql: fmt.Sprintf("CREATE OR REPLACE TEMPORARY TABLE %s LIKE %s", staging, t)},
+		{sql: fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s VARCHAR, %s NUMBER", staging, quoteIdentifier(opColumn), quoteIdentifier(seqColumn))},
+	}
+}
+
+// stagingCopyQuery loads the staged JSON file on input into the staging table
+// of the table. The load is forced, so that a file loaded by a retried flush
+// isn't skipped.
+func stagingCopyQuery(t table, stage, fileName string) query {
+	return query{
+		sql: fmt.Sprintf("COPY INTO %s FROM %s FILES = (%s) FILE_FORMAT = (TYPE = 'JSON') MATCH_BY_COLUMN_NAME = CASE_SENSITIVE PURGE = TRUE FORCE = TRUE",
+			t.staging(), stage, quoteString(fileName)),
+	}
+}
+
+// mergeQuery applies the changes of the staging table to the table. Only the
+// last change of each row is applied, and only if it's not older than the
+// change the row was last written with, so that applying the same changes
+// again is a no-op. The columns are the ones set by the inserts and updates.
+func mergeQuery(t table, columns, primaryKey []string) query {
+	lsn := quoteIdentifier(lsnColumn)
+	on := make([]string, 0, len(primaryKey))
+	for _, pk := range primaryKey {
+		on = append(on, fmt.Sprintf("target.%s = source.%s", quoteIdentifier(pk), quoteIdentifier(pk)))
+	}
+	isDelete := fmt.Sprintf("source.%s = '%s'", quoteIdentifier(opColumn), wal.ActionDelete)
+	isNewer := fmt.Sprintf("(target.%s IS NULL OR source.%s >= target.%s)", lsn, lsn, lsn)
+
+	sql := fmt.Sprintf("MERGE INTO %s AS target USING (SELECT * FROM %s QUALIFY ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s DESC, %s DESC) = 1) AS source ON %s WHEN MATCHED AND %s AND %s THEN DELETE",
+		t, t.staging(), quoteIdentifiers(primaryKey), lsn, quoteIdentifier(seqColumn), strings.Join(on, " AND "), isDelete, isNewer)
+	if len(columns) == 0 {
+		return query{sql: sql}
+	}
+
+	sets := make([]string, 0, len(columns)+1)
+	names := make([]string, 0, len(columns)+1)
+	values := make([]string, 0, len(columns)+1)
+	for _, col := range append(slices.Clone(columns), lsnColumn) {
+		name := quoteIdentifier(col)
+		if !slices.Contains(primaryKey, col) {
+			sets = append(sets, fmt.Sprintf("target.%s = source.%s", name, name))
+		}
+		names = append(names, name)
+		values = append(values, "source."+name)
+	}
+	sql += fmt.Sprintf(" WHEN MATCHED AND NOT %s AND %s THEN UPDATE SET %s WHEN NOT MATCHED AND NOT %s THEN INSERT (%s) VALUES (%s)",
+		isDelete, isNewer, strings.Join(sets, ", "), isDelete, strings.Join(names, ", "), strings.Join(values, ", "))
+	return query{sql: sql}
+}
+
+func truncateQuery(t table) query {
+	return query{sql: fmt.Sprintf("TRUNCATE TABLE %s", t)}
+}
+
+// addColumnQuery adds the column on input to the table, unless it already
+// exists.
+func addColumnQuery(t table, name, columnType string) query {
+	return query{sql: fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", t, quoteIdentifier(name), columnType)}
+}
+
+// sessionQueries set the warehouse, role and session parameters on input for
+// the statements that follow. The parameters are set in alphabetical order.
+// The names are quoted, so they're case sensitive: objects created without
+// quotes need to be provided in upper case.
+func sessionQueries(warehouse, role string, parameters map[string]string) []query {
+	queries := []query{}
+	if warehouse != "" {
+		queries = append(queries, query{sql: "USE WAREHOUSE " + quoteIdentifier(warehouse)})
+	}
+	if role != "" {
+		queries = append(queries, query{sql: "USE ROLE " + quoteIdentifier(role)})
+	}
+	for _, name := range slices.Sorted(maps.Keys(parameters)) {
+		queries = append(queries, query{sql: fmt.Sprintf("ALTER SESSION SET %s = %s", quoteIdentifier(name), quoteString(parameters[name]))})
+	}
+	return queries
+}
+
+// rowJSON returns the JSON object for the columns on input, as loaded by the
+// COPY INTO statement. json and jsonb values are embedded as JSON so that they
+// are loaded as objects into the VARIANT columns.
+func rowJSON(columns []wal.Column) ([]byte, error) {
+	return json.Marshal(rowObject(columns))
+}
+
+// changeJSON returns the JSON object of the change on input, with its
+// operation and LSN. The sequence of the change is included when it's
+// staged to be merged.
+func changeJSON(r row, seq *int) ([]byte, error) {
+	obj := rowObject(r.columns)
+	obj[opColumn] = r.op
+	obj[lsnColumn] = r.lsn
+	if seq != nil {
+		obj[seqColumn] = *seq
+	}
+	return json.Marshal(obj)
+}
+
+func rowObject(columns []wal.Column) map[string]any {
+	row := make(map[string]any, len(columns)+3)
+	for _, col := range columns {
+		row[col.Name] = col.Value
+		if !isVariant(col.Type) {
+			continue
+		}
+		if s, ok := col.Value.(string); ok && json.Valid([]byte(s)) {
+			row[col.Name] = json.RawMessage(s)
+		}
+	}
+	return row
+}
+
+func isVariant(columnType string) bool {
+	return columnType == "json" || columnType == "jsonb"
+}
+
+func quoteIdentifier(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, quoteIdentifier(name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
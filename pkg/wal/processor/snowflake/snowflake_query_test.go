@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package snowflake
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestMergeQuery(t *testing.T) {
+	t.Parallel()
+
+	testTable := table{schema: "public", name: "users"}
+
+	tests := []struct {
+		name       string
+		columns    []string
+		primaryKey []string
+
+		wantQuery query
+	}{
+		{
+			name:       "ok",
+			columns:    []string{"id", "name"},
+			primaryKey: []string{"id"},
+
+			wantQuery: query{
+				sql: `MERGE INTO "public"."users" AS target USING (SELECT * FROM "public"."users_pgstream_staging" ` +
+					`QUALIFY ROW_NUMBER() OVER (PARTITION BY "id" ORDER BY "_pgstream_lsn" DESC, "_pgstream_seq" DESC) = 1) AS source ` +
+					`ON target."id" = source."id" ` +
+					`WHEN MATCHED AND source."_pgstream_op" = 'D' AND (target."_pgstream_lsn" IS NULL OR source."_pgstream_lsn" >= target."_pgstream_lsn") THEN DELETE ` +
+					`WHEN MATCHED AND NOT source."_pgstream_op" = 'D' AND (target."_pgstream_lsn" IS NULL OR source."_pgstream_lsn" >= target."_pgstream_lsn") ` +
+					`THEN UPDATE SET target."name" = source."name", target."_pgstream_lsn" = source."_pgstream_lsn" ` +
+					`WHEN NOT MATCHED AND NOT source."_pgstream_op" = 'D' ` +
+					`THEN INSERT ("id", "name", "_pgstream_lsn") VALUES (source."id", source."name", source."_pgstream_lsn")`,
+			},
+		},
+		{
+			name:       "ok - composite primary key",
+			columns:    []string{"tenant", "id"},
+			primaryKey: []string{"tenant", "id"},
+
+			wantQuery: query{
+				sql: `MERGE INTO "public"."users" AS target USING (SELECT * FROM "public"."users_pgstream_staging" ` +
+					`QUALIFY ROW_NUMBER() OVER (PARTITION BY "tenant", "id" ORDER BY "_pgstream_lsn" DESC, "_pgstream_seq" DESC) = 1) AS source ` +
+					`ON target."tenant" = source."tenant" AND target."id" = source."id" ` +
+					`WHEN MATCHED AND source."_pgstream_op" = 'D' AND (target."_pgstream_lsn" IS NULL OR source."_pgstream_lsn" >= target."_pgstream_lsn") THEN DELETE ` +
+					`WHEN MATCHED AND NOT source."_pgstream_op" = 'D' AND (target."_pgstream_lsn" IS NULL OR source."_pgstream_lsn" >= target."_pgstream_lsn") ` +
+					`THEN UPDATE SET target."_pgstream_lsn" = source."_pgstream_lsn" ` +
+					`WHEN NOT MATCHED AND NOT source."_pgstream_op" = 'D' ` +
+					`THEN INSERT ("tenant", "id", "_pgstream_lsn") VALUES (source."tenant", source."id", source."_pgstream_lsn")`,
+			},
+		},
+		{
+			name:       "ok - only deletes",
+			columns:    []string{},
+			primaryKey: []string{"id"},
+
+			wantQuery: query{
+				sql: `MERGE INTO "public"."users" AS target USING (SELECT * FROM "public"."users_pgstream_staging" ` +
+					`QUALIFY ROW_NUMBER() OVER (PARTITION BY "id" ORDER BY "_pgstream_lsn" DESC, "_pgstream_seq" DESC) = 1) AS source ` +
+					`ON target."id" = source."id" ` +
+					`WHEN MATCHED AND source."_pgstream_op" = 'D' AND (target."_pgstream_lsn" IS NULL OR source."_pgstream_lsn" >= target."_pgstream_lsn") THEN DELETE`,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantQuery, mergeQuery(testTable, tc.columns, tc.primaryKey))
+		})
+	}
+}
+
+func TestStagingQueries(t *testing.T) {
+	t.Parallel()
+
+	testTable := table{schema: "public", name: `my"table`}
+
+	require.Equal(t, []query{
+		{sql: `CREATE OR REPLACE TEMPORARY TABLE "public"."my""table_pgstream_staging" LIKE "public"."my""table"`},
+		{sql: `ALTER TABLE "public"."my""table_pgstream_staging" ADD COLUMN "_pgstream_op" VARCHAR, "_pgstream_seq" NUMBER`},
+	}, stagingQueries(testTable))
+
+	require.Equal(t, query{
+		sql: `COPY INTO "public"."my""table_pgstream_staging" FROM @~/pgstream FILES = ('pgstream_1.json.gz') FILE_FORMAT = (TYPE = 'JSON') MATCH_BY_COLUMN_NAME = CASE_SENSITIVE PURGE = TRUE FORCE = TRUE`,
+	}, stagingCopyQuery(testTable, "@~/pgstream", "pgstream_1.json.gz"))
+}
+
+func TestSessionQueries(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		warehouse  string
+		role       string
+		parameters map[string]string
+
+		wantQueries []query
+	}{
+		{
+			name:        "ok - defaults",
+			wantQueries: []query{},
+		},
+		{
+			name:      "ok",
+			warehouse: "LOADER_WH",
+			role:      "LOADER",
+			parameters: map[string]string{
+				"TIMEZONE":  "UTC",
+				"QUERY_TAG": "pgstream's",
+			},
+			wantQueries: []query{
+				{sql: `USE WAREHOUSE "LOADER_WH"`},
+				{sql: `USE ROLE "LOADER"`},
+				{sql: `ALTER SESSION SET "QUERY_TAG" = 'pgstream''s'`},
+				{sql: `ALTER SESSION SET "TIMEZONE" = 'UTC'`},
+			},
+		},
+		{
+			name:      "ok - names with quotes and separators",
+			warehouse: `my "wh"; DROP TABLE t`,
+			role:      "loader role",
+			wantQueries: []query{
+				{sql: `USE WAREHOUSE "my ""wh""; DROP TABLE t"`},
+				{sql: `USE ROLE "loader role"`},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantQueries, sessionQueries(tc.warehouse, tc.role, tc.parameters))
+		})
+	}
+}
+
+func TestStageQueries(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, query{
+		sql: `PUT 'file:///tmp/pgstream_1.json' @~/pgstream AUTO_COMPRESS = TRUE OVERWRITE = TRUE`,
+	}, putQuery("/tmp/pgstream_1.json", "@~/pgstream"))
+
+	require.Equal(t, query{
+		sql: `COPY INTO "public"."users" FROM @~/pgstream FILES = ('pgstream_1.json.gz') FILE_FORMAT = (TYPE = 'JSON') MATCH_BY_COLUMN_NAME = CASE_SENSITIVE PURGE = TRUE`,
+	}, copyQuery(table{schema: "public", name: "users"}, "@~/pgstream", "pgstream_1.json.gz"))
+}
+
+func TestRowJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		columns []wal.Column
+
+		wantJSON string
+	}{
+		{
+			name: "ok",
+			columns: []wal.Column{
+				{Name: "id", Type: "integer", Value: 1},
+				{Name: "name", Type: "text", Value: `{"not": "json"}`},
+				{Name: "doc", Type: "jsonb", Value: `{"a": [1, 2]}`},
+				{Name: "empty", Type: "jsonb", Value: nil},
+			},
+			wantJSON: `{"doc":{"a":[1,2]},"empty":null,"id":1,"name":"{\"not\": \"json\"}"}`,
+		},
+		{
+			name: "ok - invalid json value",
+			columns: []wal.Column{
+				{Name: "doc", Type: "json", Value: "{invalid"},
+			},
+			wantJSON: `{"doc":"{invalid"}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			row, err := rowJSON(tc.columns)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.wantJSON, string(row))
+		})
+	}
+}
+
+func TestChangeJSON(t *testing.T) {
+	t.Parallel()
+
+	testRow := row{
+		op:  "U",
+		lsn: 42,
+		columns: []wal.Column{
+			{Name: "id", Type: "integer", Value: 1},
+			{Name: "doc", Type: "jsonb", Value: `{"a":1}`},
+		},
+	}
+	seq := 3
+
+	tests := []struct {
+		name string
+		row  row
+		seq  *int
+
+		wantJSON string
+	}{
+		{
+			name:     "ok",
+			row:      testRow,
+			wantJSON: `{"id":1,"doc":{"a":1},"_pgstream_op":"U","_pgstream_lsn":42}`,
+		},
+		{
+			name:     "ok - with sequence",
+			row:      testRow,
+			seq:      &seq,
+			wantJSON: `{"id":1,"doc":{"a":1},"_pgstream_op":"U","_pgstream_lsn":42,"_pgstream_seq":3}`,
+		},
+		{
+			name:     "ok - truncate",
+			row:      row{op: "T", lsn: 42},
+			wantJSON: `{"_pgstream_op":"T","_pgstream_lsn":42}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := changeJSON(tc.row, tc.seq)
+			require.NoError(t, err)
+			require.JSONEq(t, tc.wantJSON, string(got))
+		})
+	}
+}
@@ -0,0 +1,255 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package snowflake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamingClient writes rows to the tables through Snowpipe Streaming
+// channels.
+type streamingClient interface {
+	// openChannel opens the channel, or reopens it if it was already open,
+	// invalidating the previous continuation tokens
+	openChannel(ctx context.Context, ref channelRef) (*channelState, error)
+	// appendRows appends the NDJSON rows on input to the channel, and returns
+	// the continuation token of the next append. The offset token is
+	// committed alongside the rows.
+	appendRows(ctx context.Context, ref channelRef, continuationToken, offsetToken string, rows []byte) (string, error)
+	// committedOffset returns the offset token of the last rows of the
+	// channel committed to the table
+	committedOffset(ctx context.Context, ref channelRef) (string, error)
+}
+
+// channelRef identifies a Snowpipe Streaming channel.
+type channelRef struct {
+	database string
+	schema   string
+	pipe     string
+	channel  string
+}
+
+// channelState is the state of an open channel.
+type channelState struct {
+	continuationToken string
+	committedOffset   string
+}
+
+// httpStreamingClient uses the Snowpipe Streaming REST API. The requests are
+// sent to the ingest host of the account, with a token scoped to it which is
+// exchanged for the key pair JWT.
+type httpStreamingClient struct {
+	client  *http.Client
+	baseURL string
+	auth    *keyPairAuth
+	now     func() time.Time
+
+	mutex       sync.Mutex
+	ingestURL   string
+	token       string
+	tokenExpiry time.Time
+}
+
+type openChannelResponse struct {
+	NextContinuationToken string               `json:"next_continuation_token"`
+	ChannelStatus         channelStatusPayload `json:"channel_status"`
+}
+
+type appendRowsResponse struct {
+	NextContinuationToken string `json:"next_continuation_token"`
+}
+
+type channelStatusRequest struct {
+	ChannelNames []string `json:"channel_names"`
+}
+
+type channelStatusResponse struct {
+	ChannelStatuses map[string]channelStatusPayload `json:"channel_statuses"`
+}
+
+type channelStatusPayload struct {
+	LastCommittedOffsetToken *string `json:"last_committed_offset_token"`
+}
+
+const (
+	// scopedTokenLifetime is how long the scoped tokens are reused, within
+	// the lifetime of the tokens returned by Snowflake
+	scopedTokenLifetime = 30 * time.Minute
+	jwtBearerGrant      = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+	oauthTokenType      = "OAUTH"
+	userAgent           = "pgstream"
+)
+
+func newHTTPStreamingClient(client *http.Client, baseURL string, auth *keyPairAuth) *httpStreamingClient {
+	return &httpStreamingClient{
+		client:  client,
+		baseURL: baseURL,
+		auth:    auth,
+		now:     time.Now,
+	}
+}
+
+func (c *httpStreamingClient) openChannel(ctx context.Context, ref channelRef) (*channelState, error) {
+	resp := &openChannelResponse{}
+	if err := c.do(ctx, http.MethodPut, channelPath(ref), "application/json", []byte("{}"), resp); err != nil {
+		return nil, err
+	}
+	state := &channelState{continuationToken: resp.NextContinuationToken}
+	if resp.ChannelStatus.LastCommittedOffsetToken != nil {
+		state.committedOffset = *resp.ChannelStatus.LastCommittedOffsetToken
+	}
+	return state, nil
+}
+
+func (c *httpStreamingClient) appendRows(ctx context.Context, ref channelRef, continuationToken, offsetToken string, rows []byte) (string, error) {
+	query := url.Values{"continuationToken": {continuationToken}, "offsetToken": {offsetToken}}
+	path := fmt.Sprintf("/v2/streaming/data/databases/%s/schemas/%s/pipes/%s/channels/%s/rows?%s",
+		url.PathEscape(ref.database), url.PathEscape(ref.schema), url.PathEscape(ref.pipe), url.PathEscape(ref.channel), query.Encode())
+	resp := &appendRowsResponse{}
+	if err := c.do(ctx, http.MethodPost, path, "application/x-ndjson", rows, resp); err != nil {
+		return "", err
+	}
+	return resp.NextContinuationToken, nil
+}
+
+func (c *httpStreamingClient) committedOffset(ctx context.Context, ref channelRef) (string, error) {
+	body, err := json.Marshal(&channelStatusRequest{ChannelNames: []string{ref.channel}})
+	if err != nil {
+		return "", err
+	}
+	path := fmt.Sprintf("/v2/streaming/databases/%s/schemas/%s/pipes/%s:bulk-channel-status",
+		url.PathEscape(ref.database), url.PathEscape(ref.schema), url.PathEscape(ref.pipe))
+	resp := &channelStatusResponse{}
+	if err := c.do(ctx, http.MethodPost, path, "application/json", body, resp); err != nil {
+		return "", err
+	}
+	status, found := resp.ChannelStatuses[ref.channel]
+	if !found {
+		return "", fmt.Errorf("missing status of channel %s", ref.channel)
+	}
+	if status.LastCommittedOffsetToken == nil {
+		return "", nil
+	}
+	return *status.LastCommittedOffsetToken, nil
+}
+
+func (c *httpStreamingClient) do(ctx context.Context, method, path, contentType string, body []byte, result any) error {
+	ingestURL, token, err := c.scopedToken(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieving snowpipe streaming token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, ingestURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Snowflake-Authorization-Token-Type", oauthTokenType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		// the scoped token expired, retry with a new one
+		c.mutex.Lock()
+		c.token = ""
+		c.mutex.Unlock()
+		return &apiError{statusCode: http.StatusServiceUnavailable, message: newHTTPError(resp.StatusCode, b).Error()}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newHTTPError(resp.StatusCode, b)
+	}
+	if err := json.Unmarshal(b, result); err != nil {
+		return fmt.Errorf("parsing response: %w", err)
+	}
+	return nil
+}
+
+// scopedToken returns the url of the ingest host of the account and a token
+// scoped to it. The ingest host uses the same scheme as the account url.
+func (c *httpStreamingClient) scopedToken(ctx context.Context) (string, string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	jwt, err := c.auth.jwt()
+	if err != nil {
+		return "", "", err
+	}
+	if c.ingestURL == "" {
+		host, err := c.accountRequest(ctx, http.MethodGet, "/v2/streaming/hostname", jwt, nil)
+		if err != nil {
+			return "", "", fmt.Errorf("retrieving ingest host: %w", err)
+		}
+		scheme, _, _ := strings.Cut(c.baseURL, "://")
+		c.ingestURL = scheme + "://" + host
+	}
+	if c.token != "" && c.now().Before(c.tokenExpiry) {
+		return c.ingestURL, c.token, nil
+	}
+
+	_, host, _ := strings.Cut(c.ingestURL, "://")
+	form := url.Values{"grant_type": {jwtBearerGrant}, "scope": {host}, "assertion": {jwt}}
+	token, err := c.accountRequest(ctx, http.MethodPost, "/oauth/token", jwt, form)
+	if err != nil {
+		return "", "", fmt.Errorf("exchanging key pair token: %w", err)
+	}
+	c.token, c.tokenExpiry = token, c.now().Add(scopedTokenLifetime)
+	return c.ingestURL, c.token, nil
+}
+
+// accountRequest sends a request authenticated with the key pair JWT to the
+// account url, and returns its plain text response.
+func (c *httpStreamingClient) accountRequest(ctx context.Context, method, path, jwt string, form url.Values) (string, error) {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return "", err
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("X-Snowflake-Authorization-Token-Type", keyPairTokenType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", newHTTPError(resp.StatusCode, b)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+func channelPath(ref channelRef) string {
+	return fmt.Sprintf("/v2/streaming/databases/%s/schemas/%s/pipes/%s/channels/%s",
+		url.PathEscape(ref.database), url.PathEscape(ref.schema), url.PathEscape(ref.pipe), url.PathEscape(ref.channel))
+}
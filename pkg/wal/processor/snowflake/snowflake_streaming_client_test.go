@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package snowflake
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testChannelRef = channelRef{database: "analytics", schema: "public", pipe: "users-STREAMING", channel: "pgstream"}
+
+// fakeStreamingServer serves both the account and the ingest host requests of
+// the Snowpipe Streaming API.
+type fakeStreamingServer struct {
+	t       *testing.T
+	server  *httptest.Server
+	handler func(w http.ResponseWriter, r *http.Request, body []byte)
+
+	mutex         sync.Mutex
+	tokenRequests int
+}
+
+func newFakeStreamingServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request, body []byte)) *fakeStreamingServer {
+	f := &fakeStreamingServer{t: t, handler: handler}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serveHTTP))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeStreamingServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	require.NoError(f.t, err)
+	require.Equal(f.t, userAgent, r.Header.Get("User-Agent"))
+
+	switch r.URL.Path {
+	case "/v2/streaming/hostname":
+		require.Equal(f.t, keyPairTokenType, r.Header.Get("X-Snowflake-Authorization-Token-Type"))
+		w.Write([]byte(strings.TrimPrefix(f.server.URL, "http://")))
+	case "/oauth/token":
+		require.Equal(f.t, keyPairTokenType, r.Header.Get("X-Snowflake-Authorization-Token-Type"))
+		form, err := url.ParseQuery(string(body))
+		require.NoError(f.t, err)
+		require.Equal(f.t, jwtBearerGrant, form.Get("grant_type"))
+		require.Equal(f.t, strings.TrimPrefix(f.server.URL, "http://"), form.Get("scope"))
+		require.Equal(f.t, strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), form.Get("assertion"))
+		f.mutex.Lock()
+		f.tokenRequests++
+		f.mutex.Unlock()
+		w.Write([]byte("scoped-token"))
+	default:
+		require.Equal(f.t, "Bearer scoped-token", r.Header.Get("Authorization"))
+		require.Equal(f.t, oauthTokenType, r.Header.Get("X-Snowflake-Authorization-Token-Type"))
+		f.handler(w, r, body)
+	}
+}
+
+func newTestStreamingClient(t *testing.T, server *fakeStreamingServer) *httpStreamingClient {
+	auth, err := newKeyPairAuth("myorg-myaccount", "loader", newTestKey(t))
+	require.NoError(t, err)
+	return newHTTPStreamingClient(server.server.Client(), server.server.URL, auth)
+}
+
+func TestHTTPStreamingClient_openChannel(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeStreamingServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		require.Equal(t, http.MethodPut, r.Method)
+		require.Equal(t, "/v2/streaming/databases/analytics/schemas/public/pipes/users-STREAMING/channels/pgstream", r.URL.Path)
+		require.JSONEq(t, `{}`, string(body))
+		w.Write([]byte(`{"next_continuation_token":"token-0","channel_status":{"last_committed_offset_token":"42"}}`))
+	})
+	client := newTestStreamingClient(t, server)
+
+	state, err := client.openChannel(context.Background(), testChannelRef)
+	require.NoError(t, err)
+	require.Equal(t, &channelState{continuationToken: "token-0", committedOffset: "42"}, state)
+
+	// the scoped token is reused
+	_, err = client.openChannel(context.Background(), testChannelRef)
+	require.NoError(t, err)
+	require.Equal(t, 1, server.tokenRequests)
+}
+
+func TestHTTPStreamingClient_appendRows(t *testing.T) {
+	t.Parallel()
+
+	server := newFakeStreamingServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/v2/streaming/data/databases/analytics/schemas/public/pipes/users-STREAMING/channels/pgstream/rows", r.URL.Path)
+		require.Equal(t, "token-0", r.URL.Query().Get("continuationToken"))
+		require.Equal(t, "42", r.URL.Query().Get("offsetToken"))
+		require.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+		require.Equal(t, `{"id":1}`+"\n"+`{"id":2}`, string(body))
+		w.Write([]byte(`{"next_continuation_token":"token-1"}`))
+	})
+	client := newTestStreamingClient(t, server)
+
+	token, err := client.appendRows(context.Background(), testChannelRef, "token-0", "42", []byte(`{"id":1}`+"\n"+`{"id":2}`))
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+}
+
+func TestHTTPStreamingClient_committedOffset(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		response string
+
+		wantOffset string
+		wantErr    bool
+	}{
+		{
+			name:       "ok",
+			response:   `{"channel_statuses":{"pgstream":{"last_committed_offset_token":"42"}}}`,
+			wantOffset: "42",
+		},
+		{
+			name:       "ok - nothing committed",
+			response:   `{"channel_statuses":{"pgstream":{}}}`,
+			wantOffset: "",
+		},
+		{
+			name:     "error - missing channel",
+			response: `{"channel_statuses":{}}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newFakeStreamingServer(t, func(w http.ResponseWriter, r *http.Request, body []byte) {
+				require.Equal(t, http.MethodPost, r.Method)
+				require.Equal(t, "/v2/streaming/databases/analytics/schemas/public/pipes/users-STREAMING:bulk-channel-status", r.URL.Path)
+				req := channelStatusRequest{}
+				require.NoError(t, json.Unmarshal(body, &req))
+				require.Equal(t, []string{"pgstream"}, req.ChannelNames)
+				w.Write([]byte(tc.response))
+			})
+			client := newTestStreamingClient(t, server)
+
+			offset, err := client.committedOffset(context.Background(), testChannelRef)
+			require.Equal(t, tc.wantErr, err != nil, err)
+			require.Equal(t, tc.wantOffset, offset)
+		})
+	}
+}
+
+func TestHTTPStreamingClient_errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		statusCode int
+		body       string
+
+		wantPermanent  bool
+		wantErrMsg     string
+		wantTokenReset bool
+	}{
+		{
+			name:          "bad request",
+			statusCode:    http.StatusBadRequest,
+			body:          `{"code":"ERR_PIPE_DOES_NOT_EXIST","message":"pipe does not exist"}`,
+			wantPermanent: true,
+			wantErrMsg:    "pipe does not exist",
+		},
+		{
+			name:       "too many requests",
+			statusCode: http.StatusTooManyRequests,
+			body:       "slow down",
+			wantErrMsg: "slow down",
+		},
+		{
+			name:           "unauthorized",
+			statusCode:     http.StatusUnauthorized,
+			body:           "token expired",
+			wantErrMsg:     "token expired",
+			wantTokenReset: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newFakeStreamingServer(t, func(w http.ResponseWriter, _ *http.Request, _ []byte) {
+				w.WriteHeader(tc.statusCode)
+				w.Write([]byte(tc.body))
+			})
+			client := newTestStreamingClient(t, server)
+
+			_, err := client.openChannel(context.Background(), testChannelRef)
+			require.ErrorContains(t, err, tc.wantErrMsg)
+			require.Equal(t, tc.wantPermanent, isPermanent(err))
+			require.Equal(t, tc.wantTokenReset, client.token == "")
+		})
+	}
+}
@@ -0,0 +1,829 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package snowflake
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/xataio/pgstream/pkg/backoff"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+// SnowflakeSink is a wal processor that writes the events to Snowflake tables.
+// Depending on the mode, the changes of each table in a batch are either
+// staged as a JSON file and merged into the table, or appended to the table
+// through Snowpipe Streaming. Schema changes are not replicated, so the target
+// tables need to exist, with the same column names as the source tables. The
+// columns with the operation and LSN of the changes are added by the sink.
+type SnowflakeSink struct {
+	logger            loglib.Logger
+	batchSender       batchSender
+	instrumentation   *otel.Instrumentation
+	db                db
+	streamingClient   streamingClient
+	backoffProvider   backoff.Provider
+	lsnParser         replication.LSNParser
+	namer             *tableNamer
+	mode              Mode
+	stage             string
+	warehouse         string
+	role              string
+	sessionParameters map[string]string
+	// tempDir is the directory the JSON files are written to before they're
+	// uploaded to the stage
+	tempDir string
+
+	// database and channel identify the Snowpipe Streaming channels, together
+	// with the schema and pipe of each table
+	database           string
+	channel            string
+	commitTimeout      time.Duration
+	commitPollInterval time.Duration
+
+	// tables keeps the state of the target tables. It's only used when sending
+	// a batch, which doesn't happen concurrently.
+	tables map[table]*tableState
+
+	// optional checkpointer callback to mark what was safely processed
+	checkpointer checkpointer.Checkpoint
+}
+
+type Option func(*SnowflakeSink)
+
+type batchSender interface {
+	SendMessage(context.Context, *batch.WALMessage[message]) error
+	Close()
+}
+
+type db interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PingContext(ctx context.Context) error
+	Close() error
+}
+
+// message has the rows written to a table for a single event
+type message struct {
+	data  *wal.Data
+	table table
+	pipe  string
+	// primaryKey are the names of the columns the rows are merged by. Rows
+	// without primary key are appended in merge mode.
+	primaryKey []string
+	rows       []row
+}
+
+// row is a change to a single row of a table, with the operation and the LSN
+// of the change. Deletes only have the identity columns of the row, and
+// truncates no columns.
+type row struct {
+	op      string
+	lsn     uint64
+	columns []wal.Column
+}
+
+type tableState struct {
+	// prepared is true once the metadata columns have been added to the table
+	prepared bool
+	// channel is the open Snowpipe Streaming channel of the table, if any
+	channel *channelState
+}
+
+// pendingRows are the rows of a table waiting to be flushed
+type pendingRows struct {
+	pipe       string
+	primaryKey []string
+	rows       []row
+}
+
+// encodedRow is a JSON encoded row, with the LSN used as its offset token
+type encodedRow struct {
+	lsn  uint64
+	line []byte
+}
+
+const (
+	// maxAppendRequestBytes is the max size of the rows of a Snowpipe
+	// Streaming append request, below the 16MB limit of the API
+	maxAppendRequestBytes = 4 * 1024 * 1024
+
+	defaultCommitPollInterval = time.Second
+)
+
+var (
+	errMissingDSN             = errors.New("missing snowflake dsn")
+	errMissingWarehouse       = errors.New("warehouse auto resume requires the warehouse to be set")
+	errDriverNotRegistered    = errors.New("snowflake driver not registered, import github.com/snowflakedb/gosnowflake to register it")
+	errMissingIdentityColumns = errors.New("missing identity columns")
+	errUnsupportedMode        = errors.New("unsupported snowflake mode")
+	errMissingAccount         = errors.New("streaming mode requires the snowflake account")
+	errMissingUser            = errors.New("streaming mode requires the snowflake user")
+	errMissingDatabase        = errors.New("streaming mode requires the snowflake database")
+	errMissingPrivateKey      = errors.New("streaming mode requires the private key file")
+	errCommitTimeout          = errors.New("timed out waiting for the rows to be committed")
+)
+
+func NewSnowflakeSink(ctx context.Context, cfg *Config, opts ...Option) (*SnowflakeSink, error) {
+	if cfg.DSN == "" {
+		return nil, errMissingDSN
+	}
+	if cfg.WarehouseAutoResume && cfg.Warehouse == "" {
+		return nil, errMissingWarehouse
+	}
+	mode := cfg.mode()
+	switch mode {
+	case ModeMerge:
+	case ModeStreaming:
+		switch {
+		case cfg.Streaming.Account == "":
+			return nil, errMissingAccount
+		case cfg.Streaming.User == "":
+			return nil, errMissingUser
+		case cfg.Streaming.Database == "":
+			return nil, errMissingDatabase
+		case cfg.PrivateKeyFile == "":
+			return nil, errMissingPrivateKey
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", errUnsupportedMode, mode)
+	}
+	driverName := cfg.driverName()
+	if !slices.Contains(sql.Drivers(), driverName) {
+		return nil, fmt.Errorf("%w: %q", errDriverNotRegistered, driverName)
+	}
+
+	s := &SnowflakeSink{
+		logger:             loglib.NewNoopLogger(),
+		backoffProvider:    backoff.NewProvider(cfg.retryPolicy()),
+		lsnParser:          pgreplication.NewLSNParser(),
+		mode:               mode,
+		stage:              cfg.stage(),
+		warehouse:          cfg.Warehouse,
+		role:               cfg.Role,
+		sessionParameters:  cfg.SessionParameters,
+		tempDir:            os.TempDir(),
+		database:           cfg.Streaming.Database,
+		channel:            cfg.Streaming.channel(),
+		commitTimeout:      cfg.Streaming.commitTimeout(),
+		commitPollInterval: defaultCommitPollInterval,
+		namer: &tableNamer{
+			schemaTemplate: cfg.schemaTemplate(),
+			tableTemplate:  cfg.tableTemplate(),
+			pipeTemplate:   cfg.Streaming.pipeTemplate(),
+		},
+		tables: map[table]*tableState{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	dsn := cfg.DSN
+	if cfg.PrivateKeyFile != "" {
+		pemKey, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading snowflake private key: %w", err)
+		}
+		key, err := parsePrivateKey(pemKey)
+		if err != nil {
+			return nil, err
+		}
+		if dsn, err = dsnWithPrivateKey(dsn, key); err != nil {
+			return nil, err
+		}
+		if mode == ModeStreaming {
+			auth, err := newKeyPairAuth(cfg.Streaming.Account, cfg.Streaming.User, key)
+			if err != nil {
+				return nil, err
+			}
+			s.streamingClient = newHTTPStreamingClient(&http.Client{}, cfg.Streaming.endpoint(), auth)
+		}
+	}
+
+	sqlDB, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening snowflake connection: %w", err)
+	}
+	// the uploaded files, the session setup and the staging tables are scoped
+	// to the session, so all the statements are run on the same connection
+	sqlDB.SetMaxOpenConns(1)
+	s.db = sqlDB
+
+	if cfg.WarehouseAutoResume {
+		if err := s.resumeWarehouse(ctx); err != nil {
+			s.db.Close()
+			return nil, err
+		}
+	}
+
+	s.batchSender, err = batch.NewSender(ctx, &cfg.Batch, s.sendBatch, s.logger, batch.WithInstrumentation(s.instrumentation, s.Name()))
+	if err != nil {
+		s.db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(s *SnowflakeSink) {
+		s.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "snowflake_sink",
+		})
+	}
+}
+
+func WithCheckpoint(c checkpointer.Checkpoint) Option {
+	return func(s *SnowflakeSink) {
+		s.checkpointer = c
+	}
+}
+
+// WithInstrumentation reports the metrics of the batches sent by the sink.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(s *SnowflakeSink) {
+		s.instrumentation = i
+	}
+}
+
+// ProcessWALEvent is called on every new message from the wal. It can be called
+// concurrently.
+func (s *SnowflakeSink) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (retErr error) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Panic("[PANIC] Panic while processing replication event", loglib.Fields{
+				"wal_data":    walEvent,
+				"panic":       r,
+				"stack_trace": debug.Stack(),
+			})
+
+			retErr = fmt.Errorf("snowflake sink: understanding event: %v", r)
+		}
+	}()
+
+	msg, err := s.newMessage(walEvent.Data)
+	if err != nil {
+		return err
+	}
+
+	return s.batchSender.SendMessage(ctx, batch.NewWALMessage(msg, walEvent.CommitPosition))
+}
+
+func (s *SnowflakeSink) Name() string {
+	return "snowflake-sink"
+}
+
+func (s *SnowflakeSink) Close() error {
+	s.batchSender.Close()
+	return s.db.Close()
+}
+
+func (s *SnowflakeSink) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// newMessage returns the message for the event data on input. Events that
+// aren't row changes, such as schema changes, are not written, but their
+// position is still checkpointed. Updates that change the identity of the row
+// delete the row with the old identity, so that the old row isn't kept when
+// the changes are merged.
+func (s *SnowflakeSink) newMessage(data *wal.Data) (message, error) {
+	if data == nil || processor.IsSchemaLogEvent(data) {
+		return message{}, nil
+	}
+
+	action := wal.Action(data.Action)
+	identity := identityColumns(data)
+	switch action {
+	case wal.ActionInsert, wal.ActionTruncate:
+	case wal.ActionUpdate, wal.ActionDelete:
+		if len(identity) == 0 {
+			s.logger.Warn(errMissingIdentityColumns, "snowflake sink: skipping event, the table needs a replica identity", loglib.Fields{
+				"schema": data.Schema,
+				"table":  data.Table,
+				"action": data.Action,
+			})
+			return message{}, nil
+		}
+	default:
+		return message{}, nil
+	}
+
+	lsn, err := s.lsnParser.FromString(data.LSN)
+	if err != nil {
+		return message{}, fmt.Errorf("snowflake sink: parsing event lsn %q: %w", data.LSN, err)
+	}
+
+	t := s.namer.table(data.Schema, data.Table)
+	msg := message{
+		data:       data,
+		table:      t,
+		pipe:       s.namer.pipe(data.Schema, data.Table, t),
+		primaryKey: columnNames(identity),
+	}
+	switch action {
+	case wal.ActionInsert:
+		msg.rows = []row{{op: string(action), lsn: uint64(lsn), columns: data.Columns}}
+	case wal.ActionUpdate:
+		if identityChanged(data) {
+			msg.rows = append(msg.rows, row{op: string(wal.ActionDelete), lsn: uint64(lsn), columns: identity})
+		}
+		msg.rows = append(msg.rows, row{op: string(action), lsn: uint64(lsn), columns: data.Columns})
+	case wal.ActionDelete:
+		msg.rows = []row{{op: string(action), lsn: uint64(lsn), columns: identity}}
+	case wal.ActionTruncate:
+		msg.rows = []row{{op: string(action), lsn: uint64(lsn)}}
+	}
+	return msg, nil
+}
+
+func (s *SnowflakeSink) sendBatch(ctx context.Context, batch *batch.Batch[message]) error {
+	messages := batch.GetMessages()
+	s.logger.Debug("snowflake sink: writing message batch", loglib.Fields{
+		"batch_size":             len(messages),
+		"batch_commit_positions": len(batch.GetCommitPositions()),
+	})
+
+	if len(messages) > 0 {
+		if err := s.writeMessages(ctx, messages); err != nil {
+			s.logger.Error(err, "failed to write to snowflake")
+			return fmt.Errorf("snowflake sink: %w", err)
+		}
+	}
+
+	positions := batch.GetCommitPositions()
+	if s.checkpointer != nil && len(positions) > 0 {
+		if err := s.checkpointer(ctx, positions); err != nil {
+			s.logger.Warn(err, "snowflake sink: error updating commit position")
+		}
+	}
+
+	return nil
+}
+
+// writeMessages groups the rows of the messages on input per table, and
+// flushes the rows of each table in the order the tables were first written
+// to. In merge mode, the rows of a table are flushed before the table is
+// truncated, or before rows with a different primary key are added.
+func (s *SnowflakeSink) writeMessages(ctx context.Context, messages []message) error {
+	if err := s.retry(ctx, func() error { return s.setupSession(ctx) }); err != nil {
+		return err
+	}
+
+	tables := []table{}
+	pending := map[table]*pendingRows{}
+	flush := func(t table) error {
+		p, found := pending[t]
+		if !found {
+			return nil
+		}
+		delete(pending, t)
+		tables = slices.DeleteFunc(tables, func(pending table) bool { return pending == t })
+		return s.flush(ctx, t, p)
+	}
+
+	for _, msg := range messages {
+		if s.mode == ModeMerge {
+			if wal.Action(msg.data.Action) == wal.ActionTruncate {
+				if err := flush(msg.table); err != nil {
+					return err
+				}
+				if err := s.retryStatements(ctx, func() error { return s.exec(ctx, truncateQuery(msg.table)) }); err != nil {
+					return err
+				}
+				continue
+			}
+			if p, found := pending[msg.table]; found && !slices.Equal(p.primaryKey, msg.primaryKey) {
+				if err := flush(msg.table); err != nil {
+					return err
+				}
+			}
+		}
+
+		p, found := pending[msg.table]
+		if !found {
+			p = &pendingRows{pipe: msg.pipe, primaryKey: msg.primaryKey}
+			pending[msg.table] = p
+			tables = append(tables, msg.table)
+		}
+		p.rows = append(p.rows, msg.rows...)
+	}
+
+	for _, t := range slices.Clone(tables) {
+		if err := flush(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SnowflakeSink) flush(ctx context.Context, t table, p *pendingRows) error {
+	if err := s.prepareTable(ctx, t); err != nil {
+		return err
+	}
+	switch {
+	case s.mode == ModeStreaming:
+		return s.streamRows(ctx, t, p)
+	case len(p.primaryKey) == 0:
+		return s.appendRows(ctx, t, p.rows)
+	default:
+		return s.mergeRows(ctx, t, p)
+	}
+}
+
+// prepareTable adds the metadata columns of the mode to the table, the first
+// time the table is written to.
+func (s *SnowflakeSink) prepareTable(ctx context.Context, t table) error {
+	state, found := s.tables[t]
+	if !found {
+		state = &tableState{}
+		s.tables[t] = state
+	}
+	if state.prepared {
+		return nil
+	}
+
+	queries := []query{addColumnQuery(t, lsnColumn, lsnColumnType)}
+	if s.mode == ModeStreaming {
+		queries = append(queries, addColumnQuery(t, opColumn, opColumnType))
+	}
+	for _, q := range queries {
+		if err := s.retryStatements(ctx, func() error { return s.exec(ctx, q) }); err != nil {
+			return fmt.Errorf("adding metadata columns to table %s: %w", t, err)
+		}
+	}
+	state.prepared = true
+	return nil
+}
+
+// mergeRows stages the rows on input and merges them into the table. The
+// whole sequence is retried when it fails, since the staging table is
+// replaced and the merge skips the changes older than the rows of the table.
+func (s *SnowflakeSink) mergeRows(ctx context.Context, t table, p *pendingRows) error {
+	columns := []string{}
+	lines := make([][]byte, 0, len(p.rows))
+	for i, r := range p.rows {
+		if r.op != string(wal.ActionDelete) {
+			for _, col := range r.columns {
+				if !slices.Contains(columns, col.Name) {
+					columns = append(columns, col.Name)
+				}
+			}
+		}
+		line, err := changeJSON(r, &i)
+		if err != nil {
+			return fmt.Errorf("marshalling row for table %s: %w", t, err)
+		}
+		lines = append(lines, line)
+	}
+
+	file, err := s.writeStagingFile(lines)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+
+	err = s.retryStatements(ctx, func() error {
+		queries := stagingQueries(t)
+		queries = append(queries,
+			putQuery(file, s.stage),
+			stagingCopyQuery(t, s.stage, filepath.Base(file)+".gz"),
+			mergeQuery(t, columns, p.primaryKey))
+		for _, q := range queries {
+			if err := s.exec(ctx, q); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("merging rows into table %s: %w", t, err)
+	}
+	return nil
+}
+
+// appendRows uploads the rows on input to the stage, and bulk loads them into
+// the table. It's used for the inserts into tables without identity, which
+// can't be merged, so a retried load can append the same rows twice.
+func (s *SnowflakeSink) appendRows(ctx context.Context, t table, rows []row) error {
+	lines := make([][]byte, 0, len(rows))
+	for _, r := range rows {
+		line, err := rowJSON(r.columns)
+		if err != nil {
+			return fmt.Errorf("marshalling row for table %s: %w", t, err)
+		}
+		lines = append(lines, line)
+	}
+	return s.retryStatements(ctx, func() error { return s.load(ctx, t, lines) })
+}
+
+// load uploads the rows on input to the stage as a newline delimited JSON file,
+// and bulk loads the file into the table.
+func (s *SnowflakeSink) load(ctx context.Context, t table, rows [][]byte) error {
+	file, err := s.writeStagingFile(rows)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file)
+
+	if err := s.exec(ctx, putQuery(file, s.stage)); err != nil {
+		return fmt.Errorf("staging rows for table %s: %w", t, err)
+	}
+	if err := s.exec(ctx, copyQuery(t, s.stage, filepath.Base(file)+".gz")); err != nil {
+		return fmt.Errorf("loading rows into table %s: %w", t, err)
+	}
+	return nil
+}
+
+// writeStagingFile writes the rows on input to a newline delimited JSON file,
+// and returns its path.
+func (s *SnowflakeSink) writeStagingFile(rows [][]byte) (string, error) {
+	file, err := os.CreateTemp(s.tempDir, "pgstream_*.json")
+	if err != nil {
+		return "", fmt.Errorf("creating staging file: %w", err)
+	}
+
+	_, err = file.Write(bytes.Join(rows, []byte("\n")))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("writing staging file: %w", err)
+	}
+	return file.Name(), nil
+}
+
+// streamRows appends the rows on input to the channel of the table, and waits
+// for them to be committed. The LSN of the last row of each request is its
+// offset token. When a request fails, the channel is reopened before retrying
+// it, and the rows already committed are skipped. Snapshot rows have no LSN,
+// so they can't be skipped.
+func (s *SnowflakeSink) streamRows(ctx context.Context, t table, p *pendingRows) error {
+	state := s.tables[t]
+	channel := channelRef{database: s.database, schema: t.schema, pipe: p.pipe, channel: s.channel}
+
+	encoded := make([]encodedRow, 0, len(p.rows))
+	for _, r := range p.rows {
+		line, err := changeJSON(r, nil)
+		if err != nil {
+			return fmt.Errorf("marshalling row for table %s: %w", t, err)
+		}
+		encoded = append(encoded, encodedRow{lsn: r.lsn, line: line})
+	}
+
+	var lastLSN uint64
+	for _, chunk := range chunkRows(encoded, maxAppendRequestBytes) {
+		err := s.retry(ctx, func() error {
+			if state.channel == nil {
+				ch, err := s.streamingClient.openChannel(ctx, channel)
+				if err != nil {
+					return fmt.Errorf("opening channel: %w", err)
+				}
+				state.channel = ch
+			}
+
+			rows := chunk
+			if committed, ok := parseOffset(state.channel.committedOffset); ok {
+				rows = slices.DeleteFunc(slices.Clone(chunk), func(r encodedRow) bool { return r.lsn != 0 && r.lsn <= committed })
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			offsetToken := strconv.FormatUint(rows[len(rows)-1].lsn, 10)
+			token, err := s.streamingClient.appendRows(ctx, channel, state.channel.continuationToken, offsetToken, joinRows(rows))
+			if err != nil {
+				state.channel = nil
+				return err
+			}
+			state.channel.continuationToken = token
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("appending rows to table %s: %w", t, err)
+		}
+		lastLSN = chunk[len(chunk)-1].lsn
+	}
+	return s.waitForCommit(ctx, t, channel, lastLSN)
+}
+
+// waitForCommit waits until the rows up to the LSN on input are committed to
+// the table of the channel.
+func (s *SnowflakeSink) waitForCommit(ctx context.Context, t table, channel channelRef, lsn uint64) error {
+	deadline := time.Now().Add(s.commitTimeout)
+	for {
+		var offset string
+		err := s.retry(ctx, func() error {
+			var err error
+			offset, err = s.streamingClient.committedOffset(ctx, channel)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("retrieving committed offset of table %s: %w", t, err)
+		}
+		if committed, ok := parseOffset(offset); ok && committed >= lsn {
+			if state := s.tables[t]; state.channel != nil {
+				state.channel.committedOffset = offset
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("table %s: %w", t, errCommitTimeout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(s.commitPollInterval):
+		}
+	}
+}
+
+// setupSession sets the warehouse, role and session parameters of the
+// session the statements are run on.
+func (s *SnowflakeSink) setupSession(ctx context.Context) error {
+	for _, q := range sessionQueries(s.warehouse, s.role, s.sessionParameters) {
+		if err := s.exec(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumeWarehouse enables the auto resume of the warehouse, and resumes it if
+// it's suspended.
+func (s *SnowflakeSink) resumeWarehouse(ctx context.Context) error {
+	for _, q := range []query{
+		{sql: fmt.Sprintf("ALTER WAREHOUSE %s SET AUTO_RESUME = TRUE", quoteIdentifier(s.warehouse))},
+		{sql: fmt.Sprintf("ALTER WAREHOUSE %s RESUME IF SUSPENDED", quoteIdentifier(s.warehouse))},
+	} {
+		if err := s.exec(ctx, q); err != nil {
+			return fmt.Errorf("resuming warehouse %s: %w", s.warehouse, err)
+		}
+	}
+	return nil
+}
+
+func (s *SnowflakeSink) exec(ctx context.Context, q query) error {
+	if _, err := s.db.ExecContext(ctx, q.sql, q.args...); err != nil {
+		return fmt.Errorf("executing %q: %w", q.sql, err)
+	}
+	return nil
+}
+
+// retryStatements runs the statements of the operation on input, retrying
+// them while they fail. The session is set up again before each retry, since
+// the failed statement might have been run on a connection that has been
+// replaced since.
+func (s *SnowflakeSink) retryStatements(ctx context.Context, op func() error) error {
+	attempt := 0
+	return s.retry(ctx, func() error {
+		attempt++
+		if attempt > 1 {
+			if err := s.setupSession(ctx); err != nil {
+				return err
+			}
+		}
+		return op()
+	})
+}
+
+// retry runs the operation on input, retrying it while it fails with transient
+// errors.
+func (s *SnowflakeSink) retry(ctx context.Context, op func() error) error {
+	return s.backoffProvider(ctx).RetryNotify(
+		func() error {
+			err := op()
+			switch {
+			case err == nil:
+				return nil
+			case isPermanent(err) || errors.Is(err, context.Canceled):
+				return fmt.Errorf("%w: %w", err, backoff.ErrPermanent)
+			}
+			return err
+		},
+		func(err error, d time.Duration) {
+			s.logger.Warn(err, "snowflake sink: request failed, retrying", loglib.Fields{
+				"retry_after": d,
+			})
+		})
+}
+
+// identityColumns returns the columns identifying the row of the event on
+// input, which are the replica identity columns, falling back to the primary
+// key columns identified by the injector.
+func identityColumns(data *wal.Data) []wal.Column {
+	if len(data.Identity) > 0 {
+		return data.Identity
+	}
+	pkColumns := []wal.Column{}
+	for _, col := range data.Columns {
+		if slices.Contains(data.Metadata.InternalColIDs, col.ID) {
+			pkColumns = append(pkColumns, col)
+		}
+	}
+	return pkColumns
+}
+
+// identityChanged returns true if the update on input sets new values for the
+// replica identity columns.
+func identityChanged(data *wal.Data) bool {
+	for _, identityCol := range data.Identity {
+		idx := slices.IndexFunc(data.Columns, func(col wal.Column) bool { return col.Name == identityCol.Name })
+		if idx == -1 || fmt.Sprint(data.Columns[idx].Value) != fmt.Sprint(identityCol.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func columnNames(columns []wal.Column) []string {
+	names := make([]string, 0, len(columns))
+	for _, col := range columns {
+		names = append(names, col.Name)
+	}
+	return names
+}
+
+// chunkRows splits the rows on input in chunks of up to the size on input.
+// Rows with the same LSN are kept in the same chunk, since the LSN of the last
+// row of a chunk is used to skip the rows already committed. Snapshot rows,
+// which have no LSN, are chunked by size only.
+func chunkRows(rows []encodedRow, maxBytes int) [][]encodedRow {
+	chunks := [][]encodedRow{}
+	start, size := 0, 0
+	for i, r := range rows {
+		if size+len(r.line) > maxBytes && i > start && (r.lsn == 0 || r.lsn != rows[i-1].lsn) {
+			chunks = append(chunks, rows[start:i])
+			start, size = i, 0
+		}
+		size += len(r.line) + 1
+	}
+	if start < len(rows) {
+		chunks = append(chunks, rows[start:])
+	}
+	return chunks
+}
+
+func joinRows(rows []encodedRow) []byte {
+	lines := make([][]byte, 0, len(rows))
+	for _, r := range rows {
+		lines = append(lines, r.line)
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// parseOffset returns the LSN of the offset token on input, if any.
+func parseOffset(token string) (uint64, bool) {
+	if token == "" {
+		return 0, false
+	}
+	lsn, err := strconv.ParseUint(token, 10, 64)
+	return lsn, err == nil
+}
+
+// Size returns an estimate of the size of the rows of the message, since the
+// rows are only encoded when the batch is sent.
+func (m message) Size() int {
+	size := 0
+	for _, r := range m.rows {
+		for _, col := range r.columns {
+			size += len(col.Name) + valueSize(col.Value)
+		}
+	}
+	return size
+}
+
+func (m message) IsEmpty() bool {
+	return m.data == nil
+}
+
+func valueSize(v any) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return len(v)
+	case []byte:
+		return len(v)
+	default:
+		return 8
+	}
+}
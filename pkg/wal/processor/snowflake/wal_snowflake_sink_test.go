@@ -0,0 +1,716 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package snowflake
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/backoff"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/batch"
+	batchmocks "github.com/xataio/pgstream/pkg/wal/processor/batch/mocks"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+type mockDB struct {
+	execFn func(query string, args ...any) error
+
+	queries []string
+	// staged has the content of the files uploaded with PUT
+	staged []string
+}
+
+func (m *mockDB) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	m.queries = append(m.queries, query)
+	if strings.HasPrefix(query, "PUT ") {
+		path := strings.TrimPrefix(strings.Fields(query)[1], "'file://")
+		content, err := os.ReadFile(strings.TrimSuffix(path, "'"))
+		if err != nil {
+			return nil, err
+		}
+		m.staged = append(m.staged, string(content))
+	}
+	if m.execFn != nil {
+		if err := m.execFn(query, args...); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockDB) PingContext(context.Context) error {
+	return nil
+}
+
+func (m *mockDB) Close() error {
+	return nil
+}
+
+type mockStreamingClient struct {
+	openFn      func(ref channelRef) (*channelState, error)
+	appendFn    func(ref channelRef, continuationToken, offsetToken string, rows []byte) (string, error)
+	committedFn func(ref channelRef) (string, error)
+
+	calls []string
+}
+
+func (m *mockStreamingClient) openChannel(_ context.Context, ref channelRef) (*channelState, error) {
+	m.calls = append(m.calls, "openChannel "+ref.pipe)
+	return m.openFn(ref)
+}
+
+func (m *mockStreamingClient) appendRows(_ context.Context, ref channelRef, continuationToken, offsetToken string, rows []byte) (string, error) {
+	m.calls = append(m.calls, fmt.Sprintf("appendRows %s %s %s", ref.pipe, continuationToken, offsetToken))
+	return m.appendFn(ref, continuationToken, offsetToken, rows)
+}
+
+func (m *mockStreamingClient) committedOffset(_ context.Context, ref channelRef) (string, error) {
+	m.calls = append(m.calls, "committedOffset "+ref.pipe)
+	return m.committedFn(ref)
+}
+
+var errTest = errors.New("oh noes")
+
+// newTestData returns the event data of the action on input for the row with
+// the id on input, with the id as primary key and LSN.
+func newTestData(action wal.Action, table string, id int) *wal.Data {
+	data := &wal.Data{
+		Action: string(action),
+		Schema: "public",
+		Table:  table,
+		LSN:    fmt.Sprintf("0/%X", id),
+	}
+	switch action {
+	case wal.ActionInsert, wal.ActionUpdate:
+		data.Columns = []wal.Column{
+			{ID: "1", Name: "id", Type: "integer", Value: id},
+			{ID: "2", Name: "doc", Type: "jsonb", Value: `{"a":1}`},
+		}
+		data.Metadata.InternalColIDs = []string{"1"}
+	}
+	switch action {
+	case wal.ActionUpdate, wal.ActionDelete:
+		data.Identity = []wal.Column{{ID: "1", Name: "id", Type: "integer", Value: id}}
+	}
+	return data
+}
+
+func newTestMessage(t *testing.T, data *wal.Data) message {
+	msg, err := newTestSink(t, &mockDB{}).newMessage(data)
+	require.NoError(t, err)
+	return msg
+}
+
+func newTestSink(t *testing.T, db db) *SnowflakeSink {
+	return &SnowflakeSink{
+		logger: loglib.NewNoopLogger(),
+		db:     db,
+		backoffProvider: backoff.NewProvider(&backoff.Config{
+			Constant: &backoff.ConstantConfig{Interval: time.Millisecond, MaxRetries: 2},
+		}),
+		lsnParser: pgreplication.NewLSNParser(),
+		namer: &tableNamer{
+			schemaTemplate: schemaPlaceholder,
+			tableTemplate:  tablePlaceholder,
+			pipeTemplate:   defaultPipeTemplate,
+		},
+		mode:               ModeMerge,
+		stage:              defaultStage,
+		tempDir:            t.TempDir(),
+		database:           "analytics",
+		channel:            defaultChannel,
+		commitTimeout:      time.Second,
+		commitPollInterval: time.Millisecond,
+		tables:             map[table]*tableState{},
+	}
+}
+
+func TestNewSnowflakeSink(t *testing.T) {
+	t.Parallel()
+
+	testDSN := "user:pass@account/db/schema"
+
+	tests := []struct {
+		name   string
+		config *Config
+
+		wantErr error
+	}{
+		{
+			name:    "error - missing dsn",
+			config:  &Config{},
+			wantErr: errMissingDSN,
+		},
+		{
+			name:    "error - auto resume without warehouse",
+			config:  &Config{DSN: testDSN, WarehouseAutoResume: true},
+			wantErr: errMissingWarehouse,
+		},
+		{
+			name:    "error - unsupported mode",
+			config:  &Config{DSN: testDSN, Mode: "invalid"},
+			wantErr: errUnsupportedMode,
+		},
+		{
+			name:    "error - streaming without account",
+			config:  &Config{DSN: testDSN, Mode: ModeStreaming},
+			wantErr: errMissingAccount,
+		},
+		{
+			name: "error - streaming without user",
+			config: &Config{DSN: testDSN, Mode: ModeStreaming, Streaming: StreamingConfig{
+				Account: "myorg-myaccount",
+			}},
+			wantErr: errMissingUser,
+		},
+		{
+			name: "error - streaming without database",
+			config: &Config{DSN: testDSN, Mode: ModeStreaming, Streaming: StreamingConfig{
+				Account: "myorg-myaccount",
+				User:    "loader",
+			}},
+			wantErr: errMissingDatabase,
+		},
+		{
+			name: "error - streaming without private key",
+			config: &Config{DSN: testDSN, Mode: ModeStreaming, Streaming: StreamingConfig{
+				Account:  "myorg-myaccount",
+				User:     "loader",
+				Database: "analytics",
+			}},
+			wantErr: errMissingPrivateKey,
+		},
+		{
+			name:    "error - driver not registered",
+			config:  &Config{DSN: testDSN, DriverName: "unknown"},
+			wantErr: errDriverNotRegistered,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewSnowflakeSink(context.Background(), tc.config)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestSnowflakeSink_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	testCommitPosition := wal.CommitPosition("0/1")
+	testTable := table{schema: "public", name: "users"}
+	testIdentity := []wal.Column{{ID: "1", Name: "id", Type: "integer", Value: 1}}
+
+	identityUpdate := newTestData(wal.ActionUpdate, "users", 2)
+	identityUpdate.Identity = testIdentity
+
+	tests := []struct {
+		name  string
+		event *wal.Event
+
+		wantMsgs []*batch.WALMessage[message]
+		wantErr  error
+	}{
+		{
+			name:  "ok - insert",
+			event: &wal.Event{Data: newTestData(wal.ActionInsert, "users", 1), CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{
+					data:       newTestData(wal.ActionInsert, "users", 1),
+					table:      testTable,
+					pipe:       "users-STREAMING",
+					primaryKey: []string{"id"},
+					rows:       []row{{op: "I", lsn: 1, columns: newTestData(wal.ActionInsert, "users", 1).Columns}},
+				}, testCommitPosition),
+			},
+		},
+		{
+			name:  "ok - delete",
+			event: &wal.Event{Data: newTestData(wal.ActionDelete, "users", 1), CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{
+					data:       newTestData(wal.ActionDelete, "users", 1),
+					table:      testTable,
+					pipe:       "users-STREAMING",
+					primaryKey: []string{"id"},
+					rows:       []row{{op: "D", lsn: 1, columns: testIdentity}},
+				}, testCommitPosition),
+			},
+		},
+		{
+			name:  "ok - update changing the identity",
+			event: &wal.Event{Data: identityUpdate, CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{
+					data:       identityUpdate,
+					table:      testTable,
+					pipe:       "users-STREAMING",
+					primaryKey: []string{"id"},
+					rows: []row{
+						{op: "D", lsn: 2, columns: testIdentity},
+						{op: "U", lsn: 2, columns: identityUpdate.Columns},
+					},
+				}, testCommitPosition),
+			},
+		},
+		{
+			name: "ok - update without identity",
+			event: &wal.Event{
+				Data:           &wal.Data{Action: "U", Schema: "public", Table: "users", Columns: []wal.Column{{Name: "id", Value: 1}}},
+				CommitPosition: testCommitPosition,
+			},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, testCommitPosition),
+			},
+		},
+		{
+			name: "ok - schema log event",
+			event: &wal.Event{
+				Data:           &wal.Data{Action: "I", Schema: schemalog.SchemaName, Table: schemalog.TableName},
+				CommitPosition: testCommitPosition,
+			},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, testCommitPosition),
+			},
+		},
+		{
+			name:  "ok - keep alive",
+			event: &wal.Event{CommitPosition: testCommitPosition},
+			wantMsgs: []*batch.WALMessage[message]{
+				batch.NewWALMessage(message{}, testCommitPosition),
+			},
+		},
+		{
+			name: "error - invalid lsn",
+			event: &wal.Event{
+				Data:           &wal.Data{Action: "T", Schema: "public", Table: "users"},
+				CommitPosition: testCommitPosition,
+			},
+			wantMsgs: []*batch.WALMessage[message]{},
+			wantErr:  errors.New("snowflake sink: parsing event lsn"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockBatchSender := batchmocks.NewBatchSender[message]()
+			s := newTestSink(t, &mockDB{})
+			s.batchSender = mockBatchSender
+
+			doneChan := make(chan struct{})
+			go func() {
+				defer close(doneChan)
+				require.Equal(t, tc.wantMsgs, mockBatchSender.GetWALMessages())
+			}()
+
+			err := s.ProcessWALEvent(context.Background(), tc.event)
+			if tc.wantErr != nil {
+				require.ErrorContains(t, err, tc.wantErr.Error())
+			} else {
+				require.NoError(t, err)
+			}
+			mockBatchSender.Close()
+			<-doneChan
+		})
+	}
+}
+
+func TestSnowflakeSink_sendBatch(t *testing.T) {
+	t.Parallel()
+
+	withoutPrimaryKey := newTestData(wal.ActionInsert, "logs", 1)
+	withoutPrimaryKey.Metadata.InternalColIDs = nil
+
+	testMessages := []message{
+		newTestMessage(t, newTestData(wal.ActionInsert, "users", 1)),
+		newTestMessage(t, newTestData(wal.ActionInsert, "orders", 1)),
+		newTestMessage(t, newTestData(wal.ActionInsert, "users", 2)),
+		newTestMessage(t, newTestData(wal.ActionDelete, "users", 1)),
+		newTestMessage(t, newTestData(wal.ActionUpdate, "orders", 2)),
+		newTestMessage(t, newTestData(wal.ActionTruncate, "items", 3)),
+		newTestMessage(t, withoutPrimaryKey),
+	}
+
+	mergeQueries := func(table string) []string {
+		return []string{
+			fmt.Sprintf(`CREATE OR REPLACE TEMPORARY TABLE "public"."%s_pgstream_staging"`, table),
+			fmt.Sprintf(`ALTER TABLE "public"."%s_pgstream_staging" ADD COLUMN`, table),
+			"PUT",
+			fmt.Sprintf(`COPY INTO "public"."%s_pgstream_staging"`, table),
+			fmt.Sprintf(`MERGE INTO "public"."%s"`, table),
+		}
+	}
+	usersStaged := `{"_pgstream_lsn":1,"_pgstream_op":"I","_pgstream_seq":0,"doc":{"a":1},"id":1}` + "\n" +
+		`{"_pgstream_lsn":2,"_pgstream_op":"I","_pgstream_seq":1,"doc":{"a":1},"id":2}` + "\n" +
+		`{"_pgstream_lsn":1,"_pgstream_op":"D","_pgstream_seq":2,"id":1}`
+
+	tests := []struct {
+		name      string
+		batch     *batch.Batch[message]
+		warehouse string
+		execFn    func() func(query string, args ...any) error
+
+		wantQueries   []string
+		wantStaged    []string
+		wantPositions []wal.CommitPosition
+		wantErr       error
+	}{
+		{
+			name:      "ok",
+			batch:     batch.NewBatch(testMessages, []wal.CommitPosition{"0/1", "0/2"}),
+			warehouse: "LOADER_WH",
+
+			wantQueries: slices.Concat(
+				[]string{
+					`USE WAREHOUSE "LOADER_WH"`,
+					`TRUNCATE TABLE "public"."items"`,
+					`ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "_pgstream_lsn" NUMBER(20,0)`,
+				},
+				mergeQueries("users"),
+				[]string{`ALTER TABLE "public"."orders" ADD COLUMN IF NOT EXISTS "_pgstream_lsn" NUMBER(20,0)`},
+				mergeQueries("orders"),
+				[]string{
+					`ALTER TABLE "public"."logs" ADD COLUMN IF NOT EXISTS "_pgstream_lsn" NUMBER(20,0)`,
+					"PUT",
+					`COPY INTO "public"."logs"`,
+				},
+			),
+			wantStaged: []string{
+				usersStaged,
+				`{"_pgstream_lsn":1,"_pgstream_op":"I","_pgstream_seq":0,"doc":{"a":1},"id":1}` + "\n" +
+					`{"_pgstream_lsn":2,"_pgstream_op":"U","_pgstream_seq":1,"doc":{"a":1},"id":2}`,
+				`{"doc":{"a":1},"id":1}`,
+			},
+			wantPositions: []wal.CommitPosition{"0/1", "0/2"},
+		},
+		{
+			name:          "ok - keep alive",
+			batch:         batch.NewBatch([]message{}, []wal.CommitPosition{"0/1"}),
+			wantPositions: []wal.CommitPosition{"0/1"},
+		},
+		{
+			name: "ok - rows flushed when the primary key changes",
+			batch: batch.NewBatch([]message{
+				newTestMessage(t, withoutPrimaryKey),
+				newTestMessage(t, newTestData(wal.ActionDelete, "logs", 1)),
+			}, []wal.CommitPosition{"0/1"}),
+
+			wantQueries: slices.Concat(
+				[]string{
+					`ALTER TABLE "public"."logs" ADD COLUMN IF NOT EXISTS "_pgstream_lsn" NUMBER(20,0)`,
+					"PUT",
+					`COPY INTO "public"."logs"`,
+				},
+				mergeQueries("logs"),
+			),
+			wantStaged: []string{
+				`{"doc":{"a":1},"id":1}`,
+				`{"_pgstream_lsn":1,"_pgstream_op":"D","_pgstream_seq":0,"id":1}`,
+			},
+			wantPositions: []wal.CommitPosition{"0/1"},
+		},
+		{
+			name:      "ok - merge retried",
+			batch:     batch.NewBatch(testMessages[:1], []wal.CommitPosition{"0/1"}),
+			warehouse: "LOADER_WH",
+			execFn: func() func(query string, args ...any) error {
+				failed := false
+				return func(query string, _ ...any) error {
+					if strings.HasPrefix(query, "MERGE INTO") && !failed {
+						failed = true
+						return errTest
+					}
+					return nil
+				}
+			},
+
+			wantQueries: slices.Concat(
+				[]string{
+					`USE WAREHOUSE "LOADER_WH"`,
+					`ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "_pgstream_lsn" NUMBER(20,0)`,
+				},
+				mergeQueries("users"),
+				[]string{`USE WAREHOUSE "LOADER_WH"`},
+				mergeQueries("users"),
+			),
+			wantStaged: []string{
+				`{"_pgstream_lsn":1,"_pgstream_op":"I","_pgstream_seq":0,"doc":{"a":1},"id":1}`,
+				`{"_pgstream_lsn":1,"_pgstream_op":"I","_pgstream_seq":0,"doc":{"a":1},"id":1}`,
+			},
+			wantPositions: []wal.CommitPosition{"0/1"},
+		},
+		{
+			name:  "error - merging rows",
+			batch: batch.NewBatch(testMessages[:1], []wal.CommitPosition{"0/1"}),
+			execFn: func() func(query string, args ...any) error {
+				return func(query string, _ ...any) error {
+					if strings.HasPrefix(query, "MERGE INTO") {
+						return errTest
+					}
+					return nil
+				}
+			},
+
+			wantQueries: slices.Concat(
+				[]string{`ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "_pgstream_lsn" NUMBER(20,0)`},
+				mergeQueries("users"),
+				mergeQueries("users"),
+				mergeQueries("users"),
+			),
+			wantStaged: slices.Repeat([]string{
+				`{"_pgstream_lsn":1,"_pgstream_op":"I","_pgstream_seq":0,"doc":{"a":1},"id":1}`,
+			}, 3),
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			db := &mockDB{}
+			if tc.execFn != nil {
+				db.execFn = tc.execFn()
+			}
+			s := newTestSink(t, db)
+			s.warehouse = tc.warehouse
+			var gotPositions []wal.CommitPosition
+			s.checkpointer = func(_ context.Context, positions []wal.CommitPosition) error {
+				gotPositions = positions
+				return nil
+			}
+
+			err := s.sendBatch(context.Background(), tc.batch)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantPositions, gotPositions)
+			require.Len(t, db.queries, len(tc.wantQueries), "queries: %v", db.queries)
+			for i, query := range tc.wantQueries {
+				require.True(t, strings.HasPrefix(db.queries[i], query), "query %d: %s", i, db.queries[i])
+			}
+			require.Equal(t, tc.wantStaged, db.staged)
+
+			// the staging files are removed once loaded
+			files, err := os.ReadDir(s.tempDir)
+			require.NoError(t, err)
+			require.Empty(t, files)
+			for _, query := range db.queries {
+				if strings.HasPrefix(query, "PUT") {
+					require.Contains(t, query, "'file://"+filepath.Clean(s.tempDir))
+				}
+			}
+		})
+	}
+}
+
+func TestSnowflakeSink_sendBatch_streaming(t *testing.T) {
+	t.Parallel()
+
+	testMessages := []message{
+		newTestMessage(t, newTestData(wal.ActionInsert, "users", 1)),
+		newTestMessage(t, newTestData(wal.ActionUpdate, "users", 2)),
+		newTestMessage(t, newTestData(wal.ActionTruncate, "users", 3)),
+	}
+	testChannel := channelRef{database: "analytics", schema: "public", pipe: "users-STREAMING", channel: "pgstream"}
+	openedChannel := func(committedOffset string) func(channelRef) (*channelState, error) {
+		return func(ref channelRef) (*channelState, error) {
+			require.Equal(t, testChannel, ref)
+			return &channelState{continuationToken: "token-0", committedOffset: committedOffset}, nil
+		}
+	}
+	committed := func(offset string) func(channelRef) (string, error) {
+		return func(channelRef) (string, error) { return offset, nil }
+	}
+
+	tests := []struct {
+		name        string
+		openFn      func(channelRef) (*channelState, error)
+		newAppendFn func() func(channelRef, string, string, []byte) (string, error)
+		committedFn func(channelRef) (string, error)
+
+		wantCalls []string
+		wantRows  []string
+		wantErr   error
+	}{
+		{
+			name:        "ok",
+			openFn:      openedChannel(""),
+			committedFn: committed("3"),
+
+			wantCalls: []string{
+				"openChannel users-STREAMING",
+				"appendRows users-STREAMING token-0 3",
+				"committedOffset users-STREAMING",
+			},
+			wantRows: []string{
+				`{"_pgstream_lsn":1,"_pgstream_op":"I","doc":{"a":1},"id":1}` + "\n" +
+					`{"_pgstream_lsn":2,"_pgstream_op":"U","doc":{"a":1},"id":2}` + "\n" +
+					`{"_pgstream_lsn":3,"_pgstream_op":"T"}`,
+			},
+		},
+		{
+			name:        "ok - committed rows skipped",
+			openFn:      openedChannel("2"),
+			committedFn: committed("3"),
+
+			wantCalls: []string{
+				"openChannel users-STREAMING",
+				"appendRows users-STREAMING token-0 3",
+				"committedOffset users-STREAMING",
+			},
+			wantRows: []string{`{"_pgstream_lsn":3,"_pgstream_op":"T"}`},
+		},
+		{
+			name:   "ok - channel reopened after append error",
+			openFn: openedChannel(""),
+			newAppendFn: func() func(channelRef, string, string, []byte) (string, error) {
+				failed := false
+				return func(channelRef, string, string, []byte) (string, error) {
+					if !failed {
+						failed = true
+						return "", errTest
+					}
+					return "token-1", nil
+				}
+			},
+			committedFn: committed("3"),
+
+			wantCalls: []string{
+				"openChannel users-STREAMING",
+				"appendRows users-STREAMING token-0 3",
+				"openChannel users-STREAMING",
+				"appendRows users-STREAMING token-0 3",
+				"committedOffset users-STREAMING",
+			},
+		},
+		{
+			name:        "error - commit timeout",
+			openFn:      openedChannel(""),
+			committedFn: committed("2"),
+
+			wantErr: errCommitTimeout,
+		},
+		{
+			name: "error - permanent append error",
+			openFn: func(channelRef) (*channelState, error) {
+				return nil, &apiError{statusCode: http.StatusBadRequest, message: "invalid pipe"}
+			},
+
+			wantCalls: []string{"openChannel users-STREAMING"},
+			wantErr:   errors.New("invalid pipe"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotRows := []string{}
+			client := &mockStreamingClient{
+				openFn: tc.openFn,
+				appendFn: func(_ channelRef, _, _ string, rows []byte) (string, error) {
+					gotRows = append(gotRows, string(rows))
+					return "token-1", nil
+				},
+				committedFn: tc.committedFn,
+			}
+			if tc.newAppendFn != nil {
+				client.appendFn = tc.newAppendFn()
+			}
+			db := &mockDB{}
+			s := newTestSink(t, db)
+			s.mode = ModeStreaming
+			s.streamingClient = client
+			s.commitTimeout = 10 * time.Millisecond
+
+			err := s.sendBatch(context.Background(), batch.NewBatch(testMessages, []wal.CommitPosition{"0/1"}))
+			if tc.wantErr != nil {
+				require.ErrorContains(t, err, tc.wantErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantCalls, client.calls)
+			if tc.wantRows != nil {
+				require.Equal(t, tc.wantRows, gotRows)
+			}
+			require.Equal(t, []string{
+				`ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "_pgstream_lsn" NUMBER(20,0)`,
+				`ALTER TABLE "public"."users" ADD COLUMN IF NOT EXISTS "_pgstream_op" VARCHAR`,
+			}, db.queries)
+		})
+	}
+}
+
+func TestSnowflakeSink_resumeWarehouse(t *testing.T) {
+	t.Parallel()
+
+	db := &mockDB{}
+	s := newTestSink(t, db)
+	s.warehouse = "LOADER_WH"
+
+	require.NoError(t, s.resumeWarehouse(context.Background()))
+	require.Equal(t, []string{
+		`ALTER WAREHOUSE "LOADER_WH" SET AUTO_RESUME = TRUE`,
+		`ALTER WAREHOUSE "LOADER_WH" RESUME IF SUSPENDED`,
+	}, db.queries)
+}
+
+func TestChunkRows(t *testing.T) {
+	t.Parallel()
+
+	newRows := func(lsns ...uint64) []encodedRow {
+		rows := make([]encodedRow, 0, len(lsns))
+		for _, lsn := range lsns {
+			rows = append(rows, encodedRow{lsn: lsn, line: []byte("1234")})
+		}
+		return rows
+	}
+
+	tests := []struct {
+		name string
+		rows []encodedRow
+
+		wantChunks [][]encodedRow
+	}{
+		{
+			name:       "ok",
+			rows:       newRows(1, 2, 3),
+			wantChunks: [][]encodedRow{newRows(1, 2), newRows(3)},
+		},
+		{
+			name:       "ok - rows with the same lsn kept together",
+			rows:       newRows(1, 2, 2, 3),
+			wantChunks: [][]encodedRow{newRows(1, 2, 2), newRows(3)},
+		},
+		{
+			name:       "ok - snapshot rows",
+			rows:       newRows(0, 0, 0),
+			wantChunks: [][]encodedRow{newRows(0, 0), newRows(0)},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantChunks, chunkRows(tc.rows, 10))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package throttle
+
+import "time"
+
+type Config struct {
+	// URL of the source database the table sizes are queried from.
+	URL string
+	// MinTableSize is the total relation size in bytes from which the events
+	// of a table are throttled. Defaults to 1GiB.
+	MinTableSize int64
+	// EventsPerSecondPerGB is the throttle rate. The events of a throttled
+	// table are limited to this rate divided by the table size in GB, so that
+	// the larger the table, the slower its events are processed. Defaults to
+	// 1000.
+	EventsPerSecondPerGB float64
+	// RefreshInterval is how often the size of each table is queried again.
+	// Defaults to 5m.
+	RefreshInterval time.Duration
+}
+
+const (
+	bytesPerGB = 1 << 30
+
+	defaultMinTableSize         = bytesPerGB
+	defaultEventsPerSecondPerGB = 1000
+	defaultRefreshInterval      = 5 * time.Minute
+)
+
+func (c *Config) minTableSize() int64 {
+	if c.MinTableSize > 0 {
+		return c.MinTableSize
+	}
+	return defaultMinTableSize
+}
+
+func (c *Config) eventsPerSecondPerGB() float64 {
+	if c.EventsPerSecondPerGB > 0 {
+		return c.EventsPerSecondPerGB
+	}
+	return defaultEventsPerSecondPerGB
+}
+
+func (c *Config) refreshInterval() time.Duration {
+	if c.RefreshInterval > 0 {
+		return c.RefreshInterval
+	}
+	return defaultRefreshInterval
+}
@@ -0,0 +1,248 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package throttle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// TableSizeThrottle is a decorator around a wal processor that slows down the
+// processing of the events of very large tables, so that snapshotting them
+// doesn't starve the rest of the pipeline. The events of the tables smaller
+// than the configured size are processed at full speed, while the events of
+// the larger ones are paced at a rate inversely proportional to their size.
+type TableSizeThrottle struct {
+	logger    loglib.Logger
+	processor processor.Processor
+	conn      pglib.Querier
+
+	minTableSize         int64
+	eventsPerSecondPerGB float64
+	refreshInterval      time.Duration
+	clock                func() time.Time
+	wait                 func(context.Context, time.Duration) error
+
+	meter           metric.Meter
+	tableAttributes *otel.TableAttributes
+	registration    metric.Registration
+
+	mutex  sync.Mutex
+	tables map[string]*tableThrottle
+}
+
+type Option func(t *TableSizeThrottle)
+
+// tableThrottle keeps track of the throttling of the events of a table.
+type tableThrottle struct {
+	// rate of the table events in events per second, zero if not throttled
+	rate      float64
+	checkedAt time.Time
+	// next is when the next event of the table can be processed
+	next       time.Time
+	attributes []attribute.KeyValue
+}
+
+const tableSizeQuery = "SELECT pg_total_relation_size($1::regclass)"
+
+var errMissingURL = errors.New("missing source postgres url for the table size throttle")
+
+// New returns a table size throttle wrapper around the processor on input,
+// which queries the table sizes from the configured source database.
+func New(ctx context.Context, cfg *Config, p processor.Processor, opts ...Option) (*TableSizeThrottle, error) {
+	if cfg.URL == "" {
+		return nil, errMissingURL
+	}
+
+	conn, err := pglib.NewConnPool(ctx, cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("creating table size throttle postgres connection: %w", err)
+	}
+
+	t := newTableSizeThrottle(cfg, conn, p)
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	if err := t.initMetrics(); err != nil {
+		conn.Close(ctx)
+		return nil, fmt.Errorf("initialising table size throttle metrics: %w", err)
+	}
+
+	return t, nil
+}
+
+func newTableSizeThrottle(cfg *Config, conn pglib.Querier, p processor.Processor) *TableSizeThrottle {
+	return &TableSizeThrottle{
+		logger:               loglib.NewNoopLogger(),
+		processor:            p,
+		conn:                 conn,
+		minTableSize:         cfg.minTableSize(),
+		eventsPerSecondPerGB: cfg.eventsPerSecondPerGB(),
+		refreshInterval:      cfg.refreshInterval(),
+		clock:                time.Now,
+		wait:                 wait,
+		tables:               map[string]*tableThrottle{},
+	}
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(t *TableSizeThrottle) {
+		t.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_table_size_throttle",
+		})
+	}
+}
+
+// WithInstrumentation reports the throttle rate of each throttled table.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(t *TableSizeThrottle) {
+		if i != nil {
+			t.meter = i.Meter
+			t.tableAttributes = i.Tables
+		}
+	}
+}
+
+// ProcessWALEvent sends the event on input to the wrapped processor, once the
+// throttle of its table allows it.
+func (t *TableSizeThrottle) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	if event.Data != nil && event.Data.Table != "" {
+		if delay := t.reserve(ctx, event.Data.Schema, event.Data.Table); delay > 0 {
+			if err := t.wait(ctx, delay); err != nil {
+				return err
+			}
+		}
+	}
+	return t.processor.ProcessWALEvent(ctx, event)
+}
+
+func (t *TableSizeThrottle) Name() string {
+	return t.processor.Name()
+}
+
+// Close stops reporting the throttle rates, and closes the source connection
+// and the wrapped processor.
+func (t *TableSizeThrottle) Close() error {
+	var errs error
+	if t.registration != nil {
+		errs = errors.Join(errs, t.registration.Unregister())
+	}
+	errs = errors.Join(errs, t.conn.Close(context.Background()))
+	return errors.Join(errs, t.processor.Close())
+}
+
+func (t *TableSizeThrottle) Ping(ctx context.Context) error {
+	return t.processor.Ping(ctx)
+}
+
+// reserve returns how long the event of the table on input needs to wait
+// before being processed, and reserves the following slot for the next one.
+func (t *TableSizeThrottle) reserve(ctx context.Context, schema, table string) time.Duration {
+	key := pglib.QuoteQualifiedIdentifier(schema, table)
+
+	t.mutex.Lock()
+	tt, found := t.tables[key]
+	stale := !found || t.clock().Sub(tt.checkedAt) >= t.refreshInterval
+	t.mutex.Unlock()
+
+	// the size is queried without holding the lock, so that the events of the
+	// other tables are not blocked by it
+	var rate float64
+	if stale {
+		rate = t.tableRate(ctx, key)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	now := t.clock()
+	if tt, found = t.tables[key]; !found {
+		tt = &tableThrottle{attributes: t.tableAttributes.Get(schema, table)}
+		t.tables[key] = tt
+	}
+	if stale {
+		tt.rate = rate
+		tt.checkedAt = now
+	}
+	if tt.rate == 0 {
+		return 0
+	}
+
+	if tt.next.Before(now) {
+		tt.next = now
+	}
+	delay := tt.next.Sub(now)
+	tt.next = tt.next.Add(time.Duration(float64(time.Second) / tt.rate))
+	return delay
+}
+
+// tableRate returns the rate the events of the table on input are throttled
+// to, or zero if they're not throttled. Tables whose size can't be retrieved
+// are not throttled until the next refresh.
+func (t *TableSizeThrottle) tableRate(ctx context.Context, table string) float64 {
+	var size int64
+	if err := t.conn.QueryRow(ctx, []any{&size}, tableSizeQuery, table); err != nil {
+		t.logger.Warn(err, "table size throttle: retrieving table size, events won't be throttled", loglib.Fields{
+			"table": table,
+		})
+		return 0
+	}
+	if size < t.minTableSize {
+		return 0
+	}
+
+	rate := t.eventsPerSecondPerGB * bytesPerGB / float64(size)
+	t.logger.Debug("table size throttle: throttling table events", loglib.Fields{
+		"table":             table,
+		"table_size_bytes":  size,
+		"events_per_second": rate,
+	})
+	return rate
+}
+
+func (t *TableSizeThrottle) initMetrics() error {
+	if t.meter == nil {
+		return nil
+	}
+
+	rateGauge, err := t.meter.Float64ObservableGauge("pgstream.snapshot.throttle.rate",
+		metric.WithUnit("{event}/s"),
+		metric.WithDescription("Rate the events of the tables larger than the table size throttle threshold are limited to"))
+	if err != nil {
+		return err
+	}
+
+	t.registration, err = t.meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		for _, tt := range t.tables {
+			if tt.rate > 0 {
+				o.ObserveFloat64(rateGauge, tt.rate, metric.WithAttributes(tt.attributes...))
+			}
+		}
+		return nil
+	}, rateGauge)
+	return err
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
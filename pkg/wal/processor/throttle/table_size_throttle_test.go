@@ -0,0 +1,198 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	"github.com/xataio/pgstream/pkg/otel"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestTableSizeThrottle_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	testNow := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newEvent := func(table string) *wal.Event {
+		return &wal.Event{Data: &wal.Data{Schema: "public", Table: table}}
+	}
+
+	tests := []struct {
+		name    string
+		sizes   map[string]int64
+		sizeErr error
+		events  []*wal.Event
+		// advance is how long the clock moves forward between events
+		advance time.Duration
+
+		wantDelays  []time.Duration
+		wantQueries int
+	}{
+		{
+			name:   "small table not throttled",
+			sizes:  map[string]int64{`"public"."small"`: bytesPerGB / 2},
+			events: []*wal.Event{newEvent("small"), newEvent("small"), newEvent("small")},
+
+			wantDelays:  []time.Duration{},
+			wantQueries: 1,
+		},
+		{
+			name:   "large table throttled by its size",
+			sizes:  map[string]int64{`"public"."large"`: 2 * bytesPerGB},
+			events: []*wal.Event{newEvent("large"), newEvent("large"), newEvent("large")},
+
+			// 1000 events per second per GB over 2GB, one event every 2ms
+			wantDelays:  []time.Duration{2 * time.Millisecond, 4 * time.Millisecond},
+			wantQueries: 1,
+		},
+		{
+			name: "only large tables throttled",
+			sizes: map[string]int64{
+				`"public"."large"`: 4 * bytesPerGB,
+				`"public"."small"`: bytesPerGB / 2,
+			},
+			events: []*wal.Event{newEvent("large"), newEvent("small"), newEvent("large"), newEvent("small")},
+
+			wantDelays:  []time.Duration{4 * time.Millisecond},
+			wantQueries: 2,
+		},
+		{
+			name:    "size refreshed after the interval",
+			sizes:   map[string]int64{`"public"."large"`: bytesPerGB},
+			events:  []*wal.Event{newEvent("large"), newEvent("large")},
+			advance: time.Minute,
+
+			wantDelays:  []time.Duration{},
+			wantQueries: 2,
+		},
+		{
+			name:    "table size error not throttled",
+			sizeErr: errTest,
+			events:  []*wal.Event{newEvent("large"), newEvent("large")},
+
+			wantDelays:  []time.Duration{},
+			wantQueries: 1,
+		},
+		{
+			name:   "keep alive events not throttled",
+			events: []*wal.Event{{CommitPosition: "1"}, {CommitPosition: "2"}},
+
+			wantDelays:  []time.Duration{},
+			wantQueries: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			queries := 0
+			processed := 0
+			now := testNow
+			delays := []time.Duration{}
+			throttle := newTableSizeThrottle(&Config{RefreshInterval: time.Minute}, &pgmocks.Querier{
+				QueryRowFn: func(_ context.Context, dest []any, query string, args ...any) error {
+					queries++
+					require.Equal(t, tableSizeQuery, query)
+					if tc.sizeErr != nil {
+						return tc.sizeErr
+					}
+					size, ok := dest[0].(*int64)
+					require.True(t, ok)
+					*size = tc.sizes[args[0].(string)]
+					return nil
+				},
+			}, &mocks.Processor{
+				ProcessWALEventFn: func(context.Context, *wal.Event) error {
+					processed++
+					return nil
+				},
+			})
+			throttle.clock = func() time.Time { return now }
+			throttle.wait = func(_ context.Context, d time.Duration) error {
+				delays = append(delays, d)
+				return nil
+			}
+
+			for _, event := range tc.events {
+				require.NoError(t, throttle.ProcessWALEvent(context.Background(), event))
+				now = now.Add(tc.advance)
+			}
+
+			require.Equal(t, tc.wantDelays, delays)
+			require.Equal(t, tc.wantQueries, queries)
+			require.Equal(t, len(tc.events), processed)
+		})
+	}
+}
+
+func TestTableSizeThrottle_ProcessWALEvent_cancelled(t *testing.T) {
+	t.Parallel()
+
+	throttle := newTableSizeThrottle(&Config{EventsPerSecondPerGB: 0.001}, &pgmocks.Querier{
+		QueryRowFn: func(_ context.Context, dest []any, _ string, _ ...any) error {
+			*dest[0].(*int64) = bytesPerGB
+			return nil
+		},
+	}, &mocks.Processor{
+		ProcessWALEventFn: func(context.Context, *wal.Event) error { return nil },
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	event := &wal.Event{Data: &wal.Data{Schema: "public", Table: "large"}}
+	require.NoError(t, throttle.ProcessWALEvent(ctx, event))
+	cancel()
+	require.ErrorIs(t, throttle.ProcessWALEvent(ctx, event), context.Canceled)
+}
+
+func TestTableSizeThrottle_metrics(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	throttle := newTableSizeThrottle(&Config{EventsPerSecondPerGB: 500}, &pgmocks.Querier{
+		QueryRowFn: func(_ context.Context, dest []any, _ string, args ...any) error {
+			size := int64(bytesPerGB)
+			if args[0] == `"public"."small"` {
+				size = 1024
+			}
+			*dest[0].(*int64) = size
+			return nil
+		},
+		CloseFn: func(context.Context) error { return nil },
+	}, &mocks.Processor{
+		ProcessWALEventFn: func(context.Context, *wal.Event) error { return nil },
+	})
+	throttle.wait = func(context.Context, time.Duration) error { return nil }
+	WithInstrumentation(&otel.Instrumentation{Meter: meterProvider.Meter("test")})(throttle)
+	require.NoError(t, throttle.initMetrics())
+
+	for _, table := range []string{"large", "small"} {
+		require.NoError(t, throttle.ProcessWALEvent(context.Background(), &wal.Event{Data: &wal.Data{Schema: "public", Table: table}}))
+	}
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	m := rm.ScopeMetrics[0].Metrics[0]
+	require.Equal(t, "pgstream.snapshot.throttle.rate", m.Name)
+	gauge, ok := m.Data.(metricdata.Gauge[float64])
+	require.True(t, ok)
+	require.Len(t, gauge.DataPoints, 1)
+	table, _ := gauge.DataPoints[0].Attributes.Value(attribute.Key(otel.TableAttributeKey))
+	require.Equal(t, "large", table.AsString())
+	require.Equal(t, float64(500), gauge.DataPoints[0].Value)
+
+	require.NoError(t, throttle.Close())
+}
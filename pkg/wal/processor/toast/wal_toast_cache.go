@@ -0,0 +1,255 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package toast
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/rowcache"
+)
+
+// TOASTCache is a decorator around a wal processor that fills in the values
+// of unchanged TOAST columns. Postgres doesn't include TOAST column values in
+// the WAL when they haven't changed, so update events will be missing them (or
+// will have them marked as unchanged). The cache keeps the last seen value of
+// the TOAST-capable columns for each row, identified by its primary key, so
+// that they can be added back to the update events.
+//
+// Rows are identified by the pgstream identity columns when the event metadata
+// has been injected. Otherwise, the replica identity of the event is used, in
+// which case insert events can't be cached.
+type TOASTCache struct {
+	logger    loglib.Logger
+	processor processor.Processor
+
+	mutex sync.Mutex
+	cache *rowcache.LRU
+}
+
+type Config struct {
+	// MaxEntries is the maximum number of rows to keep in the cache. Defaults
+	// to 10000.
+	MaxEntries int
+	// MaxBytes is the maximum size in bytes of the cached values. Defaults to
+	// 64MiB.
+	MaxBytes int64
+}
+
+type Option func(c *TOASTCache)
+
+const (
+	defaultMaxEntries = 10000
+	defaultMaxBytes   = 64 * 1024 * 1024 // 64MiB
+
+	// value used by postgres output plugins to mark unchanged TOAST columns
+	unchangedToastDatum = "unchanged-toast-datum"
+)
+
+// toastableTypes are the data types with a storage strategy that allows the
+// values to be stored out of line. Array types are also toastable.
+var toastableTypes = map[string]struct{}{
+	"text":              {},
+	"character varying": {},
+	"character":         {},
+	"bytea":             {},
+	"json":              {},
+	"jsonb":             {},
+	"xml":               {},
+	"tsvector":          {},
+	"tsquery":           {},
+	"hstore":            {},
+	"numeric":           {},
+	"bit varying":       {},
+	"citext":            {},
+}
+
+// New will return a TOAST cache processor wrapper that will fill in the
+// unchanged TOAST column values of the wal events before passing them over to
+// the processor on input.
+func New(cfg *Config, p processor.Processor, opts ...Option) *TOASTCache {
+	c := &TOASTCache{
+		logger:    loglib.NewNoopLogger(),
+		processor: p,
+		cache:     rowcache.NewLRU(cfg.maxEntries(), cfg.maxBytes()),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(c *TOASTCache) {
+		c.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_toast_cache",
+		})
+	}
+}
+
+func (c *TOASTCache) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	if event.Data != nil {
+		c.process(event.Data)
+	}
+
+	return c.processor.ProcessWALEvent(ctx, event)
+}
+
+func (c *TOASTCache) Name() string {
+	return c.processor.Name()
+}
+
+func (c *TOASTCache) Close() error {
+	return c.processor.Close()
+}
+
+func (c *TOASTCache) Ping(ctx context.Context) error {
+	return c.processor.Ping(ctx)
+}
+
+func (c *TOASTCache) process(data *wal.Data) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if processor.IsSchemaLogEvent(data) {
+		// the schema has changed, the columns of the cached rows might no
+		// longer be valid
+		if schemaName, found := rowcache.SchemaLogEventSchemaName(data); found {
+			c.cache.RemovePrefix(rowcache.SchemaKeyPrefix(schemaName))
+		}
+		return
+	}
+
+	if data.Schema == schemalog.SchemaName {
+		return
+	}
+
+	switch data.Action {
+	case "I":
+		if key, found := rowcache.RowKey(data); found {
+			c.store(key, data.Columns)
+		}
+	case "U":
+		key, found := rowcache.RowKey(data)
+		if !found {
+			return
+		}
+		c.fillUnchangedColumns(key, data)
+		c.store(key, data.Columns)
+		// if the row identity has changed, remove the old entry
+		if oldKey, found := rowcache.OldRowKey(data); found && oldKey != key {
+			c.cache.Remove(oldKey)
+		}
+	case "D":
+		if key, found := rowcache.OldRowKey(data); found {
+			c.cache.Remove(key)
+		}
+	case "T":
+		c.cache.RemovePrefix(rowcache.TableKeyPrefix(data))
+	}
+}
+
+// fillUnchangedColumns adds the TOAST columns that are missing or marked as
+// unchanged in the update event. The values are taken from the old values of
+// the event identity if available (replica identity full), or from the cache
+// otherwise.
+func (c *TOASTCache) fillUnchangedColumns(key string, data *wal.Data) {
+	cachedColumns, _ := c.cache.Get(key)
+
+	findColumn := func(name string) (wal.Column, bool) {
+		for _, col := range data.Identity {
+			if col.Name == name && !isUnchangedToastValue(col.Value) {
+				return col, true
+			}
+		}
+		for _, col := range cachedColumns {
+			if col.Name == name {
+				return col, true
+			}
+		}
+		return wal.Column{}, false
+	}
+
+	present := make(map[string]struct{}, len(data.Columns))
+	for i, col := range data.Columns {
+		present[col.Name] = struct{}{}
+		if !isUnchangedToastValue(col.Value) {
+			continue
+		}
+		if oldCol, found := findColumn(col.Name); found {
+			data.Columns[i].Value = oldCol.Value
+			continue
+		}
+		c.logger.Warn(nil, "unchanged TOAST column value not found in cache", loglib.Fields{
+			"schema": data.Schema,
+			"table":  data.Table,
+			"column": col.Name,
+		})
+	}
+
+	for _, col := range cachedColumns {
+		if _, found := present[col.Name]; found {
+			continue
+		}
+		if oldCol, found := findColumn(col.Name); found {
+			data.Columns = append(data.Columns, oldCol)
+		}
+	}
+}
+
+// store caches the TOAST-capable column values on input for the row key.
+func (c *TOASTCache) store(key string, columns []wal.Column) {
+	toastColumns := []wal.Column{}
+	size := int64(len(key))
+	for _, col := range columns {
+		if !isToastableType(col.Type) || col.Value == nil || isUnchangedToastValue(col.Value) {
+			continue
+		}
+		toastColumns = append(toastColumns, col)
+		size += int64(len(col.Name)+len(col.Type)+len(col.ID)) + rowcache.ValueSize(col.Value)
+	}
+
+	if len(toastColumns) == 0 {
+		c.cache.Remove(key)
+		return
+	}
+	c.cache.Put(key, toastColumns, size)
+}
+
+func isToastableType(dataType string) bool {
+	if strings.HasSuffix(dataType, "[]") {
+		return true
+	}
+	// remove any type modifiers, such as character varying(255)
+	if i := strings.IndexByte(dataType, '('); i > 0 {
+		dataType = dataType[:i]
+	}
+	_, found := toastableTypes[dataType]
+	return found
+}
+
+func isUnchangedToastValue(value any) bool {
+	v, ok := value.(string)
+	return ok && v == unchangedToastDatum
+}
+
+func (c *Config) maxEntries() int {
+	if c.MaxEntries > 0 {
+		return c.MaxEntries
+	}
+	return defaultMaxEntries
+}
+
+func (c *Config) maxBytes() int64 {
+	if c.MaxBytes > 0 {
+		return c.MaxBytes
+	}
+	return defaultMaxBytes
+}
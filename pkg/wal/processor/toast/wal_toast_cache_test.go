@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package toast
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+func TestTOASTCache_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	testMetadata := wal.Metadata{
+		TablePgstreamID: "t1",
+		InternalColIDs:  []string{"t1-1"},
+	}
+
+	idColumn := wal.Column{ID: "t1-1", Name: "id", Type: "integer", Value: 1}
+	bodyColumn := wal.Column{ID: "t1-2", Name: "body", Type: "text", Value: "large text"}
+	countColumn := wal.Column{ID: "t1-3", Name: "count", Type: "integer", Value: 5}
+	unchangedBodyColumn := wal.Column{ID: "t1-2", Name: "body", Type: "text", Value: unchangedToastDatum}
+
+	newEvent := func(action string, metadata wal.Metadata, columns, identity []wal.Column) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action:   action,
+				Schema:   "public",
+				Table:    "test",
+				Columns:  columns,
+				Identity: identity,
+				Metadata: metadata,
+			},
+		}
+	}
+
+	tests := []struct {
+		name string
+		// events processed before the one being validated
+		previousEvents []*wal.Event
+		event          *wal.Event
+		processErr     error
+
+		wantColumns []wal.Column
+		wantErr     error
+	}{
+		{
+			name: "ok - unchanged column filled from insert",
+			previousEvents: []*wal.Event{
+				newEvent("I", testMetadata, []wal.Column{idColumn, bodyColumn, countColumn}, nil),
+			},
+			event: newEvent("U", testMetadata, []wal.Column{idColumn, countColumn}, []wal.Column{idColumn}),
+
+			wantColumns: []wal.Column{idColumn, countColumn, bodyColumn},
+		},
+		{
+			name: "ok - unchanged column marker replaced",
+			previousEvents: []*wal.Event{
+				newEvent("I", testMetadata, []wal.Column{idColumn, bodyColumn, countColumn}, nil),
+			},
+			event: newEvent("U", testMetadata, []wal.Column{idColumn, unchangedBodyColumn, countColumn}, []wal.Column{idColumn}),
+
+			wantColumns: []wal.Column{idColumn, bodyColumn, countColumn},
+		},
+		{
+			name: "ok - unchanged column filled from previous update",
+			previousEvents: []*wal.Event{
+				newEvent("I", testMetadata, []wal.Column{idColumn, {ID: "t1-2", Name: "body", Type: "text", Value: "old text"}}, nil),
+				newEvent("U", testMetadata, []wal.Column{idColumn, bodyColumn}, []wal.Column{idColumn}),
+			},
+			event: newEvent("U", testMetadata, []wal.Column{idColumn, countColumn}, []wal.Column{idColumn}),
+
+			wantColumns: []wal.Column{idColumn, countColumn, bodyColumn},
+		},
+		{
+			name: "ok - unchanged column filled from replica identity full",
+			event: newEvent("U", testMetadata, []wal.Column{idColumn, unchangedBodyColumn, countColumn},
+				[]wal.Column{idColumn, bodyColumn, countColumn}),
+
+			wantColumns: []wal.Column{idColumn, bodyColumn, countColumn},
+		},
+		{
+			name: "ok - no metadata, row identified by replica identity",
+			previousEvents: []*wal.Event{
+				newEvent("U", wal.Metadata{}, []wal.Column{idColumn, bodyColumn}, []wal.Column{idColumn}),
+			},
+			event: newEvent("U", wal.Metadata{}, []wal.Column{idColumn, countColumn}, []wal.Column{idColumn}),
+
+			wantColumns: []wal.Column{idColumn, countColumn, bodyColumn},
+		},
+		{
+			name: "ok - cache evicted on delete",
+			previousEvents: []*wal.Event{
+				newEvent("I", testMetadata, []wal.Column{idColumn, bodyColumn}, nil),
+				newEvent("D", testMetadata, nil, []wal.Column{idColumn}),
+			},
+			event: newEvent("U", testMetadata, []wal.Column{idColumn, countColumn}, []wal.Column{idColumn}),
+
+			wantColumns: []wal.Column{idColumn, countColumn},
+		},
+		{
+			name: "ok - cache evicted on truncate",
+			previousEvents: []*wal.Event{
+				newEvent("I", testMetadata, []wal.Column{idColumn, bodyColumn}, nil),
+				newEvent("T", wal.Metadata{}, nil, nil),
+			},
+			event: newEvent("U", testMetadata, []wal.Column{idColumn, countColumn}, []wal.Column{idColumn}),
+
+			wantColumns: []wal.Column{idColumn, countColumn},
+		},
+		{
+			name: "ok - cache evicted on schema change",
+			previousEvents: []*wal.Event{
+				newEvent("I", testMetadata, []wal.Column{idColumn, bodyColumn}, nil),
+				{
+					Data: &wal.Data{
+						Action: "I",
+						Schema: schemalog.SchemaName,
+						Table:  schemalog.TableName,
+						Columns: []wal.Column{
+							{Name: "schema_name", Type: "text", Value: "public"},
+						},
+					},
+				},
+			},
+			event: newEvent("U", testMetadata, []wal.Column{idColumn, countColumn}, []wal.Column{idColumn}),
+
+			wantColumns: []wal.Column{idColumn, countColumn},
+		},
+		{
+			name: "ok - different row not filled",
+			previousEvents: []*wal.Event{
+				newEvent("I", testMetadata, []wal.Column{idColumn, bodyColumn}, nil),
+			},
+			event: newEvent("U", testMetadata,
+				[]wal.Column{{ID: "t1-1", Name: "id", Type: "integer", Value: 2}, countColumn},
+				[]wal.Column{{ID: "t1-1", Name: "id", Type: "integer", Value: 2}}),
+
+			wantColumns: []wal.Column{{ID: "t1-1", Name: "id", Type: "integer", Value: 2}, countColumn},
+		},
+		{
+			name: "error - processing event",
+			previousEvents: []*wal.Event{
+				newEvent("I", testMetadata, []wal.Column{idColumn, bodyColumn}, nil),
+			},
+			event:      newEvent("U", testMetadata, []wal.Column{idColumn}, []wal.Column{idColumn}),
+			processErr: errTest,
+
+			wantColumns: []wal.Column{idColumn, bodyColumn},
+			wantErr:     errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var processedEvent *wal.Event
+			c := New(&Config{}, &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					processedEvent = walEvent
+					return nil
+				},
+			})
+
+			for _, event := range tc.previousEvents {
+				require.NoError(t, c.ProcessWALEvent(context.Background(), event))
+			}
+
+			c.processor = &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					processedEvent = walEvent
+					return tc.processErr
+				},
+			}
+			err := c.ProcessWALEvent(context.Background(), tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantColumns, processedEvent.Data.Columns)
+		})
+	}
+}
+
+func TestIsToastableType(t *testing.T) {
+	t.Parallel()
+
+	require.True(t, isToastableType("text"))
+	require.True(t, isToastableType("character varying(255)"))
+	require.True(t, isToastableType("integer[]"))
+	require.True(t, isToastableType("jsonb"))
+	require.False(t, isToastableType("integer"))
+	require.False(t, isToastableType("timestamp with time zone"))
+}
@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transaction
+
+import (
+	"context"
+	"sync"
+
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/checkpointer"
+	"github.com/xataio/pgstream/pkg/wal/replication"
+)
+
+// CommitTracker defers the commit hook of the transactions until the
+// processor acknowledges them, by checkpointing a position at or past their
+// commit position. Without it, the commit hook is called as soon as the
+// commit event has been processed, which for the batching processors only
+// means it's been enqueued.
+type CommitTracker struct {
+	onCommit CommitHook
+	parser   replication.LSNParser
+
+	// mutex protects the pending commits, added on every processed commit
+	// event and removed on every checkpoint. The hooks are called with it
+	// held, so that they're called in commit order.
+	mutex   sync.Mutex
+	pending []pendingCommit
+}
+
+type pendingCommit struct {
+	xid      uint32
+	lsn      replication.LSN
+	position wal.CommitPosition
+}
+
+// NewCommitTracker returns a tracker that calls the commit hook on input once
+// the transactions are acknowledged. The processor must be configured with it
+// and its checkpoints routed through it for the hook to be called.
+func NewCommitTracker(onCommit CommitHook, parser replication.LSNParser) *CommitTracker {
+	return &CommitTracker{
+		onCommit: onCommit,
+		parser:   parser,
+	}
+}
+
+// WithCommitTracker configures the processor to call the commit hook once
+// the transactions are acknowledged instead of once they're processed. It
+// replaces the commit hook of the processor configuration.
+func WithCommitTracker(t *CommitTracker) Option {
+	return func(p *TransactionAwareProcessor) {
+		p.onCommit = t.track
+	}
+}
+
+// Checkpoint returns a checkpoint that calls the commit hook of the
+// transactions acknowledged by the positions on input, once they've been
+// checkpointed successfully.
+func (t *CommitTracker) Checkpoint(next checkpointer.Checkpoint) checkpointer.Checkpoint {
+	return func(ctx context.Context, positions []wal.CommitPosition) error {
+		if err := next(ctx, positions); err != nil {
+			return err
+		}
+		t.Acknowledge(positions)
+		return nil
+	}
+}
+
+// Acknowledge calls the commit hook of the pending transactions committed up
+// to the max position on input. The positions that are not valid LSNs are
+// ignored.
+func (t *CommitTracker) Acknowledge(positions []wal.CommitPosition) {
+	var max replication.LSN
+	for _, position := range positions {
+		lsn, err := t.parser.FromString(string(position))
+		if err == nil && lsn > max {
+			max = lsn
+		}
+	}
+	if max == 0 {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	// the commits are tracked in commit order, which is also LSN order
+	acked := 0
+	for _, commit := range t.pending {
+		if commit.lsn > max {
+			break
+		}
+		if t.onCommit != nil {
+			t.onCommit(commit.xid, commit.position)
+		}
+		acked++
+	}
+	t.pending = t.pending[acked:]
+}
+
+func (t *CommitTracker) track(xid uint32, position wal.CommitPosition) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	lsn, err := t.parser.FromString(string(position))
+	if err != nil {
+		// the commit can't be matched with a checkpoint, so it's notified
+		// right away rather than never
+		if t.onCommit != nil {
+			t.onCommit(xid, position)
+		}
+		return
+	}
+	t.pending = append(t.pending, pendingCommit{
+		xid:      xid,
+		lsn:      lsn,
+		position: position,
+	})
+}
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+func TestCommitTracker_Checkpoint(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name          string
+		commits       map[uint32]wal.CommitPosition
+		positions     []wal.CommitPosition
+		checkpointErr error
+
+		wantHooks []string
+		wantErr   error
+	}{
+		{
+			name: "ok - commits up to the max position acknowledged",
+			commits: map[uint32]wal.CommitPosition{
+				1: "0/10",
+				2: "0/20",
+				3: "0/30",
+			},
+			positions: []wal.CommitPosition{"0/15", "0/20"},
+
+			wantHooks: []string{"commit:1@0/10", "commit:2@0/20"},
+		},
+		{
+			name: "ok - no commits acknowledged",
+			commits: map[uint32]wal.CommitPosition{
+				1: "0/10",
+			},
+			positions: []wal.CommitPosition{"0/5", "invalid"},
+
+			wantHooks: []string{},
+		},
+		{
+			name: "ok - invalid commit position notified right away",
+			commits: map[uint32]wal.CommitPosition{
+				1: "invalid",
+			},
+			positions: []wal.CommitPosition{},
+
+			wantHooks: []string{"commit:1@invalid"},
+		},
+		{
+			name: "error - checkpointing",
+			commits: map[uint32]wal.CommitPosition{
+				1: "0/10",
+			},
+			positions:     []wal.CommitPosition{"0/10"},
+			checkpointErr: errTest,
+
+			wantHooks: []string{},
+			wantErr:   errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotHooks := []string{}
+			tracker := NewCommitTracker(func(xid uint32, position wal.CommitPosition) {
+				gotHooks = append(gotHooks, fmt.Sprintf("commit:%d@%s", xid, position))
+			}, pgreplication.NewLSNParser())
+
+			for xid := uint32(1); xid <= uint32(len(tc.commits)); xid++ {
+				tracker.track(xid, tc.commits[xid])
+			}
+
+			checkpoint := tracker.Checkpoint(func(ctx context.Context, positions []wal.CommitPosition) error {
+				return tc.checkpointErr
+			})
+			err := checkpoint(context.Background(), tc.positions)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantHooks, gotHooks)
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transaction
+
+import (
+	"context"
+	"sync"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// TransactionAwareProcessor is a decorator around a wal processor that calls
+// the configured hooks on the transaction boundaries. By default, the commit
+// hook is called once the commit event has been processed by the wrapped
+// processor, which happens after all the events of the transaction have been
+// processed. For the processors that batch the events, that only means
+// they've been enqueued, not written to the target. Use a CommitTracker for
+// the commit hook to be called once the transaction has been checkpointed.
+//
+// The rollback hook is called for the transactions that are left without a
+// commit record, which is detected when the events of a different transaction
+// are received while one is open. The replication only sends committed
+// transactions, so this happens when the commit event doesn't reach the
+// processor, for instance when it's filtered out before this layer, or when
+// the decoding plugin streams in progress transactions that are then
+// aborted. It doesn't guarantee the transaction was rolled back in the source
+// database, and it's never called on Close.
+//
+// It requires the listener to include the transaction id of the events, and
+// to emit the commit events. Events that don't belong to a transaction are
+// sent to the wrapped processor without calling any hooks.
+type TransactionAwareProcessor struct {
+	logger     loglib.Logger
+	processor  processor.Processor
+	onCommit   CommitHook
+	onRollback RollbackHook
+
+	// mutex serialises the calls to the wrapped processor, so that the hooks
+	// are called in transaction order
+	mutex sync.Mutex
+	// openXID is the id of the transaction being processed, or 0 if there's
+	// none
+	openXID uint32
+}
+
+// CommitHook is called with the id and the commit position of every committed
+// transaction, in commit order. It must not block, since it's called from the
+// processing or the checkpointing path.
+type CommitHook func(xid uint32, position wal.CommitPosition)
+
+// RollbackHook is called with the id of every transaction that isn't
+// committed.
+type RollbackHook func(xid uint32)
+
+type Config struct {
+	OnCommit   CommitHook
+	OnRollback RollbackHook
+}
+
+type Option func(p *TransactionAwareProcessor)
+
+// New will return a transaction aware wrapper around the processor on input.
+func New(cfg *Config, p processor.Processor, opts ...Option) *TransactionAwareProcessor {
+	tp := &TransactionAwareProcessor{
+		logger:     loglib.NewNoopLogger(),
+		processor:  p,
+		onCommit:   cfg.OnCommit,
+		onRollback: cfg.OnRollback,
+	}
+
+	for _, opt := range opts {
+		opt(tp)
+	}
+
+	return tp
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(p *TransactionAwareProcessor) {
+		p.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_transaction_aware_processor",
+		})
+	}
+}
+
+// ProcessWALEvent sends the event on input to the wrapped processor, and calls
+// the transaction hooks once the transaction boundaries are processed.
+func (p *TransactionAwareProcessor) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	data := event.Data
+	if data == nil || data.XID == 0 {
+		return p.processor.ProcessWALEvent(ctx, event)
+	}
+
+	if p.openXID != 0 && p.openXID != data.XID {
+		p.rollback(p.openXID)
+	}
+
+	if wal.Action(data.Action) != wal.ActionCommit {
+		p.openXID = data.XID
+		return p.processor.ProcessWALEvent(ctx, event)
+	}
+
+	p.openXID = 0
+	if err := p.processor.ProcessWALEvent(ctx, event); err != nil {
+		return err
+	}
+	if p.onCommit != nil {
+		p.onCommit(data.XID, event.CommitPosition)
+	}
+	return nil
+}
+
+func (p *TransactionAwareProcessor) Name() string {
+	return p.processor.Name()
+}
+
+// Close closes the wrapped processor. The hooks are not called for the
+// transaction being processed, since it will be received again when the
+// replication is resumed.
+func (p *TransactionAwareProcessor) Close() error {
+	return p.processor.Close()
+}
+
+func (p *TransactionAwareProcessor) Ping(ctx context.Context) error {
+	return p.processor.Ping(ctx)
+}
+
+func (p *TransactionAwareProcessor) rollback(xid uint32) {
+	p.logger.Warn(nil, "transaction without commit record, rolling back", loglib.Fields{
+		"xid": xid,
+	})
+	p.openXID = 0
+	if p.onRollback != nil {
+		p.onRollback(xid)
+	}
+}
@@ -0,0 +1,153 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+	pgreplication "github.com/xataio/pgstream/pkg/wal/replication/postgres"
+)
+
+func TestTransactionAwareProcessor_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	newEvent := func(xid uint32, action wal.Action, position string) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action: string(action),
+				Schema: "public",
+				Table:  "users",
+				XID:    xid,
+			},
+			CommitPosition: wal.CommitPosition(position),
+		}
+	}
+
+	tests := []struct {
+		name       string
+		events     []*wal.Event
+		processErr func(event *wal.Event) error
+		// ackedPositions are checkpointed after the events are processed,
+		// with the commit hook driven by a commit tracker
+		ackedPositions []wal.CommitPosition
+
+		// wantHooks is a short representation of the hooks calls, in call
+		// order: commit:xid@position or rollback:xid
+		wantHooks     []string
+		wantProcessed int
+		wantErr       error
+	}{
+		{
+			name: "commit hook called after the transaction events",
+			events: []*wal.Event{
+				newEvent(1, wal.ActionInsert, "1"),
+				newEvent(1, wal.ActionUpdate, "2"),
+				newEvent(1, wal.ActionCommit, "3"),
+				{CommitPosition: "4"},
+				newEvent(0, wal.ActionInsert, "5"),
+				newEvent(2, wal.ActionCommit, "6"),
+			},
+
+			wantHooks:     []string{"commit:1@3", "commit:2@6"},
+			wantProcessed: 6,
+		},
+		{
+			name: "rollback hook called for transactions without commit",
+			events: []*wal.Event{
+				newEvent(1, wal.ActionInsert, "1"),
+				newEvent(2, wal.ActionInsert, "2"),
+				newEvent(2, wal.ActionCommit, "3"),
+			},
+
+			wantHooks:     []string{"rollback:1", "commit:2@3"},
+			wantProcessed: 3,
+		},
+		{
+			name: "commit hook called once the transaction is acknowledged",
+			events: []*wal.Event{
+				newEvent(1, wal.ActionInsert, "0/1"),
+				newEvent(1, wal.ActionCommit, "0/2"),
+				newEvent(2, wal.ActionInsert, "0/3"),
+				newEvent(2, wal.ActionCommit, "0/4"),
+				newEvent(3, wal.ActionInsert, "0/5"),
+				newEvent(4, wal.ActionInsert, "0/6"),
+			},
+			ackedPositions: []wal.CommitPosition{"0/1", "0/2"},
+
+			wantHooks:     []string{"rollback:3", "commit:1@0/2"},
+			wantProcessed: 6,
+		},
+		{
+			name: "error - processing commit event",
+			events: []*wal.Event{
+				newEvent(1, wal.ActionInsert, "1"),
+				newEvent(1, wal.ActionCommit, "2"),
+			},
+			processErr: func(event *wal.Event) error {
+				if event.Data != nil && wal.Action(event.Data.Action) == wal.ActionCommit {
+					return errTest
+				}
+				return nil
+			},
+
+			wantHooks:     []string{},
+			wantProcessed: 2,
+			wantErr:       errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotHooks := []string{}
+			processed := 0
+			mockProcessor := &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+					processed++
+					if tc.processErr != nil {
+						return tc.processErr(event)
+					}
+					return nil
+				},
+			}
+
+			cfg := &Config{
+				OnCommit: func(xid uint32, position wal.CommitPosition) {
+					gotHooks = append(gotHooks, fmt.Sprintf("commit:%d@%s", xid, position))
+				},
+				OnRollback: func(xid uint32) {
+					gotHooks = append(gotHooks, fmt.Sprintf("rollback:%d", xid))
+				},
+			}
+			tracker := NewCommitTracker(cfg.OnCommit, pgreplication.NewLSNParser())
+			opts := []Option{}
+			if tc.ackedPositions != nil {
+				opts = append(opts, WithCommitTracker(tracker))
+			}
+
+			p := New(cfg, mockProcessor, opts...)
+
+			var err error
+			for _, event := range tc.events {
+				if err = p.ProcessWALEvent(context.Background(), event); err != nil {
+					break
+				}
+			}
+			if tc.ackedPositions != nil {
+				tracker.Acknowledge(tc.ackedPositions)
+			}
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantHooks, gotHooks)
+			require.Equal(t, tc.wantProcessed, processed)
+		})
+	}
+}
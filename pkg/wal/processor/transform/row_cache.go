@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transform
+
+import (
+	"context"
+	"sync"
+
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/rowcache"
+)
+
+// Cache keeps the latest known column values of the rows, by row key. The keys
+// of a table share the same prefix, so that all the rows of a table or schema
+// can be removed at once.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]wal.Column, bool, error)
+	Put(ctx context.Context, key string, columns []wal.Column) error
+	Remove(ctx context.Context, key string) error
+	RemovePrefix(ctx context.Context, prefix string) error
+}
+
+// memoryCache is an in memory LRU cache, bounded by number of rows and total
+// byte size.
+type memoryCache struct {
+	mutex sync.Mutex
+	lru   *rowcache.LRU
+}
+
+func newMemoryCache(maxEntries int, maxBytes int64) *memoryCache {
+	return &memoryCache{
+		lru: rowcache.NewLRU(maxEntries, maxBytes),
+	}
+}
+
+func (c *memoryCache) Get(_ context.Context, key string) ([]wal.Column, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	columns, found := c.lru.Get(key)
+	return columns, found, nil
+}
+
+func (c *memoryCache) Put(_ context.Context, key string, columns []wal.Column) error {
+	size := int64(len(key))
+	for _, col := range columns {
+		size += int64(len(col.Name)+len(col.Type)+len(col.ID)) + rowcache.ValueSize(col.Value)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lru.Put(key, columns, size)
+	return nil
+}
+
+func (c *memoryCache) Remove(_ context.Context, key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lru.Remove(key)
+	return nil
+}
+
+func (c *memoryCache) RemovePrefix(_ context.Context, prefix string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lru.RemovePrefix(prefix)
+	return nil
+}
@@ -0,0 +1,231 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transform
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/rowcache"
+)
+
+// BeforeAfterNormalizer is a decorator around a wal processor that makes sure
+// update and delete events include the before image of the row. With the
+// default replica identity, postgres only includes the old values of the
+// primary key columns, and for updates only if the key changed. The normalizer
+// keeps the latest known state of each row, identified by its primary key, and
+// uses it to set the before image of the event, so that sinks such as audit
+// logs receive both the before and after images. The before image is set in
+// its own field, and the event identity is left as provided by postgres, so
+// that the sinks keep identifying the rows by their replica identity.
+//
+// Rows are identified by the pgstream identity columns when the event metadata
+// has been injected. Otherwise, the replica identity of the event is used, in
+// which case insert events can't be cached. Rows that are not cached (i.e.
+// modified before the normalizer was started, or evicted from the cache)
+// are sent with the identity provided by postgres.
+type BeforeAfterNormalizer struct {
+	logger    loglib.Logger
+	processor processor.Processor
+	cache     Cache
+}
+
+type Config struct {
+	// MaxEntries is the maximum number of rows to keep in the in memory
+	// cache. Defaults to 10000.
+	MaxEntries int
+	// MaxBytes is the maximum size in bytes of the rows in the in memory
+	// cache. Defaults to 64MiB.
+	MaxBytes int64
+}
+
+type Option func(n *BeforeAfterNormalizer)
+
+const (
+	defaultMaxEntries = 10000
+	defaultMaxBytes   = 64 * 1024 * 1024 // 64MiB
+
+	// value used by postgres output plugins to mark unchanged TOAST columns
+	unchangedToastDatum = "unchanged-toast-datum"
+)
+
+// New will return a normalizer processor wrapper that will fill in the before
+// image of the wal events before passing them over to the processor on input.
+// The rows are kept in an in memory cache by default, which can be replaced by
+// a shared one (i.e. Redis backed) with the WithCache option.
+func New(cfg *Config, p processor.Processor, opts ...Option) *BeforeAfterNormalizer {
+	n := &BeforeAfterNormalizer{
+		logger:    loglib.NewNoopLogger(),
+		processor: p,
+		cache:     newMemoryCache(cfg.maxEntries(), cfg.maxBytes()),
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	return n
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(n *BeforeAfterNormalizer) {
+		n.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_before_after_normalizer",
+		})
+	}
+}
+
+// WithCache sets the cache used to keep the latest known state of the rows.
+func WithCache(c Cache) Option {
+	return func(n *BeforeAfterNormalizer) {
+		n.cache = c
+	}
+}
+
+func (n *BeforeAfterNormalizer) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	if event.Data != nil {
+		if err := n.normalize(ctx, event.Data); err != nil {
+			return fmt.Errorf("before/after normalizer: %w", err)
+		}
+	}
+
+	return n.processor.ProcessWALEvent(ctx, event)
+}
+
+func (n *BeforeAfterNormalizer) Name() string {
+	return n.processor.Name()
+}
+
+func (n *BeforeAfterNormalizer) Close() error {
+	return n.processor.Close()
+}
+
+func (n *BeforeAfterNormalizer) Ping(ctx context.Context) error {
+	return n.processor.Ping(ctx)
+}
+
+func (n *BeforeAfterNormalizer) normalize(ctx context.Context, data *wal.Data) error {
+	if processor.IsSchemaLogEvent(data) {
+		// the schema has changed, the columns of the cached rows might no
+		// longer be valid
+		if schemaName, found := rowcache.SchemaLogEventSchemaName(data); found {
+			return n.cache.RemovePrefix(ctx, rowcache.SchemaKeyPrefix(schemaName))
+		}
+		return nil
+	}
+
+	if data.Schema == schemalog.SchemaName {
+		return nil
+	}
+
+	switch wal.Action(data.Action) {
+	case wal.ActionInsert:
+		if key, found := rowcache.RowKey(data); found {
+			return n.cache.Put(ctx, key, slices.Clone(data.Columns))
+		}
+	case wal.ActionUpdate:
+		key, found := rowcache.RowKey(data)
+		if !found {
+			return nil
+		}
+		// the old key is only available if the row identity has changed
+		oldKey, oldKeyFound := rowcache.OldRowKey(data)
+		if !oldKeyFound {
+			oldKey = key
+		}
+		if err := n.fillBeforeImage(ctx, oldKey, data); err != nil {
+			return err
+		}
+		fillUnchangedToastColumns(data)
+		if oldKey != key {
+			if err := n.cache.Remove(ctx, oldKey); err != nil {
+				return err
+			}
+		}
+		return n.cache.Put(ctx, key, slices.Clone(data.Columns))
+	case wal.ActionDelete:
+		key, found := rowcache.OldRowKey(data)
+		if !found {
+			return nil
+		}
+		if err := n.fillBeforeImage(ctx, key, data); err != nil {
+			return err
+		}
+		return n.cache.Remove(ctx, key)
+	case wal.ActionTruncate:
+		return n.cache.RemovePrefix(ctx, rowcache.TableKeyPrefix(data))
+	}
+
+	return nil
+}
+
+// fillBeforeImage sets the before image of the event to the cached row for the
+// key on input. The old values of the identity provided by postgres take
+// precedence over the cached ones, since they're always up to date.
+func (n *BeforeAfterNormalizer) fillBeforeImage(ctx context.Context, key string, data *wal.Data) error {
+	cachedColumns, found, err := n.cache.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !found {
+		n.logger.Debug("before image not found in cache", loglib.Fields{
+			"schema": data.Schema,
+			"table":  data.Table,
+			"action": data.Action,
+		})
+		return nil
+	}
+
+	before := slices.Clone(cachedColumns)
+	for _, col := range data.Identity {
+		if isUnchangedToastValue(col.Value) {
+			continue
+		}
+		i := slices.IndexFunc(before, func(c wal.Column) bool { return c.Name == col.Name })
+		if i < 0 {
+			before = append(before, col)
+			continue
+		}
+		before[i] = col
+	}
+	data.Before = before
+	return nil
+}
+
+// fillUnchangedToastColumns replaces the unchanged TOAST column values of the
+// after image with the before image values, if available.
+func fillUnchangedToastColumns(data *wal.Data) {
+	for i, col := range data.Columns {
+		if !isUnchangedToastValue(col.Value) {
+			continue
+		}
+		j := slices.IndexFunc(data.Before, func(c wal.Column) bool { return c.Name == col.Name })
+		if j >= 0 {
+			data.Columns[i].Value = data.Before[j].Value
+		}
+	}
+}
+
+func isUnchangedToastValue(value any) bool {
+	v, ok := value.(string)
+	return ok && v == unchangedToastDatum
+}
+
+func (c *Config) maxEntries() int {
+	if c.MaxEntries > 0 {
+		return c.MaxEntries
+	}
+	return defaultMaxEntries
+}
+
+func (c *Config) maxBytes() int64 {
+	if c.MaxBytes > 0 {
+		return c.MaxBytes
+	}
+	return defaultMaxBytes
+}
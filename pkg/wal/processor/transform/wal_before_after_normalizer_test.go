@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transform
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+var (
+	testMetadata = wal.Metadata{
+		TablePgstreamID: "t1",
+		InternalColIDs:  []string{"t1-1"},
+	}
+
+	idColumn       = wal.Column{ID: "t1-1", Name: "id", Type: "integer", Value: 1}
+	nameColumn     = wal.Column{ID: "t1-2", Name: "name", Type: "text", Value: "alice"}
+	bodyColumn     = wal.Column{ID: "t1-3", Name: "body", Type: "text", Value: "large text"}
+	unchangedBody  = wal.Column{ID: "t1-3", Name: "body", Type: "text", Value: unchangedToastDatum}
+	newNameColumn  = wal.Column{ID: "t1-2", Name: "name", Type: "text", Value: "bob"}
+	newIDColumn    = wal.Column{ID: "t1-1", Name: "id", Type: "integer", Value: 2}
+	otherIDColumn  = wal.Column{ID: "t1-1", Name: "id", Type: "integer", Value: 3}
+	testInsertRow  = []wal.Column{idColumn, nameColumn, bodyColumn}
+	testUpdatedRow = []wal.Column{idColumn, newNameColumn, bodyColumn}
+)
+
+func newTestEvent(action wal.Action, metadata wal.Metadata, columns, identity []wal.Column) *wal.Event {
+	return &wal.Event{
+		Data: &wal.Data{
+			Action:   string(action),
+			Schema:   "public",
+			Table:    "test",
+			Columns:  columns,
+			Identity: identity,
+			Metadata: metadata,
+		},
+	}
+}
+
+func TestBeforeAfterNormalizer_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name string
+		// events processed before the one being validated
+		previousEvents []*wal.Event
+		event          *wal.Event
+		cache          Cache
+		processErr     error
+
+		wantColumns []wal.Column
+		wantBefore  []wal.Column
+		wantErr     error
+	}{
+		{
+			name: "ok - before image filled from insert",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+			},
+			event: newTestEvent(wal.ActionUpdate, testMetadata, testUpdatedRow, nil),
+
+			wantColumns: testUpdatedRow,
+			wantBefore:  testInsertRow,
+		},
+		{
+			name: "ok - before image filled from previous update",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+				newTestEvent(wal.ActionUpdate, testMetadata, testUpdatedRow, nil),
+			},
+			event: newTestEvent(wal.ActionUpdate, testMetadata, testInsertRow, nil),
+
+			wantColumns: testInsertRow,
+			wantBefore:  testUpdatedRow,
+		},
+		{
+			name: "ok - unchanged toast values filled from before image",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+			},
+			event: newTestEvent(wal.ActionUpdate, testMetadata, []wal.Column{idColumn, newNameColumn, unchangedBody}, nil),
+
+			wantColumns: testUpdatedRow,
+			wantBefore:  testInsertRow,
+		},
+		{
+			name: "ok - primary key changed",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+			},
+			event: newTestEvent(wal.ActionUpdate, testMetadata, []wal.Column{newIDColumn, nameColumn, bodyColumn}, []wal.Column{idColumn}),
+
+			wantColumns: []wal.Column{newIDColumn, nameColumn, bodyColumn},
+			wantBefore:  testInsertRow,
+		},
+		{
+			name: "ok - old values from postgres take precedence",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+			},
+			event: newTestEvent(wal.ActionUpdate, testMetadata, testUpdatedRow, []wal.Column{idColumn, {ID: "t1-2", Name: "name", Type: "text", Value: "carol"}}),
+
+			wantColumns: testUpdatedRow,
+			wantBefore:  []wal.Column{idColumn, {ID: "t1-2", Name: "name", Type: "text", Value: "carol"}, bodyColumn},
+		},
+		{
+			name: "ok - before image filled on delete",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+			},
+			event: newTestEvent(wal.ActionDelete, testMetadata, nil, []wal.Column{idColumn}),
+
+			wantBefore: testInsertRow,
+		},
+		{
+			name: "ok - no metadata, row identified by replica identity",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionUpdate, wal.Metadata{}, testInsertRow, []wal.Column{idColumn}),
+			},
+			event: newTestEvent(wal.ActionDelete, wal.Metadata{}, nil, []wal.Column{idColumn}),
+
+			wantBefore: testInsertRow,
+		},
+		{
+			name:  "ok - row not cached",
+			event: newTestEvent(wal.ActionUpdate, testMetadata, testUpdatedRow, nil),
+
+			wantColumns: testUpdatedRow,
+		},
+		{
+			name: "ok - different row not filled",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+			},
+			event: newTestEvent(wal.ActionUpdate, testMetadata, []wal.Column{otherIDColumn, nameColumn}, nil),
+
+			wantColumns: []wal.Column{otherIDColumn, nameColumn},
+		},
+		{
+			name: "ok - cache evicted on delete",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+				newTestEvent(wal.ActionDelete, testMetadata, nil, []wal.Column{idColumn}),
+			},
+			event: newTestEvent(wal.ActionUpdate, testMetadata, testUpdatedRow, nil),
+
+			wantColumns: testUpdatedRow,
+		},
+		{
+			name: "ok - old key evicted on primary key change",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+				newTestEvent(wal.ActionUpdate, testMetadata, []wal.Column{newIDColumn, nameColumn, bodyColumn}, []wal.Column{idColumn}),
+			},
+			event: newTestEvent(wal.ActionDelete, testMetadata, nil, []wal.Column{idColumn}),
+		},
+		{
+			name: "ok - cache evicted on truncate",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+				newTestEvent(wal.ActionTruncate, wal.Metadata{}, nil, nil),
+			},
+			event: newTestEvent(wal.ActionUpdate, testMetadata, testUpdatedRow, nil),
+
+			wantColumns: testUpdatedRow,
+		},
+		{
+			name: "ok - cache evicted on schema change",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+				{
+					Data: &wal.Data{
+						Action: "I",
+						Schema: schemalog.SchemaName,
+						Table:  schemalog.TableName,
+						Columns: []wal.Column{
+							{Name: "schema_name", Type: "text", Value: "public"},
+						},
+					},
+				},
+			},
+			event: newTestEvent(wal.ActionUpdate, testMetadata, testUpdatedRow, nil),
+
+			wantColumns: testUpdatedRow,
+		},
+		{
+			name:  "error - cache",
+			event: newTestEvent(wal.ActionUpdate, testMetadata, testUpdatedRow, nil),
+			cache: &mockCache{
+				getFn: func(key string) ([]wal.Column, bool, error) { return nil, false, errTest },
+			},
+
+			wantErr: errTest,
+		},
+		{
+			name: "error - processing event",
+			previousEvents: []*wal.Event{
+				newTestEvent(wal.ActionInsert, testMetadata, testInsertRow, nil),
+			},
+			event:      newTestEvent(wal.ActionUpdate, testMetadata, testUpdatedRow, nil),
+			processErr: errTest,
+
+			wantColumns: testUpdatedRow,
+			wantBefore:  testInsertRow,
+			wantErr:     errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var processedEvent *wal.Event
+			opts := []Option{}
+			if tc.cache != nil {
+				opts = append(opts, WithCache(tc.cache))
+			}
+			n := New(&Config{}, &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					return nil
+				},
+			}, opts...)
+
+			for _, event := range tc.previousEvents {
+				require.NoError(t, n.ProcessWALEvent(context.Background(), event))
+			}
+
+			n.processor = &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					processedEvent = walEvent
+					return tc.processErr
+				},
+			}
+			wantIdentity := slices.Clone(tc.event.Data.Identity)
+			err := n.ProcessWALEvent(context.Background(), tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil && tc.processErr == nil {
+				require.Nil(t, processedEvent)
+				return
+			}
+			require.Equal(t, tc.wantColumns, processedEvent.Data.Columns)
+			require.Equal(t, tc.wantBefore, processedEvent.Data.Before)
+			// the identity is left as provided by postgres
+			require.Equal(t, wantIdentity, processedEvent.Data.Identity)
+		})
+	}
+}
+
+type mockCache struct {
+	getFn func(key string) ([]wal.Column, bool, error)
+}
+
+func (m *mockCache) Get(_ context.Context, key string) ([]wal.Column, bool, error) {
+	return m.getFn(key)
+}
+
+func (m *mockCache) Put(context.Context, string, []wal.Column) error {
+	return nil
+}
+
+func (m *mockCache) Remove(context.Context, string) error {
+	return nil
+}
+
+func (m *mockCache) RemovePrefix(context.Context, string) error {
+	return nil
+}
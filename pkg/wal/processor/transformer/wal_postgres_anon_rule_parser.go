@@ -63,8 +63,7 @@ func NewAnonRuleParser(ctx context.Context, pgURL string, dumpToFile bool, logge
 // and convert them into transformation rules before passing them to the wrapped
 // parser for validation.
 func (a *AnonRuleParser) ParseAndValidate(ctx context.Context, rules Rules) (map[string]ColumnTransformers, error) {
-	var err error
-	rules.Transformers, err = a.parseAnonMaskingRules(ctx)
+	rules, err := a.InferRules(ctx, rules)
 	if err != nil {
 		return nil, err
 	}
@@ -79,6 +78,17 @@ func (a *AnonRuleParser) ParseAndValidate(ctx context.Context, rules Rules) (map
 	return a.parser(ctx, rules)
 }
 
+// InferRules returns the rules on input with the table transformers replaced
+// by the ones converted from the anon masking rules of the source database.
+func (a *AnonRuleParser) InferRules(ctx context.Context, rules Rules) (Rules, error) {
+	var err error
+	rules.Transformers, err = a.parseAnonMaskingRules(ctx)
+	if err != nil {
+		return Rules{}, err
+	}
+	return rules, nil
+}
+
 func (a *AnonRuleParser) parseAnonMaskingRules(ctx context.Context) ([]TableRules, error) {
 	maskingRules, err := a.getMaskingRules(ctx)
 	if err != nil {
@@ -51,68 +51,177 @@ func (v *PostgresTransformerParser) ParseAndValidate(ctx context.Context, rules
 	if err := v.validateAllRequiredTables(ctx, rules); err != nil {
 		return nil, err
 	}
+	registry := newNamedTransformerRegistry(rules.NamedTransformers, v.buildTransformer)
 	transformerMap := map[string]ColumnTransformers{}
 	for _, table := range rules.Transformers {
-		tableKey := schemaTableKey(table.Schema, table.Table)
-		fieldDescriptions, err := v.getFieldDescriptions(context.Background(), tableKey)
+		// fail on the first invalid column rule
+		columnTransformers, err := v.parseTable(ctx, registry, table, func(_ string, _ TransformerRules, err error) error {
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
+		transformerMap[schemaTableKey(table.Schema, table.Table)] = columnTransformers
+	}
+	return transformerMap, nil
+}
+
+// columnReportFn is called with the validation result of each of the column
+// rules of a table. Returning an error stops the table parsing.
+type columnReportFn func(column string, rules TransformerRules, err error) error
+
+// parseTable builds and validates the column transformers of the table rules
+// on input against the table schema. Errors that prevent the validation of the
+// table as a whole are returned, while the column rule errors are passed to
+// the report function.
+func (v *PostgresTransformerParser) parseTable(ctx context.Context, registry *namedTransformerRegistry, table TableRules, report columnReportFn) (ColumnTransformers, error) {
+	tableKey := schemaTableKey(table.Schema, table.Table)
+	fieldDescriptions, err := v.getFieldDescriptions(ctx, tableKey)
+	if err != nil {
+		return nil, err
+	}
 
-		// map column names to column pg type OIDs
-		mappedColumnTypes := make(map[string]uint32, len(fieldDescriptions))
-		for _, desc := range fieldDescriptions {
-			if _, found := table.ColumnRules[string(desc.Name)]; !found {
-				// column is not configured in rules, error out if strict validation mode is enabled
-				if table.ValidationMode == validationModeStrict {
-					return nil, fmt.Errorf("column %s of table %s has no transformer configured", desc.Name, tableKey)
+	// map column names to column pg type OIDs
+	mappedColumnTypes := make(map[string]uint32, len(fieldDescriptions))
+	tableColumns := make(map[string]struct{}, len(fieldDescriptions))
+	for _, desc := range fieldDescriptions {
+		tableColumns[string(desc.Name)] = struct{}{}
+		if _, found := table.ColumnRules[string(desc.Name)]; !found {
+			// column is not configured in rules, error out if strict validation mode is enabled
+			if table.ValidationMode == validationModeStrict {
+				if err := report(desc.Name, TransformerRules{}, fmt.Errorf("column %s of table %s has no transformer configured", desc.Name, tableKey)); err != nil {
+					return nil, err
 				}
-				continue
 			}
-			mappedColumnTypes[string(desc.Name)] = desc.DataTypeOID
+			continue
 		}
+		mappedColumnTypes[string(desc.Name)] = desc.DataTypeOID
+	}
 
-		schemaTableTransformers := make(map[string]transformers.Transformer)
-		transformerMap[tableKey] = schemaTableTransformers
-		for colName, transformerRules := range table.ColumnRules {
-			cfg := transformerRulesToConfig(transformerRules)
-
-			switch cfg.Name {
-			case "", "noop":
-				continue
-			case transformers.PGAnonymizer:
-				// pg_anonymizer transformer requires a connection pool, set
-				// the source PG URL if not provided
-				if cfg.Parameters["postgres_url"] == nil {
-					cfg.Parameters["postgres_url"] = v.connURL
-				}
-			}
+	schemaTableTransformers := make(map[string]transformers.Transformer)
+	// sort the column names so that the report order is deterministic
+	colNames := make([]string, 0, len(table.ColumnRules))
+	for colName := range table.ColumnRules {
+		colNames = append(colNames, colName)
+	}
+	slices.Sort(colNames)
+	for _, colName := range colNames {
+		transformerRules := table.ColumnRules[colName]
+		transformer, err := v.parseColumn(ctx, registry, tableKey, colName, transformerRules, mappedColumnTypes, tableColumns)
+		if err := report(colName, transformerRules, err); err != nil {
+			return nil, err
+		}
+		if transformer != nil {
+			schemaTableTransformers[colName] = transformer
+		}
+	}
+	return schemaTableTransformers, nil
+}
 
-			// build the transformer
-			transformer, err := v.builder.New(cfg)
-			if err != nil {
-				return nil, err
-			}
+// parseColumn builds the transformer for the column rules on input, and
+// validates it's compatible with the column type. It returns a nil transformer
+// if no transformation is configured for the column.
+func (v *PostgresTransformerParser) parseColumn(ctx context.Context, registry *namedTransformerRegistry, tableKey, colName string, transformerRules TransformerRules, mappedColumnTypes map[string]uint32, tableColumns map[string]struct{}) (transformers.Transformer, error) {
+	// build the transformer
+	transformer, err := registry.build(transformerRules)
+	if err != nil {
+		return nil, err
+	}
+	if transformer == nil {
+		return nil, nil
+	}
 
-			// get the data type so that we can later validate if it's compatible with the configured transformer
-			dataTypeOID, found := mappedColumnTypes[colName]
-			if !found {
-				// validate that the column in the rules is present in the table
-				return nil, fmt.Errorf("column %s not found in table %s", colName, tableKey)
-			}
+	// get the data type so that we can later validate if it's compatible with the configured transformer
+	dataTypeOID, found := mappedColumnTypes[colName]
+	if !found {
+		// validate that the column in the rules is present in the table
+		transformer.Close()
+		return nil, fmt.Errorf("column %s not found in table %s", colName, tableKey)
+	}
 
-			dataTypeName, err := v.pgtypeMap.TypeForOID(ctx, dataTypeOID)
+	dataTypeName, err := v.pgtypeMap.TypeForOID(ctx, dataTypeOID)
 
-			// validate that the transformer is compatible with the column type
-			if err != nil || !pgTypeCompatibleWithTransformerType(transformer.CompatibleTypes(), dataTypeOID, dataTypeName) {
-				return nil, fmt.Errorf("transformer '%s' specified for column '%s' in table %s does not support pg data type: %s with OID: %d", transformer.Type(), colName, tableKey, dataTypeName, dataTypeOID)
+	// array columns are transformed element by element, so the
+	// transformer needs to be compatible with the element type.
+	// Transformers compatible with all types are applied to the whole
+	// value unless the array option is set.
+	isArray := err == nil && transformers.IsArrayType(dataTypeName)
+	if transformerRules.Array && !isArray {
+		transformer.Close()
+		return nil, fmt.Errorf("column %s of table %s is not an array, but the array option is set", colName, tableKey)
+	}
+	isElementTransformer := transformerRules.Array || (isArray && !slices.Contains(transformer.CompatibleTypes(), transformers.AllDataTypes))
+	compatibleTypeOID, compatibleTypeName := dataTypeOID, dataTypeName
+	if isElementTransformer {
+		compatibleTypeOID, _ = v.pgtypeMap.ArrayElementOID(dataTypeOID)
+		compatibleTypeName = transformers.ArrayElementType(dataTypeName)
+	}
+
+	// validate that the transformer is compatible with the column type. The
+	// chained transformers are validated against the output of the previous
+	// stage instead.
+	if chain, isChain := transformer.(*transformers.ChainTransformer); isChain && err == nil {
+		if err := validateChainTypes(chain, compatibleTypeOID, compatibleTypeName); err != nil {
+			transformer.Close()
+			return nil, fmt.Errorf("column %s of table %s: %w", colName, tableKey, err)
+		}
+	} else if err != nil || !pgTypeCompatibleWithTransformerType(transformer.CompatibleTypes(), compatibleTypeOID, compatibleTypeName) {
+		transformer.Close()
+		return nil, fmt.Errorf("transformer '%s' specified for column '%s' in table %s does not support pg data type: %s with OID: %d", transformer.Type(), colName, tableKey, dataTypeName, dataTypeOID)
+	}
+	if isElementTransformer {
+		transformer = transformers.NewArrayTransformer(transformer)
+	}
+
+	// validate that the columns referenced by the transformer are present in the table
+	if referencer, ok := transformer.(transformers.ColumnReferencer); ok {
+		for _, ref := range referencer.ReferencedColumns() {
+			if _, found := tableColumns[ref]; !found {
+				transformer.Close()
+				return nil, fmt.Errorf("column %s referenced by the transformer of column %s not found in table %s", ref, colName, tableKey)
 			}
+		}
+	}
 
-			// add the transformer to the map
-			schemaTableTransformers[colName] = transformer
+	conditional, err := newConditionalTransformer(transformer, transformerRules.Condition)
+	if err != nil {
+		transformer.Close()
+		return nil, fmt.Errorf("column %s of table %s: %w", colName, tableKey, err)
+	}
+	transformer = conditional
+
+	// validate that the columns referenced by the condition are present in the table
+	if ct, ok := transformer.(*conditionalTransformer); ok {
+		for _, column := range ct.condition.columns {
+			if _, found := tableColumns[column]; !found {
+				transformer.Close()
+				return nil, fmt.Errorf("column %s referenced by the condition of column %s not found in table %s", column, colName, tableKey)
+			}
 		}
 	}
-	return transformerMap, nil
+
+	return transformer, nil
+}
+
+// buildTransformer builds the transformer for the config on input, using the
+// source PG URL for the transformers that require a connection if not
+// provided.
+func (v *PostgresTransformerParser) buildTransformer(cfg *transformers.Config) (transformers.Transformer, error) {
+	switch cfg.Name {
+	case transformers.PGAnonymizer:
+		// pg_anonymizer transformer requires a connection pool, set
+		// the source PG URL if not provided
+		if cfg.Parameters["postgres_url"] == nil {
+			cfg.Parameters["postgres_url"] = v.connURL
+		}
+	case transformers.Lookup:
+		// lookup transformer dictionaries loaded with a query use the
+		// source PG URL if not provided
+		if cfg.Parameters["query"] != nil && cfg.Parameters["postgres_url"] == nil {
+			cfg.Parameters["postgres_url"] = v.connURL
+		}
+	}
+	return v.builder.New(cfg)
 }
 
 func (v *PostgresTransformerParser) validateAllRequiredTables(ctx context.Context, rules Rules) error {
@@ -250,6 +359,29 @@ func parseTableName(qualifiedTableName string) (string, string, error) {
 	}
 }
 
+// validateChainTypes validates that each of the chain stages is compatible
+// with the output of the previous one, starting from the column type. Stages
+// keep the type of their input unless they implement the OutputTyper
+// interface.
+func validateChainTypes(chain *transformers.ChainTransformer, pgTypeOID uint32, pgTypeName string) error {
+	var outputType transformers.SupportedDataType
+	outputStage := 0
+	for i, stage := range chain.Stages() {
+		switch {
+		case outputType == "":
+			if !pgTypeCompatibleWithTransformerType(stage.CompatibleTypes(), pgTypeOID, pgTypeName) {
+				return fmt.Errorf("chain[%d]: transformer '%s' does not support pg data type: %s with OID: %d", i, stage.Type(), pgTypeName, pgTypeOID)
+			}
+		case !slices.Contains(stage.CompatibleTypes(), transformers.AllDataTypes) && !slices.Contains(stage.CompatibleTypes(), outputType):
+			return fmt.Errorf("chain[%d]: transformer '%s' does not support the %s output of chain[%d]", i, stage.Type(), outputType, outputStage)
+		}
+		if typer, ok := stage.(transformers.OutputTyper); ok && typer.OutputType() != "" {
+			outputType, outputStage = typer.OutputType(), i
+		}
+	}
+	return nil
+}
+
 func pgTypeCompatibleWithTransformerType(compatibleTypes []transformers.SupportedDataType, pgTypeOID uint32, pgTypeName string) bool {
 	if slices.Contains(compatibleTypes, transformers.AllDataTypes) {
 		return true
@@ -261,6 +393,8 @@ func pgTypeCompatibleWithTransformerType(compatibleTypes []transformers.Supporte
 		return slices.Contains(compatibleTypes, transformers.Float32DataType)
 	case pgtype.Float8OID:
 		return slices.Contains(compatibleTypes, transformers.Float64DataType)
+	case pgtype.NumericOID:
+		return slices.Contains(compatibleTypes, transformers.NumericDataType)
 	case pgtype.Int2OID:
 		return slices.Contains(compatibleTypes, transformers.Integer16DataType)
 	case pgtype.Int4OID:
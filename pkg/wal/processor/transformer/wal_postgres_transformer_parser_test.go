@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/require"
 	pglib "github.com/xataio/pgstream/internal/postgres"
 	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	"github.com/xataio/pgstream/pkg/transformers"
 	"github.com/xataio/pgstream/pkg/transformers/builder"
 )
 
@@ -139,6 +140,26 @@ func TestPostgresTransformerParser_ParseAndValidate(t *testing.T) {
 			wantTransformersFor: []string{"name"},
 			wantErr:             nil,
 		},
+		{
+			name: "ok - with condition",
+			transformerRules: []TableRules{
+				{
+					Schema:         "public",
+					Table:          "test",
+					ValidationMode: "relaxed",
+					ColumnRules: map[string]TransformerRules{
+						"name": {
+							Name:      "string",
+							Condition: &ConditionRules{Expression: "id > 10 and email is not null"},
+						},
+					},
+				},
+			},
+			validator: testPGValidator,
+
+			wantTransformersFor: []string{"name"},
+			wantErr:             nil,
+		},
 		{
 			name: "ok - no error for missing column, relaxed mode",
 			transformerRules: []TableRules{
@@ -334,6 +355,104 @@ func TestPostgresTransformerParser_ParseAndValidate(t *testing.T) {
 			validator: testPGValidator,
 			wantErr:   fmt.Errorf("column %s not found in table %s", "unknown_column", testSchemaTable),
 		},
+		{
+			name: "error - referenced column not found in table",
+			transformerRules: []TableRules{
+				{
+					Schema:         "public",
+					Table:          "test",
+					ValidationMode: "relaxed",
+					ColumnRules: map[string]TransformerRules{
+						"name": {
+							Name: "row_template",
+							Parameters: map[string]any{
+								"template": "{{ .first_name }} {{ .id }}",
+							},
+						},
+					},
+				},
+			},
+			validator: testPGValidator,
+			wantErr:   fmt.Errorf("column %s referenced by the transformer of column %s not found in table %s", "first_name", "name", testSchemaTable),
+		},
+		{
+			name: "error - condition column not found in table",
+			transformerRules: []TableRules{
+				{
+					Schema:         "public",
+					Table:          "test",
+					ValidationMode: "relaxed",
+					ColumnRules: map[string]TransformerRules{
+						"name": {
+							Name:      "string",
+							Condition: &ConditionRules{Expression: "id > 10 and country = 'DE'"},
+						},
+					},
+				},
+			},
+			validator: testPGValidator,
+			wantErr:   fmt.Errorf("column %s referenced by the condition of column %s not found in table %s", "country", "name", testSchemaTable),
+		},
+		{
+			name: "ok - chain",
+			transformerRules: []TableRules{
+				{
+					Schema:         "public",
+					Table:          "test",
+					ValidationMode: "relaxed",
+					ColumnRules: map[string]TransformerRules{
+						"name": {
+							Chain: []ChainStageRules{
+								{Name: "regex_replace", Parameters: map[string]any{"rules": []any{map[string]any{"pattern": "\\s+", "replacement": ""}}}},
+								{Name: "hmac", Parameters: map[string]any{"key": "secret"}},
+							},
+						},
+					},
+				},
+			},
+			validator: testPGValidator,
+
+			wantTransformersFor: []string{"name"},
+		},
+		{
+			name: "error - chain first stage not compatible with column type",
+			transformerRules: []TableRules{
+				{
+					Schema:         "public",
+					Table:          "test",
+					ValidationMode: "relaxed",
+					ColumnRules: map[string]TransformerRules{
+						"id": {
+							Chain: []ChainStageRules{
+								{Name: "regex_replace", Parameters: map[string]any{"rules": []any{map[string]any{"pattern": "0", "replacement": "1"}}}},
+							},
+						},
+					},
+				},
+			},
+			validator: testPGValidator,
+			wantErr:   fmt.Errorf("column id of table %s: chain[0]: transformer 'regex_replace' does not support pg data type: int8 with OID: 20", testSchemaTable),
+		},
+		{
+			name: "error - chain stage not compatible with previous stage output",
+			transformerRules: []TableRules{
+				{
+					Schema:         "public",
+					Table:          "test",
+					ValidationMode: "relaxed",
+					ColumnRules: map[string]TransformerRules{
+						"name": {
+							Chain: []ChainStageRules{
+								{Name: "hmac", Parameters: map[string]any{"key": "secret"}},
+								{Name: "greenmask_integer"},
+							},
+						},
+					},
+				},
+			},
+			validator: testPGValidator,
+			wantErr:   fmt.Errorf("column name of table %s: chain[1]: transformer 'greenmask_integer' does not support the string output of chain[0]", testSchemaTable),
+		},
 		{
 			name: "error - required table not present in rules",
 			transformerRules: []TableRules{
@@ -426,3 +545,94 @@ func TestPostgresTransformerParser_ParseAndValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestPostgresTransformerParser_ParseAndValidate_arrayColumns(t *testing.T) {
+	t.Parallel()
+
+	testSchemaTable := "\"public\".\"test\""
+	testQuerier := &pgmocks.Querier{
+		QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+			require.Equal(t, "SELECT * FROM \"public\".\"test\" LIMIT 0", query)
+			return &pgmocks.Rows{
+				FieldDescriptionsFn: func() []pgconn.FieldDescription {
+					return []pgconn.FieldDescription{
+						{Name: "id", DataTypeOID: pgtype.Int8OID},
+						{Name: "name", DataTypeOID: pgtype.TextOID},
+						{Name: "emails", DataTypeOID: pgtype.TextArrayOID},
+					}
+				},
+				CloseFn: func() {},
+				ErrFn:   func() error { return nil },
+			}, nil
+		},
+	}
+	testPGValidator := PostgresTransformerParser{
+		conn:      testQuerier,
+		builder:   builder.NewTransformerBuilder(),
+		pgtypeMap: pglib.NewMapper(testQuerier),
+	}
+
+	tests := []struct {
+		name        string
+		columnRules map[string]TransformerRules
+
+		wantArrayTransformer bool
+		wantErr              error
+	}{
+		{
+			name: "ok - array column detected",
+			columnRules: map[string]TransformerRules{
+				"emails": {Name: "string"},
+			},
+			wantArrayTransformer: true,
+		},
+		{
+			name: "ok - transformer compatible with all types applied to whole value",
+			columnRules: map[string]TransformerRules{
+				"emails": {Name: "literal_string", Parameters: map[string]any{"literal": "{}"}},
+			},
+			wantArrayTransformer: false,
+		},
+		{
+			name: "ok - transformer compatible with all types with array option",
+			columnRules: map[string]TransformerRules{
+				"emails": {Name: "literal_string", Parameters: map[string]any{"literal": "redacted"}, Array: true},
+			},
+			wantArrayTransformer: true,
+		},
+		{
+			name: "error - array option for non array column",
+			columnRules: map[string]TransformerRules{
+				"name": {Name: "string", Array: true},
+			},
+			wantErr: fmt.Errorf("column name of table %s is not an array, but the array option is set", testSchemaTable),
+		},
+		{
+			name: "error - transformer not compatible with element type",
+			columnRules: map[string]TransformerRules{
+				"emails": {Name: "greenmask_integer"},
+			},
+			wantErr: fmt.Errorf("transformer 'greenmask_integer' specified for column 'emails' in table %s does not support pg data type: _text with OID: %d", testSchemaTable, pgtype.TextArrayOID),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			transformerMap, err := testPGValidator.ParseAndValidate(context.Background(), Rules{
+				Transformers: []TableRules{
+					{Schema: "public", Table: "test", ValidationMode: "relaxed", ColumnRules: tc.columnRules},
+				},
+			})
+			if tc.wantErr != nil {
+				require.EqualError(t, err, tc.wantErr.Error())
+				return
+			}
+			require.NoError(t, err)
+
+			_, isArrayTransformer := transformerMap[testSchemaTable]["emails"].(*transformers.ArrayTransformer)
+			require.Equal(t, tc.wantArrayTransformer, isArrayTransformer)
+		})
+	}
+}
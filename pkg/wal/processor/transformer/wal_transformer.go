@@ -5,6 +5,10 @@ package transformer
 import (
 	"context"
 	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 
 	pglib "github.com/xataio/pgstream/internal/postgres"
 	loglib "github.com/xataio/pgstream/pkg/log"
@@ -19,7 +23,14 @@ type Transformer struct {
 	logger         loglib.Logger
 	processor      processor.Processor
 	transformerMap map[string]ColumnTransformers
-	parser         ParseFn
+	// transformOrder keeps the order in which the column transformers of each
+	// table are applied, so that the columns referenced by a transformer are
+	// transformed before it
+	transformOrder map[string][]string
+	// conditionalTables keeps the tables with conditional column
+	// transformers, for which the row values need to be evaluated
+	conditionalTables map[string]bool
+	parser            ParseFn
 }
 
 type ParseFn func(ctx context.Context, rules Rules) (map[string]ColumnTransformers, error)
@@ -34,6 +45,7 @@ type Config struct {
 	InferFromSecurityLabels bool
 	DumpInferredRules       bool
 	TransformerRules        []TableRules
+	NamedTransformers       map[string]NamedTransformerRules
 	ValidationMode          string
 }
 
@@ -41,7 +53,10 @@ type Option func(t *Transformer)
 
 const validationModeStrict = "strict"
 
-var errValidatorRequiredForStrictMode = errors.New("strict validation mode requires a validator function")
+var (
+	errValidatorRequiredForStrictMode = errors.New("strict validation mode requires a validator function")
+	errCircularColumnReference        = errors.New("circular column reference in transformer rules")
+)
 
 // New will return a transformer processor wrapper that will transform incoming
 // wal event column values as configured by the transformation rules.
@@ -56,15 +71,19 @@ func New(ctx context.Context, cfg *Config, processor processor.Processor, builde
 		opt(t)
 	}
 
-	var err error
-	t.transformerMap, err = t.parser(ctx, Rules{
-		Transformers:   cfg.TransformerRules,
-		ValidationMode: cfg.ValidationMode,
+	transformerMap, err := t.parser(ctx, Rules{
+		Transformers:      cfg.TransformerRules,
+		NamedTransformers: cfg.NamedTransformers,
+		ValidationMode:    cfg.ValidationMode,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	if err := t.setTransformerMap(transformerMap); err != nil {
+		return nil, err
+	}
+
 	return t, nil
 }
 
@@ -106,41 +125,94 @@ func (t *Transformer) Close() error {
 	return t.processor.Close()
 }
 
+func (t *Transformer) Ping(ctx context.Context) error {
+	return t.processor.Ping(ctx)
+}
+
+// setTransformerMap sets the column transformers of each table, along with
+// the order in which they need to be applied.
+func (t *Transformer) setTransformerMap(transformerMap map[string]ColumnTransformers) error {
+	transformOrder, err := transformationOrder(transformerMap)
+	if err != nil {
+		return err
+	}
+	t.transformerMap = transformerMap
+	t.transformOrder = transformOrder
+	t.conditionalTables = conditionalTables(transformerMap)
+	return nil
+}
+
 func (t *Transformer) applyTransformations(ctx context.Context, event *wal.Event) error {
-	if event.Data == nil || len(t.transformerMap) == 0 {
+	if event.Data == nil {
 		return nil
 	}
 
-	columnTransformers, found := t.transformerMap[schemaTableKey(event.Data.Schema, event.Data.Table)]
+	for colName, err := range t.transformRow(ctx, event.Data) {
+		t.logger.Error(err, "transforming column", loglib.Fields{
+			"severity":    "DATALOSS",
+			"column_name": colName,
+			"schema":      event.Data.Schema,
+			"table":       event.Data.Table,
+		})
+	}
+	return nil
+}
+
+// transformRow applies the column transformers to the row values on input.
+// The values of the columns that fail to be transformed are set to nil, and
+// the errors are returned by column name.
+func (t *Transformer) transformRow(ctx context.Context, data *wal.Data) map[string]error {
+	if len(t.transformerMap) == 0 {
+		return nil
+	}
+
+	tableKey := schemaTableKey(data.Schema, data.Table)
+	columnTransformers, found := t.transformerMap[tableKey]
 	if !found || len(columnTransformers) == 0 {
 		return nil
 	}
 
-	columns := event.Data.Columns
+	columns := data.Columns
+	columnIndexes := make(map[string]int, len(columns))
 	for i, col := range columns {
+		columnIndexes[col.Name] = i
+	}
+
+	// conditions are evaluated with the original row values, before any of the
+	// columns is transformed
+	var rowValues map[string]any
+	if t.conditionalTables[tableKey] {
+		rowValues = conditionRowValues(data)
+	}
+
+	var errs map[string]error
+
+	for _, colName := range t.transformOrder[tableKey] {
+		i, found := columnIndexes[colName]
+		if !found {
+			continue
+		}
+		col := columns[i]
 		// do not transform nil column values for now
 		if col.Value == nil {
 			continue
 		}
-
-		columnTransformer, found := columnTransformers[col.Name]
-		if !found {
+		columnTransformer := columnTransformers[colName]
+		if ct, ok := columnTransformer.(*conditionalTransformer); ok && !ct.condition.matches(rowValues) {
 			continue
 		}
 
 		var dynamicValues map[string]any
 		if columnTransformer.IsDynamic() {
-			dynamicValues = t.getDynamicColumnValues(col.Name, event.Data.Columns)
+			dynamicValues = t.getDynamicColumnValues(col.Name, data.Columns)
 		}
 
 		newValue, err := columnTransformer.Transform(ctx, transformers.NewValue(col.Value, col.Type, dynamicValues))
 		if err != nil {
-			t.logger.Error(err, "transforming column", loglib.Fields{
-				"severity":    "DATALOSS",
-				"column_name": col.Name,
-				"schema":      event.Data.Schema,
-				"table":       event.Data.Table,
-			})
+			if errs == nil {
+				errs = map[string]error{}
+			}
+			errs[col.Name] = err
 			newValue = nil
 		}
 		// avoid logging large values on the hot path unless trace is enabled
@@ -150,7 +222,7 @@ func (t *Transformer) applyTransformations(ctx context.Context, event *wal.Event
 		columns[i].Value = newValue
 	}
 
-	return nil
+	return errs
 }
 
 func (t *Transformer) getDynamicColumnValues(excludeColName string, columns []wal.Column) map[string]any {
@@ -164,6 +236,74 @@ func (t *Transformer) getDynamicColumnValues(excludeColName string, columns []wa
 	return values
 }
 
+// transformationOrder returns the column names of each table in the order
+// their transformers need to be applied. Columns referenced by a transformer
+// are placed before it, so that the transformer is evaluated with their
+// transformed values. Circular references return an error.
+func transformationOrder(transformerMap map[string]ColumnTransformers) (map[string][]string, error) {
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	order := make(map[string][]string, len(transformerMap))
+	for table, columnTransformers := range transformerMap {
+		columns := make([]string, 0, len(columnTransformers))
+		state := make(map[string]int, len(columnTransformers))
+
+		var visit func(column string, path []string) error
+		visit = func(column string, path []string) error {
+			path = append(path, column)
+			switch state[column] {
+			case visited:
+				return nil
+			case visiting:
+				return fmt.Errorf("%w: %s in table %s", errCircularColumnReference, strings.Join(path, " -> "), table)
+			}
+
+			state[column] = visiting
+			if referencer, ok := columnTransformers[column].(transformers.ColumnReferencer); ok {
+				for _, ref := range referencer.ReferencedColumns() {
+					// columns without transformers are already resolved
+					if _, found := columnTransformers[ref]; !found {
+						continue
+					}
+					if err := visit(ref, path); err != nil {
+						return err
+					}
+				}
+			}
+			state[column] = visited
+			columns = append(columns, column)
+			return nil
+		}
+
+		// sort the column names so that the order is deterministic
+		for _, column := range slices.Sorted(maps.Keys(columnTransformers)) {
+			if err := visit(column, nil); err != nil {
+				return nil, err
+			}
+		}
+		order[table] = columns
+	}
+	return order, nil
+}
+
+// conditionalTables returns the tables with at least one conditional column
+// transformer.
+func conditionalTables(transformerMap map[string]ColumnTransformers) map[string]bool {
+	tables := make(map[string]bool)
+	for table, columnTransformers := range transformerMap {
+		for _, columnTransformer := range columnTransformers {
+			if _, ok := columnTransformer.(*conditionalTransformer); ok {
+				tables[table] = true
+				break
+			}
+		}
+	}
+	return tables
+}
+
 func schemaTableKey(schema, table string) string {
 	return pglib.QuoteQualifiedIdentifier(schema, table)
 }
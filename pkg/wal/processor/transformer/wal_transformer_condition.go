@@ -0,0 +1,604 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/xataio/pgstream/pkg/transformers"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// conditionalTransformer is a column transformer that is only applied to the
+// rows matching its condition. Unmatched rows are left untouched.
+type conditionalTransformer struct {
+	transformers.Transformer
+	condition *condition
+}
+
+// condition is a boolean expression over the column values of a row, such as
+// `country = 'DE' and is_test is not true`.
+type condition struct {
+	raw             string
+	expr            conditionExpr
+	columns         []string
+	onMissingColumn string
+}
+
+type conditionExpr interface {
+	eval(row map[string]any) bool
+}
+
+type (
+	andExpr struct {
+		left, right conditionExpr
+	}
+	orExpr struct {
+		left, right conditionExpr
+	}
+	notExpr struct {
+		expr conditionExpr
+	}
+	compareExpr struct {
+		column string
+		op     string
+		value  any
+	}
+	inExpr struct {
+		column string
+		values []any
+		negate bool
+	}
+	isNullExpr struct {
+		column string
+		negate bool
+	}
+)
+
+const (
+	// onMissingColumnNull evaluates the columns absent from the event as null
+	// values
+	onMissingColumnNull = "null"
+	// onMissingColumnSkip leaves the column untouched when any of the
+	// condition columns is absent from the event
+	onMissingColumnSkip = "skip"
+	// onMissingColumnTransform applies the transformer when any of the
+	// condition columns is absent from the event
+	onMissingColumnTransform = "transform"
+)
+
+var (
+	errInvalidCondition                = errors.New("invalid transformer condition")
+	errUnsupportedConditionMissingMode = errors.New("unsupported condition on_missing_column value, must be one of null, skip or transform")
+)
+
+func newConditionalTransformer(t transformers.Transformer, rules *ConditionRules) (transformers.Transformer, error) {
+	if rules == nil {
+		return t, nil
+	}
+	c, err := newCondition(rules)
+	if err != nil {
+		return nil, err
+	}
+	return &conditionalTransformer{
+		Transformer: t,
+		condition:   c,
+	}, nil
+}
+
+// ReferencedColumns returns the columns referenced by the wrapped transformer.
+// The condition columns are not included, since the condition is evaluated
+// with the original row values before any transformation is applied.
+func (t *conditionalTransformer) ReferencedColumns() []string {
+	if referencer, ok := t.Transformer.(transformers.ColumnReferencer); ok {
+		return referencer.ReferencedColumns()
+	}
+	return nil
+}
+
+func newCondition(rules *ConditionRules) (*condition, error) {
+	onMissingColumn := rules.OnMissingColumn
+	switch onMissingColumn {
+	case "":
+		onMissingColumn = onMissingColumnNull
+	case onMissingColumnNull, onMissingColumnSkip, onMissingColumnTransform:
+	default:
+		return nil, errUnsupportedConditionMissingMode
+	}
+
+	p := &conditionParser{}
+	tokens, err := tokenizeCondition(rules.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", errInvalidCondition, rules.Expression, err)
+	}
+	p.tokens = tokens
+	if len(p.tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty expression", errInvalidCondition)
+	}
+
+	expr, err := p.parseOr()
+	if err == nil && !p.done() {
+		err = fmt.Errorf("unexpected %q", p.peek().value)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", errInvalidCondition, rules.Expression, err)
+	}
+
+	slices.Sort(p.columns)
+	return &condition{
+		raw:             rules.Expression,
+		expr:            expr,
+		columns:         slices.Compact(p.columns),
+		onMissingColumn: onMissingColumn,
+	}, nil
+}
+
+// matches evaluates the condition for the row values on input. Columns absent
+// from the row are handled as per the on_missing_column setting.
+func (c *condition) matches(row map[string]any) bool {
+	if c.onMissingColumn != onMissingColumnNull {
+		for _, column := range c.columns {
+			if _, found := row[column]; !found {
+				return c.onMissingColumn == onMissingColumnTransform
+			}
+		}
+	}
+	return c.expr.eval(row)
+}
+
+// conditionRowValues returns the values of the columns in the wal event data,
+// falling back to the identity values for the columns not present in it.
+func conditionRowValues(data *wal.Data) map[string]any {
+	row := make(map[string]any, len(data.Columns)+len(data.Identity))
+	for _, col := range data.Identity {
+		row[col.Name] = col.Value
+	}
+	for _, col := range data.Columns {
+		row[col.Name] = col.Value
+	}
+	return row
+}
+
+func (e *andExpr) eval(row map[string]any) bool {
+	return e.left.eval(row) && e.right.eval(row)
+}
+
+func (e *orExpr) eval(row map[string]any) bool {
+	return e.left.eval(row) || e.right.eval(row)
+}
+
+func (e *notExpr) eval(row map[string]any) bool {
+	return !e.expr.eval(row)
+}
+
+// eval compares the column value with the literal. Comparisons with null
+// values, or with values that can't be converted to the literal type, are
+// false.
+func (e *compareExpr) eval(row map[string]any) bool {
+	cmp, ok := compareConditionValues(row[e.column], e.value)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "=":
+		return cmp == 0
+	case "!=", "<>":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	default:
+		return false
+	}
+}
+
+func (e *inExpr) eval(row map[string]any) bool {
+	value := row[e.column]
+	if value == nil {
+		return false
+	}
+	for _, v := range e.values {
+		if cmp, ok := compareConditionValues(value, v); ok && cmp == 0 {
+			return !e.negate
+		}
+	}
+	return e.negate
+}
+
+func (e *isNullExpr) eval(row map[string]any) bool {
+	isNull := row[e.column] == nil
+	if e.negate {
+		return !isNull
+	}
+	return isNull
+}
+
+// compareConditionValues compares the column value with the literal on input,
+// converting the column value to the literal type. It returns false if the
+// values can't be compared.
+func compareConditionValues(value, literal any) (int, bool) {
+	if value == nil {
+		return 0, false
+	}
+
+	switch lit := literal.(type) {
+	case bool:
+		var b bool
+		switch v := value.(type) {
+		case bool:
+			b = v
+		case string:
+			var err error
+			if b, err = strconv.ParseBool(v); err != nil {
+				return 0, false
+			}
+		default:
+			return 0, false
+		}
+		if b == lit {
+			return 0, true
+		}
+		return 1, true
+	case float64:
+		f, ok := conditionFloatValue(value)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case f < lit:
+			return -1, true
+		case f > lit:
+			return 1, true
+		default:
+			return 0, true
+		}
+	case string:
+		switch v := value.(type) {
+		case string:
+			return strings.Compare(v, lit), true
+		case time.Time:
+			for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+				if t, err := time.Parse(layout, lit); err == nil {
+					return v.Compare(t), true
+				}
+			}
+			return 0, false
+		case []byte:
+			return strings.Compare(string(v), lit), true
+		default:
+			return strings.Compare(fmt.Sprint(v), lit), true
+		}
+	default:
+		return 0, false
+	}
+}
+
+func conditionFloatValue(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		f, err := strconv.ParseFloat(fmt.Sprint(v), 64)
+		return f, err == nil
+	}
+}
+
+type conditionTokenType uint8
+
+const (
+	identToken conditionTokenType = iota
+	stringToken
+	numberToken
+	operatorToken
+	punctuationToken
+)
+
+type conditionToken struct {
+	typ   conditionTokenType
+	value string
+	// quoted identifiers are never considered keywords
+	quoted bool
+}
+
+func tokenizeCondition(expression string) ([]conditionToken, error) {
+	tokens := []conditionToken{}
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, conditionToken{typ: punctuationToken, value: string(c)})
+			i++
+		case strings.HasPrefix(expression[i:], "<=") || strings.HasPrefix(expression[i:], ">=") ||
+			strings.HasPrefix(expression[i:], "!=") || strings.HasPrefix(expression[i:], "<>"):
+			tokens = append(tokens, conditionToken{typ: operatorToken, value: expression[i : i+2]})
+			i += 2
+		case c == '=' || c == '<' || c == '>':
+			tokens = append(tokens, conditionToken{typ: operatorToken, value: string(c)})
+			i++
+		case c == '\'' || c == '"':
+			// single quotes delimit string literals and double quotes
+			// identifiers, with the quote escaped by doubling it
+			var sb strings.Builder
+			j := i + 1
+			for {
+				if j >= len(expression) {
+					return nil, fmt.Errorf("unterminated quote at position %d", i)
+				}
+				if expression[j] == c {
+					if j+1 < len(expression) && expression[j+1] == c {
+						sb.WriteByte(c)
+						j += 2
+						continue
+					}
+					break
+				}
+				sb.WriteByte(expression[j])
+				j++
+			}
+			tok := conditionToken{typ: stringToken, value: sb.String()}
+			if c == '"' {
+				tok.typ = identToken
+				tok.quoted = true
+			}
+			tokens = append(tokens, tok)
+			i = j + 1
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(expression) && strings.IndexByte("0123456789.eE+-", expression[j]) != -1 {
+				j++
+			}
+			tokens = append(tokens, conditionToken{typ: numberToken, value: expression[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i + 1
+			for j < len(expression) && (expression[j] == '_' || unicode.IsLetter(rune(expression[j])) || unicode.IsDigit(rune(expression[j]))) {
+				j++
+			}
+			tokens = append(tokens, conditionToken{typ: identToken, value: expression[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+// conditionParser is a recursive descent parser for the condition grammar:
+//
+//	or         := and ("or" and)*
+//	and        := not ("and" not)*
+//	not        := "not" not | "(" or ")" | comparison
+//	comparison := column (operator literal | ["not"] "in" "(" literal ("," literal)* ")" | "is" ["not"] (null | true | false))
+//	literal    := string | number | true | false
+type conditionParser struct {
+	tokens  []conditionToken
+	pos     int
+	columns []string
+}
+
+func (p *conditionParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *conditionParser) peek() conditionToken {
+	if p.done() {
+		return conditionToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *conditionParser) next() (conditionToken, error) {
+	if p.done() {
+		return conditionToken{}, errors.New("unexpected end of expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok, nil
+}
+
+// keyword returns true and consumes the next token if it's the unquoted
+// keyword on input.
+func (p *conditionParser) keyword(kw string) bool {
+	tok := p.peek()
+	if tok.typ == identToken && !tok.quoted && strings.EqualFold(tok.value, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) punctuation(value string) bool {
+	tok := p.peek()
+	if tok.typ == punctuationToken && tok.value == value {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) parseOr() (conditionExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseAnd() (conditionExpr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &andExpr{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *conditionParser) parseNot() (conditionExpr, error) {
+	if p.keyword("not") {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{expr: expr}, nil
+	}
+	if p.punctuation("(") {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.punctuation(")") {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		return expr, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (conditionExpr, error) {
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if tok.typ != identToken {
+		return nil, fmt.Errorf("expected column name, got %q", tok.value)
+	}
+	column := tok.value
+	p.columns = append(p.columns, column)
+
+	switch {
+	case p.keyword("is"):
+		negate := p.keyword("not")
+		switch {
+		case p.keyword("null"):
+			return &isNullExpr{column: column, negate: negate}, nil
+		case p.keyword("true"), p.keyword("false"):
+			// `is [not] true/false` is null safe, unlike `=`
+			expr := conditionExpr(&compareExpr{column: column, op: "=", value: strings.EqualFold(p.tokens[p.pos-1].value, "true")})
+			if negate {
+				expr = &notExpr{expr: expr}
+			}
+			return expr, nil
+		default:
+			return nil, errors.New("expected null, true or false after is")
+		}
+	case p.keyword("in"):
+		return p.parseIn(column, false)
+	case p.keyword("not"):
+		if !p.keyword("in") {
+			return nil, errors.New("expected in after not")
+		}
+		return p.parseIn(column, true)
+	}
+
+	opTok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if opTok.typ != operatorToken {
+		return nil, fmt.Errorf("expected comparison operator after column %s, got %q", column, opTok.value)
+	}
+	value, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+	if _, isBool := value.(bool); isBool && opTok.value != "=" && opTok.value != "!=" && opTok.value != "<>" {
+		return nil, fmt.Errorf("operator %s not supported for boolean values", opTok.value)
+	}
+	return &compareExpr{column: column, op: opTok.value, value: value}, nil
+}
+
+func (p *conditionParser) parseIn(column string, negate bool) (conditionExpr, error) {
+	if !p.punctuation("(") {
+		return nil, errors.New("expected ( after in")
+	}
+	values := []any{}
+	for {
+		value, err := p.parseLiteral()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if p.punctuation(")") {
+			return &inExpr{column: column, values: values, negate: negate}, nil
+		}
+		if !p.punctuation(",") {
+			return nil, errors.New("expected , or ) in value list")
+		}
+	}
+}
+
+func (p *conditionParser) parseLiteral() (any, error) {
+	switch {
+	case p.keyword("true"):
+		return true, nil
+	case p.keyword("false"):
+		return false, nil
+	case p.keyword("null"):
+		return nil, errors.New("null values can only be compared with is [not] null")
+	}
+
+	tok, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch tok.typ {
+	case stringToken:
+		return tok.value, nil
+	case numberToken:
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok.value)
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("expected literal value, got %q", tok.value)
+	}
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/transformers"
+	transformermocks "github.com/xataio/pgstream/pkg/transformers/mocks"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func newTestConditionalTransformer(t *testing.T, newValue any, expression string) transformers.Transformer {
+	transformer, err := newConditionalTransformer(&transformermocks.Transformer{
+		TransformFn: func(transformers.Value) (any, error) { return newValue, nil },
+	}, &ConditionRules{Expression: expression})
+	require.NoError(t, err)
+	return transformer
+}
+
+func TestNewCondition(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		rules *ConditionRules
+
+		wantColumns         []string
+		wantOnMissingColumn string
+		wantErr             error
+	}{
+		{
+			name:                "ok",
+			rules:               &ConditionRules{Expression: `country IN ('DE', 'FR') AND (is_test IS NOT TRUE or "Age" >= 18) and country != 'XX'`},
+			wantColumns:         []string{"Age", "country", "is_test"},
+			wantOnMissingColumn: onMissingColumnNull,
+		},
+		{
+			name:                "ok - quoted keyword column",
+			rules:               &ConditionRules{Expression: `"and" is null`, OnMissingColumn: onMissingColumnSkip},
+			wantColumns:         []string{"and"},
+			wantOnMissingColumn: onMissingColumnSkip,
+		},
+		{
+			name:    "error - unsupported on_missing_column",
+			rules:   &ConditionRules{Expression: "a = 1", OnMissingColumn: "ignore"},
+			wantErr: errUnsupportedConditionMissingMode,
+		},
+		{
+			name:    "error - empty expression",
+			rules:   &ConditionRules{Expression: " "},
+			wantErr: errInvalidCondition,
+		},
+		{
+			name:    "error - unterminated string",
+			rules:   &ConditionRules{Expression: "country = 'DE"},
+			wantErr: errInvalidCondition,
+		},
+		{
+			name:    "error - missing literal",
+			rules:   &ConditionRules{Expression: "country ="},
+			wantErr: errInvalidCondition,
+		},
+		{
+			name:    "error - missing closing parenthesis",
+			rules:   &ConditionRules{Expression: "(country = 'DE'"},
+			wantErr: errInvalidCondition,
+		},
+		{
+			name:    "error - trailing tokens",
+			rules:   &ConditionRules{Expression: "country = 'DE' 'FR'"},
+			wantErr: errInvalidCondition,
+		},
+		{
+			name:    "error - null comparison",
+			rules:   &ConditionRules{Expression: "country = null"},
+			wantErr: errInvalidCondition,
+		},
+		{
+			name:    "error - boolean ordering",
+			rules:   &ConditionRules{Expression: "is_test > true"},
+			wantErr: errInvalidCondition,
+		},
+		{
+			name:    "error - unexpected character",
+			rules:   &ConditionRules{Expression: "country == 'DE'"},
+			wantErr: errInvalidCondition,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := newCondition(tc.rules)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			require.Equal(t, tc.wantColumns, c.columns)
+			require.Equal(t, tc.wantOnMissingColumn, c.onMissingColumn)
+		})
+	}
+}
+
+func TestCondition_matches(t *testing.T) {
+	t.Parallel()
+
+	row := map[string]any{
+		"country":    "DE",
+		"is_test":    false,
+		"age":        float64(30),
+		"score":      int32(7),
+		"deleted_at": nil,
+		"created_at": time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name            string
+		expression      string
+		onMissingColumn string
+
+		wantMatch bool
+	}{
+		{name: "equality", expression: "country = 'DE'", wantMatch: true},
+		{name: "inequality", expression: "country <> 'DE'", wantMatch: false},
+		{name: "numeric comparison", expression: "age >= 18 and score < 10", wantMatch: true},
+		{name: "string comparison", expression: "country > 'AT'", wantMatch: true},
+		{name: "time comparison", expression: "created_at >= '2024-01-01'", wantMatch: true},
+		{name: "boolean equality", expression: "is_test = false", wantMatch: true},
+		{name: "in", expression: "country in ('FR', 'DE')", wantMatch: true},
+		{name: "not in", expression: "country not in ('FR', 'DE')", wantMatch: false},
+		{name: "is null", expression: "deleted_at is null", wantMatch: true},
+		{name: "is not null", expression: "deleted_at is not null", wantMatch: false},
+		{name: "comparison with null value", expression: "deleted_at = '2024-01-01' or deleted_at != '2024-01-01'", wantMatch: false},
+		{name: "is not true", expression: "is_test is not true", wantMatch: true},
+		{name: "not", expression: "not (country = 'DE' and age > 40)", wantMatch: true},
+		{name: "precedence", expression: "country = 'FR' and age > 40 or score = 7", wantMatch: true},
+		{name: "incompatible types", expression: "country > 10", wantMatch: false},
+		{name: "missing column as null", expression: "tier is null", wantMatch: true},
+		{name: "missing column skipped", expression: "tier is null", onMissingColumn: onMissingColumnSkip, wantMatch: false},
+		{name: "missing column transformed", expression: "tier = 'gold'", onMissingColumn: onMissingColumnTransform, wantMatch: true},
+		{name: "present columns with skip", expression: "country = 'DE'", onMissingColumn: onMissingColumnSkip, wantMatch: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := newCondition(&ConditionRules{Expression: tc.expression, OnMissingColumn: tc.onMissingColumn})
+			require.NoError(t, err)
+			require.Equal(t, tc.wantMatch, c.matches(row))
+		})
+	}
+}
+
+func TestConditionRowValues(t *testing.T) {
+	t.Parallel()
+
+	data := &wal.Data{
+		Columns: []wal.Column{
+			{Name: "id", Value: 1},
+			{Name: "email", Value: "new@acme.com"},
+		},
+		Identity: []wal.Column{
+			{Name: "email", Value: "old@acme.com"},
+			{Name: "country", Value: "DE"},
+		},
+	}
+
+	require.Equal(t, map[string]any{
+		"id":      1,
+		"email":   "new@acme.com",
+		"country": "DE",
+	}, conditionRowValues(data))
+}
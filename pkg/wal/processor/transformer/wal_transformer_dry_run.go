@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// DryRunReport is the result of validating the transformation rules against
+// the source database, along with a preview of the transformations applied to
+// sample rows of each table.
+type DryRunReport struct {
+	// Errors are the validation errors that are not specific to a column
+	// rule, such as required tables missing from the rules, or tables that
+	// can't be found in the source database.
+	Errors  []string      `json:"errors,omitempty"`
+	Rules   []RuleReport  `json:"rules"`
+	Samples []TableSample `json:"samples,omitempty"`
+}
+
+// RuleReport is the validation result of the transformation rule of a table
+// column.
+type RuleReport struct {
+	Schema           string `json:"schema"`
+	Table            string `json:"table"`
+	Column           string `json:"column"`
+	Transformer      string `json:"transformer,omitempty"`
+	NamedTransformer string `json:"named_transformer,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// TableSample contains the sample rows of a table, before and after applying
+// the transformation rules.
+type TableSample struct {
+	Schema string      `json:"schema"`
+	Table  string      `json:"table"`
+	Rows   []SampleRow `json:"rows"`
+}
+
+type SampleRow struct {
+	Before map[string]any `json:"before"`
+	After  map[string]any `json:"after"`
+	// Errors are the transformation errors of the row, by column name
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+const sampleRowsQuery = "SELECT * FROM %s LIMIT $1"
+
+// DryRun validates each of the transformation rules against the source
+// database schema, using the same validation as ParseAndValidate, but without
+// stopping at the first invalid rule. If sampleRows is greater than zero, that
+// number of rows is read from each of the tables in the rules, and
+// transformed with their valid column rules. The transformed rows are not
+// written anywhere.
+func (v *PostgresTransformerParser) DryRun(ctx context.Context, rules Rules, sampleRows int) (*DryRunReport, error) {
+	report := &DryRunReport{Rules: []RuleReport{}}
+	if err := v.validateAllRequiredTables(ctx, rules); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	registry := newNamedTransformerRegistry(rules.NamedTransformers, v.buildTransformer)
+	transformerMap := map[string]ColumnTransformers{}
+	for _, table := range rules.Transformers {
+		tableKey := schemaTableKey(table.Schema, table.Table)
+		columnTransformers, err := v.parseTable(ctx, registry, table, func(column string, columnRules TransformerRules, err error) error {
+			rule := RuleReport{
+				Schema:           table.Schema,
+				Table:            table.Table,
+				Column:           column,
+				Transformer:      ruleTransformerName(columnRules),
+				NamedTransformer: columnRules.NamedTransformer,
+			}
+			if err != nil {
+				rule.Error = err.Error()
+			}
+			report.Rules = append(report.Rules, rule)
+			return nil
+		})
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("table %s: %v", tableKey, err))
+			continue
+		}
+		transformerMap[tableKey] = columnTransformers
+	}
+
+	t := &Transformer{logger: loglib.NewNoopLogger()}
+	defer closeTransformers(transformerMap)
+	if err := t.setTransformerMap(transformerMap); err != nil {
+		report.Errors = append(report.Errors, err.Error())
+		return report, nil
+	}
+
+	if sampleRows <= 0 {
+		return report, nil
+	}
+
+	for _, table := range rules.Transformers {
+		if _, found := transformerMap[schemaTableKey(table.Schema, table.Table)]; !found {
+			continue
+		}
+		sample, err := v.sampleTable(ctx, t, table, sampleRows)
+		if err != nil {
+			return nil, fmt.Errorf("sampling rows of table %s: %w", schemaTableKey(table.Schema, table.Table), err)
+		}
+		report.Samples = append(report.Samples, *sample)
+	}
+
+	return report, nil
+}
+
+// Valid returns true if none of the transformation rules have errors.
+func (r *DryRunReport) Valid() bool {
+	if len(r.Errors) > 0 {
+		return false
+	}
+	for _, rule := range r.Rules {
+		if rule.Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// sampleTable reads the number of rows on input from the table, and applies
+// the transformer to them.
+func (v *PostgresTransformerParser) sampleTable(ctx context.Context, t *Transformer, table TableRules, sampleRows int) (*TableSample, error) {
+	rows, err := v.conn.Query(ctx, fmt.Sprintf(sampleRowsQuery, schemaTableKey(table.Schema, table.Table)), sampleRows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fieldDescriptions := rows.FieldDescriptions()
+	columnTypes := make([]string, len(fieldDescriptions))
+	for i, desc := range fieldDescriptions {
+		// columns with unknown types can't have transformers configured, so
+		// they're sampled without type
+		columnTypes[i], _ = v.pgtypeMap.TypeForOID(ctx, desc.DataTypeOID)
+	}
+
+	sample := &TableSample{
+		Schema: table.Schema,
+		Table:  table.Table,
+		Rows:   []SampleRow{},
+	}
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, fmt.Errorf("retrieving rows values: %w", err)
+		}
+
+		data := &wal.Data{
+			Action:  string(wal.ActionInsert),
+			Schema:  table.Schema,
+			Table:   table.Table,
+			Columns: make([]wal.Column, 0, len(values)),
+		}
+		row := SampleRow{
+			Before: make(map[string]any, len(values)),
+			After:  make(map[string]any, len(values)),
+		}
+		for i, value := range values {
+			data.Columns = append(data.Columns, wal.Column{
+				Name:  fieldDescriptions[i].Name,
+				Type:  columnTypes[i],
+				Value: value,
+			})
+			row.Before[fieldDescriptions[i].Name] = value
+		}
+
+		for column, err := range t.transformRow(ctx, data) {
+			if row.Errors == nil {
+				row.Errors = map[string]string{}
+			}
+			row.Errors[column] = err.Error()
+		}
+		for _, col := range data.Columns {
+			row.After[col.Name] = col.Value
+		}
+		sample.Rows = append(sample.Rows, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sample, nil
+}
+
+func closeTransformers(transformerMap map[string]ColumnTransformers) {
+	for _, columnTransformers := range transformerMap {
+		for _, t := range columnTransformers {
+			t.Close()
+		}
+	}
+}
+
+// ruleTransformerName returns the name of the transformer of the column rules,
+// or the names of the chain stages in order.
+func ruleTransformerName(rules TransformerRules) string {
+	if len(rules.Chain) == 0 {
+		return rules.Name
+	}
+	names := make([]string, 0, len(rules.Chain))
+	for _, stage := range rules.Chain {
+		name := stage.Name
+		if stage.NamedTransformer != "" {
+			name = "named_transformer: " + stage.NamedTransformer
+		}
+		names = append(names, name)
+	}
+	return "chain: " + strings.Join(names, ", ")
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	"github.com/xataio/pgstream/pkg/transformers/builder"
+)
+
+func TestPostgresTransformerParser_DryRun(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	testFieldDescriptions := []pgconn.FieldDescription{
+		{Name: "id", DataTypeOID: pgtype.Int8OID},
+		{Name: "name", DataTypeOID: pgtype.TextOID},
+		{Name: "email", DataTypeOID: pgtype.TextOID},
+	}
+	testQuerier := func(sampleErr error) *pgmocks.Querier {
+		return &pgmocks.Querier{
+			QueryFn: func(ctx context.Context, _ uint, query string, args ...any) (pglib.Rows, error) {
+				switch query {
+				case "SELECT * FROM \"public\".\"test\" LIMIT 0":
+					return &pgmocks.Rows{
+						FieldDescriptionsFn: func() []pgconn.FieldDescription { return testFieldDescriptions },
+						ErrFn:               func() error { return nil },
+					}, nil
+				case "SELECT * FROM \"public\".\"missing\" LIMIT 0":
+					return nil, errTest
+				case "SELECT * FROM \"public\".\"test\" LIMIT $1":
+					if sampleErr != nil {
+						return nil, sampleErr
+					}
+					require.Equal(t, []any{1}, args)
+					return &pgmocks.Rows{
+						FieldDescriptionsFn: func() []pgconn.FieldDescription { return testFieldDescriptions },
+						NextFn:              func(i uint) bool { return i == 1 },
+						ValuesFn:            func() ([]any, error) { return []any{int64(1), "alice", "alice@example.com"}, nil },
+						ErrFn:               func() error { return nil },
+					}, nil
+				default:
+					return nil, fmt.Errorf("unexpected query: %s", query)
+				}
+			},
+		}
+	}
+
+	testRules := Rules{
+		Transformers: []TableRules{
+			{
+				Schema: "public",
+				Table:  "test",
+				ColumnRules: map[string]TransformerRules{
+					"id": {
+						Name:       "literal_string",
+						Parameters: map[string]any{"literal": "x"},
+					},
+					"name": {
+						Name:       "literal_string",
+						Parameters: map[string]any{"literal": "anonymous"},
+					},
+					"phone": {
+						Name:       "literal_string",
+						Parameters: map[string]any{"literal": "x"},
+					},
+				},
+			},
+			{
+				Schema: "public",
+				Table:  "missing",
+				ColumnRules: map[string]TransformerRules{
+					"name": {Name: "string"},
+				},
+			},
+		},
+	}
+
+	wantRules := []RuleReport{
+		{Schema: "public", Table: "test", Column: "id", Transformer: "literal_string"},
+		{Schema: "public", Table: "test", Column: "name", Transformer: "literal_string"},
+		{Schema: "public", Table: "test", Column: "phone", Transformer: "literal_string", Error: "column phone not found in table \"public\".\"test\""},
+	}
+	wantErrors := []string{"table \"public\".\"missing\": querying table rows: oh noes"}
+
+	tests := []struct {
+		name       string
+		querier    *pgmocks.Querier
+		sampleRows int
+
+		wantReport *DryRunReport
+		wantErr    error
+	}{
+		{
+			name:       "ok - without sample rows",
+			querier:    testQuerier(nil),
+			sampleRows: 0,
+
+			wantReport: &DryRunReport{
+				Errors: wantErrors,
+				Rules:  wantRules,
+			},
+		},
+		{
+			name:       "ok - with sample rows",
+			querier:    testQuerier(nil),
+			sampleRows: 1,
+
+			wantReport: &DryRunReport{
+				Errors: wantErrors,
+				Rules:  wantRules,
+				Samples: []TableSample{
+					{
+						Schema: "public",
+						Table:  "test",
+						Rows: []SampleRow{
+							{
+								Before: map[string]any{"id": int64(1), "name": "alice", "email": "alice@example.com"},
+								After:  map[string]any{"id": "x", "name": "anonymous", "email": "alice@example.com"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:       "error - sampling rows",
+			querier:    testQuerier(errTest),
+			sampleRows: 1,
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			parser := &PostgresTransformerParser{
+				conn:      tc.querier,
+				builder:   builder.NewTransformerBuilder(),
+				pgtypeMap: pglib.NewMapper(tc.querier),
+			}
+
+			report, err := parser.DryRun(context.Background(), testRules, tc.sampleRows)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantReport, report)
+			if report != nil {
+				require.False(t, report.Valid())
+			}
+		})
+	}
+}
+
+func TestDryRunReport_Valid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		report *DryRunReport
+
+		wantValid bool
+	}{
+		{
+			name: "valid",
+			report: &DryRunReport{
+				Rules: []RuleReport{{Schema: "public", Table: "test", Column: "name", Transformer: "string"}},
+			},
+			wantValid: true,
+		},
+		{
+			name: "invalid - rule error",
+			report: &DryRunReport{
+				Rules: []RuleReport{{Schema: "public", Table: "test", Column: "name", Error: "oh noes"}},
+			},
+			wantValid: false,
+		},
+		{
+			name:      "invalid - report error",
+			report:    &DryRunReport{Errors: []string{"oh noes"}},
+			wantValid: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.wantValid, tc.report.Valid())
+		})
+	}
+}
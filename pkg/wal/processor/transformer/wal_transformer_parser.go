@@ -4,6 +4,7 @@ package transformer
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/xataio/pgstream/pkg/transformers"
 )
@@ -19,7 +20,7 @@ func newTransformerParser(b transformerBuilder) *transformerParser {
 }
 
 func (p *transformerParser) parse(_ context.Context, rules Rules) (map[string]ColumnTransformers, error) {
-	var err error
+	registry := newNamedTransformerRegistry(rules.NamedTransformers, p.builder.New)
 	transformerMap := map[string]ColumnTransformers{}
 	for _, table := range rules.Transformers {
 		if table.ValidationMode == validationModeStrict {
@@ -28,13 +29,19 @@ func (p *transformerParser) parse(_ context.Context, rules Rules) (map[string]Co
 		schemaTableTransformers := make(map[string]transformers.Transformer)
 		transformerMap[schemaTableKey(table.Schema, table.Table)] = schemaTableTransformers
 		for colName, transformerRules := range table.ColumnRules {
-			cfg := transformerRulesToConfig(transformerRules)
-			if cfg.Name == "" || cfg.Name == "noop" {
+			transformer, err := registry.build(transformerRules)
+			if err != nil {
+				return nil, err
+			}
+			if transformer == nil {
 				// noop transformer, skip
 				continue
 			}
-			if schemaTableTransformers[colName], err = p.builder.New(cfg); err != nil {
-				return nil, err
+			if transformerRules.Array {
+				transformer = transformers.NewArrayTransformer(transformer)
+			}
+			if schemaTableTransformers[colName], err = newConditionalTransformer(transformer, transformerRules.Condition); err != nil {
+				return nil, fmt.Errorf("column %s of table %s: %w", colName, schemaTableKey(table.Schema, table.Table), err)
 			}
 		}
 	}
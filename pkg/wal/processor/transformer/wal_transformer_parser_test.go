@@ -61,6 +61,36 @@ func TestTransformerParser_parse(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "ok - with condition",
+			rules: []TableRules{
+				{
+					Schema: testSchema,
+					Table:  testTable,
+					ColumnRules: map[string]TransformerRules{
+						"column_1": {
+							Name:      "string",
+							Condition: &ConditionRules{Expression: "column_2 = 'a'"},
+						},
+					},
+				},
+			},
+
+			wantTransformerMap: map[string]ColumnTransformers{
+				testKey: {
+					"column_1": &conditionalTransformer{
+						Transformer: testTransformer,
+						condition: &condition{
+							raw:             "column_2 = 'a'",
+							expr:            &compareExpr{column: "column_2", op: "=", value: "a"},
+							columns:         []string{"column_2"},
+							onMissingColumn: onMissingColumnNull,
+						},
+					},
+				},
+			},
+			wantErr: nil,
+		},
 		{
 			name:  "ok - no rules",
 			rules: []TableRules{},
@@ -85,6 +115,24 @@ func TestTransformerParser_parse(t *testing.T) {
 			wantTransformerMap: nil,
 			wantErr:            transformers.ErrUnsupportedTransformer,
 		},
+		{
+			name: "error - invalid condition",
+			rules: []TableRules{
+				{
+					Schema: testSchema,
+					Table:  testTable,
+					ColumnRules: map[string]TransformerRules{
+						"column_1": {
+							Name:      "string",
+							Condition: &ConditionRules{Expression: "column_2 ="},
+						},
+					},
+				},
+			},
+
+			wantTransformerMap: nil,
+			wantErr:            errInvalidCondition,
+		},
 	}
 
 	for _, tc := range tests {
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/xataio/pgstream/pkg/transformers"
+)
+
+// namedTransformerRegistry builds the transformers for the column rules. The
+// column rules referencing a named transformer share the same instance, so
+// that the same input is transformed into the same output across tables (i.e.
+// users.email and orders.customer_email), and stateful transformers share
+// their state (i.e. the values already generated for uniqueness).
+type namedTransformerRegistry struct {
+	buildFn buildFn
+	rules   map[string]NamedTransformerRules
+
+	mutex  sync.Mutex
+	shared map[string]*sharedTransformer
+}
+
+type buildFn func(*transformers.Config) (transformers.Transformer, error)
+
+var (
+	errNamedTransformerNotFound    = errors.New("named transformer not found")
+	errInvalidNamedTransformerRule = errors.New("column rules referencing a named transformer can't define name, parameters or dynamic parameters")
+	errNamedTransformerMissingName = errors.New("named transformer must define a transformer name")
+	errNoopChainStage              = errors.New("chain stages must define a transformer")
+	errInvalidChainRule            = errors.New("column rules with a chain can't define name, parameters, dynamic parameters or named transformer")
+)
+
+func newNamedTransformerRegistry(rules map[string]NamedTransformerRules, build buildFn) *namedTransformerRegistry {
+	return &namedTransformerRegistry{
+		buildFn: build,
+		rules:   rules,
+		shared:  make(map[string]*sharedTransformer, len(rules)),
+	}
+}
+
+// build returns the transformer for the column rules on input, or nil if no
+// transformation is configured. If the rules reference a named transformer,
+// the shared instance is returned, and built the first time it's referenced.
+func (r *namedTransformerRegistry) build(rules TransformerRules) (transformers.Transformer, error) {
+	if len(rules.Chain) > 0 {
+		return r.buildChain(rules)
+	}
+
+	if rules.NamedTransformer == "" {
+		cfg := transformerRulesToConfig(rules)
+		if cfg.Name == "" || cfg.Name == "noop" {
+			return nil, nil
+		}
+		return r.buildFn(cfg)
+	}
+
+	if rules.Name != "" || len(rules.Parameters) > 0 || len(rules.DynamicParameters) > 0 {
+		return nil, fmt.Errorf("%w: %s", errInvalidNamedTransformerRule, rules.NamedTransformer)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if shared, found := r.shared[rules.NamedTransformer]; found {
+		shared.acquire()
+		return shared, nil
+	}
+
+	namedRules, found := r.rules[rules.NamedTransformer]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", errNamedTransformerNotFound, rules.NamedTransformer)
+	}
+	if namedRules.Name == "" || namedRules.Name == "noop" {
+		return nil, fmt.Errorf("%w: %s", errNamedTransformerMissingName, rules.NamedTransformer)
+	}
+
+	t, err := r.buildFn(&transformers.Config{
+		Name:              transformers.TransformerType(namedRules.Name),
+		Parameters:        namedRules.Parameters,
+		DynamicParameters: namedRules.DynamicParameters,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("named transformer %s: %w", rules.NamedTransformer, err)
+	}
+
+	shared := &sharedTransformer{Transformer: t}
+	shared.acquire()
+	r.shared[rules.NamedTransformer] = shared
+	return shared, nil
+}
+
+// buildChain returns a chain transformer with the stages of the column rules
+// on input.
+func (r *namedTransformerRegistry) buildChain(rules TransformerRules) (transformers.Transformer, error) {
+	if rules.Name != "" || len(rules.Parameters) > 0 || len(rules.DynamicParameters) > 0 || rules.NamedTransformer != "" {
+		return nil, errInvalidChainRule
+	}
+
+	stages := make([]transformers.Transformer, 0, len(rules.Chain))
+	closeStages := func() {
+		for _, stage := range stages {
+			stage.Close()
+		}
+	}
+	for i, stageRules := range rules.Chain {
+		stage, err := r.build(TransformerRules{
+			Name:              stageRules.Name,
+			Parameters:        stageRules.Parameters,
+			DynamicParameters: stageRules.DynamicParameters,
+			NamedTransformer:  stageRules.NamedTransformer,
+		})
+		if err != nil {
+			closeStages()
+			return nil, fmt.Errorf("chain[%d]: %w", i, err)
+		}
+		if stage == nil {
+			// noop stages are not skipped, so that the stage indexes in the
+			// errors match the rules
+			closeStages()
+			return nil, fmt.Errorf("chain[%d]: %w", i, errNoopChainStage)
+		}
+		stages = append(stages, stage)
+	}
+	return transformers.NewChainTransformer(stages...)
+}
+
+// sharedTransformer is a transformer referenced by multiple column rules. The
+// transformations are serialised, since the events of different tables can be
+// transformed concurrently and not all transformers are safe for concurrent
+// use. The wrapped transformer is closed once all the references are closed.
+type sharedTransformer struct {
+	transformers.Transformer
+
+	mutex sync.Mutex
+	refs  int
+}
+
+func (t *sharedTransformer) Transform(ctx context.Context, value transformers.Value) (any, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.Transformer.Transform(ctx, value)
+}
+
+// ReferencedColumns returns the columns referenced by the wrapped transformer.
+func (t *sharedTransformer) ReferencedColumns() []string {
+	if referencer, ok := t.Transformer.(transformers.ColumnReferencer); ok {
+		return referencer.ReferencedColumns()
+	}
+	return nil
+}
+
+// OutputType returns the output type of the wrapped transformer.
+func (t *sharedTransformer) OutputType() transformers.SupportedDataType {
+	if typer, ok := t.Transformer.(transformers.OutputTyper); ok {
+		return typer.OutputType()
+	}
+	return ""
+}
+
+func (t *sharedTransformer) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.refs--
+	if t.refs > 0 {
+		return nil
+	}
+	return t.Transformer.Close()
+}
+
+func (t *sharedTransformer) acquire() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.refs++
+}
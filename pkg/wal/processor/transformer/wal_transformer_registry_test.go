@@ -0,0 +1,170 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package transformer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/transformers"
+	transformermocks "github.com/xataio/pgstream/pkg/transformers/mocks"
+)
+
+func TestNamedTransformerRegistry_build(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	testNamedRules := map[string]NamedTransformerRules{
+		"customer_email": {Name: "hmac", Parameters: map[string]any{"key": "secret"}},
+		"noop":           {Name: "noop"},
+		"broken":         {Name: "broken"},
+	}
+
+	tests := []struct {
+		name  string
+		rules TransformerRules
+
+		wantShared bool
+		wantChain  bool
+		wantNil    bool
+		wantErr    error
+	}{
+		{
+			name:  "ok - column transformer",
+			rules: TransformerRules{Name: "hmac", Parameters: map[string]any{"key": "secret"}},
+		},
+		{
+			name:    "ok - noop column transformer",
+			rules:   TransformerRules{Name: "noop"},
+			wantNil: true,
+		},
+		{
+			name:       "ok - named transformer",
+			rules:      TransformerRules{NamedTransformer: "customer_email"},
+			wantShared: true,
+		},
+		{
+			name:    "error - named transformer not found",
+			rules:   TransformerRules{NamedTransformer: "unknown"},
+			wantErr: errNamedTransformerNotFound,
+		},
+		{
+			name:    "error - named transformer with parameters",
+			rules:   TransformerRules{NamedTransformer: "customer_email", Parameters: map[string]any{"key": "other"}},
+			wantErr: errInvalidNamedTransformerRule,
+		},
+		{
+			name:    "error - named transformer without name",
+			rules:   TransformerRules{NamedTransformer: "noop"},
+			wantErr: errNamedTransformerMissingName,
+		},
+		{
+			name:    "error - building named transformer",
+			rules:   TransformerRules{NamedTransformer: "broken"},
+			wantErr: errTest,
+		},
+		{
+			name: "ok - chain",
+			rules: TransformerRules{Chain: []ChainStageRules{
+				{Name: "regex_replace"},
+				{NamedTransformer: "customer_email"},
+			}},
+			wantChain: true,
+		},
+		{
+			name:    "error - noop chain stage",
+			rules:   TransformerRules{Chain: []ChainStageRules{{Name: "regex_replace"}, {Name: "noop"}}},
+			wantErr: errNoopChainStage,
+		},
+		{
+			name:    "error - chain with transformer name",
+			rules:   TransformerRules{Name: "hmac", Chain: []ChainStageRules{{Name: "regex_replace"}}},
+			wantErr: errInvalidChainRule,
+		},
+		{
+			name: "error - building chain stage",
+			rules: TransformerRules{Chain: []ChainStageRules{
+				{Name: "regex_replace"},
+				{NamedTransformer: "broken"},
+			}},
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			registry := newNamedTransformerRegistry(testNamedRules, func(cfg *transformers.Config) (transformers.Transformer, error) {
+				if cfg.Name == "broken" {
+					return nil, errTest
+				}
+				return &transformermocks.Transformer{}, nil
+			})
+
+			got, err := registry.build(tc.rules)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil || tc.wantNil {
+				require.Nil(t, got)
+				return
+			}
+			_, isShared := got.(*sharedTransformer)
+			require.Equal(t, tc.wantShared, isShared)
+			_, isChain := got.(*transformers.ChainTransformer)
+			require.Equal(t, tc.wantChain, isChain)
+		})
+	}
+}
+
+func TestNamedTransformerRegistry_sharedInstance(t *testing.T) {
+	t.Parallel()
+
+	buildCalls := 0
+	closeCalls := 0
+	transformCalls := 0
+	registry := newNamedTransformerRegistry(map[string]NamedTransformerRules{
+		"customer_email": {Name: "hmac", Parameters: map[string]any{"key": "secret"}},
+	}, func(cfg *transformers.Config) (transformers.Transformer, error) {
+		buildCalls++
+		return &transformermocks.Transformer{
+			// not safe for concurrent use, the shared transformer needs to
+			// serialise the calls
+			TransformFn: func(v transformers.Value) (any, error) {
+				transformCalls++
+				return v.TransformValue, nil
+			},
+			CloseFn: func() error {
+				closeCalls++
+				return nil
+			},
+		}, nil
+	})
+
+	users, err := registry.build(TransformerRules{NamedTransformer: "customer_email"})
+	require.NoError(t, err)
+	orders, err := registry.build(TransformerRules{NamedTransformer: "customer_email"})
+	require.NoError(t, err)
+	require.Same(t, users, orders)
+	require.Equal(t, 1, buildCalls)
+
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := users.Transform(context.Background(), transformers.NewValue("a@b.com", "text", nil))
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 10, transformCalls)
+
+	// the wrapped transformer is closed once all references are closed
+	require.NoError(t, users.Close())
+	require.Equal(t, 0, closeCalls)
+	require.NoError(t, orders.Close())
+	require.Equal(t, 1, closeCalls)
+}
@@ -3,8 +3,11 @@
 package transformer
 
 type Rules struct {
-	Transformers   []TableRules `yaml:"transformations"`
-	ValidationMode string       `yaml:"validation_mode"`
+	Transformers []TableRules `yaml:"transformations"`
+	// NamedTransformers are the transformers that can be shared by multiple
+	// column rules, by name.
+	NamedTransformers map[string]NamedTransformerRules `yaml:"named_transformers,omitempty"`
+	ValidationMode    string                           `yaml:"validation_mode"`
 }
 
 type TableRules struct {
@@ -18,4 +21,48 @@ type TransformerRules struct {
 	Name              string         `yaml:"name"`
 	Parameters        map[string]any `yaml:"parameters"`
 	DynamicParameters map[string]any `yaml:"dynamic_parameters"`
+	// NamedTransformer references a named transformer instead of defining
+	// the transformer for the column, in which case name and parameters must
+	// not be provided.
+	NamedTransformer string `yaml:"named_transformer,omitempty"`
+	// Array applies the transformer to each of the elements of the column
+	// array value. Array columns are detected automatically when the rules
+	// are validated against the source database.
+	Array bool `yaml:"array,omitempty"`
+	// Condition restricts the transformer to the rows matching it. If not
+	// provided, the transformer is applied to all rows.
+	Condition *ConditionRules `yaml:"condition,omitempty"`
+	// Chain applies a sequence of transformers to the column, in order, each
+	// of them receiving the output of the previous one. Column rules with a
+	// chain can't define a transformer of their own, but the array and
+	// condition options apply to the whole chain.
+	Chain []ChainStageRules `yaml:"chain,omitempty"`
+}
+
+// ChainStageRules define one of the transformers of a column chain, either
+// inline or by referencing a named transformer.
+type ChainStageRules struct {
+	Name              string         `yaml:"name"`
+	Parameters        map[string]any `yaml:"parameters"`
+	DynamicParameters map[string]any `yaml:"dynamic_parameters"`
+	NamedTransformer  string         `yaml:"named_transformer,omitempty"`
+}
+
+// NamedTransformerRules define a transformer instance shared by all the column
+// rules referencing it, even in different tables. The dynamic parameters
+// reference the same column names in all those tables.
+type NamedTransformerRules struct {
+	Name              string         `yaml:"name"`
+	Parameters        map[string]any `yaml:"parameters"`
+	DynamicParameters map[string]any `yaml:"dynamic_parameters"`
+}
+
+type ConditionRules struct {
+	// Expression over the row column values, such as `country = 'DE' and
+	// is_test is not true`
+	Expression string `yaml:"expression"`
+	// OnMissingColumn defines how the condition is evaluated when a column it
+	// references is not part of the event. One of null (default), skip or
+	// transform.
+	OnMissingColumn string `yaml:"on_missing_column"`
 }
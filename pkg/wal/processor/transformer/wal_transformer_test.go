@@ -5,6 +5,7 @@ package transformer
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -207,6 +208,44 @@ func TestTransformer_ProcessWALEvent(t *testing.T) {
 
 			wantErr: nil,
 		},
+		{
+			name: "ok - referenced columns transformed first",
+			event: newTestEvent([]wal.Column{
+				{Name: "column_1", Type: "text", Value: "one"},
+				{Name: "column_2", Type: "text", Value: "a"},
+				{Name: "column_3", Type: "int", Value: 1},
+			}),
+			processor: &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					wantEvent := newTestEvent([]wal.Column{
+						{Name: "column_1", Type: "text", Value: "b-1"},
+						{Name: "column_2", Type: "text", Value: "b"},
+						{Name: "column_3", Type: "int", Value: 1},
+					})
+					require.Equal(t, wantEvent, walEvent)
+					return nil
+				},
+			},
+			transformerMap: map[string]ColumnTransformers{
+				testKey: {
+					"column_1": &transformermocks.Transformer{
+						TransformFn: func(a transformers.Value) (any, error) {
+							require.Equal(t, map[string]any{"column_2": "b", "column_3": 1}, a.DynamicValues)
+							return fmt.Sprintf("%s-%d", a.DynamicValues["column_2"], a.DynamicValues["column_3"]), nil
+						},
+						IsDynamicFn:         func() bool { return true },
+						ReferencedColumnsFn: func() []string { return []string{"column_2", "column_3"} },
+					},
+					"column_2": &transformermocks.Transformer{
+						TransformFn: func(a transformers.Value) (any, error) {
+							return "b", nil
+						},
+					},
+				},
+			},
+
+			wantErr: nil,
+		},
 		{
 			name: "ok - nil column value",
 			event: newTestEvent([]wal.Column{
@@ -261,6 +300,37 @@ func TestTransformer_ProcessWALEvent(t *testing.T) {
 				},
 			},
 
+			wantErr: nil,
+		},
+		{
+			name: "ok - conditional transformers evaluated with original row values",
+			event: newTestEvent([]wal.Column{
+				{Name: "country", Type: "text", Value: "DE"},
+				{Name: "email", Type: "text", Value: "alice@acme.com"},
+				{Name: "phone", Type: "text", Value: "555-1234"},
+			}),
+			processor: &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					wantEvent := newTestEvent([]wal.Column{
+						{Name: "country", Type: "text", Value: "XX"},
+						{Name: "email", Type: "text", Value: "masked"},
+						{Name: "phone", Type: "text", Value: "555-1234"},
+					})
+					require.Equal(t, wantEvent, walEvent)
+					return nil
+				},
+			},
+			transformerMap: map[string]ColumnTransformers{
+				testKey: {
+					"country": &transformermocks.Transformer{
+						TransformFn: func(a transformers.Value) (any, error) { return "XX", nil },
+					},
+					// evaluated after the country column has been transformed
+					"email": newTestConditionalTransformer(t, "masked", "country = 'DE'"),
+					"phone": newTestConditionalTransformer(t, "masked", "country != 'DE'"),
+				},
+			},
+
 			wantErr: nil,
 		},
 	}
@@ -269,14 +339,81 @@ func TestTransformer_ProcessWALEvent(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
+			transformOrder, err := transformationOrder(tc.transformerMap)
+			require.NoError(t, err)
 			transformer := &Transformer{
-				logger:         log.NewNoopLogger(),
-				transformerMap: tc.transformerMap,
-				processor:      tc.processor,
+				logger:            log.NewNoopLogger(),
+				transformerMap:    tc.transformerMap,
+				transformOrder:    transformOrder,
+				conditionalTables: conditionalTables(tc.transformerMap),
+				processor:         tc.processor,
 			}
 
-			err := transformer.ProcessWALEvent(context.Background(), tc.event)
+			err = transformer.ProcessWALEvent(context.Background(), tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestTransformationOrder(t *testing.T) {
+	t.Parallel()
+
+	newReferencer := func(refs ...string) *transformermocks.Transformer {
+		return &transformermocks.Transformer{
+			ReferencedColumnsFn: func() []string { return refs },
+		}
+	}
+
+	tests := []struct {
+		name           string
+		transformerMap map[string]ColumnTransformers
+
+		wantOrder map[string][]string
+		wantErr   error
+	}{
+		{
+			name: "ok",
+			transformerMap: map[string]ColumnTransformers{
+				"table": {
+					"full_name":  newReferencer("first_name", "last_name"),
+					"email":      newReferencer("full_name", "id"),
+					"first_name": newReferencer(),
+					"last_name":  newReferencer(),
+				},
+			},
+			wantOrder: map[string][]string{
+				"table": {"first_name", "last_name", "full_name", "email"},
+			},
+		},
+		{
+			name: "error - circular reference",
+			transformerMap: map[string]ColumnTransformers{
+				"table": {
+					"a": newReferencer("b"),
+					"b": newReferencer("c"),
+					"c": newReferencer("a"),
+				},
+			},
+			wantErr: errCircularColumnReference,
+		},
+		{
+			name: "error - self reference",
+			transformerMap: map[string]ColumnTransformers{
+				"table": {
+					"a": newReferencer("a"),
+				},
+			},
+			wantErr: errCircularColumnReference,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			order, err := transformationOrder(tc.transformerMap)
 			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantOrder, order)
 		})
 	}
 }
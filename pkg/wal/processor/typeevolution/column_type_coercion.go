@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typeevolution
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var errIncoercibleValue = errors.New("value can't be coerced to the column type")
+
+type typeCategory int
+
+const (
+	otherCategory typeCategory = iota
+	integerCategory
+	floatCategory
+	booleanCategory
+	textCategory
+)
+
+// typeCategories maps the postgres types, without modifiers, to the category
+// their values are coerced to. The types not included, like numeric or the
+// arrays, are kept unchanged.
+var typeCategories = map[string]typeCategory{
+	"smallint":          integerCategory,
+	"integer":           integerCategory,
+	"bigint":            integerCategory,
+	"int":               integerCategory,
+	"int2":              integerCategory,
+	"int4":              integerCategory,
+	"int8":              integerCategory,
+	"smallserial":       integerCategory,
+	"serial":            integerCategory,
+	"bigserial":         integerCategory,
+	"real":              floatCategory,
+	"double precision":  floatCategory,
+	"float4":            floatCategory,
+	"float8":            floatCategory,
+	"boolean":           booleanCategory,
+	"bool":              booleanCategory,
+	"text":              textCategory,
+	"character varying": textCategory,
+	"varchar":           textCategory,
+	"character":         textCategory,
+	"char":              textCategory,
+	"bpchar":            textCategory,
+	"uuid":              textCategory,
+}
+
+// coerceValue returns the value on input converted to the go representation
+// of the postgres type on input.
+func coerceValue(value any, dataType string) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch typeCategoryOf(dataType) {
+	case integerCategory:
+		return coerceInteger(value, dataType)
+	case floatCategory:
+		return coerceFloat(value, dataType)
+	case booleanCategory:
+		return coerceBoolean(value, dataType)
+	case textCategory:
+		return coerceText(value, dataType)
+	default:
+		return value, nil
+	}
+}
+
+func typeCategoryOf(dataType string) typeCategory {
+	dataType = strings.ToLower(strings.TrimSpace(dataType))
+	if i := strings.IndexByte(dataType, '('); i >= 0 {
+		dataType = strings.TrimSpace(dataType[:i])
+	}
+	return typeCategories[dataType]
+}
+
+func coerceInteger(value any, dataType string) (any, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) || v > math.MaxInt64 || v < math.MinInt64 {
+			return nil, incoercibleValueError(value, dataType)
+		}
+		return int64(v), nil
+	case json.Number:
+		i, err := v.Int64()
+		if err != nil {
+			return nil, incoercibleValueError(value, dataType)
+		}
+		return i, nil
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, incoercibleValueError(value, dataType)
+		}
+		return i, nil
+	default:
+		return nil, incoercibleValueError(value, dataType)
+	}
+}
+
+func coerceFloat(value any, dataType string) (any, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil, incoercibleValueError(value, dataType)
+		}
+		return f, nil
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, incoercibleValueError(value, dataType)
+		}
+		return f, nil
+	default:
+		return nil, incoercibleValueError(value, dataType)
+	}
+}
+
+func coerceBoolean(value any, dataType string) (any, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, incoercibleValueError(value, dataType)
+		}
+		return b, nil
+	default:
+		return nil, incoercibleValueError(value, dataType)
+	}
+}
+
+func coerceText(value any, dataType string) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int64, int, json.Number, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return nil, incoercibleValueError(value, dataType)
+	}
+}
+
+func incoercibleValueError(value any, dataType string) error {
+	return fmt.Errorf("%w: %v (%T) to %s", errIncoercibleValue, value, value, dataType)
+}
@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typeevolution
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoerceValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		value    any
+		dataType string
+
+		wantValue any
+		wantErr   error
+	}{
+		{name: "ok - nil", value: nil, dataType: "integer", wantValue: nil},
+		{name: "ok - integer from float", value: float64(42), dataType: "integer", wantValue: int64(42)},
+		{name: "ok - bigint from json number", value: json.Number("9007199254740993"), dataType: "bigint", wantValue: int64(9007199254740993)},
+		{name: "ok - integer from string", value: "42", dataType: "int4", wantValue: int64(42)},
+		{name: "ok - double precision from int", value: int64(42), dataType: "double precision", wantValue: float64(42)},
+		{name: "ok - real from string", value: "4.5", dataType: "real", wantValue: 4.5},
+		{name: "ok - boolean from string", value: "t", dataType: "boolean", wantValue: true},
+		{name: "ok - varchar with modifier from float", value: 4.5, dataType: "character varying(255)", wantValue: "4.5"},
+		{name: "ok - text from bool", value: true, dataType: "text", wantValue: "true"},
+		{name: "ok - numeric unchanged", value: 4.5, dataType: "numeric(10,2)", wantValue: 4.5},
+		{name: "ok - array unchanged", value: []any{float64(1)}, dataType: "integer[]", wantValue: []any{float64(1)}},
+		{name: "error - integer from fractional float", value: 4.5, dataType: "integer", wantErr: errIncoercibleValue},
+		{name: "error - integer from invalid string", value: "a", dataType: "bigint", wantErr: errIncoercibleValue},
+		{name: "error - boolean from float", value: float64(1), dataType: "bool", wantErr: errIncoercibleValue},
+		{name: "error - text from map", value: map[string]any{}, dataType: "text", wantErr: errIncoercibleValue},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			value, err := coerceValue(tc.value, tc.dataType)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantValue, value)
+		})
+	}
+}
@@ -0,0 +1,284 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typeevolution
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	schemalogpg "github.com/xataio/pgstream/pkg/schemalog/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// ColumnTypeEvolutionAdapter is a decorator around a wal processor that
+// coerces the column values of the replayed events (see wal.IsReplay) to the
+// types that were in effect when the events were produced, as recorded by the
+// schema log, rather than the current schema types. This keeps the historical
+// replay into typed sinks correct after backward compatible type changes
+// (e.g. integer to bigint). Live events are sent to the wrapped processor
+// unchanged.
+//
+// The schema version of an event is the one it was stamped with by the
+// injector. Events without one are matched to the latest schema version
+// created before their commit, which might not be accurate for transactions
+// committed concurrently with a schema change.
+type ColumnTypeEvolutionAdapter struct {
+	logger    loglib.Logger
+	processor processor.Processor
+	store     schemalog.Store
+	clock     func() time.Time
+
+	// mutex protects the schema histories, which are loaded on demand
+	mutex     sync.Mutex
+	histories map[string]*schemaHistory
+}
+
+type Config struct {
+	// Store is the schema log store the schema history is read from.
+	Store schemalogpg.Config
+}
+
+// schemaHistory contains the schema log entries of a schema, ordered by
+// version.
+type schemaHistory struct {
+	entries  []*schemalog.LogEntry
+	byID     map[xid.ID]*schemalog.LogEntry
+	loadedAt time.Time
+}
+
+type Option func(a *ColumnTypeEvolutionAdapter)
+
+// New will return a column type evolution adapter wrapper around the
+// processor on input, which reads the schema history from the schema log
+// store on input.
+func New(store schemalog.Store, p processor.Processor, opts ...Option) *ColumnTypeEvolutionAdapter {
+	a := &ColumnTypeEvolutionAdapter{
+		logger:    loglib.NewNoopLogger(),
+		processor: p,
+		store:     store,
+		clock:     time.Now,
+		histories: map[string]*schemaHistory{},
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(a *ColumnTypeEvolutionAdapter) {
+		a.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "column_type_evolution_adapter",
+		})
+	}
+}
+
+// ProcessWALEvent coerces the column values of the replayed events to their
+// historical types before sending them to the wrapped processor.
+func (a *ColumnTypeEvolutionAdapter) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	if event.Data != nil && wal.IsReplay(ctx) && !processor.IsSchemaLogEvent(event.Data) {
+		if err := a.adaptEvent(ctx, event.Data); err != nil {
+			return err
+		}
+	}
+	return a.processor.ProcessWALEvent(ctx, event)
+}
+
+func (a *ColumnTypeEvolutionAdapter) Name() string {
+	return a.processor.Name()
+}
+
+func (a *ColumnTypeEvolutionAdapter) Close() error {
+	return a.processor.Close()
+}
+
+func (a *ColumnTypeEvolutionAdapter) Ping(ctx context.Context) error {
+	return a.processor.Ping(ctx)
+}
+
+func (a *ColumnTypeEvolutionAdapter) adaptEvent(ctx context.Context, data *wal.Data) error {
+	entry, err := a.schemaVersion(ctx, data)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		a.logger.Debug("no schema version found for replayed event, sending it unchanged", data.LogFields())
+		return nil
+	}
+
+	table := findTable(&entry.Schema, data)
+	if table == nil {
+		return nil
+	}
+
+	for _, columns := range [][]wal.Column{data.Columns, data.Identity} {
+		for i := range columns {
+			column := findColumn(table, &columns[i])
+			if column == nil {
+				continue
+			}
+			value, err := coerceValue(columns[i].Value, column.DataType)
+			if err != nil {
+				return fmt.Errorf("column %s.%s.%s at schema version %d: %w", data.Schema, data.Table, columns[i].Name, entry.Version, err)
+			}
+			columns[i].Value = value
+			columns[i].Type = column.DataType
+		}
+	}
+	return nil
+}
+
+// schemaVersion returns the schema log entry in effect when the event on input
+// was produced, or nil if there's none. The schema history is reloaded when
+// the event belongs to a version that has not been loaded yet.
+func (a *ColumnTypeEvolutionAdapter) schemaVersion(ctx context.Context, data *wal.Data) (*schemalog.LogEntry, error) {
+	if schemaID := data.Metadata.SchemaID; !schemaID.IsNil() {
+		if entry := a.entryByID(data.Schema, schemaID); entry != nil {
+			return entry, nil
+		}
+		if err := a.loadHistory(ctx, data.Schema); err != nil {
+			return nil, err
+		}
+		return a.entryByID(data.Schema, schemaID), nil
+	}
+
+	commitTime, err := data.GetTimestamp()
+	if err != nil {
+		return nil, nil
+	}
+	if entry, loaded := a.entryAt(data.Schema, commitTime); loaded {
+		return entry, nil
+	}
+	if err := a.loadHistory(ctx, data.Schema); err != nil {
+		return nil, err
+	}
+	entry, _ := a.entryAt(data.Schema, commitTime)
+	return entry, nil
+}
+
+// entryByID returns the loaded schema log entry with the id on input, or nil
+// if it's not been loaded.
+func (a *ColumnTypeEvolutionAdapter) entryByID(schemaName string, id xid.ID) *schemalog.LogEntry {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.history(schemaName).byID[id]
+}
+
+// entryAt returns the loaded schema log entry in effect at the time on input,
+// and whether the schema history was loaded after that time.
+func (a *ColumnTypeEvolutionAdapter) entryAt(schemaName string, t time.Time) (*schemalog.LogEntry, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	history := a.history(schemaName)
+	loaded := !history.loadedAt.IsZero() && !t.After(history.loadedAt)
+	return history.versionAt(t), loaded
+}
+
+// history returns the schema history of the schema on input, creating it if
+// it doesn't exist yet. It must be called with the mutex held.
+func (a *ColumnTypeEvolutionAdapter) history(schemaName string) *schemaHistory {
+	history, found := a.histories[schemaName]
+	if !found {
+		history = &schemaHistory{byID: map[xid.ID]*schemalog.LogEntry{}}
+		a.histories[schemaName] = history
+	}
+	return history
+}
+
+// loadHistory adds the schema log entries created since the last load to the
+// schema history. The store is queried without holding the mutex, so that
+// events of other schemas are not blocked by the load.
+func (a *ColumnTypeEvolutionAdapter) loadHistory(ctx context.Context, schemaName string) error {
+	a.mutex.Lock()
+	loadedVersion := a.history(schemaName).lastVersion()
+	a.mutex.Unlock()
+
+	loadedAt := a.clock()
+	entries, err := a.store.FetchSince(ctx, schemaName, int(loadedVersion))
+	if err != nil {
+		return fmt.Errorf("fetching schema log entries for schema %s: %w", schemaName, err)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.history(schemaName).add(entries, loadedAt)
+	return nil
+}
+
+// lastVersion returns the latest loaded schema version, or 0 if there's none.
+func (h *schemaHistory) lastVersion() int64 {
+	if len(h.entries) == 0 {
+		return 0
+	}
+	return h.entries[len(h.entries)-1].Version
+}
+
+// add appends the schema log entries on input, ordered by version, to the
+// history. Entries already added by a concurrent load are skipped.
+func (h *schemaHistory) add(entries []*schemalog.LogEntry, loadedAt time.Time) {
+	for _, entry := range entries {
+		if entry.Version <= h.lastVersion() {
+			continue
+		}
+		h.entries = append(h.entries, entry)
+		h.byID[entry.ID] = entry
+	}
+	if loadedAt.After(h.loadedAt) {
+		h.loadedAt = loadedAt
+	}
+}
+
+// versionAt returns the latest schema log entry created at or before the time
+// on input, or nil if there's none.
+func (h *schemaHistory) versionAt(t time.Time) *schemalog.LogEntry {
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if !h.entries[i].CreatedAt.After(t) {
+			return h.entries[i]
+		}
+	}
+	return nil
+}
+
+// findTable returns the table of the event on input, by pgstream id if the
+// event has one, or by name otherwise.
+func findTable(schema *schemalog.Schema, data *wal.Data) *schemalog.Table {
+	for i := range schema.Tables {
+		table := &schema.Tables[i]
+		if data.Metadata.TablePgstreamID != "" && table.PgstreamID != "" {
+			if table.PgstreamID == data.Metadata.TablePgstreamID {
+				return table
+			}
+			continue
+		}
+		if table.Name == data.Table {
+			return table
+		}
+	}
+	return nil
+}
+
+// findColumn returns the table column of the event column on input, by
+// pgstream id if the event column has one, or by name otherwise.
+func findColumn(table *schemalog.Table, column *wal.Column) *schemalog.Column {
+	for i := range table.Columns {
+		c := &table.Columns[i]
+		if column.ID != "" && c.PgstreamID != "" {
+			if c.PgstreamID == column.ID {
+				return c
+			}
+			continue
+		}
+		if c.Name == column.Name {
+			return c
+		}
+	}
+	return nil
+}
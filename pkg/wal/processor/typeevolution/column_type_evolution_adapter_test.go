@@ -0,0 +1,214 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package typeevolution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	schemalogmocks "github.com/xataio/pgstream/pkg/schemalog/mocks"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+func TestColumnTypeEvolutionAdapter_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+	v1ID, v2ID := xid.New(), xid.New()
+	newEntry := func(id xid.ID, version int64, createdAt time.Time, columnType string) *schemalog.LogEntry {
+		return &schemalog.LogEntry{
+			ID:         id,
+			Version:    version,
+			SchemaName: "public",
+			CreatedAt:  schemalog.NewSchemaCreatedAtTimestamp(createdAt),
+			Schema: schemalog.Schema{
+				Tables: []schemalog.Table{
+					{
+						Name:       "orders",
+						PgstreamID: "t1",
+						Columns: []schemalog.Column{
+							{Name: "id", DataType: "bigint", PgstreamID: "t1-1"},
+							{Name: "amount", DataType: columnType, PgstreamID: "t1-2"},
+						},
+					},
+				},
+			},
+		}
+	}
+	entries := map[int]*schemalog.LogEntry{
+		1: newEntry(v1ID, 1, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "integer"),
+		2: newEntry(v2ID, 2, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "text"),
+	}
+	testStore := &schemalogmocks.Store{
+		FetchSinceFn: func(ctx context.Context, schemaName string, version int) ([]*schemalog.LogEntry, error) {
+			require.Equal(t, "public", schemaName)
+			require.Equal(t, 0, version)
+			return []*schemalog.LogEntry{entries[1], entries[2]}, nil
+		},
+	}
+
+	newEvent := func(schemaID xid.ID, timestamp string) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action:    "I",
+				Timestamp: timestamp,
+				Schema:    "public",
+				Table:     "orders",
+				Columns: []wal.Column{
+					{ID: "t1-1", Name: "id", Type: "bigint", Value: float64(1)},
+					{ID: "t1-2", Name: "amount", Type: "numeric", Value: float64(42)},
+				},
+				Metadata: wal.Metadata{
+					SchemaID:        schemaID,
+					TablePgstreamID: "t1",
+				},
+			},
+		}
+	}
+	replayCtx := wal.WithReplayContext(context.Background(), wal.ReplayContext{IsReplay: true})
+
+	tests := []struct {
+		name  string
+		store schemalog.Store
+		ctx   context.Context
+		event *wal.Event
+
+		wantColumns []wal.Column
+		wantErr     error
+	}{
+		{
+			name:  "ok - replayed event with schema id",
+			store: testStore,
+			ctx:   replayCtx,
+			event: newEvent(v1ID, "2024-01-03 00:00:00+00"),
+
+			wantColumns: []wal.Column{
+				{ID: "t1-1", Name: "id", Type: "bigint", Value: int64(1)},
+				{ID: "t1-2", Name: "amount", Type: "integer", Value: int64(42)},
+			},
+		},
+		{
+			name:  "ok - replayed event matched by commit time",
+			store: testStore,
+			ctx:   replayCtx,
+			event: newEvent(xid.NilID(), "2024-01-02 12:00:00+00"),
+
+			wantColumns: []wal.Column{
+				{ID: "t1-1", Name: "id", Type: "bigint", Value: int64(1)},
+				{ID: "t1-2", Name: "amount", Type: "text", Value: "42"},
+			},
+		},
+		{
+			name:  "ok - replayed event before the schema history",
+			store: testStore,
+			ctx:   replayCtx,
+			event: newEvent(xid.NilID(), "2023-12-31 00:00:00+00"),
+
+			wantColumns: newEvent(xid.NilID(), "").Data.Columns,
+		},
+		{
+			name:  "ok - live event",
+			store: testStore,
+			ctx:   context.Background(),
+			event: newEvent(v1ID, "2024-01-03 00:00:00+00"),
+
+			wantColumns: newEvent(xid.NilID(), "").Data.Columns,
+		},
+		{
+			name: "ok - schema without history",
+			store: &schemalogmocks.Store{
+				FetchSinceFn: func(ctx context.Context, schemaName string, version int) ([]*schemalog.LogEntry, error) {
+					return []*schemalog.LogEntry{}, nil
+				},
+			},
+			ctx:   replayCtx,
+			event: newEvent(v1ID, "2024-01-03 00:00:00+00"),
+
+			wantColumns: newEvent(xid.NilID(), "").Data.Columns,
+		},
+		{
+			name: "error - fetching schema history",
+			store: &schemalogmocks.Store{
+				FetchSinceFn: func(ctx context.Context, schemaName string, version int) ([]*schemalog.LogEntry, error) {
+					return nil, errTest
+				},
+			},
+			ctx:   replayCtx,
+			event: newEvent(v1ID, "2024-01-03 00:00:00+00"),
+
+			wantErr: errTest,
+		},
+		{
+			name:  "error - incoercible value",
+			store: testStore,
+			ctx:   replayCtx,
+			event: func() *wal.Event {
+				event := newEvent(v1ID, "2024-01-03 00:00:00+00")
+				event.Data.Columns[1].Value = "a"
+				return event
+			}(),
+
+			wantErr: errIncoercibleValue,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var processed *wal.Event
+			a := New(tc.store, &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					processed = walEvent
+					return nil
+				},
+			})
+
+			err := a.ProcessWALEvent(tc.ctx, tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				require.Nil(t, processed)
+				return
+			}
+			require.Equal(t, tc.wantColumns, processed.Data.Columns)
+		})
+	}
+}
+
+func TestColumnTypeEvolutionAdapter_loadHistory(t *testing.T) {
+	t.Parallel()
+
+	entries := []*schemalog.LogEntry{}
+	for i := 1; i <= 3; i++ {
+		entries = append(entries, &schemalog.LogEntry{ID: xid.New(), Version: int64(i), SchemaName: fmt.Sprintf("v%d", i)})
+	}
+	lastVersion := 2
+	store := &schemalogmocks.Store{
+		FetchSinceFn: func(ctx context.Context, schemaName string, version int) ([]*schemalog.LogEntry, error) {
+			return entries[version:lastVersion], nil
+		},
+	}
+
+	a := New(store, &mocks.Processor{})
+	require.NoError(t, a.loadHistory(context.Background(), "public"))
+	require.Equal(t, entries[:2], a.histories["public"].entries)
+	require.Equal(t, uint64(1), store.GetFetchCalls())
+
+	// only the new versions are fetched on reload, with a single query
+	lastVersion = 3
+	require.NoError(t, a.loadHistory(context.Background(), "public"))
+	require.Equal(t, entries, a.histories["public"].entries)
+	require.Equal(t, uint64(2), store.GetFetchCalls())
+	require.Len(t, a.histories["public"].byID, 3)
+
+	// versions added by a concurrent load are skipped
+	a.histories["public"].add(entries[1:], time.Now())
+	require.Equal(t, entries, a.histories["public"].entries)
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"sync"
+)
+
+// TypeConverter converts the column values of a postgres data type. It
+// receives the value as decoded from the wal event, and returns the value that
+// will be sent downstream.
+type TypeConverter interface {
+	Convert(value any) (any, error)
+}
+
+// TypeConverterFunc is an adapter to use ordinary functions as type
+// converters.
+type TypeConverterFunc func(value any) (any, error)
+
+func (f TypeConverterFunc) Convert(value any) (any, error) {
+	return f(value)
+}
+
+// Registry keeps the custom type converters registered for the postgres data
+// types the built in conversions don't handle, such as enum or composite
+// types. It's safe for concurrent use, so converters can be registered and
+// unregistered while the pipeline is running. Each pipeline is expected to
+// have its own registry.
+type Registry struct {
+	mutex      sync.RWMutex
+	converters map[string]TypeConverter
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		converters: map[string]TypeConverter{},
+	}
+}
+
+// Register sets the converter for the postgres data type name on input,
+// replacing any converter previously registered for it. The type name must
+// match the one in the wal events, i.e. `mood` or `character varying(255)`.
+func (r *Registry) Register(pgTypeName string, converter TypeConverter) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.converters[pgTypeName] = converter
+}
+
+// Unregister removes the converter for the postgres data type name on input,
+// if any.
+func (r *Registry) Unregister(pgTypeName string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.converters, pgTypeName)
+}
+
+// Converter returns the converter registered for the postgres data type name
+// on input, and false if there's none.
+func (r *Registry) Converter(pgTypeName string) (TypeConverter, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	converter, found := r.converters[pgTypeName]
+	return converter, found
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package types
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	_, found := registry.Converter("mood")
+	require.False(t, found)
+
+	registry.Register("mood", TypeConverterFunc(func(value any) (any, error) {
+		return strings.ToUpper(value.(string)), nil
+	}))
+	converter, found := registry.Converter("mood")
+	require.True(t, found)
+	got, err := converter.Convert("happy")
+	require.NoError(t, err)
+	require.Equal(t, "HAPPY", got)
+
+	// registering a converter for the same type replaces the previous one
+	registry.Register("mood", TypeConverterFunc(func(value any) (any, error) {
+		return strings.ToLower(value.(string)), nil
+	}))
+	converter, found = registry.Converter("mood")
+	require.True(t, found)
+	got, err = converter.Convert("HAPPY")
+	require.NoError(t, err)
+	require.Equal(t, "happy", got)
+
+	registry.Unregister("mood")
+	_, found = registry.Converter("mood")
+	require.False(t, found)
+
+	// unregistering a type without converter is a noop
+	registry.Unregister("mood")
+}
+
+func TestRegistry_concurrency(t *testing.T) {
+	t.Parallel()
+
+	registry := NewRegistry()
+	identity := TypeConverterFunc(func(value any) (any, error) { return value, nil })
+
+	wg := &sync.WaitGroup{}
+	for i := range 10 {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			typeName := fmt.Sprintf("type_%d", i%3)
+			for range 100 {
+				registry.Register(typeName, identity)
+				registry.Converter(typeName)
+				registry.Unregister(typeName)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range 3 {
+		_, found := registry.Converter(fmt.Sprintf("type_%d", i))
+		require.False(t, found)
+	}
+}
@@ -0,0 +1,1070 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// checkExpression is a compiled postgres CHECK constraint expression, as
+// returned by pg_get_constraintdef. Only a subset of the postgres expressions
+// is supported: comparisons, boolean operators, IS [NOT] NULL, ANY/ALL arrays,
+// regular expression and LIKE matches, arithmetic, casts and a few string
+// functions. The expressions are evaluated with the SQL three-valued logic,
+// where a null result doesn't violate the constraint.
+type checkExpression struct {
+	root exprNode
+}
+
+// exprNode is a node of the expression tree. The evaluation returns nil for
+// null or unknown values, float64 for numbers, string, bool or []any for
+// arrays.
+type exprNode interface {
+	eval(row map[string]any) (any, error)
+}
+
+var (
+	errUnsupportedExpression = errors.New("unsupported check constraint expression")
+	errUnsupportedValue      = errors.New("unsupported check constraint value")
+)
+
+// compileCheckExpression parses the CHECK constraint expression on input. It
+// returns errUnsupportedExpression if the expression uses syntax not supported
+// by the evaluator.
+func compileCheckExpression(expression string) (*checkExpression, error) {
+	tokens, err := tokenizeExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("%w: unexpected %q", errUnsupportedExpression, p.peek().text)
+	}
+	return &checkExpression{root: root}, nil
+}
+
+// violatedBy returns true if the row on input definitely violates the
+// expression. Rows with missing columns or values that can't be evaluated are
+// not considered violations.
+func (e *checkExpression) violatedBy(row map[string]any) bool {
+	result, err := e.root.eval(row)
+	if err != nil {
+		return false
+	}
+	b, ok := result.(bool)
+	return ok && !b
+}
+
+// Tokenizer
+
+type tokenKind int
+
+const (
+	identToken tokenKind = iota
+	quotedIdentToken
+	numberToken
+	stringToken
+	operatorToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+const operatorChars = "=<>!~*+-/%|"
+
+func tokenizeExpression(expression string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']' || c == ',':
+			tokens = append(tokens, token{kind: operatorToken, text: string(c)})
+			i++
+		case c == ':' && i+1 < len(expression) && expression[i+1] == ':':
+			tokens = append(tokens, token{kind: operatorToken, text: "::"})
+			i += 2
+		case c == '\'':
+			s, n, err := readQuoted(expression[i:], '\'')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: stringToken, text: s})
+			i += n
+		case c == '"':
+			s, n, err := readQuoted(expression[i:], '"')
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: quotedIdentToken, text: s})
+			i += n
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(expression) && (isDigit(expression[j]) || expression[j] == '.' ||
+				((expression[j] == 'e' || expression[j] == 'E') && j+1 < len(expression) && (isDigit(expression[j+1]) || expression[j+1] == '-'))) {
+				if expression[j] == 'e' || expression[j] == 'E' {
+					j++
+				}
+				j++
+			}
+			tokens = append(tokens, token{kind: numberToken, text: expression[i:j]})
+			i = j
+		case strings.IndexByte(operatorChars, c) >= 0:
+			j := i
+			for j < len(expression) && strings.IndexByte(operatorChars, expression[j]) >= 0 {
+				j++
+			}
+			tokens = append(tokens, token{kind: operatorToken, text: expression[i:j]})
+			i = j
+		default:
+			r, _ := utf8.DecodeRuneInString(expression[i:])
+			if !unicode.IsLetter(r) && r != '_' {
+				return nil, fmt.Errorf("%w: unexpected character %q", errUnsupportedExpression, r)
+			}
+			j := i
+			for j < len(expression) {
+				r, size := utf8.DecodeRuneInString(expression[j:])
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '$' {
+					break
+				}
+				j += size
+			}
+			tokens = append(tokens, token{kind: identToken, text: expression[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+// readQuoted returns the unescaped content of the quoted string at the start
+// of the input, and the number of bytes read.
+func readQuoted(s string, quote byte) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != quote {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == quote {
+			b.WriteByte(quote)
+			i++
+			continue
+		}
+		return b.String(), i + 1, nil
+	}
+	return "", 0, fmt.Errorf("%w: unterminated quoted string", errUnsupportedExpression)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Parser
+
+type exprParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *exprParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *exprParser) peek() token {
+	if p.done() {
+		return token{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// peekKeyword returns true if the next token is the keyword on input.
+func (p *exprParser) peekKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == identToken && strings.EqualFold(t.text, keyword)
+}
+
+func (p *exprParser) peekOperator(op string) bool {
+	t := p.peek()
+	return t.kind == operatorToken && t.text == op
+}
+
+func (p *exprParser) expectOperator(op string) error {
+	if !p.peekOperator(op) {
+		return fmt.Errorf("%w: expected %q", errUnsupportedExpression, op)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekKeyword("AND") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.peekKeyword("NOT") {
+		p.pos++
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+var comparisonOperators = map[string]struct{}{
+	"=": {}, "<>": {}, "!=": {}, "<": {}, "<=": {}, ">": {}, ">=": {},
+	"~": {}, "~*": {}, "!~": {}, "!~*": {}, "~~": {}, "~~*": {}, "!~~": {}, "!~~*": {},
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peekKeyword("IS") {
+		p.pos++
+		negated := false
+		if p.peekKeyword("NOT") {
+			p.pos++
+			negated = true
+		}
+		if !p.peekKeyword("NULL") {
+			return nil, fmt.Errorf("%w: unsupported IS clause", errUnsupportedExpression)
+		}
+		p.pos++
+		return &isNullNode{operand: left, negated: negated}, nil
+	}
+
+	t := p.peek()
+	if t.kind != operatorToken {
+		return left, nil
+	}
+	if _, found := comparisonOperators[t.text]; !found {
+		return left, nil
+	}
+	p.pos++
+
+	// op ANY (array) / op ALL (array)
+	if p.peekKeyword("ANY") || p.peekKeyword("ALL") {
+		all := p.peekKeyword("ALL")
+		p.pos++
+		if err := p.expectOperator("("); err != nil {
+			return nil, err
+		}
+		array, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOperator(")"); err != nil {
+			return nil, err
+		}
+		return &arrayComparisonNode{op: t.text, all: all, left: left, array: array}, nil
+	}
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return &comparisonNode{op: t.text, left: left, right: right}, nil
+}
+
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOperator("+") || p.peekOperator("-") || p.peekOperator("||") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOperator("*") || p.peekOperator("/") || p.peekOperator("%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithmeticNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.peekOperator("-") {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &arithmeticNode{op: "*", left: &literalNode{value: float64(-1)}, right: operand}, nil
+	}
+	return p.parseCast()
+}
+
+func (p *exprParser) parseCast() (exprNode, error) {
+	operand, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekOperator("::") {
+		p.pos++
+		typeName, err := p.parseTypeName()
+		if err != nil {
+			return nil, err
+		}
+		operand, err = newCastNode(operand, typeName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return operand, nil
+}
+
+// typeNameStopWords are the keywords that can follow a type name in a cast,
+// which are not part of the type name.
+var typeNameStopWords = map[string]struct{}{
+	"and": {}, "or": {}, "is": {}, "not": {}, "any": {}, "all": {},
+}
+
+// parseTypeName returns the (possibly multi word) type name of a cast, i.e.
+// `character varying` or `text[]`.
+func (p *exprParser) parseTypeName() (string, error) {
+	words := []string{}
+	for p.peek().kind == identToken || p.peek().kind == quotedIdentToken {
+		if _, found := typeNameStopWords[strings.ToLower(p.peek().text)]; found {
+			break
+		}
+		words = append(words, strings.ToLower(p.next().text))
+	}
+	if len(words) == 0 {
+		return "", fmt.Errorf("%w: missing cast type", errUnsupportedExpression)
+	}
+	// type modifiers, i.e. numeric(10,2), don't change the evaluation
+	if p.peekOperator("(") {
+		for !p.done() && !p.peekOperator(")") {
+			p.pos++
+		}
+		if err := p.expectOperator(")"); err != nil {
+			return "", err
+		}
+	}
+	typeName := strings.Join(words, " ")
+	for p.peekOperator("[") {
+		p.pos++
+		if err := p.expectOperator("]"); err != nil {
+			return "", err
+		}
+		typeName += "[]"
+	}
+	return typeName, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case numberToken:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid number %q", errUnsupportedExpression, t.text)
+		}
+		return &literalNode{value: f}, nil
+	case stringToken:
+		return &literalNode{value: t.text}, nil
+	case quotedIdentToken:
+		return &columnNode{name: t.text}, nil
+	case operatorToken:
+		if t.text != "(" {
+			return nil, fmt.Errorf("%w: unexpected %q", errUnsupportedExpression, t.text)
+		}
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectOperator(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case identToken:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		case "array":
+			return p.parseArray()
+		}
+		if p.peekOperator("(") {
+			return p.parseFunction(strings.ToLower(t.text))
+		}
+		// unquoted identifiers are folded to lower case by postgres
+		return &columnNode{name: strings.ToLower(t.text)}, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected end of expression", errUnsupportedExpression)
+	}
+}
+
+func (p *exprParser) parseArray() (exprNode, error) {
+	if err := p.expectOperator("["); err != nil {
+		return nil, err
+	}
+	elements := []exprNode{}
+	for !p.peekOperator("]") {
+		element, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+		if !p.peekOperator(",") {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expectOperator("]"); err != nil {
+		return nil, err
+	}
+	return &arrayNode{elements: elements}, nil
+}
+
+var supportedFunctions = map[string]struct{}{
+	"length": {}, "char_length": {}, "character_length": {}, "octet_length": {},
+	"lower": {}, "upper": {}, "btrim": {}, "abs": {},
+}
+
+func (p *exprParser) parseFunction(name string) (exprNode, error) {
+	if _, found := supportedFunctions[name]; !found {
+		return nil, fmt.Errorf("%w: unsupported function %q", errUnsupportedExpression, name)
+	}
+	if err := p.expectOperator("("); err != nil {
+		return nil, err
+	}
+	arg, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectOperator(")"); err != nil {
+		return nil, err
+	}
+	return &functionNode{name: name, arg: arg}, nil
+}
+
+// Nodes
+
+type literalNode struct {
+	value any
+}
+
+func (n *literalNode) eval(map[string]any) (any, error) {
+	return n.value, nil
+}
+
+type columnNode struct {
+	name string
+}
+
+func (n *columnNode) eval(row map[string]any) (any, error) {
+	// missing columns (i.e. unchanged TOAST values) are unknown
+	value, found := row[n.name]
+	if !found {
+		return nil, nil
+	}
+	return normalizeCheckValue(value)
+}
+
+type arrayNode struct {
+	elements []exprNode
+}
+
+func (n *arrayNode) eval(row map[string]any) (any, error) {
+	values := make([]any, 0, len(n.elements))
+	for _, e := range n.elements {
+		v, err := e.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+type castNode struct {
+	operand exprNode
+	kind    castKind
+}
+
+type castKind int
+
+const (
+	castNumeric castKind = iota
+	castText
+	castBoolean
+	castArray
+)
+
+func newCastNode(operand exprNode, typeName string) (exprNode, error) {
+	if strings.HasSuffix(typeName, "[]") {
+		return &castNode{operand: operand, kind: castArray}, nil
+	}
+	switch typeName {
+	case "smallint", "integer", "bigint", "int", "int2", "int4", "int8", "numeric", "decimal", "real", "double precision", "float4", "float8":
+		return &castNode{operand: operand, kind: castNumeric}, nil
+	case "text", "character varying", "varchar", "character", "bpchar", "char", "citext", "name":
+		return &castNode{operand: operand, kind: castText}, nil
+	case "boolean", "bool":
+		return &castNode{operand: operand, kind: castBoolean}, nil
+	default:
+		// temporal and other types can't be compared reliably
+		return nil, fmt.Errorf("%w: unsupported cast to %q", errUnsupportedExpression, typeName)
+	}
+}
+
+func (n *castNode) eval(row map[string]any) (any, error) {
+	v, err := n.operand.eval(row)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	switch n.kind {
+	case castNumeric:
+		return toNumber(v)
+	case castText:
+		switch val := v.(type) {
+		case string:
+			return val, nil
+		case float64:
+			return strconv.FormatFloat(val, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(val), nil
+		}
+	case castBoolean:
+		switch val := v.(type) {
+		case bool:
+			return val, nil
+		case string:
+			return strconv.ParseBool(val)
+		}
+	case castArray:
+		return v, nil
+	}
+	return nil, errUnsupportedValue
+}
+
+type logicalNode struct {
+	and         bool
+	left, right exprNode
+}
+
+func (n *logicalNode) eval(row map[string]any) (any, error) {
+	left, err := evalBool(n.left, row)
+	if err != nil {
+		return nil, err
+	}
+	right, err := evalBool(n.right, row)
+	if err != nil {
+		return nil, err
+	}
+	// three-valued logic, where nil is unknown
+	if n.and {
+		if isFalse(left) || isFalse(right) {
+			return false, nil
+		}
+		if left == nil || right == nil {
+			return nil, nil
+		}
+		return true, nil
+	}
+	if isTrue(left) || isTrue(right) {
+		return true, nil
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+	return false, nil
+}
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n *notNode) eval(row map[string]any) (any, error) {
+	v, err := evalBool(n.operand, row)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	return !v.(bool), nil
+}
+
+type isNullNode struct {
+	operand exprNode
+	negated bool
+}
+
+func (n *isNullNode) eval(row map[string]any) (any, error) {
+	if col, ok := n.operand.(*columnNode); ok {
+		// a missing column is unknown rather than null
+		if _, found := row[col.name]; !found {
+			return nil, nil
+		}
+	}
+	v, err := n.operand.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	return (v == nil) != n.negated, nil
+}
+
+type comparisonNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *comparisonNode) eval(row map[string]any) (any, error) {
+	left, err := n.left.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	return compareValues(n.op, left, right)
+}
+
+type arrayComparisonNode struct {
+	op          string
+	all         bool
+	left, array exprNode
+}
+
+func (n *arrayComparisonNode) eval(row map[string]any) (any, error) {
+	left, err := n.left.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	arrayValue, err := n.array.eval(row)
+	if err != nil || arrayValue == nil {
+		return nil, err
+	}
+	elements, ok := arrayValue.([]any)
+	if !ok {
+		return nil, errUnsupportedValue
+	}
+
+	unknown := false
+	for _, element := range elements {
+		result, err := compareValues(n.op, left, element)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case result == nil:
+			unknown = true
+		case n.all && isFalse(result):
+			return false, nil
+		case !n.all && isTrue(result):
+			return true, nil
+		}
+	}
+	if unknown {
+		return nil, nil
+	}
+	return n.all, nil
+}
+
+type arithmeticNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *arithmeticNode) eval(row map[string]any) (any, error) {
+	left, err := n.left.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(row)
+	if err != nil {
+		return nil, err
+	}
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	if n.op == "||" {
+		l, lok := left.(string)
+		r, rok := right.(string)
+		if !lok || !rok {
+			return nil, errUnsupportedValue
+		}
+		return l + r, nil
+	}
+
+	l, err := toNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	r, err := toNumber(right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, errUnsupportedValue
+		}
+		return l / r, nil
+	default:
+		// the integer modulo can't be evaluated reliably on floats
+		return nil, errUnsupportedValue
+	}
+}
+
+type functionNode struct {
+	name string
+	arg  exprNode
+}
+
+func (n *functionNode) eval(row map[string]any) (any, error) {
+	v, err := n.arg.eval(row)
+	if err != nil || v == nil {
+		return nil, err
+	}
+
+	if n.name == "abs" {
+		f, err := toNumber(v)
+		if err != nil {
+			return nil, err
+		}
+		if f < 0 {
+			return -f, nil
+		}
+		return f, nil
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return nil, errUnsupportedValue
+	}
+	switch n.name {
+	case "length", "char_length", "character_length":
+		return float64(utf8.RuneCountInString(s)), nil
+	case "octet_length":
+		return float64(len(s)), nil
+	case "lower":
+		return strings.ToLower(s), nil
+	case "upper":
+		return strings.ToUpper(s), nil
+	default: // btrim
+		return strings.Trim(s, " "), nil
+	}
+}
+
+// Evaluation helpers
+
+func evalBool(n exprNode, row map[string]any) (any, error) {
+	v, err := n.eval(row)
+	if err != nil || v == nil {
+		return nil, err
+	}
+	if _, ok := v.(bool); !ok {
+		return nil, errUnsupportedValue
+	}
+	return v, nil
+}
+
+func isTrue(v any) bool {
+	b, ok := v.(bool)
+	return ok && b
+}
+
+func isFalse(v any) bool {
+	b, ok := v.(bool)
+	return ok && !b
+}
+
+func compareValues(op string, left, right any) (any, error) {
+	if left == nil || right == nil {
+		return nil, nil
+	}
+
+	switch op {
+	case "~", "~*", "!~", "!~*":
+		return matchRegexp(op, left, right)
+	case "~~", "~~*", "!~~", "!~~*":
+		return matchLike(op, left, right)
+	}
+
+	cmp, err := compareOrdered(left, right)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "=":
+		return cmp == 0, nil
+	case "<>", "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+// compareOrdered compares numbers numerically, and strings and booleans by
+// their value. Strings are compared byte-wise, which matches the C collation.
+func compareOrdered(left, right any) (int, error) {
+	switch l := left.(type) {
+	case float64:
+		r, err := toNumber(right)
+		if err != nil {
+			return 0, err
+		}
+		return compareNumbers(l, r), nil
+	case string:
+		switch r := right.(type) {
+		case string:
+			return strings.Compare(l, r), nil
+		case float64:
+			lf, err := toNumber(l)
+			if err != nil {
+				return 0, err
+			}
+			return compareNumbers(lf, r), nil
+		}
+	case bool:
+		if r, ok := right.(bool); ok {
+			switch {
+			case l == r:
+				return 0, nil
+			case !l:
+				return -1, nil
+			default:
+				return 1, nil
+			}
+		}
+	}
+	return 0, errUnsupportedValue
+}
+
+func compareNumbers(l, r float64) int {
+	switch {
+	case l < r:
+		return -1
+	case l > r:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func matchRegexp(op string, left, right any) (any, error) {
+	s, sok := left.(string)
+	pattern, pok := right.(string)
+	if !sok || !pok {
+		return nil, errUnsupportedValue
+	}
+	if strings.HasSuffix(op, "*") {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errUnsupportedValue
+	}
+	return re.MatchString(s) != strings.HasPrefix(op, "!"), nil
+}
+
+// matchLike evaluates the LIKE (~~) and ILIKE (~~*) operators, by converting
+// the pattern into the equivalent regular expression.
+func matchLike(op string, left, right any) (any, error) {
+	s, sok := left.(string)
+	pattern, pok := right.(string)
+	if !sok || !pok {
+		return nil, errUnsupportedValue
+	}
+
+	var b strings.Builder
+	if strings.HasSuffix(op, "*") {
+		b.WriteString("(?i)")
+	}
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '%':
+			b.WriteString("(?s:.*)")
+		case '_':
+			b.WriteString("(?s:.)")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, errUnsupportedValue
+	}
+	return re.MatchString(s) != strings.HasPrefix(op, "!"), nil
+}
+
+func toNumber(v any) (float64, error) {
+	switch val := v.(type) {
+	case float64:
+		return val, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return 0, errUnsupportedValue
+		}
+		return f, nil
+	default:
+		return 0, errUnsupportedValue
+	}
+}
+
+// normalizeCheckValue converts the wal column value on input into the types
+// used by the expression evaluation. Values that can't be compared reliably,
+// such as timestamps, are returned as errUnsupportedValue.
+func normalizeCheckValue(value any) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string, bool, float64:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case pgtype.Numeric:
+		if !v.Valid {
+			return nil, nil
+		}
+		if v.NaN || v.InfinityModifier != pgtype.Finite || v.Int == nil {
+			return nil, errUnsupportedValue
+		}
+		f := new(big.Float).SetInt(v.Int)
+		if v.Exp != 0 {
+			exp := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(absInt32(v.Exp))), nil))
+			if v.Exp > 0 {
+				f.Mul(f, exp)
+			} else {
+				f.Quo(f, exp)
+			}
+		}
+		result, _ := f.Float64()
+		return result, nil
+	case []any:
+		values := make([]any, 0, len(v))
+		for _, e := range v {
+			nv, err := normalizeCheckValue(e)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, nv)
+		}
+		return values, nil
+	default:
+		return nil, errUnsupportedValue
+	}
+}
+
+func absInt32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
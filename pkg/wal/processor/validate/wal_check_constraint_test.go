@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileCheckExpression(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		expression string
+
+		wantErr error
+	}{
+		{name: "comparison", expression: "(price > (0)::numeric)"},
+		{name: "boolean operators", expression: "((price > 0) AND ((discount IS NULL) OR (discount < price)))"},
+		{name: "any array", expression: "((status)::text = ANY ((ARRAY['active'::character varying, 'inactive'::character varying])::text[]))"},
+		{name: "regex", expression: "((email)::text ~* '^[a-z]+@[a-z]+\\.[a-z]+$'::text)"},
+		{name: "function", expression: "(char_length((name)::text) <= 10)"},
+		{name: "quoted identifier", expression: `("Price" >= 0)`},
+		{name: "unsupported function", expression: "(now() > created_at)", wantErr: errUnsupportedExpression},
+		{name: "unsupported cast", expression: "(created_at > '2020-01-01'::date)", wantErr: errUnsupportedExpression},
+		{name: "unsupported is clause", expression: "(active IS TRUE)", wantErr: errUnsupportedExpression},
+		{name: "unterminated string", expression: "(name <> 'abc)", wantErr: errUnsupportedExpression},
+		{name: "unbalanced parentheses", expression: "((price > 0)", wantErr: errUnsupportedExpression},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := compileCheckExpression(tc.expression)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestCheckExpression_violatedBy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		expression string
+		row        map[string]any
+
+		wantViolated bool
+	}{
+		{
+			name:         "numeric comparison satisfied",
+			expression:   "(price > (0)::numeric)",
+			row:          map[string]any{"price": 10.5},
+			wantViolated: false,
+		},
+		{
+			name:         "numeric comparison violated",
+			expression:   "(price > (0)::numeric)",
+			row:          map[string]any{"price": int64(-1)},
+			wantViolated: true,
+		},
+		{
+			name:         "pgtype numeric violated",
+			expression:   "(price >= 1)",
+			row:          map[string]any{"price": pgtype.Numeric{Int: big.NewInt(5), Exp: -1, Valid: true}},
+			wantViolated: true,
+		},
+		{
+			name:         "null value is not a violation",
+			expression:   "(price > 0)",
+			row:          map[string]any{"price": nil},
+			wantViolated: false,
+		},
+		{
+			name:         "missing column is not a violation",
+			expression:   "(price > 0)",
+			row:          map[string]any{},
+			wantViolated: false,
+		},
+		{
+			name:         "is not null violated",
+			expression:   "(price IS NOT NULL)",
+			row:          map[string]any{"price": nil},
+			wantViolated: true,
+		},
+		{
+			name:         "or with null operand satisfied",
+			expression:   "((discount IS NULL) OR (discount < price))",
+			row:          map[string]any{"discount": nil, "price": 10},
+			wantViolated: false,
+		},
+		{
+			name:         "and violated",
+			expression:   "((price > 0) AND (discount < price))",
+			row:          map[string]any{"discount": 20, "price": 10},
+			wantViolated: true,
+		},
+		{
+			name:         "not violated",
+			expression:   "(NOT (price = 0))",
+			row:          map[string]any{"price": 0},
+			wantViolated: true,
+		},
+		{
+			name:         "arithmetic",
+			expression:   "((price - discount) >= (0)::numeric)",
+			row:          map[string]any{"discount": 20.0, "price": 10.0},
+			wantViolated: true,
+		},
+		{
+			name:         "any array satisfied",
+			expression:   "((status)::text = ANY ((ARRAY['active'::character varying, 'inactive'::character varying])::text[]))",
+			row:          map[string]any{"status": "active"},
+			wantViolated: false,
+		},
+		{
+			name:         "any array violated",
+			expression:   "((status)::text = ANY ((ARRAY['active'::character varying, 'inactive'::character varying])::text[]))",
+			row:          map[string]any{"status": "deleted"},
+			wantViolated: true,
+		},
+		{
+			name:         "all array violated",
+			expression:   "(code <> ALL (ARRAY[1, 2]))",
+			row:          map[string]any{"code": 2},
+			wantViolated: true,
+		},
+		{
+			name:         "regex satisfied",
+			expression:   "((email)::text ~* '^[a-z]+@[a-z]+\\.[a-z]+$'::text)",
+			row:          map[string]any{"email": "Alice@Example.com"},
+			wantViolated: false,
+		},
+		{
+			name:         "regex violated",
+			expression:   "((email)::text ~ '@'::text)",
+			row:          map[string]any{"email": "alice"},
+			wantViolated: true,
+		},
+		{
+			name:         "like violated",
+			expression:   "((code)::text ~~ 'AB_%'::text)",
+			row:          map[string]any{"code": "AC12"},
+			wantViolated: true,
+		},
+		{
+			name:         "length violated",
+			expression:   "(char_length((name)::text) <= 3)",
+			row:          map[string]any{"name": "alice"},
+			wantViolated: true,
+		},
+		{
+			name:         "quoted identifier violated",
+			expression:   `("Price" >= 0)`,
+			row:          map[string]any{"Price": -1},
+			wantViolated: true,
+		},
+		{
+			name:         "boolean column",
+			expression:   "(active = true)",
+			row:          map[string]any{"active": false},
+			wantViolated: true,
+		},
+		{
+			name:         "unsupported value type is not a violation",
+			expression:   "(created_at > 0)",
+			row:          map[string]any{"created_at": time.Now()},
+			wantViolated: false,
+		},
+		{
+			name:         "mismatched types are not a violation",
+			expression:   "(price > 0)",
+			row:          map[string]any{"price": "not a number"},
+			wantViolated: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			expr, err := compileCheckExpression(tc.expression)
+			require.NoError(t, err)
+			require.Equal(t, tc.wantViolated, expr.violatedBy(tc.row))
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/xataio/pgstream/internal/json"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// DeadLetterQueue receives the wal events that can't be processed, along with
+// the error describing why.
+type DeadLetterQueue interface {
+	Send(ctx context.Context, event *wal.Event, reason error) error
+	Close() error
+}
+
+// FileDeadLetterQueue writes the wal events on input to a file, one JSON
+// encoded record per line.
+type FileDeadLetterQueue struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+type deadLetterRecord struct {
+	Error          string             `json:"error"`
+	CommitPosition wal.CommitPosition `json:"commit_position"`
+	Data           *wal.Data          `json:"data"`
+}
+
+// NewFileDeadLetterQueue returns a dead letter queue that appends the events
+// to the file on input, creating it if it doesn't exist.
+func NewFileDeadLetterQueue(path string) (*FileDeadLetterQueue, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening dead letter file: %w", err)
+	}
+	return &FileDeadLetterQueue{
+		file: file,
+	}, nil
+}
+
+func (q *FileDeadLetterQueue) Send(_ context.Context, event *wal.Event, reason error) error {
+	record, err := json.Marshal(&deadLetterRecord{
+		Error:          reason.Error(),
+		CommitPosition: event.CommitPosition,
+		Data:           event.Data,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling dead letter record: %w", err)
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if _, err := q.file.Write(append(record, '\n')); err != nil {
+		return fmt.Errorf("writing dead letter record: %w", err)
+	}
+	return nil
+}
+
+func (q *FileDeadLetterQueue) Close() error {
+	return q.file.Close()
+}
+
+// logDeadLetterQueue logs the events on input and drops them. Used when no
+// dead letter queue is configured.
+type logDeadLetterQueue struct {
+	logger loglib.Logger
+}
+
+func (q *logDeadLetterQueue) Send(_ context.Context, event *wal.Event, reason error) error {
+	fields := loglib.Fields{
+		"commit_position": event.CommitPosition,
+	}
+	if event.Data != nil {
+		fields["schema"] = event.Data.Schema
+		fields["table"] = event.Data.Table
+		fields["lsn"] = event.Data.LSN
+	}
+	q.logger.Error(reason, "skipping wal event", fields)
+	return nil
+}
+
+func (q *logDeadLetterQueue) Close() error {
+	return nil
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/internal/json"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestFileDeadLetterQueue_Send(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "dead_letter.jsonl")
+	dlq, err := NewFileDeadLetterQueue(path)
+	require.NoError(t, err)
+
+	testEvent := func(id int) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action: "I",
+				Schema: "public",
+				Table:  "users",
+				Columns: []wal.Column{
+					{Name: "id", Type: "integer", Value: float64(id)},
+				},
+			},
+			CommitPosition: wal.CommitPosition("0/1"),
+		}
+	}
+
+	require.NoError(t, dlq.Send(context.Background(), testEvent(1), errors.New("invalid 1")))
+	require.NoError(t, dlq.Send(context.Background(), testEvent(2), errors.New("invalid 2")))
+	require.NoError(t, dlq.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 2)
+
+	for i, line := range lines {
+		record := deadLetterRecord{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		require.Equal(t, deadLetterRecord{
+			Error:          fmt.Sprintf("invalid %d", i+1),
+			CommitPosition: wal.CommitPosition("0/1"),
+			Data:           testEvent(i + 1).Data,
+		}, record)
+	}
+}
@@ -0,0 +1,331 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	// register the http(s) loader to support JSON Schemas provided via URL
+	_ "github.com/santhosh-tekuri/jsonschema/v5/httploader"
+	"github.com/xataio/pgstream/internal/json"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	schemalogpg "github.com/xataio/pgstream/pkg/schemalog/postgres"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// SchemaValidatingProcessor is a decorator around a wal processor that
+// validates the wal event columns against the JSON Schema configured for their
+// table. Events that fail validation are not forwarded to the wrapped
+// processor, and are sent to the dead letter queue instead. It can also warn
+// about the events that violate the CHECK constraints of their source table,
+// without interrupting their processing.
+type SchemaValidatingProcessor struct {
+	logger          loglib.Logger
+	processor       processor.Processor
+	schemas         map[string]*jsonschema.Schema
+	deadLetterQueue DeadLetterQueue
+
+	schemaLogStore       schemalog.Store
+	walToLogEntryAdapter func(*wal.Data) (*schemalog.LogEntry, error)
+	// compiled CHECK constraints per schema and table
+	checkConstraints map[string]map[string][]checkConstraint
+}
+
+type checkConstraint struct {
+	name       string
+	expression string
+	compiled   *checkExpression
+}
+
+type Config struct {
+	// TableSchemas maps the table names to the location of the JSON Schema
+	// used to validate their events. Tables should be schema qualified. If no
+	// schema is provided, the public schema will be assumed. The location can
+	// be a file path or a http(s) URL.
+	TableSchemas map[string]string
+	// DeadLetterFile is the path to the file where the events that fail the
+	// validation will be written to. If not provided, the events will be
+	// logged and skipped.
+	DeadLetterFile string
+	// CheckConstraintsStore is the schema log store used to retrieve the CHECK
+	// constraints of the source tables. If provided, a warning will be logged
+	// for the events that violate them. The events are still processed.
+	CheckConstraintsStore *schemalogpg.Config
+}
+
+type Option func(v *SchemaValidatingProcessor)
+
+var (
+	ErrSchemaValidation = errors.New("wal event failed schema validation")
+
+	errMissingTableSchemas = errors.New("missing table JSON schemas or check constraints configuration")
+)
+
+const publicSchema = "public"
+
+// New will return a schema validating processor wrapper that will validate
+// the wal events for the configured tables before passing them over to the
+// processor on input.
+func New(cfg *Config, p processor.Processor, opts ...Option) (*SchemaValidatingProcessor, error) {
+	if len(cfg.TableSchemas) == 0 && cfg.CheckConstraintsStore == nil {
+		return nil, errMissingTableSchemas
+	}
+
+	v := &SchemaValidatingProcessor{
+		logger:               loglib.NewNoopLogger(),
+		processor:            p,
+		schemas:              make(map[string]*jsonschema.Schema, len(cfg.TableSchemas)),
+		deadLetterQueue:      &logDeadLetterQueue{logger: loglib.NewNoopLogger()},
+		walToLogEntryAdapter: processor.WalDataToLogEntry,
+		checkConstraints:     map[string]map[string][]checkConstraint{},
+	}
+
+	compiler := jsonschema.NewCompiler()
+	for table, location := range cfg.TableSchemas {
+		schema, err := compiler.Compile(location)
+		if err != nil {
+			return nil, fmt.Errorf("compiling JSON schema %s for table %s: %w", location, table, err)
+		}
+		v.schemas[qualifiedTableName(table)] = schema
+	}
+
+	if cfg.DeadLetterFile != "" {
+		dlq, err := NewFileDeadLetterQueue(cfg.DeadLetterFile)
+		if err != nil {
+			return nil, err
+		}
+		v.deadLetterQueue = dlq
+	}
+
+	if cfg.CheckConstraintsStore != nil {
+		store, err := schemalogpg.NewStore(context.Background(), *cfg.CheckConstraintsStore)
+		if err != nil {
+			return nil, fmt.Errorf("create schema log postgres store: %w", err)
+		}
+		v.schemaLogStore = store
+	}
+
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v, nil
+}
+
+func WithLogger(l loglib.Logger) Option {
+	return func(v *SchemaValidatingProcessor) {
+		v.logger = loglib.NewLogger(l).WithFields(loglib.Fields{
+			loglib.ModuleField: "wal_schema_validator",
+		})
+		if dlq, ok := v.deadLetterQueue.(*logDeadLetterQueue); ok {
+			dlq.logger = v.logger
+		}
+	}
+}
+
+// WithDeadLetterQueue sets the dead letter queue where the events that fail
+// validation will be sent to.
+func WithDeadLetterQueue(dlq DeadLetterQueue) Option {
+	return func(v *SchemaValidatingProcessor) {
+		v.deadLetterQueue = dlq
+	}
+}
+
+func (v *SchemaValidatingProcessor) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	if v.schemaLogStore != nil {
+		v.checkConstraintViolations(ctx, event)
+	}
+
+	if err := v.validate(event); err != nil {
+		if err := v.deadLetterQueue.Send(ctx, event, err); err != nil {
+			return fmt.Errorf("sending wal event to dead letter queue: %w", err)
+		}
+		return nil
+	}
+
+	return v.processor.ProcessWALEvent(ctx, event)
+}
+
+func (v *SchemaValidatingProcessor) Name() string {
+	return v.processor.Name()
+}
+
+func (v *SchemaValidatingProcessor) Close() error {
+	errs := []error{v.deadLetterQueue.Close(), v.processor.Close()}
+	if v.schemaLogStore != nil {
+		errs = append(errs, v.schemaLogStore.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (v *SchemaValidatingProcessor) Ping(ctx context.Context) error {
+	return v.processor.Ping(ctx)
+}
+
+// validate returns an error describing the validation failure if the event
+// columns don't match the JSON schema of their table. Only insert and update
+// events are validated, since they're the only ones with the row values.
+func (v *SchemaValidatingProcessor) validate(event *wal.Event) error {
+	if event.Data == nil || processor.IsSchemaLogEvent(event.Data) {
+		return nil
+	}
+	if !event.Data.IsInsert() && !event.Data.IsUpdate() {
+		return nil
+	}
+
+	schema, found := v.schemas[event.Data.Schema+"."+event.Data.Table]
+	if !found {
+		return nil
+	}
+
+	row, err := columnsToJSONValue(event.Data.Columns)
+	if err != nil {
+		return fmt.Errorf("%w: table %s.%s: %w", ErrSchemaValidation, event.Data.Schema, event.Data.Table, err)
+	}
+
+	if err := schema.Validate(row); err != nil {
+		var validationErr *jsonschema.ValidationError
+		if errors.As(err, &validationErr) {
+			// include all the validation failures in the error message
+			return fmt.Errorf("%w: table %s.%s: %#v", ErrSchemaValidation, event.Data.Schema, event.Data.Table, validationErr)
+		}
+		return fmt.Errorf("%w: table %s.%s: %w", ErrSchemaValidation, event.Data.Schema, event.Data.Table, err)
+	}
+
+	return nil
+}
+
+// checkConstraintViolations logs a warning for each CHECK constraint of the
+// source table violated by the event row values. Aside from the schema log
+// inserts, which refresh the cached constraints, the events are not modified.
+func (v *SchemaValidatingProcessor) checkConstraintViolations(ctx context.Context, event *wal.Event) {
+	if event.Data == nil {
+		return
+	}
+
+	if processor.IsSchemaLogEvent(event.Data) {
+		if !event.Data.IsInsert() {
+			return
+		}
+		logEntry, err := v.walToLogEntryAdapter(event.Data)
+		if err != nil {
+			v.logger.Warn(err, "parsing schema log event, check constraints not refreshed")
+			return
+		}
+		v.updateCheckConstraints(logEntry)
+		return
+	}
+
+	if !event.Data.IsInsert() && !event.Data.IsUpdate() {
+		return
+	}
+
+	constraints, err := v.getCheckConstraints(ctx, event.Data.Schema)
+	if err != nil {
+		v.logger.Warn(err, "retrieving check constraints", loglib.Fields{"schema": event.Data.Schema})
+		return
+	}
+
+	tableConstraints := constraints[event.Data.Table]
+	if len(tableConstraints) == 0 {
+		return
+	}
+
+	row := make(map[string]any, len(event.Data.Columns))
+	for _, col := range event.Data.Columns {
+		row[col.Name] = col.Value
+	}
+
+	for _, c := range tableConstraints {
+		if c.compiled.violatedBy(row) {
+			v.logger.Warn(nil, "wal event violates source check constraint", loglib.Fields{
+				"schema":     event.Data.Schema,
+				"table":      event.Data.Table,
+				"constraint": c.name,
+				"expression": c.expression,
+			})
+		}
+	}
+}
+
+// getCheckConstraints returns the compiled CHECK constraints of the schema
+// tables, retrieving them from the latest schema log entry if they're not
+// cached yet.
+func (v *SchemaValidatingProcessor) getCheckConstraints(ctx context.Context, schemaName string) (map[string][]checkConstraint, error) {
+	if constraints, found := v.checkConstraints[schemaName]; found {
+		return constraints, nil
+	}
+
+	logEntry, err := v.schemaLogStore.FetchLast(ctx, schemaName, false)
+	if err != nil {
+		if errors.Is(err, schemalog.ErrNoRows) {
+			v.checkConstraints[schemaName] = map[string][]checkConstraint{}
+			return v.checkConstraints[schemaName], nil
+		}
+		return nil, fmt.Errorf("fetching last schema log entry for schema %s: %w", schemaName, err)
+	}
+
+	return v.updateCheckConstraints(logEntry), nil
+}
+
+// updateCheckConstraints compiles and caches the CHECK constraints of the
+// schema log entry tables. Constraints with expressions not supported by the
+// evaluator are skipped.
+func (v *SchemaValidatingProcessor) updateCheckConstraints(logEntry *schemalog.LogEntry) map[string][]checkConstraint {
+	constraints := map[string][]checkConstraint{}
+	for _, table := range logEntry.Schema.Tables {
+		for _, c := range table.CheckConstraints() {
+			compiled, err := compileCheckExpression(c.Expression)
+			if err != nil {
+				v.logger.Debug("skipping unsupported check constraint", loglib.Fields{
+					"schema":     logEntry.SchemaName,
+					"table":      table.Name,
+					"constraint": c.Name,
+					"error":      err.Error(),
+				})
+				continue
+			}
+			constraints[table.Name] = append(constraints[table.Name], checkConstraint{
+				name:       c.Name,
+				expression: c.Expression,
+				compiled:   compiled,
+			})
+		}
+	}
+	v.checkConstraints[logEntry.SchemaName] = constraints
+	return constraints
+}
+
+// columnsToJSONValue returns the JSON representation of the columns on input,
+// as an object with the column names as keys, in the format expected by the
+// JSON schema validation.
+func columnsToJSONValue(columns []wal.Column) (any, error) {
+	row := make(map[string]any, len(columns))
+	for _, col := range columns {
+		row[col.Name] = col.Value
+	}
+
+	rowBytes, err := json.Marshal(row)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling columns to JSON: %w", err)
+	}
+
+	var value any
+	if err := json.Unmarshal(rowBytes, &value); err != nil {
+		return nil, fmt.Errorf("unmarshaling columns JSON: %w", err)
+	}
+	return value, nil
+}
+
+func qualifiedTableName(table string) string {
+	if !strings.Contains(table, ".") {
+		return publicSchema + "." + table
+	}
+	return table
+}
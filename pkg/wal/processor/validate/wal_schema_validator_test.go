@@ -0,0 +1,387 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+	schemalogmocks "github.com/xataio/pgstream/pkg/schemalog/mocks"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+const testJSONSchema = `{
+	"type": "object",
+	"required": ["id", "name"],
+	"properties": {
+		"id": {"type": "integer"},
+		"name": {"type": "string"},
+		"age": {"type": ["integer", "null"]}
+	}
+}`
+
+type mockDeadLetterQueue struct {
+	sendFn func(ctx context.Context, event *wal.Event, reason error) error
+}
+
+func (m *mockDeadLetterQueue) Send(ctx context.Context, event *wal.Event, reason error) error {
+	return m.sendFn(ctx, event, reason)
+}
+
+func (m *mockDeadLetterQueue) Close() error {
+	return nil
+}
+
+func writeTestSchema(t *testing.T, schema string) string {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	require.NoError(t, os.WriteFile(path, []byte(schema), 0o600))
+	return path
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		t.Parallel()
+
+		v, err := New(&Config{
+			TableSchemas: map[string]string{
+				"users":            writeTestSchema(t, testJSONSchema),
+				"other_schema.foo": writeTestSchema(t, testJSONSchema),
+			},
+		}, &mocks.Processor{})
+		require.NoError(t, err)
+		require.Contains(t, v.schemas, "public.users")
+		require.Contains(t, v.schemas, "other_schema.foo")
+	})
+
+	t.Run("error - missing table schemas", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := New(&Config{}, &mocks.Processor{})
+		require.ErrorIs(t, err, errMissingTableSchemas)
+	})
+
+	t.Run("error - invalid schema", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := New(&Config{
+			TableSchemas: map[string]string{
+				"users": writeTestSchema(t, `{"type": "invalid"}`),
+			},
+		}, &mocks.Processor{})
+		require.ErrorContains(t, err, "compiling JSON schema")
+	})
+
+	t.Run("error - schema not found", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := New(&Config{
+			TableSchemas: map[string]string{
+				"users": filepath.Join(t.TempDir(), "missing.json"),
+			},
+		}, &mocks.Processor{})
+		require.ErrorContains(t, err, "compiling JSON schema")
+	})
+}
+
+func TestSchemaValidatingProcessor_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	schemaPath := writeTestSchema(t, testJSONSchema)
+
+	newTestEvent := func(action, table string, columns []wal.Column) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action:  action,
+				Schema:  "public",
+				Table:   table,
+				Columns: columns,
+			},
+			CommitPosition: wal.CommitPosition("0/1"),
+		}
+	}
+
+	validColumns := []wal.Column{
+		{Name: "id", Type: "integer", Value: 1},
+		{Name: "name", Type: "text", Value: "alice"},
+		{Name: "age", Type: "integer", Value: nil},
+	}
+	invalidColumns := []wal.Column{
+		{Name: "id", Type: "integer", Value: "1"},
+		{Name: "age", Type: "integer", Value: 10},
+	}
+
+	tests := []struct {
+		name       string
+		event      *wal.Event
+		processErr error
+		dlqErr     error
+
+		wantProcessed bool
+		wantDLQErr    string
+		wantErr       error
+	}{
+		{
+			name:  "ok - valid insert",
+			event: newTestEvent("I", "users", validColumns),
+
+			wantProcessed: true,
+		},
+		{
+			name:  "ok - valid update",
+			event: newTestEvent("U", "users", validColumns),
+
+			wantProcessed: true,
+		},
+		{
+			name:  "ok - table without schema",
+			event: newTestEvent("I", "other", invalidColumns),
+
+			wantProcessed: true,
+		},
+		{
+			name:  "ok - delete event not validated",
+			event: newTestEvent("D", "users", nil),
+
+			wantProcessed: true,
+		},
+		{
+			name:  "ok - keep alive event",
+			event: &wal.Event{CommitPosition: wal.CommitPosition("0/1")},
+
+			wantProcessed: true,
+		},
+		{
+			name:  "ok - invalid insert sent to dead letter queue",
+			event: newTestEvent("I", "users", invalidColumns),
+
+			wantProcessed: false,
+			wantDLQErr:    "missing properties: 'name'",
+		},
+		{
+			name:       "error - processing event",
+			event:      newTestEvent("I", "users", validColumns),
+			processErr: errTest,
+
+			wantProcessed: true,
+			wantErr:       errTest,
+		},
+		{
+			name:   "error - sending to dead letter queue",
+			event:  newTestEvent("I", "users", invalidColumns),
+			dlqErr: errTest,
+
+			wantProcessed: false,
+			wantDLQErr:    "expected integer, but got string",
+			wantErr:       errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			processed := false
+			var dlqReason error
+			v, err := New(&Config{
+				TableSchemas: map[string]string{
+					"public.users": schemaPath,
+				},
+			}, &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+					require.Equal(t, tc.event, walEvent)
+					processed = true
+					return tc.processErr
+				},
+			}, WithDeadLetterQueue(&mockDeadLetterQueue{
+				sendFn: func(ctx context.Context, event *wal.Event, reason error) error {
+					require.Equal(t, tc.event, event)
+					dlqReason = reason
+					return tc.dlqErr
+				},
+			}))
+			require.NoError(t, err)
+
+			err = v.ProcessWALEvent(context.Background(), tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantProcessed, processed)
+			if tc.wantDLQErr == "" {
+				require.NoError(t, dlqReason)
+				return
+			}
+			require.ErrorIs(t, dlqReason, ErrSchemaValidation)
+			require.ErrorContains(t, dlqReason, "table public.users")
+			require.ErrorContains(t, dlqReason, tc.wantDLQErr)
+		})
+	}
+}
+
+type mockWarnLogger struct {
+	loglib.NoopLogger
+	mutex    sync.Mutex
+	warnings []loglib.Fields
+}
+
+func (m *mockWarnLogger) Warn(err error, msg string, fields ...loglib.Fields) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.warnings = append(m.warnings, fields...)
+}
+
+func TestSchemaValidatingProcessor_ProcessWALEvent_checkConstraints(t *testing.T) {
+	t.Parallel()
+
+	errTest := errors.New("oh noes")
+
+	testLogEntry := func(definition string) *schemalog.LogEntry {
+		return &schemalog.LogEntry{
+			SchemaName: "public",
+			Schema: schemalog.Schema{
+				Tables: []schemalog.Table{
+					{
+						Name: "products",
+						Constraints: []schemalog.Constraint{
+							{Name: "price_positive", Type: "CHECK", Definition: definition},
+							{Name: "unsupported", Type: "CHECK", Definition: "CHECK ((created_at < now()))"},
+							{Name: "products_pkey", Type: "PRIMARY KEY", Definition: "PRIMARY KEY (id)"},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	newTestEvent := func(action string, price any) *wal.Event {
+		return &wal.Event{
+			Data: &wal.Data{
+				Action: action,
+				Schema: "public",
+				Table:  "products",
+				Columns: []wal.Column{
+					{Name: "id", Type: "integer", Value: 1},
+					{Name: "price", Type: "numeric", Value: price},
+				},
+			},
+		}
+	}
+
+	schemaLogEvent := &wal.Event{
+		Data: &wal.Data{
+			Action: "I",
+			Schema: schemalog.SchemaName,
+			Table:  schemalog.TableName,
+		},
+	}
+
+	tests := []struct {
+		name             string
+		events           []*wal.Event
+		fetchLastErr     error
+		walToLogEntryErr error
+
+		wantWarnings   []loglib.Fields
+		wantFetchCalls uint
+	}{
+		{
+			name:   "ok - no violation",
+			events: []*wal.Event{newTestEvent("I", 10)},
+
+			wantFetchCalls: 1,
+		},
+		{
+			name:   "ok - violation",
+			events: []*wal.Event{newTestEvent("U", -1), newTestEvent("I", 0)},
+
+			wantWarnings: []loglib.Fields{
+				{"schema": "public", "table": "products", "constraint": "price_positive", "expression": "(price > (0)::numeric)"},
+				{"schema": "public", "table": "products", "constraint": "price_positive", "expression": "(price > (0)::numeric)"},
+			},
+			wantFetchCalls: 1,
+		},
+		{
+			name:   "ok - delete events are not checked",
+			events: []*wal.Event{newTestEvent("D", -1)},
+
+			wantFetchCalls: 0,
+		},
+		{
+			name:   "ok - constraints refreshed on schema log event",
+			events: []*wal.Event{schemaLogEvent, newTestEvent("I", 5)},
+
+			wantWarnings: []loglib.Fields{
+				{"schema": "public", "table": "products", "constraint": "price_positive", "expression": "(price > (10)::numeric)"},
+			},
+			wantFetchCalls: 0,
+		},
+		{
+			name:         "error - fetching schema log",
+			events:       []*wal.Event{newTestEvent("I", -1)},
+			fetchLastErr: errTest,
+
+			wantWarnings:   []loglib.Fields{{"schema": "public"}},
+			wantFetchCalls: 1,
+		},
+		{
+			name:           "ok - no schema log",
+			events:         []*wal.Event{newTestEvent("I", -1), newTestEvent("I", -1)},
+			fetchLastErr:   schemalog.ErrNoRows,
+			wantFetchCalls: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			processed := 0
+			fetchCalls := uint(0)
+			logger := &mockWarnLogger{}
+			v := &SchemaValidatingProcessor{
+				logger: logger,
+				processor: &mocks.Processor{
+					ProcessWALEventFn: func(ctx context.Context, walEvent *wal.Event) error {
+						processed++
+						return nil
+					},
+				},
+				deadLetterQueue: &logDeadLetterQueue{logger: logger},
+				schemaLogStore: &schemalogmocks.Store{
+					FetchLastFn: func(ctx context.Context, schemaName string, ackedOnly bool) (*schemalog.LogEntry, error) {
+						require.Equal(t, "public", schemaName)
+						require.False(t, ackedOnly)
+						fetchCalls++
+						if tc.fetchLastErr != nil {
+							return nil, tc.fetchLastErr
+						}
+						return testLogEntry("CHECK ((price > (0)::numeric))"), nil
+					},
+				},
+				walToLogEntryAdapter: func(d *wal.Data) (*schemalog.LogEntry, error) {
+					return testLogEntry("CHECK ((price > (10)::numeric)) NOT VALID"), nil
+				},
+				checkConstraints: map[string]map[string][]checkConstraint{},
+			}
+
+			for _, event := range tc.events {
+				err := v.ProcessWALEvent(context.Background(), event)
+				require.NoError(t, err)
+			}
+
+			// check constraint violations never prevent the events processing
+			require.Equal(t, len(tc.events), processed)
+			require.Equal(t, tc.wantWarnings, logger.warnings)
+			require.Equal(t, tc.wantFetchCalls, fetchCalls)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package version
+
+import (
+	"context"
+
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor"
+)
+
+// Stamper is a decorator around a wal processor that tags the data events
+// with the version of the pipeline that processed them, so that consumers can
+// tell which configuration produced each event.
+type Stamper struct {
+	processor processor.Processor
+	version   string
+}
+
+// New will return a wrapper around the processor on input that stamps the
+// pipeline version on input into the metadata of the data events.
+func New(version string, p processor.Processor) *Stamper {
+	return &Stamper{
+		processor: p,
+		version:   version,
+	}
+}
+
+// ProcessWALEvent sets the pipeline version of the event data, if any, and
+// sends it to the wrapped processor.
+func (s *Stamper) ProcessWALEvent(ctx context.Context, event *wal.Event) error {
+	if event.Data != nil {
+		event.Data.Metadata.PipelineVersion = s.version
+	}
+	return s.processor.ProcessWALEvent(ctx, event)
+}
+
+func (s *Stamper) Name() string {
+	return s.processor.Name()
+}
+
+func (s *Stamper) Close() error {
+	return s.processor.Close()
+}
+
+func (s *Stamper) Ping(ctx context.Context) error {
+	return s.processor.Ping(ctx)
+}
@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package version
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+	"github.com/xataio/pgstream/pkg/wal/processor/mocks"
+)
+
+func TestStamper_ProcessWALEvent(t *testing.T) {
+	t.Parallel()
+
+	const testVersion = "f00"
+	errTest := errors.New("oh noes")
+
+	tests := []struct {
+		name       string
+		event      *wal.Event
+		processErr error
+
+		wantEvent *wal.Event
+		wantErr   error
+	}{
+		{
+			name: "ok - data event",
+			event: &wal.Event{
+				Data:           &wal.Data{Action: "I", Schema: "public", Table: "users"},
+				CommitPosition: "1",
+			},
+
+			wantEvent: &wal.Event{
+				Data: &wal.Data{
+					Action:   "I",
+					Schema:   "public",
+					Table:    "users",
+					Metadata: wal.Metadata{PipelineVersion: testVersion},
+				},
+				CommitPosition: "1",
+			},
+		},
+		{
+			name:  "ok - keep alive event",
+			event: &wal.Event{CommitPosition: "1"},
+
+			wantEvent: &wal.Event{CommitPosition: "1"},
+		},
+		{
+			name:       "error - processing event",
+			event:      &wal.Event{CommitPosition: "1"},
+			processErr: errTest,
+
+			wantEvent: &wal.Event{CommitPosition: "1"},
+			wantErr:   errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotEvent *wal.Event
+			s := New(testVersion, &mocks.Processor{
+				ProcessWALEventFn: func(ctx context.Context, event *wal.Event) error {
+					gotEvent = event
+					return tc.processErr
+				},
+			})
+
+			err := s.ProcessWALEvent(context.Background(), tc.event)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantEvent, gotEvent)
+		})
+	}
+}
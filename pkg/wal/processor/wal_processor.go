@@ -17,6 +17,9 @@ type Processor interface {
 	ProcessWALEvent(ctx context.Context, walEvent *wal.Event) error
 	Close() error
 	Name() string
+	// Ping checks the connectivity with the sink the processor writes to.
+	// Processors that don't write to an external system return nil.
+	Ping(ctx context.Context) error
 }
 
 var (
@@ -13,12 +13,12 @@ import (
 	"sync"
 
 	httplib "github.com/xataio/pgstream/internal/http"
-	"github.com/xataio/pgstream/internal/json"
 	synclib "github.com/xataio/pgstream/internal/sync"
 	loglib "github.com/xataio/pgstream/pkg/log"
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
 	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/serializer"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription"
 )
 
@@ -30,6 +30,9 @@ type Notifier struct {
 	checkpointer      checkpointer.Checkpoint
 	subscriptionStore subscriptionRetriever
 	serialiser        serialiser
+	// contentType is set in the webhook requests when a custom serializer is
+	// configured
+	contentType string
 	// queueBytesSema is used to limit the amount of memory used by the
 	// unbuffered msg channel, optimising the channel performance for variable
 	// size messages, while preventing the process from running oom
@@ -58,7 +61,7 @@ func New(cfg *Config, store subscriptionRetriever, opts ...Option) *Notifier {
 		subscriptionStore: store,
 		notifyChan:        make(chan *notifyMsg),
 		workerCount:       cfg.workerCount(),
-		serialiser:        json.Marshal,
+		serialiser:        serialisePayload,
 		notifyDone:        make(chan error, 1),
 		once:              &sync.Once{},
 	}
@@ -88,6 +91,16 @@ func WithCheckpoint(c checkpointer.Checkpoint) Option {
 	}
 }
 
+// WithSerializer sets the serializer used to encode the wal data sent to the
+// webhooks, instead of the default JSON payload. Its content type is set in
+// the webhook requests.
+func WithSerializer(s serializer.Serializer) Option {
+	return func(n *Notifier) {
+		n.serialiser = s.Serialize
+		n.contentType = s.ContentType()
+	}
+}
+
 // ProcessWALEvent will process the wal event on input and notify all configured
 // webhooks. It can be called concurrently.
 func (n *Notifier) ProcessWALEvent(ctx context.Context, walEvent *wal.Event) (err error) {
@@ -177,6 +190,12 @@ func (n *Notifier) Close() error {
 	return nil
 }
 
+// Ping is a no-op, since the notifier sends the events to the subscriber
+// provided urls, which can't be checked in advance.
+func (n *Notifier) Ping(context.Context) error {
+	return nil
+}
+
 // closeNotifyChan closes the internal notify channel. It can be called multiple
 // times.
 func (n *Notifier) closeNotifyChan() {
@@ -231,6 +250,9 @@ func (n *Notifier) sendWebhook(ctx context.Context, payload []byte, url string)
 	if err != nil {
 		return fmt.Errorf("building webhook payload request: %w", err)
 	}
+	if n.contentType != "" {
+		req.Header.Set("Content-Type", n.contentType)
+	}
 
 	resp, err := n.client.Do(req)
 	if err != nil {
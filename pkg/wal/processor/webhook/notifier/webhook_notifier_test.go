@@ -19,6 +19,7 @@ import (
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/checkpointer"
 	"github.com/xataio/pgstream/pkg/wal/processor"
+	"github.com/xataio/pgstream/pkg/wal/processor/serializer"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription/store/mocks"
@@ -47,7 +48,7 @@ func TestNotifier_ProcessWALEvent(t *testing.T) {
 		name              string
 		store             subscriptionRetriever
 		event             *wal.Event
-		serialiser        func(any) ([]byte, error)
+		serialiser        func(*wal.Data) ([]byte, error)
 		weightedSemaphore *syncmocks.WeightedSemaphore
 
 		wantMsgs []*notifyMsg
@@ -114,7 +115,7 @@ func TestNotifier_ProcessWALEvent(t *testing.T) {
 					}, nil
 				},
 			},
-			serialiser: func(a any) ([]byte, error) { return nil, errTest },
+			serialiser: func(*wal.Data) ([]byte, error) { return nil, errTest },
 			event:      testEvent,
 
 			wantMsgs: []*notifyMsg{},
@@ -376,3 +377,48 @@ func TestNotifier(t *testing.T) {
 		}
 	}
 }
+
+func TestNotifier_sendWebhook(t *testing.T) {
+	t.Parallel()
+
+	testPayload := []byte("test payload")
+
+	tests := []struct {
+		name string
+		opts []Option
+
+		wantContentType string
+	}{
+		{
+			name: "ok - default serializer",
+
+			wantContentType: "",
+		},
+		{
+			name: "ok - custom serializer",
+			opts: []Option{WithSerializer(serializer.NewMsgpackSerializer())},
+
+			wantContentType: "application/msgpack",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			n := New(&Config{}, &mocks.Store{}, tc.opts...)
+			n.client = &httpmocks.Client{
+				DoFn: func(r *http.Request) (*http.Response, error) {
+					require.Equal(t, tc.wantContentType, r.Header.Get("Content-Type"))
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Body:       http.NoBody,
+					}, nil
+				},
+			}
+
+			err := n.sendWebhook(context.Background(), testPayload, "url-1")
+			require.NoError(t, err)
+		})
+	}
+}
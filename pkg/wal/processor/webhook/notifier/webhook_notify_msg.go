@@ -5,6 +5,7 @@ package notifier
 import (
 	"fmt"
 
+	"github.com/xataio/pgstream/internal/json"
 	"github.com/xataio/pgstream/pkg/wal"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook"
 	"github.com/xataio/pgstream/pkg/wal/processor/webhook/subscription"
@@ -16,14 +17,20 @@ type notifyMsg struct {
 	commitPosition wal.CommitPosition
 }
 
-type serialiser func(any) ([]byte, error)
+type serialiser func(*wal.Data) ([]byte, error)
+
+// serialisePayload is the default webhook serialiser, which wraps the wal data
+// in a JSON payload.
+func serialisePayload(data *wal.Data) ([]byte, error) {
+	return json.Marshal(&webhook.Payload{Data: data})
+}
 
 func newNotifyMsg(event *wal.Event, subscriptions []*subscription.Subscription, serialiser serialiser) (*notifyMsg, error) {
 	var payload []byte
 	urls := make([]string, 0, len(subscriptions))
 	if len(subscriptions) > 0 {
 		var err error
-		payload, err = serialiser(&webhook.Payload{Data: event.Data})
+		payload, err = serialiser(event.Data)
 		if err != nil {
 			return nil, fmt.Errorf("serialising webhook payload: %w", err)
 		}
@@ -0,0 +1,40 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wal
+
+import "context"
+
+// ReplayContext describes how the events being processed were produced. It's
+// carried in the context passed to the processors, so that they can tell the
+// live events from the archived events being replayed.
+type ReplayContext struct {
+	// IsReplay is true when the events are replayed from an archive, and not
+	// received from the replication. Replayed events don't have a commit
+	// position, since their progress must not be checkpointed.
+	IsReplay bool
+	// StartLSN and EndLSN are the inclusive LSN range being replayed.
+	StartLSN string
+	EndLSN   string
+}
+
+type replayContextKey struct{}
+
+// WithReplayContext returns a copy of the context on input carrying the
+// replay context.
+func WithReplayContext(ctx context.Context, rc ReplayContext) context.Context {
+	return context.WithValue(ctx, replayContextKey{}, rc)
+}
+
+// ReplayContextFromContext returns the replay context carried by the context
+// on input, and false if there's none.
+func ReplayContextFromContext(ctx context.Context) (ReplayContext, bool) {
+	rc, ok := ctx.Value(replayContextKey{}).(ReplayContext)
+	return rc, ok
+}
+
+// IsReplay returns true if the context on input belongs to the replay of
+// archived events.
+func IsReplay(ctx context.Context) bool {
+	rc, _ := ReplayContextFromContext(ctx)
+	return rc.IsReplay
+}
@@ -0,0 +1,26 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayContext(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	_, found := ReplayContextFromContext(ctx)
+	require.False(t, found)
+	require.False(t, IsReplay(ctx))
+
+	rc := ReplayContext{IsReplay: true, StartLSN: "0/10", EndLSN: "0/20"}
+	replayCtx := WithReplayContext(ctx, rc)
+	got, found := ReplayContextFromContext(replayCtx)
+	require.True(t, found)
+	require.Equal(t, rc, got)
+	require.True(t, IsReplay(replayCtx))
+}
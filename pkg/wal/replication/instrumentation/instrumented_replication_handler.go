@@ -23,11 +23,18 @@ type Handler struct {
 }
 
 type metrics struct {
-	replicationLag metric.Int64ObservableGauge
+	replicationLag        metric.Int64ObservableGauge
+	slotRetainedWAL       metric.Int64ObservableGauge
+	slotConfirmedFlushLag metric.Int64ObservableGauge
+	slotRestartLag        metric.Int64ObservableGauge
+	slotActive            metric.Int64ObservableGauge
+	slotSafeWALSize       metric.Int64ObservableGauge
+	slotMaxWALKeepSize    metric.Int64ObservableGauge
 }
 
 type metricRetriever interface {
 	GetReplicationLag(ctx context.Context) (int64, error)
+	GetReplicationSlotStatus(ctx context.Context) (*replication.SlotStatus, error)
 }
 
 func NewHandler(inner replication.Handler, instrumentation *otel.Instrumentation) (replication.Handler, error) {
@@ -70,10 +77,18 @@ func (h *Handler) SyncLSN(ctx context.Context, lsn replication.LSN) (err error)
 	return h.inner.SyncLSN(ctx, lsn)
 }
 
+func (h *Handler) SendStandbyStatusUpdate(ctx context.Context) error {
+	return h.inner.SendStandbyStatusUpdate(ctx)
+}
+
 func (h *Handler) GetReplicationLag(ctx context.Context) (int64, error) {
 	return h.inner.GetReplicationLag(ctx)
 }
 
+func (h *Handler) GetReplicationSlotStatus(ctx context.Context) (*replication.SlotStatus, error) {
+	return h.inner.GetReplicationSlotStatus(ctx)
+}
+
 func (h *Handler) GetCurrentLSN(ctx context.Context) (replication.LSN, error) {
 	return h.inner.GetCurrentLSN(ctx)
 }
@@ -107,19 +122,82 @@ func (h *Handler) initMetrics() error {
 		return err
 	}
 
+	h.metrics.slotRetainedWAL, err = h.meter.Int64ObservableGauge("pgstream.replication.slot.retained_wal",
+		metric.WithUnit("bytes"),
+		metric.WithDescription("WAL retained on the source by the replication slot (current WAL position minus restart_lsn)"))
+	if err != nil {
+		return err
+	}
+
+	h.metrics.slotConfirmedFlushLag, err = h.meter.Int64ObservableGauge("pgstream.replication.slot.confirmed_flush_lag",
+		metric.WithUnit("bytes"),
+		metric.WithDescription("Distance between the current WAL position and the replication slot confirmed_flush_lsn"))
+	if err != nil {
+		return err
+	}
+
+	h.metrics.slotRestartLag, err = h.meter.Int64ObservableGauge("pgstream.replication.slot.restart_lag",
+		metric.WithUnit("bytes"),
+		metric.WithDescription("Distance between the replication slot confirmed_flush_lsn and restart_lsn"))
+	if err != nil {
+		return err
+	}
+
+	h.metrics.slotActive, err = h.meter.Int64ObservableGauge("pgstream.replication.slot.active",
+		metric.WithDescription("Whether the replication slot is active (1) or not (0)"))
+	if err != nil {
+		return err
+	}
+
+	h.metrics.slotSafeWALSize, err = h.meter.Int64ObservableGauge("pgstream.replication.slot.safe_wal_size",
+		metric.WithUnit("bytes"),
+		metric.WithDescription("WAL that can be written before the replication slot is in danger of being invalidated. -1 if max_slot_wal_keep_size is not set"))
+	if err != nil {
+		return err
+	}
+
+	h.metrics.slotMaxWALKeepSize, err = h.meter.Int64ObservableGauge("pgstream.replication.slot.max_wal_keep_size",
+		metric.WithUnit("bytes"),
+		metric.WithDescription("Source max_slot_wal_keep_size setting. -1 if unlimited"))
+	if err != nil {
+		return err
+	}
+
 	observe := func(ctx context.Context, o metric.Observer) error {
+		slotAttribute := attribute.String("replication_slot", h.inner.GetReplicationSlotName())
 		replicationLag, err := h.metricRetriever.GetReplicationLag(ctx)
 		if err != nil {
 			return err
 		}
-		o.ObserveInt64(h.metrics.replicationLag, replicationLag,
-			metric.WithAttributes(attribute.String("replication_slot", h.inner.GetReplicationSlotName())))
+		o.ObserveInt64(h.metrics.replicationLag, replicationLag, metric.WithAttributes(slotAttribute))
+
+		status, err := h.metricRetriever.GetReplicationSlotStatus(ctx)
+		if err != nil {
+			return err
+		}
+		attrs := metric.WithAttributes(slotAttribute, attribute.String("wal_status", status.WALStatus))
+		active := int64(0)
+		if status.Active {
+			active = 1
+		}
+		o.ObserveInt64(h.metrics.slotRetainedWAL, status.RetainedWALBytes, attrs)
+		o.ObserveInt64(h.metrics.slotConfirmedFlushLag, status.ConfirmedFlushLagBytes, attrs)
+		o.ObserveInt64(h.metrics.slotRestartLag, status.RestartLagBytes, attrs)
+		o.ObserveInt64(h.metrics.slotActive, active, attrs)
+		o.ObserveInt64(h.metrics.slotSafeWALSize, status.SafeWALSizeBytes, attrs)
+		o.ObserveInt64(h.metrics.slotMaxWALKeepSize, status.MaxSlotWALKeepSizeBytes, attrs)
 		return nil
 	}
 
 	_, err = h.meter.RegisterCallback(
 		observe,
 		h.metrics.replicationLag,
+		h.metrics.slotRetainedWAL,
+		h.metrics.slotConfirmedFlushLag,
+		h.metrics.slotRestartLag,
+		h.metrics.slotActive,
+		h.metrics.slotSafeWALSize,
+		h.metrics.slotMaxWALKeepSize,
 	)
 	if err != nil {
 		return fmt.Errorf("registering replication handler metric callbacks: %w", err)
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/xataio/pgstream/pkg/wal/replication"
 	"golang.org/x/sync/singleflight"
 )
 
@@ -22,6 +23,11 @@ type metricsCache struct {
 	replicationLagUpdatedAt time.Time
 	replicationLag          int64
 
+	// cache layer for replication slot status
+	slotStatusMutex     sync.RWMutex
+	slotStatusUpdatedAt time.Time
+	slotStatus          *replication.SlotStatus
+
 	// singleflight group ensures only one goroutine fetches fresh data when
 	// the cache expires, while other concurrent callers wait for that result
 	group singleflight.Group
@@ -33,7 +39,10 @@ type metricsCache struct {
 // collection (30-60s).
 const defaultCacheTTL = 30 * time.Second
 
-var errUnexpectedLagValueType = errors.New("unexpected type for replication lag value")
+var (
+	errUnexpectedLagValueType        = errors.New("unexpected type for replication lag value")
+	errUnexpectedSlotStatusValueType = errors.New("unexpected type for replication slot status value")
+)
 
 // newMetricsCache creates a new metricsCache with the specified TTL for the
 // replication metrics cache. If the provided TTL is less than or equal to zero,
@@ -97,3 +106,47 @@ func (c *metricsCache) setReplicationLagCache(lag int64) {
 	c.replicationLag = lag
 	c.replicationLagUpdatedAt = time.Now()
 }
+
+// GetReplicationSlotStatus returns the replication slot status, using a cached
+// value if it is still valid. Otherwise, it fetches a fresh value from the
+// inner retriever and updates the cache.
+func (c *metricsCache) GetReplicationSlotStatus(ctx context.Context) (*replication.SlotStatus, error) {
+	if cacheStatus, isValid := c.isSlotStatusCacheValid(); isValid {
+		return cacheStatus, nil
+	}
+
+	val, err, _ := c.group.Do("replication_slot_status", func() (any, error) {
+		status, err := c.inner.GetReplicationSlotStatus(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		c.setSlotStatusCache(status)
+		return status, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status, ok := val.(*replication.SlotStatus)
+	if !ok {
+		return nil, errUnexpectedSlotStatusValueType
+	}
+	return status, nil
+}
+
+func (c *metricsCache) isSlotStatusCacheValid() (*replication.SlotStatus, bool) {
+	c.slotStatusMutex.RLock()
+	defer c.slotStatusMutex.RUnlock()
+
+	isValid := !c.slotStatusUpdatedAt.IsZero() && time.Since(c.slotStatusUpdatedAt) < c.ttl
+	return c.slotStatus, isValid
+}
+
+func (c *metricsCache) setSlotStatusCache(status *replication.SlotStatus) {
+	c.slotStatusMutex.Lock()
+	defer c.slotStatusMutex.Unlock()
+
+	c.slotStatus = status
+	c.slotStatusUpdatedAt = time.Now()
+}
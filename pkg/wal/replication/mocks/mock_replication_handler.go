@@ -10,19 +10,22 @@ import (
 )
 
 type Handler struct {
-	StartReplicationFn        func(context.Context) error
-	StartReplicationFromLSNFn func(context.Context, replication.LSN) error
-	ReceiveMessageFn          func(context.Context, uint64) (*replication.Message, error)
-	SyncLSNFn                 func(context.Context, replication.LSN, uint64) error
-	DropReplicationSlotFn     func(ctx context.Context) error
-	GetLSNParserFn            func() replication.LSNParser
-	GetCurrentLSNFn           func(context.Context) (replication.LSN, error)
-	ResetConnectionFn         func(ctx context.Context) error
-	GetReplicationLagFn       func(context.Context) (int64, error)
-	GetReplicationSlotNameFn  func() string
-	CloseFn                   func() error
-	SyncLSNCalls              uint64
-	ReceiveMessageCalls       uint64
+	StartReplicationFn           func(context.Context) error
+	StartReplicationFromLSNFn    func(context.Context, replication.LSN) error
+	ReceiveMessageFn             func(context.Context, uint64) (*replication.Message, error)
+	SyncLSNFn                    func(context.Context, replication.LSN, uint64) error
+	SendStandbyStatusUpdateFn    func(context.Context, uint64) error
+	DropReplicationSlotFn        func(ctx context.Context) error
+	GetLSNParserFn               func() replication.LSNParser
+	GetCurrentLSNFn              func(context.Context) (replication.LSN, error)
+	ResetConnectionFn            func(ctx context.Context) error
+	GetReplicationLagFn          func(context.Context) (int64, error)
+	GetReplicationSlotStatusFn   func(context.Context) (*replication.SlotStatus, error)
+	GetReplicationSlotNameFn     func() string
+	CloseFn                      func() error
+	SyncLSNCalls                 uint64
+	ReceiveMessageCalls          uint64
+	SendStandbyStatusUpdateCalls uint64
 }
 
 func (m *Handler) StartReplication(ctx context.Context) error {
@@ -43,6 +46,14 @@ func (m *Handler) SyncLSN(ctx context.Context, lsn replication.LSN) error {
 	return m.SyncLSNFn(ctx, lsn, m.GetSyncLSNCalls())
 }
 
+func (m *Handler) SendStandbyStatusUpdate(ctx context.Context) error {
+	atomic.AddUint64(&m.SendStandbyStatusUpdateCalls, 1)
+	if m.SendStandbyStatusUpdateFn == nil {
+		return nil
+	}
+	return m.SendStandbyStatusUpdateFn(ctx, m.GetSendStandbyStatusUpdateCalls())
+}
+
 func (m *Handler) DropReplicationSlot(ctx context.Context) error {
 	return m.DropReplicationSlotFn(ctx)
 }
@@ -55,6 +66,10 @@ func (m *Handler) GetReplicationLag(ctx context.Context) (int64, error) {
 	return m.GetReplicationLagFn(ctx)
 }
 
+func (m *Handler) GetReplicationSlotStatus(ctx context.Context) (*replication.SlotStatus, error) {
+	return m.GetReplicationSlotStatusFn(ctx)
+}
+
 func (m *Handler) GetLSNParser() replication.LSNParser {
 	return m.GetLSNParserFn()
 }
@@ -71,6 +86,10 @@ func (m *Handler) GetReceiveMessageCalls() uint64 {
 	return atomic.LoadUint64(&m.ReceiveMessageCalls)
 }
 
+func (m *Handler) GetSendStandbyStatusUpdateCalls() uint64 {
+	return atomic.LoadUint64(&m.SendStandbyStatusUpdateCalls)
+}
+
 func (m *Handler) ResetConnection(ctx context.Context) error {
 	return m.ResetConnectionFn(ctx)
 }
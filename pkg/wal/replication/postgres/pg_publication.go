@@ -0,0 +1,468 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/schemalog"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type PublicationConfig struct {
+	// Name of the publication that will be kept in sync with the configured
+	// include table list. It will be created if it doesn't exist.
+	Name string
+	// PublishViaPartitionRoot will publish the changes of partitions using
+	// the identity of their partitioned root table.
+	PublishViaPartitionRoot bool
+	// SkipUnlogged will skip the unlogged tables explicitly configured in the
+	// include table list instead of returning an error. Unlogged tables
+	// don't generate WAL, so their changes can't be replicated. Unlogged
+	// tables matching a wildcard are always skipped.
+	SkipUnlogged bool
+}
+
+// publicationTable represents a table that is part of the publication.
+type publicationTable struct {
+	schema      string
+	name        string
+	partitioned bool
+	unlogged    bool
+}
+
+const (
+	publicationTablesQuery = `SELECT n.nspname, c.relname, c.relkind = 'p', c.relpersistence = 'u'
+	FROM pg_catalog.pg_class c JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE c.relkind IN ('r', 'p') AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')
+	ORDER BY n.nspname, c.relname`
+	publishedTablesQuery = `SELECT n.nspname, c.relname
+	FROM pg_catalog.pg_publication_rel pr
+	JOIN pg_catalog.pg_publication p ON p.oid = pr.prpubid
+	JOIN pg_catalog.pg_class c ON c.oid = pr.prrelid
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE p.pubname = $1`
+	publicationExistsQuery = `SELECT EXISTS(SELECT 1 FROM pg_catalog.pg_publication WHERE pubname = $1)`
+	leafPartitionsQuery    = `SELECT n.nspname, c.relname
+	FROM pg_partition_tree($1::regclass) t
+	JOIN pg_catalog.pg_class c ON c.oid = t.relid
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	WHERE t.isleaf`
+)
+
+// pending tables are the ones added to an existing publication that haven't
+// been snapshotted yet
+const (
+	pendingTablesTableName    = "publication_pending_tables"
+	createPendingTablesSchema = `CREATE SCHEMA IF NOT EXISTS ` + schemalog.SchemaName
+	createPendingTablesQuery  = `CREATE TABLE IF NOT EXISTS ` + schemalog.SchemaName + `.` + pendingTablesTableName + `(
+	publication_name TEXT NOT NULL,
+	table_name TEXT NOT NULL,
+	PRIMARY KEY (publication_name, table_name))`
+	addPendingTablesQuery = `INSERT INTO ` + schemalog.SchemaName + `.` + pendingTablesTableName + `(publication_name, table_name)
+	SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING`
+	pendingTablesQuery       = `SELECT table_name FROM ` + schemalog.SchemaName + `.` + pendingTablesTableName + ` WHERE publication_name = $1 ORDER BY table_name`
+	deletePendingTablesQuery = `DELETE FROM ` + schemalog.SchemaName + `.` + pendingTablesTableName + ` WHERE publication_name = $1 AND table_name = ANY($2::text[])`
+	clearPendingTablesQuery  = `DELETE FROM ` + schemalog.SchemaName + `.` + pendingTablesTableName + ` WHERE publication_name = $1`
+)
+
+const unloggedTablesSkippedMetric = "pgstream.unlogged_tables.skipped"
+
+var (
+	ErrUnloggedTable = errors.New("unlogged tables don't generate WAL and can't be replicated")
+
+	errPublicationIncludeTablesRequired = errors.New("include tables must be provided when a publication is configured")
+	errNoPublicationTables              = errors.New("no tables found matching the include tables for the publication")
+)
+
+// syncPublication makes sure the configured publication contains all the
+// tables matching the include table list, creating it if it doesn't exist.
+// Tables that were not part of an existing publication are persisted in the
+// source database until they've been snapshotted, so that they're still
+// snapshotted if the process stops before completing it. It also restricts
+// the tables decoded by
+// wal2json to the published ones, to avoid wasting resources on the source
+// decoding changes for tables that are not replicated.
+func (h *Handler) syncPublication(ctx context.Context, cfg *PublicationConfig) error {
+	return h.syncPublicationTables(ctx, cfg, true)
+}
+
+// RefreshPublication syncs the configured publication again with the include
+// table list, so that the tables created after the handler was set up that
+// match it are published and decoded. It returns true if the decoded tables
+// have changed, in which case the replication needs to be restarted for
+// wal2json to decode them. The added tables are not pending to be
+// snapshotted, since their changes will be decoded once the replication is
+// restarted from the last synced position.
+func (h *Handler) RefreshPublication(ctx context.Context) (bool, error) {
+	if h.publication == nil {
+		return false, nil
+	}
+
+	pluginArguments := h.getPluginArguments()
+	if err := h.syncPublicationTables(ctx, h.publication, false); err != nil {
+		return false, err
+	}
+	return !slices.Equal(pluginArguments, h.getPluginArguments()), nil
+}
+
+// syncPublicationTables syncs the publication tables. The tables added to an
+// existing publication are kept track of as pending only if requested.
+func (h *Handler) syncPublicationTables(ctx context.Context, cfg *PublicationConfig, trackAddedTables bool) error {
+	if len(h.includedTables) == 0 {
+		return errPublicationIncludeTablesRequired
+	}
+
+	conn, err := h.pgConnBuilder()
+	if err != nil {
+		return fmt.Errorf("creating pg connection: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	tables, internalTables, err := h.discoverPublicationTables(ctx, conn, cfg)
+	if err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return errNoPublicationTables
+	}
+
+	exists := false
+	if err := conn.QueryRow(ctx, []any{&exists}, publicationExistsQuery, cfg.Name); err != nil {
+		return fmt.Errorf("checking publication %s exists: %w", cfg.Name, err)
+	}
+
+	if err := createPendingTablesTable(ctx, conn); err != nil {
+		return err
+	}
+
+	h.publication = cfg
+	if !exists {
+		// any pending tables left behind by a dropped publication with the
+		// same name are no longer relevant
+		if _, err := conn.Exec(ctx, clearPendingTablesQuery, cfg.Name); err != nil {
+			return fmt.Errorf("clearing publication %s pending tables: %w", cfg.Name, err)
+		}
+		if _, err := conn.Exec(ctx, createPublicationQuery(cfg, tables)); err != nil {
+			return fmt.Errorf("creating publication %s: %w", cfg.Name, err)
+		}
+		h.logger.Info("publication created", loglib.Fields{"publication": cfg.Name, "tables": len(tables)})
+	} else {
+		publishedTables, err := getPublishedTables(ctx, conn, cfg.Name)
+		if err != nil {
+			return err
+		}
+
+		addedTables := []string{}
+		for _, t := range tables {
+			if !slices.Contains(publishedTables, t.qualifiedName()) {
+				addedTables = append(addedTables, t.qualifiedName())
+			}
+		}
+		// the added tables are persisted before altering the publication, so
+		// that they're never published without being pending
+		if len(addedTables) > 0 && trackAddedTables {
+			if _, err := conn.Exec(ctx, addPendingTablesQuery, cfg.Name, addedTables); err != nil {
+				return fmt.Errorf("adding publication %s pending tables: %w", cfg.Name, err)
+			}
+		}
+
+		for _, query := range alterPublicationQueries(cfg, tables) {
+			if _, err := conn.Exec(ctx, query); err != nil {
+				return fmt.Errorf("altering publication %s: %w", cfg.Name, err)
+			}
+		}
+		if len(addedTables) > 0 {
+			h.logger.Info("tables added to publication", loglib.Fields{"publication": cfg.Name, "tables": addedTables})
+		}
+
+		// tables added by previous runs might not have been snapshotted yet
+		if trackAddedTables {
+			h.newlyPublishedTables, err = getPendingTables(ctx, conn, cfg.Name, tables)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	addTables, err := h.wal2jsonAddTables(ctx, conn, append(tables, internalTables...))
+	if err != nil {
+		return err
+	}
+	h.pluginArguments = append(slices.Clone(h.getBasePluginArguments()), fmt.Sprintf(`"add-tables" '%s'`, addTables))
+
+	return nil
+}
+
+// NewlyPublishedTables returns the qualified names of the tables that have
+// been added to an existing publication and are pending to be snapshotted.
+// This includes the tables added when the handler was created, as well as the
+// ones added by previous runs that were not snapshotted.
+func (h *Handler) NewlyPublishedTables() []string {
+	return h.newlyPublishedTables
+}
+
+// CompleteNewlyPublishedTables removes the newly published tables from the
+// pending ones. It must be called once they've been snapshotted.
+func (h *Handler) CompleteNewlyPublishedTables(ctx context.Context) error {
+	if len(h.newlyPublishedTables) == 0 {
+		return nil
+	}
+
+	conn, err := h.pgConnBuilder()
+	if err != nil {
+		return fmt.Errorf("creating pg connection: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, deletePendingTablesQuery, h.publication.Name, h.newlyPublishedTables); err != nil {
+		return fmt.Errorf("removing publication %s pending tables: %w", h.publication.Name, err)
+	}
+	h.newlyPublishedTables = nil
+	return nil
+}
+
+// discoverPublicationTables returns the tables matching the include table
+// list. The included tables from the pgstream schema are returned separately,
+// since they need to be decoded but they're not part of the publication.
+// Unlogged tables are skipped, unless they're explicitly included and the
+// publication is not configured to skip them, in which case an error is
+// returned.
+func (h *Handler) discoverPublicationTables(ctx context.Context, conn pglib.Querier, cfg *PublicationConfig) ([]publicationTable, []publicationTable, error) {
+	rows, err := conn.Query(ctx, publicationTablesQuery)
+	if err != nil {
+		return nil, nil, fmt.Errorf("discovering publication tables: %w", err)
+	}
+	defer rows.Close()
+
+	tables := []publicationTable{}
+	internalTables := []publicationTable{}
+	unloggedTables := []string{}
+	for rows.Next() {
+		t := publicationTable{}
+		if err := rows.Scan(&t.schema, &t.name, &t.partitioned, &t.unlogged); err != nil {
+			return nil, nil, fmt.Errorf("scanning publication table: %w", err)
+		}
+		if !h.includedTables.ContainsSchemaTable(t.schema, t.name) {
+			continue
+		}
+		if len(h.excludedTables) > 0 && h.excludedTables.ContainsSchemaTable(t.schema, t.name) {
+			continue
+		}
+		if t.unlogged {
+			if h.isExplicitlyIncluded(t) && !cfg.SkipUnlogged {
+				return nil, nil, fmt.Errorf("%w: %s", ErrUnloggedTable, t.qualifiedName())
+			}
+			unloggedTables = append(unloggedTables, t.qualifiedName())
+			continue
+		}
+		if t.schema == schemalog.SchemaName {
+			// the schema log table is always decoded
+			if t.name != schemalog.TableName {
+				internalTables = append(internalTables, t)
+			}
+			continue
+		}
+		tables = append(tables, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(unloggedTables) > 0 {
+		h.logger.Warn(nil, "skipping unlogged tables, their changes will not be replicated", loglib.Fields{
+			"publication": cfg.Name,
+			"tables":      unloggedTables,
+		})
+		h.recordUnloggedTablesSkipped(ctx, cfg.Name, len(unloggedTables))
+	}
+
+	return tables, internalTables, nil
+}
+
+// isExplicitlyIncluded returns true if the table on input is part of the
+// include table list by name, rather than by matching a wildcard.
+func (h *Handler) isExplicitlyIncluded(t publicationTable) bool {
+	_, found := h.includedTables[t.schema][t.name]
+	return found
+}
+
+func (h *Handler) recordUnloggedTablesSkipped(ctx context.Context, publication string, count int) {
+	if h.meter == nil {
+		return
+	}
+	counter, err := h.meter.Int64Counter(unloggedTablesSkippedMetric,
+		metric.WithDescription("Number of unlogged tables skipped from the publication, since their changes can't be replicated"))
+	if err != nil {
+		h.logger.Warn(err, "creating unlogged tables skipped metric")
+		return
+	}
+	counter.Add(ctx, int64(count), metric.WithAttributes(attribute.String("publication", publication)))
+}
+
+func getPublishedTables(ctx context.Context, conn pglib.Querier, publication string) ([]string, error) {
+	rows, err := conn.Query(ctx, publishedTablesQuery, publication)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving publication %s tables: %w", publication, err)
+	}
+	defer rows.Close()
+
+	tables := []string{}
+	for rows.Next() {
+		t := publicationTable{}
+		if err := rows.Scan(&t.schema, &t.name); err != nil {
+			return nil, fmt.Errorf("scanning published table: %w", err)
+		}
+		tables = append(tables, t.qualifiedName())
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tables, nil
+}
+
+func createPendingTablesTable(ctx context.Context, conn pglib.Querier) error {
+	if _, err := conn.Exec(ctx, createPendingTablesSchema); err != nil {
+		return fmt.Errorf("creating pgstream schema for publication pending tables: %w", err)
+	}
+	if _, err := conn.Exec(ctx, createPendingTablesQuery); err != nil {
+		return fmt.Errorf("creating publication pending tables table: %w", err)
+	}
+	return nil
+}
+
+// getPendingTables returns the pending tables of the publication that are
+// still part of the publication tables on input.
+func getPendingTables(ctx context.Context, conn pglib.Querier, publication string, tables []publicationTable) ([]string, error) {
+	rows, err := conn.Query(ctx, pendingTablesQuery, publication)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving publication %s pending tables: %w", publication, err)
+	}
+	defer rows.Close()
+
+	var pendingTables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, fmt.Errorf("scanning pending table: %w", err)
+		}
+		if slices.ContainsFunc(tables, func(t publicationTable) bool { return t.qualifiedName() == table }) {
+			pendingTables = append(pendingTables, table)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pendingTables, nil
+}
+
+// wal2jsonAddTables returns the value of the wal2json add-tables option for
+// the tables on input. Since wal2json reports the changes of partitioned
+// tables using the partition names, the leaf partitions are included. The
+// pgstream schema log table is always included so that schema changes are
+// not lost.
+func (h *Handler) wal2jsonAddTables(ctx context.Context, conn pglib.Querier, tables []publicationTable) (string, error) {
+	addTables := []string{
+		escapeWal2jsonName(schemalog.SchemaName) + "." + escapeWal2jsonName(schemalog.TableName),
+	}
+	for _, t := range tables {
+		if !t.partitioned {
+			addTables = append(addTables, t.wal2jsonName())
+			continue
+		}
+
+		partitions, err := getLeafPartitions(ctx, conn, t)
+		if err != nil {
+			return "", err
+		}
+		for _, p := range partitions {
+			addTables = append(addTables, p.wal2jsonName())
+		}
+	}
+
+	return strings.Join(addTables, ","), nil
+}
+
+func getLeafPartitions(ctx context.Context, conn pglib.Querier, t publicationTable) ([]publicationTable, error) {
+	rows, err := conn.Query(ctx, leafPartitionsQuery, t.quotedName())
+	if err != nil {
+		return nil, fmt.Errorf("retrieving partitions for table %s: %w", t.qualifiedName(), err)
+	}
+	defer rows.Close()
+
+	partitions := []publicationTable{}
+	for rows.Next() {
+		p := publicationTable{}
+		if err := rows.Scan(&p.schema, &p.name); err != nil {
+			return nil, fmt.Errorf("scanning partition: %w", err)
+		}
+		partitions = append(partitions, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return partitions, nil
+}
+
+func createPublicationQuery(cfg *PublicationConfig, tables []publicationTable) string {
+	return fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s WITH (publish_via_partition_root = %t)",
+		pglib.QuoteIdentifier(cfg.Name), quotedTableList(tables), cfg.PublishViaPartitionRoot)
+}
+
+func alterPublicationQueries(cfg *PublicationConfig, tables []publicationTable) []string {
+	return []string{
+		fmt.Sprintf("ALTER PUBLICATION %s SET TABLE %s", pglib.QuoteIdentifier(cfg.Name), quotedTableList(tables)),
+		fmt.Sprintf("ALTER PUBLICATION %s SET (publish_via_partition_root = %t)", pglib.QuoteIdentifier(cfg.Name), cfg.PublishViaPartitionRoot),
+	}
+}
+
+func quotedTableList(tables []publicationTable) string {
+	quotedTables := make([]string, 0, len(tables))
+	for _, t := range tables {
+		quotedTables = append(quotedTables, t.quotedName())
+	}
+	return strings.Join(quotedTables, ", ")
+}
+
+func (t publicationTable) qualifiedName() string {
+	return t.schema + "." + t.name
+}
+
+func (t publicationTable) quotedName() string {
+	return pglib.QuoteQualifiedIdentifier(t.schema, t.name)
+}
+
+func (t publicationTable) wal2jsonName() string {
+	return escapeWal2jsonName(t.schema) + "." + escapeWal2jsonName(t.name)
+}
+
+var wal2jsonNameReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	` `, `\ `,
+	`,`, `\,`,
+	`.`, `\.`,
+	`*`, `\*`,
+	`'`, `\''`,
+)
+
+// escapeWal2jsonName escapes the characters that have a special meaning in
+// the wal2json table options, as well as the single quotes used to delimit
+// the option value.
+func escapeWal2jsonName(name string) string {
+	return wal2jsonNameReplacer.Replace(name)
+}
@@ -0,0 +1,501 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+	"github.com/xataio/pgstream/pkg/log"
+)
+
+func TestHandler_syncPublication(t *testing.T) {
+	t.Parallel()
+
+	testPublication := &PublicationConfig{
+		Name:                    "test_publication",
+		PublishViaPartitionRoot: true,
+	}
+
+	testTables := [][]any{
+		{"another_schema", "excluded", false, false},
+		{"pgstream", "heartbeat", false, false},
+		{"pgstream", "schema_log", false, false},
+		{"public", "events", true, false},
+		{"public", "sessions", false, true},
+		{"public", "users", false, false},
+	}
+
+	testPartitions := [][]any{
+		{"public", "events_2024"},
+		{"public", "events_2025"},
+	}
+
+	wantPluginArguments := append(slices.Clone(pluginArguments),
+		`"add-tables" 'pgstream.schema_log,public.events_2024,public.events_2025,public.users,pgstream.heartbeat'`)
+
+	queryFn := func(publishedTables [][]any, pendingTables ...[]any) func(ctx context.Context, i uint, query string, args ...any) (pglib.Rows, error) {
+		return func(ctx context.Context, i uint, query string, args ...any) (pglib.Rows, error) {
+			switch query {
+			case publicationTablesQuery:
+				return newMockRows(testTables), nil
+			case publishedTablesQuery:
+				require.Equal(t, []any{testPublication.Name}, args)
+				return newMockRows(publishedTables), nil
+			case pendingTablesQuery:
+				require.Equal(t, []any{testPublication.Name}, args)
+				return newMockRows(pendingTables), nil
+			case leafPartitionsQuery:
+				require.Equal(t, []any{`"public"."events"`}, args)
+				return newMockRows(testPartitions), nil
+			default:
+				return nil, fmt.Errorf("unexpected query: %s", query)
+			}
+		}
+	}
+
+	publicationExistsFn := func(exists bool) func(ctx context.Context, dest []any, query string, args ...any) error {
+		return func(ctx context.Context, dest []any, query string, args ...any) error {
+			require.Equal(t, publicationExistsQuery, query)
+			require.Equal(t, []any{testPublication.Name}, args)
+			require.Len(t, dest, 1)
+			existsDest, ok := dest[0].(*bool)
+			require.True(t, ok)
+			*existsDest = exists
+			return nil
+		}
+	}
+
+	// execFn returns an exec function that expects the pending tables setup
+	// queries to be executed before the ones on input
+	execFn := func(wantQueries ...string) func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+		wantQueries = append([]string{createPendingTablesSchema, createPendingTablesQuery}, wantQueries...)
+		return func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+			if i > uint(len(wantQueries)) {
+				return pglib.CommandTag{}, fmt.Errorf("unexpected exec call: %d", i)
+			}
+			require.Equal(t, wantQueries[i-1], query)
+			switch query {
+			case clearPendingTablesQuery:
+				require.Equal(t, []any{testPublication.Name}, args)
+			case addPendingTablesQuery:
+				require.Equal(t, []any{testPublication.Name, []string{"public.events"}}, args)
+			}
+			return pglib.CommandTag{}, nil
+		}
+	}
+
+	tests := []struct {
+		name          string
+		includeTables []string
+		publication   *PublicationConfig
+		querier       *pgmocks.Querier
+
+		wantPluginArguments      []string
+		wantNewlyPublishedTables []string
+		wantErr                  error
+	}{
+		{
+			name:          "ok - publication created",
+			includeTables: []string{"public.*", "pgstream.heartbeat"},
+			querier: &pgmocks.Querier{
+				QueryFn:    queryFn(nil),
+				QueryRowFn: publicationExistsFn(false),
+				ExecFn: execFn(
+					clearPendingTablesQuery,
+					`CREATE PUBLICATION "test_publication" FOR TABLE "public"."events", "public"."users" WITH (publish_via_partition_root = true)`),
+			},
+
+			wantPluginArguments:      wantPluginArguments,
+			wantNewlyPublishedTables: nil,
+			wantErr:                  nil,
+		},
+		{
+			name:          "ok - publication altered",
+			includeTables: []string{"public.*", "pgstream.heartbeat"},
+			querier: &pgmocks.Querier{
+				QueryFn:    queryFn([][]any{{"public", "users"}}, []any{"public.events"}),
+				QueryRowFn: publicationExistsFn(true),
+				ExecFn: execFn(
+					addPendingTablesQuery,
+					`ALTER PUBLICATION "test_publication" SET TABLE "public"."events", "public"."users"`,
+					`ALTER PUBLICATION "test_publication" SET (publish_via_partition_root = true)`),
+			},
+
+			wantPluginArguments:      wantPluginArguments,
+			wantNewlyPublishedTables: []string{"public.events"},
+			wantErr:                  nil,
+		},
+		{
+			name:          "ok - publication unchanged with tables pending from a previous run",
+			includeTables: []string{"public.*", "pgstream.heartbeat"},
+			querier: &pgmocks.Querier{
+				// tables no longer part of the publication are ignored
+				QueryFn:    queryFn([][]any{{"public", "events"}, {"public", "users"}}, []any{"public.dropped"}, []any{"public.users"}),
+				QueryRowFn: publicationExistsFn(true),
+				ExecFn: execFn(
+					`ALTER PUBLICATION "test_publication" SET TABLE "public"."events", "public"."users"`,
+					`ALTER PUBLICATION "test_publication" SET (publish_via_partition_root = true)`),
+			},
+
+			wantPluginArguments:      wantPluginArguments,
+			wantNewlyPublishedTables: []string{"public.users"},
+			wantErr:                  nil,
+		},
+		{
+			name:          "ok - publication created with table patterns",
+			includeTables: []string{"public.u*", `^(public\.events|another_schema\..+)$`},
+			querier: &pgmocks.Querier{
+				QueryFn:    queryFn(nil),
+				QueryRowFn: publicationExistsFn(false),
+				ExecFn: execFn(
+					clearPendingTablesQuery,
+					`CREATE PUBLICATION "test_publication" FOR TABLE "another_schema"."excluded", "public"."events", "public"."users" WITH (publish_via_partition_root = true)`),
+			},
+
+			wantPluginArguments: append(slices.Clone(pluginArguments),
+				`"add-tables" 'pgstream.schema_log,another_schema.excluded,public.events_2024,public.events_2025,public.users'`),
+			wantNewlyPublishedTables: nil,
+			wantErr:                  nil,
+		},
+		{
+			name:          "ok - explicitly included unlogged table skipped",
+			includeTables: []string{"public.users", "public.sessions"},
+			publication: &PublicationConfig{
+				Name:         testPublication.Name,
+				SkipUnlogged: true,
+			},
+			querier: &pgmocks.Querier{
+				QueryFn:    queryFn(nil),
+				QueryRowFn: publicationExistsFn(false),
+				ExecFn: execFn(
+					clearPendingTablesQuery,
+					`CREATE PUBLICATION "test_publication" FOR TABLE "public"."users" WITH (publish_via_partition_root = false)`),
+			},
+
+			wantPluginArguments: append(slices.Clone(pluginArguments),
+				`"add-tables" 'pgstream.schema_log,public.users'`),
+			wantNewlyPublishedTables: nil,
+			wantErr:                  nil,
+		},
+		{
+			name:          "error - explicitly included unlogged table",
+			includeTables: []string{"public.users", "public.sessions"},
+			querier: &pgmocks.Querier{
+				QueryFn: queryFn(nil),
+			},
+
+			wantErr: ErrUnloggedTable,
+		},
+		{
+			name:          "error - no include tables",
+			includeTables: nil,
+			querier:       &pgmocks.Querier{},
+
+			wantErr: errPublicationIncludeTablesRequired,
+		},
+		{
+			name:          "error - no matching tables",
+			includeTables: []string{"unknown_schema.*"},
+			querier: &pgmocks.Querier{
+				QueryFn: queryFn(nil),
+			},
+
+			wantErr: errNoPublicationTables,
+		},
+		{
+			name:          "error - creating pending tables table",
+			includeTables: []string{"public.*"},
+			querier: &pgmocks.Querier{
+				QueryFn:    queryFn(nil),
+				QueryRowFn: publicationExistsFn(true),
+				ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+					return pglib.CommandTag{}, errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+		{
+			name:          "error - creating publication",
+			includeTables: []string{"public.*"},
+			querier: &pgmocks.Querier{
+				QueryFn:    queryFn(nil),
+				QueryRowFn: publicationExistsFn(false),
+				ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+					if strings.HasPrefix(query, "CREATE PUBLICATION") {
+						return pglib.CommandTag{}, errTest
+					}
+					return pglib.CommandTag{}, nil
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				logger: log.NewNoopLogger(),
+				pgConnBuilder: func() (pglib.Querier, error) {
+					return tc.querier, nil
+				},
+			}
+			if tc.includeTables != nil {
+				var err error
+				h.includedTables, err = pglib.NewSchemaTableMap(tc.includeTables)
+				require.NoError(t, err)
+			}
+
+			publication := testPublication
+			if tc.publication != nil {
+				publication = tc.publication
+			}
+
+			err := h.syncPublication(context.Background(), publication)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantNewlyPublishedTables, h.NewlyPublishedTables())
+			if tc.wantErr == nil {
+				require.Equal(t, tc.wantPluginArguments, h.getPluginArguments())
+			}
+		})
+	}
+}
+
+func TestHandler_RefreshPublication(t *testing.T) {
+	t.Parallel()
+
+	testPublication := &PublicationConfig{Name: "test_publication"}
+	testTables := [][]any{
+		{"pgstream", "schema_log", false, false},
+		{"public", "events", false, false},
+		{"public", "users", false, false},
+	}
+	wantPluginArguments := append(slices.Clone(pluginArguments),
+		`"add-tables" 'pgstream.schema_log,public.events,public.users'`)
+
+	newQuerier := func() *pgmocks.Querier {
+		return &pgmocks.Querier{
+			QueryFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.Rows, error) {
+				switch query {
+				case publicationTablesQuery:
+					return newMockRows(testTables), nil
+				case publishedTablesQuery:
+					return newMockRows([][]any{{"public", "users"}}), nil
+				default:
+					return nil, fmt.Errorf("unexpected query: %s", query)
+				}
+			},
+			QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+				*(dest[0].(*bool)) = true
+				return nil
+			},
+			ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				// the tables created while running are not pending to be
+				// snapshotted
+				if query == addPendingTablesQuery {
+					return pglib.CommandTag{}, errors.New("unexpected pending tables added")
+				}
+				return pglib.CommandTag{}, nil
+			},
+		}
+	}
+
+	tests := []struct {
+		name            string
+		publication     *PublicationConfig
+		pluginArguments []string
+
+		wantChanged bool
+		wantErr     error
+	}{
+		{
+			name:        "ok - no publication",
+			publication: nil,
+
+			wantChanged: false,
+		},
+		{
+			name:        "ok - decoded tables changed",
+			publication: testPublication,
+			pluginArguments: append(slices.Clone(pluginArguments),
+				`"add-tables" 'pgstream.schema_log,public.users'`),
+
+			wantChanged: true,
+		},
+		{
+			name:            "ok - decoded tables unchanged",
+			publication:     testPublication,
+			pluginArguments: wantPluginArguments,
+
+			wantChanged: false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			includedTables, err := pglib.NewSchemaTableMap([]string{"public.*"})
+			require.NoError(t, err)
+
+			h := &Handler{
+				logger:          log.NewNoopLogger(),
+				includedTables:  includedTables,
+				publication:     tc.publication,
+				pluginArguments: tc.pluginArguments,
+				pgConnBuilder: func() (pglib.Querier, error) {
+					return newQuerier(), nil
+				},
+			}
+
+			changed, err := h.RefreshPublication(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantChanged, changed)
+			require.Nil(t, h.NewlyPublishedTables())
+			if tc.publication != nil {
+				require.Equal(t, wantPluginArguments, h.getPluginArguments())
+			}
+		})
+	}
+}
+
+func TestHandler_CompleteNewlyPublishedTables(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                 string
+		newlyPublishedTables []string
+		execFn               func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error)
+
+		wantNewlyPublishedTables []string
+		wantErr                  error
+	}{
+		{
+			name:                 "ok",
+			newlyPublishedTables: []string{"public.events"},
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				require.Equal(t, deletePendingTablesQuery, query)
+				require.Equal(t, []any{"test_publication", []string{"public.events"}}, args)
+				return pglib.CommandTag{}, nil
+			},
+
+			wantNewlyPublishedTables: nil,
+			wantErr:                  nil,
+		},
+		{
+			name:                 "ok - no newly published tables",
+			newlyPublishedTables: nil,
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				return pglib.CommandTag{}, fmt.Errorf("unexpected exec call: %d", i)
+			},
+
+			wantNewlyPublishedTables: nil,
+			wantErr:                  nil,
+		},
+		{
+			name:                 "error - removing pending tables",
+			newlyPublishedTables: []string{"public.events"},
+			execFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+				return pglib.CommandTag{}, errTest
+			},
+
+			wantNewlyPublishedTables: []string{"public.events"},
+			wantErr:                  errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				logger:               log.NewNoopLogger(),
+				publication:          &PublicationConfig{Name: "test_publication"},
+				newlyPublishedTables: tc.newlyPublishedTables,
+				pgConnBuilder: func() (pglib.Querier, error) {
+					return &pgmocks.Querier{ExecFn: tc.execFn}, nil
+				},
+			}
+
+			err := h.CompleteNewlyPublishedTables(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantNewlyPublishedTables, h.NewlyPublishedTables())
+		})
+	}
+}
+
+func TestHandler_getPluginArguments(t *testing.T) {
+	t.Parallel()
+
+	h := &Handler{}
+	require.Equal(t, pluginArguments, h.getPluginArguments())
+
+	formatV1Arguments := PluginConfig{FormatVersion: FormatVersion1}.arguments()
+	h = &Handler{basePluginArguments: formatV1Arguments}
+	require.Equal(t, formatV1Arguments, h.getPluginArguments())
+}
+
+func TestEscapeWal2jsonName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "no special characters",
+			input: "users",
+			want:  "users",
+		},
+		{
+			name:  "special characters",
+			input: `my table,with.special*chars\`,
+			want:  `my\ table\,with\.special\*chars\\`,
+		},
+		{
+			name:  "single quote",
+			input: "user's",
+			want:  `user\''s`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.want, escapeWal2jsonName(tc.input))
+		})
+	}
+}
+
+func newMockRows(values [][]any) *pgmocks.Rows {
+	return &pgmocks.Rows{
+		NextFn: func(i uint) bool { return i <= uint(len(values)) },
+		ScanFn: func(i uint, dest ...any) error {
+			row := values[i-1]
+			for j := range dest {
+				switch d := dest[j].(type) {
+				case *string:
+					*d = row[j].(string)
+				case *bool:
+					*d = row[j].(bool)
+				default:
+					return fmt.Errorf("unexpected scan type: %T", d)
+				}
+			}
+			return nil
+		},
+		ErrFn: func() error { return nil },
+	}
+}
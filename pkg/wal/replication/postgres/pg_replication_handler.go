@@ -7,11 +7,16 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"sync"
+	"sync/atomic"
 
 	pglib "github.com/xataio/pgstream/internal/postgres"
 	loglib "github.com/xataio/pgstream/pkg/log"
+	"github.com/xataio/pgstream/pkg/otel"
 	"github.com/xataio/pgstream/pkg/schemalog"
 	"github.com/xataio/pgstream/pkg/wal/replication"
+
+	"go.opentelemetry.io/otel/metric"
 )
 
 // Handler handles the postgres replication slot operations
@@ -27,7 +32,36 @@ type Handler struct {
 	excludedTables pglib.SchemaTableMap
 	includedTables pglib.SchemaTableMap
 
+	// basePluginArguments are the plugin arguments derived from the plugin
+	// configuration, extended with the publication tables when a publication
+	// is configured.
+	basePluginArguments  []string
+	pluginArguments      []string
+	outputPlugin         string
+	publication          *PublicationConfig
+	newlyPublishedTables []string
+
+	slotConfig SlotConfig
+
 	lsnParser replication.LSNParser
+
+	// checkpointedLSN returns the LSN persisted outside of the replication
+	// slot, if any
+	checkpointedLSN func(ctx context.Context) (replication.LSN, error)
+
+	meter metric.Meter
+
+	// connMutex protects the replication connection, since the standby status
+	// updates can be sent concurrently with the connection being reset.
+	connMutex sync.RWMutex
+
+	// lastSyncedLSN is the last LSN position confirmed to postgres, used to
+	// resume the replication when the connection is reset. It's reported as
+	// the flushed position.
+	lastSyncedLSN atomic.Uint64
+	// lastReceivedLSN is the last LSN position received from postgres. It's
+	// reported as the written position.
+	lastReceivedLSN atomic.Uint64
 }
 
 type Config struct {
@@ -40,6 +74,51 @@ type Config struct {
 	ExcludeTables []string
 	// List of qualified tables included for replication.
 	IncludeTables []string
+	// Publication to keep in sync with the included tables. When provided,
+	// only the published tables will be decoded by the replication slot.
+	Publication *PublicationConfig
+	// Slot defines how the replication slot is created when it doesn't exist.
+	Slot SlotConfig
+	// Plugin defines the output plugin of the replication slot and the
+	// options used when starting the replication.
+	Plugin PluginConfig
+}
+
+// PluginConfig defines the output plugin options.
+type PluginConfig struct {
+	// Name is the output plugin of the replication slot, one of wal2json or
+	// decoderbufs. Defaults to wal2json. The decoderbufs plugin has no output
+	// options, so the rest of the settings only apply to wal2json.
+	Name string
+	// FormatVersion is the wal2json output format. Format version 1 produces
+	// a single message per transaction, while format version 2 produces a
+	// message per change. Defaults to format version 2.
+	FormatVersion int
+	// IncludeTransaction emits the begin and commit records of the
+	// transactions, along with their transaction id. Only supported by format
+	// version 2.
+	IncludeTransaction bool
+}
+
+// SlotConfig defines the options used to create the replication slot.
+type SlotConfig struct {
+	// Temporary creates a temporary replication slot, which is dropped when
+	// the replication connection is closed and therefore never retains WAL
+	// after disconnecting. Useful for ephemeral streams. Events produced while
+	// the connection is being reset will not be replicated.
+	Temporary bool
+	// TwoPhase enables the decoding of prepared transactions, if supported by
+	// the output plugin. Requires postgres 15+.
+	TwoPhase bool
+	// Failover enables the synchronisation of the replication slot to the
+	// standbys. Requires postgres 17+.
+	Failover bool
+	// CreateIfMissing creates the replication slot when it doesn't exist
+	// when the replication is started, instead of failing. By default, the
+	// slot must have been created beforehand, i.e. with the init command, so
+	// that a misconfigured slot name doesn't silently start retaining WAL.
+	// Temporary slots are always created.
+	CreateIfMissing bool
 }
 
 type Option func(h *Handler)
@@ -51,6 +130,27 @@ const (
 	logSystemID    = "system_id"
 )
 
+// Supported output plugins.
+const (
+	PluginWal2JSON    = "wal2json"
+	PluginDecoderbufs = "decoderbufs"
+)
+
+const (
+	FormatVersion1 = 1
+	FormatVersion2 = 2
+)
+
+var (
+	ErrReplicationSlotNotFound = errors.New("replication slot does not exist")
+
+	errUnsupportedFormatVersion     = errors.New("unsupported wal2json format version")
+	errTransactionFormatVersion1    = errors.New("including the transaction records is only supported by wal2json format version 2")
+	errUnsupportedPlugin            = errors.New("unsupported output plugin")
+	errDecoderbufsPluginOptions     = errors.New("the format version and transaction records are only supported by the wal2json plugin")
+	errPublicationUnsupportedPlugin = errors.New("publications are only supported by the wal2json plugin")
+)
+
 var pluginArguments = []string{
 	`"include-timestamp" '1'`,
 	`"format-version" '2'`,
@@ -59,6 +159,69 @@ var pluginArguments = []string{
 	`"include-transaction" '0'`,
 }
 
+// OutputPlugin returns the configured output plugin, or wal2json if none is
+// set.
+func (c PluginConfig) OutputPlugin() string {
+	if c.Name == "" {
+		return PluginWal2JSON
+	}
+	return c.Name
+}
+
+// arguments returns the wal2json plugin arguments for the configured format.
+// Format version 1 messages can't be written in chunks, since each message
+// needs to contain the full transaction to be parsed. The decoderbufs plugin
+// takes no arguments.
+func (c PluginConfig) arguments() []string {
+	if c.OutputPlugin() == PluginDecoderbufs {
+		return nil
+	}
+	if c.FormatVersion == FormatVersion1 {
+		return []string{
+			`"include-timestamp" '1'`,
+			`"format-version" '1'`,
+			`"write-in-chunks" '0'`,
+			`"include-lsn" '1'`,
+		}
+	}
+	if !c.IncludeTransaction {
+		return pluginArguments
+	}
+	return []string{
+		`"include-timestamp" '1'`,
+		`"format-version" '2'`,
+		`"write-in-chunks" '1'`,
+		`"include-lsn" '1'`,
+		`"include-transaction" '1'`,
+		`"include-xids" '1'`,
+	}
+}
+
+func (c PluginConfig) validate() error {
+	switch c.OutputPlugin() {
+	case PluginWal2JSON:
+	case PluginDecoderbufs:
+		if c.FormatVersion != 0 || c.IncludeTransaction {
+			return errDecoderbufsPluginOptions
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", errUnsupportedPlugin, c.Name)
+	}
+
+	switch c.FormatVersion {
+	case 0, FormatVersion2:
+		return nil
+	case FormatVersion1:
+		if c.IncludeTransaction {
+			return errTransactionFormatVersion1
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: %d", errUnsupportedFormatVersion, c.FormatVersion)
+	}
+}
+
 // NewHandler returns a new postgres replication handler for the database on input.
 func NewHandler(ctx context.Context, cfg Config, opts ...Option) (*Handler, error) {
 	pgReplicationConn, err := pglib.NewReplicationConn(ctx, cfg.PostgresURL)
@@ -71,6 +234,14 @@ func NewHandler(ctx context.Context, cfg Config, opts ...Option) (*Handler, erro
 		return nil, fmt.Errorf("identifySystem failed: %w", err)
 	}
 
+	if err := cfg.Plugin.validate(); err != nil {
+		return nil, err
+	}
+	// the publication tables are passed to wal2json as the tables to decode
+	if cfg.Publication != nil && cfg.Plugin.OutputPlugin() != PluginWal2JSON {
+		return nil, errPublicationUnsupportedPlugin
+	}
+
 	replicationSlotName := cfg.ReplicationSlotName
 	if replicationSlotName == "" {
 		replicationSlotName = pglib.DefaultReplicationSlotName(sysID.DBName)
@@ -90,6 +261,9 @@ func NewHandler(ctx context.Context, cfg Config, opts ...Option) (*Handler, erro
 		pgConnBuilder:            connBuilder,
 		pgReplicationConnBuilder: replicationConnBuilder,
 		lsnParser:                &LSNParser{},
+		slotConfig:               cfg.Slot,
+		outputPlugin:             cfg.Plugin.OutputPlugin(),
+		basePluginArguments:      cfg.Plugin.arguments(),
 		logFields: loglib.Fields{
 			logSystemID:    sysID.SystemID,
 			logDBName:      sysID.DBName,
@@ -119,9 +293,16 @@ func NewHandler(ctx context.Context, cfg Config, opts ...Option) (*Handler, erro
 		opt(h)
 	}
 
+	if cfg.Publication != nil {
+		if err := h.syncPublication(ctx, cfg.Publication); err != nil {
+			h.logger.Error(err, "syncing publication")
+			return nil, err
+		}
+	}
+
 	// make sure the replication slot we are going to use exists
-	if err := h.verifyReplicationSlotExists(ctx); err != nil {
-		h.logger.Error(err, "verifying replication slot")
+	if err := h.ensureReplicationSlot(ctx); err != nil {
+		h.logger.Error(err, "ensuring replication slot")
 		return nil, err
 	}
 
@@ -136,10 +317,31 @@ func WithLogger(l loglib.Logger) Option {
 	}
 }
 
+// WithInstrumentation enables the metrics of the handler setup, such as the
+// unlogged tables skipped from the publication.
+func WithInstrumentation(i *otel.Instrumentation) Option {
+	return func(h *Handler) {
+		if i != nil {
+			h.meter = i.Meter
+		}
+	}
+}
+
+// WithCheckpointedLSN resumes the replication from the LSN returned by the
+// function on input when it's ahead of the confirmed position of the
+// replication slot, which happens when the checkpoint is persisted before the
+// slot is synced.
+func WithCheckpointedLSN(fn func(ctx context.Context) (replication.LSN, error)) Option {
+	return func(h *Handler) {
+		h.checkpointedLSN = fn
+	}
+}
+
 // StartReplication will start the replication process on the configured
 // replication slot. It will check for the last synced LSN
 // (confirmed_flush_lsn), and if there isn't one, it will start replication from
-// the restart_lsn position.
+// the restart_lsn position. If a checkpointed LSN is configured and it's ahead
+// of the last synced LSN, the replication starts from it instead.
 func (h *Handler) StartReplication(ctx context.Context) error {
 	conn, err := h.pgConnBuilder()
 	if err != nil {
@@ -156,6 +358,20 @@ func (h *Handler) StartReplication(ctx context.Context) error {
 		logLSNPosition: h.lsnParser.ToString(startPos),
 	})
 
+	if h.checkpointedLSN != nil {
+		checkpointedPos, err := h.checkpointedLSN(ctx)
+		if err != nil {
+			return fmt.Errorf("read checkpointed position: %w", err)
+		}
+		if checkpointedPos > startPos {
+			h.logger.Info("resuming replication from checkpointed LSN position", h.logFields, loglib.Fields{
+				logLSNPosition:         h.lsnParser.ToString(checkpointedPos),
+				"slot_synced_position": h.lsnParser.ToString(startPos),
+			})
+			startPos = checkpointedPos
+		}
+	}
+
 	if startPos == 0 {
 		startPos, err = h.getRestartLSN(ctx, conn, h.pgReplicationSlotName)
 		if err != nil {
@@ -172,13 +388,22 @@ func (h *Handler) StartReplicationFromLSN(ctx context.Context, lsn replication.L
 	h.logFields[logLSNPosition] = h.lsnParser.ToString(lsn)
 	h.logger.Trace("set start LSN", h.logFields)
 
-	err := h.pgReplicationConn.StartReplication(
+	// the start position is the flushed position until the first sync, so
+	// that the status updates sent in between never confirm the WAL received
+	// since
+	h.lastReceivedLSN.Store(uint64(lsn))
+	h.lastSyncedLSN.Store(uint64(lsn))
+	err := h.getReplicationConn().StartReplication(
 		ctx, pglib.ReplicationConfig{
 			SlotName:        h.pgReplicationSlotName,
 			StartPos:        uint64(lsn),
-			PluginArguments: pluginArguments,
+			PluginArguments: h.getPluginArguments(),
 		})
 	if err != nil {
+		objectInUseErr := &pglib.ErrObjectInUse{}
+		if errors.As(err, &objectInUseErr) {
+			return fmt.Errorf("startReplication: %w: %w", replication.ErrSlotInUse, err)
+		}
 		return fmt.Errorf("startReplication: %w", err)
 	}
 
@@ -190,7 +415,7 @@ func (h *Handler) StartReplicationFromLSN(ctx context.Context, lsn replication.L
 // ReceiveMessage will listen for messages from the WAL. It returns an error if
 // an unexpected message is received.
 func (h *Handler) ReceiveMessage(ctx context.Context) (*replication.Message, error) {
-	pgMsg, err := h.pgReplicationConn.ReceiveMessage(ctx)
+	pgMsg, err := h.getReplicationConn().ReceiveMessage(ctx)
 	if err != nil {
 		switch {
 		case h.isExcludedTableError(err):
@@ -201,6 +426,8 @@ func (h *Handler) ReceiveMessage(ctx context.Context) (*replication.Message, err
 		}
 	}
 
+	h.updateReceivedLSN(pgMsg.LSN)
+
 	return &replication.Message{
 		LSN:            replication.LSN(pgMsg.LSN),
 		Data:           pgMsg.WALData,
@@ -211,16 +438,65 @@ func (h *Handler) ReceiveMessage(ctx context.Context) (*replication.Message, err
 
 // SyncLSN notifies Postgres how far we have processed in the WAL.
 func (h *Handler) SyncLSN(ctx context.Context, lsn replication.LSN) error {
-	err := h.pgReplicationConn.SendStandbyStatusUpdate(ctx, uint64(lsn))
-	if err != nil {
+	if err := h.sendStandbyStatus(ctx, uint64(lsn)); err != nil {
 		return fmt.Errorf("syncLSN: send status update: %w", err)
 	}
+	h.lastSyncedLSN.Store(uint64(lsn))
 	h.logger.Trace("stored new LSN position", loglib.Fields{
 		logLSNPosition: h.lsnParser.ToString(lsn),
 	})
 	return nil
 }
 
+// SyncedLSN returns the last position notified to Postgres, or 0 if none has
+// been notified yet.
+func (h *Handler) SyncedLSN() replication.LSN {
+	return replication.LSN(h.lastSyncedLSN.Load())
+}
+
+// SendStandbyStatusUpdate reports the current replication progress to
+// Postgres, without advancing the synced LSN. It keeps the replication
+// connection alive while events are being processed.
+func (h *Handler) SendStandbyStatusUpdate(ctx context.Context) error {
+	if err := h.sendStandbyStatus(ctx, h.lastSyncedLSN.Load()); err != nil {
+		return fmt.Errorf("send status update: %w", err)
+	}
+	return nil
+}
+
+// sendStandbyStatus reports the flushed LSN on input separately from the last
+// received LSN, so that postgres only releases the WAL that has been synced.
+// Nothing is sent until there's a flushed LSN, since a zero flush position is
+// replaced by the write position, which would confirm WAL that hasn't been
+// processed.
+func (h *Handler) sendStandbyStatus(ctx context.Context, flushedLSN uint64) error {
+	if flushedLSN == 0 {
+		h.logger.Trace("skipping standby status update, no flushed position yet", h.logFields)
+		return nil
+	}
+	h.connMutex.RLock()
+	defer h.connMutex.RUnlock()
+	return h.pgReplicationConn.SendStandbyStatusUpdate(ctx, pglib.StandbyStatus{
+		WritePosition: max(h.lastReceivedLSN.Load(), flushedLSN),
+		FlushPosition: flushedLSN,
+	})
+}
+
+func (h *Handler) updateReceivedLSN(lsn uint64) {
+	for {
+		current := h.lastReceivedLSN.Load()
+		if lsn <= current || h.lastReceivedLSN.CompareAndSwap(current, lsn) {
+			return
+		}
+	}
+}
+
+func (h *Handler) getReplicationConn() pglib.ReplicationQuerier {
+	h.connMutex.RLock()
+	defer h.connMutex.RUnlock()
+	return h.pgReplicationConn
+}
+
 // GetReplicationLag will return the consumer current replication lag. This
 // value is different from the postgres replication lag, which takes into
 // consideration all consumers and ongoing transactions.
@@ -240,6 +516,41 @@ func (h *Handler) GetReplicationLag(ctx context.Context) (int64, error) {
 	return lag, nil
 }
 
+const slotStatusQuery = `SELECT active,
+	COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn), 0)::bigint,
+	COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), confirmed_flush_lsn), 0)::bigint,
+	COALESCE(pg_wal_lsn_diff(confirmed_flush_lsn, restart_lsn), 0)::bigint,
+	COALESCE(wal_status, ''),
+	COALESCE(safe_wal_size, -1),
+	pg_size_bytes(current_setting('max_slot_wal_keep_size'))
+	FROM pg_replication_slots WHERE slot_name=$1`
+
+// GetReplicationSlotStatus returns the current state of the replication slot,
+// including the amount of WAL it's retaining on the source.
+func (h *Handler) GetReplicationSlotStatus(ctx context.Context) (*replication.SlotStatus, error) {
+	conn, err := h.pgConnBuilder()
+	if err != nil {
+		return nil, fmt.Errorf("creating pg connection: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	status := &replication.SlotStatus{}
+	err = conn.QueryRow(ctx, []any{
+		&status.Active,
+		&status.RetainedWALBytes,
+		&status.ConfirmedFlushLagBytes,
+		&status.RestartLagBytes,
+		&status.WALStatus,
+		&status.SafeWALSizeBytes,
+		&status.MaxSlotWALKeepSizeBytes,
+	}, slotStatusQuery, h.pgReplicationSlotName)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving replication slot status: %w", err)
+	}
+
+	return status, nil
+}
+
 func (h *Handler) GetCurrentLSN(ctx context.Context) (replication.LSN, error) {
 	conn, err := h.pgConnBuilder()
 	if err != nil {
@@ -259,19 +570,63 @@ func (h *Handler) GetReplicationSlotName() string {
 	return h.pgReplicationSlotName
 }
 
+// ResetConnection re-establishes the replication connection. The replication
+// is resumed from the last LSN synced by this handler, so that it never
+// resumes from a position newer than the last checkpoint. If no LSN has been
+// synced yet, the replication is resumed from the slot position.
 func (h *Handler) ResetConnection(ctx context.Context) error {
 	conn, err := h.pgReplicationConnBuilder()
 	if err != nil {
 		return err
 	}
+	h.connMutex.Lock()
 	if h.pgReplicationConn != nil {
 		h.pgReplicationConn.Close(ctx)
 	}
 	h.pgReplicationConn = conn
+	h.connMutex.Unlock()
+
+	// temporary slots are dropped along with the connection that created
+	// them, so a new one needs to be created. The replication will resume
+	// from the new slot position.
+	if h.slotConfig.Temporary {
+		h.logger.Warn(nil, "recreating temporary replication slot, events produced since the connection was lost will not be replicated", h.logFields)
+		if err := h.createReplicationSlot(ctx); err != nil {
+			return err
+		}
+		return h.StartReplication(ctx)
+	}
 
+	if lsn := h.lastSyncedLSN.Load(); lsn != 0 {
+		return h.StartReplicationFromLSN(ctx, replication.LSN(lsn))
+	}
 	return h.StartReplication(ctx)
 }
 
+func (h *Handler) getPluginArguments() []string {
+	if h.getOutputPlugin() == PluginDecoderbufs {
+		return nil
+	}
+	if len(h.pluginArguments) > 0 {
+		return h.pluginArguments
+	}
+	return h.getBasePluginArguments()
+}
+
+func (h *Handler) getOutputPlugin() string {
+	if h.outputPlugin == "" {
+		return PluginWal2JSON
+	}
+	return h.outputPlugin
+}
+
+func (h *Handler) getBasePluginArguments() []string {
+	if len(h.basePluginArguments) > 0 {
+		return h.basePluginArguments
+	}
+	return pluginArguments
+}
+
 // GetLSNParser returns a postgres implementation of the LSN parser.
 func (h *Handler) GetLSNParser() replication.LSNParser {
 	return h.lsnParser
@@ -312,21 +667,64 @@ func (h *Handler) getLastSyncedLSN(ctx context.Context, conn pglib.Querier) (rep
 	return h.lsnParser.FromString(confirmedFlushLSN)
 }
 
-func (h *Handler) verifyReplicationSlotExists(ctx context.Context) error {
+// ensureReplicationSlot makes sure the configured replication slot exists,
+// creating it if it doesn't when the creation is enabled. Temporary slots
+// are always created, since they only exist for the duration of the
+// replication connection.
+func (h *Handler) ensureReplicationSlot(ctx context.Context) error {
+	if h.slotConfig.Temporary {
+		return h.createReplicationSlot(ctx)
+	}
+
+	exists, err := h.replicationSlotExists(ctx)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	if !h.slotConfig.CreateIfMissing {
+		return fmt.Errorf("%w: %q must be created before starting the replication, either with the init command or by enabling its creation", ErrReplicationSlotNotFound, h.pgReplicationSlotName)
+	}
+
+	return h.createReplicationSlot(ctx)
+}
+
+func (h *Handler) replicationSlotExists(ctx context.Context) (bool, error) {
 	slotExists := false
 	conn, err := h.pgConnBuilder()
 	if err != nil {
-		return fmt.Errorf("creating pg connection: %w", err)
+		return false, fmt.Errorf("creating pg connection: %w", err)
 	}
 	defer conn.Close(context.Background())
 
 	err = conn.QueryRow(ctx, []any{&slotExists}, `SELECT EXISTS(SELECT 1 FROM pg_replication_slots WHERE slot_name=$1)`, h.pgReplicationSlotName)
 	if err != nil {
-		return fmt.Errorf("retrieving replication slot: %w", err)
+		return false, fmt.Errorf("retrieving replication slot: %w", err)
 	}
-	if !slotExists {
-		return fmt.Errorf("replication slot %q does not exist", h.pgReplicationSlotName)
+	return slotExists, nil
+}
+
+// createReplicationSlot creates the replication slot on the replication
+// connection, so that temporary slots are bound to it.
+func (h *Handler) createReplicationSlot(ctx context.Context) error {
+	err := h.pgReplicationConn.CreateReplicationSlot(ctx, pglib.CreateReplicationSlotConfig{
+		SlotName:  h.pgReplicationSlotName,
+		Plugin:    h.getOutputPlugin(),
+		Temporary: h.slotConfig.Temporary,
+		TwoPhase:  h.slotConfig.TwoPhase,
+		Failover:  h.slotConfig.Failover,
+	})
+	if err != nil {
+		return fmt.Errorf("creating replication slot %q: %w", h.pgReplicationSlotName, err)
 	}
+
+	h.logger.Info("replication slot created", h.logFields, loglib.Fields{
+		"temporary": h.slotConfig.Temporary,
+		"two_phase": h.slotConfig.TwoPhase,
+		"failover":  h.slotConfig.Failover,
+	})
 	return nil
 }
 
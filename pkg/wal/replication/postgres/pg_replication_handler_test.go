@@ -23,6 +23,7 @@ func TestHandler_StartReplication(t *testing.T) {
 		replicationConn pglib.ReplicationQuerier
 		connBuilder     func() (pglib.Querier, error)
 		slotName        string
+		checkpointedLSN func(context.Context) (replication.LSN, error)
 
 		wantErr error
 	}{
@@ -41,8 +42,8 @@ func TestHandler_StartReplication(t *testing.T) {
 					require.Equal(t, pluginArguments, cfg.PluginArguments)
 					return nil
 				},
-				SendStandbyStatusUpdateFn: func(ctx context.Context, lsn uint64) error {
-					require.Equal(t, testLSN, lsn)
+				SendStandbyStatusUpdateFn: func(ctx context.Context, status pglib.StandbyStatus) error {
+					require.Equal(t, pglib.StandbyStatus{WritePosition: testLSN, FlushPosition: testLSN}, status)
 					return nil
 				},
 			},
@@ -86,8 +87,8 @@ func TestHandler_StartReplication(t *testing.T) {
 					require.Equal(t, pluginArguments, cfg.PluginArguments)
 					return nil
 				},
-				SendStandbyStatusUpdateFn: func(ctx context.Context, lsn uint64) error {
-					require.Equal(t, testLSN, lsn)
+				SendStandbyStatusUpdateFn: func(ctx context.Context, status pglib.StandbyStatus) error {
+					require.Equal(t, pglib.StandbyStatus{WritePosition: testLSN, FlushPosition: testLSN}, status)
 					return nil
 				},
 			},
@@ -252,7 +253,7 @@ func TestHandler_StartReplication(t *testing.T) {
 					require.Equal(t, pluginArguments, cfg.PluginArguments)
 					return nil
 				},
-				SendStandbyStatusUpdateFn: func(ctx context.Context, lsn uint64) error {
+				SendStandbyStatusUpdateFn: func(ctx context.Context, status pglib.StandbyStatus) error {
 					return errTest
 				},
 			},
@@ -277,6 +278,100 @@ func TestHandler_StartReplication(t *testing.T) {
 			},
 			slotName: testSlot,
 
+			wantErr: errTest,
+		},
+		{
+			name: "ok - with checkpointed LSN ahead of the last synced LSN",
+			replicationConn: &pgmocks.ReplicationConn{
+				StartReplicationFn: func(ctx context.Context, cfg pglib.ReplicationConfig) error {
+					require.Equal(t, testLSN, cfg.StartPos)
+					return nil
+				},
+				SendStandbyStatusUpdateFn: func(ctx context.Context, status pglib.StandbyStatus) error {
+					require.Equal(t, pglib.StandbyStatus{WritePosition: testLSN, FlushPosition: testLSN}, status)
+					return nil
+				},
+			},
+			connBuilder: func() (pglib.Querier, error) {
+				return &pgmocks.Querier{
+					QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+						switch query {
+						case "select confirmed_flush_lsn from pg_replication_slots where slot_name=$1":
+							lsn, ok := dest[0].(*string)
+							require.True(t, ok)
+							*lsn = "1/0"
+							return nil
+						default:
+							return fmt.Errorf("unexpected query: %s", query)
+						}
+					},
+					CloseFn: func(ctx context.Context) error { return nil },
+				}, nil
+			},
+			slotName: testSlot,
+			checkpointedLSN: func(ctx context.Context) (replication.LSN, error) {
+				return replication.LSN(testLSN), nil
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "ok - with checkpointed LSN behind the last synced LSN",
+			replicationConn: &pgmocks.ReplicationConn{
+				StartReplicationFn: func(ctx context.Context, cfg pglib.ReplicationConfig) error {
+					require.Equal(t, testLSN, cfg.StartPos)
+					return nil
+				},
+				SendStandbyStatusUpdateFn: func(ctx context.Context, status pglib.StandbyStatus) error {
+					return nil
+				},
+			},
+			connBuilder: func() (pglib.Querier, error) {
+				return &pgmocks.Querier{
+					QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+						switch query {
+						case "select confirmed_flush_lsn from pg_replication_slots where slot_name=$1":
+							lsn, ok := dest[0].(*string)
+							require.True(t, ok)
+							*lsn = testLSNStr
+							return nil
+						default:
+							return fmt.Errorf("unexpected query: %s", query)
+						}
+					},
+					CloseFn: func(ctx context.Context) error { return nil },
+				}, nil
+			},
+			slotName: testSlot,
+			checkpointedLSN: func(ctx context.Context) (replication.LSN, error) {
+				return replication.LSN(1), nil
+			},
+
+			wantErr: nil,
+		},
+		{
+			name: "error - reading checkpointed LSN",
+			replicationConn: &pgmocks.ReplicationConn{
+				StartReplicationFn: func(ctx context.Context, cfg pglib.ReplicationConfig) error {
+					return errors.New("StartReplicationFn: should not be called")
+				},
+			},
+			connBuilder: func() (pglib.Querier, error) {
+				return &pgmocks.Querier{
+					QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+						lsn, ok := dest[0].(*string)
+						require.True(t, ok)
+						*lsn = testLSNStr
+						return nil
+					},
+					CloseFn: func(ctx context.Context) error { return nil },
+				}, nil
+			},
+			slotName: testSlot,
+			checkpointedLSN: func(ctx context.Context) (replication.LSN, error) {
+				return 0, errTest
+			},
+
 			wantErr: errTest,
 		},
 	}
@@ -292,6 +387,7 @@ func TestHandler_StartReplication(t *testing.T) {
 				pgReplicationSlotName: tc.slotName,
 				lsnParser:             NewLSNParser(),
 				logFields:             log.Fields{},
+				checkpointedLSN:       tc.checkpointedLSN,
 			}
 
 			err := h.StartReplication(context.Background())
@@ -300,6 +396,116 @@ func TestHandler_StartReplication(t *testing.T) {
 	}
 }
 
+func TestHandler_ResetConnection(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		lastSyncedLSN    uint64
+		slotConfig       SlotConfig
+		startReplication func(ctx context.Context, cfg pglib.ReplicationConfig) error
+
+		wantSlotCreated bool
+		wantErr         error
+	}{
+		{
+			name:          "ok - resume from last synced LSN",
+			lastSyncedLSN: testLSN,
+			startReplication: func(ctx context.Context, cfg pglib.ReplicationConfig) error {
+				require.Equal(t, testLSN, cfg.StartPos)
+				return nil
+			},
+
+			wantErr: nil,
+		},
+		{
+			name:          "ok - resume from slot position",
+			lastSyncedLSN: 0,
+			startReplication: func(ctx context.Context, cfg pglib.ReplicationConfig) error {
+				// confirmed flush LSN from the query mock
+				require.Equal(t, testLSN+1, cfg.StartPos)
+				return nil
+			},
+
+			wantErr: nil,
+		},
+		{
+			name:          "ok - temporary slot recreated",
+			lastSyncedLSN: testLSN,
+			slotConfig:    SlotConfig{Temporary: true},
+			startReplication: func(ctx context.Context, cfg pglib.ReplicationConfig) error {
+				// the new slot position is used, since the synced LSN is not
+				// available in the recreated slot
+				require.Equal(t, testLSN+1, cfg.StartPos)
+				return nil
+			},
+
+			wantSlotCreated: true,
+			wantErr:         nil,
+		},
+		{
+			name:          "error - replication slot in use",
+			lastSyncedLSN: testLSN,
+			startReplication: func(ctx context.Context, cfg pglib.ReplicationConfig) error {
+				return &pglib.ErrObjectInUse{Details: "replication slot is active for PID 42"}
+			},
+
+			wantErr: replication.ErrSlotInUse,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			oldConnClosed := false
+			slotCreated := false
+			h := &Handler{
+				logger:                log.NewNoopLogger(),
+				pgReplicationSlotName: testSlot,
+				slotConfig:            tc.slotConfig,
+				lsnParser:             NewLSNParser(),
+				logFields:             log.Fields{},
+				pgReplicationConn: &pgmocks.ReplicationConn{
+					CloseFn: func(ctx context.Context) error {
+						oldConnClosed = true
+						return nil
+					},
+				},
+				pgReplicationConnBuilder: func() (pglib.ReplicationQuerier, error) {
+					return &pgmocks.ReplicationConn{
+						StartReplicationFn: tc.startReplication,
+						SendStandbyStatusUpdateFn: func(ctx context.Context, status pglib.StandbyStatus) error {
+							return nil
+						},
+						CreateReplicationSlotFn: func(ctx context.Context, cfg pglib.CreateReplicationSlotConfig) error {
+							require.True(t, cfg.Temporary)
+							slotCreated = true
+							return nil
+						},
+					}, nil
+				},
+				pgConnBuilder: func() (pglib.Querier, error) {
+					return &pgmocks.Querier{
+						QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+							lsn, ok := dest[0].(*string)
+							require.True(t, ok)
+							*lsn = NewLSNParser().ToString(replication.LSN(testLSN + 1))
+							return nil
+						},
+					}, nil
+				},
+			}
+			h.lastSyncedLSN.Store(tc.lastSyncedLSN)
+
+			err := h.ResetConnection(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantSlotCreated, slotCreated)
+			require.True(t, oldConnClosed)
+		})
+	}
+}
+
 func TestHandler_ReceiveMessage(t *testing.T) {
 	t.Parallel()
 
@@ -407,6 +613,85 @@ func TestHandler_ReceiveMessage(t *testing.T) {
 	}
 }
 
+func TestHandler_SendStandbyStatusUpdate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		lastSyncedLSN uint64
+		receivedLSN   uint64
+		sendStatusErr error
+
+		// wantStatus is nil when no status update is expected
+		wantStatus *pglib.StandbyStatus
+		wantErr    error
+	}{
+		{
+			name:          "ok - flushed position behind received position",
+			lastSyncedLSN: testLSN,
+			receivedLSN:   testLSN + 10,
+
+			wantStatus: &pglib.StandbyStatus{WritePosition: testLSN + 10, FlushPosition: testLSN},
+			wantErr:    nil,
+		},
+		{
+			name:          "ok - keepalive reply requested before the first sync",
+			lastSyncedLSN: 0,
+			receivedLSN:   testLSN + 10,
+
+			wantStatus: nil,
+			wantErr:    nil,
+		},
+		{
+			name:          "ok - nothing received since last sync",
+			lastSyncedLSN: testLSN,
+			receivedLSN:   0,
+
+			wantStatus: &pglib.StandbyStatus{WritePosition: testLSN, FlushPosition: testLSN},
+			wantErr:    nil,
+		},
+		{
+			name:          "error - sending status update",
+			lastSyncedLSN: testLSN,
+			receivedLSN:   testLSN,
+			sendStatusErr: errTest,
+
+			wantStatus: &pglib.StandbyStatus{WritePosition: testLSN, FlushPosition: testLSN},
+			wantErr:    errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := &Handler{
+				logger:    log.NewNoopLogger(),
+				lsnParser: NewLSNParser(),
+				pgReplicationConn: &pgmocks.ReplicationConn{
+					SendStandbyStatusUpdateFn: func(ctx context.Context, status pglib.StandbyStatus) error {
+						require.NotNil(t, tc.wantStatus, "unexpected standby status update")
+						require.Equal(t, *tc.wantStatus, status)
+						return tc.sendStatusErr
+					},
+					ReceiveMessageFn: func(ctx context.Context) (*pglib.ReplicationMessage, error) {
+						return &pglib.ReplicationMessage{LSN: tc.receivedLSN, ReplyRequested: true}, nil
+					},
+				},
+			}
+			h.lastSyncedLSN.Store(tc.lastSyncedLSN)
+
+			_, err := h.ReceiveMessage(context.Background())
+			require.NoError(t, err)
+
+			err = h.SendStandbyStatusUpdate(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			// the synced LSN is not advanced by the status updates
+			require.Equal(t, tc.lastSyncedLSN, h.lastSyncedLSN.Load())
+		})
+	}
+}
+
 func TestHandler_GetReplicationLag(t *testing.T) {
 	t.Parallel()
 
@@ -565,70 +850,90 @@ func TestHandler_GetCurrentLSN(t *testing.T) {
 	}
 }
 
-func TestHandler_verifyReplicationSlotExists(t *testing.T) {
+func TestHandler_ensureReplicationSlot(t *testing.T) {
 	t.Parallel()
 
+	slotExistsConnBuilder := func(exists bool) func() (pglib.Querier, error) {
+		return func() (pglib.Querier, error) {
+			return &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					require.Len(t, args, 1)
+					require.Equal(t, args[0], testSlot)
+					switch query {
+					case "SELECT EXISTS(SELECT 1 FROM pg_replication_slots WHERE slot_name=$1)":
+						require.Len(t, dest, 1)
+						slotExists, ok := dest[0].(*bool)
+						require.True(t, ok)
+						*slotExists = exists
+						return nil
+					default:
+						return fmt.Errorf("unexpected query: %s", query)
+					}
+				},
+				CloseFn: func(ctx context.Context) error { return nil },
+			}, nil
+		}
+	}
+
 	tests := []struct {
-		name        string
-		connBuilder func() (pglib.Querier, error)
-		slotExists  bool
-		wantErr     error
+		name          string
+		connBuilder   func() (pglib.Querier, error)
+		slotConfig    SlotConfig
+		createSlotErr error
+
+		wantSlotCreated bool
+		wantErr         error
 	}{
 		{
-			name: "ok - slot exists",
-			connBuilder: func() (pglib.Querier, error) {
-				return &pgmocks.Querier{
-					QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
-						require.Len(t, args, 1)
-						require.Equal(t, args[0], testSlot)
-						switch query {
-						case "SELECT EXISTS(SELECT 1 FROM pg_replication_slots WHERE slot_name=$1)":
-							require.Len(t, dest, 1)
-							exists, ok := dest[0].(*bool)
-							require.True(t, ok)
-							*exists = true
-							return nil
-						default:
-							return fmt.Errorf("unexpected query: %s", query)
-						}
-					},
-					CloseFn: func(ctx context.Context) error { return nil },
-				}, nil
-			},
-			slotExists: true,
-			wantErr:    nil,
+			name:        "ok - slot exists",
+			connBuilder: slotExistsConnBuilder(true),
+
+			wantSlotCreated: false,
+			wantErr:         nil,
+		},
+		{
+			name:        "ok - slot does not exist, created",
+			connBuilder: slotExistsConnBuilder(false),
+			slotConfig:  SlotConfig{TwoPhase: true, Failover: true, CreateIfMissing: true},
+
+			wantSlotCreated: true,
+			wantErr:         nil,
 		},
 		{
-			name: "error - slot does not exist",
+			name: "ok - temporary slot always created",
 			connBuilder: func() (pglib.Querier, error) {
-				return &pgmocks.Querier{
-					QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
-						require.Len(t, args, 1)
-						require.Equal(t, args[0], testSlot)
-						switch query {
-						case "SELECT EXISTS(SELECT 1 FROM pg_replication_slots WHERE slot_name=$1)":
-							require.Len(t, dest, 1)
-							exists, ok := dest[0].(*bool)
-							require.True(t, ok)
-							*exists = false
-							return nil
-						default:
-							return fmt.Errorf("unexpected query: %s", query)
-						}
-					},
-					CloseFn: func(ctx context.Context) error { return nil },
-				}, nil
+				return nil, errors.New("unexpected call to connection builder")
 			},
-			slotExists: false,
-			wantErr:    fmt.Errorf("replication slot %q does not exist", testSlot),
+			slotConfig: SlotConfig{Temporary: true},
+
+			wantSlotCreated: true,
+			wantErr:         nil,
+		},
+		{
+			name:        "error - slot does not exist and creation is disabled",
+			connBuilder: slotExistsConnBuilder(false),
+			slotConfig:  SlotConfig{},
+
+			wantSlotCreated: false,
+			wantErr:         fmt.Errorf("%w: %q must be created before starting the replication, either with the init command or by enabling its creation", ErrReplicationSlotNotFound, testSlot),
+		},
+		{
+			name:          "error - creating slot",
+			connBuilder:   slotExistsConnBuilder(false),
+			slotConfig:    SlotConfig{CreateIfMissing: true},
+			createSlotErr: errTest,
+
+			wantSlotCreated: true,
+			wantErr:         fmt.Errorf("creating replication slot %q: %w", testSlot, errTest),
 		},
 		{
 			name: "error - building connection",
 			connBuilder: func() (pglib.Querier, error) {
 				return nil, errTest
 			},
-			slotExists: false,
-			wantErr:    errTest,
+
+			wantSlotCreated: false,
+			wantErr:         errTest,
 		},
 		{
 			name: "error - query execution",
@@ -640,8 +945,9 @@ func TestHandler_verifyReplicationSlotExists(t *testing.T) {
 					CloseFn: func(ctx context.Context) error { return nil },
 				}, nil
 			},
-			slotExists: false,
-			wantErr:    fmt.Errorf("retrieving replication slot: %w", errTest),
+
+			wantSlotCreated: false,
+			wantErr:         fmt.Errorf("retrieving replication slot: %w", errTest),
 		},
 	}
 
@@ -649,16 +955,118 @@ func TestHandler_verifyReplicationSlotExists(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
+			slotCreated := false
 			h := Handler{
 				logger:                log.NewNoopLogger(),
 				pgConnBuilder:         tc.connBuilder,
 				pgReplicationSlotName: testSlot,
+				slotConfig:            tc.slotConfig,
+				pgReplicationConn: &pgmocks.ReplicationConn{
+					CreateReplicationSlotFn: func(ctx context.Context, cfg pglib.CreateReplicationSlotConfig) error {
+						require.Equal(t, pglib.CreateReplicationSlotConfig{
+							SlotName:  testSlot,
+							Plugin:    PluginWal2JSON,
+							Temporary: tc.slotConfig.Temporary,
+							TwoPhase:  tc.slotConfig.TwoPhase,
+							Failover:  tc.slotConfig.Failover,
+						}, cfg)
+						slotCreated = true
+						return tc.createSlotErr
+					},
+				},
 			}
 
-			err := h.verifyReplicationSlotExists(context.Background())
+			err := h.ensureReplicationSlot(context.Background())
 			if !errors.Is(err, tc.wantErr) {
 				require.Equal(t, tc.wantErr, err)
 			}
+			require.Equal(t, tc.wantSlotCreated, slotCreated)
+		})
+	}
+}
+
+func TestPluginConfig_arguments(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		cfg  PluginConfig
+
+		wantArguments []string
+		wantErr       error
+	}{
+		{
+			name: "ok - default",
+			cfg:  PluginConfig{},
+
+			wantArguments: pluginArguments,
+		},
+		{
+			name: "ok - format version 2 with transaction",
+			cfg:  PluginConfig{FormatVersion: FormatVersion2, IncludeTransaction: true},
+
+			wantArguments: []string{
+				`"include-timestamp" '1'`,
+				`"format-version" '2'`,
+				`"write-in-chunks" '1'`,
+				`"include-lsn" '1'`,
+				`"include-transaction" '1'`,
+				`"include-xids" '1'`,
+			},
+		},
+		{
+			name: "ok - format version 1",
+			cfg:  PluginConfig{FormatVersion: FormatVersion1},
+
+			wantArguments: []string{
+				`"include-timestamp" '1'`,
+				`"format-version" '1'`,
+				`"write-in-chunks" '0'`,
+				`"include-lsn" '1'`,
+			},
+		},
+		{
+			name: "ok - decoderbufs",
+			cfg:  PluginConfig{Name: PluginDecoderbufs},
+
+			wantArguments: nil,
+		},
+		{
+			name: "error - decoderbufs with transaction",
+			cfg:  PluginConfig{Name: PluginDecoderbufs, IncludeTransaction: true},
+
+			wantErr: errDecoderbufsPluginOptions,
+		},
+		{
+			name: "error - unsupported plugin",
+			cfg:  PluginConfig{Name: "pgoutput"},
+
+			wantErr: errUnsupportedPlugin,
+		},
+		{
+			name: "error - format version 1 with transaction",
+			cfg:  PluginConfig{FormatVersion: FormatVersion1, IncludeTransaction: true},
+
+			wantErr: errTransactionFormatVersion1,
+		},
+		{
+			name: "error - unsupported format version",
+			cfg:  PluginConfig{FormatVersion: 3},
+
+			wantErr: errUnsupportedFormatVersion,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.cfg.validate()
+			require.ErrorIs(t, err, tc.wantErr)
+			if tc.wantErr != nil {
+				return
+			}
+			require.Equal(t, tc.wantArguments, tc.cfg.arguments())
 		})
 	}
 }
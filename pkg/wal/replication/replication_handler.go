@@ -14,7 +14,9 @@ type Handler interface {
 	StartReplicationFromLSN(ctx context.Context, lsn LSN) error
 	ReceiveMessage(ctx context.Context) (*Message, error)
 	SyncLSN(ctx context.Context, lsn LSN) error
+	SendStandbyStatusUpdate(ctx context.Context) error
 	GetReplicationLag(ctx context.Context) (int64, error)
+	GetReplicationSlotStatus(ctx context.Context) (*SlotStatus, error)
 	GetCurrentLSN(ctx context.Context) (LSN, error)
 	ResetConnection(ctx context.Context) error
 	GetLSNParser() LSNParser
@@ -30,6 +32,31 @@ type Message struct {
 	ReplyRequested bool
 }
 
+// SlotStatus contains the state of the replication slot and the WAL it's
+// holding back on the source.
+type SlotStatus struct {
+	Active bool
+	// RetainedWALBytes is the amount of WAL retained on the source for the
+	// slot (current WAL position minus the slot restart LSN).
+	RetainedWALBytes int64
+	// ConfirmedFlushLagBytes is the distance between the current WAL position
+	// and the last position confirmed by the consumer.
+	ConfirmedFlushLagBytes int64
+	// RestartLagBytes is the distance between the last confirmed position and
+	// the slot restart LSN.
+	RestartLagBytes int64
+	// WALStatus is the availability of the WAL files claimed by the slot
+	// (reserved, extended, unreserved or lost).
+	WALStatus string
+	// SafeWALSizeBytes is the amount of WAL that can be written before the
+	// slot is in danger of being invalidated. It is -1 when
+	// max_slot_wal_keep_size is not set.
+	SafeWALSizeBytes int64
+	// MaxSlotWALKeepSizeBytes is the source max_slot_wal_keep_size setting, -1
+	// if unlimited.
+	MaxSlotWALKeepSizeBytes int64
+}
+
 // LSNParser handles the LSN type conversion
 type LSNParser interface {
 	ToString(LSN) string
@@ -38,4 +65,9 @@ type LSNParser interface {
 
 type LSN uint64
 
-var ErrConnTimeout = errors.New("connection timeout")
+var (
+	ErrConnTimeout = errors.New("connection timeout")
+	// ErrSlotInUse is returned when the replication slot is already being
+	// streamed by another backend.
+	ErrSlotInUse = errors.New("replication slot is in use")
+)
@@ -78,10 +78,20 @@ func (h *HandlerRetrier) SyncLSN(ctx context.Context, lsn replication.LSN) error
 	})
 }
 
+// SendStandbyStatusUpdate is not retried, since the status updates are sent
+// periodically.
+func (h *HandlerRetrier) SendStandbyStatusUpdate(ctx context.Context) error {
+	return h.inner.SendStandbyStatusUpdate(ctx)
+}
+
 func (h *HandlerRetrier) GetReplicationLag(ctx context.Context) (int64, error) {
 	return h.inner.GetReplicationLag(ctx)
 }
 
+func (h *HandlerRetrier) GetReplicationSlotStatus(ctx context.Context) (*replication.SlotStatus, error) {
+	return h.inner.GetReplicationSlotStatus(ctx)
+}
+
 func (h *HandlerRetrier) GetCurrentLSN(ctx context.Context) (replication.LSN, error) {
 	return h.inner.GetCurrentLSN(ctx)
 }
@@ -0,0 +1,252 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package slot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	pglib "github.com/xataio/pgstream/internal/postgres"
+)
+
+// Manager handles the lifecycle of the postgres logical replication slots.
+type Manager struct {
+	querier pglib.Querier
+	dbName  string
+	plugin  string
+}
+
+type Option func(m *Manager)
+
+// Slot contains the details of a logical replication slot.
+type Slot struct {
+	Name     string `json:"name"`
+	Plugin   string `json:"plugin"`
+	SlotType string `json:"slot_type"`
+	Database string `json:"database"`
+	Active   bool   `json:"active"`
+	// ActivePID is the process ID of the session streaming from the slot, 0
+	// if the slot is not active.
+	ActivePID         int32  `json:"active_pid"`
+	ConfirmedFlushLSN string `json:"confirmed_flush_lsn"`
+	RestartLSN        string `json:"restart_lsn"`
+	// LagBytes is the distance between the current WAL position and the last
+	// position confirmed by the slot consumer.
+	LagBytes int64 `json:"lag_bytes"`
+	// LagSize is the human readable representation of the lag.
+	LagSize   string `json:"lag_size"`
+	WALStatus string `json:"wal_status"`
+}
+
+// Slots is a list of replication slots.
+type Slots []*Slot
+
+const (
+	defaultPlugin = "wal2json"
+
+	slotColumns = `slot_name,
+	COALESCE(plugin, ''),
+	slot_type,
+	COALESCE(database, ''),
+	active,
+	COALESCE(active_pid, 0),
+	COALESCE(confirmed_flush_lsn::text, ''),
+	COALESCE(restart_lsn::text, ''),
+	COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), confirmed_flush_lsn), 0)::bigint,
+	pg_size_pretty(COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), confirmed_flush_lsn), 0)),
+	COALESCE(wal_status, '')`
+
+	createSlotQuery = `SELECT slot_name, lsn::text FROM pg_create_logical_replication_slot($1, $2)`
+	dropSlotQuery   = `SELECT pg_drop_replication_slot($1)`
+	listSlotsQuery  = `SELECT ` + slotColumns + ` FROM pg_replication_slots ORDER BY slot_name`
+	slotInfoQuery   = `SELECT ` + slotColumns + ` FROM pg_replication_slots WHERE slot_name = $1`
+)
+
+var (
+	ErrSlotNotFound      = errors.New("replication slot not found")
+	ErrSlotAlreadyExists = errors.New("replication slot already exists")
+)
+
+// NewManager returns a replication slot manager for the postgres database
+// provided.
+func NewManager(ctx context.Context, pgURL string, opts ...Option) (*Manager, error) {
+	pgCfg, err := pglib.ParseConfig(pgURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing postgres connection string: %w", err)
+	}
+
+	conn, err := pglib.NewConn(ctx, pgURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return newManager(conn, pgCfg.Database, opts...), nil
+}
+
+func newManager(querier pglib.Querier, dbName string, opts ...Option) *Manager {
+	if dbName == "" {
+		dbName = "postgres"
+	}
+	m := &Manager{
+		querier: querier,
+		dbName:  dbName,
+		plugin:  defaultPlugin,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// WithPlugin sets the output plugin the replication slots are created with.
+// Defaults to wal2json.
+func WithPlugin(plugin string) Option {
+	return func(m *Manager) {
+		if plugin != "" {
+			m.plugin = plugin
+		}
+	}
+}
+
+// Create creates a logical replication slot using the configured output
+// plugin. If no name is provided, the default pgstream slot name for the
+// database is used.
+func (m *Manager) Create(ctx context.Context, name string) (*Slot, error) {
+	name = m.slotName(name)
+
+	slot := &Slot{}
+	if err := m.querier.QueryRow(ctx, []any{&slot.Name, &slot.ConfirmedFlushLSN}, createSlotQuery, name, m.plugin); err != nil {
+		var errAlreadyExists *pglib.ErrRelationAlreadyExists
+		if errors.As(err, &errAlreadyExists) {
+			return nil, fmt.Errorf("%w: %s", ErrSlotAlreadyExists, name)
+		}
+		return nil, fmt.Errorf("creating replication slot %s: %w", name, err)
+	}
+
+	return m.Info(ctx, slot.Name)
+}
+
+// Drop removes the logical replication slot. If no name is provided, the
+// default pgstream slot name for the database is used.
+func (m *Manager) Drop(ctx context.Context, name string) error {
+	name = m.slotName(name)
+
+	if _, err := m.querier.Exec(ctx, dropSlotQuery, name); err != nil {
+		var errNotExists *pglib.ErrRelationDoesNotExist
+		if errors.As(err, &errNotExists) {
+			return fmt.Errorf("%w: %s", ErrSlotNotFound, name)
+		}
+		return fmt.Errorf("dropping replication slot %s: %w", name, err)
+	}
+	return nil
+}
+
+// List returns all the replication slots in the postgres instance.
+func (m *Manager) List(ctx context.Context) (Slots, error) {
+	rows, err := m.querier.Query(ctx, listSlotsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("listing replication slots: %w", err)
+	}
+	defer rows.Close()
+
+	slots := Slots{}
+	for rows.Next() {
+		slot := &Slot{}
+		if err := rows.Scan(slot.scanDest()...); err != nil {
+			return nil, fmt.Errorf("scanning replication slot: %w", err)
+		}
+		slots = append(slots, slot)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return slots, nil
+}
+
+// Info returns the details of the replication slot, including the lag of the
+// slot consumer. If no name is provided, the default pgstream slot name for
+// the database is used.
+func (m *Manager) Info(ctx context.Context, name string) (*Slot, error) {
+	name = m.slotName(name)
+
+	slot := &Slot{}
+	if err := m.querier.QueryRow(ctx, slot.scanDest(), slotInfoQuery, name); err != nil {
+		if errors.Is(err, pglib.ErrNoRows) {
+			return nil, fmt.Errorf("%w: %s", ErrSlotNotFound, name)
+		}
+		return nil, fmt.Errorf("retrieving replication slot %s: %w", name, err)
+	}
+
+	return slot, nil
+}
+
+func (m *Manager) Close(ctx context.Context) error {
+	return m.querier.Close(ctx)
+}
+
+func (m *Manager) slotName(name string) string {
+	if name != "" {
+		return name
+	}
+	return pglib.DefaultReplicationSlotName(m.dbName)
+}
+
+func (s *Slot) scanDest() []any {
+	return []any{
+		&s.Name,
+		&s.Plugin,
+		&s.SlotType,
+		&s.Database,
+		&s.Active,
+		&s.ActivePID,
+		&s.ConfirmedFlushLSN,
+		&s.RestartLSN,
+		&s.LagBytes,
+		&s.LagSize,
+		&s.WALStatus,
+	}
+}
+
+func (s *Slot) PrettyPrint() string {
+	if s == nil {
+		return ""
+	}
+
+	var prettyPrint strings.Builder
+	prettyPrint.WriteString(fmt.Sprintf("Replication slot %s:\n", s.Name))
+	prettyPrint.WriteString(fmt.Sprintf(" - Plugin: %s\n", s.Plugin))
+	prettyPrint.WriteString(fmt.Sprintf(" - Slot type: %s\n", s.SlotType))
+	prettyPrint.WriteString(fmt.Sprintf(" - Database: %s\n", s.Database))
+	prettyPrint.WriteString(fmt.Sprintf(" - Active: %t\n", s.Active))
+	if s.Active {
+		prettyPrint.WriteString(fmt.Sprintf(" - Active PID: %d\n", s.ActivePID))
+	}
+	prettyPrint.WriteString(fmt.Sprintf(" - Confirmed flush LSN: %s\n", s.ConfirmedFlushLSN))
+	prettyPrint.WriteString(fmt.Sprintf(" - Restart LSN: %s\n", s.RestartLSN))
+	prettyPrint.WriteString(fmt.Sprintf(" - Lag: %d bytes (%s)\n", s.LagBytes, s.LagSize))
+	if s.WALStatus != "" {
+		prettyPrint.WriteString(fmt.Sprintf(" - WAL status: %s\n", s.WALStatus))
+	}
+
+	return prettyPrint.String()
+}
+
+func (s Slots) PrettyPrint() string {
+	if len(s) == 0 {
+		return "No replication slots found"
+	}
+
+	var prettyPrint strings.Builder
+	for i, slot := range s {
+		if i > 0 {
+			prettyPrint.WriteByte('\n')
+		}
+		prettyPrint.WriteString(slot.PrettyPrint())
+	}
+
+	return prettyPrint.String()
+}
@@ -0,0 +1,361 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package slot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	pglib "github.com/xataio/pgstream/internal/postgres"
+	pgmocks "github.com/xataio/pgstream/internal/postgres/mocks"
+)
+
+const (
+	testDBName      = "test-db"
+	testSlot        = "test_slot"
+	testDefaultSlot = "pgstream_test_db_slot"
+)
+
+var errTest = errors.New("oh noes")
+
+func testSlotInfo(name string) *Slot {
+	return &Slot{
+		Name:              name,
+		Plugin:            "wal2json",
+		SlotType:          "logical",
+		Database:          testDBName,
+		Active:            true,
+		ActivePID:         1234,
+		ConfirmedFlushLSN: "0/16B3748",
+		RestartLSN:        "0/16B3710",
+		LagBytes:          2097152,
+		LagSize:           "2048 kB",
+		WALStatus:         "reserved",
+	}
+}
+
+func scanSlot(slot *Slot, dest []any) error {
+	values := slot.scanDest()
+	if len(dest) != len(values) {
+		return fmt.Errorf("unexpected number of scan destinations: %d", len(dest))
+	}
+	for i := range dest {
+		switch d := dest[i].(type) {
+		case *string:
+			*d = *(values[i].(*string))
+		case *bool:
+			*d = *(values[i].(*bool))
+		case *int32:
+			*d = *(values[i].(*int32))
+		case *int64:
+			*d = *(values[i].(*int64))
+		default:
+			return fmt.Errorf("unexpected scan type: %T", d)
+		}
+	}
+	return nil
+}
+
+func TestManager_Create(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		slotName string
+		plugin   string
+		querier  *pgmocks.Querier
+
+		wantSlot *Slot
+		wantErr  error
+	}{
+		{
+			name:     "ok",
+			slotName: testSlot,
+			querier: &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					switch query {
+					case createSlotQuery:
+						require.Equal(t, []any{testSlot, defaultPlugin}, args)
+						*(dest[0].(*string)) = testSlot
+						*(dest[1].(*string)) = "0/16B3748"
+						return nil
+					case slotInfoQuery:
+						require.Equal(t, []any{testSlot}, args)
+						return scanSlot(testSlotInfo(testSlot), dest)
+					default:
+						return fmt.Errorf("unexpected query: %s", query)
+					}
+				},
+			},
+
+			wantSlot: testSlotInfo(testSlot),
+			wantErr:  nil,
+		},
+		{
+			name:     "ok - default slot name",
+			slotName: "",
+			querier: &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					require.Equal(t, testDefaultSlot, args[0])
+					switch query {
+					case createSlotQuery:
+						*(dest[0].(*string)) = testDefaultSlot
+						return nil
+					case slotInfoQuery:
+						return scanSlot(testSlotInfo(testDefaultSlot), dest)
+					default:
+						return fmt.Errorf("unexpected query: %s", query)
+					}
+				},
+			},
+
+			wantSlot: testSlotInfo(testDefaultSlot),
+			wantErr:  nil,
+		},
+		{
+			name:     "ok - configured plugin",
+			slotName: testSlot,
+			plugin:   "decoderbufs",
+			querier: &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					switch query {
+					case createSlotQuery:
+						require.Equal(t, []any{testSlot, "decoderbufs"}, args)
+						*(dest[0].(*string)) = testSlot
+						return nil
+					case slotInfoQuery:
+						return scanSlot(testSlotInfo(testSlot), dest)
+					default:
+						return fmt.Errorf("unexpected query: %s", query)
+					}
+				},
+			},
+
+			wantSlot: testSlotInfo(testSlot),
+			wantErr:  nil,
+		},
+		{
+			name:     "error - slot already exists",
+			slotName: testSlot,
+			querier: &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					return &pglib.ErrRelationAlreadyExists{Details: "replication slot already exists"}
+				},
+			},
+
+			wantErr: ErrSlotAlreadyExists,
+		},
+		{
+			name:     "error - creating slot",
+			slotName: testSlot,
+			querier: &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					return errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := newManager(tc.querier, testDBName, WithPlugin(tc.plugin))
+			slot, err := m.Create(context.Background(), tc.slotName)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantSlot, slot)
+		})
+	}
+}
+
+func TestManager_Drop(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		execErr error
+
+		wantErr error
+	}{
+		{
+			name:    "ok",
+			execErr: nil,
+			wantErr: nil,
+		},
+		{
+			name:    "error - slot not found",
+			execErr: &pglib.ErrRelationDoesNotExist{Details: "replication slot does not exist"},
+			wantErr: ErrSlotNotFound,
+		},
+		{
+			name:    "error - dropping slot",
+			execErr: errTest,
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := newManager(&pgmocks.Querier{
+				ExecFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.CommandTag, error) {
+					require.Equal(t, dropSlotQuery, query)
+					require.Equal(t, []any{testSlot}, args)
+					return pglib.CommandTag{}, tc.execErr
+				},
+			}, testDBName)
+
+			err := m.Drop(context.Background(), testSlot)
+			require.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestManager_List(t *testing.T) {
+	t.Parallel()
+
+	testSlots := Slots{testSlotInfo("slot_a"), testSlotInfo("slot_b")}
+
+	tests := []struct {
+		name    string
+		querier *pgmocks.Querier
+
+		wantSlots Slots
+		wantErr   error
+	}{
+		{
+			name: "ok",
+			querier: &pgmocks.Querier{
+				QueryFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.Rows, error) {
+					require.Equal(t, listSlotsQuery, query)
+					return &pgmocks.Rows{
+						NextFn: func(i uint) bool { return i <= uint(len(testSlots)) },
+						ScanFn: func(i uint, dest ...any) error {
+							return scanSlot(testSlots[i-1], dest)
+						},
+						ErrFn: func() error { return nil },
+					}, nil
+				},
+			},
+
+			wantSlots: testSlots,
+			wantErr:   nil,
+		},
+		{
+			name: "error - querying slots",
+			querier: &pgmocks.Querier{
+				QueryFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.Rows, error) {
+					return nil, errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+		{
+			name: "error - scanning slot",
+			querier: &pgmocks.Querier{
+				QueryFn: func(ctx context.Context, i uint, query string, args ...any) (pglib.Rows, error) {
+					return &pgmocks.Rows{
+						NextFn: func(i uint) bool { return i == 1 },
+						ScanFn: func(i uint, dest ...any) error { return errTest },
+						ErrFn:  func() error { return nil },
+					}, nil
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := newManager(tc.querier, testDBName)
+			slots, err := m.List(context.Background())
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantSlots, slots)
+		})
+	}
+}
+
+func TestManager_Info(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		querier *pgmocks.Querier
+
+		wantSlot *Slot
+		wantErr  error
+	}{
+		{
+			name: "ok",
+			querier: &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					require.Equal(t, slotInfoQuery, query)
+					require.Equal(t, []any{testSlot}, args)
+					return scanSlot(testSlotInfo(testSlot), dest)
+				},
+			},
+
+			wantSlot: testSlotInfo(testSlot),
+			wantErr:  nil,
+		},
+		{
+			name: "error - slot not found",
+			querier: &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					return pglib.ErrNoRows
+				},
+			},
+
+			wantErr: ErrSlotNotFound,
+		},
+		{
+			name: "error - retrieving slot",
+			querier: &pgmocks.Querier{
+				QueryRowFn: func(ctx context.Context, dest []any, query string, args ...any) error {
+					return errTest
+				},
+			},
+
+			wantErr: errTest,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := newManager(tc.querier, testDBName)
+			slot, err := m.Info(context.Background(), testSlot)
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.wantSlot, slot)
+		})
+	}
+}
+
+func TestSlot_PrettyPrint(t *testing.T) {
+	t.Parallel()
+
+	want := `Replication slot test_slot:
+ - Plugin: wal2json
+ - Slot type: logical
+ - Database: test-db
+ - Active: true
+ - Active PID: 1234
+ - Confirmed flush LSN: 0/16B3748
+ - Restart LSN: 0/16B3710
+ - Lag: 2097152 bytes (2048 kB)
+ - WAL status: reserved
+`
+	require.Equal(t, want, testSlotInfo(testSlot).PrettyPrint())
+	require.Equal(t, "No replication slots found", Slots{}.PrettyPrint())
+}
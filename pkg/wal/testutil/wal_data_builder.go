@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build test
+
+package testutil
+
+import (
+	"slices"
+
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// DataBuilder is a fluent builder for wal data test fixtures.
+//
+//	data := testutil.NewDataBuilder().
+//		WithAction(wal.ActionInsert).
+//		WithTable("public", "users").
+//		WithColumn("id", "integer", 1).
+//		WithIdentity("id", "integer", 1).
+//		Build()
+type DataBuilder struct {
+	data wal.Data
+}
+
+// NewDataBuilder returns a builder for an insert event with no columns.
+func NewDataBuilder() *DataBuilder {
+	return &DataBuilder{
+		data: wal.Data{
+			Action: string(wal.ActionInsert),
+		},
+	}
+}
+
+func (b *DataBuilder) WithAction(action wal.Action) *DataBuilder {
+	b.data.Action = string(action)
+	return b
+}
+
+func (b *DataBuilder) WithTimestamp(timestamp string) *DataBuilder {
+	b.data.Timestamp = timestamp
+	return b
+}
+
+func (b *DataBuilder) WithLSN(lsn string) *DataBuilder {
+	b.data.LSN = lsn
+	return b
+}
+
+func (b *DataBuilder) WithTable(schema, table string) *DataBuilder {
+	b.data.Schema = schema
+	b.data.Table = table
+	return b
+}
+
+func (b *DataBuilder) WithXID(xid uint32) *DataBuilder {
+	b.data.XID = xid
+	return b
+}
+
+func (b *DataBuilder) WithSeq(seq uint64) *DataBuilder {
+	b.data.Seq = seq
+	return b
+}
+
+func (b *DataBuilder) WithMetadata(metadata wal.Metadata) *DataBuilder {
+	b.data.Metadata = metadata
+	return b
+}
+
+// WithColumn appends a column with the name, type and value on input.
+func (b *DataBuilder) WithColumn(name, colType string, value any) *DataBuilder {
+	return b.WithColumns(wal.Column{Name: name, Type: colType, Value: value})
+}
+
+// WithColumns appends the columns on input, for when other column fields,
+// such as the pgstream id, need to be set.
+func (b *DataBuilder) WithColumns(columns ...wal.Column) *DataBuilder {
+	b.data.Columns = append(b.data.Columns, columns...)
+	return b
+}
+
+// WithIdentity appends an identity column with the name, type and value on
+// input.
+func (b *DataBuilder) WithIdentity(name, colType string, value any) *DataBuilder {
+	return b.WithIdentityColumns(wal.Column{Name: name, Type: colType, Value: value})
+}
+
+func (b *DataBuilder) WithIdentityColumns(columns ...wal.Column) *DataBuilder {
+	b.data.Identity = append(b.data.Identity, columns...)
+	return b
+}
+
+// Build returns the wal data. The builder can keep being used afterwards
+// without modifying the returned data.
+func (b *DataBuilder) Build() wal.Data {
+	data := b.data
+	data.Columns = slices.Clone(b.data.Columns)
+	data.Identity = slices.Clone(b.data.Identity)
+	data.Metadata.InternalColIDs = slices.Clone(b.data.Metadata.InternalColIDs)
+	return data
+}
+
+// BuildEvent returns a wal event with the wal data and the commit position on
+// input.
+func (b *DataBuilder) BuildEvent(position wal.CommitPosition) *wal.Event {
+	data := b.Build()
+	return &wal.Event{
+		Data:           &data,
+		CommitPosition: position,
+	}
+}
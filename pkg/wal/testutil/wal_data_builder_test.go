@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build test
+
+package testutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestDataBuilder(t *testing.T) {
+	t.Parallel()
+
+	builder := NewDataBuilder().
+		WithAction(wal.ActionUpdate).
+		WithTimestamp("2024-01-01 00:00:00+00").
+		WithLSN("0/1").
+		WithTable("public", "users").
+		WithXID(10).
+		WithSeq(2).
+		WithMetadata(wal.Metadata{TablePgstreamID: "t1", InternalColIDs: []string{"t1-1"}}).
+		WithColumns(wal.Column{ID: "t1-1", Name: "id", Type: "bigint", Value: int64(1)}).
+		WithColumn("name", "text", "alice").
+		WithIdentity("id", "bigint", int64(1))
+
+	data := builder.Build()
+	require.Equal(t, wal.Data{
+		Action:    "U",
+		Timestamp: "2024-01-01 00:00:00+00",
+		LSN:       "0/1",
+		Schema:    "public",
+		Table:     "users",
+		XID:       10,
+		Seq:       2,
+		Metadata:  wal.Metadata{TablePgstreamID: "t1", InternalColIDs: []string{"t1-1"}},
+		Columns: []wal.Column{
+			{ID: "t1-1", Name: "id", Type: "bigint", Value: int64(1)},
+			{Name: "name", Type: "text", Value: "alice"},
+		},
+		Identity: []wal.Column{
+			{Name: "id", Type: "bigint", Value: int64(1)},
+		},
+	}, data)
+
+	// the built data is not modified by further use of the builder
+	builder.WithColumn("email", "text", "alice@example.com")
+	require.Len(t, data.Columns, 2)
+	data.Columns[0].Value = int64(2)
+	require.Equal(t, int64(1), builder.Build().Columns[0].Value)
+
+	event := builder.BuildEvent(wal.CommitPosition("0/1"))
+	require.Equal(t, wal.CommitPosition("0/1"), event.CommitPosition)
+	require.Len(t, event.Data.Columns, 3)
+}
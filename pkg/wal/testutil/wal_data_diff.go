@@ -0,0 +1,139 @@
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build test
+
+// Package testutil provides helpers to build and compare wal data in tests. It
+// is only built with the "test" build tag, so that it's not included in the
+// production binaries.
+package testutil
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+// ColumnDiff is a difference found between two wal data values.
+type ColumnDiff struct {
+	// Field is the path of the field that differs, such as "action",
+	// "columns[name].value", or "identity[id]" when the column is missing on
+	// one of the sides.
+	Field string
+	A     any
+	B     any
+	// MissingInA and MissingInB are set when the column is only present in
+	// one of the wal data values.
+	MissingInA bool
+	MissingInB bool
+}
+
+func (d ColumnDiff) String() string {
+	return fmt.Sprintf("%s: %s != %s", d.Field, formatDiffValue(d.A, d.MissingInA), formatDiffValue(d.B, d.MissingInB))
+}
+
+// AssertDataEqual fails the test if the wal data values on input are not
+// equal, printing the differences field by field.
+func AssertDataEqual(t testing.TB, want, got wal.Data) {
+	t.Helper()
+
+	diffs := DataDiff(want, got)
+	if len(diffs) == 0 {
+		return
+	}
+
+	var diffStr strings.Builder
+	for _, diff := range diffs {
+		diffStr.WriteString("\n\t")
+		diffStr.WriteString(diff.String())
+	}
+	t.Errorf("wal data not equal (want != got):%s", diffStr.String())
+}
+
+// DataDiff returns the differences between the wal data values on input. The
+// columns and identity columns are matched by name, so their order is not
+// taken into account. It returns an empty slice if the values are equal.
+func DataDiff(a, b wal.Data) []ColumnDiff {
+	diffs := []ColumnDiff{}
+	compareField := func(field string, valueA, valueB any) {
+		if !reflect.DeepEqual(valueA, valueB) {
+			diffs = append(diffs, ColumnDiff{Field: field, A: valueA, B: valueB})
+		}
+	}
+
+	compareField("action", a.Action, b.Action)
+	compareField("timestamp", a.Timestamp, b.Timestamp)
+	compareField("lsn", a.LSN, b.LSN)
+	compareField("schema", a.Schema, b.Schema)
+	compareField("table", a.Table, b.Table)
+	compareField("xid", a.XID, b.XID)
+	compareField("seq", a.Seq, b.Seq)
+	compareField("metadata", a.Metadata, b.Metadata)
+
+	diffs = append(diffs, columnsDiff("columns", a.Columns, b.Columns)...)
+	diffs = append(diffs, columnsDiff("identity", a.Identity, b.Identity)...)
+	return diffs
+}
+
+func columnsDiff(field string, a, b []wal.Column) []ColumnDiff {
+	diffs := []ColumnDiff{}
+	columnsB := make(map[string]wal.Column, len(b))
+	for _, col := range b {
+		columnsB[col.Name] = col
+	}
+
+	seen := make(map[string]struct{}, len(a))
+	for _, colA := range a {
+		seen[colA.Name] = struct{}{}
+		colB, found := columnsB[colA.Name]
+		if !found {
+			diffs = append(diffs, ColumnDiff{
+				Field:      fmt.Sprintf("%s[%s]", field, colA.Name),
+				A:          colA,
+				MissingInB: true,
+			})
+			continue
+		}
+
+		columnField := func(name string) string {
+			return fmt.Sprintf("%s[%s].%s", field, colA.Name, name)
+		}
+		if colA.ID != colB.ID {
+			diffs = append(diffs, ColumnDiff{Field: columnField("id"), A: colA.ID, B: colB.ID})
+		}
+		if colA.Type != colB.Type {
+			diffs = append(diffs, ColumnDiff{Field: columnField("type"), A: colA.Type, B: colB.Type})
+		}
+		if !reflect.DeepEqual(colA.Value, colB.Value) {
+			diffs = append(diffs, ColumnDiff{Field: columnField("value"), A: colA.Value, B: colB.Value})
+		}
+	}
+
+	for _, colB := range b {
+		if _, found := seen[colB.Name]; found {
+			continue
+		}
+		diffs = append(diffs, ColumnDiff{
+			Field:      fmt.Sprintf("%s[%s]", field, colB.Name),
+			B:          colB,
+			MissingInA: true,
+		})
+	}
+
+	return diffs
+}
+
+// formatDiffValue includes the type of the value, since values that print the
+// same can still differ in type (int vs int64).
+func formatDiffValue(v any, missing bool) string {
+	switch {
+	case missing:
+		return "<missing>"
+	case v == nil:
+		return "<nil>"
+	default:
+		return fmt.Sprintf("%T(%+v)", v, v)
+	}
+}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build test
+
+package testutil
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xataio/pgstream/pkg/wal"
+)
+
+func TestDataDiff(t *testing.T) {
+	t.Parallel()
+
+	testData := func() wal.Data {
+		return NewDataBuilder().
+			WithTable("public", "users").
+			WithLSN("0/1").
+			WithColumns(wal.Column{ID: "col-1", Name: "id", Type: "bigint", Value: int64(1)}).
+			WithColumn("name", "text", "alice").
+			WithColumn("tags", "text[]", []string{"a", "b"}).
+			WithIdentity("id", "bigint", int64(1)).
+			Build()
+	}
+
+	tests := []struct {
+		name      string
+		a         wal.Data
+		b         wal.Data
+		wantDiffs []ColumnDiff
+	}{
+		{
+			name:      "equal",
+			a:         testData(),
+			b:         testData(),
+			wantDiffs: []ColumnDiff{},
+		},
+		{
+			name: "equal - different column order",
+			a:    testData(),
+			b: func() wal.Data {
+				d := testData()
+				d.Columns[0], d.Columns[1] = d.Columns[1], d.Columns[0]
+				return d
+			}(),
+			wantDiffs: []ColumnDiff{},
+		},
+		{
+			name: "top level fields differ",
+			a:    testData(),
+			b: func() wal.Data {
+				d := testData()
+				d.Action = string(wal.ActionUpdate)
+				d.LSN = "0/2"
+				return d
+			}(),
+			wantDiffs: []ColumnDiff{
+				{Field: "action", A: "I", B: "U"},
+				{Field: "lsn", A: "0/1", B: "0/2"},
+			},
+		},
+		{
+			name: "column fields differ",
+			a:    testData(),
+			b: func() wal.Data {
+				d := testData()
+				d.Columns[0] = wal.Column{ID: "col-2", Name: "id", Type: "integer", Value: 1}
+				d.Columns[2].Value = []string{"a"}
+				d.Identity[0].Value = int64(2)
+				return d
+			}(),
+			wantDiffs: []ColumnDiff{
+				{Field: "columns[id].id", A: "col-1", B: "col-2"},
+				{Field: "columns[id].type", A: "bigint", B: "integer"},
+				{Field: "columns[id].value", A: int64(1), B: 1},
+				{Field: "columns[tags].value", A: []string{"a", "b"}, B: []string{"a"}},
+				{Field: "identity[id].value", A: int64(1), B: int64(2)},
+			},
+		},
+		{
+			name: "missing columns",
+			a:    testData(),
+			b: func() wal.Data {
+				d := testData()
+				d.Columns = append(d.Columns[:1], wal.Column{Name: "email", Type: "text", Value: "alice@example.com"})
+				return d
+			}(),
+			wantDiffs: []ColumnDiff{
+				{Field: "columns[name]", A: wal.Column{Name: "name", Type: "text", Value: "alice"}, MissingInB: true},
+				{Field: "columns[tags]", A: wal.Column{Name: "tags", Type: "text[]", Value: []string{"a", "b"}}, MissingInB: true},
+				{Field: "columns[email]", B: wal.Column{Name: "email", Type: "text", Value: "alice@example.com"}, MissingInA: true},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.wantDiffs, DataDiff(tc.a, tc.b))
+		})
+	}
+}
+
+func TestColumnDiff_String(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		diff ColumnDiff
+
+		wantStr string
+	}{
+		{
+			name:    "values differ",
+			diff:    ColumnDiff{Field: "columns[id].value", A: int64(1), B: 1},
+			wantStr: "columns[id].value: int64(1) != int(1)",
+		},
+		{
+			name:    "nil value",
+			diff:    ColumnDiff{Field: "columns[id].value", A: nil, B: "a"},
+			wantStr: "columns[id].value: <nil> != string(a)",
+		},
+		{
+			name:    "missing column",
+			diff:    ColumnDiff{Field: "columns[id]", A: wal.Column{Name: "id"}, MissingInB: true},
+			wantStr: "columns[id]: wal.Column({ID: Name:id Type: Value:<nil>}) != <missing>",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.wantStr, tc.diff.String())
+		})
+	}
+}
+
+type mockTB struct {
+	testing.TB
+	errors []string
+}
+
+func (m *mockTB) Helper() {}
+
+func (m *mockTB) Errorf(format string, args ...any) {
+	m.errors = append(m.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertDataEqual(t *testing.T) {
+	t.Parallel()
+
+	want := NewDataBuilder().WithTable("public", "users").WithColumn("name", "text", "alice").Build()
+
+	t.Run("equal", func(t *testing.T) {
+		t.Parallel()
+
+		tb := &mockTB{}
+		AssertDataEqual(tb, want, want)
+		require.Empty(t, tb.errors)
+	})
+
+	t.Run("not equal", func(t *testing.T) {
+		t.Parallel()
+
+		got := NewDataBuilder().WithTable("public", "users").WithColumn("name", "text", "bob").Build()
+
+		tb := &mockTB{}
+		AssertDataEqual(tb, want, got)
+		require.Equal(t, []string{"wal data not equal (want != got):\n\tcolumns[name].value: string(alice) != string(bob)"}, tb.errors)
+	})
+}
@@ -16,6 +16,15 @@ import (
 type Event struct {
 	Data           *Data
 	CommitPosition CommitPosition
+	// Kafka contains the kafka specific properties of the event, if any.
+	Kafka *KafkaMetadata
+}
+
+// KafkaMetadata contains the properties used to write the event to kafka.
+type KafkaMetadata struct {
+	// PartitionKey is the key of the kafka message, which determines the
+	// partition the event is routed to.
+	PartitionKey []byte
 }
 
 // Data contains the wal data properties identifying the table operation.
@@ -27,7 +36,18 @@ type Data struct {
 	Table     string   `json:"table"`
 	Columns   []Column `json:"columns"`
 	Identity  []Column `json:"identity"`
-	Metadata  Metadata `json:"metadata"` // pgstream specific metadata
+	// Before is the full before image of the row for update and delete
+	// events, when it's been filled in by the before/after normalizer. The
+	// identity is left as provided by postgres, since the sinks use it to
+	// identify the row.
+	Before   []Column `json:"before,omitempty"`
+	Metadata Metadata `json:"metadata"` // pgstream specific metadata
+	// XID is the id of the transaction the event belongs to. Only available
+	// when the transaction records are included in the replication.
+	XID uint32 `json:"xid,omitempty"`
+	// Seq is the position of the event within its transaction, starting at 1.
+	// Only set when the events are sequenced by transaction.
+	Seq uint64 `json:"seq,omitempty"`
 }
 
 // Metadata is pgstream specific properties to help identify the id/version
@@ -42,6 +62,19 @@ type Metadata struct {
 	// This is the Pgstream ID of the "version" column. We track this specifically, as we extract it from the event
 	// in order to use as the version when working with optimistic concurrency checks.
 	InternalColVersion string `json:"version_col_pgstream_id"`
+	// PipelineVersion is the hash of the configuration of the pipeline that
+	// processed the event.
+	PipelineVersion string `json:"_pipeline_version,omitempty"`
+	// Audit is the audit context of the transaction that produced the event,
+	// when correlated with the pg_audit logs.
+	Audit *AuditInfo `json:"audit,omitempty"`
+}
+
+// AuditInfo is the audit context of a change, as logged by pg_audit.
+type AuditInfo struct {
+	User        string `json:"user"`
+	Application string `json:"application"`
+	Command     string `json:"command"`
 }
 
 type Column struct {
@@ -52,6 +85,19 @@ type Column struct {
 	Value any    `json:"value"`
 }
 
+// Action is the table operation of a wal event, as found in Data.Action.
+type Action string
+
+const (
+	ActionInsert   Action = "I"
+	ActionUpdate   Action = "U"
+	ActionDelete   Action = "D"
+	ActionTruncate Action = "T"
+	// ActionCommit marks the end of a transaction. Commit events are only
+	// emitted by the listener when requested, and are not table operations.
+	ActionCommit Action = "C"
+)
+
 const ZeroLSN = "0/0"
 
 const iso8601Format = "2006-01-02 15:04:05.999999+00"
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wal
+
+import (
+	"time"
+)
+
+// approximate overhead of the serialisation of the values and structures,
+// such as the field names, quotes and separators
+const (
+	dataOverheadBytes     = 192
+	columnOverheadBytes   = 32
+	stringOverheadBytes   = 16
+	numericSizeBytes      = 8
+	timeSizeBytes         = 32
+	defaultValueSizeBytes = 16
+)
+
+// EstimatedSize returns the approximate size in bytes of the data on input
+// once serialised. It walks the columns and sums estimates based on the value
+// types, without serialising nor allocating, so that it can be used to make
+// batching decisions on every event.
+func EstimatedSize(d Data) int {
+	size := dataOverheadBytes +
+		len(d.Action) + len(d.Timestamp) + len(d.LSN) + len(d.Schema) + len(d.Table) +
+		len(d.Metadata.TablePgstreamID) + len(d.Metadata.InternalColVersion) +
+		// xid.ID string representation
+		20
+	for _, id := range d.Metadata.InternalColIDs {
+		size += len(id) + stringOverheadBytes
+	}
+	return size + columnsSize(d.Columns) + columnsSize(d.Identity)
+}
+
+func columnsSize(columns []Column) int {
+	size := 0
+	for i := range columns {
+		size += columnOverheadBytes + len(columns[i].ID) + len(columns[i].Name) + len(columns[i].Type) + valueSize(columns[i].Value)
+	}
+	return size
+}
+
+func valueSize(v any) int {
+	switch value := v.(type) {
+	case nil:
+		return 4
+	case bool:
+		return 5
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return numericSizeBytes
+	case string:
+		return len(value) + stringOverheadBytes
+	case []byte:
+		return len(value) + stringOverheadBytes
+	case time.Time, *time.Time:
+		return timeSizeBytes
+	case []any:
+		size := stringOverheadBytes
+		for _, elem := range value {
+			size += valueSize(elem)
+		}
+		return size
+	case map[string]any:
+		size := stringOverheadBytes
+		for k, elem := range value {
+			size += len(k) + stringOverheadBytes + valueSize(elem)
+		}
+		return size
+	default:
+		return defaultValueSizeBytes
+	}
+}
@@ -0,0 +1,34 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wal
+
+import loglib "github.com/xataio/pgstream/pkg/log"
+
+// LogFields returns the log fields identifying the event, to be added to the
+// log lines about its processing.
+func (e *Event) LogFields() loglib.Fields {
+	if e == nil {
+		return loglib.Fields{}
+	}
+	fields := e.Data.LogFields()
+	if e.CommitPosition != "" {
+		fields[loglib.CommitPositionField] = string(e.CommitPosition)
+	}
+	return fields
+}
+
+// LogFields returns the log fields identifying the table operation.
+func (d *Data) LogFields() loglib.Fields {
+	if d == nil {
+		return loglib.Fields{}
+	}
+	fields := loglib.Fields{
+		loglib.SchemaField: d.Schema,
+		loglib.TableField:  d.Table,
+		loglib.ActionField: d.Action,
+	}
+	if d.LSN != "" {
+		fields[loglib.LSNField] = d.LSN
+	}
+	return fields
+}
@@ -0,0 +1,64 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package wal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	loglib "github.com/xataio/pgstream/pkg/log"
+)
+
+func TestEvent_LogFields(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		event *Event
+
+		wantFields loglib.Fields
+	}{
+		{
+			name: "data event",
+			event: &Event{
+				Data: &Data{
+					Action: "I",
+					Schema: "public",
+					Table:  "test",
+					LSN:    "0/15D6B88",
+				},
+				CommitPosition: "0/15D6B88",
+			},
+
+			wantFields: loglib.Fields{
+				loglib.SchemaField:         "public",
+				loglib.TableField:          "test",
+				loglib.ActionField:         "I",
+				loglib.LSNField:            "0/15D6B88",
+				loglib.CommitPositionField: "0/15D6B88",
+			},
+		},
+		{
+			name:  "keep alive event",
+			event: &Event{CommitPosition: "0/15D6B88"},
+
+			wantFields: loglib.Fields{
+				loglib.CommitPositionField: "0/15D6B88",
+			},
+		},
+		{
+			name:  "nil event",
+			event: nil,
+
+			wantFields: loglib.Fields{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.wantFields, tc.event.LogFields())
+		})
+	}
+}
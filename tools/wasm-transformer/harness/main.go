@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Harness to try out a custom wasm transformer module outside of pgstream. It
+// loads the module with the same transformer used by pgstream, and prints the
+// transformed output of each of the values provided as arguments:
+//
+//	go run ./tools/wasm-transformer/harness -module sample.wasm -config '{"keep_last": 2}' 4111111111111111
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/xataio/pgstream/pkg/transformers"
+)
+
+func main() {
+	modulePath := flag.String("module", "", "Path to the wasm module")
+	columnType := flag.String("type", "text", "Postgres type of the column passed to the module")
+	config := flag.String("config", "{}", "Module config, in JSON format")
+	onError := flag.String("on-error", "error", "Error policy of the transformer (error, passthrough or null)")
+	timeout := flag.String("timeout", "1s", "Timeout of each transformation")
+	memoryLimitMB := flag.Int("memory-limit-mb", 64, "Memory limit of the module instances, in megabytes")
+	flag.Parse()
+
+	moduleConfig := map[string]any{}
+	if err := json.Unmarshal([]byte(*config), &moduleConfig); err != nil {
+		log.Fatalf("parsing module config: %v", err)
+	}
+
+	t, err := transformers.NewWASMTransformer(transformers.ParameterValues{
+		"module_path":     *modulePath,
+		"config":          moduleConfig,
+		"on_error":        *onError,
+		"timeout":         *timeout,
+		"memory_limit_mb": *memoryLimitMB,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer t.Close()
+
+	for _, value := range flag.Args() {
+		output, err := t.Transform(context.Background(), transformers.NewValue(value, *columnType, nil))
+		if err != nil {
+			fmt.Printf("%q -> error: %v\n", value, err) //nolint:forbidigo
+			continue
+		}
+		if output == nil {
+			fmt.Printf("%q -> NULL\n", value) //nolint:forbidigo
+			continue
+		}
+		fmt.Printf("%q -> %q\n", value, output) //nolint:forbidigo
+	}
+}
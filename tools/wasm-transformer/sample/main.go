@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build wasip1
+
+// Sample custom transformer for the pgstream wasm transformer. It masks all
+// but the last characters of the value, as configured in the module config:
+//
+//	config:
+//	  keep_last: 4
+//	  mask_char: "#"
+//
+// Build it with:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o sample.wasm ./tools/wasm-transformer/sample
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xataio/pgstream/pkg/transformers/wasmguest"
+)
+
+const (
+	defaultKeepLast = 4
+	defaultMaskChar = "*"
+)
+
+func init() {
+	wasmguest.Register(mask)
+}
+
+func mask(value []byte, metadata wasmguest.Metadata) ([]byte, error) {
+	if !utf8.Valid(value) {
+		return nil, errors.New("value is not valid utf8")
+	}
+
+	// the config values are decoded from JSON, so numbers are float64
+	keepLast := defaultKeepLast
+	if v, ok := metadata.Config["keep_last"].(float64); ok {
+		keepLast = int(v)
+	}
+	maskChar := defaultMaskChar
+	if v, ok := metadata.Config["mask_char"].(string); ok && v != "" {
+		maskChar = v
+	}
+
+	runes := []rune(string(value))
+	if len(runes) <= keepLast {
+		return value, nil
+	}
+	masked := strings.Repeat(maskChar, len(runes)-keepLast) + string(runes[len(runes)-keepLast:])
+	return []byte(masked), nil
+}
+
+func main() {}